@@ -0,0 +1,60 @@
+// Command generate-openapi writes the OpenAPI 3.0 specification of the
+// StumpfWorks NAS API by introspecting the server's router.
+//
+// Usage:
+//
+//	go run ./cmd/generate-openapi -o ../docs/openapi.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func main() {
+	output := flag.String("o", "openapi.yaml", "Output file (- for stdout)")
+	flag.Parse()
+
+	if err := run(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "generate-openapi: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(output string) error {
+	// Router construction logs; keep the generator quiet
+	if err := logger.InitLogger("error", false); err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	cfg, err := config.Load("")
+	if err != nil {
+		return fmt.Errorf("failed to load default config: %w", err)
+	}
+
+	doc, err := api.GenerateOpenAPI(api.NewRouter(cfg))
+	if err != nil {
+		return err
+	}
+
+	spec, err := doc.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to encode spec: %w", err)
+	}
+
+	if output == "-" {
+		_, err = os.Stdout.Write(spec)
+		return err
+	}
+	if err := os.WriteFile(output, spec, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	fmt.Printf("Wrote %d paths to %s\n", len(doc.Paths), output)
+	return nil
+}
@@ -6,6 +6,11 @@ import (
 	"os"
 
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+
+	// Subsystem packages register their health checks at init time
+	_ "github.com/Stumpf-works/stumpfworks-nas/internal/dependencies"
+	_ "github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	_ "github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
 )
 
 func main() {
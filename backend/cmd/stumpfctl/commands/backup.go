@@ -1,13 +1,25 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/cli"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/client"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+// backupBarWidth is the width of the progress bar of backup run --watch
+const backupBarWidth = 24
+
 // BackupCmd returns the backup management command
 func BackupCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -18,6 +30,7 @@ func BackupCmd() *cobra.Command {
 
 	cmd.AddCommand(backupListCmd())
 	cmd.AddCommand(backupCreateCmd())
+	cmd.AddCommand(backupRunCmd())
 
 	return cmd
 }
@@ -74,3 +87,249 @@ func backupCreateCmd() *cobra.Command {
 		},
 	}
 }
+
+func backupRunCmd() *cobra.Command {
+	var (
+		watch   bool
+		timeout time.Duration
+		token   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "run <job-id>",
+		Short: "Run a backup job now",
+		Long:  "Run a backup job and wait for it to finish, optionally showing its progress live",
+		Args:  cobra.ExactArgs(1),
+		Example: `  stumpfctl backup run job-1
+  stumpfctl backup run job-1 --watch --timeout 2h`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if timeout < 0 {
+				return fmt.Errorf("--timeout must not be negative")
+			}
+
+			apiClient := client.NewClient("http://localhost:8080")
+			apiClient.Token = token
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			var run *client.BackupRun
+			var err error
+			if watch {
+				w := newBackupWatcher(apiClient, args[0], os.Stdout)
+				w.live = term.IsTerminal(int(os.Stdout.Fd()))
+				w.width = terminalWidth
+				run, err = w.run(ctx)
+				if err != nil && w.progress.Error != "" {
+					err = errors.New(w.progress.Error)
+				}
+			} else {
+				cli.PrintInfo("Running backup job %s...", args[0])
+				run, err = apiClient.RunBackupJob(ctx, args[0])
+			}
+
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				cli.PrintError("Timed out after %s, the backup job keeps running on the server", timeout)
+				return err
+			case err != nil:
+				cli.PrintError("Backup job failed: %v", err)
+				return err
+			}
+
+			cli.PrintSuccess("Backup job %s completed: %s in %d files, %s",
+				run.JobName, formatBytes(uint64(run.BytesBackup)), run.FilesBackup,
+				time.Duration(run.Duration)*time.Second)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Show the progress of the run live")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Stop waiting after this long, e.g. 30m (0 = no limit)")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("STUMPFWORKS_TOKEN"), "API token (defaults to $STUMPFWORKS_TOKEN)")
+
+	return cmd
+}
+
+// backupRunner runs backup jobs and streams the events reporting their
+// progress
+type backupRunner interface {
+	RunBackupJob(ctx context.Context, id string) (*client.BackupRun, error)
+	StreamEvents(ctx context.Context, opts client.EventStreamOptions, handle func(client.Event)) (int, error)
+}
+
+// backupProgress is the state of a backup run, as reported by its events
+type backupProgress struct {
+	Percent   float64
+	BytesDone int64
+	Speed     float64       // Bytes per second between the last two events
+	ETA       time.Duration // Negative until known
+	File      string        // File being copied
+	Error     string
+
+	at time.Time // Time of the event that reported BytesDone
+}
+
+// backupWatcher runs a backup job and draws a progress line from the
+// job's backup events
+type backupWatcher struct {
+	runner  backupRunner
+	jobID   string
+	live    bool // Redraw the progress line in place
+	out     io.Writer
+	width   func() int
+	now     func() time.Time
+	refresh time.Duration // Time between redraws of a live progress line
+
+	start    time.Time
+	progress backupProgress
+}
+
+// newBackupWatcher creates a watcher that prints a line per progress event
+func newBackupWatcher(runner backupRunner, jobID string, out io.Writer) *backupWatcher {
+	return &backupWatcher{
+		runner:   runner,
+		jobID:    jobID,
+		out:      out,
+		width:    func() int { return 80 },
+		now:      time.Now,
+		refresh:  time.Second,
+		progress: backupProgress{ETA: -1},
+	}
+}
+
+// run runs the job, drawing its progress until it finishes or ctx is
+// cancelled. The result of the run decides the outcome; events only
+// feed the progress line.
+func (w *backupWatcher) run(ctx context.Context) (*client.BackupRun, error) {
+	streamCtx, stopStream := context.WithCancel(ctx)
+	defer stopStream()
+
+	events := make(chan client.Event, 16)
+	go w.runner.StreamEvents(streamCtx, client.EventStreamOptions{Types: []string{"backup"}}, func(e client.Event) {
+		select {
+		case events <- e:
+		case <-streamCtx.Done():
+		}
+	})
+
+	type result struct {
+		run *client.BackupRun
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		run, err := w.runner.RunBackupJob(ctx, w.jobID)
+		done <- result{run, err}
+	}()
+
+	w.start = w.now()
+	ticker := time.NewTicker(w.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-events:
+			if w.update(e) {
+				w.draw()
+			}
+		case <-ticker.C:
+			if w.live {
+				w.draw()
+			}
+		case r := <-done:
+			if r.err == nil && r.run != nil {
+				w.progress.Percent = 100
+				w.progress.BytesDone = r.run.BytesBackup
+				w.progress.ETA = 0
+			}
+			w.draw()
+			if w.live {
+				fmt.Fprintln(w.out)
+			}
+			return r.run, r.err
+		case <-ctx.Done():
+			if w.live {
+				fmt.Fprintln(w.out)
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// update applies an event to the progress, reporting whether it was an
+// event of the watched job
+func (w *backupWatcher) update(e client.Event) bool {
+	if id, _ := e.Data["jobId"].(string); id != w.jobID {
+		return false
+	}
+
+	p := &w.progress
+	if v, ok := e.Data["percent"].(float64); ok {
+		p.Percent = v
+	}
+	if v, ok := e.Data["bytesDone"].(float64); ok {
+		bytes := int64(v)
+		if !p.at.IsZero() {
+			if dt := e.Timestamp.Sub(p.at).Seconds(); dt > 0 {
+				p.Speed = float64(bytes-p.BytesDone) / dt
+			}
+		}
+		p.BytesDone, p.at = bytes, e.Timestamp
+	}
+	if v, ok := e.Data["etaSeconds"].(float64); ok {
+		p.ETA = time.Duration(v) * time.Second
+	}
+	if v, ok := e.Data["file"].(string); ok && v != "" {
+		p.File = v
+	}
+	if v, ok := e.Data["error"].(string); ok {
+		p.Error = v
+	}
+	return true
+}
+
+// draw prints the progress line, in place if live
+func (w *backupWatcher) draw() {
+	line := w.render(w.width())
+	if w.live {
+		fmt.Fprintf(w.out, "\r\033[K%s", line)
+		return
+	}
+	fmt.Fprintln(w.out, line)
+}
+
+// render returns the progress line: bar, percent, elapsed time, speed, ETA
+// and current file, cut to width
+func (w *backupWatcher) render(width int) string {
+	p := w.progress
+	speed, eta := "-- MB/s", "--:--:--"
+	if p.Speed > 0 {
+		speed = fmt.Sprintf("%.1f MB/s", p.Speed/1e6)
+	}
+	if p.ETA >= 0 {
+		eta = formatClock(p.ETA)
+	}
+	line := fmt.Sprintf("%3.0f%%  %s  %s  ETA %s", p.Percent, formatClock(w.now().Sub(w.start)), speed, eta)
+
+	if width >= watchCompactWidth {
+		filled := int(p.Percent / 100 * backupBarWidth)
+		filled = max(0, min(backupBarWidth, filled))
+		line = fmt.Sprintf("[%s%s] %s", strings.Repeat("█", filled), strings.Repeat("░", backupBarWidth-filled), line)
+	}
+	if p.File != "" {
+		line += "  " + p.File
+	}
+	return fitLine(line, width)
+}
+
+// formatClock formats a duration as h:mm:ss
+func formatClock(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
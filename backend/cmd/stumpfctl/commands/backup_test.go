@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/client"
+)
+
+// backupEvents are the progress events of a run of job-1, with an event of
+// another job in between
+const backupEvents = `id: 1
+event: backup
+data: {"id":1,"type":"backup","action":"started","message":"Backup job nightly started","data":{"jobId":"job-1","bytesDone":0},"timestamp":"2025-11-20T02:00:00Z"}
+
+id: 2
+event: backup
+data: {"id":2,"type":"backup","action":"progress","message":"Backup job nightly running","data":{"jobId":"job-1","bytesDone":25000000,"percent":25,"etaSeconds":30,"file":"photos/2024/IMG_0001.jpg"},"timestamp":"2025-11-20T02:00:10Z"}
+
+id: 3
+event: backup
+data: {"id":3,"type":"backup","action":"progress","message":"Backup job other running","data":{"jobId":"job-2","bytesDone":1,"percent":99},"timestamp":"2025-11-20T02:00:11Z"}
+
+id: 4
+event: backup
+data: {"id":4,"type":"backup","action":"progress","message":"Backup job nightly running","data":{"jobId":"job-1","bytesDone":50000000,"percent":50,"etaSeconds":20,"file":"photos/2024/IMG_0002.jpg"},"timestamp":"2025-11-20T02:00:15Z"}
+
+`
+
+// newBackupServer serves the canned events and answers the run of job-1
+// with runResponse once they are sent. An empty runResponse never answers.
+func newBackupServer(t *testing.T, runStatus int, runResponse string) *httptest.Server {
+	t.Helper()
+	streamed := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/events/stream", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("type"); got != "backup" {
+			t.Errorf("stream type filter = %q, want backup", got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, backupEvents)
+		w.(http.Flusher).Flush()
+		close(streamed)
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("POST /api/v1/backups/jobs/job-1/run", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-streamed:
+		case <-r.Context().Done():
+			return
+		}
+		if runResponse == "" {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(runStatus)
+		fmt.Fprint(w, runResponse)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBackupWatcherExitStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		runStatus   int
+		runResponse string
+		timeout     time.Duration
+		wantErr     string // Empty for exit status 0
+	}{
+		{
+			name:        "completed",
+			runStatus:   http.StatusOK,
+			runResponse: `{"success":true,"data":{"id":"history-1","jobId":"job-1","jobName":"nightly","status":"success","bytesBackup":100000000,"filesBackup":2}}`,
+			timeout:     5 * time.Second,
+		},
+		{
+			name:        "failed",
+			runStatus:   http.StatusInternalServerError,
+			runResponse: `{"success":false,"error":{"code":"INTERNAL_SERVER_ERROR","message":"Failed to run backup job"}}`,
+			timeout:     5 * time.Second,
+			wantErr:     "API error: Failed to run backup job",
+		},
+		{
+			name:    "timeout",
+			timeout: 200 * time.Millisecond,
+			wantErr: context.DeadlineExceeded.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newBackupServer(t, tt.runStatus, tt.runResponse)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			var out bytes.Buffer
+			w := newBackupWatcher(client.NewClient(server.URL), "job-1", &out)
+			run, err := w.run(ctx)
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("run() error = %v, want success", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("run() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantErr == "" {
+				if run == nil || run.Status != "success" {
+					t.Errorf("run() = %+v, want a successful run", run)
+				}
+				lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+				if last := lines[len(lines)-1]; !strings.Contains(last, "100%") || !strings.Contains(last, "ETA 0:00:00") {
+					t.Errorf("last progress line = %q, want 100%% done", last)
+				}
+			}
+			if strings.Contains(out.String(), "99%") {
+				t.Errorf("output shows progress of another job:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestBackupWatcherRender(t *testing.T) {
+	start := time.Date(2025, 11, 20, 2, 0, 0, 0, time.UTC)
+	w := newBackupWatcher(nil, "job-1", nil)
+	w.start = start
+	w.now = func() time.Time { return start.Add(83 * time.Second) }
+
+	if got, want := w.render(80), "[░░░░░░░░░░░░░░░░░░░░░░░░]   0%  0:01:23  -- MB/s  ETA --:--:--"; got != want {
+		t.Errorf("render() before progress =\n%q\nwant\n%q", got, want)
+	}
+
+	for i, data := range []map[string]interface{}{
+		{"jobId": "job-1", "bytesDone": 25e6, "percent": 25.0, "etaSeconds": 30.0, "file": "a.jpg"},
+		{"jobId": "job-1", "bytesDone": 50e6, "percent": 50.0, "etaSeconds": 20.0, "file": "photos/2024/IMG_0002.jpg"},
+	} {
+		w.update(client.Event{Type: "backup", Data: data, Timestamp: start.Add(time.Duration(i) * 5 * time.Second)})
+	}
+
+	want := "[████████████░░░░░░░░░░░░]  50%  0:01:23  5.0 MB/s  ETA 0:00:20  photos/2024/IMG_0002.jpg"
+	if got := w.render(120); got != want {
+		t.Errorf("render() =\n%q\nwant\n%q", got, want)
+	}
+	// Narrow terminals drop the bar and cut the line to fit
+	if got, want := w.render(30), " 50%  0:01:23  5.0 MB/s  ETA …"; got != want {
+		t.Errorf("render(30) =\n%q\nwant\n%q", got, want)
+	}
+}
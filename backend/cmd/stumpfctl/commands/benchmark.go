@@ -0,0 +1,367 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/cli"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/client"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"github.com/spf13/cobra"
+)
+
+// ddSummary matches the summary dd prints when it is done, e.g.
+// 1073741824 bytes (1.1 GB, 1.0 GiB) copied, 2.5 s, 429 MB/s
+var ddSummary = regexp.MustCompile(`(?m)^(\d+) bytes .*copied, ([\d.]+) s`)
+
+// benchmarkSkippedFSTypes are filesystems the disk benchmark does not write to
+var benchmarkSkippedFSTypes = map[string]bool{
+	"tmpfs": true, "devtmpfs": true, "squashfs": true, "overlay": true, "vfat": true,
+}
+
+// benchmarkOptions configures a system benchmark run
+type benchmarkOptions struct {
+	test     string // disk, network, cpu or all
+	duration time.Duration
+	volumes  []string // Directories the disk benchmark writes to
+	server   string   // iperf3 server of the network benchmark
+}
+
+func systemBenchmarkCmd() *cobra.Command {
+	var (
+		opts   benchmarkOptions
+		upload bool
+		token  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "benchmark",
+		Short: "Measure disk, network and CPU performance",
+		Long: `Measure the performance of the NAS hardware, e.g. to compare it before and
+after an upgrade.
+
+disk     writes and reads a 1 GiB file with dd on each volume
+network  runs iperf3 against --server if iperf3 is installed, otherwise it
+         measures the localhost loopback
+cpu      hashes with SHA-256 on all CPU threads for --duration`,
+		Example: `  stumpfctl system benchmark
+  stumpfctl system benchmark --test disk --volume /mnt/tank
+  stumpfctl system benchmark --test network --server 192.168.1.10 --duration 10s
+  stumpfctl system benchmark --upload --token $TOKEN`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch opts.test {
+			case "disk", "network", "cpu", "all":
+			default:
+				return fmt.Errorf("--test must be disk, network, cpu or all")
+			}
+			if opts.duration <= 0 {
+				return fmt.Errorf("--duration must be greater than 0")
+			}
+			cmd.SilenceUsage = true
+
+			results, err := runBenchmark(cmd.Context(), opts, os.Stdout)
+			if len(results) > 0 {
+				printBenchmarkResults(results)
+			}
+			if err != nil {
+				return err
+			}
+
+			if upload {
+				apiClient := client.NewClient("http://localhost:8080")
+				apiClient.Token = token
+				hostname, _ := os.Hostname()
+				saved, err := apiClient.SaveBenchmarkResults(hostname, results)
+				if err != nil {
+					cli.PrintError("Failed to upload the results: %v", err)
+					return err
+				}
+				cli.PrintSuccess("Results uploaded as run %s", saved[0].RunID)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.test, "test", "all", "Benchmark to run: disk, network, cpu or all")
+	cmd.Flags().DurationVar(&opts.duration, "duration", 30*time.Second, "Run time of the network and CPU benchmarks")
+	cmd.Flags().StringSliceVar(&opts.volumes, "volume", nil, "Directory to benchmark the disk of (default: all mounted volumes)")
+	cmd.Flags().StringVar(&opts.server, "server", "", "iperf3 server for the network benchmark")
+	cmd.Flags().BoolVar(&upload, "upload", false, "Store the results on the server for later comparison")
+	cmd.Flags().StringVar(&token, "token", "", "API token for --upload")
+	return cmd
+}
+
+// runBenchmark runs the selected benchmarks and returns their results.
+// A failing disk benchmark does not stop the other volumes or tests.
+func runBenchmark(ctx context.Context, opts benchmarkOptions, log io.Writer) ([]client.BenchmarkResult, error) {
+	var results []client.BenchmarkResult
+	var failed []string
+
+	if opts.test == "disk" || opts.test == "all" {
+		volumes := opts.volumes
+		if len(volumes) == 0 {
+			var err error
+			if volumes, err = benchmarkVolumes(); err != nil {
+				return nil, err
+			}
+		}
+		for _, volume := range volumes {
+			fmt.Fprintf(log, "Benchmarking the disk of %s...\n", volume)
+			r, err := benchmarkDisk(ctx, volume)
+			if err != nil {
+				fmt.Fprintf(log, "Disk benchmark of %s failed: %v\n", volume, err)
+				failed = append(failed, volume)
+				continue
+			}
+			results = append(results, r...)
+		}
+	}
+
+	if opts.test == "network" || opts.test == "all" {
+		fmt.Fprintf(log, "Benchmarking the network for %s...\n", opts.duration)
+		r, err := benchmarkNetwork(ctx, opts.server, opts.duration)
+		if err != nil {
+			return results, fmt.Errorf("network benchmark failed: %w", err)
+		}
+		results = append(results, r...)
+	}
+
+	if opts.test == "cpu" || opts.test == "all" {
+		fmt.Fprintf(log, "Benchmarking the CPU for %s...\n", opts.duration)
+		results = append(results, benchmarkCPU(ctx, opts.duration))
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("disk benchmark failed on %s", strings.Join(failed, ", "))
+	}
+	return results, nil
+}
+
+// printBenchmarkResults prints the results as a table
+func printBenchmarkResults(results []client.BenchmarkResult) {
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		rows[i] = []string{r.Test, r.Target, r.Metric, fmt.Sprintf("%.1f %s", r.Value, r.Unit)}
+	}
+	cli.Table([]string{"Test", "Target", "Metric", "Result"}, rows)
+}
+
+// benchmarkVolumes returns the mount points of the writable volumes
+func benchmarkVolumes() ([]string, error) {
+	output, err := exec.Command("df", "--output=fstype,target").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	var volumes []string
+	for _, line := range strings.Split(string(output), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || benchmarkSkippedFSTypes[fields[0]] {
+			continue
+		}
+		target := fields[1]
+		if strings.HasPrefix(target, "/boot") || strings.HasPrefix(target, "/snap") ||
+			strings.HasPrefix(target, "/sys") || strings.HasPrefix(target, "/proc") ||
+			strings.HasPrefix(target, "/dev") || strings.HasPrefix(target, "/run") {
+			continue
+		}
+		volumes = append(volumes, target)
+	}
+	return volumes, nil
+}
+
+// benchmarkDisk writes a 1 GiB file to dir with dd, flushed to the disk, and
+// reads it back bypassing the page cache
+func benchmarkDisk(ctx context.Context, dir string) ([]client.BenchmarkResult, error) {
+	file := filepath.Join(dir, fmt.Sprintf(".stumpfctl-benchmark-%d", os.Getpid()))
+	defer os.Remove(file)
+
+	write, err := runDD(ctx, "if=/dev/zero", "of="+file, "bs=1M", "count=1024", "conv=fdatasync")
+	if err != nil {
+		return nil, err
+	}
+
+	// Some filesystems do not support O_DIRECT; their reads may be cached
+	readMetric := "read"
+	read, err := runDD(ctx, "if="+file, "of=/dev/null", "bs=1M", "iflag=direct")
+	if err != nil {
+		readMetric = "read (cached)"
+		if read, err = runDD(ctx, "if="+file, "of=/dev/null", "bs=1M"); err != nil {
+			return nil, err
+		}
+	}
+
+	return []client.BenchmarkResult{
+		{Test: "disk", Target: dir, Metric: "write", Value: write, Unit: "MB/s"},
+		{Test: "disk", Target: dir, Metric: readMetric, Value: read, Unit: "MB/s"},
+	}, nil
+}
+
+// runDD runs dd and returns its throughput in MB/s
+func runDD(ctx context.Context, args ...string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "dd", args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("dd %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return parseDDThroughput(string(output))
+}
+
+// parseDDThroughput returns the throughput in MB/s from the summary of dd.
+// It is computed from the bytes and seconds, since dd picks the unit of
+// its own rate.
+func parseDDThroughput(output string) (float64, error) {
+	m := ddSummary.FindStringSubmatch(output)
+	if m == nil {
+		return 0, fmt.Errorf("unexpected dd output: %s", strings.TrimSpace(output))
+	}
+	bytes, _ := strconv.ParseFloat(m[1], 64)
+	seconds, err := strconv.ParseFloat(m[2], 64)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("unexpected dd run time %q", m[2])
+	}
+	return bytes / seconds / 1e6, nil
+}
+
+// benchmarkNetwork measures the throughput to an iperf3 server, or of the
+// loopback interface without iperf3 or a server
+func benchmarkNetwork(ctx context.Context, server string, duration time.Duration) ([]client.BenchmarkResult, error) {
+	if server != "" {
+		if sysutil.CommandExists("iperf3") {
+			return runIperf3(ctx, server, duration)
+		}
+		cli.PrintWarning("iperf3 is not installed, measuring the loopback interface instead")
+	}
+
+	mbits, err := benchmarkLoopback(ctx, duration)
+	if err != nil {
+		return nil, err
+	}
+	return []client.BenchmarkResult{{Test: "network", Target: "loopback", Metric: "throughput", Value: mbits, Unit: "Mbit/s"}}, nil
+}
+
+// iperf3Report is the part of the iperf3 --json report with the totals
+type iperf3Report struct {
+	End struct {
+		SumSent struct {
+			BitsPerSecond float64 `json:"bits_per_second"`
+		} `json:"sum_sent"`
+		SumReceived struct {
+			BitsPerSecond float64 `json:"bits_per_second"`
+		} `json:"sum_received"`
+	} `json:"end"`
+	Error string `json:"error"`
+}
+
+// runIperf3 runs an iperf3 client against server
+func runIperf3(ctx context.Context, server string, duration time.Duration) ([]client.BenchmarkResult, error) {
+	seconds := strconv.Itoa(int(duration.Round(time.Second).Seconds()))
+	if seconds == "0" {
+		seconds = "1"
+	}
+	output, err := exec.CommandContext(ctx, "iperf3", "-c", server, "-t", seconds, "--json").Output()
+
+	var report iperf3Report
+	if jsonErr := json.Unmarshal(output, &report); jsonErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("iperf3 failed: %w", err)
+		}
+		return nil, fmt.Errorf("unexpected iperf3 output: %w", jsonErr)
+	}
+	if report.Error != "" {
+		return nil, fmt.Errorf("iperf3 failed: %s", report.Error)
+	}
+
+	return []client.BenchmarkResult{
+		{Test: "network", Target: server, Metric: "send", Value: report.End.SumSent.BitsPerSecond / 1e6, Unit: "Mbit/s"},
+		{Test: "network", Target: server, Metric: "receive", Value: report.End.SumReceived.BitsPerSecond / 1e6, Unit: "Mbit/s"},
+	}, nil
+}
+
+// benchmarkLoopback sends data over a TCP connection to localhost for
+// duration and returns the throughput in Mbit/s
+func benchmarkLoopback(ctx context.Context, duration time.Duration) (float64, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	received := make(chan int64, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- 0
+			return
+		}
+		defer conn.Close()
+		n, _ := io.Copy(io.Discard, conn)
+		received <- n
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	buf := make([]byte, 128*1024)
+	start := time.Now()
+	for ctx.Err() == nil {
+		if _, err := conn.Write(buf); err != nil {
+			conn.Close()
+			return 0, err
+		}
+	}
+	conn.Close()
+	n := <-received
+	return float64(n) * 8 / time.Since(start).Seconds() / 1e6, nil
+}
+
+// benchmarkCPU hashes 1 MiB blocks with SHA-256 on all CPU threads for
+// duration and returns the combined throughput
+func benchmarkCPU(ctx context.Context, duration time.Duration) client.BenchmarkResult {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	threads := runtime.NumCPU()
+	block := make([]byte, 1<<20)
+	var hashed atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				sha256.Sum256(block)
+				hashed.Add(int64(len(block)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return client.BenchmarkResult{
+		Test:   "cpu",
+		Target: fmt.Sprintf("threads: %d", threads),
+		Metric: "sha256",
+		Value:  float64(hashed.Load()) / time.Since(start).Seconds() / 1e6,
+		Unit:   "MB/s",
+	}
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shimEnv makes the test binary act as the command it is invoked as
+const shimEnv = "STUMPFCTL_TEST_SHIM"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(shimEnv) != "" {
+		os.Exit(runShim(filepath.Base(os.Args[0]), os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// runShim fakes dd and iperf3 and logs their arguments to the file in
+// STUMPFCTL_TEST_SHIM
+func runShim(name string, args []string) int {
+	if f, err := os.OpenFile(os.Getenv(shimEnv), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+		fmt.Fprintf(f, "%s %s\n", name, strings.Join(args, " "))
+		f.Close()
+	}
+
+	switch name {
+	case "dd":
+		fmt.Fprintln(os.Stderr, "1024+0 records in\n1024+0 records out")
+		if strings.Contains(strings.Join(args, " "), "of=/dev/null") {
+			fmt.Fprintln(os.Stderr, "1073741824 bytes (1.1 GB, 1.0 GiB) copied, 0.8 s, 1.3 GB/s")
+		} else {
+			fmt.Fprintln(os.Stderr, "1073741824 bytes (1.1 GB, 1.0 GiB) copied, 2.5 s, 429 MB/s")
+		}
+	case "iperf3":
+		fmt.Println(`{"start": {}, "intervals": [], "end": {"sum_sent": {"bytes": 1176502272, "bits_per_second": 941201817.6}, "sum_received": {"bytes": 1174405120, "bits_per_second": 939524096}}}`)
+	default:
+		fmt.Fprintf(os.Stderr, "%s: unexpected command\n", name)
+		return 127
+	}
+	return 0
+}
+
+// installShims puts dd and iperf3 shims first in PATH and returns the
+// file they log their calls to
+func installShims(t *testing.T) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"dd", "iperf3"} {
+		if err := os.Symlink(exe, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logFile := filepath.Join(dir, "calls.log")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(shimEnv, logFile)
+	return logFile
+}
+
+func TestRunBenchmarkWithShims(t *testing.T) {
+	logFile := installShims(t)
+	volume := t.TempDir()
+
+	results, err := runBenchmark(context.Background(), benchmarkOptions{
+		test:     "all",
+		duration: 50 * time.Millisecond,
+		volumes:  []string{volume},
+		server:   "192.168.1.10",
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("runBenchmark: %v", err)
+	}
+
+	want := []struct {
+		test, target, metric string
+		value                float64
+	}{
+		{"disk", volume, "write", 429.4967}, // 1073741824 bytes in 2.5 s
+		{"disk", volume, "read", 1342.1773}, // in 0.8 s
+		{"network", "192.168.1.10", "send", 941.2018},
+		{"network", "192.168.1.10", "receive", 939.5241},
+	}
+	if len(results) != len(want)+1 {
+		t.Fatalf("results = %+v, want disk, network and cpu", results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Test != w.test || r.Target != w.target || r.Metric != w.metric || math.Abs(r.Value-w.value) > 0.001 {
+			t.Errorf("result %d = %+v, want %+v", i, r, w)
+		}
+	}
+	if cpu := results[4]; cpu.Test != "cpu" || cpu.Value <= 0 {
+		t.Errorf("cpu result = %+v", cpu)
+	}
+
+	calls, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFile := filepath.Join(volume, fmt.Sprintf(".stumpfctl-benchmark-%d", os.Getpid()))
+	wantCalls := "dd if=/dev/zero of=" + testFile + " bs=1M count=1024 conv=fdatasync\n" +
+		"dd if=" + testFile + " of=/dev/null bs=1M iflag=direct\n" +
+		"iperf3 -c 192.168.1.10 -t 1 --json\n"
+	if string(calls) != wantCalls {
+		t.Errorf("calls =\n%s\nwant\n%s", calls, wantCalls)
+	}
+}
+
+func TestParseDDThroughput(t *testing.T) {
+	tests := []struct {
+		output string
+		want   float64
+	}{
+		{"1073741824 bytes (1.1 GB, 1.0 GiB) copied, 2.5 s, 429 MB/s", 429.4967},
+		{"104857600 bytes (105 MB, 100 MiB) copied, 0.05 s, 2.1 GB/s", 2097.152},
+		{"1024+0 records in\n1024+0 records out\n1048576 bytes (1.0 MB, 1.0 MiB) copied, 4 s, 262 kB/s", 0.2621},
+	}
+	for _, tt := range tests {
+		got, err := parseDDThroughput(tt.output)
+		if err != nil || math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("parseDDThroughput(%q) = %v, %v, want %v", tt.output, got, err, tt.want)
+		}
+	}
+
+	if _, err := parseDDThroughput("dd: failed to open 'x': Permission denied"); err == nil {
+		t.Error("parsed a dd error")
+	}
+	if _, err := parseDDThroughput("0 bytes copied, 0 s, 0 B/s"); err == nil {
+		t.Error("accepted a run time of 0")
+	}
+}
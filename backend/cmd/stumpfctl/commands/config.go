@@ -1,14 +1,20 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/cli"
 	"github.com/spf13/cobra"
 )
 
+// defaultConfigPath is the config file of an installed server
+const defaultConfigPath = "/etc/stumpfworks/config.yaml"
+
 // ConfigCmd returns the configuration management command
 func ConfigCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -19,6 +25,7 @@ func ConfigCmd() *cobra.Command {
 
 	cmd.AddCommand(configShowCmd())
 	cmd.AddCommand(configEditCmd())
+	cmd.AddCommand(configValidateCmd())
 
 	return cmd
 }
@@ -30,7 +37,7 @@ func configShowCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cli.PrintHeader("StumpfWorks NAS Configuration")
 
-			configPath := "/etc/stumpfworks/config.yaml"
+			configPath := defaultConfigPath
 			data, err := os.ReadFile(configPath)
 			if err != nil {
 				cli.PrintError("Failed to read configuration file: %v", err)
@@ -51,7 +58,7 @@ func configEditCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cli.PrintInfo("Opening configuration editor...")
 
-			configPath := "/etc/stumpfworks/config.yaml"
+			configPath := defaultConfigPath
 
 			// Check if file exists
 			if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -83,3 +90,65 @@ func configEditCmd() *cobra.Command {
 		},
 	}
 }
+
+func configValidateCmd() *cobra.Command {
+	var file string
+	var fix, schema bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a configuration file for errors",
+		Long: `Check a configuration file the way the server does when it starts and
+list every problem with its line. Unknown settings are reported as well.
+Exits with code 1 if any problems are found.`,
+		Example: `  stumpfctl config validate
+  stumpfctl config validate --file ./config.yaml --fix
+  stumpfctl config validate --schema > config.schema.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if schema {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(config.JSONSchema())
+			}
+			cmd.SilenceUsage = true
+			return validateConfig(cmd.OutOrStdout(), file, fix)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", defaultConfigPath, "Configuration file to check")
+	cmd.Flags().BoolVar(&fix, "fix", false, "Correct safe problems (whitespace, log level casing, missing timeouts) in place first")
+	cmd.Flags().BoolVar(&schema, "schema", false, "Print the JSON Schema of the configuration file instead")
+	return cmd
+}
+
+// validateConfig prints the problems of a config file, after fixing the
+// safe ones if fix is set
+func validateConfig(w io.Writer, file string, fix bool) error {
+	if fix {
+		changes, err := config.FixFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to fix %s: %w", file, err)
+		}
+		for _, change := range changes {
+			fmt.Fprintf(w, "fixed %s\n", change)
+		}
+	}
+
+	problems, err := config.CheckFile(file)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s is valid\n", file)
+		return nil
+	}
+
+	for _, p := range problems {
+		if p.Line > 0 {
+			fmt.Fprintf(w, "%s:%d: %s\n", file, p.Line, p.Message)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", file, p.Message)
+		}
+	}
+	return fmt.Errorf("%s has %d problems", file, len(problems))
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// brokenConfig has five problems: an invalid port, database driver, log
+// level and reload interval, and a misspelled versioning setting
+const brokenConfig = `server:
+  host: "0.0.0.0"
+  port: 70000
+database:
+  driver: mysql
+logging:
+  level: verbose
+scheduler:
+  reloadInterval: 10s
+versioning:
+  enabled: true
+  maxVersion: 5
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateConfigReportsAllProblems(t *testing.T) {
+	path := writeTestConfig(t, brokenConfig)
+
+	var out bytes.Buffer
+	err := validateConfig(&out, path, false)
+	if err == nil || !strings.Contains(err.Error(), "5 problems") {
+		t.Fatalf("validateConfig() error = %v, want 5 problems", err)
+	}
+
+	want := []string{
+		path + ":3: server.port must be between 1 and 65535",
+		path + ":5: database.driver must be",
+		path + ":7: logging.level \"verbose\" is invalid",
+		path + ":9: scheduler.reloadInterval must be at least",
+		path + ":12: versioning.maxVersion is not a known setting",
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("output has %d lines, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i+1, lines[i], prefix)
+		}
+	}
+}
+
+func TestValidateConfigFix(t *testing.T) {
+	path := writeTestConfig(t, `# Local test server
+server:
+  host: " 0.0.0.0 "
+  port: 8080
+  readTimeout: 0s
+database:
+  driver: sqlite
+  path: ./data/test.db
+logging:
+  level: INFO
+`)
+
+	var out bytes.Buffer
+	if err := validateConfig(&out, path, true); err != nil {
+		t.Fatalf("validateConfig() error = %v\n%s", err, out.String())
+	}
+	for _, want := range []string{
+		"fixed server.host: trimmed whitespace",
+		"fixed logging.level: lowercased \"INFO\"",
+		"fixed server.readTimeout: replaced \"0s\" with the default of 15s",
+		"fixed server.writeTimeout: added the default of 15s",
+		"fixed server.idleTimeout: added the default of 60s",
+		path + " is valid",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# Local test server") || !strings.Contains(string(data), "level: info") {
+		t.Errorf("fixed file =\n%s", data)
+	}
+
+	// A fixed file needs no further fixes
+	out.Reset()
+	if err := validateConfig(&out, path, true); err != nil || strings.Contains(out.String(), "fixed") {
+		t.Errorf("second fix: %v\n%s", err, out.String())
+	}
+}
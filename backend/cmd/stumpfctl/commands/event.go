@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/cli"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// EventCmd returns the event command
+func EventCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "event",
+		Short: "Observe NAS events",
+		Long:  "Watch real-time NAS events such as alerts, share changes and user changes",
+	}
+
+	cmd.AddCommand(eventWatchCmd())
+
+	return cmd
+}
+
+func eventWatchCmd() *cobra.Command {
+	var (
+		filter string
+		since  string
+		follow bool
+		token  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Stream events as they happen",
+		Example: `  stumpfctl event watch
+  stumpfctl event watch --filter type=alert,share --since 5m
+  stumpfctl event watch --follow`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			types, err := parseEventFilter(filter)
+			if err != nil {
+				cli.PrintError("%v", err)
+				return err
+			}
+
+			apiClient := client.NewClient("http://localhost:8080")
+			apiClient.Token = token
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts := client.EventStreamOptions{Types: types, Since: since}
+
+			cli.PrintInfo("Watching events (Ctrl+C to stop)...")
+			fmt.Println()
+
+			if !follow {
+				if _, err := apiClient.StreamEvents(ctx, opts, printEvent); err != nil {
+					cli.PrintError("Event stream failed: %v", err)
+					return err
+				}
+				if ctx.Err() == nil {
+					cli.PrintWarning("Event stream closed by server")
+				}
+				return nil
+			}
+
+			err = apiClient.WatchEvents(ctx, opts, client.NewBackoff(), printEvent,
+				func(delay time.Duration, err error) {
+					if err != nil {
+						cli.PrintWarning("Disconnected (%v), reconnecting in %s...", err, delay)
+					} else {
+						cli.PrintWarning("Disconnected, reconnecting in %s...", delay)
+					}
+				})
+			if err != nil && err != context.Canceled {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&filter, "filter", "", "Filter events (e.g. type=alert,share,user)")
+	cmd.Flags().StringVar(&since, "since", "", "Replay events newer than this duration (e.g. 5m)")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Reconnect automatically when the connection drops")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("STUMPFWORKS_TOKEN"), "API token (defaults to $STUMPFWORKS_TOKEN)")
+
+	return cmd
+}
+
+// parseEventFilter parses a filter expression like "type=alert,share"
+func parseEventFilter(filter string) ([]string, error) {
+	if filter == "" {
+		return nil, nil
+	}
+
+	key, value, ok := strings.Cut(filter, "=")
+	if !ok || strings.TrimSpace(key) != "type" {
+		return nil, fmt.Errorf("invalid filter %q (expected type=<type>[,<type>...])", filter)
+	}
+
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// printEvent prints a single event, colour-coded by severity
+func printEvent(e client.Event) {
+	colorize := cli.Info
+	switch e.Severity {
+	case "critical":
+		colorize = cli.Error
+	case "warning":
+		colorize = cli.Warning
+	}
+
+	line := fmt.Sprintf("%-8s %-7s", strings.ToUpper(e.Severity), e.Type)
+	if e.Action != "" {
+		line += " " + e.Action
+	}
+
+	fmt.Printf("%s %s %s\n",
+		e.Timestamp.Local().Format("2006-01-02 15:04:05"),
+		colorize(line),
+		e.Message)
+}
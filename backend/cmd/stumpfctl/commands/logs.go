@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/cli"
@@ -49,11 +50,11 @@ func showLogs(follow bool, lines int, since string) error {
 	fmt.Println()
 
 	cmd := exec.Command("journalctl", args...)
-	cmd.Stdout = cmd.Stdout
-	cmd.Stderr = cmd.Stderr
 
 	// If following, run interactively
 	if follow {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
 		return cmd.Run()
 	}
 
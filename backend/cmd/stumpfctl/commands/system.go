@@ -18,6 +18,7 @@ func SystemCmd() *cobra.Command {
 
 	cmd.AddCommand(systemInfoCmd())
 	cmd.AddCommand(systemMetricsCmd())
+	cmd.AddCommand(systemBenchmarkCmd())
 
 	return cmd
 }
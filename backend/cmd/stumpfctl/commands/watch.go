@@ -0,0 +1,361 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/cli"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/client"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// watchSections are the dashboard sections that can be watched on their own
+var watchSections = []string{"cpu", "memory", "network", "storage"}
+
+const (
+	// watchHistory is how many samples the sparklines keep
+	watchHistory = 120
+	// watchCompactWidth is the terminal width below which sparklines and
+	// bars are left out
+	watchCompactWidth = 40
+	// Column widths of the label and value around a sparkline or bar
+	watchLabelWidth = 8
+	watchValueWidth = 12
+)
+
+// sparkTicks are the sparkline characters, from low to high
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// metricsSource fetches the metrics shown by watch
+type metricsSource interface {
+	GetLatestMetric() (*client.SystemMetric, error)
+	GetRealtimeMetrics() (*client.RealtimeMetrics, error)
+}
+
+// watchSnapshot is the data of one dashboard refresh
+type watchSnapshot struct {
+	Timestamp time.Time            `json:"timestamp"`
+	Metric    *client.SystemMetric `json:"metric"`
+	PerCore   []float64            `json:"perCore,omitempty"`
+	Mounts    []client.MountUsage  `json:"mounts,omitempty"`
+}
+
+// metricsWatcher polls the metrics API and renders the dashboard
+type metricsWatcher struct {
+	source   metricsSource
+	section  string // Section to show, "" for all
+	interval time.Duration
+	count    int  // Refreshes before exiting, 0 to run until interrupted
+	live     bool // Draw the dashboard on each refresh
+	clear    bool // Clear the terminal before drawing
+	out      io.Writer
+	width    func() int
+	after    func(time.Duration) <-chan time.Time
+
+	last  *watchSnapshot
+	err   error // Error of the last refresh
+	cores [][]float64
+	rx    []float64
+	tx    []float64
+	read  []float64
+	write []float64
+}
+
+// WatchCmd returns the watch command
+func WatchCmd() *cobra.Command {
+	var (
+		interval int
+		count    int
+		output   string
+		token    string
+	)
+
+	cmd := &cobra.Command{
+		Use:       "watch [cpu|memory|network|storage]",
+		Short:     "Monitor system metrics in real time",
+		Long:      "Show a live dashboard of CPU, memory, network and storage metrics, refreshed every few seconds",
+		ValidArgs: watchSections,
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		Example: `  stumpfctl watch
+  stumpfctl watch cpu --interval 1
+  stumpfctl watch --count 5 --output json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval < 1 {
+				return fmt.Errorf("--interval must be at least 1 second")
+			}
+			if count < 0 {
+				return fmt.Errorf("--count must not be negative")
+			}
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid output format %q (expected text or json)", output)
+			}
+
+			apiClient := client.NewClient("http://localhost:8080")
+			apiClient.Token = token
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			w := newMetricsWatcher(apiClient, time.Duration(interval)*time.Second, count, os.Stdout)
+			if len(args) == 1 {
+				w.section = args[0]
+			}
+			w.live = output == "text"
+			w.clear = term.IsTerminal(int(os.Stdout.Fd()))
+			w.width = terminalWidth
+
+			w.run(ctx)
+
+			if output == "json" {
+				if w.last == nil {
+					if w.err != nil {
+						cli.PrintError("Failed to retrieve metrics: %v", w.err)
+						return w.err
+					}
+					return fmt.Errorf("no metrics received")
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(w.last)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&interval, "interval", "n", 2, "Seconds between refreshes")
+	cmd.Flags().IntVarP(&count, "count", "c", 0, "Exit after this many refreshes (0 = until interrupted)")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text (live dashboard) or json (final snapshot)")
+	cmd.Flags().StringVar(&token, "token", os.Getenv("STUMPFWORKS_TOKEN"), "API token (defaults to $STUMPFWORKS_TOKEN)")
+
+	return cmd
+}
+
+// newMetricsWatcher creates a watcher that draws all sections
+func newMetricsWatcher(source metricsSource, interval time.Duration, count int, out io.Writer) *metricsWatcher {
+	return &metricsWatcher{
+		source:   source,
+		interval: interval,
+		count:    count,
+		live:     true,
+		out:      out,
+		width:    func() int { return 80 },
+		after:    time.After,
+	}
+}
+
+// terminalWidth returns the width of the terminal on stdout, or 80 columns
+// if stdout is not a terminal
+func terminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
+
+// run refreshes the dashboard every interval until count refreshes are done
+// or ctx is cancelled
+func (w *metricsWatcher) run(ctx context.Context) {
+	for n := 1; ; n++ {
+		w.refresh()
+		if w.live {
+			if w.clear {
+				fmt.Fprint(w.out, "\033[H\033[2J")
+			}
+			fmt.Fprint(w.out, w.render(w.width()))
+		}
+		if w.count > 0 && n >= w.count {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.after(w.interval):
+		}
+	}
+}
+
+// refresh fetches the metrics and appends them to the sparkline history.
+// A failed refresh keeps the previous snapshot on screen.
+func (w *metricsWatcher) refresh() {
+	metric, err := w.source.GetLatestMetric()
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.err = nil
+	snap := &watchSnapshot{Timestamp: time.Now(), Metric: metric}
+
+	// Per-core and per-mount usage are extras; the dashboard works without
+	if w.shows("cpu") || w.shows("storage") {
+		if rt, err := w.source.GetRealtimeMetrics(); err == nil {
+			snap.PerCore = rt.CPU.PerCore
+			snap.Mounts = rt.Disk
+		}
+	}
+	w.last = snap
+
+	if len(w.cores) != len(snap.PerCore) {
+		w.cores = make([][]float64, len(snap.PerCore))
+	}
+	for i, usage := range snap.PerCore {
+		w.cores[i] = appendHistory(w.cores[i], usage)
+	}
+	w.rx = appendHistory(w.rx, float64(metric.NetworkRxBytesPerSec))
+	w.tx = appendHistory(w.tx, float64(metric.NetworkTxBytesPerSec))
+	w.read = appendHistory(w.read, float64(metric.DiskReadBytesPerSec))
+	w.write = appendHistory(w.write, float64(metric.DiskWriteBytesPerSec))
+}
+
+// shows reports whether a section is on the dashboard
+func (w *metricsWatcher) shows(section string) bool {
+	return w.section == "" || w.section == section
+}
+
+// render draws the dashboard for a terminal width columns wide
+func (w *metricsWatcher) render(width int) string {
+	var lines []string
+	header := fmt.Sprintf("StumpfWorks NAS  %s  every %s", time.Now().Format("15:04:05"), w.interval)
+	if width >= watchCompactWidth+20 {
+		header += "  (Ctrl+C to quit)"
+	}
+	lines = append(lines, header)
+	if w.err != nil {
+		lines = append(lines, "⚠ refresh failed: "+w.err.Error())
+	}
+	if w.last == nil {
+		lines = append(lines, "", "Waiting for metrics...")
+		return joinLines(lines, width)
+	}
+
+	m := w.last.Metric
+	if w.shows("cpu") {
+		lines = append(lines, "", fmt.Sprintf("CPU %.1f%%  load %.2f %.2f %.2f", m.CPUUsage, m.CPULoadAvg1, m.CPULoadAvg5, m.CPULoadAvg15))
+		if m.CPUTemperature > 0 {
+			lines[len(lines)-1] += fmt.Sprintf("  %.0f°C", m.CPUTemperature)
+		}
+		for i, history := range w.cores {
+			lines = append(lines, sparkLine(fmt.Sprintf("cpu%d", i), history, 100, fmt.Sprintf("%.0f%%", w.last.PerCore[i]), width))
+		}
+	}
+	if w.shows("memory") {
+		lines = append(lines, "", fmt.Sprintf("Memory %s / %s", formatBytes(m.MemoryUsedBytes), formatBytes(m.MemoryTotalBytes)))
+		lines = append(lines, barLine("mem", m.MemoryUsage, width))
+		if m.SwapTotalBytes > 0 {
+			lines = append(lines, barLine("swap", m.SwapUsage, width))
+		}
+	}
+	if w.shows("network") {
+		lines = append(lines, "", fmt.Sprintf("Network %d pkt/s in, %d pkt/s out", m.NetworkRxPacketsPerSec, m.NetworkTxPacketsPerSec))
+		lines = append(lines, sparkLine("rx", w.rx, 0, formatBytes(m.NetworkRxBytesPerSec)+"/s", width))
+		lines = append(lines, sparkLine("tx", w.tx, 0, formatBytes(m.NetworkTxBytesPerSec)+"/s", width))
+	}
+	if w.shows("storage") {
+		lines = append(lines, "", fmt.Sprintf("Storage %d IOPS  %s / %s used", m.DiskIOPS, formatBytes(m.DiskUsedBytes), formatBytes(m.DiskTotalBytes)))
+		lines = append(lines, sparkLine("read", w.read, 0, formatBytes(m.DiskReadBytesPerSec)+"/s", width))
+		lines = append(lines, sparkLine("write", w.write, 0, formatBytes(m.DiskWriteBytesPerSec)+"/s", width))
+		for _, mount := range w.last.Mounts {
+			lines = append(lines, barLine(mount.Mountpoint, mount.UsedPercent, width))
+		}
+	}
+	return joinLines(lines, width)
+}
+
+// sparkLine draws a labelled sparkline of the newest values that fit. max
+// is the top of the scale, or 0 to scale to the largest value shown.
+func sparkLine(label string, history []float64, max float64, value string, width int) string {
+	graphWidth := width - watchLabelWidth - watchValueWidth
+	if width < watchCompactWidth || graphWidth < 1 {
+		return fmt.Sprintf("%-*s %s", watchLabelWidth-1, fitLine(label, watchLabelWidth-1), value)
+	}
+	if len(history) > graphWidth {
+		history = history[len(history)-graphWidth:]
+	}
+	if max <= 0 {
+		for _, v := range history {
+			if v > max {
+				max = v
+			}
+		}
+	}
+
+	var graph strings.Builder
+	for _, v := range history {
+		tick := 0
+		if max > 0 {
+			tick = int(v / max * float64(len(sparkTicks)-1))
+		}
+		tick = min(len(sparkTicks)-1, tick)
+		graph.WriteRune(sparkTicks[tick])
+	}
+	return fmt.Sprintf("%-*s %-*s%*s", watchLabelWidth-1, fitLine(label, watchLabelWidth-1),
+		graphWidth, graph.String(), watchValueWidth, value)
+}
+
+// barLine draws a labelled percentage bar
+func barLine(label string, percent float64, width int) string {
+	value := fmt.Sprintf("%.1f%%", percent)
+	barWidth := width - watchLabelWidth - watchValueWidth - 2
+	if width < watchCompactWidth || barWidth < 1 {
+		return fmt.Sprintf("%-*s %s", watchLabelWidth-1, fitLine(label, watchLabelWidth-1), value)
+	}
+
+	filled := int(percent / 100 * float64(barWidth))
+	filled = max(0, min(barWidth, filled))
+	return fmt.Sprintf("%-*s [%s%s]%*s", watchLabelWidth-1, fitLine(label, watchLabelWidth-1),
+		strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled), watchValueWidth, value)
+}
+
+// appendHistory appends a sample, dropping the oldest beyond watchHistory
+func appendHistory(history []float64, v float64) []float64 {
+	history = append(history, v)
+	if len(history) > watchHistory {
+		history = history[len(history)-watchHistory:]
+	}
+	return history
+}
+
+// joinLines joins the dashboard lines, cut to the terminal width
+func joinLines(lines []string, width int) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(fitLine(line, width))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// fitLine cuts a line to width characters, marking the cut with "…"
+func fitLine(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	if width <= 1 {
+		return string([]rune(line)[:max(width, 0)])
+	}
+	return string([]rune(line)[:width-1]) + "…"
+}
+
+// formatBytes formats a byte count with binary units
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
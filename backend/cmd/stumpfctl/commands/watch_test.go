@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/client"
+)
+
+// fakeMetricsSource counts the API calls and returns a fixed sample
+type fakeMetricsSource struct {
+	latestCalls   int
+	realtimeCalls int
+	failLatest    bool
+}
+
+func (f *fakeMetricsSource) GetLatestMetric() (*client.SystemMetric, error) {
+	f.latestCalls++
+	if f.failLatest {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return &client.SystemMetric{
+		CPUUsage:             float64(f.latestCalls * 10),
+		MemoryUsedBytes:      6 << 30,
+		MemoryTotalBytes:     16 << 30,
+		MemoryUsage:          37.5,
+		SwapTotalBytes:       2 << 30,
+		NetworkRxBytesPerSec: uint64(f.latestCalls) << 20,
+		DiskWriteBytesPerSec: 512 << 10,
+	}, nil
+}
+
+func (f *fakeMetricsSource) GetRealtimeMetrics() (*client.RealtimeMetrics, error) {
+	f.realtimeCalls++
+	rt := &client.RealtimeMetrics{Disk: []client.MountUsage{{Mountpoint: "/mnt/storage/media-library", UsedPercent: 71.2}}}
+	rt.CPU.PerCore = []float64{12, 97, 45, 3}
+	return rt, nil
+}
+
+func TestMetricsWatcherRefreshSchedule(t *testing.T) {
+	source := &fakeMetricsSource{}
+	var out bytes.Buffer
+	w := newMetricsWatcher(source, 3*time.Second, 4, &out)
+
+	var waits []time.Duration
+	w.after = func(d time.Duration) <-chan time.Time {
+		// Each wait for the next refresh must follow exactly one fetch
+		if source.latestCalls != len(waits)+1 {
+			t.Errorf("wait %d after %d fetches", len(waits)+1, source.latestCalls)
+		}
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	w.run(context.Background())
+
+	if source.latestCalls != 4 {
+		t.Errorf("GetLatestMetric called %d times, want 4", source.latestCalls)
+	}
+	if len(waits) != 3 {
+		t.Fatalf("waited %d times, want 3 (none after the last refresh)", len(waits))
+	}
+	for i, d := range waits {
+		if d != 3*time.Second {
+			t.Errorf("wait %d = %s, want 3s", i+1, d)
+		}
+	}
+	if w.last == nil || w.last.Metric.CPUUsage != 40 {
+		t.Errorf("last snapshot = %+v, want the fourth sample", w.last)
+	}
+	if strings.Count(out.String(), "StumpfWorks NAS") != 4 {
+		t.Errorf("dashboard drawn %d times, want 4", strings.Count(out.String(), "StumpfWorks NAS"))
+	}
+}
+
+func TestMetricsWatcherStopsWhenCancelled(t *testing.T) {
+	source := &fakeMetricsSource{}
+	w := newMetricsWatcher(source, time.Second, 0, &bytes.Buffer{})
+	ctx, cancel := context.WithCancel(context.Background())
+	w.after = func(time.Duration) <-chan time.Time {
+		if source.latestCalls == 2 {
+			cancel()
+		}
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	done := make(chan struct{})
+	go func() {
+		w.run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not stop after cancellation")
+	}
+	if source.latestCalls < 2 {
+		t.Errorf("GetLatestMetric called %d times, want at least 2", source.latestCalls)
+	}
+}
+
+func TestMetricsWatcherSkipsRealtimeMetricsForOtherSections(t *testing.T) {
+	source := &fakeMetricsSource{}
+	w := newMetricsWatcher(source, time.Second, 1, &bytes.Buffer{})
+	w.section = "network"
+	w.run(context.Background())
+
+	if source.realtimeCalls != 0 {
+		t.Errorf("GetRealtimeMetrics called %d times for the network section", source.realtimeCalls)
+	}
+}
+
+func TestMetricsWatcherRenderFitsWidth(t *testing.T) {
+	source := &fakeMetricsSource{}
+	w := newMetricsWatcher(source, 2*time.Second, 0, &bytes.Buffer{})
+	for i := 0; i < 3; i++ {
+		w.refresh()
+	}
+
+	for _, width := range []int{120, 80, 50, 30, 12} {
+		dashboard := w.render(width)
+		for _, line := range strings.Split(strings.TrimSuffix(dashboard, "\n"), "\n") {
+			if n := utf8.RuneCountInString(line); n > width {
+				t.Errorf("width %d: line %q is %d columns wide", width, line, n)
+			}
+		}
+		if width >= watchCompactWidth && !strings.ContainsAny(dashboard, string(sparkTicks)) {
+			t.Errorf("width %d: dashboard has no sparklines:\n%s", width, dashboard)
+		}
+		if width < watchCompactWidth && strings.ContainsAny(dashboard, "█░") {
+			t.Errorf("width %d: compact dashboard has bars:\n%s", width, dashboard)
+		}
+	}
+
+	// A failed refresh keeps the last snapshot on screen
+	source.failLatest = true
+	w.refresh()
+	dashboard := w.render(80)
+	if !strings.Contains(dashboard, "refresh failed") || !strings.Contains(dashboard, "cpu3") {
+		t.Errorf("dashboard after failed refresh:\n%s", dashboard)
+	}
+}
@@ -32,6 +32,8 @@ configuration, shares, and monitor system health.`,
 	rootCmd.AddCommand(commands.ShareCmd())
 	rootCmd.AddCommand(commands.HealthCmd())
 	rootCmd.AddCommand(commands.SystemCmd())
+	rootCmd.AddCommand(commands.EventCmd())
+	rootCmd.AddCommand(commands.WatchCmd())
 	rootCmd.AddCommand(commands.VersionCmd(Version, BuildTime))
 
 	if err := rootCmd.Execute(); err != nil {
@@ -13,6 +13,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/accesslog"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/ad"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/addons"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
@@ -20,21 +21,33 @@ import (
 	"github.com/Stumpf-works/stumpfworks-nas/internal/api/handlers"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/audit"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/auth"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth/rbac"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/backup"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/cloudbackup"
+	clusterlock "github.com/Stumpf-works/stumpfworks-nas/internal/cluster"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/dependencies"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/docker"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/files/versioning"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/lifecycle"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/metrics"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/plugins"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/scheduler"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha/cluster"
+	sysstorage "github.com/Stumpf-works/stumpfworks-nas/internal/system/storage"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/lxc"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/vm"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/vpn"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/telemetry"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/tracing"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/twofa"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/updates"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/usergroups"
@@ -63,11 +76,24 @@ func main() {
 	}
 
 	cfg, err := config.Load(configPath)
+	configFromFile := err == nil
 	if err != nil {
 		// If config file doesn't exist, use defaults
 		cfg, _ = config.Load("")
 	}
 
+	// Replace {secret:key} placeholders with the values from the secret provider
+	if err := config.ResolveSecrets(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load secrets: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Validate configuration before any initialisation
+	if err := config.Validate(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize logger
 	if err := logger.InitLogger(cfg.Logging.Level, cfg.IsDevelopment()); err != nil {
 		fmt.Printf("Failed to initialize logger: %v\n", err)
@@ -90,268 +116,24 @@ func main() {
 	// Perform system health check
 	performSystemHealthCheck(cfg)
 
-	// Check system dependencies
-	if cfg.Dependencies.CheckOnStartup {
-		if err := checkDependencies(cfg); err != nil {
-			logger.Warn("Dependency check failed - some features may not work",
-				zap.Error(err))
-			// Don't fail startup - system can work with missing optional packages
-		}
-	} else {
-		logger.Info("Dependency check disabled in configuration")
-	}
+	// Background services run until shutdown
+	networkCtx, stopNetwork := context.WithCancel(context.Background())
+	defer stopNetwork()
 
-	// Initialize database
-	if err := database.Initialize(cfg); err != nil {
-		logger.Fatal("Failed to initialize database", zap.Error(err))
-	}
+	// Initialise the services in dependency order, independent ones concurrently
+	shutdownTracing := func(context.Context) error { return nil }
+	startup := lifecycle.NewStartupSequencer(startupStages(networkCtx, cfg, &shutdownTracing)...)
+	handlers.InitStartupSequencer(startup)
 	defer database.Close()
 
-	// Initialize System Library
-	if err := system.Initialize(nil); err != nil {
-		logger.Fatal("Failed to initialize system library", zap.Error(err))
-	}
-	logger.Info("System library initialized")
-
-	// Start System Library background tasks (metrics collection, etc.)
-	if err := system.MustGet().Start(); err != nil {
-		logger.Warn("Failed to start system library background tasks",
-			zap.Error(err),
-			zap.String("message", "Metrics collection may be limited"))
-	}
-
-	// Initialize Samba user manager (non-fatal if Samba not installed)
-	if err := initializeSambaUserManager(); err != nil {
-		logger.Warn("Samba user manager initialization failed",
-			zap.Error(err),
-			zap.String("message", "Samba user sync disabled - users will only work for web access"))
-	} else {
-		logger.Info("Samba user manager initialized")
-	}
-
-	// Initialize Unix group manager (non-fatal if commands not available)
-	if err := initializeUnixGroupManager(); err != nil {
-		logger.Warn("Unix group manager initialization failed",
-			zap.Error(err),
-			zap.String("message", "Unix group sync disabled - groups will only work in database"))
-	} else {
-		logger.Info("Unix group manager initialized")
-	}
-
-	// Ensure default shares exist (creates default shares on first run)
-	if err := storage.EnsureDefaultShares(); err != nil {
-		logger.Warn("Failed to ensure default shares",
-			zap.Error(err),
-			zap.String("message", "You may need to create shares manually"))
-	} else {
-		logger.Info("Default shares verified")
-	}
-
-	// Fix permissions for all existing shares
-	if err := storage.FixExistingSharePermissions(); err != nil {
-		logger.Warn("Failed to fix share permissions",
-			zap.Error(err),
-			zap.String("message", "Some shares may have incorrect permissions"))
-	} else {
-		logger.Info("Share permissions verified and fixed")
-	}
-
-	// Repair Samba configuration (fixes common misconfigurations)
-	if err := storage.RepairSambaConfig(); err != nil {
-		logger.Warn("Failed to repair Samba configuration",
-			zap.Error(err),
-			zap.String("message", "Samba shares may not work correctly - check /etc/samba/smb.conf"))
-	} else {
-		logger.Info("Samba configuration verified and repaired if needed")
-	}
-
-	// Initialize file service
-	if err := handlers.InitFileService(); err != nil {
-		logger.Fatal("Failed to initialize file service", zap.Error(err))
-	}
-	logger.Info("File service initialized")
-
-	// Initialize ACL service (non-fatal if ACL tools not available)
-	if err := initializeACL(); err != nil {
-		logger.Warn("ACL service initialization failed",
-			zap.Error(err),
-			zap.String("message", "ACL features will be disabled"))
-	} else {
-		logger.Info("ACL service initialized")
-	}
-
-	// Initialize Quota service (non-fatal if quota tools not available)
-	if err := initializeQuota(); err != nil {
-		logger.Warn("Quota service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Quota features will be disabled"))
-	} else {
-		logger.Info("Quota service initialized")
-	}
-
-	// Initialize DRBD service (non-fatal if DRBD tools not available)
-	if err := initializeDRBD(); err != nil {
-		logger.Warn("DRBD service initialization failed",
-			zap.Error(err),
-			zap.String("message", "DRBD features will be disabled"))
-	} else {
-		logger.Info("DRBD service initialized")
-	}
-
-	// Initialize Pacemaker/Corosync service (non-fatal if not available)
-	if err := initializePacemaker(); err != nil {
-		logger.Warn("Pacemaker service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Pacemaker/Corosync features will be disabled"))
-	} else {
-		logger.Info("Pacemaker/Corosync service initialized")
-	}
-
-	// Initialize Keepalived service (non-fatal if not available)
-	if err := initializeKeepalived(); err != nil {
-		logger.Warn("Keepalived service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Virtual IP (Keepalived) features will be disabled"))
-	} else {
-		logger.Info("Keepalived service initialized")
-	}
-
-	// Initialize Addon Manager (always enabled)
-	initializeAddonManager()
-
-	// Initialize VM Manager (non-fatal, requires VM Manager addon)
-	if err := initializeVMManager(); err != nil {
-		logger.Warn("VM Manager initialization failed",
-			zap.Error(err),
-			zap.String("message", "VM management features will be disabled. Install VM Manager addon to enable."))
-	} else {
-		logger.Info("VM Manager initialized")
-	}
-
-	// Initialize LXC Manager (non-fatal, requires LXC Manager addon)
-	if err := initializeLXCManager(); err != nil {
-		logger.Warn("LXC Manager initialization failed",
-			zap.Error(err),
-			zap.String("message", "LXC management features will be disabled. Install LXC Manager addon to enable."))
-	} else {
-		logger.Info("LXC Manager initialized")
-	}
-
-	// Initialize Docker service (non-fatal if not available)
-	if err := initializeDocker(); err != nil {
-		logger.Warn("Docker not available",
-			zap.Error(err),
-			zap.String("message", "Docker features will be disabled"))
-	} else {
-		logger.Info("Docker service initialized and available")
-	}
-
-	// Initialize Plugin service (non-fatal if fails)
-	if err := initializePlugins(); err != nil {
-		logger.Warn("Plugin service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Plugin features may be limited"))
-	} else {
-		logger.Info("Plugin service initialized")
-	}
-
-	// Initialize Backup service (non-fatal if fails)
-	if err := initializeBackup(); err != nil {
-		logger.Warn("Backup service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Backup features may be limited"))
-	} else {
-		logger.Info("Backup service initialized")
-	}
-
-	// Initialize Active Directory service (non-fatal if fails)
-	if err := initializeAD(); err != nil {
-		logger.Warn("Active Directory service initialization failed",
-			zap.Error(err),
-			zap.String("message", "AD features will be disabled"))
-	} else {
-		logger.Info("Active Directory service initialized")
-	}
-
-	// Initialize Active Directory Domain Controller service (non-fatal if fails)
-	if err := initializeADDC(); err != nil {
-		logger.Warn("AD Domain Controller service initialization failed",
-			zap.Error(err),
-			zap.String("message", "AD DC features will be disabled"))
-	} else {
-		logger.Info("AD Domain Controller service initialized")
-	}
-
-	// Initialize Audit Log service
-	if err := initializeAuditLog(); err != nil {
-		logger.Warn("Audit log service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Audit logging may be limited"))
-	} else {
-		logger.Info("Audit log service initialized")
-	}
-
-	// Initialize Failed Login Tracking service
-	if err := initializeFailedLoginService(); err != nil {
-		logger.Warn("Failed login service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Failed login tracking may be limited"))
-	} else {
-		logger.Info("Failed login tracking service initialized")
-	}
-
-	// Initialize Update service
-	if err := initializeUpdateService(); err != nil {
-		logger.Warn("Update service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Update checking may be limited"))
-	} else {
-		logger.Info("Update service initialized")
-	}
-
-	// Initialize Alert service
-	if err := initializeAlertService(); err != nil {
-		logger.Warn("Alert service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Email alerts may be disabled"))
-	} else {
-		logger.Info("Alert service initialized")
-	}
-
-	// Initialize Scheduler service
-	if err := initializeScheduler(); err != nil {
-		logger.Warn("Scheduler service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Scheduled tasks may be disabled"))
-	} else {
-		logger.Info("Scheduler service initialized and started")
-	}
-
-	// Initialize Two-Factor Authentication service
-	if err := initializeTwoFA(); err != nil {
-		logger.Warn("Two-Factor Authentication service initialization failed",
-			zap.Error(err),
-			zap.String("message", "2FA may be disabled"))
-	} else {
-		logger.Info("Two-Factor Authentication service initialized")
-	}
-
-	// Initialize Metrics service
-	if err := initializeMetrics(); err != nil {
-		logger.Warn("Metrics service initialization failed",
-			zap.Error(err),
-			zap.String("message", "Metrics collection may be disabled"))
-	} else {
-		logger.Info("Metrics service initialized and started")
-	}
-
-	// Create HTTP router
-	router := api.NewRouter(cfg)
+	// Until the services have started the server only answers health checks
+	// and the startup status
+	handler := api.NewStartupHandler()
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         cfg.GetServerAddress(),
-		Handler:      router,
+		Handler:      handler,
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
@@ -363,20 +145,54 @@ func main() {
 			zap.String("address", server.Addr),
 			zap.String("environment", cfg.App.Environment))
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.EnableHTTPS {
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Failed to start HTTP server", zap.Error(err))
 		}
 	}()
 
-	logger.Info("Server started successfully",
-		zap.String("address", server.Addr),
-		zap.String("health", "http://"+server.Addr+"/health"),
-		zap.String("api", "http://"+server.Addr+"/api/v1"))
+	startupDone := make(chan error, 1)
+	go func() {
+		startupDone <- startup.Run(networkCtx)
+	}()
+
+	// Watch the config file and apply supported changes without a restart
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
 
-	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+
+	select {
+	case err := <-startupDone:
+		if err != nil {
+			logger.Fatal("Failed to start services", zap.Error(err))
+		}
+
+		// The router picks up the initialised services
+		handler.SetRouter(api.NewRouter(cfg))
+
+		if configFromFile {
+			if err := initializeConfigWatcher(watchCtx, configPath, cfg); err != nil {
+				logger.Warn("Config hot reload disabled", zap.Error(err))
+			}
+		}
+
+		logger.Info("Server started successfully",
+			zap.String("address", server.Addr),
+			zap.String("health", "http://"+server.Addr+"/health"),
+			zap.String("api", "http://"+server.Addr+"/api/v1"))
+
+		// Wait for interrupt signal to gracefully shutdown the server
+		<-quit
+	case <-quit:
+		logger.Warn("Interrupted during startup")
+	}
 
 	logger.Info("Shutting down server...")
 
@@ -388,9 +204,27 @@ func main() {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Warn("Failed to flush traces", zap.Error(err))
+	}
+
 	logger.Info("Server stopped")
 }
 
+// initializeConfigWatcher starts watching the config file for changes
+// Returns error if the file cannot be watched, but this is non-fatal
+func initializeConfigWatcher(ctx context.Context, configPath string, cfg *config.Config) error {
+	config.RegisterConfigurable(config.ConfigurableFunc(func(c *config.Config) error {
+		if err := logger.SetLevel(c.Logging.Level); err != nil {
+			return err
+		}
+		logger.Info("Log level updated", zap.String("level", logger.GetLevel()))
+		return nil
+	}))
+
+	return config.NewWatcher(configPath, cfg, 0).Start(ctx)
+}
+
 // initializeDocker initializes the Docker service
 // Returns error if Docker is not available, but this is non-fatal
 func initializeDocker() error {
@@ -434,6 +268,13 @@ func initializeAuditLog() error {
 	return err
 }
 
+// initializeAccessLog initializes the Access Log service
+// Returns error if service fails to initialize, but this is non-fatal
+func initializeAccessLog() error {
+	_, err := accesslog.Initialize()
+	return err
+}
+
 // initializeFailedLoginService initializes the Failed Login Tracking service
 // Returns error if service fails to initialize, but this is non-fatal
 func initializeFailedLoginService() error {
@@ -441,6 +282,13 @@ func initializeFailedLoginService() error {
 	return err
 }
 
+// initializeRBAC initializes the RBAC service
+// Returns error if service fails to initialize, but this is non-fatal
+func initializeRBAC() error {
+	_, err := rbac.Initialize()
+	return err
+}
+
 // initializeUpdateService initializes the Update service
 // Returns error if service fails to initialize, but this is non-fatal
 func initializeUpdateService() error {
@@ -448,11 +296,39 @@ func initializeUpdateService() error {
 	return err
 }
 
+// initializeAutoUpdates enables system package updates and starts the
+// automatic updates if configured
+// Returns error if apt-get is not available, but this is non-fatal
+func initializeAutoUpdates(ctx context.Context) error {
+	if _, err := sysutil.RequireCommand("apt-get"); err != nil {
+		return err
+	}
+
+	cfg := config.GlobalConfig.AutoUpdates
+	updater, err := updates.NewAutoUpdater(updates.AutoUpdateConfig{
+		Enabled:         cfg.Enabled,
+		SecurityOnly:    cfg.SecurityOnly,
+		AllowedOrigins:  cfg.AllowedOrigins,
+		Schedule:        cfg.Schedule,
+		RequireApproval: cfg.RequireApproval,
+	})
+	if err != nil {
+		return err
+	}
+	handlers.InitAutoUpdater(updater)
+	updater.Start(ctx)
+	return nil
+}
+
 // initializeAlertService initializes the Alert service
 // Returns error if service fails to initialize, but this is non-fatal
 func initializeAlertService() error {
-	_, err := alerts.Initialize()
-	return err
+	service, err := alerts.Initialize()
+	if err != nil {
+		return err
+	}
+	config.RegisterConfigurable(service)
+	return nil
 }
 
 // initializeScheduler initializes the Scheduler service and starts it
@@ -462,6 +338,7 @@ func initializeScheduler() error {
 	if err != nil {
 		return err
 	}
+	config.RegisterConfigurable(service)
 	return service.Start()
 }
 
@@ -472,6 +349,24 @@ func initializeTwoFA() error {
 	return err
 }
 
+// initializeMFAEnforcement initializes the MFA enforcement policy
+// Returns error if service fails to initialize, but this is non-fatal
+func initializeMFAEnforcement() error {
+	_, err := auth.InitializeMFAEnforcement()
+	return err
+}
+
+// initializeSAML loads the SAML configuration and starts refreshing the IdP
+// metadata
+func initializeSAML(ctx context.Context) error {
+	service, err := auth.InitializeSAML()
+	if err != nil {
+		return err
+	}
+	service.Start(ctx)
+	return nil
+}
+
 // initializeSambaUserManager initializes the Samba user synchronization manager
 // Returns error if service fails to initialize, but this is non-fatal
 func initializeSambaUserManager() error {
@@ -502,6 +397,95 @@ func initializeMetrics() error {
 	return service.Start()
 }
 
+// initializeMetricsExporter starts pushing metrics to the remote configured
+// in metrics.export, if enabled
+// Returns error if the export settings are invalid, but this is non-fatal
+func initializeMetricsExporter(ctx context.Context) error {
+	cfg := config.GlobalConfig.Metrics.Export
+	if !cfg.Enabled {
+		return nil
+	}
+	exporter, err := metrics.NewMetricsExporter(cfg)
+	if err != nil {
+		return err
+	}
+	handlers.InitMetricsExporter(exporter)
+	return exporter.Start(ctx)
+}
+
+// initializeShareAccessTracker starts logging Samba and NFS connections to shares
+// Returns error if tracker fails to start, but this is non-fatal
+func initializeShareAccessTracker() error {
+	_, err := storage.StartShareAccessTracker()
+	return err
+}
+
+// initializeTrafficMonitor starts accounting share traffic with conntrack
+// Returns error if conntrack is not available, but this is non-fatal
+func initializeTrafficMonitor(ctx context.Context) error {
+	return network.NewTrafficMonitor(database.GetDB()).Start(ctx)
+}
+
+// initializeDynamicDNS starts the dynamic DNS updater
+// Returns error if updater fails to start, but this is non-fatal
+func initializeDynamicDNS(ctx context.Context) error {
+	return network.NewDynamicDNSUpdater(database.GetDB()).Start(ctx)
+}
+
+// initializeMDNS announces the NAS services via mDNS/Bonjour
+// Returns error if a service could not be announced, but this is non-fatal
+func initializeMDNS(ctx context.Context, cfg *config.Config) error {
+	return network.NewMDNSAdvertiser(cfg).Start(ctx)
+}
+
+// initializePortForwarding restores the persisted port forwards
+// Returns error if iptables is not installed, but this is non-fatal
+func initializePortForwarding() error {
+	manager, err := network.NewPortForwardingManager(database.GetDB(), system.MustGet().Shell)
+	if err != nil {
+		return err
+	}
+	handlers.InitPortForwardingManager(manager)
+	return manager.RestorePortForwardRules()
+}
+
+// initializeVXLAN recreates the persisted VXLAN interfaces
+// Returns error if iproute2 is not installed, but this is non-fatal
+func initializeVXLAN() error {
+	manager, err := network.NewVXLANManager(database.GetDB(), system.MustGet().Shell)
+	if err != nil {
+		return err
+	}
+	handlers.InitVXLANManager(manager)
+	return manager.RestoreAllVXLANs()
+}
+
+// initializeCloudBackup records the remotes of the rclone config
+// Returns error if rclone is not installed, but this is non-fatal
+func initializeCloudBackup() error {
+	manager, err := cloudbackup.NewRcloneConfigManager(database.GetDB(), system.MustGet().Shell)
+	if err != nil {
+		return err
+	}
+	handlers.InitRcloneConfigManager(manager)
+	return manager.SyncRemotes()
+}
+
+// initializeARPMonitor starts watching for ARP spoofing if configured
+// Returns error if the raw socket cannot be opened, but this is non-fatal
+func initializeARPMonitor(ctx context.Context) error {
+	cfg := config.GlobalConfig.ARPMonitor
+	monitor, err := network.NewARPMonitor(database.GetDB(), cfg.Interface, cfg.ChangeWindow)
+	if err != nil {
+		return err
+	}
+	handlers.InitARPMonitor(monitor)
+	if !cfg.Enabled {
+		return nil
+	}
+	return monitor.Start(ctx)
+}
+
 // initializeACL initializes the ACL (Access Control List) service
 // Returns error if ACL tools are not installed, but this is non-fatal
 func initializeACL() error {
@@ -514,6 +498,48 @@ func initializeACL() error {
 	return nil
 }
 
+// startupLockWait is how long a node waits for another node's startup routine
+const startupLockWait = 5 * time.Minute
+
+// withStartupLock runs a startup routine under a cluster-wide lock, waiting
+// up to startupLockWait for another node running it to finish
+func withStartupLock(name string, fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), startupLockWait)
+	defer cancel()
+	return clusterlock.NewDistributedLock(database.GetDB(), "startup:"+name, time.Minute).WithLock(ctx, fn)
+}
+
+// initializeDeduplication initializes the duplicate file scanner
+// Returns error if neither jdupes nor fdupes is installed, but this is non-fatal
+func initializeDeduplication() error {
+	scanner, err := filesystem.NewDeduplicationScanner()
+	if err != nil {
+		return err
+	}
+	handlers.InitDeduplicationScanner(scanner)
+	return nil
+}
+
+// initializeVersioning enables file version history if configured
+// Returns error if the configured backend is not available, but this is non-fatal
+func initializeVersioning() error {
+	cfg := config.GlobalConfig.Versioning
+	if !cfg.Enabled {
+		return nil
+	}
+
+	store, err := versioning.NewVersionStore(versioning.VersioningConfig{
+		Backend:         cfg.Backend,
+		MaxVersions:     cfg.MaxVersions,
+		ExcludePatterns: cfg.ExcludePatterns,
+	}, system.MustGet().Shell)
+	if err != nil {
+		return err
+	}
+	handlers.InitVersionStore(store)
+	return nil
+}
+
 // initializeQuota initializes the Disk Quota service
 // Returns error if quota tools are not installed, but this is non-fatal
 func initializeQuota() error {
@@ -523,51 +549,173 @@ func initializeQuota() error {
 		return err
 	}
 	handlers.InitQuotaManager(quotaManager)
+	scheduler.RegisterTaskType(models.TaskTypeQuotaCheck, storage.NewQuotaMonitor(quotaManager).RunTask)
 	return nil
 }
 
 // initializeDRBD initializes the DRBD (High Availability) service
 // Returns error if DRBD tools are not installed, but this is non-fatal
-func initializeDRBD() error {
+func initializeDRBD() (*ha.DRBDManager, error) {
 	shell := system.MustGet().Shell
 	drbdManager, err := ha.NewDRBDManager(shell)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	handlers.InitDRBDManager(drbdManager)
-	return nil
+	return drbdManager, nil
 }
 
 // initializePacemaker initializes the Pacemaker/Corosync (Cluster HA) service
 // Returns error if Pacemaker tools are not installed, but this is non-fatal
-func initializePacemaker() error {
+func initializePacemaker() (*ha.PacemakerManager, error) {
 	shell := system.MustGet().Shell
 	pacemakerManager, err := ha.NewPacemakerManager(shell)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	handlers.InitPacemakerManager(pacemakerManager)
-	return nil
+	return pacemakerManager, nil
 }
 
 // initializeKeepalived initializes the Keepalived (VIP Management) service
 // Returns error if Keepalived is not installed, but this is non-fatal
-func initializeKeepalived() error {
+func initializeKeepalived() (*ha.KeepalivedManager, error) {
 	shell := system.MustGet().Shell
 	keepalivedManager, err := ha.NewKeepalivedManager(shell)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	handlers.InitKeepalivedManager(keepalivedManager)
+	return keepalivedManager, nil
+}
+
+// initializeHACluster combines the available HA managers into the cluster manager
+// Returns error if no HA subsystem is available, but this is non-fatal
+func initializeHACluster(drbd *ha.DRBDManager, pacemaker *ha.PacemakerManager, keepalived *ha.KeepalivedManager) error {
+	manager := cluster.NewClusterManager(drbd, pacemaker, keepalived)
+	if !manager.IsEnabled() {
+		return fmt.Errorf("no HA subsystem available")
+	}
+	handlers.InitClusterManager(manager)
+	return nil
+}
+
+// initializeFencing initializes fencing with the node configurations stored
+// in the database
+func initializeFencing() *ha.FencingManager {
+	manager := ha.NewFencingManager(database.GetDB(), system.MustGet().Shell)
+	handlers.InitFencingManager(manager)
+	return manager
+}
+
+// initializeScrubMonitor watches the results of ZFS scrubs
+// Returns error if ZFS is not available, but this is non-fatal
+func initializeScrubMonitor(ctx context.Context) error {
+	lib := system.Get()
+	if lib == nil || lib.Storage == nil || lib.Storage.ZFS == nil {
+		return fmt.Errorf("ZFS not available")
+	}
+	sysstorage.NewScrubMonitor(lib.Storage.ZFS).Start(ctx)
 	return nil
 }
 
-// initializeAddonManager initializes the Addon Manager
+// initializeNotifications subscribes the alert service and browser push to the notification bus
+// Returns error if browser push cannot be set up, but this is non-fatal
+func initializeNotifications() error {
+	bus := notifications.GetBus()
+	if service := alerts.GetService(); service != nil {
+		bus.Subscribe(notifications.AllTypes, service.HandleNotification)
+	}
+
+	hostname, _ := os.Hostname()
+	subscriber, err := notifications.NewWebPushSubscriber(database.GetDB(), "mailto:root@"+hostname)
+	if err != nil {
+		return err
+	}
+	bus.Subscribe(notifications.AllTypes, subscriber.Deliver)
+	handlers.InitWebPushSubscriber(subscriber)
+	return nil
+}
+
+// initializeMaintenanceSummaries sends the digests of ended maintenance windows
+// Returns error if the alert service is not available, but this is non-fatal
+func initializeMaintenanceSummaries(ctx context.Context) error {
+	service := alerts.GetService()
+	if service == nil {
+		return fmt.Errorf("alert service not available")
+	}
+	service.StartMaintenanceSummaries(ctx)
+	return nil
+}
+
+// initializeSMARTTestScheduler watches the self-tests of scheduled disks
+// Returns error if SMART is not available, but this is non-fatal
+func initializeSMARTTestScheduler(ctx context.Context) error {
+	lib := system.Get()
+	if lib == nil || lib.Storage == nil || lib.Storage.SMART == nil {
+		return fmt.Errorf("SMART not available")
+	}
+	testScheduler := sysstorage.NewSMARTTestScheduler(lib.Storage.SMART)
+	testScheduler.Start(ctx)
+	handlers.InitSMARTTestScheduler(testScheduler)
+	return nil
+}
+
+// initializeVolumeHealthMonitor writes a canary file to each mounted volume every 5 minutes
+// Returns error if the database is not available, but this is non-fatal
+func initializeVolumeHealthMonitor(ctx context.Context) error {
+	return storage.NewVolumeHealthMonitor(database.GetDB()).StartMonitoring(ctx, 300)
+}
+
+// initializeTelemetry reports anonymous usage statistics once a day.
+// Nothing is sent unless telemetry was enabled.
+func initializeTelemetry(ctx context.Context) {
+	telemetry.Start(ctx, database.GetDB())
+}
+
+// initializeResourceGovernor starts throttling containers under host load
+// Returns error if Docker is not available, but this is non-fatal
+func initializeResourceGovernor(ctx context.Context) error {
+	governor, err := docker.NewResourceGovernor(database.GetDB(), docker.GetService())
+	if err != nil {
+		return err
+	}
+	return governor.Start(ctx)
+}
+
+// initializeKerberos starts renewing the TGTs of service accounts
+// Returns error if kinit is not installed, but this is non-fatal
+func initializeKerberos(ctx context.Context) error {
+	manager, err := ad.NewKerberosManager(ad.DefaultKerberosCacheDir)
+	if err != nil {
+		return err
+	}
+	manager.Start(ctx)
+	return nil
+}
+
+// initializeSplitBrainDetector watches DRBD resources for split-brain
+// Returns error if DRBD is not available, but this is non-fatal
+func initializeSplitBrainDetector(ctx context.Context, drbd *ha.DRBDManager, fencing *ha.FencingManager) error {
+	if drbd == nil {
+		return fmt.Errorf("DRBD not available")
+	}
+	return ha.NewDRBDSplitBrainDetector(drbd, system.MustGet().Shell, fencing.FenceNode).Start(ctx)
+}
+
+// initializeTracing installs the OpenTelemetry tracer provider
+// Returns error if the exporter cannot be created, but this is non-fatal
+func initializeTracing(cfg *config.Config) (func(context.Context) error, error) {
+	return tracing.Initialize(context.Background(), cfg.Tracing, AppName, AppVersion)
+}
+
+// initializeAddonManager initializes the Addon Manager and the addon marketplace
 // This is always enabled and manages installable addons
-func initializeAddonManager() {
+func initializeAddonManager(marketplace config.MarketplaceConfig) {
 	shell := system.MustGet().Shell
 	addonManager := addons.NewManager(shell)
 	handlers.InitAddonManager(addonManager)
+	handlers.InitMarketplaceClient(addons.NewMarketplaceClient(marketplace.RegistryURL, marketplace.CacheTTL, marketplace.Keyring))
 	logger.Info("Addon manager initialized")
 }
 
@@ -591,10 +739,33 @@ func initializeLXCManager() error {
 	if err != nil {
 		return err
 	}
+
+	// Isolate container networking in namespaces, recreating those lost by a reboot
+	netns, err := network.NewNetworkNamespace(database.GetDB(), shell)
+	if err != nil {
+		logger.Warn("Network namespaces not available, containers are bridged on the host", zap.Error(err))
+	} else {
+		if err := netns.Restore(); err != nil {
+			logger.Warn("Failed to restore container network namespaces", zap.Error(err))
+		}
+		lxcManager.UseNetworkNamespaces(netns)
+	}
 	handlers.InitLXCManager(lxcManager)
 	return nil
 }
 
+// initializeVPNManager initializes the VPN Manager
+// Returns error if WireGuard is not installed, but this is non-fatal
+func initializeVPNManager() error {
+	shell := system.MustGet().Shell
+	vpnManager, err := vpn.NewVPNManager(shell)
+	if err != nil {
+		return err
+	}
+	handlers.InitVPNManager(vpnManager)
+	return nil
+}
+
 // checkDependencies checks and optionally installs system dependencies
 func checkDependencies(cfg *config.Config) error {
 	logger.Info("Checking system dependencies",
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/handlers"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/lifecycle"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// startupStages lists the services initialised at startup and what each
+// needs first. Background services run until ctx is cancelled; the shutdown
+// function of the tracer is stored in shutdownTracing.
+func startupStages(ctx context.Context, cfg *config.Config, shutdownTracing *func(context.Context) error) []lifecycle.StartupStage {
+	// Set by their stages for the stages depending on them
+	var (
+		drbdManager       *ha.DRBDManager
+		pacemakerManager  *ha.PacemakerManager
+		keepalivedManager *ha.KeepalivedManager
+		fencingManager    *ha.FencingManager
+	)
+
+	return []lifecycle.StartupStage{
+		// Core services; the server cannot run without them
+		{
+			Name: "dependencies",
+			Init: func() error {
+				if !cfg.Dependencies.CheckOnStartup {
+					logger.Info("Dependency check disabled in configuration")
+					return nil
+				}
+				return checkDependencies(cfg)
+			},
+			Impact: "Some features may not work",
+		},
+		{
+			Name:     "database",
+			Init:     func() error { return database.Initialize(cfg) },
+			Critical: true,
+		},
+		{
+			Name:      "system",
+			DependsOn: []string{"dependencies"},
+			Init:      func() error { return system.Initialize(nil) },
+			Critical:  true,
+		},
+		{
+			Name:      "system-tasks",
+			DependsOn: []string{"database", "system"},
+			Init:      func() error { return system.MustGet().Start() },
+			Impact:    "Metrics collection may be limited",
+		},
+		{
+			Name:      "file-service",
+			DependsOn: []string{"database", "system"},
+			Init:      handlers.InitFileService,
+			Critical:  true,
+		},
+
+		// Users, groups and shares. The share setup steps run on one node at
+		// a time, so nodes starting together don't both create the default
+		// shares or rewrite smb.conf.
+		{
+			Name:      "samba-users",
+			DependsOn: []string{"database", "system"},
+			Init:      initializeSambaUserManager,
+			Impact:    "Samba user sync disabled - users will only work for web access",
+		},
+		{
+			Name:      "unix-groups",
+			DependsOn: []string{"database", "system"},
+			Init:      initializeUnixGroupManager,
+			Impact:    "Unix group sync disabled - groups will only work in database",
+		},
+		{
+			Name:      "default-shares",
+			DependsOn: []string{"samba-users", "unix-groups"},
+			Init:      func() error { return withStartupLock("default-shares", storage.EnsureDefaultShares) },
+			Impact:    "You may need to create shares manually",
+		},
+		{
+			Name:      "share-permissions",
+			DependsOn: []string{"default-shares"},
+			Init:      func() error { return withStartupLock("share-permissions", storage.FixExistingSharePermissions) },
+			Impact:    "Some shares may have incorrect permissions",
+		},
+		{
+			Name:      "samba-config",
+			DependsOn: []string{"share-permissions"},
+			Init:      func() error { return withStartupLock("samba-config", storage.RepairSambaConfig) },
+			Impact:    "Samba shares may not work correctly - check /etc/samba/smb.conf",
+		},
+		{
+			Name:      "share-access",
+			DependsOn: []string{"database", "samba-config"},
+			Init:      initializeShareAccessTracker,
+			Impact:    "Share connections will not be logged",
+		},
+
+		// Filesystem features
+		{
+			Name:      "acl",
+			DependsOn: []string{"system"},
+			Init:      initializeACL,
+			Impact:    "ACL features will be disabled",
+		},
+		{
+			Name:      "deduplication",
+			DependsOn: []string{"dependencies"},
+			Init:      initializeDeduplication,
+			Impact:    "Duplicate file scans will be disabled",
+		},
+		{
+			Name:      "versioning",
+			DependsOn: []string{"system"},
+			Init:      initializeVersioning,
+			Impact:    "Overwritten files will not be versioned",
+		},
+		{
+			Name:      "quota",
+			DependsOn: []string{"system"},
+			Init:      initializeQuota,
+			Impact:    "Quota features will be disabled",
+		},
+
+		// Networking
+		{
+			Name:      "port-forwarding",
+			DependsOn: []string{"database", "system"},
+			Init:      initializePortForwarding,
+			Impact:    "Port forwards may not be active",
+		},
+		{
+			Name:      "vxlan",
+			DependsOn: []string{"database", "system"},
+			Init:      initializeVXLAN,
+			Impact:    "VXLAN interfaces may not be active",
+		},
+		{
+			Name:      "arp-monitor",
+			DependsOn: []string{"database", "notifications"},
+			Init:      func() error { return initializeARPMonitor(ctx) },
+			Impact:    "ARP spoofing will not be detected",
+		},
+		{
+			Name:      "ipam",
+			DependsOn: []string{"database"},
+			Init: func() error {
+				handlers.InitIPAMManager(network.NewIPAMManager(database.GetDB()))
+				return nil
+			},
+		},
+		{
+			Name:      "traffic-monitor",
+			DependsOn: []string{"database", "system"},
+			Init:      func() error { return initializeTrafficMonitor(ctx) },
+			Impact:    "Share traffic will not be accounted",
+		},
+		{
+			Name:      "dynamic-dns",
+			DependsOn: []string{"database"},
+			Init:      func() error { return initializeDynamicDNS(ctx) },
+			Impact:    "Dynamic DNS updates disabled",
+		},
+		{
+			Name:   "mdns",
+			Init:   func() error { return initializeMDNS(ctx, cfg) },
+			Impact: "The NAS will not be discoverable via mDNS/Bonjour",
+		},
+
+		// High availability
+		{
+			Name:      "drbd",
+			DependsOn: []string{"system"},
+			Init: func() (err error) {
+				drbdManager, err = initializeDRBD()
+				return err
+			},
+			Impact: "DRBD features will be disabled",
+		},
+		{
+			Name:      "pacemaker",
+			DependsOn: []string{"system"},
+			Init: func() (err error) {
+				pacemakerManager, err = initializePacemaker()
+				return err
+			},
+			Impact: "Pacemaker/Corosync features will be disabled",
+		},
+		{
+			Name:      "keepalived",
+			DependsOn: []string{"system"},
+			Init: func() (err error) {
+				keepalivedManager, err = initializeKeepalived()
+				return err
+			},
+			Impact: "Virtual IP (Keepalived) features will be disabled",
+		},
+		{
+			Name:      "ha-cluster",
+			DependsOn: []string{"drbd", "pacemaker", "keepalived"},
+			Init: func() error {
+				return initializeHACluster(drbdManager, pacemakerManager, keepalivedManager)
+			},
+			Impact: "Unified HA cluster status and failover will be disabled",
+		},
+		{
+			Name:      "fencing",
+			DependsOn: []string{"database", "system"},
+			Init: func() error {
+				fencingManager = initializeFencing()
+				return nil
+			},
+		},
+		{
+			Name:      "split-brain",
+			DependsOn: []string{"drbd", "fencing"},
+			Init:      func() error { return initializeSplitBrainDetector(ctx, drbdManager, fencingManager) },
+			Impact:    "Split-brain peers will not be fenced automatically",
+		},
+
+		// Addons, virtualisation and containers
+		{
+			Name:      "addons",
+			DependsOn: []string{"system"},
+			Init: func() error {
+				initializeAddonManager(cfg.Marketplace)
+				return nil
+			},
+		},
+		{
+			Name:      "vm-manager",
+			DependsOn: []string{"system"},
+			Init:      initializeVMManager,
+			Impact:    "VM management features will be disabled. Install VM Manager addon to enable.",
+		},
+		{
+			Name:      "lxc-manager",
+			DependsOn: []string{"database", "system"},
+			Init:      initializeLXCManager,
+			Impact:    "LXC management features will be disabled. Install LXC Manager addon to enable.",
+		},
+		{
+			Name:      "vpn-manager",
+			DependsOn: []string{"system"},
+			Init:      initializeVPNManager,
+			Impact:    "VPN management features will be disabled. Install wireguard-tools to enable.",
+		},
+		{
+			Name:      "docker",
+			DependsOn: []string{"dependencies"},
+			Init:      initializeDocker,
+			Impact:    "Docker features will be disabled",
+		},
+		{
+			Name:      "resource-governor",
+			DependsOn: []string{"database", "docker"},
+			Init:      func() error { return initializeResourceGovernor(ctx) },
+			Impact:    "Containers will not be throttled under memory pressure",
+		},
+		{
+			Name:      "plugins",
+			DependsOn: []string{"database"},
+			Init:      initializePlugins,
+			Impact:    "Plugin features may be limited",
+		},
+		{
+			Name:      "backup",
+			DependsOn: []string{"database"},
+			Init:      initializeBackup,
+			Impact:    "Backup features may be limited",
+		},
+		{
+			Name:      "cloud-backup",
+			DependsOn: []string{"database", "system"},
+			Init:      initializeCloudBackup,
+			Impact:    "Cloud backup remotes cannot be managed",
+		},
+
+		// Directory services
+		{
+			Name:      "ad",
+			DependsOn: []string{"database"},
+			Init:      initializeAD,
+			Impact:    "AD features will be disabled",
+		},
+		{
+			Name:      "ad-dc",
+			DependsOn: []string{"database", "system"},
+			Init:      initializeADDC,
+			Impact:    "AD DC features will be disabled",
+		},
+		{
+			Name:   "kerberos",
+			Init:   func() error { return initializeKerberos(ctx) },
+			Impact: "Kerberos tickets of service accounts will not be renewed",
+		},
+
+		// Security and auditing
+		{
+			Name:      "audit-log",
+			DependsOn: []string{"database"},
+			Init:      initializeAuditLog,
+			Impact:    "Audit logging may be limited",
+		},
+		{
+			Name:      "access-log",
+			DependsOn: []string{"database"},
+			Init:      initializeAccessLog,
+			Impact:    "HTTP access logs will not be stored",
+		},
+		{
+			Name:      "failed-logins",
+			DependsOn: []string{"database"},
+			Init:      initializeFailedLoginService,
+			Impact:    "Failed login tracking may be limited",
+		},
+		{
+			Name:      "rbac",
+			DependsOn: []string{"database"},
+			Init:      initializeRBAC,
+			Impact:    "Only admins will be granted access to protected routes",
+		},
+		{
+			Name:      "twofa",
+			DependsOn: []string{"database"},
+			Init:      initializeTwoFA,
+			Impact:    "2FA may be disabled",
+		},
+		{
+			Name:      "mfa-enforcement",
+			DependsOn: []string{"database", "twofa"},
+			Init:      initializeMFAEnforcement,
+			Impact:    "2FA will not be required at login",
+		},
+		{
+			Name:      "saml",
+			DependsOn: []string{"database", "audit-log"},
+			Init:      func() error { return initializeSAML(ctx) },
+			Impact:    "SAML single sign-on will be disabled",
+		},
+		{
+			Name: "secrets",
+			Init: func() error {
+				// The provider was validated when the config was loaded
+				provider, err := config.NewSecretProvider(cfg.Secrets)
+				if err != nil {
+					return err
+				}
+				handlers.InitSecretProvider(provider)
+				return nil
+			},
+			Impact: "Secrets cannot be managed through the API",
+		},
+
+		// Monitoring, alerting and scheduled work
+		{
+			Name:      "updates",
+			DependsOn: []string{"database"},
+			Init:      initializeUpdateService,
+			Impact:    "Update checking may be limited",
+		},
+		{
+			Name:      "auto-updates",
+			DependsOn: []string{"backup", "notifications"},
+			Init:      func() error { return initializeAutoUpdates(ctx) },
+			Impact:    "System package updates cannot be applied",
+		},
+		{
+			Name:      "alerts",
+			DependsOn: []string{"database"},
+			Init:      initializeAlertService,
+			Impact:    "Email alerts may be disabled",
+		},
+		{
+			Name:      "scheduler",
+			DependsOn: []string{"database"},
+			Init:      initializeScheduler,
+			Impact:    "Scheduled tasks may be disabled",
+		},
+		{
+			Name:      "metrics",
+			DependsOn: []string{"database", "system-tasks", "scheduler"},
+			Init:      initializeMetrics,
+			Impact:    "Metrics collection may be disabled",
+		},
+		{
+			Name:      "metrics-export",
+			DependsOn: []string{"metrics"},
+			Init:      func() error { return initializeMetricsExporter(ctx) },
+			Impact:    "Metrics will not be pushed to the remote InfluxDB or Pushgateway",
+		},
+		{
+			Name:      "notifications",
+			DependsOn: []string{"database", "alerts"},
+			Init:      initializeNotifications,
+			Impact:    "Browser push notifications will be disabled",
+		},
+		{
+			Name:      "maintenance-summaries",
+			DependsOn: []string{"alerts"},
+			Init:      func() error { return initializeMaintenanceSummaries(ctx) },
+			Impact:    "Suppressed alert digests will not be sent",
+		},
+		{
+			Name:      "scrub-monitor",
+			DependsOn: []string{"system", "alerts"},
+			Init:      func() error { return initializeScrubMonitor(ctx) },
+			Impact:    "Scrub errors will not be alerted",
+		},
+		{
+			Name:      "smart-tests",
+			DependsOn: []string{"system", "alerts"},
+			Init:      func() error { return initializeSMARTTestScheduler(ctx) },
+			Impact:    "Failed SMART self-tests will not be alerted",
+		},
+		{
+			Name:      "volume-health",
+			DependsOn: []string{"database", "alerts"},
+			Init:      func() error { return initializeVolumeHealthMonitor(ctx) },
+			Impact:    "Failing volumes will not be alerted",
+		},
+		{
+			Name:      "telemetry",
+			DependsOn: []string{"database"},
+			Init: func() error {
+				initializeTelemetry(ctx)
+				return nil
+			},
+		},
+		{
+			Name: "tracing",
+			Init: func() error {
+				if !cfg.Tracing.Enabled {
+					return nil
+				}
+				shutdown, err := initializeTracing(cfg)
+				if err != nil {
+					return err
+				}
+				*shutdownTracing = shutdown
+				logger.Info("Tracing enabled", zap.String("endpoint", cfg.Tracing.OTLPEndpoint))
+				return nil
+			},
+			Impact: "Request traces will not be exported",
+		},
+	}
+}
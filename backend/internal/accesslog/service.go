@@ -0,0 +1,184 @@
+// Package accesslog stores HTTP access logs in the database. Entries are
+// queued and written in batches by a background goroutine so request
+// handling never waits on the database.
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// queueSize is the number of entries buffered before new ones are dropped
+	queueSize = 1024
+
+	// maxBatchSize is the maximum number of entries written in one insert
+	maxBatchSize = 100
+)
+
+// Service handles access log storage and queries
+type Service struct {
+	db    *gorm.DB
+	queue chan *models.AccessLog
+}
+
+var (
+	globalService *Service
+	once          sync.Once
+)
+
+// Initialize initializes the access log service and starts the writer
+func Initialize() (*Service, error) {
+	var initErr error
+	once.Do(func() {
+		db := database.GetDB()
+		if db == nil {
+			initErr = fmt.Errorf("database not initialized")
+			return
+		}
+
+		globalService = NewService(db)
+		go globalService.run()
+	})
+
+	return globalService, initErr
+}
+
+// GetService returns the global access log service, or nil if it has not
+// been initialized
+func GetService() *Service {
+	return globalService
+}
+
+// NewService creates an access log service. Call Record only on services
+// returned by Initialize, which also starts the background writer.
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:    db,
+		queue: make(chan *models.AccessLog, queueSize),
+	}
+}
+
+// Record queues an entry on the global service. It never blocks; entries are
+// dropped if the service is not initialized or the queue is full.
+func Record(entry *models.AccessLog) {
+	if s := GetService(); s != nil {
+		s.Record(entry)
+	}
+}
+
+// Record queues an entry for writing without blocking
+func (s *Service) Record(entry *models.AccessLog) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	select {
+	case s.queue <- entry:
+	default:
+		logger.Debug("Access log queue full, dropping entry", zap.String("path", entry.Path))
+	}
+}
+
+// run writes queued entries, batching whatever has accumulated
+func (s *Service) run() {
+	batch := make([]*models.AccessLog, 0, maxBatchSize)
+	for entry := range s.queue {
+		batch = append(batch, entry)
+	drain:
+		for len(batch) < maxBatchSize {
+			select {
+			case next := <-s.queue:
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		if err := s.db.Create(&batch).Error; err != nil {
+			logger.Error("Failed to write access logs", zap.Int("count", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+}
+
+// QueryParams represents access log query parameters
+type QueryParams struct {
+	UserID     *uint
+	PathPrefix string
+	MinStatus  int
+	MaxStatus  int
+	StartDate  *time.Time
+	EndDate    *time.Time
+	Limit      int
+	Offset     int
+}
+
+// Query retrieves access logs, newest first
+func (s *Service) Query(ctx context.Context, params *QueryParams) ([]models.AccessLog, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.AccessLog{})
+
+	if params.UserID != nil {
+		query = query.Where("user_id = ?", *params.UserID)
+	}
+	if params.PathPrefix != "" {
+		query = query.Where(`path LIKE ? ESCAPE '\'`, escapeLike(params.PathPrefix)+"%")
+	}
+	if params.MinStatus > 0 {
+		query = query.Where("status_code >= ?", params.MinStatus)
+	}
+	if params.MaxStatus > 0 {
+		query = query.Where("status_code <= ?", params.MaxStatus)
+	}
+	if params.StartDate != nil {
+		query = query.Where("created_at >= ?", *params.StartDate)
+	}
+	if params.EndDate != nil {
+		query = query.Where("created_at <= ?", *params.EndDate)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count access logs: %w", err)
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var logs []models.AccessLog
+	if err := query.Order("created_at DESC").Limit(limit).Offset(params.Offset).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query access logs: %w", err)
+	}
+
+	return logs, total, nil
+}
+
+// Cleanup deletes access logs older than retentionDays. A retention of 0 or
+// less keeps all logs.
+func (s *Service) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	result := s.db.WithContext(ctx).Where("created_at < ?", cutoff).Delete(&models.AccessLog{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete old access logs: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// escapeLike escapes LIKE wildcards so the prefix is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,118 @@
+package accesslog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/accesslog"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&models.AccessLog{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	return db
+}
+
+func TestMiddlewareLogsNullUserForAnonymousRequests(t *testing.T) {
+	database.DB = newTestDB(t)
+	t.Cleanup(func() { database.DB = nil })
+
+	if _, err := accesslog.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	handler := middleware.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/shares?token=secret", nil)
+	req.RemoteAddr = "192.0.2.7:43210"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Requests for the access logs themselves are never logged
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/admin/access-logs", nil))
+
+	var entries []models.AccessLog
+	deadline := time.Now().Add(2 * time.Second)
+	for len(entries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		database.DB.Find(&entries)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("got %d access log entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.UserID != nil {
+		t.Errorf("UserID = %d, want nil", *entry.UserID)
+	}
+	if entry.Path != "/api/v1/shares" || entry.StatusCode != http.StatusNotFound || entry.RemoteIP != "192.0.2.7" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.Query != "token=REDACTED" {
+		t.Errorf("Query = %q, want token redacted", entry.Query)
+	}
+}
+
+func TestCleanupDeletesExpiredEntries(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+
+	for _, age := range []time.Duration{
+		time.Hour,
+		6 * 24 * time.Hour,
+		8 * 24 * time.Hour,
+		30 * 24 * time.Hour,
+	} {
+		entry := models.AccessLog{CreatedAt: now.Add(-age), Method: "GET", Path: "/", StatusCode: 200}
+		if err := db.Create(&entry).Error; err != nil {
+			t.Fatalf("failed to create entry: %v", err)
+		}
+	}
+
+	service := accesslog.NewService(db)
+
+	deleted, err := service.Cleanup(context.Background(), 0)
+	if err != nil || deleted != 0 {
+		t.Fatalf("Cleanup(0) = %d, %v; want 0, nil (retention disabled)", deleted, err)
+	}
+
+	deleted, err = service.Cleanup(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("Cleanup(7) error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Cleanup(7) deleted %d entries, want 2", deleted)
+	}
+
+	var remaining int64
+	db.Model(&models.AccessLog{}).Where("created_at < ?", now.AddDate(0, 0, -7)).Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("%d entries older than 7 days remain", remaining)
+	}
+}
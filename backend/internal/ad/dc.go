@@ -2,13 +2,17 @@
 package ad
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/tracing"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DCService manages Active Directory Domain Controller functionality
@@ -128,7 +132,13 @@ func (dc *DCService) UpdateConfig(config *DCConfig) error {
 }
 
 // Provision provisions a new AD domain
-func (dc *DCService) Provision(opts ProvisionOptions) error {
+func (dc *DCService) Provision(ctx context.Context, opts ProvisionOptions) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ad.Provision", trace.WithAttributes(
+		attribute.String("ad.realm", opts.Realm),
+		attribute.String("ad.domain", opts.Domain),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	dc.mu.Lock()
 	defer dc.mu.Unlock()
 
@@ -149,7 +159,10 @@ func (dc *DCService) Provision(opts ProvisionOptions) error {
 	}
 
 	// Provision domain
-	if err := dc.sambaTool.ProvisionDomain(opts); err != nil {
+	_, provisionSpan := tracing.Tracer().Start(ctx, "ad.ProvisionDomain")
+	err = dc.sambaTool.ProvisionDomain(opts)
+	tracing.End(provisionSpan, err)
+	if err != nil {
 		return fmt.Errorf("failed to provision domain: %w", err)
 	}
 
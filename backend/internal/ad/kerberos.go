@@ -0,0 +1,350 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultKerberosCacheDir holds a credential cache per service principal
+	DefaultKerberosCacheDir = "/var/lib/stumpfworks/krb5cc"
+
+	// kerberosRenewBefore is how long before expiry a TGT is renewed
+	kerberosRenewBefore = 30 * time.Minute
+	// kerberosCheckInterval is how often tickets are checked for renewal
+	kerberosCheckInterval = 5 * time.Minute
+	// kerberosRenewLifetime is the renewable lifetime requested for TGTs
+	kerberosRenewLifetime = "7d"
+)
+
+var (
+	// ErrNoTicket is returned for principals without a credential cache
+	ErrNoTicket = errors.New("no Kerberos ticket for principal")
+
+	// principalRegex matches principals with a realm, e.g. nfs/nas.example.com@EXAMPLE.COM
+	principalRegex = regexp.MustCompile(`^[A-Za-z0-9._$-]+(/[A-Za-z0-9._-]+)?@[A-Za-z0-9.-]+$`)
+	// cacheNameRegex matches the characters replaced in cache file names
+	cacheNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+	// klistTimeLayouts are the timestamp formats klist prints in common locales
+	klistTimeLayouts = []string{
+		"01/02/2006 15:04:05",
+		"01/02/06 15:04:05",
+		"2006-01-02 15:04:05",
+		"02.01.2006 15:04:05",
+	}
+)
+
+// Replaced in tests
+var (
+	runKerberosCommand          = sysutil.RunCommand
+	runKerberosCommandWithInput = sysutil.RunCommandWithInput
+)
+
+// KerberosTicket is a ticket in the credential cache of a principal
+type KerberosTicket struct {
+	Principal            string    `json:"principal"`        // Owner of the credential cache
+	ServicePrincipal     string    `json:"servicePrincipal"` // krbtgt/REALM@REALM for TGTs
+	ExpiresAt            time.Time `json:"expiresAt"`
+	RenewUntil           time.Time `json:"renewUntil"` // Zero for tickets that are not renewable
+	ForwardableRenewable bool      `json:"forwardableRenewable"`
+}
+
+// IsTGT reports whether the ticket is a ticket-granting ticket
+func (t KerberosTicket) IsTGT() bool {
+	return strings.HasPrefix(t.ServicePrincipal, "krbtgt/")
+}
+
+// KerberosManager obtains and renews the TGTs of service accounts, such as
+// the NFS service principal, keeping a credential cache per principal
+type KerberosManager struct {
+	cacheDir string
+	mu       sync.Mutex
+	keytabs  map[string]string // Keytab of each principal obtained with one, to obtain a new TGT once renewal runs out
+}
+
+var globalKerberosManager *KerberosManager
+
+// NewKerberosManager creates the Kerberos ticket manager, keeping credential
+// caches in cacheDir
+func NewKerberosManager(cacheDir string) (*KerberosManager, error) {
+	if !sysutil.CommandExists("kinit") || !sysutil.CommandExists("klist") {
+		return nil, fmt.Errorf("kinit not available (install 'krb5-user' package)")
+	}
+	m := newKerberosManager(cacheDir)
+	globalKerberosManager = m
+	return m, nil
+}
+
+func newKerberosManager(cacheDir string) *KerberosManager {
+	return &KerberosManager{cacheDir: cacheDir, keytabs: make(map[string]string)}
+}
+
+// GetKerberosManager returns the global Kerberos ticket manager
+func GetKerberosManager() *KerberosManager {
+	return globalKerberosManager
+}
+
+// CachePath returns the credential cache of a principal, for services that
+// are pointed at it with KRB5CCNAME
+func (m *KerberosManager) CachePath(principal string) string {
+	return filepath.Join(m.cacheDir, cacheNameRegex.ReplaceAllString(principal, "_"))
+}
+
+// ObtainTGT obtains a renewable TGT for principal, from keytab if it is set
+// and with password otherwise
+func (m *KerberosManager) ObtainTGT(principal, password, keytab string) error {
+	if !principalRegex.MatchString(principal) {
+		return fmt.Errorf("invalid principal %q", principal)
+	}
+	if (password == "") == (keytab == "") {
+		return fmt.Errorf("either a password or a keytab is required")
+	}
+	if keytab != "" && !filepath.IsAbs(keytab) {
+		return fmt.Errorf("keytab must be an absolute path")
+	}
+	if err := os.MkdirAll(m.cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create credential cache directory: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	args := []string{"-c", "FILE:" + m.CachePath(principal), "-f", "-r", kerberosRenewLifetime}
+	var err error
+	if keytab != "" {
+		_, err = runKerberosCommand("kinit", append(args, "-k", "-t", keytab, principal)...)
+	} else {
+		_, err = runKerberosCommandWithInput(password+"\n", "kinit", append(args, principal)...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to obtain TGT for %s: %w", principal, err)
+	}
+
+	if keytab != "" {
+		m.keytabs[principal] = keytab
+	} else {
+		delete(m.keytabs, principal)
+	}
+	log.Info().Str("principal", principal).Msg("Kerberos TGT obtained")
+	return nil
+}
+
+// RenewTGT renews the TGT of principal
+func (m *KerberosManager) RenewTGT(principal string) error {
+	cache, err := m.cache(principal)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := runKerberosCommand("kinit", "-R", "-c", "FILE:"+cache, principal); err != nil {
+		return fmt.Errorf("failed to renew TGT for %s: %w", principal, err)
+	}
+	return nil
+}
+
+// DestroyTGT destroys the tickets of principal and stops renewing them
+func (m *KerberosManager) DestroyTGT(principal string) error {
+	cache, err := m.cache(principal)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := runKerberosCommand("kdestroy", "-c", "FILE:"+cache); err != nil {
+		return fmt.Errorf("failed to destroy tickets of %s: %w", principal, err)
+	}
+	delete(m.keytabs, principal)
+	log.Info().Str("principal", principal).Msg("Kerberos tickets destroyed")
+	return nil
+}
+
+// ListTickets returns the tickets of all principals
+func (m *KerberosManager) ListTickets() ([]KerberosTicket, error) {
+	caches, err := filepath.Glob(filepath.Join(m.cacheDir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credential caches: %w", err)
+	}
+
+	tickets := []KerberosTicket{}
+	for _, cache := range caches {
+		output, err := runKerberosCommand("klist", "-f", "-c", "FILE:"+cache)
+		if err != nil {
+			// Expired caches make klist fail; they are listed again once renewed
+			log.Debug().Err(err).Str("cache", cache).Msg("Skipping unreadable credential cache")
+			continue
+		}
+		parsed, err := parseKlist(output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tickets in %s: %w", cache, err)
+		}
+		tickets = append(tickets, parsed...)
+	}
+	return tickets, nil
+}
+
+// Start renews TGTs that expire within 30 minutes, checking every 5 minutes
+// until ctx is cancelled. TGTs that can no longer be renewed are obtained
+// again if they were obtained with a keytab.
+func (m *KerberosManager) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(kerberosCheckInterval)
+		defer ticker.Stop()
+		for {
+			m.renewExpiring(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// renewExpiring renews the TGTs that expire within kerberosRenewBefore of now
+func (m *KerberosManager) renewExpiring(now time.Time) {
+	tickets, err := m.ListTickets()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list Kerberos tickets")
+		return
+	}
+
+	for _, ticket := range tickets {
+		if !ticket.IsTGT() || ticket.ExpiresAt.Sub(now) > kerberosRenewBefore {
+			continue
+		}
+
+		err := fmt.Errorf("renewable lifetime ended")
+		if ticket.RenewUntil.After(now) {
+			err = m.RenewTGT(ticket.Principal)
+		}
+		if err != nil {
+			m.mu.Lock()
+			keytab, ok := m.keytabs[ticket.Principal]
+			m.mu.Unlock()
+			if ok {
+				err = m.ObtainTGT(ticket.Principal, "", keytab)
+			}
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("principal", ticket.Principal).Time("expires", ticket.ExpiresAt).Msg("Failed to renew Kerberos TGT")
+			continue
+		}
+		log.Info().Str("principal", ticket.Principal).Msg("Kerberos TGT renewed")
+	}
+}
+
+// cache returns the existing credential cache of principal
+func (m *KerberosManager) cache(principal string) (string, error) {
+	if !principalRegex.MatchString(principal) {
+		return "", fmt.Errorf("invalid principal %q", principal)
+	}
+	cache := m.CachePath(principal)
+	if _, err := os.Stat(cache); err != nil {
+		return "", ErrNoTicket
+	}
+	return cache, nil
+}
+
+// parseKlist parses the output of klist -f:
+//
+//	Ticket cache: FILE:/var/lib/stumpfworks/krb5cc/nfs_nas.example.com_EXAMPLE.COM
+//	Default principal: nfs/nas.example.com@EXAMPLE.COM
+//
+//	Valid starting       Expires              Service principal
+//	11/20/2025 10:00:00  11/20/2025 20:00:00  krbtgt/EXAMPLE.COM@EXAMPLE.COM
+//		renew until 11/27/2025 10:00:00, Flags: FRIA
+func parseKlist(output string) ([]KerberosTicket, error) {
+	var principal string
+	var tickets []KerberosTicket
+	inTickets := false
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "Default principal:"):
+			principal = strings.TrimSpace(strings.TrimPrefix(trimmed, "Default principal:"))
+		case strings.HasPrefix(trimmed, "Valid starting"):
+			inTickets = true
+		case !inTickets:
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			// Details of the ticket above
+			if len(tickets) == 0 {
+				return nil, fmt.Errorf("ticket details without a ticket: %q", trimmed)
+			}
+			if err := parseKlistDetails(&tickets[len(tickets)-1], trimmed); err != nil {
+				return nil, err
+			}
+		default:
+			ticket, err := parseKlistTicket(trimmed)
+			if err != nil {
+				return nil, err
+			}
+			ticket.Principal = principal
+			tickets = append(tickets, ticket)
+		}
+	}
+	return tickets, nil
+}
+
+// parseKlistTicket parses a "start expires service" line, whose timestamps
+// have a date and a time
+func parseKlistTicket(line string) (KerberosTicket, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 {
+		return KerberosTicket{}, fmt.Errorf("unexpected ticket line: %q", line)
+	}
+	expires, err := parseKlistTime(fields[2] + " " + fields[3])
+	if err != nil {
+		return KerberosTicket{}, err
+	}
+	return KerberosTicket{ServicePrincipal: fields[4], ExpiresAt: expires}, nil
+}
+
+// parseKlistDetails parses a "renew until <time>, Flags: <flags>" line, of
+// which either part may be missing
+func parseKlistDetails(ticket *KerberosTicket, line string) error {
+	for _, part := range strings.Split(line, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "renew until "):
+			renewUntil, err := parseKlistTime(strings.TrimPrefix(part, "renew until "))
+			if err != nil {
+				return err
+			}
+			ticket.RenewUntil = renewUntil
+		case strings.HasPrefix(part, "Flags: "):
+			flags := strings.TrimPrefix(part, "Flags: ")
+			ticket.ForwardableRenewable = strings.Contains(flags, "F") && strings.Contains(flags, "R")
+		}
+	}
+	return nil
+}
+
+// parseKlistTime parses a klist timestamp, which is in local time
+func parseKlistTime(s string) (time.Time, error) {
+	for _, layout := range klistTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unexpected klist timestamp %q", s)
+}
@@ -0,0 +1,97 @@
+package ad
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleKlist = `Ticket cache: FILE:/var/lib/stumpfworks/krb5cc/nfs_nas.example.com_EXAMPLE.COM
+Default principal: nfs/nas.example.com@EXAMPLE.COM
+
+Valid starting       Expires              Service principal
+11/20/2025 10:00:00  11/20/2025 20:00:00  krbtgt/EXAMPLE.COM@EXAMPLE.COM
+	renew until 11/27/2025 10:00:00, Flags: FRIA
+11/20/2025 10:05:12  11/20/2025 20:00:00  ldap/dc1.example.com@EXAMPLE.COM
+	Flags: FAT
+`
+
+func TestParseKlist(t *testing.T) {
+	tickets, err := parseKlist(sampleKlist)
+	if err != nil {
+		t.Fatalf("parseKlist: %v", err)
+	}
+	want := []KerberosTicket{
+		{
+			Principal:            "nfs/nas.example.com@EXAMPLE.COM",
+			ServicePrincipal:     "krbtgt/EXAMPLE.COM@EXAMPLE.COM",
+			ExpiresAt:            time.Date(2025, 11, 20, 20, 0, 0, 0, time.Local),
+			RenewUntil:           time.Date(2025, 11, 27, 10, 0, 0, 0, time.Local),
+			ForwardableRenewable: true,
+		},
+		{
+			Principal:        "nfs/nas.example.com@EXAMPLE.COM",
+			ServicePrincipal: "ldap/dc1.example.com@EXAMPLE.COM",
+			ExpiresAt:        time.Date(2025, 11, 20, 20, 0, 0, 0, time.Local),
+		},
+	}
+	if !reflect.DeepEqual(tickets, want) {
+		t.Errorf("parseKlist() =\n%+v\nwant\n%+v", tickets, want)
+	}
+
+	// Two-digit years, as some locales print them
+	tickets, err = parseKlist("Default principal: a@B\n\nValid starting     Expires            Service principal\n11/20/25 10:00:00  11/20/25 20:00:00  krbtgt/B@B\n")
+	if err != nil || len(tickets) != 1 || tickets[0].ExpiresAt.Year() != 2025 {
+		t.Errorf("parseKlist(short years) = %+v, %v", tickets, err)
+	}
+
+	if _, err := parseKlist("Valid starting  Expires  Service principal\n11/20/2025  krbtgt/B@B\n"); err == nil {
+		t.Error("parseKlist accepted a malformed ticket line")
+	}
+}
+
+func TestKerberosManagerRenewExpiring(t *testing.T) {
+	m := newKerberosManager(t.TempDir())
+	principal := "nfs/nas.example.com@EXAMPLE.COM"
+	if err := os.WriteFile(m.CachePath(principal), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	m.keytabs[principal] = "/etc/krb5.keytab"
+
+	var commands []string
+	realRun := runKerberosCommand
+	runKerberosCommand = func(name string, args ...string) (string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		if name == "klist" {
+			return sampleKlist, nil
+		}
+		return "", nil
+	}
+	t.Cleanup(func() { runKerberosCommand = realRun })
+
+	cache := "FILE:" + filepath.Join(m.cacheDir, "nfs_nas.example.com_EXAMPLE.COM")
+
+	// An hour before expiry nothing is renewed
+	m.renewExpiring(time.Date(2025, 11, 20, 19, 0, 0, 0, time.Local))
+	if want := []string{"klist -f -c " + cache}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands an hour before expiry = %q, want %q", commands, want)
+	}
+
+	// 20 minutes before expiry the TGT is renewed; the service ticket is not
+	commands = nil
+	m.renewExpiring(time.Date(2025, 11, 20, 19, 40, 0, 0, time.Local))
+	if want := []string{"klist -f -c " + cache, "kinit -R -c " + cache + " " + principal}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands before expiry = %q, want %q", commands, want)
+	}
+
+	// Past the renewable lifetime a new TGT is obtained from the keytab
+	commands = nil
+	m.renewExpiring(time.Date(2025, 11, 27, 12, 0, 0, 0, time.Local))
+	want := []string{"klist -f -c " + cache, "kinit -c " + cache + " -f -r 7d -k -t /etc/krb5.keytab " + principal}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("commands after renewable lifetime = %q, want %q", commands, want)
+	}
+}
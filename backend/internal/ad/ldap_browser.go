@@ -0,0 +1,303 @@
+package ad
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-ldap/ldap/v3/gssapi"
+)
+
+const (
+	// sambaLDAPURL is the directory of the local domain controller
+	sambaLDAPURL = "ldap://localhost:389"
+	// sambaKeytabPath holds the keys of the domain controller's machine account
+	sambaKeytabPath = "/var/lib/samba/private/secrets.keytab"
+	// sambaCAPath is the CA of the certificate Samba generates at provisioning
+	sambaCAPath = "/var/lib/samba/private/tls/ca.pem"
+	// krb5ConfPath is the Kerberos configuration of the realm
+	krb5ConfPath = "/etc/krb5.conf"
+
+	// ldapSizeLimit caps the entries a search returns
+	ldapSizeLimit = 1000
+	// ldapTimeLimit is the server-side time limit of a search in seconds
+	ldapTimeLimit = 30
+)
+
+var (
+	// ErrInvalidDN is returned for malformed distinguished names
+	ErrInvalidDN = errors.New("invalid distinguished name")
+	// ErrInvalidFilter is returned for malformed LDAP filters
+	ErrInvalidFilter = errors.New("invalid LDAP filter")
+	// ErrInvalidAttribute is returned for malformed attribute names
+	ErrInvalidAttribute = errors.New("invalid attribute name")
+	// ErrLDAPObjectNotFound is returned when a DN does not exist
+	ErrLDAPObjectNotFound = errors.New("LDAP object not found")
+
+	// attributeNameRegex matches LDAP attribute descriptions
+	attributeNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*(;[A-Za-z0-9-]+)*$`)
+)
+
+// LDAPEntry is an object of the directory
+type LDAPEntry struct {
+	DN         string              `json:"dn"`
+	Attributes map[string][]string `json:"attributes"`
+}
+
+// ldapConn is the part of *ldap.Conn the browser uses
+type ldapConn interface {
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Modify(modifyRequest *ldap.ModifyRequest) error
+	Close() error
+}
+
+// LDAPBrowser gives raw access to the Samba directory for troubleshooting.
+// Every call opens its own connection, bound with the Kerberos keys of the
+// domain controller's machine account.
+type LDAPBrowser struct {
+	baseDN string
+	dial   func() (ldapConn, error)
+}
+
+// LDAPBrowser returns a browser for the directory of the provisioned domain
+func (dc *DCService) LDAPBrowser() (*LDAPBrowser, error) {
+	if !dc.IsProvisioned() {
+		return nil, fmt.Errorf("domain is not provisioned")
+	}
+	return NewLDAPBrowser(dc.GetConfig().Realm)
+}
+
+// NewLDAPBrowser creates a browser for the directory of the local domain
+// controller of realm
+func NewLDAPBrowser(realm string) (*LDAPBrowser, error) {
+	if realm == "" {
+		return nil, fmt.Errorf("realm is required")
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	host := strings.ToLower(strings.SplitN(hostname, ".", 2)[0])
+	realm = strings.ToUpper(realm)
+	fqdn := host + "." + strings.ToLower(realm)
+
+	return newLDAPBrowser(realmToDN(realm), func() (ldapConn, error) {
+		return dialSamba(realm, strings.ToUpper(host)+"$", fqdn)
+	}), nil
+}
+
+func newLDAPBrowser(baseDN string, dial func() (ldapConn, error)) *LDAPBrowser {
+	return &LDAPBrowser{baseDN: baseDN, dial: dial}
+}
+
+// BaseDN returns the DN of the domain
+func (b *LDAPBrowser) BaseDN() string {
+	return b.baseDN
+}
+
+// Search returns the objects below baseDN that match filter, with the
+// given attributes or all of them if attrs is empty. An empty baseDN
+// searches the whole domain and an empty filter matches every object. At
+// most ldapSizeLimit entries are returned.
+func (b *LDAPBrowser) Search(baseDN, filter string, attrs []string) ([]LDAPEntry, error) {
+	if baseDN == "" {
+		baseDN = b.baseDN
+	}
+	if _, err := ldap.ParseDN(baseDN); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDN, err)
+	}
+	if filter == "" {
+		filter = "(objectClass=*)"
+	}
+	if _, err := ldap.CompileFilter(filter); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	for _, attr := range attrs {
+		if attr != "*" && attr != "+" && !attributeNameRegex.MatchString(attr) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAttribute, attr)
+		}
+	}
+
+	conn, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		ldapSizeLimit, ldapTimeLimit, false, filter, attrs, nil,
+	))
+	// Hitting the size limit still returns the entries found until then
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrLDAPObjectNotFound
+		}
+		return nil, fmt.Errorf("LDAP search failed: %w", err)
+	}
+	if result == nil {
+		return []LDAPEntry{}, nil
+	}
+
+	entries := make([]LDAPEntry, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		entries = append(entries, newLDAPEntry(entry))
+	}
+	return entries, nil
+}
+
+// GetObject returns the object dn with all its attributes
+func (b *LDAPBrowser) GetObject(dn string) (*LDAPEntry, error) {
+	if _, err := ldap.ParseDN(dn); err != nil || dn == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDN, dn)
+	}
+
+	conn, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases,
+		1, ldapTimeLimit, false, "(objectClass=*)", []string{"*"}, nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, ErrLDAPObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LDAP search failed: %w", err)
+	}
+	if len(result.Entries) == 0 {
+		return nil, ErrLDAPObjectNotFound
+	}
+
+	entry := newLDAPEntry(result.Entries[0])
+	return &entry, nil
+}
+
+// ModifyAttribute replaces the values of an attribute of the object dn.
+// No values remove the attribute.
+func (b *LDAPBrowser) ModifyAttribute(dn, attribute string, values []string) error {
+	if _, err := ldap.ParseDN(dn); err != nil || dn == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidDN, dn)
+	}
+	if !attributeNameRegex.MatchString(attribute) {
+		return fmt.Errorf("%w: %q", ErrInvalidAttribute, attribute)
+	}
+
+	conn, err := b.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	request := ldap.NewModifyRequest(dn, nil)
+	request.Replace(attribute, values)
+	if err := conn.Modify(request); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return ErrLDAPObjectNotFound
+		}
+		return fmt.Errorf("failed to modify %s of %s: %w", attribute, dn, err)
+	}
+	return nil
+}
+
+// dialSamba connects to the local directory over StartTLS and binds as
+// principal with GSSAPI
+func dialSamba(realm, principal, fqdn string) (ldapConn, error) {
+	conn, err := ldap.DialURL(sambaLDAPURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", sambaLDAPURL, err)
+	}
+
+	// Samba rejects binds on plain connections by default. Its certificate
+	// is self-signed; without the CA there is nothing to verify it against,
+	// which is acceptable as the connection never leaves the host.
+	tlsConfig := &tls.Config{ServerName: fqdn}
+	if ca, err := os.ReadFile(sambaCAPath); err == nil {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+	} else {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if err := conn.StartTLS(tlsConfig); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start TLS: %w", err)
+	}
+
+	krb, err := gssapi.NewClientWithKeytab(principal, realm, sambaKeytabPath, krb5ConfPath)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to load machine account keytab: %w", err)
+	}
+	defer krb.Close()
+	if err := conn.GSSAPIBind(krb, "ldap/"+fqdn, ""); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("GSSAPI bind as %s failed: %w", principal, err)
+	}
+	return conn, nil
+}
+
+// newLDAPEntry maps an entry to an LDAPEntry. The GUID and SID of objects
+// are formatted the way Windows shows them; other binary values are base64
+// encoded.
+func newLDAPEntry(entry *ldap.Entry) LDAPEntry {
+	result := LDAPEntry{DN: entry.DN, Attributes: make(map[string][]string, len(entry.Attributes))}
+	for _, attr := range entry.Attributes {
+		values := make([]string, 0, len(attr.ByteValues))
+		for _, value := range attr.ByteValues {
+			switch {
+			case strings.EqualFold(attr.Name, "objectGUID") && len(value) == 16:
+				values = append(values, formatGUID(value))
+			case strings.EqualFold(attr.Name, "objectSid") && len(value) >= 8:
+				values = append(values, formatSID(value))
+			case utf8.Valid(value):
+				values = append(values, string(value))
+			default:
+				values = append(values, base64.StdEncoding.EncodeToString(value))
+			}
+		}
+		result.Attributes[attr.Name] = values
+	}
+	return result
+}
+
+// formatGUID formats a binary GUID, whose first three fields are little endian
+func formatGUID(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10], b[10:16])
+}
+
+// formatSID formats a binary security identifier as S-1-5-21-...
+func formatSID(b []byte) string {
+	var authority uint64
+	for _, v := range b[2:8] {
+		authority = authority<<8 | uint64(v)
+	}
+	sid := fmt.Sprintf("S-%d-%d", b[0], authority)
+	for i := 0; i < int(b[1]) && 8+4*i+4 <= len(b); i++ {
+		sid += fmt.Sprintf("-%d", binary.LittleEndian.Uint32(b[8+4*i:]))
+	}
+	return sid
+}
+
+// realmToDN returns the domain DN of a realm, DC=example,DC=com for EXAMPLE.COM
+func realmToDN(realm string) string {
+	parts := strings.Split(strings.ToLower(realm), ".")
+	for i, part := range parts {
+		parts[i] = "DC=" + part
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,198 @@
+package ad
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeDirectory serves the entries of an LDIF file. Filters are ignored;
+// scopes, attribute selection and missing objects behave like a server.
+type fakeDirectory struct {
+	entries  []*ldap.Entry
+	searches []*ldap.SearchRequest
+	modifies []*ldap.ModifyRequest
+}
+
+func loadLDIF(t *testing.T, path string) *fakeDirectory {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	dir := &fakeDirectory{}
+	var entry *ldap.Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			t.Fatalf("invalid LDIF line %q", line)
+		}
+		raw := []byte(strings.TrimSpace(value))
+		if strings.HasPrefix(value, ":") {
+			if raw, err = base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:])); err != nil {
+				t.Fatalf("invalid base64 in %q: %v", line, err)
+			}
+		}
+
+		if name == "dn" {
+			entry = &ldap.Entry{DN: string(raw)}
+			dir.entries = append(dir.entries, entry)
+			continue
+		}
+		var attr *ldap.EntryAttribute
+		for _, a := range entry.Attributes {
+			if a.Name == name {
+				attr = a
+			}
+		}
+		if attr == nil {
+			attr = &ldap.EntryAttribute{Name: name}
+			entry.Attributes = append(entry.Attributes, attr)
+		}
+		attr.Values = append(attr.Values, string(raw))
+		attr.ByteValues = append(attr.ByteValues, raw)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return dir
+}
+
+func (d *fakeDirectory) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	d.searches = append(d.searches, req)
+	base, err := ldap.ParseDN(req.BaseDN)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+
+	result := &ldap.SearchResult{}
+	found := false
+	for _, entry := range d.entries {
+		dn, _ := ldap.ParseDN(entry.DN)
+		if base.EqualFold(dn) {
+			found = true
+		} else if req.Scope == ldap.ScopeBaseObject || !base.AncestorOfFold(dn) {
+			continue
+		}
+		result.Entries = append(result.Entries, selectAttributes(entry, req.Attributes))
+	}
+	if !found {
+		return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+	}
+	return result, nil
+}
+
+func (d *fakeDirectory) Modify(req *ldap.ModifyRequest) error {
+	d.modifies = append(d.modifies, req)
+	return nil
+}
+
+func (d *fakeDirectory) Close() error {
+	return nil
+}
+
+func selectAttributes(entry *ldap.Entry, attrs []string) *ldap.Entry {
+	if len(attrs) == 0 || attrs[0] == "*" {
+		return entry
+	}
+	selected := &ldap.Entry{DN: entry.DN}
+	for _, attr := range entry.Attributes {
+		for _, name := range attrs {
+			if strings.EqualFold(attr.Name, name) {
+				selected.Attributes = append(selected.Attributes, attr)
+			}
+		}
+	}
+	return selected
+}
+
+func newTestBrowser(t *testing.T) (*LDAPBrowser, *fakeDirectory) {
+	t.Helper()
+	dir := loadLDIF(t, "testdata/directory.ldif")
+	return newLDAPBrowser(realmToDN("EXAMPLE.COM"), func() (ldapConn, error) { return dir, nil }), dir
+}
+
+func TestLDAPBrowserGetObject(t *testing.T) {
+	browser, _ := newTestBrowser(t)
+
+	// DNs match regardless of case and spacing
+	entry, err := browser.GetObject("cn=jane doe, cn=users, dc=EXAMPLE, dc=com")
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	want := map[string][]string{
+		"objectClass":     {"top", "person", "organizationalPerson", "user"},
+		"cn":              {"Jane Doe"},
+		"sAMAccountName":  {"jdoe"},
+		"memberOf":        {"CN=Domain Admins,CN=Users,DC=example,DC=com", "CN=Backup Operators,CN=Builtin,DC=example,DC=com"},
+		"objectGUID":      {"6f5a3c2e-8f1d-4b7a-9c3e-2d4f6a8b0c1e"},
+		"objectSid":       {"S-1-5-21-1004336348-1177238915-682003330-1104"},
+		"userCertificate": {"MIIBCgKCAQEAwQ=="},
+	}
+	if entry.DN != "CN=Jane Doe,CN=Users,DC=example,DC=com" || !reflect.DeepEqual(entry.Attributes, want) {
+		t.Errorf("GetObject() = %s %v\nwant attributes %v", entry.DN, entry.Attributes, want)
+	}
+
+	if _, err := browser.GetObject("CN=Nobody,CN=Users,DC=example,DC=com"); !errors.Is(err, ErrLDAPObjectNotFound) {
+		t.Errorf("GetObject(missing) error = %v, want ErrLDAPObjectNotFound", err)
+	}
+	for _, dn := range []string{"", "CN=Jane Doe,CN", "not a dn"} {
+		if _, err := browser.GetObject(dn); !errors.Is(err, ErrInvalidDN) {
+			t.Errorf("GetObject(%q) error = %v, want ErrInvalidDN", dn, err)
+		}
+	}
+}
+
+func TestLDAPBrowserSearch(t *testing.T) {
+	browser, dir := newTestBrowser(t)
+
+	entries, err := browser.Search("", "", []string{"cn"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	req := dir.searches[0]
+	if req.BaseDN != "DC=example,DC=com" || req.Filter != "(objectClass=*)" || req.Scope != ldap.ScopeWholeSubtree || req.SizeLimit != ldapSizeLimit {
+		t.Errorf("search request = %+v, want a subtree search of the domain", req)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Search() returned %d entries, want 4", len(entries))
+	}
+	if got := entries[2].Attributes; !reflect.DeepEqual(got, map[string][]string{"cn": {"Jane Doe"}}) {
+		t.Errorf("attributes of %s = %v, want only cn", entries[2].DN, got)
+	}
+
+	if _, err := browser.Search("", "(objectClass=user", nil); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Search(unbalanced filter) error = %v, want ErrInvalidFilter", err)
+	}
+	if _, err := browser.Search("", "", []string{"cn)(x"}); !errors.Is(err, ErrInvalidAttribute) {
+		t.Errorf("Search(bad attribute) error = %v, want ErrInvalidAttribute", err)
+	}
+	if len(dir.searches) != 1 {
+		t.Errorf("invalid searches reached the directory: %d searches", len(dir.searches))
+	}
+}
+
+func TestLDAPBrowserModifyAttribute(t *testing.T) {
+	browser, dir := newTestBrowser(t)
+
+	if err := browser.ModifyAttribute("CN=Jane Doe,CN=Users,DC=example,DC=com", "description", []string{"On leave"}); err != nil {
+		t.Fatalf("ModifyAttribute: %v", err)
+	}
+	changes := dir.modifies[0].Changes
+	if len(changes) != 1 || changes[0].Operation != ldap.ReplaceAttribute || changes[0].Modification.Type != "description" {
+		t.Errorf("modify request = %+v, want a replace of description", dir.modifies[0])
+	}
+}
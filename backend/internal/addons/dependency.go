@@ -0,0 +1,256 @@
+package addons
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+)
+
+// AddonDependency declares that an addon needs another addon
+type AddonDependency struct {
+	ID         string `json:"id"`
+	MinVersion string `json:"min_version,omitempty"` // Empty accepts any version
+	Optional   bool   `json:"optional,omitempty"`    // Missing optional dependencies only cause a warning
+}
+
+// ResolutionPlan is the order in which an addon and its dependencies are installed
+type ResolutionPlan struct {
+	AddonID          string   `json:"addon_id"`
+	Install          []string `json:"install"`           // Dependencies first, the addon last
+	AlreadyInstalled []string `json:"already_installed"` // Dependencies that are already satisfied
+	Warnings         []string `json:"warnings,omitempty"`
+}
+
+// ConflictError reports an addon that is incompatible with an installed one
+type ConflictError struct {
+	AddonID     string `json:"addon_id"`
+	InstalledID string `json:"installed_id"`
+	DeclaredBy  string `json:"declared_by"` // The addon whose manifest lists the conflict
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("addon %s conflicts with installed addon %s (declared by %s)", e.AddonID, e.InstalledID, e.DeclaredBy)
+}
+
+// DependencyResolver plans the installation of addons with their dependencies
+type DependencyResolver struct {
+	catalog   []Manifest
+	installed func() (map[string]string, error) // Addon ID to installed version
+}
+
+// NewDependencyResolver creates a resolver for the addons in catalog.
+// installed returns the versions of the installed addons by ID.
+func NewDependencyResolver(catalog []Manifest, installed func() (map[string]string, error)) *DependencyResolver {
+	return &DependencyResolver{
+		catalog:   catalog,
+		installed: installed,
+	}
+}
+
+// installedAddons returns the versions of the addons marked installed in the database
+func installedAddons() (map[string]string, error) {
+	var installations []models.AddonInstallation
+	if err := database.DB.Where("installed = ?", true).Find(&installations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list installed addons: %w", err)
+	}
+	versions := make(map[string]string, len(installations))
+	for _, installation := range installations {
+		versions[installation.AddonID] = installation.Version
+	}
+	return versions, nil
+}
+
+func (r *DependencyResolver) manifest(addonID string) *Manifest {
+	for i := range r.catalog {
+		if r.catalog[i].ID == addonID {
+			return &r.catalog[i]
+		}
+	}
+	return nil
+}
+
+// Resolve returns the order in which addonID and its missing dependencies
+// must be installed. It fails on dependency cycles and on required
+// dependencies that are unknown or too old.
+func (r *DependencyResolver) Resolve(addonID string) (*ResolutionPlan, error) {
+	if r.manifest(addonID) == nil {
+		return nil, fmt.Errorf("addon not found: %s", addonID)
+	}
+	installed, err := r.installed()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ResolutionPlan{
+		AddonID:          addonID,
+		Install:          []string{},
+		AlreadyInstalled: []string{},
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var path []string
+
+	// Depth-first search that appends each addon after its dependencies
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == id {
+					start = i
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), id)
+			return fmt.Errorf("circular addon dependency: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+		defer func() { path = path[:len(path)-1] }()
+
+		manifest := r.manifest(id)
+		for _, dep := range manifest.Dependencies {
+			if version, ok := installed[dep.ID]; ok {
+				if dep.MinVersion != "" && compareVersions(version, dep.MinVersion) < 0 {
+					if err := r.unsatisfied(plan, id, dep, fmt.Sprintf("installed version %s is older than %s", version, dep.MinVersion)); err != nil {
+						return err
+					}
+					continue
+				}
+				if state[dep.ID] != visited {
+					state[dep.ID] = visited
+					plan.AlreadyInstalled = append(plan.AlreadyInstalled, dep.ID)
+				}
+				continue
+			}
+
+			depManifest := r.manifest(dep.ID)
+			if depManifest == nil {
+				if err := r.unsatisfied(plan, id, dep, "addon is not available"); err != nil {
+					return err
+				}
+				continue
+			}
+			if dep.MinVersion != "" && compareVersions(depManifest.Version, dep.MinVersion) < 0 {
+				if err := r.unsatisfied(plan, id, dep, fmt.Sprintf("available version %s is older than %s", depManifest.Version, dep.MinVersion)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := visit(dep.ID); err != nil {
+				return err
+			}
+		}
+
+		state[id] = visited
+		plan.Install = append(plan.Install, id)
+		return nil
+	}
+
+	if err := visit(addonID); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// unsatisfied records a warning for an optional dependency that cannot be
+// installed and returns an error for a required one
+func (r *DependencyResolver) unsatisfied(plan *ResolutionPlan, addonID string, dep AddonDependency, reason string) error {
+	if dep.Optional {
+		plan.Warnings = append(plan.Warnings,
+			fmt.Sprintf("optional dependency %s of %s skipped: %s", dep.ID, addonID, reason))
+		return nil
+	}
+	return fmt.Errorf("dependency %s of %s cannot be satisfied: %s", dep.ID, addonID, reason)
+}
+
+// CheckConflicts returns the installed addons that are incompatible with
+// addonID, whether the conflict is declared by addonID or by the installed addon
+func (r *DependencyResolver) CheckConflicts(addonID string) ([]ConflictError, error) {
+	manifest := r.manifest(addonID)
+	if manifest == nil {
+		return nil, fmt.Errorf("addon not found: %s", addonID)
+	}
+	installed, err := r.installed()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(installed))
+	for id := range installed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	conflicts := []ConflictError{}
+	for _, id := range manifest.Conflicts {
+		if _, ok := installed[id]; ok {
+			conflicts = append(conflicts, ConflictError{AddonID: addonID, InstalledID: id, DeclaredBy: addonID})
+		}
+	}
+	for _, id := range ids {
+		other := r.manifest(id)
+		if other == nil || id == addonID {
+			continue
+		}
+		for _, conflict := range other.Conflicts {
+			if conflict == addonID && !manifest.conflictsWith(id) {
+				conflicts = append(conflicts, ConflictError{AddonID: addonID, InstalledID: id, DeclaredBy: id})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+func (m *Manifest) conflictsWith(addonID string) bool {
+	for _, id := range m.Conflicts {
+		if id == addonID {
+			return true
+		}
+	}
+	return false
+}
+
+// compareVersions compares dotted numeric versions such as 1.10.2, ignoring a
+// leading "v" and pre-release suffixes. It returns -1, 0 or 1.
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na = leadingInt(pa[i])
+		}
+		if i < len(pb) {
+			nb = leadingInt(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// leadingInt parses the digits at the start of s, e.g. 3 for "3-beta"
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
@@ -0,0 +1,91 @@
+package addons
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func installedStub(versions map[string]string) func() (map[string]string, error) {
+	return func() (map[string]string, error) { return versions, nil }
+}
+
+func TestResolveDependencyChain(t *testing.T) {
+	catalog := []Manifest{
+		{ID: "nextcloud", Version: "1.0.0", Dependencies: []AddonDependency{
+			{ID: "php", MinVersion: "8.1"},
+			{ID: "redis", Optional: true},
+		}},
+		{ID: "php", Version: "8.2.0", Dependencies: []AddonDependency{{ID: "webserver"}}},
+		{ID: "webserver", Version: "2.4.0", Dependencies: []AddonDependency{{ID: "ssl", MinVersion: "1.2"}}},
+		{ID: "ssl", Version: "1.3.0"},
+	}
+
+	resolver := NewDependencyResolver(catalog, installedStub(map[string]string{}))
+	plan, err := resolver.Resolve("nextcloud")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if want := []string{"ssl", "webserver", "php", "nextcloud"}; !reflect.DeepEqual(plan.Install, want) {
+		t.Errorf("install order = %v, want %v", plan.Install, want)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "redis") {
+		t.Errorf("warnings = %v", plan.Warnings)
+	}
+
+	// Installed dependencies are not installed again
+	resolver = NewDependencyResolver(catalog, installedStub(map[string]string{"webserver": "2.4.1"}))
+	plan, err = resolver.Resolve("nextcloud")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(plan.Install, []string{"php", "nextcloud"}) ||
+		!reflect.DeepEqual(plan.AlreadyInstalled, []string{"webserver"}) {
+		t.Errorf("plan = %+v", plan)
+	}
+
+	// An installed dependency that is too old cannot be satisfied
+	resolver = NewDependencyResolver(catalog, installedStub(map[string]string{"php": "7.4.33"}))
+	if _, err := resolver.Resolve("nextcloud"); err == nil || !strings.Contains(err.Error(), "older than 8.1") {
+		t.Errorf("Resolve with old php = %v", err)
+	}
+}
+
+func TestResolveCircularDependency(t *testing.T) {
+	catalog := []Manifest{
+		{ID: "a", Version: "1.0", Dependencies: []AddonDependency{{ID: "b"}}},
+		{ID: "b", Version: "1.0", Dependencies: []AddonDependency{{ID: "c"}}},
+		{ID: "c", Version: "1.0", Dependencies: []AddonDependency{{ID: "b"}}},
+	}
+
+	_, err := NewDependencyResolver(catalog, installedStub(map[string]string{})).Resolve("a")
+	if err == nil || err.Error() != "circular addon dependency: b -> c -> b" {
+		t.Errorf("Resolve = %v", err)
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	catalog := []Manifest{
+		{ID: "samba-ad", Version: "1.0", Conflicts: []string{"ldap"}},
+		{ID: "ldap", Version: "1.0"},
+		{ID: "freeipa", Version: "1.0", Conflicts: []string{"samba-ad"}},
+		{ID: "docker", Version: "1.0"},
+	}
+	resolver := NewDependencyResolver(catalog, installedStub(map[string]string{"ldap": "1.0", "freeipa": "1.0"}))
+
+	conflicts, err := resolver.CheckConflicts("samba-ad")
+	if err != nil {
+		t.Fatalf("CheckConflicts: %v", err)
+	}
+	want := []ConflictError{
+		{AddonID: "samba-ad", InstalledID: "ldap", DeclaredBy: "samba-ad"},
+		{AddonID: "samba-ad", InstalledID: "freeipa", DeclaredBy: "freeipa"},
+	}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("conflicts = %+v", conflicts)
+	}
+
+	if conflicts, _ := resolver.CheckConflicts("docker"); len(conflicts) != 0 {
+		t.Errorf("docker conflicts = %+v", conflicts)
+	}
+}
@@ -73,22 +73,62 @@ func (m *Manager) GetAddonStatus(addonID string) (*InstallationStatus, error) {
 	return status, nil
 }
 
-// InstallAddon installs an addon
-func (m *Manager) InstallAddon(addonID string) error {
-	logger.Info("Installing addon", zap.String("addon_id", addonID))
+// Resolver returns a dependency resolver for the builtin addons
+func (m *Manager) Resolver() *DependencyResolver {
+	return NewDependencyResolver(BuiltinAddons, installedAddons)
+}
 
-	// Get addon manifest
-	addon, err := m.GetAddon(addonID)
-	if err != nil {
-		return err
-	}
+// InstallAddon installs an addon after the addons it depends on and returns
+// the resolved installation plan
+func (m *Manager) InstallAddon(addonID string) (*ResolutionPlan, error) {
+	logger.Info("Installing addon", zap.String("addon_id", addonID))
 
 	// Check if already installed
 	status, err := m.GetAddonStatus(addonID)
 	if err == nil && status.Installed && status.PackagesOK {
-		return fmt.Errorf("addon already installed: %s", addonID)
+		return nil, fmt.Errorf("addon already installed: %s", addonID)
 	}
 
+	resolver := m.Resolver()
+	plan, err := resolver.Resolve(addonID)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range plan.Warnings {
+		logger.Warn("Addon dependency warning", zap.String("addon_id", addonID), zap.String("warning", warning))
+	}
+
+	// Refuse the whole plan before installing anything
+	for _, id := range plan.Install {
+		conflicts, err := resolver.CheckConflicts(id)
+		if err != nil {
+			return plan, err
+		}
+		if len(conflicts) > 0 {
+			return plan, conflicts[0]
+		}
+	}
+
+	for _, id := range plan.Install {
+		addon, err := m.GetAddon(id)
+		if err != nil {
+			return plan, err
+		}
+		if err := m.installAddon(addon); err != nil {
+			if id != addonID {
+				return plan, fmt.Errorf("failed to install dependency %s: %w", id, err)
+			}
+			return plan, err
+		}
+	}
+
+	return plan, nil
+}
+
+// installAddon installs the packages and services of a single addon
+func (m *Manager) installAddon(addon *Manifest) error {
+	addonID := addon.ID
+
 	// Create or update installation record
 	var installation models.AddonInstallation
 	result := database.DB.Where("addon_id = ?", addonID).First(&installation)
@@ -15,6 +15,10 @@ type Manifest struct {
 	SystemPackages []string `json:"system_packages"` // apt packages to install
 	Services       []string `json:"services"`        // systemd services to enable
 
+	// Other addons
+	Dependencies []AddonDependency `json:"dependencies,omitempty"` // Addons installed before this one
+	Conflicts    []string          `json:"conflicts,omitempty"`    // IDs of addons that cannot be installed alongside
+
 	// Installation
 	InstallScript   string `json:"install_script"`   // Optional bash script to run on install
 	UninstallScript string `json:"uninstall_script"` // Optional bash script to run on uninstall
@@ -30,6 +34,11 @@ type Manifest struct {
 
 	// Service Management
 	RequiresRestart bool `json:"requires_restart"` // Whether service restart is needed after installation
+
+	// Marketplace distribution (registry addons only)
+	DownloadURL  string `json:"download_url,omitempty"`  // Addon archive
+	SHA256       string `json:"sha256,omitempty"`        // Hex-encoded checksum of the archive
+	SignatureURL string `json:"signature_url,omitempty"` // Detached GPG signature of the archive, if signed
 }
 
 // Installation status
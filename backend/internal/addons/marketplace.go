@@ -0,0 +1,309 @@
+package addons
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DefaultMarketplaceCacheTTL is used when no cache TTL is configured
+const DefaultMarketplaceCacheTTL = time.Hour
+
+// ErrMarketplaceAddonNotFound is returned for addons the registry does not list
+var ErrMarketplaceAddonNotFound = stderrors.New("addon not found in marketplace")
+
+// marketplaceNameRegex matches addon IDs and versions that are safe to use in
+// URLs and file names
+var marketplaceNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// MarketplaceClient fetches addons from a remote registry. The registry serves
+//
+//	{RegistryURL}/index.json                  {"addons": [...]} with the latest version of each addon
+//	{RegistryURL}/addons/{id}/{version}.json  the manifest of a specific version
+//
+// The index is cached in the marketplace_addons table for CacheTTL.
+type MarketplaceClient struct {
+	RegistryURL string
+	CacheTTL    time.Duration
+	Keyring     string // GPG keyring for signatures, "" uses the default keyring
+
+	httpClient      *http.Client
+	verifySignature func(keyring, archive, signature string) error
+	mu              sync.Mutex
+}
+
+// registryIndex is the index.json document of a registry
+type registryIndex struct {
+	Addons []Manifest `json:"addons"`
+}
+
+// NewMarketplaceClient creates a client for the registry at registryURL
+func NewMarketplaceClient(registryURL string, cacheTTL time.Duration, keyring string) *MarketplaceClient {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultMarketplaceCacheTTL
+	}
+	return &MarketplaceClient{
+		RegistryURL:     strings.TrimRight(registryURL, "/"),
+		CacheTTL:        cacheTTL,
+		Keyring:         keyring,
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+		verifySignature: gpgVerify,
+	}
+}
+
+// ListAvailable returns the addons in the registry, optionally limited to a category
+func (c *MarketplaceClient) ListAvailable(category string) ([]Manifest, error) {
+	if err := c.refresh(); err != nil {
+		return nil, err
+	}
+	query := database.DB.Order("name")
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	return cachedManifests(query)
+}
+
+// GetAddonDetails returns the manifest of the latest version of an addon
+func (c *MarketplaceClient) GetAddonDetails(id string) (*Manifest, error) {
+	if err := c.refresh(); err != nil {
+		return nil, err
+	}
+	manifests, err := cachedManifests(database.DB.Where("id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMarketplaceAddonNotFound, id)
+	}
+	return &manifests[0], nil
+}
+
+// SearchAddons returns the addons whose ID, name, description or category contains query
+func (c *MarketplaceClient) SearchAddons(query string) ([]Manifest, error) {
+	if err := c.refresh(); err != nil {
+		return nil, err
+	}
+	pattern := "%" + strings.ToLower(query) + "%"
+	return cachedManifests(database.DB.Order("name").Where(
+		"LOWER(id) LIKE ? OR LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(category) LIKE ?",
+		pattern, pattern, pattern, pattern))
+}
+
+// DownloadAddon downloads the archive of an addon version to destDir as
+// {id}-{version}.tar.gz. An empty version downloads the latest one. The
+// archive is only kept if it matches the SHA256 checksum of its manifest and,
+// if the manifest references one, its GPG signature.
+func (c *MarketplaceClient) DownloadAddon(id, version, destDir string) error {
+	manifest, err := c.GetAddonDetails(id)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != manifest.Version {
+		if !marketplaceNameRegex.MatchString(id) || !marketplaceNameRegex.MatchString(version) {
+			return fmt.Errorf("invalid addon version: %s %s", id, version)
+		}
+		manifest = &Manifest{}
+		if err := c.getJSON(fmt.Sprintf("%s/addons/%s/%s.json", c.RegistryURL, id, version), manifest); err != nil {
+			return err
+		}
+	}
+	if manifest.ID != id || !marketplaceNameRegex.MatchString(manifest.Version) {
+		return fmt.Errorf("registry returned an invalid manifest for %s", id)
+	}
+	if manifest.DownloadURL == "" {
+		return fmt.Errorf("addon %s %s has no download URL", id, manifest.Version)
+	}
+	if manifest.SHA256 == "" {
+		return fmt.Errorf("addon %s %s has no checksum", id, manifest.Version)
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(destDir, ".download-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	err = c.download(manifest.DownloadURL, io.MultiWriter(tmp, hash))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download addon %s: %w", id, err)
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, manifest.SHA256) {
+		return fmt.Errorf("checksum mismatch for addon %s %s: expected %s, got %s", id, manifest.Version, manifest.SHA256, sum)
+	}
+
+	if manifest.SignatureURL != "" {
+		sig, err := os.CreateTemp(destDir, ".signature-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp file: %w", err)
+		}
+		defer os.Remove(sig.Name())
+		err = c.download(manifest.SignatureURL, sig)
+		sig.Close()
+		if err != nil {
+			return fmt.Errorf("failed to download signature of addon %s: %w", id, err)
+		}
+		if err := c.verifySignature(c.Keyring, tmp.Name(), sig.Name()); err != nil {
+			return fmt.Errorf("signature verification failed for addon %s %s: %w", id, manifest.Version, err)
+		}
+	}
+
+	dest := filepath.Join(destDir, fmt.Sprintf("%s-%s.tar.gz", id, manifest.Version))
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to save addon archive: %w", err)
+	}
+
+	logger.Info("Addon downloaded from marketplace",
+		zap.String("addon_id", id),
+		zap.String("version", manifest.Version),
+		zap.String("path", dest))
+	return nil
+}
+
+// refresh fetches the registry index if the cached one is older than the TTL.
+// A stale cache is kept when the registry cannot be reached.
+func (c *MarketplaceClient) refresh() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var oldest models.MarketplaceAddon
+	err := database.DB.Order("fetched_at").First(&oldest).Error
+	haveCache := err == nil
+	if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to read marketplace cache: %w", err)
+	}
+	if haveCache && time.Since(oldest.FetchedAt) < c.CacheTTL {
+		return nil
+	}
+
+	var index registryIndex
+	if err := c.getJSON(c.RegistryURL+"/index.json", &index); err != nil {
+		if haveCache {
+			logger.Warn("Failed to refresh addon marketplace, using cached index", zap.Error(err))
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.MarketplaceAddon{}).Error; err != nil {
+			return fmt.Errorf("failed to clear marketplace cache: %w", err)
+		}
+		for _, manifest := range index.Addons {
+			if !marketplaceNameRegex.MatchString(manifest.ID) {
+				logger.Warn("Skipping marketplace addon with invalid ID", zap.String("addon_id", manifest.ID))
+				continue
+			}
+			data, err := json.Marshal(manifest)
+			if err != nil {
+				return err
+			}
+			addon := models.MarketplaceAddon{
+				ID:          manifest.ID,
+				Name:        manifest.Name,
+				Category:    manifest.Category,
+				Version:     manifest.Version,
+				Description: manifest.Description,
+				Author:      manifest.Author,
+				Manifest:    string(data),
+				FetchedAt:   now,
+			}
+			if err := tx.Save(&addon).Error; err != nil {
+				return fmt.Errorf("failed to cache marketplace addon %s: %w", manifest.ID, err)
+			}
+		}
+		logger.Info("Addon marketplace index refreshed", zap.Int("addons", len(index.Addons)))
+		return nil
+	})
+}
+
+// cachedManifests decodes the manifests of the cached addons selected by query
+func cachedManifests(query *gorm.DB) ([]Manifest, error) {
+	var addons []models.MarketplaceAddon
+	if err := query.Find(&addons).Error; err != nil {
+		return nil, fmt.Errorf("failed to read marketplace cache: %w", err)
+	}
+	manifests := make([]Manifest, 0, len(addons))
+	for _, addon := range addons {
+		var manifest Manifest
+		if err := json.Unmarshal([]byte(addon.Manifest), &manifest); err != nil {
+			return nil, fmt.Errorf("invalid cached manifest of %s: %w", addon.ID, err)
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// getJSON fetches a registry document and decodes it into v
+func (c *MarketplaceClient) getJSON(url string, v interface{}) error {
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrMarketplaceAddonNotFound, url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry returned status %d for %s", resp.StatusCode, url)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", url, err)
+	}
+	return nil
+}
+
+// download writes the body of url to w
+func (c *MarketplaceClient) download(url string, w io.Writer) error {
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// gpgVerify checks a detached GPG signature of archive
+func gpgVerify(keyring, archive, signature string) error {
+	args := []string{"--batch"}
+	if keyring != "" {
+		args = append(args, "--no-default-keyring", "--keyring", keyring)
+	}
+	args = append(args, "--verify", signature, archive)
+	output, err := exec.Command("gpg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
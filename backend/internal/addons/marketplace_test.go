@@ -0,0 +1,169 @@
+package addons
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var testArchive = []byte("addon archive contents")
+
+// newTestRegistry serves a registry with two addons; the archive of
+// "jellyfin" 10.9.0 does not match its checksum
+func newTestRegistry(t *testing.T, indexHits *int32) *httptest.Server {
+	t.Helper()
+	sum := sha256.Sum256(testArchive)
+	checksum := hex.EncodeToString(sum[:])
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.json":
+			atomic.AddInt32(indexHits, 1)
+			json.NewEncoder(w).Encode(registryIndex{Addons: []Manifest{
+				{ID: "jellyfin", Name: "Jellyfin", Category: "media", Version: "10.9.0",
+					Description: "Media server", DownloadURL: srv.URL + "/tampered.tar.gz", SHA256: checksum},
+				{ID: "nextcloud", Name: "Nextcloud", Category: "productivity", Version: "29.0.1",
+					Description: "File sync and share", DownloadURL: srv.URL + "/archive.tar.gz", SHA256: checksum},
+			}})
+		case "/addons/jellyfin/10.8.13.json":
+			json.NewEncoder(w).Encode(Manifest{ID: "jellyfin", Version: "10.8.13",
+				DownloadURL: srv.URL + "/archive.tar.gz", SHA256: strings.ToUpper(checksum)})
+		case "/archive.tar.gz":
+			w.Write(testArchive)
+		case "/tampered.tar.gz":
+			w.Write(append([]byte("injected "), testArchive...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestMarketplace(t *testing.T) (*MarketplaceClient, *int32) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "addons.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.MarketplaceAddon{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { database.DB = nil })
+
+	indexHits := new(int32)
+	srv := newTestRegistry(t, indexHits)
+	return NewMarketplaceClient(srv.URL+"/", time.Hour, ""), indexHits
+}
+
+func TestMarketplaceListAndCache(t *testing.T) {
+	client, indexHits := newTestMarketplace(t)
+
+	all, err := client.ListAvailable("")
+	if err != nil || len(all) != 2 {
+		t.Fatalf("ListAvailable = %v, %v", all, err)
+	}
+	media, err := client.ListAvailable("media")
+	if err != nil || len(media) != 1 || media[0].ID != "jellyfin" {
+		t.Errorf("ListAvailable(media) = %v, %v", media, err)
+	}
+	found, err := client.SearchAddons("SYNC")
+	if err != nil || len(found) != 1 || found[0].ID != "nextcloud" {
+		t.Errorf("SearchAddons = %v, %v", found, err)
+	}
+	if _, err := client.GetAddonDetails("plex"); !stderrors.Is(err, ErrMarketplaceAddonNotFound) {
+		t.Errorf("GetAddonDetails(plex) = %v", err)
+	}
+	if hits := atomic.LoadInt32(indexHits); hits != 1 {
+		t.Errorf("index fetched %d times within the TTL", hits)
+	}
+
+	// An expired cache is refreshed
+	database.DB.Model(&models.MarketplaceAddon{}).Where("1 = 1").Update("fetched_at", time.Now().Add(-2*time.Hour))
+	if _, err := client.GetAddonDetails("nextcloud"); err != nil {
+		t.Fatalf("GetAddonDetails: %v", err)
+	}
+	if hits := atomic.LoadInt32(indexHits); hits != 2 {
+		t.Errorf("index fetched %d times after the TTL expired", hits)
+	}
+}
+
+func TestMarketplaceDownloadVerifiesChecksum(t *testing.T) {
+	client, _ := newTestMarketplace(t)
+	dest := t.TempDir()
+
+	if err := client.DownloadAddon("nextcloud", "", dest); err != nil {
+		t.Fatalf("DownloadAddon: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "nextcloud-29.0.1.tar.gz"))
+	if err != nil || string(data) != string(testArchive) {
+		t.Errorf("archive = %q, %v", data, err)
+	}
+
+	err = client.DownloadAddon("jellyfin", "", dest)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("DownloadAddon of tampered archive = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "jellyfin-10.9.0.tar.gz")); !os.IsNotExist(err) {
+		t.Error("tampered archive was kept")
+	}
+
+	// Older versions are looked up in the registry
+	if err := client.DownloadAddon("jellyfin", "10.8.13", dest); err != nil {
+		t.Errorf("DownloadAddon(10.8.13): %v", err)
+	}
+
+	entries, _ := os.ReadDir(dest)
+	if len(entries) != 2 {
+		t.Errorf("download directory has %d entries, want 2", len(entries))
+	}
+}
+
+func TestMarketplaceDownloadVerifiesSignature(t *testing.T) {
+	client, _ := newTestMarketplace(t)
+	client.verifySignature = func(keyring, archive, signature string) error {
+		return stderrors.New("BAD signature")
+	}
+
+	sum := sha256.Sum256(testArchive)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.json":
+			json.NewEncoder(w).Encode(registryIndex{Addons: []Manifest{{ID: "signed", Version: "1.0.0",
+				DownloadURL: "http://" + r.Host + "/a.tar.gz", SignatureURL: "http://" + r.Host + "/a.tar.gz.sig",
+				SHA256: hex.EncodeToString(sum[:])}}})
+		case "/a.tar.gz":
+			w.Write(testArchive)
+		default:
+			w.Write([]byte("signature"))
+		}
+	}))
+	defer srv.Close()
+	client.RegistryURL = srv.URL
+
+	if err := client.DownloadAddon("signed", "", t.TempDir()); err == nil || !strings.Contains(err.Error(), "BAD signature") {
+		t.Errorf("DownloadAddon with bad signature = %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// summaryCheckInterval is how often ended maintenance windows are checked
+// for pending digests
+const summaryCheckInterval = time.Minute
+
+var (
+	// ErrMaintenanceWindowNotFound is returned for unknown maintenance windows
+	ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+
+	// ErrMaintenanceWindowActive is returned when a digest is requested before the window ended
+	ErrMaintenanceWindowActive = errors.New("maintenance window has not ended")
+)
+
+// MaintenanceWindow mutes alerts of the matching types, or all alerts, between Start and End
+type MaintenanceWindow struct {
+	Name               string    `json:"name"`
+	Reason             string    `json:"reason"`
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	MatchingAlertTypes []string  `json:"matchingAlertTypes"`
+	AllAlerts          bool      `json:"allAlerts"`
+}
+
+// Validate checks that the window has a name, ends after it starts and mutes something
+func (w *MaintenanceWindow) Validate() error {
+	if strings.TrimSpace(w.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("end must be after start")
+	}
+	if !w.AllAlerts && len(w.MatchingAlertTypes) == 0 {
+		return fmt.Errorf("set allAlerts or at least one matching alert type")
+	}
+	return nil
+}
+
+// AddMaintenanceWindow stores a maintenance window and returns its ID
+func (s *Service) AddMaintenanceWindow(window MaintenanceWindow) (string, error) {
+	if err := window.Validate(); err != nil {
+		return "", err
+	}
+
+	record := &models.MaintenanceWindow{}
+	applyWindow(record, &window)
+	if err := s.db.Create(record).Error; err != nil {
+		return "", fmt.Errorf("failed to save maintenance window: %w", err)
+	}
+
+	logger.Info("Maintenance window added",
+		zap.Uint("id", record.ID),
+		zap.String("name", record.Name),
+		zap.Time("start", record.Start),
+		zap.Time("end", record.End))
+	return strconv.FormatUint(uint64(record.ID), 10), nil
+}
+
+// UpdateMaintenanceWindow changes a maintenance window. Moving the end of an
+// ended window allows its digest to be sent again.
+func (s *Service) UpdateMaintenanceWindow(id string, window MaintenanceWindow) (*models.MaintenanceWindow, error) {
+	if err := window.Validate(); err != nil {
+		return nil, err
+	}
+
+	record, err := s.getMaintenanceWindow(id)
+	if err != nil {
+		return nil, err
+	}
+	if !record.End.Equal(window.End) {
+		record.SummarySentAt = nil
+	}
+	applyWindow(record, &window)
+	if err := s.db.Save(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save maintenance window: %w", err)
+	}
+	return record, nil
+}
+
+// DeleteMaintenanceWindow removes a maintenance window and its suppressed alerts
+func (s *Service) DeleteMaintenanceWindow(id string) error {
+	record, err := s.getMaintenanceWindow(id)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("window_id = ?", record.ID).Delete(&models.SuppressedAlert{}).Error; err != nil {
+			return fmt.Errorf("failed to delete suppressed alerts: %w", err)
+		}
+		if err := tx.Delete(record).Error; err != nil {
+			return fmt.Errorf("failed to delete maintenance window: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListMaintenanceWindows returns all maintenance windows, the latest first
+func (s *Service) ListMaintenanceWindows() ([]models.MaintenanceWindow, error) {
+	windows := []models.MaintenanceWindow{}
+	if err := s.db.Order("start DESC").Find(&windows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list maintenance windows: %w", err)
+	}
+	return windows, nil
+}
+
+// GetSuppressedAlerts returns the alerts muted by a maintenance window
+func (s *Service) GetSuppressedAlerts(windowID string) ([]models.SuppressedAlert, error) {
+	record, err := s.getMaintenanceWindow(windowID)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []models.SuppressedAlert{}
+	if err := s.db.Where("window_id = ?", record.ID).Order("created_at").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list suppressed alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+// SendSuppressedAlertSummary emails a digest of the alerts muted by an ended
+// maintenance window to the alert recipient
+func (s *Service) SendSuppressedAlertSummary(windowID string) error {
+	record, err := s.getMaintenanceWindow(windowID)
+	if err != nil {
+		return err
+	}
+	if time.Now().Before(record.End) {
+		return ErrMaintenanceWindowActive
+	}
+
+	ctx := context.Background()
+	config, err := s.getEffectiveConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if !config.Enabled || config.AlertRecipient == "" {
+		return fmt.Errorf("email alerts are not enabled")
+	}
+
+	suppressed, err := s.GetSuppressedAlerts(windowID)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("Maintenance Summary - %s (%d suppressed alerts)", record.Name, len(suppressed))
+	var items strings.Builder
+	for _, alert := range suppressed {
+		fmt.Fprintf(&items, "<li><strong>%s</strong> %s: %s</li>\n",
+			alert.CreatedAt.Format("2006-01-02 15:04:05"), html.EscapeString(alert.AlertType), html.EscapeString(alert.Subject))
+	}
+	if len(suppressed) == 0 {
+		items.WriteString("<li>No alerts were raised during the window.</li>\n")
+	}
+	body := fmt.Sprintf(`
+<html>
+<body>
+<h2>Maintenance Window Summary</h2>
+<p><strong>%s</strong> ran from %s to %s.</p>
+<p>%s</p>
+<p>The following alerts were suppressed:</p>
+<ul>
+%s</ul>
+</body>
+</html>
+`, html.EscapeString(record.Name), record.Start.Format("2006-01-02 15:04:05"), record.End.Format("2006-01-02 15:04:05"),
+		html.EscapeString(record.Reason), items.String())
+
+	if err := s.sendEmail(ctx, config, subject, body, models.AlertTypeMaintenance); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.db.Model(record).Update("summary_sent_at", &now).Error
+}
+
+// StartMaintenanceSummaries emails the digest of each maintenance window
+// that suppressed alerts once it ends, until ctx is cancelled
+func (s *Service) StartMaintenanceSummaries(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(summaryCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sendPendingSummaries()
+			}
+		}
+	}()
+}
+
+// sendPendingSummaries sends the digests of ended windows that suppressed alerts
+func (s *Service) sendPendingSummaries() {
+	var windows []models.MaintenanceWindow
+	err := s.db.Where("summary_sent_at IS NULL AND \"end\" <= ?", time.Now()).
+		Where("EXISTS (SELECT 1 FROM suppressed_alerts WHERE suppressed_alerts.window_id = maintenance_windows.id)").
+		Find(&windows).Error
+	if err != nil {
+		logger.Warn("Failed to find ended maintenance windows", zap.Error(err))
+		return
+	}
+
+	for _, window := range windows {
+		if err := s.SendSuppressedAlertSummary(strconv.FormatUint(uint64(window.ID), 10)); err != nil {
+			logger.Warn("Failed to send maintenance summary", zap.Uint("window", window.ID), zap.Error(err))
+		}
+	}
+}
+
+// suppress records an alert muted by an active maintenance window. Returns
+// false if no window matches.
+func (s *Service) suppress(ctx context.Context, alertType, subject, textBody string) bool {
+	now := time.Now()
+	var windows []models.MaintenanceWindow
+	if err := s.db.WithContext(ctx).Where("start <= ? AND \"end\" > ?", now, now).Find(&windows).Error; err != nil {
+		logger.Warn("Failed to check maintenance windows", zap.Error(err))
+		return false
+	}
+
+	for _, window := range windows {
+		if !window.Matches(alertType, now) {
+			continue
+		}
+
+		suppressed := &models.SuppressedAlert{
+			WindowID:  window.ID,
+			AlertType: alertType,
+			Subject:   subject,
+			Body:      textBody,
+		}
+		if err := s.db.WithContext(ctx).Create(suppressed).Error; err != nil {
+			logger.Warn("Failed to record suppressed alert", zap.Error(err))
+		}
+		logger.Info("Alert suppressed by maintenance window",
+			zap.String("type", alertType),
+			zap.Uint("window", window.ID))
+		return true
+	}
+	return false
+}
+
+func (s *Service) getMaintenanceWindow(id string) (*models.MaintenanceWindow, error) {
+	var record models.MaintenanceWindow
+	if err := s.db.First(&record, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMaintenanceWindowNotFound
+		}
+		return nil, fmt.Errorf("failed to get maintenance window: %w", err)
+	}
+	return &record, nil
+}
+
+func applyWindow(record *models.MaintenanceWindow, window *MaintenanceWindow) {
+	record.Name = window.Name
+	record.Reason = window.Reason
+	record.Start = window.Start
+	record.End = window.End
+	record.MatchingAlertTypes = window.MatchingAlertTypes
+	if record.MatchingAlertTypes == nil {
+		record.MatchingAlertTypes = []string{}
+	}
+	record.AllAlerts = window.AllAlerts
+}
@@ -0,0 +1,86 @@
+package alerts
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "alerts.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AlertLog{}, &models.MaintenanceWindow{}, &models.SuppressedAlert{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return &Service{db: db, lastAlertTimes: make(map[string]time.Time)}
+}
+
+func TestMaintenanceWindowSuppressesMatchingAlerts(t *testing.T) {
+	s := newTestService(t)
+	now := time.Now()
+
+	id, err := s.AddMaintenanceWindow(MaintenanceWindow{
+		Name:               "Disk replacement",
+		Reason:             "Swapping sdb",
+		Start:              now.Add(-time.Minute),
+		End:                now.Add(time.Hour),
+		MatchingAlertTypes: []string{models.AlertTypeSMARTTest},
+	})
+	if err != nil {
+		t.Fatalf("AddMaintenanceWindow: %v", err)
+	}
+
+	// Nothing listens on port 1, so delivery fails but is still logged
+	config := &models.AlertConfig{Enabled: true, SMTPHost: "127.0.0.1", SMTPPort: 1, AlertRecipient: "admin@example.com"}
+	ctx := context.Background()
+	if err := s.sendAlert(ctx, config, "SMART test failed", "<p>sdb</p>", "sdb", models.AlertTypeSMARTTest); err != nil {
+		t.Fatalf("suppressed sendAlert returned %v", err)
+	}
+	s.sendAlert(ctx, config, "IP blocked", "<p>10.0.0.1</p>", "10.0.0.1", models.AlertTypeIPBlock)
+
+	suppressed, err := s.GetSuppressedAlerts(id)
+	if err != nil {
+		t.Fatalf("GetSuppressedAlerts: %v", err)
+	}
+	if len(suppressed) != 1 || suppressed[0].AlertType != models.AlertTypeSMARTTest || suppressed[0].Body != "sdb" {
+		t.Errorf("suppressed alerts = %+v, want the SMART test alert", suppressed)
+	}
+
+	var logs []models.AlertLog
+	s.db.Find(&logs)
+	if len(logs) != 1 || logs[0].AlertType != models.AlertTypeIPBlock {
+		t.Errorf("alert logs = %+v, want only the IP block alert", logs)
+	}
+
+	if err := s.SendSuppressedAlertSummary(id); err != ErrMaintenanceWindowActive {
+		t.Errorf("SendSuppressedAlertSummary during the window = %v, want %v", err, ErrMaintenanceWindowActive)
+	}
+}
+
+func TestMaintenanceWindowValidate(t *testing.T) {
+	now := time.Now()
+	tests := []MaintenanceWindow{
+		{Start: now, End: now.Add(time.Hour), AllAlerts: true},
+		{Name: "backwards", Start: now, End: now.Add(-time.Hour), AllAlerts: true},
+		{Name: "empty", Start: now, End: now.Add(time.Hour)},
+	}
+	for _, w := range tests {
+		if err := w.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", w)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// notificationSource is the Source of the notifications the alert service
+// publishes for its own alerts
+const notificationSource = "alerts"
+
+// HandleNotification delivers a notification published by another service
+// by email and webhook, subject to maintenance windows and the alert rate
+// limit of its type. It is subscribed to all notification types.
+func (s *Service) HandleNotification(n notifications.Notification) {
+	// The alert service's own alerts were already delivered
+	if n.Source == notificationSource {
+		return
+	}
+
+	ctx := context.Background()
+	config, err := s.getEffectiveConfig(ctx)
+	if err != nil {
+		logger.Warn("Failed to load alert config for notification", zap.String("type", n.Type), zap.Error(err))
+		return
+	}
+	if !config.Enabled && !config.WebhookEnabled {
+		return
+	}
+	if !s.shouldSendAlert(n.Type, config.RateLimitMinutes) {
+		return
+	}
+
+	subject := n.Title
+	textBody := notificationText(n)
+	if s.suppress(ctx, n.Type, subject, textBody) {
+		return
+	}
+	s.publishAlertEvent(n.Type, n.Severity, subject)
+
+	if err := s.deliverAlert(ctx, config, subject, notificationHTML(n), textBody, n.Type); err != nil {
+		logger.Warn("Failed to deliver notification", zap.String("type", n.Type), zap.Error(err))
+	}
+}
+
+// notificationText renders a notification for webhooks and digests
+func notificationText(n notifications.Notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n\n", n.Title)
+	if n.Body != "" {
+		fmt.Fprintf(&b, "%s\n", n.Body)
+	}
+	fmt.Fprintf(&b, "Source: %s\nSeverity: %s\nTime: %s", n.Source, n.Severity, n.OccurredAt.Format("2006-01-02 15:04:05"))
+	for _, key := range sortedMetadataKeys(n.Metadata) {
+		fmt.Fprintf(&b, "\n%s: %v", key, n.Metadata[key])
+	}
+	return b.String()
+}
+
+// notificationHTML renders a notification for email
+func notificationHTML(n notifications.Notification) string {
+	var rows strings.Builder
+	for _, key := range sortedMetadataKeys(n.Metadata) {
+		fmt.Fprintf(&rows, "<li><strong>%s:</strong> %s</li>\n",
+			html.EscapeString(key), html.EscapeString(fmt.Sprint(n.Metadata[key])))
+	}
+	return fmt.Sprintf(`
+<html>
+<body>
+<h2>%s</h2>
+<p>%s</p>
+<ul>
+<li><strong>Source:</strong> %s</li>
+<li><strong>Severity:</strong> %s</li>
+<li><strong>Time:</strong> %s</li>
+%s</ul>
+</body>
+</html>
+`, html.EscapeString(n.Title), html.EscapeString(n.Body), html.EscapeString(n.Source),
+		html.EscapeString(n.Severity), n.OccurredAt.Format("2006-01-02 15:04:05"), rows.String())
+}
+
+func sortedMetadataKeys(metadata map[string]interface{}) []string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
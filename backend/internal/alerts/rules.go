@@ -0,0 +1,335 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Operators combining the conditions of an alert rule
+const (
+	OperatorAnd = "and"
+	OperatorOr  = "or"
+)
+
+var (
+	// ErrAlertRuleNotFound is returned for unknown alert rules
+	ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+	// ErrAlertRuleExists is returned when an alert rule name is taken
+	ErrAlertRuleExists = errors.New("alert rule already exists")
+)
+
+// ruleMetrics maps the JSON names of the numeric SystemMetric fields to
+// their field index
+var ruleMetrics = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(models.SystemMetric{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		switch field.Type.Kind() {
+		case reflect.Float64, reflect.Int, reflect.Uint64:
+			if field.Name == "ID" {
+				continue
+			}
+			fields[strings.Split(field.Tag.Get("json"), ",")[0]] = i
+		}
+	}
+	return fields
+}()
+
+// AlertRule is a named expression over system metrics
+type AlertRule struct {
+	Name       string                     `json:"name"`
+	Enabled    bool                       `json:"enabled"`
+	Expression models.AlertRuleExpression `json:"expression"`
+}
+
+// Validate checks the rule name, operator and conditions
+func (r *AlertRule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	return validateExpression(&r.Expression)
+}
+
+func validateExpression(expr *models.AlertRuleExpression) error {
+	if expr.Operator != OperatorAnd && expr.Operator != OperatorOr {
+		return fmt.Errorf("invalid operator %q (expected and or or)", expr.Operator)
+	}
+	if len(expr.Conditions) == 0 {
+		return fmt.Errorf("at least one condition is required")
+	}
+	for i, cond := range expr.Conditions {
+		if _, ok := ruleMetrics[cond.MetricName]; !ok {
+			return fmt.Errorf("condition %d: unknown metric %q", i+1, cond.MetricName)
+		}
+		if _, err := compare(0, cond.Comparator, cond.Threshold); err != nil {
+			return fmt.Errorf("condition %d: %w", i+1, err)
+		}
+		if cond.Window < 0 {
+			return fmt.Errorf("condition %d: window must not be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// ruleState tracks since when each condition of a rule has been true
+type ruleState struct {
+	since  []time.Time // Zero while the condition is false or was not evaluated
+	firing bool
+}
+
+// EvaluateAlertRules evaluates the enabled alert rules against a collected
+// sample and alerts on each rule whose expression starts to hold
+func (s *Service) EvaluateAlertRules(ctx context.Context, sample *models.SystemMetric) {
+	var rules []models.AlertRule
+	if err := s.db.WithContext(ctx).Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		logger.Warn("Failed to load alert rules", zap.Error(err))
+		return
+	}
+
+	value := func(name string) (float64, error) {
+		return metricValue(sample, name)
+	}
+
+	var firing []models.AlertRule
+	s.mu.Lock()
+	states := make(map[uint]*ruleState, len(rules))
+	for _, rule := range rules {
+		state := s.ruleStates[rule.ID]
+		if state == nil || len(state.since) != len(rule.Expression.Conditions) {
+			state = &ruleState{since: make([]time.Time, len(rule.Expression.Conditions))}
+		}
+		states[rule.ID] = state
+
+		held, err := evaluateExpression(&rule.Expression, state.since, value, sample.Timestamp)
+		if err != nil {
+			logger.Warn("Failed to evaluate alert rule", zap.String("rule", rule.Name), zap.Error(err))
+			continue
+		}
+		if held && !state.firing {
+			firing = append(firing, rule)
+		}
+		state.firing = held
+	}
+	s.ruleStates = states
+	s.mu.Unlock()
+
+	for _, rule := range firing {
+		if err := s.sendRuleAlert(ctx, &rule, sample); err != nil {
+			logger.Warn("Failed to send alert rule alert", zap.String("rule", rule.Name), zap.Error(err))
+		}
+		s.db.WithContext(ctx).Model(&rule).Update("last_fired_at", sample.Timestamp)
+	}
+}
+
+// evaluateExpression evaluates the conditions of expr in order. "and" stops
+// at the first condition whose comparison is false, "or" at the first that
+// holds for its window. The windows of conditions that are not evaluated
+// restart, so they only count time in which they were observed to be true.
+func evaluateExpression(expr *models.AlertRuleExpression, since []time.Time, value func(string) (float64, error), now time.Time) (bool, error) {
+	allHeld := true
+	for i := range expr.Conditions {
+		held, matching, err := evaluateCondition(&expr.Conditions[i], &since[i], value, now)
+		if err != nil {
+			return false, err
+		}
+		if (expr.Operator == OperatorAnd && !matching) || (expr.Operator == OperatorOr && held) {
+			for j := i + 1; j < len(since); j++ {
+				since[j] = time.Time{}
+			}
+			return held, nil
+		}
+		allHeld = allHeld && held
+	}
+	return expr.Operator == OperatorAnd && allHeld, nil
+}
+
+// evaluateCondition reports whether cond has held for its window and
+// whether its comparison is currently true, updating since to when it
+// became true
+func evaluateCondition(cond *models.AlertCondition, since *time.Time, value func(string) (float64, error), now time.Time) (held, matching bool, err error) {
+	v, err := value(cond.MetricName)
+	if err != nil {
+		return false, false, err
+	}
+	matching, err = compare(v, cond.Comparator, cond.Threshold)
+	if err != nil {
+		return false, false, err
+	}
+	if !matching {
+		*since = time.Time{}
+		return false, false, nil
+	}
+	if since.IsZero() {
+		*since = now
+	}
+	return now.Sub(*since) >= cond.Window, true, nil
+}
+
+func compare(v float64, comparator string, threshold float64) (bool, error) {
+	switch comparator {
+	case ">":
+		return v > threshold, nil
+	case ">=":
+		return v >= threshold, nil
+	case "<":
+		return v < threshold, nil
+	case "<=":
+		return v <= threshold, nil
+	case "==":
+		return v == threshold, nil
+	case "!=":
+		return v != threshold, nil
+	default:
+		return false, fmt.Errorf("invalid comparator %q", comparator)
+	}
+}
+
+func metricValue(sample *models.SystemMetric, name string) (float64, error) {
+	index, ok := ruleMetrics[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown metric %q", name)
+	}
+	field := reflect.ValueOf(sample).Elem().Field(index)
+	switch field.Kind() {
+	case reflect.Float64:
+		return field.Float(), nil
+	case reflect.Int:
+		return float64(field.Int()), nil
+	default:
+		return float64(field.Uint()), nil
+	}
+}
+
+// sendRuleAlert reports that the expression of rule holds
+func (s *Service) sendRuleAlert(ctx context.Context, rule *models.AlertRule, sample *models.SystemMetric) error {
+	config, err := s.getEffectiveConfig(ctx)
+	if err != nil || !config.Enabled {
+		return nil
+	}
+
+	var items, lines []string
+	for _, cond := range rule.Expression.Conditions {
+		current, _ := metricValue(sample, cond.MetricName)
+		line := fmt.Sprintf("%s %s %g for %s (now %g)", cond.MetricName, cond.Comparator, cond.Threshold, cond.Window, current)
+		items = append(items, "<li>"+html.EscapeString(line)+"</li>")
+		lines = append(lines, "- "+line)
+	}
+	joiner := strings.ToUpper(rule.Expression.Operator)
+
+	subject := fmt.Sprintf("Alert Rule Triggered - %s", rule.Name)
+	htmlBody := fmt.Sprintf(`
+<html>
+<body>
+<h2>Alert Rule Triggered</h2>
+<p><strong>%s</strong> matched at %s.</p>
+<p>Conditions (%s):</p>
+<ul>
+%s
+</ul>
+</body>
+</html>
+`, html.EscapeString(rule.Name), sample.Timestamp.Format("2006-01-02 15:04:05"), joiner, strings.Join(items, "\n"))
+
+	textBody := fmt.Sprintf("**Alert Rule Triggered**\n\nRule: %s\nTime: %s\nConditions (%s):\n%s",
+		rule.Name, sample.Timestamp.Format("2006-01-02 15:04:05"), joiner, strings.Join(lines, "\n"))
+
+	return s.sendAlert(ctx, config, subject, htmlBody, textBody, models.AlertTypeMetricRule)
+}
+
+// ListAlertRules returns all alert rules ordered by name
+func (s *Service) ListAlertRules() ([]models.AlertRule, error) {
+	rules := []models.AlertRule{}
+	if err := s.db.Order("name").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateAlertRule validates and stores an alert rule
+func (s *Service) CreateAlertRule(rule AlertRule) (*models.AlertRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	s.db.Model(&models.AlertRule{}).Where("name = ?", rule.Name).Count(&count)
+	if count > 0 {
+		return nil, ErrAlertRuleExists
+	}
+
+	record := &models.AlertRule{Name: rule.Name, Enabled: rule.Enabled, Expression: rule.Expression}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save alert rule: %w", err)
+	}
+	return record, nil
+}
+
+// UpdateAlertRule replaces an alert rule. Its condition windows restart.
+func (s *Service) UpdateAlertRule(id string, rule AlertRule) (*models.AlertRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	record, err := s.getAlertRule(id)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Name != record.Name {
+		var count int64
+		s.db.Model(&models.AlertRule{}).Where("name = ?", rule.Name).Count(&count)
+		if count > 0 {
+			return nil, ErrAlertRuleExists
+		}
+	}
+
+	record.Name = rule.Name
+	record.Enabled = rule.Enabled
+	record.Expression = rule.Expression
+	if err := s.db.Save(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save alert rule: %w", err)
+	}
+
+	s.mu.Lock()
+	delete(s.ruleStates, record.ID)
+	s.mu.Unlock()
+	return record, nil
+}
+
+// DeleteAlertRule removes an alert rule
+func (s *Service) DeleteAlertRule(id string) error {
+	record, err := s.getAlertRule(id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(record).Error; err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+
+	s.mu.Lock()
+	delete(s.ruleStates, record.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Service) getAlertRule(id string) (*models.AlertRule, error) {
+	var record models.AlertRule
+	if err := s.db.First(&record, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlertRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+	return &record, nil
+}
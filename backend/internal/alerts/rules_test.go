@@ -0,0 +1,104 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+)
+
+func TestEvaluateExpressionShortCircuits(t *testing.T) {
+	values := map[string]float64{"cpuUsage": 50, "memoryUsage": 95}
+	tests := []struct {
+		operator  string
+		want      bool
+		evaluated []string
+	}{
+		// cpuUsage > 90 is false, so "and" never looks at memoryUsage
+		{OperatorAnd, false, []string{"cpuUsage"}},
+		// memoryUsage > 85 is true, so "or" never looks at swapUsage
+		{OperatorOr, true, []string{"cpuUsage", "memoryUsage"}},
+	}
+	for _, tt := range tests {
+		conditions := []models.AlertCondition{
+			{MetricName: "cpuUsage", Comparator: ">", Threshold: 90},
+			{MetricName: "memoryUsage", Comparator: ">", Threshold: 85},
+			{MetricName: "swapUsage", Comparator: ">", Threshold: 50},
+		}
+		expr := &models.AlertRuleExpression{Operator: tt.operator, Conditions: conditions}
+
+		var evaluated []string
+		value := func(name string) (float64, error) {
+			evaluated = append(evaluated, name)
+			return values[name], nil
+		}
+		got, err := evaluateExpression(expr, make([]time.Time, len(conditions)), value, time.Now())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.operator, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.operator, got, tt.want)
+		}
+		if len(evaluated) != len(tt.evaluated) {
+			t.Errorf("%s evaluated %v, want %v", tt.operator, evaluated, tt.evaluated)
+		}
+	}
+}
+
+func TestEvaluateExpressionWindow(t *testing.T) {
+	expr := &models.AlertRuleExpression{
+		Operator: OperatorAnd,
+		Conditions: []models.AlertCondition{
+			{MetricName: "cpuUsage", Comparator: ">", Threshold: 90, Window: 5 * time.Minute},
+			{MetricName: "memoryUsage", Comparator: ">", Threshold: 85, Window: 5 * time.Minute},
+		},
+	}
+	since := make([]time.Time, 2)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		minute int
+		cpu    float64
+		want   bool
+	}{
+		{0, 95, false},
+		{4, 95, false},
+		{5, 95, true},
+		// A dip resets the window
+		{6, 50, false},
+		{7, 95, false},
+		{11, 95, false},
+		{12, 95, true},
+	}
+	for _, step := range steps {
+		value := func(name string) (float64, error) {
+			if name == "cpuUsage" {
+				return step.cpu, nil
+			}
+			return 90, nil
+		}
+		got, err := evaluateExpression(expr, since, value, start.Add(time.Duration(step.minute)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != step.want {
+			t.Errorf("minute %d: got %v, want %v", step.minute, got, step.want)
+		}
+	}
+}
+
+func TestAlertRuleValidate(t *testing.T) {
+	valid := models.AlertCondition{MetricName: "cpuUsage", Comparator: ">", Threshold: 90}
+	tests := []AlertRule{
+		{Expression: models.AlertRuleExpression{Operator: OperatorAnd, Conditions: []models.AlertCondition{valid}}},
+		{Name: "op", Expression: models.AlertRuleExpression{Operator: "xor", Conditions: []models.AlertCondition{valid}}},
+		{Name: "empty", Expression: models.AlertRuleExpression{Operator: OperatorOr}},
+		{Name: "metric", Expression: models.AlertRuleExpression{Operator: OperatorOr, Conditions: []models.AlertCondition{{MetricName: "cpu", Comparator: ">"}}}},
+		{Name: "comparator", Expression: models.AlertRuleExpression{Operator: OperatorOr, Conditions: []models.AlertCondition{{MetricName: "cpuUsage", Comparator: "=>"}}}},
+	}
+	for _, rule := range tests {
+		if err := rule.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", rule)
+		}
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"html"
 	"net/smtp"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -21,6 +26,8 @@ type Service struct {
 	db              *gorm.DB
 	mu              sync.RWMutex
 	lastAlertTimes  map[string]time.Time // Rate limiting by alert type
+	overrides       config.AlertsConfig  // Threshold overrides from config.yaml (hot-reloadable)
+	ruleStates      map[uint]*ruleState  // Condition windows of alert rules by rule ID
 }
 
 var (
@@ -41,6 +48,10 @@ func Initialize() (*Service, error) {
 		globalService = &Service{
 			db:             db,
 			lastAlertTimes: make(map[string]time.Time),
+			ruleStates:     make(map[uint]*ruleState),
+		}
+		if config.GlobalConfig != nil {
+			globalService.overrides = config.GlobalConfig.Alerts
 		}
 
 		logger.Info("Alert service initialized")
@@ -84,6 +95,37 @@ func (s *Service) GetConfig(ctx context.Context) (*models.AlertConfig, error) {
 	return &config, nil
 }
 
+// ApplyConfig applies alert threshold overrides from a reloaded configuration
+func (s *Service) ApplyConfig(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides = cfg.Alerts
+	logger.Info("Alert thresholds updated",
+		zap.Int("failed_login_threshold", cfg.Alerts.FailedLoginThreshold),
+		zap.Int("rate_limit_minutes", cfg.Alerts.RateLimitMinutes))
+	return nil
+}
+
+// getEffectiveConfig returns the stored alert configuration with config file overrides applied
+func (s *Service) getEffectiveConfig(ctx context.Context) (*models.AlertConfig, error) {
+	alertConfig, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.overrides.FailedLoginThreshold > 0 {
+		alertConfig.FailedLoginThreshold = s.overrides.FailedLoginThreshold
+	}
+	if s.overrides.RateLimitMinutes > 0 {
+		alertConfig.RateLimitMinutes = s.overrides.RateLimitMinutes
+	}
+	return alertConfig, nil
+}
+
 // UpdateConfig updates the alert configuration
 func (s *Service) UpdateConfig(ctx context.Context, config *models.AlertConfig) error {
 	s.mu.Lock()
@@ -126,7 +168,7 @@ func (s *Service) TestEmail(ctx context.Context, config *models.AlertConfig) err
 
 // SendFailedLoginAlert sends an alert for failed login attempts
 func (s *Service) SendFailedLoginAlert(ctx context.Context, username, ipAddress string, attemptCount int) error {
-	config, err := s.GetConfig(ctx)
+	config, err := s.getEffectiveConfig(ctx)
 	if err != nil || !config.Enabled || !config.OnFailedLogin {
 		return nil // Silently skip if not enabled
 	}
@@ -169,7 +211,7 @@ func (s *Service) SendFailedLoginAlert(ctx context.Context, username, ipAddress
 
 // SendIPBlockAlert sends an alert when an IP is blocked
 func (s *Service) SendIPBlockAlert(ctx context.Context, ipAddress string, reason string, attempts int) error {
-	config, err := s.GetConfig(ctx)
+	config, err := s.getEffectiveConfig(ctx)
 	if err != nil || !config.Enabled || !config.OnIPBlock {
 		return nil
 	}
@@ -206,7 +248,7 @@ func (s *Service) SendIPBlockAlert(ctx context.Context, ipAddress string, reason
 
 // SendCriticalEventAlert sends an alert for critical security events
 func (s *Service) SendCriticalEventAlert(ctx context.Context, action, username, ipAddress, message string) error {
-	config, err := s.GetConfig(ctx)
+	config, err := s.getEffectiveConfig(ctx)
 	if err != nil || !config.Enabled || !config.OnCriticalEvent {
 		return nil
 	}
@@ -242,6 +284,154 @@ func (s *Service) SendCriticalEventAlert(ctx context.Context, action, username,
 	return s.sendAlert(ctx, config, subject, htmlBody, textBody, models.AlertTypeCriticalEvent)
 }
 
+// SendInactiveUserAlert reports accounts that have not logged in for the
+// given number of days
+func (s *Service) SendInactiveUserAlert(ctx context.Context, usernames []string, days int) error {
+	config, err := s.getEffectiveConfig(ctx)
+	if err != nil || !config.Enabled {
+		return nil
+	}
+
+	list := strings.Join(usernames, ", ")
+	subject := fmt.Sprintf("Inactive Accounts - %d users without login for %d days", len(usernames), days)
+	htmlBody := fmt.Sprintf(`
+<html>
+<body>
+<h2>Inactive User Accounts</h2>
+<p><strong>The following active accounts have not logged in for %d days:</strong></p>
+<p>%s</p>
+<p>Consider disabling accounts that are no longer needed.</p>
+</body>
+</html>
+`, days, html.EscapeString(list))
+
+	textBody := fmt.Sprintf("**Inactive User Accounts**\n\nNo login for %d days: %s\n\nConsider disabling accounts that are no longer needed.", days, list)
+
+	return s.sendAlert(ctx, config, subject, htmlBody, textBody, models.AlertTypeInactiveUser)
+}
+
+// SendDDNSFailureAlert reports that the dynamic DNS record could not be
+// updated to the new public IP
+func (s *Service) SendDDNSFailureAlert(ctx context.Context, hostname, ip string, attempts int, reason string) error {
+	config, err := s.getEffectiveConfig(ctx)
+	if err != nil || !config.Enabled {
+		return nil
+	}
+
+	subject := fmt.Sprintf("Dynamic DNS Update Failed - %s", hostname)
+	htmlBody := fmt.Sprintf(`
+<html>
+<body>
+<h2>Dynamic DNS Update Failed</h2>
+<p><strong>The public IP of the NAS changed, but its DNS record could not be updated.</strong></p>
+<ul>
+<li><strong>Hostname:</strong> %s</li>
+<li><strong>New IP:</strong> %s</li>
+<li><strong>Attempts:</strong> %d</li>
+<li><strong>Error:</strong> %s</li>
+<li><strong>Time:</strong> %s</li>
+</ul>
+<p>The NAS may be unreachable by its hostname until the record is updated.</p>
+</body>
+</html>
+`, html.EscapeString(hostname), ip, attempts, html.EscapeString(reason), time.Now().Format("2006-01-02 15:04:05"))
+
+	textBody := fmt.Sprintf("**Dynamic DNS Update Failed**\n\nHostname: %s\nNew IP: %s\nAttempts: %d\nError: %s\nTime: %s\n\nThe NAS may be unreachable by its hostname until the record is updated.",
+		hostname, ip, attempts, reason, time.Now().Format("2006-01-02 15:04:05"))
+
+	return s.sendAlert(ctx, config, subject, htmlBody, textBody, models.AlertTypeDDNSFailure)
+}
+
+// SendScrubErrorAlert reports a ZFS scrub that found unrecoverable errors
+func (s *Service) SendScrubErrorAlert(ctx context.Context, pool string, errorCount int, repairedBytes uint64, finished time.Time) error {
+	config, err := s.getEffectiveConfig(ctx)
+	if err != nil || !config.Enabled {
+		return nil
+	}
+
+	subject := fmt.Sprintf("ZFS Scrub Found Errors - %s", pool)
+	htmlBody := fmt.Sprintf(`
+<html>
+<body>
+<h2>ZFS Scrub Found Errors</h2>
+<p><strong>The last scrub of a pool found data it could not repair.</strong></p>
+<ul>
+<li><strong>Pool:</strong> %s</li>
+<li><strong>Errors:</strong> %d</li>
+<li><strong>Repaired:</strong> %d bytes</li>
+<li><strong>Finished:</strong> %s</li>
+</ul>
+<p>Run <code>zpool status -v %s</code> to list the affected files and restore them from a backup.</p>
+</body>
+</html>
+`, html.EscapeString(pool), errorCount, repairedBytes, finished.Format("2006-01-02 15:04:05"), html.EscapeString(pool))
+
+	textBody := fmt.Sprintf("**ZFS Scrub Found Errors**\n\nPool: %s\nErrors: %d\nRepaired: %d bytes\nFinished: %s\n\nRun `zpool status -v %s` to list the affected files and restore them from a backup.",
+		pool, errorCount, repairedBytes, finished.Format("2006-01-02 15:04:05"), pool)
+
+	return s.sendAlert(ctx, config, subject, htmlBody, textBody, models.AlertTypeScrubErrors)
+}
+
+// SendSMARTTestFailureAlert reports a failed drive self-test. lba is the
+// first failing block, or nil if the drive did not report one.
+func (s *Service) SendSMARTTestFailureAlert(ctx context.Context, device, testType, status string, lba *uint64) error {
+	config, err := s.getEffectiveConfig(ctx)
+	if err != nil || !config.Enabled {
+		return nil
+	}
+
+	firstError := "-"
+	if lba != nil {
+		firstError = fmt.Sprintf("%d", *lba)
+	}
+
+	subject := fmt.Sprintf("SMART Self-Test Failed - %s", device)
+	htmlBody := fmt.Sprintf(`
+<html>
+<body>
+<h2>SMART Self-Test Failed</h2>
+<p><strong>A drive failed its self-test and may be about to fail.</strong></p>
+<ul>
+<li><strong>Device:</strong> %s</li>
+<li><strong>Test:</strong> %s</li>
+<li><strong>Status:</strong> %s</li>
+<li><strong>First failing LBA:</strong> %s</li>
+<li><strong>Time:</strong> %s</li>
+</ul>
+<p>Back up the data on this drive and plan to replace it.</p>
+</body>
+</html>
+`, html.EscapeString(device), html.EscapeString(testType), html.EscapeString(status), firstError, time.Now().Format("2006-01-02 15:04:05"))
+
+	textBody := fmt.Sprintf("**SMART Self-Test Failed**\n\nDevice: %s\nTest: %s\nStatus: %s\nFirst failing LBA: %s\nTime: %s\n\nBack up the data on this drive and plan to replace it.",
+		device, testType, status, firstError, time.Now().Format("2006-01-02 15:04:05"))
+
+	return s.sendAlert(ctx, config, subject, htmlBody, textBody, models.AlertTypeSMARTTest)
+}
+
+// SendWelcomeEmail tells a new user their username using the alert SMTP
+// settings. Fails if SMTP is not configured.
+func (s *Service) SendWelcomeEmail(ctx context.Context, recipient, username string) error {
+	config, err := s.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	subject := "Welcome to Stumpf.Works NAS"
+	body := fmt.Sprintf(`
+<html>
+<body>
+<h2>Welcome, %s</h2>
+<p>An account has been created for you on Stumpf.Works NAS.</p>
+<p><strong>Username:</strong> %s</p>
+<p>Ask your administrator for your initial password and change it after your first login.</p>
+</body>
+</html>
+`, html.EscapeString(username), html.EscapeString(username))
+
+	return s.sendEmailTo(ctx, config, recipient, subject, body, models.AlertTypeUserWelcome)
+}
+
 // shouldSendAlert checks if an alert should be sent based on rate limiting
 func (s *Service) shouldSendAlert(alertType string, rateLimitMinutes int) bool {
 	s.mu.Lock()
@@ -261,8 +451,50 @@ func (s *Service) shouldSendAlert(alertType string, rateLimitMinutes int) bool {
 	return false
 }
 
-// sendAlert sends alerts to all enabled channels (email and/or webhook)
+// sendAlert sends alerts to all enabled channels (email and/or webhook) and
+// publishes them as notifications for the other delivery channels
 func (s *Service) sendAlert(ctx context.Context, config *models.AlertConfig, subject, htmlBody, textBody, alertType string) error {
+	// Alerts muted by a maintenance window are only recorded for the digest
+	if s.suppress(ctx, alertType, subject, textBody) {
+		return nil
+	}
+
+	severity := alertSeverity(alertType)
+	s.publishAlertEvent(alertType, severity, subject)
+	if err := notifications.Publish(notifications.Notification{
+		Type:     alertType,
+		Title:    subject,
+		Body:     textBody,
+		Severity: severity,
+		Source:   notificationSource,
+	}); err != nil {
+		logger.Warn("Failed to publish alert notification", zap.Error(err))
+	}
+
+	return s.deliverAlert(ctx, config, subject, htmlBody, textBody, alertType)
+}
+
+// alertSeverity returns the severity of an alert type
+func alertSeverity(alertType string) string {
+	if alertType == models.AlertTypeCriticalEvent || alertType == models.AlertTypeSystemError {
+		return events.SeverityCritical
+	}
+	return events.SeverityWarning
+}
+
+// publishAlertEvent publishes an alert to the event bus so live subscribers see it
+func (s *Service) publishAlertEvent(alertType, severity, subject string) {
+	events.Publish(events.Event{
+		Type:     events.TypeAlert,
+		Action:   alertType,
+		Severity: severity,
+		Source:   "alerts",
+		Message:  subject,
+	})
+}
+
+// deliverAlert sends an alert by email and webhook, as enabled
+func (s *Service) deliverAlert(ctx context.Context, config *models.AlertConfig, subject, htmlBody, textBody, alertType string) error {
 	var emailErr, webhookErr error
 
 	// Send email if enabled
@@ -295,8 +527,13 @@ func (s *Service) sendAlert(ctx context.Context, config *models.AlertConfig, sub
 
 // sendEmail sends an email alert
 func (s *Service) sendEmail(ctx context.Context, config *models.AlertConfig, subject, body, alertType string) error {
+	return s.sendEmailTo(ctx, config, config.AlertRecipient, subject, body, alertType)
+}
+
+// sendEmailTo sends an email to a single recipient using the alert SMTP settings
+func (s *Service) sendEmailTo(ctx context.Context, config *models.AlertConfig, recipient, subject, body, alertType string) error {
 	// Validate config
-	if config.SMTPHost == "" || config.AlertRecipient == "" {
+	if config.SMTPHost == "" || recipient == "" {
 		return fmt.Errorf("SMTP host and recipient are required")
 	}
 
@@ -313,7 +550,7 @@ func (s *Service) sendEmail(ctx context.Context, config *models.AlertConfig, sub
 	// Prepare email headers
 	headers := make(map[string]string)
 	headers["From"] = fmt.Sprintf("%s <%s>", fromName, from)
-	headers["To"] = config.AlertRecipient
+	headers["To"] = recipient
 	headers["Subject"] = subject
 	headers["MIME-Version"] = "1.0"
 	headers["Content-Type"] = "text/html; charset=UTF-8"
@@ -330,9 +567,9 @@ func (s *Service) sendEmail(ctx context.Context, config *models.AlertConfig, sub
 
 	var err error
 	if config.SMTPUseTLS {
-		err = s.sendEmailTLS(addr, auth, from, []string{config.AlertRecipient}, []byte(message))
+		err = s.sendEmailTLS(addr, auth, from, []string{recipient}, []byte(message))
 	} else {
-		err = smtp.SendMail(addr, auth, from, []string{config.AlertRecipient}, []byte(message))
+		err = smtp.SendMail(addr, auth, from, []string{recipient}, []byte(message))
 	}
 
 	// Log the alert
@@ -341,7 +578,7 @@ func (s *Service) sendEmail(ctx context.Context, config *models.AlertConfig, sub
 		Channel:   models.AlertChannelEmail,
 		Subject:   subject,
 		Body:      body,
-		Recipient: config.AlertRecipient,
+		Recipient: recipient,
 		Status:    "sent",
 	}
 
@@ -351,11 +588,11 @@ func (s *Service) sendEmail(ctx context.Context, config *models.AlertConfig, sub
 		logger.Error("Failed to send alert email",
 			zap.Error(err),
 			zap.String("type", alertType),
-			zap.String("recipient", config.AlertRecipient))
+			zap.String("recipient", recipient))
 	} else {
 		logger.Info("Alert email sent",
 			zap.String("type", alertType),
-			zap.String("recipient", config.AlertRecipient))
+			zap.String("recipient", recipient))
 	}
 
 	s.db.WithContext(ctx).Create(alertLog)
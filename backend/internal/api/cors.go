@@ -0,0 +1,63 @@
+package api
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// corsOrigins holds the configured CORS origins so they can be replaced when
+// the configuration is reloaded
+type corsOrigins struct {
+	mu      sync.RWMutex
+	origins []string
+}
+
+func newCORSOrigins(origins []string) *corsOrigins {
+	c := &corsOrigins{}
+	c.set(origins)
+	return c
+}
+
+func (c *corsOrigins) set(origins []string) {
+	normalized := make([]string, 0, len(origins))
+	for _, o := range origins {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(o)))
+	}
+
+	c.mu.Lock()
+	c.origins = normalized
+	c.mu.Unlock()
+}
+
+// ApplyConfig replaces the allowed origins with those from the reloaded configuration
+func (c *corsOrigins) ApplyConfig(cfg *config.Config) error {
+	c.set(cfg.Server.AllowedOrigins)
+	logger.Info("CORS: Allowed origins updated", zap.Strings("origins", cfg.Server.AllowedOrigins))
+	return nil
+}
+
+// allowed reports whether origin matches one of the configured origins.
+// Supports "*" and a single wildcard such as "https://*.example.com".
+func (c *corsOrigins) allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, o := range c.origins {
+		if o == "*" || o == origin {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(o, "*"); ok {
+			if len(origin) >= len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
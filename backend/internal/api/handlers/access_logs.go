@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/accesslog"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+// ListAccessLogs retrieves HTTP access logs with filtering and pagination
+// GET /api/v1/admin/access-logs?userId=1&pathPrefix=/api/v1/files&minStatus=400&maxStatus=499&startDate=...&endDate=...&limit=100&offset=0
+func ListAccessLogs(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		utils.RespondError(w, errors.InternalServerError("Database not initialized", nil))
+		return
+	}
+
+	params, err := parseAccessLogQuery(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	service := accesslog.GetService()
+	if service == nil {
+		service = accesslog.NewService(db)
+	}
+
+	logs, total, err := service.Query(r.Context(), params)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to retrieve access logs", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]interface{}{
+		"logs":   logs,
+		"total":  total,
+		"limit":  params.Limit,
+		"offset": params.Offset,
+	})
+}
+
+// parseAccessLogQuery builds query parameters from the request
+func parseAccessLogQuery(r *http.Request) (*accesslog.QueryParams, error) {
+	query := r.URL.Query()
+	params := &accesslog.QueryParams{
+		PathPrefix: query.Get("pathPrefix"),
+		Limit:      100,
+	}
+
+	if v := query.Get("userId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, errors.BadRequest("Invalid userId", err)
+		}
+		uid := uint(id)
+		params.UserID = &uid
+	}
+
+	ints := []struct {
+		name   string
+		target *int
+		min    int
+		max    int
+	}{
+		{"minStatus", &params.MinStatus, 100, 599},
+		{"maxStatus", &params.MaxStatus, 100, 599},
+		{"limit", &params.Limit, 1, 1000},
+		{"offset", &params.Offset, 0, int(^uint(0) >> 1)},
+	}
+	for _, p := range ints {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < p.min || n > p.max {
+			return nil, errors.BadRequest("Invalid "+p.name, err)
+		}
+		*p.target = n
+	}
+
+	times := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"startDate", &params.StartDate},
+		{"endDate", &params.EndDate},
+	}
+	for _, p := range times {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.BadRequest("Invalid "+p.name+" (use RFC3339)", err)
+		}
+		*p.target = &t
+	}
+
+	return params, nil
+}
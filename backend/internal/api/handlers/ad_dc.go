@@ -133,7 +133,7 @@ func (h *ADDCHandler) ProvisionDomain(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info("Provisioning AD domain", zap.String("realm", opts.Realm), zap.String("domain", opts.Domain))
 
-	if err := h.service.Provision(opts); err != nil {
+	if err := h.service.Provision(r.Context(), opts); err != nil {
 		logger.Error("Failed to provision domain", zap.Error(err))
 		utils.RespondError(w, errors.InternalServerError("Failed to provision domain", err))
 		return
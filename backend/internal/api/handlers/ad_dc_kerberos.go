@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/ad"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ===== Kerberos Tickets =====
+
+// KerberosObtainRequest is the body of POST /ad-dc/kerberos/obtain. Exactly
+// one of Password and Keytab is set.
+type KerberosObtainRequest struct {
+	Principal string `json:"principal"`          // e.g. nfs/nas.example.com@EXAMPLE.COM
+	Password  string `json:"password,omitempty"` // Not stored; the TGT is renewed until its renewable lifetime ends
+	Keytab    string `json:"keytab,omitempty"`   // Absolute path; new TGTs are obtained from it as needed
+}
+
+// ListKerberosTickets lists the tickets of the service principals
+func (h *ADDCHandler) ListKerberosTickets(w http.ResponseWriter, r *http.Request) {
+	manager, ok := requireKerberosManager(w)
+	if !ok {
+		return
+	}
+
+	tickets, err := manager.ListTickets()
+	if err != nil {
+		logger.Error("Failed to list Kerberos tickets", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to list Kerberos tickets", err))
+		return
+	}
+
+	utils.RespondSuccess(w, tickets)
+}
+
+// ObtainKerberosTicket obtains a TGT for a service principal
+func (h *ADDCHandler) ObtainKerberosTicket(w http.ResponseWriter, r *http.Request) {
+	manager, ok := requireKerberosManager(w)
+	if !ok {
+		return
+	}
+
+	var req KerberosObtainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.Principal == "" {
+		utils.RespondError(w, errors.BadRequest("Principal is required", nil))
+		return
+	}
+	if (req.Password == "") == (req.Keytab == "") {
+		utils.RespondError(w, errors.BadRequest("Either a password or a keytab is required", nil))
+		return
+	}
+
+	if err := manager.ObtainTGT(req.Principal, req.Password, req.Keytab); err != nil {
+		logger.Error("Failed to obtain Kerberos ticket", zap.String("principal", req.Principal), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to obtain Kerberos ticket", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message":   "Kerberos ticket obtained successfully",
+		"principal": req.Principal,
+		"cache":     manager.CachePath(req.Principal),
+	})
+}
+
+// requireKerberosManager returns the Kerberos ticket manager, responding
+// with an error if it is not available
+func requireKerberosManager(w http.ResponseWriter) (*ad.KerberosManager, bool) {
+	manager := ad.GetKerberosManager()
+	if manager == nil {
+		utils.RespondError(w, errors.NewAppError(
+			http.StatusServiceUnavailable,
+			"Kerberos ticket management not available",
+			nil,
+		))
+		return nil, false
+	}
+	return manager, true
+}
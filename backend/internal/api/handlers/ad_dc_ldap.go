@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/ad"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ===== LDAP Browser =====
+
+// LDAPSearch searches the directory. Query parameters: base (DN, defaults
+// to the domain), filter (defaults to all objects) and attrs (comma
+// separated, defaults to all attributes).
+func (h *ADDCHandler) LDAPSearch(w http.ResponseWriter, r *http.Request) {
+	browser, ok := h.ldapBrowser(w)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var attrs []string
+	for _, attr := range strings.Split(query.Get("attrs"), ",") {
+		if attr = strings.TrimSpace(attr); attr != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	entries, err := browser.Search(query.Get("base"), query.Get("filter"), attrs)
+	if err != nil {
+		respondLDAPError(w, "Failed to search directory", err)
+		return
+	}
+
+	utils.RespondSuccess(w, entries)
+}
+
+// LDAPGetObject returns the object given by the dn query parameter
+func (h *ADDCHandler) LDAPGetObject(w http.ResponseWriter, r *http.Request) {
+	browser, ok := h.ldapBrowser(w)
+	if !ok {
+		return
+	}
+
+	dn := r.URL.Query().Get("dn")
+	if dn == "" {
+		utils.RespondError(w, errors.BadRequest("dn is required", nil))
+		return
+	}
+
+	entry, err := browser.GetObject(dn)
+	if err != nil {
+		respondLDAPError(w, "Failed to get object", err)
+		return
+	}
+
+	utils.RespondSuccess(w, entry)
+}
+
+// ldapBrowser returns a browser of the domain, responding with an error
+// if there is none
+func (h *ADDCHandler) ldapBrowser(w http.ResponseWriter) (*ad.LDAPBrowser, bool) {
+	if h.service == nil {
+		utils.RespondError(w, errors.NewAppError(
+			http.StatusServiceUnavailable,
+			"AD DC service not available",
+			nil,
+		))
+		return nil, false
+	}
+
+	browser, err := h.service.LDAPBrowser()
+	if err != nil {
+		utils.RespondError(w, errors.NewAppError(http.StatusServiceUnavailable, "LDAP directory not available", err))
+		return nil, false
+	}
+	return browser, true
+}
+
+// respondLDAPError maps LDAP browser errors to responses
+func respondLDAPError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case stderrors.Is(err, ad.ErrInvalidDN), stderrors.Is(err, ad.ErrInvalidFilter), stderrors.Is(err, ad.ErrInvalidAttribute):
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+	case stderrors.Is(err, ad.ErrLDAPObjectNotFound):
+		utils.RespondError(w, errors.NotFound("Object not found", err))
+	default:
+		logger.Error(message, zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError(message, err))
+	}
+}
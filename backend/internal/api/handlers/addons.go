@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/addons"
@@ -120,28 +121,40 @@ func InstallAddon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := addonManager.InstallAddon(addonID); err != nil {
+	plan, err := addonManager.InstallAddon(addonID)
+	if err != nil {
 		logger.Error("Failed to install addon", zap.Error(err), zap.String("addon_id", addonID))
+		var conflict addons.ConflictError
+		if stderrors.As(err, &conflict) {
+			utils.RespondError(w, errors.Conflict("Addon conflicts with an installed addon", err))
+			return
+		}
+		if plan == nil {
+			utils.RespondError(w, errors.BadRequest("Failed to resolve addon dependencies", err))
+			return
+		}
 		utils.RespondError(w, errors.InternalServerError("Failed to install addon", err))
 		return
 	}
 
-	logger.Info("Addon installed successfully via API", zap.String("addon_id", addonID))
+	logger.Info("Addon installed successfully via API", zap.String("addon_id", addonID), zap.Strings("installed", plan.Install))
 
 	// Schedule service restart if addon requires it
 	if addon.RequiresRestart {
 		logger.Info("Addon requires service restart, scheduling restart", zap.String("addon_id", addonID))
 		addonManager.ScheduleServiceRestart()
 
-		utils.RespondSuccess(w, map[string]string{
+		utils.RespondSuccess(w, map[string]interface{}{
 			"message":           "Addon installed successfully. Service will restart in 3 seconds to initialize addon.",
 			"addon_id":          addonID,
 			"restart_scheduled": "true",
+			"plan":              plan,
 		})
 	} else {
-		utils.RespondSuccess(w, map[string]string{
+		utils.RespondSuccess(w, map[string]interface{}{
 			"message":  "Addon installed successfully",
 			"addon_id": addonID,
+			"plan":     plan,
 		})
 	}
 }
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// ListAlertRules handles GET /api/v1/alerts/rules
+func (h *AlertHandler) ListAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.alertService.ListAlertRules()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list alert rules", err))
+		return
+	}
+
+	utils.RespondSuccess(w, rules)
+}
+
+// CreateAlertRule handles POST /api/v1/alerts/rules
+func (h *AlertHandler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	var req alerts.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	rule, err := h.alertService.CreateAlertRule(req)
+	if err != nil {
+		if stderrors.Is(err, alerts.ErrAlertRuleExists) {
+			utils.RespondError(w, errors.Conflict("An alert rule with this name already exists", err))
+			return
+		}
+		logger.Error("Failed to create alert rule", zap.String("name", req.Name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to create alert rule", err))
+		return
+	}
+
+	utils.RespondCreated(w, rule)
+}
+
+// UpdateAlertRule handles PUT /api/v1/alerts/rules/{id}
+func (h *AlertHandler) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req alerts.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	rule, err := h.alertService.UpdateAlertRule(id, req)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, alerts.ErrAlertRuleNotFound):
+			utils.RespondError(w, errors.NotFound("Alert rule not found", err))
+		case stderrors.Is(err, alerts.ErrAlertRuleExists):
+			utils.RespondError(w, errors.Conflict("An alert rule with this name already exists", err))
+		default:
+			logger.Error("Failed to update alert rule", zap.String("id", id), zap.Error(err))
+			utils.RespondError(w, errors.InternalServerError("Failed to update alert rule", err))
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, rule)
+}
+
+// DeleteAlertRule handles DELETE /api/v1/alerts/rules/{id}
+func (h *AlertHandler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.alertService.DeleteAlertRule(id); err != nil {
+		if stderrors.Is(err, alerts.ErrAlertRuleNotFound) {
+			utils.RespondError(w, errors.NotFound("Alert rule not found", err))
+			return
+		}
+		logger.Error("Failed to delete alert rule", zap.String("id", id), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to delete alert rule", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var arpMonitor *network.ARPMonitor
+
+// InitARPMonitor initializes the ARP monitor
+func InitARPMonitor(m *network.ARPMonitor) {
+	arpMonitor = m
+	logger.Info("ARP monitor initialized")
+}
+
+// WhitelistMACRequest represents a request to let an IP move to a MAC
+// address without an ARP spoofing alert
+type WhitelistMACRequest struct {
+	IP  string `json:"ip"`
+	MAC string `json:"mac"`
+}
+
+func requireARPMonitor(w http.ResponseWriter) bool {
+	if arpMonitor == nil {
+		utils.RespondError(w, errors.InternalServerError("ARP monitor not available", nil))
+		return false
+	}
+	return true
+}
+
+// ListARPDevices handles GET /api/v1/network/arp/devices
+func ListARPDevices(w http.ResponseWriter, r *http.Request) {
+	if !requireARPMonitor(w) {
+		return
+	}
+
+	devices, err := arpMonitor.GetKnownDevices()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list ARP devices", err))
+		return
+	}
+
+	utils.RespondSuccess(w, devices)
+}
+
+// WhitelistARPMAC handles POST /api/v1/network/arp/whitelist
+func WhitelistARPMAC(w http.ResponseWriter, r *http.Request) {
+	if !requireARPMonitor(w) {
+		return
+	}
+
+	var req WhitelistMACRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if err := arpMonitor.WhitelistMAC(req.IP, req.MAC); err != nil {
+		logger.Warn("Failed to whitelist MAC", zap.String("ip", req.IP), zap.String("mac", req.MAC), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{"message": "MAC whitelisted"})
+}
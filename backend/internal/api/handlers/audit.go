@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
@@ -156,3 +157,32 @@ func (h *AuditHandler) GetAuditStats(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondSuccess(w, stats)
 }
+
+// GetRetentionPolicy retrieves the audit log retention policy
+func (h *AuditHandler) GetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.service.GetRetentionPolicy()
+	if err != nil {
+		logger.Error("Failed to retrieve audit retention policy", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to retrieve audit retention policy", err))
+		return
+	}
+
+	utils.RespondSuccess(w, policy)
+}
+
+// UpdateRetentionPolicy replaces the audit log retention policy
+func (h *AuditHandler) UpdateRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	var req audit.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	policy, err := h.service.UpdateRetentionPolicy(r.Context(), req)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, policy)
+}
@@ -3,10 +3,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/audit"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/auth"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/twofa"
@@ -25,11 +28,14 @@ type LoginRequest struct {
 
 // LoginResponse represents a login response
 type LoginResponse struct {
-	Requires2FA  bool                `json:"requires2FA,omitempty"`
-	UserID       uint                `json:"userId,omitempty"`
-	AccessToken  string              `json:"accessToken,omitempty"`
-	RefreshToken string              `json:"refreshToken,omitempty"`
-	User         *users.UserResponse `json:"user,omitempty"`
+	Requires2FA      bool                `json:"requires2FA,omitempty"`
+	Requires2FASetup bool                `json:"requires2FASetup,omitempty"` // 2FA must be set up using SetupToken before logging in
+	UserID           uint                `json:"userId,omitempty"`
+	AccessToken      string              `json:"accessToken,omitempty"`
+	RefreshToken     string              `json:"refreshToken,omitempty"`
+	SetupToken       string              `json:"setupToken,omitempty"`    // Only allows the 2FA setup flow
+	MFAGraceUntil    *time.Time          `json:"mfaGraceUntil,omitempty"` // 2FA is required after this time
+	User             *users.UserResponse `json:"user,omitempty"`
 }
 
 // Login handles user authentication
@@ -44,11 +50,26 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	ipAddress := getClientIP(r)
 	userAgent := r.UserAgent()
 
+	// Refuse logins from locked out IPs and usernames
+	failedLoginService := auth.GetFailedLoginService()
+	if failedLoginService != nil {
+		locked, lockout, err := failedLoginService.IsLocked(req.Username, ipAddress)
+		if err != nil {
+			logger.Error("Failed to check lockout", zap.Error(err), zap.String("username", req.Username))
+		} else if locked {
+			message := "Too many failed login attempts. Please try again later."
+			if lockout.ExpiresAt != nil {
+				message = fmt.Sprintf("Too many failed login attempts. Please try again after %s.", lockout.ExpiresAt.Format(time.RFC3339))
+			}
+			utils.RespondError(w, errors.Forbidden(message, nil))
+			return
+		}
+	}
+
 	// Authenticate user
 	user, err := users.AuthenticateUser(req.Username, req.Password)
 	if err != nil {
 		// Track failed login attempt
-		failedLoginService := auth.GetFailedLoginService()
 		if failedLoginService != nil {
 			// Determine failure reason
 			reason := models.FailureReasonInvalidPassword
@@ -94,7 +115,36 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Generate tokens (no 2FA required or 2FA not enabled)
+	// 2FA not enabled
+	completeLogin(w, r, user, false)
+}
+
+// completeLogin issues the tokens of a user who authenticated with enrolled
+// telling whether 2FA was used. Users the MFA policy requires to use 2FA
+// only get a token for the 2FA setup flow once the grace period ended.
+func completeLogin(w http.ResponseWriter, r *http.Request, user *users.User, enrolled bool) {
+	var mfaCheck auth.MFACheck
+	if enforcement := auth.GetMFAEnforcement(); enforcement != nil {
+		mfaCheck = enforcement.Check(user.Role, getClientIP(r), enrolled, time.Now().UTC())
+	}
+
+	if mfaCheck.Status == auth.MFASetupRequired {
+		setupToken, err := users.GenerateSetupToken(user)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to generate setup token", err))
+			return
+		}
+
+		logger.Info("Login requires 2FA setup", zap.String("username", user.Username))
+		utils.RespondSuccess(w, LoginResponse{
+			Requires2FASetup: true,
+			UserID:           user.ID,
+			SetupToken:       setupToken,
+			User:             users.ToResponse(user),
+		})
+		return
+	}
+
 	accessToken, err := users.GenerateToken(user)
 	if err != nil {
 		utils.RespondError(w, errors.InternalServerError("Failed to generate access token", err))
@@ -107,14 +157,36 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	auditLogin(r, user)
+
 	// Return response
 	utils.RespondSuccess(w, LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         users.ToResponse(user),
+		AccessToken:   accessToken,
+		RefreshToken:  refreshToken,
+		MFAGraceUntil: mfaCheck.GraceUntil,
+		User:          users.ToResponse(user),
 	})
 }
 
+// auditLogin records a successful login under the user's ID. The audit
+// middleware cannot, as the request is unauthenticated.
+func auditLogin(r *http.Request, user *users.User) {
+	userID := user.ID
+	if err := audit.GetService().Log(r.Context(), &audit.LogEntry{
+		UserID:    &userID,
+		Username:  user.Username,
+		Action:    models.ActionAuthLogin,
+		Resource:  "auth",
+		Status:    models.StatusSuccess,
+		Severity:  models.SeverityInfo,
+		IPAddress: getClientIP(r),
+		UserAgent: r.UserAgent(),
+		Message:   "User " + user.Username + " logged in",
+	}); err != nil {
+		logger.Error("Failed to audit login", zap.String("username", user.Username), zap.Error(err))
+	}
+}
+
 // getClientIP extracts the real IP address from the request
 func getClientIP(r *http.Request) string {
 	// Check X-Forwarded-For header
@@ -159,6 +231,10 @@ func RefreshToken(w http.ResponseWriter, r *http.Request) {
 		utils.RespondError(w, errors.Unauthorized("Invalid refresh token", err))
 		return
 	}
+	if claims.Scope != "" {
+		utils.RespondError(w, errors.Unauthorized("Invalid refresh token", nil))
+		return
+	}
 
 	// Get user
 	user, err := users.GetUserByID(claims.UserID)
@@ -240,23 +316,5 @@ func LoginWith2FA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := users.GenerateToken(user)
-	if err != nil {
-		utils.RespondError(w, errors.InternalServerError("Failed to generate access token", err))
-		return
-	}
-
-	refreshToken, err := users.GenerateRefreshToken(user)
-	if err != nil {
-		utils.RespondError(w, errors.InternalServerError("Failed to generate refresh token", err))
-		return
-	}
-
-	// Return response
-	utils.RespondSuccess(w, LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         users.ToResponse(user),
-	})
+	completeLogin(w, r, user, true)
 }
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -124,7 +125,9 @@ func (h *BackupHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
 func (h *BackupHandler) RunJob(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "id")
 
-	history, err := h.service.RunJob(r.Context(), jobID)
+	// The run outlives a client that disconnects or times out; its progress
+	// is published as events.TypeBackup events
+	history, err := h.service.RunJob(context.WithoutCancel(r.Context()), jobID)
 	if err != nil {
 		logger.Error("Failed to run backup job", zap.Error(err), zap.String("jobID", jobID))
 		utils.RespondError(w, errors.InternalServerError("Failed to run backup job", err))
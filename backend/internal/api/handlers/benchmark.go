@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// maxBenchmarkResults limits the results stored for one benchmark run
+const maxBenchmarkResults = 100
+
+// BenchmarkMeasurement is one measurement of a benchmark run
+type BenchmarkMeasurement struct {
+	Test   string  `json:"test"` // disk, network or cpu
+	Target string  `json:"target"`
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit"`
+}
+
+// SaveBenchmarkResultsRequest represents the results of a stumpfctl system
+// benchmark run
+type SaveBenchmarkResultsRequest struct {
+	Hostname string                 `json:"hostname"`
+	Results  []BenchmarkMeasurement `json:"results"`
+}
+
+// SaveBenchmarkResults stores the results of a benchmark run under a new
+// run ID, so that runs can be compared later
+// POST /api/v1/system/benchmark/results
+func SaveBenchmarkResults(w http.ResponseWriter, r *http.Request) {
+	var req SaveBenchmarkResultsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+	if len(req.Results) == 0 || len(req.Results) > maxBenchmarkResults {
+		utils.RespondError(w, errors.BadRequest("A run must have between 1 and 100 results", nil))
+		return
+	}
+
+	runID := uuid.New().String()
+	now := time.Now()
+	results := make([]models.BenchmarkResult, len(req.Results))
+	for i, m := range req.Results {
+		switch m.Test {
+		case models.BenchmarkTestDisk, models.BenchmarkTestNetwork, models.BenchmarkTestCPU:
+		default:
+			utils.RespondError(w, errors.BadRequest("test must be disk, network or cpu", nil))
+			return
+		}
+		if m.Metric == "" || m.Value < 0 {
+			utils.RespondError(w, errors.BadRequest("Results need a metric and a value of at least 0", nil))
+			return
+		}
+		results[i] = models.BenchmarkResult{
+			RunID:     runID,
+			Hostname:  req.Hostname,
+			Test:      m.Test,
+			Target:    m.Target,
+			Metric:    m.Metric,
+			Value:     m.Value,
+			Unit:      m.Unit,
+			CreatedAt: now,
+		}
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		utils.RespondError(w, errors.InternalServerError("Database not initialized", nil))
+		return
+	}
+	if err := db.Create(&results).Error; err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to save benchmark results", err))
+		return
+	}
+
+	utils.RespondCreated(w, results)
+}
+
+// ListBenchmarkResults returns the stored benchmark results, newest first
+// (?test=disk&limit=100)
+// GET /api/v1/system/benchmark/results
+func ListBenchmarkResults(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			utils.RespondError(w, errors.BadRequest("Invalid limit", err))
+			return
+		}
+		limit = n
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		utils.RespondError(w, errors.InternalServerError("Database not initialized", nil))
+		return
+	}
+
+	query := db.Order("created_at DESC, id")
+	if test := r.URL.Query().Get("test"); test != "" {
+		query = query.Where("test = ?", test)
+	}
+	results := []models.BenchmarkResult{}
+	if err := query.Limit(limit).Find(&results).Error; err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list benchmark results", err))
+		return
+	}
+
+	utils.RespondSuccess(w, results)
+}
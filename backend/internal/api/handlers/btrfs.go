@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// BTRFSSubvolumeRequest is the request body of CreateBTRFSSubvolume
+type BTRFSSubvolumeRequest struct {
+	Path string `json:"path"`
+}
+
+// BTRFSSnapshotRequest is the request body of CreateBTRFSSnapshot
+type BTRFSSnapshotRequest struct {
+	Source      string `json:"source"`      // Subvolume to snapshot
+	Destination string `json:"destination"` // Path of the new snapshot
+	ReadOnly    bool   `json:"read_only"`   // Required to send the snapshot
+}
+
+// BTRFSSendReceiveRequest is the request body of BTRFSSendReceive
+type BTRFSSendReceiveRequest struct {
+	Snapshot    string `json:"snapshot"`         // Read-only snapshot to send
+	Destination string `json:"destination"`      // Directory on the receiving filesystem
+	Parent      string `json:"parent,omitempty"` // Snapshot on both sides, to send only the changes since it
+}
+
+// btrfsManager returns the BTRFS manager, responding with an error if
+// btrfs-progs is not installed
+func btrfsManager(w http.ResponseWriter) *storage.BTRFSManager {
+	lib := getSystemLib(w)
+	if lib == nil {
+		return nil
+	}
+	if lib.Storage == nil || lib.Storage.BTRFS == nil {
+		utils.RespondError(w, errors.BadRequest("BTRFS not available", nil))
+		return nil
+	}
+	return lib.Storage.BTRFS
+}
+
+// btrfsPaths checks that paths, given as name and value pairs, are
+// absolute
+func btrfsPaths(pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		name, path := pairs[i], pairs[i+1]
+		if path == "" {
+			return fmt.Errorf("%s is required", name)
+		}
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("%s must be an absolute path", name)
+		}
+	}
+	return nil
+}
+
+// ListBTRFSSubvolumes lists the subvolumes of the filesystem mounted at
+// the mountpoint query parameter
+func ListBTRFSSubvolumes(w http.ResponseWriter, r *http.Request) {
+	mountPoint := r.URL.Query().Get("mountpoint")
+	if err := btrfsPaths("mountpoint", mountPoint); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), nil))
+		return
+	}
+	btrfs := btrfsManager(w)
+	if btrfs == nil {
+		return
+	}
+
+	subvolumes, err := btrfs.ListSubvolumes(mountPoint)
+	if err != nil {
+		logger.Error("Failed to list BTRFS subvolumes", zap.String("mountpoint", mountPoint), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to list subvolumes", err))
+		return
+	}
+
+	utils.RespondSuccess(w, subvolumes)
+}
+
+// CreateBTRFSSubvolume creates a subvolume
+func CreateBTRFSSubvolume(w http.ResponseWriter, r *http.Request) {
+	var req BTRFSSubvolumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+	if err := btrfsPaths("path", req.Path); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), nil))
+		return
+	}
+	btrfs := btrfsManager(w)
+	if btrfs == nil {
+		return
+	}
+
+	if err := btrfs.CreateSubvolume(req.Path); err != nil {
+		logger.Error("Failed to create BTRFS subvolume", zap.String("path", req.Path), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to create subvolume", err))
+		return
+	}
+
+	utils.RespondCreated(w, map[string]string{
+		"message": "Subvolume created successfully",
+		"path":    req.Path,
+	})
+}
+
+// DeleteBTRFSSubvolume deletes the subvolume or snapshot at the path query
+// parameter
+func DeleteBTRFSSubvolume(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if err := btrfsPaths("path", path); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), nil))
+		return
+	}
+	btrfs := btrfsManager(w)
+	if btrfs == nil {
+		return
+	}
+
+	if err := btrfs.DeleteSubvolume(path); err != nil {
+		logger.Error("Failed to delete BTRFS subvolume", zap.String("path", path), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to delete subvolume", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// CreateBTRFSSnapshot snapshots a subvolume
+func CreateBTRFSSnapshot(w http.ResponseWriter, r *http.Request) {
+	var req BTRFSSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+	if err := btrfsPaths("source", req.Source, "destination", req.Destination); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), nil))
+		return
+	}
+	btrfs := btrfsManager(w)
+	if btrfs == nil {
+		return
+	}
+
+	if err := btrfs.CreateSnapshot(req.Source, req.Destination, req.ReadOnly); err != nil {
+		logger.Error("Failed to create BTRFS snapshot", zap.String("source", req.Source), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to create snapshot", err))
+		return
+	}
+
+	utils.RespondCreated(w, map[string]string{
+		"message":  "Snapshot created successfully",
+		"snapshot": req.Destination,
+	})
+}
+
+// BTRFSSendReceive replicates a read-only snapshot to another BTRFS
+// filesystem
+func BTRFSSendReceive(w http.ResponseWriter, r *http.Request) {
+	var req BTRFSSendReceiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+	paths := []string{"snapshot", req.Snapshot, "destination", req.Destination}
+	if req.Parent != "" {
+		paths = append(paths, "parent", req.Parent)
+	}
+	if err := btrfsPaths(paths...); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), nil))
+		return
+	}
+	btrfs := btrfsManager(w)
+	if btrfs == nil {
+		return
+	}
+
+	if err := btrfs.SendReceive(req.Snapshot, req.Destination, req.Parent); err != nil {
+		logger.Error("Failed to send BTRFS snapshot", zap.String("snapshot", req.Snapshot),
+			zap.String("destination", req.Destination), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to send snapshot", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Snapshot sent successfully",
+	})
+}
+
+// GetBTRFSUsage returns the space usage of the filesystem mounted at the
+// mountpoint query parameter
+func GetBTRFSUsage(w http.ResponseWriter, r *http.Request) {
+	mountPoint := r.URL.Query().Get("mountpoint")
+	if err := btrfsPaths("mountpoint", mountPoint); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), nil))
+		return
+	}
+	btrfs := btrfsManager(w)
+	if btrfs == nil {
+		return
+	}
+
+	usage, err := btrfs.GetFilesystemUsage(mountPoint)
+	if err != nil {
+		logger.Error("Failed to get BTRFS usage", zap.String("mountpoint", mountPoint), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to get usage", err))
+		return
+	}
+
+	utils.RespondSuccess(w, usage)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/cloudbackup"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var rcloneConfigManager *cloudbackup.RcloneConfigManager
+
+// InitRcloneConfigManager initializes the rclone config manager
+func InitRcloneConfigManager(m *cloudbackup.RcloneConfigManager) {
+	rcloneConfigManager = m
+	logger.Info("rclone config manager initialized")
+}
+
+// CreateRcloneRemoteRequest represents a request to add an rclone remote
+type CreateRcloneRemoteRequest struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Params map[string]string `json:"params"`
+}
+
+// UpdateRcloneRemoteRequest represents a request to change the parameters
+// of an rclone remote
+type UpdateRcloneRemoteRequest struct {
+	Params map[string]string `json:"params"`
+}
+
+func requireRclone(w http.ResponseWriter) bool {
+	if rcloneConfigManager == nil {
+		utils.RespondError(w, errors.InternalServerError("Cloud backup not available (rclone not installed)", nil))
+		return false
+	}
+	return true
+}
+
+// ListRcloneRemotes handles GET /api/v1/cloudbackup/remotes
+func ListRcloneRemotes(w http.ResponseWriter, r *http.Request) {
+	if !requireRclone(w) {
+		return
+	}
+
+	remotes, err := rcloneConfigManager.ListRemotes()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list rclone remotes", err))
+		return
+	}
+
+	utils.RespondSuccess(w, remotes)
+}
+
+// GetRcloneRemote handles GET /api/v1/cloudbackup/remotes/{name}
+func GetRcloneRemote(w http.ResponseWriter, r *http.Request) {
+	if !requireRclone(w) {
+		return
+	}
+
+	remote, err := rcloneConfigManager.GetRemote(chi.URLParam(r, "name"))
+	if err != nil {
+		if stderrors.Is(err, cloudbackup.ErrRemoteNotFound) {
+			utils.RespondError(w, errors.NotFound("rclone remote not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get rclone remote", err))
+		return
+	}
+
+	utils.RespondSuccess(w, remote)
+}
+
+// CreateRcloneRemote handles POST /api/v1/cloudbackup/remotes
+func CreateRcloneRemote(w http.ResponseWriter, r *http.Request) {
+	if !requireRclone(w) {
+		return
+	}
+
+	var req CreateRcloneRemoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := cloudbackup.ValidateRemote(req.Name, req.Type, req.Params); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	if err := rcloneConfigManager.AddRemote(req.Name, req.Type, req.Params); err != nil {
+		if stderrors.Is(err, cloudbackup.ErrRemoteExists) {
+			utils.RespondError(w, errors.Conflict("rclone remote already exists", err))
+			return
+		}
+		logger.Error("Failed to create rclone remote", zap.String("name", req.Name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to create rclone remote", err))
+		return
+	}
+
+	remote, err := rcloneConfigManager.GetRemote(req.Name)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get rclone remote", err))
+		return
+	}
+	utils.RespondCreated(w, remote)
+}
+
+// UpdateRcloneRemote handles PUT /api/v1/cloudbackup/remotes/{name}
+func UpdateRcloneRemote(w http.ResponseWriter, r *http.Request) {
+	if !requireRclone(w) {
+		return
+	}
+
+	var req UpdateRcloneRemoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := rcloneConfigManager.UpdateRemote(name, req.Params); err != nil {
+		if stderrors.Is(err, cloudbackup.ErrRemoteNotFound) {
+			utils.RespondError(w, errors.NotFound("rclone remote not found", err))
+			return
+		}
+		logger.Error("Failed to update rclone remote", zap.String("name", name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to update rclone remote", err))
+		return
+	}
+
+	remote, err := rcloneConfigManager.GetRemote(name)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get rclone remote", err))
+		return
+	}
+	utils.RespondSuccess(w, remote)
+}
+
+// DeleteRcloneRemote handles DELETE /api/v1/cloudbackup/remotes/{name}
+func DeleteRcloneRemote(w http.ResponseWriter, r *http.Request) {
+	if !requireRclone(w) {
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := rcloneConfigManager.RemoveRemote(name); err != nil {
+		if stderrors.Is(err, cloudbackup.ErrRemoteNotFound) {
+			utils.RespondError(w, errors.NotFound("rclone remote not found", err))
+			return
+		}
+		logger.Error("Failed to delete rclone remote", zap.String("name", name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to delete rclone remote", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// TestRcloneRemote handles POST /api/v1/cloudbackup/remotes/{name}/test
+func TestRcloneRemote(w http.ResponseWriter, r *http.Request) {
+	if !requireRclone(w) {
+		return
+	}
+
+	result, err := rcloneConfigManager.TestRemote(chi.URLParam(r, "name"))
+	if err != nil {
+		if stderrors.Is(err, cloudbackup.ErrRemoteNotFound) {
+			utils.RespondError(w, errors.NotFound("rclone remote not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to test rclone remote", err))
+		return
+	}
+
+	utils.RespondSuccess(w, result)
+}
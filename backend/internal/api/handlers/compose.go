@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 	"path/filepath"
 
@@ -234,3 +235,37 @@ func (h *ComposeHandler) GetComposeFile(w http.ResponseWriter, r *http.Request)
 
 	utils.RespondSuccess(w, content)
 }
+
+// DiffStackRequest is the body of stack diff requests
+type DiffStackRequest struct {
+	Compose string `json:"compose"`
+}
+
+// DiffStack shows what changes when a stack is redeployed with a new compose file
+func (h *ComposeHandler) DiffStack(w http.ResponseWriter, r *http.Request) {
+	stackName := chi.URLParam(r, "name")
+	stackPath := filepath.Join(h.stacksDir, stackName)
+
+	var req DiffStackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if req.Compose == "" {
+		utils.RespondError(w, errors.BadRequest("Compose content is required", nil))
+		return
+	}
+
+	diff, err := h.service.DiffStack(stackPath, req.Compose)
+	if err != nil {
+		if stderrors.Is(err, docker.ErrStackNotFound) {
+			utils.RespondError(w, errors.NotFound("Stack not found", err))
+			return
+		}
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, diff)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/metrics"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// ListCustomMetrics handles GET /api/v1/monitoring/custom-metrics
+func ListCustomMetrics(w http.ResponseWriter, r *http.Request) {
+	list, err := metrics.GetMetricEngine().ListCustomMetrics()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list custom metrics", err))
+		return
+	}
+
+	utils.RespondSuccess(w, list)
+}
+
+// CreateCustomMetric handles POST /api/v1/monitoring/custom-metrics
+func CreateCustomMetric(w http.ResponseWriter, r *http.Request) {
+	var req metrics.CustomMetric
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	metric, err := metrics.GetMetricEngine().CreateCustomMetric(req)
+	if err != nil {
+		if stderrors.Is(err, metrics.ErrCustomMetricExists) {
+			utils.RespondError(w, errors.Conflict("A custom metric with this name already exists", err))
+			return
+		}
+		logger.Error("Failed to create custom metric", zap.String("name", req.Name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to create custom metric", err))
+		return
+	}
+
+	utils.RespondCreated(w, metric)
+}
+
+// UpdateCustomMetric handles PUT /api/v1/monitoring/custom-metrics/{id}
+func UpdateCustomMetric(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req metrics.CustomMetric
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	metric, err := metrics.GetMetricEngine().UpdateCustomMetric(id, req)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, metrics.ErrCustomMetricNotFound):
+			utils.RespondError(w, errors.NotFound("Custom metric not found", err))
+		case stderrors.Is(err, metrics.ErrCustomMetricExists):
+			utils.RespondError(w, errors.Conflict("A custom metric with this name already exists", err))
+		default:
+			logger.Error("Failed to update custom metric", zap.String("id", id), zap.Error(err))
+			utils.RespondError(w, errors.InternalServerError("Failed to update custom metric", err))
+		}
+		return
+	}
+
+	utils.RespondSuccess(w, metric)
+}
+
+// DeleteCustomMetric handles DELETE /api/v1/monitoring/custom-metrics/{id}
+func DeleteCustomMetric(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := metrics.GetMetricEngine().DeleteCustomMetric(id); err != nil {
+		if stderrors.Is(err, metrics.ErrCustomMetricNotFound) {
+			utils.RespondError(w, errors.NotFound("Custom metric not found", err))
+			return
+		}
+		logger.Error("Failed to delete custom metric", zap.String("id", id), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to delete custom metric", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
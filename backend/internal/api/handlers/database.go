@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+// GetDatabasePoolStats returns connection pool statistics
+// GET /api/v1/admin/database/pool-stats
+func GetDatabasePoolStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := database.GetPoolStats()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get database pool stats", err))
+		return
+	}
+
+	utils.RespondSuccess(w, stats)
+}
+
+// GetCacheStats returns query cache statistics
+// GET /api/v1/admin/cache/stats
+func GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	utils.RespondSuccess(w, database.Cached().Stats())
+}
+
+// FlushCache empties the query cache
+// POST /api/v1/admin/cache/flush
+func FlushCache(w http.ResponseWriter, r *http.Request) {
+	database.Cached().Flush()
+	logger.Info("Query cache flushed")
+	utils.RespondSuccess(w, database.Cached().Stats())
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var dedupeScanner *filesystem.DeduplicationScanner
+
+// InitDeduplicationScanner initializes the duplicate file scanner
+func InitDeduplicationScanner(s *filesystem.DeduplicationScanner) {
+	dedupeScanner = s
+	logger.Info("Deduplication scanner initialized")
+}
+
+// DedupeScanRequest is the body of POST /files/dedup/scan
+type DedupeScanRequest struct {
+	Path string `json:"path"`
+	filesystem.ScanOptions
+}
+
+// StartDedupeScan starts a background scan for duplicate files below a directory
+// POST /api/v1/files/dedup/scan
+func StartDedupeScan(w http.ResponseWriter, r *http.Request) {
+	if dedupeScanner == nil {
+		utils.RespondError(w, errors.InternalServerError("Duplicate file scanning not available (install jdupes or fdupes)", nil))
+		return
+	}
+
+	var req DedupeScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.Path == "" {
+		utils.RespondError(w, errors.BadRequest("Missing path in request", nil))
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	// Linking replaces files, so it needs write access
+	if req.LinkDuplicates {
+		if err := fileService.CheckWritePermission(ctx, req.Path); err != nil {
+			utils.RespondError(w, err)
+			return
+		}
+	}
+	root, err := fileService.ResolvePath(ctx, req.Path)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	jobID, err := dedupeScanner.ScanAsync(root, req.ScanOptions)
+	if err != nil {
+		logger.Error("Failed to start dedupe scan", zap.String("path", req.Path), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to start duplicate scan: "+err.Error(), err))
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusAccepted, map[string]string{
+		"jobId": jobID,
+		"path":  req.Path,
+	})
+}
+
+// GetDedupeResults returns the progress of a duplicate scan, and its report once completed
+// GET /api/v1/files/dedup/results/{jobId}
+func GetDedupeResults(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "jobId")
+
+	job, err := filesystem.GetDedupeJob(id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			utils.RespondError(w, errors.NotFound("Dedupe job not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get dedupe job", err))
+		return
+	}
+
+	// The report lists file names, so it is only shown to users who can read the scanned directory
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+	if _, err := fileService.CheckReadPermission(ctx, job.Root); err != nil {
+		utils.RespondError(w, errors.NotFound("Dedupe job not found", err))
+		return
+	}
+
+	utils.RespondSuccess(w, job)
+}
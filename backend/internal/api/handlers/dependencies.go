@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/dependencies"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// DependencyHandler handles system package HTTP requests
+type DependencyHandler struct {
+	installer *dependencies.Installer
+}
+
+// NewDependencyHandler creates a new dependency handler
+func NewDependencyHandler() *DependencyHandler {
+	return &DependencyHandler{
+		installer: dependencies.NewInstaller(dependencies.CheckOnly),
+	}
+}
+
+// GetDependencyReport lists the system packages with their version and
+// install status
+func (h *DependencyHandler) GetDependencyReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.installer.GetReport()
+	if stderrors.Is(err, dependencies.ErrDpkgUnavailable) {
+		utils.RespondError(w, errors.NewAppError(http.StatusServiceUnavailable, err.Error(), err))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get dependency report", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to get dependency report", err))
+		return
+	}
+
+	utils.RespondSuccess(w, report)
+}
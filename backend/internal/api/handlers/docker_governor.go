@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/docker"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// ContainerPriorityRequest is the request body of SetContainerPriority
+type ContainerPriorityRequest struct {
+	Priority string `json:"priority"` // high or normal
+}
+
+// resourceGovernor returns the resource governor, responding with an error
+// if it is not running
+func resourceGovernor(w http.ResponseWriter) *docker.ResourceGovernor {
+	governor := docker.GetResourceGovernor()
+	if governor == nil {
+		utils.RespondError(w, errors.NewAppError(503, "Resource governor is not available", nil))
+	}
+	return governor
+}
+
+// GetResourceGovernorStatus returns the resource governor's configuration,
+// the host load it last saw and the containers it throttled
+func (h *DockerHandler) GetResourceGovernorStatus(w http.ResponseWriter, r *http.Request) {
+	governor := resourceGovernor(w)
+	if governor == nil {
+		return
+	}
+
+	status, err := governor.Status()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get resource governor status", err))
+		return
+	}
+
+	utils.RespondSuccess(w, status)
+}
+
+// UpdateResourceGovernorConfig updates the resource governor's thresholds
+func (h *DockerHandler) UpdateResourceGovernorConfig(w http.ResponseWriter, r *http.Request) {
+	governor := resourceGovernor(w)
+	if governor == nil {
+		return
+	}
+
+	var req docker.GovernorConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := docker.ValidateGovernorConfig(req); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	if err := governor.UpdateConfig(req); err != nil {
+		logger.Error("Failed to update resource governor config", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to update resource governor config", err))
+		return
+	}
+
+	status, err := governor.Status()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get resource governor status", err))
+		return
+	}
+	utils.RespondSuccess(w, status)
+}
+
+// SetContainerPriority sets the priority the resource governor gives a
+// container
+func (h *DockerHandler) SetContainerPriority(w http.ResponseWriter, r *http.Request) {
+	governor := resourceGovernor(w)
+	if governor == nil {
+		return
+	}
+
+	containerID := chi.URLParam(r, "id")
+	var req ContainerPriorityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if err := governor.SetContainerPriority(containerID, req.Priority); err != nil {
+		if stderrors.Is(err, docker.ErrInvalidPriority) {
+			utils.RespondError(w, errors.BadRequest(err.Error(), err))
+			return
+		}
+		logger.Error("Failed to set container priority", zap.Error(err), zap.String("container", containerID))
+		utils.RespondError(w, errors.InternalServerError("Failed to set container priority", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{"message": "Container priority updated successfully"})
+}
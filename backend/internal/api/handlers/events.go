@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// sseKeepAliveInterval is how often a comment line is sent to keep idle connections open
+const sseKeepAliveInterval = 15 * time.Second
+
+// SSEHandler streams NAS events (alerts, metrics, share and user changes, ...)
+// as Server-Sent Events. Clients that reconnect with a Last-Event-ID header
+// receive the buffered events they missed before live events resume.
+// Use it behind middleware.SSEMiddleware, which sets the stream headers.
+type SSEHandler struct {
+	bus       *events.Bus
+	keepAlive time.Duration
+}
+
+// NewSSEHandler creates an SSE handler for the given event bus
+func NewSSEHandler(bus *events.Bus) *SSEHandler {
+	return &SSEHandler{
+		bus:       bus,
+		keepAlive: sseKeepAliveInterval,
+	}
+}
+
+// ServeHTTP handles GET /api/v1/events/stream?type=alert,metrics&since=5m
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondError(w, errors.InternalServerError("Streaming not supported", nil))
+		return
+	}
+
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	// Tell clients how long to wait before reconnecting
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	sub := h.bus.Subscribe(filter)
+	defer sub.Close()
+
+	logger.Debug("Event stream client connected",
+		zap.String("remote_addr", r.RemoteAddr),
+		zap.Strings("types", filter.Types),
+		zap.Uint64("last_event_id", filter.AfterID))
+
+	keepAlive := time.NewTicker(h.keepAlive)
+	defer keepAlive.Stop()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Debug("Event stream client disconnected", zap.String("remote_addr", r.RemoteAddr))
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Error("Failed to encode event", zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// parseEventFilter builds an event filter from the request query parameters
+func parseEventFilter(r *http.Request) (events.Filter, error) {
+	var filter events.Filter
+	query := r.URL.Query()
+
+	for _, value := range query["type"] {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	// EventSource sends Last-Event-ID when reconnecting; the query parameter
+	// is for clients that cannot set headers
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("lastEventId")
+	}
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid Last-Event-ID %q", lastEventID)
+		}
+		filter.AfterID = id
+	}
+
+	if since := query.Get("since"); since != "" {
+		if d, err := time.ParseDuration(since); err == nil {
+			filter.Since = time.Now().UTC().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.Since = t
+		} else {
+			return filter, fmt.Errorf("invalid since value %q (use a duration like 5m or an RFC3339 timestamp)", since)
+		}
+	}
+
+	return filter, nil
+}
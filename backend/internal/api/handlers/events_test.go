@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.InitLogger("error", false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// flushRecorder is a ResponseWriter that records what has been flushed, so
+// the test can observe a stream while the handler is still running
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	flushed strings.Builder
+}
+
+func newFlushRecorder() *flushRecorder {
+	return &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (f *flushRecorder) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ResponseRecorder.Write(p)
+}
+
+func (f *flushRecorder) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushed.Write(f.ResponseRecorder.Body.Bytes())
+	f.ResponseRecorder.Body.Reset()
+}
+
+func (f *flushRecorder) Flushed() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flushed.String()
+}
+
+// stream starts the SSE handler and returns the recorder and a stop function
+func stream(t *testing.T, bus *events.Bus, lastEventID string) (*flushRecorder, func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/stream", nil).WithContext(ctx)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	rec := newFlushRecorder()
+
+	handler := middleware.SSEMiddleware(NewSSEHandler(bus))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(rec, req)
+	}()
+
+	waitFor(t, func() bool { return bus.SubscriberCount() == 1 })
+	return rec, func() {
+		cancel()
+		<-done
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSSEHandlerDeliversEvents(t *testing.T) {
+	bus := events.NewBus()
+	rec, stop := stream(t, bus, "")
+
+	bus.Publish(events.Event{Type: events.TypeAlert, Message: "disk failing"})
+	bus.Publish(events.Event{Type: events.TypeMetrics, Message: "metrics collected"})
+
+	waitFor(t, func() bool { return strings.Contains(rec.Flushed(), "id: 2\n") })
+	stop()
+
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	body := rec.Flushed()
+	for _, want := range []string{
+		"retry: 3000\n\n",
+		"id: 1\nevent: alert\ndata: {",
+		`"message":"disk failing"`,
+		"id: 2\nevent: metrics\ndata: {",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream missing %q\nstream:\n%s", want, body)
+		}
+	}
+}
+
+func TestSSEHandlerResumesFromLastEventID(t *testing.T) {
+	bus := events.NewBus()
+	for _, msg := range []string{"first", "second", "third"} {
+		bus.Publish(events.Event{Type: events.TypeShare, Message: msg})
+	}
+
+	// The client saw event 1 before it was disconnected
+	rec, stop := stream(t, bus, "1")
+	bus.Publish(events.Event{Type: events.TypeUser, Message: "fourth"})
+
+	waitFor(t, func() bool { return strings.Contains(rec.Flushed(), "id: 4\n") })
+	stop()
+
+	body := rec.Flushed()
+	if strings.Contains(body, "id: 1\n") {
+		t.Errorf("event 1 was replayed although the client already had it")
+	}
+	second := strings.Index(body, "id: 2\n")
+	third := strings.Index(body, "id: 3\n")
+	fourth := strings.Index(body, "id: 4\n")
+	if second < 0 || third < 0 || !(second < third && third < fourth) {
+		t.Errorf("missed events not replayed in order\nstream:\n%s", body)
+	}
+}
+
+func TestSSEHandlerRejectsInvalidLastEventID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rec := newFlushRecorder()
+
+	NewSSEHandler(events.NewBus()).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/auth"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
@@ -115,3 +117,94 @@ func (h *FailedLoginHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondSuccess(w, stats)
 }
+
+// LockoutPolicy is the request and response body of the lockout policy endpoints
+type LockoutPolicy struct {
+	MaxAttempts       int      `json:"maxAttempts"`    // Failed attempts before a lockout
+	WindowMinutes     int      `json:"windowMinutes"`  // Time window for counting attempts
+	LockoutMinutes    int      `json:"lockoutMinutes"` // How long a lockout lasts
+	LockoutByIP       bool     `json:"lockoutByIP"`
+	LockoutByUsername bool     `json:"lockoutByUsername"`
+	WhitelistIPs      []string `json:"whitelistIPs"` // IPs and CIDR ranges that are never locked out by IP
+}
+
+func lockoutPolicyResponse(policy models.FailedLoginPolicy) LockoutPolicy {
+	return LockoutPolicy{
+		MaxAttempts:       policy.MaxAttempts,
+		WindowMinutes:     policy.WindowMinutes,
+		LockoutMinutes:    int(policy.LockoutDuration / time.Minute),
+		LockoutByIP:       policy.LockoutByIP,
+		LockoutByUsername: policy.LockoutByUsername,
+		WhitelistIPs:      policy.WhitelistIPs,
+	}
+}
+
+// GetLockoutPolicy returns the failed login lockout policy
+func (h *FailedLoginHandler) GetLockoutPolicy(w http.ResponseWriter, r *http.Request) {
+	utils.RespondSuccess(w, lockoutPolicyResponse(h.service.GetPolicy()))
+}
+
+// UpdateLockoutPolicy replaces the failed login lockout policy
+func (h *FailedLoginHandler) UpdateLockoutPolicy(w http.ResponseWriter, r *http.Request) {
+	var req LockoutPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	policy, err := h.service.UpdatePolicy(r.Context(), models.FailedLoginPolicy{
+		MaxAttempts:       req.MaxAttempts,
+		WindowMinutes:     req.WindowMinutes,
+		LockoutDuration:   time.Duration(req.LockoutMinutes) * time.Minute,
+		LockoutByIP:       req.LockoutByIP,
+		LockoutByUsername: req.LockoutByUsername,
+		WhitelistIPs:      req.WhitelistIPs,
+	})
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, lockoutPolicyResponse(policy))
+}
+
+// GetLockedAccounts retrieves all currently locked usernames
+func (h *FailedLoginHandler) GetLockedAccounts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.service.GetLockedAccounts()
+	if err != nil {
+		logger.Error("Failed to retrieve locked accounts", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to retrieve locked accounts", err))
+		return
+	}
+
+	utils.RespondSuccess(w, lockouts)
+}
+
+// UnlockAccount removes the lockout of a username
+func (h *FailedLoginHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var req UnlockAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if req.Username == "" {
+		utils.RespondError(w, errors.BadRequest("Username is required", nil))
+		return
+	}
+
+	if err := h.service.UnlockAccount(r.Context(), req.Username); err != nil {
+		logger.Error("Failed to unlock account", zap.Error(err), zap.String("username", req.Username))
+		utils.RespondError(w, errors.InternalServerError("Failed to unlock account", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Account unlocked successfully",
+	})
+}
+
+// UnlockAccountRequest is the request body of UnlockAccount
+type UnlockAccountRequest struct {
+	Username string `json:"username"`
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchTimeout    = 30 * time.Second
+	defaultSearchMaxResults = 500
+	maxSearchMaxResults     = 5000
+	maxSearchContextLines   = 10
+)
+
+// ErrSearchTimeout is returned when a content search exceeds its timeout
+var ErrSearchTimeout = stderrors.New("search timed out")
+
+// SearchOptions controls a file content search
+type SearchOptions struct {
+	CaseSensitive bool
+	UseRegex      bool   // Treat the query as a regular expression instead of a literal string
+	MaxResults    int    // 0 = default of 500
+	FilePattern   string // Glob matched against file names, e.g. "*.txt"
+	IncludeBinary bool
+	ContextLines  int           // Lines of context before and after each match
+	Timeout       time.Duration // 0 = default of 30s
+}
+
+// SearchMatch is a single line matching a content search
+type SearchMatch struct {
+	File        string   `json:"file"`
+	Line        int      `json:"line"`
+	Column      int      `json:"column"` // 1-based byte offset of the first match in the line
+	MatchedLine string   `json:"matchedLine"`
+	Context     []string `json:"context,omitempty"`
+}
+
+// SearchResponse is returned by the file search endpoint
+type SearchResponse struct {
+	Root      string        `json:"root"`
+	Query     string        `json:"query"`
+	Matches   []SearchMatch `json:"matches"`
+	Truncated bool          `json:"truncated"` // More matches exist than MaxResults
+}
+
+// searchLine is a matching or context line reported by rg or grep
+type searchLine struct {
+	file    string
+	line    int
+	column  int
+	text    string
+	isMatch bool
+}
+
+// SearchFileContent searches the files below root for query. It uses
+// ripgrep when installed and falls back to grep. Symlinks are not followed.
+func SearchFileContent(root, query string, opts SearchOptions) ([]SearchMatch, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = defaultSearchMaxResults
+	}
+	if opts.ContextLines < 0 {
+		opts.ContextLines = 0
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultSearchTimeout
+	}
+
+	if rg, err := sysutil.RequireCommand("rg"); err == nil {
+		return runSearch(rg, ripgrepArgs(root, query, opts), parseRipgrepOutput, query, opts)
+	}
+	return runSearch("grep", grepArgs(root, query, opts), parseGrepOutput, query, opts)
+}
+
+// ripgrepArgs builds the rg command line
+func ripgrepArgs(root, query string, opts SearchOptions) []string {
+	args := []string{"--json", "--hidden", "--no-ignore", "--no-config"}
+	if !opts.CaseSensitive {
+		args = append(args, "--ignore-case")
+	}
+	if !opts.UseRegex {
+		args = append(args, "--fixed-strings")
+	}
+	if opts.FilePattern != "" {
+		args = append(args, "--glob", opts.FilePattern)
+	}
+	if opts.IncludeBinary {
+		args = append(args, "--text")
+	}
+	if opts.ContextLines > 0 {
+		args = append(args, "--context", strconv.Itoa(opts.ContextLines))
+	}
+	return append(args, "--regexp", query, "--", root)
+}
+
+// grepArgs builds the grep command line. -Z terminates file names with a
+// NUL byte so names containing ':' or '-' parse correctly.
+func grepArgs(root, query string, opts SearchOptions) []string {
+	args := []string{"-r", "-n", "-H", "-Z", "--color=never"}
+	if !opts.CaseSensitive {
+		args = append(args, "-i")
+	}
+	if opts.UseRegex {
+		args = append(args, "-E")
+	} else {
+		args = append(args, "-F")
+	}
+	if opts.FilePattern != "" {
+		args = append(args, "--include="+opts.FilePattern)
+	}
+	if opts.IncludeBinary {
+		args = append(args, "-a")
+	} else {
+		args = append(args, "-I")
+	}
+	if opts.ContextLines > 0 {
+		args = append(args, "-C", strconv.Itoa(opts.ContextLines))
+	}
+	return append(args, "-e", query, "--", root)
+}
+
+// runSearch runs a search command and collects up to opts.MaxResults matches.
+// The command is killed once enough matches have been read.
+func runSearch(name string, args []string, parse func(io.Reader, func(searchLine) bool) error, query string, opts SearchOptions) ([]SearchMatch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	var lines []searchLine
+	matches := 0
+	parseErr := parse(stdout, func(l searchLine) bool {
+		if l.isMatch {
+			// Keep reading past the last match for its trailing context
+			if matches == opts.MaxResults {
+				cancel()
+				return false
+			}
+			matches++
+		}
+		lines = append(lines, l)
+		return true
+	})
+	io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, ErrSearchTimeout
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse %s output: %w", name, parseErr)
+	}
+
+	// Exit code 1 means no matches; 2 means errors such as unreadable
+	// files, which are not fatal as long as something was searched
+	var exitErr *exec.ExitError
+	if waitErr != nil && matches < opts.MaxResults && stderrors.As(waitErr, &exitErr) && exitErr.ExitCode() >= 2 {
+		if matches == 0 {
+			return nil, fmt.Errorf("%s failed: %s", name, strings.TrimSpace(stderr.String()))
+		}
+		logger.Warn("File search reported errors", zap.String("command", name), zap.String("stderr", strings.TrimSpace(stderr.String())))
+	}
+
+	return buildMatches(lines, query, opts), nil
+}
+
+// buildMatches attaches context lines to matches and fills in missing columns
+func buildMatches(lines []searchLine, query string, opts SearchOptions) []SearchMatch {
+	columnPattern := query
+	if !opts.UseRegex {
+		columnPattern = regexp.QuoteMeta(query)
+	}
+	if !opts.CaseSensitive {
+		columnPattern = "(?i)" + columnPattern
+	}
+	columnRe, _ := regexp.Compile(columnPattern)
+
+	matches := []SearchMatch{}
+	for i, l := range lines {
+		if !l.isMatch {
+			continue
+		}
+
+		match := SearchMatch{File: l.file, Line: l.line, Column: l.column, MatchedLine: l.text}
+		if match.Column == 0 && columnRe != nil {
+			if loc := columnRe.FindStringIndex(l.text); loc != nil {
+				match.Column = loc[0] + 1
+			}
+		}
+
+		if opts.ContextLines > 0 {
+			for j := i - 1; j >= 0 && lines[j].file == l.file && lines[j].line >= l.line-opts.ContextLines; j-- {
+				match.Context = append([]string{lines[j].text}, match.Context...)
+			}
+			for j := i + 1; j < len(lines) && lines[j].file == l.file && lines[j].line <= l.line+opts.ContextLines; j++ {
+				match.Context = append(match.Context, lines[j].text)
+			}
+		}
+
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// ripgrepMessage is one line of `rg --json` output
+type ripgrepMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Path       struct{ Text string } `json:"path"`
+		Lines      struct{ Text string } `json:"lines"`
+		LineNumber int                   `json:"line_number"`
+		Submatches []struct {
+			Start int `json:"start"`
+		} `json:"submatches"`
+	} `json:"data"`
+}
+
+// parseRipgrepOutput parses `rg --json` output, calling emit for each match
+// and context line until it returns false
+func parseRipgrepOutput(r io.Reader, emit func(searchLine) bool) error {
+	reader := bufio.NewReader(r)
+	for {
+		raw, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(raw)) > 0 {
+			var msg ripgrepMessage
+			if jsonErr := json.Unmarshal(raw, &msg); jsonErr != nil {
+				return jsonErr
+			}
+
+			// Paths and lines that are not valid UTF-8 are sent base64
+			// encoded in a "bytes" field and are skipped
+			if (msg.Type == "match" || msg.Type == "context") && msg.Data.Path.Text != "" {
+				l := searchLine{
+					file:    msg.Data.Path.Text,
+					line:    msg.Data.LineNumber,
+					text:    strings.TrimRight(msg.Data.Lines.Text, "\r\n"),
+					isMatch: msg.Type == "match",
+				}
+				if len(msg.Data.Submatches) > 0 {
+					l.column = msg.Data.Submatches[0].Start + 1
+				}
+				if !emit(l) {
+					return nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseGrepOutput parses `grep -n -H -Z` output: "file\0line:text" for
+// matches, "file\0line-text" for context lines and "--" between groups
+func parseGrepOutput(r io.Reader, emit func(searchLine) bool) error {
+	reader := bufio.NewReader(r)
+	for {
+		raw, err := reader.ReadString('\n')
+		if line := strings.TrimRight(raw, "\r\n"); line != "" && line != "--" {
+			if l, ok := parseGrepLine(line); ok && !emit(l) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseGrepLine parses a single grep output line
+func parseGrepLine(line string) (searchLine, bool) {
+	file, rest, ok := strings.Cut(line, "\x00")
+	if !ok {
+		return searchLine{}, false
+	}
+
+	i := 0
+	for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	if i == 0 || i == len(rest) || (rest[i] != ':' && rest[i] != '-') {
+		return searchLine{}, false
+	}
+
+	lineNum, _ := strconv.Atoi(rest[:i])
+	return searchLine{
+		file:    file,
+		line:    lineNum,
+		text:    rest[i+1:],
+		isMatch: rest[i] == ':',
+	}, true
+}
+
+// SearchFiles searches file contents below a directory
+// GET /api/v1/files/search?root=/path&q=text&caseSensitive=false&regex=false&maxResults=500&filePattern=*.txt&includeBinary=false&context=2
+func SearchFiles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	root := q.Get("root")
+	query := q.Get("q")
+	if root == "" || query == "" {
+		utils.RespondError(w, errors.BadRequest("Missing root or q parameter", nil))
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	// Resolve symlinks so a link inside the share can't point the search elsewhere
+	searchRoot, err := fileService.ResolvePath(ctx, root)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	opts := SearchOptions{
+		CaseSensitive: q.Get("caseSensitive") == "true",
+		UseRegex:      q.Get("regex") == "true",
+		FilePattern:   q.Get("filePattern"),
+		IncludeBinary: q.Get("includeBinary") == "true",
+		MaxResults:    defaultSearchMaxResults,
+	}
+	if cfg := config.GlobalConfig; cfg != nil {
+		opts.Timeout = cfg.Server.FileSearchTimeout
+	}
+	if v := q.Get("maxResults"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchMaxResults {
+			utils.RespondError(w, errors.BadRequest(fmt.Sprintf("maxResults must be between 1 and %d", maxSearchMaxResults), err))
+			return
+		}
+		opts.MaxResults = n
+	}
+	if v := q.Get("context"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSearchContextLines {
+			utils.RespondError(w, errors.BadRequest(fmt.Sprintf("context must be between 0 and %d", maxSearchContextLines), err))
+			return
+		}
+		opts.ContextLines = n
+	}
+
+	// Ask for one extra match to tell whether results were cut off
+	limit := opts.MaxResults
+	opts.MaxResults++
+	matches, err := SearchFileContent(searchRoot, query, opts)
+	if err != nil {
+		if stderrors.Is(err, ErrSearchTimeout) {
+			utils.RespondError(w, errors.NewAppError(http.StatusGatewayTimeout, "Search timed out, try a narrower root or query", err))
+			return
+		}
+		logger.Error("File search failed", zap.String("root", searchRoot), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("File search failed", err))
+		return
+	}
+
+	response := SearchResponse{Root: searchRoot, Query: query, Matches: matches}
+	if len(matches) > limit {
+		response.Matches = matches[:limit]
+		response.Truncated = true
+	}
+	utils.RespondSuccess(w, response)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeSearchTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"notes.txt":           "first line\nthe Needle is here\nlast line\n",
+		"docs/report-v1:2.md": "alpha\nbeta\nneedle and needle\ngamma\n",
+		"docs/other.log":      "nothing to see\n",
+		"bin/blob.dat":        "\x00\x01needle\x00",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Matches behind a symlink must not be reported
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("needle\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestSearchFileContent(t *testing.T) {
+	root := writeSearchTree(t)
+
+	search := func(t *testing.T, query string, opts SearchOptions) []SearchMatch {
+		t.Helper()
+		matches, err := SearchFileContent(root, query, opts)
+		if err != nil {
+			t.Fatalf("SearchFileContent() error = %v", err)
+		}
+		for i := range matches {
+			matches[i].File = strings.TrimPrefix(matches[i].File, root+"/")
+		}
+		return matches
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		opts  SearchOptions
+		want  []SearchMatch
+	}{
+		{
+			name:  "case insensitive with context",
+			query: "needle",
+			opts:  SearchOptions{ContextLines: 1, FilePattern: "*.txt"},
+			want:  []SearchMatch{{File: "notes.txt", Line: 2, Column: 5, MatchedLine: "the Needle is here", Context: []string{"first line", "last line"}}},
+		},
+		{
+			name:  "case sensitive",
+			query: "Needle",
+			opts:  SearchOptions{CaseSensitive: true},
+			want:  []SearchMatch{{File: "notes.txt", Line: 2, Column: 5, MatchedLine: "the Needle is here"}},
+		},
+		{
+			name:  "file name with separators",
+			query: "needle",
+			opts:  SearchOptions{FilePattern: "*.md"},
+			want:  []SearchMatch{{File: "docs/report-v1:2.md", Line: 3, Column: 1, MatchedLine: "needle and needle"}},
+		},
+		{
+			name:  "regex",
+			query: "^b[a-z]+a$",
+			opts:  SearchOptions{UseRegex: true},
+			want:  []SearchMatch{{File: "docs/report-v1:2.md", Line: 2, Column: 1, MatchedLine: "beta"}},
+		},
+		{
+			name:  "literal query is not a regex",
+			query: "^b[a-z]+a$",
+			opts:  SearchOptions{},
+			want:  []SearchMatch{},
+		},
+		{
+			name:  "binary",
+			query: "needle",
+			opts:  SearchOptions{IncludeBinary: true, FilePattern: "*.dat"},
+			want:  []SearchMatch{{File: "bin/blob.dat", Line: 1, Column: 3, MatchedLine: "\x00\x01needle\x00"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := search(t, tt.query, tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matches = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("skips binary files and symlinks by default", func(t *testing.T) {
+		got := search(t, "needle", SearchOptions{})
+		if len(got) != 2 {
+			t.Fatalf("got %d matches, want 2: %+v", len(got), got)
+		}
+		for _, m := range got {
+			if strings.HasPrefix(m.File, "link/") || strings.HasPrefix(m.File, "bin/") {
+				t.Errorf("unexpected match in %s", m.File)
+			}
+		}
+	})
+
+	t.Run("max results", func(t *testing.T) {
+		if got := search(t, "needle", SearchOptions{MaxResults: 1}); len(got) != 1 {
+			t.Errorf("got %d matches, want 1", len(got))
+		}
+	})
+}
+
+func TestParseRipgrepOutput(t *testing.T) {
+	output := `{"type":"begin","data":{"path":{"text":"/srv/share/notes.txt"}}}
+{"type":"context","data":{"path":{"text":"/srv/share/notes.txt"},"lines":{"text":"first line\n"},"line_number":1,"absolute_offset":0,"submatches":[]}}
+{"type":"match","data":{"path":{"text":"/srv/share/notes.txt"},"lines":{"text":"the Needle is here\n"},"line_number":2,"absolute_offset":11,"submatches":[{"match":{"text":"Needle"},"start":4,"end":10}]}}
+{"type":"context","data":{"path":{"text":"/srv/share/notes.txt"},"lines":{"text":"last line\n"},"line_number":3,"absolute_offset":30,"submatches":[]}}
+{"type":"match","data":{"path":{"bytes":"L3Nydi9zaGFyZS//"},"lines":{"text":"needle\n"},"line_number":1,"absolute_offset":0,"submatches":[{"match":{"text":"needle"},"start":0,"end":6}]}}
+{"type":"end","data":{"path":{"text":"/srv/share/notes.txt"}}}
+{"type":"summary","data":{}}
+`
+	var lines []searchLine
+	if err := parseRipgrepOutput(strings.NewReader(output), func(l searchLine) bool {
+		lines = append(lines, l)
+		return true
+	}); err != nil {
+		t.Fatalf("parseRipgrepOutput() error = %v", err)
+	}
+
+	got := buildMatches(lines, "needle", SearchOptions{ContextLines: 1})
+	want := []SearchMatch{{
+		File:        "/srv/share/notes.txt",
+		Line:        2,
+		Column:      5,
+		MatchedLine: "the Needle is here",
+		Context:     []string{"first line", "last line"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("matches = %+v, want %+v", got, want)
+	}
+}
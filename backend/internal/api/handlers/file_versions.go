@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/files"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/files/versioning"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var versionStore *versioning.VersionStore
+
+// InitVersionStore enables file version history
+func InitVersionStore(store *versioning.VersionStore) {
+	versionStore = store
+	logger.Info("File versioning enabled", zap.String("backend", store.Config().Backend))
+}
+
+// RestoreVersionRequest represents a request to restore a file version
+type RestoreVersionRequest struct {
+	Path string `json:"path"`
+}
+
+// ListFileVersions lists the stored versions of a file
+func ListFileVersions(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.RespondError(w, errors.BadRequest("Missing path parameter", nil))
+		return
+	}
+
+	if versionStore == nil {
+		utils.RespondError(w, errors.InternalServerError("File versioning not enabled", nil))
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	resolved, err := fileService.ResolvePath(ctx, path)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	versions, err := versionStore.ListVersions(resolved)
+	if err != nil {
+		logger.Error("Failed to list file versions", zap.String("path", resolved), zap.Error(err))
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, versions)
+}
+
+// RestoreFileVersion replaces a file with one of its versions
+func RestoreFileVersion(w http.ResponseWriter, r *http.Request) {
+	versionID := chi.URLParam(r, "id")
+
+	var req RestoreVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+	if req.Path == "" {
+		utils.RespondError(w, errors.BadRequest("Path is required", nil))
+		return
+	}
+
+	if versionStore == nil {
+		utils.RespondError(w, errors.InternalServerError("File versioning not enabled", nil))
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	if err := fileService.CheckWritePermission(ctx, req.Path); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+	resolved, err := fileService.ResolvePath(ctx, req.Path)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	if err := versionStore.RestoreVersion(resolved, versionID); err != nil {
+		logger.Error("Failed to restore file version", zap.String("path", resolved), zap.String("version", versionID), zap.Error(err))
+		utils.RespondError(w, err)
+		return
+	}
+
+	logger.Info("File version restored", zap.String("path", resolved), zap.String("version", versionID), zap.String("user", ctx.User.Username))
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Version restored successfully",
+	})
+}
+
+// keepVersion stores the current content of path as a version before it is
+// overwritten. Does nothing if versioning is disabled or path is not an
+// existing regular file.
+func keepVersion(ctx *files.SecurityContext, path string) error {
+	if versionStore == nil {
+		return nil
+	}
+
+	if err := fileService.CheckWritePermission(ctx, path); err != nil {
+		return err
+	}
+	resolved, err := fileService.ResolvePath(ctx, path)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	if info, err := os.Lstat(resolved); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	if _, err := versionStore.CreateVersion(resolved); err != nil {
+		logger.Error("Failed to keep file version", zap.String("path", resolved), zap.Error(err))
+		return errors.InternalServerError("Failed to keep the previous version of the file", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,9 +14,12 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/audit"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/files"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
@@ -23,15 +27,16 @@ import (
 )
 
 var (
-	fileService   *files.Service
-	uploadManager *files.UploadManager
+	fileService      *files.Service
+	uploadManager    *files.UploadManager
+	thumbnailService *files.ThumbnailService
 )
 
 // InitFileService initializes the file service with allowed paths from shares
 func InitFileService() error {
 	// Get all shares to determine allowed paths
-	var shares []*models.Share
-	if err := database.DB.Find(&shares).Error; err != nil {
+	shares, err := loadShares()
+	if err != nil {
 		return err
 	}
 
@@ -47,8 +52,23 @@ func InitFileService() error {
 	// Initialize file service
 	fileService = files.NewService(allowedPaths, permChecker)
 
-	// Initialize upload manager
-	uploadManager = files.NewUploadManager("/tmp/stumpfworks-uploads")
+	// Initialize upload manager and resume uploads interrupted by a restart
+	uploadManager = files.NewUploadManager("/var/lib/stumpfworks-nas/uploads", database.GetDB())
+	if _, err := uploadManager.RestoreSessions(); err != nil {
+		logger.Warn("Failed to restore upload sessions", zap.Error(err))
+	}
+	uploadManager.StartCleanup(time.Hour)
+
+	// Thumbnails are optional; browsing works without them
+	cacheDir := "/var/cache/stumpfworks-nas/thumbnails"
+	if cfg := config.GlobalConfig; cfg != nil && cfg.Server.ThumbnailCacheDir != "" {
+		cacheDir = cfg.Server.ThumbnailCacheDir
+	}
+	if thumbnailService, err = files.NewThumbnailService(cacheDir); err != nil {
+		logger.Warn("Thumbnails disabled", zap.Error(err))
+	} else {
+		thumbnailService.StartCleanup(time.Hour)
+	}
 
 	logger.Info("File service initialized", zap.Int("allowedPaths", len(allowedPaths)))
 	return nil
@@ -62,9 +82,9 @@ func getSecurityContext(r *http.Request) (*files.SecurityContext, error) {
 		return nil, errors.Unauthorized("User not authenticated", nil)
 	}
 
-	// Get all shares
-	var shares []*models.Share
-	if err := database.DB.Find(&shares).Error; err != nil {
+	// Get all shares (cached, this runs on every file request)
+	shares, err := loadShares()
+	if err != nil {
 		return nil, errors.InternalServerError("Failed to load shares", err)
 	}
 
@@ -78,6 +98,19 @@ func getSecurityContext(r *http.Request) (*files.SecurityContext, error) {
 	}, nil
 }
 
+// loadShares returns all shares from the query cache
+func loadShares() ([]*models.Share, error) {
+	list, err := database.Cached().ListShares()
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]*models.Share, len(list))
+	for i := range list {
+		shares[i] = &list[i]
+	}
+	return shares, nil
+}
+
 // buildSharesRootResponse creates a virtual directory listing showing all available shares
 func buildSharesRootResponse(ctx *files.SecurityContext) *files.BrowseResponse {
 	var virtualFiles []files.FileInfo
@@ -183,6 +216,64 @@ func GetFileInfo(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, info)
 }
 
+// GetThumbnail returns a JPEG thumbnail of an image or video
+// GET /api/v1/files/thumbnail?path=/path/to/image.jpg&size=128
+func GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		utils.RespondError(w, errors.BadRequest("Missing path parameter", nil))
+		return
+	}
+
+	size := files.DefaultThumbnailSize
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < files.MinThumbnailSize || n > files.MaxThumbnailSize {
+			utils.RespondError(w, errors.BadRequest(
+				fmt.Sprintf("size must be between %d and %d", files.MinThumbnailSize, files.MaxThumbnailSize), err))
+			return
+		}
+		size = n
+	}
+
+	if thumbnailService == nil {
+		utils.RespondError(w, errors.InternalServerError("Thumbnails not available", nil))
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	cleanPath, err := fileService.CheckReadPermission(ctx, path)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	thumb, err := thumbnailService.Get(cleanPath, size)
+	if err != nil {
+		logger.Warn("Failed to generate thumbnail", zap.String("path", cleanPath), zap.Error(err))
+		utils.RespondError(w, err)
+		return
+	}
+
+	f, err := os.Open(thumb.Path)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to read thumbnail", err))
+		return
+	}
+	defer f.Close()
+
+	// ServeContent answers If-None-Match with 304 using the ETag header
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("ETag", thumb.ETag)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", thumb.ModTime, f)
+}
+
 // ===== File Upload Handlers =====
 
 // UploadFile handles file uploads (simple single-file upload)
@@ -214,6 +305,11 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := checkShareFileFilter(destPath, header.Filename, header.Size); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
 	// Upload file
 	if err := fileService.UploadSingleFile(ctx, destPath, file, header); err != nil {
 		logger.Error("Failed to upload file", zap.String("filename", header.Filename), zap.Error(err))
@@ -227,6 +323,27 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// checkShareFileFilter checks an upload into dir against the file filter of
+// the share containing dir
+func checkShareFileFilter(dir, filename string, size int64) error {
+	shareName, ok := storage.ShareNameForPath(dir)
+	if !ok {
+		return nil
+	}
+
+	err := storage.ValidateFileAccess(shareName, filename, size)
+	switch {
+	case err == nil:
+		return nil
+	case stderrors.Is(err, storage.ErrFileTooLarge):
+		return errors.PayloadTooLarge(err.Error(), err)
+	case stderrors.Is(err, storage.ErrFileTypeBlocked):
+		return errors.Forbidden(err.Error(), err)
+	default:
+		return errors.InternalServerError("Failed to check the share's file filter", err)
+	}
+}
+
 // StartChunkedUpload starts a chunked upload session
 func StartChunkedUpload(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -239,7 +356,13 @@ func StartChunkedUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := uploadManager.StartUploadSession(req.FileName, req.TotalSize)
+	user := mw.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.RespondError(w, errors.Unauthorized("User not authenticated", nil))
+		return
+	}
+
+	session, err := uploadManager.StartUploadSession(req.FileName, req.TotalSize, user.ID)
 	if err != nil {
 		logger.Error("Failed to start upload session", zap.String("fileName", req.FileName), zap.Error(err))
 		utils.RespondError(w, err)
@@ -260,6 +383,11 @@ func UploadChunk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := ownUploadSession(r, sessionID); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
 	// Read chunk data from request body
 	if err := uploadManager.UploadChunk(sessionID, chunkIndex, r.Body); err != nil {
 		logger.Error("Failed to upload chunk", zap.String("sessionID", sessionID), zap.Int("chunkIndex", chunkIndex), zap.Error(err))
@@ -290,6 +418,12 @@ func FinalizeUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	session, err := ownUploadSession(r, req.SessionID)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
 	// Check write permissions on destination directory BEFORE finalizing
 	// This prevents users from uploading chunks to paths they don't have access to
 	destPath := req.DestinationPath
@@ -311,6 +445,17 @@ func FinalizeUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := checkShareFileFilter(filepath.Dir(destPath), filepath.Base(destPath), session.TotalSize); err != nil {
+		uploadManager.CancelUpload(req.SessionID)
+		utils.RespondError(w, err)
+		return
+	}
+
+	if err := keepVersion(ctx, destPath); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
 	if err := uploadManager.FinalizeUpload(req.SessionID, req.DestinationPath); err != nil {
 		logger.Error("Failed to finalize upload", zap.String("sessionID", req.SessionID), zap.Error(err))
 		utils.RespondError(w, err)
@@ -328,6 +473,11 @@ func FinalizeUpload(w http.ResponseWriter, r *http.Request) {
 func CancelUpload(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionId")
 
+	if _, err := ownUploadSession(r, sessionID); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
 	if err := uploadManager.CancelUpload(sessionID); err != nil {
 		logger.Error("Failed to cancel upload", zap.String("sessionID", sessionID), zap.Error(err))
 		utils.RespondError(w, err)
@@ -343,7 +493,7 @@ func CancelUpload(w http.ResponseWriter, r *http.Request) {
 func GetUploadSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionId")
 
-	session, err := uploadManager.GetUploadSession(sessionID)
+	session, err := ownUploadSession(r, sessionID)
 	if err != nil {
 		utils.RespondError(w, err)
 		return
@@ -352,6 +502,55 @@ func GetUploadSession(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, session)
 }
 
+// GetUploadProgress returns the progress of an upload session, including
+// sessions restored after a restart and recently finished ones
+func GetUploadProgress(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+
+	user := mw.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.RespondError(w, errors.Unauthorized("User not authenticated", nil))
+		return
+	}
+
+	progress, err := uploadManager.GetUploadProgress(sessionID)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	// Don't reveal other users' uploads
+	if !canAccessUpload(user, progress.UserID) {
+		utils.RespondError(w, errors.NotFound("Upload session not found", nil))
+		return
+	}
+
+	utils.RespondSuccess(w, progress)
+}
+
+// ownUploadSession returns an active upload session of the current user.
+// Other users' sessions are reported as not found, like by
+// GetUploadProgress, so they cannot be written to, finalized or cancelled.
+func ownUploadSession(r *http.Request, sessionID string) (*files.UploadSession, error) {
+	user := mw.GetUserFromContext(r.Context())
+	if user == nil {
+		return nil, errors.Unauthorized("User not authenticated", nil)
+	}
+	session, err := uploadManager.GetUploadSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessUpload(user, session.UserID) {
+		return nil, errors.NotFound("Upload session not found", nil)
+	}
+	return session, nil
+}
+
+// canAccessUpload reports whether user may access an upload of ownerID
+func canAccessUpload(user *models.User, ownerID uint) bool {
+	return ownerID == user.ID || user.IsAdmin()
+}
+
 // ===== File Download Handler =====
 
 // DownloadFile handles file downloads
@@ -483,6 +682,48 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// BulkRenameFiles renames the files in a directory matching a regular
+// expression. Each rename is recorded in the audit log.
+func BulkRenameFiles(w http.ResponseWriter, r *http.Request) {
+	var req files.BulkRenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	userID := ctx.User.ID
+	result, err := fileService.BulkRename(ctx, &req, func(before, after string) {
+		_ = audit.GetService().Log(r.Context(), &audit.LogEntry{
+			UserID:    &userID,
+			Username:  ctx.User.Username,
+			Action:    models.ActionFileRename,
+			Resource:  before,
+			Status:    models.StatusSuccess,
+			Severity:  models.SeverityInfo,
+			UserAgent: r.UserAgent(),
+			Message:   fmt.Sprintf("Renamed %s to %s", filepath.Base(before), filepath.Base(after)),
+			Details:   map[string]interface{}{"from": before, "to": after, "bulk": true},
+		})
+	})
+	if err != nil {
+		logger.Error("Failed to bulk rename files", zap.String("dir", req.Dir), zap.String("pattern", req.Pattern), zap.Error(err))
+		utils.RespondError(w, err)
+		return
+	}
+
+	if len(result.Conflicts) > 0 && !req.DryRun {
+		utils.RespondJSON(w, http.StatusConflict, result)
+		return
+	}
+	utils.RespondSuccess(w, result)
+}
+
 // CopyFiles copies files or directories
 func CopyFiles(w http.ResponseWriter, r *http.Request) {
 	var req files.CopyMoveRequest
@@ -497,6 +738,13 @@ func CopyFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Overwrite {
+		if err := keepVersion(ctx, req.Destination); err != nil {
+			utils.RespondError(w, err)
+			return
+		}
+	}
+
 	if err := fileService.Copy(ctx, &req); err != nil {
 		logger.Error("Failed to copy files", zap.String("source", req.Source), zap.String("destination", req.Destination), zap.Error(err))
 		utils.RespondError(w, err)
@@ -522,6 +770,13 @@ func MoveFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Overwrite {
+		if err := keepVersion(ctx, req.Destination); err != nil {
+			utils.RespondError(w, err)
+			return
+		}
+	}
+
 	if err := fileService.Move(ctx, &req); err != nil {
 		logger.Error("Failed to move files", zap.String("source", req.Source), zap.String("destination", req.Destination), zap.Error(err))
 		utils.RespondError(w, err)
@@ -610,9 +865,12 @@ func GetDiskUsage(w http.ResponseWriter, r *http.Request) {
 
 // ===== Archive Handlers =====
 
-// CreateArchive creates a compressed archive
+// CreateArchive creates a compressed archive. Large archives are created in
+// the background: the response is then 202 with a job ID, and progress is
+// streamed as "archive" events on /api/v1/events/stream.
+// POST /api/v1/files/archive/create
 func CreateArchive(w http.ResponseWriter, r *http.Request) {
-	var req files.ArchiveRequest
+	var req files.CreateArchiveRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.RespondError(w, errors.BadRequest("Invalid request", err))
 		return
@@ -624,15 +882,18 @@ func CreateArchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := fileService.CreateArchive(ctx, &req); err != nil {
+	result, err := fileService.CreateArchive(ctx, &req)
+	if err != nil {
 		logger.Error("Failed to create archive", zap.Strings("paths", req.Paths), zap.Error(err))
 		utils.RespondError(w, err)
 		return
 	}
 
-	utils.RespondSuccess(w, map[string]string{
-		"message": "Archive created successfully",
-	})
+	if result.JobID != "" {
+		utils.RespondJSON(w, http.StatusAccepted, result)
+		return
+	}
+	utils.RespondSuccess(w, result)
 }
 
 // ExtractArchive extracts a compressed archive
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/files"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestUploadSessionsAreLimitedToTheirOwner(t *testing.T) {
+	logger.InitLogger("error", false)
+	uploadManager = files.NewUploadManager(t.TempDir(), nil)
+	t.Cleanup(func() { uploadManager = nil })
+
+	owner := &models.User{ID: 1, Username: "alice", Role: "user"}
+	other := &models.User{ID: 2, Username: "bob", Role: "user"}
+	session, err := uploadManager.StartUploadSession("report.pdf", 10, owner.ID)
+	if err != nil {
+		t.Fatalf("StartUploadSession() error = %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/upload/{sessionId}", GetUploadSession)
+	r.Post("/upload/{sessionId}/chunk/{chunkIndex}", UploadChunk)
+	r.Delete("/upload/{sessionId}", CancelUpload)
+	do := func(user *models.User, method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), mw.UserContextKey, user))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	base := "/upload/" + session.ID
+	for _, call := range []struct{ method, path string }{
+		{http.MethodGet, base},
+		{http.MethodPost, base + "/chunk/0"},
+		{http.MethodDelete, base},
+	} {
+		if code := do(other, call.method, call.path, "0123456789"); code != http.StatusNotFound {
+			t.Errorf("%s %s by another user: status = %d, want %d", call.method, call.path, code, http.StatusNotFound)
+		}
+	}
+	if _, err := uploadManager.GetUploadSession(session.ID); err != nil {
+		t.Fatalf("session gone after another user's requests: %v", err)
+	}
+
+	if code := do(owner, http.MethodPost, base+"/chunk/0", "0123456789"); code != http.StatusOK {
+		t.Errorf("chunk by the owner: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := do(owner, http.MethodDelete, base, ""); code != http.StatusOK {
+		t.Errorf("cancel by the owner: status = %d, want %d", code, http.StatusOK)
+	}
+}
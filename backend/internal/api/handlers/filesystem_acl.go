@@ -3,13 +3,16 @@ package handlers
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 var aclManager *filesystem.ACLManager
@@ -266,3 +269,72 @@ func RemoveAllACLs(w http.ResponseWriter, r *http.Request) {
 		"path":    req.Path,
 	})
 }
+
+// InheritACLRequest represents the request for propagating ACLs to a subtree
+type InheritACLRequest struct {
+	Path    string                `json:"path"`
+	Entries []filesystem.ACLEntry `json:"entries"`
+	Depth   *int                  `json:"depth,omitempty"` // Levels below path (-1 or omitted = unlimited)
+}
+
+// InheritACL sets ACL entries on a directory and default ACLs on its
+// subdirectories in the background
+// POST /api/v1/syslib/acl/inherit
+// Body: { "path": "/path/to/dir", "entries": [...], "depth": -1 }
+func InheritACL(w http.ResponseWriter, r *http.Request) {
+	var req InheritACLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if req.Path == "" {
+		utils.RespondError(w, errors.BadRequest("Missing path in request", nil))
+		return
+	}
+
+	if len(req.Entries) == 0 {
+		utils.RespondError(w, errors.BadRequest("No ACL entries provided", nil))
+		return
+	}
+
+	depth := -1
+	if req.Depth != nil {
+		depth = *req.Depth
+	}
+
+	if aclManager == nil || !aclManager.IsEnabled() {
+		utils.RespondError(w, errors.InternalServerError("ACL support not available", nil))
+		return
+	}
+
+	jobID, err := aclManager.SetInheritedACLAsync(req.Path, req.Entries, depth)
+	if err != nil {
+		logger.Error("Failed to start ACL inheritance", zap.String("path", req.Path), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to start ACL inheritance: "+err.Error(), err))
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusAccepted, map[string]string{
+		"jobId": jobID,
+		"path":  req.Path,
+	})
+}
+
+// GetACLJobStatus returns the progress of an ACL inheritance job
+// GET /api/v1/syslib/acl/jobs/{id}/status
+func GetACLJobStatus(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	job, err := filesystem.GetACLJob(id)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			utils.RespondError(w, errors.NotFound("ACL job not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get ACL job", err))
+		return
+	}
+
+	utils.RespondSuccess(w, job)
+}
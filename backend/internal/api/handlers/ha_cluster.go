@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha/cluster"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var clusterManager *cluster.ClusterManager
+
+// InitClusterManager initializes the HA cluster manager
+func InitClusterManager(manager *cluster.ClusterManager) {
+	clusterManager = manager
+	logger.Info("HA cluster manager initialized in handlers")
+}
+
+// FailoverRequest is the request body of PerformHAFailover
+type FailoverRequest struct {
+	TargetNode string `json:"target_node"`
+}
+
+// GetHAClusterStatus returns the combined DRBD, Pacemaker and Keepalived status
+func GetHAClusterStatus(w http.ResponseWriter, r *http.Request) {
+	if clusterManager == nil || !clusterManager.IsEnabled() {
+		utils.RespondError(w, errors.NewAppError(
+			http.StatusServiceUnavailable,
+			"HA cluster not available",
+			nil,
+		))
+		return
+	}
+
+	status, err := clusterManager.GetClusterStatus()
+	if err != nil {
+		logger.Error("Failed to get HA cluster status", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to get HA cluster status", err))
+		return
+	}
+
+	utils.RespondSuccess(w, status)
+}
+
+// PerformHAFailover moves all HA services to another node
+func PerformHAFailover(w http.ResponseWriter, r *http.Request) {
+	if clusterManager == nil || !clusterManager.IsEnabled() {
+		utils.RespondError(w, errors.NewAppError(
+			http.StatusServiceUnavailable,
+			"HA cluster not available",
+			nil,
+		))
+		return
+	}
+
+	var req FailoverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.TargetNode == "" {
+		utils.RespondError(w, errors.BadRequest("target_node is required", nil))
+		return
+	}
+
+	if err := clusterManager.PerformFailover(req.TargetNode); err != nil {
+		logger.Error("HA failover failed", zap.Error(err), zap.String("target", req.TargetNode))
+		utils.RespondError(w, errors.InternalServerError("Failover failed", err))
+		return
+	}
+
+	logger.Info("HA failover performed", zap.String("target", req.TargetNode))
+	utils.RespondSuccess(w, map[string]string{
+		"message":     "Failover completed successfully",
+		"target_node": req.TargetNode,
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var fencingManager *ha.FencingManager
+
+// InitFencingManager initializes the fencing manager
+func InitFencingManager(manager *ha.FencingManager) {
+	fencingManager = manager
+	logger.Info("Fencing manager initialized in handlers")
+}
+
+// FencingConfigRequest is the request body of SetNodeFencing
+type FencingConfigRequest struct {
+	Method string            `json:"method"` // ipmi, apc-pdu, aws-ec2
+	Config map[string]string `json:"config"`
+}
+
+func fencingUnavailable(w http.ResponseWriter) bool {
+	if fencingManager == nil {
+		utils.RespondError(w, errors.NewAppError(
+			http.StatusServiceUnavailable,
+			"Fencing service not available",
+			nil,
+		))
+		return true
+	}
+	return false
+}
+
+// SetNodeFencing stores the fencing configuration of a node
+func SetNodeFencing(w http.ResponseWriter, r *http.Request) {
+	if fencingUnavailable(w) {
+		return
+	}
+
+	node := chi.URLParam(r, "node")
+	var req FencingConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	fencing, err := fencingManager.SetNodeFencing(node, req.Method, req.Config)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, fencing)
+}
+
+// FenceNode powers off a cluster node
+func FenceNode(w http.ResponseWriter, r *http.Request) {
+	if fencingUnavailable(w) {
+		return
+	}
+
+	node := chi.URLParam(r, "node")
+	if err := fencingManager.FenceNode(node); err != nil {
+		if stderrors.Is(err, ha.ErrNoFencingConfigured) {
+			utils.RespondError(w, errors.NotFound("No fencing configured for node", err))
+			return
+		}
+		logger.Error("Failed to fence node", zap.Error(err), zap.String("node", node))
+		utils.RespondError(w, errors.InternalServerError("Failed to fence node", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Node fenced successfully",
+		"node":    node,
+	})
+}
+
+// TestNodeFencing checks that a node can be fenced without powering it off
+func TestNodeFencing(w http.ResponseWriter, r *http.Request) {
+	if fencingUnavailable(w) {
+		return
+	}
+
+	node := chi.URLParam(r, "node")
+	if err := fencingManager.TestNode(node); err != nil {
+		if stderrors.Is(err, ha.ErrNoFencingConfigured) {
+			utils.RespondError(w, errors.NotFound("No fencing configured for node", err))
+			return
+		}
+		logger.Warn("Fencing test failed", zap.Error(err), zap.String("node", node))
+		utils.RespondError(w, errors.NewAppError(http.StatusBadGateway, "Fencing test failed", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Fencing device reachable",
+		"node":    node,
+	})
+}
@@ -8,10 +8,20 @@ import (
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
 )
 
-// HealthCheck returns the health status of the API
+// HealthCheck returns the health status of the API. Until all services
+// have started it responds with 503 and status "starting".
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	cfg := config.GlobalConfig
 
+	if !startupReady() {
+		utils.RespondJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":  "starting",
+			"service": cfg.App.Name,
+			"version": cfg.App.Version,
+		})
+		return
+	}
+
 	utils.RespondSuccess(w, map[string]interface{}{
 		"status":  "ok",
 		"service": cfg.App.Name,
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/netip"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var ipamManager *network.IPAMManager
+
+// InitIPAMManager initializes the IPAM manager
+func InitIPAMManager(m *network.IPAMManager) {
+	ipamManager = m
+	logger.Info("IPAM manager initialized")
+}
+
+// IPAllocationRequest is the body of manual IP allocation requests
+type IPAllocationRequest struct {
+	AllocatedFor string `json:"allocatedFor"`
+}
+
+func requireIPAM(w http.ResponseWriter) bool {
+	if ipamManager == nil {
+		utils.RespondError(w, errors.InternalServerError("IPAM manager not initialized", nil))
+		return false
+	}
+	return true
+}
+
+// respondIPAMError maps IPAM errors to HTTP errors
+func respondIPAMError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case stderrors.Is(err, network.ErrIPAMPoolNotFound):
+		utils.RespondError(w, errors.NotFound("IPAM pool not found", err))
+	case stderrors.Is(err, network.ErrIPAllocationNotFound):
+		utils.RespondError(w, errors.NotFound("IP address is not allocated", err))
+	case stderrors.Is(err, network.ErrIPAMPoolExists), stderrors.Is(err, network.ErrIPAMPoolInUse),
+		stderrors.Is(err, network.ErrIPAMPoolExhausted):
+		utils.RespondError(w, errors.Conflict(err.Error(), err))
+	default:
+		logger.Error(message, zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError(message, err))
+	}
+}
+
+// allocatePoolIP allocates an address of a pool for a peer or container.
+// Responds with the error and returns false if the allocation failed.
+func allocatePoolIP(w http.ResponseWriter, poolName, allocatedFor string) (string, bool) {
+	if !requireIPAM(w) {
+		return "", false
+	}
+	ip, err := ipamManager.AllocateIPFor(poolName, allocatedFor)
+	if err != nil {
+		respondIPAMError(w, "Failed to allocate IP address", err)
+		return "", false
+	}
+	return ip, true
+}
+
+// releasePoolIP returns an address allocated by allocatePoolIP after the
+// peer or container using it failed to be created
+func releasePoolIP(ip string) {
+	if err := ipamManager.ReleaseIP(ip); err != nil {
+		logger.Warn("Failed to release IP address", zap.String("ip", ip), zap.Error(err))
+	}
+}
+
+// ListIPAMPools handles GET /api/v1/network/ipam/pools
+func ListIPAMPools(w http.ResponseWriter, r *http.Request) {
+	if !requireIPAM(w) {
+		return
+	}
+
+	pools, err := ipamManager.ListPools()
+	if err != nil {
+		respondIPAMError(w, "Failed to list IPAM pools", err)
+		return
+	}
+
+	utils.RespondSuccess(w, pools)
+}
+
+// CreateIPAMPool handles POST /api/v1/network/ipam/pools
+func CreateIPAMPool(w http.ResponseWriter, r *http.Request) {
+	if !requireIPAM(w) {
+		return
+	}
+
+	var req network.IPAMPool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	pool, err := ipamManager.CreatePool(req)
+	if err != nil {
+		respondIPAMError(w, "Failed to create IPAM pool", err)
+		return
+	}
+
+	utils.RespondCreated(w, pool)
+}
+
+// UpdateIPAMPool handles PUT /api/v1/network/ipam/pools/{name}
+func UpdateIPAMPool(w http.ResponseWriter, r *http.Request) {
+	if !requireIPAM(w) {
+		return
+	}
+
+	var req network.IPAMPool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	req.Name = chi.URLParam(r, "name")
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	pool, err := ipamManager.UpdatePool(req.Name, req)
+	if err != nil {
+		respondIPAMError(w, "Failed to update IPAM pool", err)
+		return
+	}
+
+	utils.RespondSuccess(w, pool)
+}
+
+// DeleteIPAMPool handles DELETE /api/v1/network/ipam/pools/{name}
+func DeleteIPAMPool(w http.ResponseWriter, r *http.Request) {
+	if !requireIPAM(w) {
+		return
+	}
+
+	if err := ipamManager.DeletePool(chi.URLParam(r, "name")); err != nil {
+		respondIPAMError(w, "Failed to delete IPAM pool", err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// ListIPAllocations handles GET /api/v1/network/ipam/pools/{name}/allocations
+func ListIPAllocations(w http.ResponseWriter, r *http.Request) {
+	if !requireIPAM(w) {
+		return
+	}
+
+	allocations, err := ipamManager.ListAllocations(chi.URLParam(r, "name"))
+	if err != nil {
+		respondIPAMError(w, "Failed to list IP allocations", err)
+		return
+	}
+
+	utils.RespondSuccess(w, allocations)
+}
+
+// AllocateIP handles POST /api/v1/network/ipam/pools/{name}/allocations,
+// reserving the next free address of a pool for a host configured by hand
+func AllocateIP(w http.ResponseWriter, r *http.Request) {
+	var req IPAllocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	ip, ok := allocatePoolIP(w, chi.URLParam(r, "name"), req.AllocatedFor)
+	if !ok {
+		return
+	}
+
+	utils.RespondCreated(w, map[string]string{"ip": ip})
+}
+
+// ReleaseIP handles DELETE /api/v1/network/ipam/allocations/{ip}
+func ReleaseIP(w http.ResponseWriter, r *http.Request) {
+	if !requireIPAM(w) {
+		return
+	}
+
+	ip := chi.URLParam(r, "ip")
+	if _, err := netip.ParseAddr(ip); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid IP address", err))
+		return
+	}
+
+	if err := ipamManager.ReleaseIP(ip); err != nil {
+		respondIPAMError(w, "Failed to release IP address", err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
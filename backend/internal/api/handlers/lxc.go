@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	stderrors "errors"
+	"io/fs"
 	"net/http"
+	"net/netip"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/lxc"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
@@ -60,6 +64,13 @@ func GetContainer(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, container)
 }
 
+// ContainerCreateRequest is the body of container create requests. With
+// IPAMPool set, the container gets a static address from that pool.
+type ContainerCreateRequest struct {
+	lxc.ContainerCreateRequest
+	IPAMPool string `json:"ipam_pool,omitempty"`
+}
+
 // CreateContainer creates a new LXC container
 func CreateContainer(w http.ResponseWriter, r *http.Request) {
 	if lxcManager == nil {
@@ -67,7 +78,7 @@ func CreateContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req lxc.ContainerCreateRequest
+	var req ContainerCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
 		return
@@ -75,7 +86,35 @@ func CreateContainer(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info("Creating container via API", zap.String("container_name", req.Name))
 
-	if err := lxcManager.CreateContainer(req); err != nil {
+	var ip string
+	if req.IPAMPool != "" {
+		if !requireIPAM(w) {
+			return
+		}
+		pool, err := ipamManager.GetPool(req.IPAMPool)
+		if err != nil {
+			respondIPAMError(w, "Failed to get IPAM pool", err)
+			return
+		}
+		subnet, err := netip.ParsePrefix(pool.Subnet)
+		if err != nil || !subnet.Addr().Is4() {
+			utils.RespondError(w, errors.BadRequest("Containers need an IPv4 pool", err))
+			return
+		}
+
+		var ok bool
+		ip, ok = allocatePoolIP(w, req.IPAMPool, "lxc:"+req.Name)
+		if !ok {
+			return
+		}
+		req.IPv4Address = netip.PrefixFrom(netip.MustParseAddr(ip), subnet.Bits()).String()
+		req.IPv4Gateway = pool.GatewayIP
+	}
+
+	if err := lxcManager.CreateContainer(req.ContainerCreateRequest); err != nil {
+		if ip != "" {
+			releasePoolIP(ip)
+		}
 		logger.Error("Failed to create container", zap.Error(err), zap.String("container_name", req.Name))
 		utils.RespondError(w, errors.InternalServerError("Failed to create container", err))
 		return
@@ -88,6 +127,121 @@ func CreateContainer(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetContainerNetwork gets the bridge, VLAN and address of a container
+func GetContainerNetwork(w http.ResponseWriter, r *http.Request) {
+	if lxcManager == nil {
+		utils.RespondError(w, errors.InternalServerError("LXC manager not initialized", nil))
+		return
+	}
+
+	containerName := chi.URLParam(r, "name")
+	cfg, err := lxcManager.GetContainerNetwork(containerName)
+	if err != nil {
+		if stderrors.Is(err, fs.ErrNotExist) {
+			utils.RespondError(w, errors.NotFound("Container not found", err))
+			return
+		}
+		logger.Error("Failed to get container network", zap.Error(err), zap.String("container", containerName))
+		utils.RespondError(w, errors.InternalServerError("Failed to get container network", err))
+		return
+	}
+
+	utils.RespondSuccess(w, cfg)
+}
+
+// ContainerNetworkRequest is the body of container network updates. With
+// IPAMPool set, the container gets a static address from that pool.
+type ContainerNetworkRequest struct {
+	lxc.LXCNetworkConfig
+	IPAMPool string `json:"ipam_pool,omitempty"`
+}
+
+// SetContainerNetwork attaches a container to a bridge and VLAN and sets its
+// address. The change takes effect when the container is next started.
+func SetContainerNetwork(w http.ResponseWriter, r *http.Request) {
+	if lxcManager == nil {
+		utils.RespondError(w, errors.InternalServerError("LXC manager not initialized", nil))
+		return
+	}
+
+	var req ContainerNetworkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	containerName := chi.URLParam(r, "name")
+	req.ContainerID = containerName
+
+	current, err := lxcManager.GetContainerNetwork(containerName)
+	if err != nil {
+		if stderrors.Is(err, fs.ErrNotExist) {
+			utils.RespondError(w, errors.NotFound("Container not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get container network", err))
+		return
+	}
+
+	var ip string
+	if req.IPAMPool != "" {
+		if !requireIPAM(w) {
+			return
+		}
+		pool, err := ipamManager.GetPool(req.IPAMPool)
+		if err != nil {
+			respondIPAMError(w, "Failed to get IPAM pool", err)
+			return
+		}
+		subnet, err := netip.ParsePrefix(pool.Subnet)
+		if err != nil || !subnet.Addr().Is4() {
+			utils.RespondError(w, errors.BadRequest("Containers need an IPv4 pool", err))
+			return
+		}
+		// Validate before an address is taken from the pool
+		req.IPAddress, req.Gateway = "", ""
+		if err := req.Validate(); err != nil {
+			utils.RespondError(w, errors.BadRequest(err.Error(), err))
+			return
+		}
+
+		var ok bool
+		ip, ok = allocatePoolIP(w, req.IPAMPool, "lxc:"+containerName)
+		if !ok {
+			return
+		}
+		req.IPAddress = netip.PrefixFrom(netip.MustParseAddr(ip), subnet.Bits()).String()
+		req.Gateway = pool.GatewayIP
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	if err := lxcManager.SetContainerNetwork(req.LXCNetworkConfig); err != nil {
+		if ip != "" {
+			releasePoolIP(ip)
+		}
+		logger.Error("Failed to set container network", zap.Error(err), zap.String("container", containerName))
+		utils.RespondError(w, errors.InternalServerError("Failed to set container network", err))
+		return
+	}
+
+	// Return the old address to its pool, if it came from one
+	if old, err := netip.ParsePrefix(current.IPAddress); err == nil && ipamManager != nil &&
+		current.IPAddress != req.IPAddress {
+		if err := ipamManager.ReleaseIP(old.Addr().String()); err != nil && !stderrors.Is(err, network.ErrIPAllocationNotFound) {
+			logger.Warn("Failed to release container address", zap.Error(err), zap.String("container", containerName))
+		}
+	}
+
+	cfg, err := lxcManager.GetContainerNetwork(containerName)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get container network", err))
+		return
+	}
+	utils.RespondSuccess(w, cfg)
+}
+
 // StartContainer starts an LXC container
 func StartContainer(w http.ResponseWriter, r *http.Request) {
 	if lxcManager == nil {
@@ -168,6 +322,12 @@ func DeleteContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if ipamManager != nil {
+		if err := ipamManager.ReleaseAllocationsFor("lxc:" + containerName); err != nil {
+			logger.Warn("Failed to release container addresses", zap.Error(err), zap.String("container", containerName))
+		}
+	}
+
 	logger.Info("Container deleted successfully via API", zap.String("container", containerName))
 	utils.RespondSuccess(w, map[string]string{
 		"message": "Container deleted successfully",
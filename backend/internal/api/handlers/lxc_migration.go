@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// MigrateContainerRequest is the request body of MigrateContainer
+type MigrateContainerRequest struct {
+	TargetHost string `json:"target_host"`
+	TargetUser string `json:"target_user"` // SSH user on the target, defaults to root
+}
+
+// RestoreContainerRequest is the request body of RestoreContainer
+type RestoreContainerRequest struct {
+	Start bool `json:"start"` // Keep the container running; otherwise it is frozen
+}
+
+// MigrateContainer starts a live migration of a container to another NAS
+func MigrateContainer(w http.ResponseWriter, r *http.Request) {
+	if lxcManager == nil {
+		utils.RespondError(w, errors.InternalServerError("LXC manager not initialized", nil))
+		return
+	}
+
+	containerName := chi.URLParam(r, "name")
+	var req MigrateContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.TargetUser == "" {
+		req.TargetUser = "root"
+	}
+
+	// The target authenticates the restore with the caller's own token
+	migration, err := lxcManager.StartMigration(containerName, req.TargetHost, req.TargetUser, requestToken(r))
+	if err != nil {
+		logger.Error("Failed to start container migration", zap.Error(err), zap.String("container", containerName))
+		utils.RespondError(w, errors.BadRequest("Failed to start migration", err))
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusAccepted, migration)
+}
+
+// RestoreContainer restores a container from the checkpoint a migration
+// copied to this host
+func RestoreContainer(w http.ResponseWriter, r *http.Request) {
+	if lxcManager == nil {
+		utils.RespondError(w, errors.InternalServerError("LXC manager not initialized", nil))
+		return
+	}
+
+	containerName := chi.URLParam(r, "name")
+	var req RestoreContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if err := lxcManager.RestoreContainer(containerName, lxcManager.CheckpointDir(containerName), req.Start); err != nil {
+		logger.Error("Failed to restore container", zap.Error(err), zap.String("container", containerName))
+		utils.RespondError(w, errors.InternalServerError("Failed to restore container", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Container restored",
+	})
+}
+
+// ListContainerMigrations lists container migrations, most recent first
+func ListContainerMigrations(w http.ResponseWriter, r *http.Request) {
+	if lxcManager == nil {
+		utils.RespondError(w, errors.InternalServerError("LXC manager not initialized", nil))
+		return
+	}
+
+	migrations, err := lxcManager.ListMigrations()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list migrations", err))
+		return
+	}
+
+	utils.RespondSuccess(w, migrations)
+}
+
+// GetContainerMigration returns the progress of a container migration
+func GetContainerMigration(w http.ResponseWriter, r *http.Request) {
+	if lxcManager == nil {
+		utils.RespondError(w, errors.InternalServerError("LXC manager not initialized", nil))
+		return
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid migration ID", err))
+		return
+	}
+
+	migration, err := lxcManager.GetMigration(uint(id))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			utils.RespondError(w, errors.NotFound("Migration not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get migration", err))
+		return
+	}
+
+	utils.RespondSuccess(w, migration)
+}
+
+// requestToken returns the JWT the request was authenticated with
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// ListMaintenanceWindows handles GET /api/v1/monitoring/maintenance-windows
+func (h *AlertHandler) ListMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	windows, err := h.alertService.ListMaintenanceWindows()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list maintenance windows", err))
+		return
+	}
+
+	utils.RespondSuccess(w, windows)
+}
+
+// CreateMaintenanceWindow handles POST /api/v1/monitoring/maintenance-windows
+func (h *AlertHandler) CreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var req alerts.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	id, err := h.alertService.AddMaintenanceWindow(req)
+	if err != nil {
+		logger.Error("Failed to add maintenance window", zap.String("name", req.Name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to add maintenance window", err))
+		return
+	}
+
+	utils.RespondCreated(w, map[string]string{"id": id})
+}
+
+// UpdateMaintenanceWindow handles PUT /api/v1/monitoring/maintenance-windows/{id}
+func (h *AlertHandler) UpdateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req alerts.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	window, err := h.alertService.UpdateMaintenanceWindow(id, req)
+	if err != nil {
+		respondMaintenanceWindowError(w, "Failed to update maintenance window", id, err)
+		return
+	}
+
+	utils.RespondSuccess(w, window)
+}
+
+// DeleteMaintenanceWindow handles DELETE /api/v1/monitoring/maintenance-windows/{id}
+func (h *AlertHandler) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.alertService.DeleteMaintenanceWindow(id); err != nil {
+		respondMaintenanceWindowError(w, "Failed to delete maintenance window", id, err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// GetSuppressedAlerts handles GET /api/v1/monitoring/maintenance-windows/{id}/suppressed
+func (h *AlertHandler) GetSuppressedAlerts(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	suppressed, err := h.alertService.GetSuppressedAlerts(id)
+	if err != nil {
+		respondMaintenanceWindowError(w, "Failed to list suppressed alerts", id, err)
+		return
+	}
+
+	utils.RespondSuccess(w, suppressed)
+}
+
+// SendMaintenanceSummary handles POST /api/v1/monitoring/maintenance-windows/{id}/summary
+func (h *AlertHandler) SendMaintenanceSummary(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := h.alertService.SendSuppressedAlertSummary(id); err != nil {
+		respondMaintenanceWindowError(w, "Failed to send maintenance summary", id, err)
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Maintenance summary sent successfully",
+	})
+}
+
+func respondMaintenanceWindowError(w http.ResponseWriter, message, id string, err error) {
+	switch {
+	case stderrors.Is(err, alerts.ErrMaintenanceWindowNotFound):
+		utils.RespondError(w, errors.NotFound("Maintenance window not found", err))
+	case stderrors.Is(err, alerts.ErrMaintenanceWindowActive):
+		utils.RespondError(w, errors.Conflict("Maintenance window has not ended yet", err))
+	default:
+		logger.Error(message, zap.String("id", id), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError(message, err))
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/addons"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var marketplaceClient *addons.MarketplaceClient
+
+// InitMarketplaceClient initializes the addon marketplace client
+func InitMarketplaceClient(client *addons.MarketplaceClient) {
+	marketplaceClient = client
+	logger.Info("Addon marketplace initialized in handlers", zap.String("registry", client.RegistryURL))
+}
+
+// ListMarketplaceAddons lists the addons of the marketplace registry,
+// optionally filtered by ?category= and searched by ?q=
+func ListMarketplaceAddons(w http.ResponseWriter, r *http.Request) {
+	if marketplaceClient == nil {
+		utils.RespondError(w, errors.InternalServerError("Addon marketplace not initialized", nil))
+		return
+	}
+
+	category := r.URL.Query().Get("category")
+	query := r.URL.Query().Get("q")
+
+	var manifests []addons.Manifest
+	var err error
+	if query == "" {
+		manifests, err = marketplaceClient.ListAvailable(category)
+	} else {
+		manifests, err = marketplaceClient.SearchAddons(query)
+		if err == nil && category != "" {
+			filtered := []addons.Manifest{}
+			for _, manifest := range manifests {
+				if manifest.Category == category {
+					filtered = append(filtered, manifest)
+				}
+			}
+			manifests = filtered
+		}
+	}
+	if err != nil {
+		logger.Error("Failed to list marketplace addons", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to list marketplace addons", err))
+		return
+	}
+
+	utils.RespondSuccess(w, manifests)
+}
+
+// GetMarketplaceAddon returns the manifest of an addon in the marketplace registry
+func GetMarketplaceAddon(w http.ResponseWriter, r *http.Request) {
+	if marketplaceClient == nil {
+		utils.RespondError(w, errors.InternalServerError("Addon marketplace not initialized", nil))
+		return
+	}
+
+	manifest, err := marketplaceClient.GetAddonDetails(chi.URLParam(r, "id"))
+	if err != nil {
+		if stderrors.Is(err, addons.ErrMarketplaceAddonNotFound) {
+			utils.RespondError(w, errors.NotFound("Addon not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get marketplace addon", err))
+		return
+	}
+
+	utils.RespondSuccess(w, manifest)
+}
@@ -6,7 +6,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/metrics"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
@@ -150,6 +152,21 @@ func (h *MetricsHandler) GetLatestHealthScore(w http.ResponseWriter, r *http.Req
 	utils.RespondSuccess(w, score)
 }
 
+// GetHealthScoreBreakdown returns the component scores and deductions of
+// the current health score
+func (h *MetricsHandler) GetHealthScoreBreakdown(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	breakdown, err := h.service.GetHealthScoreBreakdown(ctx)
+	if err != nil {
+		logger.Error("Failed to get health score breakdown", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to retrieve health score breakdown", err))
+		return
+	}
+
+	utils.RespondSuccess(w, breakdown)
+}
+
 // GetTrends returns trend analysis for key metrics
 func (h *MetricsHandler) GetTrends(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -198,7 +215,14 @@ func PrometheusMetricsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert to Prometheus format
-	prometheusOutput := current.ToPrometheusFormat()
+	prometheusOutput := current.ToPrometheusFormat() + database.Cached().PrometheusMetrics()
+	prometheusOutput += metrics.GetMetricEngine().PrometheusMetrics()
+	if monitor := network.GetTrafficMonitor(); monitor != nil {
+		prometheusOutput += monitor.PrometheusMetrics()
+	}
+	if vpnManager != nil {
+		prometheusOutput += vpnManager.PrometheusMetrics()
+	}
 
 	// Set content type for Prometheus
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/metrics"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	metricsExporter   *metrics.MetricsExporter // nil while the export is disabled
+	metricsExporterMu sync.Mutex
+)
+
+// InitMetricsExporter initializes the running metrics exporter
+func InitMetricsExporter(e *metrics.MetricsExporter) {
+	metricsExporterMu.Lock()
+	defer metricsExporterMu.Unlock()
+	metricsExporter = e
+	logger.Info("Metrics exporter initialized")
+}
+
+// MetricsExportConfigRequest represents the metrics export settings. An
+// empty token keeps the current one.
+type MetricsExportConfigRequest struct {
+	Enabled      bool   `json:"enabled"`
+	Protocol     string `json:"protocol"`
+	RemoteURL    string `json:"remoteUrl"`
+	Token        string `json:"token,omitempty"`
+	Org          string `json:"org"`
+	Bucket       string `json:"bucket"`
+	PushInterval string `json:"pushInterval"` // Go duration, e.g. 60s
+}
+
+// MetricsExportConfigResponse represents the metrics export settings
+// without the token
+type MetricsExportConfigResponse struct {
+	Enabled      bool   `json:"enabled"`
+	Protocol     string `json:"protocol"`
+	RemoteURL    string `json:"remoteUrl"`
+	TokenSet     bool   `json:"tokenSet"`
+	Org          string `json:"org"`
+	Bucket       string `json:"bucket"`
+	PushInterval string `json:"pushInterval"`
+}
+
+// toConfig merges the request into the current settings
+func (req *MetricsExportConfigRequest) toConfig(current config.MetricsExportConfig) (config.MetricsExportConfig, error) {
+	cfg := config.MetricsExportConfig{
+		Enabled:   req.Enabled,
+		Protocol:  req.Protocol,
+		RemoteURL: req.RemoteURL,
+		Token:     req.Token,
+		Org:       req.Org,
+		Bucket:    req.Bucket,
+	}
+	if cfg.Token == "" {
+		cfg.Token = current.Token
+	}
+	interval, err := time.ParseDuration(req.PushInterval)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.PushInterval = interval
+	return cfg, nil
+}
+
+func newMetricsExportConfigResponse(cfg config.MetricsExportConfig) MetricsExportConfigResponse {
+	return MetricsExportConfigResponse{
+		Enabled:      cfg.Enabled,
+		Protocol:     cfg.Protocol,
+		RemoteURL:    cfg.RemoteURL,
+		TokenSet:     cfg.Token != "",
+		Org:          cfg.Org,
+		Bucket:       cfg.Bucket,
+		PushInterval: cfg.PushInterval.String(),
+	}
+}
+
+// currentMetricsExportConfig returns the metrics.export settings in use
+func currentMetricsExportConfig() config.MetricsExportConfig {
+	if config.GlobalConfig == nil {
+		return config.MetricsExportConfig{}
+	}
+	return config.GlobalConfig.Metrics.Export
+}
+
+// GetMetricsExportConfig handles GET /api/v1/monitoring/export/config
+func GetMetricsExportConfig(w http.ResponseWriter, r *http.Request) {
+	metricsExporterMu.Lock()
+	cfg := currentMetricsExportConfig()
+	metricsExporterMu.Unlock()
+
+	utils.RespondSuccess(w, newMetricsExportConfigResponse(cfg))
+}
+
+// UpdateMetricsExportConfig handles PUT /api/v1/monitoring/export/config.
+// The exporter is restarted with the new settings; they are kept until the
+// server restarts, so set metrics.export in the config file to make them
+// permanent.
+func UpdateMetricsExportConfig(w http.ResponseWriter, r *http.Request) {
+	var req MetricsExportConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	metricsExporterMu.Lock()
+	defer metricsExporterMu.Unlock()
+
+	cfg, err := req.toConfig(currentMetricsExportConfig())
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid push interval", err))
+		return
+	}
+
+	var exporter *metrics.MetricsExporter
+	if cfg.Enabled {
+		if exporter, err = metrics.NewMetricsExporter(cfg); err != nil {
+			utils.RespondError(w, errors.BadRequest(err.Error(), err))
+			return
+		}
+	}
+
+	if metricsExporter != nil {
+		metricsExporter.Stop()
+	}
+	metricsExporter = exporter
+	if exporter != nil {
+		if err := exporter.Start(context.Background()); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to start metrics export", err))
+			return
+		}
+	}
+	if config.GlobalConfig != nil {
+		config.GlobalConfig.Metrics.Export = cfg
+	}
+
+	logger.Info("Metrics export configuration updated",
+		zap.Bool("enabled", cfg.Enabled),
+		zap.String("protocol", cfg.Protocol),
+		zap.String("url", cfg.RemoteURL))
+	utils.RespondSuccess(w, newMetricsExportConfigResponse(cfg))
+}
+
+// TestMetricsExport handles POST /api/v1/monitoring/export/test. It pushes
+// the current metrics with the settings in the body, or with the settings
+// in use if the body is empty.
+func TestMetricsExport(w http.ResponseWriter, r *http.Request) {
+	metricsExporterMu.Lock()
+	cfg := currentMetricsExportConfig()
+	metricsExporterMu.Unlock()
+
+	var req MetricsExportConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+		if cfg, err = req.toConfig(cfg); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid push interval", err))
+			return
+		}
+	} else if !stderrors.Is(err, io.EOF) {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	exporter, err := metrics.NewMetricsExporter(cfg)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, exporter.Test(r.Context()))
+}
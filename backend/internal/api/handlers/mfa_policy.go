@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+// MFAPolicyRequest is the request body of UpdateMFAPolicy
+type MFAPolicyRequest struct {
+	RequireForRoles       []string `json:"requireForRoles"`
+	RequireForExternalIPs bool     `json:"requireForExternalIPs"`
+	ExemptLocalSubnets    []string `json:"exemptLocalSubnets"`
+	GracePeriodDays       int      `json:"gracePeriodDays"`
+}
+
+// GetMFAPolicy returns the policy deciding which logins require 2FA
+func GetMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	enforcement := auth.GetMFAEnforcement()
+	if enforcement == nil {
+		utils.RespondError(w, errors.InternalServerError("MFA enforcement not available", nil))
+		return
+	}
+
+	utils.RespondSuccess(w, enforcement.GetPolicy())
+}
+
+// UpdateMFAPolicy replaces the MFA policy. Its grace period starts now.
+func UpdateMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	enforcement := auth.GetMFAEnforcement()
+	if enforcement == nil {
+		utils.RespondError(w, errors.InternalServerError("MFA enforcement not available", nil))
+		return
+	}
+
+	var req MFAPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	policy, err := enforcement.UpdatePolicy(r.Context(), models.MFAPolicy{
+		RequireForRoles:       req.RequireForRoles,
+		RequireForExternalIPs: req.RequireForExternalIPs,
+		ExemptLocalSubnets:    req.ExemptLocalSubnets,
+		GracePeriodDays:       req.GracePeriodDays,
+	})
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, policy)
+}
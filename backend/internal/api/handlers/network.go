@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 	"strconv"
 
@@ -79,10 +80,12 @@ func (h *NetworkHandler) ConfigureInterface(w http.ResponseWriter, r *http.Reque
 	name := chi.URLParam(r, "name")
 
 	var req struct {
-		Mode    string `json:"mode"`    // "static" or "dhcp"
-		Address string `json:"address"` // for static
-		Netmask string `json:"netmask"` // for static
-		Gateway string `json:"gateway"` // for static
+		Mode        string `json:"mode"`        // "static" or "dhcp"
+		Address     string `json:"address"`     // for static
+		Netmask     string `json:"netmask"`     // for static
+		Gateway     string `json:"gateway"`     // for static
+		IPv6Address string `json:"ipv6Address"` // for static, e.g. 2001:db8::10/64
+		IPv6Gateway string `json:"ipv6Gateway"` // for static
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -92,11 +95,11 @@ func (h *NetworkHandler) ConfigureInterface(w http.ResponseWriter, r *http.Reque
 
 	var err error
 	if req.Mode == "static" {
-		if req.Address == "" || req.Netmask == "" {
+		if (req.Address == "" && req.IPv6Address == "") || (req.Address != "" && req.Netmask == "") {
 			utils.RespondError(w, errors.BadRequest("Missing required fields", nil))
 			return
 		}
-		err = network.ConfigureStaticIP(name, req.Address, req.Netmask, req.Gateway)
+		err = network.ConfigureIPAddress(name, req.Address, req.Netmask, req.Gateway, req.IPv6Address, req.IPv6Gateway)
 	} else if req.Mode == "dhcp" {
 		err = network.ConfigureDHCP(name)
 	} else {
@@ -508,3 +511,120 @@ func (h *NetworkHandler) ListBridges(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondSuccess(w, bridges)
 }
+
+// GetBridgeStats handles GET /api/network/bridges/{name}/stats
+func (h *NetworkHandler) GetBridgeStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := network.NewBridgeStatistics().GetStats(chi.URLParam(r, "name"))
+	if err != nil {
+		respondBridgeError(w, "Failed to get bridge statistics", err)
+		return
+	}
+
+	utils.RespondSuccess(w, stats)
+}
+
+// GetBridgeFDB handles GET /api/network/bridges/{name}/fdb
+func (h *NetworkHandler) GetBridgeFDB(w http.ResponseWriter, r *http.Request) {
+	entries, err := network.NewBridgeStatistics().GetForwardingTable(chi.URLParam(r, "name"))
+	if err != nil {
+		respondBridgeError(w, "Failed to get forwarding table", err)
+		return
+	}
+
+	utils.RespondSuccess(w, entries)
+}
+
+func respondBridgeError(w http.ResponseWriter, message string, err error) {
+	if stderrors.Is(err, network.ErrBridgeNotFound) {
+		utils.RespondError(w, errors.NotFound("Bridge not found", err))
+		return
+	}
+	utils.RespondError(w, errors.InternalServerError(message, err))
+}
+
+// TrafficResponse is the share traffic accounted by the traffic monitor
+type TrafficResponse struct {
+	Shares      []network.ShareTraffic   `json:"shares"`
+	Connections []network.ConnectionStat `json:"connections"`
+}
+
+// GetTraffic handles GET /api/network/traffic
+func (h *NetworkHandler) GetTraffic(w http.ResponseWriter, r *http.Request) {
+	monitor := network.GetTrafficMonitor()
+	if monitor == nil {
+		utils.RespondError(w, errors.InternalServerError("Traffic monitor is not running", nil))
+		return
+	}
+
+	connections, err := monitor.GetConnectionStats()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get connection stats", err))
+		return
+	}
+
+	utils.RespondSuccess(w, TrafficResponse{
+		Shares:      monitor.GetShareTraffic(),
+		Connections: connections,
+	})
+}
+
+// GetDDNSStatus handles GET /api/network/ddns/status
+func (h *NetworkHandler) GetDDNSStatus(w http.ResponseWriter, r *http.Request) {
+	updater := network.GetDynamicDNSUpdater()
+	if updater == nil {
+		utils.RespondError(w, errors.InternalServerError("Dynamic DNS updater is not running", nil))
+		return
+	}
+
+	status, err := updater.Status()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get dynamic DNS status", err))
+		return
+	}
+
+	utils.RespondSuccess(w, status)
+}
+
+// RestartMDNS handles POST /api/network/mdns/restart
+func (h *NetworkHandler) RestartMDNS(w http.ResponseWriter, r *http.Request) {
+	advertiser := network.GetMDNSAdvertiser()
+	if advertiser == nil {
+		utils.RespondError(w, errors.InternalServerError("mDNS advertiser is not running", nil))
+		return
+	}
+
+	if err := advertiser.Restart(); err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to announce mDNS services", err))
+		return
+	}
+
+	utils.RespondSuccess(w, advertiser.Services())
+}
+
+// UpdateDDNSConfig handles PUT /api/network/ddns/config
+func (h *NetworkHandler) UpdateDDNSConfig(w http.ResponseWriter, r *http.Request) {
+	updater := network.GetDynamicDNSUpdater()
+	if updater == nil {
+		utils.RespondError(w, errors.InternalServerError("Dynamic DNS updater is not running", nil))
+		return
+	}
+
+	var cfg network.DynamicDNSConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if err := updater.SaveConfig(cfg); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	status, err := updater.Status()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get dynamic DNS status", err))
+		return
+	}
+
+	utils.RespondSuccess(w, status)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var webPushSubscriber *notifications.WebPushSubscriber
+
+// InitWebPushSubscriber initializes the browser push notification subscriber
+func InitWebPushSubscriber(s *notifications.WebPushSubscriber) {
+	webPushSubscriber = s
+	logger.Info("Web push subscriber initialized")
+}
+
+// UnsubscribePushRequest is the body of push unsubscribe requests
+type UnsubscribePushRequest struct {
+	Endpoint string `json:"endpoint"`
+}
+
+func requireWebPush(w http.ResponseWriter) bool {
+	if webPushSubscriber == nil {
+		utils.RespondError(w, errors.InternalServerError("Push notifications not available", nil))
+		return false
+	}
+	return true
+}
+
+// GetVAPIDPublicKey handles GET /api/v1/notifications/vapid-public-key
+func GetVAPIDPublicKey(w http.ResponseWriter, r *http.Request) {
+	if !requireWebPush(w) {
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{"publicKey": webPushSubscriber.PublicKey()})
+}
+
+// SubscribePush handles POST /api/v1/notifications/subscribe with the JSON
+// of a browser PushSubscription
+func SubscribePush(w http.ResponseWriter, r *http.Request) {
+	if !requireWebPush(w) {
+		return
+	}
+
+	user := mw.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.RespondError(w, errors.Unauthorized("Authentication required", nil))
+		return
+	}
+
+	var req notifications.PushSubscription
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	sub, err := webPushSubscriber.Subscribe(user.ID, req, r.UserAgent())
+	if err != nil {
+		logger.Error("Failed to save push subscription", zap.Uint("user", user.ID), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to save push subscription", err))
+		return
+	}
+
+	utils.RespondCreated(w, sub)
+}
+
+// UnsubscribePush handles DELETE /api/v1/notifications/unsubscribe
+func UnsubscribePush(w http.ResponseWriter, r *http.Request) {
+	if !requireWebPush(w) {
+		return
+	}
+
+	user := mw.GetUserFromContext(r.Context())
+	if user == nil {
+		utils.RespondError(w, errors.Unauthorized("Authentication required", nil))
+		return
+	}
+
+	var req UnsubscribePushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.Endpoint == "" {
+		utils.RespondError(w, errors.BadRequest("endpoint is required", nil))
+		return
+	}
+
+	if err := webPushSubscriber.Unsubscribe(user.ID, req.Endpoint); err != nil {
+		if stderrors.Is(err, notifications.ErrPushSubscriptionNotFound) {
+			utils.RespondError(w, errors.NotFound("Push subscription not found", err))
+			return
+		}
+		logger.Error("Failed to remove push subscription", zap.Uint("user", user.ID), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to remove push subscription", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
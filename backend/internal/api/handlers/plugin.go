@@ -3,8 +3,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/plugins"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
@@ -203,30 +207,123 @@ func (h *PluginHandler) RestartPlugin(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, map[string]string{"message": "Plugin restarted successfully"})
 }
 
-// GetPluginStatus returns the runtime status of a plugin
+// pluginStatusLogLines is how many log lines the plugin status includes
+const pluginStatusLogLines = 5
+
+// GetPluginStatus returns the runtime status of a plugin with its latest
+// log lines
 func (h *PluginHandler) GetPluginStatus(w http.ResponseWriter, r *http.Request) {
 	pluginID := chi.URLParam(r, "id")
 
+	recentLogs, err := h.runtime.GetPluginLogs(pluginID, pluginStatusLogLines, time.Time{})
+	if err != nil {
+		recentLogs = []models.PluginLog{}
+	}
+
 	status, err := h.runtime.GetPluginStatus(pluginID)
 	if err != nil {
 		// Plugin not running, return basic status
 		utils.RespondSuccess(w, map[string]interface{}{
-			"pluginID": pluginID,
-			"status":   "stopped",
-			"running":  false,
+			"pluginID":   pluginID,
+			"status":     "stopped",
+			"running":    false,
+			"recentLogs": recentLogs,
 		})
 		return
 	}
 
 	utils.RespondSuccess(w, map[string]interface{}{
-		"pluginID":  status.PluginID,
-		"status":    status.Status,
-		"running":   status.Status == "running",
-		"startedAt": status.StartedAt,
-		"error":     status.LastError,
+		"pluginID":   status.PluginID,
+		"status":     status.Status,
+		"running":    status.Status == "running",
+		"startedAt":  status.StartedAt,
+		"error":      status.LastError,
+		"recentLogs": recentLogs,
 	})
 }
 
+// defaultPluginLogTail is how many log lines are returned without ?tail
+const defaultPluginLogTail = 100
+
+// GetPluginLogs returns the captured output of a plugin
+// (?tail=100&since=2h, since also as days like 1d or an RFC 3339 time)
+func (h *PluginHandler) GetPluginLogs(w http.ResponseWriter, r *http.Request) {
+	pluginID := chi.URLParam(r, "id")
+	query := r.URL.Query()
+
+	tail := defaultPluginLogTail
+	if value := query.Get("tail"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			utils.RespondError(w, errors.BadRequest("Invalid tail value", err))
+			return
+		}
+		tail = n
+	}
+
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		var err error
+		if since, err = parseSince(value, 0); err != nil {
+			utils.RespondError(w, err)
+			return
+		}
+	}
+
+	logs, err := h.runtime.GetPluginLogs(pluginID, tail, since)
+	if err != nil {
+		utils.RespondError(w, errors.NotFound("Plugin not found", err))
+		return
+	}
+
+	utils.RespondSuccess(w, logs)
+}
+
+// StreamPluginLogs streams the new output lines of a plugin as
+// Server-Sent Events. Use it behind middleware.SSEMiddleware.
+func (h *PluginHandler) StreamPluginLogs(w http.ResponseWriter, r *http.Request) {
+	pluginID := chi.URLParam(r, "id")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondError(w, errors.InternalServerError("Streaming not supported", nil))
+		return
+	}
+
+	lines, unsubscribe, err := h.runtime.SubscribePluginLogs(pluginID)
+	if err != nil {
+		utils.RespondError(w, errors.NotFound("Plugin not found", err))
+		return
+	}
+	defer unsubscribe()
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case line := <-lines:
+			data, err := json.Marshal(line)
+			if err != nil {
+				logger.Error("Failed to encode plugin log", zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
 // ListRunningPlugins returns all currently running plugins
 func (h *PluginHandler) ListRunningPlugins(w http.ResponseWriter, r *http.Request) {
 	procs := h.runtime.ListRunningPlugins()
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var portForwardingManager *network.PortForwardingManager
+
+// InitPortForwardingManager initializes the port forwarding manager
+func InitPortForwardingManager(m *network.PortForwardingManager) {
+	portForwardingManager = m
+	logger.Info("Port forwarding manager initialized")
+}
+
+// PortForwardRequest is the body of port forward create and update requests
+type PortForwardRequest struct {
+	ExternalPort int    `json:"externalPort"`
+	InternalIP   string `json:"internalIp"`
+	InternalPort string `json:"internalPort"`
+	Protocol     string `json:"protocol"`
+	Description  string `json:"description"`
+	Enabled      *bool  `json:"enabled,omitempty"` // Defaults to true
+}
+
+func (req *PortForwardRequest) rule() network.PortForwardRule {
+	rule := network.PortForwardRule{
+		ExternalPort: req.ExternalPort,
+		InternalIP:   req.InternalIP,
+		InternalPort: req.InternalPort,
+		Protocol:     req.Protocol,
+		Description:  req.Description,
+		Enabled:      true,
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return rule
+}
+
+func requirePortForwarding(w http.ResponseWriter) bool {
+	if portForwardingManager == nil {
+		utils.RespondError(w, errors.InternalServerError("Port forwarding not available (iptables not installed)", nil))
+		return false
+	}
+	return true
+}
+
+// ListPortForwards handles GET /api/v1/network/port-forwards
+func ListPortForwards(w http.ResponseWriter, r *http.Request) {
+	if !requirePortForwarding(w) {
+		return
+	}
+
+	rules, err := portForwardingManager.ListRules()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list port forwards", err))
+		return
+	}
+
+	utils.RespondSuccess(w, rules)
+}
+
+// CreatePortForward handles POST /api/v1/network/port-forwards
+func CreatePortForward(w http.ResponseWriter, r *http.Request) {
+	if !requirePortForwarding(w) {
+		return
+	}
+
+	var req PortForwardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	input := req.rule()
+	if err := input.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	rule, err := portForwardingManager.AddRule(input)
+	if err != nil {
+		logger.Error("Failed to add port forward", zap.Int("externalPort", req.ExternalPort), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to add port forward", err))
+		return
+	}
+
+	utils.RespondCreated(w, rule)
+}
+
+// UpdatePortForward handles PUT /api/v1/network/port-forwards/{id}
+func UpdatePortForward(w http.ResponseWriter, r *http.Request) {
+	if !requirePortForwarding(w) {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	var req PortForwardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	input := req.rule()
+	if err := input.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	rule, err := portForwardingManager.UpdateRule(id, input)
+	if err != nil {
+		if stderrors.Is(err, network.ErrPortForwardNotFound) {
+			utils.RespondError(w, errors.NotFound("Port forward not found", err))
+			return
+		}
+		logger.Error("Failed to update port forward", zap.String("id", id), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to update port forward", err))
+		return
+	}
+
+	utils.RespondSuccess(w, rule)
+}
+
+// DeletePortForward handles DELETE /api/v1/network/port-forwards/{id}
+func DeletePortForward(w http.ResponseWriter, r *http.Request) {
+	if !requirePortForwarding(w) {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := portForwardingManager.RemoveRule(id); err != nil {
+		if stderrors.Is(err, network.ErrPortForwardNotFound) {
+			utils.RespondError(w, errors.NotFound("Port forward not found", err))
+			return
+		}
+		logger.Error("Failed to remove port forward", zap.String("id", id), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to remove port forward", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CreateProjectRequest is the request body of CreateQuotaProject
+type CreateProjectRequest struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	SoftLimit int64  `json:"soft_limit"` // Bytes, 0 = no limit
+	HardLimit int64  `json:"hard_limit"` // Bytes, 0 = no limit
+}
+
+// ProjectLimitsRequest is the request body of UpdateQuotaProject
+type ProjectLimitsRequest struct {
+	SoftLimit int64 `json:"soft_limit"` // Bytes, 0 = no limit
+	HardLimit int64 `json:"hard_limit"` // Bytes, 0 = no limit
+}
+
+// QuotaProjectResponse is a project with its current usage
+type QuotaProjectResponse struct {
+	*filesystem.XFSProject
+	Used int64 `json:"used"` // Bytes
+}
+
+func quotaUnavailable(w http.ResponseWriter) bool {
+	if quotaManager == nil || !quotaManager.IsEnabled() {
+		utils.RespondError(w, errors.InternalServerError("Quota support not available", nil))
+		return true
+	}
+	return false
+}
+
+func respondProjectError(w http.ResponseWriter, message string, err error) {
+	if stderrors.Is(err, filesystem.ErrProjectNotFound) {
+		utils.RespondError(w, errors.NotFound("Project not found", err))
+		return
+	}
+	utils.RespondError(w, errors.BadRequest(message, err))
+}
+
+// ListQuotaProjects lists the XFS project quotas
+func ListQuotaProjects(w http.ResponseWriter, r *http.Request) {
+	if quotaUnavailable(w) {
+		return
+	}
+
+	projects, err := quotaManager.ListProjects()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list projects", err))
+		return
+	}
+
+	utils.RespondSuccess(w, projects)
+}
+
+// CreateQuotaProject creates a project quota on a directory tree
+func CreateQuotaProject(w http.ResponseWriter, r *http.Request) {
+	if quotaUnavailable(w) {
+		return
+	}
+
+	var req CreateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	project, err := quotaManager.CreateProject(req.Name, req.Path, req.SoftLimit, req.HardLimit)
+	if err != nil {
+		logger.Error("Failed to create project quota", zap.String("project", req.Name), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to create project", err))
+		return
+	}
+
+	utils.RespondCreated(w, project)
+}
+
+// GetQuotaProject returns a project with its limits and usage
+func GetQuotaProject(w http.ResponseWriter, r *http.Request) {
+	if quotaUnavailable(w) {
+		return
+	}
+	name := chi.URLParam(r, "name")
+
+	project, err := quotaManager.GetProject(name)
+	if err != nil {
+		respondProjectError(w, "Failed to get project", err)
+		return
+	}
+	usage, err := quotaManager.GetProjectUsage(name)
+	if err != nil {
+		respondProjectError(w, "Failed to get project usage", err)
+		return
+	}
+
+	utils.RespondSuccess(w, QuotaProjectResponse{XFSProject: project, Used: usage.Used})
+}
+
+// UpdateQuotaProject replaces the limits of a project
+func UpdateQuotaProject(w http.ResponseWriter, r *http.Request) {
+	if quotaUnavailable(w) {
+		return
+	}
+
+	var req ProjectLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	project, err := quotaManager.SetProjectLimits(chi.URLParam(r, "name"), req.SoftLimit, req.HardLimit)
+	if err != nil {
+		respondProjectError(w, "Failed to update project", err)
+		return
+	}
+
+	utils.RespondSuccess(w, project)
+}
+
+// DeleteQuotaProject removes a project quota
+func DeleteQuotaProject(w http.ResponseWriter, r *http.Request) {
+	if quotaUnavailable(w) {
+		return
+	}
+	name := chi.URLParam(r, "name")
+
+	if err := quotaManager.DeleteProject(name); err != nil {
+		logger.Error("Failed to delete project quota", zap.String("project", name), zap.Error(err))
+		respondProjectError(w, "Failed to delete project", err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
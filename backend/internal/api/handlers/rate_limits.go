@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SetRateLimitRequest is the body of PUT /admin/rate-limits/{target}
+type SetRateLimitRequest struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond" validate:"required"`
+	Burst             int     `json:"burst" validate:"required"`
+	Description       string  `json:"description,omitempty"`
+}
+
+// ListRateLimits returns all custom rate limits
+// GET /api/v1/admin/rate-limits
+func ListRateLimits(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		utils.RespondError(w, errors.InternalServerError("Database not initialized", nil))
+		return
+	}
+
+	var limits []models.RateLimit
+	if err := db.Order("type, target").Find(&limits).Error; err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list rate limits", err))
+		return
+	}
+
+	utils.RespondSuccess(w, limits)
+}
+
+// SetRateLimit creates or updates the custom rate limit for a user or IP range.
+// The target is a username, an IP address or a URL-encoded CIDR range.
+// PUT /api/v1/admin/rate-limits/{target}
+func SetRateLimit(w http.ResponseWriter, r *http.Request) {
+	target, limitType, err := parseRateLimitTarget(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	var req SetRateLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.RequestsPerSecond <= 0 {
+		utils.RespondError(w, errors.BadRequest("requestsPerSecond must be greater than 0", nil))
+		return
+	}
+	if req.Burst < 1 {
+		utils.RespondError(w, errors.BadRequest("burst must be at least 1", nil))
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		utils.RespondError(w, errors.InternalServerError("Database not initialized", nil))
+		return
+	}
+
+	var limit models.RateLimit
+	err = db.Where("target = ?", target).First(&limit).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		utils.RespondError(w, errors.InternalServerError("Failed to load rate limit", err))
+		return
+	}
+
+	limit.Target = target
+	limit.Type = limitType
+	limit.RequestsPerSecond = req.RequestsPerSecond
+	limit.Burst = req.Burst
+	limit.Description = req.Description
+
+	if err := db.Save(&limit).Error; err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to save rate limit", err))
+		return
+	}
+
+	if err := middleware.ReloadRateLimits(); err != nil {
+		logger.Warn("Failed to reload rate limits", zap.Error(err))
+	}
+
+	logger.Info("Rate limit updated",
+		zap.String("target", target),
+		zap.String("type", limitType),
+		zap.Float64("rps", req.RequestsPerSecond),
+		zap.Int("burst", req.Burst))
+
+	utils.RespondSuccess(w, limit)
+}
+
+// DeleteRateLimit removes a custom rate limit, restoring the defaults
+// DELETE /api/v1/admin/rate-limits/{target}
+func DeleteRateLimit(w http.ResponseWriter, r *http.Request) {
+	target, _, err := parseRateLimitTarget(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		utils.RespondError(w, errors.InternalServerError("Database not initialized", nil))
+		return
+	}
+
+	result := db.Where("target = ?", target).Delete(&models.RateLimit{})
+	if result.Error != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to delete rate limit", result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.RespondError(w, errors.NotFound("Rate limit not found", nil))
+		return
+	}
+
+	if err := middleware.ReloadRateLimits(); err != nil {
+		logger.Warn("Failed to reload rate limits", zap.Error(err))
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// parseRateLimitTarget reads the {target} URL parameter and determines whether
+// it refers to a user or an IP range
+func parseRateLimitTarget(r *http.Request) (string, string, error) {
+	target, err := url.PathUnescape(chi.URLParam(r, "target"))
+	if err != nil {
+		return "", "", errors.BadRequest("Invalid target", err)
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", "", errors.BadRequest("Target is required", nil)
+	}
+
+	if net.ParseIP(target) != nil || strings.Contains(target, "/") {
+		network, err := middleware.ParseIPOrCIDR(target)
+		if err != nil {
+			return "", "", errors.BadRequest("Invalid IP address or CIDR range", err)
+		}
+		if strings.Contains(target, "/") {
+			target = network.String()
+		}
+		return target, models.RateLimitTypeIP, nil
+	}
+
+	return target, models.RateLimitTypeUser, nil
+}
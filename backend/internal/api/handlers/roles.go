@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth/rbac"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// SetRolePermissionsRequest replaces the permissions of a role
+type SetRolePermissionsRequest struct {
+	Permissions []rbac.Permission `json:"permissions"`
+}
+
+// rbacService returns the RBAC service or responds with an error
+func rbacService(w http.ResponseWriter) *rbac.Service {
+	s := rbac.GetService()
+	if s == nil {
+		utils.RespondError(w, errors.InternalServerError("RBAC service not initialized", nil))
+	}
+	return s
+}
+
+// ListRoles lists all roles with their permissions
+func ListRoles(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	roles, err := s.ListRoles()
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, roles)
+}
+
+// GetRole returns a role
+func GetRole(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	role, err := s.GetRole(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, role)
+}
+
+// CreateRole creates a custom role
+func CreateRole(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	var req rbac.CreateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	role, err := s.CreateRole(&req)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondCreated(w, role)
+}
+
+// UpdateRole renames or describes a custom role
+func UpdateRole(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	var req rbac.UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	role, err := s.UpdateRole(chi.URLParam(r, "id"), &req)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, role)
+}
+
+// DeleteRole deletes a custom role
+func DeleteRole(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	if err := s.DeleteRole(chi.URLParam(r, "id")); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// GetRolePermissions returns the permissions of a role
+func GetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	permissions, err := s.GetPermissions(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, permissions)
+}
+
+// SetRolePermissions replaces the permissions of a custom role
+func SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	var req SetRolePermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	permissions, err := s.SetPermissions(chi.URLParam(r, "id"), req.Permissions)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, permissions)
+}
+
+// ListUserRoles lists the roles assigned to a user
+func ListUserRoles(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	userID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid user ID", err))
+		return
+	}
+
+	assignments, err := s.GetUserRoles(uint(userID))
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, assignments)
+}
+
+// AssignUserRole grants a role to a user
+func AssignUserRole(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	userID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid user ID", err))
+		return
+	}
+
+	var req rbac.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	assignment, err := s.AssignRole(uint(userID), &req)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, assignment)
+}
+
+// RevokeUserRole removes a role from a user
+func RevokeUserRole(w http.ResponseWriter, r *http.Request) {
+	s := rbacService(w)
+	if s == nil {
+		return
+	}
+
+	userID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid user ID", err))
+		return
+	}
+	roleID, err := strconv.ParseUint(chi.URLParam(r, "roleId"), 10, 32)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid role ID", err))
+		return
+	}
+
+	if err := s.RevokeRole(uint(userID), uint(roleID)); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
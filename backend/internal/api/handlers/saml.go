@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+// SAMLConfigRequest is the request body of UpdateSAMLConfig
+type SAMLConfigRequest struct {
+	Enabled              bool     `json:"enabled"`
+	IdPMetadataURL       string   `json:"idpMetadataUrl"`
+	SPEntityID           string   `json:"spEntityId"`
+	AssertionConsumerURL string   `json:"assertionConsumerUrl"`
+	CertFile             string   `json:"certFile"`
+	KeyFile              string   `json:"keyFile"`
+	EmailAttribute       string   `json:"emailAttribute"`
+	GroupsAttribute      string   `json:"groupsAttribute"`
+	AdminGroups          []string `json:"adminGroups"`
+	DefaultRole          string   `json:"defaultRole" openapi:"summary=user or guest"`
+	AllowIdPInitiated    bool     `json:"allowIdpInitiated"`
+	MetadataRefreshHours int      `json:"metadataRefreshHours"`
+}
+
+// SAMLServiceProvider serves the SAML endpoints: the SP metadata, the login
+// redirect to the IdP and the assertion consumer service
+func SAMLServiceProvider(w http.ResponseWriter, r *http.Request) {
+	service := auth.GetSAMLService()
+	if service == nil {
+		utils.RespondError(w, errors.NotFound("SAML single sign-on is not enabled", nil))
+		return
+	}
+	provider, err := service.Provider()
+	if err != nil {
+		utils.RespondError(w, errors.NotFound(err.Error(), err))
+		return
+	}
+
+	provider.Middleware().ServeHTTP(w, r)
+}
+
+// GetSAMLConfig returns the SAML single sign-on configuration
+func GetSAMLConfig(w http.ResponseWriter, r *http.Request) {
+	service := auth.GetSAMLService()
+	if service == nil {
+		utils.RespondError(w, errors.InternalServerError("SAML service not available", nil))
+		return
+	}
+
+	utils.RespondSuccess(w, service.GetConfig())
+}
+
+// UpdateSAMLConfig replaces the SAML configuration. Enabling SAML fetches
+// the IdP metadata.
+func UpdateSAMLConfig(w http.ResponseWriter, r *http.Request) {
+	service := auth.GetSAMLService()
+	if service == nil {
+		utils.RespondError(w, errors.InternalServerError("SAML service not available", nil))
+		return
+	}
+
+	var req SAMLConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	cfg, err := service.UpdateConfig(r.Context(), models.SAMLConfig{
+		Enabled:              req.Enabled,
+		IdPMetadataURL:       req.IdPMetadataURL,
+		SPEntityID:           req.SPEntityID,
+		AssertionConsumerURL: req.AssertionConsumerURL,
+		CertFile:             req.CertFile,
+		KeyFile:              req.KeyFile,
+		EmailAttribute:       req.EmailAttribute,
+		GroupsAttribute:      req.GroupsAttribute,
+		AdminGroups:          req.AdminGroups,
+		DefaultRole:          req.DefaultRole,
+		AllowIdPInitiated:    req.AllowIdPInitiated,
+		MetadataRefreshHours: req.MetadataRefreshHours,
+	})
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	utils.RespondSuccess(w, cfg)
+}
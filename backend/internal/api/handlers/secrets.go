@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var secretProvider config.SecretProvider
+
+// InitSecretProvider initializes the provider storing config secrets
+func InitSecretProvider(p config.SecretProvider) {
+	secretProvider = p
+	logger.Info("Secret provider initialized")
+}
+
+// SetSecretRequest is the body of POST /admin/secrets
+type SetSecretRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func requireSecretProvider(w http.ResponseWriter) bool {
+	if secretProvider == nil {
+		utils.RespondError(w, errors.InternalServerError("Secret provider not available", nil))
+		return false
+	}
+	return true
+}
+
+// ListSecrets returns the keys of the stored secrets; values are never returned
+// GET /api/v1/admin/secrets/list
+func ListSecrets(w http.ResponseWriter, r *http.Request) {
+	if !requireSecretProvider(w) {
+		return
+	}
+
+	lister, ok := secretProvider.(config.SecretLister)
+	if !ok {
+		utils.RespondError(w, errors.BadRequest("The secret provider cannot list secrets", nil))
+		return
+	}
+
+	keys, err := lister.List()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list secrets", err))
+		return
+	}
+
+	utils.RespondSuccess(w, keys)
+}
+
+// SetSecret creates or replaces a secret. Config settings reference it as
+// {secret:key}; services pick up a new value when the server restarts.
+// POST /api/v1/admin/secrets
+func SetSecret(w http.ResponseWriter, r *http.Request) {
+	if !requireSecretProvider(w) {
+		return
+	}
+
+	var req SetSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := config.ValidateSecretKey(req.Key); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+	if req.Value == "" {
+		utils.RespondError(w, errors.BadRequest("value is required", nil))
+		return
+	}
+
+	if err := secretProvider.Set(req.Key, req.Value); err != nil {
+		logger.Error("Failed to store secret", zap.String("key", req.Key), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to store secret", err))
+		return
+	}
+
+	username := ""
+	if user := mw.GetUserFromContext(r.Context()); user != nil {
+		username = user.Username
+	}
+	logger.Info("Secret updated", zap.String("key", req.Key), zap.String("user", username))
+
+	utils.RespondSuccess(w, map[string]string{"key": req.Key})
+}
@@ -6,24 +6,33 @@ import (
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/telemetry"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 var setupValidator = validator.New()
 
 // SetupStatusResponse represents the setup status
 type SetupStatusResponse struct {
-	SetupRequired bool `json:"setupRequired"`
-	AdminExists   bool `json:"adminExists"`
+	SetupRequired  bool     `json:"setupRequired"` // The wizard has not been finalised
+	AdminExists    bool     `json:"adminExists"`
+	CompletedSteps []string `json:"completedSteps"`
+	NextStep       string   `json:"nextStep,omitempty"` // First wizard step not completed yet
 }
 
 // InitialSetupRequest represents the initial setup request
 type InitialSetupRequest struct {
-	Username  string `json:"username" validate:"required,min=3,max=50"`
-	Email     string `json:"email" validate:"required,email"`
-	Password  string `json:"password" validate:"required,min=8"`
-	FullName  string `json:"fullName" validate:"required,min=2,max=100"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8"`
+	FullName string `json:"fullName" validate:"required,min=2,max=100"`
+
+	// Opt in to anonymous usage statistics
+	EnableTelemetry bool `json:"enableTelemetry"`
 }
 
 // SetupStatus returns the current setup status
@@ -40,17 +49,67 @@ func SetupStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	state, err := loadSetupState(db)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error": map[string]string{
+				"code":    "DATABASE_ERROR",
+				"message": "Failed to load setup state",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
 	var count int64
 	db.Model(&models.User{}).Where("role = ?", "admin").Count(&count)
 
-	respondJSON(w, http.StatusOK, SetupStatusResponse{
-		SetupRequired: count == 0,
-		AdminExists:   count > 0,
-	})
+	response := SetupStatusResponse{
+		SetupRequired:  !state.Completed,
+		AdminExists:    count > 0,
+		CompletedSteps: state.CompletedSteps,
+	}
+	if !state.Completed {
+		for _, step := range setupWizardSteps() {
+			if !state.StepCompleted(step.Name) {
+				response.NextStep = step.Name
+				break
+			}
+		}
+	}
+	respondJSON(w, http.StatusOK, response)
 }
 
-// InitializeSetup creates the initial admin user
+// InitializeSetup creates the initial admin user and finalises the setup in
+// one step, skipping the optional wizard steps
 func InitializeSetup(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if !setupOpen(w, db) {
+		return
+	}
+
+	user := createInitialAdmin(w, r, db)
+	if user == nil {
+		return
+	}
+	if err := completeSetupStep(db, setupStepAdminAccount, true); err != nil {
+		logger.Error("Failed to finalise setup", zap.Error(err))
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data": map[string]string{
+			"message":  "Initial setup completed successfully",
+			"username": user.Username,
+			"email":    user.Email,
+		},
+	})
+}
+
+// createInitialAdmin creates the first admin user from the request body. It
+// writes the error response and returns nil if that fails.
+func createInitialAdmin(w http.ResponseWriter, r *http.Request, db *gorm.DB) *models.User {
 	var req InitialSetupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
@@ -61,7 +120,7 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 				"details": err.Error(),
 			},
 		})
-		return
+		return nil
 	}
 
 	// Validate request
@@ -74,19 +133,7 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 				"details": err.Error(),
 			},
 		})
-		return
-	}
-
-	db := database.GetDB()
-	if db == nil {
-		respondJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
-			"success": false,
-			"error": map[string]string{
-				"code":    "DATABASE_UNAVAILABLE",
-				"message": "Database connection not available",
-			},
-		})
-		return
+		return nil
 	}
 
 	// Check if admin already exists (prevent multiple initialization)
@@ -100,7 +147,7 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 				"message": "System has already been initialized",
 			},
 		})
-		return
+		return nil
 	}
 
 	// Check if username already exists
@@ -113,7 +160,7 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 				"message": "Username already exists",
 			},
 		})
-		return
+		return nil
 	}
 
 	// Check if email already exists
@@ -125,7 +172,7 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 				"message": "Email already exists",
 			},
 		})
-		return
+		return nil
 	}
 
 	// Create admin user
@@ -147,7 +194,7 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 				"details": err.Error(),
 			},
 		})
-		return
+		return nil
 	}
 
 	// Save user to database
@@ -160,7 +207,7 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 				"details": err.Error(),
 			},
 		})
-		return
+		return nil
 	}
 
 	// Create Samba user for network share access
@@ -171,14 +218,18 @@ func InitializeSetup(w http.ResponseWriter, r *http.Request) {
 		// TODO: Consider displaying this warning in the UI
 	}
 
-	respondJSON(w, http.StatusCreated, map[string]interface{}{
-		"success": true,
-		"data": map[string]string{
-			"message":  "Initial setup completed successfully",
-			"username": user.Username,
-			"email":    user.Email,
-		},
-	})
+	if req.EnableTelemetry {
+		t, err := telemetry.Load(db)
+		if err == nil {
+			t.Enabled = true
+			err = t.Save()
+		}
+		if err != nil {
+			logger.Warn("Failed to enable telemetry during setup", zap.Error(err))
+		}
+	}
+
+	return &user
 }
 
 // respondJSON is a helper function to send JSON responses
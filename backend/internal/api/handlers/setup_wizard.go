@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth/rbac"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Setup wizard steps
+const (
+	setupStepAdminAccount  = "admin-account"
+	setupStepNetworkConfig = "network-config"
+	setupStepStorageConfig = "storage-config"
+	setupStepShareConfig   = "share-config"
+	setupStepSMTPConfig    = "smtp-config"
+)
+
+// WizardStep is one step of the first-time setup. Its Handler applies the
+// config data of the step; a successful response completes the step.
+type WizardStep struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Completed   bool             `json:"completed"`
+	Required    bool             `json:"required"` // Must be completed before setup can be finalised
+	Handler     http.HandlerFunc `json:"-"`
+}
+
+// SetupWizard is the first-time setup, in the order the steps are shown
+type SetupWizard struct {
+	Steps []WizardStep `json:"steps"`
+}
+
+// SetupNetworkRequest is the config data of the network-config step
+type SetupNetworkRequest struct {
+	Interface     string   `json:"interface"` // Left unchanged if empty
+	Mode          string   `json:"mode"`      // static or dhcp
+	Address       string   `json:"address"`   // for static
+	Netmask       string   `json:"netmask"`   // for static
+	Gateway       string   `json:"gateway"`   // for static
+	Nameservers   []string `json:"nameservers"`
+	SearchDomains []string `json:"searchDomains"`
+}
+
+// SetupStorageRequest is the config data of the storage-config step. Without
+// a pool name the existing storage is kept.
+type SetupStorageRequest struct {
+	PoolName string   `json:"poolName"`
+	RaidType string   `json:"raidType"` // mirror, raidz, raidz2, raidz3 or empty for a stripe
+	Devices  []string `json:"devices"`
+}
+
+// SetupSMTPRequest is the config data of the smtp-config step
+type SetupSMTPRequest struct {
+	SMTPHost       string `json:"smtpHost" validate:"required"`
+	SMTPPort       int    `json:"smtpPort" validate:"required,min=1,max=65535"`
+	SMTPUsername   string `json:"smtpUsername"`
+	SMTPPassword   string `json:"smtpPassword"`
+	SMTPFromEmail  string `json:"smtpFromEmail" validate:"required,email"`
+	SMTPFromName   string `json:"smtpFromName"`
+	SMTPUseTLS     bool   `json:"smtpUseTLS"`
+	AlertRecipient string `json:"alertRecipient" validate:"required,email"`
+}
+
+// Applied by the setup steps. Replaced in tests.
+var (
+	configureSetupInterface = func(req SetupNetworkRequest) error {
+		if req.Mode == "dhcp" {
+			return network.ConfigureDHCP(req.Interface)
+		}
+		return network.ConfigureIPAddress(req.Interface, req.Address, req.Netmask, req.Gateway, "", "")
+	}
+	setSetupDNS     = network.SetDNSConfig
+	createSetupPool = func(req SetupStorageRequest) error {
+		lib := system.Get()
+		if lib == nil || lib.Storage == nil || lib.Storage.ZFS == nil {
+			return fmt.Errorf("ZFS not available")
+		}
+		return lib.Storage.ZFS.CreatePool(req.PoolName, req.RaidType, req.Devices, nil)
+	}
+	createSetupShare = func(ctx context.Context, req *storage.CreateShareRequest) error {
+		_, err := storage.CreateShare(ctx, req)
+		return err
+	}
+	saveSetupSMTPConfig = func(ctx context.Context, req SetupSMTPRequest) error {
+		service := alerts.GetService()
+		if service == nil {
+			return fmt.Errorf("alert service not available")
+		}
+		config, err := service.GetConfig(ctx)
+		if err != nil {
+			return err
+		}
+		config.Enabled = true
+		config.SMTPHost = req.SMTPHost
+		config.SMTPPort = req.SMTPPort
+		config.SMTPUsername = req.SMTPUsername
+		config.SMTPPassword = req.SMTPPassword
+		config.SMTPFromEmail = req.SMTPFromEmail
+		config.SMTPFromName = req.SMTPFromName
+		config.SMTPUseTLS = req.SMTPUseTLS
+		config.AlertRecipient = req.AlertRecipient
+		return service.UpdateConfig(ctx, config)
+	}
+)
+
+// setupWizardSteps returns the steps of the wizard, none completed
+func setupWizardSteps() []WizardStep {
+	return []WizardStep{
+		{Name: setupStepAdminAccount, Description: "Create the administrator account", Required: true, Handler: setupAdminAccount},
+		{Name: setupStepNetworkConfig, Description: "Configure the network interface and DNS servers", Handler: setupNetwork},
+		{Name: setupStepStorageConfig, Description: "Create a storage pool for the data", Handler: setupStorage},
+		{Name: setupStepShareConfig, Description: "Create the first network share", Handler: setupShare},
+		{Name: setupStepSMTPConfig, Description: "Configure the mail server for alert emails", Handler: setupSMTP},
+	}
+}
+
+// newSetupWizard returns the wizard with the progress of state
+func newSetupWizard(state *models.SetupState) *SetupWizard {
+	wizard := &SetupWizard{Steps: setupWizardSteps()}
+	for i := range wizard.Steps {
+		wizard.Steps[i].Completed = state.StepCompleted(wizard.Steps[i].Name)
+	}
+	return wizard
+}
+
+// step returns the step with the given name, or nil
+func (s *SetupWizard) step(name string) *WizardStep {
+	for i := range s.Steps {
+		if s.Steps[i].Name == name {
+			return &s.Steps[i]
+		}
+	}
+	return nil
+}
+
+// loadSetupState returns the wizard progress. Systems set up before the
+// wizard had steps have an admin but no state; their setup is complete.
+func loadSetupState(db *gorm.DB) (*models.SetupState, error) {
+	var state models.SetupState
+	err := db.First(&state).Error
+	if err != gorm.ErrRecordNotFound {
+		return &state, err
+	}
+
+	var admins int64
+	if err := db.Model(&models.User{}).Where("role = ?", "admin").Count(&admins).Error; err != nil {
+		return nil, err
+	}
+	state.CompletedSteps = []string{}
+	if admins > 0 {
+		now := time.Now().UTC()
+		state.CompletedSteps = []string{setupStepAdminAccount}
+		state.Completed = true
+		state.CompletedAt = &now
+	}
+	if err := db.Create(&state).Error; err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// completeSetupStep records a completed step, finalising the setup if
+// finalise is set
+func completeSetupStep(db *gorm.DB, name string, finalise bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		state, err := loadSetupState(tx)
+		if err != nil {
+			return err
+		}
+		if name != "" && !state.StepCompleted(name) {
+			state.CompletedSteps = append(state.CompletedSteps, name)
+		}
+		if finalise {
+			now := time.Now().UTC()
+			state.Completed = true
+			state.CompletedAt = &now
+		}
+		return tx.Save(state).Error
+	})
+}
+
+// setupOpen writes an error response and returns false once the setup has
+// been finalised, which closes the wizard
+func setupOpen(w http.ResponseWriter, db *gorm.DB) bool {
+	if db == nil {
+		respondJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"success": false,
+			"error": map[string]string{
+				"code":    "DATABASE_UNAVAILABLE",
+				"message": "Database connection not available",
+			},
+		})
+		return false
+	}
+
+	state, err := loadSetupState(db)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error": map[string]string{
+				"code":    "DATABASE_ERROR",
+				"message": "Failed to load setup state",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+	if state.Completed {
+		respondJSON(w, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error": map[string]string{
+				"code":    "SETUP_COMPLETED",
+				"message": "Setup has already been completed",
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// ListSetupSteps returns the setup wizard steps and their completion
+func ListSetupSteps(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if !setupOpen(w, db) {
+		return
+	}
+
+	state, err := loadSetupState(db)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to load setup state", err))
+		return
+	}
+
+	utils.RespondSuccess(w, newSetupWizard(state))
+}
+
+// setupStepRecorder records the status of a step response
+type setupStepRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *setupStepRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *setupStepRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// UpdateSetupStep applies the config data of a wizard step and marks it
+// completed. The admin account is created first; the other steps require
+// the admin to be logged in.
+func UpdateSetupStep(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if !setupOpen(w, db) {
+		return
+	}
+
+	state, err := loadSetupState(db)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to load setup state", err))
+		return
+	}
+	name := chi.URLParam(r, "name")
+	step := newSetupWizard(state).step(name)
+	if step == nil {
+		utils.RespondError(w, errors.NotFound("Unknown setup step: "+name, nil))
+		return
+	}
+
+	handler := http.Handler(step.Handler)
+	if name == setupStepAdminAccount {
+		if step.Completed {
+			utils.RespondError(w, errors.Conflict("The admin account has already been created", nil))
+			return
+		}
+	} else {
+		if !state.StepCompleted(setupStepAdminAccount) {
+			utils.RespondError(w, errors.Conflict("Create the admin account first", nil))
+			return
+		}
+		handler = middleware.AuthMiddleware(rbac.RequirePermission("system", "update")(handler))
+	}
+
+	rec := &setupStepRecorder{ResponseWriter: w}
+	handler.ServeHTTP(rec, r)
+	if rec.status < 200 || rec.status >= 300 {
+		return
+	}
+	if err := completeSetupStep(db, name, false); err != nil {
+		logger.Error("Failed to record setup step", zap.String("step", name), zap.Error(err))
+	}
+}
+
+// CompleteSetup finalises the setup once the required steps are complete.
+// Afterwards the setup endpoints are closed.
+func CompleteSetup(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if !setupOpen(w, db) {
+		return
+	}
+
+	state, err := loadSetupState(db)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to load setup state", err))
+		return
+	}
+	for _, step := range newSetupWizard(state).Steps {
+		if step.Required && !step.Completed {
+			utils.RespondError(w, errors.Conflict("Setup step "+step.Name+" is required", nil))
+			return
+		}
+	}
+
+	if err := completeSetupStep(db, "", true); err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to complete setup", err))
+		return
+	}
+
+	logger.Info("Setup completed", zap.Strings("steps", state.CompletedSteps))
+	utils.RespondSuccess(w, map[string]string{"message": "Setup completed"})
+}
+
+func setupAdminAccount(w http.ResponseWriter, r *http.Request) {
+	user := createInitialAdmin(w, r, database.GetDB())
+	if user == nil {
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"data": map[string]string{
+			"message":  "Admin account created",
+			"username": user.Username,
+			"email":    user.Email,
+		},
+	})
+}
+
+func setupNetwork(w http.ResponseWriter, r *http.Request) {
+	var req SetupNetworkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	if req.Interface != "" {
+		if req.Mode != "static" && req.Mode != "dhcp" {
+			utils.RespondError(w, errors.BadRequest("Mode must be static or dhcp", nil))
+			return
+		}
+		if req.Mode == "static" && (req.Address == "" || req.Netmask == "") {
+			utils.RespondError(w, errors.BadRequest("Static configuration requires an address and netmask", nil))
+			return
+		}
+		if err := configureSetupInterface(req); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to configure interface", err))
+			return
+		}
+	}
+	if len(req.Nameservers) > 0 {
+		if err := setSetupDNS(req.Nameservers, req.SearchDomains); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to set DNS config", err))
+			return
+		}
+	}
+
+	utils.RespondSuccess(w, map[string]string{"message": "Network configured"})
+}
+
+func setupStorage(w http.ResponseWriter, r *http.Request) {
+	var req SetupStorageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	if req.PoolName != "" {
+		if len(req.Devices) == 0 {
+			utils.RespondError(w, errors.BadRequest("A pool requires at least one device", nil))
+			return
+		}
+		if err := createSetupPool(req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Failed to create pool", err))
+			return
+		}
+	}
+
+	utils.RespondSuccess(w, map[string]string{"message": "Storage configured"})
+}
+
+func setupShare(w http.ResponseWriter, r *http.Request) {
+	var req storage.CreateShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+	if err := setupValidator.Struct(req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid share", err))
+		return
+	}
+
+	if err := createSetupShare(r.Context(), &req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to create share", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{"message": "Share created"})
+}
+
+func setupSMTP(w http.ResponseWriter, r *http.Request) {
+	var req SetupSMTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+	if err := setupValidator.Struct(req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid SMTP config", err))
+		return
+	}
+
+	if err := saveSetupSMTPConfig(r.Context(), req); err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to save SMTP config", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{"message": "SMTP configured"})
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSetupWizard(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "setup.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.SetupState{}); err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	realConfig := config.GlobalConfig
+	config.GlobalConfig = &config.Config{Auth: config.AuthConfig{
+		JWTSecret:          "test-secret-key-for-jwt-testing-minimum-32-characters-long",
+		JWTExpirationHours: 1,
+		JWTRefreshHours:    24,
+	}}
+
+	var applied []string
+	realInterface, realDNS, realPool, realShare, realSMTP := configureSetupInterface, setSetupDNS, createSetupPool, createSetupShare, saveSetupSMTPConfig
+	configureSetupInterface = func(req SetupNetworkRequest) error {
+		applied = append(applied, "interface "+req.Interface+" "+req.Address)
+		return nil
+	}
+	setSetupDNS = func(nameservers, search []string) error {
+		applied = append(applied, "dns "+nameservers[0])
+		return nil
+	}
+	createSetupPool = func(req SetupStorageRequest) error {
+		applied = append(applied, "pool "+req.PoolName)
+		return nil
+	}
+	createSetupShare = func(ctx context.Context, req *storage.CreateShareRequest) error {
+		applied = append(applied, "share "+req.Name)
+		return nil
+	}
+	saveSetupSMTPConfig = func(ctx context.Context, req SetupSMTPRequest) error {
+		applied = append(applied, "smtp "+req.SMTPHost)
+		return nil
+	}
+	t.Cleanup(func() {
+		database.DB = nil
+		config.GlobalConfig = realConfig
+		configureSetupInterface, setSetupDNS, createSetupPool, createSetupShare, saveSetupSMTPConfig = realInterface, realDNS, realPool, realShare, realSMTP
+	})
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/setup/status", SetupStatus)
+	r.Post("/api/v1/setup/initialize", InitializeSetup)
+	r.Get("/api/v1/setup/steps", ListSetupSteps)
+	r.Put("/api/v1/setup/steps/{name}", UpdateSetupStep)
+	r.Post("/api/v1/setup/complete", CompleteSetup)
+
+	token := ""
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&buf).Encode(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	status := func() SetupStatusResponse {
+		t.Helper()
+		rec := request(http.MethodGet, "/api/v1/setup/status", nil)
+		var resp SetupStatusResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if s := status(); !s.SetupRequired || s.AdminExists || s.NextStep != setupStepAdminAccount {
+		t.Fatalf("initial status = %+v, want setup required starting at admin-account", s)
+	}
+
+	// The other steps need the admin account
+	if rec := request(http.MethodPut, "/api/v1/setup/steps/network-config", SetupNetworkRequest{}); rec.Code != http.StatusConflict {
+		t.Errorf("network-config before admin-account: %d, want 409", rec.Code)
+	}
+	if rec := request(http.MethodPut, "/api/v1/setup/steps/unknown", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown step: %d, want 404", rec.Code)
+	}
+
+	rec := request(http.MethodPut, "/api/v1/setup/steps/admin-account", InitialSetupRequest{
+		Username: "admin",
+		Email:    "admin@example.com",
+		Password: "correct-horse-battery",
+		FullName: "Admin",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("admin-account: %d %s", rec.Code, rec.Body)
+	}
+	if rec := request(http.MethodPut, "/api/v1/setup/steps/admin-account", nil); rec.Code != http.StatusConflict {
+		t.Errorf("second admin-account: %d, want 409", rec.Code)
+	}
+
+	// Without the admin's token the steps are rejected
+	if rec := request(http.MethodPut, "/api/v1/setup/steps/network-config", SetupNetworkRequest{}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("network-config without token: %d, want 401", rec.Code)
+	}
+
+	var admin models.User
+	if err := db.Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	if token, err = users.GenerateToken(&admin); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		body interface{}
+	}{
+		{setupStepNetworkConfig, SetupNetworkRequest{Interface: "eth0", Mode: "static", Address: "192.168.1.10", Netmask: "255.255.255.0", Nameservers: []string{"192.168.1.1"}}},
+		{setupStepStorageConfig, SetupStorageRequest{PoolName: "tank", RaidType: "mirror", Devices: []string{"/dev/sdb", "/dev/sdc"}}},
+		{setupStepShareConfig, storage.CreateShareRequest{Name: "data", Path: "/mnt/tank/data", Type: "smb"}},
+		{setupStepSMTPConfig, SetupSMTPRequest{SMTPHost: "mail.example.com", SMTPPort: 587, SMTPFromEmail: "nas@example.com", AlertRecipient: "admin@example.com"}},
+	}
+	completed := []string{setupStepAdminAccount}
+	for i, step := range steps {
+		if s := status(); s.NextStep != step.name || !reflect.DeepEqual(s.CompletedSteps, completed) {
+			t.Errorf("status before %s = %+v, want next step %s after %v", step.name, s, step.name, completed)
+		}
+		if rec := request(http.MethodPut, "/api/v1/setup/steps/"+step.name, step.body); rec.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", step.name, rec.Code, rec.Body)
+		}
+		completed = append(completed, step.name)
+		if i == 0 {
+			// A failed step is not completed
+			if rec := request(http.MethodPut, "/api/v1/setup/steps/"+setupStepStorageConfig, SetupStorageRequest{PoolName: "tank"}); rec.Code != http.StatusBadRequest {
+				t.Errorf("storage-config without devices: %d, want 400", rec.Code)
+			}
+		}
+	}
+
+	wantApplied := []string{"interface eth0 192.168.1.10", "dns 192.168.1.1", "pool tank", "share data", "smtp mail.example.com"}
+	if !reflect.DeepEqual(applied, wantApplied) {
+		t.Errorf("applied = %v, want %v", applied, wantApplied)
+	}
+
+	rec = request(http.MethodGet, "/api/v1/setup/steps", nil)
+	var list struct {
+		Data SetupWizard `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range list.Data.Steps {
+		if !step.Completed {
+			t.Errorf("step %s is not completed", step.Name)
+		}
+	}
+	if s := status(); !s.SetupRequired || s.NextStep != "" || !reflect.DeepEqual(s.CompletedSteps, completed) {
+		t.Errorf("status after all steps = %+v, want setup required with no next step", s)
+	}
+
+	if rec := request(http.MethodPost, "/api/v1/setup/complete", nil); rec.Code != http.StatusOK {
+		t.Fatalf("complete: %d %s", rec.Code, rec.Body)
+	}
+	if s := status(); s.SetupRequired || !s.AdminExists {
+		t.Errorf("status after completion = %+v, want setup done", s)
+	}
+
+	// Completing the setup closes the wizard
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/setup/steps"},
+		{http.MethodPut, "/api/v1/setup/steps/network-config"},
+		{http.MethodPost, "/api/v1/setup/complete"},
+		{http.MethodPost, "/api/v1/setup/initialize"},
+	} {
+		if rec := request(req.method, req.path, SetupNetworkRequest{}); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s after completion: %d, want 403", req.method, req.path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/files"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// ListShareSnapshots lists the ZFS snapshots of a share
+// GET /api/v1/storage/shares/{id}/snapshots
+func ListShareSnapshots(w http.ResponseWriter, r *http.Request) {
+	share, ok := shareFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Only users who may read the share see its snapshots
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+	if _, err := fileService.CheckReadPermission(ctx, share.Path); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	snapshots, err := storage.NewShareSnapshotBrowser(database.GetDB()).ListSnapshots(share.Name)
+	if err != nil {
+		respondSnapshotError(w, "Failed to list snapshots", share.Name, err)
+		return
+	}
+
+	utils.RespondSuccess(w, snapshots)
+}
+
+// BrowseShareSnapshot lists a directory of a share as it was in a snapshot.
+// The path query parameter is relative to the share.
+// GET /api/v1/storage/shares/{id}/snapshots/{snap}/browse?path=/docs
+func BrowseShareSnapshot(w http.ResponseWriter, r *http.Request) {
+	share, ok := shareFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	path, err := storage.NewShareSnapshotBrowser(database.GetDB()).SnapshotPath(share.Name, chi.URLParam(r, "snap"), r.URL.Query().Get("path"))
+	if err != nil {
+		respondSnapshotError(w, "Failed to browse snapshot", share.Name, err)
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	// Browse the snapshot like any directory in the share, with the same
+	// permission checks
+	result, err := fileService.Browse(ctx, &files.BrowseRequest{
+		Path:       path,
+		ShowHidden: r.URL.Query().Get("showHidden") == "true",
+	})
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, result)
+}
+
+// RestoreShareSnapshotFile copies a file from a snapshot back into the share
+// POST /api/v1/storage/shares/{id}/snapshots/{snap}/restore
+func RestoreShareSnapshotFile(w http.ResponseWriter, r *http.Request) {
+	share, ok := shareFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req storage.RestoreFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.FilePath == "" {
+		utils.RespondError(w, errors.BadRequest("filePath is required", nil))
+		return
+	}
+
+	ctx, err := getSecurityContext(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+	target := req.RestoreTo
+	if target == "" {
+		target = req.FilePath
+	}
+	if err := fileService.CheckWritePermission(ctx, filepath.Join(share.Path, target)); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	snapshot := chi.URLParam(r, "snap")
+	if err := storage.NewShareSnapshotBrowser(database.GetDB()).RestoreFileFromSnapshot(share.Name, snapshot, req.FilePath, req.RestoreTo); err != nil {
+		respondSnapshotError(w, "Failed to restore file from snapshot", share.Name, err)
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "File restored from snapshot " + snapshot,
+	})
+}
+
+// shareFromRequest looks up the share in the id URL parameter, responding
+// with 404 if it does not exist
+func shareFromRequest(w http.ResponseWriter, r *http.Request) (*storage.Share, bool) {
+	shareID := chi.URLParam(r, "id")
+	share, err := storage.GetShare(shareID)
+	if err != nil {
+		utils.RespondError(w, errors.NotFound("Share not found", err))
+		return nil, false
+	}
+	return share, true
+}
+
+func respondSnapshotError(w http.ResponseWriter, message, share string, err error) {
+	switch {
+	case stderrors.Is(err, storage.ErrShareNotFound):
+		utils.RespondError(w, errors.NotFound("Share not found", err))
+	case stderrors.Is(err, storage.ErrSnapshotsUnavailable):
+		utils.RespondError(w, errors.BadRequest("Share is not the root of a ZFS dataset", err))
+	case stderrors.Is(err, storage.ErrSnapshotNotFound):
+		utils.RespondError(w, errors.NotFound("Snapshot or file not found", err))
+	case stderrors.Is(err, storage.ErrInvalidSnapshotPath):
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+	default:
+		logger.Error(message, zap.String("share", share), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError(message, err))
+	}
+}
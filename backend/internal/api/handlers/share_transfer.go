@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ShareTransferRequest is the body of a share transfer request
+type ShareTransferRequest struct {
+	DestinationVolumeID string `json:"destinationVolumeId"`
+	PreservePermissions bool   `json:"preservePermissions"`
+	DeleteAfterTransfer bool   `json:"deleteAfterTransfer"`
+}
+
+// StartShareTransfer moves a share's data to another volume in the background
+// POST /api/v1/storage/shares/{id}/transfer
+func StartShareTransfer(w http.ResponseWriter, r *http.Request) {
+	shareID := chi.URLParam(r, "id")
+
+	var req ShareTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.DestinationVolumeID == "" {
+		utils.RespondError(w, errors.BadRequest("destinationVolumeId is required", nil))
+		return
+	}
+
+	job := storage.ShareTransferJob{
+		ShareID:             shareID,
+		DestinationVolumeID: req.DestinationVolumeID,
+		PreservePermissions: req.PreservePermissions,
+		DeleteAfterTransfer: req.DeleteAfterTransfer,
+	}
+	jobID, err := job.Start()
+	if err != nil {
+		switch {
+		case stderrors.Is(err, storage.ErrShareNotFound):
+			utils.RespondError(w, errors.NotFound("Share not found", err))
+		case stderrors.Is(err, storage.ErrTransferRunning):
+			utils.RespondError(w, errors.Conflict("Share is already being transferred", err))
+		default:
+			logger.Error("Failed to start share transfer", zap.String("share", shareID), zap.Error(err))
+			utils.RespondError(w, errors.BadRequest("Failed to start share transfer: "+err.Error(), err))
+		}
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusAccepted, map[string]string{
+		"jobId":   jobID,
+		"shareId": shareID,
+	})
+}
+
+// GetShareTransferProgress returns the progress of a share transfer
+// GET /api/v1/storage/shares/{id}/transfer/{jobId}/progress
+func GetShareTransferProgress(w http.ResponseWriter, r *http.Request) {
+	progress, err := storage.GetTransferProgress(chi.URLParam(r, "jobId"))
+	if err == nil && progress.ShareID != chi.URLParam(r, "id") {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			utils.RespondError(w, errors.NotFound("Share transfer not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get share transfer", err))
+		return
+	}
+
+	utils.RespondSuccess(w, progress)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var smartTestScheduler *storage.SMARTTestScheduler
+
+// InitSMARTTestScheduler initializes the SMART self-test scheduler
+func InitSMARTTestScheduler(scheduler *storage.SMARTTestScheduler) {
+	smartTestScheduler = scheduler
+	logger.Info("SMART test scheduler initialized in handlers")
+}
+
+// SMARTScheduleRequest is the request body of SetSMARTTestSchedule. An empty
+// schedule disables that test.
+type SMARTScheduleRequest struct {
+	Short string `json:"short"` // Cron expression
+	Long  string `json:"long"`  // Cron expression
+}
+
+func smartSchedulerUnavailable(w http.ResponseWriter) bool {
+	if smartTestScheduler == nil {
+		utils.RespondError(w, errors.NewAppError(
+			http.StatusServiceUnavailable,
+			"SMART test scheduler not available",
+			nil,
+		))
+		return true
+	}
+	return false
+}
+
+// GetSMARTTestHistory returns the self-test log of a disk
+func GetSMARTTestHistory(w http.ResponseWriter, r *http.Request) {
+	if smartSchedulerUnavailable(w) {
+		return
+	}
+	device := chi.URLParam(r, "device")
+
+	history, err := smartTestScheduler.GetTestHistory(device)
+	if err != nil {
+		logger.Error("Failed to get SMART test history", zap.String("device", device), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to get SMART test history", err))
+		return
+	}
+
+	utils.RespondSuccess(w, history)
+}
+
+// GetSMARTTestSchedule returns the self-test schedule of a disk
+func GetSMARTTestSchedule(w http.ResponseWriter, r *http.Request) {
+	if smartSchedulerUnavailable(w) {
+		return
+	}
+
+	schedule, err := smartTestScheduler.GetSchedule(chi.URLParam(r, "device"))
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to get SMART test schedule", err))
+		return
+	}
+
+	utils.RespondSuccess(w, schedule)
+}
+
+// SetSMARTTestSchedule replaces the self-test schedule of a disk
+func SetSMARTTestSchedule(w http.ResponseWriter, r *http.Request) {
+	if smartSchedulerUnavailable(w) {
+		return
+	}
+	device := chi.URLParam(r, "device")
+
+	var req SMARTScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	if err := smartTestScheduler.ScheduleShortTest(device, req.Short); err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to set SMART test schedule", err))
+		return
+	}
+	if err := smartTestScheduler.ScheduleLongTest(device, req.Long); err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to set SMART test schedule", err))
+		return
+	}
+
+	schedule, err := smartTestScheduler.GetSchedule(device)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get SMART test schedule", err))
+		return
+	}
+
+	utils.RespondSuccess(w, schedule)
+}
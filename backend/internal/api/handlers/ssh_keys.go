@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// AddSSHKeyRequest represents a request to authorize an SSH public key
+type AddSSHKeyRequest struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// UpdateSSHKeyRequest represents a request to relabel an SSH key
+type UpdateSSHKeyRequest struct {
+	Label string `json:"label"`
+}
+
+// ListSSHKeys returns the SSH keys of a user
+func ListSSHKeys(w http.ResponseWriter, r *http.Request) {
+	user, err := sshKeyUser(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	keys, err := users.GetSSHKeyManager().ListKeys(user.Username)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, keys)
+}
+
+// AddSSHKey authorizes an SSH public key for a user
+func AddSSHKey(w http.ResponseWriter, r *http.Request) {
+	user, err := sshKeyUser(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	var req AddSSHKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	key, err := users.GetSSHKeyManager().AddKey(user.Username, req.Key, req.Label)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondCreated(w, key)
+}
+
+// UpdateSSHKey changes the label of an SSH key
+func UpdateSSHKey(w http.ResponseWriter, r *http.Request) {
+	key, err := sshKeyOfUser(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	var req UpdateSSHKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	key, err = users.GetSSHKeyManager().UpdateLabel(key.ID, req.Label)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, key)
+}
+
+// DeleteSSHKey revokes an SSH key
+func DeleteSSHKey(w http.ResponseWriter, r *http.Request) {
+	key, err := sshKeyOfUser(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	if err := users.GetSSHKeyManager().DeleteKey(key.ID); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "SSH key deleted successfully",
+	})
+}
+
+// sshKeyUser returns the user named by the {id} URL parameter
+func sshKeyUser(r *http.Request) (*users.User, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		return nil, errors.BadRequest("Invalid user ID", err)
+	}
+	return users.GetUserByID(uint(id))
+}
+
+// sshKeyOfUser returns the {keyId} key, which must belong to the {id} user
+func sshKeyOfUser(r *http.Request) (*users.SSHKey, error) {
+	user, err := sshKeyUser(r)
+	if err != nil {
+		return nil, err
+	}
+	key, err := users.GetSSHKeyManager().GetKey(chi.URLParam(r, "keyId"))
+	if err != nil {
+		return nil, err
+	}
+	if key.UserID != user.ID {
+		return nil, errors.NotFound("SSH key not found", nil)
+	}
+	return key, nil
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/lifecycle"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+var startupSequencer *lifecycle.StartupSequencer
+
+// InitStartupSequencer sets the sequencer whose progress is reported by
+// GetStartupStatus and HealthCheck
+func InitStartupSequencer(sequencer *lifecycle.StartupSequencer) {
+	startupSequencer = sequencer
+}
+
+// startupReady reports whether the services have finished starting. Without
+// a sequencer the server was started without one and is ready.
+func startupReady() bool {
+	return startupSequencer == nil || startupSequencer.Ready()
+}
+
+// GetStartupStatus returns which startup stages are complete, failed or
+// still running
+func GetStartupStatus(w http.ResponseWriter, r *http.Request) {
+	if startupSequencer == nil {
+		utils.RespondError(w, errors.NewAppError(
+			http.StatusServiceUnavailable,
+			"Startup status not available",
+			nil,
+		))
+		return
+	}
+	utils.RespondSuccess(w, startupSequencer.Status())
+}
@@ -4,11 +4,13 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/cache"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
@@ -174,6 +176,30 @@ func GetVolume(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, volume)
 }
 
+// GetVolumeHealthHistory returns the latest canary file checks of a volume
+func GetVolumeHealthHistory(w http.ResponseWriter, r *http.Request) {
+	volumeID := chi.URLParam(r, "id")
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			utils.RespondError(w, errors.BadRequest("Invalid limit", err))
+			return
+		}
+		limit = n
+	}
+
+	checks, err := storage.GetVolumeHealthHistory(volumeID, limit)
+	if err != nil {
+		logger.Error("Failed to get volume health history", zap.String("id", volumeID), zap.Error(err))
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, checks)
+}
+
 // CreateVolume creates a new storage volume
 func CreateVolume(w http.ResponseWriter, r *http.Request) {
 	var req storage.CreateVolumeRequest
@@ -288,13 +314,14 @@ func CreateShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	share, err := storage.CreateShare(&req)
+	share, err := storage.CreateShare(r.Context(), &req)
 	if err != nil {
 		logger.Error("Failed to create share", zap.String("name", req.Name), zap.Error(err))
 		utils.RespondError(w, errors.InternalServerError("Failed to create share", err))
 		return
 	}
 
+	publishShareEvent("created", share.ID, share.Name)
 	utils.RespondSuccess(w, share)
 }
 
@@ -315,6 +342,7 @@ func UpdateShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	publishShareEvent("updated", share.ID, share.Name)
 	utils.RespondSuccess(w, share)
 }
 
@@ -328,11 +356,27 @@ func DeleteShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	publishShareEvent("deleted", shareID, "")
 	utils.RespondSuccess(w, map[string]string{
 		"message": "Share deleted successfully",
 	})
 }
 
+// publishShareEvent notifies event stream subscribers about a share change
+func publishShareEvent(action, id, name string) {
+	label := name
+	if label == "" {
+		label = id
+	}
+	events.Publish(events.Event{
+		Type:    events.TypeShare,
+		Action:  action,
+		Source:  "storage",
+		Message: "Share " + label + " " + action,
+		Data:    map[string]interface{}{"id": id, "name": name},
+	})
+}
+
 // EnableShare enables a network share
 func EnableShare(w http.ResponseWriter, r *http.Request) {
 	shareID := chi.URLParam(r, "id")
@@ -363,6 +407,40 @@ func DisableShare(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetShareAccessLog retrieves the client connections to a share, newest first
+// GET /api/v1/storage/shares/{id}/access-log?limit=100&offset=0
+func GetShareAccessLog(w http.ResponseWriter, r *http.Request) {
+	shareID := chi.URLParam(r, "id")
+
+	limit, offset := 100, 0
+	query := r.URL.Query()
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			utils.RespondError(w, errors.BadRequest("Invalid limit", err))
+			return
+		}
+		limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			utils.RespondError(w, errors.BadRequest("Invalid offset", err))
+			return
+		}
+		offset = n
+	}
+
+	page, err := storage.GetShareAccessLog(shareID, limit, offset)
+	if err != nil {
+		logger.Error("Failed to get share access log", zap.String("id", shareID), zap.Error(err))
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, page)
+}
+
 // ===== Storage Statistics Handlers =====
 
 // GetStorageStats retrieves overall storage statistics
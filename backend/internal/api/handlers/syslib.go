@@ -5,11 +5,14 @@ import (
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/sharing"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/network"
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/storage"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
@@ -253,6 +256,290 @@ func ListZFSSnapshots(w http.ResponseWriter, r *http.Request) {
 	utils.RespondSuccess(w, snapshots)
 }
 
+// ScrubScheduleRequest is the request body of SetZFSScrubSchedule
+type ScrubScheduleRequest struct {
+	Schedule string `json:"schedule"` // Standard cron expression, e.g. "0 2 1 * *"
+}
+
+// ScrubScheduleResponse is the scrub schedule and last scrub of a pool
+type ScrubScheduleResponse struct {
+	Pool      string               `json:"pool"`
+	Schedule  string               `json:"schedule"` // Empty without a schedule
+	LastScrub *storage.ScrubResult `json:"last_scrub,omitempty"`
+}
+
+// GetZFSScrubSchedule returns the scrub schedule and last scrub result of a pool
+func GetZFSScrubSchedule(w http.ResponseWriter, r *http.Request) {
+	pool := chi.URLParam(r, "name")
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	schedule, err := lib.Storage.ZFS.GetScrubSchedule(pool)
+	if err != nil && !stderrors.Is(err, storage.ErrScrubScheduleNotFound) {
+		utils.RespondError(w, errors.BadRequest("Failed to get scrub schedule", err))
+		return
+	}
+
+	lastScrub, err := lib.Storage.ZFS.GetLastScrubResult(pool)
+	if err != nil {
+		logger.Error("Failed to get last scrub result", zap.String("pool", pool), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to get last scrub result", err))
+		return
+	}
+
+	utils.RespondSuccess(w, ScrubScheduleResponse{Pool: pool, Schedule: schedule, LastScrub: lastScrub})
+}
+
+// SetZFSScrubSchedule creates or replaces the scrub schedule of a pool
+func SetZFSScrubSchedule(w http.ResponseWriter, r *http.Request) {
+	pool := chi.URLParam(r, "name")
+	var req ScrubScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	if err := lib.Storage.ZFS.SetScrubSchedule(pool, req.Schedule); err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to set scrub schedule", err))
+		return
+	}
+
+	schedule, _ := lib.Storage.ZFS.GetScrubSchedule(pool)
+	utils.RespondSuccess(w, ScrubScheduleResponse{Pool: pool, Schedule: schedule})
+}
+
+// DeleteZFSScrubSchedule stops scheduled scrubs of a pool
+func DeleteZFSScrubSchedule(w http.ResponseWriter, r *http.Request) {
+	pool := chi.URLParam(r, "name")
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	if err := lib.Storage.ZFS.RemoveScrubSchedule(pool); err != nil {
+		if stderrors.Is(err, storage.ErrScrubScheduleNotFound) {
+			utils.RespondError(w, errors.NotFound("No scrub schedule for pool", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to remove scrub schedule", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// AutoTieringAnalyzeRequest is the request body of AnalyzeZFSAutoTiering
+type AutoTieringAnalyzeRequest struct {
+	Pool string `json:"pool"`
+}
+
+// AnalyzeZFSAutoTiering reports how recently the datasets of a pool were read
+func AnalyzeZFSAutoTiering(w http.ResponseWriter, r *http.Request) {
+	var req AutoTieringAnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	temperatures, err := storage.NewAutoTieringManager(lib.Storage.ZFS).AnalyzeAccessPatterns(req.Pool)
+	if err != nil {
+		logger.Error("Failed to analyze access patterns", zap.String("pool", req.Pool), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to analyze access patterns", err))
+		return
+	}
+
+	utils.RespondSuccess(w, temperatures)
+}
+
+// RunZFSAutoTiering moves the cold datasets of the hot pool to the cold pool
+func RunZFSAutoTiering(w http.ResponseWriter, r *http.Request) {
+	var policy storage.AutoTieringPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	report, err := storage.NewAutoTieringManager(lib.Storage.ZFS).MigrateColdData(policy)
+	if err != nil {
+		logger.Error("Auto-tiering failed", zap.String("hot_pool", policy.HotPool), zap.String("cold_pool", policy.ColdPool), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Auto-tiering failed", err))
+		return
+	}
+
+	utils.RespondSuccess(w, report)
+}
+
+// CreateEncryptedDatasetRequest is the request body of CreateEncryptedZFSDataset
+type CreateEncryptedDatasetRequest struct {
+	Pool       string `json:"pool"`
+	Name       string `json:"name"`
+	Passphrase string `json:"passphrase"` // Omitted with a file:// key location
+	storage.EncryptionOptions
+}
+
+// EncryptionKeyRequest is the request body of the encrypted dataset key operations
+type EncryptionKeyRequest struct {
+	Passphrase    string `json:"passphrase"`               // Mount, or the new passphrase when changing the key
+	OldPassphrase string `json:"old_passphrase,omitempty"` // Change key only
+}
+
+// zfsDatasetParam returns the dataset name of the URL; its slashes are
+// escaped as %2F
+func zfsDatasetParam(r *http.Request) string {
+	name := chi.URLParam(r, "name")
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// CreateEncryptedZFSDataset creates a dataset with ZFS native encryption
+func CreateEncryptedZFSDataset(w http.ResponseWriter, r *http.Request) {
+	var req CreateEncryptedDatasetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	if err := lib.Storage.ZFS.CreateEncryptedDataset(req.Pool, req.Name, req.Passphrase, req.EncryptionOptions); err != nil {
+		logger.Error("Failed to create encrypted ZFS dataset", zap.String("pool", req.Pool), zap.String("name", req.Name), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to create encrypted dataset", err))
+		return
+	}
+
+	utils.RespondCreated(w, map[string]string{
+		"message": "Encrypted dataset created successfully",
+		"dataset": req.Pool + "/" + req.Name,
+	})
+}
+
+// MountEncryptedZFSDataset loads the key of an encrypted dataset and mounts it
+func MountEncryptedZFSDataset(w http.ResponseWriter, r *http.Request) {
+	dataset := zfsDatasetParam(r)
+	var req EncryptionKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	if err := lib.Storage.ZFS.MountEncryptedDataset(dataset, req.Passphrase); err != nil {
+		logger.Warn("Failed to mount encrypted ZFS dataset", zap.String("dataset", dataset), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to mount encrypted dataset", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Dataset mounted successfully",
+	})
+}
+
+// UnmountEncryptedZFSDataset unmounts an encrypted dataset and unloads its key
+func UnmountEncryptedZFSDataset(w http.ResponseWriter, r *http.Request) {
+	dataset := zfsDatasetParam(r)
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	if err := lib.Storage.ZFS.UnmountEncryptedDataset(dataset); err != nil {
+		logger.Error("Failed to unmount encrypted ZFS dataset", zap.String("dataset", dataset), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to unmount encrypted dataset", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Dataset unmounted and key unloaded",
+	})
+}
+
+// ChangeZFSEncryptionKey replaces the passphrase of an encrypted dataset
+func ChangeZFSEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	dataset := zfsDatasetParam(r)
+	var req EncryptionKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request", err))
+		return
+	}
+
+	lib := getSystemLib(w)
+	if lib == nil {
+		return
+	}
+	if lib.Storage == nil || lib.Storage.ZFS == nil {
+		utils.RespondError(w, errors.BadRequest("ZFS not available", nil))
+		return
+	}
+
+	if err := lib.Storage.ZFS.ChangeEncryptionKey(dataset, req.OldPassphrase, req.Passphrase); err != nil {
+		logger.Warn("Failed to change ZFS encryption key", zap.String("dataset", dataset), zap.Error(err))
+		utils.RespondError(w, errors.BadRequest("Failed to change encryption key", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Encryption key changed successfully",
+	})
+}
+
 // ===== RAID Handlers =====
 
 // ListRAIDArrays lists all mdadm RAID arrays
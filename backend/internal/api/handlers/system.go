@@ -69,3 +69,14 @@ func ApplyUpdates(w http.ResponseWriter, r *http.Request) {
 		"message": "System updated successfully. Please restart the server to apply changes.",
 	})
 }
+
+// GetNASProcesses lists the running NAS daemons with their memory and CPU use
+func GetNASProcesses(w http.ResponseWriter, r *http.Request) {
+	processes, err := system.GetNASProcesses()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list processes", err))
+		return
+	}
+
+	utils.RespondSuccess(w, processes)
+}
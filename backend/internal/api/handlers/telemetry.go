@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/telemetry"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+// TelemetrySettings are the telemetry settings with the report that would
+// be sent, so admins can review it before opting in
+type TelemetrySettings struct {
+	Enabled      bool               `json:"enabled"`
+	CollectorURL string             `json:"collectorUrl"`
+	NodeID       string             `json:"nodeId"`
+	LastReportAt *time.Time         `json:"lastReportAt,omitempty"`
+	Payload      *telemetry.Payload `json:"payload,omitempty"`
+}
+
+// UpdateTelemetryRequest is the body of PUT /admin/telemetry
+type UpdateTelemetryRequest struct {
+	Enabled      *bool   `json:"enabled,omitempty"`
+	CollectorURL *string `json:"collectorUrl,omitempty"`
+}
+
+// GetTelemetry returns the telemetry settings and a preview of the report
+// GET /api/v1/admin/telemetry
+func GetTelemetry(w http.ResponseWriter, r *http.Request) {
+	t, err := telemetry.Load(database.GetDB())
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to load telemetry settings", err))
+		return
+	}
+	respondTelemetrySettings(w, r, t)
+}
+
+// UpdateTelemetry enables or disables telemetry or changes the collector
+// PUT /api/v1/admin/telemetry
+func UpdateTelemetry(w http.ResponseWriter, r *http.Request) {
+	var req UpdateTelemetryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	t, err := telemetry.Load(database.GetDB())
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to load telemetry settings", err))
+		return
+	}
+	if req.Enabled != nil {
+		t.Enabled = *req.Enabled
+	}
+	if req.CollectorURL != nil {
+		u, err := url.Parse(*req.CollectorURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			utils.RespondError(w, errors.BadRequest("collectorUrl must be an http or https URL", err))
+			return
+		}
+		t.CollectorURL = *req.CollectorURL
+	}
+
+	if err := t.Save(); err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to save telemetry settings", err))
+		return
+	}
+	respondTelemetrySettings(w, r, t)
+}
+
+func respondTelemetrySettings(w http.ResponseWriter, r *http.Request, t *telemetry.Telemetry) {
+	lastReport, err := t.LastReport()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to load telemetry settings", err))
+		return
+	}
+	payload, err := t.Collect(r.Context())
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to collect telemetry report", err))
+		return
+	}
+
+	utils.RespondSuccess(w, TelemetrySettings{
+		Enabled:      t.Enabled,
+		CollectorURL: t.CollectorURL,
+		NodeID:       t.NodeID,
+		LastReportAt: lastReport,
+		Payload:      payload,
+	})
+}
@@ -4,7 +4,9 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/twofa"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
@@ -162,6 +164,39 @@ func (h *TwoFAHandler) RegenerateBackupCodes(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// BackupCodesStatus summarizes a user's backup codes without revealing them
+type BackupCodesStatus struct {
+	Total     int                      `json:"total"`
+	Used      int                      `json:"used"`
+	Remaining int                      `json:"remaining"`
+	Codes     []twofa.BackupCodeStatus `json:"codes"`
+}
+
+// GetBackupCodesStatus returns which of the user's backup codes were used
+func (h *TwoFAHandler) GetBackupCodesStatus(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == 0 {
+		utils.RespondError(w, errors.Unauthorized("User not found in context", nil))
+		return
+	}
+
+	codes, err := h.service.BackupCodes().ListCodes(strconv.FormatUint(uint64(userID), 10))
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to get backup codes", err))
+		return
+	}
+
+	status := BackupCodesStatus{Total: len(codes), Codes: codes}
+	for _, c := range codes {
+		if c.Used {
+			status.Used++
+		}
+	}
+	status.Remaining = status.Total - status.Used
+
+	utils.RespondSuccess(w, status)
+}
+
 // VerifyTwoFactor verifies a 2FA code during login
 func (h *TwoFAHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -206,9 +241,9 @@ func (h *TwoFAHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
 
 // getUserIDFromContext extracts the user ID from the request context
 func getUserIDFromContext(r *http.Request) uint {
-	userID, ok := r.Context().Value("userID").(uint)
-	if !ok {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
 		return 0
 	}
-	return userID
+	return user.ID
 }
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	stderrors "errors"
 	"net/http"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/updates"
@@ -16,6 +17,13 @@ type UpdateHandler struct {
 	service *updates.UpdateService
 }
 
+var autoUpdater *updates.AutoUpdater
+
+// InitAutoUpdater enables the system package update endpoints
+func InitAutoUpdater(updater *updates.AutoUpdater) {
+	autoUpdater = updater
+}
+
 // NewUpdateHandler creates a new update handler
 func NewUpdateHandler() *UpdateHandler {
 	return &UpdateHandler{
@@ -50,3 +58,42 @@ func (h *UpdateHandler) GetCurrentVersion(w http.ResponseWriter, r *http.Request
 		"version": version,
 	})
 }
+
+// GetSecurityUpdates lists the pending security updates of the system packages
+func (h *UpdateHandler) GetSecurityUpdates(w http.ResponseWriter, r *http.Request) {
+	if autoUpdater == nil {
+		utils.RespondError(w, errors.NewAppError(http.StatusServiceUnavailable, "System package updates are not available", nil))
+		return
+	}
+
+	packages, err := autoUpdater.GetSecurityUpdates()
+	if err != nil {
+		logger.Error("Failed to get security updates", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to get security updates", err))
+		return
+	}
+
+	utils.RespondSuccess(w, packages)
+}
+
+// ApplySecurityUpdates installs the pending security updates after
+// snapshotting the root filesystem
+func (h *UpdateHandler) ApplySecurityUpdates(w http.ResponseWriter, r *http.Request) {
+	if autoUpdater == nil {
+		utils.RespondError(w, errors.NewAppError(http.StatusServiceUnavailable, "System package updates are not available", nil))
+		return
+	}
+
+	report, err := autoUpdater.ApplySecurityUpdates(r.Context())
+	if stderrors.Is(err, updates.ErrUpdateInProgress) {
+		utils.RespondError(w, errors.Conflict(err.Error(), err))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to apply security updates", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to apply security updates", err))
+		return
+	}
+
+	utils.RespondSuccess(w, report)
+}
@@ -163,3 +163,41 @@ func GetGroupMembers(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondSuccess(w, members)
 }
+
+// AddSubgroup nests a group in a user group
+func AddSubgroup(w http.ResponseWriter, r *http.Request) {
+	var req usergroups.AddSubgroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	childID := strconv.FormatUint(uint64(req.GroupID), 10)
+	if err := usergroups.GetGroupHierarchy().AddSubgroup(chi.URLParam(r, "id"), childID); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// RemoveSubgroup removes a nested group from a user group
+func RemoveSubgroup(w http.ResponseWriter, r *http.Request) {
+	if err := usergroups.GetGroupHierarchy().RemoveSubgroup(chi.URLParam(r, "id"), chi.URLParam(r, "childId")); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// GetGroupTree returns a user group with its nested subgroups
+func GetGroupTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := usergroups.GetGroupHierarchy().GetTree(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, tree)
+}
@@ -3,10 +3,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
@@ -85,6 +89,14 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	events.Publish(events.Event{
+		Type:    events.TypeUser,
+		Action:  "created",
+		Source:  "users",
+		Message: "User " + user.Username + " created",
+		Data:    map[string]interface{}{"id": user.ID, "username": user.Username, "role": user.Role},
+	})
+
 	utils.RespondCreated(w, users.ToResponse(user))
 }
 
@@ -139,5 +151,129 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	events.Publish(events.Event{
+		Type:    events.TypeUser,
+		Action:  "deleted",
+		Source:  "users",
+		Message: "User " + idStr + " deleted",
+		Data:    map[string]interface{}{"id": id},
+	})
+
 	utils.RespondNoContent(w)
 }
+
+// ImportUsers creates users from an uploaded CSV or LDIF file
+// (?format=csv|ldif). Options are sent as form fields: dryRun, onConflict,
+// defaultPassword and sendWelcomeEmail.
+func ImportUsers(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ldif" {
+		utils.RespondError(w, errors.BadRequest("Format must be one of: csv, ldif", nil))
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to parse multipart form", err))
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to get file from form", err))
+		return
+	}
+	defer file.Close()
+
+	opts := users.BulkImportOptions{
+		DryRun:           r.FormValue("dryRun") == "true",
+		OnConflict:       r.FormValue("onConflict"),
+		DefaultPassword:  r.FormValue("defaultPassword"),
+		SendWelcomeEmail: r.FormValue("sendWelcomeEmail") == "true",
+	}
+
+	var result *users.BulkImportResult
+	if format == "ldif" {
+		result, err = users.BulkImportLDIF(file, opts)
+	} else {
+		result, err = users.BulkImportCSV(file, opts)
+	}
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	if !opts.DryRun && result.Created+result.Updated > 0 {
+		events.Publish(events.Event{
+			Type:    events.TypeUser,
+			Action:  "imported",
+			Source:  "users",
+			Message: fmt.Sprintf("Imported users: %d created, %d updated", result.Created, result.Updated),
+			Data:    map[string]interface{}{"created": result.Created, "updated": result.Updated, "failed": result.Failed},
+		})
+	}
+
+	utils.RespondSuccess(w, result)
+}
+
+// GetUserActivity returns a user's activity report. since is a number of
+// days ("30d"), a duration ("12h") or an RFC 3339 time; default 30 days.
+func GetUserActivity(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r.URL.Query().Get("since"), 30*24*time.Hour)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	report, err := users.GetActivityReport(chi.URLParam(r, "id"), since)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, report)
+}
+
+// ListInactiveUsers returns active accounts without a login in the last
+// ?days= days (default 90)
+func ListInactiveUsers(w http.ResponseWriter, r *http.Request) {
+	days := 90
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			utils.RespondError(w, errors.BadRequest("days must be a positive number", err))
+			return
+		}
+		days = n
+	}
+
+	inactive, err := users.GetInactiveUsers(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.RespondSuccess(w, inactive)
+}
+
+// parseSince parses a report start given as days ("30d"), a duration
+// ("12h") or an RFC 3339 time
+func parseSince(value string, def time.Duration) (time.Time, error) {
+	if value == "" {
+		return time.Now().Add(-def), nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.BadRequest("Invalid since value (use e.g. 30d, 12h or an RFC 3339 time)", nil)
+}
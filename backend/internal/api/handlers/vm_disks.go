@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/vm"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// AddVMDiskRequest is the request body of AddVMDisk
+type AddVMDiskRequest struct {
+	ImageFile string `json:"image_file"` // Existing disk image or block device
+	Bus       string `json:"bus"`        // virtio (default), sata, scsi, usb or ide
+	vm.DiskOptions
+}
+
+// ResizeVMDiskRequest is the request body of ResizeVMDisk
+type ResizeVMDiskRequest struct {
+	SizeGB int `json:"size_gb"`
+}
+
+// respondVMDiskError maps VM disk errors to HTTP errors
+func respondVMDiskError(w http.ResponseWriter, message string, err error) {
+	if stderrors.Is(err, vm.ErrDiskNotFound) {
+		utils.RespondError(w, errors.NotFound("Disk not found", err))
+		return
+	}
+	logger.Error(message, zap.Error(err))
+	utils.RespondError(w, errors.BadRequest(message+": "+err.Error(), err))
+}
+
+// ListVMDisks lists the disks attached to a VM
+func ListVMDisks(w http.ResponseWriter, r *http.Request) {
+	if vmManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VM manager not initialized", nil))
+		return
+	}
+
+	disks, err := vmManager.ListDisks(chi.URLParam(r, "name"))
+	if err != nil {
+		respondVMDiskError(w, "Failed to list VM disks", err)
+		return
+	}
+
+	utils.RespondSuccess(w, disks)
+}
+
+// AddVMDisk attaches a disk image to a VM, hot-plugging it if the VM runs
+func AddVMDisk(w http.ResponseWriter, r *http.Request) {
+	if vmManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VM manager not initialized", nil))
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	var req AddVMDiskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if req.ImageFile == "" {
+		utils.RespondError(w, errors.BadRequest("Image file is required", nil))
+		return
+	}
+	if req.Bus == "" {
+		req.Bus = "virtio"
+	}
+
+	if err := vmManager.AddDisk(name, req.ImageFile, req.Bus, req.DiskOptions); err != nil {
+		respondVMDiskError(w, "Failed to add VM disk", err)
+		return
+	}
+
+	disks, err := vmManager.ListDisks(name)
+	if err != nil {
+		respondVMDiskError(w, "Failed to list VM disks", err)
+		return
+	}
+	utils.RespondCreated(w, disks)
+}
+
+// ResizeVMDisk grows a disk of a VM
+func ResizeVMDisk(w http.ResponseWriter, r *http.Request) {
+	if vmManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VM manager not initialized", nil))
+		return
+	}
+
+	var req ResizeVMDiskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if err := vmManager.ResizeDisk(chi.URLParam(r, "name"), chi.URLParam(r, "target"), req.SizeGB); err != nil {
+		respondVMDiskError(w, "Failed to resize VM disk", err)
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Disk resized; grow the partition in the guest to use the space",
+	})
+}
+
+// RemoveVMDisk detaches a disk from a VM (?delete_image=true deletes its image)
+func RemoveVMDisk(w http.ResponseWriter, r *http.Request) {
+	if vmManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VM manager not initialized", nil))
+		return
+	}
+
+	deleteImage := r.URL.Query().Get("delete_image") == "true"
+	if err := vmManager.RemoveDisk(chi.URLParam(r, "name"), chi.URLParam(r, "target"), deleteImage); err != nil {
+		respondVMDiskError(w, "Failed to remove VM disk", err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/vm"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CPUPinningRequest is the request body of SetVMCPUPinning
+type CPUPinningRequest struct {
+	Pins []vm.VCPUPin `json:"pins"`
+}
+
+// MemoryBackingRequest is the request body of SetVMMemoryBacking
+type MemoryBackingRequest struct {
+	Hugepages bool   `json:"hugepages"`
+	Nodeset   string `json:"nodeset"` // NUMA nodes to allocate from, e.g. "0" or "0-1"; empty for any
+}
+
+// SetVMCPUPinning pins the virtual CPUs of a VM to host CPUs
+func SetVMCPUPinning(w http.ResponseWriter, r *http.Request) {
+	if vmManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VM manager not initialized", nil))
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	var req CPUPinningRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if len(req.Pins) == 0 {
+		utils.RespondError(w, errors.BadRequest("At least one vCPU pin is required", nil))
+		return
+	}
+
+	if err := vmManager.SetCPUPinning(name, req.Pins); err != nil {
+		logger.Error("Failed to set CPU pinning", zap.Error(err), zap.String("vm", name))
+		utils.RespondError(w, errors.BadRequest("Failed to set CPU pinning", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "CPU pinning updated",
+	})
+}
+
+// SetVMMemoryBacking configures hugepages and the NUMA nodes of a VM's memory
+func SetVMMemoryBacking(w http.ResponseWriter, r *http.Request) {
+	if vmManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VM manager not initialized", nil))
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	var req MemoryBackingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if err := vmManager.SetMemoryBacking(name, req.Hugepages, req.Nodeset); err != nil {
+		logger.Error("Failed to set memory backing", zap.Error(err), zap.String("vm", name))
+		utils.RespondError(w, errors.BadRequest("Failed to set memory backing", err))
+		return
+	}
+
+	utils.RespondSuccess(w, map[string]string{
+		"message": "Memory backing updated, effective on next VM start",
+	})
+}
+
+// GetNUMATopology returns the NUMA nodes of the host
+func GetNUMATopology(w http.ResponseWriter, r *http.Request) {
+	if vmManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VM manager not initialized", nil))
+		return
+	}
+
+	topology, err := vmManager.GetNUMATopology()
+	if err != nil {
+		logger.Error("Failed to get NUMA topology", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to get NUMA topology", err))
+		return
+	}
+
+	utils.RespondSuccess(w, topology)
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/vpn"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var vpnManager *vpn.VPNManager
+
+// InitVPNManager initializes the VPN manager
+func InitVPNManager(manager *vpn.VPNManager) {
+	vpnManager = manager
+	logger.Info("VPN manager initialized in handlers")
+}
+
+// WireGuardInterfaceDetails is an interface with its peers
+type WireGuardInterfaceDetails struct {
+	Interface *vpn.WireGuardInterface `json:"interface"`
+	Peers     []vpn.WireGuardPeer     `json:"peers"`
+}
+
+// PeerClientConfig is the wg-quick configuration of a peer's client
+type PeerClientConfig struct {
+	Config string `json:"config"`
+}
+
+// respondWireGuardError maps errors of the VPN manager to HTTP errors
+func respondWireGuardError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case stderrors.Is(err, vpn.ErrWireGuardInterfaceNotFound):
+		utils.RespondError(w, errors.NotFound("WireGuard interface not found", err))
+	case stderrors.Is(err, vpn.ErrPeerNotFound):
+		utils.RespondError(w, errors.NotFound("VPN peer not found", err))
+	case stderrors.Is(err, vpn.ErrWireGuardInterfaceExists), stderrors.Is(err, vpn.ErrListenPortInUse):
+		utils.RespondError(w, errors.Conflict(err.Error(), err))
+	default:
+		logger.Error(message, zap.Error(err))
+		utils.RespondError(w, errors.BadRequest(message+": "+err.Error(), err))
+	}
+}
+
+// GetVPNStatus returns the status of a VPN protocol (?protocol=, default wireguard)
+func GetVPNStatus(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	protocol := r.URL.Query().Get("protocol")
+	if protocol == "" {
+		protocol = vpn.ProtocolWireGuard
+	}
+
+	status, err := vpnManager.GetProtocolStatus(protocol)
+	if err != nil {
+		utils.RespondError(w, errors.BadRequest("Failed to get VPN status", err))
+		return
+	}
+
+	utils.RespondSuccess(w, status)
+}
+
+// ListWireGuardInterfaces lists the WireGuard interfaces
+func ListWireGuardInterfaces(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	interfaces, err := vpnManager.ListWireGuardInterfaces()
+	if err != nil {
+		logger.Error("Failed to list WireGuard interfaces", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to list WireGuard interfaces", err))
+		return
+	}
+
+	utils.RespondSuccess(w, interfaces)
+}
+
+// GetWireGuardInterface returns a WireGuard interface and its peers
+func GetWireGuardInterface(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	iface, peers, err := vpnManager.GetWireGuardInterface(chi.URLParam(r, "name"))
+	if err != nil {
+		respondWireGuardError(w, "Failed to get WireGuard interface", err)
+		return
+	}
+
+	utils.RespondSuccess(w, WireGuardInterfaceDetails{Interface: iface, Peers: peers})
+}
+
+// GetWireGuardInterfaceStats returns the traffic counters of a running
+// WireGuard interface
+func GetWireGuardInterfaceStats(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	stats, err := vpnManager.GetWireGuardInterfaceStats(chi.URLParam(r, "name"))
+	if err != nil {
+		respondWireGuardError(w, "Failed to get WireGuard interface stats", err)
+		return
+	}
+
+	utils.RespondSuccess(w, stats)
+}
+
+// CreateWireGuardInterface creates and starts a WireGuard interface
+func CreateWireGuardInterface(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	var req vpn.WireGuardInterface
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	if err := vpnManager.CreateWireGuardInterface(req); err != nil {
+		respondWireGuardError(w, "Failed to create WireGuard interface", err)
+		return
+	}
+
+	iface, _, err := vpnManager.GetWireGuardInterface(req.Name)
+	if err != nil {
+		respondWireGuardError(w, "Failed to get WireGuard interface", err)
+		return
+	}
+
+	utils.RespondCreated(w, iface)
+}
+
+// UpdateWireGuardInterface changes the listen port and address of a WireGuard interface
+func UpdateWireGuardInterface(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	var req vpn.WireGuardInterface
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := vpnManager.UpdateWireGuardInterface(name, req); err != nil {
+		respondWireGuardError(w, "Failed to update WireGuard interface", err)
+		return
+	}
+
+	iface, _, err := vpnManager.GetWireGuardInterface(name)
+	if err != nil {
+		respondWireGuardError(w, "Failed to get WireGuard interface", err)
+		return
+	}
+
+	utils.RespondSuccess(w, iface)
+}
+
+// DeleteWireGuardInterface stops a WireGuard interface and removes its configuration
+func DeleteWireGuardInterface(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	if err := vpnManager.DeleteWireGuardInterface(chi.URLParam(r, "name")); err != nil {
+		respondWireGuardError(w, "Failed to delete WireGuard interface", err)
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
+
+// WireGuardPeerRequest is the body of peer create requests. With IPAMPool
+// set, the peer's tunnel address is allocated from that pool.
+type WireGuardPeerRequest struct {
+	vpn.WireGuardPeer
+	IPAMPool string `json:"ipamPool,omitempty"`
+}
+
+// CreateWireGuardPeer adds a peer to a WireGuard interface
+func CreateWireGuardPeer(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	var req WireGuardPeerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	ifaceName := chi.URLParam(r, "name")
+	var ip string
+	if req.IPAMPool != "" {
+		var ok bool
+		ip, ok = allocatePoolIP(w, req.IPAMPool, "wireguard:"+ifaceName+":"+req.PublicKey)
+		if !ok {
+			return
+		}
+		req.AllowedIPs = append([]string{network.HostPrefix(ip)}, req.AllowedIPs...)
+	}
+
+	if err := vpnManager.CreateWireGuardPeer(ifaceName, req.WireGuardPeer); err != nil {
+		if ip != "" {
+			releasePoolIP(ip)
+		}
+		respondWireGuardError(w, "Failed to create WireGuard peer", err)
+		return
+	}
+
+	utils.RespondCreated(w, req.WireGuardPeer)
+}
+
+// ListWireGuardPeers lists the peers of a WireGuard interface with their IDs
+// and split tunnel policies
+func ListWireGuardPeers(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	peers, err := vpnManager.ListPeers(chi.URLParam(r, "name"))
+	if err != nil {
+		logger.Error("Failed to list VPN peers", zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to list VPN peers", err))
+		return
+	}
+
+	utils.RespondSuccess(w, peers)
+}
+
+// SetPeerSplitTunnel changes the traffic a peer's client routes through the
+// tunnel and returns its regenerated client configuration
+func SetPeerSplitTunnel(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	var req vpn.SplitTunnelPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+
+	peerID := chi.URLParam(r, "id")
+	req.PeerID = peerID
+	if err := vpnManager.SetPeerSplitTunnel(peerID, req); err != nil {
+		respondWireGuardError(w, "Failed to set split tunnel", err)
+		return
+	}
+
+	GetPeerClientConfig(w, r)
+}
+
+// GetPeerClientConfig returns the wg-quick configuration of a peer's client
+func GetPeerClientConfig(w http.ResponseWriter, r *http.Request) {
+	if vpnManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VPN manager not initialized", nil))
+		return
+	}
+
+	config, err := vpnManager.GetPeerClientConfig(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWireGuardError(w, "Failed to get client configuration", err)
+		return
+	}
+
+	utils.RespondSuccess(w, PeerClientConfig{Config: config})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var vxlanManager *network.VXLANManager
+
+// InitVXLANManager initializes the VXLAN manager
+func InitVXLANManager(m *network.VXLANManager) {
+	vxlanManager = m
+	logger.Info("VXLAN manager initialized")
+}
+
+func requireVXLAN(w http.ResponseWriter) bool {
+	if vxlanManager == nil {
+		utils.RespondError(w, errors.InternalServerError("VXLAN not available (iproute2 not installed)", nil))
+		return false
+	}
+	return true
+}
+
+// ListVXLANs handles GET /api/v1/network/vxlan
+func ListVXLANs(w http.ResponseWriter, r *http.Request) {
+	if !requireVXLAN(w) {
+		return
+	}
+
+	configs, err := vxlanManager.ListVXLANs()
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to list VXLAN interfaces", err))
+		return
+	}
+
+	utils.RespondSuccess(w, configs)
+}
+
+// GetVXLAN handles GET /api/v1/network/vxlan/{name}
+func GetVXLAN(w http.ResponseWriter, r *http.Request) {
+	if !requireVXLAN(w) {
+		return
+	}
+
+	cfg, err := vxlanManager.GetVXLAN(chi.URLParam(r, "name"))
+	if err != nil {
+		if stderrors.Is(err, network.ErrVXLANNotFound) {
+			utils.RespondError(w, errors.NotFound("VXLAN interface not found", err))
+			return
+		}
+		utils.RespondError(w, errors.InternalServerError("Failed to get VXLAN interface", err))
+		return
+	}
+
+	utils.RespondSuccess(w, cfg)
+}
+
+// CreateVXLAN handles POST /api/v1/network/vxlan
+func CreateVXLAN(w http.ResponseWriter, r *http.Request) {
+	if !requireVXLAN(w) {
+		return
+	}
+
+	var req network.VXLANConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		utils.RespondError(w, errors.BadRequest(err.Error(), err))
+		return
+	}
+
+	if err := vxlanManager.CreateVXLAN(req.Name, req.VNI, req.RemoteIP, req.LocalIP, req.DstPort, req.Bridge); err != nil {
+		if stderrors.Is(err, network.ErrVXLANExists) {
+			utils.RespondError(w, errors.Conflict("VXLAN interface already exists", err))
+			return
+		}
+		logger.Error("Failed to create VXLAN interface", zap.String("name", req.Name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to create VXLAN interface", err))
+		return
+	}
+
+	utils.RespondCreated(w, req)
+}
+
+// DeleteVXLAN handles DELETE /api/v1/network/vxlan/{name}
+func DeleteVXLAN(w http.ResponseWriter, r *http.Request) {
+	if !requireVXLAN(w) {
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := vxlanManager.DeleteVXLAN(name); err != nil {
+		if stderrors.Is(err, network.ErrVXLANNotFound) {
+			utils.RespondError(w, errors.NotFound("VXLAN interface not found", err))
+			return
+		}
+		logger.Error("Failed to delete VXLAN interface", zap.String("name", name), zap.Error(err))
+		utils.RespondError(w, errors.InternalServerError("Failed to delete VXLAN interface", err))
+		return
+	}
+
+	utils.RespondNoContent(w)
+}
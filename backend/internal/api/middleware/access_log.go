@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/accesslog"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// accessLogExcludedPrefix is never logged, so reading the access logs does not
+// produce new ones
+const accessLogExcludedPrefix = "/api/v1/admin/access-logs"
+
+type accessLogContextKey struct{}
+
+// accessLogUser is filled in by AuthMiddleware further down the chain, which
+// runs after AccessLogMiddleware and cannot change the outer request context
+type accessLogUser struct {
+	id *uint
+}
+
+// AccessLogMiddleware records every request in the access_logs table.
+// Place it after middleware.RequestID and TrustedRealIP.
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, accessLogExcludedPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		user := &accessLogUser{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogContextKey{}, user))
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		accesslog.Record(&models.AccessLog{
+			CreatedAt:      start,
+			Method:         r.Method,
+			Path:           r.URL.Path,
+			Query:          redactQuery(r.URL.RawQuery),
+			RemoteIP:       clientIPWithoutPort(r),
+			StatusCode:     status,
+			ResponseTimeMs: float64(time.Since(start).Microseconds()) / 1000,
+			UserID:         user.id,
+			RequestID:      middleware.GetReqID(r.Context()),
+		})
+	})
+}
+
+// redactedQueryParams are replaced before a query string is stored
+var redactedQueryParams = []string{"token", "access_token", "password"}
+
+// redactQuery hides credentials passed as query parameters (WebSocket clients
+// send their JWT as ?token=)
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	// On a parse error, values holds the well-formed pairs; re-encoding them
+	// drops the malformed ones rather than storing them unredacted
+	values, err := url.ParseQuery(rawQuery)
+	redacted := err != nil
+	for _, name := range redactedQueryParams {
+		if values.Has(name) {
+			values.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// setAccessLogUser attaches the authenticated user to the request's access log entry
+func setAccessLogUser(ctx context.Context, userID uint) {
+	if user, ok := ctx.Value(accessLogContextKey{}).(*accessLogUser); ok {
+		user.id = &userID
+	}
+}
@@ -106,6 +106,11 @@ func logAuditEntry(r *http.Request, user *models.User, statusCode int) {
 		status = models.StatusError
 	}
 
+	// Successful logins are audited by the login handlers with the user's ID
+	if action == models.ActionAuthLogin && status == models.StatusSuccess {
+		return
+	}
+
 	// Determine severity
 	severity := models.SeverityInfo
 	if statusCode >= 400 && statusCode < 500 {
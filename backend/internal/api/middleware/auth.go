@@ -15,6 +15,15 @@ type contextKey string
 
 const UserContextKey contextKey = "user"
 
+// setupTokenPaths are the routes a 2FA setup token grants access to
+var setupTokenPaths = map[string]bool{
+	"/api/v1/2fa/status":  true,
+	"/api/v1/2fa/setup":   true,
+	"/api/v1/2fa/enable":  true,
+	"/api/v1/auth/me":     true,
+	"/api/v1/auth/logout": true,
+}
+
 // AuthMiddleware validates JWT tokens and adds user to context
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -46,6 +55,12 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Tokens issued to users who must set up 2FA only allow the setup flow
+		if claims.Scope == users.ScopeTwoFASetup && !setupTokenPaths[r.URL.Path] {
+			utils.RespondError(w, errors.Forbidden("Two-factor authentication setup required", nil))
+			return
+		}
+
 		// Get user from database
 		user, err := users.GetUserByID(claims.UserID)
 		if err != nil {
@@ -59,6 +74,9 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		setAccessLogUser(r.Context(), user.ID)
+		setTraceUser(r.Context(), user.ID)
+
 		// Add user to context
 		ctx := context.WithValue(r.Context(), UserContextKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+type bodyLimitContextKey struct{}
+
+// BodySizeLimit limits request bodies to maxBytes (0 = unlimited). Reads past
+// the limit fail with *http.MaxBytesError, which utils.RespondError turns into
+// a 413 response.
+//
+// It can be applied globally and again on individual routes: the innermost
+// limit replaces outer ones instead of stacking, so upload routes can allow
+// more than the global default.
+func BodySizeLimit(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Remember the unwrapped body so a route-level limit can replace
+			// the global one
+			original, ok := r.Context().Value(bodyLimitContextKey{}).(io.ReadCloser)
+			if !ok {
+				original = r.Body
+				r = r.WithContext(context.WithValue(r.Context(), bodyLimitContextKey{}, original))
+			}
+
+			if maxBytes > 0 && original != nil {
+				r.Body = http.MaxBytesReader(w, original, maxBytes)
+			} else {
+				r.Body = original
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
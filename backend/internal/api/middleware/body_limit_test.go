@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+// readBodyHandler behaves like a typical API handler reading a JSON body
+var readBodyHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if _, err := io.ReadAll(r.Body); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid request body", err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestBodySizeLimit(t *testing.T) {
+	const limit = 1024
+
+	tests := []struct {
+		name          string
+		handler       http.Handler
+		size          int
+		unknownLength bool // chunked body, only caught while reading
+		wantStatus    int
+	}{
+		{
+			name:       "just under limit",
+			handler:    BodySizeLimit(limit)(readBodyHandler),
+			size:       limit - 1,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "exactly at limit",
+			handler:    BodySizeLimit(limit)(readBodyHandler),
+			size:       limit,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "just over limit",
+			handler:    BodySizeLimit(limit)(readBodyHandler),
+			size:       limit + 1,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:          "just over limit without content length",
+			handler:       BodySizeLimit(limit)(readBodyHandler),
+			size:          limit + 1,
+			unknownLength: true,
+			wantStatus:    http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "unlimited",
+			handler:    BodySizeLimit(0)(readBodyHandler),
+			size:       10 * limit,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "route limit overrides global limit",
+			handler:    BodySizeLimit(limit)(BodySizeLimit(4 * limit)(readBodyHandler)),
+			size:       2 * limit,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "route limit still enforced",
+			handler:       BodySizeLimit(limit)(BodySizeLimit(4 * limit)(readBodyHandler)),
+			size:          4*limit + 1,
+			unknownLength: true,
+			wantStatus:    http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(strings.Repeat("a", tt.size)))
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+
+			tt.handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusRequestEntityTooLarge {
+				return
+			}
+
+			var resp utils.Response
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("413 response is not JSON: %v", err)
+			}
+			if resp.Success || resp.Error == nil || resp.Error.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("unexpected error body: %+v", resp)
+			}
+		})
+	}
+}
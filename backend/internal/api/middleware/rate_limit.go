@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// limiterIdleTimeout is how long an unused limiter is kept in memory
+	limiterIdleTimeout = 10 * time.Minute
+
+	// limiterSweepInterval is how often idle limiters are removed
+	limiterSweepInterval = time.Minute
+)
+
+// rateLimitExemptPrefixes are never rate limited (monitoring systems poll these)
+var rateLimitExemptPrefixes = []string{
+	"/health",
+	"/metrics",
+	"/api/v1/health",
+	"/api/v1/metrics",
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type ipRateRule struct {
+	network *net.IPNet
+	rule    models.RateLimit
+}
+
+// RateLimiter applies token-bucket limits per authenticated user or client IP
+type RateLimiter struct {
+	userLimit rate.Limit
+	ipLimit   rate.Limit
+	burst     int
+
+	mu        sync.Mutex
+	limiters  map[string]*limiterEntry
+	userRules map[string]models.RateLimit // keyed by username
+	ipRules   []ipRateRule
+	lastSweep time.Time
+}
+
+var (
+	globalRateLimiter *RateLimiter
+	rateLimiterMu     sync.RWMutex
+)
+
+// NewRateLimiter creates a rate limiter with default per-user and per-IP limits
+func NewRateLimiter(userLimit, ipLimit rate.Limit, burst int) *RateLimiter {
+	return &RateLimiter{
+		userLimit: userLimit,
+		ipLimit:   ipLimit,
+		burst:     burst,
+		limiters:  make(map[string]*limiterEntry),
+		userRules: make(map[string]models.RateLimit),
+		lastSweep: time.Now(),
+	}
+}
+
+// RateLimitMiddleware limits requests per authenticated user (or per client IP
+// for unauthenticated requests). Custom limits from the rate_limits table
+// override the defaults. Place it after AuthMiddleware to key by user.
+func RateLimitMiddleware(userLimit, ipLimit rate.Limit, burst int) func(http.Handler) http.Handler {
+	rateLimiterMu.Lock()
+	if globalRateLimiter == nil {
+		globalRateLimiter = NewRateLimiter(userLimit, ipLimit, burst)
+		if err := globalRateLimiter.LoadRules(); err != nil {
+			logger.Warn("Failed to load custom rate limits", zap.Error(err))
+		}
+	}
+	limiter := globalRateLimiter
+	rateLimiterMu.Unlock()
+
+	return limiter.Middleware
+}
+
+// ReloadRateLimits reloads custom rate limits from the database
+func ReloadRateLimits() error {
+	rateLimiterMu.RLock()
+	limiter := globalRateLimiter
+	rateLimiterMu.RUnlock()
+
+	if limiter == nil {
+		return nil
+	}
+	return limiter.LoadRules()
+}
+
+// LoadRules loads custom limits from the database
+func (rl *RateLimiter) LoadRules() error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+
+	var rules []models.RateLimit
+	if err := db.Find(&rules).Error; err != nil {
+		return err
+	}
+	rl.SetRules(rules)
+	return nil
+}
+
+// SetRules replaces the custom limits. Existing buckets are reset so new
+// limits take effect immediately.
+func (rl *RateLimiter) SetRules(rules []models.RateLimit) {
+	userRules := make(map[string]models.RateLimit)
+	var ipRules []ipRateRule
+
+	for _, rule := range rules {
+		switch rule.Type {
+		case models.RateLimitTypeUser:
+			userRules[rule.Target] = rule
+		case models.RateLimitTypeIP:
+			network, err := ParseIPOrCIDR(rule.Target)
+			if err != nil {
+				logger.Warn("Ignoring invalid rate limit target",
+					zap.String("target", rule.Target), zap.Error(err))
+				continue
+			}
+			ipRules = append(ipRules, ipRateRule{network: network, rule: rule})
+		}
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.userRules = userRules
+	rl.ipRules = ipRules
+	rl.limiters = make(map[string]*limiterEntry)
+}
+
+// Middleware is the http middleware enforcing the limits
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range rateLimitExemptPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		key, limit, burst := rl.resolve(r)
+		limiter := rl.limiterFor(key, limit, burst)
+
+		reservation := limiter.Reserve()
+		if !reservation.OK() {
+			// Burst of 0 - requests are never allowed
+			w.Header().Set("Retry-After", "60")
+			utils.RespondError(w, errors.TooManyRequests("Rate limit exceeded", nil))
+			return
+		}
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			utils.RespondError(w, errors.TooManyRequests(
+				fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter), nil))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resolve determines the bucket key and limits for a request
+func (rl *RateLimiter) resolve(r *http.Request) (string, rate.Limit, int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if user := GetUserFromContext(r.Context()); user != nil {
+		if rule, ok := rl.userRules[user.Username]; ok {
+			return fmt.Sprintf("user:%d", user.ID), rate.Limit(rule.RequestsPerSecond), rule.Burst
+		}
+		return fmt.Sprintf("user:%d", user.ID), rl.userLimit, rl.burst
+	}
+
+	ip := clientIPWithoutPort(r)
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, ipRule := range rl.ipRules {
+			if ipRule.network.Contains(parsed) {
+				return "ip:" + ip, rate.Limit(ipRule.rule.RequestsPerSecond), ipRule.rule.Burst
+			}
+		}
+	}
+	return "ip:" + ip, rl.ipLimit, rl.burst
+}
+
+// limiterFor returns the bucket for key, creating it if needed
+func (rl *RateLimiter) limiterFor(key string, limit rate.Limit, burst int) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.lastSweep) > limiterSweepInterval {
+		for k, entry := range rl.limiters {
+			if now.Sub(entry.lastSeen) > limiterIdleTimeout {
+				delete(rl.limiters, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	entry, ok := rl.limiters[key]
+	if !ok {
+		entry = &limiterEntry{limiter: rate.NewLimiter(limit, burst)}
+		rl.limiters[key] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter
+}
+
+// clientIPWithoutPort returns the address of RemoteAddr with any port
+// stripped. Forwarded headers are ignored; TrustedRealIP sets RemoteAddr
+// from them for requests from trusted proxies.
+func clientIPWithoutPort(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// ParseIPOrCIDR parses a single IP address or a CIDR range into a network
+func ParseIPOrCIDR(target string) (*net.IPNet, error) {
+	if strings.Contains(target, "/") {
+		_, network, err := net.ParseCIDR(target)
+		return network, err
+	}
+	ip := net.ParseIP(target)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", target)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
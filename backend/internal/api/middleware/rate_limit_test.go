@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"golang.org/x/time/rate"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.InitLogger("error", false); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func doRequest(h http.Handler, path, remoteAddr string, user *users.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), UserContextKey, user))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	// 0.2 req/s means a new token every 5 seconds
+	h := NewRateLimiter(rate.Limit(10), rate.Limit(0.2), 3).Middleware(okHandler)
+
+	for i := 0; i < 3; i++ {
+		if rec := doRequest(h, "/api/v1/system/info", "192.0.2.10:5000", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := doRequest(h, "/api/v1/system/info", "192.0.2.10:5001", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After = %q, want %q", got, "5")
+	}
+
+	// Another client is unaffected
+	if rec := doRequest(h, "/api/v1/system/info", "192.0.2.11:5000", nil); rec.Code != http.StatusOK {
+		t.Errorf("other IP status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedHeaders(t *testing.T) {
+	h := TrustedRealIP([]string{"127.0.0.1"})(NewRateLimiter(rate.Limit(10), rate.Limit(0.2), 2).Middleware(okHandler))
+
+	codes := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil)
+		req.RemoteAddr = "192.0.2.20:5000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+		req.Header.Set("X-Real-IP", fmt.Sprintf("198.51.100.%d", i+1))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("request %d: status = %d, want %d", i+1, codes[i], want[i])
+		}
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	h := NewRateLimiter(rate.Limit(0.5), rate.Limit(100), 2).Middleware(okHandler)
+	user := &users.User{ID: 7, Username: "alice"}
+
+	codes := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		// Changing IPs must not reset a user's bucket
+		rec := doRequest(h, "/api/v1/files", "198.51.100."+string(rune('1'+i))+":1234", user)
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "2" {
+			t.Errorf("Retry-After = %q, want %q", rec.Header().Get("Retry-After"), "2")
+		}
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("request %d: status = %d, want %d", i+1, codes[i], want[i])
+		}
+	}
+}
+
+func TestRateLimitCustomRules(t *testing.T) {
+	rl := NewRateLimiter(rate.Limit(100), rate.Limit(100), 100)
+	rl.SetRules([]models.RateLimit{
+		{Target: "10.0.0.0/8", Type: models.RateLimitTypeIP, RequestsPerSecond: 1, Burst: 1},
+		{Target: "bob", Type: models.RateLimitTypeUser, RequestsPerSecond: 1, Burst: 1},
+	})
+	h := rl.Middleware(okHandler)
+
+	doRequest(h, "/api/v1/system/info", "10.1.2.3:1000", nil)
+	if rec := doRequest(h, "/api/v1/system/info", "10.1.2.3:1000", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("IP range rule: status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	bob := &users.User{ID: 8, Username: "bob"}
+	doRequest(h, "/api/v1/system/info", "203.0.113.1:1000", bob)
+	if rec := doRequest(h, "/api/v1/system/info", "203.0.113.1:1000", bob); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("user rule: status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitExemptPaths(t *testing.T) {
+	h := NewRateLimiter(rate.Limit(0.01), rate.Limit(0.01), 1).Middleware(okHandler)
+
+	for _, path := range []string{"/health", "/metrics", "/api/v1/health/status", "/api/v1/metrics/history"} {
+		for i := 0; i < 5; i++ {
+			if rec := doRequest(h, path, "192.0.2.50:1000", nil); rec.Code != http.StatusOK {
+				t.Fatalf("%s request %d: status = %d, want %d", path, i+1, rec.Code, http.StatusOK)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// TrustedRealIP sets r.RemoteAddr to the client address from the
+// X-Forwarded-For or X-Real-IP header, like chi's middleware.RealIP, but only
+// for requests from one of the trusted proxies (IPs or CIDR ranges). Other
+// requests keep their RemoteAddr, so clients cannot choose the IP they are
+// rate limited and logged by.
+func TrustedRealIP(trustedProxies []string) func(http.Handler) http.Handler {
+	var networks []*net.IPNet
+	for _, proxy := range trustedProxies {
+		network, err := ParseIPOrCIDR(proxy)
+		if err != nil {
+			logger.Warn("Ignoring invalid trusted proxy", zap.String("proxy", proxy), zap.Error(err))
+			continue
+		}
+		networks = append(networks, network)
+	}
+	trusted := func(ip net.IP) bool {
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := net.ParseIP(clientIPWithoutPort(r)); ip != nil && trusted(ip) {
+				if client := forwardedClientIP(r, trusted); client != "" {
+					r.RemoteAddr = client
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClientIP returns the last X-Forwarded-For address not added by a
+// trusted proxy, or X-Real-IP without that header. Entries further left
+// were sent by the client and cannot be trusted.
+func forwardedClientIP(r *http.Request, trusted func(ip net.IP) bool) string {
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return ""
+			}
+			if !trusted(ip) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedRealIP(t *testing.T) {
+	var got string
+	h := TrustedRealIP([]string{"127.0.0.1", "10.0.0.0/8"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.RemoteAddr
+	}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		want       string
+	}{
+		{"untrusted client keeps its address", "192.0.2.1:4000", "203.0.113.9", "203.0.113.8", "192.0.2.1:4000"},
+		{"trusted proxy", "127.0.0.1:4000", "203.0.113.9", "", "203.0.113.9"},
+		{"spoofed entries left of the proxy hops", "127.0.0.1:4000", "198.51.100.1, 203.0.113.9, 10.1.2.3", "", "203.0.113.9"},
+		{"X-Real-IP from a trusted proxy", "10.0.0.5:4000", "", "203.0.113.7", "203.0.113.7"},
+		{"invalid header", "127.0.0.1:4000", "not-an-ip", "", "127.0.0.1:4000"},
+		{"no header", "127.0.0.1:4000", "", "", "127.0.0.1:4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
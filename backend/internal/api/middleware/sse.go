@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// SSEMiddleware prepares a response for Server-Sent Events: it sets the
+// event-stream headers, disables proxy buffering and lifts the server write
+// deadline so long-lived streams are not cut off.
+func SSEMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+		h.Set("X-Accel-Buffering", "no") // nginx
+
+		// Not every ResponseWriter supports deadlines (e.g. in tests)
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDHeader carries the trace ID of a request in its response
+const TraceIDHeader = "X-Trace-ID"
+
+// TracingMiddleware starts a span per request, continuing the trace of a W3C
+// traceparent header, and returns the trace ID in the X-Trace-ID header.
+// Handlers start child spans from the request context.
+func TracingMiddleware(tracer trace.Tracer) func(http.Handler) http.Handler {
+	propagator := propagation.TraceContext{}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method+" "+r.URL.Path,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("url.path", r.URL.Path),
+				))
+			defer span.End()
+
+			if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+				w.Header().Set(TraceIDHeader, spanContext.TraceID().String())
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			// The route pattern is known once the router has matched the request
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if route := rctx.RoutePattern(); route != "" {
+					span.SetName(r.Method + " " + route)
+					span.SetAttributes(attribute.String("http.route", route))
+				}
+			}
+		})
+	}
+}
+
+// setTraceUser records the authenticated user on the request's span
+func setTraceUser(ctx context.Context, userID uint) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("enduser.id", strconv.FormatUint(uint64(userID), 10)))
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracingRouter serves GET /shares/{id} with a handler that starts a
+// span in a nested call
+func newTracingRouter(tracer trace.Tracer) http.Handler {
+	nested := func(ctx context.Context) {
+		_, span := tracer.Start(ctx, "nested")
+		span.End()
+	}
+
+	r := chi.NewRouter()
+	r.Use(TracingMiddleware(tracer))
+	r.Get("/shares/{id}", func(w http.ResponseWriter, r *http.Request) {
+		nested(r.Context())
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return r
+}
+
+func TestTracingMiddlewareLinksChildSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	router := newTracingRouter(provider.Tracer("test"))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/shares/7", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	child, root := spans[0], spans[1]
+
+	if root.Parent().IsValid() {
+		t.Error("request span has a parent")
+	}
+	if root.Name() != "GET /shares/{id}" {
+		t.Errorf("request span name = %q", root.Name())
+	}
+	if child.Parent().SpanID() != root.SpanContext().SpanID() || child.SpanContext().TraceID() != root.SpanContext().TraceID() {
+		t.Error("nested span is not a child of the request span")
+	}
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range root.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["http.route"].AsString() != "/shares/{id}" {
+		t.Errorf("http.route = %q", attrs["http.route"].AsString())
+	}
+	if attrs["http.response.status_code"].AsInt64() != http.StatusAccepted {
+		t.Errorf("http.response.status_code = %d", attrs["http.response.status_code"].AsInt64())
+	}
+
+	if got := rec.Header().Get(TraceIDHeader); got != root.SpanContext().TraceID().String() {
+		t.Errorf("X-Trace-ID = %q, want %q", got, root.SpanContext().TraceID())
+	}
+}
+
+func TestTracingMiddlewareContinuesTraceparent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	router := newTracingRouter(provider.Tracer("test"))
+
+	req := httptest.NewRequest(http.MethodGet, "/shares/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	root := spans[1]
+	if root.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the incoming trace", root.SpanContext().TraceID())
+	}
+	if root.Parent().SpanID().String() != "00f067aa0ba902b7" || !root.Parent().IsRemote() {
+		t.Errorf("request span parent = %v, want the remote caller", root.Parent())
+	}
+	if got := rec.Header().Get(TraceIDHeader); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("X-Trace-ID = %q", got)
+	}
+}
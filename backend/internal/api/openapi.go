@@ -0,0 +1,312 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/ad"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/addons"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/handlers"
+	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/openapi"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth/rbac"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/cloudbackup"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/dependencies"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/docker"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/files"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/files/versioning"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/lifecycle"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/metrics"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha/cluster"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/lxc"
+	sysstorage "github.com/Stumpf-works/stumpfworks-nas/internal/system/storage"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/vm"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/vpn"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/updates"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/usergroups"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+//go:generate go run ../../cmd/generate-openapi -o ../../../docs/openapi.yaml
+
+// openAPIDocs documents request/response types for individual routes.
+// Routes not listed here still appear in the spec with generic schemas.
+var openAPIDocs = map[string]openapi.RouteDoc{
+	"POST /api/v1/auth/login": {Summary: "Log in with username and password", Request: handlers.LoginRequest{}, Response: handlers.LoginResponse{}},
+	"GET /api/v1/auth/me":     {Summary: "Get the current user", Response: users.UserResponse{}},
+
+	"GET /api/v1/setup/status":       {Summary: "Setup status", Response: handlers.SetupStatusResponse{}},
+	"GET /api/v1/setup/steps":        {Summary: "List the setup wizard steps; closed once setup is completed", Response: handlers.SetupWizard{}},
+	"PUT /api/v1/setup/steps/{name}": {Summary: "Apply a setup wizard step; steps after admin-account require the admin's token"},
+	"POST /api/v1/setup/complete":    {Summary: "Finalise the setup, which closes the setup endpoints", Response: map[string]string{}},
+
+	"GET /api/v1/users":                          {Summary: "List users", Response: []users.UserResponse{}},
+	"POST /api/v1/users":                         {Summary: "Create a user", Request: users.CreateUserRequest{}, Response: users.UserResponse{}, Status: http.StatusCreated},
+	"POST /api/v1/users/import":                  {Summary: "Import users from a CSV or LDIF file (multipart, ?format=csv|ldif)", Response: users.BulkImportResult{}},
+	"GET /api/v1/users/{id}":                     {Summary: "Get a user", Response: users.UserResponse{}},
+	"PUT /api/v1/users/{id}":                     {Summary: "Update a user", Request: users.UpdateUserRequest{}, Response: users.UserResponse{}},
+	"DELETE /api/v1/users/{id}":                  {Summary: "Delete a user", Status: http.StatusNoContent},
+	"GET /api/v1/users/{id}/activity":            {Summary: "Get a user's activity report (?since=30d)", Response: users.UserActivityReport{}},
+	"GET /api/v1/users/inactive":                 {Summary: "List accounts without a login in the last ?days=90 days", Response: []users.UserActivity{}},
+	"GET /api/v1/users/{id}/ssh-keys":            {Summary: "List a user's SSH keys", Response: []users.SSHKey{}},
+	"POST /api/v1/users/{id}/ssh-keys":           {Summary: "Authorize an SSH public key for a user", Request: handlers.AddSSHKeyRequest{}, Response: users.SSHKey{}, Status: http.StatusCreated},
+	"PUT /api/v1/users/{id}/ssh-keys/{keyId}":    {Summary: "Relabel an SSH key", Request: handlers.UpdateSSHKeyRequest{}, Response: users.SSHKey{}},
+	"DELETE /api/v1/users/{id}/ssh-keys/{keyId}": {Summary: "Revoke an SSH key"},
+
+	"GET /api/v1/groups/{id}/tree":                   {Summary: "Get a group with its nested subgroups", Response: usergroups.GroupTreeNode{}},
+	"POST /api/v1/groups/{id}/subgroups":             {Summary: "Nest a group in this group", Request: usergroups.AddSubgroupRequest{}, Status: http.StatusNoContent},
+	"DELETE /api/v1/groups/{id}/subgroups/{childId}": {Summary: "Remove a nested group", Status: http.StatusNoContent},
+
+	"POST /api/v1/2fa/backup-codes/regenerate": {Summary: "Replace the backup codes after verifying a TOTP code; the new codes are shown only once"},
+	"GET /api/v1/2fa/backup-codes/status":      {Summary: "Get which backup codes were used, without the codes", Response: handlers.BackupCodesStatus{}},
+
+	"GET /api/v1/storage/stats":                                  {Summary: "Get storage statistics", Response: storage.StorageStats{}},
+	"GET /api/v1/storage/shares":                                 {Summary: "List shares", Response: []storage.Share{}},
+	"POST /api/v1/storage/shares":                                {Summary: "Create a share", Request: storage.CreateShareRequest{}, Response: storage.Share{}},
+	"GET /api/v1/storage/shares/{id}":                            {Summary: "Get a share", Response: storage.Share{}},
+	"PUT /api/v1/storage/shares/{id}":                            {Summary: "Update a share", Request: storage.CreateShareRequest{}, Response: storage.Share{}},
+	"POST /api/v1/storage/shares/{id}/transfer":                  {Summary: "Move a share's data to another volume in the background; the share stays online and is only read-only during the final sync", Request: handlers.ShareTransferRequest{}, Response: map[string]string{}, Status: http.StatusAccepted},
+	"GET /api/v1/storage/shares/{id}/transfer/{jobId}/progress":  {Summary: "Get the status and rsync progress of a share transfer", Response: storage.TransferProgress{}},
+	"GET /api/v1/storage/volumes/{id}/health-history":            {Summary: "List the latest canary file checks of a mounted volume, newest first (?limit=100)", Response: []models.VolumeHealthCheck{}},
+	"GET /api/v1/storage/shares/{id}/access-log":                 {Summary: "List the Samba and NFS connections to a share, newest first", Response: storage.ShareAccessLogPage{}},
+	"GET /api/v1/network/traffic":                                {Summary: "Get share traffic accounted from connection tracking", Response: handlers.TrafficResponse{}},
+	"GET /api/v1/network/ddns/status":                            {Summary: "Get the dynamic DNS configuration and record state", Response: network.DDNSStatus{}},
+	"PUT /api/v1/network/ddns/config":                            {Summary: "Configure dynamic DNS; the stored credential is kept if omitted", Request: network.DynamicDNSConfig{}, Response: network.DDNSStatus{}},
+	"POST /api/v1/network/mdns/restart":                          {Summary: "Withdraw and announce the mDNS/Bonjour services again", Response: []network.MDNSService{}},
+	"GET /api/v1/network/port-forwards":                          {Summary: "List NAT port forwards", Response: []network.PortForwardRule{}},
+	"POST /api/v1/network/port-forwards":                         {Summary: "Forward an external port to a private IPv4 host", Request: handlers.PortForwardRequest{}, Response: network.PortForwardRule{}, Status: http.StatusCreated},
+	"PUT /api/v1/network/port-forwards/{id}":                     {Summary: "Replace a port forward", Request: handlers.PortForwardRequest{}, Response: network.PortForwardRule{}},
+	"DELETE /api/v1/network/port-forwards/{id}":                  {Summary: "Remove a port forward", Status: http.StatusNoContent},
+	"GET /api/v1/network/vxlan":                                  {Summary: "List the VXLAN tunnels to other nodes", Response: []network.VXLANConfig{}},
+	"POST /api/v1/network/vxlan":                                 {Summary: "Create a VXLAN tunnel to another node and attach it to a bridge", Request: network.VXLANConfig{}, Response: network.VXLANConfig{}, Status: http.StatusCreated},
+	"GET /api/v1/network/vxlan/{name}":                           {Summary: "Get a VXLAN tunnel", Response: network.VXLANConfig{}},
+	"DELETE /api/v1/network/vxlan/{name}":                        {Summary: "Remove a VXLAN tunnel", Status: http.StatusNoContent},
+	"GET /api/v1/network/arp/devices":                            {Summary: "List the devices seen in ARP replies with their MAC addresses", Response: []models.ARPEntry{}},
+	"POST /api/v1/network/arp/whitelist":                         {Summary: "Let an IP move to a MAC address without an ARP spoofing alert", Request: handlers.WhitelistMACRequest{}},
+	"GET /api/v1/cloudbackup/remotes":                            {Summary: "List the rclone remotes with their credentials masked", Response: []cloudbackup.RcloneRemote{}},
+	"POST /api/v1/cloudbackup/remotes":                           {Summary: "Add an rclone remote", Request: handlers.CreateRcloneRemoteRequest{}, Response: cloudbackup.RcloneRemote{}, Status: http.StatusCreated},
+	"GET /api/v1/cloudbackup/remotes/{name}":                     {Summary: "Get an rclone remote with its credentials masked", Response: cloudbackup.RcloneRemote{}},
+	"PUT /api/v1/cloudbackup/remotes/{name}":                     {Summary: "Change the parameters of an rclone remote; masked values are kept", Request: handlers.UpdateRcloneRemoteRequest{}, Response: cloudbackup.RcloneRemote{}},
+	"DELETE /api/v1/cloudbackup/remotes/{name}":                  {Summary: "Remove an rclone remote", Status: http.StatusNoContent},
+	"POST /api/v1/cloudbackup/remotes/{name}/test":               {Summary: "Test the connection to an rclone remote", Response: cloudbackup.RemoteTestResult{}},
+	"GET /api/v1/syslib/ha/cluster/status":                       {Summary: "Get the combined DRBD, Pacemaker and Keepalived cluster status", Response: cluster.ClusterStatus{}},
+	"POST /api/v1/syslib/ha/cluster/failover":                    {Summary: "Fail over all HA services to a node and fence the old primary", Request: handlers.FailoverRequest{}},
+	"POST /api/v1/syslib/ha/fence/{node}":                        {Summary: "Power off a cluster node through its fencing device"},
+	"PUT /api/v1/syslib/ha/fence/{node}/config":                  {Summary: "Configure IPMI, APC PDU or AWS EC2 fencing of a node", Request: handlers.FencingConfigRequest{}, Response: ha.NodeFencing{}},
+	"POST /api/v1/syslib/ha/fence/{node}/test":                   {Summary: "Check that a node's fencing device is reachable without powering it off"},
+	"POST /api/v1/storage/volumes":                               {Summary: "Create a volume", Request: storage.CreateVolumeRequest{}},
+	"GET /api/v1/admin/access-logs":                              {Summary: "List HTTP access logs with filters and pagination"},
+	"GET /api/v1/admin/cache/stats":                              {Summary: "Get query cache statistics", Response: database.CacheStats{}},
+	"POST /api/v1/admin/cache/flush":                             {Summary: "Flush the query cache", Response: database.CacheStats{}},
+	"GET /api/v1/admin/database/pool-stats":                      {Summary: "Get database connection pool statistics", Response: database.PoolStats{}},
+	"GET /api/v1/admin/rate-limits":                              {Summary: "List custom rate limits", Response: []models.RateLimit{}},
+	"PUT /api/v1/admin/rate-limits/{target}":                     {Summary: "Set the rate limit for a user or IP range", Request: handlers.SetRateLimitRequest{}, Response: models.RateLimit{}},
+	"DELETE /api/v1/admin/rate-limits/{target}":                  {Summary: "Remove a custom rate limit", Status: http.StatusNoContent},
+	"GET /api/v1/admin/telemetry":                                {Summary: "Get the telemetry settings and a preview of the anonymous report", Response: handlers.TelemetrySettings{}},
+	"PUT /api/v1/admin/telemetry":                                {Summary: "Opt in to or out of anonymous usage statistics", Request: handlers.UpdateTelemetryRequest{}, Response: handlers.TelemetrySettings{}},
+	"GET /api/v1/admin/secrets/list":                             {Summary: "List the keys of the stored config secrets (secret:read)", Response: []string{}},
+	"POST /api/v1/admin/secrets":                                 {Summary: "Store a config secret referenced as {secret:key} (secret:update)", Request: handlers.SetSecretRequest{}, Response: map[string]string{}},
+	"GET /api/v1/admin/roles":                                    {Summary: "List roles with their permissions", Response: []models.Role{}},
+	"POST /api/v1/admin/roles":                                   {Summary: "Create a custom role", Request: rbac.CreateRoleRequest{}, Response: models.Role{}, Status: http.StatusCreated},
+	"GET /api/v1/admin/roles/{id}":                               {Summary: "Get a role", Response: models.Role{}},
+	"PUT /api/v1/admin/roles/{id}":                               {Summary: "Rename or describe a custom role", Request: rbac.UpdateRoleRequest{}, Response: models.Role{}},
+	"DELETE /api/v1/admin/roles/{id}":                            {Summary: "Delete a custom role and revoke it from all users", Status: http.StatusNoContent},
+	"GET /api/v1/admin/roles/{id}/permissions":                   {Summary: "List the permissions of a role", Response: []rbac.Permission{}},
+	"PUT /api/v1/admin/roles/{id}/permissions":                   {Summary: "Replace the permissions of a custom role", Request: handlers.SetRolePermissionsRequest{}, Response: []rbac.Permission{}},
+	"GET /api/v1/admin/users/{id}/roles":                         {Summary: "List the roles assigned to a user", Response: []models.UserRole{}},
+	"POST /api/v1/admin/users/{id}/roles":                        {Summary: "Assign a role to a user, optionally until expiresAt", Request: rbac.AssignRoleRequest{}, Response: models.UserRole{}},
+	"DELETE /api/v1/admin/users/{id}/roles/{roleId}":             {Summary: "Revoke a role from a user", Status: http.StatusNoContent},
+	"GET /api/v1/syslib/zfs/pools/{name}/scrub-schedule":         {Summary: "Get the scrub schedule and last scrub result of a pool", Response: handlers.ScrubScheduleResponse{}},
+	"PUT /api/v1/syslib/zfs/pools/{name}/scrub-schedule":         {Summary: "Scrub a pool on a cron schedule", Request: handlers.ScrubScheduleRequest{}, Response: handlers.ScrubScheduleResponse{}},
+	"DELETE /api/v1/syslib/zfs/pools/{name}/scrub-schedule":      {Summary: "Stop scheduled scrubs of a pool", Status: http.StatusNoContent},
+	"POST /api/v1/syslib/zfs/auto-tiering/analyze":               {Summary: "Report how recently the datasets of a pool were read, from the access times of their files", Request: handlers.AutoTieringAnalyzeRequest{}, Response: []sysstorage.DatasetTemperature{}},
+	"POST /api/v1/syslib/zfs/auto-tiering/run":                   {Summary: "Move the datasets not read within the threshold from the hot to the cold pool with zfs send", Request: sysstorage.AutoTieringPolicy{}, Response: sysstorage.MigrationReport{}},
+	"POST /api/v1/syslib/zfs/datasets/encrypted":                 {Summary: "Create a dataset with ZFS native encryption", Request: handlers.CreateEncryptedDatasetRequest{}, Status: http.StatusCreated},
+	"POST /api/v1/syslib/zfs/datasets/{name}/mount-encrypted":    {Summary: "Load the key of an encrypted dataset and mount it", Request: handlers.EncryptionKeyRequest{}},
+	"POST /api/v1/syslib/zfs/datasets/{name}/unmount-encrypted":  {Summary: "Unmount an encrypted dataset and unload its key"},
+	"POST /api/v1/syslib/zfs/datasets/{name}/change-key":         {Summary: "Change the passphrase of an encrypted dataset", Request: handlers.EncryptionKeyRequest{}},
+	"GET /api/v1/syslib/btrfs/subvolumes":                        {Summary: "List the subvolumes of a BTRFS filesystem given by the mountpoint query parameter", Response: []sysstorage.BTRFSSubvolume{}},
+	"POST /api/v1/syslib/btrfs/subvolumes":                       {Summary: "Create a BTRFS subvolume", Request: handlers.BTRFSSubvolumeRequest{}, Status: http.StatusCreated},
+	"DELETE /api/v1/syslib/btrfs/subvolumes":                     {Summary: "Delete the BTRFS subvolume or snapshot given by the path query parameter", Status: http.StatusNoContent},
+	"POST /api/v1/syslib/btrfs/snapshots":                        {Summary: "Snapshot a BTRFS subvolume", Request: handlers.BTRFSSnapshotRequest{}, Status: http.StatusCreated},
+	"POST /api/v1/syslib/btrfs/send-receive":                     {Summary: "Replicate a read-only snapshot to another BTRFS filesystem, incrementally from a parent snapshot", Request: handlers.BTRFSSendReceiveRequest{}},
+	"GET /api/v1/syslib/btrfs/usage":                             {Summary: "Get the space usage of a BTRFS filesystem given by the mountpoint query parameter", Response: sysstorage.BTRFSUsage{}},
+	"GET /api/v1/syslib/smart/{device}/history":                  {Summary: "Get the self-test log of a disk, most recent first", Response: []sysstorage.SMARTTestResult{}},
+	"GET /api/v1/syslib/smart/{device}/schedule":                 {Summary: "Get the self-test schedule of a disk", Response: sysstorage.SMARTTestSchedule{}},
+	"PUT /api/v1/syslib/smart/{device}/schedule":                 {Summary: "Run short and long self-tests of a disk on cron schedules", Request: handlers.SMARTScheduleRequest{}, Response: sysstorage.SMARTTestSchedule{}},
+	"POST /api/v1/syslib/acl/inherit":                            {Summary: "Propagate ACLs to a directory tree in the background", Request: handlers.InheritACLRequest{}, Status: http.StatusAccepted},
+	"PUT /api/v1/syslib/vms/{name}/cpu-pinning":                  {Summary: "Pin the virtual CPUs of a VM to host CPUs", Request: handlers.CPUPinningRequest{}},
+	"PUT /api/v1/syslib/vms/{name}/memory-backing":               {Summary: "Back a VM's memory with hugepages on specific NUMA nodes (applies on next start)", Request: handlers.MemoryBackingRequest{}},
+	"GET /api/v1/syslib/vms/{name}/disks":                        {Summary: "List the disks attached to a VM", Response: []vm.VMDisk{}},
+	"POST /api/v1/syslib/vms/{name}/disks":                       {Summary: "Attach a disk image to a VM on the next free target, hot-plugged if the VM runs", Request: handlers.AddVMDiskRequest{}, Response: []vm.VMDisk{}, Status: http.StatusCreated},
+	"PUT /api/v1/syslib/vms/{name}/disks/{target}":               {Summary: "Grow a VM disk; running VMs see the new size right away", Request: handlers.ResizeVMDiskRequest{}},
+	"DELETE /api/v1/syslib/vms/{name}/disks/{target}":            {Summary: "Detach a disk from a VM (?delete_image=true deletes its image)", Status: http.StatusNoContent},
+	"GET /api/v1/syslib/numa/topology":                           {Summary: "Get the NUMA nodes of the host with their CPUs and memory", Response: vm.NUMATopology{}},
+	"GET /api/v1/syslib/quota/projects":                          {Summary: "List the XFS project quotas", Response: []filesystem.XFSProject{}},
+	"POST /api/v1/syslib/quota/projects":                         {Summary: "Create an XFS project quota on a directory tree", Request: handlers.CreateProjectRequest{}, Response: filesystem.XFSProject{}, Status: http.StatusCreated},
+	"GET /api/v1/syslib/quota/projects/{name}":                   {Summary: "Get the limits and usage of a project", Response: handlers.QuotaProjectResponse{}},
+	"PUT /api/v1/syslib/quota/projects/{name}":                   {Summary: "Replace the limits of a project", Request: handlers.ProjectLimitsRequest{}, Response: filesystem.XFSProject{}},
+	"DELETE /api/v1/syslib/quota/projects/{name}":                {Summary: "Remove a project quota", Status: http.StatusNoContent},
+	"GET /api/v1/syslib/acl/jobs/{id}/status":                    {Summary: "Get the progress of an ACL inheritance job", Response: models.ACLJob{}},
+	"GET /api/v1/lxc/containers/{name}/network":                  {Summary: "Get the bridge, VLAN and address of a container", Response: lxc.LXCNetworkConfig{}},
+	"PUT /api/v1/lxc/containers/{name}/network":                  {Summary: "Attach a container to a bridge and VLAN, optionally with a static address from an IPAM pool; applies on the next start", Request: handlers.ContainerNetworkRequest{}, Response: lxc.LXCNetworkConfig{}},
+	"POST /api/v1/lxc/containers/{name}/migrate":                 {Summary: "Live-migrate a container to another NAS with CRIU (202 with the migration)", Request: handlers.MigrateContainerRequest{}, Response: models.ContainerMigration{}, Status: http.StatusAccepted},
+	"POST /api/v1/lxc/containers/{name}/restore":                 {Summary: "Restore a container from the checkpoint a migration copied to this host", Request: handlers.RestoreContainerRequest{}},
+	"GET /api/v1/lxc/migrations":                                 {Summary: "List container migrations, most recent first", Response: []models.ContainerMigration{}},
+	"GET /api/v1/lxc/migrations/{id}":                            {Summary: "Get the progress of a container migration", Response: models.ContainerMigration{}},
+	"GET /api/v1/plugins/{id}/logs":                              {Summary: "Get the latest stdout/stderr lines of a plugin (?tail=100&since=2h)", Response: []models.PluginLog{}},
+	"GET /api/v1/plugins/{id}/logs/stream":                       {Summary: "Stream the new stdout/stderr lines of a plugin (text/event-stream)"},
+	"GET /api/v1/store/plugins":                                  {Summary: "List the addons of the marketplace registry, filtered by ?category= and searched by ?q=", Response: []addons.Manifest{}},
+	"GET /api/v1/store/plugins/{id}":                             {Summary: "Get the manifest of a marketplace addon", Response: addons.Manifest{}},
+	"GET /api/v1/security/lockout-policy":                        {Summary: "Get the failed login lockout policy (security:read)", Response: handlers.LockoutPolicy{}},
+	"PUT /api/v1/security/lockout-policy":                        {Summary: "Replace the failed login lockout policy (security:manage)", Request: handlers.LockoutPolicy{}, Response: handlers.LockoutPolicy{}},
+	"GET /api/v1/security/locked-accounts":                       {Summary: "List usernames locked out after failed logins", Response: []models.AccountLockout{}},
+	"POST /api/v1/security/unlock-account":                       {Summary: "Lift the lockout of a username", Request: handlers.UnlockAccountRequest{}},
+	"GET /api/v1/auth/mfa-policy":                                {Summary: "Get the policy requiring 2FA for roles or external IPs (security:read)", Response: models.MFAPolicy{}},
+	"PUT /api/v1/auth/mfa-policy":                                {Summary: "Replace the MFA policy, starting a new grace period (security:manage)", Request: handlers.MFAPolicyRequest{}, Response: models.MFAPolicy{}},
+	"GET /api/v1/auth/saml/metadata":                             {Summary: "Get the SAML service provider metadata (XML) to register the NAS at the IdP"},
+	"GET /api/v1/auth/saml/login":                                {Summary: "Start a SAML login by redirecting to the IdP"},
+	"POST /api/v1/auth/saml/acs":                                 {Summary: "SAML assertion consumer service; redirects to the web UI with the session tokens in the URL fragment"},
+	"GET /api/v1/auth/saml/config":                               {Summary: "Get the SAML single sign-on configuration (security:read)", Response: models.SAMLConfig{}},
+	"PUT /api/v1/auth/saml/config":                               {Summary: "Replace the SAML configuration, fetching the IdP metadata when enabled (security:manage)", Request: handlers.SAMLConfigRequest{}, Response: models.SAMLConfig{}},
+	"GET /api/v1/audit/retention-policy":                         {Summary: "Get how long and how many audit logs are kept", Response: models.AuditRetentionPolicy{}},
+	"PUT /api/v1/audit/retention-policy":                         {Summary: "Replace the audit log retention policy, applied by the nightly retention task (audit:manage)", Request: models.AuditRetentionPolicy{}, Response: models.AuditRetentionPolicy{}},
+	"GET /api/v1/vpn/status":                                     {Summary: "Get the status of a VPN protocol across its interfaces (?protocol=wireguard)", Response: vpn.ProtocolStatus{}},
+	"GET /api/v1/vpn/wireguard/interfaces":                       {Summary: "List WireGuard interfaces", Response: []vpn.WireGuardInterface{}},
+	"POST /api/v1/vpn/wireguard/interfaces":                      {Summary: "Create and start a WireGuard interface; the listen port must be unused", Request: vpn.WireGuardInterface{}, Response: vpn.WireGuardInterface{}, Status: http.StatusCreated},
+	"GET /api/v1/vpn/wireguard/interfaces/{name}":                {Summary: "Get a WireGuard interface and its peers", Response: handlers.WireGuardInterfaceDetails{}},
+	"PUT /api/v1/vpn/wireguard/interfaces/{name}":                {Summary: "Change the listen port and address of a WireGuard interface", Request: vpn.WireGuardInterface{}, Response: vpn.WireGuardInterface{}},
+	"DELETE /api/v1/vpn/wireguard/interfaces/{name}":             {Summary: "Stop a WireGuard interface and remove its configuration", Status: http.StatusNoContent},
+	"GET /api/v1/vpn/wireguard/interfaces/{name}/stats":          {Summary: "Get the listen port, peer count and traffic of a running WireGuard interface", Response: vpn.WireGuardIfaceStats{}},
+	"POST /api/v1/vpn/wireguard/interfaces/{name}/peers":         {Summary: "Add a peer to a WireGuard interface, optionally with an address from an IPAM pool", Request: handlers.WireGuardPeerRequest{}, Response: vpn.WireGuardPeer{}, Status: http.StatusCreated},
+	"GET /api/v1/vpn/wireguard/interfaces/{name}/peers":          {Summary: "List the peers of a WireGuard interface with their split tunnel policies", Response: []models.VPNPeer{}},
+	"GET /api/v1/vpn/wireguard/peers/{id}/config":                {Summary: "Get the wg-quick configuration of a peer's client", Response: handlers.PeerClientConfig{}},
+	"PUT /api/v1/vpn/wireguard/peers/{id}/split-tunnel":          {Summary: "Set the traffic a peer's client routes through the tunnel and regenerate its configuration", Request: vpn.SplitTunnelPolicy{}, Response: handlers.PeerClientConfig{}},
+	"GET /api/v1/network/bridges/{name}/stats":                   {Summary: "Get the byte and packet counters of a bridge and the STP state of its ports", Response: network.BridgeStats{}},
+	"GET /api/v1/network/bridges/{name}/fdb":                     {Summary: "Get the forwarding database of a bridge", Response: []network.FDBEntry{}},
+	"GET /api/v1/health/score/breakdown":                         {Summary: "Get the component scores of the current health score and the deductions that lowered it", Response: metrics.HealthScore{}},
+	"GET /api/v1/monitoring/custom-metrics":                      {Summary: "List user-defined Prometheus metrics with their current values", Response: []metrics.CustomMetricStatus{}},
+	"POST /api/v1/monitoring/custom-metrics":                     {Summary: "Define a Prometheus metric computed from a template expression over the collected system metrics", Request: metrics.CustomMetric{}, Response: models.CustomMetric{}, Status: http.StatusCreated},
+	"PUT /api/v1/monitoring/custom-metrics/{id}":                 {Summary: "Change a user-defined metric", Request: metrics.CustomMetric{}, Response: models.CustomMetric{}},
+	"DELETE /api/v1/monitoring/custom-metrics/{id}":              {Summary: "Delete a user-defined metric", Status: http.StatusNoContent},
+	"GET /api/v1/monitoring/export/config":                       {Summary: "Get the settings for pushing metrics to InfluxDB or a Prometheus Pushgateway", Response: handlers.MetricsExportConfigResponse{}},
+	"PUT /api/v1/monitoring/export/config":                       {Summary: "Change the metrics export settings and restart the export; an empty token keeps the current one", Request: handlers.MetricsExportConfigRequest{}, Response: handlers.MetricsExportConfigResponse{}},
+	"POST /api/v1/monitoring/export/test":                        {Summary: "Push the current metrics once with the settings in the body, or the settings in use if it is empty", Request: handlers.MetricsExportConfigRequest{}, Response: metrics.ExportTestResult{}},
+	"GET /api/v1/monitoring/maintenance-windows":                 {Summary: "List maintenance windows muting alerts", Response: []models.MaintenanceWindow{}},
+	"POST /api/v1/monitoring/maintenance-windows":                {Summary: "Mute all alerts or the matching alert types between start and end, returning the window ID", Request: alerts.MaintenanceWindow{}, Status: http.StatusCreated},
+	"PUT /api/v1/monitoring/maintenance-windows/{id}":            {Summary: "Change a maintenance window", Request: alerts.MaintenanceWindow{}, Response: models.MaintenanceWindow{}},
+	"DELETE /api/v1/monitoring/maintenance-windows/{id}":         {Summary: "Delete a maintenance window and its suppressed alerts", Status: http.StatusNoContent},
+	"GET /api/v1/monitoring/maintenance-windows/{id}/suppressed": {Summary: "List the alerts muted by a maintenance window", Response: []models.SuppressedAlert{}},
+	"POST /api/v1/monitoring/maintenance-windows/{id}/summary":   {Summary: "Email the digest of alerts muted by an ended maintenance window (409 while it is active)"},
+	"GET /api/v1/alerts/rules":                                   {Summary: "List alert rules over system metrics", Response: []models.AlertRule{}},
+	"POST /api/v1/alerts/rules":                                  {Summary: "Create an alert rule combining metric conditions with and/or; each condition must hold for its window (nanoseconds)", Request: alerts.AlertRule{}, Response: models.AlertRule{}, Status: http.StatusCreated},
+	"PUT /api/v1/alerts/rules/{id}":                              {Summary: "Change an alert rule, restarting its condition windows", Request: alerts.AlertRule{}, Response: models.AlertRule{}},
+	"DELETE /api/v1/alerts/rules/{id}":                           {Summary: "Delete an alert rule", Status: http.StatusNoContent},
+	"GET /api/v1/storage/shares/{id}/snapshots":                  {Summary: "List the ZFS snapshots of a share, oldest first", Response: []storage.SnapshotEntry{}},
+	"GET /api/v1/storage/shares/{id}/snapshots/{snap}/browse":    {Summary: "List a directory of a share, given by the path query parameter relative to the share, as it was in a snapshot", Response: files.BrowseResponse{}},
+	"POST /api/v1/storage/shares/{id}/snapshots/{snap}/restore":  {Summary: "Copy a file from a snapshot back into the share, in place or to restoreTo", Request: storage.RestoreFileRequest{}},
+	"GET /api/v1/network/ipam/pools":                             {Summary: "List IP address management pools", Response: []models.IPAMPool{}},
+	"POST /api/v1/network/ipam/pools":                            {Summary: "Create an IPAM pool for a subnet", Request: network.IPAMPool{}, Response: models.IPAMPool{}, Status: http.StatusCreated},
+	"PUT /api/v1/network/ipam/pools/{name}":                      {Summary: "Replace the subnet, gateway, DNS servers and excluded ranges of an IPAM pool", Request: network.IPAMPool{}, Response: models.IPAMPool{}},
+	"DELETE /api/v1/network/ipam/pools/{name}":                   {Summary: "Delete an IPAM pool without allocations", Status: http.StatusNoContent},
+	"GET /api/v1/network/ipam/pools/{name}/allocations":          {Summary: "List the allocated addresses of an IPAM pool", Response: []models.IPAllocation{}},
+	"POST /api/v1/network/ipam/pools/{name}/allocations":         {Summary: "Allocate the next free address of an IPAM pool", Request: handlers.IPAllocationRequest{}, Status: http.StatusCreated},
+	"DELETE /api/v1/network/ipam/allocations/{ip}":               {Summary: "Release an allocated address", Status: http.StatusNoContent},
+	"POST /api/v1/lxc/containers":                                {Summary: "Create an LXC container, optionally with a static address from an IPAM pool", Request: handlers.ContainerCreateRequest{}},
+	"PUT /api/v1/docker/containers/{id}/priority":                {Summary: "Set the priority the resource governor gives a container (high containers are never throttled)", Request: handlers.ContainerPriorityRequest{}},
+	"GET /api/v1/docker/resource-governor/status":                {Summary: "Get the resource governor's config, the host load and the throttled containers", Response: docker.GovernorStatus{}},
+	"PUT /api/v1/docker/resource-governor/config":                {Summary: "Update the memory and CPU steal thresholds of the resource governor", Request: docker.GovernorConfig{}, Response: docker.GovernorStatus{}},
+	"POST /api/v1/docker/stacks/{name}/diff":                     {Summary: "Compare the compose file of a stack with a new one before redeploying", Request: handlers.DiffStackRequest{}, Response: docker.StackDiff{}},
+	"GET /api/v1/notifications/vapid-public-key":                 {Summary: "Get the VAPID public key browsers subscribe to push notifications with"},
+	"POST /api/v1/notifications/subscribe":                       {Summary: "Subscribe a browser to push notifications", Request: notifications.PushSubscription{}, Response: models.PushSubscription{}, Status: http.StatusCreated},
+	"DELETE /api/v1/notifications/unsubscribe":                   {Summary: "Unsubscribe a browser from push notifications", Request: handlers.UnsubscribePushRequest{}, Status: http.StatusNoContent},
+	"POST /api/v1/files/dedup/scan":                              {Summary: "Start a background scan for duplicate files, optionally hardlinking them", Request: handlers.DedupeScanRequest{}, Response: map[string]string{}, Status: http.StatusAccepted},
+	"GET /api/v1/files/dedup/results/{jobId}":                    {Summary: "Get the progress and report of a duplicate file scan", Response: models.DedupeJob{}},
+	"GET /api/v1/files/thumbnail":                                {Summary: "Get a JPEG thumbnail of an image or video (image/jpeg)"},
+	"POST /api/v1/files/archive/create":                          {Summary: "Create a zip, tar, tar.gz or tar.xz archive (202 with a job ID for large archives)", Request: files.CreateArchiveRequest{}, Response: files.ArchiveResult{}},
+	"POST /api/v1/files/archive/extract":                         {Summary: "Extract an archive, detecting its format from the content", Request: files.ExtractRequest{}},
+	"GET /api/v1/files/search":                                   {Summary: "Search file contents below a directory", Response: handlers.SearchResponse{}},
+	"GET /api/v1/files/versions":                                 {Summary: "List the stored versions of a file, newest first", Response: []versioning.FileVersion{}},
+	"POST /api/v1/files/rename/bulk":                             {Summary: "Rename the files in a directory matching a regular expression (409 with the result on name conflicts)", Request: files.BulkRenameRequest{}, Response: files.BulkRenameResult{}},
+	"POST /api/v1/files/versions/{id}/restore":                   {Summary: "Restore a file version; the current content is kept as a new version", Request: handlers.RestoreVersionRequest{}},
+	"GET /api/v1/files/upload/{sessionId}/progress":              {Summary: "Get chunked upload progress, including sessions resumed after a restart", Response: files.UploadProgress{}},
+	"GET /api/v1/ad-dc/ldap/search":                              {Summary: "Search the domain directory (query: base, filter, attrs; at most 1000 entries)", Response: []ad.LDAPEntry{}},
+	"GET /api/v1/ad-dc/ldap/object":                              {Summary: "Get a directory object with all its attributes (query: dn)", Response: ad.LDAPEntry{}},
+	"GET /api/v1/ad-dc/kerberos/tickets":                         {Summary: "List the Kerberos tickets of the service principals", Response: []ad.KerberosTicket{}},
+	"POST /api/v1/ad-dc/kerberos/obtain":                         {Summary: "Obtain a renewable TGT for a service principal with a password or keytab; it is renewed 30 minutes before expiry", Request: handlers.KerberosObtainRequest{}, Response: map[string]string{}},
+	"GET /api/v1/system/dependencies":                            {Summary: "List the system packages the NAS uses with their version and install status", Response: dependencies.DependencyReport{}},
+	"GET /api/v1/system/updates/security":                        {Summary: "List the pending security updates of the system packages", Response: []updates.Package{}},
+	"POST /api/v1/system/updates/security/apply":                 {Summary: "Install the pending security updates after snapshotting a ZFS root filesystem", Response: updates.UpdateReport{}},
+	"GET /api/v1/system/benchmark/results":                       {Summary: "List stored stumpfctl system benchmark results, newest first (?test=disk&limit=100)", Response: []models.BenchmarkResult{}},
+	"POST /api/v1/system/benchmark/results":                      {Summary: "Store the results of a stumpfctl system benchmark run under a new run ID", Request: handlers.SaveBenchmarkResultsRequest{}, Response: []models.BenchmarkResult{}, Status: http.StatusCreated},
+	"GET /api/v1/system/processes":                               {Summary: "List the running NAS daemons (Samba, NFS, VPN, Docker, cluster and the server) with their memory, CPU use over 100ms and open files", Response: []system.ProcessInfo{}},
+	"GET /api/v1/system/startup-status":                          {Summary: "Get the progress of the service startup; served without authentication while the server starts", Response: lifecycle.StartupStatus{}},
+	"GET /api/v1/events/stream":                                  {Summary: "Stream events (text/event-stream)"},
+	"GET /api/v1/openapi.yaml":                                   {Summary: "Get this OpenAPI specification"},
+}
+
+// openAPIExcluded lists routes that are not part of the REST API
+var openAPIExcluded = []string{"/*"}
+
+// GenerateOpenAPI builds the OpenAPI specification for a router created by NewRouter
+func GenerateOpenAPI(router http.Handler) (*openapi.Document, error) {
+	routes, ok := router.(chi.Routes)
+	if !ok {
+		return nil, fmt.Errorf("router does not support route introspection")
+	}
+
+	return openapi.Generate(routes, openapi.Options{
+		Title:           "Stumpf.Works NAS API",
+		Description:     "REST API of the Stumpf.Works NAS management server",
+		Version:         "v1",
+		Docs:            openAPIDocs,
+		AuthMiddlewares: []func(http.Handler) http.Handler{mw.AuthMiddleware},
+		Exclude:         openAPIExcluded,
+	})
+}
+
+// openAPIHandler serves the generated specification. The spec is generated on
+// first request, once all routes have been registered.
+func openAPIHandler(router http.Handler) http.HandlerFunc {
+	var (
+		once sync.Once
+		spec []byte
+		err  error
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			var doc *openapi.Document
+			if doc, err = GenerateOpenAPI(router); err == nil {
+				spec, err = doc.Marshal()
+			}
+			if err != nil {
+				logger.Error("Failed to generate OpenAPI specification", zap.Error(err))
+			}
+		})
+
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to generate OpenAPI specification", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/yaml")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(spec)
+	}
+}
@@ -0,0 +1,492 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// bearerScheme is the name of the JWT security scheme
+const bearerScheme = "bearerAuth"
+
+// RouteDoc adds documentation to a route, keyed by "METHOD /path/{param}"
+type RouteDoc struct {
+	Summary  string
+	Request  interface{} // Zero value of the request body type (nil = none)
+	Response interface{} // Zero value of the response data type (nil = untyped)
+	Status   int         // Success status code (default 200)
+}
+
+// Options configures the generator
+type Options struct {
+	Title       string
+	Description string
+	Version     string
+
+	// Docs documents individual routes
+	Docs map[string]RouteDoc
+
+	// AuthMiddlewares marks routes as requiring a bearer token when any of
+	// these middlewares is in their chain
+	AuthMiddlewares []func(http.Handler) http.Handler
+
+	// Exclude lists route patterns that should not appear in the spec
+	Exclude []string
+}
+
+var paramPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// generator builds a Document
+type generator struct {
+	opts    Options
+	doc     *Document
+	types   map[reflect.Type]string
+	authPCs map[uintptr]bool
+}
+
+// Generate walks the router and builds an OpenAPI document
+func Generate(routes chi.Routes, opts Options) (*Document, error) {
+	g := &generator{
+		opts: opts,
+		doc: &Document{
+			OpenAPI: Version,
+			Info: Info{
+				Title:       opts.Title,
+				Description: opts.Description,
+				Version:     opts.Version,
+			},
+			Paths: make(map[string]PathItem),
+			Components: Components{
+				Schemas: make(map[string]*Schema),
+				SecuritySchemes: map[string]SecurityScheme{
+					bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+		types:   make(map[reflect.Type]string),
+		authPCs: make(map[uintptr]bool),
+	}
+	for _, m := range opts.AuthMiddlewares {
+		g.authPCs[reflect.ValueOf(m).Pointer()] = true
+	}
+	g.addEnvelopeSchemas()
+
+	excluded := make(map[string]bool, len(opts.Exclude))
+	for _, p := range opts.Exclude {
+		excluded[p] = true
+	}
+
+	// chi.Walk visits the methods of a path in map order. Operations are
+	// built in sorted order instead, so the type seen first, which gets the
+	// unprefixed component name, is the same on every run.
+	type walkedRoute struct {
+		method, route string
+		handler       http.Handler
+		middlewares   []func(http.Handler) http.Handler
+	}
+	var routeList []walkedRoute
+	err := chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !excluded[route] {
+			routeList = append(routeList, walkedRoute{method, route, handler, middlewares})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk routes: %w", err)
+	}
+	sort.Slice(routeList, func(i, j int) bool {
+		if routeList[i].route != routeList[j].route {
+			return routeList[i].route < routeList[j].route
+		}
+		return routeList[i].method < routeList[j].method
+	})
+
+	tags := make(map[string]bool)
+	for _, r := range routeList {
+		path, params := NormalizePath(r.route)
+		tag := tagFor(path)
+		tags[tag] = true
+
+		item, ok := g.doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			g.doc.Paths[path] = item
+		}
+		item[strings.ToLower(r.method)] = g.operation(r.method, path, params, tag, r.handler, r.middlewares)
+	}
+
+	for tag := range tags {
+		g.doc.Tags = append(g.doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(g.doc.Tags, func(i, j int) bool { return g.doc.Tags[i].Name < g.doc.Tags[j].Name })
+
+	return g.doc, nil
+}
+
+// Marshal encodes the document as YAML
+func (d *Document) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NormalizePath converts a chi route pattern into an OpenAPI path template and
+// returns its path parameter names. Regex constraints are dropped and a
+// trailing wildcard becomes a {path} parameter.
+func NormalizePath(route string) (string, []string) {
+	if route != "/" {
+		route = strings.TrimSuffix(route, "/")
+	}
+	if strings.HasSuffix(route, "/*") {
+		route = strings.TrimSuffix(route, "*") + "{path}"
+	}
+
+	var params []string
+	path := paramPattern.ReplaceAllStringFunc(route, func(m string) string {
+		name := paramPattern.FindStringSubmatch(m)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+func (g *generator) operation(method, path string, params []string, tag string, handler http.Handler, middlewares []func(http.Handler) http.Handler) *Operation {
+	doc := g.opts.Docs[method+" "+path]
+
+	op := &Operation{
+		Tags:        []string{tag},
+		Summary:     doc.Summary,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]Response),
+	}
+	if op.Summary == "" {
+		op.Summary = summaryFromHandler(handler)
+	}
+
+	for _, p := range params {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     p,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	if doc.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  jsonContent(g.schemaFor(reflect.TypeOf(doc.Request))),
+		}
+	} else if method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch {
+		op.RequestBody = &RequestBody{
+			Content: jsonContent(&Schema{Type: "object"}),
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status == http.StatusNoContent {
+		op.Responses["204"] = Response{Description: "No Content"}
+	} else {
+		data := &Schema{Ref: "#/components/schemas/SuccessResponse"}
+		if doc.Response != nil {
+			data = &Schema{AllOf: []*Schema{
+				{Ref: "#/components/schemas/SuccessResponse"},
+				{
+					Type:       "object",
+					Properties: map[string]*Schema{"data": g.schemaFor(reflect.TypeOf(doc.Response))},
+				},
+			}}
+		}
+		op.Responses[fmt.Sprintf("%d", status)] = Response{
+			Description: http.StatusText(status),
+			Content:     jsonContent(data),
+		}
+	}
+	op.Responses["default"] = Response{
+		Description: "Error",
+		Content:     jsonContent(&Schema{Ref: "#/components/schemas/ErrorResponse"}),
+	}
+
+	if g.requiresAuth(middlewares) {
+		op.Security = []map[string][]string{{bearerScheme: {}}}
+		op.Responses["401"] = Response{
+			Description: "Unauthorized",
+			Content:     jsonContent(&Schema{Ref: "#/components/schemas/ErrorResponse"}),
+		}
+	}
+
+	return op
+}
+
+func (g *generator) requiresAuth(middlewares []func(http.Handler) http.Handler) bool {
+	for _, m := range middlewares {
+		if g.authPCs[reflect.ValueOf(m).Pointer()] {
+			return true
+		}
+	}
+	return false
+}
+
+// addEnvelopeSchemas registers the standard success/error response envelopes
+func (g *generator) addEnvelopeSchemas() {
+	g.doc.Components.Schemas["SuccessResponse"] = &Schema{
+		Type:     "object",
+		Required: []string{"success"},
+		Properties: map[string]*Schema{
+			"success": {Type: "boolean"},
+			"data":    {},
+		},
+	}
+	g.doc.Components.Schemas["ErrorResponse"] = &Schema{
+		Type:     "object",
+		Required: []string{"success", "error"},
+		Properties: map[string]*Schema{
+			"success": {Type: "boolean"},
+			"error": {
+				Type: "object",
+				Properties: map[string]*Schema{
+					"code":    {Type: "integer"},
+					"message": {Type: "string"},
+					"details": {Type: "string"},
+				},
+			},
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the schema for a Go type, registering named structs as components
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.types[t]
+		if !ok {
+			name = g.componentName(t)
+			g.types[t] = name
+			// Register before building so recursive types terminate
+			g.doc.Components.Schemas[name] = &Schema{}
+			*g.doc.Components.Schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// componentName picks a unique component name for a named type
+func (g *generator) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := g.doc.Components.Schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return upperFirst(pkg) + name
+}
+
+// structSchema builds an object schema from exported fields and their json tags
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		// Embedded structs without a json name are flattened
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := g.structSchema(ft)
+				for k, v := range embedded.Properties {
+					s.Properties[k] = v
+				}
+				s.Required = append(s.Required, embedded.Required...)
+				continue
+			}
+		}
+
+		prop := g.schemaFor(field.Type)
+		if summary := tagSummary(field.Tag.Get("openapi")); summary != "" {
+			if prop.Ref != "" {
+				// Siblings of $ref are ignored in OpenAPI 3.0, so wrap it
+				prop = &Schema{AllOf: []*Schema{prop}}
+			}
+			prop.Description = summary
+		}
+		s.Properties[name] = prop
+
+		if !omitEmpty && strings.Contains(field.Tag.Get("validate"), "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+// jsonName returns the JSON property name for a struct field
+func jsonName(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+// tagSummary extracts the summary from an `openapi:"summary=..."` tag
+func tagSummary(tag string) string {
+	if summary, ok := strings.CutPrefix(tag, "summary="); ok {
+		return summary
+	}
+	return ""
+}
+
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+// tagFor derives the resource tag from the first path segment after /api/v1
+func tagFor(path string) string {
+	trimmed := strings.TrimPrefix(path, "/api/v1")
+	for _, seg := range strings.Split(trimmed, "/") {
+		if seg != "" && !strings.HasPrefix(seg, "{") {
+			return strings.TrimSuffix(seg, ".yaml")
+		}
+	}
+	return "root"
+}
+
+// operationID builds a stable, unique camelCase ID from method and path
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.FieldsFunc(path, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		b.WriteString(upperFirst(seg))
+	}
+	return b.String()
+}
+
+// summaryFromHandler derives a readable summary from the handler function name,
+// e.g. "(*AlertHandler).GetConfig-fm" -> "Get config"
+func summaryFromHandler(h http.Handler) string {
+	fn, ok := h.(http.HandlerFunc)
+	if !ok {
+		return ""
+	}
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return ""
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, "-fm")
+	if name == "" || strings.HasPrefix(name, "func") {
+		return ""
+	}
+
+	var words []string
+	start := 0
+	runes := []rune(name)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+
+	for i := 1; i < len(words); i++ {
+		if strings.ToUpper(words[i]) != words[i] {
+			words[i] = strings.ToLower(words[i])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
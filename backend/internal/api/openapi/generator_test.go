@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// URL collides with net/url.URL, so one of them gets a package prefix
+type URL struct {
+	Link string `json:"link"`
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	r := chi.NewRouter()
+	r.Get("/api/v1/links", noop)
+	r.Post("/api/v1/links", noop)
+	r.Put("/api/v1/links", noop)
+	r.Delete("/api/v1/links", noop)
+	r.Patch("/api/v1/links", noop)
+	opts := Options{Docs: map[string]RouteDoc{
+		"GET /api/v1/links":    {Response: url.URL{}},
+		"POST /api/v1/links":   {Request: URL{}},
+		"PUT /api/v1/links":    {Request: url.URL{}},
+		"DELETE /api/v1/links": {Response: URL{}},
+		"PATCH /api/v1/links":  {Request: URL{}},
+	}}
+
+	var first []byte
+	for i := 0; i < 20; i++ {
+		doc, err := Generate(r, opts)
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		spec, err := doc.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if first == nil {
+			first = spec
+			continue
+		}
+		if !bytes.Equal(spec, first) {
+			t.Fatalf("run %d generated a different spec:\n%s\nfirst run:\n%s", i+1, spec, first)
+		}
+	}
+
+	// Methods are visited in sorted order, so DELETE's URL is seen first
+	doc, _ := Generate(r, opts)
+	if _, ok := doc.Components.Schemas["URL"]; !ok {
+		t.Errorf("schemas = %v, want URL and UrlURL", doc.Components.Schemas)
+	}
+	if _, ok := doc.Components.Schemas["UrlURL"]; !ok {
+		t.Errorf("schemas = %v, want URL and UrlURL", doc.Components.Schemas)
+	}
+}
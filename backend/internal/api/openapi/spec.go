@@ -0,0 +1,98 @@
+// Package openapi generates an OpenAPI 3.0 specification from the chi router.
+//
+// Paths, methods, path parameters and security requirements are derived from
+// the registered routes. Request and response schemas are derived by
+// reflection from the json struct tags of the types listed in RouteDoc.
+// Struct fields may carry an `openapi:"summary=..."` tag which becomes the
+// property description in the generated schema.
+package openapi
+
+// Version is the OpenAPI specification version produced by the generator
+const Version = "3.0.3"
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string              `yaml:"openapi"`
+	Info       Info                `yaml:"info"`
+	Tags       []Tag               `yaml:"tags,omitempty"`
+	Paths      map[string]PathItem `yaml:"paths"`
+	Components Components          `yaml:"components"`
+}
+
+// Info contains API metadata
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description,omitempty"`
+	Version     string `yaml:"version"`
+}
+
+// Tag groups operations by resource
+type Tag struct {
+	Name string `yaml:"name"`
+}
+
+// PathItem maps lower-case HTTP methods to operations
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation
+type Operation struct {
+	Tags        []string              `yaml:"tags,omitempty"`
+	Summary     string                `yaml:"summary,omitempty"`
+	Description string                `yaml:"description,omitempty"`
+	OperationID string                `yaml:"operationId"`
+	Parameters  []Parameter           `yaml:"parameters,omitempty"`
+	RequestBody *RequestBody          `yaml:"requestBody,omitempty"`
+	Responses   map[string]Response   `yaml:"responses"`
+	Security    []map[string][]string `yaml:"security,omitempty"`
+}
+
+// Parameter describes an operation parameter
+type Parameter struct {
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"`
+	Required bool    `yaml:"required"`
+	Schema   *Schema `yaml:"schema"`
+}
+
+// RequestBody describes an operation request body
+type RequestBody struct {
+	Required bool                 `yaml:"required"`
+	Content  map[string]MediaType `yaml:"content"`
+}
+
+// Response describes an operation response
+type Response struct {
+	Description string               `yaml:"description"`
+	Content     map[string]MediaType `yaml:"content,omitempty"`
+}
+
+// MediaType holds the schema for a content type
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Components holds reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `yaml:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `yaml:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes an authentication method
+type SecurityScheme struct {
+	Type         string `yaml:"type"`
+	Scheme       string `yaml:"scheme,omitempty"`
+	BearerFormat string `yaml:"bearerFormat,omitempty"`
+}
+
+// Schema is a (subset of an) OpenAPI schema object
+type Schema struct {
+	Ref                  string             `yaml:"$ref,omitempty"`
+	Type                 string             `yaml:"type,omitempty"`
+	Format               string             `yaml:"format,omitempty"`
+	Description          string             `yaml:"description,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties,omitempty"`
+	Required             []string           `yaml:"required,omitempty"`
+	Items                *Schema            `yaml:"items,omitempty"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `yaml:"allOf,omitempty"`
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/openapi"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+)
+
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	return NewRouter(cfg)
+}
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	router := newTestRouter(t)
+
+	doc, err := GenerateOpenAPI(router)
+	if err != nil {
+		t.Fatalf("GenerateOpenAPI() error = %v", err)
+	}
+
+	excluded := make(map[string]bool)
+	for _, p := range openAPIExcluded {
+		excluded[p] = true
+	}
+
+	count := 0
+	err = chi.Walk(router.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if excluded[route] {
+			return nil
+		}
+		count++
+		path, _ := openapi.NormalizePath(route)
+		item, ok := doc.Paths[path]
+		if !ok {
+			t.Errorf("route %s %s: path %s missing from spec", method, route, path)
+			return nil
+		}
+		if _, ok := item[strings.ToLower(method)]; !ok {
+			t.Errorf("route %s %s: method missing from spec", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("chi.Walk() error = %v", err)
+	}
+	if count == 0 {
+		t.Fatal("no routes found")
+	}
+
+	// Every documented route must exist, otherwise the doc entry is stale
+	for key := range openAPIDocs {
+		found := false
+		for path, item := range doc.Paths {
+			for method := range item {
+				if key == strings.ToUpper(method)+" "+path {
+					found = true
+				}
+			}
+		}
+		if !found {
+			t.Errorf("openAPIDocs entry %q does not match any route", key)
+		}
+	}
+}
+
+func TestOpenAPISpecIsValid(t *testing.T) {
+	doc, err := GenerateOpenAPI(newTestRouter(t))
+	if err != nil {
+		t.Fatalf("GenerateOpenAPI() error = %v", err)
+	}
+	spec, err := doc.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	loaded, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		t.Fatalf("failed to load generated spec: %v", err)
+	}
+	if err := loaded.Validate(context.Background()); err != nil {
+		t.Fatalf("generated spec is not valid OpenAPI 3.0: %v", err)
+	}
+}
+
+func TestOpenAPISpecIsDeterministic(t *testing.T) {
+	router := newTestRouter(t)
+
+	var first []byte
+	for i := 0; i < 3; i++ {
+		doc, err := GenerateOpenAPI(router)
+		if err != nil {
+			t.Fatalf("GenerateOpenAPI() error = %v", err)
+		}
+		spec, err := doc.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if first == nil {
+			first = spec
+		} else if !bytes.Equal(spec, first) {
+			t.Fatalf("run %d generated a different spec", i+1)
+		}
+	}
+}
+
+func TestOpenAPISpecIsUpToDate(t *testing.T) {
+	committed, err := os.ReadFile("../../../docs/openapi.yaml")
+	if err != nil {
+		t.Fatalf("failed to read docs/openapi.yaml: %v", err)
+	}
+	doc, err := GenerateOpenAPI(newTestRouter(t))
+	if err != nil {
+		t.Fatalf("GenerateOpenAPI() error = %v", err)
+	}
+	spec, err := doc.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !bytes.Equal(spec, committed) {
+		t.Error("docs/openapi.yaml is out of date; run go generate ./internal/api/")
+	}
+}
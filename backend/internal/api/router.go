@@ -8,13 +8,17 @@ import (
 
 	"github.com/Stumpf-works/stumpfworks-nas/embedfs"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/api/handlers"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth/rbac"
 	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/tracing"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 // NewRouter creates and configures the HTTP router
@@ -23,12 +27,19 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 	// Global middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(mw.TrustedRealIP(cfg.Server.TrustedProxies))
+	r.Use(mw.TracingMiddleware(tracing.Tracer()))
 	r.Use(mw.LoggerMiddleware)
+	r.Use(mw.AccessLogMiddleware)
 	r.Use(mw.RevisionMiddleware) // Add version headers to all responses
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
 	r.Use(middleware.Compress(5)) // Gzip compression (level 5 = balanced speed/compression)
+	r.Use(mw.BodySizeLimit(cfg.Server.MaxBodySizeBytes))
+
+	// File uploads, user imports and compose files may exceed the default
+	// body limit
+	uploadLimit := mw.BodySizeLimit(cfg.Server.MaxUploadSizeBytes)
 
 	// CORS middleware - auto-detect origins in development
 	var corsHandler *cors.Cors
@@ -68,7 +79,7 @@ func NewRouter(cfg *config.Config) http.Handler {
 			},
 			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-			ExposedHeaders:   []string{"Link"},
+			ExposedHeaders:   []string{"Link", mw.TraceIDHeader},
 			AllowCredentials: true,
 			MaxAge:           300,
 		})
@@ -81,11 +92,17 @@ func NewRouter(cfg *config.Config) http.Handler {
 			allowedOrigins = []string{} // Empty = block all
 		}
 
+		// Origins are checked through corsOrigins so they can be hot-reloaded
+		origins := newCORSOrigins(allowedOrigins)
+		config.RegisterConfigurable(origins)
+
 		corsHandler = cors.New(cors.Options{
-			AllowedOrigins:   allowedOrigins,
+			AllowOriginFunc: func(r *http.Request, origin string) bool {
+				return origins.allowed(origin)
+			},
 			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-			ExposedHeaders:   []string{"Link"},
+			ExposedHeaders:   []string{"Link", mw.TraceIDHeader},
 			AllowCredentials: true,
 			MaxAge:           300,
 		})
@@ -94,6 +111,15 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 	r.Use(corsHandler.Handler)
 
+	// API rate limiting (per user once authenticated, per IP otherwise)
+	rateLimit := func(next http.Handler) http.Handler { return next }
+	if cfg.RateLimit.Enabled {
+		rateLimit = mw.RateLimitMiddleware(
+			rate.Limit(cfg.RateLimit.UserRequestsPerSecond),
+			rate.Limit(cfg.RateLimit.IPRequestsPerSecond),
+			cfg.RateLimit.Burst)
+	}
+
 	// Health check (no auth required)
 	r.Get("/health", handlers.HealthCheck)
 
@@ -101,18 +127,32 @@ func NewRouter(cfg *config.Config) http.Handler {
 	r.Get("/metrics", handlers.PrometheusMetricsHandler)
 
 	// API v1 routes
+	root := r
 	r.Route("/api/v1", func(r chi.Router) {
+		// OpenAPI specification (no auth required)
+		r.Get("/openapi.yaml", openAPIHandler(root))
+
 		// Setup wizard routes (no auth required, always accessible)
 		r.Group(func(r chi.Router) {
 			r.Get("/setup/status", handlers.SetupStatus)
 			r.Post("/setup/initialize", handlers.InitializeSetup)
+			r.Get("/setup/steps", handlers.ListSetupSteps)
+			r.Put("/setup/steps/{name}", handlers.UpdateSetupStep)
+			r.With(mw.AuthMiddleware, rbac.RequirePermission("system", "update")).Post("/setup/complete", handlers.CompleteSetup)
 		})
 
+		// Startup progress (no auth required, also served while starting)
+		r.Get("/system/startup-status", handlers.GetStartupStatus)
+
 		// Public routes (no auth, but with IP blocking check)
 		r.Group(func(r chi.Router) {
 			r.Use(mw.IPBlockMiddleware)
+			r.Use(rateLimit)
 			r.Post("/auth/login", handlers.Login)
 			r.Post("/auth/login/2fa", handlers.LoginWith2FA)
+			r.Get("/auth/saml/metadata", handlers.SAMLServiceProvider)
+			r.Get("/auth/saml/login", handlers.SAMLServiceProvider)
+			r.Post("/auth/saml/acs", handlers.SAMLServiceProvider)
 			// r.Post("/auth/register", handlers.Register) // Will implement later
 		})
 
@@ -123,11 +163,11 @@ func NewRouter(cfg *config.Config) http.Handler {
 			r.Get("/{id}", handlers.GetAddon)
 			r.Get("/{id}/status", handlers.GetAddonStatus)
 
-			// Admin-only endpoints - only admins can install/uninstall
+			// Addon installation requires the addon:manage permission
 			r.Group(func(r chi.Router) {
 				r.Use(mw.SetupRequired)
 				r.Use(mw.AuthMiddleware)
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequirePermission("addon", "manage"))
 				r.Post("/{id}/install", handlers.InstallAddon)
 				r.Post("/{id}/uninstall", handlers.UninstallAddon)
 			})
@@ -137,20 +177,33 @@ func NewRouter(cfg *config.Config) http.Handler {
 		r.Group(func(r chi.Router) {
 			r.Use(mw.SetupRequired)
 			r.Use(mw.AuthMiddleware)
+			r.Use(rateLimit)
 
 			// Auth routes
 			r.Post("/auth/logout", handlers.Logout)
 			r.Post("/auth/refresh", handlers.RefreshToken)
 			r.Get("/auth/me", handlers.GetCurrentUser)
+			r.With(rbac.RequirePermission("security", "read")).Get("/auth/mfa-policy", handlers.GetMFAPolicy)
+			r.With(rbac.RequirePermission("security", "manage")).Put("/auth/mfa-policy", handlers.UpdateMFAPolicy)
+			r.With(rbac.RequirePermission("security", "read")).Get("/auth/saml/config", handlers.GetSAMLConfig)
+			r.With(rbac.RequirePermission("security", "manage")).Put("/auth/saml/config", handlers.UpdateSAMLConfig)
 
 			// System routes
 			r.Get("/system/info", handlers.GetSystemInfo)
 			r.Get("/system/metrics", handlers.GetSystemMetrics)
+			r.With(rbac.RequireAccess("system")).Get("/system/processes", handlers.GetNASProcesses)
 
 			// Update routes
 			updateHandler := handlers.NewUpdateHandler()
 			r.Get("/system/version", updateHandler.GetCurrentVersion)
 			r.Get("/system/check-updates", updateHandler.CheckForUpdates)
+			r.With(rbac.RequirePermission("system", "read")).Get("/system/updates/security", updateHandler.GetSecurityUpdates)
+			r.With(rbac.RequirePermission("system", "update")).Post("/system/updates/security/apply", updateHandler.ApplySecurityUpdates)
+
+			dependencyHandler := handlers.NewDependencyHandler()
+			r.With(rbac.RequireAccess("system")).Get("/system/dependencies", dependencyHandler.GetDependencyReport)
+			r.With(rbac.RequirePermission("system", "read")).Get("/system/benchmark/results", handlers.ListBenchmarkResults)
+			r.With(rbac.RequirePermission("system", "create")).Post("/system/benchmark/results", handlers.SaveBenchmarkResults)
 
 			// Metrics and monitoring routes
 			r.Route("/metrics", func(r chi.Router) {
@@ -166,21 +219,29 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 				r.Get("/scores", metricsHandler.GetHealthScores)
 				r.Get("/score", metricsHandler.GetLatestHealthScore)
+				r.Get("/score/breakdown", metricsHandler.GetHealthScoreBreakdown)
 			})
 
-			// User routes (admin only for now)
+			// User routes (user permissions)
 			r.Route("/users", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("user"))
 				r.Get("/", handlers.ListUsers)
 				r.Post("/", handlers.CreateUser)
+				r.With(uploadLimit).Post("/import", handlers.ImportUsers)
+				r.Get("/inactive", handlers.ListInactiveUsers)
 				r.Get("/{id}", handlers.GetUser)
 				r.Put("/{id}", handlers.UpdateUser)
 				r.Delete("/{id}", handlers.DeleteUser)
+				r.Get("/{id}/activity", handlers.GetUserActivity)
+				r.Get("/{id}/ssh-keys", handlers.ListSSHKeys)
+				r.Post("/{id}/ssh-keys", handlers.AddSSHKey)
+				r.Put("/{id}/ssh-keys/{keyId}", handlers.UpdateSSHKey)
+				r.Delete("/{id}/ssh-keys/{keyId}", handlers.DeleteSSHKey)
 			})
 
-			// User Group routes (admin only)
+			// User Group routes (group permissions)
 			r.Route("/groups", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("group"))
 				r.Get("/", handlers.ListGroups)
 				r.Post("/", handlers.CreateGroup)
 				r.Get("/{id}", handlers.GetGroup)
@@ -191,6 +252,11 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/{id}/members", handlers.AddGroupMember)
 				r.Delete("/{id}/members/{userId}", handlers.RemoveGroupMember)
 				r.Get("/{id}/members", handlers.GetGroupMembers)
+
+				// Nested groups
+				r.Get("/{id}/tree", handlers.GetGroupTree)
+				r.Post("/{id}/subgroups", handlers.AddSubgroup)
+				r.Delete("/{id}/subgroups/{childId}", handlers.RemoveSubgroup)
 			})
 
 			// Storage routes
@@ -211,14 +277,20 @@ func NewRouter(cfg *config.Config) http.Handler {
 				// Volumes
 				r.Get("/volumes", handlers.ListVolumes)
 				r.Get("/volumes/{id}", handlers.GetVolume)
+				r.Get("/volumes/{id}/health-history", handlers.GetVolumeHealthHistory)
 
 				// Shares
 				r.Get("/shares", handlers.ListShares)
 				r.Get("/shares/{id}", handlers.GetShare)
+				r.With(rbac.RequirePermission("share", "read")).Get("/shares/{id}/access-log", handlers.GetShareAccessLog)
+				r.Get("/shares/{id}/snapshots", handlers.ListShareSnapshots)
+				r.Get("/shares/{id}/snapshots/{snap}/browse", handlers.BrowseShareSnapshot)
+				r.Post("/shares/{id}/snapshots/{snap}/restore", handlers.RestoreShareSnapshotFile)
+				r.Get("/shares/{id}/transfer/{jobId}/progress", handlers.GetShareTransferProgress)
 
-				// Admin-only storage operations
+				// Storage operations (storage permissions)
 				r.Group(func(r chi.Router) {
-					r.Use(mw.AdminOnly)
+					r.Use(rbac.RequireAccess("storage"))
 
 					// Disk operations
 					r.Post("/disks/format", handlers.FormatDisk)
@@ -227,38 +299,60 @@ func NewRouter(cfg *config.Config) http.Handler {
 					// Volume operations
 					r.Post("/volumes", handlers.CreateVolume)
 					r.Delete("/volumes/{id}", handlers.DeleteVolume)
-
-					// Share operations
-					r.Post("/shares", handlers.CreateShare)
-					r.Put("/shares/{id}", handlers.UpdateShare)
-					r.Delete("/shares/{id}", handlers.DeleteShare)
-					r.Post("/shares/{id}/enable", handlers.EnableShare)
-					r.Post("/shares/{id}/disable", handlers.DisableShare)
 				})
+
+				// Share operations (share permissions)
+				r.With(rbac.RequirePermission("share", "create")).Post("/shares", handlers.CreateShare)
+				r.With(rbac.RequirePermission("share", "update")).Put("/shares/{id}", handlers.UpdateShare)
+				r.With(rbac.RequirePermission("share", "delete")).Delete("/shares/{id}", handlers.DeleteShare)
+				r.With(rbac.RequirePermission("share", "update")).Post("/shares/{id}/enable", handlers.EnableShare)
+				r.With(rbac.RequirePermission("share", "update")).Post("/shares/{id}/disable", handlers.DisableShare)
+				r.With(rbac.RequireAccess("storage"), rbac.RequirePermission("share", "update")).Post("/shares/{id}/transfer", handlers.StartShareTransfer)
 			})
 
 			// System Library routes (Phase 1 integration)
+			// Each subgroup requires access to the resource it manages
 			r.Route("/syslib", func(r chi.Router) {
-				r.Use(mw.AdminOnly) // All system library operations require admin
-
 				// System Library Health
-				r.Get("/health", handlers.SystemLibraryHealth)
+				r.With(rbac.RequirePermission("system", "read")).Get("/health", handlers.SystemLibraryHealth)
 
 				// ZFS operations
 				r.Route("/zfs", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("storage"))
 					r.Get("/pools", handlers.ListZFSPools)
 					r.Get("/pools/{name}", handlers.GetZFSPool)
 					r.Post("/pools", handlers.CreateZFSPool)
 					r.Delete("/pools/{name}", handlers.DestroyZFSPool)
 					r.Post("/pools/{name}/scrub", handlers.ScrubZFSPool)
+					r.Get("/pools/{name}/scrub-schedule", handlers.GetZFSScrubSchedule)
+					r.Put("/pools/{name}/scrub-schedule", handlers.SetZFSScrubSchedule)
+					r.Delete("/pools/{name}/scrub-schedule", handlers.DeleteZFSScrubSchedule)
+					r.Post("/auto-tiering/analyze", handlers.AnalyzeZFSAutoTiering)
+					r.Post("/auto-tiering/run", handlers.RunZFSAutoTiering)
 
 					r.Get("/pools/{pool}/datasets", handlers.ListZFSDatasets)
 					r.Post("/snapshots", handlers.CreateZFSSnapshot)
 					r.Get("/datasets/{dataset}/snapshots", handlers.ListZFSSnapshots)
+					r.Post("/datasets/encrypted", handlers.CreateEncryptedZFSDataset)
+					r.Post("/datasets/{name}/mount-encrypted", handlers.MountEncryptedZFSDataset)
+					r.Post("/datasets/{name}/unmount-encrypted", handlers.UnmountEncryptedZFSDataset)
+					r.Post("/datasets/{name}/change-key", handlers.ChangeZFSEncryptionKey)
+				})
+
+				// BTRFS subvolumes, snapshots and replication
+				r.Route("/btrfs", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("storage"))
+					r.Get("/subvolumes", handlers.ListBTRFSSubvolumes)
+					r.Post("/subvolumes", handlers.CreateBTRFSSubvolume)
+					r.Delete("/subvolumes", handlers.DeleteBTRFSSubvolume)
+					r.Post("/snapshots", handlers.CreateBTRFSSnapshot)
+					r.Post("/send-receive", handlers.BTRFSSendReceive)
+					r.Get("/usage", handlers.GetBTRFSUsage)
 				})
 
 				// RAID operations
 				r.Route("/raid", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("storage"))
 					r.Get("/arrays", handlers.ListRAIDArrays)
 					r.Get("/arrays/{name}", handlers.GetRAIDArray)
 					r.Post("/arrays", handlers.CreateRAIDArray)
@@ -266,12 +360,17 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 				// SMART operations
 				r.Route("/smart", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("storage"))
 					r.Get("/{device}", handlers.GetSMARTInfo)
 					r.Post("/{device}/test", handlers.RunSMARTTest)
+					r.Get("/{device}/history", handlers.GetSMARTTestHistory)
+					r.Get("/{device}/schedule", handlers.GetSMARTTestSchedule)
+					r.Put("/{device}/schedule", handlers.SetSMARTTestSchedule)
 				})
 
 				// Samba operations
 				r.Route("/samba", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("share"))
 					r.Get("/status", handlers.GetSambaStatus)
 					r.Post("/restart", handlers.RestartSamba)
 					r.Get("/shares", handlers.ListSambaShares)
@@ -283,19 +382,65 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 				// NFS operations
 				r.Route("/nfs", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("share"))
 					r.Post("/restart", handlers.RestartNFS)
 					r.Get("/exports", handlers.ListNFSExports)
 					r.Post("/exports", handlers.CreateNFSExport)
 					r.Delete("/exports", handlers.DeleteNFSExport)
 				})
 
+				// ACL inheritance
+				r.Route("/acl", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("file"))
+					r.Post("/inherit", handlers.InheritACL)
+					r.Get("/jobs/{id}/status", handlers.GetACLJobStatus)
+				})
+
+				// VM CPU and NUMA placement and disks
+				r.Group(func(r chi.Router) {
+					r.Use(rbac.RequireAccess("vm"))
+					r.Put("/vms/{name}/cpu-pinning", handlers.SetVMCPUPinning)
+					r.Put("/vms/{name}/memory-backing", handlers.SetVMMemoryBacking)
+					r.Get("/vms/{name}/disks", handlers.ListVMDisks)
+					r.Post("/vms/{name}/disks", handlers.AddVMDisk)
+					r.Put("/vms/{name}/disks/{target}", handlers.ResizeVMDisk)
+					r.Delete("/vms/{name}/disks/{target}", handlers.RemoveVMDisk)
+					r.Get("/numa/topology", handlers.GetNUMATopology)
+				})
+
+				// XFS project quotas
+				r.Route("/quota/projects", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("quota"))
+					r.Get("/", handlers.ListQuotaProjects)
+					r.Post("/", handlers.CreateQuotaProject)
+					r.Get("/{name}", handlers.GetQuotaProject)
+					r.Put("/{name}", handlers.UpdateQuotaProject)
+					r.Delete("/{name}", handlers.DeleteQuotaProject)
+				})
+
 				// Network operations
 				r.Route("/network", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("network"))
 					r.Post("/bond", handlers.CreateBondInterface)
 					r.Delete("/bond/{name}", handlers.DeleteBondInterface)
 					r.Post("/vlan", handlers.CreateVLANInterface)
 					r.Delete("/vlan/{parent}/{vlanid}", handlers.DeleteVLANInterface)
 				})
+
+				// HA cluster operations across DRBD, Pacemaker and Keepalived
+				r.Route("/ha/cluster", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("ha"))
+					r.Get("/status", handlers.GetHAClusterStatus)
+					r.Post("/failover", handlers.PerformHAFailover)
+				})
+
+				// STONITH fencing of HA cluster nodes
+				r.Route("/ha/fence/{node}", func(r chi.Router) {
+					r.Use(rbac.RequireAccess("ha"))
+					r.Post("/", handlers.FenceNode)
+					r.Put("/config", handlers.SetNodeFencing)
+					r.Post("/test", handlers.TestNodeFencing)
+				})
 			})
 
 			// File Management routes
@@ -305,37 +450,49 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Get("/info", handlers.GetFileInfo)
 				r.Get("/download", handlers.DownloadFile)
 				r.Get("/usage", handlers.GetDiskUsage)
+				r.Get("/search", handlers.SearchFiles)
+				r.Get("/thumbnail", handlers.GetThumbnail)
 
 				// File operations (write access required)
-				r.Post("/upload", handlers.UploadFile)
+				r.With(uploadLimit).Post("/upload", handlers.UploadFile)
 				r.Post("/mkdir", handlers.CreateDirectory)
 				r.Post("/rename", handlers.RenameFile)
+				r.Post("/rename/bulk", handlers.BulkRenameFiles)
 				r.Post("/copy", handlers.CopyFiles)
 				r.Post("/move", handlers.MoveFiles)
 				r.Delete("/delete", handlers.DeleteFiles)
 
 				// Chunked upload
 				r.Post("/upload/start", handlers.StartChunkedUpload)
-				r.Post("/upload/{sessionId}/chunk/{chunkIndex}", handlers.UploadChunk)
+				r.With(uploadLimit).Post("/upload/{sessionId}/chunk/{chunkIndex}", handlers.UploadChunk)
 				r.Post("/upload/finalize", handlers.FinalizeUpload)
 				r.Delete("/upload/{sessionId}", handlers.CancelUpload)
 				r.Get("/upload/{sessionId}", handlers.GetUploadSession)
+				r.Get("/upload/{sessionId}/progress", handlers.GetUploadProgress)
+
+				// Version history
+				r.Get("/versions", handlers.ListFileVersions)
+				r.Post("/versions/{id}/restore", handlers.RestoreFileVersion)
 
 				// Archives
 				r.Post("/archive/create", handlers.CreateArchive)
 				r.Post("/archive/extract", handlers.ExtractArchive)
 
-				// Permissions (admin only)
+				// Duplicate files
+				r.Post("/dedup/scan", handlers.StartDedupeScan)
+				r.Get("/dedup/results/{jobId}", handlers.GetDedupeResults)
+
+				// Permissions (file permissions)
 				r.Group(func(r chi.Router) {
-					r.Use(mw.AdminOnly)
+					r.Use(rbac.RequireAccess("file"))
 					r.Get("/permissions", handlers.GetFilePermissions)
 					r.Post("/permissions", handlers.ChangeFilePermissions)
 				})
 			})
 
-			// Filesystem ACL routes (admin only)
+			// Filesystem ACL routes (file permissions)
 			r.Route("/filesystem/acl", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("file"))
 
 				r.Get("/", handlers.GetACL)                    // GET /api/v1/filesystem/acl?path=/path/to/file
 				r.Post("/", handlers.SetACL)                   // POST /api/v1/filesystem/acl
@@ -345,9 +502,9 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Delete("/all", handlers.RemoveAllACLs)       // DELETE /api/v1/filesystem/acl/all
 			})
 
-			// Disk Quota routes (admin only)
+			// Disk Quota routes (quota permissions)
 			r.Route("/quotas", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("quota"))
 
 				// User quotas
 				r.Get("/user", handlers.GetUserQuota)           // GET /api/v1/quotas/user?name=user&filesystem=/path
@@ -372,6 +529,14 @@ func NewRouter(cfg *config.Config) http.Handler {
 				// Interface management
 				r.Get("/interfaces", netHandler.ListInterfaces)
 				r.Get("/interfaces/stats", netHandler.GetInterfaceStats)
+				r.Get("/bridges/{name}/stats", netHandler.GetBridgeStats)
+				r.Get("/bridges/{name}/fdb", netHandler.GetBridgeFDB)
+
+				// Share traffic accounting
+				r.Get("/traffic", netHandler.GetTraffic)
+
+				// Dynamic DNS
+				r.Get("/ddns/status", netHandler.GetDDNSStatus)
 
 				// Routes and DNS
 				r.Get("/routes", netHandler.GetRoutes)
@@ -387,9 +552,12 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/diagnostics/traceroute", netHandler.Traceroute)
 				r.Post("/diagnostics/netstat", netHandler.Netstat)
 
-				// Admin-only network operations
+				// Devices seen in ARP replies
+				r.Get("/arp/devices", handlers.ListARPDevices)
+
+				// Network configuration (network:configure)
 				r.Group(func(r chi.Router) {
-					r.Use(mw.AdminOnly)
+					r.Use(rbac.RequirePermission("network", "configure"))
 
 					// Interface configuration
 					r.Post("/interfaces/{name}/state", netHandler.SetInterfaceState)
@@ -405,6 +573,36 @@ func NewRouter(cfg *config.Config) http.Handler {
 					r.Post("/firewall/default", netHandler.SetDefaultPolicy)
 					r.Post("/firewall/reset", netHandler.ResetFirewall)
 
+					// Dynamic DNS configuration
+					r.Put("/ddns/config", netHandler.UpdateDDNSConfig)
+
+					// mDNS/Bonjour announcements
+					r.Post("/mdns/restart", netHandler.RestartMDNS)
+
+					// Port forwarding
+					r.Get("/port-forwards", handlers.ListPortForwards)
+					r.Post("/port-forwards", handlers.CreatePortForward)
+					r.Put("/port-forwards/{id}", handlers.UpdatePortForward)
+					r.Delete("/port-forwards/{id}", handlers.DeletePortForward)
+
+					// VXLAN overlay networks between nodes
+					r.Get("/vxlan", handlers.ListVXLANs)
+					r.Post("/vxlan", handlers.CreateVXLAN)
+					r.Get("/vxlan/{name}", handlers.GetVXLAN)
+					r.Delete("/vxlan/{name}", handlers.DeleteVXLAN)
+
+					// MACs IPs may move to without an ARP spoofing alert
+					r.Post("/arp/whitelist", handlers.WhitelistARPMAC)
+
+					// IP address management
+					r.Get("/ipam/pools", handlers.ListIPAMPools)
+					r.Post("/ipam/pools", handlers.CreateIPAMPool)
+					r.Put("/ipam/pools/{name}", handlers.UpdateIPAMPool)
+					r.Delete("/ipam/pools/{name}", handlers.DeleteIPAMPool)
+					r.Get("/ipam/pools/{name}/allocations", handlers.ListIPAllocations)
+					r.Post("/ipam/pools/{name}/allocations", handlers.AllocateIP)
+					r.Delete("/ipam/allocations/{ip}", handlers.ReleaseIP)
+
 					// Bridge management
 					r.Get("/bridges", netHandler.ListBridges)
 					r.Post("/bridges", netHandler.CreateBridge)
@@ -436,6 +634,7 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/containers/{id}/unpause", dockerHandler.UnpauseContainer)
 				r.Post("/containers/{id}/exec", dockerHandler.ExecContainer)
 				r.Put("/containers/{id}/resources", dockerHandler.UpdateContainerResources)
+				r.With(rbac.RequirePermission("system", "update")).Put("/containers/{id}/priority", dockerHandler.SetContainerPriority)
 				r.Delete("/containers/{id}", dockerHandler.RemoveContainer)
 
 				// Image routes
@@ -464,12 +663,16 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Get("/version", dockerHandler.GetDockerVersion)
 				r.Post("/system/prune", dockerHandler.PruneSystem)
 
+				// Resource governor routes
+				r.Get("/resource-governor/status", dockerHandler.GetResourceGovernorStatus)
+				r.With(rbac.RequirePermission("system", "update")).Put("/resource-governor/config", dockerHandler.UpdateResourceGovernorConfig)
+
 				// Docker Compose Stack routes
 				composeHandler := handlers.NewComposeHandler("")
 				r.Get("/stacks", composeHandler.ListStacks)
-				r.Post("/stacks", composeHandler.CreateStack)
+				r.With(uploadLimit).Post("/stacks", composeHandler.CreateStack)
 				r.Get("/stacks/{name}", composeHandler.GetStack)
-				r.Put("/stacks/{name}", composeHandler.UpdateStack)
+				r.With(uploadLimit).Put("/stacks/{name}", composeHandler.UpdateStack)
 				r.Delete("/stacks/{name}", composeHandler.DeleteStack)
 				r.Post("/stacks/{name}/deploy", composeHandler.DeployStack)
 				r.Post("/stacks/{name}/stop", composeHandler.StopStack)
@@ -477,6 +680,7 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/stacks/{name}/remove", composeHandler.RemoveStack)
 				r.Get("/stacks/{name}/logs", composeHandler.GetStackLogs)
 				r.Get("/stacks/{name}/compose", composeHandler.GetComposeFile)
+				r.With(uploadLimit).Post("/stacks/{name}/diff", composeHandler.DiffStack)
 			})
 
 			// Backup routes
@@ -502,6 +706,18 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/snapshots/{id}/restore", backupHandler.RestoreSnapshot)
 			})
 
+			// Cloud backup remotes (rclone), which hold credentials
+			r.Route("/cloudbackup", func(r chi.Router) {
+				r.Use(rbac.RequireAccess("backup"))
+
+				r.Get("/remotes", handlers.ListRcloneRemotes)
+				r.Post("/remotes", handlers.CreateRcloneRemote)
+				r.Get("/remotes/{name}", handlers.GetRcloneRemote)
+				r.Put("/remotes/{name}", handlers.UpdateRcloneRemote)
+				r.Delete("/remotes/{name}", handlers.DeleteRcloneRemote)
+				r.Post("/remotes/{name}/test", handlers.TestRcloneRemote)
+			})
+
 			// Active Directory routes
 			r.Route("/ad", func(r chi.Router) {
 				adHandler := handlers.NewADHandler()
@@ -598,11 +814,24 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/test-config", dcHandler.TestConfiguration)
 				r.Get("/dbcheck", dcHandler.ShowDBCheck)
 				r.Post("/backup", dcHandler.BackupOnline)
+
+				// Kerberos tickets of service accounts
+				r.Get("/kerberos/tickets", dcHandler.ListKerberosTickets)
+				r.With(rbac.RequirePermission("directory", "manage")).Post("/kerberos/obtain", dcHandler.ObtainKerberosTicket)
+
+				// LDAP Browser (binds to the directory on every request, so
+				// it is limited to 1 request per second with bursts of 5)
+				r.Group(func(r chi.Router) {
+					r.Use(rbac.RequirePermission("directory", "read"))
+					r.Use(mw.NewRateLimiter(1, 1, 5).Middleware)
+					r.Get("/ldap/search", dcHandler.LDAPSearch)
+					r.Get("/ldap/object", dcHandler.LDAPGetObject)
+				})
 			})
 
 			// High Availability - DRBD routes
 			r.Route("/ha/drbd", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("ha"))
 				r.Get("/resources", handlers.ListDRBDResources)
 				r.Post("/resources", handlers.CreateDRBDResource)
 				r.Get("/resources/{name}", handlers.GetDRBDResourceStatus)
@@ -618,7 +847,7 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 			// High Availability - Pacemaker/Corosync routes
 			r.Route("/ha/cluster", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("ha"))
 				r.Get("/status", handlers.GetClusterStatus)
 				r.Post("/resources", handlers.CreateClusterResource)
 				r.Delete("/resources/{id}", handlers.DeleteClusterResource)
@@ -633,7 +862,7 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 			// High Availability - Keepalived (VIP) routes
 			r.Route("/ha/vip", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("ha"))
 				r.Get("/", handlers.ListVIPs)
 				r.Post("/", handlers.CreateVIP)
 				r.Get("/{id}", handlers.GetVIPStatus)
@@ -646,17 +875,35 @@ func NewRouter(cfg *config.Config) http.Handler {
 			r.Route("/audit", func(r chi.Router) {
 				auditHandler := handlers.NewAuditHandler()
 
-				// Audit log retrieval (admin only)
-				r.Use(mw.AdminOnly)
+				// Audit log retrieval (audit:read)
+				r.Use(rbac.RequirePermission("audit", "read"))
 				r.Get("/logs", auditHandler.ListAuditLogs)
 				r.Get("/logs/recent", auditHandler.GetRecentAuditLogs)
 				r.Get("/logs/{id}", auditHandler.GetAuditLog)
 				r.Get("/stats", auditHandler.GetAuditStats)
+				r.Get("/retention-policy", auditHandler.GetRetentionPolicy)
+				r.With(rbac.RequirePermission("audit", "manage")).Put("/retention-policy", auditHandler.UpdateRetentionPolicy)
+			})
+
+			// VPN routes (requires wireguard-tools)
+			r.Route("/vpn", func(r chi.Router) {
+				r.Use(rbac.RequireAccess("network"))
+				r.Get("/status", handlers.GetVPNStatus)
+				r.Get("/wireguard/interfaces", handlers.ListWireGuardInterfaces)
+				r.Post("/wireguard/interfaces", handlers.CreateWireGuardInterface)
+				r.Get("/wireguard/interfaces/{name}", handlers.GetWireGuardInterface)
+				r.Put("/wireguard/interfaces/{name}", handlers.UpdateWireGuardInterface)
+				r.Delete("/wireguard/interfaces/{name}", handlers.DeleteWireGuardInterface)
+				r.Get("/wireguard/interfaces/{name}/stats", handlers.GetWireGuardInterfaceStats)
+				r.Get("/wireguard/interfaces/{name}/peers", handlers.ListWireGuardPeers)
+				r.Post("/wireguard/interfaces/{name}/peers", handlers.CreateWireGuardPeer)
+				r.Get("/wireguard/peers/{id}/config", handlers.GetPeerClientConfig)
+				r.Put("/wireguard/peers/{id}/split-tunnel", handlers.SetPeerSplitTunnel)
 			})
 
 			// VM Management routes (requires VM Manager addon installed)
 			r.Route("/vms", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("vm"))
 				r.Get("/", handlers.ListVMs)
 				r.Post("/", handlers.CreateVM)
 				r.Get("/{id}", handlers.GetVM)
@@ -668,15 +915,21 @@ func NewRouter(cfg *config.Config) http.Handler {
 
 			// LXC Container Management routes (requires LXC Manager addon installed)
 			r.Route("/lxc", func(r chi.Router) {
-				r.Use(mw.AdminOnly)
+				r.Use(rbac.RequireAccess("container"))
 				r.Get("/containers", handlers.ListContainers)
 				r.Post("/containers", handlers.CreateContainer)
 				r.Get("/containers/{name}", handlers.GetContainer)
+				r.Get("/containers/{name}/network", handlers.GetContainerNetwork)
+				r.Put("/containers/{name}/network", handlers.SetContainerNetwork)
 				r.Post("/containers/{name}/start", handlers.StartContainer)
 				r.Post("/containers/{name}/stop", handlers.StopContainer)
 				r.Delete("/containers/{name}", handlers.DeleteContainer)
 				r.Post("/containers/{name}/exec", handlers.ExecContainerCommand)
 				r.Get("/containers/{name}/console", handlers.GetContainerConsole)
+				r.Post("/containers/{name}/migrate", handlers.MigrateContainer)
+				r.Post("/containers/{name}/restore", handlers.RestoreContainer)
+				r.Get("/migrations", handlers.ListContainerMigrations)
+				r.Get("/migrations/{id}", handlers.GetContainerMigration)
 				r.Get("/templates", handlers.ListLXCTemplates)
 			})
 
@@ -684,41 +937,81 @@ func NewRouter(cfg *config.Config) http.Handler {
 			r.Route("/security", func(r chi.Router) {
 				failedLoginHandler := handlers.NewFailedLoginHandler()
 
-				// Security management (admin only)
-				r.Use(mw.AdminOnly)
+				// Security management (security permissions)
+				r.Use(rbac.RequireAccess("security"))
 				r.Get("/failed-logins", failedLoginHandler.ListFailedAttempts)
 				r.Get("/blocked-ips", failedLoginHandler.GetBlockedIPs)
 				r.Post("/unblock-ip", failedLoginHandler.UnblockIP)
 				r.Get("/failed-logins/stats", failedLoginHandler.GetStats)
+				r.Get("/locked-accounts", failedLoginHandler.GetLockedAccounts)
+				r.Post("/unlock-account", failedLoginHandler.UnlockAccount)
+
+				// Lockout policy (security:read, security:manage to change it)
+				r.With(rbac.RequirePermission("security", "read")).Get("/lockout-policy", failedLoginHandler.GetLockoutPolicy)
+				r.With(rbac.RequirePermission("security", "manage")).Put("/lockout-policy", failedLoginHandler.UpdateLockoutPolicy)
 			})
 
 			// Alert/Notification routes
 			r.Route("/alerts", func(r chi.Router) {
 				alertHandler := handlers.NewAlertHandler()
 
-				// Alert management (admin only)
-				r.Use(mw.AdminOnly)
+				// Alert management (alert permissions)
+				r.Use(rbac.RequireAccess("alert"))
 				r.Get("/config", alertHandler.GetConfig)
 				r.Put("/config", alertHandler.UpdateConfig)
 				r.Post("/test/email", alertHandler.TestEmail)
 				r.Post("/test/webhook", alertHandler.TestWebhook)
 				r.Get("/logs", alertHandler.GetAlertLogs)
+
+				// Alert rules over system metrics
+				r.Get("/rules", alertHandler.ListAlertRules)
+				r.Post("/rules", alertHandler.CreateAlertRule)
+				r.Put("/rules/{id}", alertHandler.UpdateAlertRule)
+				r.Delete("/rules/{id}", alertHandler.DeleteAlertRule)
+			})
+
+			// Browser push notifications
+			r.Route("/notifications", func(r chi.Router) {
+				r.Use(rbac.RequireAccess("alert"))
+				r.Get("/vapid-public-key", handlers.GetVAPIDPublicKey)
+				r.Post("/subscribe", handlers.SubscribePush)
+				r.Delete("/unsubscribe", handlers.UnsubscribePush)
 			})
 
 			// Monitoring configuration routes
 			r.Route("/monitoring", func(r chi.Router) {
-				// Monitoring config management (admin only)
-				r.Use(mw.AdminOnly)
+				// Monitoring config management (monitoring permissions)
+				r.Use(rbac.RequireAccess("monitoring"))
 				r.Get("/config", handlers.GetMonitoringConfig)
 				r.Put("/config", handlers.UpdateMonitoringConfig)
+
+				// User-defined Prometheus metrics
+				r.Get("/custom-metrics", handlers.ListCustomMetrics)
+				r.Post("/custom-metrics", handlers.CreateCustomMetric)
+				r.Put("/custom-metrics/{id}", handlers.UpdateCustomMetric)
+				r.Delete("/custom-metrics/{id}", handlers.DeleteCustomMetric)
+
+				// Pushing metrics to InfluxDB or a Prometheus Pushgateway
+				r.Get("/export/config", handlers.GetMetricsExportConfig)
+				r.Put("/export/config", handlers.UpdateMetricsExportConfig)
+				r.Post("/export/test", handlers.TestMetricsExport)
+
+				// Maintenance windows muting alerts
+				maintenanceHandler := handlers.NewAlertHandler()
+				r.Get("/maintenance-windows", maintenanceHandler.ListMaintenanceWindows)
+				r.Post("/maintenance-windows", maintenanceHandler.CreateMaintenanceWindow)
+				r.Put("/maintenance-windows/{id}", maintenanceHandler.UpdateMaintenanceWindow)
+				r.Delete("/maintenance-windows/{id}", maintenanceHandler.DeleteMaintenanceWindow)
+				r.Get("/maintenance-windows/{id}/suppressed", maintenanceHandler.GetSuppressedAlerts)
+				r.Post("/maintenance-windows/{id}/summary", maintenanceHandler.SendMaintenanceSummary)
 			})
 
 			// Scheduler/Task routes
 			r.Route("/tasks", func(r chi.Router) {
 				schedulerHandler := handlers.NewSchedulerHandler()
 
-				// Task management (admin only)
-				r.Use(mw.AdminOnly)
+				// Task management (task permissions)
+				r.Use(rbac.RequireAccess("task"))
 				r.Get("/", schedulerHandler.ListTasks)
 				r.Post("/", schedulerHandler.CreateTask)
 				r.Get("/{id}", schedulerHandler.GetTask)
@@ -739,6 +1032,7 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/enable", twofaHandler.EnableTwoFactor)
 				r.Post("/disable", twofaHandler.DisableTwoFactor)
 				r.Post("/backup-codes/regenerate", twofaHandler.RegenerateBackupCodes)
+				r.Get("/backup-codes/status", twofaHandler.GetBackupCodesStatus)
 			})
 
 			// Plugin routes
@@ -760,19 +1054,21 @@ func NewRouter(cfg *config.Config) http.Handler {
 				r.Post("/{id}/stop", pluginHandler.StopPlugin)
 				r.Post("/{id}/restart", pluginHandler.RestartPlugin)
 				r.Get("/{id}/status", pluginHandler.GetPluginStatus)
+				r.Get("/{id}/logs", pluginHandler.GetPluginLogs)
+				r.With(mw.SSEMiddleware).Get("/{id}/logs/stream", pluginHandler.StreamPluginLogs)
 				r.Get("/running", pluginHandler.ListRunningPlugins)
 			})
 
 			// Plugin Store routes (registry-based installation)
 			r.Route("/store", func(r chi.Router) {
-				// Public endpoints (browsing)
-				r.Get("/plugins", handlers.ListAvailablePlugins)
-				r.Get("/plugins/{id}", handlers.GetPluginFromRegistry)
+				// Public endpoints (browsing the addon marketplace)
+				r.Get("/plugins", handlers.ListMarketplaceAddons)
+				r.Get("/plugins/{id}", handlers.GetMarketplaceAddon)
 				r.Get("/plugins/search", handlers.SearchPlugins)
 
-				// Admin-only endpoints (installation)
+				// Installation endpoints (plugin:manage)
 				r.Group(func(r chi.Router) {
-					r.Use(mw.AdminOnly)
+					r.Use(rbac.RequirePermission("plugin", "manage"))
 
 					r.Post("/plugins/{id}/install", handlers.InstallPlugin)
 					r.Delete("/plugins/{id}/uninstall", handlers.UninstallPlugin)
@@ -782,9 +1078,43 @@ func NewRouter(cfg *config.Config) http.Handler {
 				})
 			})
 
+			// Admin settings (system permissions)
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(rbac.RequireAccess("system"))
+				r.Get("/access-logs", handlers.ListAccessLogs)
+				r.Get("/database/pool-stats", handlers.GetDatabasePoolStats)
+				r.Get("/cache/stats", handlers.GetCacheStats)
+				r.Post("/cache/flush", handlers.FlushCache)
+				r.Get("/rate-limits", handlers.ListRateLimits)
+				r.Put("/rate-limits/{target}", handlers.SetRateLimit)
+				r.Delete("/rate-limits/{target}", handlers.DeleteRateLimit)
+				r.Get("/telemetry", handlers.GetTelemetry)
+				r.Put("/telemetry", handlers.UpdateTelemetry)
+				r.With(rbac.RequirePermission("secret", "read")).Get("/secrets/list", handlers.ListSecrets)
+				r.With(rbac.RequirePermission("secret", "update")).Post("/secrets", handlers.SetSecret)
+
+				// Roles and role assignments (role permissions)
+				r.Group(func(r chi.Router) {
+					r.Use(rbac.RequireAccess("role"))
+					r.Get("/roles", handlers.ListRoles)
+					r.Post("/roles", handlers.CreateRole)
+					r.Get("/roles/{id}", handlers.GetRole)
+					r.Put("/roles/{id}", handlers.UpdateRole)
+					r.Delete("/roles/{id}", handlers.DeleteRole)
+					r.Get("/roles/{id}/permissions", handlers.GetRolePermissions)
+					r.Put("/roles/{id}/permissions", handlers.SetRolePermissions)
+					r.Get("/users/{id}/roles", handlers.ListUserRoles)
+					r.Post("/users/{id}/roles", handlers.AssignUserRole)
+					r.Delete("/users/{id}/roles/{roleId}", handlers.RevokeUserRole)
+				})
+			})
+
+			// Real-time event stream (Server-Sent Events)
+			r.With(mw.SSEMiddleware).Get("/events/stream", handlers.NewSSEHandler(events.GetBus()).ServeHTTP)
+
 			// Terminal WebSocket endpoint
 			r.Route("/terminal", func(r chi.Router) {
-				r.Use(mw.AdminOnly) // Terminal access requires admin privileges
+				r.Use(rbac.RequirePermission("terminal", "access")) // Terminal access is a shell as root
 				r.Get("/ws", handlers.TerminalWebSocketHandler)
 			})
 		})
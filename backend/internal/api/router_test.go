@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestUserImportAcceptsUploadsOverBodyLimit(t *testing.T) {
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "api.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserGroup{}, &models.AuditLog{}, &models.AccessLog{}); err != nil {
+		t.Fatal(err)
+	}
+	admin := &models.User{Username: "admin", Email: "admin@example.com", PasswordHash: "x", Role: "admin", IsActive: true}
+	if err := db.Create(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	t.Cleanup(func() { database.DB = nil })
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	cfg.Server.MaxBodySizeBytes = 1 << 20
+	router := NewRouter(cfg)
+
+	token, err := users.GenerateToken(&users.User{ID: admin.ID, Username: admin.Username, Role: admin.Role})
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	// A CSV of 2 MB, over the global body limit
+	var csv strings.Builder
+	csv.WriteString("username,email,fullname,password\n")
+	for i := 0; csv.Len() < 2<<20; i++ {
+		fmt.Fprintf(&csv, "user%d,user%d@example.com,%s,Secret123!\n", i, i, strings.Repeat("x", 200))
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("dryRun", "true")
+	part, _ := form.CreateFormFile("file", "users.csv")
+	part.Write([]byte(csv.String()))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/import", &body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/api/handlers"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// StartupHandler lets the server listen while the services start. Until
+// SetRouter is called it only serves the health check and the startup
+// status; other requests get a 503.
+type StartupHandler struct {
+	current atomic.Value // http.Handler
+}
+
+// NewStartupHandler creates a handler serving the startup routes
+func NewStartupHandler() *StartupHandler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Recoverer)
+
+	r.Get("/health", handlers.HealthCheck)
+	r.Get("/api/v1/system/startup-status", handlers.GetStartupStatus)
+	r.NotFound(respondStarting)
+	r.MethodNotAllowed(respondStarting)
+
+	h := &StartupHandler{}
+	h.current.Store(http.Handler(r))
+	return h
+}
+
+// SetRouter serves all further requests with router
+func (h *StartupHandler) SetRouter(router http.Handler) {
+	h.current.Store(router)
+}
+
+// ServeHTTP implements http.Handler
+func (h *StartupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// respondStarting answers requests the services are not ready for. It
+// doesn't go through RespondError, which would log every polled request.
+func respondStarting(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "5")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(utils.Response{
+		Success: false,
+		Error: &utils.ErrorInfo{
+			Code:    http.StatusServiceUnavailable,
+			Message: "Server is starting",
+		},
+	})
+}
@@ -0,0 +1,280 @@
+package audit
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RetentionPolicy limits how long and how many audit logs are kept
+type RetentionPolicy = models.AuditRetentionPolicy
+
+// DefaultArchiveDir is where expiring audit logs are archived by default
+const DefaultArchiveDir = "/var/lib/stumpfworks/audit-archive"
+
+// DefaultRetentionPolicy is stored when no policy has been configured yet
+var DefaultRetentionPolicy = RetentionPolicy{
+	MaxAgeDays:          365,
+	MaxRecords:          0,
+	ArchiveBeforeDelete: false,
+	ArchivePath:         DefaultArchiveDir,
+}
+
+const (
+	// retentionLastRunKey is the audit metadata key of the last enforcement time
+	retentionLastRunKey = "retention.last_enforced_at"
+	// retentionMinInterval is the minimum time between two enforcements, so
+	// a nightly run is not repeated by a restart or another cluster node
+	retentionMinInterval = 12 * time.Hour
+	// retentionBatchSize is how many logs are archived per query
+	retentionBatchSize = 1000
+)
+
+// GetRetentionPolicy returns the retention policy, storing the default
+// policy on first use
+func (s *Service) GetRetentionPolicy() (RetentionPolicy, error) {
+	var policy RetentionPolicy
+	err := s.db.First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		policy = DefaultRetentionPolicy
+		err = s.db.Create(&policy).Error
+	}
+	if err != nil {
+		return RetentionPolicy{}, fmt.Errorf("failed to load audit retention policy: %w", err)
+	}
+	return policy, nil
+}
+
+// UpdateRetentionPolicy validates and stores a new retention policy. It
+// applies from the next enforcement on.
+func (s *Service) UpdateRetentionPolicy(ctx context.Context, policy RetentionPolicy) (RetentionPolicy, error) {
+	if policy.MaxAgeDays < 0 {
+		return RetentionPolicy{}, fmt.Errorf("maxAgeDays must not be negative")
+	}
+	if policy.MaxRecords < 0 {
+		return RetentionPolicy{}, fmt.Errorf("maxRecords must not be negative")
+	}
+	if policy.ArchivePath == "" {
+		policy.ArchivePath = DefaultArchiveDir
+	}
+	if !filepath.IsAbs(policy.ArchivePath) {
+		return RetentionPolicy{}, fmt.Errorf("archivePath must be an absolute path")
+	}
+	policy.ArchivePath = filepath.Clean(policy.ArchivePath)
+
+	current, err := s.GetRetentionPolicy()
+	if err != nil {
+		return RetentionPolicy{}, err
+	}
+	policy.ID = current.ID
+	if err := s.db.Save(&policy).Error; err != nil {
+		return RetentionPolicy{}, fmt.Errorf("failed to save audit retention policy: %w", err)
+	}
+
+	logger.Info("Audit retention policy updated",
+		zap.Int("maxAgeDays", policy.MaxAgeDays),
+		zap.Int("maxRecords", policy.MaxRecords),
+		zap.Bool("archiveBeforeDelete", policy.ArchiveBeforeDelete),
+		zap.String("archivePath", policy.ArchivePath))
+
+	_ = s.Log(ctx, &LogEntry{
+		Username: "system",
+		Action:   "audit.retention_policy_updated",
+		Resource: "audit/retention-policy",
+		Status:   models.StatusSuccess,
+		Severity: models.SeverityWarning,
+		Message:  "Audit log retention policy updated",
+	})
+
+	return policy, nil
+}
+
+// EnforceRetention deletes the audit logs that the retention policy no
+// longer keeps: logs older than MaxAgeDays and all but the newest
+// MaxRecords logs. With ArchiveBeforeDelete they are first exported to a
+// gzip compressed JSONL file in ArchivePath. A run within 12 hours of the
+// last one is skipped.
+func (s *Service) EnforceRetention() error {
+	s.retentionMu.Lock()
+	defer s.retentionMu.Unlock()
+
+	now := time.Now().UTC()
+	claimed, previous, err := s.claimRetentionRun(now)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		logger.Debug("Audit retention was enforced recently, skipping", zap.String("last_run", previous))
+		return nil
+	}
+
+	deleted, archive, err := s.enforceRetention(now)
+	if err != nil {
+		// Let the next run try again
+		if restoreErr := s.restoreRetentionRun(previous); restoreErr != nil {
+			logger.Warn("Failed to reset audit retention run time", zap.Error(restoreErr))
+		}
+		return err
+	}
+
+	logger.Info("Audit retention enforced", zap.Int64("deleted", deleted), zap.String("archive", archive))
+	return nil
+}
+
+// enforceRetention deletes the expiring logs and returns how many were
+// deleted and the archive they were written to, if any
+func (s *Service) enforceRetention(now time.Time) (int64, string, error) {
+	policy, err := s.GetRetentionPolicy()
+	if err != nil {
+		return 0, "", err
+	}
+
+	var conditions []string
+	var args []interface{}
+	if policy.MaxAgeDays > 0 {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, now.AddDate(0, 0, -policy.MaxAgeDays))
+	}
+	if policy.MaxRecords > 0 {
+		// The newest log beyond MaxRecords; it and all older logs expire
+		var ids []uint
+		if err := s.db.Model(&models.AuditLog{}).Order("id DESC").
+			Offset(policy.MaxRecords).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return 0, "", fmt.Errorf("failed to count audit logs: %w", err)
+		}
+		if len(ids) > 0 {
+			conditions = append(conditions, "id <= ?")
+			args = append(args, ids[0])
+		}
+	}
+	if len(conditions) == 0 {
+		return 0, "", nil
+	}
+	expired := "(" + strings.Join(conditions, " OR ") + ")"
+
+	if !policy.ArchiveBeforeDelete {
+		result := s.db.Where(expired, args...).Delete(&models.AuditLog{})
+		if result.Error != nil {
+			return 0, "", fmt.Errorf("failed to delete expired audit logs: %w", result.Error)
+		}
+		return result.RowsAffected, "", nil
+	}
+
+	archive, lastID, err := s.archiveAuditLogs(policy.ArchivePath, now, expired, args)
+	if err != nil {
+		return 0, "", err
+	}
+	if archive == "" {
+		return 0, "", nil
+	}
+
+	// Delete exactly the archived logs
+	result := s.db.Where(expired, args...).Where("id <= ?", lastID).Delete(&models.AuditLog{})
+	if result.Error != nil {
+		return 0, archive, fmt.Errorf("failed to delete archived audit logs: %w", result.Error)
+	}
+	return result.RowsAffected, archive, nil
+}
+
+// archiveAuditLogs writes the logs matching where to a new archive in dir
+// and returns its path and the highest archived ID. No archive is created
+// if no logs match.
+func (s *Service) archiveAuditLogs(dir string, now time.Time, where string, args []interface{}) (string, uint, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", 0, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("audit-%s.jsonl.gz", now.Format("20060102-150405")))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create audit archive: %w", err)
+	}
+
+	var lastID uint
+	var count int
+	err = func() error {
+		gz := gzip.NewWriter(f)
+		encoder := json.NewEncoder(gz)
+		for {
+			var batch []models.AuditLog
+			if err := s.db.Where(where, args...).Where("id > ?", lastID).
+				Order("id").Limit(retentionBatchSize).Find(&batch).Error; err != nil {
+				return fmt.Errorf("failed to read expired audit logs: %w", err)
+			}
+			for i := range batch {
+				if err := encoder.Encode(&batch[i]); err != nil {
+					return fmt.Errorf("failed to write audit archive: %w", err)
+				}
+				lastID = batch[i].ID
+			}
+			count += len(batch)
+			if len(batch) < retentionBatchSize {
+				break
+			}
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to write audit archive: %w", err)
+		}
+		// The logs are deleted next, so the archive must be on disk first
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("failed to write audit archive: %w", err)
+		}
+		return f.Close()
+	}()
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", 0, err
+	}
+
+	if count == 0 {
+		os.Remove(path)
+		return "", 0, nil
+	}
+	return path, lastID, nil
+}
+
+// claimRetentionRun records now as the last enforcement time unless
+// retention was enforced less than retentionMinInterval ago. It also
+// returns the previous time so a failed run can be reset.
+func (s *Service) claimRetentionRun(now time.Time) (bool, string, error) {
+	var claimed bool
+	var previous string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var meta models.AuditMetadata
+		err := tx.First(&meta, "key = ?", retentionLastRunKey).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		previous = meta.Value
+
+		if last, err := time.Parse(time.RFC3339, meta.Value); err == nil && now.Sub(last) < retentionMinInterval {
+			return nil
+		}
+		claimed = true
+		return tx.Save(&models.AuditMetadata{Key: retentionLastRunKey, Value: now.Format(time.RFC3339)}).Error
+	})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to record audit retention run: %w", err)
+	}
+	return claimed, previous, nil
+}
+
+// restoreRetentionRun resets the last enforcement time to previous
+func (s *Service) restoreRetentionRun(previous string) error {
+	if previous == "" {
+		return s.db.Delete(&models.AuditMetadata{}, "key = ?", retentionLastRunKey).Error
+	}
+	return s.db.Save(&models.AuditMetadata{Key: retentionLastRunKey, Value: previous}).Error
+}
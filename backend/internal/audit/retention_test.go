@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "audit.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AuditLog{}, &models.AuditRetentionPolicy{}, &models.AuditMetadata{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return &Service{db: db}
+}
+
+func TestEnforceRetentionArchivesDeletedLogs(t *testing.T) {
+	s := newTestService(t)
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+
+	// 3 logs older than 30 days, then 8 recent ones of which only the newest 5 are kept
+	now := time.Now().UTC()
+	var all []uint
+	for i := 0; i < 11; i++ {
+		createdAt := now.Add(-time.Duration(11-i) * time.Hour)
+		if i < 3 {
+			createdAt = now.AddDate(0, 0, -40+i)
+		}
+		entry := models.AuditLog{Username: "alice", Action: "file.delete", Status: models.StatusSuccess, Severity: models.SeverityInfo, CreatedAt: createdAt}
+		if err := s.db.Create(&entry).Error; err != nil {
+			t.Fatalf("failed to create audit log: %v", err)
+		}
+		all = append(all, entry.ID)
+	}
+
+	if _, err := s.UpdateRetentionPolicy(t.Context(), RetentionPolicy{
+		MaxAgeDays:          30,
+		MaxRecords:          5,
+		ArchiveBeforeDelete: true,
+		ArchivePath:         archiveDir,
+	}); err != nil {
+		t.Fatalf("UpdateRetentionPolicy() error = %v", err)
+	}
+	// The policy update itself is audited and counts towards MaxRecords
+	var updateLog models.AuditLog
+	if err := s.db.Last(&updateLog).Error; err != nil {
+		t.Fatalf("failed to load policy update log: %v", err)
+	}
+	all = append(all, updateLog.ID)
+
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("EnforceRetention() error = %v", err)
+	}
+
+	var remaining []uint
+	if err := s.db.Model(&models.AuditLog{}).Order("id").Pluck("id", &remaining).Error; err != nil {
+		t.Fatalf("failed to list audit logs: %v", err)
+	}
+	if want := all[len(all)-5:]; !equalIDs(remaining, want) {
+		t.Errorf("remaining logs = %v, want %v", remaining, want)
+	}
+
+	archives, err := filepath.Glob(filepath.Join(archiveDir, "audit-*.jsonl.gz"))
+	if err != nil || len(archives) != 1 {
+		t.Fatalf("archives = %v, %v; want one archive", archives, err)
+	}
+	archived := readArchive(t, archives[0])
+	if want := all[:len(all)-5]; !equalIDs(archived, want) {
+		t.Errorf("archived logs = %v, want the deleted logs %v", archived, want)
+	}
+
+	// A second run right away is skipped
+	extra := models.AuditLog{Username: "bob", Action: "file.delete", Status: models.StatusSuccess, Severity: models.SeverityInfo, CreatedAt: now}
+	s.db.Create(&extra)
+	if err := s.EnforceRetention(); err != nil {
+		t.Fatalf("second EnforceRetention() error = %v", err)
+	}
+	var count int64
+	s.db.Model(&models.AuditLog{}).Count(&count)
+	if count != 6 {
+		t.Errorf("second run left %d logs, want 6 (run skipped)", count)
+	}
+}
+
+func readArchive(t *testing.T, path string) []uint {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive is not gzip compressed: %v", err)
+	}
+
+	var ids []uint
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var entry models.AuditLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid archive line %q: %v", scanner.Text(), err)
+		}
+		if entry.Username == "" || entry.Action == "" {
+			t.Errorf("archived log %d is incomplete: %+v", entry.ID, entry)
+		}
+		ids = append(ids, entry.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	return ids
+}
+
+func equalIDs(got, want []uint) bool {
+	slices.Sort(got)
+	return slices.Equal(got, want)
+}
@@ -18,8 +18,9 @@ import (
 
 // Service handles audit logging operations
 type Service struct {
-	db *gorm.DB
-	mu sync.RWMutex
+	db          *gorm.DB
+	mu          sync.RWMutex
+	retentionMu sync.Mutex // Serializes retention runs without blocking Log
 }
 
 var (
@@ -22,9 +22,7 @@ type FailedLoginService struct {
 	mu sync.RWMutex
 
 	// Configuration
-	maxAttempts      int           // Max attempts before blocking
-	blockDuration    time.Duration // How long to block IP
-	attemptWindow    time.Duration // Time window for counting attempts
+	policy           models.FailedLoginPolicy // Lockout thresholds, see GetPolicy
 	cleanupInterval  time.Duration // How often to clean old records
 	stopCleanup      chan bool
 }
@@ -36,21 +34,37 @@ var (
 
 // InitializeFailedLoginService initializes the failed login tracking service
 func InitializeFailedLoginService() (*FailedLoginService, error) {
+	var initErr error
 	failedLoginOnce.Do(func() {
-		globalFailedLoginService = &FailedLoginService{
-			db:               database.GetDB(),
-			maxAttempts:      5,                // 5 failed attempts
-			blockDuration:    15 * time.Minute, // Block for 15 minutes
-			attemptWindow:    15 * time.Minute, // Count attempts in last 15 minutes
-			cleanupInterval:  1 * time.Hour,    // Cleanup every hour
-			stopCleanup:      make(chan bool),
+		service, err := newFailedLoginService(database.GetDB())
+		if err != nil {
+			initErr = err
+			return
 		}
+		globalFailedLoginService = service
 
 		// Start background cleanup task
 		go globalFailedLoginService.startCleanupTask()
 	})
 
-	return globalFailedLoginService, nil
+	return globalFailedLoginService, initErr
+}
+
+// newFailedLoginService creates a service that uses the lockout policy stored in db
+func newFailedLoginService(db *gorm.DB) (*FailedLoginService, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	s := &FailedLoginService{
+		db:              db,
+		cleanupInterval: 1 * time.Hour, // Cleanup every hour
+		stopCleanup:     make(chan bool),
+	}
+	if err := s.loadPolicy(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 // GetFailedLoginService returns the global failed login service
@@ -102,36 +116,50 @@ func (s *FailedLoginService) RecordFailedAttempt(ctx context.Context, username,
 	return nil
 }
 
-// checkAndBlockIP checks if an IP should be blocked based on recent failed attempts
+// checkAndBlockIP checks if an IP or username should be locked out based on
+// recent failed attempts and the lockout policy
 func (s *FailedLoginService) checkAndBlockIP(ctx context.Context, ipAddress, username string) error {
-	// Count recent failed attempts from this IP
-	cutoffTime := time.Now().UTC().Add(-s.attemptWindow)
+	policy := s.policy
+	cutoffTime := time.Now().UTC().Add(-time.Duration(policy.WindowMinutes) * time.Minute)
+
+	if policy.LockoutByUsername && username != "" {
+		if err := s.checkAndLockUsername(ctx, username, cutoffTime); err != nil {
+			return err
+		}
+	}
 
+	if !policy.LockoutByIP || isWhitelisted(policy.WhitelistIPs, ipAddress) {
+		return nil
+	}
+
+	// Count recent failed attempts from this IP that did not cause a block yet
 	var attemptCount int64
 	if err := s.db.Model(&models.FailedLoginAttempt{}).
-		Where("ip_address = ? AND created_at > ?", ipAddress, cutoffTime).
+		Where("ip_address = ? AND created_at > ? AND blocked = ?", ipAddress, cutoffTime, false).
 		Count(&attemptCount).Error; err != nil {
 		return fmt.Errorf("failed to count attempts: %w", err)
 	}
 
 	// If attempts exceed threshold, block the IP
-	if attemptCount >= int64(s.maxAttempts) {
+	if attemptCount >= int64(policy.MaxAttempts) {
 		// Check if already blocked
 		var existingBlock models.IPBlock
-		err := s.db.Where("ip_address = ? AND is_active = ?", ipAddress, true).First(&existingBlock).Error
-
-		if err == gorm.ErrRecordNotFound {
-			// Create new block
-			block := &models.IPBlock{
-				IPAddress:   ipAddress,
-				Reason:      fmt.Sprintf("Too many failed login attempts (%d)", attemptCount),
-				Attempts:    int(attemptCount),
-				ExpiresAt:   time.Now().UTC().Add(s.blockDuration),
-				IsActive:    true,
-				IsPermanent: false,
-			}
+		err := s.db.Where("ip_address = ?", ipAddress).First(&existingBlock).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("failed to check IP block: %w", err)
+		}
 
-			if err := s.db.Create(block).Error; err != nil {
+		if err == gorm.ErrRecordNotFound || !existingBlock.IsActive {
+			// Create new block, or reactivate the expired one
+			block := &existingBlock
+			block.IPAddress = ipAddress
+			block.Reason = fmt.Sprintf("Too many failed login attempts (%d)", attemptCount)
+			block.Attempts = int(attemptCount)
+			block.ExpiresAt = time.Now().UTC().Add(policy.LockoutDuration)
+			block.IsActive = true
+			block.IsPermanent = false
+
+			if err := s.db.Save(block).Error; err != nil {
 				return fmt.Errorf("failed to create IP block: %w", err)
 			}
 
@@ -154,7 +182,7 @@ func (s *FailedLoginService) checkAndBlockIP(ctx context.Context, ipAddress, use
 					map[string]interface{}{
 						"ip_address":  ipAddress,
 						"attempts":    attemptCount,
-						"duration":    s.blockDuration.String(),
+						"duration":    policy.LockoutDuration.String(),
 						"expires_at":  block.ExpiresAt,
 					})
 			}
@@ -162,7 +190,7 @@ func (s *FailedLoginService) checkAndBlockIP(ctx context.Context, ipAddress, use
 			logger.Warn("IP address blocked",
 				zap.String("ip", ipAddress),
 				zap.Int64("attempts", attemptCount),
-				zap.Duration("duration", s.blockDuration))
+				zap.Duration("duration", policy.LockoutDuration))
 
 			// Send alert notification
 			alertService := alerts.GetService()
@@ -177,7 +205,7 @@ func (s *FailedLoginService) checkAndBlockIP(ctx context.Context, ipAddress, use
 	}
 
 	// Send failed login alert if threshold reached (even if not blocked yet)
-	if attemptCount >= int64(s.maxAttempts-2) && attemptCount < int64(s.maxAttempts) {
+	if attemptCount >= int64(policy.MaxAttempts-2) && attemptCount < int64(policy.MaxAttempts) {
 		alertService := alerts.GetService()
 		if alertService != nil {
 			go func() {
@@ -196,6 +224,10 @@ func (s *FailedLoginService) IsIPBlocked(ipAddress string) (bool, *models.IPBloc
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	if !s.policy.LockoutByIP || isWhitelisted(s.policy.WhitelistIPs, ipAddress) {
+		return false, nil, nil
+	}
+
 	var block models.IPBlock
 	err := s.db.Where("ip_address = ? AND is_active = ?", ipAddress, true).First(&block).Error
 
@@ -372,15 +404,9 @@ func (s *FailedLoginService) cleanup() {
 		logger.Info("Cleaned up old login attempts", zap.Int64("count", result.RowsAffected))
 	}
 
-	// Deactivate expired IP blocks
-	now := time.Now().UTC()
-	result = s.db.Model(&models.IPBlock{}).
-		Where("is_active = ? AND is_permanent = ? AND expires_at < ?", true, false, now).
-		Update("is_active", false)
-	if result.Error != nil {
-		logger.Error("Failed to deactivate expired blocks", zap.Error(result.Error))
-	} else if result.RowsAffected > 0 {
-		logger.Info("Deactivated expired IP blocks", zap.Int64("count", result.RowsAffected))
+	// Deactivate expired IP blocks and account lockouts
+	if _, err := s.unlockExpired(time.Now().UTC()); err != nil {
+		logger.Error("Failed to deactivate expired lockouts", zap.Error(err))
 	}
 }
 
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/audit"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Lockout types reported by IsLocked
+const (
+	LockoutTypeIP       = "ip"
+	LockoutTypeUsername = "username"
+)
+
+// DefaultFailedLoginPolicy is stored when no policy has been configured yet
+var DefaultFailedLoginPolicy = models.FailedLoginPolicy{
+	MaxAttempts:       5,                // 5 failed attempts
+	WindowMinutes:     15,               // Count attempts in last 15 minutes
+	LockoutDuration:   15 * time.Minute, // Lock out for 15 minutes
+	LockoutByIP:       true,
+	LockoutByUsername: false,
+	WhitelistIPs:      []string{},
+}
+
+// LockoutReason describes why a login is refused
+type LockoutReason struct {
+	Type      string     `json:"type"`    // ip or username
+	Subject   string     `json:"subject"` // The locked IP address or username
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // Nil for permanent blocks
+}
+
+// loadPolicy reads the lockout policy, storing the default policy on first start
+func (s *FailedLoginService) loadPolicy() error {
+	var policy models.FailedLoginPolicy
+	err := s.db.First(&policy).Error
+	if err == gorm.ErrRecordNotFound {
+		policy = DefaultFailedLoginPolicy
+		err = s.db.Create(&policy).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load lockout policy: %w", err)
+	}
+
+	s.policy = policy
+	return nil
+}
+
+// GetPolicy returns the lockout policy
+func (s *FailedLoginService) GetPolicy() models.FailedLoginPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy
+}
+
+// UpdatePolicy validates and stores a new lockout policy. It applies to
+// failed attempts recorded from now on.
+func (s *FailedLoginService) UpdatePolicy(ctx context.Context, policy models.FailedLoginPolicy) (models.FailedLoginPolicy, error) {
+	if policy.MaxAttempts < 1 {
+		return models.FailedLoginPolicy{}, fmt.Errorf("maxAttempts must be at least 1")
+	}
+	if policy.WindowMinutes < 1 {
+		return models.FailedLoginPolicy{}, fmt.Errorf("windowMinutes must be at least 1")
+	}
+	if policy.LockoutDuration < time.Minute {
+		return models.FailedLoginPolicy{}, fmt.Errorf("lockout duration must be at least 1 minute")
+	}
+	if policy.WhitelistIPs == nil {
+		policy.WhitelistIPs = []string{}
+	}
+	for _, entry := range policy.WhitelistIPs {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return models.FailedLoginPolicy{}, fmt.Errorf("invalid whitelist entry %q: must be an IP address or CIDR range", entry)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policy.ID = s.policy.ID
+	if err := s.db.Save(&policy).Error; err != nil {
+		return models.FailedLoginPolicy{}, fmt.Errorf("failed to save lockout policy: %w", err)
+	}
+	s.policy = policy
+
+	logger.Info("Lockout policy updated",
+		zap.Int("maxAttempts", policy.MaxAttempts),
+		zap.Int("windowMinutes", policy.WindowMinutes),
+		zap.Duration("lockoutDuration", policy.LockoutDuration),
+		zap.Bool("lockoutByIP", policy.LockoutByIP),
+		zap.Bool("lockoutByUsername", policy.LockoutByUsername))
+
+	auditService := audit.GetService()
+	if auditService != nil {
+		_ = auditService.Log(ctx, &audit.LogEntry{
+			Username: "system",
+			Action:   "security.lockout_policy_updated",
+			Resource: "security/lockout-policy",
+			Status:   models.StatusSuccess,
+			Severity: models.SeverityWarning,
+			Message:  "Failed login lockout policy updated",
+		})
+	}
+
+	return policy, nil
+}
+
+// IsLocked checks whether logins as username from ipAddress are locked out.
+// IP and username lockouts are checked as enabled by the policy; whitelisted
+// IPs are never locked out by IP.
+func (s *FailedLoginService) IsLocked(username, ipAddress string) (bool, LockoutReason, error) {
+	blocked, block, err := s.IsIPBlocked(ipAddress)
+	if err != nil {
+		return false, LockoutReason{}, err
+	}
+	if blocked {
+		reason := LockoutReason{Type: LockoutTypeIP, Subject: ipAddress, Reason: block.Reason}
+		if !block.IsPermanent {
+			reason.ExpiresAt = &block.ExpiresAt
+		}
+		return true, reason, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.policy.LockoutByUsername || username == "" {
+		return false, LockoutReason{}, nil
+	}
+
+	var lockout models.AccountLockout
+	err = s.db.Where("username = ? AND is_active = ?", username, true).First(&lockout).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, LockoutReason{}, nil
+	}
+	if err != nil {
+		return false, LockoutReason{}, fmt.Errorf("failed to check account lockout: %w", err)
+	}
+	if time.Now().UTC().After(lockout.ExpiresAt) {
+		// Expired but not yet unlocked by the auto-unlock job
+		return false, LockoutReason{}, nil
+	}
+
+	return true, LockoutReason{
+		Type:      LockoutTypeUsername,
+		Subject:   username,
+		Reason:    lockout.Reason,
+		ExpiresAt: &lockout.ExpiresAt,
+	}, nil
+}
+
+// checkAndLockUsername locks a username that reached the attempt threshold.
+// Attempts made before the previous lockout ended are not counted again.
+func (s *FailedLoginService) checkAndLockUsername(ctx context.Context, username string, cutoffTime time.Time) error {
+	var lockout models.AccountLockout
+	err := s.db.Where("username = ?", username).First(&lockout).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to check account lockout: %w", err)
+	}
+	if err == nil {
+		if lockout.IsActive {
+			return nil
+		}
+		if lockout.UpdatedAt.After(cutoffTime) {
+			cutoffTime = lockout.UpdatedAt
+		}
+	}
+
+	var attemptCount int64
+	if err := s.db.Model(&models.FailedLoginAttempt{}).
+		Where("username = ? AND created_at > ?", username, cutoffTime).
+		Count(&attemptCount).Error; err != nil {
+		return fmt.Errorf("failed to count attempts: %w", err)
+	}
+	if attemptCount < int64(s.policy.MaxAttempts) {
+		return nil
+	}
+
+	lockout.Username = username
+	lockout.Reason = fmt.Sprintf("Too many failed login attempts (%d)", attemptCount)
+	lockout.Attempts = int(attemptCount)
+	lockout.ExpiresAt = time.Now().UTC().Add(s.policy.LockoutDuration)
+	lockout.IsActive = true
+	if err := s.db.Save(&lockout).Error; err != nil {
+		return fmt.Errorf("failed to lock account: %w", err)
+	}
+
+	logger.Warn("Account locked",
+		zap.String("username", username),
+		zap.Int64("attempts", attemptCount),
+		zap.Duration("duration", s.policy.LockoutDuration))
+
+	auditService := audit.GetService()
+	if auditService != nil {
+		_ = auditService.LogWithDetails(ctx, nil, username, "security.account_locked", username,
+			models.StatusSuccess, models.SeverityCritical,
+			fmt.Sprintf("Account %s locked due to %d failed login attempts", username, attemptCount),
+			map[string]interface{}{
+				"attempts":   attemptCount,
+				"duration":   s.policy.LockoutDuration.String(),
+				"expires_at": lockout.ExpiresAt,
+			})
+	}
+
+	return nil
+}
+
+// GetLockedAccounts retrieves all currently locked usernames
+func (s *FailedLoginService) GetLockedAccounts() ([]*models.AccountLockout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lockouts []*models.AccountLockout
+	if err := s.db.Where("is_active = ?", true).
+		Order("created_at DESC").
+		Find(&lockouts).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve locked accounts: %w", err)
+	}
+
+	return lockouts, nil
+}
+
+// UnlockAccount removes the lockout of a username
+func (s *FailedLoginService) UnlockAccount(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.db.Model(&models.AccountLockout{}).
+		Where("username = ? AND is_active = ?", username, true).
+		Update("is_active", false)
+	if result.Error != nil {
+		return fmt.Errorf("failed to unlock account: %w", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		logger.Info("Account unlocked", zap.String("username", username))
+
+		auditService := audit.GetService()
+		if auditService != nil {
+			_ = auditService.Log(ctx, &audit.LogEntry{
+				Username: "system",
+				Action:   "security.account_unlocked",
+				Resource: username,
+				Status:   models.StatusSuccess,
+				Severity: models.SeverityInfo,
+				Message:  fmt.Sprintf("Account %s unlocked", username),
+			})
+		}
+	}
+
+	return nil
+}
+
+// AutoUnlockJob unlocks the IP addresses and usernames whose lockout expired
+// before now. It is run by the scheduler and returns the number of lockouts lifted.
+func (s *FailedLoginService) AutoUnlockJob(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unlockExpired(now)
+}
+
+// unlockExpired deactivates expired IP blocks and account lockouts
+func (s *FailedLoginService) unlockExpired(now time.Time) (int64, error) {
+	result := s.db.Model(&models.IPBlock{}).
+		Where("is_active = ? AND is_permanent = ? AND expires_at < ?", true, false, now).
+		Update("is_active", false)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to deactivate expired IP blocks: %w", result.Error)
+	}
+	unlocked := result.RowsAffected
+
+	result = s.db.Model(&models.AccountLockout{}).
+		Where("is_active = ? AND expires_at < ?", true, now).
+		Update("is_active", false)
+	if result.Error != nil {
+		return unlocked, fmt.Errorf("failed to unlock expired accounts: %w", result.Error)
+	}
+	unlocked += result.RowsAffected
+
+	if unlocked > 0 {
+		logger.Info("Expired lockouts lifted", zap.Int64("count", unlocked))
+	}
+	return unlocked, nil
+}
+
+// isWhitelisted reports whether ipAddress matches an IP or CIDR range of the whitelist
+func isWhitelisted(whitelist []string, ipAddress string) bool {
+	ip := parseIP(ipAddress)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range whitelist {
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return true
+			}
+		} else if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP parses a client address, which is "host:port" when it was taken
+// from the request's RemoteAddr
+func parseIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return net.ParseIP(address)
+}
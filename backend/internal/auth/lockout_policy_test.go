@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestFailedLoginService(t *testing.T, policy models.FailedLoginPolicy) *FailedLoginService {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "auth.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.FailedLoginAttempt{}, &models.IPBlock{}, &models.AccountLockout{},
+		&models.FailedLoginPolicy{}, &models.AuditLog{}, &models.AlertConfig{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { database.DB = nil })
+
+	s, err := newFailedLoginService(db)
+	if err != nil {
+		t.Fatalf("newFailedLoginService: %v", err)
+	}
+	if s.GetPolicy().MaxAttempts != DefaultFailedLoginPolicy.MaxAttempts {
+		t.Errorf("initial policy = %+v", s.GetPolicy())
+	}
+	if _, err := s.UpdatePolicy(context.Background(), policy); err != nil {
+		t.Fatalf("UpdatePolicy: %v", err)
+	}
+	return s
+}
+
+func recordAttempts(t *testing.T, s *FailedLoginService, n int, username, ip string) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := s.RecordFailedAttempt(context.Background(), username, ip, "test", models.FailureReasonInvalidPassword); err != nil {
+			t.Fatalf("RecordFailedAttempt: %v", err)
+		}
+	}
+}
+
+func TestWhitelistedIPBypassesIPLockout(t *testing.T) {
+	s := newTestFailedLoginService(t, models.FailedLoginPolicy{
+		MaxAttempts:     3,
+		WindowMinutes:   15,
+		LockoutDuration: 10 * time.Minute,
+		LockoutByIP:     true,
+		WhitelistIPs:    []string{"10.0.0.0/8", "2001:db8::1"},
+	})
+
+	recordAttempts(t, s, 3, "alice", "10.1.2.3")
+	if locked, _, err := s.IsLocked("alice", "10.1.2.3"); err != nil || locked {
+		t.Errorf("whitelisted IP locked = %v, %v", locked, err)
+	}
+	recordAttempts(t, s, 3, "alice", "[2001:db8::1]:51234")
+	if locked, _, err := s.IsLocked("alice", "[2001:db8::1]:51234"); err != nil || locked {
+		t.Errorf("whitelisted RemoteAddr locked = %v, %v", locked, err)
+	}
+
+	recordAttempts(t, s, 3, "alice", "192.0.2.7")
+	locked, reason, err := s.IsLocked("alice", "192.0.2.7")
+	if err != nil || !locked || reason.Type != LockoutTypeIP || reason.Subject != "192.0.2.7" || reason.ExpiresAt == nil {
+		t.Errorf("IsLocked = %v, %+v, %v", locked, reason, err)
+	}
+
+	// Username lockout is disabled, so alice can still log in from elsewhere
+	if locked, _, _ := s.IsLocked("alice", "10.1.2.3"); locked {
+		t.Error("username locked although lockoutByUsername is disabled")
+	}
+
+	if _, err := s.UpdatePolicy(context.Background(), models.FailedLoginPolicy{
+		MaxAttempts: 3, WindowMinutes: 15, LockoutDuration: time.Minute, WhitelistIPs: []string{"10.0.0.300"},
+	}); err == nil {
+		t.Error("accepted an invalid whitelist entry")
+	}
+}
+
+func TestAutoUnlockAfterLockoutDuration(t *testing.T) {
+	s := newTestFailedLoginService(t, models.FailedLoginPolicy{
+		MaxAttempts:       3,
+		WindowMinutes:     15,
+		LockoutDuration:   10 * time.Minute,
+		LockoutByIP:       true,
+		LockoutByUsername: true,
+	})
+
+	recordAttempts(t, s, 3, "bob", "192.0.2.7")
+	locked, reason, err := s.IsLocked("bob", "198.51.100.1")
+	if err != nil || !locked || reason.Type != LockoutTypeUsername {
+		t.Fatalf("IsLocked = %v, %+v, %v", locked, reason, err)
+	}
+
+	now := time.Now().UTC()
+	if unlocked, err := s.AutoUnlockJob(now.Add(5 * time.Minute)); err != nil || unlocked != 0 {
+		t.Errorf("AutoUnlockJob before expiry = %d, %v", unlocked, err)
+	}
+	if locked, _, _ := s.IsLocked("bob", "198.51.100.1"); !locked {
+		t.Error("lockout lifted before its duration")
+	}
+
+	// Lifts both the IP block and the account lockout
+	if unlocked, err := s.AutoUnlockJob(now.Add(11 * time.Minute)); err != nil || unlocked != 2 {
+		t.Errorf("AutoUnlockJob after expiry = %d, %v", unlocked, err)
+	}
+	if locked, reason, _ := s.IsLocked("bob", "192.0.2.7"); locked {
+		t.Errorf("still locked after auto-unlock: %+v", reason)
+	}
+
+	// Attempts from before the lockout do not count again
+	recordAttempts(t, s, 1, "bob", "198.51.100.1")
+	if locked, _, _ := s.IsLocked("bob", "198.51.100.1"); locked {
+		t.Error("account locked again by a single attempt after auto-unlock")
+	}
+	recordAttempts(t, s, 2, "bob", "198.51.100.1")
+	if locked, _, _ := s.IsLocked("bob", "198.51.100.1"); !locked {
+		t.Error("account not locked again after reaching the threshold")
+	}
+}
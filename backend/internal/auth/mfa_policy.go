@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/audit"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// MFA requirement of a login, as decided by MFAEnforcement.Check
+const (
+	MFANotRequired   = "not_required"   // The policy does not require 2FA
+	MFASatisfied     = "satisfied"      // 2FA is required and the user has enrolled
+	MFAGracePeriod   = "grace_period"   // 2FA is required, but the grace period has not ended yet
+	MFASetupRequired = "setup_required" // 2FA is required; the user must set it up before logging in
+)
+
+// DefaultMFAPolicy is stored when no policy has been configured yet. It
+// requires 2FA for nobody.
+var DefaultMFAPolicy = models.MFAPolicy{
+	RequireForRoles:       []string{},
+	RequireForExternalIPs: false,
+	ExemptLocalSubnets:    []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+	GracePeriodDays:       7,
+}
+
+// MFACheck is the result of checking a login against the MFA policy
+type MFACheck struct {
+	Status     string     `json:"status"`
+	GraceUntil *time.Time `json:"graceUntil,omitempty"` // Set during the grace period
+}
+
+// MFAEnforcement decides which logins require two-factor authentication
+type MFAEnforcement struct {
+	db     *gorm.DB
+	mu     sync.RWMutex
+	policy models.MFAPolicy
+}
+
+var (
+	globalMFAEnforcement *MFAEnforcement
+	mfaEnforcementOnce   sync.Once
+)
+
+// InitializeMFAEnforcement initializes the MFA enforcement service
+func InitializeMFAEnforcement() (*MFAEnforcement, error) {
+	var initErr error
+	mfaEnforcementOnce.Do(func() {
+		globalMFAEnforcement, initErr = newMFAEnforcement(database.GetDB())
+	})
+
+	return globalMFAEnforcement, initErr
+}
+
+// GetMFAEnforcement returns the global MFA enforcement service
+func GetMFAEnforcement() *MFAEnforcement {
+	if globalMFAEnforcement == nil {
+		globalMFAEnforcement, _ = InitializeMFAEnforcement()
+	}
+	return globalMFAEnforcement
+}
+
+// newMFAEnforcement creates a service that uses the MFA policy stored in db,
+// storing the default policy on first start
+func newMFAEnforcement(db *gorm.DB) (*MFAEnforcement, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var policy models.MFAPolicy
+	err := db.First(&policy).Error
+	if err == gorm.ErrRecordNotFound {
+		policy = DefaultMFAPolicy
+		policy.ChangedAt = time.Now().UTC()
+		err = db.Create(&policy).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MFA policy: %w", err)
+	}
+
+	return &MFAEnforcement{db: db, policy: policy}, nil
+}
+
+// GetPolicy returns the MFA policy
+func (e *MFAEnforcement) GetPolicy() models.MFAPolicy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policy
+}
+
+// UpdatePolicy validates and stores a new MFA policy. The grace period of
+// the new policy starts now.
+func (e *MFAEnforcement) UpdatePolicy(ctx context.Context, policy models.MFAPolicy) (models.MFAPolicy, error) {
+	if policy.GracePeriodDays < 0 {
+		return models.MFAPolicy{}, fmt.Errorf("gracePeriodDays must not be negative")
+	}
+	if policy.RequireForRoles == nil {
+		policy.RequireForRoles = []string{}
+	}
+	for _, role := range policy.RequireForRoles {
+		if strings.TrimSpace(role) == "" {
+			return models.MFAPolicy{}, fmt.Errorf("role names must not be empty")
+		}
+	}
+	if policy.ExemptLocalSubnets == nil {
+		policy.ExemptLocalSubnets = []string{}
+	}
+	for _, subnet := range policy.ExemptLocalSubnets {
+		if _, _, err := net.ParseCIDR(subnet); err != nil && net.ParseIP(subnet) == nil {
+			return models.MFAPolicy{}, fmt.Errorf("invalid local subnet %q: must be an IP address or CIDR range", subnet)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	policy.ID = e.policy.ID
+	policy.ChangedAt = time.Now().UTC()
+	if err := e.db.Save(&policy).Error; err != nil {
+		return models.MFAPolicy{}, fmt.Errorf("failed to save MFA policy: %w", err)
+	}
+	e.policy = policy
+
+	logger.Info("MFA policy updated",
+		zap.Strings("requireForRoles", policy.RequireForRoles),
+		zap.Bool("requireForExternalIPs", policy.RequireForExternalIPs),
+		zap.Int("gracePeriodDays", policy.GracePeriodDays))
+
+	auditService := audit.GetService()
+	if auditService != nil {
+		_ = auditService.Log(ctx, &audit.LogEntry{
+			Username: "system",
+			Action:   "security.mfa_policy_updated",
+			Resource: "auth/mfa-policy",
+			Status:   models.StatusSuccess,
+			Severity: models.SeverityWarning,
+			Message:  "Two-factor authentication policy updated",
+		})
+	}
+
+	return policy, nil
+}
+
+// Check decides whether a login of a user with role from ipAddress must use
+// 2FA. Users who have not enrolled may log in until the grace period after
+// the last policy change ends; then they must set up 2FA first.
+func (e *MFAEnforcement) Check(role, ipAddress string, enrolled bool, now time.Time) MFACheck {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if !e.requires(role, ipAddress) {
+		return MFACheck{Status: MFANotRequired}
+	}
+	if enrolled {
+		return MFACheck{Status: MFASatisfied}
+	}
+
+	graceUntil := e.policy.ChangedAt.AddDate(0, 0, e.policy.GracePeriodDays)
+	if now.Before(graceUntil) {
+		return MFACheck{Status: MFAGracePeriod, GraceUntil: &graceUntil}
+	}
+	return MFACheck{Status: MFASetupRequired}
+}
+
+// requires reports whether the policy requires 2FA for the role or the IP address
+func (e *MFAEnforcement) requires(role, ipAddress string) bool {
+	for _, required := range e.policy.RequireForRoles {
+		if required == role {
+			return true
+		}
+	}
+
+	if !e.policy.RequireForExternalIPs {
+		return false
+	}
+	if ip := parseIP(ipAddress); ip != nil && ip.IsLoopback() {
+		return false
+	}
+	// Unparseable addresses are treated as external
+	return !isWhitelisted(e.policy.ExemptLocalSubnets, ipAddress)
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestMFAEnforcement(t *testing.T) *MFAEnforcement {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "mfa.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.MFAPolicy{}, &models.AuditLog{}, &models.AlertConfig{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { database.DB = nil })
+
+	e, err := newMFAEnforcement(db)
+	if err != nil {
+		t.Fatalf("newMFAEnforcement: %v", err)
+	}
+	return e
+}
+
+func TestMFAGracePeriodExpiryBlocksLogin(t *testing.T) {
+	e := newTestMFAEnforcement(t)
+	if check := e.Check("admin", "203.0.113.5", false, time.Now()); check.Status != MFANotRequired {
+		t.Errorf("default policy check = %+v", check)
+	}
+
+	policy, err := e.UpdatePolicy(context.Background(), models.MFAPolicy{
+		RequireForRoles: []string{"admin"},
+		GracePeriodDays: 3,
+	})
+	if err != nil {
+		t.Fatalf("UpdatePolicy: %v", err)
+	}
+
+	check := e.Check("admin", "192.168.1.10", false, policy.ChangedAt.Add(48*time.Hour))
+	if check.Status != MFAGracePeriod || check.GraceUntil == nil || !check.GraceUntil.Equal(policy.ChangedAt.AddDate(0, 0, 3)) {
+		t.Errorf("check during grace period = %+v", check)
+	}
+	if check := e.Check("admin", "192.168.1.10", false, policy.ChangedAt.Add(73*time.Hour)); check.Status != MFASetupRequired {
+		t.Errorf("check after grace period = %+v", check)
+	}
+	if check := e.Check("admin", "192.168.1.10", true, policy.ChangedAt.Add(73*time.Hour)); check.Status != MFASatisfied {
+		t.Errorf("check of enrolled admin = %+v", check)
+	}
+	if check := e.Check("user", "203.0.113.5", false, policy.ChangedAt.Add(73*time.Hour)); check.Status != MFANotRequired {
+		t.Errorf("check of user = %+v", check)
+	}
+
+	// Changing the policy starts a new grace period
+	policy, err = e.UpdatePolicy(context.Background(), models.MFAPolicy{
+		RequireForRoles: []string{"admin"},
+		GracePeriodDays: 0,
+	})
+	if err != nil {
+		t.Fatalf("UpdatePolicy: %v", err)
+	}
+	if check := e.Check("admin", "192.168.1.10", false, policy.ChangedAt); check.Status != MFASetupRequired {
+		t.Errorf("check without grace period = %+v", check)
+	}
+}
+
+func TestMFARequiredForExternalIPs(t *testing.T) {
+	e := newTestMFAEnforcement(t)
+	policy, err := e.UpdatePolicy(context.Background(), models.MFAPolicy{
+		RequireForExternalIPs: true,
+		ExemptLocalSubnets:    []string{"192.168.0.0/16"},
+	})
+	if err != nil {
+		t.Fatalf("UpdatePolicy: %v", err)
+	}
+
+	now := policy.ChangedAt.Add(time.Minute)
+	for ip, want := range map[string]string{
+		"192.168.1.10:51234": MFANotRequired,
+		"127.0.0.1":          MFANotRequired,
+		"[::1]:8080":         MFANotRequired,
+		"10.0.0.5":           MFASetupRequired,
+		"203.0.113.5:443":    MFASetupRequired,
+		"unknown":            MFASetupRequired,
+	} {
+		if check := e.Check("user", ip, false, now); check.Status != want {
+			t.Errorf("Check(%s) = %s, want %s", ip, check.Status, want)
+		}
+	}
+
+	if _, err := e.UpdatePolicy(context.Background(), models.MFAPolicy{ExemptLocalSubnets: []string{"192.168.0.0/33"}}); err == nil {
+		t.Error("accepted an invalid subnet")
+	}
+	if _, err := e.UpdatePolicy(context.Background(), models.MFAPolicy{GracePeriodDays: -1}); err == nil {
+		t.Error("accepted a negative grace period")
+	}
+}
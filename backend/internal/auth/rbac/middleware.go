@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"fmt"
+	"net/http"
+
+	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/utils"
+)
+
+// RBACMiddleware allows a request only if the authenticated user has the
+// permission for the action on the resource. An empty action is derived
+// from the request method with MethodAction. Until the service is
+// initialized only admins are let through.
+func RBACMiddleware(resource, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := mw.GetUserFromContext(r.Context())
+			if user == nil {
+				utils.RespondError(w, errors.Unauthorized("User not found in context", nil))
+				return
+			}
+
+			act := action
+			if act == "" {
+				act = MethodAction(r.Method)
+			}
+
+			allowed := user.IsAdmin()
+			if s := GetService(); s != nil {
+				var err error
+				if allowed, err = s.HasPermission(user, resource, act); err != nil {
+					utils.RespondError(w, err)
+					return
+				}
+			}
+			if !allowed {
+				utils.RespondError(w, errors.Forbidden(fmt.Sprintf("Permission %s:%s required", resource, act), nil))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission is RBACMiddleware for a fixed action
+func RequirePermission(resource, action string) func(http.Handler) http.Handler {
+	return RBACMiddleware(resource, action)
+}
+
+// RequireAccess is RBACMiddleware with the action derived from the request
+// method, for route groups mixing reads and writes
+func RequireAccess(resource string) func(http.Handler) http.Handler {
+	return RBACMiddleware(resource, "")
+}
@@ -0,0 +1,151 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mw "github.com/Stumpf-works/stumpfworks-nas/internal/api/middleware"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func setupTestService(t *testing.T) *Service {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "rbac.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.RolePermission{}, &models.UserRole{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	s := NewService(db)
+	if err := s.SeedBuiltinRoles(); err != nil {
+		t.Fatalf("seed built-in roles: %v", err)
+	}
+	globalService = s
+	t.Cleanup(func() { globalService = nil })
+	return s
+}
+
+func createUserWithRole(t *testing.T, s *Service, username, roleName string, expiresAt *time.Time) *models.User {
+	t.Helper()
+
+	user := &models.User{Username: username, Email: username + "@example.com", PasswordHash: "x", Role: "user", IsActive: true}
+	if err := s.db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	var role models.Role
+	if err := s.db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		t.Fatalf("find role %s: %v", roleName, err)
+	}
+	// Written directly so already expired assignments can be created
+	if err := s.db.Create(&models.UserRole{UserID: user.ID, RoleID: role.ID, ExpiresAt: expiresAt}).Error; err != nil {
+		t.Fatalf("assign role: %v", err)
+	}
+	return user
+}
+
+// newTestRouter mirrors how NewRouter protects share and network routes
+func newTestRouter(user *models.User) http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), mw.UserContextKey, user)))
+		})
+	})
+	r.With(RequirePermission("share", "create")).Post("/api/v1/storage/shares", ok)
+	r.With(RequirePermission("share", "delete")).Delete("/api/v1/storage/shares/{id}", ok)
+	r.With(RequirePermission("network", "configure")).Post("/api/v1/network/interfaces/{name}/configure", ok)
+	r.With(RequireAccess("network")).Get("/api/v1/network/firewall", ok)
+	r.With(RequireAccess("system")).Get("/api/v1/admin/cache/stats", ok)
+	return r
+}
+
+func TestRBACMiddleware(t *testing.T) {
+	s := setupTestService(t)
+	past := time.Now().Add(-time.Hour)
+
+	storageAdmin := createUserWithRole(t, s, "storage", RoleStorageAdmin, nil)
+	networkAdmin := createUserWithRole(t, s, "network", RoleNetworkAdmin, nil)
+	readOnly := createUserWithRole(t, s, "viewer", RoleReadOnly, nil)
+	expired := createUserWithRole(t, s, "former", RoleStorageAdmin, &past)
+	admin := &models.User{ID: 999, Username: "admin", Role: "admin", IsActive: true}
+
+	tests := []struct {
+		name   string
+		user   *models.User
+		method string
+		path   string
+		want   int
+	}{
+		{"storage admin creates share", storageAdmin, http.MethodPost, "/api/v1/storage/shares", http.StatusOK},
+		{"storage admin deletes share", storageAdmin, http.MethodDelete, "/api/v1/storage/shares/1", http.StatusOK},
+		{"storage admin configures network", storageAdmin, http.MethodPost, "/api/v1/network/interfaces/eth0/configure", http.StatusForbidden},
+		{"storage admin reads firewall", storageAdmin, http.MethodGet, "/api/v1/network/firewall", http.StatusForbidden},
+		{"network admin configures network", networkAdmin, http.MethodPost, "/api/v1/network/interfaces/eth0/configure", http.StatusOK},
+		{"network admin creates share", networkAdmin, http.MethodPost, "/api/v1/storage/shares", http.StatusForbidden},
+		{"read only reads firewall", readOnly, http.MethodGet, "/api/v1/network/firewall", http.StatusOK},
+		{"read only creates share", readOnly, http.MethodPost, "/api/v1/storage/shares", http.StatusForbidden},
+		{"expired role creates share", expired, http.MethodPost, "/api/v1/storage/shares", http.StatusForbidden},
+		{"legacy admin reads admin stats", admin, http.MethodGet, "/api/v1/admin/cache/stats", http.StatusOK},
+		{"no user", nil, http.MethodPost, "/api/v1/storage/shares", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestRouter(tt.user).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d (%s)", tt.method, tt.path, rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBuiltinRolesAreImmutable(t *testing.T) {
+	s := setupTestService(t)
+
+	roles, err := s.ListRoles()
+	if err != nil {
+		t.Fatalf("ListRoles: %v", err)
+	}
+	if len(roles) != len(BuiltinRoles()) {
+		t.Fatalf("got %d roles, want %d", len(roles), len(BuiltinRoles()))
+	}
+
+	id := "1"
+	if _, err := s.SetPermissions(id, []Permission{{"share", "read"}}); err == nil {
+		t.Error("SetPermissions on a built-in role succeeded")
+	}
+	if err := s.DeleteRole(id); err == nil {
+		t.Error("DeleteRole on a built-in role succeeded")
+	}
+
+	// Reseeding must not duplicate roles or permissions
+	if err := s.SeedBuiltinRoles(); err != nil {
+		t.Fatalf("reseed: %v", err)
+	}
+	perms, err := s.GetPermissions(id)
+	if err != nil {
+		t.Fatalf("GetPermissions: %v", err)
+	}
+	if len(perms) != 1 || perms[0] != (Permission{Wildcard, Wildcard}) {
+		t.Errorf("superadmin permissions = %v", perms)
+	}
+}
@@ -0,0 +1,113 @@
+// Package rbac implements role-based access control. Users are granted
+// roles, each role grants a set of permissions, and routes require a
+// permission instead of the admin role.
+package rbac
+
+import "net/http"
+
+// Wildcard matches any resource or action in a permission
+const Wildcard = "*"
+
+// Built-in role names
+const (
+	RoleSuperAdmin     = "superadmin"
+	RoleStorageAdmin   = "storage_admin"
+	RoleNetworkAdmin   = "network_admin"
+	RoleBackupOperator = "backup_operator"
+	RoleReadOnly       = "read_only"
+)
+
+// Permission allows an action on a resource, e.g. {"share", "create"}
+type Permission struct {
+	Resource string `json:"resource" validate:"required"`
+	Action   string `json:"action" validate:"required"`
+}
+
+// Role is a named set of permissions
+type Role struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Matches reports whether the permission covers the resource and action
+func (p Permission) Matches(resource, action string) bool {
+	return (p.Resource == Wildcard || p.Resource == resource) &&
+		(p.Action == Wildcard || p.Action == action)
+}
+
+// Allows reports whether any of the role's permissions covers the resource
+// and action
+func (r Role) Allows(resource, action string) bool {
+	return allows(r.Permissions, resource, action)
+}
+
+func allows(permissions []Permission, resource, action string) bool {
+	for _, p := range permissions {
+		if p.Matches(resource, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltinRoles returns the roles that exist on every installation
+func BuiltinRoles() []Role {
+	return []Role{
+		{
+			Name:        RoleSuperAdmin,
+			Description: "Full access to everything",
+			Permissions: []Permission{{Wildcard, Wildcard}},
+		},
+		{
+			Name:        RoleStorageAdmin,
+			Description: "Manage disks, volumes, shares, quotas and file permissions",
+			Permissions: []Permission{
+				{"storage", Wildcard},
+				{"share", Wildcard},
+				{"quota", Wildcard},
+				{"file", Wildcard},
+			},
+		},
+		{
+			Name:        RoleNetworkAdmin,
+			Description: "Configure networking and high availability",
+			Permissions: []Permission{
+				{"network", Wildcard},
+				{"ha", Wildcard},
+			},
+		},
+		{
+			Name:        RoleBackupOperator,
+			Description: "Run backups and scheduled tasks",
+			Permissions: []Permission{
+				{"backup", Wildcard},
+				{"task", Wildcard},
+				{"storage", "read"},
+				{"share", "read"},
+			},
+		},
+		{
+			Name:        RoleReadOnly,
+			Description: "View everything without making changes",
+			Permissions: []Permission{{Wildcard, "read"}},
+		},
+	}
+}
+
+// MethodAction returns the action a request method performs: read for safe
+// methods, create for POST, update for PUT and PATCH and delete for DELETE
+func MethodAction(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "read"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut, http.MethodPatch:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return method
+	}
+}
@@ -0,0 +1,368 @@
+package rbac
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// CreateRoleRequest represents a request to create a custom role
+type CreateRoleRequest struct {
+	Name        string       `json:"name" validate:"required"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// UpdateRoleRequest represents a request to rename or describe a custom role
+type UpdateRoleRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// AssignRoleRequest represents a request to grant a role to a user
+type AssignRoleRequest struct {
+	RoleID    uint       `json:"roleId" validate:"required"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Service manages roles and evaluates user permissions
+type Service struct {
+	db *gorm.DB
+}
+
+var (
+	globalService *Service
+	once          sync.Once
+)
+
+// Initialize initializes the RBAC service and creates the built-in roles
+func Initialize() (*Service, error) {
+	var initErr error
+	once.Do(func() {
+		db := database.GetDB()
+		if db == nil {
+			initErr = fmt.Errorf("database not initialized")
+			return
+		}
+
+		s := NewService(db)
+		if initErr = s.SeedBuiltinRoles(); initErr != nil {
+			return
+		}
+		globalService = s
+	})
+
+	return globalService, initErr
+}
+
+// GetService returns the global RBAC service, or nil if it has not been
+// initialized
+func GetService() *Service {
+	return globalService
+}
+
+// NewService creates an RBAC service backed by db
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// SeedBuiltinRoles creates the built-in roles and resets their permissions
+// to the current defaults
+func (s *Service) SeedBuiltinRoles() error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, builtin := range BuiltinRoles() {
+			var role models.Role
+			err := tx.Where("name = ?", builtin.Name).First(&role).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+			role.Name = builtin.Name
+			role.Description = builtin.Description
+			role.BuiltIn = true
+			if err := tx.Save(&role).Error; err != nil {
+				return err
+			}
+			if err := replacePermissions(tx, role.ID, builtin.Permissions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListRoles returns all roles with their permissions, built-in roles first
+func (s *Service) ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := s.db.Preload("Permissions").Order("built_in DESC, name").Find(&roles).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to query roles", err)
+	}
+	return roles, nil
+}
+
+// GetRole returns a role with its permissions
+func (s *Service) GetRole(roleID string) (*models.Role, error) {
+	id, err := parseRoleID(roleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.role(id)
+}
+
+// CreateRole creates a custom role
+func (s *Service) CreateRole(req *CreateRoleRequest) (*models.Role, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, errors.BadRequest("Role names must be 2-50 lowercase letters, digits or underscores", nil)
+	}
+	if err := validatePermissions(req.Permissions); err != nil {
+		return nil, err
+	}
+	if err := s.checkNameFree(req.Name, 0); err != nil {
+		return nil, err
+	}
+
+	role := models.Role{Name: req.Name, Description: req.Description}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return replacePermissions(tx, role.ID, req.Permissions)
+	})
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to create role", err)
+	}
+	return s.role(role.ID)
+}
+
+// UpdateRole renames or describes a custom role
+func (s *Service) UpdateRole(roleID string, req *UpdateRoleRequest) (*models.Role, error) {
+	role, err := s.customRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil && *req.Name != role.Name {
+		if !roleNamePattern.MatchString(*req.Name) {
+			return nil, errors.BadRequest("Role names must be 2-50 lowercase letters, digits or underscores", nil)
+		}
+		if err := s.checkNameFree(*req.Name, role.ID); err != nil {
+			return nil, err
+		}
+		role.Name = *req.Name
+	}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+
+	if err := s.db.Omit("Permissions").Save(role).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to update role", err)
+	}
+	return s.role(role.ID)
+}
+
+// DeleteRole deletes a custom role and revokes it from all users
+func (s *Service) DeleteRole(roleID string) error {
+	role, err := s.customRole(roleID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return errors.InternalServerError("Failed to delete role", err)
+	}
+	return nil
+}
+
+// GetPermissions returns the permissions of a role
+func (s *Service) GetPermissions(roleID string) ([]Permission, error) {
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	return toPermissions(role.Permissions), nil
+}
+
+// SetPermissions replaces the permissions of a custom role
+func (s *Service) SetPermissions(roleID string, permissions []Permission) ([]Permission, error) {
+	role, err := s.customRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	if err := replacePermissions(s.db, role.ID, permissions); err != nil {
+		return nil, errors.InternalServerError("Failed to update role permissions", err)
+	}
+	return s.GetPermissions(roleID)
+}
+
+// GetUserRoles returns the roles assigned to a user, including expired ones
+func (s *Service) GetUserRoles(userID uint) ([]models.UserRole, error) {
+	var assignments []models.UserRole
+	err := s.db.Preload("Role.Permissions").Where("user_id = ?", userID).Order("id").Find(&assignments).Error
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to query user roles", err)
+	}
+	return assignments, nil
+}
+
+// AssignRole grants a role to a user. Assigning a role the user already has
+// updates its expiry.
+func (s *Service) AssignRole(userID uint, req *AssignRoleRequest) (*models.UserRole, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.BadRequest("Expiry must be in the future", nil)
+	}
+	if err := s.db.First(&models.User{}, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NotFound("User not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to query user", err)
+	}
+	if _, err := s.role(req.RoleID); err != nil {
+		return nil, err
+	}
+
+	var assignment models.UserRole
+	err := s.db.Where("user_id = ? AND role_id = ?", userID, req.RoleID).First(&assignment).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errors.InternalServerError("Failed to query user roles", err)
+	}
+	assignment.UserID = userID
+	assignment.RoleID = req.RoleID
+	assignment.ExpiresAt = req.ExpiresAt
+	if err := s.db.Omit("Role").Save(&assignment).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to assign role", err)
+	}
+
+	if err := s.db.Preload("Role.Permissions").First(&assignment, assignment.ID).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to query user roles", err)
+	}
+	return &assignment, nil
+}
+
+// RevokeRole removes a role from a user
+func (s *Service) RevokeRole(userID, roleID uint) error {
+	result := s.db.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.UserRole{})
+	if result.Error != nil {
+		return errors.InternalServerError("Failed to revoke role", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFound("Role is not assigned to this user", nil)
+	}
+	return nil
+}
+
+// HasPermission reports whether the user may perform the action on the
+// resource. Users with the legacy admin role have every permission.
+func (s *Service) HasPermission(user *models.User, resource, action string) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if user.IsAdmin() {
+		return true, nil
+	}
+
+	var permissions []models.RolePermission
+	err := s.db.Model(&models.RolePermission{}).
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ? AND (user_roles.expires_at IS NULL OR user_roles.expires_at > ?)", user.ID, time.Now()).
+		Find(&permissions).Error
+	if err != nil {
+		return false, errors.InternalServerError("Failed to query permissions", err)
+	}
+	return allows(toPermissions(permissions), resource, action), nil
+}
+
+// role loads a role with its permissions
+func (s *Service) role(id uint) (*models.Role, error) {
+	var role models.Role
+	if err := s.db.Preload("Permissions").First(&role, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NotFound("Role not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to query role", err)
+	}
+	return &role, nil
+}
+
+// customRole loads a role and refuses built-in roles
+func (s *Service) customRole(roleID string) (*models.Role, error) {
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role.BuiltIn {
+		return nil, errors.Forbidden("Built-in roles cannot be modified", nil)
+	}
+	return role, nil
+}
+
+func (s *Service) checkNameFree(name string, exceptID uint) error {
+	var count int64
+	if err := s.db.Model(&models.Role{}).Where("name = ? AND id <> ?", name, exceptID).Count(&count).Error; err != nil {
+		return errors.InternalServerError("Failed to query roles", err)
+	}
+	if count > 0 {
+		return errors.Conflict(fmt.Sprintf("Role %s already exists", name), nil)
+	}
+	return nil
+}
+
+func replacePermissions(tx *gorm.DB, roleID uint, permissions []Permission) error {
+	if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	rows := make([]models.RolePermission, len(permissions))
+	for i, p := range permissions {
+		rows[i] = models.RolePermission{RoleID: roleID, Resource: p.Resource, Action: p.Action}
+	}
+	return tx.Create(&rows).Error
+}
+
+func validatePermissions(permissions []Permission) error {
+	for _, p := range permissions {
+		if p.Resource == "" || p.Action == "" {
+			return errors.BadRequest("Permissions need a resource and an action", nil)
+		}
+	}
+	return nil
+}
+
+func toPermissions(rows []models.RolePermission) []Permission {
+	permissions := make([]Permission, len(rows))
+	for i, p := range rows {
+		permissions[i] = Permission{Resource: p.Resource, Action: p.Action}
+	}
+	return permissions
+}
+
+func parseRoleID(roleID string) (uint, error) {
+	id, err := strconv.ParseUint(roleID, 10, 32)
+	if err != nil {
+		return 0, errors.BadRequest("Invalid role ID", err)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,721 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/audit"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/usergroups"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// samlRequestLifetime is how long a login started at the SP may take at
+	// the IdP
+	samlRequestLifetime = 10 * time.Minute
+
+	// maxPendingSAMLRequests bounds the logins in progress, since anybody
+	// can start one
+	maxPendingSAMLRequests = 1000
+
+	// maxSAMLMetadataSize bounds the IdP metadata download
+	maxSAMLMetadataSize = 10 << 20
+)
+
+// DefaultSAMLConfig is stored when SAML has not been configured yet
+var DefaultSAMLConfig = models.SAMLConfig{
+	EmailAttribute:       "email",
+	GroupsAttribute:      "groups",
+	AdminGroups:          []string{},
+	DefaultRole:          "user",
+	MetadataRefreshHours: 24,
+}
+
+// SAMLIdentity is the user an IdP asserted
+type SAMLIdentity struct {
+	NameID   string
+	Email    string
+	FullName string
+	Groups   []string
+}
+
+// SAMLProvider is the SAML 2.0 service provider of the NAS. Its Middleware
+// serves the SP metadata, starts logins at the IdP and consumes the
+// assertions the IdP posts back. Users who log in through SAML are
+// authenticated by the IdP, so local 2FA does not apply to them.
+type SAMLProvider struct {
+	IdPMetadataURL       string
+	SPEntityID           string
+	AssertionConsumerURL string
+	CertFile             string
+	KeyFile              string
+
+	config   models.SAMLConfig
+	sp       *saml.ServiceProvider
+	requests *samlRequests
+}
+
+// samlRequests are the pending AuthnRequests of a provider
+type samlRequests struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// NewSAMLProvider creates the service provider for cfg, trusting the IdP
+// described by idp
+func NewSAMLProvider(cfg models.SAMLConfig, idp *saml.EntityDescriptor) (*SAMLProvider, error) {
+	keyPair, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SP certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SP certificate: %w", err)
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("SP key must be an RSA key")
+	}
+
+	acsURL, err := url.Parse(cfg.AssertionConsumerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion consumer URL: %w", err)
+	}
+	metadataURL := acsURL.ResolveReference(&url.URL{Path: "metadata"})
+
+	return &SAMLProvider{
+		IdPMetadataURL:       cfg.IdPMetadataURL,
+		SPEntityID:           cfg.SPEntityID,
+		AssertionConsumerURL: cfg.AssertionConsumerURL,
+		CertFile:             cfg.CertFile,
+		KeyFile:              cfg.KeyFile,
+		config:               cfg,
+		sp: &saml.ServiceProvider{
+			EntityID:          cfg.SPEntityID, // The metadata URL if empty
+			Key:               key,
+			Certificate:       cert,
+			MetadataURL:       *metadataURL,
+			AcsURL:            *acsURL,
+			IDPMetadata:       idp,
+			AllowIDPInitiated: cfg.AllowIdPInitiated,
+		},
+		requests: &samlRequests{expires: make(map[string]time.Time)},
+	}, nil
+}
+
+// Middleware serves the SAML endpoints, selected by the last element of the
+// request path: metadata, login and acs
+func (p *SAMLProvider) Middleware() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "metadata":
+			p.serveMetadata(w)
+		case "login":
+			p.startLogin(w, r)
+		case "acs":
+			p.consumeAssertion(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func (p *SAMLProvider) serveMetadata(w http.ResponseWriter) {
+	buf, err := xml.MarshalIndent(p.sp.Metadata(), "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to generate SAML metadata", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(buf)
+}
+
+// startLogin sends the browser to the IdP with a new AuthnRequest
+func (p *SAMLProvider) startLogin(w http.ResponseWriter, r *http.Request) {
+	binding := saml.HTTPRedirectBinding
+	location := p.sp.GetSSOBindingLocation(binding)
+	if location == "" {
+		binding = saml.HTTPPostBinding
+		location = p.sp.GetSSOBindingLocation(binding)
+	}
+	if location == "" {
+		http.Error(w, "The IdP has no single sign-on endpoint", http.StatusBadGateway)
+		return
+	}
+
+	req, err := p.sp.MakeAuthenticationRequest(location, binding, saml.HTTPPostBinding)
+	if err != nil {
+		http.Error(w, "Failed to create SAML request", http.StatusInternalServerError)
+		return
+	}
+	if !p.requests.track(req.ID, time.Now()) {
+		http.Error(w, "Too many SAML logins in progress", http.StatusServiceUnavailable)
+		return
+	}
+
+	if binding == saml.HTTPRedirectBinding {
+		redirectURL, err := req.Redirect("", p.sp)
+		if err != nil {
+			http.Error(w, "Failed to create SAML request", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, "<!DOCTYPE html><html><body>%s</body></html>", req.Post(""))
+}
+
+// consumeAssertion verifies the IdP response, provisions the user and sends
+// the browser to the web UI with a new session. Errors are reported to the
+// web UI too, since the browser arrives here from the IdP.
+func (p *SAMLProvider) consumeAssertion(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		redirectSSOError(w, r, "Invalid SAML response")
+		return
+	}
+
+	assertion, err := p.sp.ParseResponse(r, p.requests.pending(time.Now()))
+	if err != nil {
+		if invalid, ok := err.(*saml.InvalidResponseError); ok {
+			err = invalid.PrivateErr
+		}
+		logger.Warn("Rejected SAML response", zap.Error(err))
+		redirectSSOError(w, r, "The SAML response was not accepted")
+		return
+	}
+	// A response is only accepted once
+	if assertion.Subject != nil {
+		for _, confirmation := range assertion.Subject.SubjectConfirmations {
+			if confirmation.SubjectConfirmationData != nil {
+				p.requests.forget(confirmation.SubjectConfirmationData.InResponseTo)
+			}
+		}
+	}
+
+	identity, err := p.identity(assertion)
+	if err != nil {
+		redirectSSOError(w, r, err.Error())
+		return
+	}
+
+	user, err := p.provisionUser(identity)
+	if err != nil {
+		logger.Warn("SAML login failed", zap.String("email", identity.Email), zap.Error(err))
+		if appErr, ok := err.(*errors.AppError); ok {
+			redirectSSOError(w, r, appErr.Message)
+		} else {
+			redirectSSOError(w, r, "Failed to sign in")
+		}
+		return
+	}
+
+	accessToken, err := users.GenerateToken(user)
+	if err != nil {
+		redirectSSOError(w, r, "Failed to generate access token")
+		return
+	}
+	refreshToken, err := users.GenerateRefreshToken(user)
+	if err != nil {
+		redirectSSOError(w, r, "Failed to generate refresh token")
+		return
+	}
+
+	auditSAMLLogin(r, user)
+
+	// The tokens travel in the fragment, which the browser does not send to
+	// the server again
+	fragment := url.Values{"accessToken": {accessToken}, "refreshToken": {refreshToken}}
+	http.Redirect(w, r, "/#"+fragment.Encode(), http.StatusSeeOther)
+}
+
+func redirectSSOError(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/#"+url.Values{"ssoError": {message}}.Encode(), http.StatusSeeOther)
+}
+
+// track remembers a pending AuthnRequest. It reports false when too many
+// logins are in progress.
+func (q *samlRequests) track(id string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.expires) >= maxPendingSAMLRequests {
+		for pending, expires := range q.expires {
+			if now.After(expires) {
+				delete(q.expires, pending)
+			}
+		}
+		if len(q.expires) >= maxPendingSAMLRequests {
+			return false
+		}
+	}
+	q.expires[id] = now.Add(samlRequestLifetime)
+	return true
+}
+
+func (q *samlRequests) forget(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.expires, id)
+}
+
+// pending returns the IDs of the AuthnRequests a response may answer
+func (q *samlRequests) pending(now time.Time) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]string, 0, len(q.expires))
+	for id, expires := range q.expires {
+		if now.After(expires) {
+			delete(q.expires, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// identity extracts the user from the assertion attributes. Attributes
+// match the configured names by name or friendly name.
+func (p *SAMLProvider) identity(assertion *saml.Assertion) (*SAMLIdentity, error) {
+	identity := &SAMLIdentity{}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		identity.NameID = assertion.Subject.NameID.Value
+	}
+
+	values := func(names ...string) []string {
+		var result []string
+		for _, statement := range assertion.AttributeStatements {
+			for _, attr := range statement.Attributes {
+				for _, name := range names {
+					if strings.EqualFold(attr.Name, name) || strings.EqualFold(attr.FriendlyName, name) {
+						for _, value := range attr.Values {
+							if v := strings.TrimSpace(value.Value); v != "" {
+								result = append(result, v)
+							}
+						}
+						break
+					}
+				}
+			}
+		}
+		return result
+	}
+
+	if emails := values(p.config.EmailAttribute); len(emails) > 0 {
+		identity.Email = emails[0]
+	} else if strings.Contains(identity.NameID, "@") {
+		identity.Email = identity.NameID
+	}
+	if identity.Email == "" {
+		return nil, fmt.Errorf("the IdP did not send an email address")
+	}
+	identity.Groups = values(p.config.GroupsAttribute)
+	if names := values("displayName", "cn", "name"); len(names) > 0 {
+		identity.FullName = names[0]
+	}
+	return identity, nil
+}
+
+// role returns the local role of a user in the IdP groups
+func (p *SAMLProvider) role(groups []string) string {
+	for _, group := range groups {
+		for _, admin := range p.config.AdminGroups {
+			if strings.EqualFold(group, admin) {
+				return "admin"
+			}
+		}
+	}
+	return p.config.DefaultRole
+}
+
+// provisionUser finds the local user with the asserted email or creates
+// one. The role follows the IdP groups on every login; local groups named
+// like an IdP group gain the user as member.
+func (p *SAMLProvider) provisionUser(identity *SAMLIdentity) (*users.User, error) {
+	role := p.role(identity.Groups)
+
+	var user users.User
+	err := database.DB.Where("LOWER(email) = ?", strings.ToLower(identity.Email)).First(&user).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		username, err := samlUsername(identity.Email)
+		if err != nil {
+			return nil, err
+		}
+		created, err := users.CreateUser(&users.CreateUserRequest{
+			Username: username,
+			Email:    identity.Email,
+			Password: randomPassword(), // Logins go through the IdP
+			FullName: identity.FullName,
+			Role:     role,
+		})
+		if err != nil {
+			return nil, err
+		}
+		user = *created
+		logger.Info("Created user from SAML login", zap.String("username", user.Username), zap.String("role", role))
+	case err != nil:
+		return nil, errors.InternalServerError("Failed to query user", err)
+	default:
+		if !user.IsActive {
+			return nil, errors.Forbidden("Account is disabled", nil)
+		}
+		updates := map[string]interface{}{"role": role}
+		if identity.FullName != "" {
+			updates["full_name"] = identity.FullName
+		}
+		if err := database.DB.Model(&user).Updates(updates).Error; err != nil {
+			return nil, errors.InternalServerError("Failed to update user", err)
+		}
+	}
+
+	for _, name := range identity.Groups {
+		group, err := usergroups.GetGroupByName(name)
+		if err != nil {
+			continue // Only groups that exist locally are synced
+		}
+		err = usergroups.AddMember(group.ID, user.ID)
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code == http.StatusConflict {
+			continue // Already a member
+		}
+		if err != nil {
+			logger.Warn("Failed to add SAML user to group",
+				zap.String("username", user.Username), zap.String("group", name), zap.Error(err))
+		}
+	}
+
+	if err := user.UpdateLastLogin(database.DB); err != nil {
+		logger.Warn("Failed to update last login", zap.String("username", user.Username), zap.Error(err))
+	}
+	return &user, nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// samlUsername derives a free username from the local part of email
+func samlUsername(email string) (string, error) {
+	base := strings.ToLower(email)
+	if i := strings.Index(base, "@"); i >= 0 {
+		base = base[:i]
+	}
+	base = strings.Trim(usernameInvalidChars.ReplaceAllString(base, ""), ".-")
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	if len(base) > 90 {
+		base = base[:90]
+	}
+
+	username := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := database.DB.Model(&users.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return "", errors.InternalServerError("Failed to query user", err)
+		}
+		if count == 0 {
+			return username, nil
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.Conflict("No free username for "+email, nil)
+}
+
+func randomPassword() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func auditSAMLLogin(r *http.Request, user *users.User) {
+	auditService := audit.GetService()
+	if auditService == nil {
+		return
+	}
+	ip := r.RemoteAddr
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		ip = strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	userID := user.ID
+	if err := auditService.Log(r.Context(), &audit.LogEntry{
+		UserID:    &userID,
+		Username:  user.Username,
+		Action:    models.ActionAuthLogin,
+		Resource:  "auth/saml",
+		Status:    models.StatusSuccess,
+		Severity:  models.SeverityInfo,
+		IPAddress: ip,
+		UserAgent: r.UserAgent(),
+		Message:   "User " + user.Username + " logged in via SAML",
+	}); err != nil {
+		logger.Error("Failed to audit login", zap.String("username", user.Username), zap.Error(err))
+	}
+}
+
+// SAMLService stores the SAML configuration and keeps the IdP metadata
+// fresh
+type SAMLService struct {
+	db     *gorm.DB
+	client *http.Client
+
+	mu       sync.RWMutex
+	config   models.SAMLConfig
+	provider *SAMLProvider // nil while SAML is disabled
+}
+
+var (
+	globalSAMLService *SAMLService
+	samlServiceOnce   sync.Once
+)
+
+// InitializeSAML initializes the SAML service
+func InitializeSAML() (*SAMLService, error) {
+	var initErr error
+	samlServiceOnce.Do(func() {
+		globalSAMLService, initErr = newSAMLService(database.GetDB(), &http.Client{Timeout: 30 * time.Second})
+	})
+
+	return globalSAMLService, initErr
+}
+
+// GetSAMLService returns the global SAML service
+func GetSAMLService() *SAMLService {
+	return globalSAMLService
+}
+
+// newSAMLService creates a service that uses the SAML configuration stored
+// in db. An enabled configuration starts with the stored IdP metadata; Start
+// refreshes it.
+func newSAMLService(db *gorm.DB, client *http.Client) (*SAMLService, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var cfg models.SAMLConfig
+	err := db.First(&cfg).Error
+	if err == gorm.ErrRecordNotFound {
+		cfg = DefaultSAMLConfig
+		err = db.Create(&cfg).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SAML config: %w", err)
+	}
+
+	s := &SAMLService{db: db, client: client, config: cfg}
+	if cfg.Enabled && cfg.IdPMetadata != "" {
+		idp, err := samlsp.ParseMetadata([]byte(cfg.IdPMetadata))
+		if err == nil {
+			s.provider, err = NewSAMLProvider(cfg, idp)
+		}
+		if err != nil {
+			logger.Warn("SAML single sign-on is unavailable", zap.Error(err))
+		}
+	}
+	return s, nil
+}
+
+// GetConfig returns the SAML configuration
+func (s *SAMLService) GetConfig() models.SAMLConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Provider returns the service provider, or an error if SAML is disabled
+func (s *SAMLService) Provider() (*SAMLProvider, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.provider == nil {
+		return nil, fmt.Errorf("SAML single sign-on is not enabled")
+	}
+	return s.provider, nil
+}
+
+// UpdateConfig validates and stores a new SAML configuration. Enabling SAML
+// fetches the IdP metadata, so the IdP must be reachable.
+func (s *SAMLService) UpdateConfig(ctx context.Context, cfg models.SAMLConfig) (models.SAMLConfig, error) {
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = DefaultSAMLConfig.EmailAttribute
+	}
+	if cfg.GroupsAttribute == "" {
+		cfg.GroupsAttribute = DefaultSAMLConfig.GroupsAttribute
+	}
+	if cfg.AdminGroups == nil {
+		cfg.AdminGroups = []string{}
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = DefaultSAMLConfig.DefaultRole
+	}
+	if cfg.DefaultRole != "user" && cfg.DefaultRole != "guest" {
+		return models.SAMLConfig{}, fmt.Errorf("defaultRole must be user or guest")
+	}
+	if cfg.MetadataRefreshHours == 0 {
+		cfg.MetadataRefreshHours = DefaultSAMLConfig.MetadataRefreshHours
+	}
+	if cfg.MetadataRefreshHours < 1 || cfg.MetadataRefreshHours > 24*30 {
+		return models.SAMLConfig{}, fmt.Errorf("metadataRefreshHours must be between 1 and 720")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg.ID = s.config.ID
+	cfg.IdPMetadata = s.config.IdPMetadata
+	cfg.MetadataFetchedAt = s.config.MetadataFetchedAt
+
+	var provider *SAMLProvider
+	if cfg.Enabled {
+		for name, value := range map[string]string{"idpMetadataUrl": cfg.IdPMetadataURL, "assertionConsumerUrl": cfg.AssertionConsumerURL} {
+			if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return models.SAMLConfig{}, fmt.Errorf("%s must be an http or https URL", name)
+			}
+		}
+
+		raw, idp, err := s.fetchMetadata(ctx, cfg.IdPMetadataURL)
+		if err != nil {
+			return models.SAMLConfig{}, err
+		}
+		if provider, err = NewSAMLProvider(cfg, idp); err != nil {
+			return models.SAMLConfig{}, err
+		}
+		now := time.Now().UTC()
+		cfg.IdPMetadata = string(raw)
+		cfg.MetadataFetchedAt = &now
+	}
+
+	if err := s.db.Save(&cfg).Error; err != nil {
+		return models.SAMLConfig{}, fmt.Errorf("failed to save SAML config: %w", err)
+	}
+	s.config = cfg
+	s.provider = provider
+
+	logger.Info("SAML config updated", zap.Bool("enabled", cfg.Enabled), zap.String("idp", cfg.IdPMetadataURL))
+
+	auditService := audit.GetService()
+	if auditService != nil {
+		_ = auditService.Log(ctx, &audit.LogEntry{
+			Username: "system",
+			Action:   "security.saml_config_updated",
+			Resource: "auth/saml",
+			Status:   models.StatusSuccess,
+			Severity: models.SeverityWarning,
+			Message:  "SAML single sign-on configuration updated",
+		})
+	}
+
+	return cfg, nil
+}
+
+// fetchMetadata downloads and parses the IdP metadata
+func (s *SAMLService) fetchMetadata(ctx context.Context, metadataURL string) ([]byte, *saml.EntityDescriptor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid IdP metadata URL: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch IdP metadata: %s", resp.Status)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxSAMLMetadataSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+	}
+	idp, err := samlsp.ParseMetadata(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid IdP metadata: %w", err)
+	}
+	return raw, idp, nil
+}
+
+// RefreshMetadata fetches the IdP metadata again, e.g. after the IdP
+// rotated its signing certificate. On failure the previous metadata stays
+// in use.
+func (s *SAMLService) RefreshMetadata(ctx context.Context) error {
+	cfg := s.GetConfig()
+	if !cfg.Enabled {
+		return nil
+	}
+
+	raw, idp, err := s.fetchMetadata(ctx, cfg.IdPMetadataURL)
+	if err != nil {
+		return err
+	}
+	provider, err := NewSAMLProvider(cfg, idp)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.UpdatedAt != cfg.UpdatedAt {
+		return nil // Reconfigured meanwhile
+	}
+	// Logins started with the old provider can still finish
+	if s.provider != nil {
+		provider.requests = s.provider.requests
+	}
+
+	now := time.Now().UTC()
+	if err := s.db.Model(&s.config).UpdateColumns(map[string]interface{}{
+		"idp_metadata":        string(raw),
+		"metadata_fetched_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to save IdP metadata: %w", err)
+	}
+	s.config.IdPMetadata = string(raw)
+	s.config.MetadataFetchedAt = &now
+	s.provider = provider
+	return nil
+}
+
+// Start refreshes the IdP metadata on its schedule until ctx is done
+func (s *SAMLService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			cfg := s.GetConfig()
+			due := cfg.MetadataFetchedAt == nil ||
+				time.Since(*cfg.MetadataFetchedAt) >= time.Duration(cfg.MetadataRefreshHours)*time.Hour
+			if cfg.Enabled && due {
+				if err := s.RefreshMetadata(ctx); err != nil {
+					logger.Warn("Failed to refresh IdP metadata", zap.Error(err))
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"html"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/crewjam/saml"
+	samllogger "github.com/crewjam/saml/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// newTestKeyPair creates a self-signed certificate and its key
+func newTestKeyPair(t *testing.T, name string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+type testSessionProvider struct{ session *saml.Session }
+
+func (p testSessionProvider) GetSession(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) *saml.Session {
+	return p.session
+}
+
+type testServiceProviderProvider struct{ service *SAMLService }
+
+func (p testServiceProviderProvider) GetServiceProvider(r *http.Request, id string) (*saml.EntityDescriptor, error) {
+	provider, err := p.service.Provider()
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	return provider.sp.Metadata(), nil
+}
+
+var samlResponseField = regexp.MustCompile(`name="SAMLResponse" value="([^"]+)"`)
+
+func TestSAMLLoginFlow(t *testing.T) {
+	logger.InitLogger("error", false)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "saml.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserGroup{}, &models.SAMLConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.UserGroup{Name: "engineering"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	realConfig := config.GlobalConfig
+	config.GlobalConfig = &config.Config{Auth: config.AuthConfig{
+		JWTSecret:          "test-secret-key-for-jwt-testing-minimum-32-characters-long",
+		JWTExpirationHours: 1,
+		JWTRefreshHours:    24,
+	}}
+	t.Cleanup(func() {
+		database.DB = nil
+		config.GlobalConfig = realConfig
+	})
+
+	// The SP key pair is read from files like in production
+	spKey, spCert := newTestKeyPair(t, "nas.example.com")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "sp.crt"), filepath.Join(dir, "sp.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: spCert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(spKey)}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := newSAMLService(db, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider, err := service.Provider()
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		provider.Middleware().ServeHTTP(w, r)
+	}))
+	defer sp.Close()
+
+	idpKey, idpCert := newTestKeyPair(t, "idp.example.com")
+	idpServer := httptest.NewUnstartedServer(nil)
+	idpServer.Start()
+	defer idpServer.Close()
+	metadataURL, _ := url.Parse(idpServer.URL + "/metadata")
+	ssoURL, _ := url.Parse(idpServer.URL + "/sso")
+	idp := &saml.IdentityProvider{
+		Key:         idpKey,
+		Certificate: idpCert,
+		Logger:      samllogger.DefaultLogger,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+		SessionProvider: testSessionProvider{session: &saml.Session{
+			ID:         "session-1",
+			CreateTime: time.Now(),
+			ExpireTime: time.Now().Add(time.Hour),
+			NameID:     "jdoe@example.com",
+			CustomAttributes: []saml.Attribute{
+				{Name: "email", Values: []saml.AttributeValue{{Type: "xs:string", Value: "jdoe@example.com"}}},
+				{Name: "groups", Values: []saml.AttributeValue{
+					{Type: "xs:string", Value: "nas-admins"},
+					{Type: "xs:string", Value: "engineering"},
+				}},
+			},
+		}},
+		ServiceProviderProvider: testServiceProviderProvider{service: service},
+	}
+	idpServer.Config.Handler = idp.Handler()
+
+	if _, err := service.UpdateConfig(context.Background(), models.SAMLConfig{
+		Enabled:              true,
+		IdPMetadataURL:       metadataURL.String(),
+		AssertionConsumerURL: sp.URL + "/api/v1/auth/saml/acs",
+		CertFile:             certFile,
+		KeyFile:              keyFile,
+		AdminGroups:          []string{"nas-admins"},
+	}); err != nil {
+		t.Fatalf("UpdateConfig: %v", err)
+	}
+	if stored := service.GetConfig(); stored.IdPMetadata == "" || stored.MetadataFetchedAt == nil {
+		t.Error("IdP metadata was not stored")
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := browser.Get(sp.URL + "/api/v1/auth/saml/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/samlmetadata+xml" {
+		t.Errorf("metadata: %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	// The login redirects to the IdP, which posts the signed response back
+	// through the browser
+	resp, err = browser.Get(sp.URL + "/api/v1/auth/saml/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("login: %s, want a redirect to the IdP", resp.Status)
+	}
+	resp, err = browser.Get(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := samlResponseField.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("IdP did not return a SAML response: %s", body)
+	}
+	samlResponse := html.UnescapeString(string(match[1]))
+
+	acs := func() *url.URL {
+		t.Helper()
+		resp, err := browser.PostForm(sp.URL+"/api/v1/auth/saml/acs", url.Values{"SAMLResponse": {samlResponse}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		location, err := url.Parse(resp.Header.Get("Location"))
+		if resp.StatusCode != http.StatusSeeOther || err != nil {
+			t.Fatalf("acs: %s to %q", resp.Status, resp.Header.Get("Location"))
+		}
+		return location
+	}
+
+	fragment, _ := url.ParseQuery(acs().Fragment)
+	claims, err := users.ValidateToken(fragment.Get("accessToken"))
+	if err != nil {
+		t.Fatalf("acs did not issue a valid access token (fragment %v): %v", fragment, err)
+	}
+	if fragment.Get("refreshToken") == "" {
+		t.Error("acs did not issue a refresh token")
+	}
+
+	var user models.User
+	if err := db.First(&user, claims.UserID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var group models.UserGroup
+	if err := db.Preload("Members").Where("name = ?", "engineering").First(&group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "jdoe" || user.Email != "jdoe@example.com" || user.Role != "admin" {
+		t.Errorf("provisioned user = %s <%s> role %s, want jdoe <jdoe@example.com> role admin", user.Username, user.Email, user.Role)
+	}
+	if len(group.Members) != 1 || group.Members[0].ID != user.ID {
+		t.Errorf("engineering members = %+v, want the provisioned user", group.Members)
+	}
+
+	// A response is only accepted once
+	fragment, _ = url.ParseQuery(acs().Fragment)
+	if fragment.Get("ssoError") == "" || fragment.Get("accessToken") != "" {
+		t.Errorf("replayed response was accepted: %v", fragment)
+	}
+}
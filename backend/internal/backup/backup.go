@@ -7,8 +7,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 )
 
 // BackupJob represents a backup job configuration
@@ -77,8 +83,18 @@ var (
 
 const (
 	DefaultBackupDir = "/var/lib/stumpfworks/backups"
+
+	// progressInterval is the minimum time between progress events
+	progressInterval = time.Second
 )
 
+// rsyncProgress matches an rsync --info=progress2 line, e.g.
+// "1,234,567  45%   12.34MB/s    0:00:10 (xfr#3, to-chk=10/20)"
+var rsyncProgress = regexp.MustCompile(`^([\d,]+)\s+(\d{1,3})%\s+(\S+/s)\s+(\d+):(\d{2}):(\d{2})(?:\s+\(xfr#(\d+))?`)
+
+// Replaced in tests
+var runRsync = sysutil.RunCommandWithStdoutProgress
+
 // Initialize initializes the backup service
 func Initialize(backupDir string) (*Service, error) {
 	var err error
@@ -298,6 +314,7 @@ func (s *Service) RunJob(ctx context.Context, id string) (*BackupHistory, error)
 		StartTime: now,
 		Status:    "running",
 	}
+	publishRunEvent(history, "started", events.SeverityInfo, "Backup job "+job.Name+" started", nil)
 
 	// Execute backup
 	err := s.executeBackup(ctx, job, history)
@@ -314,9 +331,13 @@ func (s *Service) RunJob(ctx context.Context, id string) (*BackupHistory, error)
 		job.Status = "failed"
 		history.Status = "failed"
 		history.Error = err.Error()
+		publishRunEvent(history, "failed", events.SeverityWarning, "Backup job "+job.Name+" failed: "+err.Error(), nil)
 	} else {
 		job.Status = "success"
 		history.Status = "success"
+		publishRunEvent(history, "completed", events.SeverityInfo, "Backup job "+job.Name+" completed", map[string]interface{}{
+			"percent": 100,
+		})
 	}
 
 	job.UpdatedAt = time.Now()
@@ -337,8 +358,9 @@ func (s *Service) executeBackup(ctx context.Context, job *BackupJob, history *Ba
 
 	history.BackupPath = backupPath
 
-	// Build rsync command for backup
-	args := []string{"-av"}
+	// Build rsync command for backup. -v lists the files as they are
+	// copied and --info=progress2 reports the progress of the whole run.
+	args := []string{"-av", "--info=progress2"}
 
 	if job.Compression {
 		args = append(args, "-z")
@@ -346,20 +368,76 @@ func (s *Service) executeBackup(ctx context.Context, job *BackupJob, history *Ba
 
 	args = append(args, job.Source, backupPath+"/")
 
-	cmd := exec.CommandContext(ctx, "rsync", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("backup failed: %w, output: %s", err, string(output))
-	}
+	var file string
+	var lastEvent time.Time
+	output, err := runRsync(ctx, func(line string) {
+		m := rsyncProgress.FindStringSubmatch(line)
+		if m == nil {
+			if isRsyncFileLine(line) {
+				file = line
+			}
+			return
+		}
 
-	// Get backup size
-	if info, err := os.Stat(backupPath); err == nil {
-		history.BytesBackup = info.Size()
+		history.BytesBackup, _ = strconv.ParseInt(strings.ReplaceAll(m[1], ",", ""), 10, 64)
+		if m[7] != "" {
+			history.FilesBackup, _ = strconv.Atoi(m[7])
+		}
+		if time.Since(lastEvent) < progressInterval {
+			return
+		}
+		lastEvent = time.Now()
+
+		percent, _ := strconv.Atoi(m[2])
+		hours, _ := strconv.Atoi(m[4])
+		minutes, _ := strconv.Atoi(m[5])
+		seconds, _ := strconv.Atoi(m[6])
+		publishRunEvent(history, "progress", events.SeverityInfo, "Backup job "+job.Name+" running", map[string]interface{}{
+			"percent":    percent,
+			"rate":       m[3],
+			"etaSeconds": hours*3600 + minutes*60 + seconds,
+			"file":       file,
+		})
+	}, "rsync", args...)
+	if err != nil {
+		return fmt.Errorf("backup failed: %w, output: %s", err, output)
 	}
 
 	return nil
 }
 
+// isRsyncFileLine reports whether a line of rsync -v output names a
+// copied file, rather than a directory or the summary around the list
+func isRsyncFileLine(line string) bool {
+	return !strings.HasSuffix(line, "/") &&
+		!strings.HasPrefix(line, "sending incremental file list") &&
+		!strings.HasPrefix(line, "sent ") &&
+		!strings.HasPrefix(line, "total size is ")
+}
+
+// publishRunEvent publishes an events.TypeBackup event for a backup run,
+// carrying the run's IDs and progress so far next to data
+func publishRunEvent(history *BackupHistory, action, severity, message string, data map[string]interface{}) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["jobId"] = history.JobID
+	data["historyId"] = history.ID
+	data["bytesDone"] = history.BytesBackup
+	data["filesDone"] = history.FilesBackup
+	if history.Error != "" {
+		data["error"] = history.Error
+	}
+	events.Publish(events.Event{
+		Type:     events.TypeBackup,
+		Action:   action,
+		Severity: severity,
+		Source:   "backup",
+		Message:  message,
+		Data:     data,
+	})
+}
+
 // GetHistory returns backup history
 func (s *Service) GetHistory(ctx context.Context, jobID string, limit int) ([]*BackupHistory, error) {
 	s.mu.RLock()
@@ -0,0 +1,353 @@
+// Package cloudbackup manages the rclone remotes cloud backup jobs upload to
+package cloudbackup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// MaskedValue replaces sensitive remote parameters in responses. Sending it
+// back in an update keeps the stored value.
+const MaskedValue = "********"
+
+// remoteTestTimeout limits how long TestRemote waits for a remote to answer
+const remoteTestTimeout = 30 * time.Second
+
+var (
+	// ErrRemoteExists is returned when adding a remote whose name is taken
+	ErrRemoteExists = errors.New("rclone remote already exists")
+	// ErrRemoteNotFound is returned for unknown remotes
+	ErrRemoteNotFound = errors.New("rclone remote not found")
+)
+
+var (
+	// Remote names may not start with a dash, so they are never taken for a flag
+	remoteNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+@-]{0,63}$`)
+	remoteTypePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+	paramKeyPattern   = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// sensitiveParams are the remote parameters that hold credentials
+var sensitiveParams = map[string]bool{
+	"password":          true,
+	"password2":         true,
+	"pass":              true,
+	"client_secret":     true,
+	"secret_access_key": true,
+	"session_token":     true,
+	"token":             true,
+	"key":               true,
+	"account_key":       true,
+	"sas_url":           true,
+}
+
+// RcloneRemote is a remote in the rclone config. Sensitive parameters are
+// masked with MaskedValue.
+type RcloneRemote struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"` // e.g. s3, b2, sftp, drive
+	Params map[string]string `json:"params"`
+}
+
+// RemoteTestResult is the outcome of a connection test of a remote
+type RemoteTestResult struct {
+	Name     string `json:"name"`
+	Success  bool   `json:"success"`
+	Duration int64  `json:"duration"`        // Milliseconds
+	Error    string `json:"error,omitempty"` // rclone's message if the test failed
+}
+
+// ValidateRemote checks the name, type and parameter keys of a remote
+func ValidateRemote(name, remoteType string, params map[string]string) error {
+	if !remoteNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid remote name %q", name)
+	}
+	if !remoteTypePattern.MatchString(remoteType) {
+		return fmt.Errorf("invalid remote type %q", remoteType)
+	}
+	for key := range params {
+		if !paramKeyPattern.MatchString(key) || key == "type" {
+			return fmt.Errorf("invalid parameter %q", key)
+		}
+	}
+	return nil
+}
+
+// RcloneConfigManager manages the remotes in the rclone config of the
+// server, so they no longer need to be added to rclone.conf by hand. The
+// names of the remotes are recorded in models.CloudBackupRemote for the
+// backup jobs.
+type RcloneConfigManager struct {
+	db    *gorm.DB
+	shell executor.ShellExecutor
+	mu    sync.Mutex
+}
+
+// NewRcloneConfigManager creates an rclone config manager
+func NewRcloneConfigManager(db *gorm.DB, shell executor.ShellExecutor) (*RcloneConfigManager, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if !shell.CommandExists("rclone") {
+		return nil, fmt.Errorf("rclone not installed (install 'rclone' package)")
+	}
+	return &RcloneConfigManager{db: db, shell: shell}, nil
+}
+
+// ListRemotes returns the configured remotes by name
+func (m *RcloneConfigManager) ListRemotes() ([]RcloneRemote, error) {
+	dump, err := m.dump()
+	if err != nil {
+		return nil, err
+	}
+
+	remotes := make([]RcloneRemote, 0, len(dump))
+	for name, params := range dump {
+		remotes = append(remotes, remote(name, params))
+	}
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	return remotes, nil
+}
+
+// GetRemote returns a remote
+func (m *RcloneConfigManager) GetRemote(name string) (*RcloneRemote, error) {
+	dump, err := m.dump()
+	if err != nil {
+		return nil, err
+	}
+	params, ok := dump[name]
+	if !ok {
+		return nil, ErrRemoteNotFound
+	}
+	r := remote(name, params)
+	return &r, nil
+}
+
+// AddRemote creates a remote. rclone obscures passwords itself.
+func (m *RcloneConfigManager) AddRemote(name string, remoteType string, params map[string]string) error {
+	if err := ValidateRemote(name, remoteType, params); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dump, err := m.dump()
+	if err != nil {
+		return err
+	}
+	if _, ok := dump[name]; ok {
+		return ErrRemoteExists
+	}
+
+	args := append([]string{"config", "create", name, remoteType}, paramArgs(params)...)
+	if err := m.rclone(append(args, "--non-interactive")...); err != nil {
+		return err
+	}
+	record := models.CloudBackupRemote{Name: name, Type: remoteType}
+	if err := m.db.Save(&record).Error; err != nil {
+		m.rclone("config", "delete", name)
+		return fmt.Errorf("failed to save remote: %w", err)
+	}
+
+	logger.Info("rclone remote created", zap.String("name", name), zap.String("type", remoteType))
+	return nil
+}
+
+// UpdateRemote changes parameters of a remote. Parameters set to
+// MaskedValue keep their stored value.
+func (m *RcloneConfigManager) UpdateRemote(name string, params map[string]string) error {
+	changed := make(map[string]string, len(params))
+	for key, value := range params {
+		if value != MaskedValue {
+			changed[key] = value
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dump, err := m.dump()
+	if err != nil {
+		return err
+	}
+	current, ok := dump[name]
+	if !ok {
+		return ErrRemoteNotFound
+	}
+	if err := ValidateRemote(name, current["type"], changed); err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	args := append([]string{"config", "update", name}, paramArgs(changed)...)
+	if err := m.rclone(append(args, "--non-interactive")...); err != nil {
+		return err
+	}
+
+	logger.Info("rclone remote updated", zap.String("name", name))
+	return nil
+}
+
+// RemoveRemote deletes a remote
+func (m *RcloneConfigManager) RemoveRemote(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dump, err := m.dump()
+	if err != nil {
+		return err
+	}
+	if _, ok := dump[name]; !ok {
+		return ErrRemoteNotFound
+	}
+
+	if err := m.rclone("config", "delete", name); err != nil {
+		return err
+	}
+	if err := m.db.Delete(&models.CloudBackupRemote{}, "name = ?", name).Error; err != nil {
+		return fmt.Errorf("failed to delete remote: %w", err)
+	}
+
+	logger.Info("rclone remote deleted", zap.String("name", name))
+	return nil
+}
+
+// TestRemote checks that a remote can be reached with its credentials by
+// listing its top level. A failed connection is reported in the result,
+// not as an error.
+func (m *RcloneConfigManager) TestRemote(name string) (*RemoteTestResult, error) {
+	dump, err := m.dump()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := dump[name]; !ok {
+		return nil, ErrRemoteNotFound
+	}
+
+	start := time.Now()
+	result, err := m.shell.ExecuteWithTimeout(remoteTestTimeout, "rclone", "lsd", name+":", "--max-depth", "0")
+	test := &RemoteTestResult{Name: name, Success: err == nil, Duration: time.Since(start).Milliseconds()}
+	if err != nil {
+		test.Error = commandError(result, err)
+	}
+	return test, nil
+}
+
+// SyncRemotes records the remotes in the rclone config and drops the
+// records of remotes that were removed from it by hand
+func (m *RcloneConfigManager) SyncRemotes() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dump, err := m.dump()
+	if err != nil {
+		return err
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		names := make([]string, 0, len(dump))
+		for name, params := range dump {
+			names = append(names, name)
+			record := models.CloudBackupRemote{Name: name, Type: params["type"]}
+			if err := tx.Save(&record).Error; err != nil {
+				return fmt.Errorf("failed to save remote %s: %w", name, err)
+			}
+		}
+		query := tx.Model(&models.CloudBackupRemote{})
+		if len(names) > 0 {
+			query = query.Where("name NOT IN ?", names)
+		} else {
+			query = query.Where("1 = 1")
+		}
+		if err := query.Delete(&models.CloudBackupRemote{}).Error; err != nil {
+			return fmt.Errorf("failed to delete removed remotes: %w", err)
+		}
+		return nil
+	})
+}
+
+// dump returns the parameters of the remotes by name, from rclone config
+// dump, which prints the config as JSON
+func (m *RcloneConfigManager) dump() (map[string]map[string]string, error) {
+	result, err := m.shell.Execute("rclone", "config", "dump")
+	if err != nil {
+		return nil, fmt.Errorf("rclone config dump failed: %s", commandError(result, err))
+	}
+	return parseConfigDump(result.Stdout)
+}
+
+// rclone runs an rclone command that prints nothing of interest
+func (m *RcloneConfigManager) rclone(args ...string) error {
+	result, err := m.shell.Execute("rclone", args...)
+	if err != nil {
+		return fmt.Errorf("rclone %s %s failed: %s", args[0], args[1], commandError(result, err))
+	}
+	return nil
+}
+
+// parseConfigDump parses the output of rclone config dump
+func parseConfigDump(output string) (map[string]map[string]string, error) {
+	dump := map[string]map[string]string{}
+	if strings.TrimSpace(output) == "" {
+		return dump, nil
+	}
+	if err := json.Unmarshal([]byte(output), &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse rclone config dump: %w", err)
+	}
+	return dump, nil
+}
+
+// remote builds an RcloneRemote from its dumped parameters, masking the
+// sensitive ones
+func remote(name string, params map[string]string) RcloneRemote {
+	r := RcloneRemote{Name: name, Type: params["type"], Params: make(map[string]string, len(params))}
+	for key, value := range params {
+		switch {
+		case key == "type":
+		case sensitiveParams[key] && value != "":
+			r.Params[key] = MaskedValue
+		default:
+			r.Params[key] = value
+		}
+	}
+	return r
+}
+
+// paramArgs returns the parameters as key=value arguments sorted by key.
+// Unlike separate key and value arguments, a value starting with a dash
+// cannot be taken for a flag.
+func paramArgs(params map[string]string) []string {
+	args := make([]string, 0, len(params))
+	for key, value := range params {
+		args = append(args, key+"="+value)
+	}
+	sort.Strings(args)
+	return args
+}
+
+// commandError returns the last line rclone printed to stderr, which holds
+// the reason it failed, or err if it printed nothing
+func commandError(result *executor.CommandResult, err error) string {
+	if result != nil {
+		lines := strings.Split(strings.TrimSpace(result.Stderr), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			return last
+		}
+	}
+	return err.Error()
+}
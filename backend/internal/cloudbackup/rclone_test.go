@@ -0,0 +1,263 @@
+package cloudbackup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// configDump is the output of rclone config dump
+const configDump = `{
+    "b2-offsite": {
+        "account": "0012ab34cd56",
+        "key": "K001xyzSecretApplicationKey",
+        "type": "b2"
+    },
+    "gdrive": {
+        "client_id": "12345.apps.googleusercontent.com",
+        "client_secret": "GOCSPX-secret",
+        "scope": "drive",
+        "token": "{\"access_token\":\"ya29.a0\",\"expiry\":\"2026-10-18T12:00:00Z\"}",
+        "type": "drive"
+    },
+    "nas2": {
+        "host": "nas2.example.com",
+        "pass": "",
+        "type": "sftp",
+        "user": "backup"
+    }
+}
+`
+
+// newTestRcloneConfigManager returns a manager whose rclone fakes a config
+// holding remotes. config create, update and delete change remotes.
+func newTestRcloneConfigManager(t *testing.T, remotes map[string]map[string]string) (*RcloneConfigManager, *executor.MockShellExecutor, *gorm.DB) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cloudbackup.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.CloudBackupRemote{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	shell := executor.NewMockShellExecutor()
+	shell.Handler = func(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+		args := call.Args
+		switch {
+		case len(args) == 2 && args[1] == "dump":
+			out, _ := json.Marshal(remotes)
+			return &executor.CommandResult{Stdout: string(out), Success: true}, nil
+		case args[1] == "create":
+			remotes[args[2]] = map[string]string{"type": args[3]}
+			setParams(remotes[args[2]], args[4:])
+		case args[1] == "update":
+			setParams(remotes[args[2]], args[3:])
+		case args[1] == "delete":
+			delete(remotes, args[2])
+		case args[0] == "lsd" && args[1] == "gdrive:":
+			return &executor.CommandResult{
+				Stderr: "2026/10/18 12:00:00 ERROR : : error listing: couldn't list directory: googleapi: Error 401: Invalid Credentials\n" +
+					"2026/10/18 12:00:00 Failed to lsd with 2 errors: last error was: googleapi: Error 401: Invalid Credentials\n",
+				ExitCode: 1,
+			}, fmt.Errorf("exit status 1")
+		}
+		return &executor.CommandResult{Success: true}, nil
+	}
+	m, err := NewRcloneConfigManager(db, shell)
+	if err != nil {
+		t.Fatalf("NewRcloneConfigManager: %v", err)
+	}
+	return m, shell, db
+}
+
+func setParams(params map[string]string, args []string) {
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			params[key] = value
+		}
+	}
+}
+
+func dumpedRemotes(t *testing.T) map[string]map[string]string {
+	t.Helper()
+	remotes, err := parseConfigDump(configDump)
+	if err != nil {
+		t.Fatalf("parseConfigDump: %v", err)
+	}
+	return remotes
+}
+
+func rcloneCalls(shell *executor.MockShellExecutor) []string {
+	var calls []string
+	for _, call := range shell.Calls {
+		if s := call.String(); s != "rclone config dump" {
+			calls = append(calls, s)
+		}
+	}
+	return calls
+}
+
+func TestListRemotesMasksSecrets(t *testing.T) {
+	m, _, _ := newTestRcloneConfigManager(t, dumpedRemotes(t))
+
+	remotes, err := m.ListRemotes()
+	if err != nil {
+		t.Fatalf("ListRemotes: %v", err)
+	}
+	want := []RcloneRemote{
+		{Name: "b2-offsite", Type: "b2", Params: map[string]string{"account": "0012ab34cd56", "key": MaskedValue}},
+		{Name: "gdrive", Type: "drive", Params: map[string]string{
+			"client_id":     "12345.apps.googleusercontent.com",
+			"client_secret": MaskedValue,
+			"scope":         "drive",
+			"token":         MaskedValue,
+		}},
+		// An empty password is not masked, so it shows that none is set
+		{Name: "nas2", Type: "sftp", Params: map[string]string{"host": "nas2.example.com", "pass": "", "user": "backup"}},
+	}
+	if !reflect.DeepEqual(remotes, want) {
+		t.Errorf("remotes =\n%+v\nwant\n%+v", remotes, want)
+	}
+
+	if _, err := m.GetRemote("s3"); err != ErrRemoteNotFound {
+		t.Errorf("GetRemote of an unknown remote: %v, want ErrRemoteNotFound", err)
+	}
+}
+
+func TestAddUpdateRemoveRemote(t *testing.T) {
+	m, shell, db := newTestRcloneConfigManager(t, map[string]map[string]string{})
+
+	err := m.AddRemote("wasabi", "s3", map[string]string{
+		"provider":          "Wasabi",
+		"access_key_id":     "AKIAEXAMPLE",
+		"secret_access_key": "--not-a-flag",
+	})
+	if err != nil {
+		t.Fatalf("AddRemote: %v", err)
+	}
+	if err := m.AddRemote("wasabi", "s3", nil); err != ErrRemoteExists {
+		t.Errorf("AddRemote of an existing remote: %v, want ErrRemoteExists", err)
+	}
+
+	// The masked secret is kept
+	err = m.UpdateRemote("wasabi", map[string]string{"secret_access_key": MaskedValue, "region": "eu-central-1"})
+	if err != nil {
+		t.Fatalf("UpdateRemote: %v", err)
+	}
+
+	var records []models.CloudBackupRemote
+	db.Find(&records)
+	if len(records) != 1 || records[0].Name != "wasabi" || records[0].Type != "s3" {
+		t.Errorf("records = %+v", records)
+	}
+
+	if err := m.RemoveRemote("wasabi"); err != nil {
+		t.Fatalf("RemoveRemote: %v", err)
+	}
+	if err := m.RemoveRemote("wasabi"); err != ErrRemoteNotFound {
+		t.Errorf("RemoveRemote of a removed remote: %v, want ErrRemoteNotFound", err)
+	}
+	var count int64
+	db.Model(&models.CloudBackupRemote{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d records left", count)
+	}
+
+	want := []string{
+		"rclone config create wasabi s3 access_key_id=AKIAEXAMPLE provider=Wasabi secret_access_key=--not-a-flag --non-interactive",
+		"rclone config update wasabi region=eu-central-1 --non-interactive",
+		"rclone config delete wasabi",
+	}
+	if got := rcloneCalls(shell); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestAddRemoteValidates(t *testing.T) {
+	m, shell, _ := newTestRcloneConfigManager(t, map[string]map[string]string{})
+
+	tests := []struct {
+		name, remoteType string
+		params           map[string]string
+	}{
+		{"--config", "s3", nil},
+		{"my remote", "s3", nil},
+		{"offsite", "S3;rm", nil},
+		{"offsite", "s3", map[string]string{"type": "local"}},
+		{"offsite", "s3", map[string]string{"--config": "/tmp/x"}},
+	}
+	for _, tt := range tests {
+		if err := m.AddRemote(tt.name, tt.remoteType, tt.params); err == nil {
+			t.Errorf("AddRemote(%q, %q, %v) succeeded", tt.name, tt.remoteType, tt.params)
+		}
+	}
+	if calls := rcloneCalls(shell); len(calls) != 0 {
+		t.Errorf("rclone called for invalid remotes: %v", calls)
+	}
+}
+
+func TestTestRemote(t *testing.T) {
+	m, shell, _ := newTestRcloneConfigManager(t, dumpedRemotes(t))
+
+	result, err := m.TestRemote("b2-offsite")
+	if err != nil || !result.Success || result.Error != "" {
+		t.Errorf("TestRemote(b2-offsite) = %+v, %v", result, err)
+	}
+	result, err = m.TestRemote("gdrive")
+	if err != nil {
+		t.Fatalf("TestRemote(gdrive): %v", err)
+	}
+	if result.Success || result.Error != "2026/10/18 12:00:00 Failed to lsd with 2 errors: last error was: googleapi: Error 401: Invalid Credentials" {
+		t.Errorf("TestRemote(gdrive) = %+v", result)
+	}
+	if _, err := m.TestRemote("s3"); err != ErrRemoteNotFound {
+		t.Errorf("TestRemote of an unknown remote: %v, want ErrRemoteNotFound", err)
+	}
+
+	calls := shell.CallsTo("rclone")
+	if last := calls[len(calls)-2]; last.String() != "rclone lsd gdrive: --max-depth 0" || last.Timeout != remoteTestTimeout {
+		t.Errorf("test command = %+v", last)
+	}
+}
+
+func TestSyncRemotes(t *testing.T) {
+	remotes := dumpedRemotes(t)
+	m, _, db := newTestRcloneConfigManager(t, remotes)
+	db.Create(&models.CloudBackupRemote{Name: "removed-by-hand", Type: "s3"})
+
+	if err := m.SyncRemotes(); err != nil {
+		t.Fatalf("SyncRemotes: %v", err)
+	}
+	var names []string
+	db.Model(&models.CloudBackupRemote{}).Order("name").Pluck("name", &names)
+	if want := []string{"b2-offsite", "gdrive", "nas2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("recorded remotes = %v, want %v", names, want)
+	}
+
+	for name := range remotes {
+		delete(remotes, name)
+	}
+	if err := m.SyncRemotes(); err != nil {
+		t.Fatalf("SyncRemotes of an empty config: %v", err)
+	}
+	var count int64
+	db.Model(&models.CloudBackupRemote{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d records left", count)
+	}
+}
@@ -0,0 +1,176 @@
+// Package cluster coordinates work between the nodes of an HA cluster that
+// share a database.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLockHeld is returned by WithLock if another holder kept the lock
+	// until the context was done
+	ErrLockHeld = errors.New("lock is held by another node")
+	// ErrLockLost is returned by KeepAlive if the lock expired and was taken over
+	ErrLockLost = errors.New("lock was lost")
+)
+
+// lockRetryInterval is how often WithLock retries to acquire a held lock
+var lockRetryInterval = 500 * time.Millisecond
+
+// DistributedLock is a named lock held by one node at a time, backed by the
+// cluster_locks table. The lock expires after TTL unless it is kept alive,
+// so a crashed node does not keep it forever. Expiry is decided by the
+// clocks of the nodes, which must be kept in sync.
+type DistributedLock struct {
+	Name     string
+	TTL      time.Duration
+	HolderID string // Identifies this holder; unique per lock instance
+
+	db *gorm.DB
+}
+
+// NewDistributedLock creates a lock. Each instance is a separate holder, so
+// two instances with the same name exclude each other even in one process.
+func NewDistributedLock(db *gorm.DB, name string, ttl time.Duration) *DistributedLock {
+	return &DistributedLock{
+		Name:     name,
+		TTL:      ttl,
+		HolderID: newHolderID(),
+		db:       db,
+	}
+}
+
+// TryAcquire takes the lock if it is free, expired or already held by this
+// holder, and reports whether it is now held
+func (l *DistributedLock) TryAcquire() (bool, error) {
+	if l.db == nil {
+		return false, fmt.Errorf("database not initialized")
+	}
+
+	now := time.Now().UTC()
+	lock := models.ClusterLock{Name: l.Name, HolderID: l.HolderID, ExpiresAt: now.Add(l.TTL)}
+	result := l.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", l.Name, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// The row exists: take it over if it expired or is our own
+	result = l.db.Model(&models.ClusterLock{}).
+		Where("name = ? AND (expires_at < ? OR holder_id = ?)", l.Name, now, l.HolderID).
+		Updates(map[string]interface{}{"holder_id": l.HolderID, "expires_at": now.Add(l.TTL)})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", l.Name, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Release frees the lock if this holder has it
+func (l *DistributedLock) Release() error {
+	if l.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := l.db.Where("name = ? AND holder_id = ?", l.Name, l.HolderID).Delete(&models.ClusterLock{}).Error; err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", l.Name, err)
+	}
+	return nil
+}
+
+// KeepAlive extends the lock's expiry every third of its TTL until ctx is
+// done, when it returns nil. It returns ErrLockLost if the lock is no
+// longer held by this holder.
+func (l *DistributedLock) KeepAlive(ctx context.Context) error {
+	if l.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if l.TTL <= 0 {
+		return fmt.Errorf("lock %s has no TTL", l.Name)
+	}
+
+	ticker := time.NewTicker(l.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			result := l.db.Model(&models.ClusterLock{}).
+				Where("name = ? AND holder_id = ?", l.Name, l.HolderID).
+				Update("expires_at", time.Now().UTC().Add(l.TTL))
+			if result.Error != nil {
+				logger.Warn("Failed to refresh cluster lock", zap.String("lock", l.Name), zap.Error(result.Error))
+				continue
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: %s", ErrLockLost, l.Name)
+			}
+		}
+	}
+}
+
+// WithLock waits until the lock is acquired, runs fn while keeping the
+// lock alive and releases it. It serializes work between nodes rather than
+// skipping it: the next node runs fn once the first one has finished. It
+// returns ErrLockHeld if ctx is done before the lock could be acquired.
+func (l *DistributedLock) WithLock(ctx context.Context, fn func() error) error {
+	for {
+		acquired, err := l.TryAcquire()
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s", ErrLockHeld, l.Name)
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	// ctx only bounds the wait; the lock is kept until fn returns
+	keepAliveCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := l.KeepAlive(keepAliveCtx); err != nil {
+			logger.Error("Cluster lock lost while running", zap.String("lock", l.Name), zap.Error(err))
+		}
+	}()
+
+	err := fn()
+
+	cancel()
+	<-done
+	if releaseErr := l.Release(); releaseErr != nil {
+		logger.Warn("Failed to release cluster lock", zap.String("lock", l.Name), zap.Error(releaseErr))
+	}
+	return err
+}
+
+// newHolderID returns hostname-pid-random, identifying the node and process
+func newHolderID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
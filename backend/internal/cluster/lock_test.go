@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cluster.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.ClusterLock{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTryAcquireOnlyOneHolder(t *testing.T) {
+	db := newTestDB(t)
+
+	const nodes = 10
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < nodes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := NewDistributedLock(db, "default-shares", time.Minute).TryAcquire()
+			if err != nil {
+				t.Errorf("TryAcquire() error = %v", err)
+			}
+			if ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := acquired.Load(); got != 1 {
+		t.Fatalf("%d of %d holders acquired the lock, want 1", got, nodes)
+	}
+}
+
+func TestTryAcquireExpiredAndReleased(t *testing.T) {
+	db := newTestDB(t)
+	first := NewDistributedLock(db, "samba-config", time.Minute)
+	second := NewDistributedLock(db, "samba-config", time.Minute)
+
+	if ok, err := first.TryAcquire(); !ok || err != nil {
+		t.Fatalf("first TryAcquire() = %v, %v", ok, err)
+	}
+	if ok, _ := first.TryAcquire(); !ok {
+		t.Error("holder could not re-acquire its own lock")
+	}
+	if ok, _ := second.TryAcquire(); ok {
+		t.Fatal("second holder acquired a held lock")
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if ok, _ := second.TryAcquire(); !ok {
+		t.Fatal("second holder could not acquire a released lock")
+	}
+
+	// Expire the second holder's lock
+	db.Model(&models.ClusterLock{}).Where("name = ?", "samba-config").Update("expires_at", time.Now().UTC().Add(-time.Second))
+	if ok, _ := first.TryAcquire(); !ok {
+		t.Fatal("expired lock was not taken over")
+	}
+	second.TTL = 30 * time.Millisecond
+	if err := second.KeepAlive(context.Background()); !errors.Is(err, ErrLockLost) {
+		t.Errorf("KeepAlive() of the lost lock = %v, want ErrLockLost", err)
+	}
+}
+
+func TestWithLockSerializes(t *testing.T) {
+	db := newTestDB(t)
+	lockRetryInterval = 10 * time.Millisecond
+
+	var running, maxRunning, runs atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := NewDistributedLock(db, "share-permissions", time.Minute).WithLock(context.Background(), func() error {
+				n := running.Add(1)
+				if n > maxRunning.Load() {
+					maxRunning.Store(n)
+				}
+				time.Sleep(20 * time.Millisecond)
+				running.Add(-1)
+				runs.Add(1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("WithLock() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning.Load() != 1 || runs.Load() != 5 {
+		t.Errorf("max concurrent runs = %d, runs = %d; want 1 and 5", maxRunning.Load(), runs.Load())
+	}
+
+	holder := NewDistributedLock(db, "share-permissions", time.Minute)
+	holder.TryAcquire()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := NewDistributedLock(db, "share-permissions", time.Minute).WithLock(ctx, func() error { return nil })
+	if !errors.Is(err, ErrLockHeld) {
+		t.Errorf("WithLock() on a held lock = %v, want ErrLockHeld", err)
+	}
+}
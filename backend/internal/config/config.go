@@ -4,6 +4,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -17,6 +18,16 @@ type Config struct {
 	Auth         AuthConfig
 	Logging      LoggingConfig
 	Dependencies DependenciesConfig
+	Alerts       AlertsConfig
+	Scheduler    SchedulerConfig
+	RateLimit    RateLimitConfig
+	Versioning   VersioningConfig
+	AutoUpdates  AutoUpdatesConfig
+	ARPMonitor   ARPMonitorConfig
+	Marketplace  MarketplaceConfig
+	Tracing      TracingConfig
+	Secrets      SecretsConfig
+	Metrics      MetricsConfig
 }
 
 // AppConfig contains application-level settings
@@ -35,6 +46,15 @@ type ServerConfig struct {
 	IdleTimeout    time.Duration
 	AllowedOrigins []string
 	TrustedProxies []string
+	EnableHTTPS    bool   // Serve HTTPS using TLSCertFile and TLSKeyFile
+	TLSCertFile    string // Path to the PEM-encoded certificate
+	TLSKeyFile     string // Path to the PEM-encoded private key
+
+	MaxBodySizeBytes   int64 // Request body limit for API endpoints (0 = unlimited)
+	MaxUploadSizeBytes int64 // Request body limit for file uploads, user imports and compose files (0 = unlimited)
+
+	FileSearchTimeout time.Duration // Maximum run time of a file content search (0 = default of 30s)
+	ThumbnailCacheDir string        // Directory for generated image and video thumbnails
 }
 
 // DatabaseConfig contains database connection settings
@@ -47,9 +67,23 @@ type DatabaseConfig struct {
 	Username        string // For PostgreSQL
 	Password        string // For PostgreSQL
 	SSLMode         string // For PostgreSQL
-	MaxOpenConns    int
-	MaxIdleConns    int
-	ConnMaxLifetime string
+	Pool            DatabasePoolConfig
+}
+
+// DatabasePoolConfig tunes the database connection pool
+type DatabasePoolConfig struct {
+	MaxOpenConns    int           // Maximum open connections (0 = unlimited)
+	MaxIdleConns    int           // Maximum idle connections kept open
+	ConnMaxLifetime time.Duration // Close connections after this age (0 = never)
+	ConnMaxIdleTime time.Duration // Close connections idle for this long (0 = never)
+}
+
+// legacyPoolKeys maps the pool settings that used to live directly under
+// database to their current keys
+var legacyPoolKeys = map[string]string{
+	"database.maxOpenConns":    "database.pool.maxOpenConns",
+	"database.maxIdleConns":    "database.pool.maxIdleConns",
+	"database.connMaxLifetime": "database.pool.connMaxLifetime",
 }
 
 // AuthConfig contains authentication settings
@@ -63,8 +97,9 @@ type AuthConfig struct {
 
 // LoggingConfig contains logging settings
 type LoggingConfig struct {
-	Level       string
-	Development bool
+	Level         string
+	Development   bool
+	RetentionDays int // Days to keep access logs in the database (0 = forever)
 }
 
 // DependenciesConfig contains system dependency settings
@@ -73,10 +108,105 @@ type DependenciesConfig struct {
 	InstallMode    string // "check", "auto", or "interactive"
 }
 
+// AlertsConfig contains alerting overrides (0 = use the value from the alert settings)
+type AlertsConfig struct {
+	FailedLoginThreshold int // Failed logins before an alert is sent
+	RateLimitMinutes     int // Minimum minutes between alerts of the same type
+}
+
+// SchedulerConfig contains task scheduler settings
+type SchedulerConfig struct {
+	ReloadInterval time.Duration // How often scheduled tasks are reloaded from the database
+}
+
+// RateLimitConfig contains API rate limiting defaults (custom limits are managed via the API)
+type RateLimitConfig struct {
+	Enabled               bool
+	UserRequestsPerSecond float64 // Per authenticated user
+	IPRequestsPerSecond   float64 // Per client IP for unauthenticated requests
+	Burst                 int
+}
+
+// VersioningConfig contains file version history settings
+type VersioningConfig struct {
+	Enabled         bool     // Keep a version before files are overwritten by uploads or renames
+	Backend         string   // "copy" or "zfs" ("" = copy)
+	MaxVersions     int      // Versions kept per file (0 = unlimited)
+	ExcludePatterns []string // Glob patterns of file names that are never versioned
+}
+
+// AutoUpdatesConfig contains automatic system package update settings
+type AutoUpdatesConfig struct {
+	Enabled         bool
+	SecurityOnly    bool     // Only install updates from the allowed origins
+	AllowedOrigins  []string // apt release labels security updates come from, e.g. "-security"
+	Schedule        string   // Cron expression of the automatic runs
+	RequireApproval bool     // Only announce pending updates; an admin applies them
+}
+
+// ARPMonitorConfig contains ARP spoofing detection settings
+type ARPMonitorConfig struct {
+	Enabled      bool
+	Interface    string        // NIC to watch ("" = interface of the default route)
+	ChangeWindow time.Duration // An IP moving to another MAC within this time of its last reply raises an alert
+}
+
+// MarketplaceConfig contains addon marketplace settings
+type MarketplaceConfig struct {
+	RegistryURL string        // Base URL of the addon registry
+	CacheTTL    time.Duration // How long the cached registry index is used before it is fetched again
+	Keyring     string        // GPG keyring used to verify addon signatures ("" = default keyring)
+}
+
+// TracingConfig contains OpenTelemetry tracing settings
+type TracingConfig struct {
+	Enabled      bool
+	OTLPEndpoint string // OTLP/HTTP collector URL, e.g. http://localhost:4318
+}
+
+// SecretsConfig selects where {secret:key} placeholders in the config are
+// resolved from
+type SecretsConfig struct {
+	Provider string // "env" (STUMPFWORKS_SECRET_<KEY> variables) or "file"
+	Dir      string // Secrets directory of the file provider, one 0600 file per key
+}
+
+// MetricsConfig contains metrics collection settings
+type MetricsConfig struct {
+	// Weight of each health score component (cpu, memory, disk, smart,
+	// network); components not listed keep their default weight
+	HealthScoreWeights map[string]float64
+	Export             MetricsExportConfig
+}
+
+// MetricsExportConfig contains settings for pushing metrics to a remote
+// InfluxDB or Prometheus Pushgateway
+type MetricsExportConfig struct {
+	Enabled      bool
+	Protocol     string // "influxdb" or "prometheus-push"
+	RemoteURL    string // Base URL of the InfluxDB instance or Pushgateway
+	Token        string // InfluxDB API token, or bearer token of the Pushgateway
+	Org          string // InfluxDB organization
+	Bucket       string // InfluxDB bucket
+	PushInterval time.Duration
+}
+
 var GlobalConfig *Config
 
-// Load loads configuration from file and environment variables
+// Load loads configuration from file and environment variables and makes
+// it the GlobalConfig. The result is not validated; call Validate before
+// using it.
 func Load(configPath string) (*Config, error) {
+	cfg, err := load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	GlobalConfig = cfg
+	return cfg, nil
+}
+
+// load reads the configuration without touching GlobalConfig
+func load(configPath string) (*Config, error) {
 	v := viper.New()
 
 	// Set defaults
@@ -94,18 +224,19 @@ func Load(configPath string) (*Config, error) {
 	v.AutomaticEnv()
 	v.SetEnvPrefix("STUMPFWORKS")
 
+	// Older config files set the pool options directly under database
+	for legacy, key := range legacyPoolKeys {
+		if v.IsSet(legacy) && !v.InConfig(key) {
+			v.Set(key, v.Get(legacy))
+		}
+	}
+
 	// Unmarshal config
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-	// Validate config
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
-	}
-
-	GlobalConfig = &cfg
 	return &cfg, nil
 }
 
@@ -124,6 +255,11 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.idleTimeout", "60s")
 	v.SetDefault("server.allowedOrigins", []string{"http://localhost:3000", "http://localhost:5173"})
 	v.SetDefault("server.trustedProxies", []string{"127.0.0.1", "::1"})
+	v.SetDefault("server.enableHTTPS", false)
+	v.SetDefault("server.maxBodySizeBytes", 1<<20) // 1 MB
+	v.SetDefault("server.maxUploadSizeBytes", 0)
+	v.SetDefault("server.fileSearchTimeout", "30s")
+	v.SetDefault("server.thumbnailCacheDir", "/var/cache/stumpfworks-nas/thumbnails")
 
 	// Database defaults
 	v.SetDefault("database.driver", "postgres")
@@ -134,9 +270,10 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("database.username", "stumpfworks")
 	v.SetDefault("database.password", "")
 	v.SetDefault("database.sslmode", "disable")
-	v.SetDefault("database.maxOpenConns", 25)
-	v.SetDefault("database.maxIdleConns", 5)
-	v.SetDefault("database.connMaxLifetime", "5m")
+	v.SetDefault("database.pool.maxOpenConns", 25)
+	v.SetDefault("database.pool.maxIdleConns", 5)
+	v.SetDefault("database.pool.connMaxLifetime", "5m")
+	v.SetDefault("database.pool.connMaxIdleTime", "1m")
 
 	// Auth defaults
 	v.SetDefault("auth.jwtSecret", generateRandomSecret())
@@ -148,73 +285,64 @@ func setDefaults(v *viper.Viper) {
 	// Logging defaults
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.development", true)
+	v.SetDefault("logging.retentionDays", 30)
 
 	// Dependencies defaults
 	v.SetDefault("dependencies.checkOnStartup", true)
 	v.SetDefault("dependencies.installMode", "check") // check | auto | interactive
-}
-
-// Validate validates the configuration
-func (c *Config) Validate() error {
-	if c.Server.Port < 1 || c.Server.Port > 65535 {
-		return fmt.Errorf("invalid port number: %d", c.Server.Port)
-	}
-
-	// JWT Secret validation
-	if c.Auth.JWTSecret == "" {
-		return fmt.Errorf("JWT secret is required")
-	}
-
-	// Enforce strong JWT secret in production
-	if c.IsProduction() {
-		if len(c.Auth.JWTSecret) < 32 {
-			return fmt.Errorf("JWT secret must be at least 32 characters in production (current: %d)", len(c.Auth.JWTSecret))
-		}
-		// Check for weak/default secrets
-		weakSecrets := []string{
-			"dev-secret",
-			"dev-secret-please-change-in-production",
-			"dev-secret-change-in-production",
-			"change-me",
-			"changeme",
-			"secret",
-			"password",
-			"admin",
-		}
-		for _, weak := range weakSecrets {
-			if c.Auth.JWTSecret == weak {
-				return fmt.Errorf("weak/default JWT secret detected in production: '%s' - please use a strong random secret", weak)
-			}
-		}
-	}
 
-	// Warn about weak secrets in development
-	if c.IsDevelopment() && len(c.Auth.JWTSecret) < 16 {
-		fmt.Fprintf(os.Stderr, "WARNING: JWT secret is very short (%d chars) - recommended minimum: 32 chars\n", len(c.Auth.JWTSecret))
-	}
-
-	// Validate database config
-	if c.Database.Driver == "sqlite" && c.Database.Path == "" {
-		return fmt.Errorf("database path is required for SQLite")
-	}
-	if c.Database.Driver == "postgres" {
-		if c.Database.Host == "" {
-			return fmt.Errorf("database host is required for PostgreSQL")
-		}
-		if c.Database.Database == "" {
-			return fmt.Errorf("database name is required for PostgreSQL")
-		}
-		if c.Database.Username == "" {
-			return fmt.Errorf("database username is required for PostgreSQL")
-		}
-	}
-
-	// Validate CORS in production
-	if c.IsProduction() && len(c.Server.AllowedOrigins) == 0 {
-		return fmt.Errorf("no CORS origins configured in production - please set server.allowedOrigins")
-	}
-
-	return nil
+	// Alerts defaults (0 = use alert settings from the database)
+	v.SetDefault("alerts.failedLoginThreshold", 0)
+	v.SetDefault("alerts.rateLimitMinutes", 0)
+
+	// Scheduler defaults
+	v.SetDefault("scheduler.reloadInterval", "1m")
+
+	// Rate limit defaults
+	v.SetDefault("rateLimit.enabled", true)
+	v.SetDefault("rateLimit.userRequestsPerSecond", 20)
+	v.SetDefault("rateLimit.ipRequestsPerSecond", 10)
+	v.SetDefault("rateLimit.burst", 40)
+
+	// Versioning defaults
+	v.SetDefault("versioning.enabled", false)
+	v.SetDefault("versioning.backend", "copy") // copy | zfs
+	v.SetDefault("versioning.maxVersions", 10)
+	v.SetDefault("versioning.excludePatterns", []string{"*.tmp", "*.part", "~$*"})
+
+	// Automatic update defaults
+	v.SetDefault("autoUpdates.enabled", false)
+	v.SetDefault("autoUpdates.securityOnly", true)
+	v.SetDefault("autoUpdates.allowedOrigins", []string{"-security"})
+	v.SetDefault("autoUpdates.schedule", "0 4 * * *")
+	v.SetDefault("autoUpdates.requireApproval", false)
+
+	// ARP monitor defaults
+	v.SetDefault("arpMonitor.enabled", false)
+	v.SetDefault("arpMonitor.interface", "")
+	v.SetDefault("arpMonitor.changeWindow", "24h")
+
+	// Marketplace defaults
+	v.SetDefault("marketplace.registryURL", "https://raw.githubusercontent.com/Stumpf-works/stumpfworks-nas-apps/main/addons")
+	v.SetDefault("marketplace.cacheTTL", "1h")
+	v.SetDefault("marketplace.keyring", "")
+
+	// Tracing defaults
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.otlpEndpoint", "http://localhost:4318")
+
+	// Metrics export defaults
+	v.SetDefault("metrics.export.enabled", false)
+	v.SetDefault("metrics.export.protocol", "influxdb")
+	v.SetDefault("metrics.export.remoteURL", "")
+	v.SetDefault("metrics.export.token", "")
+	v.SetDefault("metrics.export.org", "")
+	v.SetDefault("metrics.export.bucket", "")
+	v.SetDefault("metrics.export.pushInterval", "60s")
+
+	// Secrets defaults
+	v.SetDefault("secrets.provider", "file") // env | file
+	v.SetDefault("secrets.dir", "/etc/stumpfworks-nas/secrets")
 }
 
 // IsDevelopment returns true if running in development mode
@@ -227,6 +355,29 @@ func (c *Config) IsProduction() bool {
 	return c.App.Environment == "production"
 }
 
+// DSN returns the PostgreSQL connection string for the database settings
+func (d *DatabaseConfig) DSN() string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSNValue(d.Host),
+		d.Port,
+		quoteDSNValue(d.Username),
+		quoteDSNValue(d.Password),
+		quoteDSNValue(d.Database),
+		quoteDSNValue(d.SSLMode),
+	)
+}
+
+// quoteDSNValue quotes a keyword/value DSN value if it is empty or contains spaces or quotes
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
 // GetServerAddress returns the server address in format "host:port"
 func (c *Config) GetServerAddress() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
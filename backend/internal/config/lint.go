@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a problem found in a config file
+type Problem struct {
+	Key     string // Setting the problem is about, e.g. server.port ("" if unknown)
+	Line    int    // Line of the setting in the file (0 if the file does not set it)
+	Message string
+}
+
+// defaultTimeoutKeys are the timeouts FixFile adds to a config file that
+// does not set them
+var defaultTimeoutKeys = []string{"server.readTimeout", "server.writeTimeout", "server.idleTimeout"}
+
+// CheckFile loads and validates a config file like the server does and
+// returns every problem with the line of its setting. Settings the server
+// does not know are reported as well, since they are mostly typos.
+func CheckFile(path string) ([]Problem, error) {
+	root, err := readConfigNode(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := map[string]int{}
+	var problems []Problem
+	if doc := documentMapping(root); doc != nil {
+		walkConfigNode(doc, reflect.TypeOf(Config{}), "", lines, &problems)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		return append(problems, Problem{Message: err.Error()}), nil
+	}
+	if err := Validate(cfg); err != nil {
+		for _, msg := range err.(ValidationErrors) {
+			key := strings.TrimSuffix(strings.Fields(msg)[0], ":")
+			problems = append(problems, Problem{Key: key, Line: lineOf(lines, key), Message: msg})
+		}
+	}
+
+	// Problems without a line come last
+	sort.SliceStable(problems, func(i, j int) bool {
+		if (problems[i].Line == 0) != (problems[j].Line == 0) {
+			return problems[j].Line == 0
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+// FixFile corrects safe problems of a config file in place: it trims
+// whitespace around strings, lowercases the log level and adds the default
+// server timeouts if they are missing. It returns the changes made.
+func FixFile(path string) ([]string, error) {
+	root, err := readConfigNode(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := documentMapping(root)
+	if doc == nil {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+
+	var changes []string
+	trimStrings(doc, "", &changes)
+
+	if level := findNode(doc, "logging.level"); level != nil && level.Value != strings.ToLower(level.Value) {
+		changes = append(changes, fmt.Sprintf("logging.level: lowercased %q", level.Value))
+		level.Value = strings.ToLower(level.Value)
+	}
+
+	defaults := viper.New()
+	setDefaults(defaults)
+	for _, key := range defaultTimeoutKeys {
+		value := defaults.GetString(key)
+		node := findNode(doc, key)
+		switch {
+		case node == nil:
+			setNode(doc, key, value)
+			changes = append(changes, fmt.Sprintf("%s: added the default of %s", key, value))
+		case node.Value == "" || (key == "server.readTimeout" && isZeroDuration(node.Value)):
+			// A read timeout of 0 is invalid; the others mean no timeout
+			changes = append(changes, fmt.Sprintf("%s: replaced %q with the default of %s", key, node.Value, value))
+			node.Value = value
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := sysutil.AtomicWriteFile(path, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// readConfigNode parses a config file keeping the line of each setting
+func readConfigNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s is not valid YAML: %w", path, err)
+	}
+	return &root, nil
+}
+
+// documentMapping returns the top-level mapping of a parsed file, or nil
+// if the file is empty
+func documentMapping(root *yaml.Node) *yaml.Node {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	return root.Content[0]
+}
+
+// walkConfigNode records the line of every setting of a mapping and reports
+// the keys that are not fields of t. Keys match fields case-insensitively,
+// like they do when the config is loaded.
+func walkConfigNode(node *yaml.Node, t reflect.Type, prefix string, lines map[string]int, problems *[]Problem) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+		lines[strings.ToLower(path)] = key.Line
+
+		field, ok := configField(t, key.Value)
+		if !ok {
+			if _, legacy := legacyPoolKeys[path]; !legacy {
+				*problems = append(*problems, Problem{Key: path, Line: key.Line, Message: path + " is not a known setting"})
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && value.Kind == yaml.MappingNode {
+			walkConfigNode(value, field.Type, path, lines, problems)
+		}
+	}
+}
+
+// configField returns the field of a config struct a key sets
+func configField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(t.Field(i).Name, key) {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// lineOf returns the line of a setting, or of its closest parent section
+// if the file does not set it
+func lineOf(lines map[string]int, key string) int {
+	key = strings.ToLower(key)
+	for key != "" {
+		if line, ok := lines[key]; ok {
+			return line
+		}
+		dot := strings.LastIndex(key, ".")
+		if dot < 0 {
+			break
+		}
+		key = key[:dot]
+	}
+	return 0
+}
+
+// findNode returns the value of a dotted setting, or nil if it is not set
+func findNode(node *yaml.Node, key string) *yaml.Node {
+	for _, part := range strings.Split(key, ".") {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if strings.EqualFold(node.Content[i].Value, part) {
+				next = node.Content[i+1]
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// setNode adds a dotted setting, creating its sections as needed
+func setNode(node *yaml.Node, key, value string) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		section := findNode(node, part)
+		if section == nil || section.Kind != yaml.MappingNode {
+			section = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, section)
+		}
+		node = section
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: parts[len(parts)-1]},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
+
+// trimStrings trims the whitespace around all string values below node
+func trimStrings(node *yaml.Node, path string, changes *[]string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			trimStrings(node.Content[i+1], key, changes)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			trimStrings(item, path, changes)
+		}
+	case yaml.ScalarNode:
+		if trimmed := strings.TrimSpace(node.Value); node.Tag == "!!str" && trimmed != node.Value {
+			*changes = append(*changes, fmt.Sprintf("%s: trimmed whitespace around %q", path, node.Value))
+			node.Value = trimmed
+		}
+	}
+}
+
+// isZeroDuration reports whether a duration setting is 0
+func isZeroDuration(value string) bool {
+	d, err := time.ParseDuration(value)
+	return value == "0" || (err == nil && d == 0)
+}
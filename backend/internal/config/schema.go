@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"time"
+	"unicode"
+
+	"github.com/spf13/viper"
+)
+
+// secretDefaultKeys are settings whose default is generated and must not
+// be published in the schema
+var secretDefaultKeys = map[string]bool{"auth.jwtSecret": true}
+
+// JSONSchema describes the settings of a config file as a JSON Schema, with
+// their defaults
+func JSONSchema() map[string]interface{} {
+	defaults := viper.New()
+	setDefaults(defaults)
+
+	schema := structSchema(reflect.TypeOf(Config{}), "", defaults)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "StumpfWorks NAS configuration"
+	return schema
+}
+
+// structSchema describes a config struct
+func structSchema(t reflect.Type, prefix string, defaults *viper.Viper) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := settingName(field.Name)
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		property := typeSchema(field.Type, path, defaults)
+		if defaults.IsSet(path) && !secretDefaultKeys[path] && field.Type.Kind() != reflect.Struct {
+			property["default"] = defaults.Get(path)
+		}
+		properties[key] = property
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// typeSchema describes the value of a setting
+func typeSchema(t reflect.Type, path string, defaults *viper.Viper) map[string]interface{} {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]interface{}{
+			"type":        "string",
+			"description": "Duration such as 30s, 5m or 1h",
+			"pattern":     `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`,
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return structSchema(t, path, defaults)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), path, defaults)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), path, defaults)}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// settingName returns the config file key of a field: the field name with
+// its leading initialism lowercased, e.g. jwtSecret for JWTSecret
+func settingName(field string) string {
+	runes := []rune(field)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// The last capital of an initialism followed by a word starts that word
+	if upper > 1 && upper < len(runes) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
@@ -0,0 +1,41 @@
+package config
+
+import "testing"
+
+func TestSettingName(t *testing.T) {
+	tests := map[string]string{
+		"Port":         "port",
+		"JWTSecret":    "jwtSecret",
+		"TLSCertFile":  "tlsCertFile",
+		"OTLPEndpoint": "otlpEndpoint",
+		"RegistryURL":  "registryURL",
+		"SSLMode":      "sslMode",
+	}
+	for field, want := range tests {
+		if got := settingName(field); got != want {
+			t.Errorf("settingName(%s) = %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := JSONSchema()
+	server := schema["properties"].(map[string]interface{})["server"].(map[string]interface{})
+	props := server["properties"].(map[string]interface{})
+
+	port := props["port"].(map[string]interface{})
+	if port["type"] != "integer" || port["default"] != 8080 {
+		t.Errorf("server.port = %v", port)
+	}
+	if timeout := props["readTimeout"].(map[string]interface{}); timeout["type"] != "string" || timeout["default"] != "15s" {
+		t.Errorf("server.readTimeout = %v", timeout)
+	}
+	if origins := props["allowedOrigins"].(map[string]interface{}); origins["type"] != "array" {
+		t.Errorf("server.allowedOrigins = %v", origins)
+	}
+
+	auth := schema["properties"].(map[string]interface{})["auth"].(map[string]interface{})
+	if _, ok := auth["properties"].(map[string]interface{})["jwtSecret"].(map[string]interface{})["default"]; ok {
+		t.Error("schema publishes the generated JWT secret")
+	}
+}
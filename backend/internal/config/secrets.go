@@ -0,0 +1,279 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SecretEnvPrefix is the prefix of the environment variables read by
+// EnvSecretProvider, e.g. STUMPFWORKS_SECRET_DB_PASSWORD for db_password
+const SecretEnvPrefix = "STUMPFWORKS_SECRET_"
+
+// ErrSecretNotFound is returned by secret providers for unknown keys
+var ErrSecretNotFound = errors.New("secret not found")
+
+// secretKeyPattern restricts secret keys to names that are safe as file
+// names and environment variables
+var secretKeyPattern = regexp.MustCompile(`^[a-z0-9_]{1,128}$`)
+
+// secretPlaceholder matches {secret:key} references in config values
+var secretPlaceholder = regexp.MustCompile(`\{secret:([^}]*)\}`)
+
+// SecretProvider stores sensitive configuration values outside the config file
+type SecretProvider interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+}
+
+// SecretLister is implemented by secret providers that can enumerate their keys
+type SecretLister interface {
+	List() ([]string, error)
+}
+
+// ValidateSecretKey checks that a secret key is lower case letters, digits
+// and underscores
+func ValidateSecretKey(key string) error {
+	if !secretKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid secret key %q: use 1-128 lower case letters, digits and underscores", key)
+	}
+	return nil
+}
+
+// EnvSecretProvider reads secrets from STUMPFWORKS_SECRET_<KEY> environment
+// variables. Set only changes the environment of the running process.
+type EnvSecretProvider struct{}
+
+// Get returns the secret from the environment
+func (EnvSecretProvider) Get(key string) (string, error) {
+	if err := ValidateSecretKey(key); err != nil {
+		return "", err
+	}
+	value, ok := os.LookupEnv(SecretEnvPrefix + strings.ToUpper(key))
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// Set stores the secret in the environment of the running process
+func (EnvSecretProvider) Set(key string, value string) error {
+	if err := ValidateSecretKey(key); err != nil {
+		return err
+	}
+	return os.Setenv(SecretEnvPrefix+strings.ToUpper(key), value)
+}
+
+// List returns the keys of all secrets in the environment
+func (EnvSecretProvider) List() ([]string, error) {
+	var keys []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if key, ok := strings.CutPrefix(name, SecretEnvPrefix); ok && key != "" {
+			keys = append(keys, strings.ToLower(key))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// FileSecretProvider stores each secret in its own file in Dir. The
+// directory is created with mode 0700 and the files with mode 0600; files
+// readable by group or others are rejected.
+type FileSecretProvider struct {
+	Dir string
+}
+
+// NewFileSecretProvider creates a provider for the secrets directory dir
+func NewFileSecretProvider(dir string) *FileSecretProvider {
+	return &FileSecretProvider{Dir: dir}
+}
+
+// Get reads the secret file of key. A trailing newline is ignored.
+func (p *FileSecretProvider) Get(key string) (string, error) {
+	if err := ValidateSecretKey(key); err != nil {
+		return "", err
+	}
+	path := filepath.Join(p.Dir, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("secret file %s must not be accessible by group or others (mode %04o, want 0600)", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// Set writes the secret file of key, replacing it atomically
+func (p *FileSecretProvider) Set(key string, value string) error {
+	if err := ValidateSecretKey(key); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(p.Dir, "."+key+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write secret: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write secret: %w", err)
+	}
+	if _, err := tmp.WriteString(value); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write secret: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write secret: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(p.Dir, key)); err != nil {
+		return fmt.Errorf("failed to write secret: %w", err)
+	}
+	return nil
+}
+
+// List returns the keys of all secret files
+func (p *FileSecretProvider) List() ([]string, error) {
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && secretKeyPattern.MatchString(entry.Name()) {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys, nil
+}
+
+// NewSecretProvider creates the secret provider selected by the secrets settings
+func NewSecretProvider(c SecretsConfig) (SecretProvider, error) {
+	switch c.Provider {
+	case "env":
+		return EnvSecretProvider{}, nil
+	case "file", "":
+		if c.Dir == "" {
+			return nil, fmt.Errorf("secrets.dir is required for the file secret provider")
+		}
+		return NewFileSecretProvider(c.Dir), nil
+	default:
+		return nil, fmt.Errorf("secrets.provider must be \"env\" or \"file\" (got %q)", c.Provider)
+	}
+}
+
+// ResolveSecrets expands the secret placeholders of cfg using the provider
+// configured in its secrets settings
+func ResolveSecrets(cfg *Config) error {
+	provider, err := NewSecretProvider(cfg.Secrets)
+	if err != nil {
+		return err
+	}
+	return LoadSecrets(cfg, provider)
+}
+
+// LoadSecrets replaces {secret:key} placeholders in all string settings of
+// cfg with the values from provider, e.g. database.password set to
+// "{secret:db_password}". Secrets settings themselves are not expanded.
+func LoadSecrets(cfg *Config, provider SecretProvider) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		if t.Field(i).Name == "Secrets" {
+			continue
+		}
+		if err := expandSecrets(v.Field(i), configKey(t.Field(i).Name), provider); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandSecrets expands the placeholders in the strings reachable from v
+func expandSecrets(v reflect.Value, path string, provider SecretProvider) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if err := expandSecrets(v.Field(i), path+"."+configKey(t.Field(i).Name), provider); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandSecrets(v.Index(i), fmt.Sprintf("%s[%d]", path, i), provider); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if !strings.Contains(v.String(), "{secret:") {
+			return nil
+		}
+		expanded, err := expandSecretString(v.String(), provider)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(expanded)
+	}
+	return nil
+}
+
+// expandSecretString replaces the placeholders in s
+func expandSecretString(s string, provider SecretProvider) (string, error) {
+	var firstErr error
+	expanded := secretPlaceholder.ReplaceAllStringFunc(s, func(match string) string {
+		key := secretPlaceholder.FindStringSubmatch(match)[1]
+		value, err := provider.Get(key)
+		if err != nil && firstErr == nil {
+			if errors.Is(err, ErrSecretNotFound) {
+				err = fmt.Errorf("secret %q referenced by %s is not defined", key, match)
+			} else {
+				err = fmt.Errorf("failed to read secret %q: %w", key, err)
+			}
+			firstErr = err
+		}
+		return value
+	})
+	return expanded, firstErr
+}
+
+// configKey converts a config struct field name to its setting name, e.g.
+// JWTSecret to jwtSecret
+func configKey(field string) string {
+	runes := []rune(field)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	if upper > 1 && upper < len(runes) {
+		upper-- // Keep the first letter of the next word, e.g. the S of JWTSecret
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSecretsExpandsPlaceholders(t *testing.T) {
+	provider := NewFileSecretProvider(filepath.Join(t.TempDir(), "secrets"))
+	if err := provider.Set("db_password", "s3cr3t"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := provider.Set("jwt_secret", "jwt-0123456789abcdef"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	cfg := validTestConfig()
+	cfg.Database.Password = "{secret:db_password}"
+	cfg.Auth.JWTSecret = "{secret:jwt_secret}"
+	cfg.Server.AllowedOrigins = []string{"https://{secret:db_password}.example.com"}
+
+	if err := LoadSecrets(cfg, provider); err != nil {
+		t.Fatalf("LoadSecrets() error = %v", err)
+	}
+	if cfg.Database.Password != "s3cr3t" {
+		t.Errorf("database.password = %q, want s3cr3t", cfg.Database.Password)
+	}
+	if cfg.Auth.JWTSecret != "jwt-0123456789abcdef" {
+		t.Errorf("auth.jwtSecret = %q, want the secret", cfg.Auth.JWTSecret)
+	}
+	if cfg.Server.AllowedOrigins[0] != "https://s3cr3t.example.com" {
+		t.Errorf("server.allowedOrigins[0] = %q", cfg.Server.AllowedOrigins[0])
+	}
+	if cfg.Database.Username != "stumpfworks" {
+		t.Errorf("database.username changed to %q", cfg.Database.Username)
+	}
+
+	keys, err := provider.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if strings.Join(keys, ",") != "db_password,jwt_secret" {
+		t.Errorf("List() = %v", keys)
+	}
+}
+
+func TestLoadSecretsUnknownKey(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Database.Password = "{secret:db_password}"
+
+	err := LoadSecrets(cfg, NewFileSecretProvider(t.TempDir()))
+	if err == nil {
+		t.Fatal("LoadSecrets() error = nil, want an error for the undefined secret")
+	}
+	for _, want := range []string{"database.password", `"db_password"`, "not defined"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestEnvSecretProvider(t *testing.T) {
+	t.Setenv("STUMPFWORKS_SECRET_DB_PASSWORD", "from-env")
+
+	cfg := validTestConfig()
+	cfg.Database.Password = "{secret:db_password}"
+	if err := LoadSecrets(cfg, EnvSecretProvider{}); err != nil {
+		t.Fatalf("LoadSecrets() error = %v", err)
+	}
+	if cfg.Database.Password != "from-env" {
+		t.Errorf("database.password = %q, want from-env", cfg.Database.Password)
+	}
+}
+
+func TestFileSecretProviderRejectsReadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db_password"), []byte("s3cr3t\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewFileSecretProvider(dir)
+	if _, err := provider.Get("db_password"); err == nil {
+		t.Fatal("Get() of a world-readable secret file succeeded")
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "db_password"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	value, err := provider.Get("db_password")
+	if err != nil || value != "s3cr3t" {
+		t.Fatalf("Get() = %q, %v, want s3cr3t", value, err)
+	}
+	if _, err := provider.Get("../config"); err == nil {
+		t.Fatal("Get() accepted a key outside the secrets directory")
+	}
+}
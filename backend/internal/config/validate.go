@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap/zapcore"
+)
+
+// minSchedulerInterval is the smallest allowed scheduler reload interval
+const minSchedulerInterval = time.Minute
+
+// weakJWTSecrets are well-known secrets that are rejected in production
+var weakJWTSecrets = []string{
+	"dev-secret",
+	"dev-secret-please-change-in-production",
+	"dev-secret-change-in-production",
+	"change-me",
+	"changeme",
+	"secret",
+	"password",
+	"admin",
+}
+
+// ValidationErrors collects every configuration problem found by Validate
+type ValidationErrors []string
+
+// Error lists all violations, one per line
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return "invalid configuration: " + e[0]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d problems):", len(e))
+	for _, msg := range e {
+		b.WriteString("\n  - ")
+		b.WriteString(msg)
+	}
+	return b.String()
+}
+
+// Validate checks all configuration values and returns a ValidationErrors
+// listing every violation, so all problems can be fixed at once.
+func Validate(cfg *Config) error {
+	var errs ValidationErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	// Server
+	if cfg.Server.Port < 1 || cfg.Server.Port > 65535 {
+		add("server.port must be between 1 and 65535 (got %d)", cfg.Server.Port)
+	}
+	if cfg.Server.ReadTimeout <= 0 {
+		add("server.readTimeout must be greater than 0 (got %s)", cfg.Server.ReadTimeout)
+	}
+	if cfg.Server.WriteTimeout < 0 {
+		add("server.writeTimeout must not be negative (got %s)", cfg.Server.WriteTimeout)
+	}
+	if cfg.Server.IdleTimeout < 0 {
+		add("server.idleTimeout must not be negative (got %s)", cfg.Server.IdleTimeout)
+	}
+	if cfg.Server.MaxBodySizeBytes < 0 {
+		add("server.maxBodySizeBytes must not be negative (got %d)", cfg.Server.MaxBodySizeBytes)
+	}
+	if cfg.Server.MaxUploadSizeBytes < 0 {
+		add("server.maxUploadSizeBytes must not be negative (got %d)", cfg.Server.MaxUploadSizeBytes)
+	}
+	if cfg.Server.FileSearchTimeout < 0 {
+		add("server.fileSearchTimeout must not be negative (got %s)", cfg.Server.FileSearchTimeout)
+	}
+	if cfg.Server.EnableHTTPS {
+		validateTLSFile(&errs, "server.tlsCertFile", cfg.Server.TLSCertFile)
+		validateTLSFile(&errs, "server.tlsKeyFile", cfg.Server.TLSKeyFile)
+	}
+
+	// CORS
+	for _, origin := range cfg.Server.AllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			add("server.allowedOrigins: %q is not a valid origin: %v", origin, err)
+		}
+	}
+	if cfg.IsProduction() && len(cfg.Server.AllowedOrigins) == 0 {
+		add("server.allowedOrigins must be set in production")
+	}
+
+	// Auth
+	if cfg.Auth.JWTSecret == "" {
+		add("auth.jwtSecret is required")
+	} else if cfg.IsProduction() {
+		if len(cfg.Auth.JWTSecret) < 32 {
+			add("auth.jwtSecret must be at least 32 characters in production (got %d)", len(cfg.Auth.JWTSecret))
+		}
+		for _, weak := range weakJWTSecrets {
+			if cfg.Auth.JWTSecret == weak {
+				add("auth.jwtSecret is a weak/default secret (%q) - use a strong random secret in production", weak)
+				break
+			}
+		}
+	} else if cfg.IsDevelopment() && len(cfg.Auth.JWTSecret) < 16 {
+		fmt.Fprintf(os.Stderr, "WARNING: JWT secret is very short (%d chars) - recommended minimum: 32 chars\n", len(cfg.Auth.JWTSecret))
+	}
+
+	// Database
+	switch cfg.Database.Driver {
+	case "sqlite":
+		if cfg.Database.Path == "" {
+			add("database.path is required for SQLite")
+		}
+	case "postgres", "postgresql":
+		if cfg.Database.Host == "" {
+			add("database.host is required for PostgreSQL")
+		}
+		if cfg.Database.Database == "" {
+			add("database.database is required for PostgreSQL")
+		}
+		if cfg.Database.Username == "" {
+			add("database.username is required for PostgreSQL")
+		}
+		if cfg.Database.Port < 1 || cfg.Database.Port > 65535 {
+			add("database.port must be between 1 and 65535 (got %d)", cfg.Database.Port)
+		} else if _, err := pgconn.ParseConfig(cfg.Database.DSN()); err != nil {
+			add("database connection settings do not form a valid DSN: %v", err)
+		}
+	default:
+		add("database.driver must be \"sqlite\" or \"postgres\" (got %q)", cfg.Database.Driver)
+	}
+	pool := cfg.Database.Pool
+	if pool.MaxOpenConns < 0 {
+		add("database.pool.maxOpenConns must not be negative (got %d)", pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns < 0 {
+		add("database.pool.maxIdleConns must not be negative (got %d)", pool.MaxIdleConns)
+	} else if pool.MaxOpenConns > 0 && pool.MaxIdleConns > pool.MaxOpenConns {
+		add("database.pool.maxIdleConns (%d) must not exceed maxOpenConns (%d)", pool.MaxIdleConns, pool.MaxOpenConns)
+	}
+	if pool.ConnMaxLifetime < 0 {
+		add("database.pool.connMaxLifetime must not be negative (got %s)", pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime < 0 {
+		add("database.pool.connMaxIdleTime must not be negative (got %s)", pool.ConnMaxIdleTime)
+	}
+
+	// Logging
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(cfg.Logging.Level)); err != nil {
+		add("logging.level %q is invalid (use debug, info, warn, error, dpanic, panic or fatal)", cfg.Logging.Level)
+	}
+	if cfg.Logging.RetentionDays < 0 {
+		add("logging.retentionDays must not be negative (got %d)", cfg.Logging.RetentionDays)
+	}
+
+	// Scheduler
+	if cfg.Scheduler.ReloadInterval < minSchedulerInterval {
+		add("scheduler.reloadInterval must be at least %s (got %s)", minSchedulerInterval, cfg.Scheduler.ReloadInterval)
+	}
+
+	// Rate limiting
+	if cfg.RateLimit.Enabled {
+		if cfg.RateLimit.UserRequestsPerSecond <= 0 {
+			add("rateLimit.userRequestsPerSecond must be greater than 0 (got %g)", cfg.RateLimit.UserRequestsPerSecond)
+		}
+		if cfg.RateLimit.IPRequestsPerSecond <= 0 {
+			add("rateLimit.ipRequestsPerSecond must be greater than 0 (got %g)", cfg.RateLimit.IPRequestsPerSecond)
+		}
+		if cfg.RateLimit.Burst < 1 {
+			add("rateLimit.burst must be at least 1 (got %d)", cfg.RateLimit.Burst)
+		}
+	}
+
+	// Alerts
+	if cfg.Alerts.FailedLoginThreshold < 0 {
+		add("alerts.failedLoginThreshold must not be negative (got %d)", cfg.Alerts.FailedLoginThreshold)
+	}
+	if cfg.Alerts.RateLimitMinutes < 0 {
+		add("alerts.rateLimitMinutes must not be negative (got %d)", cfg.Alerts.RateLimitMinutes)
+	}
+
+	// Versioning
+	switch cfg.Versioning.Backend {
+	case "", "copy", "hardlink", "zfs": // "hardlink" is the former name of "copy"
+	default:
+		add("versioning.backend must be \"copy\" or \"zfs\" (got %q)", cfg.Versioning.Backend)
+	}
+	if cfg.Versioning.MaxVersions < 0 {
+		add("versioning.maxVersions must not be negative (got %d)", cfg.Versioning.MaxVersions)
+	}
+	for _, pattern := range cfg.Versioning.ExcludePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add("versioning.excludePatterns: %q is not a valid pattern", pattern)
+		}
+	}
+
+	// Automatic updates
+	if cfg.AutoUpdates.Enabled && len(strings.Fields(cfg.AutoUpdates.Schedule)) != 5 {
+		add("autoUpdates.schedule must be a cron expression with 5 fields (got %q)", cfg.AutoUpdates.Schedule)
+	}
+	if cfg.AutoUpdates.SecurityOnly && len(cfg.AutoUpdates.AllowedOrigins) == 0 {
+		add("autoUpdates.allowedOrigins must not be empty if securityOnly is set")
+	}
+
+	// ARP monitor
+	if cfg.ARPMonitor.Enabled && cfg.ARPMonitor.ChangeWindow <= 0 {
+		add("arpMonitor.changeWindow must be positive (got %s)", cfg.ARPMonitor.ChangeWindow)
+	}
+
+	// Marketplace
+	if cfg.Marketplace.RegistryURL != "" &&
+		!strings.HasPrefix(cfg.Marketplace.RegistryURL, "https://") && !strings.HasPrefix(cfg.Marketplace.RegistryURL, "http://") {
+		add("marketplace.registryURL must be an http or https URL (got %q)", cfg.Marketplace.RegistryURL)
+	}
+	if cfg.Marketplace.CacheTTL < 0 {
+		add("marketplace.cacheTTL must not be negative (got %s)", cfg.Marketplace.CacheTTL)
+	}
+
+	// Tracing
+	if cfg.Tracing.Enabled &&
+		!strings.HasPrefix(cfg.Tracing.OTLPEndpoint, "https://") && !strings.HasPrefix(cfg.Tracing.OTLPEndpoint, "http://") {
+		add("tracing.otlpEndpoint must be an http or https URL (got %q)", cfg.Tracing.OTLPEndpoint)
+	}
+
+	// Metrics export
+	if export := cfg.Metrics.Export; export.Enabled {
+		if export.Protocol != "influxdb" && export.Protocol != "prometheus-push" {
+			add("metrics.export.protocol must be \"influxdb\" or \"prometheus-push\" (got %q)", export.Protocol)
+		}
+		if !strings.HasPrefix(export.RemoteURL, "https://") && !strings.HasPrefix(export.RemoteURL, "http://") {
+			add("metrics.export.remoteURL must be an http or https URL (got %q)", export.RemoteURL)
+		}
+		if export.Protocol == "influxdb" && (export.Org == "" || export.Bucket == "") {
+			add("metrics.export.org and metrics.export.bucket are required for InfluxDB")
+		}
+		if export.PushInterval < 10*time.Second {
+			add("metrics.export.pushInterval must be at least 10s (got %s)", export.PushInterval)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	return Validate(c)
+}
+
+// validateTLSFile checks that a TLS certificate or key file exists
+func validateTLSFile(errs *ValidationErrors, key, path string) {
+	if path == "" {
+		*errs = append(*errs, fmt.Sprintf("%s is required when server.enableHTTPS is true", key))
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s %q does not exist or is not readable", key, path))
+		return
+	}
+	if info.IsDir() {
+		*errs = append(*errs, fmt.Sprintf("%s %q is a directory", key, path))
+	}
+}
+
+// validateOrigin checks that a CORS origin is "*" or an absolute http(s) URL.
+// A single "*" wildcard in the host (e.g. https://*.example.com) is allowed.
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(origin, "*", "wildcard", 1))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if u.Path != "" && u.Path != "/" {
+		return fmt.Errorf("origin must not contain a path")
+	}
+	return nil
+}
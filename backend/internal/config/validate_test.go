@@ -0,0 +1,108 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func validTestConfig() *Config {
+	return &Config{
+		App: AppConfig{Environment: "development"},
+		Server: ServerConfig{
+			Port:           8080,
+			ReadTimeout:    15 * time.Second,
+			WriteTimeout:   15 * time.Second,
+			AllowedOrigins: []string{"http://localhost:3000", "https://*.example.com"},
+		},
+		Database: DatabaseConfig{
+			Driver:   "postgres",
+			Host:     "localhost",
+			Port:     5432,
+			Database: "stumpfworks_nas",
+			Username: "stumpfworks",
+			Password: "secret with spaces",
+			SSLMode:  "disable",
+			Pool:     DatabasePoolConfig{MaxOpenConns: 25, MaxIdleConns: 5, ConnMaxLifetime: 5 * time.Minute},
+		},
+		Auth:      AuthConfig{JWTSecret: "test-secret-0123456789abcdef"},
+		Logging:   LoggingConfig{Level: "info"},
+		Scheduler: SchedulerConfig{ReloadInterval: time.Minute},
+	}
+}
+
+func TestValidateValidConfig(t *testing.T) {
+	if err := Validate(validTestConfig()); err != nil {
+		t.Fatalf("Validate() error = %v, want nil", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Server.Port = 70000
+	cfg.Server.ReadTimeout = -time.Second
+	cfg.Server.EnableHTTPS = true
+	cfg.Server.TLSCertFile = "/nonexistent/cert.pem"
+	cfg.Server.TLSKeyFile = ""
+	cfg.Server.AllowedOrigins = []string{"http://localhost:3000", "ftp://example.com", "not a url"}
+	cfg.Database.Port = 0
+	cfg.Logging.Level = "verbose"
+	cfg.Scheduler.ReloadInterval = 30 * time.Second
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("Validate() error = nil, want errors")
+	}
+
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Validate() error type = %T, want ValidationErrors", err)
+	}
+
+	expected := []string{
+		"server.port",
+		"server.readTimeout",
+		"server.tlsCertFile",
+		"server.tlsKeyFile",
+		`"ftp://example.com"`,
+		`"not a url"`,
+		"database.port",
+		"logging.level",
+		"scheduler.reloadInterval",
+	}
+
+	msg := err.Error()
+	for _, want := range expected {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error message missing %q:\n%s", want, msg)
+		}
+	}
+	if len(errs) != len(expected) {
+		t.Errorf("got %d errors, want %d:\n%s", len(errs), len(expected), msg)
+	}
+}
+
+func TestValidateOrigin(t *testing.T) {
+	tests := []struct {
+		origin  string
+		wantErr bool
+	}{
+		{"*", false},
+		{"http://localhost:5173", false},
+		{"https://nas.example.com", false},
+		{"https://*.example.com", false},
+		{"nas.example.com", true},
+		{"ftp://example.com", true},
+		{"https://example.com/path", true},
+		{"http://", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			err := validateOrigin(tt.origin)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOrigin(%q) error = %v, wantErr %v", tt.origin, err, tt.wantErr)
+			}
+		})
+	}
+}
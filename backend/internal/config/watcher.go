@@ -0,0 +1,207 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+)
+
+// Configurable is implemented by services that can apply configuration changes at runtime
+type Configurable interface {
+	ApplyConfig(cfg *Config) error
+}
+
+// ConfigurableFunc adapts a plain function to the Configurable interface
+type ConfigurableFunc func(cfg *Config) error
+
+// ApplyConfig calls f(cfg)
+func (f ConfigurableFunc) ApplyConfig(cfg *Config) error {
+	return f(cfg)
+}
+
+// ConfigChangedEvent describes a configuration reload
+type ConfigChangedEvent struct {
+	Path            string   `json:"path"`
+	Changed         []string `json:"changed"`
+	RestartRequired []string `json:"restartRequired,omitempty"`
+}
+
+// restartRequiredSections lists settings that only take effect after a restart
+var restartRequiredSections = map[string]func(c *Config) interface{}{
+	"server.host": func(c *Config) interface{} { return c.Server.Host },
+	"server.port": func(c *Config) interface{} { return c.Server.Port },
+	"server.timeouts": func(c *Config) interface{} {
+		return []time.Duration{c.Server.ReadTimeout, c.Server.WriteTimeout, c.Server.IdleTimeout}
+	},
+	"server.bodySizeLimits": func(c *Config) interface{} {
+		return []int64{c.Server.MaxBodySizeBytes, c.Server.MaxUploadSizeBytes}
+	},
+	"server.thumbnailCacheDir": func(c *Config) interface{} { return c.Server.ThumbnailCacheDir },
+	"database":                 func(c *Config) interface{} { return c.Database },
+	"auth":                     func(c *Config) interface{} { return c.Auth },
+	"app.environment":          func(c *Config) interface{} { return c.App.Environment },
+	"logging.development":      func(c *Config) interface{} { return c.Logging.Development },
+	"rateLimit":                func(c *Config) interface{} { return c.RateLimit },
+	"versioning":               func(c *Config) interface{} { return c.Versioning },
+	"secrets":                  func(c *Config) interface{} { return c.Secrets },
+}
+
+// reloadableSections lists settings that are applied without a restart
+var reloadableSections = map[string]func(c *Config) interface{}{
+	"logging.level":            func(c *Config) interface{} { return c.Logging.Level },
+	"logging.retentionDays":    func(c *Config) interface{} { return c.Logging.RetentionDays },
+	"server.allowedOrigins":    func(c *Config) interface{} { return c.Server.AllowedOrigins },
+	"server.fileSearchTimeout": func(c *Config) interface{} { return c.Server.FileSearchTimeout },
+	"alerts":                   func(c *Config) interface{} { return c.Alerts },
+	"scheduler":                func(c *Config) interface{} { return c.Scheduler },
+}
+
+// applyReloadable copies the reloadableSections of loaded into running.
+// Everything else, including the JWT secret and settings changed at
+// runtime, keeps its running value.
+func applyReloadable(running, loaded *Config) {
+	running.Logging.Level = loaded.Logging.Level
+	running.Logging.RetentionDays = loaded.Logging.RetentionDays
+	running.Server.AllowedOrigins = loaded.Server.AllowedOrigins
+	running.Server.FileSearchTimeout = loaded.Server.FileSearchTimeout
+	running.Alerts = loaded.Alerts
+	running.Scheduler = loaded.Scheduler
+}
+
+var (
+	registryMu    sync.RWMutex
+	configurables []Configurable
+)
+
+// RegisterConfigurable registers a service to be notified when the configuration is reloaded
+func RegisterConfigurable(c Configurable) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	configurables = append(configurables, c)
+}
+
+// Watcher reloads the configuration file when it changes, copies the
+// reloadable settings into the running configuration and applies it to all
+// registered Configurable services
+type Watcher struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	current *Config // Running configuration, updated in place
+	loaded  *Config // Last configuration read from the file
+}
+
+// NewWatcher creates a watcher for the given config file. current is the
+// configuration the server was started with.
+func NewWatcher(path string, current *Config, interval time.Duration) *Watcher {
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		current:  current,
+		loaded:   current,
+	}
+}
+
+// Start begins watching the config file until ctx is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	if err := sysutil.WatchFile(ctx, w.path, w.interval, w.Reload); err != nil {
+		return err
+	}
+	logger.Info("Watching config file for changes", zap.String("path", w.path))
+	return nil
+}
+
+// Reload re-reads and validates the config file and applies its reloadable
+// settings. Invalid configuration is rejected and the running configuration
+// is kept.
+func (w *Watcher) Reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cfg, err := load(w.path)
+	if err == nil {
+		err = ResolveSecrets(cfg)
+	}
+	if err == nil {
+		err = Validate(cfg)
+	}
+	if err != nil {
+		logger.Error("Config reload failed, keeping current configuration",
+			zap.String("path", w.path), zap.Error(err))
+		return
+	}
+
+	changed := diffSections(reloadableSections, w.loaded, cfg)
+	restart := diffSections(restartRequiredSections, w.loaded, cfg)
+	w.loaded = cfg
+
+	if len(changed) == 0 && len(restart) == 0 {
+		logger.Debug("Config file changed but no settings differ", zap.String("path", w.path))
+		return
+	}
+
+	for _, section := range restart {
+		logger.Warn("Config setting changed but requires a server restart to take effect",
+			zap.String("setting", section))
+	}
+
+	if w.current == nil {
+		w.current = cfg
+		GlobalConfig = cfg
+	} else {
+		applyReloadable(w.current, cfg)
+	}
+
+	registryMu.RLock()
+	targets := make([]Configurable, len(configurables))
+	copy(targets, configurables)
+	registryMu.RUnlock()
+
+	for _, c := range targets {
+		if err := c.ApplyConfig(w.current); err != nil {
+			logger.Error("Failed to apply reloaded config", zap.Error(err))
+		}
+	}
+
+	logger.Info("Configuration reloaded",
+		zap.Strings("changed", changed),
+		zap.Strings("restart_required", restart))
+
+	event := ConfigChangedEvent{Path: w.path, Changed: changed, RestartRequired: restart}
+	severity := events.SeverityInfo
+	if len(restart) > 0 {
+		severity = events.SeverityWarning
+	}
+	events.Publish(events.Event{
+		Type:     events.TypeConfig,
+		Action:   "changed",
+		Severity: severity,
+		Source:   "config",
+		Message:  "Configuration reloaded",
+		Data: map[string]interface{}{
+			"path":            event.Path,
+			"changed":         event.Changed,
+			"restartRequired": event.RestartRequired,
+		},
+	})
+}
+
+// diffSections returns the names of the sections that differ between old and new
+func diffSections(sections map[string]func(c *Config) interface{}, old, new *Config) []string {
+	var changed []string
+	for name, get := range sections {
+		if old == nil || !reflect.DeepEqual(get(old), get(new)) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
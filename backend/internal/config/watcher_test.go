@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+const testConfigTemplate = `app:
+  environment: development
+logging:
+  level: %s
+database:
+  driver: sqlite
+  path: ./test.db
+auth:
+  jwtSecret: test-secret-0123456789abcdef
+`
+
+func writeTestConfig(t *testing.T, path, level string) {
+	t.Helper()
+	content := []byte(fmt.Sprintf(testConfigTemplate, level))
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+func TestWatcherAppliesLogLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestConfig(t, path, "info")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := logger.InitLogger(cfg.Logging.Level, false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+
+	RegisterConfigurable(ConfigurableFunc(func(c *Config) error {
+		return logger.SetLevel(c.Logging.Level)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := NewWatcher(path, cfg, 50*time.Millisecond)
+	if err := watcher.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	writeTestConfig(t, path, "debug")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if logger.GetLevel() == "debug" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("log level = %q after 2s, want %q", logger.GetLevel(), "debug")
+}
+
+func TestWatcherReloadKeepsRestartRequiredSettings(t *testing.T) {
+	logger.InitLogger("info", false)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "app:\n  environment: development\nlogging:\n  level: warn\ndatabase:\n  driver: sqlite\n  path: ./reloaded.db\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	running, err := load(path)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	running.Logging.Level = "info"
+	running.Auth.JWTSecret = "running-secret-0123456789abcdef"
+	running.Database.Path = "./running.db"
+	running.Metrics.Export.Enabled = true
+	GlobalConfig = running
+	t.Cleanup(func() { GlobalConfig = nil })
+
+	NewWatcher(path, running, time.Minute).Reload()
+
+	if GlobalConfig != running {
+		t.Fatal("Reload replaced GlobalConfig")
+	}
+	if running.Logging.Level != "warn" {
+		t.Errorf("logging.level = %q, want the reloaded %q", running.Logging.Level, "warn")
+	}
+	if running.Auth.JWTSecret != "running-secret-0123456789abcdef" {
+		t.Errorf("auth.jwtSecret changed to %q on reload", running.Auth.JWTSecret)
+	}
+	if running.Database.Path != "./running.db" {
+		t.Errorf("database.path changed to %q on reload", running.Database.Path)
+	}
+	if !running.Metrics.Export.Enabled {
+		t.Error("metrics.export set at runtime was reset on reload")
+	}
+}
+
+func TestApplyReloadableCoversReloadableSections(t *testing.T) {
+	loaded, err := load("")
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	loaded.Logging.Level = "debug"
+	loaded.Logging.RetentionDays = 7
+	loaded.Server.AllowedOrigins = []string{"https://nas.example.com"}
+	loaded.Server.FileSearchTimeout = time.Minute
+	loaded.Alerts.FailedLoginThreshold = 3
+	loaded.Scheduler.ReloadInterval = time.Hour
+
+	running := &Config{}
+	applyReloadable(running, loaded)
+	if changed := diffSections(reloadableSections, running, loaded); len(changed) != 0 {
+		t.Errorf("applyReloadable does not copy %v", changed)
+	}
+}
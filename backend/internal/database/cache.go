@@ -0,0 +1,261 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"gorm.io/gorm"
+)
+
+// Cache keys
+const (
+	cacheKeyShareList = "shares:list"
+	cacheKeySharePfx  = "share:"
+	cacheKeyUIDPfx    = "uid:"
+	cacheKeyGIDPfx    = "gid:"
+)
+
+// CacheOption configures a Cache
+type CacheOption struct {
+	TTL        time.Duration // How long entries stay valid
+	MaxEntries int           // Maximum number of entries (0 = unlimited)
+}
+
+// DefaultCacheOption is used for the global query cache
+var DefaultCacheOption = CacheOption{
+	TTL:        30 * time.Second,
+	MaxEntries: 1000,
+}
+
+type cacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// CacheStats describes cache usage
+type CacheStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	Entries    int64  `json:"entries"`
+	TTLSeconds int64  `json:"ttlSeconds"`
+	MaxEntries int    `json:"maxEntries"`
+}
+
+// Cache is a small in-process TTL cache for frequently read, rarely changed
+// query results. Callers must treat cached values as read-only.
+type Cache struct {
+	opts    CacheOption
+	entries sync.Map // string -> cacheEntry
+	count   atomic.Int64
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}
+
+// NewCache creates a cache with the given options
+func NewCache(opts CacheOption) *Cache {
+	return &Cache{opts: opts}
+}
+
+// Get returns the cached value for key if present and not expired
+func (c *Cache) Get(key string) (interface{}, bool) {
+	if v, ok := c.entries.Load(key); ok {
+		entry := v.(cacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			c.hits.Add(1)
+			return entry.value, true
+		}
+		c.Invalidate(key)
+	}
+	c.misses.Add(1)
+	return nil, false
+}
+
+// Set stores a value. When the cache is full, expired entries are evicted
+// first, then arbitrary ones.
+func (c *Cache) Set(key string, value interface{}) {
+	if c.opts.MaxEntries > 0 && c.count.Load() >= int64(c.opts.MaxEntries) {
+		c.evict()
+	}
+
+	entry := cacheEntry{value: value, expiresAt: time.Now().Add(c.opts.TTL)}
+	if _, loaded := c.entries.Swap(key, entry); !loaded {
+		c.count.Add(1)
+	}
+}
+
+// GetOrLoad returns the cached value for key, calling load on a miss.
+// Errors are not cached.
+func (c *Cache) GetOrLoad(key string, load func() (interface{}, error)) (interface{}, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.Set(key, v)
+	return v, nil
+}
+
+// Invalidate removes a single entry. Call it whenever the underlying data changes.
+func (c *Cache) Invalidate(key string) {
+	if _, loaded := c.entries.LoadAndDelete(key); loaded {
+		c.count.Add(-1)
+	}
+}
+
+// InvalidatePrefix removes all entries whose key starts with prefix
+func (c *Cache) InvalidatePrefix(prefix string) {
+	c.entries.Range(func(k, _ interface{}) bool {
+		if strings.HasPrefix(k.(string), prefix) {
+			c.Invalidate(k.(string))
+		}
+		return true
+	})
+}
+
+// Flush removes all entries
+func (c *Cache) Flush() {
+	c.InvalidatePrefix("")
+}
+
+// Stats returns hit/miss counters and the current number of entries
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Entries:    c.count.Load(),
+		TTLSeconds: int64(c.opts.TTL / time.Second),
+		MaxEntries: c.opts.MaxEntries,
+	}
+}
+
+// PrometheusMetrics returns the cache counters in Prometheus text format
+func (c *Cache) PrometheusMetrics() string {
+	stats := c.Stats()
+	var b strings.Builder
+	b.WriteString("# HELP nasdb_cache_hits_total Number of query cache hits\n")
+	b.WriteString("# TYPE nasdb_cache_hits_total counter\n")
+	fmt.Fprintf(&b, "nasdb_cache_hits_total %d\n", stats.Hits)
+	b.WriteString("# HELP nasdb_cache_misses_total Number of query cache misses\n")
+	b.WriteString("# TYPE nasdb_cache_misses_total counter\n")
+	fmt.Fprintf(&b, "nasdb_cache_misses_total %d\n", stats.Misses)
+	return b.String()
+}
+
+// evict makes room for one entry
+func (c *Cache) evict() {
+	now := time.Now()
+	var victim string
+	c.entries.Range(func(k, v interface{}) bool {
+		if now.After(v.(cacheEntry).expiresAt) {
+			c.Invalidate(k.(string))
+		} else if victim == "" {
+			victim = k.(string)
+		}
+		return true
+	})
+	if c.count.Load() >= int64(c.opts.MaxEntries) && victim != "" {
+		c.Invalidate(victim)
+	}
+}
+
+// CachedDB wraps the database with a query cache for shares and UID/GID
+// lookups, which are read on every file access check
+type CachedDB struct {
+	*Cache
+	db *gorm.DB
+}
+
+var cached = NewCachedDB(nil, DefaultCacheOption)
+
+// NewCachedDB creates a cached view of db
+func NewCachedDB(db *gorm.DB, opts CacheOption) *CachedDB {
+	return &CachedDB{Cache: NewCache(opts), db: db}
+}
+
+// Cached returns the global cached database
+func Cached() *CachedDB {
+	return cached
+}
+
+// ListShares returns all shares
+func (c *CachedDB) ListShares() ([]models.Share, error) {
+	v, err := c.GetOrLoad(cacheKeyShareList, func() (interface{}, error) {
+		if c.db == nil {
+			return nil, fmt.Errorf("database not initialized")
+		}
+		var shares []models.Share
+		if err := c.db.Find(&shares).Error; err != nil {
+			return nil, err
+		}
+		return shares, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]models.Share(nil), v.([]models.Share)...), nil
+}
+
+// GetShare returns a share by ID. gorm.ErrRecordNotFound is returned
+// unwrapped for unknown IDs.
+func (c *CachedDB) GetShare(id string) (*models.Share, error) {
+	v, err := c.GetOrLoad(cacheKeySharePfx+id, func() (interface{}, error) {
+		if c.db == nil {
+			return nil, fmt.Errorf("database not initialized")
+		}
+		var share models.Share
+		if err := c.db.First(&share, id).Error; err != nil {
+			return nil, err
+		}
+		return share, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	share := v.(models.Share)
+	return &share, nil
+}
+
+// InvalidateShares drops all cached share data. Call it after any share write.
+func (c *CachedDB) InvalidateShares() {
+	c.Invalidate(cacheKeyShareList)
+	c.InvalidatePrefix(cacheKeySharePfx)
+}
+
+// LookupUID returns the UID of a system user
+func (c *CachedDB) LookupUID(username string) (int, error) {
+	v, err := c.GetOrLoad(cacheKeyUIDPfx+username, func() (interface{}, error) {
+		return sysutil.LookupUID(username)
+	})
+	if err != nil {
+		return -1, err
+	}
+	return v.(int), nil
+}
+
+// LookupGID returns the GID of a system group
+func (c *CachedDB) LookupGID(groupname string) (int, error) {
+	v, err := c.GetOrLoad(cacheKeyGIDPfx+groupname, func() (interface{}, error) {
+		return sysutil.LookupGID(groupname)
+	})
+	if err != nil {
+		return -1, err
+	}
+	return v.(int), nil
+}
+
+// InvalidateUser drops the cached UID of a system user
+func (c *CachedDB) InvalidateUser(username string) {
+	c.Invalidate(cacheKeyUIDPfx + username)
+}
+
+// InvalidateGroup drops the cached GID of a system group
+func (c *CachedDB) InvalidateGroup(groupname string) {
+	c.Invalidate(cacheKeyGIDPfx + groupname)
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestCachedDBServesSharesWithoutQuery(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cache.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Share{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+
+	share := models.Share{Name: "media", Path: "/mnt/media", Type: "smb"}
+	if err := db.Create(&share).Error; err != nil {
+		t.Fatalf("failed to create share: %v", err)
+	}
+	id := strconv.FormatUint(uint64(share.ID), 10)
+
+	queries := 0
+	db.Callback().Query().After("gorm:query").Register("test:count_queries", func(*gorm.DB) {
+		queries++
+	})
+
+	c := NewCachedDB(db, DefaultCacheOption)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetShare(id); err != nil {
+			t.Fatalf("GetShare() error = %v", err)
+		}
+		if _, err := c.ListShares(); err != nil {
+			t.Fatalf("ListShares() error = %v", err)
+		}
+	}
+	if queries != 2 {
+		t.Errorf("ran %d queries, want 2 (second reads served from cache)", queries)
+	}
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 2 hits and 2 misses", stats)
+	}
+
+	if err := db.Model(&share).Update("path", "/mnt/archive").Error; err != nil {
+		t.Fatalf("failed to update share: %v", err)
+	}
+	c.InvalidateShares()
+
+	got, err := c.GetShare(id)
+	if err != nil {
+		t.Fatalf("GetShare() error = %v", err)
+	}
+	if queries != 3 {
+		t.Errorf("ran %d queries, want 3 (invalidated entry reloaded)", queries)
+	}
+	if got.Path != "/mnt/archive" {
+		t.Errorf("Path = %q, want updated value %q", got.Path, "/mnt/archive")
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -46,16 +47,7 @@ func Initialize(cfg *config.Config) error {
 	case "sqlite":
 		DB, err = gorm.Open(sqlite.Open(cfg.Database.Path), gormConfig)
 	case "postgres", "postgresql":
-		// Build PostgreSQL DSN
-		dsn := fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Database.Host,
-			cfg.Database.Port,
-			cfg.Database.Username,
-			cfg.Database.Password,
-			cfg.Database.Database,
-			cfg.Database.SSLMode,
-		)
+		dsn := cfg.Database.DSN()
 		DB, err = gorm.Open(postgres.Open(dsn), gormConfig)
 	default:
 		return fmt.Errorf("unsupported database driver: %s (supported: sqlite, postgres)", cfg.Database.Driver)
@@ -71,27 +63,15 @@ func Initialize(cfg *config.Config) error {
 		return fmt.Errorf("failed to get database instance: %w", err)
 	}
 
-	// Use config values or defaults
-	maxOpenConns := cfg.Database.MaxOpenConns
-	if maxOpenConns == 0 {
-		maxOpenConns = 25
-	}
-	maxIdleConns := cfg.Database.MaxIdleConns
-	if maxIdleConns == 0 {
-		maxIdleConns = 5
-	}
-	connMaxLifetime, err := time.ParseDuration(cfg.Database.ConnMaxLifetime)
-	if err != nil || connMaxLifetime == 0 {
-		connMaxLifetime = 5 * time.Minute
-	}
-
-	sqlDB.SetMaxIdleConns(maxIdleConns)
-	sqlDB.SetMaxOpenConns(maxOpenConns)
-	sqlDB.SetConnMaxLifetime(connMaxLifetime)
+	ConfigurePool(sqlDB, cfg.Database.Pool)
+	cached.db = DB
+	poolConfig = cfg.Database.Pool
+	sysutil.HealthChecks.Register("Database connection pool", true, "database", CheckPoolUsage)
 
 	logger.Info("Database connected successfully",
 		zap.String("driver", cfg.Database.Driver),
-		zap.String("path", cfg.Database.Path))
+		zap.String("path", cfg.Database.Path),
+		zap.Int("maxOpenConns", cfg.Database.Pool.MaxOpenConns))
 
 	// Run migrations
 	if err := RunMigrations(); err != nil {
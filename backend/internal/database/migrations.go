@@ -20,17 +20,65 @@ func RunMigrations() error {
 		&models.AuditLog{},
 		&models.FailedLoginAttempt{},
 		&models.IPBlock{},
+		&models.AccountLockout{},
+		&models.FailedLoginPolicy{},
 		&models.AlertConfig{},
 		&models.AlertLog{},
+		&models.MaintenanceWindow{},
+		&models.SuppressedAlert{},
+		&models.AlertRule{},
 		&models.ScheduledTask{},
 		&models.TaskExecution{},
 		&models.TwoFactorAuth{},
 		&models.TwoFactorBackupCode{},
 		&models.TwoFactorAttempt{},
+		&models.MFAPolicy{},
 		&models.SystemMetric{},
 		&models.HealthScore{},
 		&models.MonitoringConfig{},
+		&models.CustomMetric{},
 		&models.AddonInstallation{},
+		&models.RateLimit{},
+		&models.AccessLog{},
+		&models.ACLJob{},
+		&models.UploadSession{},
+		&models.SSHKey{},
+		&models.GroupMembership{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.UserRole{},
+		&models.BackupCode{},
+		&models.ShareAccessLog{},
+		&models.DefaultShareProvision{},
+		&models.PortForwardRule{},
+		&models.DDNSConfig{},
+		&models.DDNSState{},
+		&models.ClusterNode{},
+		&models.EncryptedDataset{},
+		&models.ContainerMigration{},
+		&models.MarketplaceAddon{},
+		&models.VPNPeer{},
+		&models.IPAMPool{},
+		&models.IPAllocation{},
+		&models.PushSubscription{},
+		&models.VAPIDKey{},
+		&models.DedupeJob{},
+		&models.ClusterLock{},
+		&models.AuditRetentionPolicy{},
+		&models.AuditMetadata{},
+		&models.VolumeHealthCheck{},
+		&models.NetworkNamespace{},
+		&models.ShareTransfer{},
+		&models.TelemetryState{},
+		&models.VXLANInterface{},
+		&models.ResourceGovernorConfig{},
+		&models.ContainerPriority{},
+		&models.SAMLConfig{},
+		&models.SetupState{},
+		&models.BenchmarkResult{},
+		&models.CloudBackupRemote{},
+		&models.ARPEntry{},
+		&models.ARPWhitelistEntry{},
 		// Add more models here as they are created
 	); err != nil {
 		return err
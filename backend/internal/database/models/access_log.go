@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// AccessLog is a single HTTP request recorded by the access log middleware
+type AccessLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+
+	Method         string  `gorm:"size:10;not null" json:"method"`
+	Path           string  `gorm:"size:1024;not null;index" json:"path"`
+	Query          string  `gorm:"type:text" json:"query,omitempty"`
+	RemoteIP       string  `gorm:"size:45;index" json:"remoteIp"` // IPv6 max length
+	StatusCode     int     `gorm:"not null;index" json:"statusCode"`
+	ResponseTimeMs float64 `json:"responseTimeMs"`
+	UserID         *uint   `gorm:"index" json:"userId"` // Null for unauthenticated requests
+	RequestID      string  `gorm:"size:100" json:"requestId,omitempty"`
+}
+
+// TableName specifies the table name for AccessLog model
+func (AccessLog) TableName() string {
+	return "access_logs"
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ACL job statuses
+const (
+	ACLJobStatusRunning   = "running"
+	ACLJobStatusCompleted = "completed"
+	ACLJobStatusFailed    = "failed"
+)
+
+// ACLJob tracks a background ACL inheritance run
+type ACLJob struct {
+	ID        string    `gorm:"primaryKey;size:64" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Root  string `gorm:"size:4096;not null" json:"root"`
+	Depth int    `json:"depth"` // -1 = unlimited
+
+	Status         string     `gorm:"size:20;not null;index" json:"status"` // running, completed, failed
+	PathsProcessed int        `json:"pathsProcessed"`
+	CurrentPath    string     `gorm:"size:4096" json:"currentPath,omitempty"`
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+}
+
+// TableName specifies the table name for ACLJob
+func (ACLJob) TableName() string {
+	return "acl_jobs"
+}
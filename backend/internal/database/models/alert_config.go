@@ -53,10 +53,22 @@ type AlertLog struct {
 
 // Alert types
 const (
-	AlertTypeFailedLogin   = "failed_login"
-	AlertTypeIPBlock       = "ip_block"
-	AlertTypeCriticalEvent = "critical_event"
-	AlertTypeSystemError   = "system_error"
+	AlertTypeFailedLogin    = "failed_login"
+	AlertTypeIPBlock        = "ip_block"
+	AlertTypeCriticalEvent  = "critical_event"
+	AlertTypeSystemError    = "system_error"
+	AlertTypeUserWelcome    = "user_welcome"
+	AlertTypeInactiveUser   = "inactive_user"
+	AlertTypeDDNSFailure    = "ddns_failure"
+	AlertTypeScrubErrors    = "zfs_scrub_errors"
+	AlertTypeSMARTTest      = "smart_test_failure"
+	AlertTypeMaintenance    = "maintenance_summary"
+	AlertTypeMetricRule     = "metric_rule"
+	AlertTypeDRBDSplitBrain = "drbd_split_brain"
+	AlertTypeVolumeHealth   = "volume_health"
+	AlertTypeStorageQuota   = "storage_quota_warning"
+	AlertTypeSystemUpdates  = "system_updates"
+	AlertTypeARPSpoofing    = "arp_spoofing"
 )
 
 // Alert channels
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// AlertRule raises an alert when its expression over system metrics holds
+type AlertRule struct {
+	ID          uint                `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+	Name        string              `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Enabled     bool                `json:"enabled"`
+	Expression  AlertRuleExpression `gorm:"serializer:json" json:"expression"`
+	LastFiredAt *time.Time          `json:"lastFiredAt,omitempty"`
+}
+
+// TableName specifies the table name for AlertRule
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// AlertRuleExpression combines conditions with "and" or "or"
+type AlertRuleExpression struct {
+	Operator   string           `json:"operator"`
+	Conditions []AlertCondition `json:"conditions"`
+}
+
+// AlertCondition compares a system metric, named by its JSON field such as
+// cpuUsage, against a threshold. The condition only holds once the
+// comparison has been true for Window.
+type AlertCondition struct {
+	MetricName string        `json:"metricName"`
+	Comparator string        `json:"comparator"` // >, >=, <, <=, ==, !=
+	Threshold  float64       `json:"threshold"`
+	Window     time.Duration `json:"window"` // Nanoseconds
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ARPEntry is the MAC address an IP on the local network last announced in
+// an ARP reply
+type ARPEntry struct {
+	IP          string    `gorm:"primaryKey;size:15" json:"ip"`
+	MAC         string    `gorm:"size:17;not null" json:"mac"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	ChangeCount int       `json:"changeCount"` // How often the IP moved to another MAC
+}
+
+// TableName specifies the table name for ARPEntry model
+func (ARPEntry) TableName() string {
+	return "arp_entries"
+}
+
+// ARPWhitelistEntry is a MAC an IP may move to without an ARP spoofing
+// alert, e.g. the other node of a failover pair sharing a virtual IP
+type ARPWhitelistEntry struct {
+	IP        string    `gorm:"primaryKey;size:15" json:"ip"`
+	MAC       string    `gorm:"primaryKey;size:17" json:"mac"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for ARPWhitelistEntry model
+func (ARPWhitelistEntry) TableName() string {
+	return "arp_whitelist"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// AuditRetentionPolicy limits how long and how many audit logs are kept.
+// There is a single policy row.
+type AuditRetentionPolicy struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	MaxAgeDays          int    `json:"maxAgeDays"`                  // Delete logs older than this; 0 keeps them regardless of age
+	MaxRecords          int    `json:"maxRecords"`                  // Keep only the newest logs; 0 keeps any number
+	ArchiveBeforeDelete bool   `json:"archiveBeforeDelete"`         // Export expiring logs before deleting them
+	ArchivePath         string `gorm:"size:500" json:"archivePath"` // Directory for gzip compressed JSONL archives
+}
+
+// TableName specifies the table name for AuditRetentionPolicy model
+func (AuditRetentionPolicy) TableName() string {
+	return "audit_retention_policies"
+}
+
+// AuditMetadata stores state of the audit log service, such as when
+// retention was last enforced
+type AuditMetadata struct {
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `gorm:"size:255" json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for AuditMetadata model
+func (AuditMetadata) TableName() string {
+	return "audit_metadata"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// BackupCode is a one-time 2FA recovery code. Only a hash of the code is
+// stored; the plaintext is shown once when the codes are generated.
+type BackupCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	CodeHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // Hex BLAKE3 hash
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// TableName specifies the table name for BackupCode model
+func (BackupCode) TableName() string {
+	return "backup_codes"
+}
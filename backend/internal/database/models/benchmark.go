@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// BenchmarkResult is one measurement of a stumpfctl system benchmark run
+type BenchmarkResult struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	RunID     string    `gorm:"size:36;not null;index" json:"runId"` // Shared by the results of a run
+	Hostname  string    `gorm:"size:255" json:"hostname"`
+	Test      string    `gorm:"size:20;not null;index" json:"test"` // disk, network or cpu
+	Target    string    `gorm:"size:500" json:"target"`             // Volume, iperf3 server, "loopback" or the CPU threads
+	Metric    string    `gorm:"size:50;not null" json:"metric"`     // e.g. write, read, send, receive, sha256
+	Value     float64   `json:"value"`
+	Unit      string    `gorm:"size:20" json:"unit"` // MB/s or Mbit/s
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
+
+// TableName specifies the table name for BenchmarkResult model
+func (BenchmarkResult) TableName() string {
+	return "benchmark_results"
+}
+
+// Benchmark tests
+const (
+	BenchmarkTestDisk    = "disk"
+	BenchmarkTestNetwork = "network"
+	BenchmarkTestCPU     = "cpu"
+)
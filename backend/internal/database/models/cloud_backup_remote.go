@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CloudBackupRemote is an rclone remote cloud backup jobs can upload to.
+// The remotes themselves, with their credentials, live in the rclone
+// config; only their names are recorded.
+type CloudBackupRemote struct {
+	Name      string    `gorm:"primaryKey;size:64" json:"name"`
+	Type      string    `gorm:"size:32;not null" json:"type"` // rclone backend, e.g. s3 or b2
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for CloudBackupRemote model
+func (CloudBackupRemote) TableName() string {
+	return "cloud_backup_remotes"
+}
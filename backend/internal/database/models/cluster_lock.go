@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ClusterLock is a named lock shared by the nodes of a cluster through the
+// database. A lock whose ExpiresAt has passed may be taken over.
+type ClusterLock struct {
+	Name      string    `gorm:"primaryKey;size:255" json:"name"`
+	HolderID  string    `gorm:"size:255;not null" json:"holderId"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+}
+
+// TableName specifies the table name for ClusterLock
+func (ClusterLock) TableName() string {
+	return "cluster_locks"
+}
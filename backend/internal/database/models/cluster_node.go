@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ClusterNode is the fencing configuration of an HA cluster node
+type ClusterNode struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Name        string    `gorm:"size:255;not null;uniqueIndex" json:"name"` // Pacemaker/DRBD node name
+	FenceMethod string    `gorm:"size:20" json:"fenceMethod"`                // ipmi, apc-pdu, aws-ec2
+	FenceConfig string    `gorm:"type:text" json:"-"`                        // JSON map of the fence agent options, contains credentials
+}
+
+// TableName specifies the table name for ClusterNode model
+func (ClusterNode) TableName() string {
+	return "cluster_nodes"
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Container migration statuses
+const (
+	MigrationStatusCheckpointing = "checkpointing"
+	MigrationStatusTransferring  = "transferring"
+	MigrationStatusRestoring     = "restoring"
+	MigrationStatusCompleted     = "completed"
+	MigrationStatusFailed        = "failed"
+)
+
+// ContainerMigration tracks the live migration of an LXC container to another host
+type ContainerMigration struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	ContainerID string    `gorm:"size:255;not null;index" json:"containerId"`
+	SourceHost  string    `gorm:"size:255;not null" json:"sourceHost"`
+	TargetHost  string    `gorm:"size:255;not null" json:"targetHost"`
+	StartedAt   time.Time `json:"startedAt"`
+
+	Status      string     `gorm:"size:20;not null;index" json:"status"` // checkpointing, transferring, restoring, completed, failed
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// TableName specifies the table name for ContainerMigration
+func (ContainerMigration) TableName() string {
+	return "container_migrations"
+}
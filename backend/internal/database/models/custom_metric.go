@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// CustomMetric is a user-defined metric exported to Prometheus. Its
+// expression is a Go template evaluated against each collected SystemMetric.
+type CustomMetric struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Name       string    `gorm:"uniqueIndex;not null" json:"name"`
+	Expression string    `gorm:"type:text;not null" json:"expression"`
+	Help       string    `json:"help"`
+	Type       string    `gorm:"not null" json:"type"` // gauge or counter
+}
+
+// TableName specifies the table name for CustomMetric
+func (CustomMetric) TableName() string {
+	return "custom_metrics"
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// DDNSConfig is the dynamic DNS configuration. There is at most one row.
+type DDNSConfig struct {
+	ID                    uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt             time.Time `json:"createdAt"`
+	UpdatedAt             time.Time `json:"updatedAt"`
+	Enabled               bool      `gorm:"not null" json:"enabled"`
+	Provider              string    `gorm:"size:50;not null" json:"provider"` // cloudflare, namecheap, dyndns
+	Domain                string    `gorm:"size:255;not null" json:"domain"`
+	Subdomain             string    `gorm:"size:255" json:"subdomain"`
+	Credential            string    `gorm:"size:512" json:"-"` // API token or password, never exposed
+	UpdateIntervalMinutes int       `gorm:"not null" json:"updateIntervalMinutes"`
+}
+
+// TableName specifies the table name for DDNSConfig model
+func (DDNSConfig) TableName() string {
+	return "ddns_configs"
+}
+
+// DDNSState is the last known public IP and the result of the last update
+// of the dynamic DNS record. There is at most one row.
+type DDNSState struct {
+	ID             uint       `gorm:"primaryKey" json:"-"`
+	Hostname       string     `gorm:"size:255" json:"hostname"`
+	CurrentIP      string     `gorm:"size:45" json:"currentIp"`             // Public IP the record points to
+	DetectedIP     string     `gorm:"size:45" json:"detectedIp"`            // Public IP at the last check
+	LastUpdate     *time.Time `json:"lastUpdate,omitempty"`                 // Last successful record update
+	LastCheck      *time.Time `json:"lastCheck,omitempty"`                  // Last public IP check
+	LastError      string     `gorm:"size:1000" json:"lastError,omitempty"` // Empty after a successful update
+	FailedAttempts int        `json:"failedAttempts"`
+}
+
+// TableName specifies the table name for DDNSState model
+func (DDNSState) TableName() string {
+	return "ddns_states"
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Dedupe job statuses
+const (
+	DedupeJobStatusRunning   = "running"
+	DedupeJobStatusCompleted = "completed"
+	DedupeJobStatusFailed    = "failed"
+)
+
+// DuplicateGroup is a set of files with identical content
+type DuplicateGroup struct {
+	Files []string `json:"files"`
+	Size  int64    `json:"size"` // Bytes per file
+	Hash  string   `json:"hash"` // Content hash with the scan's algorithm
+}
+
+// DedupeReport lists the duplicate files found by a scan
+type DedupeReport struct {
+	TotalDuplicates int              `json:"totalDuplicates"` // Files beyond the first of each group
+	WastedBytes     int64            `json:"wastedBytes"`     // Space used by those files
+	LinkedFiles     int              `json:"linkedFiles"`     // Duplicates replaced by hardlinks
+	Groups          []DuplicateGroup `json:"groups"`
+}
+
+// DedupeJob tracks a background duplicate file scan and caches its report
+type DedupeJob struct {
+	ID        string    `gorm:"primaryKey;size:64" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Root           string `gorm:"size:4096;not null" json:"root"`
+	Algorithm      string `gorm:"size:10;not null" json:"algorithm"` // sha256 or md5
+	MinSize        int64  `json:"minSize"`
+	LinkDuplicates bool   `json:"linkDuplicates"`
+	Tool           string `gorm:"size:20" json:"tool"` // jdupes or fdupes
+
+	Status      string        `gorm:"size:20;not null;index" json:"status"` // running, completed, failed
+	Progress    int           `json:"progress"`                             // Percent, as reported by the tool
+	Report      *DedupeReport `gorm:"serializer:json" json:"report,omitempty"`
+	Error       string        `gorm:"type:text" json:"error,omitempty"`
+	CompletedAt *time.Time    `json:"completedAt,omitempty"`
+}
+
+// TableName specifies the table name for DedupeJob
+func (DedupeJob) TableName() string {
+	return "dedupe_jobs"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// DefaultShareProvision records that a built-in default share was created,
+// and with which configuration. A share is only provisioned again when its
+// configuration changes, so shares removed by the admin stay removed.
+type DefaultShareProvision struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ShareName     string    `gorm:"size:255;not null;uniqueIndex" json:"shareName"`
+	ProvisionedAt time.Time `gorm:"not null" json:"provisionedAt"`
+	Checksum      string    `gorm:"size:64;not null" json:"checksum"` // SHA-256 of the share spec
+}
+
+// TableName specifies the table name for DefaultShareProvision model
+func (DefaultShareProvision) TableName() string {
+	return "default_share_provisions"
+}
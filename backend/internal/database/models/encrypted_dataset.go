@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// EncryptedDataset records a ZFS dataset created with native encryption and
+// how its key is loaded
+type EncryptedDataset struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+	Dataset            string    `gorm:"size:255;not null;uniqueIndex" json:"dataset"`
+	Algorithm          string    `gorm:"size:20;not null" json:"algorithm"`  // aes-256-gcm, aes-256-ccm
+	KeyFormat          string    `gorm:"size:20;not null" json:"keyFormat"`  // passphrase, raw, hex
+	KeyLocation        string    `gorm:"size:512" json:"keyLocation"`        // prompt or file:// URI
+	RequiresPassphrase bool      `gorm:"not null" json:"requiresPassphrase"` // The key must be entered to mount after boot
+}
+
+// TableName specifies the table name for EncryptedDataset model
+func (EncryptedDataset) TableName() string {
+	return "encrypted_datasets"
+}
@@ -66,3 +66,40 @@ const (
 	FailureReasonInvalidToken     = "invalid_token"
 	FailureReasonTokenExpired     = "token_expired"
 )
+
+// AccountLockout represents a username locked after too many failed logins
+type AccountLockout struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"` // Also set when the lockout is lifted
+	ExpiresAt time.Time `gorm:"index" json:"expiresAt"`
+
+	Username string `gorm:"size:100;not null;uniqueIndex" json:"username"`
+	Reason   string `gorm:"size:255" json:"reason"`
+	Attempts int    `gorm:"default:0" json:"attempts"`
+	IsActive bool   `gorm:"default:true;index" json:"isActive"`
+}
+
+// TableName specifies the table name for AccountLockout model
+func (AccountLockout) TableName() string {
+	return "account_lockouts"
+}
+
+// FailedLoginPolicy controls when failed logins lock out an IP address or username.
+// The table holds a single row.
+type FailedLoginPolicy struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	MaxAttempts       int           `gorm:"not null" json:"maxAttempts"`   // Failed attempts before a lockout
+	WindowMinutes     int           `gorm:"not null" json:"windowMinutes"` // Time window for counting attempts
+	LockoutDuration   time.Duration `gorm:"not null" json:"-"`             // How long a lockout lasts
+	LockoutByIP       bool          `json:"lockoutByIP"`
+	LockoutByUsername bool          `json:"lockoutByUsername"`
+	WhitelistIPs      []string      `gorm:"serializer:json" json:"whitelistIPs"` // IPs and CIDR ranges that are never locked out
+}
+
+// TableName specifies the table name for FailedLoginPolicy model
+func (FailedLoginPolicy) TableName() string {
+	return "failed_login_policies"
+}
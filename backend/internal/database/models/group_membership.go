@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// GroupMembership makes the child group a member of the parent group.
+// Members of the child are transitively members of the parent.
+type GroupMembership struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	ParentID uint `gorm:"not null;uniqueIndex:idx_group_memberships_parent_child" json:"parentId"`
+	ChildID  uint `gorm:"not null;uniqueIndex:idx_group_memberships_parent_child;index" json:"childId"`
+}
+
+// TableName specifies the table name for GroupMembership model
+func (GroupMembership) TableName() string {
+	return "group_memberships"
+}
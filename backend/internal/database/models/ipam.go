@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// IPAMPool is a subnet from which addresses are handed out to VPN peers and
+// containers
+type IPAMPool struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	Name           string    `gorm:"size:64;uniqueIndex;not null" json:"name"`
+	Subnet         string    `gorm:"size:43;not null" json:"subnet"` // CIDR, e.g. 10.8.0.0/24
+	GatewayIP      string    `gorm:"size:45" json:"gatewayIp"`
+	DNSServers     []string  `gorm:"serializer:json" json:"dnsServers"`
+	ExcludedRanges []string  `gorm:"serializer:json" json:"excludedRanges"` // CIDRs, single IPs or first-last ranges
+}
+
+// TableName specifies the table name for IPAMPool model
+func (IPAMPool) TableName() string {
+	return "ipam_pools"
+}
+
+// IPAllocation is an address of an IPAM pool in use
+type IPAllocation struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	PoolName     string    `gorm:"size:64;not null;uniqueIndex:idx_ip_allocation" json:"poolName"`
+	AllocatedIP  string    `gorm:"size:45;not null;uniqueIndex:idx_ip_allocation" json:"allocatedIp"`
+	AllocatedFor string    `gorm:"size:255" json:"allocatedFor"` // e.g. wireguard:wg0:<public key> or lxc:<name>
+	AllocatedAt  time.Time `gorm:"not null" json:"allocatedAt"`
+}
+
+// TableName specifies the table name for IPAllocation model
+func (IPAllocation) TableName() string {
+	return "ip_allocations"
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// MaintenanceWindow mutes alerts during scheduled maintenance
+type MaintenanceWindow struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+	Name               string     `gorm:"size:255;not null" json:"name"`
+	Reason             string     `gorm:"type:text" json:"reason"`
+	Start              time.Time  `gorm:"not null;index" json:"start"`
+	End                time.Time  `gorm:"not null;index" json:"end"`
+	MatchingAlertTypes []string   `gorm:"serializer:json" json:"matchingAlertTypes"`
+	AllAlerts          bool       `json:"allAlerts"`
+	SummarySentAt      *time.Time `json:"summarySentAt,omitempty"` // When the digest of suppressed alerts was emailed
+}
+
+// TableName specifies the table name for MaintenanceWindow
+func (MaintenanceWindow) TableName() string {
+	return "maintenance_windows"
+}
+
+// Matches reports whether the window mutes alertType at t
+func (w *MaintenanceWindow) Matches(alertType string, t time.Time) bool {
+	if t.Before(w.Start) || !t.Before(w.End) {
+		return false
+	}
+	if w.AllAlerts {
+		return true
+	}
+	for _, matching := range w.MatchingAlertTypes {
+		if matching == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+// SuppressedAlert is an alert muted by a maintenance window, kept for review
+type SuppressedAlert struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+	WindowID  uint      `gorm:"not null;index" json:"windowId"`
+	AlertType string    `gorm:"size:100;not null" json:"alertType"`
+	Subject   string    `gorm:"size:255;not null" json:"subject"`
+	Body      string    `gorm:"type:text" json:"body"` // Plain text body
+}
+
+// TableName specifies the table name for SuppressedAlert
+func (SuppressedAlert) TableName() string {
+	return "suppressed_alerts"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MarketplaceAddon caches an addon listed in the remote marketplace registry
+type MarketplaceAddon struct {
+	ID          string    `gorm:"primaryKey;size:100" json:"id"`
+	Name        string    `gorm:"size:255" json:"name"`
+	Category    string    `gorm:"size:50;index" json:"category"`
+	Version     string    `gorm:"size:50" json:"version"`
+	Description string    `gorm:"type:text" json:"description"`
+	Author      string    `gorm:"size:255" json:"author"`
+	Manifest    string    `gorm:"type:text" json:"-"` // Full manifest as JSON
+	FetchedAt   time.Time `gorm:"index" json:"fetchedAt"`
+}
+
+// TableName specifies the table name for MarketplaceAddon
+func (MarketplaceAddon) TableName() string {
+	return "marketplace_addons"
+}
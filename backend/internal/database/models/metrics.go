@@ -58,6 +58,7 @@ type HealthScore struct {
 	CPUScore     int `json:"cpuScore"`
 	MemoryScore  int `json:"memoryScore"`
 	DiskScore    int `json:"diskScore"`
+	SMARTScore   int `json:"smartScore"`
 	NetworkScore int `json:"networkScore"`
 
 	// Issues detected
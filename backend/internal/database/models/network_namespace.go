@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// NetworkNamespace associates a named network namespace with the container
+// or VM whose networking it isolates. The interfaces are recorded so the
+// namespace can be recreated after a reboot, which clears /run/netns.
+type NetworkNamespace struct {
+	Name          string    `gorm:"primaryKey;size:64" json:"name"`
+	ContainerID   string    `gorm:"size:255;not null;index" json:"containerId"`
+	ContainerType string    `gorm:"size:20;not null" json:"containerType"` // lxc or vm
+	HostInterface string    `gorm:"size:15" json:"hostInterface"`          // Host end of the veth pair
+	Bridge        string    `gorm:"size:15" json:"bridge"`                 // Bridge the host end is attached to
+	IPv4Address   string    `gorm:"size:50" json:"ipv4Address,omitempty"`  // Static address in CIDR notation, DHCP if empty
+	IPv4Gateway   string    `gorm:"size:50" json:"ipv4Gateway,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// TableName specifies the table name for NetworkNamespace model
+func (NetworkNamespace) TableName() string {
+	return "network_namespaces"
+}
+
+// Container types of network namespaces
+const (
+	NamespaceContainerLXC = "lxc"
+	NamespaceContainerVM  = "vm"
+)
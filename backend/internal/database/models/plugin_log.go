@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Plugin log levels
+const (
+	PluginLogInfo  = "info"  // stdout
+	PluginLogError = "error" // stderr
+)
+
+// PluginLog is a line a plugin process wrote to stdout or stderr. The
+// plugin runtime keeps the latest lines in memory; they are not persisted.
+type PluginLog struct {
+	PluginID  string    `json:"pluginId"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
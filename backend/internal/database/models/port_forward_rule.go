@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PortForwardRule is a NAT port forward from an external port of the NAS to
+// a host on the local network
+type PortForwardRule struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	ExternalPort int       `gorm:"not null;uniqueIndex:idx_port_forward_port" json:"externalPort"`
+	Protocol     string    `gorm:"size:3;not null;uniqueIndex:idx_port_forward_port" json:"protocol"` // tcp, udp
+	InternalIP   string    `gorm:"size:45;not null" json:"internalIp"`
+	InternalPort string    `gorm:"size:5;not null" json:"internalPort"`
+	Description  string    `gorm:"size:255" json:"description"`
+	Enabled      bool      `gorm:"not null" json:"enabled"`
+}
+
+// TableName specifies the table name for PortForwardRule model
+func (PortForwardRule) TableName() string {
+	return "port_forward_rules"
+}
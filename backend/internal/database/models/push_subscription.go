@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// PushSubscription is a browser subscribed to notifications through the Web
+// Push Protocol. Endpoint is the push service URL of the browser, P256dh and
+// Auth are its message encryption keys (base64url).
+type PushSubscription struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UserID    uint      `gorm:"not null;index" json:"userId"`
+	Endpoint  string    `gorm:"size:2048;not null;uniqueIndex" json:"endpoint"`
+	P256dh    string    `gorm:"size:128;not null" json:"-"`
+	Auth      string    `gorm:"size:64;not null" json:"-"`
+	UserAgent string    `gorm:"size:512" json:"userAgent"`
+}
+
+// TableName specifies the table name for PushSubscription model
+func (PushSubscription) TableName() string {
+	return "push_subscriptions"
+}
+
+// VAPIDKey is the key pair identifying the server to push services (RFC
+// 8292). There is a single key pair; browsers subscribe with its public key.
+type VAPIDKey struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	PrivateKey string    `gorm:"size:64;not null" json:"-"`          // base64url P-256 scalar
+	PublicKey  string    `gorm:"size:128;not null" json:"publicKey"` // base64url uncompressed point
+}
+
+// TableName specifies the table name for VAPIDKey model
+func (VAPIDKey) TableName() string {
+	return "vapid_keys"
+}
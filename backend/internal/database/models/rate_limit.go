@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Rate limit target types
+const (
+	RateLimitTypeUser = "user"
+	RateLimitTypeIP   = "ip"
+)
+
+// RateLimit is a custom API rate limit for a user or an IP range
+type RateLimit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Target is a username (Type "user") or an IP address/CIDR range (Type "ip")
+	Target string `gorm:"size:100;not null;uniqueIndex" json:"target"`
+	Type   string `gorm:"size:10;not null;index" json:"type"`
+
+	RequestsPerSecond float64 `gorm:"not null" json:"requestsPerSecond"`
+	Burst             int     `gorm:"not null" json:"burst"`
+	Description       string  `gorm:"size:255" json:"description,omitempty"`
+}
+
+// TableName specifies the table name for RateLimit model
+func (RateLimit) TableName() string {
+	return "rate_limits"
+}
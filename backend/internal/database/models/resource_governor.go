@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ResourceGovernorConfig is the configuration of the Docker resource
+// governor. There is a single record.
+type ResourceGovernorConfig struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Enabled                  bool    `json:"enabled"`
+	MemoryThresholdPercent   float64 `json:"memoryThresholdPercent"`   // Throttle when less host memory is available
+	MemoryReductionPercent   float64 `json:"memoryReductionPercent"`   // How much to lower memory limits by
+	CPUStealThresholdPercent float64 `json:"cpuStealThresholdPercent"` // Throttle when more CPU time is stolen
+}
+
+// TableName specifies the table name for ResourceGovernorConfig
+func (ResourceGovernorConfig) TableName() string {
+	return "resource_governor_config"
+}
+
+// ContainerPriority is the priority the resource governor gives a
+// container, overriding its nas.priority label. Docker labels cannot be
+// changed once a container exists, and containers are recreated with new
+// IDs, so priorities are kept by container name.
+type ContainerPriority struct {
+	ContainerName string    `gorm:"primaryKey;size:255" json:"containerName"`
+	Priority      string    `gorm:"size:20;not null" json:"priority"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for ContainerPriority
+func (ContainerPriority) TableName() string {
+	return "container_priorities"
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions that can be assigned to users.
+// Built-in roles are created on startup and cannot be modified.
+type Role struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Name        string           `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	Description string           `gorm:"size:255" json:"description,omitempty"`
+	BuiltIn     bool             `gorm:"not null;default:false" json:"builtIn"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleID;constraint:OnDelete:CASCADE" json:"permissions"`
+}
+
+// TableName specifies the table name for Role model
+func (Role) TableName() string {
+	return "roles"
+}
+
+// RolePermission allows an action on a resource. Either may be "*".
+type RolePermission struct {
+	ID       uint   `gorm:"primaryKey" json:"-"`
+	RoleID   uint   `gorm:"not null;index" json:"-"`
+	Resource string `gorm:"size:50;not null" json:"resource"`
+	Action   string `gorm:"size:50;not null" json:"action"`
+}
+
+// TableName specifies the table name for RolePermission model
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// UserRole assigns a role to a user, optionally until ExpiresAt
+type UserRole struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UserID    uint       `gorm:"not null;uniqueIndex:idx_user_roles_user_role" json:"userId"`
+	RoleID    uint       `gorm:"not null;uniqueIndex:idx_user_roles_user_role;index" json:"roleId"`
+	ExpiresAt *time.Time `gorm:"index" json:"expiresAt,omitempty"`
+
+	Role Role `gorm:"foreignKey:RoleID" json:"role"`
+}
+
+// TableName specifies the table name for UserRole model
+func (UserRole) TableName() string {
+	return "user_roles"
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// SAMLConfig configures single sign-on through a SAML 2.0 identity
+// provider. The table holds a single row.
+type SAMLConfig struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Enabled              bool   `json:"enabled"`
+	IdPMetadataURL       string `gorm:"size:1024" json:"idpMetadataUrl"`
+	SPEntityID           string `gorm:"size:1024" json:"spEntityId"`           // Usually the URL of the SP metadata
+	AssertionConsumerURL string `gorm:"size:1024" json:"assertionConsumerUrl"` // e.g. https://nas.example.com/api/v1/auth/saml/acs
+	CertFile             string `gorm:"size:1024" json:"certFile"`             // PEM certificate of the SP
+	KeyFile              string `gorm:"size:1024" json:"keyFile"`              // PEM private key of the SP
+
+	EmailAttribute       string   `gorm:"size:255" json:"emailAttribute"`                  // Name or friendly name; the NameID is used if it is missing
+	GroupsAttribute      string   `gorm:"size:255" json:"groupsAttribute"`                 // Name or friendly name
+	AdminGroups          []string `gorm:"serializer:json" json:"adminGroups"`              // IdP groups whose members get the admin role
+	DefaultRole          string   `gorm:"size:50" json:"defaultRole"`                      // Role of everyone else: user or guest
+	AllowIdPInitiated    bool     `json:"allowIdpInitiated"`                               // Accept logins started from the IdP portal
+	MetadataRefreshHours int      `gorm:"not null;default:24" json:"metadataRefreshHours"` // Hours between IdP metadata refreshes
+
+	IdPMetadata       string     `gorm:"type:text" json:"-"` // Last fetched IdP metadata, used while the IdP is unreachable
+	MetadataFetchedAt *time.Time `json:"metadataFetchedAt,omitempty"`
+}
+
+// TableName specifies the table name for SAMLConfig
+func (SAMLConfig) TableName() string {
+	return "saml_configs"
+}
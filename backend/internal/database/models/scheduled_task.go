@@ -64,6 +64,12 @@ const (
 	TaskTypeCustom      = "custom"
 	TaskTypeLogRotation = "log_rotation"
 	TaskTypeMetrics     = "metrics"
+
+	TaskTypeAccessLogCleanup  = "access_log_cleanup"
+	TaskTypeInactiveUserCheck = "inactive_user_check"
+	TaskTypeLockoutAutoUnlock = "lockout_auto_unlock"
+	TaskTypeAuditRetention    = "audit_retention"
+	TaskTypeQuotaCheck        = "quota_check"
 )
 
 // Task status
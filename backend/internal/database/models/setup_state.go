@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// SetupState is the progress of the first-time setup wizard. The table
+// holds a single row.
+type SetupState struct {
+	ID             uint       `gorm:"primaryKey" json:"-"`
+	CompletedSteps []string   `gorm:"serializer:json" json:"completedSteps"`
+	Completed      bool       `json:"completed"` // Setup was finalised; the wizard is closed
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// TableName specifies the table name for SetupState
+func (SetupState) TableName() string {
+	return "setup_states"
+}
+
+// StepCompleted reports whether the wizard step has been completed
+func (s *SetupState) StepCompleted(name string) bool {
+	for _, step := range s.CompletedSteps {
+		if step == name {
+			return true
+		}
+	}
+	return false
+}
@@ -19,9 +19,21 @@ type Share struct {
 	GuestOK     bool   `gorm:"default:false"`
 	ValidUsers  string `gorm:"size:1000"` // Comma-separated list of usernames
 	ValidGroups string `gorm:"size:1000"` // Comma-separated list of group names
+	FileFilter  ShareFileFilter `gorm:"serializer:json"` // File types that may be stored on the share
 	DeletedAt   gorm.DeletedAt `gorm:"index;uniqueIndex:idx_name_deleted"` // Part of composite unique index
 }
 
+// ShareFileFilter restricts the files stored on a share. Extensions are
+// matched without the leading dot and case-insensitively. Samba enforces
+// DenyExtensions and DenyPatterns as veto files; AllowExtensions and
+// MaxFileSizeMB are only enforced for uploads through the API.
+type ShareFileFilter struct {
+	DenyExtensions  []string `json:"denyExtensions,omitempty"`
+	AllowExtensions []string `json:"allowExtensions,omitempty"` // If set, only these extensions are accepted
+	DenyPatterns    []string `json:"denyPatterns,omitempty"`    // Glob patterns of file names, e.g. "~$*"
+	MaxFileSizeMB   int64    `json:"maxFileSizeMB,omitempty"`   // 0 = unlimited
+}
+
 // TableName specifies the table name for Share
 func (Share) TableName() string {
 	return "shares"
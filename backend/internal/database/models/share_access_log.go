@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Share access protocols
+const (
+	ShareProtocolSMB = "smb"
+	ShareProtocolNFS = "nfs"
+)
+
+// ShareAccessLog records a client connection to a share, from when it was
+// first seen until it disappeared. File counts are the distinct files the
+// client was seen to have open for reading or writing.
+type ShareAccessLog struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ShareName      string     `gorm:"size:255;not null;index" json:"shareName"`
+	Protocol       string     `gorm:"size:10;not null" json:"protocol"`
+	Username       string     `gorm:"size:255" json:"username,omitempty"`
+	ClientIP       string     `gorm:"size:45" json:"clientIp"`
+	SessionKey     string     `gorm:"size:255;not null;index" json:"-"` // Identifies the connection between polls
+	ConnectedAt    time.Time  `gorm:"not null;index" json:"connectedAt"`
+	DisconnectedAt *time.Time `gorm:"index" json:"disconnectedAt,omitempty"` // Nil while connected
+	FilesRead      int        `json:"filesRead"`
+	FilesWritten   int        `json:"filesWritten"`
+}
+
+// TableName specifies the table name for ShareAccessLog model
+func (ShareAccessLog) TableName() string {
+	return "share_access_logs"
+}
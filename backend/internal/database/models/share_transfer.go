@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Share transfer statuses
+const (
+	ShareTransferStatusCopying   = "copying"    // Copying while the share stays writable
+	ShareTransferStatusFinalSync = "final_sync" // Share read-only for the last sync
+	ShareTransferStatusCompleted = "completed"
+	ShareTransferStatusFailed    = "failed"
+)
+
+// ShareTransfer tracks the migration of a share's data to another volume
+type ShareTransfer struct {
+	ID        string    `gorm:"primaryKey;size:64" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	ShareID             string `gorm:"size:20;not null;index" json:"shareId"`
+	DestinationVolumeID string `gorm:"size:100;not null" json:"destinationVolumeId"`
+	SourcePath          string `gorm:"size:500;not null" json:"sourcePath"`
+	DestinationPath     string `gorm:"size:500;not null" json:"destinationPath"`
+	PreservePermissions bool   `json:"preservePermissions"`
+	DeleteAfterTransfer bool   `json:"deleteAfterTransfer"`
+
+	Status           string     `gorm:"size:20;not null;index" json:"status"`
+	Progress         int        `json:"progress"`                      // Percent of the current sync, as reported by rsync
+	BytesTransferred int64      `json:"bytesTransferred"`              // Bytes copied by the current sync
+	Rate             string     `gorm:"size:20" json:"rate,omitempty"` // Transfer rate, e.g. "12.34MB/s"
+	Error            string     `gorm:"type:text" json:"error,omitempty"`
+	CompletedAt      *time.Time `json:"completedAt,omitempty"`
+}
+
+// TableName specifies the table name for ShareTransfer
+func (ShareTransfer) TableName() string {
+	return "share_transfers"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SSHKey is an SSH public key authorized for a user's system account
+type SSHKey struct {
+	ID          string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID      uint       `gorm:"not null;uniqueIndex:idx_ssh_keys_user_fingerprint" json:"userId"`
+	Fingerprint string     `gorm:"size:128;not null;uniqueIndex:idx_ssh_keys_user_fingerprint" json:"fingerprint"` // SHA256:...
+	Label       string     `gorm:"size:255" json:"label"`
+	KeyType     string     `gorm:"size:64;not null" json:"keyType"`     // e.g. ssh-ed25519
+	PublicKey   string     `gorm:"type:text;not null" json:"publicKey"` // authorized_keys line without options
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// TableName specifies the table name for SSHKey
+func (SSHKey) TableName() string {
+	return "ssh_keys"
+}
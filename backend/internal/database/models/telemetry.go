@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TelemetryState stores the telemetry settings and the node's anonymous ID.
+// There is a single record; NodeID is generated once and never changes.
+type TelemetryState struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	NodeID       string     `gorm:"size:36;not null;uniqueIndex" json:"nodeId"` // Random UUID
+	Enabled      bool       `gorm:"default:false" json:"enabled"`
+	CollectorURL string     `gorm:"size:500" json:"collectorUrl"`
+	LastReportAt *time.Time `json:"lastReportAt,omitempty"`
+}
+
+// TableName specifies the table name for TelemetryState
+func (TelemetryState) TableName() string {
+	return "telemetry_state"
+}
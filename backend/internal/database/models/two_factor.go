@@ -37,6 +37,19 @@ type TwoFactorAttempt struct {
 	AttemptedAt time.Time `gorm:"not null;index" json:"attemptedAt"`
 }
 
+// MFAPolicy controls which logins must use two-factor authentication.
+// The table holds a single row.
+type MFAPolicy struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	RequireForRoles       []string  `gorm:"serializer:json" json:"requireForRoles"`    // User roles that must use 2FA
+	RequireForExternalIPs bool      `json:"requireForExternalIPs"`                     // Require 2FA for logins from outside ExemptLocalSubnets
+	ExemptLocalSubnets    []string  `gorm:"serializer:json" json:"exemptLocalSubnets"` // CIDR ranges considered local
+	GracePeriodDays       int       `gorm:"not null;default:0" json:"gracePeriodDays"` // Days after ChangedAt users may still log in without 2FA
+	ChangedAt             time.Time `json:"changedAt"`                                 // When the policy was last changed
+}
+
 // TableName specifies the table name for TwoFactorAuth
 func (TwoFactorAuth) TableName() string {
 	return "two_factor_auth"
@@ -51,3 +64,8 @@ func (TwoFactorBackupCode) TableName() string {
 func (TwoFactorAttempt) TableName() string {
 	return "two_factor_attempts"
 }
+
+// TableName specifies the table name for MFAPolicy
+func (MFAPolicy) TableName() string {
+	return "mfa_policies"
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Upload session statuses
+const (
+	UploadStatusActive    = "active"
+	UploadStatusCompleted = "completed"
+	UploadStatusCancelled = "cancelled"
+)
+
+// UploadSession persists the state of a chunked upload so it survives restarts
+type UploadSession struct {
+	SessionID string    `gorm:"primaryKey;size:64" json:"sessionId"`
+	UserID    uint      `gorm:"index" json:"userId"`
+	Filename  string    `gorm:"size:255;not null" json:"filename"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	TotalChunks    int    `json:"totalChunks"`
+	ReceivedChunks int    `json:"receivedChunks"`
+	ChunkMap       string `gorm:"type:text" json:"-"` // One '0'/'1' per chunk index
+	TotalSize      int64  `json:"totalSize"`
+	BytesReceived  int64  `json:"bytesReceived"`
+
+	Status      string     `gorm:"size:20;not null;index" json:"status"` // active, completed, cancelled
+	PartialPath string     `gorm:"size:4096" json:"-"`                   // Directory holding the received chunks
+	StartedAt   time.Time  `json:"startedAt"`
+	ExpiresAt   time.Time  `gorm:"index" json:"expiresAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+}
+
+// TableName specifies the table name for UploadSession
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// VolumeHealthCheck is the result of writing and reading back a canary file
+// on a mounted volume
+type VolumeHealthCheck struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	VolumeID     string    `gorm:"size:255;not null;index" json:"volumeId"`
+	MountPoint   string    `gorm:"size:500" json:"mountPoint"`
+	Status       string    `gorm:"size:20;not null" json:"status"` // healthy or failed
+	LatencyMs    int64     `json:"latencyMs"`                      // Time to write, read and remove the canary file
+	ErrorMessage string    `gorm:"size:500" json:"errorMessage,omitempty"`
+	CheckedAt    time.Time `gorm:"index" json:"checkedAt"`
+}
+
+// TableName specifies the table name for VolumeHealthCheck model
+func (VolumeHealthCheck) TableName() string {
+	return "volume_health_checks"
+}
+
+// Volume health check statuses
+const (
+	VolumeHealthHealthy = "healthy"
+	VolumeHealthFailed  = "failed"
+)
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// VPNPeer is a WireGuard peer created through the API, with the client
+// side routing of its split tunnel
+type VPNPeer struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Interface  string   `gorm:"size:15;not null;index" json:"interface"`
+	Name       string   `gorm:"size:255" json:"name,omitempty"`
+	PublicKey  string   `gorm:"size:44;not null;uniqueIndex" json:"publicKey"`
+	AllowedIPs []string `gorm:"serializer:json" json:"allowedIPs"` // Tunnel addresses of the peer on the server
+
+	// Split tunnel policy, see vpn.SplitTunnelPolicy
+	RouteAllTraffic  bool     `json:"routeAllTraffic"`
+	AllowedCIDRs     []string `gorm:"serializer:json" json:"allowedCIDRs"`
+	ExcludedCIDRs    []string `gorm:"serializer:json" json:"excludedCIDRs"`
+	ClientAllowedIPs []string `gorm:"serializer:json" json:"clientAllowedIPs"` // Routed through the tunnel by the client
+	ClientConfig     string   `gorm:"type:text" json:"-"`                      // wg-quick configuration of the client
+}
+
+// TableName specifies the table name for VPNPeer
+func (VPNPeer) TableName() string {
+	return "vpn_peers"
+}
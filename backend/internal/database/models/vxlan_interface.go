@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// VXLANInterface is a VXLAN tunnel to another NAS node. The interfaces are
+// recorded so they can be recreated at startup.
+type VXLANInterface struct {
+	Name      string    `gorm:"primaryKey;size:15" json:"name"`
+	VNI       int       `gorm:"not null" json:"vni"`
+	RemoteIP  string    `gorm:"size:50;not null" json:"remoteIp"`
+	LocalIP   string    `gorm:"size:50;not null" json:"localIp"`
+	DstPort   int       `gorm:"not null" json:"dstPort"`
+	Bridge    string    `gorm:"size:15" json:"bridge,omitempty"` // Bridge the interface is attached to
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName specifies the table name for VXLANInterface model
+func (VXLANInterface) TableName() string {
+	return "vxlan_interfaces"
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+)
+
+// poolUsageWarnRatio is the share of MaxOpenConns above which the pool
+// health check warns
+const poolUsageWarnRatio = 0.8
+
+var poolConfig config.DatabasePoolConfig
+
+// PoolStats describes the current state of the connection pool
+type PoolStats struct {
+	MaxOpenConnections int   `json:"maxOpenConnections"` // 0 = unlimited
+	Open               int   `json:"open"`
+	InUse              int   `json:"inUse"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"waitCount"`      // Total number of waits for a connection
+	WaitDurationMs     int64 `json:"waitDurationMs"` // Total time spent waiting
+	MaxIdleClosed      int64 `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64 `json:"maxLifetimeClosed"`
+}
+
+// ConfigurePool applies the pool settings to a database handle
+func ConfigurePool(sqlDB *sql.DB, pool config.DatabasePoolConfig) {
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+}
+
+// GetPoolStats returns statistics for the global connection pool
+func GetPoolStats() (*PoolStats, error) {
+	if DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := sqlDB.Stats()
+	return &PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		Open:               stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}, nil
+}
+
+// CheckPoolUsage verifies that the number of database connections is below
+// 80% of MaxOpenConns. For PostgreSQL the server-side count from
+// pg_stat_activity is used, which includes connections held by other
+// processes. A warning event is published when the threshold is exceeded.
+func CheckPoolUsage() sysutil.HealthCheckResult {
+	check := sysutil.HealthCheckResult{
+		Installed: DB != nil,
+	}
+
+	if DB == nil {
+		check.Status = "error"
+		check.Message = "Database not initialized"
+		return check
+	}
+
+	maxOpen := poolConfig.MaxOpenConns
+	if maxOpen <= 0 {
+		check.Status = "ok"
+		check.Message = "Connection pool size is unlimited"
+		return check
+	}
+
+	count, err := connectionCount()
+	if err != nil {
+		check.Status = "warning"
+		check.Message = fmt.Sprintf("Failed to count database connections: %v", err)
+		return check
+	}
+
+	threshold := int(float64(maxOpen) * poolUsageWarnRatio)
+	if count < threshold {
+		check.Status = "ok"
+		check.Message = fmt.Sprintf("%d of %d connections in use", count, maxOpen)
+		return check
+	}
+
+	check.Status = "warning"
+	check.Message = fmt.Sprintf("%d of %d connections in use (warning threshold %d%%)",
+		count, maxOpen, int(poolUsageWarnRatio*100))
+
+	logger.Warn("Database connection pool nearly exhausted",
+		zap.Int("connections", count),
+		zap.Int("maxOpenConns", maxOpen))
+	events.Publish(events.Event{
+		Type:     events.TypeSystem,
+		Action:   "database_pool_exhausted",
+		Severity: events.SeverityWarning,
+		Source:   "database",
+		Message:  check.Message,
+		Data: map[string]interface{}{
+			"connections":  count,
+			"maxOpenConns": maxOpen,
+		},
+	})
+
+	return check
+}
+
+// connectionCount returns the number of open connections to the database
+func connectionCount() (int, error) {
+	if DB.Dialector.Name() == "postgres" {
+		var count int64
+		err := DB.Raw("SELECT count(*) FROM pg_stat_activity WHERE datname = current_database()").
+			Scan(&count).Error
+		return int(count), err
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return 0, err
+	}
+	return sqlDB.Stats().OpenConnections, nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestConfigurePoolLimitsOpenConnections(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "pool.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("DB() error = %v", err)
+	}
+	defer sqlDB.Close()
+
+	ConfigurePool(sqlDB, config.DatabasePoolConfig{
+		MaxOpenConns:    2,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Minute,
+	})
+
+	ctx := context.Background()
+
+	// Hold both connections, as two long-running queries would
+	for i := 0; i < 2; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			t.Fatalf("connection %d: %v", i+1, err)
+		}
+		defer conn.Close()
+		if err := conn.PingContext(ctx); err != nil {
+			t.Fatalf("connection %d ping: %v", i+1, err)
+		}
+	}
+
+	// A third query must wait for a free connection instead of opening one
+	queryCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	var one int
+	err = sqlDB.QueryRowContext(queryCtx, "SELECT 1").Scan(&one)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third query error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	stats := sqlDB.Stats()
+	if stats.OpenConnections != 2 {
+		t.Errorf("OpenConnections = %d, want 2", stats.OpenConnections)
+	}
+	if stats.WaitCount != 1 {
+		t.Errorf("WaitCount = %d, want 1", stats.WaitCount)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"go.uber.org/zap"
 )
 
@@ -15,24 +16,28 @@ import (
 type PackageManager string
 
 const (
-	APT  PackageManager = "apt"     // Debian/Ubuntu
-	YUM  PackageManager = "yum"     // RHEL/CentOS 7
-	DNF  PackageManager = "dnf"     // RHEL/CentOS 8+, Fedora
-	PACMAN PackageManager = "pacman" // Arch Linux
-	ZYPPER PackageManager = "zypper" // openSUSE
+	APT     PackageManager = "apt"    // Debian/Ubuntu
+	YUM     PackageManager = "yum"    // RHEL/CentOS 7
+	DNF     PackageManager = "dnf"    // RHEL/CentOS 8+, Fedora
+	PACMAN  PackageManager = "pacman" // Arch Linux
+	ZYPPER  PackageManager = "zypper" // openSUSE
 	UNKNOWN PackageManager = "unknown"
 )
 
 // Package represents a system package dependency
 type Package struct {
-	Name         string   // Package name
-	Required     bool     // If true, system won't work without it
-	CheckCommand string   // Command to check if installed (e.g., "samba --version")
-	AptName      string   // Package name in apt (Debian/Ubuntu)
-	YumName      string   // Package name in yum/dnf (RHEL/CentOS)
-	PacmanName   string   // Package name in pacman (Arch)
-	Description  string   // What this package is used for
-	Installed    bool     // Current installation status
+	Name         string // Package name
+	Required     bool   // If true, system won't work without it
+	CheckCommand string // Command to check if installed (e.g., "samba --version")
+	AptName      string // Package name in apt (Debian/Ubuntu)
+	YumName      string // Package name in yum/dnf (RHEL/CentOS)
+	PacmanName   string // Package name in pacman (Arch)
+	Description  string // What this package is used for
+	Installed    bool   // Current installation status
+	// RequiredVersion is the oldest dpkg version the features need; empty
+	// if any version works
+	RequiredVersion string
+	UsedBy          []string // Features that need the package
 }
 
 // Checker checks and manages system dependencies
@@ -43,10 +48,7 @@ type Checker struct {
 
 // NewChecker creates a new dependency checker
 func NewChecker() *Checker {
-	checker := &Checker{
-		packageManager: detectPackageManager(),
-		packages:       getRequiredPackages(),
-	}
+	checker := newChecker()
 
 	logger.Info("Dependency checker initialized",
 		zap.String("packageManager", string(checker.packageManager)),
@@ -56,6 +58,14 @@ func NewChecker() *Checker {
 	return checker
 }
 
+// newChecker creates a dependency checker without logging it
+func newChecker() *Checker {
+	return &Checker{
+		packageManager: detectPackageManager(),
+		packages:       getRequiredPackages(),
+	}
+}
+
 // detectPackageManager detects which package manager is available
 func detectPackageManager() PackageManager {
 	// Check in order of preference
@@ -71,7 +81,7 @@ func detectPackageManager() PackageManager {
 	}
 
 	for _, m := range managers {
-		if _, err := exec.LookPath(m.command); err == nil {
+		if sysutil.CommandExists(m.command) {
 			return m.pm
 		}
 	}
@@ -83,13 +93,15 @@ func detectPackageManager() PackageManager {
 func getRequiredPackages() []*Package {
 	return []*Package{
 		{
-			Name:         "samba",
-			Required:     true,
-			CheckCommand: "smbd",
-			AptName:      "samba",
-			YumName:      "samba",
-			PacmanName:   "samba",
-			Description:  "SMB/CIFS file server (for Windows network drives)",
+			Name:            "samba",
+			Required:        true,
+			CheckCommand:    "smbd",
+			AptName:         "samba",
+			YumName:         "samba",
+			PacmanName:      "samba",
+			Description:     "SMB/CIFS file server (for Windows network drives)",
+			RequiredVersion: "2:4.13",
+			UsedBy:          []string{"SMB shares", "Samba users"},
 		},
 		{
 			Name:         "smbclient",
@@ -99,15 +111,18 @@ func getRequiredPackages() []*Package {
 			YumName:      "samba-client",
 			PacmanName:   "smbclient",
 			Description:  "Samba client tools (for user management)",
+			UsedBy:       []string{"Samba users"},
 		},
 		{
-			Name:         "smartmontools",
-			Required:     true,
-			CheckCommand: "smartctl",
-			AptName:      "smartmontools",
-			YumName:      "smartmontools",
-			PacmanName:   "smartmontools",
-			Description:  "SMART disk monitoring tools (for disk health)",
+			Name:            "smartmontools",
+			Required:        true,
+			CheckCommand:    "smartctl",
+			AptName:         "smartmontools",
+			YumName:         "smartmontools",
+			PacmanName:      "smartmontools",
+			Description:     "SMART disk monitoring tools (for disk health)",
+			RequiredVersion: "7.0", // smartctl -j
+			UsedBy:          []string{"Disk health", "Disk metrics"},
 		},
 		{
 			Name:         "nfs-kernel-server",
@@ -117,6 +132,7 @@ func getRequiredPackages() []*Package {
 			YumName:      "nfs-utils",
 			PacmanName:   "nfs-utils",
 			Description:  "NFS server (for Unix/Linux network shares)",
+			UsedBy:       []string{"NFS shares"},
 		},
 		{
 			Name:         "lvm2",
@@ -126,6 +142,7 @@ func getRequiredPackages() []*Package {
 			YumName:      "lvm2",
 			PacmanName:   "lvm2",
 			Description:  "Logical Volume Manager (for advanced disk management)",
+			UsedBy:       []string{"LVM volumes"},
 		},
 		{
 			Name:         "mdadm",
@@ -135,6 +152,7 @@ func getRequiredPackages() []*Package {
 			YumName:      "mdadm",
 			PacmanName:   "mdadm",
 			Description:  "Software RAID management tool",
+			UsedBy:       []string{"RAID arrays"},
 		},
 		{
 			Name:         "docker",
@@ -144,6 +162,7 @@ func getRequiredPackages() []*Package {
 			YumName:      "docker",
 			PacmanName:   "docker",
 			Description:  "Container runtime (for Docker management features)",
+			UsedBy:       []string{"Docker"},
 		},
 		{
 			Name:         "acl",
@@ -153,6 +172,7 @@ func getRequiredPackages() []*Package {
 			YumName:      "acl",
 			PacmanName:   "acl",
 			Description:  "POSIX ACL support for granular file permissions",
+			UsedBy:       []string{"File ACLs"},
 		},
 		{
 			Name:         "quota",
@@ -162,6 +182,7 @@ func getRequiredPackages() []*Package {
 			YumName:      "quota",
 			PacmanName:   "quota-tools",
 			Description:  "Disk quota management for users and groups",
+			UsedBy:       []string{"Disk quotas", "Quota alerts"},
 		},
 		{
 			Name:         "drbd-utils",
@@ -171,6 +192,7 @@ func getRequiredPackages() []*Package {
 			YumName:      "drbd-utils",
 			PacmanName:   "drbd-utils",
 			Description:  "DRBD block-level replication for High Availability",
+			UsedBy:       []string{"DRBD replication"},
 		},
 	}
 }
@@ -220,7 +242,7 @@ func (c *Checker) CheckAll() error {
 func (c *Checker) isPackageInstalled(pkg *Package) bool {
 	// First try to find the command
 	if pkg.CheckCommand != "" {
-		if _, err := exec.LookPath(pkg.CheckCommand); err == nil {
+		if sysutil.CommandExists(pkg.CheckCommand) {
 			return true
 		}
 	}
@@ -0,0 +1,63 @@
+package dependencies
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+)
+
+func init() {
+	sysutil.HealthChecks.Register("System packages", true, "dependencies", checkPackages)
+}
+
+// checkPackages reports missing required packages as missing and outdated
+// packages as a warning. Missing optional packages only disable features, so
+// they are just listed.
+func checkPackages() sysutil.HealthCheckResult {
+	report, err := (&Installer{checker: newChecker()}).GetReport()
+	if errors.Is(err, ErrDpkgUnavailable) {
+		return sysutil.HealthCheckResult{Installed: true, Status: "ok", Message: err.Error()}
+	}
+	if err != nil {
+		return sysutil.HealthCheckResult{Installed: true, Status: "warning", Message: err.Error()}
+	}
+
+	var requiredMissing, missing, outdated []string
+	for _, pkg := range report.Packages {
+		switch {
+		case pkg.Required && pkg.Status != PackageInstalled:
+			requiredMissing = append(requiredMissing, pkg.Name)
+		case pkg.Status == PackageMissing:
+			missing = append(missing, pkg.Name)
+		case pkg.Status == PackageOutdated:
+			outdated = append(outdated, fmt.Sprintf("%s %s (needs %s)", pkg.Name, pkg.InstalledVersion, pkg.RequiredVersion))
+		}
+	}
+
+	switch {
+	case len(requiredMissing) > 0:
+		return sysutil.HealthCheckResult{
+			Status:  "missing",
+			Message: "Required packages missing or outdated: " + strings.Join(requiredMissing, ", "),
+		}
+	case len(outdated) > 0:
+		return sysutil.HealthCheckResult{
+			Installed: true,
+			Status:    "warning",
+			Message:   "Outdated packages: " + strings.Join(outdated, ", "),
+		}
+	case len(missing) > 0:
+		return sysutil.HealthCheckResult{
+			Installed: true,
+			Status:    "ok",
+			Message:   "Optional packages missing: " + strings.Join(missing, ", "),
+		}
+	}
+	return sysutil.HealthCheckResult{
+		Installed: true,
+		Status:    "ok",
+		Message:   fmt.Sprintf("%d packages installed", report.Summary.Installed),
+	}
+}
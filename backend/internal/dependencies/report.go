@@ -0,0 +1,310 @@
+package dependencies
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+)
+
+// Package statuses of a DependencyReport
+const (
+	PackageInstalled = "installed"
+	PackageMissing   = "missing"
+	PackageOutdated  = "outdated" // Installed, but older than RequiredVersion
+)
+
+// ErrDpkgUnavailable is returned by the report and updates on systems
+// without dpkg
+var ErrDpkgUnavailable = errors.New("package versions can only be checked on dpkg-based systems")
+
+// PackageStatus is the install status of a system package
+type PackageStatus struct {
+	Name             string   `json:"name"`
+	InstalledVersion string   `json:"installedVersion,omitempty"`
+	RequiredVersion  string   `json:"requiredVersion,omitempty"`
+	Status           string   `json:"status"`
+	Required         bool     `json:"required"`
+	UsedBy           []string `json:"usedBy"`
+}
+
+// DependencySummary counts the packages of a DependencyReport per status
+type DependencySummary struct {
+	Total           int `json:"total"`
+	Installed       int `json:"installed"`
+	Missing         int `json:"missing"`
+	Outdated        int `json:"outdated"`
+	RequiredMissing int `json:"requiredMissing"` // Missing or outdated required packages
+}
+
+// DependencyReport lists the system packages the NAS uses with their
+// installed versions
+type DependencyReport struct {
+	Packages    []PackageStatus   `json:"packages"`
+	Summary     DependencySummary `json:"summary"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+}
+
+// dpkgQueryFormat makes dpkg-query print one tab-separated line per package
+const dpkgQueryFormat = "${Package}\t${db:Status-Status}\t${Version}\n"
+
+// runDpkgQuery lists the status of packages. Replaced in tests.
+var runDpkgQuery = func(names []string) (string, error) {
+	args := append([]string{"-W", "-f=" + dpkgQueryFormat}, names...)
+	output, err := exec.Command("dpkg-query", args...).Output()
+	// dpkg-query exits with 1 if a package is unknown but still lists the others
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+	return string(output), err
+}
+
+// runAptGet runs apt-get non-interactively. Replaced in tests.
+var runAptGet = func(args ...string) error {
+	cmd := exec.Command("apt-get", args...)
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("apt-get %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// GetReport returns the install status and version of every package the
+// NAS uses, as reported by dpkg-query
+func (i *Installer) GetReport() (*DependencyReport, error) {
+	if i.checker.packageManager != APT {
+		return nil, ErrDpkgUnavailable
+	}
+
+	names := make([]string, 0, len(i.checker.packages))
+	for _, pkg := range i.checker.packages {
+		names = append(names, pkg.AptName)
+	}
+	output, err := runDpkgQuery(names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query installed packages: %w", err)
+	}
+
+	return buildReport(i.checker.packages, parseDpkgQuery(output), sysutil.CommandExists), nil
+}
+
+// parseDpkgQuery returns the versions of the installed packages listed by
+// dpkg-query. Removed packages whose config files are kept are skipped.
+func parseDpkgQuery(output string) map[string]string {
+	versions := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 || fields[1] != "installed" {
+			continue
+		}
+		versions[fields[0]] = fields[2]
+	}
+	return versions
+}
+
+// buildReport builds a report from the installed package versions
+func buildReport(packages []*Package, versions map[string]string, commandExists func(string) bool) *DependencyReport {
+	report := &DependencyReport{
+		Packages:    make([]PackageStatus, 0, len(packages)),
+		GeneratedAt: time.Now(),
+	}
+
+	for _, pkg := range packages {
+		status := PackageStatus{
+			Name:            pkg.Name,
+			RequiredVersion: pkg.RequiredVersion,
+			Status:          PackageMissing,
+			Required:        pkg.Required,
+			UsedBy:          pkg.UsedBy,
+		}
+		if version, ok := versions[pkg.AptName]; ok {
+			status.InstalledVersion = version
+			status.Status = PackageInstalled
+			if pkg.RequiredVersion != "" && compareDebianVersions(version, pkg.RequiredVersion) < 0 {
+				status.Status = PackageOutdated
+			}
+		} else if pkg.CheckCommand != "" && commandExists(pkg.CheckCommand) {
+			// Installed from another package, e.g. docker-ce instead of docker.io
+			status.Status = PackageInstalled
+		}
+
+		switch status.Status {
+		case PackageInstalled:
+			report.Summary.Installed++
+		case PackageMissing:
+			report.Summary.Missing++
+		case PackageOutdated:
+			report.Summary.Outdated++
+		}
+		if status.Required && status.Status != PackageInstalled {
+			report.Summary.RequiredMissing++
+		}
+		report.Packages = append(report.Packages, status)
+	}
+	report.Summary.Total = len(report.Packages)
+
+	return report
+}
+
+// Update upgrades an installed package to the newest version available in
+// the apt repositories
+func (i *Installer) Update(packageName string) error {
+	if i.checker.packageManager != APT {
+		return ErrDpkgUnavailable
+	}
+
+	for _, pkg := range i.checker.packages {
+		if pkg.Name == packageName || pkg.AptName == packageName {
+			return i.upgrade(pkg.AptName)
+		}
+	}
+	return fmt.Errorf("unknown package: %s", packageName)
+}
+
+// UpdateAll upgrades all installed packages the NAS uses
+func (i *Installer) UpdateAll() error {
+	report, err := i.GetReport()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for idx, status := range report.Packages {
+		if status.InstalledVersion != "" {
+			names = append(names, i.checker.packages[idx].AptName)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return i.upgrade(names...)
+}
+
+// upgrade refreshes the package lists and upgrades packages without
+// installing missing ones
+func (i *Installer) upgrade(aptNames ...string) error {
+	if !isRoot() {
+		return fmt.Errorf("updating packages requires root privileges")
+	}
+
+	logger.Info("Updating packages", zap.Strings("packages", aptNames))
+	if err := runAptGet("update"); err != nil {
+		return err
+	}
+	return runAptGet(append([]string{"install", "-y", "--only-upgrade"}, aptNames...)...)
+}
+
+// compareDebianVersions compares two dpkg versions ([epoch:]upstream[-revision])
+// like dpkg --compare-versions and returns -1, 0 or 1
+func compareDebianVersions(a, b string) int {
+	aEpoch, aUpstream, aRevision := splitDebianVersion(a)
+	bEpoch, bUpstream, bRevision := splitDebianVersion(b)
+	if aEpoch != bEpoch {
+		if aEpoch < bEpoch {
+			return -1
+		}
+		return 1
+	}
+	if c := compareVersionPart(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return compareVersionPart(aRevision, bRevision)
+}
+
+// splitDebianVersion splits a dpkg version into epoch, upstream version and
+// Debian revision
+func splitDebianVersion(version string) (int, string, string) {
+	epoch := 0
+	if idx := strings.Index(version, ":"); idx >= 0 {
+		epoch, _ = strconv.Atoi(version[:idx])
+		version = version[idx+1:]
+	}
+	revision := ""
+	if idx := strings.LastIndex(version, "-"); idx >= 0 {
+		version, revision = version[:idx], version[idx+1:]
+	}
+	return epoch, version, revision
+}
+
+// compareVersionPart compares upstream versions or revisions with the dpkg
+// algorithm: alternating non-digit runs, compared by character with ~
+// sorting before everything, and digit runs, compared numerically
+func compareVersionPart(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := versionCharOrder(a, i), versionCharOrder(b, j)
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// versionCharOrder returns the sort weight of the character at idx of a
+// version: letters sort before other characters, ~ before the end
+func versionCharOrder(version string, idx int) int {
+	if idx >= len(version) {
+		return 0
+	}
+	c := version[idx]
+	switch {
+	case isDigit(c):
+		return 0
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
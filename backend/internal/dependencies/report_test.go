@@ -0,0 +1,150 @@
+package dependencies
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// sampleDpkgQuery is dpkg-query output for the known packages: lvm2 was
+// removed with its config files kept, mdadm and docker.io are unknown and
+// samba is older than required
+const sampleDpkgQuery = "samba\tinstalled\t2:4.9.5+dfsg-5+deb10u3\n" +
+	"smbclient\tinstalled\t2:4.9.5+dfsg-5+deb10u3\n" +
+	"smartmontools\tinstalled\t7.3-pve1\n" +
+	"nfs-kernel-server\tinstalled\t1:2.6.2-4\n" +
+	"lvm2\tconfig-files\t2.03.16-2\n" +
+	"acl\tinstalled\t2.3.1-3\n" +
+	"quota\tinstalled\t4.06-1+b2\n" +
+	"drbd-utils\tnot-installed\t\n"
+
+func TestBuildReport(t *testing.T) {
+	commands := map[string]bool{"docker": true}
+	report := buildReport(getRequiredPackages(), parseDpkgQuery(sampleDpkgQuery), func(name string) bool {
+		return commands[name]
+	})
+
+	want := map[string]struct{ status, version string }{
+		"samba":             {PackageOutdated, "2:4.9.5+dfsg-5+deb10u3"},
+		"smbclient":         {PackageInstalled, "2:4.9.5+dfsg-5+deb10u3"},
+		"smartmontools":     {PackageInstalled, "7.3-pve1"},
+		"nfs-kernel-server": {PackageInstalled, "1:2.6.2-4"},
+		"lvm2":              {PackageMissing, ""},
+		"mdadm":             {PackageMissing, ""},
+		"docker":            {PackageInstalled, ""}, // docker-ce provides the command
+		"acl":               {PackageInstalled, "2.3.1-3"},
+		"quota":             {PackageInstalled, "4.06-1+b2"},
+		"drbd-utils":        {PackageMissing, ""},
+	}
+	if len(report.Packages) != len(want) {
+		t.Fatalf("report has %d packages, want %d", len(report.Packages), len(want))
+	}
+	for _, pkg := range report.Packages {
+		w := want[pkg.Name]
+		if pkg.Status != w.status || pkg.InstalledVersion != w.version {
+			t.Errorf("%s: status %q version %q, want %q %q", pkg.Name, pkg.Status, pkg.InstalledVersion, w.status, w.version)
+		}
+	}
+
+	samba := report.Packages[0]
+	if !samba.Required || samba.RequiredVersion != "2:4.13" || !reflect.DeepEqual(samba.UsedBy, []string{"SMB shares", "Samba users"}) {
+		t.Errorf("samba = %+v", samba)
+	}
+
+	wantSummary := DependencySummary{Total: 10, Installed: 6, Missing: 3, Outdated: 1, RequiredMissing: 1}
+	if report.Summary != wantSummary {
+		t.Errorf("summary = %+v, want %+v", report.Summary, wantSummary)
+	}
+}
+
+func TestInstallerGetReport(t *testing.T) {
+	var queried []string
+	real := runDpkgQuery
+	runDpkgQuery = func(names []string) (string, error) {
+		queried = names
+		return sampleDpkgQuery, nil
+	}
+	t.Cleanup(func() { runDpkgQuery = real })
+
+	installer := &Installer{checker: &Checker{packageManager: APT, packages: getRequiredPackages()}}
+	report, err := installer.GetReport()
+	if err != nil {
+		t.Fatalf("GetReport() error = %v", err)
+	}
+	if len(queried) != 10 || queried[6] != "docker.io" {
+		t.Errorf("queried %v, want the apt names of all packages", queried)
+	}
+	if report.Summary.Outdated != 1 {
+		t.Errorf("summary = %+v", report.Summary)
+	}
+
+	installer.checker.packageManager = PACMAN
+	if _, err := installer.GetReport(); err != ErrDpkgUnavailable {
+		t.Errorf("GetReport() on pacman error = %v, want ErrDpkgUnavailable", err)
+	}
+}
+
+func TestInstallerUpdate(t *testing.T) {
+	if !isRoot() {
+		t.Skip("updates require root")
+	}
+	logger.InitLogger("error", false)
+	var calls []string
+	realApt, realQuery := runAptGet, runDpkgQuery
+	runAptGet = func(args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		return nil
+	}
+	runDpkgQuery = func(names []string) (string, error) { return sampleDpkgQuery, nil }
+	t.Cleanup(func() { runAptGet, runDpkgQuery = realApt, realQuery })
+
+	installer := &Installer{checker: &Checker{packageManager: APT, packages: getRequiredPackages()}}
+	if err := installer.Update("docker"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := installer.Update("zfsutils-linux"); err == nil {
+		t.Error("Update() accepted an unknown package")
+	}
+	if err := installer.UpdateAll(); err != nil {
+		t.Fatalf("UpdateAll() error = %v", err)
+	}
+
+	want := []string{
+		"update",
+		"install -y --only-upgrade docker.io",
+		"update",
+		"install -y --only-upgrade samba smbclient smartmontools nfs-kernel-server acl quota",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("apt-get calls = %q, want %q", calls, want)
+	}
+}
+
+func TestCompareDebianVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2:4.17.12+dfsg-0+deb12u1", "2:4.13", 1},
+		{"2:4.9.5+dfsg-5+deb10u3", "2:4.13", -1},
+		{"4.17", "2:4.13", -1}, // Epoch wins
+		{"7.3-pve1", "7.0", 1},
+		{"7.0", "7.0-1", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+b1", -1},
+		{"1.0a", "1.0+", -1}, // Letters sort before other characters
+		{"1.010", "1.9", 1},
+		{"1.01", "1.1", 0},
+		{"1.0-1", "1.0-1", 0},
+	}
+	for _, tt := range tests {
+		if got := compareDebianVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareDebianVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareDebianVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareDebianVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
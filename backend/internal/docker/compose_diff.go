@@ -0,0 +1,235 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces the values of secret environment variables in diffs
+const redactedValue = "REDACTED"
+
+// ErrStackNotFound is returned for stacks without a compose file
+var ErrStackNotFound = errors.New("stack not found")
+
+// secretEnvMarkers mark environment variables whose values are not shown in
+// diffs, matched against the upper-cased variable name
+var secretEnvMarkers = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL"}
+
+// StackDiff is what redeploying a stack with a new compose file changes
+type StackDiff struct {
+	AddedServices   []string      `json:"addedServices"`
+	RemovedServices []string      `json:"removedServices"`
+	ChangedServices []ServiceDiff `json:"changedServices"`
+	AddedNetworks   []string      `json:"addedNetworks"`
+	RemovedNetworks []string      `json:"removedNetworks"`
+	AddedVolumes    []string      `json:"addedVolumes"`
+	RemovedVolumes  []string      `json:"removedVolumes"`
+}
+
+// HasChanges reports whether the diff is not empty
+func (d *StackDiff) HasChanges() bool {
+	return len(d.AddedServices) > 0 || len(d.RemovedServices) > 0 || len(d.ChangedServices) > 0 ||
+		len(d.AddedNetworks) > 0 || len(d.RemovedNetworks) > 0 ||
+		len(d.AddedVolumes) > 0 || len(d.RemovedVolumes) > 0
+}
+
+// ServiceDiff is a changed parameter of a service. Environment variables are
+// compared one by one as environment.<NAME>; an empty Old or New means the
+// parameter was added or removed.
+type ServiceDiff struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// composeDocument is a compose file with the service definitions kept as
+// generic maps, so every parameter is compared and not only the ones
+// ComposeServiceConfig knows
+type composeDocument struct {
+	Services map[string]map[string]interface{} `yaml:"services"`
+	Networks map[string]interface{}            `yaml:"networks"`
+	Volumes  map[string]interface{}            `yaml:"volumes"`
+}
+
+// DiffStack compares the stored compose file of a stack with newCompose
+func (s *Service) DiffStack(stackPath string, newCompose string) (*StackDiff, error) {
+	composePath := filepath.Join(stackPath, "docker-compose.yml")
+	if _, err := os.Stat(composePath); os.IsNotExist(err) {
+		composePath = filepath.Join(stackPath, "docker-compose.yaml")
+	}
+
+	current, err := os.ReadFile(composePath)
+	if os.IsNotExist(err) {
+		return nil, ErrStackNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+	return DiffComposeFiles(current, []byte(newCompose))
+}
+
+// DiffComposeFiles compares two compose files. Values of environment
+// variables that look like secrets are redacted.
+func DiffComposeFiles(oldCompose, newCompose []byte) (*StackDiff, error) {
+	var oldDoc, newDoc composeDocument
+	if err := yaml.Unmarshal(oldCompose, &oldDoc); err != nil {
+		return nil, fmt.Errorf("failed to parse current compose file: %w", err)
+	}
+	if err := yaml.Unmarshal(newCompose, &newDoc); err != nil {
+		return nil, fmt.Errorf("failed to parse new compose file: %w", err)
+	}
+
+	diff := &StackDiff{ChangedServices: []ServiceDiff{}}
+	diff.AddedServices, diff.RemovedServices = diffKeys(oldDoc.Services, newDoc.Services)
+	diff.AddedNetworks, diff.RemovedNetworks = diffKeys(oldDoc.Networks, newDoc.Networks)
+	diff.AddedVolumes, diff.RemovedVolumes = diffKeys(oldDoc.Volumes, newDoc.Volumes)
+
+	for _, name := range sortedKeys(oldDoc.Services) {
+		newService, ok := newDoc.Services[name]
+		if !ok {
+			continue
+		}
+		diff.ChangedServices = append(diff.ChangedServices, diffService(name, oldDoc.Services[name], newService)...)
+	}
+	return diff, nil
+}
+
+// diffService compares the parameters of a service present in both files
+func diffService(name string, oldService, newService map[string]interface{}) []ServiceDiff {
+	fields := make(map[string]bool)
+	for field := range oldService {
+		fields[field] = true
+	}
+	for field := range newService {
+		fields[field] = true
+	}
+
+	var diffs []ServiceDiff
+	for _, field := range sortedKeys(fields) {
+		oldValue, newValue := oldService[field], newService[field]
+		if field == "environment" {
+			diffs = append(diffs, diffEnvironment(name, oldValue, newValue)...)
+			continue
+		}
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		diffs = append(diffs, ServiceDiff{Name: name, Field: field, Old: formatValue(oldValue), New: formatValue(newValue)})
+	}
+	return diffs
+}
+
+// diffEnvironment compares environment variables given as a list of
+// NAME=value or as a map
+func diffEnvironment(service string, oldValue, newValue interface{}) []ServiceDiff {
+	oldEnv, newEnv := environmentMap(oldValue), environmentMap(newValue)
+	names := make(map[string]bool)
+	for name := range oldEnv {
+		names[name] = true
+	}
+	for name := range newEnv {
+		names[name] = true
+	}
+
+	var diffs []ServiceDiff
+	for _, name := range sortedKeys(names) {
+		oldVar, inOld := oldEnv[name]
+		newVar, inNew := newEnv[name]
+		if inOld && inNew && oldVar == newVar {
+			continue
+		}
+		if isSecretEnv(name) {
+			if inOld {
+				oldVar = redactedValue
+			}
+			if inNew {
+				newVar = redactedValue
+			}
+		}
+		diffs = append(diffs, ServiceDiff{Name: service, Field: "environment." + name, Old: oldVar, New: newVar})
+	}
+	return diffs
+}
+
+func environmentMap(value interface{}) map[string]string {
+	env := make(map[string]string)
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			name, val, _ := strings.Cut(fmt.Sprint(item), "=")
+			env[name] = val
+		}
+	case map[string]interface{}:
+		for name, val := range v {
+			if val == nil {
+				env[name] = ""
+			} else {
+				env[name] = fmt.Sprint(val)
+			}
+		}
+	}
+	return env
+}
+
+func isSecretEnv(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, marker := range secretEnvMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue renders a compose value for display, scalars as they are and
+// lists and maps as JSON
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// diffKeys returns the keys only in newMap and the keys only in oldMap, sorted
+func diffKeys[V any](oldMap, newMap map[string]V) (added, removed []string) {
+	added, removed = []string{}, []string{}
+	for _, key := range sortedKeys(newMap) {
+		if _, ok := oldMap[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	for _, key := range sortedKeys(oldMap) {
+		if _, ok := newMap[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	return added, removed
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,78 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+const currentCompose = `
+services:
+  web:
+    image: nginx:1.25
+    ports:
+      - "80:80"
+    environment:
+      - LOG_LEVEL=info
+      - DB_PASSWORD=hunter2
+  worker:
+    image: app/worker:2
+volumes:
+  data: {}
+`
+
+const newCompose = `
+services:
+  web:
+    image: nginx:1.27
+    ports:
+      - "80:80"
+    environment:
+      LOG_LEVEL: debug
+      DB_PASSWORD: correct-horse
+  cache:
+    image: redis:7
+volumes:
+  data: {}
+  cache: {}
+networks:
+  backend: {}
+`
+
+func TestDiffComposeFiles(t *testing.T) {
+	diff, err := DiffComposeFiles([]byte(currentCompose), []byte(newCompose))
+	if err != nil {
+		t.Fatalf("DiffComposeFiles: %v", err)
+	}
+
+	if !reflect.DeepEqual(diff.AddedServices, []string{"cache"}) {
+		t.Errorf("AddedServices = %v, want [cache]", diff.AddedServices)
+	}
+	if !reflect.DeepEqual(diff.RemovedServices, []string{"worker"}) {
+		t.Errorf("RemovedServices = %v, want [worker]", diff.RemovedServices)
+	}
+	if !reflect.DeepEqual(diff.AddedVolumes, []string{"cache"}) || len(diff.RemovedVolumes) != 0 {
+		t.Errorf("volumes added %v removed %v, want [cache] and none", diff.AddedVolumes, diff.RemovedVolumes)
+	}
+	if !reflect.DeepEqual(diff.AddedNetworks, []string{"backend"}) {
+		t.Errorf("AddedNetworks = %v, want [backend]", diff.AddedNetworks)
+	}
+
+	want := []ServiceDiff{
+		{Name: "web", Field: "environment.DB_PASSWORD", Old: redactedValue, New: redactedValue},
+		{Name: "web", Field: "environment.LOG_LEVEL", Old: "info", New: "debug"},
+		{Name: "web", Field: "image", Old: "nginx:1.25", New: "nginx:1.27"},
+	}
+	if !reflect.DeepEqual(diff.ChangedServices, want) {
+		t.Errorf("ChangedServices = %+v, want %+v", diff.ChangedServices, want)
+	}
+}
+
+func TestDiffComposeFilesUnchanged(t *testing.T) {
+	diff, err := DiffComposeFiles([]byte(currentCompose), []byte(currentCompose))
+	if err != nil {
+		t.Fatalf("DiffComposeFiles: %v", err)
+	}
+	if diff.HasChanges() {
+		t.Errorf("diff of identical files = %+v, want no changes", diff)
+	}
+}
@@ -0,0 +1,551 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// LabelPriority set to PriorityHigh marks a container as essential
+	LabelPriority = "nas.priority"
+	// LabelResourceLock set to "true" exempts a container from throttling
+	LabelResourceLock = "nas.resource-lock"
+
+	// Container priorities
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+
+	// GovernorInterval is how often the resource governor checks the host load
+	GovernorInterval = 30 * time.Second
+
+	// minMemoryLimit is the lowest memory limit the governor sets
+	minMemoryLimit = 64 << 20
+	// minCPUQuota is the lowest CPU quota the governor sets, in microseconds
+	minCPUQuota = 10000
+	// defaultCPUPeriod is Docker's CFS period when none is set, in microseconds
+	defaultCPUPeriod = 100000
+)
+
+// ErrInvalidPriority is returned for priorities other than high and normal
+var ErrInvalidPriority = errors.New("priority must be high or normal")
+
+// GovernorConfig is the configuration of the resource governor
+type GovernorConfig = models.ResourceGovernorConfig
+
+// DefaultGovernorConfig throttles when less than 10% of the memory is
+// available or more than half of the CPU time is stolen
+func DefaultGovernorConfig() GovernorConfig {
+	return GovernorConfig{
+		Enabled:                  true,
+		MemoryThresholdPercent:   10,
+		MemoryReductionPercent:   20,
+		CPUStealThresholdPercent: 50,
+	}
+}
+
+// ValidateGovernorConfig checks that the percentages are in range
+func ValidateGovernorConfig(cfg GovernorConfig) error {
+	for name, value := range map[string]float64{
+		"memory threshold":    cfg.MemoryThresholdPercent,
+		"memory reduction":    cfg.MemoryReductionPercent,
+		"CPU steal threshold": cfg.CPUStealThresholdPercent,
+	} {
+		if value <= 0 || value >= 100 {
+			return fmt.Errorf("%s must be between 0 and 100 percent", name)
+		}
+	}
+	return nil
+}
+
+// HostMetrics is the host load the resource governor acts on
+type HostMetrics struct {
+	MemoryTotalBytes       uint64  `json:"memoryTotalBytes"`
+	MemoryAvailablePercent float64 `json:"memoryAvailablePercent"`
+	CPUStealPercent        float64 `json:"cpuStealPercent"` // Since the previous check
+}
+
+// ThrottledContainer is a container whose limits the governor lowered. The
+// original limits are restored once the host recovers.
+type ThrottledContainer struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Memory           int64     `json:"memory,omitempty"`   // Memory limit set by the governor, 0 if not throttled
+	CPUQuota         int64     `json:"cpuQuota,omitempty"` // CPU quota set by the governor, 0 if not throttled
+	OriginalMemory   int64     `json:"originalMemory"`     // 0 = unlimited
+	OriginalCPUQuota int64     `json:"originalCpuQuota"`   // 0 = unlimited
+	ThrottledAt      time.Time `json:"throttledAt"`
+
+	cpuPeriod int64
+}
+
+// GovernorStatus is the state of the resource governor
+type GovernorStatus struct {
+	Config         GovernorConfig       `json:"config"`
+	Running        bool                 `json:"running"`
+	LastCheck      *time.Time           `json:"lastCheck,omitempty"`
+	Metrics        HostMetrics          `json:"metrics"`
+	MemoryPressure bool                 `json:"memoryPressure"`
+	CPUPressure    bool                 `json:"cpuPressure"`
+	Throttled      []ThrottledContainer `json:"throttled"`
+	Priorities     map[string]string    `json:"priorities"` // Priorities set through the API, by container name
+	LastError      string               `json:"lastError,omitempty"`
+}
+
+// containerAPI is the part of the Docker service the governor uses
+type containerAPI interface {
+	ListContainers(ctx context.Context, all bool) ([]types.Container, error)
+	InspectContainer(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	GetContainerStats(ctx context.Context, containerID string) (container.StatsResponse, error)
+	UpdateContainerResources(ctx context.Context, containerID string, resources container.Resources) error
+}
+
+// metricsSource reads the host load
+type metricsSource interface {
+	HostMetrics(ctx context.Context) (HostMetrics, error)
+}
+
+// ResourceGovernor throttles the containers of non-essential workloads
+// while the host runs short of memory or loses CPU time to its hypervisor,
+// so the NAS services keep working. Containers labelled nas.priority=high
+// or nas.resource-lock=true are left alone.
+type ResourceGovernor struct {
+	db       *gorm.DB
+	docker   containerAPI
+	metrics  metricsSource
+	interval time.Duration
+
+	mu             sync.Mutex
+	config         GovernorConfig
+	running        bool
+	lastCheck      time.Time
+	last           HostMetrics
+	memoryPressure bool
+	cpuPressure    bool
+	lastErr        error
+	throttled      map[string]*ThrottledContainer // By container ID
+}
+
+var globalGovernor *ResourceGovernor
+
+// NewResourceGovernor creates the resource governor for the Docker service,
+// loading its configuration
+func NewResourceGovernor(db *gorm.DB, service *Service) (*ResourceGovernor, error) {
+	if !service.IsAvailable() {
+		return nil, fmt.Errorf("Docker is not available")
+	}
+	g, err := newResourceGovernor(db, service, &hostMetricsSource{})
+	if err != nil {
+		return nil, err
+	}
+	globalGovernor = g
+	return g, nil
+}
+
+func newResourceGovernor(db *gorm.DB, docker containerAPI, metrics metricsSource) (*ResourceGovernor, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	config := DefaultGovernorConfig()
+	if err := db.FirstOrCreate(&config).Error; err != nil {
+		return nil, fmt.Errorf("failed to load resource governor config: %w", err)
+	}
+
+	return &ResourceGovernor{
+		db:        db,
+		docker:    docker,
+		metrics:   metrics,
+		interval:  GovernorInterval,
+		config:    config,
+		throttled: make(map[string]*ThrottledContainer),
+	}, nil
+}
+
+// GetResourceGovernor returns the global resource governor, nil if Docker
+// is not available
+func GetResourceGovernor() *ResourceGovernor {
+	return globalGovernor
+}
+
+// Start checks the host load every 30 seconds until ctx is cancelled, then
+// restores the limits of the throttled containers
+func (g *ResourceGovernor) Start(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running {
+		return fmt.Errorf("resource governor already running")
+	}
+	g.running = true
+
+	go func() {
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		for {
+			if err := g.Check(ctx); err != nil && ctx.Err() == nil {
+				logger.Warn("Resource governor check failed", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				g.mu.Lock()
+				g.running = false
+				g.restoreAll(context.Background())
+				g.mu.Unlock()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Check reads the host load once, throttling the running containers that
+// are not protected if the host is under pressure and restoring them once
+// it no longer is
+func (g *ResourceGovernor) Check(ctx context.Context) error {
+	metrics, err := g.metrics.HostMetrics(ctx)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lastCheck = time.Now()
+	g.lastErr = err
+	if err != nil {
+		return fmt.Errorf("failed to read host metrics: %w", err)
+	}
+	g.last = metrics
+	g.memoryPressure = g.config.Enabled && metrics.MemoryAvailablePercent < g.config.MemoryThresholdPercent
+	g.cpuPressure = g.config.Enabled && metrics.CPUStealPercent > g.config.CPUStealThresholdPercent
+
+	if !g.memoryPressure && !g.cpuPressure {
+		g.restoreAll(ctx)
+		return nil
+	}
+
+	containers, err := g.docker.ListContainers(ctx, false)
+	if err != nil {
+		g.lastErr = err
+		return err
+	}
+	priorities, err := g.priorities()
+	if err != nil {
+		g.lastErr = err
+		return err
+	}
+
+	running := map[string]bool{}
+	var failed []string
+	for _, c := range containers {
+		running[c.ID] = true
+		if err := g.govern(ctx, c, priorities); err != nil {
+			logger.Warn("Failed to adjust container limits", zap.String("container", containerName(c)), zap.Error(err))
+			failed = append(failed, containerName(c))
+		}
+	}
+	// Containers that stopped lose their runtime limits anyway
+	for id := range g.throttled {
+		if !running[id] {
+			delete(g.throttled, id)
+		}
+	}
+
+	if len(failed) > 0 {
+		g.lastErr = fmt.Errorf("failed to adjust containers: %s", strings.Join(failed, ", "))
+		return g.lastErr
+	}
+	return nil
+}
+
+// govern throttles or restores a container according to the host load
+func (g *ResourceGovernor) govern(ctx context.Context, c types.Container, priorities map[string]string) error {
+	t := g.throttled[c.ID]
+	if isProtected(c, priorities) {
+		if t != nil {
+			return g.restore(ctx, t)
+		}
+		return nil
+	}
+
+	if t == nil {
+		info, err := g.docker.InspectContainer(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		t = &ThrottledContainer{ID: c.ID, Name: containerName(c), cpuPeriod: defaultCPUPeriod}
+		if info.HostConfig != nil {
+			t.OriginalMemory = info.HostConfig.Memory
+			t.OriginalCPUQuota = info.HostConfig.CPUQuota
+			if info.HostConfig.CPUPeriod > 0 {
+				t.cpuPeriod = info.HostConfig.CPUPeriod
+			}
+		}
+	}
+
+	var resources container.Resources
+	switch {
+	case g.memoryPressure && t.Memory == 0:
+		base := t.OriginalMemory
+		if base == 0 {
+			// Unlimited containers are limited below what they use now
+			stats, err := g.docker.GetContainerStats(ctx, c.ID)
+			if err != nil {
+				return err
+			}
+			base = int64(stats.MemoryStats.Usage)
+		}
+		t.Memory = max(int64(float64(base)*(1-g.config.MemoryReductionPercent/100)), minMemoryLimit)
+		resources.Memory = t.Memory
+		resources.MemorySwap = -1
+	case !g.memoryPressure && t.Memory != 0:
+		t.Memory = 0
+		resources.Memory = g.memoryLimit(t.OriginalMemory)
+		resources.MemorySwap = -1
+	}
+
+	if g.cpuPressure {
+		base := t.OriginalCPUQuota
+		if base <= 0 {
+			base = t.cpuPeriod * int64(cpuCount())
+		}
+		if quota := max(int64(float64(base)*(1-g.last.CPUStealPercent/100)), minCPUQuota); quota != t.CPUQuota {
+			t.CPUQuota = quota
+			resources.CPUQuota = quota
+		}
+	} else if t.CPUQuota != 0 {
+		t.CPUQuota = 0
+		resources.CPUQuota = cpuQuota(t.OriginalCPUQuota)
+	}
+
+	if resources.Memory == 0 && resources.CPUQuota == 0 {
+		return nil
+	}
+	if err := g.docker.UpdateContainerResources(ctx, c.ID, resources); err != nil {
+		return err
+	}
+
+	if t.Memory == 0 && t.CPUQuota == 0 {
+		delete(g.throttled, c.ID)
+		logger.Info("Container limits restored", zap.String("container", t.Name))
+		return nil
+	}
+	if _, ok := g.throttled[c.ID]; !ok {
+		t.ThrottledAt = time.Now()
+		g.throttled[c.ID] = t
+	}
+	logger.Info("Container throttled", zap.String("container", t.Name),
+		zap.Int64("memory", t.Memory), zap.Int64("cpuQuota", t.CPUQuota))
+	return nil
+}
+
+// restore puts back the original limits of a throttled container
+func (g *ResourceGovernor) restore(ctx context.Context, t *ThrottledContainer) error {
+	var resources container.Resources
+	if t.Memory != 0 {
+		resources.Memory = g.memoryLimit(t.OriginalMemory)
+		resources.MemorySwap = -1
+	}
+	if t.CPUQuota != 0 {
+		resources.CPUQuota = cpuQuota(t.OriginalCPUQuota)
+	}
+	if err := g.docker.UpdateContainerResources(ctx, t.ID, resources); err != nil {
+		return err
+	}
+	delete(g.throttled, t.ID)
+	logger.Info("Container limits restored", zap.String("container", t.Name))
+	return nil
+}
+
+// restoreAll restores every throttled container. Containers that cannot
+// be restored, usually because they were removed, are forgotten.
+func (g *ResourceGovernor) restoreAll(ctx context.Context) {
+	for id, t := range g.throttled {
+		if err := g.restore(ctx, t); err != nil {
+			logger.Warn("Failed to restore container limits", zap.String("container", t.Name), zap.Error(err))
+			delete(g.throttled, id)
+		}
+	}
+}
+
+// memoryLimit returns the limit that restores original. Docker cannot
+// remove a memory limit, so unlimited containers get the host's memory.
+func (g *ResourceGovernor) memoryLimit(original int64) int64 {
+	if original > 0 {
+		return original
+	}
+	return int64(g.last.MemoryTotalBytes)
+}
+
+// cpuQuota returns the quota that restores original; -1 removes the quota
+func cpuQuota(original int64) int64 {
+	if original > 0 {
+		return original
+	}
+	return -1
+}
+
+// SetContainerPriority gives a container the high or normal priority. It
+// takes the place of the nas.priority label, as labels of existing
+// containers cannot be changed, and sticks to the container's name so it
+// survives the container being recreated.
+func (g *ResourceGovernor) SetContainerPriority(containerID string, priority string) error {
+	if priority != PriorityHigh && priority != PriorityNormal {
+		return ErrInvalidPriority
+	}
+
+	info, err := g.docker.InspectContainer(context.Background(), containerID)
+	if err != nil {
+		return err
+	}
+	record := models.ContainerPriority{
+		ContainerName: strings.TrimPrefix(info.Name, "/"),
+		Priority:      priority,
+	}
+	if err := g.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error; err != nil {
+		return fmt.Errorf("failed to save container priority: %w", err)
+	}
+
+	logger.Info("Container priority set", zap.String("container", record.ContainerName), zap.String("priority", priority))
+	return nil
+}
+
+// UpdateConfig saves a new configuration, which applies from the next
+// check. Disabling the governor restores the throttled containers.
+func (g *ResourceGovernor) UpdateConfig(cfg GovernorConfig) error {
+	if err := ValidateGovernorConfig(cfg); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cfg.ID = g.config.ID
+	if err := g.db.Save(&cfg).Error; err != nil {
+		return fmt.Errorf("failed to save resource governor config: %w", err)
+	}
+	g.config = cfg
+	if !cfg.Enabled {
+		g.memoryPressure, g.cpuPressure = false, false
+		g.restoreAll(context.Background())
+	}
+	return nil
+}
+
+// Status returns the configuration, the last host metrics and the
+// throttled containers
+func (g *ResourceGovernor) Status() (*GovernorStatus, error) {
+	priorities, err := g.priorities()
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := &GovernorStatus{
+		Config:         g.config,
+		Running:        g.running,
+		Metrics:        g.last,
+		MemoryPressure: g.memoryPressure,
+		CPUPressure:    g.cpuPressure,
+		Throttled:      make([]ThrottledContainer, 0, len(g.throttled)),
+		Priorities:     priorities,
+	}
+	if !g.lastCheck.IsZero() {
+		lastCheck := g.lastCheck
+		status.LastCheck = &lastCheck
+	}
+	if g.lastErr != nil {
+		status.LastError = g.lastErr.Error()
+	}
+	for _, t := range g.throttled {
+		status.Throttled = append(status.Throttled, *t)
+	}
+	sort.Slice(status.Throttled, func(i, j int) bool { return status.Throttled[i].Name < status.Throttled[j].Name })
+	return status, nil
+}
+
+// priorities returns the priorities set through SetContainerPriority
+func (g *ResourceGovernor) priorities() (map[string]string, error) {
+	var records []models.ContainerPriority
+	if err := g.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load container priorities: %w", err)
+	}
+	priorities := make(map[string]string, len(records))
+	for _, record := range records {
+		priorities[record.ContainerName] = record.Priority
+	}
+	return priorities, nil
+}
+
+// isProtected reports whether a container must not be throttled: it is
+// resource-locked or has the high priority
+func isProtected(c types.Container, priorities map[string]string) bool {
+	if c.Labels[LabelResourceLock] == "true" {
+		return true
+	}
+	priority, ok := priorities[containerName(c)]
+	if !ok {
+		priority = c.Labels[LabelPriority]
+	}
+	return priority == PriorityHigh
+}
+
+// containerName returns the name of a container without the leading slash
+func containerName(c types.Container) string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// Replaced in tests
+var cpuCount = func() int {
+	n, err := cpu.Counts(true)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// hostMetricsSource reads the host load with gopsutil. CPU steal is
+// measured between two calls, so the first call reports none.
+type hostMetricsSource struct {
+	last *cpu.TimesStat
+}
+
+func (s *hostMetricsSource) HostMetrics(ctx context.Context) (HostMetrics, error) {
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return HostMetrics{}, err
+	}
+	times, err := cpu.TimesWithContext(ctx, false)
+	if err != nil || len(times) == 0 {
+		return HostMetrics{}, fmt.Errorf("failed to read CPU times: %w", err)
+	}
+
+	metrics := HostMetrics{MemoryTotalBytes: vm.Total}
+	if vm.Total > 0 {
+		metrics.MemoryAvailablePercent = float64(vm.Available) / float64(vm.Total) * 100
+	}
+	if s.last != nil {
+		if total := times[0].Total() - s.last.Total(); total > 0 {
+			metrics.CPUStealPercent = (times[0].Steal - s.last.Steal) / total * 100
+		}
+	}
+	s.last = &times[0]
+	return metrics, nil
+}
@@ -0,0 +1,186 @@
+package docker
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// fakeDocker runs containers with fixed limits and records the updates
+type fakeDocker struct {
+	containers []types.Container
+	limits     map[string]container.Resources
+	usage      map[string]uint64
+	updates    map[string][]container.Resources
+}
+
+func (f *fakeDocker) ListContainers(ctx context.Context, all bool) ([]types.Container, error) {
+	return f.containers, nil
+}
+
+func (f *fakeDocker) InspectContainer(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	info := types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+		ID:         containerID,
+		Name:       "/" + containerID,
+		HostConfig: &container.HostConfig{Resources: f.limits[containerID]},
+	}}
+	return info, nil
+}
+
+func (f *fakeDocker) GetContainerStats(ctx context.Context, containerID string) (container.StatsResponse, error) {
+	var stats container.StatsResponse
+	stats.MemoryStats.Usage = f.usage[containerID]
+	return stats, nil
+}
+
+func (f *fakeDocker) UpdateContainerResources(ctx context.Context, containerID string, resources container.Resources) error {
+	f.updates[containerID] = append(f.updates[containerID], resources)
+	return nil
+}
+
+// fakeMetrics reports whatever the test sets
+type fakeMetrics struct {
+	metrics HostMetrics
+}
+
+func (f *fakeMetrics) HostMetrics(ctx context.Context) (HostMetrics, error) {
+	return f.metrics, nil
+}
+
+func newTestGovernor(t *testing.T) (*ResourceGovernor, *fakeDocker, *fakeMetrics) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "docker.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ResourceGovernorConfig{}, &models.ContainerPriority{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	fake := &fakeDocker{
+		containers: []types.Container{
+			{ID: "web", Names: []string{"/web"}},
+			{ID: "db", Names: []string{"/db"}, Labels: map[string]string{LabelPriority: PriorityHigh}},
+			{ID: "backup", Names: []string{"/backup"}, Labels: map[string]string{LabelResourceLock: "true"}},
+			{ID: "media", Names: []string{"/media"}},
+			{ID: "cache", Names: []string{"/cache"}},
+		},
+		limits: map[string]container.Resources{
+			"web":   {Memory: 1 << 30, CPUQuota: 200000, CPUPeriod: 100000},
+			"db":    {Memory: 4 << 30},
+			"cache": {Memory: 512 << 20},
+		},
+		usage:   map[string]uint64{"media": 800 << 20},
+		updates: map[string][]container.Resources{},
+	}
+	metrics := &fakeMetrics{metrics: HostMetrics{MemoryTotalBytes: 16 << 30, MemoryAvailablePercent: 50}}
+
+	g, err := newResourceGovernor(db, fake, metrics)
+	if err != nil {
+		t.Fatalf("newResourceGovernor: %v", err)
+	}
+	realCPUCount := cpuCount
+	cpuCount = func() int { return 4 }
+	t.Cleanup(func() { cpuCount = realCPUCount })
+	return g, fake, metrics
+}
+
+func TestResourceGovernorThrottlesUnprotectedContainers(t *testing.T) {
+	g, fake, metrics := newTestGovernor(t)
+	ctx := context.Background()
+
+	// A priority set through the API protects a container like the label
+	if err := g.SetContainerPriority("cache", PriorityHigh); err != nil {
+		t.Fatalf("SetContainerPriority: %v", err)
+	}
+
+	if err := g.Check(ctx); err != nil {
+		t.Fatalf("Check without pressure: %v", err)
+	}
+	if len(fake.updates) != 0 {
+		t.Fatalf("containers updated without pressure: %v", fake.updates)
+	}
+
+	metrics.metrics.MemoryAvailablePercent = 5
+	metrics.metrics.CPUStealPercent = 60
+	if err := g.Check(ctx); err != nil {
+		t.Fatalf("Check under pressure: %v", err)
+	}
+	want := map[string][]container.Resources{
+		// 80% of the limit, and the quota cut by the 60% steal
+		"web": {{Memory: 858993459, MemorySwap: -1, CPUQuota: 80000}},
+		// Unlimited: 80% of the usage, and of all 4 CPUs
+		"media": {{Memory: 671088640, MemorySwap: -1, CPUQuota: 160000}},
+	}
+	if !reflect.DeepEqual(fake.updates, want) {
+		t.Fatalf("updates under pressure = %+v, want %+v", fake.updates, want)
+	}
+
+	// Limits are lowered once per pressure episode
+	if err := g.Check(ctx); err != nil {
+		t.Fatalf("second Check under pressure: %v", err)
+	}
+	if len(fake.updates["web"]) != 1 {
+		t.Errorf("web updated again: %+v", fake.updates["web"])
+	}
+
+	status, err := g.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.MemoryPressure || !status.CPUPressure || len(status.Throttled) != 2 || status.Priorities["cache"] != PriorityHigh {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	// Recovery restores the original limits; unlimited ones as far as Docker allows
+	metrics.metrics = HostMetrics{MemoryTotalBytes: 16 << 30, MemoryAvailablePercent: 40}
+	if err := g.Check(ctx); err != nil {
+		t.Fatalf("Check after recovery: %v", err)
+	}
+	if got, want := fake.updates["web"][1], (container.Resources{Memory: 1 << 30, MemorySwap: -1, CPUQuota: 200000}); !reflect.DeepEqual(got, want) {
+		t.Errorf("web restored to %+v, want %+v", got, want)
+	}
+	if got, want := fake.updates["media"][1], (container.Resources{Memory: 16 << 30, MemorySwap: -1, CPUQuota: -1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("media restored to %+v, want %+v", got, want)
+	}
+	for _, id := range []string{"db", "backup", "cache"} {
+		if updates := fake.updates[id]; len(updates) != 0 {
+			t.Errorf("protected container %s was updated: %+v", id, updates)
+		}
+	}
+}
+
+func TestResourceGovernorDisabled(t *testing.T) {
+	g, fake, metrics := newTestGovernor(t)
+
+	cfg := DefaultGovernorConfig()
+	cfg.Enabled = false
+	if err := g.UpdateConfig(cfg); err != nil {
+		t.Fatalf("UpdateConfig: %v", err)
+	}
+	metrics.metrics.MemoryAvailablePercent = 1
+	if err := g.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(fake.updates) != 0 {
+		t.Errorf("disabled governor updated containers: %v", fake.updates)
+	}
+
+	cfg.MemoryThresholdPercent = 100
+	if err := g.UpdateConfig(cfg); err == nil {
+		t.Error("UpdateConfig accepted a 100% memory threshold")
+	}
+}
@@ -0,0 +1,224 @@
+// Package events provides an in-process publish/subscribe bus for NAS events
+// (alerts, share changes, user changes, ...). Subscribers receive events over
+// buffered channels so a slow consumer never blocks a publisher.
+package events
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Event types
+const (
+	TypeAlert   = "alert"
+	TypeShare   = "share"
+	TypeUser    = "user"
+	TypeSystem  = "system"
+	TypeConfig  = "config"
+	TypeMetrics = "metrics"
+	TypeArchive = "archive"
+	TypeBackup  = "backup"
+)
+
+// Event severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+const (
+	// historySize is the number of recent events kept per event type for
+	// reconnecting subscribers
+	historySize = 100
+
+	// subscriberBuffer is the channel buffer size per subscriber
+	subscriberBuffer = 64
+)
+
+// Event represents a single NAS event
+type Event struct {
+	ID        uint64                 `json:"id"`
+	Type      string                 `json:"type"`
+	Action    string                 `json:"action,omitempty"`
+	Severity  string                 `json:"severity"`
+	Source    string                 `json:"source,omitempty"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// Filter selects which events a subscriber receives
+type Filter struct {
+	Types   []string  // Empty = all types
+	Since   time.Time // Replay buffered events newer than this (zero = no replay)
+	AfterID uint64    // Replay buffered events with a higher ID (Last-Event-ID, 0 = no replay)
+}
+
+// Matches returns true if the event passes the filter
+func (f Filter) Matches(e Event) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if strings.EqualFold(t, e.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription is a live event subscription
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+	bus    *Bus
+	once   sync.Once
+}
+
+// Close unsubscribes and closes the event channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.unsubscribe(s)
+	})
+}
+
+// Bus fans out published events to all matching subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	nextID      uint64
+	subscribers map[*Subscription]struct{}
+	history     map[string]*ringBuffer // keyed by event type
+}
+
+var (
+	globalBus *Bus
+	busOnce   sync.Once
+)
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+		history:     make(map[string]*ringBuffer),
+	}
+}
+
+// GetBus returns the global event bus
+func GetBus() *Bus {
+	busOnce.Do(func() {
+		globalBus = NewBus()
+	})
+	return globalBus
+}
+
+// Publish is a shortcut for GetBus().Publish
+func Publish(e Event) {
+	GetBus().Publish(e)
+}
+
+// Publish assigns an ID and timestamp to the event and delivers it to subscribers
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	if e.Severity == "" {
+		e.Severity = SeverityInfo
+	}
+
+	ring, ok := b.history[e.Type]
+	if !ok {
+		ring = newRingBuffer(historySize)
+		b.history[e.Type] = ring
+	}
+	ring.push(e)
+
+	// Deliveries are non-blocking, so it is safe to hold the lock here; this
+	// also guarantees a subscription is never closed mid-send.
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			logger.Warn("Event subscriber too slow, dropping event",
+				zap.Uint64("event_id", e.ID),
+				zap.String("type", e.Type))
+		}
+	}
+
+	return e
+}
+
+// Subscribe registers a new subscriber. Buffered events newer than
+// filter.Since or filter.AfterID are replayed in order before any live events.
+func (b *Bus) Subscribe(filter Filter) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Size the channel so the replay never drops events
+	missed := b.replay(filter)
+	ch := make(chan Event, subscriberBuffer+len(missed))
+	sub := &Subscription{C: ch, ch: ch, filter: filter, bus: b}
+	for _, e := range missed {
+		ch <- e
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// SubscriberCount returns the number of active subscribers
+func (b *Bus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// replay returns the buffered events a new subscriber missed, ordered by ID.
+// The caller must hold b.mu.
+func (b *Bus) replay(filter Filter) []Event {
+	if filter.Since.IsZero() && filter.AfterID == 0 {
+		return nil
+	}
+
+	var missed []Event
+	for _, ring := range b.history {
+		for _, e := range ring.items() {
+			if !filter.Matches(e) {
+				continue
+			}
+			if filter.AfterID > 0 && e.ID <= filter.AfterID {
+				continue
+			}
+			if !filter.Since.IsZero() && !e.Timestamp.After(filter.Since) {
+				continue
+			}
+			missed = append(missed, e)
+		}
+	}
+
+	sort.Slice(missed, func(i, j int) bool { return missed[i].ID < missed[j].ID })
+	return missed
+}
+
+func (b *Bus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
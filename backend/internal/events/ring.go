@@ -0,0 +1,33 @@
+package events
+
+// ringBuffer is a fixed-size FIFO of events. Once full, the oldest event is
+// overwritten.
+type ringBuffer struct {
+	events []Event
+	start  int
+	size   int
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{events: make([]Event, capacity)}
+}
+
+// push appends an event, evicting the oldest one if the buffer is full
+func (r *ringBuffer) push(e Event) {
+	end := (r.start + r.size) % len(r.events)
+	r.events[end] = e
+	if r.size < len(r.events) {
+		r.size++
+	} else {
+		r.start = (r.start + 1) % len(r.events)
+	}
+}
+
+// items returns the buffered events, oldest first
+func (r *ringBuffer) items() []Event {
+	out := make([]Event, 0, r.size)
+	for i := 0; i < r.size; i++ {
+		out = append(out, r.events[(r.start+i)%len(r.events)])
+	}
+	return out
+}
@@ -3,104 +3,195 @@ package files
 
 import (
 	"archive/tar"
-	"archive/zip"
+	"bytes"
 	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/alexmullins/zip"
+	"github.com/ulikunitz/xz"
 	"go.uber.org/zap"
 )
 
-// CreateArchive creates a compressed archive from specified files
-func (s *Service) CreateArchive(ctx *SecurityContext, req *ArchiveRequest) error {
-	// Validate paths
-	sourcePaths, err := s.validator.ValidatePaths(req.Paths)
-	if err != nil {
-		return err
+// Archive formats
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatTarXz = "tar.xz"
+)
+
+// AsyncArchiveThreshold is the total source size above which archives are
+// created in the background
+const AsyncArchiveThreshold = 100 << 20 // 100 MB
+
+// archiveProgressInterval is the minimum time between progress events
+const archiveProgressInterval = time.Second
+
+// CreateArchive creates a compressed archive from specified files. Archives
+// of more than AsyncArchiveThreshold bytes are created in the background;
+// the result then carries a job ID and progress is published as
+// events.TypeArchive events.
+func (s *Service) CreateArchive(ctx *SecurityContext, req *CreateArchiveRequest) (*ArchiveResult, error) {
+	format, ok := normalizeArchiveFormat(req.Format)
+	if !ok {
+		return nil, errors.BadRequest("Unsupported archive format (supported: zip, tar, tar.gz, tar.xz)", nil)
+	}
+	if req.CompressionLevel < 0 || req.CompressionLevel > 9 {
+		return nil, errors.BadRequest("Compression level must be between 1 and 9 (0 = default)", nil)
+	}
+	if req.Password != "" && format != ArchiveFormatZip {
+		return nil, errors.BadRequest("Password protection is only supported for zip archives", nil)
+	}
+	if len(req.Paths) == 0 {
+		return nil, errors.BadRequest("No paths provided", nil)
 	}
 
-	// Check read permissions for all sources
-	for _, path := range sourcePaths {
-		if err := s.permissions.CanAccess(ctx, path); err != nil {
-			return err
+	// Resolve sources, rejecting symlinks that lead out of the share
+	sourcePaths := make([]string, 0, len(req.Paths))
+	for _, path := range req.Paths {
+		resolved, err := s.ResolvePath(ctx, path)
+		if err != nil {
+			return nil, err
 		}
+		sourcePaths = append(sourcePaths, resolved)
 	}
 
-	// Validate output path
-	outputPath, err := s.validator.ValidateAndSanitize(req.OutputPath)
+	// Resolve the output directory the same way
+	if req.Destination == "" {
+		return nil, errors.BadRequest("Missing destination", nil)
+	}
+	outputDir, err := s.ResolvePath(ctx, filepath.Dir(filepath.Clean(req.Destination)))
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Check write permissions for output
-	outputDir := filepath.Dir(outputPath)
 	if err := s.permissions.CanWrite(ctx, outputDir); err != nil {
-		return err
+		return nil, err
+	}
+	outputPath := filepath.Join(outputDir, filepath.Base(req.Destination))
+	if _, err := os.Lstat(outputPath); err == nil {
+		return nil, errors.Conflict("Destination already exists", nil)
 	}
 
-	// Create archive based on format
-	switch req.Format {
-	case "zip":
-		err = s.createZipArchive(sourcePaths, outputPath)
-	case "tar":
-		err = s.createTarArchive(sourcePaths, outputPath, false)
-	case "tar.gz", "tgz":
-		err = s.createTarArchive(sourcePaths, outputPath, true)
-	default:
-		return errors.BadRequest("Unsupported archive format (supported: zip, tar, tar.gz)", nil)
+	var totalBytes int64
+	for _, sourcePath := range sourcePaths {
+		if isWithin(sourcePath, outputPath) {
+			return nil, errors.BadRequest("Destination must not be inside an archived directory", nil)
+		}
+		size, err := sourceSize(sourcePath)
+		if err != nil {
+			return nil, errors.InternalServerError("Failed to read source", err)
+		}
+		totalBytes += size
+	}
+
+	result := &ArchiveResult{Destination: outputPath, Format: format, TotalBytes: totalBytes}
+	opts := archiveOptions{format: format, level: req.CompressionLevel, password: req.Password}
+
+	if totalBytes <= AsyncArchiveThreshold {
+		if err := writeArchive(sourcePaths, outputPath, opts, nil); err != nil {
+			return nil, err
+		}
+		logger.Info("Archive created", zap.String("output", outputPath), zap.String("format", format), zap.String("user", ctx.User.Username))
+		return result, nil
 	}
 
+	jobID, err := newArchiveJobID()
 	if err != nil {
-		return err
+		return nil, errors.InternalServerError("Failed to generate job ID", err)
 	}
+	result.JobID = jobID
 
-	logger.Info("Archive created", zap.String("output", outputPath), zap.String("format", req.Format), zap.String("user", ctx.User.Username))
-	return nil
+	go s.runArchiveJob(ctx.User.Username, result, sourcePaths, opts)
+	return result, nil
 }
 
-// ExtractArchive extracts a compressed archive
+// runArchiveJob creates an archive in the background, publishing progress events
+func (s *Service) runArchiveJob(username string, job *ArchiveResult, sourcePaths []string, opts archiveOptions) {
+	publish := func(action, severity, message string, done int64) {
+		data := map[string]interface{}{
+			"jobId":       job.JobID,
+			"destination": job.Destination,
+			"user":        username,
+			"bytesDone":   done,
+			"bytesTotal":  job.TotalBytes,
+		}
+		if job.TotalBytes > 0 {
+			data["percent"] = done * 100 / job.TotalBytes
+		}
+		events.Publish(events.Event{
+			Type:     events.TypeArchive,
+			Action:   action,
+			Severity: severity,
+			Source:   "files",
+			Message:  message,
+			Data:     data,
+		})
+	}
+
+	publish("started", events.SeverityInfo, "Creating archive "+filepath.Base(job.Destination), 0)
+
+	var done atomic.Int64
+	lastEvent := time.Now()
+	err := writeArchive(sourcePaths, job.Destination, opts, func(n int64) {
+		total := done.Add(n)
+		if time.Since(lastEvent) >= archiveProgressInterval {
+			lastEvent = time.Now()
+			publish("progress", events.SeverityInfo, "Creating archive "+filepath.Base(job.Destination), total)
+		}
+	})
+
+	if err != nil {
+		logger.Error("Archive job failed", zap.String("job", job.JobID), zap.String("output", job.Destination), zap.Error(err))
+		publish("failed", events.SeverityWarning, "Failed to create archive "+filepath.Base(job.Destination)+": "+err.Error(), done.Load())
+		return
+	}
+
+	logger.Info("Archive created", zap.String("job", job.JobID), zap.String("output", job.Destination), zap.String("format", opts.format), zap.String("user", username))
+	publish("completed", events.SeverityInfo, "Archive "+filepath.Base(job.Destination)+" created", job.TotalBytes)
+}
+
+// ExtractArchive extracts a compressed archive. The format is detected from
+// the file content, not its extension.
 func (s *Service) ExtractArchive(ctx *SecurityContext, req *ExtractRequest) error {
 	// Validate archive path
-	archivePath, err := s.validator.ValidateAndSanitize(req.ArchivePath)
+	archivePath, err := s.ResolvePath(ctx, req.ArchivePath)
 	if err != nil {
 		return err
 	}
 
-	// Check read permissions
-	if err := s.permissions.CanAccess(ctx, archivePath); err != nil {
-		return err
+	// Validate destination path, creating it if needed
+	if req.Destination == "" {
+		return errors.BadRequest("Missing destination", nil)
 	}
-
-	// Validate destination path
-	destPath, err := s.validator.ValidateAndSanitize(req.Destination)
+	parentDir, err := s.ResolvePath(ctx, filepath.Dir(filepath.Clean(req.Destination)))
 	if err != nil {
 		return err
 	}
-
-	// Check write permissions
-	if err := s.permissions.CanWrite(ctx, destPath); err != nil {
+	destPath := filepath.Join(parentDir, filepath.Base(req.Destination))
+	if err := s.permissions.CanWrite(ctx, parentDir); err != nil {
 		return err
 	}
-
-	// Detect archive format from extension
-	ext := strings.ToLower(filepath.Ext(archivePath))
-
-	switch ext {
-	case ".zip":
-		err = s.extractZipArchive(archivePath, destPath)
-	case ".tar":
-		err = s.extractTarArchive(archivePath, destPath, false)
-	case ".gz", ".tgz":
-		err = s.extractTarArchive(archivePath, destPath, true)
-	default:
-		return errors.BadRequest("Unsupported archive format (supported: .zip, .tar, .tar.gz, .tgz)", nil)
+	if err := os.MkdirAll(destPath, 0755); err != nil {
+		return errors.InternalServerError("Failed to create destination", err)
+	}
+	if destPath, err = s.ResolvePath(ctx, destPath); err != nil {
+		return err
 	}
 
-	if err != nil {
+	if err := extractArchive(archivePath, destPath, req.Password); err != nil {
 		return err
 	}
 
@@ -108,275 +199,469 @@ func (s *Service) ExtractArchive(ctx *SecurityContext, req *ExtractRequest) erro
 	return nil
 }
 
-// Helper: createZipArchive creates a ZIP archive
-func (s *Service) createZipArchive(sourcePaths []string, outputPath string) error {
-	// Create output file
-	zipFile, err := os.Create(outputPath)
-	if err != nil {
-		return errors.InternalServerError("Failed to create archive file", err)
+// DetectArchiveFormat detects the archive format from the first 262 bytes of
+// a file. Compressed streams are assumed to contain a tar archive. Returns
+// "" for unknown formats.
+func DetectArchiveFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return ArchiveFormatZip
+	case bytes.HasPrefix(header, []byte{0x1F, 0x8B}):
+		return ArchiveFormatTarGz
+	case bytes.HasPrefix(header, []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}):
+		return ArchiveFormatTarXz
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return ArchiveFormatTar
 	}
-	defer zipFile.Close()
+	return ""
+}
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+// archiveOptions controls how writeArchive creates an archive
+type archiveOptions struct {
+	format   string
+	level    int    // 1-9, 0 = default
+	password string // zip only
+}
 
-	// Add each source to the archive
-	for _, sourcePath := range sourcePaths {
-		if err := s.addToZip(zipWriter, sourcePath, ""); err != nil {
-			os.Remove(outputPath) // Cleanup on error
-			return err
-		}
+// normalizeArchiveFormat maps format aliases to the Archive* constants
+func normalizeArchiveFormat(format string) (string, bool) {
+	switch strings.ToLower(format) {
+	case "", "zip":
+		return ArchiveFormatZip, true
+	case "tar":
+		return ArchiveFormatTar, true
+	case "tar.gz", "tgz":
+		return ArchiveFormatTarGz, true
+	case "tar.xz", "txz":
+		return ArchiveFormatTarXz, true
 	}
-
-	return nil
+	return "", false
 }
 
-// Helper: addToZip adds a file or directory to a ZIP archive
-func (s *Service) addToZip(zipWriter *zip.Writer, sourcePath, baseInZip string) error {
-	info, err := os.Stat(sourcePath)
+// writeArchive writes sourcePaths to a new archive at outputPath. progress,
+// if set, is called with the number of source bytes read.
+func writeArchive(sourcePaths []string, outputPath string, opts archiveOptions, progress func(int64)) error {
+	// O_EXCL: never overwrite an existing file
+	outFile, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	if err != nil {
-		return errors.InternalServerError("Failed to stat file", err)
+		return errors.InternalServerError("Failed to create archive file", err)
 	}
 
-	if info.IsDir() {
-		// Add directory recursively
-		entries, err := os.ReadDir(sourcePath)
-		if err != nil {
-			return errors.InternalServerError("Failed to read directory", err)
-		}
-
-		for _, entry := range entries {
-			entryPath := filepath.Join(sourcePath, entry.Name())
-			entryBase := filepath.Join(baseInZip, filepath.Base(sourcePath), entry.Name())
-			if err := s.addToZip(zipWriter, entryPath, entryBase); err != nil {
-				return err
-			}
-		}
+	if opts.format == ArchiveFormatZip {
+		err = writeZip(outFile, sourcePaths, opts, progress)
 	} else {
-		// Add file
-		file, err := os.Open(sourcePath)
+		err = writeTar(outFile, sourcePaths, opts, progress)
+	}
+	if closeErr := outFile.Close(); err == nil && closeErr != nil {
+		err = errors.InternalServerError("Failed to write archive", closeErr)
+	}
+	if err != nil {
+		os.Remove(outputPath) // Cleanup on error
+		return err
+	}
+	return nil
+}
+
+// writeZip writes a ZIP archive, encrypting entries with AES-256 if a password is set
+func writeZip(w io.Writer, sourcePaths []string, opts archiveOptions, progress func(int64)) error {
+	zipWriter := zip.NewWriter(w)
+
+	err := walkSources(sourcePaths, func(path, name string, info fs.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
 		if err != nil {
-			return errors.InternalServerError("Failed to open file", err)
+			return errors.InternalServerError("Failed to create ZIP header", err)
 		}
-		defer file.Close()
+		header.Name = name
 
-		// Create entry in ZIP
-		zipPath := filepath.Join(baseInZip, filepath.Base(sourcePath))
-		if baseInZip == "" {
-			zipPath = filepath.Base(sourcePath)
+		if info.IsDir() {
+			header.Name += "/"
+			_, err := zipWriter.CreateHeader(header)
+			return err
 		}
 
-		writer, err := zipWriter.Create(zipPath)
+		header.Method = zip.Deflate
+		if opts.password != "" {
+			header.SetPassword(opts.password)
+		}
+		writer, err := zipWriter.CreateHeader(header)
 		if err != nil {
 			return errors.InternalServerError("Failed to create ZIP entry", err)
 		}
-
-		if _, err := io.Copy(writer, file); err != nil {
-			return errors.InternalServerError("Failed to write to ZIP", err)
-		}
+		return copyFile(writer, path, progress)
+	})
+	if err != nil {
+		return err
 	}
 
+	if err := zipWriter.Close(); err != nil {
+		return errors.InternalServerError("Failed to write ZIP archive", err)
+	}
 	return nil
 }
 
-// Helper: extractZipArchive extracts a ZIP archive
-func (s *Service) extractZipArchive(archivePath, destPath string) error {
-	reader, err := zip.OpenReader(archivePath)
+// writeTar writes a TAR archive, optionally compressed with gzip or xz
+func writeTar(w io.Writer, sourcePaths []string, opts archiveOptions, progress func(int64)) error {
+	var compressor io.WriteCloser
+	var err error
+
+	switch opts.format {
+	case ArchiveFormatTarGz:
+		level := gzip.DefaultCompression
+		if opts.level > 0 {
+			level = opts.level
+		}
+		compressor, err = gzip.NewWriterLevel(w, level)
+	case ArchiveFormatTarXz:
+		compressor, err = xz.WriterConfig{DictCap: xzDictCap(opts.level)}.NewWriter(w)
+	}
 	if err != nil {
-		return errors.InternalServerError("Failed to open ZIP archive", err)
+		return errors.InternalServerError("Failed to create compressor", err)
+	}
+	if compressor != nil {
+		w = compressor
 	}
-	defer reader.Close()
 
-	for _, file := range reader.File {
-		// Validate path to prevent zip slip
-		filePath := filepath.Join(destPath, file.Name)
-		if !strings.HasPrefix(filePath, filepath.Clean(destPath)+string(os.PathSeparator)) {
-			return errors.BadRequest("Archive contains invalid path (zip slip detected)", nil)
+	tarWriter := tar.NewWriter(w)
+	err = walkSources(sourcePaths, func(path, name string, info fs.FileInfo) error {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return errors.InternalServerError("Failed to create tar header", err)
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
 		}
 
-		if file.FileInfo().IsDir() {
-			// Create directory
-			if err := os.MkdirAll(filePath, file.Mode()); err != nil {
-				return errors.InternalServerError("Failed to create directory", err)
-			}
-		} else {
-			// Create file
-			if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-				return errors.InternalServerError("Failed to create parent directory", err)
-			}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return errors.InternalServerError("Failed to write tar header", err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return copyFile(tarWriter, path, progress)
+	})
+	if err != nil {
+		return err
+	}
 
-			outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
-			if err != nil {
-				return errors.InternalServerError("Failed to create file", err)
-			}
+	if err := tarWriter.Close(); err != nil {
+		return errors.InternalServerError("Failed to write tar archive", err)
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return errors.InternalServerError("Failed to write tar archive", err)
+		}
+	}
+	return nil
+}
 
-			rc, err := file.Open()
+// xzDictCap maps a compression level to an xz dictionary size, following
+// the xz presets
+func xzDictCap(level int) int {
+	switch {
+	case level == 0:
+		return 8 << 20 // xz default (-6)
+	case level <= 1:
+		return 1 << 20
+	case level <= 3:
+		return 4 << 20
+	case level <= 6:
+		return 8 << 20
+	case level <= 7:
+		return 16 << 20
+	case level <= 8:
+		return 32 << 20
+	default:
+		return 64 << 20
+	}
+}
+
+// walkSources calls fn for every directory and regular file below the source
+// paths. Entry names start with the base name of their source. Symlinks are
+// skipped so archives never include content from outside the sources.
+func walkSources(sourcePaths []string, fn func(path, name string, info fs.FileInfo) error) error {
+	for _, sourcePath := range sourcePaths {
+		base := filepath.Base(sourcePath)
+		err := filepath.Walk(sourcePath, func(path string, info fs.FileInfo, err error) error {
 			if err != nil {
-				outFile.Close()
-				return errors.InternalServerError("Failed to open ZIP entry", err)
+				return errors.InternalServerError("Failed to read source", err)
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				logger.Debug("Skipping non-regular file in archive", zap.String("path", path))
+				return nil
 			}
 
-			_, err = io.Copy(outFile, rc)
-			rc.Close()
-			outFile.Close()
-
+			rel, err := filepath.Rel(sourcePath, path)
 			if err != nil {
-				return errors.InternalServerError("Failed to extract file", err)
+				return err
 			}
+			return fn(path, filepath.ToSlash(filepath.Join(base, rel)), info)
+		})
+		if err != nil {
+			return err
 		}
 	}
-
 	return nil
 }
 
-// Helper: createTarArchive creates a TAR archive (optionally gzipped)
-func (s *Service) createTarArchive(sourcePaths []string, outputPath string, gzipped bool) error {
-	// Create output file
-	tarFile, err := os.Create(outputPath)
+// sourceSize returns the total size of the regular files below path
+func sourceSize(path string) (int64, error) {
+	var total int64
+	err := filepath.Walk(path, func(_ string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// copyFile copies a file into an archive entry, reporting progress
+func copyFile(w io.Writer, path string, progress func(int64)) error {
+	file, err := os.Open(path)
 	if err != nil {
-		return errors.InternalServerError("Failed to create archive file", err)
+		return errors.InternalServerError("Failed to open file", err)
 	}
-	defer tarFile.Close()
-
-	var tarWriter *tar.Writer
+	defer file.Close()
 
-	if gzipped {
-		gzipWriter := gzip.NewWriter(tarFile)
-		defer gzipWriter.Close()
-		tarWriter = tar.NewWriter(gzipWriter)
-	} else {
-		tarWriter = tar.NewWriter(tarFile)
+	var r io.Reader = file
+	if progress != nil {
+		r = &progressReader{r: file, progress: progress}
 	}
-	defer tarWriter.Close()
-
-	// Add each source to the archive
-	for _, sourcePath := range sourcePaths {
-		if err := s.addToTar(tarWriter, sourcePath, ""); err != nil {
-			os.Remove(outputPath) // Cleanup on error
-			return err
-		}
+	if _, err := io.Copy(w, r); err != nil {
+		return errors.InternalServerError("Failed to write to archive", err)
 	}
-
 	return nil
 }
 
-// Helper: addToTar adds a file or directory to a TAR archive
-func (s *Service) addToTar(tarWriter *tar.Writer, sourcePath, baseInTar string) error {
-	info, err := os.Stat(sourcePath)
-	if err != nil {
-		return errors.InternalServerError("Failed to stat file", err)
+// progressReader reports the number of bytes read
+type progressReader struct {
+	r        io.Reader
+	progress func(int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.progress(int64(n))
 	}
+	return n, err
+}
 
-	// Create tar header
-	header, err := tar.FileInfoHeader(info, "")
+// extractArchive extracts archivePath into destPath, which must exist
+func extractArchive(archivePath, destPath, password string) error {
+	destPath, err := filepath.EvalSymlinks(destPath)
 	if err != nil {
-		return errors.InternalServerError("Failed to create tar header", err)
+		return errors.InternalServerError("Failed to resolve destination", err)
 	}
 
-	// Set name in archive
-	if baseInTar != "" {
-		header.Name = filepath.Join(baseInTar, filepath.Base(sourcePath))
-	} else {
-		header.Name = filepath.Base(sourcePath)
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return errors.InternalServerError("Failed to open archive", err)
+	}
+	header := make([]byte, 262)
+	n, _ := io.ReadFull(file, header)
+	file.Close()
+
+	switch format := DetectArchiveFormat(header[:n]); format {
+	case ArchiveFormatZip:
+		return extractZip(archivePath, destPath, password)
+	case ArchiveFormatTar, ArchiveFormatTarGz, ArchiveFormatTarXz:
+		if password != "" {
+			return errors.BadRequest("Password protection is only supported for zip archives", nil)
+		}
+		return extractTar(archivePath, destPath, format)
 	}
+	return errors.BadRequest("Unsupported archive format (supported: zip, tar, tar.gz, tar.xz)", nil)
+}
 
-	// Write header
-	if err := tarWriter.WriteHeader(header); err != nil {
-		return errors.InternalServerError("Failed to write tar header", err)
+// extractZip extracts a ZIP archive, decrypting entries with password
+func extractZip(archivePath, destPath, password string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return errors.BadRequest("Failed to open ZIP archive", err)
 	}
+	defer reader.Close()
 
-	if info.IsDir() {
-		// Add directory recursively
-		entries, err := os.ReadDir(sourcePath)
+	for _, file := range reader.File {
+		targetPath, err := safeExtractPath(destPath, file.Name)
 		if err != nil {
-			return errors.InternalServerError("Failed to read directory", err)
+			return err
+		}
+		if targetPath == "" {
+			continue
 		}
 
-		for _, entry := range entries {
-			entryPath := filepath.Join(sourcePath, entry.Name())
-			entryBase := header.Name
-			if err := s.addToTar(tarWriter, entryPath, entryBase); err != nil {
+		info := file.FileInfo()
+		if info.IsDir() {
+			if err := mkdirWithin(destPath, targetPath); err != nil {
 				return err
 			}
+			continue
 		}
-	} else {
-		// Add file content
-		file, err := os.Open(sourcePath)
-		if err != nil {
-			return errors.InternalServerError("Failed to open file", err)
+		if !info.Mode().IsRegular() {
+			logger.Warn("Skipping unsupported ZIP entry", zap.String("name", file.Name), zap.String("mode", info.Mode().String()))
+			continue
 		}
-		defer file.Close()
 
-		if _, err := io.Copy(tarWriter, file); err != nil {
-			return errors.InternalServerError("Failed to write to tar", err)
+		if file.IsEncrypted() {
+			if password == "" {
+				return errors.BadRequest("Archive is encrypted, a password is required", nil)
+			}
+			file.SetPassword(password)
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			if stderrors.Is(err, zip.ErrPassword) {
+				return errors.BadRequest("Wrong archive password", err)
+			}
+			return errors.InternalServerError("Failed to open ZIP entry", err)
+		}
+		err = writeExtractedFile(destPath, targetPath, rc, info.Mode().Perm())
+		rc.Close()
+		if err != nil {
+			if stderrors.Is(err, zip.ErrAuthentication) || stderrors.Is(err, zip.ErrChecksum) {
+				return errors.BadRequest("Archive is corrupt or the password is wrong", err)
+			}
+			return err
 		}
 	}
 
 	return nil
 }
 
-// Helper: extractTarArchive extracts a TAR archive (optionally gzipped)
-func (s *Service) extractTarArchive(archivePath, destPath string, gzipped bool) error {
+// extractTar extracts a TAR archive, decompressing it according to format
+func extractTar(archivePath, destPath, format string) error {
 	file, err := os.Open(archivePath)
 	if err != nil {
 		return errors.InternalServerError("Failed to open archive", err)
 	}
 	defer file.Close()
 
-	var tarReader *tar.Reader
-
-	if gzipped {
+	var r io.Reader = file
+	switch format {
+	case ArchiveFormatTarGz:
 		gzipReader, err := gzip.NewReader(file)
 		if err != nil {
-			return errors.InternalServerError("Failed to create gzip reader", err)
+			return errors.BadRequest("Failed to read gzip stream", err)
 		}
 		defer gzipReader.Close()
-		tarReader = tar.NewReader(gzipReader)
-	} else {
-		tarReader = tar.NewReader(file)
+		r = gzipReader
+	case ArchiveFormatTarXz:
+		xzReader, err := xz.NewReader(file)
+		if err != nil {
+			return errors.BadRequest("Failed to read xz stream", err)
+		}
+		r = xzReader
 	}
 
+	tarReader := tar.NewReader(r)
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return errors.InternalServerError("Failed to read tar header", err)
+			return errors.BadRequest("Failed to read tar header", err)
 		}
 
-		// Validate path to prevent tar slip
-		targetPath := filepath.Join(destPath, header.Name)
-		if !strings.HasPrefix(targetPath, filepath.Clean(destPath)+string(os.PathSeparator)) {
-			return errors.BadRequest("Archive contains invalid path (tar slip detected)", nil)
+		targetPath, err := safeExtractPath(destPath, header.Name)
+		if err != nil {
+			return err
+		}
+		if targetPath == "" {
+			continue
 		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			// Create directory
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
-				return errors.InternalServerError("Failed to create directory", err)
+			if err := mkdirWithin(destPath, targetPath); err != nil {
+				return err
 			}
 		case tar.TypeReg:
-			// Create file
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return errors.InternalServerError("Failed to create parent directory", err)
+			if err := writeExtractedFile(destPath, targetPath, tarReader, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
 			}
+		default:
+			// Links could point outside the destination and are not extracted
+			logger.Warn("Skipping unsupported tar entry", zap.String("name", header.Name), zap.Uint8("type", header.Typeflag))
+		}
+	}
 
-			outFile, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode))
-			if err != nil {
-				return errors.InternalServerError("Failed to create file", err)
-			}
+	return nil
+}
 
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return errors.InternalServerError("Failed to extract file", err)
-			}
-			outFile.Close()
-		default:
-			logger.Warn("Unsupported tar entry type", zap.String("name", header.Name), zap.Uint8("type", header.Typeflag))
+// safeExtractPath returns where an archive entry is extracted to, or "" for
+// entries naming the destination itself. Absolute names and names escaping
+// the destination are rejected.
+func safeExtractPath(destPath, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", errors.BadRequest(fmt.Sprintf("Archive contains invalid path %q (path traversal detected)", name), nil)
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+	return filepath.Join(destPath, cleaned), nil
+}
+
+// mkdirWithin creates dir and its parents, failing if a symlink already in
+// destPath leads the directory outside of it
+func mkdirWithin(destPath, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.InternalServerError("Failed to create directory", err)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return errors.InternalServerError("Failed to resolve directory", err)
+	}
+	if !isWithin(destPath, resolved) {
+		return errors.BadRequest(fmt.Sprintf("Archive entry %s leads outside the destination via a symlink", dir), nil)
+	}
+	return nil
+}
+
+// writeExtractedFile writes an extracted file below destPath. Existing
+// symlinks at the target are replaced rather than followed.
+func writeExtractedFile(destPath, targetPath string, r io.Reader, perm os.FileMode) error {
+	if err := mkdirWithin(destPath, filepath.Dir(targetPath)); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(targetPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(targetPath); err != nil {
+			return errors.InternalServerError("Failed to replace symlink", err)
 		}
 	}
 
+	outFile, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.InternalServerError("Failed to create file", err)
+	}
+	if _, err := io.Copy(outFile, r); err != nil {
+		outFile.Close()
+		return fmt.Errorf("failed to extract %s: %w", targetPath, err)
+	}
+	if err := outFile.Close(); err != nil {
+		return errors.InternalServerError("Failed to write file", err)
+	}
 	return nil
 }
+
+// isWithin reports whether path is dir or inside it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// newArchiveJobID returns a random hex job ID
+func newArchiveJobID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,151 @@
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// readTree returns the relative paths and contents of all files below root
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if info.IsDir() {
+			tree[rel+"/"] = ""
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		tree[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(t.TempDir(), "project")
+	contents := map[string]string{
+		"readme.txt":          "hello archive\n",
+		"docs/guide.md":       "# Guide\n",
+		"docs/empty.txt":      "",
+		"data/nested/big.bin": string(bytes.Repeat([]byte{0, 1, 2, 3, 255}, 50000)),
+	}
+	for name, content := range contents {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(src, "emptydir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	want := readTree(t, filepath.Dir(src))
+
+	tests := []struct {
+		name string
+		opts archiveOptions
+	}{
+		{"zip", archiveOptions{format: ArchiveFormatZip}},
+		{"encrypted zip", archiveOptions{format: ArchiveFormatZip, password: "s3cret"}},
+		{"tar", archiveOptions{format: ArchiveFormatTar}},
+		{"tar.gz", archiveOptions{format: ArchiveFormatTarGz, level: 9}},
+		{"tar.xz", archiveOptions{format: ArchiveFormatTarXz, level: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// The extension is deliberately meaningless: the format is detected from content
+			archivePath := filepath.Join(dir, "archive.bin")
+
+			var progressed int64
+			if err := writeArchive([]string{src}, archivePath, tt.opts, func(n int64) { progressed += n }); err != nil {
+				t.Fatalf("writeArchive() error = %v", err)
+			}
+			if size, _ := sourceSize(src); progressed != size {
+				t.Errorf("progress reported %d bytes, want %d", progressed, size)
+			}
+
+			header := make([]byte, 262)
+			f, _ := os.Open(archivePath)
+			n, _ := f.Read(header)
+			f.Close()
+			if got := DetectArchiveFormat(header[:n]); got != tt.opts.format {
+				t.Errorf("DetectArchiveFormat() = %q, want %q", got, tt.opts.format)
+			}
+
+			if tt.opts.password != "" {
+				if err := extractArchive(archivePath, t.TempDir(), "wrong"); err == nil {
+					t.Error("extractArchive() with wrong password succeeded")
+				}
+				if err := extractArchive(archivePath, t.TempDir(), ""); err == nil {
+					t.Error("extractArchive() without password succeeded")
+				}
+			}
+
+			dest := t.TempDir()
+			if err := extractArchive(archivePath, dest, tt.opts.password); err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			if got := readTree(t, dest); !reflect.DeepEqual(got, want) {
+				t.Errorf("extracted tree differs:\ngot  %v\nwant %v", keys(got), keys(want))
+			}
+		})
+	}
+}
+
+func TestExtractArchiveRejectsPathTraversal(t *testing.T) {
+	for _, name := range []string{"../evil.txt", "safe/../../evil.txt", "/etc/evil.txt"} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 4, Typeflag: tar.TypeReg})
+			tw.Write([]byte("evil"))
+			tw.Close()
+
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, "evil.tar")
+			if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			dest := filepath.Join(dir, "out")
+			if err := os.Mkdir(dest, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := extractArchive(archivePath, dest, ""); err == nil {
+				t.Fatal("extractArchive() succeeded, want path traversal error")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil.txt")); err == nil {
+				t.Error("file was written outside the destination")
+			}
+		})
+	}
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
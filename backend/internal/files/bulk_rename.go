@@ -0,0 +1,235 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// renameTokenPattern matches metadata tokens in bulk rename replacements,
+// e.g. {ext}, {date:20060102} or {counter:04d}
+var renameTokenPattern = regexp.MustCompile(`\{(name|ext|date|counter)(?::([^}]*))?\}`)
+
+// counterFormatPattern matches the allowed {counter:...} formats
+var counterFormatPattern = regexp.MustCompile(`^0?[0-9]{0,2}d$`)
+
+// defaultRenameDateLayout is used by {date} without a layout
+const defaultRenameDateLayout = "2006-01-02"
+
+// BulkRename renames the files in req.Dir whose names match req.Pattern.
+// Nothing is renamed if two files would end up with the same name or a new
+// name is already taken. onRename is called after each successful rename.
+func (s *Service) BulkRename(ctx *SecurityContext, req *BulkRenameRequest, onRename func(before, after string)) (*BulkRenameResult, error) {
+	if req.Pattern == "" {
+		return nil, errors.BadRequest("Pattern is required", nil)
+	}
+	re, err := regexp.Compile(req.Pattern)
+	if err != nil {
+		return nil, errors.BadRequest("Invalid pattern", err)
+	}
+	if err := validateRenameTokens(req.Replacement); err != nil {
+		return nil, err
+	}
+
+	dir, err := s.validator.ValidateAndSanitize(req.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.permissions.CanWrite(ctx, dir); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NotFound("Directory not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to access directory", err)
+	}
+	if !info.IsDir() {
+		return nil, errors.BadRequest("Path is not a directory", nil)
+	}
+
+	plan, result, err := planBulkRename(dir, re, req.Replacement)
+	if err != nil {
+		return nil, err
+	}
+	if req.Preview || req.DryRun {
+		result.Preview = plan
+	}
+	if req.DryRun || len(result.Conflicts) > 0 {
+		return result, nil
+	}
+
+	result.Renamed, result.Errors = applyBulkRename(dir, plan, result.Errors, onRename)
+
+	logger.Info("Bulk rename completed",
+		zap.String("dir", dir),
+		zap.String("pattern", req.Pattern),
+		zap.Int("renamed", result.Renamed),
+		zap.Int("errors", len(result.Errors)),
+		zap.String("user", ctx.User.Username))
+	return result, nil
+}
+
+// planBulkRename computes the new names of the files in dir matching re,
+// in name order, and reports duplicate or already taken names as conflicts
+func planBulkRename(dir string, re *regexp.Regexp, replacement string) ([]RenamePair, *BulkRenameResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, errors.InternalServerError("Failed to read directory", err)
+	}
+
+	result := &BulkRenameResult{Conflicts: []string{}, Errors: []string{}}
+	plan := []RenamePair{}
+	existing := make(map[string]bool, len(entries))
+	targets := make(map[string][]string)
+	counter := 0
+
+	for _, entry := range entries {
+		name := entry.Name()
+		existing[name] = true
+		if entry.IsDir() || !re.MatchString(name) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		counter++
+		after := re.ReplaceAllString(name, expandRenameTokens(replacement, info, counter))
+		if after == name {
+			result.Skipped++
+			continue
+		}
+		if err := ValidateFileName(after); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s -> %s: %v", name, after, err))
+			continue
+		}
+
+		plan = append(plan, RenamePair{Before: name, After: after})
+		targets[after] = append(targets[after], name)
+	}
+
+	renamedAway := make(map[string]bool, len(plan))
+	for _, pair := range plan {
+		renamedAway[pair.Before] = true
+	}
+
+	reported := make(map[string]bool)
+	for _, pair := range plan {
+		switch {
+		case reported[pair.After]:
+		case len(targets[pair.After]) > 1:
+			result.Conflicts = append(result.Conflicts,
+				fmt.Sprintf("%s -> %s: duplicate name", strings.Join(targets[pair.After], ", "), pair.After))
+			reported[pair.After] = true
+		case existing[pair.After] && !renamedAway[pair.After]:
+			result.Conflicts = append(result.Conflicts,
+				fmt.Sprintf("%s -> %s: file already exists", pair.Before, pair.After))
+			reported[pair.After] = true
+		}
+	}
+
+	return plan, result, nil
+}
+
+// applyBulkRename renames in two passes through temporary names, so renames
+// that swap or shift names (a -> b, b -> c) work
+func applyBulkRename(dir string, plan []RenamePair, errs []string, onRename func(before, after string)) (int, []string) {
+	prefix, err := generateSessionID()
+	if err != nil {
+		return 0, append(errs, fmt.Sprintf("failed to generate temporary names: %v", err))
+	}
+
+	type staged struct {
+		RenamePair
+		tmp string
+	}
+	var pending []staged
+	for i, pair := range plan {
+		tmp := filepath.Join(dir, fmt.Sprintf(".bulkrename-%s-%d", prefix, i))
+		if err := os.Rename(filepath.Join(dir, pair.Before), tmp); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", pair.Before, err))
+			continue
+		}
+		pending = append(pending, staged{RenamePair: pair, tmp: tmp})
+	}
+
+	renamed := 0
+	for _, p := range pending {
+		target := filepath.Join(dir, p.After)
+
+		// The name may still be held by a file whose own rename failed
+		_, err := os.Lstat(target)
+		if err == nil {
+			err = fmt.Errorf("%s already exists", p.After)
+		} else if os.IsNotExist(err) {
+			err = os.Rename(p.tmp, target)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s -> %s: %v", p.Before, p.After, err))
+			if restoreErr := os.Rename(p.tmp, filepath.Join(dir, p.Before)); restoreErr != nil {
+				logger.Error("Failed to restore file after bulk rename error",
+					zap.String("file", p.Before), zap.String("tmp", p.tmp), zap.Error(restoreErr))
+			}
+			continue
+		}
+
+		renamed++
+		if onRename != nil {
+			onRename(filepath.Join(dir, p.Before), target)
+		}
+	}
+	return renamed, errs
+}
+
+// validateRenameTokens checks the formats of the tokens in a replacement
+func validateRenameTokens(replacement string) error {
+	for _, m := range renameTokenPattern.FindAllStringSubmatch(replacement, -1) {
+		if m[1] == "counter" && m[2] != "" && !counterFormatPattern.MatchString(m[2]) {
+			return errors.BadRequest(fmt.Sprintf("Invalid counter format %q (use e.g. {counter:04d})", m[2]), nil)
+		}
+	}
+	return nil
+}
+
+// expandRenameTokens replaces the metadata tokens in a replacement with the
+// values for one file. The result is still expanded by the regexp, so $ in
+// values is escaped.
+func expandRenameTokens(replacement string, info os.FileInfo, counter int) string {
+	return renameTokenPattern.ReplaceAllStringFunc(replacement, func(token string) string {
+		m := renameTokenPattern.FindStringSubmatch(token)
+		ext := filepath.Ext(info.Name())
+
+		var value string
+		switch m[1] {
+		case "name":
+			value = strings.TrimSuffix(info.Name(), ext)
+		case "ext":
+			value = strings.TrimPrefix(ext, ".")
+		case "date":
+			layout := m[2]
+			if layout == "" {
+				layout = defaultRenameDateLayout
+			}
+			value = info.ModTime().Format(layout)
+		case "counter":
+			format := m[2]
+			if format == "" {
+				format = "d"
+			}
+			value = fmt.Sprintf("%"+format, counter)
+		}
+		return strings.ReplaceAll(value, "$", "$$")
+	})
+}
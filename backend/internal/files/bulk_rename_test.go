@@ -0,0 +1,179 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func TestBulkRename(t *testing.T) {
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 7, 14, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		files        []string
+		pattern      string
+		replacement  string
+		want         []string // Directory listing afterwards
+		wantSkipped  int
+		wantConflict bool
+	}{
+		{
+			name:        "capture groups",
+			files:       []string{"IMG_0001.JPG", "IMG_0002.JPG", "notes.txt"},
+			pattern:     `^IMG_(\d+)\.JPG$`,
+			replacement: "holiday-$1.jpg",
+			want:        []string{"holiday-0001.jpg", "holiday-0002.jpg", "notes.txt"},
+		},
+		{
+			name:        "swapped capture groups",
+			files:       []string{"2023-london.png"},
+			pattern:     `^(\d{4})-(\w+)\.png$`,
+			replacement: "${2}_${1}.png",
+			want:        []string{"london_2023.png"},
+		},
+		{
+			name:        "counter and metadata tokens",
+			files:       []string{"b.jpeg", "a.jpeg", "c.raw"},
+			pattern:     `^.*$`,
+			replacement: "photo_{date}_{counter:03d}.{ext}",
+			want:        []string{"photo_2024-07-14_001.jpeg", "photo_2024-07-14_002.jpeg", "photo_2024-07-14_003.raw"},
+		},
+		{
+			name:        "names shifted onto each other",
+			files:       []string{"2.txt", "3.txt"},
+			pattern:     `^\d\.txt$`,
+			replacement: "{counter}.txt",
+			want:        []string{"1.txt", "2.txt"},
+		},
+		{
+			name:        "unchanged names are skipped",
+			files:       []string{"keep.txt", "old.txt"},
+			pattern:     `^old\.txt$|^keep\.txt$`,
+			replacement: "{name}.txt",
+			want:        []string{"keep.txt", "old.txt"},
+			wantSkipped: 2,
+		},
+		{
+			name:         "duplicate names are refused",
+			files:        []string{"a1.txt", "a2.txt", "b.txt"},
+			pattern:      `^a\d\.txt$`,
+			replacement:  "a.txt",
+			want:         []string{"a1.txt", "a2.txt", "b.txt"},
+			wantConflict: true,
+		},
+		{
+			name:         "existing names are refused",
+			files:        []string{"draft.txt", "final.txt"},
+			pattern:      `^draft`,
+			replacement:  "final",
+			want:         []string{"draft.txt", "final.txt"},
+			wantConflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			svc := NewService([]string{dir}, NewPermissionChecker(nil))
+			ctx := &SecurityContext{User: &models.User{Username: "admin"}, IsAdmin: true, AllowedPaths: []string{dir}}
+
+			var audited int
+			result, err := svc.BulkRename(ctx, &BulkRenameRequest{
+				Dir:         dir,
+				Pattern:     tt.pattern,
+				Replacement: tt.replacement,
+			}, func(before, after string) { audited++ })
+			if err != nil {
+				t.Fatalf("BulkRename() error = %v", err)
+			}
+
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files after rename = %v, want %v", got, tt.want)
+			}
+			if result.Skipped != tt.wantSkipped {
+				t.Errorf("Skipped = %d, want %d", result.Skipped, tt.wantSkipped)
+			}
+			if (len(result.Conflicts) > 0) != tt.wantConflict {
+				t.Errorf("Conflicts = %v, want conflict: %v", result.Conflicts, tt.wantConflict)
+			}
+			if len(result.Errors) > 0 {
+				t.Errorf("Errors = %v", result.Errors)
+			}
+			if audited != result.Renamed {
+				t.Errorf("onRename called %d times for %d renames", audited, result.Renamed)
+			}
+		})
+	}
+}
+
+func TestBulkRenameDryRun(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"scan1.pdf", "scan2.pdf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := NewService([]string{dir}, NewPermissionChecker(nil))
+	ctx := &SecurityContext{User: &models.User{Username: "admin"}, IsAdmin: true, AllowedPaths: []string{dir}}
+
+	result, err := svc.BulkRename(ctx, &BulkRenameRequest{
+		Dir:         dir,
+		Pattern:     `^scan(\d)\.pdf$`,
+		Replacement: "invoice-{counter:02d}.pdf",
+		DryRun:      true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("BulkRename() error = %v", err)
+	}
+
+	want := []RenamePair{{"scan1.pdf", "invoice-01.pdf"}, {"scan2.pdf", "invoice-02.pdf"}}
+	if !reflect.DeepEqual(result.Preview, want) {
+		t.Errorf("Preview = %v, want %v", result.Preview, want)
+	}
+	if result.Renamed != 0 || !reflect.DeepEqual(listDir(t, dir), []string{"scan1.pdf", "scan2.pdf"}) {
+		t.Errorf("dry run renamed files: %+v", result)
+	}
+
+	if _, err := svc.BulkRename(ctx, &BulkRenameRequest{Dir: dir, Pattern: `(`}, nil); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+	if _, err := svc.BulkRename(ctx, &BulkRenameRequest{Dir: dir, Pattern: `.`, Replacement: "{counter:x}"}, nil); err == nil {
+		t.Error("invalid counter format accepted")
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
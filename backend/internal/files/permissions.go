@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"syscall"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"go.uber.org/zap"
@@ -167,10 +168,8 @@ func (s *Service) changeOwnership(path, owner, group string, recursive bool) err
 			uid = parsedUID
 		} else {
 			// Lookup username
-			if u, err := user.Lookup(owner); err == nil {
-				if parsedUID, err := strconv.Atoi(u.Uid); err == nil {
-					uid = parsedUID
-				}
+			if lookedUp, err := database.Cached().LookupUID(owner); err == nil {
+				uid = lookedUp
 			} else {
 				return errors.BadRequest(fmt.Sprintf("User '%s' not found", owner), err)
 			}
@@ -183,10 +182,8 @@ func (s *Service) changeOwnership(path, owner, group string, recursive bool) err
 			gid = parsedGID
 		} else {
 			// Lookup group name
-			if g, err := user.LookupGroup(group); err == nil {
-				if parsedGID, err := strconv.Atoi(g.Gid); err == nil {
-					gid = parsedGID
-				}
+			if lookedUp, err := database.Cached().LookupGID(group); err == nil {
+				gid = lookedUp
 			} else {
 				return errors.BadRequest(fmt.Sprintf("Group '%s' not found", group), err)
 			}
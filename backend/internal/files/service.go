@@ -2,8 +2,10 @@
 package files
 
 import (
+	stderrors "errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime"
 	"os"
 	"os/user"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"go.uber.org/zap"
 )
 
@@ -31,6 +34,44 @@ func NewService(allowedPaths []string, permChecker *PermissionChecker) *Service
 	}
 }
 
+// CheckReadPermission validates that a user has read access to a path and
+// returns the cleaned path
+func (s *Service) CheckReadPermission(ctx *SecurityContext, path string) (string, error) {
+	cleanPath, err := s.validator.ValidateAndSanitize(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.permissions.CanAccess(ctx, cleanPath); err != nil {
+		return "", err
+	}
+	return cleanPath, nil
+}
+
+// ResolvePath checks read access to path and resolves symlinks in it,
+// rejecting paths that resolve outside of the share they are in
+func (s *Service) ResolvePath(ctx *SecurityContext, path string) (string, error) {
+	cleanPath, err := s.CheckReadPermission(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, shareRoot := range ctx.AllowedPaths {
+		if !isWithin(shareRoot, cleanPath) {
+			continue
+		}
+		rel, _ := filepath.Rel(shareRoot, cleanPath)
+		resolved, err := sysutil.SafeJoinResolved(shareRoot, rel)
+		if err == nil {
+			return resolved, nil
+		}
+		if stderrors.Is(err, fs.ErrNotExist) {
+			return "", errors.NotFound("Path not found", err)
+		}
+	}
+	return "", errors.Forbidden("Path resolves outside of the allowed shares", nil)
+}
+
 // CheckWritePermission validates that a user has write access to a path
 // This is a helper method for operations that need to check permissions before acting
 func (s *Service) CheckWritePermission(ctx *SecurityContext, path string) error {
@@ -390,10 +431,7 @@ func (s *Service) getFileInfo(path string, entry os.DirEntry) (*FileInfo, error)
 	mimeType := mime.TypeByExtension(ext)
 
 	// Determine if file can have thumbnail
-	hasThumbnail := false
-	if strings.HasPrefix(mimeType, "image/") {
-		hasThumbnail = true
-	}
+	hasThumbnail := !info.IsDir() && CanThumbnail(path)
 
 	fileInfo := &FileInfo{
 		Name:         info.Name(),
@@ -446,22 +484,31 @@ func (s *Service) copyFile(src, dst string) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
+	// Copy into a temporary file and rename it into place, so an existing
+	// destination is replaced rather than truncated
+	dstFile, err := os.CreateTemp(filepath.Dir(dst), ".copy-*")
 	if err != nil {
 		return errors.InternalServerError("Failed to create destination file", err)
 	}
+	defer os.Remove(dstFile.Name())
 	defer dstFile.Close()
 
 	if _, err := io.Copy(dstFile, srcFile); err != nil {
 		return errors.InternalServerError("Failed to copy file data", err)
 	}
+	if err := dstFile.Close(); err != nil {
+		return errors.InternalServerError("Failed to copy file data", err)
+	}
 
 	// Copy permissions
 	srcInfo, err := os.Stat(src)
 	if err == nil {
-		os.Chmod(dst, srcInfo.Mode())
+		os.Chmod(dstFile.Name(), srcInfo.Mode())
 	}
 
+	if err := os.Rename(dstFile.Name(), dst); err != nil {
+		return errors.InternalServerError("Failed to create destination file", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,367 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"github.com/disintegration/imaging"
+	"go.uber.org/zap"
+)
+
+// Thumbnail sizes in pixels (longest edge)
+const (
+	DefaultThumbnailSize = 128
+	MinThumbnailSize     = 16
+	MaxThumbnailSize     = 1024
+)
+
+// Image types returned by DetectImageType
+const (
+	ImageTypeJPEG = "jpeg"
+	ImageTypePNG  = "png"
+	ImageTypeGIF  = "gif"
+	ImageTypeHEIC = "heic"
+)
+
+const (
+	thumbnailQuality = 85
+	videoFrameOffset = "5" // Seconds into a video to take the thumbnail frame from
+
+	// maxThumbnailPixels limits the images decoded for thumbnails, since a
+	// small compressed file can decode to gigabytes (decompression bomb)
+	maxThumbnailPixels = 50_000_000
+
+	// ffmpegRecheckInterval is how long the ffmpeg lookup is cached
+	ffmpegRecheckInterval = 5 * time.Minute
+)
+
+// Thumbnail cache limits enforced by StartCleanup
+const (
+	ThumbnailCacheMaxAge   = 30 * 24 * time.Hour // Unused thumbnails are removed after this
+	ThumbnailCacheMaxBytes = 1 << 30             // Least recently used thumbnails are removed above this
+)
+
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".heic": true, ".heif": true,
+}
+
+var videoExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mkv": true, ".mov": true, ".avi": true,
+	".webm": true, ".wmv": true, ".flv": true, ".mpg": true, ".mpeg": true,
+}
+
+// heicBrands are the ISO base media file brands used by HEIC/HEIF images
+var heicBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1"}
+
+// ThumbnailService generates image and video thumbnails and caches them on disk
+type ThumbnailService struct {
+	cacheDir string
+
+	mu     sync.Mutex
+	stopCh chan struct{}
+}
+
+// ffmpegCheck caches whether ffmpeg is installed, which CanThumbnail would
+// otherwise look up for every video of a directory listing
+var ffmpegCheck struct {
+	mu        sync.Mutex
+	available bool
+	checkedAt time.Time
+}
+
+// ffmpegAvailable reports whether ffmpeg is installed, looking it up again
+// after ffmpegRecheckInterval so a later install is picked up
+func ffmpegAvailable() bool {
+	ffmpegCheck.mu.Lock()
+	defer ffmpegCheck.mu.Unlock()
+
+	if time.Since(ffmpegCheck.checkedAt) > ffmpegRecheckInterval {
+		ffmpegCheck.available = sysutil.CommandExists("ffmpeg")
+		ffmpegCheck.checkedAt = time.Now()
+	}
+	return ffmpegCheck.available
+}
+
+// Thumbnail is a generated thumbnail in the cache
+type Thumbnail struct {
+	Path    string    // JPEG file in the cache directory
+	ETag    string    // Quoted entity tag, changes with the source file
+	ModTime time.Time // Modification time of the source file
+}
+
+// NewThumbnailService creates a thumbnail service that caches into cacheDir
+func NewThumbnailService(cacheDir string) (*ThumbnailService, error) {
+	if err := os.MkdirAll(cacheDir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create thumbnail cache directory: %w", err)
+	}
+	return &ThumbnailService{cacheDir: cacheDir}, nil
+}
+
+// CanThumbnail reports whether a thumbnail can be generated for path, based
+// on its extension. Videos require ffmpeg.
+func CanThumbnail(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if imageExtensions[ext] {
+		return true
+	}
+	return videoExtensions[ext] && ffmpegAvailable()
+}
+
+// DetectImageType detects the image type from the first bytes of a file.
+// Returns "" for unknown types.
+func DetectImageType(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return ImageTypeJPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return ImageTypePNG
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return ImageTypeGIF
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		brand := string(header[8:12])
+		for _, b := range heicBrands {
+			if brand == b {
+				return ImageTypeHEIC
+			}
+		}
+	}
+	return ""
+}
+
+// Get returns the thumbnail of path scaled to fit size x size pixels,
+// generating it if it is not cached or the source file has changed since
+func (s *ThumbnailService) Get(path string, size int) (*Thumbnail, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NotFound("File not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to access file", err)
+	}
+	if info.IsDir() {
+		return nil, errors.BadRequest("Path is a directory", nil)
+	}
+
+	// The key includes the source's mtime and size, so a changed source
+	// gets a new thumbnail and the old one is left to Prune
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%d", path, size, info.ModTime().UnixNano(), info.Size())))
+	name := hex.EncodeToString(key[:])
+
+	thumb := &Thumbnail{
+		Path:    filepath.Join(s.cacheDir, name+".jpg"),
+		ETag:    `"` + name[:16] + `"`,
+		ModTime: info.ModTime(),
+	}
+
+	// The mtime of a cached thumbnail is the time it was last used
+	if _, err := os.Stat(thumb.Path); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(thumb.Path, now, now)
+		return thumb, nil
+	}
+
+	img, err := s.decode(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.write(thumb, imaging.Fit(img, size, size, imaging.Lanczos)); err != nil {
+		return nil, errors.InternalServerError("Failed to write thumbnail", err)
+	}
+	return thumb, nil
+}
+
+// decode reads an image, or a frame of a video, from path
+func (s *ThumbnailService) decode(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to open file", err)
+	}
+	header := make([]byte, 16)
+	n, _ := io.ReadFull(f, header)
+	f.Close()
+
+	switch DetectImageType(header[:n]) {
+	case ImageTypeJPEG, ImageTypePNG, ImageTypeGIF:
+		img, err := openImage(path, imaging.AutoOrientation(true))
+		if err != nil {
+			return nil, errors.BadRequest("Failed to decode image", err)
+		}
+		return img, nil
+	case ImageTypeHEIC:
+		// The standard library has no HEIC decoder
+		return s.extractFrame(path, false)
+	}
+
+	if videoExtensions[strings.ToLower(filepath.Ext(path))] {
+		return s.extractFrame(path, true)
+	}
+	return nil, errors.BadRequest("Unsupported file type for thumbnails", nil)
+}
+
+// extractFrame uses ffmpeg to decode a single frame. For videos the frame is
+// taken a few seconds in to skip black intros, or from the start for short clips.
+func (s *ThumbnailService) extractFrame(path string, video bool) (image.Image, error) {
+	if !ffmpegAvailable() {
+		return nil, errors.BadRequest("ffmpeg is required for thumbnails of this file type", nil)
+	}
+
+	tmp, err := os.CreateTemp(s.cacheDir, "frame-*.jpg")
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to create temporary file", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	run := func(seek bool) error {
+		args := []string{"-y", "-loglevel", "error"}
+		if seek {
+			args = append(args, "-ss", videoFrameOffset)
+		}
+		args = append(args, "-i", path, "-vframes", "1", tmp.Name())
+		_, err := sysutil.RunCommand("ffmpeg", args...)
+		return err
+	}
+
+	err = run(video)
+	if info, statErr := os.Stat(tmp.Name()); video && (err != nil || statErr != nil || info.Size() == 0) {
+		err = run(false)
+	}
+	if err != nil {
+		return nil, errors.BadRequest("Failed to extract frame", err)
+	}
+
+	img, err := openImage(tmp.Name())
+	if err != nil {
+		return nil, errors.BadRequest("Failed to decode extracted frame", err)
+	}
+	return img, nil
+}
+
+// openImage decodes an image after checking from its header that it is at
+// most maxThumbnailPixels
+func openImage(path string, opts ...imaging.DecodeOption) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if pixels := int64(cfg.Width) * int64(cfg.Height); pixels > maxThumbnailPixels {
+		return nil, fmt.Errorf("image of %dx%d pixels exceeds the thumbnail limit of %d pixels", cfg.Width, cfg.Height, maxThumbnailPixels)
+	}
+	return imaging.Open(path, opts...)
+}
+
+// write stores a thumbnail atomically
+func (s *ThumbnailService) write(thumb *Thumbnail, img image.Image) error {
+	tmp, err := os.CreateTemp(s.cacheDir, "thumb-*.jpg")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := imaging.Encode(tmp, img, imaging.JPEG, imaging.JPEGQuality(thumbnailQuality)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), thumb.Path)
+}
+
+// Prune removes thumbnails not used within maxAge, then the least recently
+// used ones until the cache holds at most maxBytes (0 = no size limit).
+// Returns the number of files removed.
+func (s *ThumbnailService) Prune(maxAge time.Duration, maxBytes int64) (int, error) {
+	entries, err := os.ReadDir(s.cacheDir)
+	if err != nil {
+		return 0, err
+	}
+
+	type cached struct {
+		path    string
+		size    int64
+		lastUse time.Time
+	}
+	var files []cached
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, cached{filepath.Join(s.cacheDir, entry.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].lastUse.Before(files[j].lastUse) })
+
+	removed := 0
+	cutoff := time.Now().Add(-maxAge)
+	for _, f := range files {
+		if f.lastUse.After(cutoff) && (maxBytes <= 0 || total <= maxBytes) {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		total -= f.size
+		removed++
+	}
+	return removed, nil
+}
+
+// StartCleanup prunes the cache every interval to ThumbnailCacheMaxAge and
+// ThumbnailCacheMaxBytes
+func (s *ThumbnailService) StartCleanup(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh != nil {
+		return
+	}
+	s.stopCh = make(chan struct{})
+
+	go func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Prune(ThumbnailCacheMaxAge, ThumbnailCacheMaxBytes); err != nil {
+					logger.Warn("Failed to prune thumbnail cache", zap.Error(err))
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(s.stopCh)
+}
+
+// StopCleanup stops the background cleanup started by StartCleanup
+func (s *ThumbnailService) StopCleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.stopCh = nil
+	}
+}
@@ -0,0 +1,178 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestThumbnailFromPNG(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "photo.png")
+
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	f, err := os.Create(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	service, err := NewThumbnailService(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatalf("NewThumbnailService() error = %v", err)
+	}
+
+	thumb, err := service.Get(source, 128)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	header, err := os.ReadFile(thumb.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DetectImageType(header); got != ImageTypeJPEG {
+		t.Errorf("thumbnail type = %q, want %q", got, ImageTypeJPEG)
+	}
+
+	decoded, err := imaging.Open(thumb.Path)
+	if err != nil {
+		t.Fatalf("failed to decode thumbnail: %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+		t.Errorf("thumbnail is %dx%d, want 128x64", b.Dx(), b.Dy())
+	}
+
+	// Same source and size is served from the cache
+	again, err := service.Get(source, 128)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if again.ETag != thumb.ETag {
+		t.Errorf("ETag changed for an unchanged file: %s != %s", again.ETag, thumb.ETag)
+	}
+
+	// A new mtime invalidates the cached thumbnail
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(source, later, later); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := service.Get(source, 128)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if updated.ETag == thumb.ETag {
+		t.Error("ETag did not change after the source was modified")
+	}
+	if updated.Path == thumb.Path {
+		t.Error("modified source reused the cached thumbnail")
+	}
+}
+
+func TestThumbnailRejectsDecompressionBombs(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "bomb.png")
+
+	// A 1x1 PNG whose header claims 20000x20000 pixels
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:20], 20000)
+	binary.BigEndian.PutUint32(data[20:24], 20000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewThumbnailService(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatalf("NewThumbnailService() error = %v", err)
+	}
+	_, err = service.Get(source, 128)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the thumbnail limit") {
+		t.Errorf("Get() error = %v, want the pixel limit", err)
+	}
+}
+
+func TestThumbnailPrune(t *testing.T) {
+	dir := t.TempDir()
+	service, err := NewThumbnailService(dir)
+	if err != nil {
+		t.Fatalf("NewThumbnailService() error = %v", err)
+	}
+
+	now := time.Now()
+	lastUse := map[string]time.Time{
+		"stale.jpg":  now.Add(-48 * time.Hour),
+		"old.jpg":    now.Add(-3 * time.Hour),
+		"recent.jpg": now.Add(-2 * time.Hour),
+		"new.jpg":    now.Add(-time.Hour),
+	}
+	for name, at := range lastUse {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// stale.jpg is too old, old.jpg the least recently used over the size limit
+	removed, err := service.Prune(24*time.Hour, 200)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Prune() removed %d files, want 2", removed)
+	}
+	for name, want := range map[string]bool{"stale.jpg": false, "old.jpg": false, "recent.jpg": true, "new.jpg": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+func TestDetectImageType(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, ImageTypeJPEG},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00"), ImageTypePNG},
+		{"gif", []byte("GIF89a\x01\x00"), ImageTypeGIF},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00"), ImageTypeHEIC},
+		{"mp4 is not an image", []byte("\x00\x00\x00\x18ftypisom\x00\x00"), ""},
+		{"text", []byte("hello world"), ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectImageType(tt.header); got != tt.want {
+				t.Errorf("DetectImageType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
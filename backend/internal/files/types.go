@@ -49,6 +49,31 @@ type RenameRequest struct {
 	NewName string `json:"newName"`
 }
 
+// BulkRenameRequest renames the files in a directory whose names match a
+// regular expression
+type BulkRenameRequest struct {
+	Dir         string `json:"dir"`
+	Pattern     string `json:"pattern"`     // Go regular expression matched against file names
+	Replacement string `json:"replacement"` // Supports $1 capture groups and {name}, {ext}, {date}, {counter} tokens
+	DryRun      bool   `json:"dryRun"`      // Only compute the result, don't rename anything
+	Preview     bool   `json:"preview"`     // Include the before/after list in the result
+}
+
+// RenamePair is a planned rename within a directory
+type RenamePair struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// BulkRenameResult reports the outcome of a bulk rename
+type BulkRenameResult struct {
+	Renamed   int          `json:"renamed"`
+	Skipped   int          `json:"skipped"`
+	Conflicts []string     `json:"conflicts"`
+	Errors    []string     `json:"errors"`
+	Preview   []RenamePair `json:"preview,omitempty"`
+}
+
 // CopyMoveRequest represents a file/directory copy or move request
 type CopyMoveRequest struct {
 	Source      string `json:"source"`
@@ -71,17 +96,29 @@ type PermissionsRequest struct {
 	Recursive   bool   `json:"recursive"`
 }
 
-// ArchiveRequest represents an archive creation request
-type ArchiveRequest struct {
-	Paths      []string `json:"paths"`
-	OutputPath string   `json:"outputPath"`
-	Format     string   `json:"format"` // "zip", "tar", "tar.gz"
+// CreateArchiveRequest represents an archive creation request
+type CreateArchiveRequest struct {
+	Paths            []string `json:"paths"`
+	Destination      string   `json:"destination"`                // Path of the archive file to create
+	Format           string   `json:"format"`                     // "zip", "tar", "tar.gz", "tar.xz"
+	CompressionLevel int      `json:"compressionLevel,omitempty"` // 1 (fastest) to 9 (smallest), 0 = default; gzip and xz only
+	Password         string   `json:"password,omitempty"`         // Encrypts zip entries with AES-256
+}
+
+// ArchiveResult describes a created archive. Large archives are created in
+// the background; JobID is then set and progress is published as events.
+type ArchiveResult struct {
+	JobID       string `json:"jobId,omitempty"`
+	Destination string `json:"destination"`
+	Format      string `json:"format"`
+	TotalBytes  int64  `json:"totalBytes"`
 }
 
 // ExtractRequest represents an archive extraction request
 type ExtractRequest struct {
 	ArchivePath string `json:"archivePath"`
 	Destination string `json:"destination"`
+	Password    string `json:"password,omitempty"` // For encrypted zip archives
 }
 
 // SearchRequest represents a file search request
@@ -97,14 +134,34 @@ type SearchRequest struct {
 
 // UploadSession represents an active upload session
 type UploadSession struct {
-	ID          string    `json:"id"`
-	FileName    string    `json:"fileName"`
-	TotalSize   int64     `json:"totalSize"`
-	UploadedSize int64    `json:"uploadedSize"`
-	ChunkSize   int64     `json:"chunkSize"`
-	Chunks      []bool    `json:"chunks"`
-	StartTime   time.Time `json:"startTime"`
-	LastUpdate  time.Time `json:"lastUpdate"`
+	ID           string    `json:"id"`
+	UserID       uint      `json:"userId"`
+	FileName     string    `json:"fileName"`
+	TotalSize    int64     `json:"totalSize"`
+	UploadedSize int64     `json:"uploadedSize"`
+	ChunkSize    int64     `json:"chunkSize"`
+	Chunks       []bool    `json:"chunks"`
+	StartTime    time.Time `json:"startTime"`
+	LastUpdate   time.Time `json:"lastUpdate"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+
+	finished bool // Removed from memory; later saves of the active state are dropped
+}
+
+// UploadProgress reports the state of a chunked upload session
+type UploadProgress struct {
+	SessionID      string     `json:"sessionId"`
+	UserID         uint       `json:"userId"`
+	FileName       string     `json:"fileName"`
+	Status         string     `json:"status"` // active, completed, cancelled
+	TotalChunks    int        `json:"totalChunks"`
+	ReceivedChunks int        `json:"receivedChunks"`
+	TotalSize      int64      `json:"totalSize"`
+	BytesReceived  int64      `json:"bytesReceived"`
+	Percent        float64    `json:"percent"`
+	StartedAt      time.Time  `json:"startedAt"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
 }
 
 // DiskUsageInfo represents disk usage information for a path
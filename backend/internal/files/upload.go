@@ -4,17 +4,23 @@ package files
 import (
 	"crypto/rand"
 	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"math"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 const (
@@ -28,23 +34,29 @@ const (
 	MinimumFreeSpace int64 = 1 * 1024 * 1024 * 1024
 )
 
-// UploadManager manages file uploads
+// UploadManager manages file uploads. Chunked upload sessions are persisted
+// to the database so they can be resumed after a restart.
 type UploadManager struct {
 	sessions map[string]*UploadSession
 	mu       sync.RWMutex
 	tempDir  string
+	db       *gorm.DB   // nil keeps sessions in memory only
+	dbMu     sync.Mutex // Orders database writes without holding mu
+	stopCh   chan struct{}
 }
 
-// NewUploadManager creates a new upload manager
-func NewUploadManager(tempDir string) *UploadManager {
+// NewUploadManager creates a new upload manager. Chunks are stored below
+// tempDir; db may be nil, in which case sessions do not survive a restart.
+func NewUploadManager(tempDir string, db *gorm.DB) *UploadManager {
 	return &UploadManager{
 		sessions: make(map[string]*UploadSession),
 		tempDir:  tempDir,
+		db:       db,
 	}
 }
 
 // StartUploadSession starts a new chunked upload session
-func (um *UploadManager) StartUploadSession(fileName string, totalSize int64) (*UploadSession, error) {
+func (um *UploadManager) StartUploadSession(fileName string, totalSize int64, userID uint) (*UploadSession, error) {
 	// Generate session ID
 	sessionID, err := generateSessionID()
 	if err != nil {
@@ -57,15 +69,22 @@ func (um *UploadManager) StartUploadSession(fileName string, totalSize int64) (*
 		numChunks++
 	}
 
+	now := time.Now()
 	session := &UploadSession{
 		ID:           sessionID,
+		UserID:       userID,
 		FileName:     fileName,
 		TotalSize:    totalSize,
 		UploadedSize: 0,
 		ChunkSize:    ChunkSize,
 		Chunks:       make([]bool, numChunks),
-		StartTime:    time.Now(),
-		LastUpdate:   time.Now(),
+		StartTime:    now,
+		LastUpdate:   now,
+		ExpiresAt:    now.Add(UploadSessionTimeout),
+	}
+
+	if err := um.persist(session, models.UploadStatusActive, nil); err != nil {
+		return nil, errors.InternalServerError("Failed to save upload session", err)
 	}
 
 	um.mu.Lock()
@@ -78,9 +97,13 @@ func (um *UploadManager) StartUploadSession(fileName string, totalSize int64) (*
 
 // UploadChunk uploads a chunk of a file
 func (um *UploadManager) UploadChunk(sessionID string, chunkIndex int, reader io.Reader) error {
-	um.mu.Lock()
+	um.mu.RLock()
 	session, exists := um.sessions[sessionID]
-	um.mu.Unlock()
+	var uploaded bool
+	if exists && chunkIndex >= 0 && chunkIndex < len(session.Chunks) {
+		uploaded = session.Chunks[chunkIndex]
+	}
+	um.mu.RUnlock()
 
 	if !exists {
 		return errors.NotFound("Upload session not found", nil)
@@ -92,70 +115,114 @@ func (um *UploadManager) UploadChunk(sessionID string, chunkIndex int, reader io
 	}
 
 	// Check if chunk already uploaded
-	if session.Chunks[chunkIndex] {
+	if uploaded {
 		return errors.Conflict("Chunk already uploaded", nil)
 	}
 
 	// Create temp directory for this session
-	sessionDir := filepath.Join(um.tempDir, sessionID)
+	sessionDir := um.sessionDir(sessionID)
 	if err := os.MkdirAll(sessionDir, 0755); err != nil {
 		return errors.InternalServerError("Failed to create temp directory", err)
 	}
 
-	// Write chunk to temp file
-	chunkPath := filepath.Join(sessionDir, fmt.Sprintf("chunk_%d", chunkIndex))
-	chunkFile, err := os.Create(chunkPath)
+	// Write to a temporary name first so a crash mid-chunk never leaves a
+	// truncated chunk file behind that looks complete
+	tmp, err := os.CreateTemp(sessionDir, fmt.Sprintf("chunk_%d-*.part", chunkIndex))
 	if err != nil {
 		return errors.InternalServerError("Failed to create chunk file", err)
 	}
-	defer chunkFile.Close()
-
-	written, err := io.Copy(chunkFile, reader)
+	written, err := io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		os.Remove(tmp.Name())
 		return errors.InternalServerError("Failed to write chunk", err)
 	}
 
-	// Update session
 	um.mu.Lock()
+
+	// The session may have been cancelled or the chunk received by a
+	// concurrent request while this one was writing
+	if um.sessions[sessionID] != session {
+		um.mu.Unlock()
+		os.Remove(tmp.Name())
+		return errors.NotFound("Upload session not found", nil)
+	}
+	if session.Chunks[chunkIndex] {
+		um.mu.Unlock()
+		os.Remove(tmp.Name())
+		return errors.Conflict("Chunk already uploaded", nil)
+	}
+
+	chunkPath := um.chunkPath(sessionID, chunkIndex)
+	if err := os.Rename(tmp.Name(), chunkPath); err != nil {
+		um.mu.Unlock()
+		os.Remove(tmp.Name())
+		return errors.InternalServerError("Failed to write chunk", err)
+	}
+
+	// Update session
+	prevUpdate, prevExpiry := session.LastUpdate, session.ExpiresAt
 	session.Chunks[chunkIndex] = true
 	session.UploadedSize += written
 	session.LastUpdate = time.Now()
+	session.ExpiresAt = session.LastUpdate.Add(UploadSessionTimeout)
 	um.mu.Unlock()
 
+	if err := um.persist(session, models.UploadStatusActive, nil); err != nil {
+		// Roll back so the client can retry the chunk
+		um.mu.Lock()
+		session.Chunks[chunkIndex] = false
+		session.UploadedSize -= written
+		session.LastUpdate, session.ExpiresAt = prevUpdate, prevExpiry
+		os.Remove(chunkPath)
+		um.mu.Unlock()
+		return errors.InternalServerError("Failed to record chunk", err)
+	}
+
 	logger.Debug("Chunk uploaded", zap.String("sessionID", sessionID), zap.Int("chunkIndex", chunkIndex))
 	return nil
 }
 
 // FinalizeUpload combines all chunks into the final file
 func (um *UploadManager) FinalizeUpload(sessionID, destinationPath string) error {
-	um.mu.Lock()
+	um.mu.RLock()
 	session, exists := um.sessions[sessionID]
-	um.mu.Unlock()
+	missing := -1
+	if exists {
+		for i, uploaded := range session.Chunks {
+			if !uploaded {
+				missing = i
+				break
+			}
+		}
+	}
+	um.mu.RUnlock()
 
 	if !exists {
 		return errors.NotFound("Upload session not found", nil)
 	}
 
 	// Check if all chunks are uploaded
-	for i, uploaded := range session.Chunks {
-		if !uploaded {
-			return errors.BadRequest(fmt.Sprintf("Missing chunk: %d", i), nil)
-		}
+	if missing >= 0 {
+		return errors.BadRequest(fmt.Sprintf("Missing chunk: %d", missing), nil)
 	}
 
-	sessionDir := filepath.Join(um.tempDir, sessionID)
+	sessionDir := um.sessionDir(sessionID)
 
-	// Create final file
-	finalFile, err := os.Create(destinationPath)
+	// Assemble into a temporary file and rename it into place, so an
+	// existing file is replaced rather than truncated
+	finalFile, err := os.CreateTemp(filepath.Dir(destinationPath), ".upload-*")
 	if err != nil {
 		return errors.InternalServerError("Failed to create final file", err)
 	}
+	defer os.Remove(finalFile.Name())
 	defer finalFile.Close()
 
 	// Combine all chunks
 	for i := 0; i < len(session.Chunks); i++ {
-		chunkPath := filepath.Join(sessionDir, fmt.Sprintf("chunk_%d", i))
-		chunkFile, err := os.Open(chunkPath)
+		chunkFile, err := os.Open(um.chunkPath(sessionID, i))
 		if err != nil {
 			return errors.InternalServerError(fmt.Sprintf("Failed to open chunk %d", i), err)
 		}
@@ -167,13 +234,25 @@ func (um *UploadManager) FinalizeUpload(sessionID, destinationPath string) error
 		chunkFile.Close()
 	}
 
+	if err := finalFile.Close(); err != nil {
+		return errors.InternalServerError("Failed to write final file", err)
+	}
+	if err := os.Chmod(finalFile.Name(), 0644); err != nil {
+		return errors.InternalServerError("Failed to write final file", err)
+	}
+	if err := os.Rename(finalFile.Name(), destinationPath); err != nil {
+		return errors.InternalServerError("Failed to create final file", err)
+	}
+
 	// Cleanup temp files
 	os.RemoveAll(sessionDir)
 
-	// Remove session
+	// Remove session; the record is kept until it expires so progress can
+	// still be queried
 	um.mu.Lock()
 	delete(um.sessions, sessionID)
 	um.mu.Unlock()
+	um.finish(session, models.UploadStatusCompleted)
 
 	logger.Info("Upload finalized", zap.String("sessionID", sessionID), zap.String("destination", destinationPath))
 	return nil
@@ -182,19 +261,17 @@ func (um *UploadManager) FinalizeUpload(sessionID, destinationPath string) error
 // CancelUpload cancels an upload session
 func (um *UploadManager) CancelUpload(sessionID string) error {
 	um.mu.Lock()
-	_, exists := um.sessions[sessionID]
-	if exists {
-		delete(um.sessions, sessionID)
-	}
+	session, exists := um.sessions[sessionID]
+	delete(um.sessions, sessionID)
 	um.mu.Unlock()
 
 	if !exists {
 		return errors.NotFound("Upload session not found", nil)
 	}
+	um.finish(session, models.UploadStatusCancelled)
 
 	// Cleanup temp files
-	sessionDir := filepath.Join(um.tempDir, sessionID)
-	os.RemoveAll(sessionDir)
+	os.RemoveAll(um.sessionDir(sessionID))
 
 	logger.Info("Upload cancelled", zap.String("sessionID", sessionID))
 	return nil
@@ -213,22 +290,294 @@ func (um *UploadManager) GetUploadSession(sessionID string) (*UploadSession, err
 	return session, nil
 }
 
-// CleanupExpiredSessions removes expired upload sessions
-func (um *UploadManager) CleanupExpiredSessions() {
-	um.mu.Lock()
-	defer um.mu.Unlock()
+// GetUploadProgress returns the progress of an upload session. Finished and
+// cancelled sessions are reported until they expire.
+func (um *UploadManager) GetUploadProgress(sessionID string) (*UploadProgress, error) {
+	um.mu.RLock()
+	session, exists := um.sessions[sessionID]
+	var record *models.UploadSession
+	if exists {
+		record = um.toRecord(session, models.UploadStatusActive, nil)
+	}
+	um.mu.RUnlock()
 
+	if !exists {
+		if um.db == nil {
+			return nil, errors.NotFound("Upload session not found", nil)
+		}
+		record = &models.UploadSession{}
+		if err := um.db.First(record, "session_id = ?", sessionID).Error; err != nil {
+			if stderrors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.NotFound("Upload session not found", nil)
+			}
+			return nil, errors.InternalServerError("Failed to load upload session", err)
+		}
+	}
+
+	progress := &UploadProgress{
+		SessionID:      record.SessionID,
+		UserID:         record.UserID,
+		FileName:       record.Filename,
+		Status:         record.Status,
+		TotalChunks:    record.TotalChunks,
+		ReceivedChunks: record.ReceivedChunks,
+		TotalSize:      record.TotalSize,
+		BytesReceived:  record.BytesReceived,
+		StartedAt:      record.StartedAt,
+		ExpiresAt:      record.ExpiresAt,
+		FinishedAt:     record.FinishedAt,
+	}
+	switch {
+	case record.TotalSize > 0:
+		progress.Percent = math.Min(100, float64(record.BytesReceived)*100/float64(record.TotalSize))
+	case record.Status == models.UploadStatusCompleted:
+		progress.Percent = 100
+	}
+	return progress, nil
+}
+
+// RestoreSessions reloads unfinished sessions from the database after a
+// restart. Chunks recorded as received but missing on disk are marked as
+// outstanding again, and stray chunk files that were never recorded are
+// removed, so clients re-send exactly what is missing. Returns the number
+// of restored sessions.
+func (um *UploadManager) RestoreSessions() (int, error) {
+	if um.db == nil {
+		return 0, nil
+	}
+
+	var records []models.UploadSession
+	if err := um.db.Where("status = ? AND expires_at > ?", models.UploadStatusActive, time.Now()).
+		Find(&records).Error; err != nil {
+		return 0, fmt.Errorf("failed to load upload sessions: %w", err)
+	}
+
+	for i := range records {
+		session := um.verifySession(&records[i])
+		if err := um.persist(session, models.UploadStatusActive, nil); err != nil {
+			logger.Warn("Failed to update restored upload session", zap.String("sessionID", session.ID), zap.Error(err))
+		}
+		um.mu.Lock()
+		um.sessions[session.ID] = session
+		um.mu.Unlock()
+	}
+
+	if len(records) > 0 {
+		logger.Info("Upload sessions restored", zap.Int("count", len(records)))
+	}
+	return len(records), nil
+}
+
+// verifySession rebuilds a session from its record and reconciles it with
+// the chunk files on disk
+func (um *UploadManager) verifySession(record *models.UploadSession) *UploadSession {
+	session := &UploadSession{
+		ID:         record.SessionID,
+		UserID:     record.UserID,
+		FileName:   record.Filename,
+		TotalSize:  record.TotalSize,
+		ChunkSize:  ChunkSize,
+		Chunks:     make([]bool, record.TotalChunks),
+		StartTime:  record.StartedAt,
+		LastUpdate: record.UpdatedAt,
+		ExpiresAt:  record.ExpiresAt,
+	}
+	if len(record.ChunkMap) == record.TotalChunks {
+		for i := range session.Chunks {
+			session.Chunks[i] = record.ChunkMap[i] == '1'
+		}
+	}
+
+	missing := 0
+	for i, received := range session.Chunks {
+		if !received {
+			continue
+		}
+		info, err := os.Stat(um.chunkPath(session.ID, i))
+		if err != nil || !info.Mode().IsRegular() {
+			session.Chunks[i] = false
+			missing++
+			continue
+		}
+		session.UploadedSize += info.Size()
+	}
+
+	// Anything else in the session directory is an unrecorded or partial chunk
+	entries, _ := os.ReadDir(um.sessionDir(session.ID))
+	for _, entry := range entries {
+		if suffix, ok := strings.CutPrefix(entry.Name(), "chunk_"); ok {
+			if index, err := strconv.Atoi(suffix); err == nil && suffix == strconv.Itoa(index) && index >= 0 && index < len(session.Chunks) && session.Chunks[index] {
+				continue
+			}
+		}
+		os.RemoveAll(filepath.Join(um.sessionDir(session.ID), entry.Name()))
+	}
+
+	if missing > 0 || session.UploadedSize != record.BytesReceived {
+		logger.Warn("Upload session does not match files on disk",
+			zap.String("sessionID", session.ID),
+			zap.Int("missingChunks", missing),
+			zap.Int64("recordedBytes", record.BytesReceived),
+			zap.Int64("bytesOnDisk", session.UploadedSize))
+	}
+	return session
+}
+
+// CleanupExpiredSessions removes expired upload sessions, their records and
+// temp files. Returns the number of sessions removed.
+func (um *UploadManager) CleanupExpiredSessions() int {
 	now := time.Now()
+	removed := make(map[string]bool)
+
+	um.mu.Lock()
 	for sessionID, session := range um.sessions {
-		if now.Sub(session.LastUpdate) > UploadSessionTimeout {
+		if now.After(session.ExpiresAt) {
 			// Cleanup temp files
-			sessionDir := filepath.Join(um.tempDir, sessionID)
-			os.RemoveAll(sessionDir)
+			os.RemoveAll(um.sessionDir(sessionID))
 
 			delete(um.sessions, sessionID)
+			session.finished = true
+			removed[sessionID] = true
 			logger.Info("Expired upload session cleaned up", zap.String("sessionID", sessionID))
 		}
 	}
+	um.mu.Unlock()
+
+	if um.db == nil {
+		return len(removed)
+	}
+
+	um.dbMu.Lock()
+	defer um.dbMu.Unlock()
+
+	var records []models.UploadSession
+	if err := um.db.Where("expires_at <= ?", now).Find(&records).Error; err != nil {
+		logger.Error("Failed to load expired upload sessions", zap.Error(err))
+		return len(removed)
+	}
+	for _, record := range records {
+		if record.PartialPath != "" {
+			os.RemoveAll(record.PartialPath)
+		}
+		if err := um.db.Delete(&models.UploadSession{}, "session_id = ?", record.SessionID).Error; err != nil {
+			logger.Error("Failed to delete upload session", zap.String("sessionID", record.SessionID), zap.Error(err))
+			continue
+		}
+		if record.Status == models.UploadStatusActive && !removed[record.SessionID] {
+			removed[record.SessionID] = true
+			logger.Info("Expired upload session cleaned up", zap.String("sessionID", record.SessionID))
+		}
+	}
+	return len(removed)
+}
+
+// StartCleanup runs CleanupExpiredSessions every interval until StopCleanup is called
+func (um *UploadManager) StartCleanup(interval time.Duration) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.stopCh != nil {
+		return
+	}
+	um.stopCh = make(chan struct{})
+
+	go func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				um.CleanupExpiredSessions()
+			case <-stop:
+				return
+			}
+		}
+	}(um.stopCh)
+}
+
+// StopCleanup stops the background cleanup started by StartCleanup
+func (um *UploadManager) StopCleanup() {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.stopCh != nil {
+		close(um.stopCh)
+		um.stopCh = nil
+	}
+}
+
+// finish records the final status of a session that was removed from memory
+func (um *UploadManager) finish(session *UploadSession, status string) {
+	now := time.Now()
+	um.mu.Lock()
+	session.ExpiresAt = now.Add(UploadSessionTimeout)
+	session.finished = true
+	um.mu.Unlock()
+	if err := um.persist(session, status, &now); err != nil {
+		logger.Warn("Failed to update upload session", zap.String("sessionID", session.ID), zap.Error(err))
+	}
+}
+
+// persist saves a session to the database. Must be called without um.mu
+// held: the record is taken under um.mu, but written under dbMu only, so
+// database I/O does not block other uploads. Taking the record under dbMu
+// keeps concurrent saves of a session from storing an older state last.
+func (um *UploadManager) persist(session *UploadSession, status string, finishedAt *time.Time) error {
+	if um.db == nil {
+		return nil
+	}
+
+	um.dbMu.Lock()
+	defer um.dbMu.Unlock()
+
+	um.mu.RLock()
+	if session.finished && finishedAt == nil {
+		// A chunk save that lost the race against finalizing or cancelling
+		um.mu.RUnlock()
+		return nil
+	}
+	record := um.toRecord(session, status, finishedAt)
+	um.mu.RUnlock()
+	return um.db.Save(record).Error
+}
+
+// toRecord converts a session into its database record
+func (um *UploadManager) toRecord(session *UploadSession, status string, finishedAt *time.Time) *models.UploadSession {
+	chunkMap := make([]byte, len(session.Chunks))
+	received := 0
+	for i, ok := range session.Chunks {
+		chunkMap[i] = '0'
+		if ok {
+			chunkMap[i] = '1'
+			received++
+		}
+	}
+
+	return &models.UploadSession{
+		SessionID:      session.ID,
+		UserID:         session.UserID,
+		Filename:       session.FileName,
+		TotalChunks:    len(session.Chunks),
+		ReceivedChunks: received,
+		ChunkMap:       string(chunkMap),
+		TotalSize:      session.TotalSize,
+		BytesReceived:  session.UploadedSize,
+		Status:         status,
+		PartialPath:    um.sessionDir(session.ID),
+		StartedAt:      session.StartTime,
+		ExpiresAt:      session.ExpiresAt,
+		FinishedAt:     finishedAt,
+	}
+}
+
+// sessionDir returns the directory holding a session's chunks
+func (um *UploadManager) sessionDir(sessionID string) string {
+	return filepath.Join(um.tempDir, sessionID)
+}
+
+// chunkPath returns the path of a received chunk
+func (um *UploadManager) chunkPath(sessionID string, chunkIndex int) string {
+	return filepath.Join(um.sessionDir(sessionID), fmt.Sprintf("chunk_%d", chunkIndex))
 }
 
 // UploadSingleFile handles a simple single-file upload
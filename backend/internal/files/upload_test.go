@@ -0,0 +1,189 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func openUploadDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "uploads.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.UploadSession{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	return db
+}
+
+func TestUploadResumesAfterRestart(t *testing.T) {
+	db := openUploadDB(t)
+	tempDir := t.TempDir()
+
+	before := NewUploadManager(tempDir, db)
+	session, err := before.StartUploadSession("video.mkv", 2*ChunkSize+1, 7)
+	if err != nil {
+		t.Fatalf("StartUploadSession() error = %v", err)
+	}
+	if err := before.UploadChunk(session.ID, 0, strings.NewReader("first-")); err != nil {
+		t.Fatalf("UploadChunk(0) error = %v", err)
+	}
+
+	// Simulate a restart: a fresh manager on the same database and temp dir
+	after := NewUploadManager(tempDir, db)
+	restored, err := after.RestoreSessions()
+	if err != nil {
+		t.Fatalf("RestoreSessions() error = %v", err)
+	}
+	if restored != 1 {
+		t.Fatalf("RestoreSessions() = %d, want 1", restored)
+	}
+
+	progress, err := after.GetUploadProgress(session.ID)
+	if err != nil {
+		t.Fatalf("GetUploadProgress() error = %v", err)
+	}
+	if progress.Status != models.UploadStatusActive || progress.ReceivedChunks != 1 ||
+		progress.TotalChunks != 3 || progress.BytesReceived != 6 || progress.UserID != 7 {
+		t.Fatalf("progress after restart = %+v", progress)
+	}
+
+	if err := after.UploadChunk(session.ID, 0, strings.NewReader("again")); err == nil {
+		t.Fatal("re-uploading a restored chunk should conflict")
+	}
+	for i, data := range []string{"second-", "third"} {
+		if err := after.UploadChunk(session.ID, i+1, strings.NewReader(data)); err != nil {
+			t.Fatalf("UploadChunk(%d) error = %v", i+1, err)
+		}
+	}
+
+	dest := filepath.Join(t.TempDir(), "video.mkv")
+	if err := after.FinalizeUpload(session.ID, dest); err != nil {
+		t.Fatalf("FinalizeUpload() error = %v", err)
+	}
+	content, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first-second-third" {
+		t.Errorf("final file = %q", content)
+	}
+
+	progress, err = after.GetUploadProgress(session.ID)
+	if err != nil {
+		t.Fatalf("GetUploadProgress() after finalize error = %v", err)
+	}
+	if progress.Status != models.UploadStatusCompleted || progress.FinishedAt == nil {
+		t.Errorf("progress after finalize = %+v", progress)
+	}
+}
+
+func TestRestoreReconcilesChunksOnDisk(t *testing.T) {
+	db := openUploadDB(t)
+	tempDir := t.TempDir()
+
+	before := NewUploadManager(tempDir, db)
+	session, err := before.StartUploadSession("disk.img", 3*ChunkSize, 1)
+	if err != nil {
+		t.Fatalf("StartUploadSession() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := before.UploadChunk(session.ID, i, strings.NewReader("data")); err != nil {
+			t.Fatalf("UploadChunk(%d) error = %v", i, err)
+		}
+	}
+
+	// Chunk 1 was lost, and chunk 2 was written but never recorded
+	sessionDir := filepath.Join(tempDir, session.ID)
+	if err := os.Remove(filepath.Join(sessionDir, "chunk_1")); err != nil {
+		t.Fatal(err)
+	}
+	stray := filepath.Join(sessionDir, "chunk_2")
+	if err := os.WriteFile(stray, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	after := NewUploadManager(tempDir, db)
+	if _, err := after.RestoreSessions(); err != nil {
+		t.Fatalf("RestoreSessions() error = %v", err)
+	}
+
+	restored, err := after.GetUploadSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetUploadSession() error = %v", err)
+	}
+	if want := []bool{true, false, false}; !equalChunks(restored.Chunks, want) {
+		t.Errorf("Chunks = %v, want %v", restored.Chunks, want)
+	}
+	if restored.UploadedSize != 4 {
+		t.Errorf("UploadedSize = %d, want 4", restored.UploadedSize)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Errorf("unrecorded chunk was not removed")
+	}
+
+	var record models.UploadSession
+	if err := db.First(&record, "session_id = ?", session.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if record.ReceivedChunks != 1 || record.BytesReceived != 4 {
+		t.Errorf("record not corrected: %+v", record)
+	}
+}
+
+func TestCleanupExpiredSessions(t *testing.T) {
+	db := openUploadDB(t)
+	tempDir := t.TempDir()
+
+	um := NewUploadManager(tempDir, db)
+	session, err := um.StartUploadSession("old.bin", 10, 1)
+	if err != nil {
+		t.Fatalf("StartUploadSession() error = %v", err)
+	}
+	if err := um.UploadChunk(session.ID, 0, strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("UploadChunk() error = %v", err)
+	}
+
+	um.mu.Lock()
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	um.mu.Unlock()
+	if err := um.persist(session, models.UploadStatusActive, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed := um.CleanupExpiredSessions(); removed != 1 {
+		t.Errorf("CleanupExpiredSessions() = %d, want 1", removed)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, session.ID)); !os.IsNotExist(err) {
+		t.Errorf("temp files of expired session were not removed")
+	}
+	if _, err := um.GetUploadProgress(session.ID); err == nil {
+		t.Errorf("expired session is still reported")
+	}
+}
+
+func equalChunks(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
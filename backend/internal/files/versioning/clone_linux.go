@@ -0,0 +1,15 @@
+//go:build linux
+
+package versioning
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile makes dst a reflink of src, sharing its data blocks until
+// either file is written
+func cloneFile(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
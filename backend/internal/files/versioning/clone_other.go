@@ -0,0 +1,12 @@
+//go:build !linux
+
+package versioning
+
+import (
+	"errors"
+	"os"
+)
+
+func cloneFile(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
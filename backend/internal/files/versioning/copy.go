@@ -0,0 +1,106 @@
+package versioning
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+)
+
+// checksumSuffix names the sidecar file holding a version's checksum
+const checksumSuffix = ".sha256"
+
+// copyVersionDir returns the directory holding the versions of path
+func copyVersionDir(path string) string {
+	return filepath.Join(filepath.Dir(path), VersionsDir, filepath.Base(path))
+}
+
+// copyVersionPath returns the file holding a version of path
+func copyVersionPath(path, versionID string) string {
+	return filepath.Join(copyVersionDir(path), versionID)
+}
+
+// createCopyVersion copies path into its versions directory. The copy is
+// written to a temporary file first, so a failed copy never shows up as a
+// version.
+func (s *VersionStore) createCopyVersion(path, versionID, checksum string, mode os.FileMode) error {
+	dir := copyVersionDir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.InternalServerError("Failed to create versions directory", err)
+	}
+
+	tmp, err := copyToTemp(path, dir, ".version-*")
+	if err != nil {
+		return errors.InternalServerError("Failed to create version", err)
+	}
+	defer os.Remove(tmp)
+
+	target := copyVersionPath(path, versionID)
+	if err := os.Chmod(tmp, mode); err != nil {
+		return errors.InternalServerError("Failed to create version", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return errors.InternalServerError("Failed to create version", err)
+	}
+	if err := os.WriteFile(target+checksumSuffix, []byte(checksum+"\n"), 0644); err != nil {
+		os.Remove(target)
+		return errors.InternalServerError("Failed to create version", err)
+	}
+	return nil
+}
+
+// listCopyVersions lists the versions in path's versions directory
+func listCopyVersions(path string) ([]FileVersion, error) {
+	entries, err := os.ReadDir(copyVersionDir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []FileVersion{}, nil
+		}
+		return nil, errors.InternalServerError("Failed to read versions directory", err)
+	}
+
+	versions := []FileVersion{}
+	for _, entry := range entries {
+		id := entry.Name()
+		if !versionIDPattern.MatchString(id) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		version := FileVersion{
+			VersionID: id,
+			CreatedAt: parseVersionID(id),
+			Size:      info.Size(),
+		}
+		target := copyVersionPath(path, id)
+		if data, err := os.ReadFile(target + checksumSuffix); err == nil {
+			version.Checksum = strings.TrimSpace(string(data))
+		} else if sum, err := fileChecksum(target); err == nil {
+			version.Checksum = sum
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// deleteCopyVersion removes a version and its checksum, and the
+// versions directory once it is empty
+func deleteCopyVersion(path, versionID string) error {
+	target := copyVersionPath(path, versionID)
+	if err := os.Remove(target); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", target, err)
+	}
+	os.Remove(target + checksumSuffix)
+
+	// Remove fails unless the directories are empty
+	dir := copyVersionDir(path)
+	if os.Remove(dir) == nil {
+		os.Remove(filepath.Dir(dir))
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+// Package versioning keeps previous versions of files so accidental
+// overwrites can be undone
+package versioning
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Versioning backends
+const (
+	BackendCopy = "copy" // Copies in a .versions directory next to the file
+	BackendZFS  = "zfs"  // A snapshot of the dataset containing the file
+
+	// BackendHardlink is the former name of BackendCopy. Versions used to be
+	// hardlinks, which in-place writes to the file changed as well.
+	BackendHardlink = "hardlink"
+)
+
+// VersionsDir is the directory used by the copy backend
+const VersionsDir = ".versions"
+
+// versionIDLayout formats version IDs; they sort chronologically
+const versionIDLayout = "20060102T150405.000000000Z"
+
+var versionIDPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}\.[0-9]{9}Z$`)
+
+// VersioningConfig configures a VersionStore
+type VersioningConfig struct {
+	Backend         string   // "copy" or "zfs" ("" = copy)
+	MaxVersions     int      // Versions kept per file (0 = unlimited)
+	ExcludePatterns []string // Glob patterns matched against the file name
+}
+
+// FileVersion is a stored version of a file
+type FileVersion struct {
+	VersionID string    `json:"versionId"`
+	CreatedAt time.Time `json:"createdAt"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"` // SHA-256, hex encoded
+}
+
+// VersionStore creates, lists and restores file versions
+type VersionStore struct {
+	cfg   VersioningConfig
+	shell executor.ShellExecutor // Only used by the zfs backend
+	mu    sync.Mutex
+}
+
+// NewVersionStore creates a version store. shell is required for the zfs backend.
+func NewVersionStore(cfg VersioningConfig, shell executor.ShellExecutor) (*VersionStore, error) {
+	if cfg.Backend == "" || cfg.Backend == BackendHardlink {
+		cfg.Backend = BackendCopy
+	}
+
+	switch cfg.Backend {
+	case BackendCopy:
+	case BackendZFS:
+		if shell == nil || !shell.CommandExists("zfs") {
+			return nil, fmt.Errorf("ZFS tools not installed")
+		}
+	default:
+		return nil, fmt.Errorf("unknown versioning backend %q", cfg.Backend)
+	}
+
+	if cfg.MaxVersions < 0 {
+		return nil, fmt.Errorf("invalid max versions %d", cfg.MaxVersions)
+	}
+	for _, pattern := range cfg.ExcludePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &VersionStore{cfg: cfg, shell: shell}, nil
+}
+
+// Config returns the store's configuration
+func (s *VersionStore) Config() VersioningConfig {
+	return s.cfg
+}
+
+// IsExcluded reports whether path is never versioned
+func (s *VersionStore) IsExcluded(path string) bool {
+	for _, dir := range strings.Split(filepath.Dir(path), string(filepath.Separator)) {
+		if dir == VersionsDir {
+			return true
+		}
+	}
+
+	name := filepath.Base(path)
+	for _, pattern := range s.cfg.ExcludePatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateVersion stores the current content of path as a new version and
+// returns its ID, or "" if path is excluded. Old versions beyond MaxVersions
+// are removed.
+func (s *VersionStore) CreateVersion(path string) (string, error) {
+	path = filepath.Clean(path)
+	if s.IsExcluded(path) {
+		return "", nil
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.NotFound("File not found", err)
+		}
+		return "", errors.InternalServerError("Failed to access file", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", errors.BadRequest("Only regular files can be versioned", nil)
+	}
+
+	checksum, err := fileChecksum(path)
+	if err != nil {
+		return "", errors.InternalServerError("Failed to checksum file", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := newVersionID()
+	if s.cfg.Backend == BackendZFS {
+		err = s.createZFSVersion(path, id, info.Size(), checksum)
+	} else {
+		err = s.createCopyVersion(path, id, checksum, info.Mode().Perm())
+	}
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("File version created", zap.String("path", path), zap.String("version", id), zap.String("backend", s.cfg.Backend))
+
+	if err := s.prune(path); err != nil {
+		logger.Warn("Failed to prune old file versions", zap.String("path", path), zap.Error(err))
+	}
+	return id, nil
+}
+
+// ListVersions returns the versions of path, newest first
+func (s *VersionStore) ListVersions(path string) ([]FileVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listVersions(filepath.Clean(path))
+}
+
+// RestoreVersion replaces path with the given version. The current content
+// is kept as a new version first, so a restore can be undone.
+func (s *VersionStore) RestoreVersion(path, versionID string) error {
+	path = filepath.Clean(path)
+	if !versionIDPattern.MatchString(versionID) {
+		return errors.BadRequest("Invalid version ID", nil)
+	}
+
+	s.mu.Lock()
+	source, err := s.versionPath(path, versionID)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	// Copy the version next to the file before creating a new version, which
+	// may prune the one being restored
+	tmp, err := copyToTemp(source, filepath.Dir(path), ".restore-*")
+	s.mu.Unlock()
+	if err != nil {
+		return errors.InternalServerError("Failed to read version", err)
+	}
+	defer os.Remove(tmp)
+
+	mode := os.FileMode(0644)
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+		if _, err := s.CreateVersion(path); err != nil {
+			return err
+		}
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		return errors.InternalServerError("Failed to restore version", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.InternalServerError("Failed to restore version", err)
+	}
+
+	logger.Info("File version restored", zap.String("path", path), zap.String("version", versionID))
+	return nil
+}
+
+// DeleteVersion removes a version of path
+func (s *VersionStore) DeleteVersion(path, versionID string) error {
+	path = filepath.Clean(path)
+	if !versionIDPattern.MatchString(versionID) {
+		return errors.BadRequest("Invalid version ID", nil)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.versionPath(path, versionID); err != nil {
+		return err
+	}
+	if err := s.deleteVersion(path, versionID); err != nil {
+		return errors.InternalServerError("Failed to delete version", err)
+	}
+
+	logger.Info("File version deleted", zap.String("path", path), zap.String("version", versionID))
+	return nil
+}
+
+// listVersions lists versions with s.mu held
+func (s *VersionStore) listVersions(path string) ([]FileVersion, error) {
+	var versions []FileVersion
+	var err error
+	if s.cfg.Backend == BackendZFS {
+		versions, err = s.listZFSVersions(path)
+	} else {
+		versions, err = listCopyVersions(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].VersionID > versions[j].VersionID
+	})
+	return versions, nil
+}
+
+// versionPath returns the file holding a version's content
+func (s *VersionStore) versionPath(path, versionID string) (string, error) {
+	var source string
+	var err error
+	if s.cfg.Backend == BackendZFS {
+		source, err = s.zfsVersionPath(path, versionID)
+	} else {
+		source = copyVersionPath(path, versionID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(source); err != nil {
+		return "", errors.NotFound("Version not found", err)
+	}
+	return source, nil
+}
+
+// deleteVersion removes a version with s.mu held
+func (s *VersionStore) deleteVersion(path, versionID string) error {
+	if s.cfg.Backend == BackendZFS {
+		return s.deleteZFSVersion(path, versionID)
+	}
+	return deleteCopyVersion(path, versionID)
+}
+
+// prune removes the oldest versions of path beyond MaxVersions
+func (s *VersionStore) prune(path string) error {
+	if s.cfg.MaxVersions == 0 {
+		return nil
+	}
+
+	versions, err := s.listVersions(path)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions[min(len(versions), s.cfg.MaxVersions):] {
+		if err := s.deleteVersion(path, v.VersionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newVersionID returns an ID for a version created now
+func newVersionID() string {
+	return time.Now().UTC().Format(versionIDLayout)
+}
+
+// parseVersionID returns the creation time encoded in a version ID
+func parseVersionID(id string) time.Time {
+	t, _ := time.Parse(versionIDLayout, id)
+	return t
+}
+
+// fileChecksum returns the hex SHA-256 of a file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyToTemp copies src to a new temporary file in dir, named after pattern,
+// and returns its path. The data is shared with src where the filesystem
+// supports reflinks (btrfs, XFS).
+func copyToTemp(src, dir, pattern string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	if cloneFile(out, in) != nil {
+		// Not supported by the filesystem, or src is on another one
+		_, err = io.Copy(out, in)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
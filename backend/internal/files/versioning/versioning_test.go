@@ -0,0 +1,258 @@
+package versioning
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// fakeZFS emulates a single dataset mounted at mountpoint. Snapshots copy
+// the tagged file into .zfs/snapshot like the real snapshot directory.
+type fakeZFS struct {
+	*executor.MockShellExecutor
+
+	dataset    string
+	mountpoint string
+	snapshots  []fakeSnapshot
+}
+
+type fakeSnapshot struct {
+	name  string
+	props map[string]string
+}
+
+func newFakeZFS(dataset, mountpoint string) *fakeZFS {
+	z := &fakeZFS{MockShellExecutor: executor.NewMockShellExecutor(), dataset: dataset, mountpoint: mountpoint}
+	z.ExpectCommand("zfs", "list", "-H", "-t", "filesystem", "-o", "name,mountpoint").
+		Returns(fmt.Sprintf("rpool\tnone\n%s\t%s\n", dataset, mountpoint), "", 0)
+	z.Handler = z.handle
+	return z
+}
+
+// handle answers the snapshot commands, which depend on earlier calls
+func (z *fakeZFS) handle(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+	args := call.Args
+	switch args[0] {
+	case "list":
+		var out strings.Builder
+		for _, snap := range z.snapshots {
+			fmt.Fprintf(&out, "%s\t%s\t%s\t%s\n", snap.name, snap.props[propPath], snap.props[propSize], snap.props[propChecksum])
+		}
+		return &executor.CommandResult{Stdout: out.String()}, nil
+
+	case "snapshot":
+		snap := fakeSnapshot{name: args[len(args)-1], props: map[string]string{}}
+		for i := 1; i < len(args)-1; i += 2 {
+			key, value, _ := strings.Cut(args[i+1], "=")
+			snap.props[key] = value
+		}
+		_, short, _ := strings.Cut(snap.name, "@")
+		rel := snap.props[propPath]
+		data, err := os.ReadFile(filepath.Join(z.mountpoint, rel))
+		if err != nil {
+			return nil, err
+		}
+		target := filepath.Join(z.mountpoint, ".zfs", "snapshot", short, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, data, 0444); err != nil {
+			return nil, err
+		}
+		z.snapshots = append(z.snapshots, snap)
+		return &executor.CommandResult{Success: true}, nil
+
+	case "destroy":
+		for i, snap := range z.snapshots {
+			if snap.name == args[1] {
+				z.snapshots = append(z.snapshots[:i], z.snapshots[i+1:]...)
+				_, short, _ := strings.Cut(snap.name, "@")
+				os.RemoveAll(filepath.Join(z.mountpoint, ".zfs", "snapshot", short))
+				return &executor.CommandResult{Success: true}, nil
+			}
+		}
+		return nil, fmt.Errorf("snapshot %s does not exist", args[1])
+	}
+	return nil, fmt.Errorf("unexpected zfs command %v", args)
+}
+
+func TestVersionStore(t *testing.T) {
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		backend string
+		shell   func(dir string) executor.ShellExecutor
+	}{
+		{name: "copy", backend: BackendCopy},
+		{name: "zfs", backend: BackendZFS, shell: func(dir string) executor.ShellExecutor {
+			return newFakeZFS("tank/data", dir)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var shell executor.ShellExecutor
+			if tt.shell != nil {
+				shell = tt.shell(dir)
+			}
+
+			store, err := NewVersionStore(VersioningConfig{
+				Backend:         tt.backend,
+				MaxVersions:     2,
+				ExcludePatterns: []string{"*.tmp"},
+			}, shell)
+			if err != nil {
+				t.Fatalf("NewVersionStore() error = %v", err)
+			}
+
+			path := filepath.Join(dir, "docs", "report.txt")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			// Each overwrite replaces the file, as the upload and move handlers do
+			var ids []string
+			for _, content := range []string{"v1", "v2", "v3"} {
+				if _, err := os.Stat(path); err == nil {
+					id, err := store.CreateVersion(path)
+					if err != nil {
+						t.Fatalf("CreateVersion() error = %v", err)
+					}
+					ids = append(ids, id)
+				}
+				replaceFile(t, path, content)
+				time.Sleep(time.Millisecond) // Keep version IDs distinct
+			}
+
+			versions, err := store.ListVersions(path)
+			if err != nil {
+				t.Fatalf("ListVersions() error = %v", err)
+			}
+			if len(versions) != 2 || versions[0].VersionID != ids[1] || versions[1].VersionID != ids[0] {
+				t.Fatalf("ListVersions() = %+v, want %v newest first", versions, ids)
+			}
+			if versions[1].Size != 2 || versions[1].Checksum == "" || versions[1].CreatedAt.IsZero() {
+				t.Errorf("version metadata = %+v", versions[1])
+			}
+
+			if err := store.RestoreVersion(path, ids[0]); err != nil {
+				t.Fatalf("RestoreVersion() error = %v", err)
+			}
+			if data, _ := os.ReadFile(path); string(data) != "v1" {
+				t.Errorf("content after restore = %q, want v1", data)
+			}
+
+			// The restore kept "v3" as a version and pruned down to MaxVersions
+			versions, err = store.ListVersions(path)
+			if err != nil {
+				t.Fatalf("ListVersions() error = %v", err)
+			}
+			if len(versions) != 2 || versions[1].VersionID != ids[1] {
+				t.Fatalf("versions after restore = %+v", versions)
+			}
+
+			if err := store.DeleteVersion(path, versions[0].VersionID); err != nil {
+				t.Fatalf("DeleteVersion() error = %v", err)
+			}
+			if err := store.DeleteVersion(path, versions[0].VersionID); err == nil {
+				t.Error("deleting a deleted version should fail")
+			}
+			if err := store.RestoreVersion(path, "../../etc/passwd"); err == nil {
+				t.Error("RestoreVersion() accepted an invalid version ID")
+			}
+
+			excluded := filepath.Join(dir, "scratch.tmp")
+			replaceFile(t, excluded, "x")
+			if id, err := store.CreateVersion(excluded); err != nil || id != "" {
+				t.Errorf("CreateVersion(excluded) = %q, %v", id, err)
+			}
+		})
+	}
+}
+
+func TestZFSVersionsAreScopedToFile(t *testing.T) {
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	store, err := NewVersionStore(VersioningConfig{Backend: BackendZFS}, newFakeZFS("tank/data", dir))
+	if err != nil {
+		t.Fatalf("NewVersionStore() error = %v", err)
+	}
+
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	replaceFile(t, a, "a")
+	replaceFile(t, b, "b")
+
+	id, err := store.CreateVersion(a)
+	if err != nil {
+		t.Fatalf("CreateVersion() error = %v", err)
+	}
+	if versions, _ := store.ListVersions(b); len(versions) != 0 {
+		t.Errorf("ListVersions(b) = %+v, want none", versions)
+	}
+	if err := store.RestoreVersion(b, id); err == nil {
+		t.Error("restored a version of another file")
+	}
+}
+
+func TestCopyVersionsSurviveInPlaceWrites(t *testing.T) {
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+
+	// "hardlink" is still accepted and stores copies
+	store, err := NewVersionStore(VersioningConfig{Backend: BackendHardlink}, nil)
+	if err != nil {
+		t.Fatalf("NewVersionStore() error = %v", err)
+	}
+	if backend := store.Config().Backend; backend != BackendCopy {
+		t.Errorf("backend = %q, want %q", backend, BackendCopy)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.txt")
+	replaceFile(t, path, "original")
+	id, err := store.CreateVersion(path)
+	if err != nil {
+		t.Fatalf("CreateVersion() error = %v", err)
+	}
+
+	// Truncate and rewrite the file like an SMB or NFS client would
+	if err := os.WriteFile(path, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(copyVersionPath(path, id)); string(data) != "original" {
+		t.Errorf("version content = %q, want original", data)
+	}
+	if err := store.RestoreVersion(path, id); err != nil {
+		t.Fatalf("RestoreVersion() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "original" {
+		t.Errorf("content after restore = %q, want original", data)
+	}
+}
+
+// replaceFile writes content to a new file and renames it over path
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".new"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,149 @@
+package versioning
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+)
+
+// snapshotPrefix marks snapshots created for file versions
+const snapshotPrefix = "ver-"
+
+// User properties recording which file a version snapshot was taken for
+const (
+	propPath     = "stumpfworks:versionpath"
+	propSize     = "stumpfworks:versionsize"
+	propChecksum = "stumpfworks:versionchecksum"
+)
+
+// zfsDataset is the dataset containing a file
+type zfsDataset struct {
+	Name       string
+	Mountpoint string
+	RelPath    string // Path of the file relative to Mountpoint
+}
+
+// findDataset returns the mounted dataset containing path
+func (s *VersionStore) findDataset(path string) (*zfsDataset, error) {
+	result, err := s.shell.Execute("zfs", "list", "-H", "-t", "filesystem", "-o", "name,mountpoint")
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to list ZFS datasets", err)
+	}
+
+	var best *zfsDataset
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 || !filepath.IsAbs(fields[1]) {
+			continue // "none", "legacy" or "-"
+		}
+		mountpoint := filepath.Clean(fields[1])
+		rel, err := filepath.Rel(mountpoint, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == nil || len(mountpoint) > len(best.Mountpoint) {
+			best = &zfsDataset{Name: fields[0], Mountpoint: mountpoint, RelPath: rel}
+		}
+	}
+
+	if best == nil {
+		return nil, errors.BadRequest("File is not on a ZFS dataset", nil)
+	}
+	return best, nil
+}
+
+// createZFSVersion snapshots the dataset containing path, tagging the
+// snapshot with the file it was taken for
+func (s *VersionStore) createZFSVersion(path, versionID string, size int64, checksum string) error {
+	ds, err := s.findDataset(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.shell.Execute("zfs", "snapshot",
+		"-o", propPath+"="+ds.RelPath,
+		"-o", propSize+"="+strconv.FormatInt(size, 10),
+		"-o", propChecksum+"="+checksum,
+		ds.Name+"@"+snapshotPrefix+versionID)
+	if err != nil {
+		return errors.InternalServerError("Failed to create ZFS snapshot", err)
+	}
+	return nil
+}
+
+// listZFSVersions lists the version snapshots taken for path
+func (s *VersionStore) listZFSVersions(path string) ([]FileVersion, error) {
+	ds, err := s.findDataset(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.shell.Execute("zfs", "list", "-H", "-p", "-t", "snapshot", "-d", "1",
+		"-o", strings.Join([]string{"name", propPath, propSize, propChecksum}, ","), ds.Name)
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to list ZFS snapshots", err)
+	}
+
+	versions := []FileVersion{}
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 || fields[1] != ds.RelPath {
+			continue
+		}
+		id, ok := strings.CutPrefix(fields[0], ds.Name+"@"+snapshotPrefix)
+		if !ok || !versionIDPattern.MatchString(id) {
+			continue
+		}
+
+		size, _ := strconv.ParseInt(fields[2], 10, 64)
+		versions = append(versions, FileVersion{
+			VersionID: id,
+			CreatedAt: parseVersionID(id),
+			Size:      size,
+			Checksum:  fields[3],
+		})
+	}
+	return versions, nil
+}
+
+// zfsVersionPath returns the path of a version inside the snapshot directory
+func (s *VersionStore) zfsVersionPath(path, versionID string) (string, error) {
+	ds, err := s.findDataset(path)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkZFSVersion(path, versionID); err != nil {
+		return "", err
+	}
+	return filepath.Join(ds.Mountpoint, ".zfs", "snapshot", snapshotPrefix+versionID, ds.RelPath), nil
+}
+
+// deleteZFSVersion destroys a version snapshot
+func (s *VersionStore) deleteZFSVersion(path, versionID string) error {
+	ds, err := s.findDataset(path)
+	if err != nil {
+		return err
+	}
+	if _, err := s.shell.Execute("zfs", "destroy", ds.Name+"@"+snapshotPrefix+versionID); err != nil {
+		return fmt.Errorf("failed to destroy snapshot: %w", err)
+	}
+	return nil
+}
+
+// checkZFSVersion makes sure versionID is a snapshot taken for path, so
+// snapshots of other files in the same dataset are not touched
+func (s *VersionStore) checkZFSVersion(path, versionID string) error {
+	versions, err := s.listZFSVersions(path)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.VersionID == versionID {
+			return nil
+		}
+	}
+	return errors.NotFound("Version not found", nil)
+}
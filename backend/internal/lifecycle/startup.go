@@ -0,0 +1,297 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// StageState is the progress of a startup stage
+type StageState string
+
+const (
+	StagePending  StageState = "pending"
+	StageRunning  StageState = "running"
+	StageComplete StageState = "complete"
+	StageFailed   StageState = "failed"
+	StageSkipped  StageState = "skipped" // Not started because startup was aborted
+)
+
+// StartupStage is one service initialisation of the server
+type StartupStage struct {
+	Name      string
+	DependsOn []string // Stages that must have finished before Init runs
+	Init      func() error
+
+	// Critical stages abort the startup when they fail. A failed stage that
+	// is not critical only logs a warning; its dependents still run and must
+	// cope with the missing service.
+	Critical bool
+
+	// Impact tells the admin what a failure of the stage means, e.g.
+	// "Quota features will be disabled"
+	Impact string
+}
+
+// StageStatus is the reported state of a startup stage
+type StageStatus struct {
+	Name       string     `json:"name"`
+	DependsOn  []string   `json:"dependsOn,omitempty"`
+	Critical   bool       `json:"critical"`
+	State      StageState `json:"state"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	DurationMs int64      `json:"durationMs,omitempty"`
+}
+
+// StartupStatus is the progress of the whole startup
+type StartupStatus struct {
+	Ready     bool          `json:"ready"`
+	Completed int           `json:"completed"` // Stages that finished, successfully or not
+	Total     int           `json:"total"`
+	Stages    []StageStatus `json:"stages"`
+}
+
+// StartupSequencer runs the startup stages in dependency order. Stages whose
+// dependencies have finished run concurrently.
+type StartupSequencer struct {
+	stages []StartupStage
+
+	mu     sync.RWMutex
+	status map[string]*StageStatus
+	order  []string // Topological order, for reporting
+	ready  atomic.Bool
+}
+
+// NewStartupSequencer creates a sequencer for the stages
+func NewStartupSequencer(stages ...StartupStage) *StartupSequencer {
+	s := &StartupSequencer{
+		stages: stages,
+		status: make(map[string]*StageStatus, len(stages)),
+	}
+	for _, stage := range stages {
+		s.status[stage.Name] = &StageStatus{
+			Name:      stage.Name,
+			DependsOn: stage.DependsOn,
+			Critical:  stage.Critical,
+			State:     StagePending,
+		}
+		s.order = append(s.order, stage.Name)
+	}
+	return s
+}
+
+// sortStages returns the stage names in dependency order, rejecting
+// duplicate names, unknown dependencies and cycles. Stages without an order
+// between them keep their registration order.
+func sortStages(stages []StartupStage) ([]string, error) {
+	index := make(map[string]int, len(stages))
+	for i, stage := range stages {
+		if stage.Name == "" {
+			return nil, fmt.Errorf("startup stage %d has no name", i)
+		}
+		if _, ok := index[stage.Name]; ok {
+			return nil, fmt.Errorf("duplicate startup stage %q", stage.Name)
+		}
+		index[stage.Name] = i
+	}
+
+	pending := make([]int, len(stages))
+	dependents := make(map[string][]int)
+	for i, stage := range stages {
+		for _, dep := range stage.DependsOn {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("startup stage %q depends on unknown stage %q", stage.Name, dep)
+			}
+			pending[i]++
+			dependents[dep] = append(dependents[dep], i)
+		}
+	}
+
+	var queue, order []int
+	for i := range stages {
+		if pending[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		order = append(order, i)
+		for _, j := range dependents[stages[i].Name] {
+			if pending[j]--; pending[j] == 0 {
+				queue = append(queue, j)
+				sort.Ints(queue)
+			}
+		}
+	}
+
+	if len(order) != len(stages) {
+		var cycle []string
+		for i, n := range pending {
+			if n > 0 {
+				cycle = append(cycle, stages[i].Name)
+			}
+		}
+		return nil, fmt.Errorf("startup stages have a dependency cycle: %s", strings.Join(cycle, ", "))
+	}
+
+	names := make([]string, len(order))
+	for i, j := range order {
+		names[i] = stages[j].Name
+	}
+	return names, nil
+}
+
+// Run runs all stages and returns once they finished. A failed critical
+// stage stops further stages from starting; Run then waits for the running
+// ones and returns the error. Cancelling ctx skips the stages not yet
+// started. The sequencer is ready once Run returns without an error.
+func (s *StartupSequencer) Run(ctx context.Context) error {
+	order, err := sortStages(s.stages)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.order = order
+	s.mu.Unlock()
+
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+
+	done := make(map[string]chan struct{}, len(s.stages))
+	for _, stage := range s.stages {
+		done[stage.Name] = make(chan struct{})
+	}
+
+	logger.Info("Starting services", zap.Int("stages", len(s.stages)))
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for _, stage := range s.stages {
+		wg.Add(1)
+		go func(stage StartupStage) {
+			defer wg.Done()
+			defer close(done[stage.Name])
+
+			for _, dep := range stage.DependsOn {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				s.finish(stage.Name, StageSkipped, nil)
+				return
+			}
+
+			s.begin(stage.Name)
+			err := stage.Init()
+			if err == nil {
+				s.finish(stage.Name, StageComplete, nil)
+				return
+			}
+			s.finish(stage.Name, StageFailed, err)
+
+			if stage.Critical {
+				logger.Error("Critical startup stage failed", zap.String("stage", stage.Name), zap.Error(err))
+				abort(fmt.Errorf("startup stage %s failed: %w", stage.Name, err))
+				return
+			}
+			fields := []zap.Field{zap.String("stage", stage.Name), zap.Error(err)}
+			if stage.Impact != "" {
+				fields = append(fields, zap.String("message", stage.Impact))
+			}
+			logger.Warn("Startup stage failed", fields...)
+		}(stage)
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	s.ready.Store(true)
+	status := s.Status()
+	failed := 0
+	for _, stage := range status.Stages {
+		if stage.State == StageFailed {
+			failed++
+		}
+	}
+	logger.Info("Services started",
+		zap.Int("stages", status.Total),
+		zap.Int("failed", failed),
+		zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+func (s *StartupSequencer) begin(name string) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.status[name]
+	st.State = StageRunning
+	st.StartedAt = &now
+}
+
+func (s *StartupSequencer) finish(name string, state StageState, err error) {
+	now := time.Now()
+	s.mu.Lock()
+	st := s.status[name]
+	st.State = state
+	st.FinishedAt = &now
+	if st.StartedAt != nil {
+		st.DurationMs = now.Sub(*st.StartedAt).Milliseconds()
+	}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	completed := 0
+	for _, other := range s.status {
+		if other.FinishedAt != nil {
+			completed++
+		}
+	}
+	s.mu.Unlock()
+
+	if state == StageComplete {
+		logger.Info("Startup stage complete",
+			zap.String("stage", name),
+			zap.Int64("duration_ms", st.DurationMs),
+			zap.String("progress", fmt.Sprintf("%d/%d", completed, len(s.stages))))
+	}
+}
+
+// Status returns the state of every stage, in dependency order
+func (s *StartupSequencer) Status() StartupStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := StartupStatus{
+		Ready:  s.ready.Load(),
+		Total:  len(s.order),
+		Stages: make([]StageStatus, 0, len(s.order)),
+	}
+	for _, name := range s.order {
+		st := *s.status[name]
+		if st.FinishedAt != nil {
+			status.Completed++
+		}
+		status.Stages = append(status.Stages, st)
+	}
+	return status
+}
+
+// Ready reports whether all stages have finished without a critical failure
+func (s *StartupSequencer) Ready() bool {
+	return s.ready.Load()
+}
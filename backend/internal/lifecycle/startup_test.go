@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func TestStartupSequencerRunsIndependentStagesConcurrently(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	// Each of a and b waits for the other to start, so they only finish if
+	// they run at the same time
+	aStarted, bStarted := make(chan struct{}), make(chan struct{})
+	rendezvous := func(self, other chan struct{}) func() error {
+		return func() error {
+			close(self)
+			select {
+			case <-other:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("other stage did not start")
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	s := NewStartupSequencer(
+		StartupStage{Name: "c", DependsOn: []string{"a", "b"}, Init: record("c")},
+		StartupStage{Name: "a", DependsOn: []string{"db"}, Init: rendezvous(aStarted, bStarted)},
+		StartupStage{Name: "b", DependsOn: []string{"db"}, Init: rendezvous(bStarted, aStarted)},
+		StartupStage{Name: "db", Init: record("db"), Critical: true},
+	)
+	if s.Ready() {
+		t.Fatal("sequencer is ready before Run")
+	}
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !s.Ready() {
+		t.Error("sequencer is not ready after Run")
+	}
+
+	status := s.Status()
+	var names []string
+	for _, stage := range status.Stages {
+		names = append(names, stage.Name)
+		if stage.State != StageComplete {
+			t.Errorf("stage %s is %s (%s), want complete", stage.Name, stage.State, stage.Error)
+		}
+	}
+	if got := strings.Join(names, ","); got != "db,a,b,c" {
+		t.Errorf("stages reported in order %s, want db,a,b,c", got)
+	}
+	if got := strings.Join(order, ","); got != "db,c" {
+		t.Errorf("dependent stages ran in order %s, want db,c", got)
+	}
+	if status.Completed != 4 || status.Total != 4 || !status.Ready {
+		t.Errorf("status = %d/%d ready=%v, want 4/4 ready", status.Completed, status.Total, status.Ready)
+	}
+}
+
+func TestStartupSequencerFailures(t *testing.T) {
+	logger.InitLogger("error", false)
+	ok := func() error { return nil }
+
+	// A non-critical failure doesn't stop its dependents
+	s := NewStartupSequencer(
+		StartupStage{Name: "quota", Init: func() error { return errors.New("quota tools missing") }},
+		StartupStage{Name: "shares", DependsOn: []string{"quota"}, Init: ok},
+	)
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run with a non-critical failure: %v", err)
+	}
+	stages := s.Status().Stages
+	if stages[0].State != StageFailed || stages[0].Error != "quota tools missing" || stages[1].State != StageComplete {
+		t.Errorf("stages = %+v, want quota failed and shares complete", stages)
+	}
+
+	// A critical failure skips the stages waiting for it and the sequencer
+	// never becomes ready
+	s = NewStartupSequencer(
+		StartupStage{Name: "database", Init: func() error { return errors.New("disk full") }, Critical: true},
+		StartupStage{Name: "users", DependsOn: []string{"database"}, Init: ok},
+	)
+	if err := s.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Run with a critical failure = %v, want the database error", err)
+	}
+	if stages := s.Status().Stages; stages[1].State != StageSkipped || s.Ready() {
+		t.Errorf("after a critical failure users is %s, ready=%v; want skipped and not ready", stages[1].State, s.Ready())
+	}
+
+	for _, stages := range [][]StartupStage{
+		{{Name: "a", DependsOn: []string{"b"}, Init: ok}, {Name: "b", DependsOn: []string{"a"}, Init: ok}},
+		{{Name: "a", DependsOn: []string{"missing"}, Init: ok}},
+		{{Name: "a", Init: ok}, {Name: "a", Init: ok}},
+	} {
+		if err := NewStartupSequencer(stages...).Run(context.Background()); err == nil {
+			t.Errorf("Run accepted invalid stages %+v", stages)
+		}
+	}
+}
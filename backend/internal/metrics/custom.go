@@ -0,0 +1,429 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Custom metric types
+const (
+	CustomMetricGauge   = "gauge"
+	CustomMetricCounter = "counter"
+)
+
+// customMetricPrefix is prepended to the names of custom metrics in the
+// Prometheus output so they cannot shadow built-in metrics
+const customMetricPrefix = "stumpfworks_custom_"
+
+var (
+	// ErrCustomMetricNotFound is returned for unknown custom metrics
+	ErrCustomMetricNotFound = errors.New("custom metric not found")
+
+	// ErrCustomMetricExists is returned when a custom metric name is taken
+	ErrCustomMetricExists = errors.New("custom metric already exists")
+)
+
+// customMetricName matches valid Prometheus metric names
+var customMetricName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// CustomMetric defines a user metric. Expression is a Go template evaluated
+// against the collected models.SystemMetric that must produce a number, e.g.
+// {{div .MemoryUsedBytes .MemoryTotalBytes}}. The functions add, sub, mul and
+// div are available for arithmetic.
+type CustomMetric struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Help       string `json:"help"`
+	Type       string `json:"type"` // gauge or counter
+}
+
+// CustomMetricStatus is a custom metric with its last evaluation
+type CustomMetricStatus struct {
+	models.CustomMetric
+	Value     *float64 `json:"value,omitempty"`     // Unset until the metric was evaluated
+	LastError string   `json:"lastError,omitempty"` // Error of the last evaluation
+}
+
+// Validate checks the name and type of a custom metric and compiles its expression
+func (cm *CustomMetric) Validate() error {
+	_, err := cm.compile()
+	return err
+}
+
+// compile validates a custom metric and returns its compiled expression
+func (cm *CustomMetric) compile() (*template.Template, error) {
+	if !customMetricName.MatchString(cm.Name) {
+		return nil, fmt.Errorf("invalid metric name %q: use letters, digits and underscores", cm.Name)
+	}
+	if cm.Type != CustomMetricGauge && cm.Type != CustomMetricCounter {
+		return nil, fmt.Errorf("invalid metric type %q: must be %q or %q", cm.Type, CustomMetricGauge, CustomMetricCounter)
+	}
+	if strings.TrimSpace(cm.Expression) == "" {
+		return nil, fmt.Errorf("expression of metric %s is empty", cm.Name)
+	}
+
+	tmpl, err := template.New(cm.Name).Funcs(expressionFuncs).Parse(cm.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile expression of metric %s: %w", cm.Name, err)
+	}
+
+	// Catch unknown fields and non-numeric output now rather than on every collection
+	if _, err := evaluate(tmpl, probeSample()); err != nil {
+		return nil, fmt.Errorf("invalid expression of metric %s: %w", cm.Name, err)
+	}
+	return tmpl, nil
+}
+
+// probeSample returns a sample whose numeric fields are all 1, so expressions
+// can be test-evaluated without dividing by zero
+func probeSample() *models.SystemMetric {
+	sample := &models.SystemMetric{}
+	v := reflect.ValueOf(sample).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int64:
+			field.SetInt(1)
+		case reflect.Uint, reflect.Uint64:
+			field.SetUint(1)
+		case reflect.Float64:
+			field.SetFloat(1)
+		}
+	}
+	return sample
+}
+
+// registeredMetric is a compiled custom metric and its current value
+type registeredMetric struct {
+	CustomMetric
+	tmpl      *template.Template
+	value     float64
+	evaluated bool
+	lastError string
+}
+
+// MetricEngine evaluates custom metrics on every metrics collection and
+// exports them in the Prometheus format
+type MetricEngine struct {
+	mu      sync.RWMutex
+	metrics map[string]*registeredMetric
+}
+
+var globalEngine = NewMetricEngine()
+
+// NewMetricEngine creates an engine without custom metrics
+func NewMetricEngine() *MetricEngine {
+	return &MetricEngine{metrics: make(map[string]*registeredMetric)}
+}
+
+// GetMetricEngine returns the engine of the metrics collector
+func GetMetricEngine() *MetricEngine {
+	return globalEngine
+}
+
+// RegisterCustomMetric compiles a custom metric and adds it to the engine,
+// replacing a metric of the same name
+func (e *MetricEngine) RegisterCustomMetric(cm CustomMetric) error {
+	tmpl, err := cm.compile()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metrics[cm.Name] = &registeredMetric{CustomMetric: cm, tmpl: tmpl}
+	return nil
+}
+
+// UnregisterCustomMetric removes a custom metric from the engine
+func (e *MetricEngine) UnregisterCustomMetric(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.metrics, name)
+}
+
+// EvaluateCustomMetrics evaluates all custom metrics against a collected
+// sample. Gauges take the value of their expression; counters are increased
+// by it. A failing metric keeps its previous value and does not stop the
+// evaluation of the others.
+func (e *MetricEngine) EvaluateCustomMetrics(sample *models.SystemMetric) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []error
+	for _, m := range e.metrics {
+		value, err := evaluate(m.tmpl, sample)
+		if err == nil && m.Type == CustomMetricCounter && value < 0 {
+			err = fmt.Errorf("counter increment %g is negative", value)
+		}
+		if err != nil {
+			m.lastError = err.Error()
+			errs = append(errs, fmt.Errorf("metric %s: %w", m.Name, err))
+			continue
+		}
+
+		if m.Type == CustomMetricCounter {
+			m.value += value
+		} else {
+			m.value = value
+		}
+		m.evaluated = true
+		m.lastError = ""
+	}
+	return errors.Join(errs...)
+}
+
+// PrometheusMetrics returns the evaluated custom metrics in the Prometheus text format
+func (e *MetricEngine) PrometheusMetrics() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.metrics))
+	for name, m := range e.metrics {
+		if m.evaluated {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		m := e.metrics[name]
+		help := m.Help
+		if help == "" {
+			help = "Custom metric " + name
+		}
+		fmt.Fprintf(&b, "# HELP %s%s %s\n", customMetricPrefix, name, strings.ReplaceAll(help, "\n", " "))
+		fmt.Fprintf(&b, "# TYPE %s%s %s\n", customMetricPrefix, name, m.Type)
+		fmt.Fprintf(&b, "%s%s %s\n", customMetricPrefix, name, strconv.FormatFloat(m.value, 'g', -1, 64))
+	}
+	return b.String()
+}
+
+// LoadCustomMetrics registers the custom metrics stored in the database.
+// Metrics that no longer compile are skipped.
+func (e *MetricEngine) LoadCustomMetrics(db *gorm.DB) error {
+	var records []models.CustomMetric
+	if err := db.Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to load custom metrics: %w", err)
+	}
+
+	for _, record := range records {
+		if err := e.RegisterCustomMetric(fromRecord(&record)); err != nil {
+			logger.Warn("Skipping invalid custom metric", zap.String("name", record.Name), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// ListCustomMetrics returns the stored custom metrics with their current values
+func (e *MetricEngine) ListCustomMetrics() ([]CustomMetricStatus, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var records []models.CustomMetric
+	if err := db.Order("name").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list custom metrics: %w", err)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	result := make([]CustomMetricStatus, 0, len(records))
+	for _, record := range records {
+		status := CustomMetricStatus{CustomMetric: record}
+		if m, ok := e.metrics[record.Name]; ok {
+			if m.evaluated {
+				value := m.value
+				status.Value = &value
+			}
+			status.LastError = m.lastError
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// CreateCustomMetric stores and registers a new custom metric
+func (e *MetricEngine) CreateCustomMetric(cm CustomMetric) (*models.CustomMetric, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if err := cm.Validate(); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	db.Model(&models.CustomMetric{}).Where("name = ?", cm.Name).Count(&count)
+	if count > 0 {
+		return nil, ErrCustomMetricExists
+	}
+
+	record := toRecord(cm)
+	if err := db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save custom metric: %w", err)
+	}
+	if err := e.RegisterCustomMetric(cm); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Custom metric created", zap.String("name", cm.Name), zap.String("type", cm.Type))
+	return record, nil
+}
+
+// UpdateCustomMetric changes a stored custom metric. Changing the name or
+// type resets a counter.
+func (e *MetricEngine) UpdateCustomMetric(id string, cm CustomMetric) (*models.CustomMetric, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if err := cm.Validate(); err != nil {
+		return nil, err
+	}
+
+	var record models.CustomMetric
+	if err := db.First(&record, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomMetricNotFound
+		}
+		return nil, fmt.Errorf("failed to get custom metric: %w", err)
+	}
+
+	if cm.Name != record.Name {
+		var count int64
+		db.Model(&models.CustomMetric{}).Where("name = ?", cm.Name).Count(&count)
+		if count > 0 {
+			return nil, ErrCustomMetricExists
+		}
+	}
+
+	oldName, oldType := record.Name, record.Type
+	record.Name = cm.Name
+	record.Expression = cm.Expression
+	record.Help = cm.Help
+	record.Type = cm.Type
+	if err := db.Save(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save custom metric: %w", err)
+	}
+
+	e.mu.Lock()
+	previous := e.metrics[oldName]
+	delete(e.metrics, oldName)
+	e.mu.Unlock()
+	if err := e.RegisterCustomMetric(cm); err != nil {
+		return nil, err
+	}
+
+	// Keep the value of a counter whose identity did not change
+	if previous != nil && oldName == cm.Name && oldType == cm.Type {
+		e.mu.Lock()
+		e.metrics[cm.Name].value = previous.value
+		e.metrics[cm.Name].evaluated = previous.evaluated
+		e.mu.Unlock()
+	}
+
+	logger.Info("Custom metric updated", zap.String("name", cm.Name))
+	return &record, nil
+}
+
+// DeleteCustomMetric removes a stored custom metric
+func (e *MetricEngine) DeleteCustomMetric(id string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	var record models.CustomMetric
+	if err := db.First(&record, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCustomMetricNotFound
+		}
+		return fmt.Errorf("failed to get custom metric: %w", err)
+	}
+	if err := db.Delete(&record).Error; err != nil {
+		return fmt.Errorf("failed to delete custom metric: %w", err)
+	}
+	e.UnregisterCustomMetric(record.Name)
+
+	logger.Info("Custom metric deleted", zap.String("name", record.Name))
+	return nil
+}
+
+func toRecord(cm CustomMetric) *models.CustomMetric {
+	return &models.CustomMetric{Name: cm.Name, Expression: cm.Expression, Help: cm.Help, Type: cm.Type}
+}
+
+func fromRecord(record *models.CustomMetric) CustomMetric {
+	return CustomMetric{Name: record.Name, Expression: record.Expression, Help: record.Help, Type: record.Type}
+}
+
+// evaluate executes an expression and parses its output as a number
+func evaluate(tmpl *template.Template, sample *models.SystemMetric) (float64, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, sample); err != nil {
+		return 0, err
+	}
+	output := strings.TrimSpace(buf.String())
+	value, err := strconv.ParseFloat(output, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expression produced %q, not a number", output)
+	}
+	return value, nil
+}
+
+// expressionFuncs are the arithmetic functions of custom metric expressions
+var expressionFuncs = template.FuncMap{
+	"add": func(a, b interface{}) (float64, error) { return arithmetic(a, b, func(x, y float64) float64 { return x + y }) },
+	"sub": func(a, b interface{}) (float64, error) { return arithmetic(a, b, func(x, y float64) float64 { return x - y }) },
+	"mul": func(a, b interface{}) (float64, error) { return arithmetic(a, b, func(x, y float64) float64 { return x * y }) },
+	"div": func(a, b interface{}) (float64, error) {
+		if y, err := toFloat(b); err == nil && y == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return arithmetic(a, b, func(x, y float64) float64 { return x / y })
+	},
+}
+
+func arithmetic(a, b interface{}, op func(x, y float64) float64) (float64, error) {
+	x, err := toFloat(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y), nil
+}
+
+// toFloat converts the numeric fields of SystemMetric and template constants
+func toFloat(v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+)
+
+func TestRegisterCustomMetricRejectsMalformedExpression(t *testing.T) {
+	engine := NewMetricEngine()
+
+	tests := []struct {
+		metric CustomMetric
+		want   string
+	}{
+		{CustomMetric{Name: "mem_ratio", Type: "gauge", Expression: "{{div .MemoryUsedBytes"}, "failed to compile expression of metric mem_ratio"},
+		{CustomMetric{Name: "mem_ratio", Type: "gauge", Expression: "{{.NoSuchField}}"}, "can't evaluate field NoSuchField"},
+		{CustomMetric{Name: "mem_ratio", Type: "gauge", Expression: "{{.CPUUsage}}%"}, "not a number"},
+		{CustomMetric{Name: "mem_ratio", Type: "histogram", Expression: "{{.CPUUsage}}"}, "invalid metric type"},
+		{CustomMetric{Name: "mem-ratio", Type: "gauge", Expression: "{{.CPUUsage}}"}, "invalid metric name"},
+	}
+	for _, tt := range tests {
+		err := engine.RegisterCustomMetric(tt.metric)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("RegisterCustomMetric(%q) error = %v, want %q", tt.metric.Expression, err, tt.want)
+		}
+	}
+}
+
+func TestEvaluateCustomMetrics(t *testing.T) {
+	engine := NewMetricEngine()
+	for _, cm := range []CustomMetric{
+		{Name: "memory_used_ratio", Type: "gauge", Expression: "{{div .MemoryUsedBytes .MemoryTotalBytes}}"},
+		{Name: "busy_threads", Type: "counter", Expression: "{{sub .ThreadCount .ProcessCount}}", Help: "Threads beyond one per process"},
+	} {
+		if err := engine.RegisterCustomMetric(cm); err != nil {
+			t.Fatalf("RegisterCustomMetric(%s): %v", cm.Name, err)
+		}
+	}
+
+	sample := &models.SystemMetric{MemoryUsedBytes: 3 << 30, MemoryTotalBytes: 4 << 30, ProcessCount: 100, ThreadCount: 150}
+	for i := 0; i < 2; i++ {
+		if err := engine.EvaluateCustomMetrics(sample); err != nil {
+			t.Fatalf("EvaluateCustomMetrics: %v", err)
+		}
+	}
+
+	output := engine.PrometheusMetrics()
+	for _, line := range []string{
+		"# TYPE stumpfworks_custom_memory_used_ratio gauge\n",
+		"stumpfworks_custom_memory_used_ratio 0.75\n",
+		"# HELP stumpfworks_custom_busy_threads Threads beyond one per process\n",
+		"# TYPE stumpfworks_custom_busy_threads counter\n",
+		"stumpfworks_custom_busy_threads 100\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("output is missing %q:\n%s", line, output)
+		}
+	}
+
+	// A failing evaluation keeps the previous value
+	if err := engine.EvaluateCustomMetrics(&models.SystemMetric{}); err == nil {
+		t.Error("expected an error dividing by zero")
+	}
+	if !strings.Contains(engine.PrometheusMetrics(), "stumpfworks_custom_memory_used_ratio 0.75\n") {
+		t.Error("gauge lost its value after a failed evaluation")
+	}
+}
@@ -0,0 +1,422 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Export protocols
+const (
+	ExportProtocolInfluxDB       = "influxdb"
+	ExportProtocolPrometheusPush = "prometheus-push"
+)
+
+const (
+	// MinPushInterval is the shortest allowed push interval; metrics are
+	// collected once per CollectionInterval anyway
+	MinPushInterval = 10 * time.Second
+
+	// exportTimeout bounds a single push
+	exportTimeout = 30 * time.Second
+
+	// exportJob is the Pushgateway job the metrics are grouped under
+	exportJob = "stumpfworks"
+
+	// exportMetricPrefix is prepended to the metric names pushed to a
+	// Pushgateway
+	exportMetricPrefix = "stumpfworks_"
+)
+
+// ErrExporterRunning is returned by Start if the exporter already runs
+var ErrExporterRunning = errors.New("metrics exporter already running")
+
+// MetricsExporter pushes a snapshot of the CPU, memory, disk, network and
+// share metrics to a remote time series database every PushInterval.
+//
+// With the influxdb protocol the points are written in line protocol to the
+// InfluxDB v2 write API (/api/v2/write) of RemoteURL, authenticated with
+// Token. With prometheus-push the snapshot is PUT in text format to the
+// Pushgateway at RemoteURL, replacing the previous one; Token is sent as a
+// bearer token if set.
+type MetricsExporter struct {
+	Protocol     string
+	RemoteURL    string
+	Token        string
+	Org          string // InfluxDB organization
+	Bucket       string // InfluxDB bucket
+	PushInterval time.Duration
+
+	client       *http.Client
+	hostname     string
+	latestMetric func(ctx context.Context) (*models.SystemMetric, error) // Replaced in tests
+	shareTraffic func() []network.ShareTraffic                           // Replaced in tests
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewMetricsExporter creates an exporter from the metrics.export config
+func NewMetricsExporter(cfg config.MetricsExportConfig) (*MetricsExporter, error) {
+	hostname, _ := os.Hostname()
+	e := &MetricsExporter{
+		Protocol:     cfg.Protocol,
+		RemoteURL:    strings.TrimRight(cfg.RemoteURL, "/"),
+		Token:        cfg.Token,
+		Org:          cfg.Org,
+		Bucket:       cfg.Bucket,
+		PushInterval: cfg.PushInterval,
+		client:       &http.Client{Timeout: exportTimeout},
+		hostname:     hostname,
+		latestMetric: latestSystemMetric,
+		shareTraffic: currentShareTraffic,
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Validate checks the exporter settings
+func (e *MetricsExporter) Validate() error {
+	switch e.Protocol {
+	case ExportProtocolInfluxDB:
+		if e.Org == "" || e.Bucket == "" {
+			return fmt.Errorf("the influxdb protocol needs an org and a bucket")
+		}
+	case ExportProtocolPrometheusPush:
+	default:
+		return fmt.Errorf("invalid export protocol %q: must be %s or %s", e.Protocol, ExportProtocolInfluxDB, ExportProtocolPrometheusPush)
+	}
+	u, err := url.Parse(e.RemoteURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid remote URL %q: must be an http or https URL", e.RemoteURL)
+	}
+	if e.PushInterval < MinPushInterval {
+		return fmt.Errorf("push interval must be at least %s (got %s)", MinPushInterval, e.PushInterval)
+	}
+	return nil
+}
+
+// latestSystemMetric returns the last sample of the metrics service
+func latestSystemMetric(ctx context.Context) (*models.SystemMetric, error) {
+	service := GetService()
+	if service == nil {
+		return nil, fmt.Errorf("metrics service not initialized")
+	}
+	return service.GetLatestMetric(ctx)
+}
+
+// currentShareTraffic returns the share traffic counters, if the traffic
+// monitor runs
+func currentShareTraffic() []network.ShareTraffic {
+	if monitor := network.GetTrafficMonitor(); monitor != nil {
+		return monitor.GetShareTraffic()
+	}
+	return nil
+}
+
+// Start pushes the metrics every PushInterval until ctx is done or Stop is
+// called. A failed push is logged and retried at the next interval.
+func (e *MetricsExporter) Start(ctx context.Context) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		return ErrExporterRunning
+	}
+	ctx, e.cancel = context.WithCancel(ctx)
+
+	go e.run(ctx)
+	logger.Info("Metrics export started",
+		zap.String("protocol", e.Protocol),
+		zap.String("url", e.RemoteURL),
+		zap.Duration("interval", e.PushInterval))
+	return nil
+}
+
+// Stop stops pushing metrics
+func (e *MetricsExporter) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+		logger.Info("Metrics export stopped")
+	}
+}
+
+func (e *MetricsExporter) run(ctx context.Context) {
+	ticker := time.NewTicker(e.PushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := e.Push(ctx); err != nil && ctx.Err() == nil {
+				logger.Warn("Failed to push metrics", zap.String("url", e.RemoteURL), zap.Error(err))
+			}
+		}
+	}
+}
+
+// Push sends the current snapshot once and returns the number of points
+// written
+func (e *MetricsExporter) Push(ctx context.Context) (int, error) {
+	metric, err := e.latestMetric(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("no metrics collected yet: %w", err)
+	}
+	points := snapshotPoints(metric, e.shareTraffic(), e.hostname)
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	var req *http.Request
+	switch e.Protocol {
+	case ExportProtocolInfluxDB:
+		query := url.Values{"org": {e.Org}, "bucket": {e.Bucket}, "precision": {"s"}}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost,
+			e.RemoteURL+"/api/v2/write?"+query.Encode(), strings.NewReader(formatLineProtocol(points)))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if e.Token != "" {
+			req.Header.Set("Authorization", "Token "+e.Token)
+		}
+	case ExportProtocolPrometheusPush:
+		path := "/metrics/job/" + exportJob
+		if e.hostname != "" {
+			path += "/instance/" + url.PathEscape(e.hostname)
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut,
+			e.RemoteURL+path, strings.NewReader(formatPushgateway(points)))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if e.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+e.Token)
+		}
+	default:
+		return 0, fmt.Errorf("invalid export protocol %q", e.Protocol)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("remote returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return len(points), nil
+}
+
+// ExportTestResult is the outcome of a test push
+type ExportTestResult struct {
+	Success  bool   `json:"success"`
+	Points   int    `json:"points"`          // Points written
+	Duration int64  `json:"duration"`        // Milliseconds
+	Error    string `json:"error,omitempty"` // Why the push failed
+}
+
+// Test pushes the current snapshot once to check the remote and its
+// credentials
+func (e *MetricsExporter) Test(ctx context.Context) *ExportTestResult {
+	start := time.Now()
+	points, err := e.Push(ctx)
+	result := &ExportTestResult{Success: err == nil, Points: points, Duration: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// exportPoint is one measurement of the snapshot. Integer fields are
+// written as integers in line protocol.
+type exportPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      []exportField
+	counter     bool // The fields only ever grow
+	time        time.Time
+}
+
+type exportField struct {
+	name    string
+	value   float64
+	integer bool
+}
+
+func floatField(name string, value float64) exportField {
+	return exportField{name: name, value: value}
+}
+
+func intField(name string, value uint64) exportField {
+	return exportField{name: name, value: float64(value), integer: true}
+}
+
+// snapshotPoints converts a sample and the share traffic into points
+func snapshotPoints(m *models.SystemMetric, shares []network.ShareTraffic, hostname string) []exportPoint {
+	tags := map[string]string{}
+	if hostname != "" {
+		tags["host"] = hostname
+	}
+	points := []exportPoint{
+		{measurement: "cpu", tags: tags, time: m.Timestamp, fields: []exportField{
+			floatField("usage_percent", m.CPUUsage),
+			floatField("load1", m.CPULoadAvg1),
+			floatField("load5", m.CPULoadAvg5),
+			floatField("load15", m.CPULoadAvg15),
+			floatField("temperature_celsius", m.CPUTemperature),
+		}},
+		{measurement: "memory", tags: tags, time: m.Timestamp, fields: []exportField{
+			intField("used_bytes", m.MemoryUsedBytes),
+			intField("total_bytes", m.MemoryTotalBytes),
+			floatField("usage_percent", m.MemoryUsage),
+			intField("swap_used_bytes", m.SwapUsedBytes),
+			intField("swap_total_bytes", m.SwapTotalBytes),
+			floatField("swap_usage_percent", m.SwapUsage),
+		}},
+		{measurement: "disk", tags: tags, time: m.Timestamp, fields: []exportField{
+			intField("used_bytes", m.DiskUsedBytes),
+			intField("total_bytes", m.DiskTotalBytes),
+			floatField("usage_percent", m.DiskUsage),
+			intField("read_bytes_per_sec", m.DiskReadBytesPerSec),
+			intField("write_bytes_per_sec", m.DiskWriteBytesPerSec),
+			intField("iops", m.DiskIOPS),
+		}},
+		{measurement: "network", tags: tags, time: m.Timestamp, fields: []exportField{
+			intField("rx_bytes_per_sec", m.NetworkRxBytesPerSec),
+			intField("tx_bytes_per_sec", m.NetworkTxBytesPerSec),
+			intField("rx_packets_per_sec", m.NetworkRxPacketsPerSec),
+			intField("tx_packets_per_sec", m.NetworkTxPacketsPerSec),
+		}},
+	}
+	for _, s := range shares {
+		shareTags := map[string]string{"share": s.Share, "protocol": s.Protocol}
+		for k, v := range tags {
+			shareTags[k] = v
+		}
+		points = append(points, exportPoint{
+			measurement: "share",
+			tags:        shareTags,
+			counter:     true,
+			time:        m.Timestamp,
+			fields:      []exportField{intField("rx_bytes", s.RxBytes), intField("tx_bytes", s.TxBytes)},
+		})
+	}
+	return points
+}
+
+// sortedTagKeys returns the tag keys in the order InfluxDB stores them
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// formatLineProtocol renders points in InfluxDB line protocol with second
+// precision, e.g. cpu,host=nas usage_percent=12.5,load1=0.4 1700000000
+func formatLineProtocol(points []exportPoint) string {
+	var b strings.Builder
+	for _, p := range points {
+		b.WriteString(measurementEscaper.Replace(p.measurement))
+		for _, k := range sortedTagKeys(p.tags) {
+			if p.tags[k] == "" {
+				continue // Empty tag values are invalid
+			}
+			fmt.Fprintf(&b, ",%s=%s", tagEscaper.Replace(k), tagEscaper.Replace(p.tags[k]))
+		}
+		for i, f := range p.fields {
+			if i == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteByte(',')
+			}
+			b.WriteString(tagEscaper.Replace(f.name))
+			b.WriteByte('=')
+			if f.integer {
+				b.WriteString(strconv.FormatInt(int64(f.value), 10) + "i")
+			} else {
+				b.WriteString(strconv.FormatFloat(f.value, 'f', -1, 64))
+			}
+		}
+		fmt.Fprintf(&b, " %d\n", p.time.Unix())
+	}
+	return b.String()
+}
+
+// formatPushgateway renders points in the Prometheus text format, one
+// metric per measurement field, e.g. stumpfworks_cpu_usage_percent. The
+// Pushgateway stores the push time itself, so no timestamps are sent.
+func formatPushgateway(points []exportPoint) string {
+	type sample struct {
+		labels string
+		value  float64
+	}
+	var names []string
+	samples := map[string][]sample{}
+	counters := map[string]bool{}
+	for _, p := range points {
+		var labels []string
+		for _, k := range sortedTagKeys(p.tags) {
+			labels = append(labels, fmt.Sprintf("%s=%q", k, p.tags[k]))
+		}
+		for _, f := range p.fields {
+			name := exportMetricPrefix + p.measurement + "_" + f.name
+			if p.counter {
+				name += "_total"
+				counters[name] = true
+			}
+			if _, ok := samples[name]; !ok {
+				names = append(names, name)
+			}
+			samples[name] = append(samples[name], sample{strings.Join(labels, ","), f.value})
+		}
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		metricType := "gauge"
+		if counters[name] {
+			metricType = "counter"
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, metricType)
+		for _, s := range samples[name] {
+			fmt.Fprintf(&b, "%s{%s} %s\n", name, s.labels, strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+	return b.String()
+}
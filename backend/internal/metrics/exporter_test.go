@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+var exportSample = &models.SystemMetric{
+	Timestamp:            time.Unix(1700000000, 0),
+	CPUUsage:             12.5,
+	CPULoadAvg1:          0.42,
+	MemoryUsedBytes:      3 << 30,
+	MemoryTotalBytes:     8 << 30,
+	MemoryUsage:          37.5,
+	DiskUsedBytes:        500,
+	DiskTotalBytes:       1000,
+	DiskUsage:            50,
+	DiskIOPS:             120,
+	NetworkRxBytesPerSec: 2048,
+	NetworkTxBytesPerSec: 1024,
+}
+
+// remoteRequest is a request received by the mock remote
+type remoteRequest struct {
+	method, path, query, auth, body string
+}
+
+func newTestExporter(t *testing.T, cfg config.MetricsExportConfig) (*MetricsExporter, chan remoteRequest) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	requests := make(chan remoteRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- remoteRequest{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), string(body)}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg.RemoteURL = server.URL + "/"
+	if cfg.PushInterval == 0 {
+		cfg.PushInterval = time.Minute
+	}
+	e, err := NewMetricsExporter(cfg)
+	if err != nil {
+		t.Fatalf("NewMetricsExporter: %v", err)
+	}
+	e.hostname = "nas01"
+	e.latestMetric = func(ctx context.Context) (*models.SystemMetric, error) { return exportSample, nil }
+	e.shareTraffic = func() []network.ShareTraffic {
+		return []network.ShareTraffic{{Share: "Media Files", Protocol: "smb", RxBytes: 100, TxBytes: 5000}}
+	}
+	return e, requests
+}
+
+func TestExporterPushesLineProtocol(t *testing.T) {
+	e, requests := newTestExporter(t, config.MetricsExportConfig{
+		Protocol: ExportProtocolInfluxDB, Token: "s3cr3t", Org: "home", Bucket: "nas",
+	})
+
+	n, err := e.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("Push wrote %d points, want 5", n)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/api/v2/write" || req.auth != "Token s3cr3t" {
+		t.Errorf("request = %s %s, Authorization %q", req.method, req.path, req.auth)
+	}
+	if req.query != "bucket=nas&org=home&precision=s" {
+		t.Errorf("query = %q", req.query)
+	}
+	want := `cpu,host=nas01 usage_percent=12.5,load1=0.42,load5=0,load15=0,temperature_celsius=0 1700000000
+memory,host=nas01 used_bytes=3221225472i,total_bytes=8589934592i,usage_percent=37.5,swap_used_bytes=0i,swap_total_bytes=0i,swap_usage_percent=0 1700000000
+disk,host=nas01 used_bytes=500i,total_bytes=1000i,usage_percent=50,read_bytes_per_sec=0i,write_bytes_per_sec=0i,iops=120i 1700000000
+network,host=nas01 rx_bytes_per_sec=2048i,tx_bytes_per_sec=1024i,rx_packets_per_sec=0i,tx_packets_per_sec=0i 1700000000
+share,host=nas01,protocol=smb,share=Media\ Files rx_bytes=100i,tx_bytes=5000i 1700000000
+`
+	if req.body != want {
+		t.Errorf("body =\n%s\nwant\n%s", req.body, want)
+	}
+}
+
+func TestExporterPushesToPushgateway(t *testing.T) {
+	e, requests := newTestExporter(t, config.MetricsExportConfig{Protocol: ExportProtocolPrometheusPush})
+
+	if _, err := e.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPut || req.path != "/metrics/job/stumpfworks/instance/nas01" || req.auth != "" {
+		t.Errorf("request = %s %s, Authorization %q", req.method, req.path, req.auth)
+	}
+	for _, line := range []string{
+		"# TYPE stumpfworks_cpu_usage_percent gauge\nstumpfworks_cpu_usage_percent{host=\"nas01\"} 12.5\n",
+		"stumpfworks_memory_used_bytes{host=\"nas01\"} 3221225472\n",
+		"# TYPE stumpfworks_share_rx_bytes_total counter\nstumpfworks_share_rx_bytes_total{host=\"nas01\",protocol=\"smb\",share=\"Media Files\"} 100\n",
+	} {
+		if !strings.Contains(req.body, line) {
+			t.Errorf("body does not contain %q:\n%s", line, req.body)
+		}
+	}
+}
+
+func TestExporterReportsRemoteErrors(t *testing.T) {
+	logger.InitLogger("error", false)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":"unauthorized","message":"unauthorized access"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	e, err := NewMetricsExporter(config.MetricsExportConfig{
+		Protocol: ExportProtocolInfluxDB, RemoteURL: server.URL, Org: "home", Bucket: "nas", PushInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewMetricsExporter: %v", err)
+	}
+	e.latestMetric = func(ctx context.Context) (*models.SystemMetric, error) { return exportSample, nil }
+	e.shareTraffic = func() []network.ShareTraffic { return nil }
+
+	_, err = e.Push(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "unauthorized access") {
+		t.Errorf("Push error = %v, want the remote's 401", err)
+	}
+
+	if result := e.Test(context.Background()); result.Success || result.Points != 0 || !strings.Contains(result.Error, "401") {
+		t.Errorf("Test = %+v, want a failure", result)
+	}
+}
+
+func TestExporterStartStop(t *testing.T) {
+	e, _ := newTestExporter(t, config.MetricsExportConfig{Protocol: ExportProtocolPrometheusPush})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := e.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := e.Start(ctx); err != ErrExporterRunning {
+		t.Errorf("second Start: %v, want ErrExporterRunning", err)
+	}
+	e.Stop()
+	if err := e.Start(ctx); err != nil {
+		t.Errorf("Start after Stop: %v", err)
+	}
+	e.Stop()
+
+	e.PushInterval = time.Second
+	if err := e.Start(ctx); err == nil {
+		t.Error("Start with a 1s push interval succeeded")
+		e.Stop()
+	}
+}
+
+func TestNewMetricsExporterValidates(t *testing.T) {
+	valid := config.MetricsExportConfig{
+		Protocol: ExportProtocolInfluxDB, RemoteURL: "https://influx.example.com", Org: "home", Bucket: "nas", PushInterval: time.Minute,
+	}
+	if _, err := NewMetricsExporter(valid); err != nil {
+		t.Errorf("NewMetricsExporter(%+v): %v", valid, err)
+	}
+
+	invalid := []func(c *config.MetricsExportConfig){
+		func(c *config.MetricsExportConfig) { c.Protocol = "graphite" },
+		func(c *config.MetricsExportConfig) { c.RemoteURL = "influx.example.com:8086" },
+		func(c *config.MetricsExportConfig) { c.RemoteURL = "ftp://influx.example.com" },
+		func(c *config.MetricsExportConfig) { c.Bucket = "" },
+		func(c *config.MetricsExportConfig) { c.PushInterval = time.Second },
+	}
+	for i, mutate := range invalid {
+		cfg := valid
+		mutate(&cfg)
+		if _, err := NewMetricsExporter(cfg); err == nil {
+			t.Errorf("case %d: NewMetricsExporter(%+v) succeeded", i, cfg)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/storage"
+)
+
+// Health score components
+const (
+	ComponentCPU     = "cpu"
+	ComponentMemory  = "memory"
+	ComponentDisk    = "disk"
+	ComponentSMART   = "smart"
+	ComponentNetwork = "network"
+	// ComponentOverall marks deductions taken off the overall score
+	ComponentOverall = "overall"
+)
+
+// SMARTFailurePenalty is deducted from the overall score for each disk that
+// failed its SMART health test, regardless of the SMART weight
+const SMARTFailurePenalty = 30
+
+// healthScoreComponents lists the weighted components in report order
+var healthScoreComponents = []string{ComponentCPU, ComponentMemory, ComponentDisk, ComponentSMART, ComponentNetwork}
+
+// DefaultHealthScoreWeights returns the default weight of each component
+func DefaultHealthScoreWeights() map[string]float64 {
+	return map[string]float64{
+		ComponentCPU:     0.20,
+		ComponentMemory:  0.20,
+		ComponentDisk:    0.30,
+		ComponentSMART:   0.20,
+		ComponentNetwork: 0.10,
+	}
+}
+
+// LatestMetrics are the readings a health score is computed from
+type LatestMetrics struct {
+	System           *models.SystemMetric
+	NetworkErrorRate float64      // Errors and drops per 100 packets
+	Disks            []DiskHealth // Disks with SMART data
+}
+
+// DiskHealth is the SMART state of a disk
+type DiskHealth struct {
+	Device             string `json:"device"`
+	SMARTPassed        bool   `json:"smartPassed"`
+	ReallocatedSectors uint64 `json:"reallocatedSectors"`
+	PendingSectors     uint64 `json:"pendingSectors"`
+	Temperature        int    `json:"temperature"`
+}
+
+// HealthScore is a computed health score with the deductions that led to it
+type HealthScore struct {
+	Overall    int              `json:"overall"`    // 0-100, higher is better
+	Components map[string]int   `json:"components"` // 0-100 per component
+	Deductions []ScoreDeduction `json:"deductions"`
+}
+
+// ScoreDeduction is a reason a score is below 100. Points are taken off the
+// component's score, or off the overall score for ComponentOverall.
+type ScoreDeduction struct {
+	Component string `json:"component"`
+	Reason    string `json:"reason"`
+	Points    int    `json:"points"`
+}
+
+// HealthScoreCalculator computes a 0-100 health score as the weighted
+// average of the component scores. Weights are relative and need not add
+// up to 1; components without a weight don't count.
+type HealthScoreCalculator struct {
+	Weights map[string]float64
+}
+
+// NewHealthScoreCalculator creates a calculator with the default weights,
+// overridden per component by weights
+func NewHealthScoreCalculator(weights map[string]float64) *HealthScoreCalculator {
+	merged := DefaultHealthScoreWeights()
+	for component, weight := range weights {
+		merged[component] = weight
+	}
+	return &HealthScoreCalculator{Weights: merged}
+}
+
+// Validate checks that the weights name known components, are not negative
+// and are not all zero
+func (c *HealthScoreCalculator) Validate() error {
+	var total float64
+	for component, weight := range c.Weights {
+		known := false
+		for _, name := range healthScoreComponents {
+			known = known || name == component
+		}
+		if !known {
+			return fmt.Errorf("unknown health score component %q", component)
+		}
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("invalid weight %v for health score component %q", weight, component)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("health score weights must not all be zero")
+	}
+	return nil
+}
+
+// ComputeScore computes the health score of the given readings
+func (c *HealthScoreCalculator) ComputeScore(metrics LatestMetrics) (HealthScore, error) {
+	if metrics.System == nil {
+		return HealthScore{}, fmt.Errorf("no system metrics")
+	}
+	if err := c.Validate(); err != nil {
+		return HealthScore{}, err
+	}
+
+	score := HealthScore{Components: make(map[string]int), Deductions: []ScoreDeduction{}}
+	deduct := func(component, reason string, points int) {
+		score.Deductions = append(score.Deductions, ScoreDeduction{Component: component, Reason: reason, Points: points})
+	}
+
+	m := metrics.System
+	switch {
+	case m.CPUUsage > 90:
+		deduct(ComponentCPU, fmt.Sprintf("CPU usage %.0f%% above 90%%", m.CPUUsage), 80)
+	case m.CPUUsage > 75:
+		deduct(ComponentCPU, fmt.Sprintf("CPU usage %.0f%% above 75%%", m.CPUUsage), 50)
+	case m.CPUUsage > 50:
+		deduct(ComponentCPU, fmt.Sprintf("CPU usage %.0f%% above 50%%", m.CPUUsage), 25)
+	}
+
+	switch {
+	case m.MemoryUsage > 90:
+		deduct(ComponentMemory, fmt.Sprintf("Memory usage %.0f%% above 90%%", m.MemoryUsage), 80)
+	case m.MemoryUsage > 80:
+		deduct(ComponentMemory, fmt.Sprintf("Memory usage %.0f%% above 80%%", m.MemoryUsage), 50)
+	case m.MemoryUsage > 70:
+		deduct(ComponentMemory, fmt.Sprintf("Memory usage %.0f%% above 70%%", m.MemoryUsage), 25)
+	}
+
+	switch {
+	case m.DiskUsage > 95:
+		deduct(ComponentDisk, fmt.Sprintf("Disk usage %.0f%% above 95%%", m.DiskUsage), 90)
+	case m.DiskUsage > 90:
+		deduct(ComponentDisk, fmt.Sprintf("Disk usage %.0f%% above 90%%", m.DiskUsage), 70)
+	case m.DiskUsage > 80:
+		deduct(ComponentDisk, fmt.Sprintf("Disk usage %.0f%% above 80%%", m.DiskUsage), 40)
+	case m.DiskUsage > 70:
+		deduct(ComponentDisk, fmt.Sprintf("Disk usage %.0f%% above 70%%", m.DiskUsage), 20)
+	}
+
+	failedDisks := 0
+	for _, disk := range metrics.Disks {
+		if !disk.SMARTPassed {
+			failedDisks++
+			deduct(ComponentSMART, fmt.Sprintf("SMART health test failed on %s", disk.Device), 100)
+			continue
+		}
+		if disk.ReallocatedSectors > 0 {
+			deduct(ComponentSMART, fmt.Sprintf("%d reallocated sectors on %s", disk.ReallocatedSectors, disk.Device), 20)
+		}
+		if disk.PendingSectors > 0 {
+			deduct(ComponentSMART, fmt.Sprintf("%d pending sectors on %s", disk.PendingSectors, disk.Device), 20)
+		}
+		if disk.Temperature > 60 {
+			deduct(ComponentSMART, fmt.Sprintf("%s at %d°C", disk.Device, disk.Temperature), 10)
+		}
+	}
+
+	rate := metrics.NetworkErrorRate
+	switch {
+	case rate > 5:
+		deduct(ComponentNetwork, fmt.Sprintf("Network error rate %.1f%% above 5%%", rate), 90)
+	case rate > 2:
+		deduct(ComponentNetwork, fmt.Sprintf("Network error rate %.1f%% above 2%%", rate), 60)
+	case rate > 0.5:
+		deduct(ComponentNetwork, fmt.Sprintf("Network error rate %.1f%% above 0.5%%", rate), 30)
+	case rate > 0.1:
+		deduct(ComponentNetwork, fmt.Sprintf("Network error rate %.2f%% above 0.1%%", rate), 10)
+	}
+
+	for _, component := range healthScoreComponents {
+		score.Components[component] = 100
+	}
+	for _, d := range score.Deductions {
+		score.Components[d.Component] = max(0, score.Components[d.Component]-d.Points)
+	}
+
+	var weighted, total float64
+	for _, component := range healthScoreComponents {
+		weighted += c.Weights[component] * float64(score.Components[component])
+		total += c.Weights[component]
+	}
+	overall := int(math.Round(weighted / total))
+
+	if failedDisks > 0 {
+		deduct(ComponentOverall, fmt.Sprintf("%d disk(s) failed the SMART health test", failedDisks), failedDisks*SMARTFailurePenalty)
+		overall -= failedDisks * SMARTFailurePenalty
+	}
+	score.Overall = max(0, min(100, overall))
+
+	// Largest deductions first
+	sort.SliceStable(score.Deductions, func(i, j int) bool { return score.Deductions[i].Points > score.Deductions[j].Points })
+	return score, nil
+}
+
+// smartDiskHealth returns the SMART state of the disks that report it
+func smartDiskHealth() []DiskHealth {
+	disks, err := storage.ListDisks()
+	if err != nil {
+		return nil
+	}
+
+	var health []DiskHealth
+	for _, disk := range disks {
+		if disk.SMART == nil {
+			continue
+		}
+		health = append(health, DiskHealth{
+			Device:             disk.Name,
+			SMARTPassed:        disk.SMART.Healthy,
+			ReallocatedSectors: disk.SMART.ReallocatedSectors,
+			PendingSectors:     disk.SMART.PendingSectors,
+			Temperature:        disk.SMART.Temperature,
+		})
+	}
+	return health
+}
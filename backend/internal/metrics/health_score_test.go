@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+)
+
+func TestComputeScorePerfectSystem(t *testing.T) {
+	score, err := NewHealthScoreCalculator(nil).ComputeScore(LatestMetrics{
+		System: &models.SystemMetric{CPUUsage: 12, MemoryUsage: 40, DiskUsage: 55},
+		Disks:  []DiskHealth{{Device: "sda", SMARTPassed: true, Temperature: 35}},
+	})
+	if err != nil {
+		t.Fatalf("ComputeScore() error = %v", err)
+	}
+	if score.Overall != 100 || len(score.Deductions) != 0 {
+		t.Errorf("ComputeScore() = %+v, want 100 without deductions", score)
+	}
+	for component, value := range score.Components {
+		if value != 100 {
+			t.Errorf("component %s = %d, want 100", component, value)
+		}
+	}
+}
+
+func TestComputeScoreFailedSMARTDisk(t *testing.T) {
+	system := &models.SystemMetric{CPUUsage: 12, MemoryUsage: 40, DiskUsage: 55}
+	disks := []DiskHealth{
+		{Device: "sda", SMARTPassed: true},
+		{Device: "sdb", SMARTPassed: false},
+	}
+
+	score, err := NewHealthScoreCalculator(nil).ComputeScore(LatestMetrics{System: system, Disks: disks})
+	if err != nil {
+		t.Fatalf("ComputeScore() error = %v", err)
+	}
+	if score.Overall > 70 {
+		t.Errorf("Overall = %d, want <= 70 with a failed SMART test", score.Overall)
+	}
+	if score.Components[ComponentSMART] != 0 {
+		t.Errorf("SMART component = %d, want 0", score.Components[ComponentSMART])
+	}
+	if d := score.Deductions[0]; d.Component != ComponentSMART || d.Points != 100 {
+		t.Errorf("first deduction = %+v, want the failed SMART test", d)
+	}
+
+	// The penalty applies even if SMART carries no weight
+	score, err = NewHealthScoreCalculator(map[string]float64{ComponentSMART: 0}).ComputeScore(LatestMetrics{System: system, Disks: disks})
+	if err != nil {
+		t.Fatalf("ComputeScore() error = %v", err)
+	}
+	if score.Overall != 100-SMARTFailurePenalty {
+		t.Errorf("Overall without SMART weight = %d, want %d", score.Overall, 100-SMARTFailurePenalty)
+	}
+}
+
+func TestHealthScoreCalculatorRejectsInvalidWeights(t *testing.T) {
+	for _, weights := range []map[string]float64{
+		{"gpu": 0.5},
+		{ComponentCPU: -1},
+		{ComponentCPU: 0, ComponentMemory: 0, ComponentDisk: 0, ComponentSMART: 0, ComponentNetwork: 0},
+	} {
+		_, err := NewHealthScoreCalculator(weights).ComputeScore(LatestMetrics{System: &models.SystemMetric{}})
+		if err == nil {
+			t.Errorf("ComputeScore() with weights %v succeeded, want an error", weights)
+		}
+	}
+}
@@ -3,13 +3,17 @@ package metrics
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/events"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
@@ -42,6 +46,11 @@ type Service struct {
 	prevNetStats  map[string]net.IOCountersStat
 	prevDiskStats map[string]disk.IOCountersStat
 	prevTime      time.Time
+
+	scorer     *HealthScoreCalculator
+	diskHealth func() []DiskHealth
+	scoreMu    sync.RWMutex
+	lastScore  *HealthScore // Breakdown of the last stored health score
 }
 
 var (
@@ -65,6 +74,12 @@ func Initialize() (*Service, error) {
 			prevNetStats:  make(map[string]net.IOCountersStat),
 			prevDiskStats: make(map[string]disk.IOCountersStat),
 			prevTime:      time.Now(),
+			scorer:        newConfiguredScorer(),
+			diskHealth:    smartDiskHealth,
+		}
+
+		if err := GetMetricEngine().LoadCustomMetrics(db); err != nil {
+			logger.Warn("Failed to load custom metrics", zap.Error(err))
 		}
 
 		logger.Info("Metrics service initialized")
@@ -73,6 +88,21 @@ func Initialize() (*Service, error) {
 	return globalService, initErr
 }
 
+// newConfiguredScorer creates the health score calculator with the weights
+// from the config, falling back to the defaults if they are invalid
+func newConfiguredScorer() *HealthScoreCalculator {
+	var weights map[string]float64
+	if cfg := config.GlobalConfig; cfg != nil {
+		weights = cfg.Metrics.HealthScoreWeights
+	}
+	scorer := NewHealthScoreCalculator(weights)
+	if err := scorer.Validate(); err != nil {
+		logger.Warn("Invalid health score weights, using defaults", zap.Error(err))
+		scorer = NewHealthScoreCalculator(nil)
+	}
+	return scorer
+}
+
 // GetService returns the global metrics service
 func GetService() *Service {
 	if globalService == nil {
@@ -204,6 +234,31 @@ func (s *Service) collectMetrics() {
 	// Calculate and store health score
 	s.calculateHealthScore(metric)
 
+	// Update user-defined metrics from the new sample
+	if err := GetMetricEngine().EvaluateCustomMetrics(metric); err != nil {
+		logger.Warn("Failed to evaluate custom metrics", zap.Error(err))
+	}
+
+	// Alert on rules whose conditions now hold
+	if alertService := alerts.GetService(); alertService != nil {
+		alertService.EvaluateAlertRules(context.Background(), metric)
+	}
+
+	// Push the new sample to live dashboards
+	events.Publish(events.Event{
+		Type:    events.TypeMetrics,
+		Action:  "collected",
+		Source:  "metrics",
+		Message: "System metrics collected",
+		Data: map[string]interface{}{
+			"cpuUsage":    metric.CPUUsage,
+			"memoryUsage": metric.MemoryUsage,
+			"swapUsage":   metric.SwapUsage,
+			"cpuLoadAvg1": metric.CPULoadAvg1,
+			"timestamp":   metric.Timestamp,
+		},
+	})
+
 	// Cleanup old metrics periodically (every hour)
 	if time.Now().Minute() == 0 {
 		s.cleanupOldMetrics()
@@ -308,100 +363,38 @@ func (s *Service) collectNetworkMetrics(metric *models.SystemMetric) {
 
 // calculateHealthScore calculates and stores the system health score
 func (s *Service) calculateHealthScore(metric *models.SystemMetric) {
-	score := &models.HealthScore{
-		Timestamp: metric.Timestamp,
-	}
-
-	// CPU score (inverse of usage, load avg consideration)
-	cpuScore := 100
-	if metric.CPUUsage > 90 {
-		cpuScore = 20
-	} else if metric.CPUUsage > 75 {
-		cpuScore = 50
-	} else if metric.CPUUsage > 50 {
-		cpuScore = 75
-	}
-	score.CPUScore = cpuScore
-
-	// Memory score (inverse of usage)
-	memoryScore := 100
-	if metric.MemoryUsage > 90 {
-		memoryScore = 20
-	} else if metric.MemoryUsage > 80 {
-		memoryScore = 50
-	} else if metric.MemoryUsage > 70 {
-		memoryScore = 75
-	}
-	score.MemoryScore = memoryScore
-
-	// Disk score (inverse of usage)
-	diskScore := 100
-	if metric.DiskUsage > 95 {
-		diskScore = 10
-	} else if metric.DiskUsage > 90 {
-		diskScore = 30
-	} else if metric.DiskUsage > 80 {
-		diskScore = 60
-	} else if metric.DiskUsage > 70 {
-		diskScore = 80
-	}
-	score.DiskScore = diskScore
-
-	// Network score (based on packet errors and utilization)
-	networkScore := 100
-
-	// Get current network stats to check for errors
-	if netIO, err := net.IOCounters(true); err == nil {
-		totalPackets := uint64(0)
-		totalErrors := uint64(0)
-
-		for _, io := range netIO {
-			// Skip loopback
-			if io.Name == "lo" {
-				continue
-			}
-			totalPackets += io.PacketsSent + io.PacketsRecv
-			totalErrors += io.Errin + io.Errout + io.Dropin + io.Dropout
-		}
-
-		// Calculate error rate
-		if totalPackets > 0 {
-			errorRate := float64(totalErrors) / float64(totalPackets) * 100
-
-			// Penalize based on error rate
-			if errorRate > 5.0 {
-				networkScore = 10 // Very high error rate
-			} else if errorRate > 2.0 {
-				networkScore = 40 // High error rate
-			} else if errorRate > 0.5 {
-				networkScore = 70 // Moderate error rate
-			} else if errorRate > 0.1 {
-				networkScore = 90 // Low error rate
-			}
-			// else: networkScore = 100 (very low/no errors)
-		}
+	breakdown, err := s.scorer.ComputeScore(LatestMetrics{
+		System:           metric,
+		NetworkErrorRate: networkErrorRate(),
+		Disks:            s.diskHealth(),
+	})
+	if err != nil {
+		logger.Error("Failed to calculate health score", zap.Error(err))
+		return
 	}
 
-	score.NetworkScore = networkScore
+	s.scoreMu.Lock()
+	s.lastScore = &breakdown
+	s.scoreMu.Unlock()
 
-	// Overall score (weighted average)
-	score.Score = (cpuScore*30 + memoryScore*30 + diskScore*30 + score.NetworkScore*10) / 100
+	score := &models.HealthScore{
+		Timestamp:    metric.Timestamp,
+		Score:        breakdown.Overall,
+		CPUScore:     breakdown.Components[ComponentCPU],
+		MemoryScore:  breakdown.Components[ComponentMemory],
+		DiskScore:    breakdown.Components[ComponentDisk],
+		SMARTScore:   breakdown.Components[ComponentSMART],
+		NetworkScore: breakdown.Components[ComponentNetwork],
+	}
 
 	// Detect issues
-	issues := []string{}
-	if metric.CPUUsage > 90 {
-		issues = append(issues, "High CPU usage")
-	}
-	if metric.MemoryUsage > 90 {
-		issues = append(issues, "High memory usage")
-	}
-	if metric.DiskUsage > 90 {
-		issues = append(issues, "Low disk space")
-	}
-	if len(issues) > 0 {
-		score.Issues = fmt.Sprintf(`["%s"]`, issues[0])
-		for i := 1; i < len(issues); i++ {
-			score.Issues = fmt.Sprintf(`%s,"%s"`, score.Issues[:len(score.Issues)-1], issues[i])
+	if len(breakdown.Deductions) > 0 {
+		issues := make([]string, 0, len(breakdown.Deductions))
+		for _, d := range breakdown.Deductions {
+			issues = append(issues, d.Reason)
+		}
+		if data, err := json.Marshal(issues); err == nil {
+			score.Issues = string(data)
 		}
 	}
 
@@ -411,6 +404,56 @@ func (s *Service) calculateHealthScore(metric *models.SystemMetric) {
 	}
 }
 
+// networkErrorRate returns the errors and drops per 100 packets across all
+// interfaces except loopback
+func networkErrorRate() float64 {
+	netIO, err := net.IOCounters(true)
+	if err != nil {
+		return 0
+	}
+
+	totalPackets := uint64(0)
+	totalErrors := uint64(0)
+	for _, io := range netIO {
+		// Skip loopback
+		if io.Name == "lo" {
+			continue
+		}
+		totalPackets += io.PacketsSent + io.PacketsRecv
+		totalErrors += io.Errin + io.Errout + io.Dropin + io.Dropout
+	}
+	if totalPackets == 0 {
+		return 0
+	}
+	return float64(totalErrors) / float64(totalPackets) * 100
+}
+
+// GetHealthScoreBreakdown returns the components and deductions of the last
+// health score, computing it from the latest metric if none was calculated
+// since startup
+func (s *Service) GetHealthScoreBreakdown(ctx context.Context) (*HealthScore, error) {
+	s.scoreMu.RLock()
+	last := s.lastScore
+	s.scoreMu.RUnlock()
+	if last != nil {
+		return last, nil
+	}
+
+	metric, err := s.GetLatestMetric(ctx)
+	if err != nil {
+		return nil, err
+	}
+	breakdown, err := s.scorer.ComputeScore(LatestMetrics{
+		System:           metric,
+		NetworkErrorRate: networkErrorRate(),
+		Disks:            s.diskHealth(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &breakdown, nil
+}
+
 // cleanupOldMetrics removes metrics older than the retention period
 func (s *Service) cleanupOldMetrics() {
 	metricsCutoff := time.Now().Add(-MetricsRetention)
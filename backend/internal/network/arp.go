@@ -0,0 +1,372 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// arpAlertCooldown is the minimum time between two alerts about one IP,
+	// so an attacker flipping an IP back and forth does not flood the admins
+	arpAlertCooldown = 10 * time.Minute
+	// arpLastSeenInterval is how often the LastSeen of an unchanged entry is
+	// written, as busy hosts answer ARP requests many times a minute
+	arpLastSeenInterval = time.Minute
+
+	etherTypeARP   = 0x0806
+	arpOpReply     = 2
+	arpFrameLength = 14 + 28 // Ethernet header and ARP payload for IPv4
+)
+
+// ErrARPMonitorRunning is returned when starting a monitor twice
+var ErrARPMonitorRunning = errors.New("ARP monitor already running")
+
+// ARPSpoofingAlert reports an IP that moved to another MAC address soon
+// after it was last seen at its known one, or a host claiming an IP of the
+// NAS itself
+type ARPSpoofingAlert struct {
+	IP         string    `json:"ip"`
+	KnownMAC   string    `json:"knownMac"`
+	NewMAC     string    `json:"newMac"`
+	Interface  string    `json:"interface"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// arpPacketSource delivers the Ethernet frames of ARP packets
+type arpPacketSource interface {
+	ReadPacket() ([]byte, error)
+	Close() error
+}
+
+// openARPSource opens a raw socket receiving the ARP packets of an
+// interface. Replaced in tests.
+var openARPSource = openARPSocket
+
+// ARPMonitor watches the ARP replies on the LAN for IPs that suddenly
+// announce another MAC address, the sign of ARP poisoning rerouting NAS
+// traffic through another host
+type ARPMonitor struct {
+	db      *gorm.DB
+	iface   string
+	window  time.Duration
+	publish func(notifications.Notification) error
+
+	mu        sync.Mutex
+	running   bool
+	entries   map[string]*models.ARPEntry
+	whitelist map[string]bool      // "ip mac" pairs
+	localIPs  map[string]string    // IPs of the watched interface to its MAC
+	lastAlert map[string]time.Time // Per IP
+}
+
+// NewARPMonitor creates a monitor for iface, or the interface of the
+// default route if iface is empty. An IP moving to another MAC within
+// window of its last reply raises an alert.
+func NewARPMonitor(db *gorm.DB, iface string, window time.Duration) (*ARPMonitor, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("change window must be positive")
+	}
+	return &ARPMonitor{
+		db:        db,
+		iface:     iface,
+		window:    window,
+		publish:   notifications.Publish,
+		lastAlert: make(map[string]time.Time),
+	}, nil
+}
+
+// Start watches the ARP replies on the interface until ctx is cancelled.
+// It needs root to open the raw socket.
+func (m *ARPMonitor) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running {
+		return ErrARPMonitorRunning
+	}
+	if m.iface == "" {
+		iface, err := defaultRouteInterface()
+		if err != nil {
+			return fmt.Errorf("failed to find the interface of the default route: %w", err)
+		}
+		m.iface = iface
+	}
+	if err := m.load(); err != nil {
+		return err
+	}
+	m.localIPs = interfaceIPv4s(m.iface)
+
+	source, err := openARPSource(m.iface)
+	if err != nil {
+		return fmt.Errorf("failed to open ARP socket on %s: %w", m.iface, err)
+	}
+	m.running = true
+
+	go func() {
+		<-ctx.Done()
+		source.Close()
+	}()
+	go m.run(ctx, source)
+
+	logger.Info("ARP monitor started", zap.String("interface", m.iface), zap.Duration("changeWindow", m.window))
+	return nil
+}
+
+// GetKnownDevices returns the IPs seen on the LAN with their MAC addresses
+func (m *ARPMonitor) GetKnownDevices() ([]models.ARPEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The cache holds LastSeen times not written yet
+	if m.entries != nil {
+		devices := make([]models.ARPEntry, 0, len(m.entries))
+		for _, entry := range m.entries {
+			devices = append(devices, *entry)
+		}
+		sortARPEntries(devices)
+		return devices, nil
+	}
+
+	var devices []models.ARPEntry
+	if err := m.db.Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("failed to list ARP entries: %w", err)
+	}
+	sortARPEntries(devices)
+	return devices, nil
+}
+
+// WhitelistMAC lets ip move to mac without an alert
+func (m *ARPMonitor) WhitelistMAC(ip, mac string) error {
+	parsedIP := net.ParseIP(ip).To4()
+	if parsedIP == nil {
+		return fmt.Errorf("invalid IPv4 address %q", ip)
+	}
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return fmt.Errorf("invalid MAC address %q", mac)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := models.ARPWhitelistEntry{IP: parsedIP.String(), MAC: hw.String()}
+	if err := m.db.Save(&record).Error; err != nil {
+		return fmt.Errorf("failed to save ARP whitelist entry: %w", err)
+	}
+	if m.whitelist != nil {
+		m.whitelist[record.IP+" "+record.MAC] = true
+	}
+
+	logger.Info("MAC whitelisted for IP", zap.String("ip", record.IP), zap.String("mac", record.MAC))
+	return nil
+}
+
+// run reads ARP packets until the source is closed
+func (m *ARPMonitor) run(ctx context.Context, source arpPacketSource) {
+	defer func() {
+		m.mu.Lock()
+		m.running = false
+		m.mu.Unlock()
+	}()
+
+	for {
+		frame, err := source.ReadPacket()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("ARP monitor stopped", zap.String("interface", m.iface), zap.Error(err))
+			}
+			return
+		}
+		ip, mac, ok := parseARPReply(frame)
+		if !ok {
+			continue
+		}
+		if alert := m.observe(ip, mac, time.Now()); alert != nil {
+			m.report(*alert)
+		}
+	}
+}
+
+// observe records that ip announced mac and returns an alert if the move
+// to mac is suspicious
+func (m *ARPMonitor) observe(ip, mac string, now time.Time) *ARPSpoofingAlert {
+	// Address probes of hosts that have no IP yet
+	if ip == "0.0.0.0" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if own, ok := m.localIPs[ip]; ok {
+		if mac == own {
+			return nil
+		}
+		return m.alert(ip, own, mac, now)
+	}
+
+	entry, ok := m.entries[ip]
+	if !ok {
+		entry = &models.ARPEntry{IP: ip, MAC: mac, FirstSeen: now, LastSeen: now}
+		m.entries[ip] = entry
+		m.save(entry)
+		return nil
+	}
+	if entry.MAC == mac {
+		if now.Sub(entry.LastSeen) >= arpLastSeenInterval {
+			entry.LastSeen = now
+			m.save(entry)
+		}
+		return nil
+	}
+
+	// A device replaced since the IP was last seen is not suspicious
+	suspicious := now.Sub(entry.LastSeen) < m.window && !m.whitelist[ip+" "+mac]
+	knownMAC := entry.MAC
+	entry.MAC = mac
+	entry.LastSeen = now
+	entry.ChangeCount++
+	m.save(entry)
+
+	logger.Info("IP moved to another MAC address",
+		zap.String("ip", ip), zap.String("from", knownMAC), zap.String("to", mac), zap.Bool("suspicious", suspicious))
+	if !suspicious {
+		return nil
+	}
+	return m.alert(ip, knownMAC, mac, now)
+}
+
+// alert returns an alert about ip unless one was raised within
+// arpAlertCooldown
+func (m *ARPMonitor) alert(ip, knownMAC, newMAC string, now time.Time) *ARPSpoofingAlert {
+	if last, ok := m.lastAlert[ip]; ok && now.Sub(last) < arpAlertCooldown {
+		return nil
+	}
+	m.lastAlert[ip] = now
+	return &ARPSpoofingAlert{IP: ip, KnownMAC: knownMAC, NewMAC: newMAC, Interface: m.iface, DetectedAt: now}
+}
+
+func (m *ARPMonitor) report(alert ARPSpoofingAlert) {
+	logger.Warn("Possible ARP spoofing",
+		zap.String("ip", alert.IP), zap.String("knownMac", alert.KnownMAC), zap.String("newMac", alert.NewMAC))
+	err := m.publish(notifications.Notification{
+		Type:     models.AlertTypeARPSpoofing,
+		Title:    fmt.Sprintf("Possible ARP spoofing of %s", alert.IP),
+		Body:     fmt.Sprintf("%s was announced by %s on %s instead of %s. Another host may be intercepting its traffic; whitelist the MAC if the move is expected.", alert.IP, alert.NewMAC, alert.Interface, alert.KnownMAC),
+		Severity: notifications.SeverityCritical,
+		Source:   "network",
+		Metadata: map[string]interface{}{"ip": alert.IP, "knownMac": alert.KnownMAC, "newMac": alert.NewMAC, "interface": alert.Interface},
+	})
+	if err != nil {
+		logger.Warn("Failed to publish ARP spoofing notification", zap.Error(err))
+	}
+}
+
+// load reads the known entries and the whitelist into the cache
+func (m *ARPMonitor) load() error {
+	var entries []models.ARPEntry
+	if err := m.db.Find(&entries).Error; err != nil {
+		return fmt.Errorf("failed to load ARP entries: %w", err)
+	}
+	var whitelist []models.ARPWhitelistEntry
+	if err := m.db.Find(&whitelist).Error; err != nil {
+		return fmt.Errorf("failed to load ARP whitelist: %w", err)
+	}
+
+	m.entries = make(map[string]*models.ARPEntry, len(entries))
+	for i := range entries {
+		m.entries[entries[i].IP] = &entries[i]
+	}
+	m.whitelist = make(map[string]bool, len(whitelist))
+	for _, w := range whitelist {
+		m.whitelist[w.IP+" "+w.MAC] = true
+	}
+	return nil
+}
+
+func (m *ARPMonitor) save(entry *models.ARPEntry) {
+	if err := m.db.Save(entry).Error; err != nil {
+		logger.Warn("Failed to save ARP entry", zap.String("ip", entry.IP), zap.Error(err))
+	}
+}
+
+// parseARPReply returns the sender IP and MAC of an Ethernet frame holding
+// an IPv4 ARP reply
+func parseARPReply(frame []byte) (ip, mac string, ok bool) {
+	if len(frame) < arpFrameLength || binary.BigEndian.Uint16(frame[12:14]) != etherTypeARP {
+		return "", "", false
+	}
+	arp := frame[14:]
+	hardwareType := binary.BigEndian.Uint16(arp[0:2])
+	protocolType := binary.BigEndian.Uint16(arp[2:4])
+	if hardwareType != 1 || protocolType != 0x0800 || arp[4] != 6 || arp[5] != 4 {
+		return "", "", false
+	}
+	if binary.BigEndian.Uint16(arp[6:8]) != arpOpReply {
+		return "", "", false
+	}
+	return net.IP(arp[14:18]).String(), net.HardwareAddr(arp[8:14]).String(), true
+}
+
+// interfaceIPv4s returns the IPv4 addresses of an interface mapped to its
+// MAC address
+func interfaceIPv4s(name string) map[string]string {
+	ips := make(map[string]string)
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return ips
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return ips
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			ips[ipNet.IP.String()] = iface.HardwareAddr.String()
+		}
+	}
+	return ips
+}
+
+// defaultRouteInterface returns the interface of the IPv4 default route
+func defaultRouteInterface() (string, error) {
+	output, err := runCommand("ip", "-4", "route", "show", "default")
+	if err != nil {
+		return "", err
+	}
+	// Parse: default via <gateway> dev <iface> ...
+	fields := strings.Fields(output)
+	for i, field := range fields {
+		if field == "dev" && i+1 < len(fields) {
+			return fields[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("no default route")
+}
+
+// sortARPEntries sorts entries by IP numerically
+func sortARPEntries(entries []models.ARPEntry) {
+	key := func(ip string) uint32 {
+		if v4 := net.ParseIP(ip).To4(); v4 != nil {
+			return binary.BigEndian.Uint32(v4)
+		}
+		return 0
+	}
+	sort.Slice(entries, func(i, j int) bool { return key(entries[i].IP) < key(entries[j].IP) })
+}
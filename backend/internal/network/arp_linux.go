@@ -0,0 +1,83 @@
+//go:build linux
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// arpSocketTimeout bounds a blocking read, so a closed socket is noticed
+const arpSocketTimeout = 1 // Second
+
+// arpSocket is an AF_PACKET socket receiving the ARP packets of one
+// interface
+type arpSocket struct {
+	fd        int
+	closed    atomic.Bool
+	closeOnce sync.Once
+}
+
+func openARPSocket(iface string) (arpPacketSource, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := htons(etherTypeARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(protocol))
+	if err != nil {
+		return nil, fmt.Errorf("socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: protocol, Ifindex: ifi.Index}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("bind: %w", err)
+	}
+	timeout := syscall.Timeval{Sec: arpSocketTimeout}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("setsockopt: %w", err)
+	}
+	return &arpSocket{fd: fd}, nil
+}
+
+// ReadPacket returns the next frame received by the interface. Frames
+// the NAS sent itself are skipped.
+func (s *arpSocket) ReadPacket() ([]byte, error) {
+	buf := make([]byte, 1514)
+	for {
+		if s.closed.Load() {
+			s.closeOnce.Do(func() { syscall.Close(s.fd) })
+			return nil, net.ErrClosed
+		}
+		n, from, err := syscall.Recvfrom(s.fd, buf, 0)
+		if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			s.closeOnce.Do(func() { syscall.Close(s.fd) })
+			return nil, err
+		}
+		if ll, ok := from.(*syscall.SockaddrLinklayer); ok && ll.Pkttype == syscall.PACKET_OUTGOING {
+			continue
+		}
+		return buf[:n], nil
+	}
+}
+
+// Close stops ReadPacket within a second. The socket is released by
+// ReadPacket, as closing it under a blocked read does not wake the read.
+func (s *arpSocket) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+// htons converts a port or protocol number to network byte order on the
+// little-endian hosts the NAS runs on
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
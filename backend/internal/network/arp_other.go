@@ -0,0 +1,9 @@
+//go:build !linux
+
+package network
+
+import "fmt"
+
+func openARPSocket(iface string) (arpPacketSource, error) {
+	return nil, fmt.Errorf("ARP monitoring is only supported on Linux")
+}
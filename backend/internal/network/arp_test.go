@@ -0,0 +1,220 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// fakeARPSource delivers frames, then blocks until closed. drained is
+// closed once all frames were read.
+type fakeARPSource struct {
+	frames  chan []byte
+	drained chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newFakeARPSource(frames ...[]byte) *fakeARPSource {
+	s := &fakeARPSource{
+		frames:  make(chan []byte, len(frames)),
+		drained: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	for _, frame := range frames {
+		s.frames <- frame
+	}
+	close(s.frames)
+	return s
+}
+
+func (s *fakeARPSource) ReadPacket() ([]byte, error) {
+	if frame, ok := <-s.frames; ok {
+		return frame, nil
+	}
+	// The monitor is done with the last frame once it asks for the next
+	close(s.drained)
+	<-s.closed
+	return nil, net.ErrClosed
+}
+
+func (s *fakeARPSource) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+// arpFrame builds an Ethernet frame with an ARP packet announcing that
+// ip is at mac
+func arpFrame(op uint16, ip, mac string) []byte {
+	hw, _ := net.ParseMAC(mac)
+	frame := make([]byte, arpFrameLength)
+	copy(frame[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], hw)
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeARP)
+	arp := frame[14:]
+	binary.BigEndian.PutUint16(arp[0:2], 1)
+	binary.BigEndian.PutUint16(arp[2:4], 0x0800)
+	arp[4], arp[5] = 6, 4
+	binary.BigEndian.PutUint16(arp[6:8], op)
+	copy(arp[8:14], hw)
+	copy(arp[14:18], net.ParseIP(ip).To4())
+	copy(arp[24:28], net.ParseIP("192.168.1.10").To4())
+	return frame
+}
+
+func newTestARPMonitor(t *testing.T) (*ARPMonitor, *gorm.DB, *[]notifications.Notification) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "arp.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ARPEntry{}, &models.ARPWhitelistEntry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	m, err := NewARPMonitor(db, "eth-test", time.Hour)
+	if err != nil {
+		t.Fatalf("NewARPMonitor: %v", err)
+	}
+	var published []notifications.Notification
+	m.publish = func(n notifications.Notification) error {
+		published = append(published, n)
+		return nil
+	}
+	return m, db, &published
+}
+
+func TestARPMonitorDetectsSpoofing(t *testing.T) {
+	m, db, published := newTestARPMonitor(t)
+
+	recent := time.Now().Add(-time.Minute)
+	db.Create(&[]models.ARPEntry{
+		{IP: "192.168.1.1", MAC: "00:11:22:33:44:01", FirstSeen: recent, LastSeen: recent},
+		{IP: "192.168.1.2", MAC: "00:11:22:33:44:02", FirstSeen: recent, LastSeen: recent},
+		{IP: "192.168.1.50", MAC: "00:11:22:33:44:50", FirstSeen: recent, LastSeen: time.Now().Add(-48 * time.Hour)},
+	})
+	// The failover partner of the NAS at .2
+	if err := m.WhitelistMAC("192.168.1.2", "00:11:22:33:44:F2"); err != nil {
+		t.Fatalf("WhitelistMAC: %v", err)
+	}
+
+	source := newFakeARPSource(
+		arpFrame(arpOpReply, "192.168.1.1", "de:ad:be:ef:00:01"),  // Poisoning the gateway
+		arpFrame(arpOpReply, "192.168.1.1", "00:11:22:33:44:01"),  // The gateway answers again
+		arpFrame(arpOpReply, "192.168.1.2", "00:11:22:33:44:f2"),  // Whitelisted failover
+		arpFrame(arpOpReply, "192.168.1.50", "00:11:22:33:44:51"), // Replaced device
+		arpFrame(arpOpReply, "192.168.1.60", "00:11:22:33:44:60"), // New device
+		arpFrame(1, "192.168.1.70", "de:ad:be:ef:00:01"),          // Requests are ignored
+		arpFrame(arpOpReply, "0.0.0.0", "de:ad:be:ef:00:02"),      // Probe
+		[]byte{0x01, 0x02},
+	)
+	real := openARPSource
+	openARPSource = func(iface string) (arpPacketSource, error) { return source, nil }
+	t.Cleanup(func() { openARPSource = real })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := m.Start(ctx); err != ErrARPMonitorRunning {
+		t.Errorf("second Start: %v, want ErrARPMonitorRunning", err)
+	}
+	select {
+	case <-source.drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("frames not processed")
+	}
+
+	// Flipping back within the cooldown raises no second alert
+	if len(*published) != 1 {
+		t.Fatalf("published %d notifications, want 1: %+v", len(*published), *published)
+	}
+	n := (*published)[0]
+	wantMetadata := map[string]interface{}{"ip": "192.168.1.1", "knownMac": "00:11:22:33:44:01", "newMac": "de:ad:be:ef:00:01", "interface": "eth-test"}
+	if n.Type != models.AlertTypeARPSpoofing || n.Severity != notifications.SeverityCritical || !reflect.DeepEqual(n.Metadata, wantMetadata) {
+		t.Errorf("notification = %+v", n)
+	}
+
+	devices, err := m.GetKnownDevices()
+	if err != nil {
+		t.Fatalf("GetKnownDevices: %v", err)
+	}
+	got := map[string]string{}
+	changes := map[string]int{}
+	for _, d := range devices {
+		got[d.IP] = d.MAC
+		changes[d.IP] = d.ChangeCount
+	}
+	wantMACs := map[string]string{
+		"192.168.1.1":  "00:11:22:33:44:01",
+		"192.168.1.2":  "00:11:22:33:44:f2",
+		"192.168.1.50": "00:11:22:33:44:51",
+		"192.168.1.60": "00:11:22:33:44:60",
+	}
+	if !reflect.DeepEqual(got, wantMACs) {
+		t.Errorf("devices = %v, want %v", got, wantMACs)
+	}
+	if changes["192.168.1.1"] != 2 || changes["192.168.1.60"] != 0 {
+		t.Errorf("change counts = %v", changes)
+	}
+	if devices[0].IP != "192.168.1.1" || devices[3].IP != "192.168.1.60" {
+		t.Errorf("devices not sorted by IP: %+v", devices)
+	}
+
+	var stored models.ARPEntry
+	db.First(&stored, "ip = ?", "192.168.1.60")
+	if stored.MAC != "00:11:22:33:44:60" {
+		t.Errorf("new device not stored: %+v", stored)
+	}
+}
+
+func TestARPMonitorOwnIP(t *testing.T) {
+	m, _, _ := newTestARPMonitor(t)
+	if err := m.load(); err != nil {
+		t.Fatal(err)
+	}
+	m.localIPs = map[string]string{"192.168.1.10": "52:54:00:aa:bb:cc"}
+	now := time.Now()
+
+	if alert := m.observe("192.168.1.10", "52:54:00:aa:bb:cc", now); alert != nil {
+		t.Errorf("alert about the NAS's own reply: %+v", alert)
+	}
+	alert := m.observe("192.168.1.10", "de:ad:be:ef:00:01", now)
+	if alert == nil || alert.KnownMAC != "52:54:00:aa:bb:cc" || alert.NewMAC != "de:ad:be:ef:00:01" {
+		t.Errorf("alert = %+v, want one about the NAS's IP", alert)
+	}
+	if alert := m.observe("192.168.1.10", "de:ad:be:ef:00:01", now.Add(arpAlertCooldown)); alert == nil {
+		t.Error("no alert after the cooldown")
+	}
+}
+
+func TestWhitelistMACValidates(t *testing.T) {
+	m, _, _ := newTestARPMonitor(t)
+	for _, tt := range []struct{ ip, mac string }{
+		{"fe80::1", "00:11:22:33:44:55"},
+		{"192.168.1.300", "00:11:22:33:44:55"},
+		{"192.168.1.2", "00:11:22:33:44"},
+		{"192.168.1.2", "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01"},
+	} {
+		if err := m.WhitelistMAC(tt.ip, tt.mac); err == nil {
+			t.Errorf("WhitelistMAC(%q, %q) succeeded", tt.ip, tt.mac)
+		}
+	}
+}
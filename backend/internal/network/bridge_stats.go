@@ -0,0 +1,263 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrBridgeNotFound is returned for interfaces that are not bridges
+var ErrBridgeNotFound = errors.New("bridge not found")
+
+// stpStates names the values of /sys/class/net/$bridge/brif/$port/state
+var stpStates = map[string]string{
+	"0": "disabled",
+	"1": "listening",
+	"2": "learning",
+	"3": "forwarding",
+	"4": "blocking",
+}
+
+// BridgeStats are the counters of a bridge and its ports
+type BridgeStats struct {
+	Name      string            `json:"name"`
+	RxBytes   uint64            `json:"rxBytes"`
+	TxBytes   uint64            `json:"txBytes"`
+	RxPackets uint64            `json:"rxPackets"`
+	TxPackets uint64            `json:"txPackets"`
+	RxErrors  uint64            `json:"rxErrors"`
+	TxErrors  uint64            `json:"txErrors"`
+	RxDropped uint64            `json:"rxDropped"`
+	TxDropped uint64            `json:"txDropped"`
+	Ports     []BridgePortStats `json:"ports"`
+}
+
+// BridgePortStats are the STP state and counters of a bridge port
+type BridgePortStats struct {
+	Name      string `json:"name"`
+	PortNo    int    `json:"portNo"`
+	State     string `json:"state"` // disabled, listening, learning, forwarding, blocking
+	RxBytes   uint64 `json:"rxBytes"`
+	TxBytes   uint64 `json:"txBytes"`
+	RxPackets uint64 `json:"rxPackets"`
+	TxPackets uint64 `json:"txPackets"`
+}
+
+// FDBEntry is an entry of a bridge's forwarding database
+type FDBEntry struct {
+	MAC        string  `json:"mac"`
+	Port       string  `json:"port"`
+	IsLocal    bool    `json:"isLocal"`  // Address of the bridge or one of its ports
+	IsStatic   bool    `json:"isStatic"` // Not aged out
+	AgeSeconds float64 `json:"ageSeconds"`
+}
+
+// BridgeStatistics reads bridge counters from sysfs and forwarding tables
+// from brctl or bridge
+type BridgeStatistics struct {
+	sysfsRoot string
+}
+
+// NewBridgeStatistics creates a reader of bridge statistics
+func NewBridgeStatistics() *BridgeStatistics {
+	return &BridgeStatistics{sysfsRoot: "/sys/class/net"}
+}
+
+// GetStats returns the counters of a bridge and the STP state and counters of its ports
+func (b *BridgeStatistics) GetStats(bridgeName string) (*BridgeStats, error) {
+	if err := b.checkBridge(bridgeName); err != nil {
+		return nil, err
+	}
+
+	bridgeDir := filepath.Join(b.sysfsRoot, bridgeName)
+	stats := &BridgeStats{
+		Name:      bridgeName,
+		RxBytes:   readCounter(bridgeDir, "rx_bytes"),
+		TxBytes:   readCounter(bridgeDir, "tx_bytes"),
+		RxPackets: readCounter(bridgeDir, "rx_packets"),
+		TxPackets: readCounter(bridgeDir, "tx_packets"),
+		RxErrors:  readCounter(bridgeDir, "rx_errors"),
+		TxErrors:  readCounter(bridgeDir, "tx_errors"),
+		RxDropped: readCounter(bridgeDir, "rx_dropped"),
+		TxDropped: readCounter(bridgeDir, "tx_dropped"),
+		Ports:     []BridgePortStats{},
+	}
+
+	ports, err := b.ports(bridgeName)
+	if err != nil {
+		return nil, err
+	}
+	for _, port := range ports {
+		portDir := filepath.Join(b.sysfsRoot, port.Name)
+		port.RxBytes = readCounter(portDir, "rx_bytes")
+		port.TxBytes = readCounter(portDir, "tx_bytes")
+		port.RxPackets = readCounter(portDir, "rx_packets")
+		port.TxPackets = readCounter(portDir, "tx_packets")
+		stats.Ports = append(stats.Ports, port)
+	}
+
+	return stats, nil
+}
+
+// GetForwardingTable returns the forwarding database of a bridge, read with
+// brctl showmacs or, without bridge-utils, bridge fdb show
+func (b *BridgeStatistics) GetForwardingTable(bridgeName string) ([]FDBEntry, error) {
+	if err := b.checkBridge(bridgeName); err != nil {
+		return nil, err
+	}
+
+	if hostShell.CommandExists("brctl") {
+		output, err := runCommand("brctl", "showmacs", bridgeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read forwarding table: %s", output)
+		}
+
+		ports, err := b.ports(bridgeName)
+		if err != nil {
+			return nil, err
+		}
+		portNames := make(map[int]string, len(ports))
+		for _, port := range ports {
+			portNames[port.PortNo] = port.Name
+		}
+		return ParseShowmacs([]byte(output), portNames), nil
+	}
+
+	output, err := runCommand("bridge", "-s", "fdb", "show", "br", bridgeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read forwarding table: %s", output)
+	}
+	return ParseBridgeFDB([]byte(output), bridgeName), nil
+}
+
+// ParseShowmacs parses brctl showmacs output. portNames maps port numbers
+// to interface names; unknown ports keep their number.
+func ParseShowmacs(output []byte, portNames map[int]string) []FDBEntry {
+	entries := []FDBEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// Format: port no  mac addr  is local?  ageing timer
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		portNo, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue // Header
+		}
+
+		port, ok := portNames[portNo]
+		if !ok {
+			port = fields[0]
+		}
+		age, _ := strconv.ParseFloat(fields[3], 64)
+		isLocal := fields[2] == "yes"
+		entries = append(entries, FDBEntry{
+			MAC:        fields[1],
+			Port:       port,
+			IsLocal:    isLocal,
+			IsStatic:   isLocal, // Local entries never age out
+			AgeSeconds: age,
+		})
+	}
+	return entries
+}
+
+// ParseBridgeFDB parses bridge -s fdb show output. Entries of the bridge
+// device itself are reported with the bridge as their port.
+func ParseBridgeFDB(output []byte, bridgeName string) []FDBEntry {
+	entries := []FDBEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// Format: 52:54:00:12:34:56 dev eth0 vlan 1 used 5/10 master br0 permanent
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		entry := FDBEntry{MAC: fields[0], Port: bridgeName}
+		for i := 1; i < len(fields); i++ {
+			switch fields[i] {
+			case "dev":
+				if i+1 < len(fields) {
+					entry.Port = fields[i+1]
+					i++
+				}
+			case "used":
+				// used <seconds since used>/<seconds since updated>
+				if i+1 < len(fields) {
+					if _, updated, ok := strings.Cut(fields[i+1], "/"); ok {
+						entry.AgeSeconds, _ = strconv.ParseFloat(updated, 64)
+					}
+					i++
+				}
+			case "permanent":
+				entry.IsLocal = true
+				entry.IsStatic = true
+			case "static":
+				entry.IsStatic = true
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// checkBridge fails if bridgeName is not a bridge
+func (b *BridgeStatistics) checkBridge(bridgeName string) error {
+	if bridgeName == "" || strings.ContainsAny(bridgeName, "/\x00") || bridgeName == "." || bridgeName == ".." {
+		return fmt.Errorf("invalid bridge name: %s", bridgeName)
+	}
+	if _, err := os.Stat(filepath.Join(b.sysfsRoot, bridgeName, "bridge")); err != nil {
+		return fmt.Errorf("%w: %s", ErrBridgeNotFound, bridgeName)
+	}
+	return nil
+}
+
+// ports returns the ports of a bridge with their number and STP state, sorted by port number
+func (b *BridgeStatistics) ports(bridgeName string) ([]BridgePortStats, error) {
+	brif := filepath.Join(b.sysfsRoot, bridgeName, "brif")
+	dirEntries, err := os.ReadDir(brif)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list bridge ports: %w", err)
+	}
+
+	ports := make([]BridgePortStats, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		port := BridgePortStats{Name: entry.Name(), State: "unknown"}
+		if data, err := os.ReadFile(filepath.Join(brif, entry.Name(), "state")); err == nil {
+			if state, ok := stpStates[strings.TrimSpace(string(data))]; ok {
+				port.State = state
+			}
+		}
+		if data, err := os.ReadFile(filepath.Join(brif, entry.Name(), "port_no")); err == nil {
+			// port_no is hexadecimal, e.g. 0x1
+			if portNo, err := strconv.ParseInt(strings.TrimSpace(string(data)), 0, 32); err == nil {
+				port.PortNo = int(portNo)
+			}
+		}
+		ports = append(ports, port)
+	}
+
+	sort.Slice(ports, func(i, j int) bool { return ports[i].PortNo < ports[j].PortNo })
+	return ports, nil
+}
+
+// readCounter reads a counter of the statistics directory of an interface
+func readCounter(ifaceDir, name string) uint64 {
+	data, err := os.ReadFile(filepath.Join(ifaceDir, "statistics", name))
+	if err != nil {
+		return 0
+	}
+	value, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return value
+}
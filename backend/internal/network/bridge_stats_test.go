@@ -0,0 +1,94 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// brctl showmacs br0 output
+const showmacsOutput = `port no	mac addr		is local?	ageing timer
+  1	52:54:00:12:34:56	yes		   0.00
+  1	52:54:00:ab:cd:ef	no		  12.34
+  2	52:54:00:aa:bb:cc	yes		   0.00
+  3	de:ad:be:ef:00:01	no		 287.10
+`
+
+func TestParseShowmacs(t *testing.T) {
+	entries := ParseShowmacs([]byte(showmacsOutput), map[int]string{1: "eth0", 2: "vnet0"})
+	want := []FDBEntry{
+		{MAC: "52:54:00:12:34:56", Port: "eth0", IsLocal: true, IsStatic: true},
+		{MAC: "52:54:00:ab:cd:ef", Port: "eth0", AgeSeconds: 12.34},
+		{MAC: "52:54:00:aa:bb:cc", Port: "vnet0", IsLocal: true, IsStatic: true},
+		{MAC: "de:ad:be:ef:00:01", Port: "3", AgeSeconds: 287.10},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseShowmacs =\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestParseBridgeFDB(t *testing.T) {
+	output := `52:54:00:ab:cd:ef dev eth0 used 3/45 master br0
+52:54:00:12:34:56 dev eth0 vlan 1 master br0 permanent
+02:00:00:00:00:01 dev vnet0 master br0 static
+33:33:00:00:00:01 dev br0 self permanent
+`
+	entries := ParseBridgeFDB([]byte(output), "br0")
+	want := []FDBEntry{
+		{MAC: "52:54:00:ab:cd:ef", Port: "eth0", AgeSeconds: 45},
+		{MAC: "52:54:00:12:34:56", Port: "eth0", IsLocal: true, IsStatic: true},
+		{MAC: "02:00:00:00:00:01", Port: "vnet0", IsStatic: true},
+		{MAC: "33:33:00:00:00:01", Port: "br0", IsLocal: true, IsStatic: true},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseBridgeFDB =\n%+v\nwant\n%+v", entries, want)
+	}
+}
+
+func TestBridgeGetStats(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, value string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("br0/bridge/stp_state", "1")
+	write("br0/statistics/rx_bytes", "1048576")
+	write("br0/statistics/tx_packets", "900")
+	write("br0/brif/eth0/state", "3")
+	write("br0/brif/eth0/port_no", "0x2")
+	write("br0/brif/vnet0/state", "4")
+	write("br0/brif/vnet0/port_no", "0x1")
+	write("eth0/statistics/rx_bytes", "5000")
+	write("vnet0/statistics/tx_packets", "42")
+	write("eth0/statistics/rx_packets", "not a number")
+
+	b := &BridgeStatistics{sysfsRoot: root}
+	stats, err := b.GetStats("br0")
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.RxBytes != 1048576 || stats.TxPackets != 900 || len(stats.Ports) != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	want := []BridgePortStats{
+		{Name: "vnet0", PortNo: 1, State: "blocking", TxPackets: 42},
+		{Name: "eth0", PortNo: 2, State: "forwarding", RxBytes: 5000},
+	}
+	if !reflect.DeepEqual(stats.Ports, want) {
+		t.Errorf("ports = %+v", stats.Ports)
+	}
+
+	if _, err := b.GetStats("eth0"); err == nil {
+		t.Error("GetStats accepted an interface that is not a bridge")
+	}
+	if _, err := b.GetStats("../br0"); err == nil {
+		t.Error("GetStats accepted a path")
+	}
+}
@@ -0,0 +1,377 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultDDNSInterval is the update interval if none is configured
+	defaultDDNSInterval = 5
+
+	// ddnsUpdateRetries is how often a record update is tried before the
+	// failure is alerted
+	ddnsUpdateRetries = 3
+
+	// publicIPURL returns the public IPv4 address of the caller as plain text
+	publicIPURL = "https://api.ipify.org"
+)
+
+// DynamicDNSConfig configures the dynamic DNS hostname of the NAS
+type DynamicDNSConfig struct {
+	Enabled               bool   `json:"enabled"`
+	Provider              string `json:"provider"` // cloudflare, namecheap, dyndns
+	Domain                string `json:"domain"`
+	Subdomain             string `json:"subdomain"`               // Empty or @ for the domain itself
+	Credential            string `json:"credential,omitempty"`    // API token (cloudflare), password (namecheap) or username:password (dyndns)
+	UpdateIntervalMinutes int    `json:"updateIntervalMinutes"`   // Defaults to 5
+	HasCredential         bool   `json:"hasCredential,omitempty"` // Set in responses instead of the credential
+}
+
+// Hostname returns the fully qualified hostname of the record
+func (c DynamicDNSConfig) Hostname() string {
+	if host := c.host(); host != "@" {
+		return host + "." + c.Domain
+	}
+	return c.Domain
+}
+
+func (c DynamicDNSConfig) host() string {
+	if c.Subdomain == "" {
+		return "@"
+	}
+	return c.Subdomain
+}
+
+// Validate checks the configuration and applies defaults
+func (c *DynamicDNSConfig) Validate() error {
+	switch c.Provider {
+	case DDNSProviderCloudflare, DDNSProviderNamecheap, DDNSProviderDynDNS:
+	default:
+		return fmt.Errorf("unsupported dynamic DNS provider %q", c.Provider)
+	}
+	if c.Domain == "" {
+		return fmt.Errorf("domain is required")
+	}
+	if c.UpdateIntervalMinutes == 0 {
+		c.UpdateIntervalMinutes = defaultDDNSInterval
+	}
+	if c.UpdateIntervalMinutes < 1 || c.UpdateIntervalMinutes > 1440 {
+		return fmt.Errorf("update interval must be between 1 and 1440 minutes")
+	}
+	return nil
+}
+
+// DDNSStatus is the dynamic DNS configuration, without the credential, and
+// the state of the record
+type DDNSStatus struct {
+	Config DynamicDNSConfig `json:"config"`
+	State  models.DDNSState `json:"state"`
+}
+
+// DynamicDNSUpdater keeps the dynamic DNS record pointed at the public IP of
+// the NAS. It checks the public IP every update interval and updates the
+// record when the IP changes, alerting when the update keeps failing.
+type DynamicDNSUpdater struct {
+	db          *gorm.DB
+	client      *http.Client
+	ipURL       string
+	retryDelay  time.Duration
+	newProvider func(cfg DynamicDNSConfig) (DDNSProvider, error)
+	onFailure   func(hostname, ip string, attempts int, err error)
+
+	mu     sync.Mutex
+	reload chan struct{}
+}
+
+var (
+	ddnsUpdater   *DynamicDNSUpdater
+	ddnsUpdaterMu sync.RWMutex
+)
+
+// NewDynamicDNSUpdater creates an updater using the public IP service and
+// provider APIs
+func NewDynamicDNSUpdater(db *gorm.DB) *DynamicDNSUpdater {
+	u := &DynamicDNSUpdater{
+		db:         db,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		ipURL:      publicIPURL,
+		retryDelay: 10 * time.Second,
+		onFailure:  alertDDNSFailure,
+		reload:     make(chan struct{}, 1),
+	}
+	u.newProvider = func(cfg DynamicDNSConfig) (DDNSProvider, error) {
+		return NewDDNSProvider(cfg, u.client)
+	}
+	return u
+}
+
+func alertDDNSFailure(hostname, ip string, attempts int, err error) {
+	service := alerts.GetService()
+	if service == nil {
+		return
+	}
+	if alertErr := service.SendDDNSFailureAlert(context.Background(), hostname, ip, attempts, err.Error()); alertErr != nil {
+		logger.Warn("Failed to send dynamic DNS alert", zap.Error(alertErr))
+	}
+}
+
+// GetDynamicDNSUpdater returns the running dynamic DNS updater, or nil
+func GetDynamicDNSUpdater() *DynamicDNSUpdater {
+	ddnsUpdaterMu.RLock()
+	defer ddnsUpdaterMu.RUnlock()
+	return ddnsUpdater
+}
+
+// Start checks the public IP every update interval until ctx is cancelled
+// and makes the updater available through GetDynamicDNSUpdater
+func (u *DynamicDNSUpdater) Start(ctx context.Context) error {
+	if _, err := u.loadConfig(); err != nil {
+		return err
+	}
+
+	ddnsUpdaterMu.Lock()
+	ddnsUpdater = u
+	ddnsUpdaterMu.Unlock()
+
+	go func() {
+		for {
+			cfg, err := u.loadConfig()
+			interval := defaultDDNSInterval
+			if err != nil {
+				logger.Warn("Failed to load dynamic DNS config", zap.Error(err))
+			} else {
+				if cfg.UpdateIntervalMinutes > 0 {
+					interval = cfg.UpdateIntervalMinutes
+				}
+				if err := u.Check(ctx); err != nil {
+					logger.Warn("Dynamic DNS check failed", zap.Error(err))
+				}
+			}
+
+			timer := time.NewTimer(time.Duration(interval) * time.Minute)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-u.reload:
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Check updates the record if the public IP changed since the last
+// successful update. It does nothing while dynamic DNS is disabled.
+func (u *DynamicDNSUpdater) Check(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	cfg, err := u.loadConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	state, err := u.loadState()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	state.LastCheck = &now
+
+	ip, err := u.detectIP(ctx)
+	if err != nil {
+		state.LastError = err.Error()
+		u.db.Save(state)
+		return err
+	}
+
+	hostname := cfg.Hostname()
+	if ip == state.CurrentIP && hostname == state.Hostname && state.LastError == "" {
+		state.DetectedIP = ip
+		return u.db.Save(state).Error
+	}
+
+	provider, err := u.newProvider(*cfg)
+	if err == nil {
+		attempt := 0
+		_, err = sysutil.RetryWithBackoff(ctx, sysutil.RetryOptions{
+			MaxAttempts:  ddnsUpdateRetries,
+			InitialDelay: u.retryDelay,
+			Multiplier:   1,
+		}, func() (struct{}, error) {
+			attempt++
+			err := provider.Update(ip)
+			if err != nil {
+				logger.Warn("Dynamic DNS update failed",
+					zap.String("hostname", hostname),
+					zap.String("ip", ip),
+					zap.Int("attempt", attempt),
+					zap.Error(err))
+			}
+			return struct{}{}, err
+		})
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			return err
+		}
+	}
+
+	if err != nil {
+		// Alert once per IP and hostname, not on every check
+		alreadyAlerted := state.LastError != "" && state.DetectedIP == ip && state.Hostname == hostname
+		state.Hostname = hostname
+		state.DetectedIP = ip
+		state.LastError = err.Error()
+		state.FailedAttempts += ddnsUpdateRetries
+		if saveErr := u.db.Save(state).Error; saveErr != nil {
+			logger.Error("Failed to save dynamic DNS state", zap.Error(saveErr))
+		}
+		if !alreadyAlerted {
+			u.onFailure(hostname, ip, ddnsUpdateRetries, err)
+		}
+		return fmt.Errorf("failed to update %s to %s: %w", hostname, ip, err)
+	}
+
+	logger.Info("Dynamic DNS record updated",
+		zap.String("hostname", hostname),
+		zap.String("previousIp", state.CurrentIP),
+		zap.String("ip", ip))
+	state.Hostname = hostname
+	state.CurrentIP = ip
+	state.DetectedIP = ip
+	state.LastUpdate = &now
+	state.LastError = ""
+	state.FailedAttempts = 0
+	return u.db.Save(state).Error
+}
+
+// detectIP returns the public IPv4 address reported by the IP service
+func (u *DynamicDNSUpdater) detectIP(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.ipURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect public IP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to detect public IP: status %d", resp.StatusCode)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("IP service returned an invalid address %q", strings.TrimSpace(string(body)))
+	}
+	return ip.String(), nil
+}
+
+// Status returns the configuration and state of the record
+func (u *DynamicDNSUpdater) Status() (*DDNSStatus, error) {
+	cfg, err := u.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	state, err := u.loadState()
+	if err != nil {
+		return nil, err
+	}
+	cfg.HasCredential = cfg.Credential != ""
+	cfg.Credential = ""
+	return &DDNSStatus{Config: *cfg, State: *state}, nil
+}
+
+// SaveConfig validates and stores the configuration and checks the public IP
+// with it. Without a credential the stored credential is kept. The
+// provider's credentials are verified when enabling.
+func (u *DynamicDNSUpdater) SaveConfig(cfg DynamicDNSConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var model models.DDNSConfig
+	err := u.db.First(&model).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load dynamic DNS config: %w", err)
+	}
+	if cfg.Credential == "" {
+		cfg.Credential = model.Credential
+	}
+
+	if cfg.Enabled {
+		if cfg.Credential == "" {
+			return fmt.Errorf("credential is required")
+		}
+		provider, err := u.newProvider(cfg)
+		if err != nil {
+			return err
+		}
+		if err := provider.Verify(); err != nil {
+			return fmt.Errorf("provider rejected the credentials: %w", err)
+		}
+	}
+
+	model.Enabled = cfg.Enabled
+	model.Provider = cfg.Provider
+	model.Domain = cfg.Domain
+	model.Subdomain = cfg.Subdomain
+	model.Credential = cfg.Credential
+	model.UpdateIntervalMinutes = cfg.UpdateIntervalMinutes
+	if err := u.db.Save(&model).Error; err != nil {
+		return fmt.Errorf("failed to save dynamic DNS config: %w", err)
+	}
+
+	// Apply the new interval and hostname right away
+	select {
+	case u.reload <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (u *DynamicDNSUpdater) loadConfig() (*DynamicDNSConfig, error) {
+	var model models.DDNSConfig
+	if err := u.db.First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &DynamicDNSConfig{UpdateIntervalMinutes: defaultDDNSInterval}, nil
+		}
+		return nil, fmt.Errorf("failed to load dynamic DNS config: %w", err)
+	}
+	return &DynamicDNSConfig{
+		Enabled:               model.Enabled,
+		Provider:              model.Provider,
+		Domain:                model.Domain,
+		Subdomain:             model.Subdomain,
+		Credential:            model.Credential,
+		UpdateIntervalMinutes: model.UpdateIntervalMinutes,
+	}, nil
+}
+
+func (u *DynamicDNSUpdater) loadState() (*models.DDNSState, error) {
+	var state models.DDNSState
+	if err := u.db.First(&state).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load dynamic DNS state: %w", err)
+	}
+	return &state, nil
+}
@@ -0,0 +1,228 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DDNSProvider updates the DNS record of a dynamic DNS hostname
+type DDNSProvider interface {
+	// Update points the hostname to ip
+	Update(ip string) error
+	// Verify checks the credentials without changing the record
+	Verify() error
+}
+
+// Dynamic DNS providers
+const (
+	DDNSProviderCloudflare = "cloudflare"
+	DDNSProviderNamecheap  = "namecheap"
+	DDNSProviderDynDNS     = "dyndns"
+)
+
+// Provider API endpoints, replaced in tests
+var (
+	cloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+	namecheapAPIURL  = "https://dynamicdns.park-your-domain.com/update"
+	dyndnsAPIURL     = "https://members.dyndns.org/v3/update"
+)
+
+// NewDDNSProvider creates the provider of a dynamic DNS configuration
+func NewDDNSProvider(cfg DynamicDNSConfig, client *http.Client) (DDNSProvider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	switch cfg.Provider {
+	case DDNSProviderCloudflare:
+		return &cloudflareProvider{client: client, baseURL: cloudflareAPIURL, token: cfg.Credential, zone: cfg.Domain, hostname: cfg.Hostname()}, nil
+	case DDNSProviderNamecheap:
+		return &namecheapProvider{client: client, baseURL: namecheapAPIURL, password: cfg.Credential, domain: cfg.Domain, host: cfg.host()}, nil
+	case DDNSProviderDynDNS:
+		username, password, ok := strings.Cut(cfg.Credential, ":")
+		if !ok {
+			return nil, fmt.Errorf("dyndns credential must be username:password")
+		}
+		return &dyndnsProvider{client: client, baseURL: dyndnsAPIURL, username: username, password: password, hostname: cfg.Hostname()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported dynamic DNS provider %q", cfg.Provider)
+	}
+}
+
+// cloudflareProvider updates an A record through the Cloudflare API with an
+// API token that can edit DNS records of the zone
+type cloudflareProvider struct {
+	client   *http.Client
+	baseURL  string
+	token    string
+	zone     string
+	hostname string
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+func (p *cloudflareProvider) do(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, p.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("cloudflare returned status %d", resp.StatusCode)
+	}
+	if !out.Success {
+		msgs := make([]string, len(out.Errors))
+		for i, e := range out.Errors {
+			msgs[i] = e.Message
+		}
+		return fmt.Errorf("cloudflare: %s", strings.Join(msgs, "; "))
+	}
+	if result != nil {
+		return json.Unmarshal(out.Result, result)
+	}
+	return nil
+}
+
+// Verify checks that the token is valid
+func (p *cloudflareProvider) Verify() error {
+	return p.do(http.MethodGet, "/user/tokens/verify", nil, nil)
+}
+
+// Update sets the A record of the hostname, creating it if missing
+func (p *cloudflareProvider) Update(ip string) error {
+	var zones []struct {
+		ID string `json:"id"`
+	}
+	if err := p.do(http.MethodGet, "/zones?name="+url.QueryEscape(p.zone), nil, &zones); err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("cloudflare: zone %s not found", p.zone)
+	}
+
+	recordsPath := "/zones/" + zones[0].ID + "/dns_records"
+	var records []struct {
+		ID string `json:"id"`
+	}
+	if err := p.do(http.MethodGet, recordsPath+"?type=A&name="+url.QueryEscape(p.hostname), nil, &records); err != nil {
+		return err
+	}
+
+	record := map[string]interface{}{"type": "A", "name": p.hostname, "content": ip, "ttl": 1}
+	if len(records) == 0 {
+		return p.do(http.MethodPost, recordsPath, record, nil)
+	}
+	return p.do(http.MethodPut, recordsPath+"/"+records[0].ID, record, nil)
+}
+
+// namecheapProvider uses the Namecheap dynamic DNS endpoint with the
+// domain's dynamic DNS password
+type namecheapProvider struct {
+	client   *http.Client
+	baseURL  string
+	password string
+	domain   string
+	host     string
+}
+
+// Verify checks the configuration. Namecheap has no endpoint to check the
+// password without updating the record.
+func (p *namecheapProvider) Verify() error {
+	if p.password == "" || p.domain == "" {
+		return fmt.Errorf("namecheap requires a domain and dynamic DNS password")
+	}
+	return nil
+}
+
+// Update sets the record of the host
+func (p *namecheapProvider) Update(ip string) error {
+	query := url.Values{"host": {p.host}, "domain": {p.domain}, "password": {p.password}, "ip": {ip}}
+	resp, err := p.client.Get(p.baseURL + "?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("namecheap request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		ErrCount int      `xml:"ErrCount"`
+		Errors   []string `xml:"errors>Err1"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("namecheap returned status %d", resp.StatusCode)
+	}
+	if out.ErrCount > 0 {
+		return fmt.Errorf("namecheap: %s", strings.Join(out.Errors, "; "))
+	}
+	return nil
+}
+
+// dyndnsProvider uses the dyndns2 update protocol, which is also offered by
+// many other providers
+type dyndnsProvider struct {
+	client   *http.Client
+	baseURL  string
+	username string
+	password string
+	hostname string
+}
+
+// Verify checks the configuration. The dyndns2 protocol has no endpoint to
+// check credentials without updating the record.
+func (p *dyndnsProvider) Verify() error {
+	if p.username == "" || p.password == "" {
+		return fmt.Errorf("dyndns requires a username and password")
+	}
+	return nil
+}
+
+// Update sets the record of the hostname
+func (p *dyndnsProvider) Update(ip string) error {
+	query := url.Values{"hostname": {p.hostname}, "myip": {ip}}
+	req, err := http.NewRequest(http.MethodGet, p.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.username, p.password)
+	req.Header.Set("User-Agent", "Stumpf.Works NAS")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("dyndns request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	result := strings.TrimSpace(string(body))
+	if strings.HasPrefix(result, "good") || strings.HasPrefix(result, "nochg") {
+		return nil
+	}
+	return fmt.Errorf("dyndns: %s", result)
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type fakeDDNSProvider struct {
+	updates []string
+	fail    bool
+}
+
+func (p *fakeDDNSProvider) Update(ip string) error {
+	p.updates = append(p.updates, ip)
+	if p.fail {
+		return errors.New("provider unavailable")
+	}
+	return nil
+}
+
+func (p *fakeDDNSProvider) Verify() error { return nil }
+
+func newTestDDNSUpdater(t *testing.T) (*DynamicDNSUpdater, *fakeDDNSProvider, *string, *int) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ddns.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.DDNSConfig{}, &models.DDNSState{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	publicIP := "203.0.113.10"
+	ipService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, publicIP)
+	}))
+	t.Cleanup(ipService.Close)
+
+	provider := &fakeDDNSProvider{}
+	alerts := 0
+	u := NewDynamicDNSUpdater(db)
+	u.ipURL = ipService.URL
+	u.retryDelay = 0
+	u.newProvider = func(DynamicDNSConfig) (DDNSProvider, error) { return provider, nil }
+	u.onFailure = func(string, string, int, error) { alerts++ }
+
+	err = u.SaveConfig(DynamicDNSConfig{Enabled: true, Provider: DDNSProviderDynDNS, Domain: "example.com", Subdomain: "nas", Credential: "user:secret"})
+	if err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	return u, provider, &publicIP, &alerts
+}
+
+func TestDynamicDNSUpdaterIPChange(t *testing.T) {
+	u, provider, publicIP, _ := newTestDDNSUpdater(t)
+	ctx := context.Background()
+
+	checks := []struct {
+		ip      string
+		updates int
+	}{
+		{"203.0.113.10", 1}, // First check
+		{"203.0.113.10", 1}, // Unchanged
+		{"203.0.113.20", 2}, // Changed
+	}
+	for _, c := range checks {
+		*publicIP = c.ip
+		if err := u.Check(ctx); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		if len(provider.updates) != c.updates {
+			t.Fatalf("after check with %s: %d updates, want %d", c.ip, len(provider.updates), c.updates)
+		}
+	}
+
+	status, err := u.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.State.CurrentIP != "203.0.113.20" || status.State.Hostname != "nas.example.com" || status.State.LastUpdate == nil {
+		t.Errorf("state = %+v", status.State)
+	}
+	if status.Config.Credential != "" || !status.Config.HasCredential {
+		t.Errorf("status exposes the credential: %+v", status.Config)
+	}
+}
+
+func TestDynamicDNSUpdaterAlertsAfterRetries(t *testing.T) {
+	u, provider, _, alerts := newTestDDNSUpdater(t)
+	provider.fail = true
+
+	if err := u.Check(context.Background()); err == nil {
+		t.Fatal("expected the check to fail")
+	}
+	if len(provider.updates) != ddnsUpdateRetries || *alerts != 1 {
+		t.Errorf("%d updates and %d alerts, want %d and 1", len(provider.updates), *alerts, ddnsUpdateRetries)
+	}
+
+	// A still failing update for the same IP is not alerted again
+	u.Check(context.Background())
+	if *alerts != 1 {
+		t.Errorf("got %d alerts, want 1", *alerts)
+	}
+
+	provider.fail = false
+	if err := u.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	status, _ := u.Status()
+	if status.State.LastError != "" || status.State.FailedAttempts != 0 {
+		t.Errorf("state after recovery = %+v", status.State)
+	}
+}
+
+func TestDynDNSProviderUpdate(t *testing.T) {
+	var query, user, pass string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		user, pass, _ = r.BasicAuth()
+		fmt.Fprint(w, "good 203.0.113.10")
+	}))
+	defer api.Close()
+
+	dyndnsAPIURL = api.URL
+	defer func() { dyndnsAPIURL = "https://members.dyndns.org/v3/update" }()
+
+	provider, err := NewDDNSProvider(DynamicDNSConfig{Provider: DDNSProviderDynDNS, Domain: "example.com", Subdomain: "nas", Credential: "user:secret"}, api.Client())
+	if err != nil {
+		t.Fatalf("NewDDNSProvider: %v", err)
+	}
+	if err := provider.Update("203.0.113.10"); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if query != "hostname=nas.example.com&myip=203.0.113.10" || user != "user" || pass != "secret" {
+		t.Errorf("request query %q, auth %s:%s", query, user, pass)
+	}
+}
@@ -3,13 +3,12 @@ package network
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
-	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 )
 
 var (
@@ -30,29 +29,7 @@ type FirewallStatus struct {
 // getUFWPath finds and caches the ufw executable path
 func getUFWPath() (string, error) {
 	ufwPathOnce.Do(func() {
-		// Common paths where ufw might be installed
-		commonPaths := []string{
-			"/usr/sbin/ufw",
-			"/sbin/ufw",
-			"/usr/bin/ufw",
-			"/bin/ufw",
-		}
-
-		// First try PATH lookup
-		if path, err := exec.LookPath("ufw"); err == nil {
-			ufwPath = path
-			return
-		}
-
-		// Then check common paths
-		for _, path := range commonPaths {
-			if _, err := os.Stat(path); err == nil {
-				ufwPath = path
-				return
-			}
-		}
-
-		ufwPathErr = fmt.Errorf("ufw is not installed")
+		ufwPath, ufwPathErr = sysutil.RequireCommand("ufw")
 	})
 
 	if ufwPathErr != nil {
@@ -70,17 +47,16 @@ func GetFirewallStatus() (*FirewallStatus, error) {
 	}
 
 	// Get status
-	cmd := exec.Command(ufw, "status", "verbose")
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ufw, "status", "verbose")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get firewall status: %s", string(output))
+		return nil, fmt.Errorf("failed to get firewall status: %s", output)
 	}
 
 	status := &FirewallStatus{
 		Rules: []FirewallRule{},
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
@@ -114,10 +90,9 @@ func GetFirewallStatus() (*FirewallStatus, error) {
 	}
 
 	// Get numbered rules
-	cmd = exec.Command(ufw, "status", "numbered")
-	output, err = cmd.CombinedOutput()
+	output, err = runCommand(ufw, "status", "numbered")
 	if err == nil {
-		status.Rules = parseFirewallRules(string(output))
+		status.Rules = parseFirewallRules(output)
 	}
 
 	return status, nil
@@ -195,9 +170,8 @@ func EnableFirewall() error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, "--force", "enable")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to enable firewall: %s", string(output))
+	if output, err := runCommand(ufw, "--force", "enable"); err != nil {
+		return fmt.Errorf("failed to enable firewall: %s", output)
 	}
 	return nil
 }
@@ -209,9 +183,8 @@ func DisableFirewall() error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, "disable")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to disable firewall: %s", string(output))
+	if output, err := runCommand(ufw, "disable"); err != nil {
+		return fmt.Errorf("failed to disable firewall: %s", output)
 	}
 	return nil
 }
@@ -252,9 +225,8 @@ func AddFirewallRule(action, port, protocol, from, to string) error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, args...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to add rule: %s", string(output))
+	if output, err := runCommand(ufw, args...); err != nil {
+		return fmt.Errorf("failed to add rule: %s", output)
 	}
 
 	return nil
@@ -267,9 +239,8 @@ func DeleteFirewallRule(ruleNumber int) error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, "--force", "delete", strconv.Itoa(ruleNumber))
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to delete rule: %s", string(output))
+	if output, err := runCommand(ufw, "--force", "delete", strconv.Itoa(ruleNumber)); err != nil {
+		return fmt.Errorf("failed to delete rule: %s", output)
 	}
 	return nil
 }
@@ -288,9 +259,8 @@ func SetDefaultPolicy(direction, policy string) error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, "default", policy, direction)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set default policy: %s", string(output))
+	if output, err := runCommand(ufw, "default", policy, direction); err != nil {
+		return fmt.Errorf("failed to set default policy: %s", output)
 	}
 
 	return nil
@@ -303,9 +273,8 @@ func ResetFirewall() error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, "--force", "reset")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to reset firewall: %s", string(output))
+	if output, err := runCommand(ufw, "--force", "reset"); err != nil {
+		return fmt.Errorf("failed to reset firewall: %s", output)
 	}
 	return nil
 }
@@ -317,9 +286,8 @@ func AllowService(service string) error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, "allow", service)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to allow service: %s", string(output))
+	if output, err := runCommand(ufw, "allow", service); err != nil {
+		return fmt.Errorf("failed to allow service: %s", output)
 	}
 	return nil
 }
@@ -331,9 +299,8 @@ func DenyService(service string) error {
 		return err
 	}
 
-	cmd := exec.Command(ufw, "deny", service)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to deny service: %s", string(output))
+	if output, err := runCommand(ufw, "deny", service); err != nil {
+		return fmt.Errorf("failed to deny service: %s", output)
 	}
 	return nil
 }
@@ -0,0 +1,448 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrIPAMPoolNotFound is returned for unknown pool names
+	ErrIPAMPoolNotFound = errors.New("IPAM pool not found")
+
+	// ErrIPAMPoolExists is returned when a pool name is taken or its subnet
+	// overlaps another pool
+	ErrIPAMPoolExists = errors.New("IPAM pool already exists")
+
+	// ErrIPAMPoolInUse is returned when deleting a pool with allocations
+	ErrIPAMPoolInUse = errors.New("IPAM pool has allocated addresses")
+
+	// ErrIPAMPoolExhausted is returned when a pool has no free address left
+	ErrIPAMPoolExhausted = errors.New("no free address left in IPAM pool")
+
+	// ErrIPAllocationNotFound is returned when releasing an address that is not allocated
+	ErrIPAllocationNotFound = errors.New("IP address is not allocated")
+)
+
+var poolNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// IPAMPool is a subnet from which addresses are allocated. The network and
+// broadcast addresses, the gateway and the excluded ranges are never
+// handed out.
+type IPAMPool struct {
+	Name           string   `json:"name"`
+	Subnet         string   `json:"subnet"`
+	GatewayIP      string   `json:"gatewayIp"`
+	DNSServers     []string `json:"dnsServers"`
+	ExcludedRanges []string `json:"excludedRanges"` // CIDRs, single IPs or first-last ranges
+}
+
+// Validate checks the pool name, subnet, gateway, DNS servers and excluded ranges
+func (p *IPAMPool) Validate() error {
+	if !poolNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid pool name %q (letters, digits, '.', '_' and '-', up to 64 characters)", p.Name)
+	}
+
+	subnet, err := netip.ParsePrefix(p.Subnet)
+	if err != nil {
+		return fmt.Errorf("invalid subnet %q: must be a CIDR range", p.Subnet)
+	}
+	if subnet != subnet.Masked() {
+		return fmt.Errorf("subnet %s has host bits set, did you mean %s?", p.Subnet, subnet.Masked())
+	}
+	if subnet.Addr().BitLen()-subnet.Bits() < 2 {
+		return fmt.Errorf("subnet %s has no room for hosts", p.Subnet)
+	}
+
+	if p.GatewayIP != "" {
+		gateway, err := netip.ParseAddr(p.GatewayIP)
+		if err != nil {
+			return fmt.Errorf("invalid gateway IP %q", p.GatewayIP)
+		}
+		first, last := hostRange(subnet)
+		if gateway.Less(first) || last.Less(gateway) {
+			return fmt.Errorf("gateway %s is not a host address of %s", p.GatewayIP, p.Subnet)
+		}
+	}
+	for _, server := range p.DNSServers {
+		if _, err := netip.ParseAddr(server); err != nil {
+			return fmt.Errorf("invalid DNS server %q", server)
+		}
+	}
+	for _, r := range p.ExcludedRanges {
+		if _, _, err := parseAddrRange(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IPAMManager hands out the addresses of IPAM pools and records who uses
+// them, so VPN peers and containers never get the same address
+type IPAMManager struct {
+	db *gorm.DB
+	mu sync.Mutex
+}
+
+// NewIPAMManager creates an IPAM manager
+func NewIPAMManager(db *gorm.DB) *IPAMManager {
+	return &IPAMManager{db: db}
+}
+
+// CreatePool stores a pool. Subnets of pools must not overlap, so an address
+// belongs to at most one pool.
+func (m *IPAMManager) CreatePool(pool IPAMPool) (*models.IPAMPool, error) {
+	if err := pool.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkOverlap(&pool, 0); err != nil {
+		return nil, err
+	}
+
+	record := &models.IPAMPool{}
+	applyPool(record, &pool)
+	if err := m.db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save IPAM pool: %w", err)
+	}
+
+	logger.Info("IPAM pool created", zap.String("name", record.Name), zap.String("subnet", record.Subnet))
+	return record, nil
+}
+
+// UpdatePool changes the subnet, gateway, DNS servers and excluded ranges of
+// a pool. The existing allocations must remain in the subnet.
+func (m *IPAMManager) UpdatePool(name string, pool IPAMPool) (*models.IPAMPool, error) {
+	pool.Name = name
+	if err := pool.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, err := m.getPool(m.db, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkOverlap(&pool, record.ID); err != nil {
+		return nil, err
+	}
+
+	subnet := netip.MustParsePrefix(pool.Subnet)
+	var allocations []models.IPAllocation
+	if err := m.db.Where("pool_name = ?", name).Find(&allocations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list IP allocations: %w", err)
+	}
+	for _, allocation := range allocations {
+		if addr, err := netip.ParseAddr(allocation.AllocatedIP); err != nil || !subnet.Contains(addr) {
+			return nil, fmt.Errorf("allocated address %s is outside subnet %s", allocation.AllocatedIP, pool.Subnet)
+		}
+	}
+
+	applyPool(record, &pool)
+	if err := m.db.Save(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save IPAM pool: %w", err)
+	}
+	return record, nil
+}
+
+// DeletePool removes a pool without allocations
+func (m *IPAMManager) DeletePool(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, err := m.getPool(m.db, name)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := m.db.Model(&models.IPAllocation{}).Where("pool_name = ?", name).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count IP allocations: %w", err)
+	}
+	if count > 0 {
+		return ErrIPAMPoolInUse
+	}
+
+	if err := m.db.Delete(record).Error; err != nil {
+		return fmt.Errorf("failed to delete IPAM pool: %w", err)
+	}
+	return nil
+}
+
+// GetPool returns a pool by name
+func (m *IPAMManager) GetPool(name string) (*models.IPAMPool, error) {
+	return m.getPool(m.db, name)
+}
+
+// ListPools returns all pools ordered by name
+func (m *IPAMManager) ListPools() ([]models.IPAMPool, error) {
+	pools := []models.IPAMPool{}
+	if err := m.db.Order("name").Find(&pools).Error; err != nil {
+		return nil, fmt.Errorf("failed to list IPAM pools: %w", err)
+	}
+	return pools, nil
+}
+
+// AllocateIP allocates the lowest free address of a pool
+func (m *IPAMManager) AllocateIP(poolName string) (string, error) {
+	return m.AllocateIPFor(poolName, "")
+}
+
+// AllocateIPFor allocates the lowest free address of a pool and records what
+// it is used for, e.g. wireguard:wg0:<public key> or lxc:<container>
+func (m *IPAMManager) AllocateIPFor(poolName, allocatedFor string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ip string
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		pool, err := m.getPool(tx, poolName)
+		if err != nil {
+			return err
+		}
+
+		var used []string
+		if err := tx.Model(&models.IPAllocation{}).Where("pool_name = ?", poolName).Pluck("allocated_ip", &used).Error; err != nil {
+			return fmt.Errorf("failed to list IP allocations: %w", err)
+		}
+
+		addr, err := freeAddr(pool, used)
+		if err != nil {
+			return err
+		}
+
+		// The unique index on pool and address keeps allocations distinct
+		// even across processes sharing the database
+		allocation := &models.IPAllocation{
+			PoolName:     poolName,
+			AllocatedIP:  addr.String(),
+			AllocatedFor: allocatedFor,
+			AllocatedAt:  time.Now(),
+		}
+		if err := tx.Create(allocation).Error; err != nil {
+			return fmt.Errorf("failed to save IP allocation: %w", err)
+		}
+		ip = allocation.AllocatedIP
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("IP address allocated",
+		zap.String("pool", poolName),
+		zap.String("ip", ip),
+		zap.String("for", allocatedFor))
+	return ip, nil
+}
+
+// ReleaseIP returns an allocated address to its pool
+func (m *IPAMManager) ReleaseIP(ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := m.db.Where("allocated_ip = ?", addr.String()).Delete(&models.IPAllocation{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to release IP address: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrIPAllocationNotFound
+	}
+
+	logger.Info("IP address released", zap.String("ip", addr.String()))
+	return nil
+}
+
+// ReleaseAllocationsFor releases all addresses allocated for an owner
+func (m *IPAMManager) ReleaseAllocationsFor(allocatedFor string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.db.Where("allocated_for = ?", allocatedFor).Delete(&models.IPAllocation{}).Error; err != nil {
+		return fmt.Errorf("failed to release IP addresses: %w", err)
+	}
+	return nil
+}
+
+// ListAllocations returns the allocations of a pool ordered by allocation time
+func (m *IPAMManager) ListAllocations(poolName string) ([]models.IPAllocation, error) {
+	if _, err := m.getPool(m.db, poolName); err != nil {
+		return nil, err
+	}
+
+	allocations := []models.IPAllocation{}
+	if err := m.db.Where("pool_name = ?", poolName).Order("allocated_at, id").Find(&allocations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list IP allocations: %w", err)
+	}
+	return allocations, nil
+}
+
+// HostPrefix returns the single-address CIDR of ip, e.g. for the allowed IPs
+// of a WireGuard peer
+func HostPrefix(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()).String()
+}
+
+// checkOverlap rejects a pool whose name is taken or whose subnet overlaps
+// another pool than the one with ID self
+func (m *IPAMManager) checkOverlap(pool *IPAMPool, self uint) error {
+	var pools []models.IPAMPool
+	if err := m.db.Where("id <> ?", self).Find(&pools).Error; err != nil {
+		return fmt.Errorf("failed to list IPAM pools: %w", err)
+	}
+
+	subnet := netip.MustParsePrefix(pool.Subnet)
+	for _, other := range pools {
+		if other.Name == pool.Name {
+			return ErrIPAMPoolExists
+		}
+		if otherSubnet, err := netip.ParsePrefix(other.Subnet); err == nil && otherSubnet.Overlaps(subnet) {
+			return fmt.Errorf("%w: subnet %s overlaps pool %s (%s)", ErrIPAMPoolExists, pool.Subnet, other.Name, other.Subnet)
+		}
+	}
+	return nil
+}
+
+func (m *IPAMManager) getPool(db *gorm.DB, name string) (*models.IPAMPool, error) {
+	var record models.IPAMPool
+	if err := db.Where("name = ?", name).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIPAMPoolNotFound
+		}
+		return nil, fmt.Errorf("failed to get IPAM pool: %w", err)
+	}
+	return &record, nil
+}
+
+func applyPool(record *models.IPAMPool, pool *IPAMPool) {
+	record.Name = pool.Name
+	record.Subnet = pool.Subnet
+	record.GatewayIP = pool.GatewayIP
+	record.DNSServers = pool.DNSServers
+	if record.DNSServers == nil {
+		record.DNSServers = []string{}
+	}
+	record.ExcludedRanges = pool.ExcludedRanges
+	if record.ExcludedRanges == nil {
+		record.ExcludedRanges = []string{}
+	}
+}
+
+// freeAddr returns the lowest host address of a pool that is not the
+// gateway, excluded or in use
+func freeAddr(pool *models.IPAMPool, used []string) (netip.Addr, error) {
+	subnet, err := netip.ParsePrefix(pool.Subnet)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid subnet %q of pool %s", pool.Subnet, pool.Name)
+	}
+
+	type addrRange struct{ first, last netip.Addr }
+	var excluded []addrRange
+	for _, r := range pool.ExcludedRanges {
+		first, last, err := parseAddrRange(r)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		excluded = append(excluded, addrRange{first, last})
+	}
+
+	taken := make(map[netip.Addr]bool, len(used)+1)
+	for _, ip := range used {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			taken[addr] = true
+		}
+	}
+	if gateway, err := netip.ParseAddr(pool.GatewayIP); err == nil {
+		taken[gateway] = true
+	}
+
+	first, last := hostRange(subnet)
+	addr := first
+next:
+	for addr.IsValid() && !last.Less(addr) {
+		// Skip whole excluded ranges rather than stepping through them
+		for _, r := range excluded {
+			if !addr.Less(r.first) && !r.last.Less(addr) {
+				addr = r.last.Next()
+				continue next
+			}
+		}
+		if !taken[addr] {
+			return addr, nil
+		}
+		addr = addr.Next()
+	}
+	return netip.Addr{}, ErrIPAMPoolExhausted
+}
+
+// hostRange returns the first and last host address of a subnet. IPv4
+// subnets lose their network and broadcast addresses, IPv6 subnets their
+// subnet-router anycast address.
+func hostRange(subnet netip.Prefix) (netip.Addr, netip.Addr) {
+	first, last := subnet.Addr().Next(), lastAddr(subnet)
+	if last.Is4() {
+		last = last.Prev()
+	}
+	return first, last
+}
+
+// lastAddr returns the highest address of a prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(bytes)*8; i++ {
+		bytes[i/8] |= 0x80 >> (i % 8)
+	}
+	last, _ := netip.AddrFromSlice(bytes)
+	return last
+}
+
+// parseAddrRange parses an excluded range given as a CIDR, a single address
+// or a first-last range
+func parseAddrRange(s string) (netip.Addr, netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid excluded range %q", s)
+		}
+		prefix = prefix.Masked()
+		return prefix.Addr(), lastAddr(prefix), nil
+	}
+
+	firstStr, lastStr, isRange := strings.Cut(s, "-")
+	first, err := netip.ParseAddr(strings.TrimSpace(firstStr))
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid excluded range %q", s)
+	}
+	if !isRange {
+		return first, first, nil
+	}
+	last, err := netip.ParseAddr(strings.TrimSpace(lastStr))
+	if err != nil || last.Less(first) || last.Is4() != first.Is4() {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid excluded range %q", s)
+	}
+	return first, last, nil
+}
@@ -0,0 +1,117 @@
+package network
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestIPAMManager(t *testing.T) *IPAMManager {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ipam.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.IPAMPool{}, &models.IPAllocation{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewIPAMManager(db)
+}
+
+func TestAllocateIPConcurrent(t *testing.T) {
+	m := newTestIPAMManager(t)
+	if _, err := m.CreatePool(IPAMPool{Name: "vpn", Subnet: "10.8.0.0/24", GatewayIP: "10.8.0.1"}); err != nil {
+		t.Fatalf("CreatePool: %v", err)
+	}
+
+	const n = 50
+	ips := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ips[i], errs[i] = m.AllocateIP("vpn")
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, n)
+	for i, ip := range ips {
+		if errs[i] != nil {
+			t.Fatalf("AllocateIP: %v", errs[i])
+		}
+		if ip == "10.8.0.0" || ip == "10.8.0.1" {
+			t.Errorf("allocated reserved address %s", ip)
+		}
+		if seen[ip] {
+			t.Errorf("address %s allocated twice", ip)
+		}
+		seen[ip] = true
+	}
+
+	allocations, err := m.ListAllocations("vpn")
+	if err != nil {
+		t.Fatalf("ListAllocations: %v", err)
+	}
+	if len(allocations) != n {
+		t.Errorf("got %d allocations, want %d", len(allocations), n)
+	}
+}
+
+func TestAllocateIPSkipsExcludedAndReleased(t *testing.T) {
+	m := newTestIPAMManager(t)
+	_, err := m.CreatePool(IPAMPool{
+		Name:           "lab",
+		Subnet:         "192.168.50.0/29",
+		GatewayIP:      "192.168.50.1",
+		ExcludedRanges: []string{"192.168.50.2-192.168.50.3", "192.168.50.5"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePool: %v", err)
+	}
+
+	// Hosts are .1 to .6, leaving .4 and .6 after the gateway and exclusions
+	for _, want := range []string{"192.168.50.4", "192.168.50.6"} {
+		if ip, err := m.AllocateIP("lab"); err != nil || ip != want {
+			t.Fatalf("AllocateIP = %q, %v, want %s", ip, err, want)
+		}
+	}
+	if _, err := m.AllocateIP("lab"); err != ErrIPAMPoolExhausted {
+		t.Fatalf("AllocateIP on a full pool = %v, want %v", err, ErrIPAMPoolExhausted)
+	}
+
+	if err := m.ReleaseIP("192.168.50.4"); err != nil {
+		t.Fatalf("ReleaseIP: %v", err)
+	}
+	if ip, err := m.AllocateIP("lab"); err != nil || ip != "192.168.50.4" {
+		t.Errorf("AllocateIP after release = %q, %v, want 192.168.50.4", ip, err)
+	}
+	if err := m.DeletePool("lab"); err != ErrIPAMPoolInUse {
+		t.Errorf("DeletePool with allocations = %v, want %v", err, ErrIPAMPoolInUse)
+	}
+}
+
+func TestCreatePoolRejectsOverlap(t *testing.T) {
+	m := newTestIPAMManager(t)
+	if _, err := m.CreatePool(IPAMPool{Name: "a", Subnet: "10.0.0.0/16"}); err != nil {
+		t.Fatalf("CreatePool: %v", err)
+	}
+	if _, err := m.CreatePool(IPAMPool{Name: "b", Subnet: "10.0.5.0/24"}); err == nil {
+		t.Error("CreatePool accepted a subnet overlapping pool a")
+	}
+	if _, err := m.CreatePool(IPAMPool{Name: "c", Subnet: "10.1.0.1/24"}); err == nil {
+		t.Error("CreatePool accepted a subnet with host bits set")
+	}
+}
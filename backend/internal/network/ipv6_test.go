@@ -0,0 +1,74 @@
+package network
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCalculateCIDRIPv6(t *testing.T) {
+	tests := []struct {
+		netmask string
+		want    int
+	}{
+		{"255.255.255.0", 24},
+		{net.IP(net.CIDRMask(64, 128)).String(), 64},
+		{"ffff:ffff:ffff:ffff::", 64},
+		{net.IP(net.CIDRMask(128, 128)).String(), 128},
+	}
+	for _, tt := range tests {
+		if got := calculateCIDR(tt.netmask); got != tt.want {
+			t.Errorf("calculateCIDR(%q) = %d, want %d", tt.netmask, got, tt.want)
+		}
+	}
+}
+
+func TestStaticIPv6CIDR(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		wantErr bool
+	}{
+		{address: "2001:db8::10/64", want: "2001:db8::10/64"},
+		{address: "2001:db8::10/128", want: "2001:db8::10/128"},
+		{address: "2001:db8::10", want: "2001:db8::10/64"},
+		{address: "fe80::1/64", wantErr: true},
+		{address: "192.168.1.10/24", wantErr: true},
+		{address: "2001:db8::10/129", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := staticIPv6CIDR(tt.address)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("staticIPv6CIDR(%q) = %q, want error", tt.address, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("staticIPv6CIDR(%q) = %q, %v, want %q", tt.address, got, err, tt.want)
+		}
+	}
+}
+
+func TestGetIPv6LinkLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "if_inet6")
+	content := `00000000000000000000000000000001 01 80 10 80       lo
+20010db8000000000000000000000010 02 40 00 80     eth0
+fe800000000000000250b6fffe123456 02 40 20 80     eth0
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	original := ifInet6Path
+	ifInet6Path = path
+	t.Cleanup(func() { ifInet6Path = original })
+
+	got, err := GetIPv6LinkLocal("eth0")
+	if err != nil || got != "fe80::250:b6ff:fe12:3456" {
+		t.Errorf("GetIPv6LinkLocal(eth0) = %q, %v", got, err)
+	}
+	if _, err := GetIPv6LinkLocal("lo"); err == nil {
+		t.Error("expected an error for an interface without a link-local address")
+	}
+}
@@ -0,0 +1,223 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/grandcat/zeroconf"
+	"go.uber.org/zap"
+)
+
+// mDNS service types announced by the NAS
+const (
+	MDNSServiceHTTP = "_http._tcp"       // Web UI
+	MDNSServiceSMB  = "_smb._tcp"        // Samba
+	MDNSServiceNFS  = "_nfs._tcp"        // NFS
+	MDNSServiceAFP  = "_afpovertcp._tcp" // Not served; lets macOS Finder list the NAS
+	MDNSServiceSSH  = "_ssh._tcp"
+
+	mdnsDomain = "local."
+)
+
+// MDNSService is a service announced via mDNS
+type MDNSService struct {
+	Type string `json:"type"`
+	Port int    `json:"port"`
+}
+
+// mdnsServer is an mDNS registration
+type mdnsServer interface {
+	Shutdown()
+}
+
+// registerMDNS announces a service on all multicast interfaces. Replaced in
+// tests.
+var registerMDNS = func(instance, service, domain string, port int, text []string) (mdnsServer, error) {
+	return zeroconf.Register(instance, service, domain, port, text, nil)
+}
+
+// mdnsText is the TXT record of a service type
+var mdnsText = map[string][]string{
+	MDNSServiceHTTP: {"path=/"},
+}
+
+// MDNSAdvertiser announces the services of the NAS via mDNS/Bonjour, so it
+// can be found on the local network without knowing its IP
+type MDNSAdvertiser struct {
+	instance string
+
+	mu       sync.Mutex
+	ports    map[string]int // Service type to port
+	servers  map[string]mdnsServer
+	shutdown bool
+}
+
+var (
+	mdnsAdvertiser   *MDNSAdvertiser
+	mdnsAdvertiserMu sync.RWMutex
+)
+
+// NewMDNSAdvertiser creates an advertiser for the web UI on the configured
+// server port and the file sharing and SSH services on their default ports
+func NewMDNSAdvertiser(cfg *config.Config) *MDNSAdvertiser {
+	webPort := 8080
+	appName := ""
+	if cfg != nil {
+		if cfg.Server.Port > 0 {
+			webPort = cfg.Server.Port
+		}
+		appName = cfg.App.Name
+	}
+	hostname, _ := os.Hostname()
+
+	return &MDNSAdvertiser{
+		instance: mdnsInstanceName(appName, hostname),
+		ports: map[string]int{
+			MDNSServiceHTTP: webPort,
+			MDNSServiceSMB:  445,
+			MDNSServiceNFS:  2049,
+			MDNSServiceAFP:  548,
+			MDNSServiceSSH:  22,
+		},
+		servers: make(map[string]mdnsServer),
+	}
+}
+
+// mdnsInstanceName returns the instance name announced for the NAS: the
+// app name, or the hostname if none is configured. Characters that would
+// need escaping in DNS labels are replaced.
+func mdnsInstanceName(appName, hostname string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(appName))
+	if name = strings.Trim(name, "-"); name != "" {
+		return name
+	}
+	if hostname != "" {
+		return hostname
+	}
+	return "stumpfworks-nas"
+}
+
+// GetMDNSAdvertiser returns the running mDNS advertiser, or nil
+func GetMDNSAdvertiser() *MDNSAdvertiser {
+	mdnsAdvertiserMu.RLock()
+	defer mdnsAdvertiserMu.RUnlock()
+	return mdnsAdvertiser
+}
+
+// Start announces the services until ctx is cancelled and makes the
+// advertiser available through GetMDNSAdvertiser. Services that fail to
+// register are reported in the error; the others stay announced.
+func (a *MDNSAdvertiser) Start(ctx context.Context) error {
+	a.mu.Lock()
+	err := a.registerAll()
+	a.mu.Unlock()
+
+	mdnsAdvertiserMu.Lock()
+	mdnsAdvertiser = a
+	mdnsAdvertiserMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.shutdownAll()
+		a.shutdown = true
+	}()
+
+	logger.Info("mDNS services announced", zap.String("instance", a.instance), zap.Any("services", a.Services()))
+	return err
+}
+
+// Restart withdraws and announces all services again, e.g. after the
+// network configuration changed
+func (a *MDNSAdvertiser) Restart() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.shutdown {
+		return fmt.Errorf("mDNS advertiser is stopped")
+	}
+	a.shutdownAll()
+	return a.registerAll()
+}
+
+// UpdateService announces a service on a new port, adding it if it was not
+// announced before
+func (a *MDNSAdvertiser) UpdateService(serviceType string, port int) error {
+	if !strings.HasPrefix(serviceType, "_") || !(strings.HasSuffix(serviceType, "._tcp") || strings.HasSuffix(serviceType, "._udp")) {
+		return fmt.Errorf("invalid service type %q (use e.g. _http._tcp)", serviceType)
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.shutdown {
+		return fmt.Errorf("mDNS advertiser is stopped")
+	}
+	if current, ok := a.ports[serviceType]; ok && current == port && a.servers[serviceType] != nil {
+		return nil
+	}
+	if server := a.servers[serviceType]; server != nil {
+		server.Shutdown()
+		delete(a.servers, serviceType)
+	}
+	a.ports[serviceType] = port
+	return a.register(serviceType)
+}
+
+// Services returns the announced services, sorted by type
+func (a *MDNSAdvertiser) Services() []MDNSService {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	services := make([]MDNSService, 0, len(a.servers))
+	for serviceType := range a.servers {
+		services = append(services, MDNSService{Type: serviceType, Port: a.ports[serviceType]})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Type < services[j].Type })
+	return services
+}
+
+// registerAll announces all services. The caller holds a.mu.
+func (a *MDNSAdvertiser) registerAll() error {
+	var errs []error
+	for serviceType := range a.ports {
+		if err := a.register(serviceType); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// register announces a service. The caller holds a.mu.
+func (a *MDNSAdvertiser) register(serviceType string) error {
+	server, err := registerMDNS(a.instance, serviceType, mdnsDomain, a.ports[serviceType], mdnsText[serviceType])
+	if err != nil {
+		return fmt.Errorf("failed to announce %s: %w", serviceType, err)
+	}
+	a.servers[serviceType] = server
+	return nil
+}
+
+// shutdownAll withdraws all services. The caller holds a.mu.
+func (a *MDNSAdvertiser) shutdownAll() {
+	for serviceType, server := range a.servers {
+		server.Shutdown()
+		delete(a.servers, serviceType)
+	}
+}
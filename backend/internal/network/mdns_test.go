@@ -0,0 +1,129 @@
+package network
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+type fakeMDNSServer struct {
+	registrations *fakeMDNSRegistrations
+	key           string
+}
+
+func (s *fakeMDNSServer) Shutdown() { delete(s.registrations.active, s.key) }
+
+// fakeMDNSRegistrations intercepts the zeroconf registrations
+type fakeMDNSRegistrations struct {
+	active    map[string]int // "instance service" to port
+	text      map[string][]string
+	registers int
+}
+
+func interceptMDNS(t *testing.T) *fakeMDNSRegistrations {
+	t.Helper()
+	regs := &fakeMDNSRegistrations{active: map[string]int{}, text: map[string][]string{}}
+	real := registerMDNS
+	registerMDNS = func(instance, service, domain string, port int, text []string) (mdnsServer, error) {
+		if domain != "local." {
+			t.Errorf("registered %s in domain %q", service, domain)
+		}
+		key := instance + " " + service
+		regs.active[key] = port
+		regs.text[service] = text
+		regs.registers++
+		return &fakeMDNSServer{registrations: regs, key: key}, nil
+	}
+	t.Cleanup(func() {
+		registerMDNS = real
+		mdnsAdvertiser = nil
+	})
+	return regs
+}
+
+func TestMDNSAdvertiser(t *testing.T) {
+	logger.InitLogger("error", false)
+	regs := interceptMDNS(t)
+
+	cfg := &config.Config{App: config.AppConfig{Name: "Stumpf.Works NAS"}, Server: config.ServerConfig{Port: 8443}}
+	advertiser := NewMDNSAdvertiser(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := advertiser.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if GetMDNSAdvertiser() != advertiser {
+		t.Error("started advertiser is not available")
+	}
+
+	want := map[string]int{
+		"Stumpf-Works-NAS _http._tcp":       8443,
+		"Stumpf-Works-NAS _smb._tcp":        445,
+		"Stumpf-Works-NAS _nfs._tcp":        2049,
+		"Stumpf-Works-NAS _afpovertcp._tcp": 548,
+		"Stumpf-Works-NAS _ssh._tcp":        22,
+	}
+	if !reflect.DeepEqual(regs.active, want) {
+		t.Errorf("registered %v, want %v", regs.active, want)
+	}
+	if !reflect.DeepEqual(regs.text[MDNSServiceHTTP], []string{"path=/"}) {
+		t.Errorf("web UI TXT record = %v", regs.text[MDNSServiceHTTP])
+	}
+
+	// A port change re-announces only that service
+	before := regs.registers
+	if err := advertiser.UpdateService(MDNSServiceSSH, 2222); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	if err := advertiser.UpdateService(MDNSServiceSMB, 445); err != nil {
+		t.Fatalf("UpdateService with the same port: %v", err)
+	}
+	if regs.registers != before+1 || regs.active["Stumpf-Works-NAS _ssh._tcp"] != 2222 || len(regs.active) != 5 {
+		t.Errorf("after updating SSH: %d registrations, active %v", regs.registers-before, regs.active)
+	}
+	if err := advertiser.UpdateService("http", 80); err == nil {
+		t.Error("invalid service type was accepted")
+	}
+	if err := advertiser.UpdateService(MDNSServiceHTTP, 70000); err == nil {
+		t.Error("invalid port was accepted")
+	}
+
+	if err := advertiser.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	var types []string
+	for _, service := range advertiser.Services() {
+		types = append(types, service.Type)
+	}
+	if !sort.StringsAreSorted(types) || len(types) != 5 || regs.active["Stumpf-Works-NAS _ssh._tcp"] != 2222 {
+		t.Errorf("after restart: services %v, active %v", types, regs.active)
+	}
+
+	// Cancelling the context withdraws all services
+	cancel()
+	for i := 0; i < 1000 && advertiser.Restart() == nil; i++ {
+		time.Sleep(time.Millisecond) // Wait for the shutdown
+	}
+	if len(regs.active) != 0 {
+		t.Errorf("still announced after shutdown: %v", regs.active)
+	}
+}
+
+func TestMDNSInstanceName(t *testing.T) {
+	tests := []struct{ appName, hostname, want string }{
+		{"Stumpf.Works NAS", "nas01", "Stumpf-Works-NAS"},
+		{"", "nas01", "nas01"},
+		{" ... ", "nas01", "nas01"},
+		{"", "", "stumpfworks-nas"},
+	}
+	for _, tt := range tests {
+		if got := mdnsInstanceName(tt.appName, tt.hostname); got != tt.want {
+			t.Errorf("mdnsInstanceName(%q, %q) = %q, want %q", tt.appName, tt.hostname, got, tt.want)
+		}
+	}
+}
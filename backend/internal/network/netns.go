@@ -0,0 +1,301 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// netnsDir is where ip netns keeps the named network namespaces
+const netnsDir = "/run/netns"
+
+// NamespaceInterface is the name of the interface inside a container's
+// network namespace
+const NamespaceInterface = "eth0"
+
+var (
+	netnsNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+	// Interface names are limited to 15 characters (IFNAMSIZ)
+	ifaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,14}$`)
+)
+
+// NetworkNamespace manages named network namespaces (ip netns) that give
+// containers and VMs their own interfaces, addresses and routes, so they
+// cannot conflict with the host or each other. Each namespace is connected
+// to a host bridge through a veth pair.
+type NetworkNamespace struct {
+	db    *gorm.DB
+	shell executor.ShellExecutor
+	dir   string
+	mu    sync.Mutex
+}
+
+// NewNetworkNamespace creates a network namespace manager
+func NewNetworkNamespace(db *gorm.DB, shell executor.ShellExecutor) (*NetworkNamespace, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if !shell.CommandExists("ip") {
+		return nil, fmt.Errorf("ip not installed (install 'iproute2' package)")
+	}
+	return &NetworkNamespace{db: db, shell: shell, dir: netnsDir}, nil
+}
+
+// NamespaceName returns the namespace name of a container or VM
+func NamespaceName(containerType, containerID string) string {
+	name := containerType + "-" + containerID
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// HostInterfaceName returns the name of the host end of a namespace's veth
+// pair, derived from the namespace name to fit into 15 characters
+func HostInterfaceName(nsName string) string {
+	h := fnv.New32a()
+	h.Write([]byte(nsName))
+	return fmt.Sprintf("veth%08x", h.Sum32())
+}
+
+// Path returns the file of a namespace, for tools that join it by path
+func (n *NetworkNamespace) Path(name string) string {
+	return filepath.Join(n.dir, name)
+}
+
+// Create adds a network namespace with its loopback interface up
+func (n *NetworkNamespace) Create(name string) error {
+	if !netnsNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid namespace name %q", name)
+	}
+	if err := n.ip("netns", "add", name); err != nil {
+		return err
+	}
+	if err := n.ip("-n", name, "link", "set", "lo", "up"); err != nil {
+		n.ip("netns", "delete", name)
+		return err
+	}
+	logger.Info("Network namespace created", zap.String("name", name))
+	return nil
+}
+
+// Delete removes a network namespace and its association. Interfaces in the
+// namespace are destroyed with it, taking the host end of their veth
+// pairs along.
+func (n *NetworkNamespace) Delete(name string) error {
+	if !netnsNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid namespace name %q", name)
+	}
+	if err := n.ip("netns", "delete", name); err != nil {
+		return err
+	}
+	if err := n.db.Delete(&models.NetworkNamespace{}, "name = ?", name).Error; err != nil {
+		return fmt.Errorf("failed to delete namespace association: %w", err)
+	}
+	logger.Info("Network namespace deleted", zap.String("name", name))
+	return nil
+}
+
+// AddVethPair creates a veth pair with hostIface on the host and nsIface in
+// the namespace, and brings both ends up
+func (n *NetworkNamespace) AddVethPair(nsName, hostIface, nsIface string) error {
+	if !netnsNamePattern.MatchString(nsName) {
+		return fmt.Errorf("invalid namespace name %q", nsName)
+	}
+	for _, iface := range []string{hostIface, nsIface} {
+		if !ifaceNamePattern.MatchString(iface) {
+			return fmt.Errorf("invalid interface name %q", iface)
+		}
+	}
+
+	if err := n.ip("link", "add", hostIface, "type", "veth", "peer", "name", nsIface, "netns", nsName); err != nil {
+		return err
+	}
+	if err := n.ip("link", "set", hostIface, "up"); err != nil {
+		n.ip("link", "delete", hostIface)
+		return err
+	}
+	if err := n.ip("-n", nsName, "link", "set", nsIface, "up"); err != nil {
+		n.ip("link", "delete", hostIface)
+		return err
+	}
+	return nil
+}
+
+// MoveInterfaceToNamespace moves a host interface into a namespace
+func (n *NetworkNamespace) MoveInterfaceToNamespace(iface, nsName string) error {
+	if !ifaceNamePattern.MatchString(iface) {
+		return fmt.Errorf("invalid interface name %q", iface)
+	}
+	if !netnsNamePattern.MatchString(nsName) {
+		return fmt.Errorf("invalid namespace name %q", nsName)
+	}
+	return n.ip("link", "set", iface, "netns", nsName)
+}
+
+// ListNamespaces returns the names of the network namespaces in /run/netns
+func (n *NetworkNamespace) ListNamespaces() ([]string, error) {
+	entries, err := os.ReadDir(n.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", n.dir, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Provision creates the namespace of a container or VM, connects it to
+// ns.Bridge through a veth pair and records the association
+func (n *NetworkNamespace) Provision(ns models.NetworkNamespace) (*models.NetworkNamespace, error) {
+	if ns.ContainerID == "" || ns.ContainerType == "" {
+		return nil, fmt.Errorf("container ID and type are required")
+	}
+	if ns.Name == "" {
+		ns.Name = NamespaceName(ns.ContainerType, ns.ContainerID)
+	}
+	if ns.HostInterface == "" {
+		ns.HostInterface = HostInterfaceName(ns.Name)
+	}
+	if ns.IPv4Address != "" {
+		if prefix, err := netip.ParsePrefix(ns.IPv4Address); err != nil || !prefix.Addr().Is4() {
+			return nil, fmt.Errorf("invalid IPv4 address %q: must be in CIDR notation", ns.IPv4Address)
+		}
+	}
+	if ns.IPv4Gateway != "" {
+		if addr, err := netip.ParseAddr(ns.IPv4Gateway); err != nil || !addr.Is4() {
+			return nil, fmt.Errorf("invalid IPv4 gateway %q", ns.IPv4Gateway)
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := n.setup(ns); err != nil {
+		return nil, err
+	}
+	if err := n.db.Save(&ns).Error; err != nil {
+		n.ip("netns", "delete", ns.Name)
+		return nil, fmt.Errorf("failed to save namespace association: %w", err)
+	}
+	return &ns, nil
+}
+
+// Release deletes the namespace of a container or VM, if it has one
+func (n *NetworkNamespace) Release(containerType, containerID string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var ns models.NetworkNamespace
+	err := n.db.Where("container_type = ? AND container_id = ?", containerType, containerID).First(&ns).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load namespace association: %w", err)
+	}
+
+	if _, err := os.Stat(n.Path(ns.Name)); err == nil {
+		return n.Delete(ns.Name)
+	}
+	// Already gone, e.g. after a reboot
+	return n.db.Delete(&ns).Error
+}
+
+// Restore recreates the recorded namespaces that are missing, as
+// /run/netns does not survive a reboot
+func (n *NetworkNamespace) Restore() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var namespaces []models.NetworkNamespace
+	if err := n.db.Find(&namespaces).Error; err != nil {
+		return fmt.Errorf("failed to load namespace associations: %w", err)
+	}
+	names, err := n.ListNamespaces()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+
+	var failed []string
+	for _, ns := range namespaces {
+		if existing[ns.Name] {
+			continue
+		}
+		if err := n.setup(ns); err != nil {
+			logger.Warn("Failed to restore network namespace", zap.String("name", ns.Name), zap.Error(err))
+			failed = append(failed, ns.Name)
+			continue
+		}
+		logger.Info("Network namespace restored", zap.String("name", ns.Name), zap.String("container", ns.ContainerID))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore network namespaces: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// setup creates a namespace with its veth pair attached to the bridge and
+// its static address, removing it again if a step fails
+func (n *NetworkNamespace) setup(ns models.NetworkNamespace) error {
+	if err := n.Create(ns.Name); err != nil {
+		return err
+	}
+
+	err := n.AddVethPair(ns.Name, ns.HostInterface, NamespaceInterface)
+	if err == nil && ns.Bridge != "" {
+		err = n.ip("link", "set", ns.HostInterface, "master", ns.Bridge)
+	}
+	if err == nil && ns.IPv4Address != "" {
+		err = n.ip("-n", ns.Name, "addr", "add", ns.IPv4Address, "dev", NamespaceInterface)
+		if err == nil && ns.IPv4Gateway != "" {
+			err = n.ip("-n", ns.Name, "route", "add", "default", "via", ns.IPv4Gateway)
+		}
+	}
+	if err != nil {
+		n.ip("netns", "delete", ns.Name)
+		return err
+	}
+	return nil
+}
+
+// ip runs an ip command, including its stderr in the error
+func (n *NetworkNamespace) ip(args ...string) error {
+	return runIP(n.shell, args...)
+}
+
+// runIP runs an ip command, including its stderr in the error
+func runIP(shell executor.ShellExecutor, args ...string) error {
+	result, err := shell.Execute("ip", args...)
+	if err != nil {
+		stderr := ""
+		if result != nil {
+			stderr = strings.TrimSpace(result.Stderr)
+		}
+		return fmt.Errorf("ip %s failed: %s: %w", strings.Join(args, " "), stderr, err)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package network
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// fakeNetns simulates ip netns: it keeps the interfaces of each namespace,
+// with "" as the host namespace, and mirrors namespaces as files in dir
+type fakeNetns struct {
+	dir        string
+	interfaces map[string]map[string]bool
+	peers      map[string]string // Namespace of the peer of each host veth end
+	failVeth   bool
+}
+
+func (f *fakeNetns) handle(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+	args := call.Args
+	ns := ""
+	if len(args) > 2 && args[0] == "-n" {
+		ns, args = args[1], args[2:]
+		if f.interfaces[ns] == nil {
+			return &executor.CommandResult{Stderr: "Cannot open network namespace"}, fmt.Errorf("exit status 1")
+		}
+	}
+
+	line := strings.Join(args, " ")
+	switch {
+	case len(args) == 3 && args[0] == "netns" && args[1] == "add":
+		f.interfaces[args[2]] = map[string]bool{"lo": true}
+		os.WriteFile(filepath.Join(f.dir, args[2]), nil, 0600)
+	case len(args) == 3 && args[0] == "netns" && args[1] == "delete":
+		// Deleting a namespace destroys its veth ends and their peers
+		delete(f.interfaces, args[2])
+		for host, peerNs := range f.peers {
+			if peerNs == args[2] {
+				delete(f.interfaces[""], host)
+				delete(f.peers, host)
+			}
+		}
+		os.Remove(filepath.Join(f.dir, args[2]))
+	case len(args) == 10 && args[0] == "link" && args[1] == "add" && args[3] == "type" && args[4] == "veth":
+		if f.failVeth {
+			return &executor.CommandResult{Stderr: "RTNETLINK answers: File exists"}, fmt.Errorf("exit status 2")
+		}
+		f.interfaces[""][args[2]] = true
+		f.interfaces[args[9]][args[7]] = true
+		f.peers[args[2]] = args[9]
+	case len(args) >= 4 && args[0] == "link" && args[1] == "set":
+		if !f.interfaces[ns][args[2]] {
+			return &executor.CommandResult{Stderr: "Cannot find device"}, fmt.Errorf("exit status 1")
+		}
+	case args[0] == "addr" || args[0] == "route":
+		if ns == "" {
+			return nil, fmt.Errorf("address configured on the host: %s", line)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected ip command: %s", line)
+	}
+	return &executor.CommandResult{Success: true}, nil
+}
+
+func newTestNetworkNamespace(t *testing.T) (*NetworkNamespace, *fakeNetns, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "network.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.NetworkNamespace{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	fake := &fakeNetns{
+		dir:        t.TempDir(),
+		interfaces: map[string]map[string]bool{"": {"lxcbr0": true}},
+		peers:      map[string]string{},
+	}
+	shell := executor.NewMockShellExecutor()
+	shell.Handler = fake.handle
+	n, err := NewNetworkNamespace(db, shell)
+	if err != nil {
+		t.Fatalf("NewNetworkNamespace: %v", err)
+	}
+	n.dir = fake.dir
+	return n, fake, shell
+}
+
+func TestProvisionCreatesVethPair(t *testing.T) {
+	n, fake, shell := newTestNetworkNamespace(t)
+
+	ns, err := n.Provision(models.NetworkNamespace{
+		ContainerID:   "web",
+		ContainerType: models.NamespaceContainerLXC,
+		Bridge:        "lxcbr0",
+		IPv4Address:   "10.0.3.10/24",
+		IPv4Gateway:   "10.0.3.1",
+	})
+	if err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+	if ns.Name != "lxc-web" || ns.HostInterface != HostInterfaceName("lxc-web") {
+		t.Errorf("Provision = %+v, want namespace lxc-web", ns)
+	}
+
+	host := ns.HostInterface
+	want := [][]string{
+		{"netns", "add", "lxc-web"},
+		{"-n", "lxc-web", "link", "set", "lo", "up"},
+		{"link", "add", host, "type", "veth", "peer", "name", "eth0", "netns", "lxc-web"},
+		{"link", "set", host, "up"},
+		{"-n", "lxc-web", "link", "set", "eth0", "up"},
+		{"link", "set", host, "master", "lxcbr0"},
+		{"-n", "lxc-web", "addr", "add", "10.0.3.10/24", "dev", "eth0"},
+		{"-n", "lxc-web", "route", "add", "default", "via", "10.0.3.1"},
+	}
+	calls := shell.CallsTo("ip")
+	if len(calls) != len(want) {
+		t.Fatalf("got %d ip calls, want %d: %v", len(calls), len(want), calls)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(calls[i].Args, want[i]) {
+			t.Errorf("ip call %d = %v, want %v", i, calls[i].Args, want[i])
+		}
+	}
+
+	names, err := n.ListNamespaces()
+	if err != nil || !reflect.DeepEqual(names, []string{"lxc-web"}) {
+		t.Errorf("ListNamespaces = %v, %v; want [lxc-web]", names, err)
+	}
+	if !fake.interfaces[""][host] || fake.interfaces[""]["eth0"] {
+		t.Errorf("host interfaces = %v, want the host end of the veth pair but not eth0", fake.interfaces[""])
+	}
+}
+
+func TestNamespacesAreIsolated(t *testing.T) {
+	n, fake, _ := newTestNetworkNamespace(t)
+
+	web, err := n.Provision(models.NetworkNamespace{ContainerID: "web", ContainerType: models.NamespaceContainerLXC, Bridge: "lxcbr0"})
+	if err != nil {
+		t.Fatalf("Provision web: %v", err)
+	}
+	db, err := n.Provision(models.NetworkNamespace{ContainerID: "db", ContainerType: models.NamespaceContainerLXC, Bridge: "lxcbr0"})
+	if err != nil {
+		t.Fatalf("Provision db: %v", err)
+	}
+
+	// Both containers get an eth0 of their own, connected by distinct host interfaces
+	if !fake.interfaces[web.Name]["eth0"] || !fake.interfaces[db.Name]["eth0"] {
+		t.Errorf("namespace interfaces = %v, want eth0 in both namespaces", fake.interfaces)
+	}
+	if web.HostInterface == db.HostInterface {
+		t.Errorf("both namespaces use host interface %s", web.HostInterface)
+	}
+
+	// Releasing one container leaves the other alone
+	if err := n.Release(models.NamespaceContainerLXC, "web"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	names, _ := n.ListNamespaces()
+	if !reflect.DeepEqual(names, []string{"lxc-db"}) {
+		t.Errorf("ListNamespaces after release = %v, want [lxc-db]", names)
+	}
+	var count int64
+	n.db.Model(&models.NetworkNamespace{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d namespace associations left, want 1", count)
+	}
+}
+
+func TestProvisionRemovesNamespaceOnVethFailure(t *testing.T) {
+	n, fake, _ := newTestNetworkNamespace(t)
+	fake.failVeth = true
+
+	if _, err := n.Provision(models.NetworkNamespace{ContainerID: "web", ContainerType: models.NamespaceContainerLXC}); err == nil {
+		t.Fatal("Provision succeeded although the veth pair could not be created")
+	}
+	if names, _ := n.ListNamespaces(); len(names) != 0 {
+		t.Errorf("ListNamespaces = %v, want the namespace removed again", names)
+	}
+}
+
+func TestRestoreRecreatesMissingNamespaces(t *testing.T) {
+	n, fake, _ := newTestNetworkNamespace(t)
+
+	if _, err := n.Provision(models.NetworkNamespace{ContainerID: "web", ContainerType: models.NamespaceContainerLXC, Bridge: "lxcbr0"}); err != nil {
+		t.Fatalf("Provision: %v", err)
+	}
+
+	// A reboot clears /run/netns
+	fake.interfaces = map[string]map[string]bool{"": {"lxcbr0": true}}
+	fake.peers = map[string]string{}
+	os.Remove(filepath.Join(fake.dir, "lxc-web"))
+
+	if err := n.Restore(); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if !fake.interfaces["lxc-web"]["eth0"] {
+		t.Errorf("namespace interfaces = %v, want lxc-web restored with eth0", fake.interfaces)
+	}
+}
@@ -7,23 +7,36 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 )
 
+// ifInet6Path lists the IPv6 addresses of all interfaces
+var ifInet6Path = "/proc/net/if_inet6"
+
+// procNetDevPath lists the traffic counters of all interfaces
+var procNetDevPath = "/proc/net/dev"
+
+// sysClassNetPath holds a directory per interface
+var sysClassNetPath = "/sys/class/net"
+
 // Interface represents a network interface
 type Interface struct {
-	Name         string   `json:"name"`
-	Index        int      `json:"index"`
-	HardwareAddr string   `json:"hardwareAddr"`
-	Flags        []string `json:"flags"`
-	MTU          int      `json:"mtu"`
-	Addresses    []string `json:"addresses"`
-	IsUp         bool     `json:"isUp"`
-	Speed        string   `json:"speed"`
-	Type         string   `json:"type"`
+	Name          string   `json:"name"`
+	Index         int      `json:"index"`
+	HardwareAddr  string   `json:"hardwareAddr"`
+	Flags         []string `json:"flags"`
+	MTU           int      `json:"mtu"`
+	Addresses     []string `json:"addresses"`
+	IPv4Addresses []string `json:"ipv4Addresses"`
+	IPv6Addresses []string `json:"ipv6Addresses"`
+	IsUp          bool     `json:"isUp"`
+	Speed         string   `json:"speed"`
+	Type          string   `json:"type"`
 }
 
 // InterfaceStats represents network interface statistics
@@ -37,6 +50,7 @@ type InterfaceStats struct {
 	TxErrors    uint64 `json:"txErrors"`
 	RxDropped   uint64 `json:"rxDropped"`
 	TxDropped   uint64 `json:"txDropped"`
+	Type        string `json:"type"` // As in Interface, e.g. vpn for WireGuard tunnels
 }
 
 // Route represents a network route
@@ -89,8 +103,17 @@ func ListInterfaces() ([]Interface, error) {
 		}
 
 		var addresses []string
+		ipv4Addresses := []string{}
+		ipv6Addresses := []string{}
 		for _, addr := range addrs {
 			addresses = append(addresses, addr.String())
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				if ipNet.IP.To4() != nil {
+					ipv4Addresses = append(ipv4Addresses, addr.String())
+				} else {
+					ipv6Addresses = append(ipv6Addresses, addr.String())
+				}
+			}
 		}
 
 		flags := []string{}
@@ -107,37 +130,49 @@ func ListInterfaces() ([]Interface, error) {
 			flags = append(flags, "MULTICAST")
 		}
 
-		// Determine interface type
-		ifaceType := "ethernet"
-		if strings.HasPrefix(iface.Name, "wl") || strings.HasPrefix(iface.Name, "wifi") {
-			ifaceType = "wireless"
-		} else if strings.HasPrefix(iface.Name, "lo") {
-			ifaceType = "loopback"
-		} else if strings.HasPrefix(iface.Name, "br") {
-			ifaceType = "bridge"
-		} else if strings.HasPrefix(iface.Name, "veth") || strings.HasPrefix(iface.Name, "docker") {
-			ifaceType = "virtual"
-		}
+		ifaceType := interfaceType(iface.Name)
 
 		// Get interface speed
 		speed := getInterfaceSpeed(iface.Name)
 
 		result = append(result, Interface{
-			Name:         iface.Name,
-			Index:        iface.Index,
-			HardwareAddr: iface.HardwareAddr.String(),
-			Flags:        flags,
-			MTU:          iface.MTU,
-			Addresses:    addresses,
-			IsUp:         iface.Flags&net.FlagUp != 0,
-			Speed:        speed,
-			Type:         ifaceType,
+			Name:          iface.Name,
+			Index:         iface.Index,
+			HardwareAddr:  iface.HardwareAddr.String(),
+			Flags:         flags,
+			MTU:           iface.MTU,
+			Addresses:     addresses,
+			IPv4Addresses: ipv4Addresses,
+			IPv6Addresses: ipv6Addresses,
+			IsUp:          iface.Flags&net.FlagUp != 0,
+			Speed:         speed,
+			Type:          ifaceType,
 		})
 	}
 
 	return result, nil
 }
 
+// interfaceType determines the type of an interface from its name, or from
+// its device type for WireGuard tunnels, which can have any name
+func interfaceType(name string) string {
+	if uevent, err := os.ReadFile(filepath.Join(sysClassNetPath, name, "uevent")); err == nil &&
+		strings.Contains(string(uevent), "DEVTYPE=wireguard") {
+		return "vpn"
+	}
+
+	if strings.HasPrefix(name, "wl") || strings.HasPrefix(name, "wifi") {
+		return "wireless"
+	} else if strings.HasPrefix(name, "lo") {
+		return "loopback"
+	} else if strings.HasPrefix(name, "br") {
+		return "bridge"
+	} else if strings.HasPrefix(name, "veth") || strings.HasPrefix(name, "docker") {
+		return "virtual"
+	}
+	return "ethernet"
+}
+
 // getInterfaceSpeed tries to get interface speed from sysfs
 func getInterfaceSpeed(name string) string {
 	speedFile := fmt.Sprintf("/sys/class/net/%s/speed", name)
@@ -155,11 +190,12 @@ func getInterfaceSpeed(name string) string {
 	return "Unknown"
 }
 
-// GetInterfaceStats returns statistics for all interfaces
+// GetInterfaceStats returns statistics for all interfaces, including
+// WireGuard tunnels so VPN traffic shows up next to the physical interfaces
 func GetInterfaceStats() ([]InterfaceStats, error) {
-	data, err := os.ReadFile("/proc/net/dev")
+	data, err := os.ReadFile(procNetDevPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read /proc/net/dev: %w", err)
+		return nil, fmt.Errorf("failed to read %s: %w", procNetDevPath, err)
 	}
 
 	var stats []InterfaceStats
@@ -180,6 +216,7 @@ func GetInterfaceStats() ([]InterfaceStats, error) {
 
 		stat := InterfaceStats{
 			Name: name,
+			Type: interfaceType(name),
 		}
 
 		// Parse statistics (format: bytes packets errs drop fifo frame compressed multicast)
@@ -216,73 +253,152 @@ func GetInterfaceStats() ([]InterfaceStats, error) {
 
 // SetInterfaceUp brings an interface up
 func SetInterfaceUp(name string) error {
-	cmd := exec.Command("ip", "link", "set", name, "up")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to bring interface up: %s", string(output))
+	if output, err := runCommand("ip", "link", "set", name, "up"); err != nil {
+		return fmt.Errorf("failed to bring interface up: %s", output)
 	}
 	return nil
 }
 
 // SetInterfaceDown brings an interface down
 func SetInterfaceDown(name string) error {
-	cmd := exec.Command("ip", "link", "set", name, "down")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to bring interface down: %s", string(output))
+	if output, err := runCommand("ip", "link", "set", name, "down"); err != nil {
+		return fmt.Errorf("failed to bring interface down: %s", output)
 	}
 	return nil
 }
 
-// ConfigureStaticIP configures a static IP address on an interface
-func ConfigureStaticIP(name, ipAddress, netmask, gateway string) error {
-	// Remove existing IP addresses
-	cmd := exec.Command("ip", "addr", "flush", "dev", name)
-	cmd.Run()
+// ConfigureIPAddress configures static IPv4 and IPv6 addresses on an interface.
+// Leave ipAddress or ipv6Address empty to keep the addresses of that family.
+// The IPv6 address takes a prefix length, e.g. 2001:db8::10/64 or /128 for a
+// single host, and defaults to /64. Link-local addresses are managed by the
+// kernel and kept.
+func ConfigureIPAddress(name, ipAddress, netmask, gateway, ipv6Address, ipv6Gateway string) error {
+	var ipv6CIDR string
+	if ipv6Address != "" {
+		var err error
+		if ipv6CIDR, err = staticIPv6CIDR(ipv6Address); err != nil {
+			return err
+		}
+	}
+
+	if ipAddress != "" {
+		// Remove existing IPv4 addresses
+		runCommand("ip", "-4", "addr", "flush", "dev", name)
+
+		// Calculate CIDR notation
+		cidr := calculateCIDR(netmask)
+		ipWithCIDR := fmt.Sprintf("%s/%d", ipAddress, cidr)
 
-	// Calculate CIDR notation
-	cidr := calculateCIDR(netmask)
-	ipWithCIDR := fmt.Sprintf("%s/%d", ipAddress, cidr)
+		// Add new IP address
+		if output, err := runCommand("ip", "addr", "add", ipWithCIDR, "dev", name); err != nil {
+			return fmt.Errorf("failed to set IP: %s", output)
+		}
+
+		// Set default gateway if provided
+		if gateway != "" {
+			// Remove existing default route
+			runCommand("ip", "route", "del", "default")
 
-	// Add new IP address
-	cmd = exec.Command("ip", "addr", "add", ipWithCIDR, "dev", name)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to set IP: %s", string(output))
+			// Add new default route
+			if output, err := runCommand("ip", "route", "add", "default", "via", gateway, "dev", name); err != nil {
+				return fmt.Errorf("failed to set gateway: %s", output)
+			}
+		}
 	}
 
-	// Set default gateway if provided
-	if gateway != "" {
-		// Remove existing default route
-		exec.Command("ip", "route", "del", "default").Run()
+	if ipv6CIDR != "" {
+		// Remove existing global IPv6 addresses, keeping the link-local address
+		runCommand("ip", "-6", "addr", "flush", "dev", name, "scope", "global")
 
-		// Add new default route
-		cmd = exec.Command("ip", "route", "add", "default", "via", gateway, "dev", name)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to set gateway: %s", string(output))
+		if output, err := runCommand("ip", "-6", "addr", "add", ipv6CIDR, "dev", name); err != nil {
+			return fmt.Errorf("failed to set IPv6 address: %s", output)
+		}
+
+		if ipv6Gateway != "" {
+			runCommand("ip", "-6", "route", "del", "default")
+
+			if output, err := runCommand("ip", "-6", "route", "add", "default", "via", ipv6Gateway, "dev", name); err != nil {
+				return fmt.Errorf("failed to set IPv6 gateway: %s", output)
+			}
 		}
 	}
 
 	return nil
 }
 
+// staticIPv6CIDR validates an IPv6 address for static assignment and returns
+// it in CIDR notation, defaulting to a /64 prefix
+func staticIPv6CIDR(address string) (string, error) {
+	if !strings.Contains(address, "/") {
+		address += "/64"
+	}
+	if !sysutil.ValidateIPv6CIDR(address) {
+		return "", fmt.Errorf("invalid IPv6 address: %s", address)
+	}
+
+	ip, ipNet, _ := net.ParseCIDR(address)
+	if ip.IsLinkLocalUnicast() {
+		return "", fmt.Errorf("link-local address %s cannot be assigned statically", ip)
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	return fmt.Sprintf("%s/%d", ip, ones), nil
+}
+
+// GetIPv6LinkLocal returns the IPv6 link-local address of an interface
+func GetIPv6LinkLocal(iface string) (string, error) {
+	data, err := os.ReadFile(ifInet6Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read IPv6 addresses: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// Format: address ifindex prefixlen scope flags name
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[5] != iface || len(fields[0]) != 32 {
+			continue
+		}
+
+		ip := make(net.IP, net.IPv6len)
+		valid := true
+		for i := range ip {
+			b, err := strconv.ParseUint(fields[0][i*2:i*2+2], 16, 8)
+			if err != nil {
+				valid = false
+				break
+			}
+			ip[i] = byte(b)
+		}
+		if valid && ip.IsLinkLocalUnicast() {
+			return ip.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("interface %s has no IPv6 link-local address", iface)
+}
+
 // ConfigureDHCP configures an interface to use DHCP
 func ConfigureDHCP(name string) error {
 	// This would typically require dhclient or dhcpcd
-	cmd := exec.Command("dhclient", name)
-	if _, err := cmd.CombinedOutput(); err != nil {
+	if _, err := runCommand("dhclient", name); err != nil {
 		// Try dhcpcd as fallback
-		cmd = exec.Command("dhcpcd", name)
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to configure DHCP: %s", string(output))
+		if output, err := runCommand("dhcpcd", name); err != nil {
+			return fmt.Errorf("failed to configure DHCP: %s", output)
 		}
 	}
 	return nil
 }
 
-// calculateCIDR converts netmask to CIDR notation
+// calculateCIDR converts an IPv4 or IPv6 netmask to CIDR notation
 func calculateCIDR(netmask string) int {
-	ip := net.ParseIP(netmask).To4()
+	ip := net.ParseIP(netmask)
 	if ip == nil {
 		return 24 // default
 	}
+	if ip4 := ip.To4(); ip4 != nil && !strings.Contains(netmask, ":") {
+		ip = ip4
+	}
 
 	var cidr int
 	for _, octet := range ip {
@@ -296,14 +412,13 @@ func calculateCIDR(netmask string) int {
 
 // GetRoutes returns the routing table
 func GetRoutes() ([]Route, error) {
-	cmd := exec.Command("ip", "route", "show")
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand("ip", "route", "show")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get routes: %w", err)
 	}
 
 	var routes []Route
-	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -367,10 +482,9 @@ func AddRoute(destination, gateway, iface string, metric int) error {
 		args = append(args, "metric", strconv.Itoa(metric))
 	}
 
-	cmd := exec.Command("ip", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand("ip", args...)
 	if err != nil {
-		return fmt.Errorf("failed to add route: %w: %s", err, string(output))
+		return fmt.Errorf("failed to add route: %w: %s", err, output)
 	}
 
 	return nil
@@ -390,10 +504,9 @@ func DeleteRoute(destination, gateway, iface string) error {
 		args = append(args, "dev", iface)
 	}
 
-	cmd := exec.Command("ip", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand("ip", args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete route: %w: %s", err, string(output))
+		return fmt.Errorf("failed to delete route: %w: %s", err, output)
 	}
 
 	return nil
@@ -449,17 +562,17 @@ func SetDNSConfig(nameservers []string, searchDomains []string) error {
 		content.WriteString(fmt.Sprintf("search %s\n", strings.Join(searchDomains, " ")))
 	}
 
-	return os.WriteFile("/etc/resolv.conf", []byte(content.String()), 0644)
+	return sysutil.AtomicWriteFile("/etc/resolv.conf", []byte(content.String()), 0644)
 }
 
 // Ping executes a ping command
 func Ping(host string, count int) (*DiagnosticResult, error) {
-	cmd := exec.Command("ping", "-c", strconv.Itoa(count), host)
-	output, err := cmd.CombinedOutput()
+	// ping sends one probe per second; allow for the reply timeout of the last
+	output, err := runCommandWithTimeout(time.Duration(count+10)*time.Second, "ping", "-c", strconv.Itoa(count), host)
 
 	result := &DiagnosticResult{
 		Command: fmt.Sprintf("ping -c %d %s", count, host),
-		Output:  string(output),
+		Output:  output,
 		Success: err == nil,
 	}
 
@@ -472,12 +585,12 @@ func Ping(host string, count int) (*DiagnosticResult, error) {
 
 // Traceroute executes a traceroute command
 func Traceroute(host string) (*DiagnosticResult, error) {
-	cmd := exec.Command("traceroute", host)
-	output, err := cmd.CombinedOutput()
+	// traceroute waits up to 5s for each of 30 hops
+	output, err := runCommandWithTimeout(3*time.Minute, "traceroute", host)
 
 	result := &DiagnosticResult{
 		Command: fmt.Sprintf("traceroute %s", host),
-		Output:  string(output),
+		Output:  output,
 		Success: err == nil,
 	}
 
@@ -497,18 +610,16 @@ func Netstat(options string) (*DiagnosticResult, error) {
 		args = []string{"-tuln"}
 	}
 
-	cmd := exec.Command("netstat", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand("netstat", args...)
 
 	// Try ss if netstat is not available
 	if err != nil {
-		cmd = exec.Command("ss", args...)
-		output, err = cmd.CombinedOutput()
+		output, err = runCommand("ss", args...)
 	}
 
 	result := &DiagnosticResult{
 		Command: fmt.Sprintf("netstat %s", strings.Join(args, " ")),
-		Output:  string(output),
+		Output:  output,
 		Success: err == nil,
 	}
 
@@ -555,16 +666,14 @@ func WakeOnLAN(macAddress string) error {
 // This safely migrates IP addresses from physical interfaces to the bridge
 func CreateBridge(name string, ports []string) error {
 	// Create the bridge
-	cmd := exec.Command("ip", "link", "add", name, "type", "bridge")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to create bridge: %s", string(output))
+	if output, err := runCommand("ip", "link", "add", name, "type", "bridge"); err != nil {
+		return fmt.Errorf("failed to create bridge: %s", output)
 	}
 
 	// Bring the bridge up immediately
-	cmd = exec.Command("ip", "link", "set", name, "up")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		exec.Command("ip", "link", "delete", name, "type", "bridge").Run()
-		return fmt.Errorf("failed to bring bridge up: %s", string(output))
+	if output, err := runCommand("ip", "link", "set", name, "up"); err != nil {
+		runCommand("ip", "link", "delete", name, "type", "bridge")
+		return fmt.Errorf("failed to bring bridge up: %s", output)
 	}
 
 	// Add ports to the bridge if specified
@@ -586,64 +695,59 @@ func CreateBridge(name string, ports []string) error {
 		if len(portAddrs) > 0 {
 			// Step 1: Assign IP addresses to the bridge
 			for _, addr := range portAddrs {
-				cmd = exec.Command("ip", "addr", "add", addr, "dev", name)
-				cmd.CombinedOutput() // Ignore errors, address might already exist
+				runCommand("ip", "addr", "add", addr, "dev", name) // Ignore errors, address might already exist
 			}
 
 			// Step 2: If there's a default gateway, add it via the bridge
 			if gateway != "" {
 				// Remove old default route
-				exec.Command("ip", "route", "del", "default").Run()
+				runCommand("ip", "route", "del", "default")
 
 				// Add new default route via bridge
-				cmd = exec.Command("ip", "route", "add", "default", "via", gateway, "dev", name)
-				cmd.CombinedOutput()
+				runCommand("ip", "route", "add", "default", "via", gateway, "dev", name)
 			}
 
 			// Step 3: Now it's safe to remove IPs from the port (before attaching to bridge)
-			cmd = exec.Command("ip", "addr", "flush", "dev", port)
-			cmd.Run()
+			runCommand("ip", "addr", "flush", "dev", port)
 		}
 
 		// Step 4: Attach port to bridge (port can stay UP)
-		cmd = exec.Command("ip", "link", "set", port, "master", name)
-		if output, err := cmd.CombinedOutput(); err != nil {
+		if output, err := runCommand("ip", "link", "set", port, "master", name); err != nil {
 			// If attachment fails, try to restore IPs to the port
 			for _, addr := range portAddrs {
-				exec.Command("ip", "addr", "add", addr, "dev", port).Run()
+				runCommand("ip", "addr", "add", addr, "dev", port)
 			}
 			if gateway != "" {
-				exec.Command("ip", "route", "del", "default").Run()
-				exec.Command("ip", "route", "add", "default", "via", gateway, "dev", port).Run()
+				runCommand("ip", "route", "del", "default")
+				runCommand("ip", "route", "add", "default", "via", gateway, "dev", port)
 			}
 			// Clean up the bridge
-			exec.Command("ip", "link", "delete", name, "type", "bridge").Run()
-			return fmt.Errorf("failed to attach port %s to bridge: %s", port, string(output))
+			runCommand("ip", "link", "delete", name, "type", "bridge")
+			return fmt.Errorf("failed to attach port %s to bridge: %s", port, output)
 		}
 
 		// Step 5: Ensure port is up as a bridge port
-		exec.Command("ip", "link", "set", port, "up").Run()
+		runCommand("ip", "link", "set", port, "up")
 	}
 
 	// Step 6: Add iptables rules to allow forwarding through the bridge
 	// This is essential for containers/VMs to communicate with the external network
-	exec.Command("iptables", "-I", "FORWARD", "-i", name, "-o", name, "-j", "ACCEPT").Run()
-	exec.Command("iptables", "-I", "FORWARD", "-i", name, "-j", "ACCEPT").Run()
-	exec.Command("iptables", "-I", "FORWARD", "-o", name, "-j", "ACCEPT").Run()
+	runCommand("iptables", "-I", "FORWARD", "-i", name, "-o", name, "-j", "ACCEPT")
+	runCommand("iptables", "-I", "FORWARD", "-i", name, "-j", "ACCEPT")
+	runCommand("iptables", "-I", "FORWARD", "-o", name, "-j", "ACCEPT")
 
 	return nil
 }
 
 // getInterfaceAddresses retrieves IP addresses configured on an interface
 func getInterfaceAddresses(ifaceName string) ([]string, error) {
-	cmd := exec.Command("ip", "-o", "addr", "show", ifaceName)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand("ip", "-o", "addr", "show", ifaceName)
 	if err != nil {
 		return nil, err
 	}
 
 	var addresses []string
-	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := scanner.Text()
 		fields := strings.Fields(line)
@@ -665,14 +769,13 @@ func getInterfaceAddresses(ifaceName string) ([]string, error) {
 
 // getDefaultGatewayForInterface finds the default gateway for a specific interface
 func getDefaultGatewayForInterface(ifaceName string) (string, error) {
-	cmd := exec.Command("ip", "route", "show", "default", "dev", ifaceName)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand("ip", "route", "show", "default", "dev", ifaceName)
 	if err != nil {
 		return "", err
 	}
 
 	// Parse: default via <gateway> dev <iface> ...
-	fields := strings.Fields(string(output))
+	fields := strings.Fields(output)
 	for i, field := range fields {
 		if field == "via" && i+1 < len(fields) {
 			return fields[i+1], nil
@@ -685,11 +788,10 @@ func getDefaultGatewayForInterface(ifaceName string) (string, error) {
 // DeleteBridge deletes a bridge interface
 func DeleteBridge(name string) error {
 	// Get all ports attached to this bridge
-	cmd := exec.Command("ip", "link", "show", "master", name)
-	output, _ := cmd.CombinedOutput()
+	output, _ := runCommand("ip", "link", "show", "master", name)
 
 	// Parse output to find ports
-	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.Contains(line, ":") {
@@ -698,19 +800,18 @@ func DeleteBridge(name string) error {
 				portName := strings.TrimSuffix(fields[1], ":")
 				if portName != name {
 					// Remove port from bridge
-					exec.Command("ip", "link", "set", portName, "nomaster").Run()
+					runCommand("ip", "link", "set", portName, "nomaster")
 				}
 			}
 		}
 	}
 
 	// Bring bridge down
-	exec.Command("ip", "link", "set", name, "down").Run()
+	runCommand("ip", "link", "set", name, "down")
 
 	// Delete the bridge
-	cmd = exec.Command("ip", "link", "delete", name, "type", "bridge")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to delete bridge: %s", string(output))
+	if output, err := runCommand("ip", "link", "delete", name, "type", "bridge"); err != nil {
+		return fmt.Errorf("failed to delete bridge: %s", output)
 	}
 
 	return nil
@@ -731,43 +832,38 @@ func AttachPortToBridge(bridgeName string, portName string) error {
 	if len(portAddrs) > 0 {
 		// Step 1: Assign IP addresses to the bridge
 		for _, addr := range portAddrs {
-			cmd := exec.Command("ip", "addr", "add", addr, "dev", bridgeName)
-			cmd.CombinedOutput() // Ignore errors, address might already exist
+			runCommand("ip", "addr", "add", addr, "dev", bridgeName) // Ignore errors, address might already exist
 		}
 
 		// Step 2: If there's a default gateway, migrate it to the bridge
 		if gateway != "" {
 			// Remove old default route
-			exec.Command("ip", "route", "del", "default").Run()
+			runCommand("ip", "route", "del", "default")
 
 			// Add new default route via bridge
-			cmd := exec.Command("ip", "route", "add", "default", "via", gateway, "dev", bridgeName)
-			cmd.CombinedOutput()
+			runCommand("ip", "route", "add", "default", "via", gateway, "dev", bridgeName)
 		}
 
 		// Step 3: Now it's safe to remove IPs from the port
-		cmd := exec.Command("ip", "addr", "flush", "dev", portName)
-		cmd.Run()
+		runCommand("ip", "addr", "flush", "dev", portName)
 	}
 
 	// Step 4: Attach port to bridge (port can stay UP)
-	cmd := exec.Command("ip", "link", "set", portName, "master", bridgeName)
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := runCommand("ip", "link", "set", portName, "master", bridgeName); err != nil {
 		// If attachment fails, try to restore IPs to the port
 		for _, addr := range portAddrs {
-			exec.Command("ip", "addr", "add", addr, "dev", portName).Run()
+			runCommand("ip", "addr", "add", addr, "dev", portName)
 		}
 		if gateway != "" {
-			exec.Command("ip", "route", "del", "default").Run()
-			exec.Command("ip", "route", "add", "default", "via", gateway, "dev", portName).Run()
+			runCommand("ip", "route", "del", "default")
+			runCommand("ip", "route", "add", "default", "via", gateway, "dev", portName)
 		}
-		return fmt.Errorf("failed to attach port to bridge: %s", string(output))
+		return fmt.Errorf("failed to attach port to bridge: %s", output)
 	}
 
 	// Step 5: Ensure port is up as a bridge port
-	cmd = exec.Command("ip", "link", "set", portName, "up")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to bring port up: %s", string(output))
+	if output, err := runCommand("ip", "link", "set", portName, "up"); err != nil {
+		return fmt.Errorf("failed to bring port up: %s", output)
 	}
 
 	return nil
@@ -776,9 +872,8 @@ func AttachPortToBridge(bridgeName string, portName string) error {
 // DetachPortFromBridge detaches an interface from a bridge
 func DetachPortFromBridge(portName string) error {
 	// Remove port from bridge
-	cmd := exec.Command("ip", "link", "set", portName, "nomaster")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to detach port from bridge: %s", string(output))
+	if output, err := runCommand("ip", "link", "set", portName, "nomaster"); err != nil {
+		return fmt.Errorf("failed to detach port from bridge: %s", output)
 	}
 
 	return nil
@@ -786,15 +881,14 @@ func DetachPortFromBridge(portName string) error {
 
 // ListBridges returns a list of all bridge interfaces
 func ListBridges() ([]string, error) {
-	cmd := exec.Command("ip", "-o", "link", "show", "type", "bridge")
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand("ip", "-o", "link", "show", "type", "bridge")
 	if err != nil {
 		// If no bridges exist, this is not an error
 		return []string{}, nil
 	}
 
 	var bridges []string
-	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := scanner.Text()
 		// Format: index: bridge_name: <BROADCAST,MULTICAST,UP,LOWER_UP> ...
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+)
+
+func mockHostShell(t *testing.T) *executor.MockShellExecutor {
+	t.Helper()
+	shell := executor.NewMockShellExecutor()
+	realShell := hostShell
+	hostShell = shell
+	t.Cleanup(func() { hostShell = realShell })
+	return shell
+}
+
+func TestGetRoutes(t *testing.T) {
+	shell := mockHostShell(t)
+	shell.ExpectCommand("ip", "route", "show").Returns(
+		"default via 192.168.1.1 dev eth0 proto static metric 100\n"+
+			"192.168.1.0/24 dev eth0 proto kernel scope link src 192.168.1.10", "", 0)
+
+	routes, err := GetRoutes()
+	if err != nil {
+		t.Fatalf("GetRoutes: %v", err)
+	}
+	want := []Route{
+		{Destination: "default", Gateway: "192.168.1.1", Iface: "eth0", Metric: 100},
+		{Destination: "192.168.1.0/24", Iface: "eth0"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("GetRoutes() = %+v, want %+v", routes, want)
+	}
+	shell.AssertExpectations(t)
+}
+
+func TestSetInterfaceUpReportsOutput(t *testing.T) {
+	shell := mockHostShell(t)
+	shell.ExpectCommand("ip", "link", "set", "eth9", "up").
+		Returns("", `Cannot find device "eth9"`, 1)
+
+	err := SetInterfaceUp("eth9")
+	if err == nil || !strings.Contains(err.Error(), `Cannot find device "eth9"`) {
+		t.Errorf("SetInterfaceUp() error = %v, want the ip output", err)
+	}
+}
+
+func TestGetInterfaceStatsIncludesWireGuard(t *testing.T) {
+	dir := t.TempDir()
+	procNetDev := filepath.Join(dir, "dev")
+	netDev := "Inter-|   Receive                                                |  Transmit\n" +
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n" +
+		"    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0\n" +
+		"  eth0: 5000000    4000    1    2    0     0          0         0  3000000    2500    0    0    0     0       0          0\n" +
+		" site1:   70000     300    0    0    0     0          0         0    90000     350    0    0    0     0       0          0\n"
+	if err := os.WriteFile(procNetDev, []byte(netDev), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "class", "site1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "class", "site1", "uevent"), []byte("DEVTYPE=wireguard\nINTERFACE=site1\nIFINDEX=7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	realNetDev, realClassNet := procNetDevPath, sysClassNetPath
+	procNetDevPath, sysClassNetPath = procNetDev, filepath.Join(dir, "class")
+	t.Cleanup(func() { procNetDevPath, sysClassNetPath = realNetDev, realClassNet })
+
+	stats, err := GetInterfaceStats()
+	if err != nil {
+		t.Fatalf("GetInterfaceStats: %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("got %d interfaces: %+v", len(stats), stats)
+	}
+	if stats[1].Type != "ethernet" || stats[1].RxBytes != 5000000 || stats[1].TxDropped != 0 || stats[1].RxDropped != 2 {
+		t.Errorf("eth0 = %+v", stats[1])
+	}
+	if stats[2].Name != "site1" || stats[2].Type != "vpn" || stats[2].RxBytes != 70000 || stats[2].TxBytes != 90000 {
+		t.Errorf("site1 = %+v", stats[2])
+	}
+}
@@ -0,0 +1,290 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// portForwardComment tags the iptables rules of a port forward, so they can
+// be told apart from rules added by other tools
+const portForwardComment = "stumpfworks-pf-"
+
+// ErrPortForwardNotFound is returned for unknown port forward IDs
+var ErrPortForwardNotFound = errors.New("port forward rule not found")
+
+// PortForwardRule forwards an external port of the NAS to a host on the
+// local network
+type PortForwardRule struct {
+	ID           string `json:"id"`
+	ExternalPort int    `json:"externalPort"`
+	InternalIP   string `json:"internalIp"`
+	InternalPort string `json:"internalPort"`
+	Protocol     string `json:"protocol"` // tcp, udp
+	Description  string `json:"description"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// Validate checks the ports and protocol, and that the rule forwards to a
+// private IPv4 address
+func (r *PortForwardRule) Validate() error {
+	if r.ExternalPort < 1 || r.ExternalPort > 65535 {
+		return fmt.Errorf("invalid external port %d", r.ExternalPort)
+	}
+	if port, err := strconv.Atoi(r.InternalPort); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid internal port %q", r.InternalPort)
+	}
+	if r.Protocol != "tcp" && r.Protocol != "udp" {
+		return fmt.Errorf("invalid protocol %q (use tcp or udp)", r.Protocol)
+	}
+	ip := net.ParseIP(r.InternalIP)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid internal IPv4 address %q", r.InternalIP)
+	}
+	if !sysutil.IsPrivateIP(r.InternalIP) {
+		return fmt.Errorf("internal IP %s is not in a private range", r.InternalIP)
+	}
+	return nil
+}
+
+// PortForwardingManager manages port forwards as iptables DNAT rules and
+// persists them, as iptables rules do not survive a reboot
+type PortForwardingManager struct {
+	db    *gorm.DB
+	shell executor.ShellExecutor
+	mu    sync.Mutex
+}
+
+// NewPortForwardingManager creates a port forwarding manager
+func NewPortForwardingManager(db *gorm.DB, shell executor.ShellExecutor) (*PortForwardingManager, error) {
+	if !shell.CommandExists("iptables") {
+		return nil, fmt.Errorf("iptables not installed (install 'iptables' package)")
+	}
+	return &PortForwardingManager{db: db, shell: shell}, nil
+}
+
+// iptablesArgs returns the arguments of the DNAT rule and the rule
+// accepting the forwarded traffic, for the iptables operation op (-A, -D
+// or -C)
+func iptablesArgs(op string, rule *PortForwardRule) [][]string {
+	comment := portForwardComment + rule.ID
+	return [][]string{
+		{"-t", "nat", op, "PREROUTING",
+			"-p", rule.Protocol, "--dport", strconv.Itoa(rule.ExternalPort),
+			"-m", "comment", "--comment", comment,
+			"-j", "DNAT", "--to-destination", rule.InternalIP + ":" + rule.InternalPort},
+		{op, "FORWARD",
+			"-p", rule.Protocol, "-d", rule.InternalIP, "--dport", rule.InternalPort,
+			"-m", "comment", "--comment", comment,
+			"-j", "ACCEPT"},
+	}
+}
+
+// apply adds the iptables rules of an enabled rule
+func (m *PortForwardingManager) apply(rule *PortForwardRule) error {
+	if !rule.Enabled {
+		return nil
+	}
+	for i, args := range iptablesArgs("-A", rule) {
+		if _, err := m.shell.Execute("iptables", args...); err != nil {
+			// Don't leave half a forward behind
+			for _, added := range iptablesArgs("-D", rule)[:i] {
+				m.shell.Execute("iptables", added...)
+			}
+			return fmt.Errorf("failed to add iptables rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// unapply removes the iptables rules of a rule. Missing rules are ignored.
+func (m *PortForwardingManager) unapply(rule *PortForwardRule) {
+	for _, args := range iptablesArgs("-D", rule) {
+		if _, err := m.shell.Execute("iptables", args...); err != nil {
+			logger.Debug("iptables rule already removed", zap.String("id", rule.ID), zap.Error(err))
+		}
+	}
+}
+
+// AddRule persists a port forward and applies it if enabled
+func (m *PortForwardingManager) AddRule(rule PortForwardRule) (*PortForwardRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	model := toPortForwardModel(&rule)
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(model).Error; err != nil {
+			return fmt.Errorf("failed to save port forward rule: %w", err)
+		}
+		rule.ID = strconv.FormatUint(uint64(model.ID), 10)
+		return m.apply(&rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Port forward added",
+		zap.String("id", rule.ID),
+		zap.Int("externalPort", rule.ExternalPort),
+		zap.String("to", rule.InternalIP+":"+rule.InternalPort))
+	return &rule, nil
+}
+
+// UpdateRule replaces a port forward
+func (m *PortForwardingManager) UpdateRule(id string, rule PortForwardRule) (*PortForwardRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, err := m.getRule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.ID = existing.ID
+	model := toPortForwardModel(&rule)
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PortForwardRule{}).Where("id = ?", id).Select("*").Omit("id", "created_at").Updates(model).Error; err != nil {
+			return fmt.Errorf("failed to save port forward rule: %w", err)
+		}
+		m.unapply(existing)
+		if err := m.apply(&rule); err != nil {
+			// Restore the previous forward, the transaction keeps its record
+			if restoreErr := m.apply(existing); restoreErr != nil {
+				logger.Error("Failed to restore port forward", zap.String("id", id), zap.Error(restoreErr))
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// RemoveRule removes a port forward and its iptables rules
+func (m *PortForwardingManager) RemoveRule(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, err := m.getRule(id)
+	if err != nil {
+		return err
+	}
+	if err := m.db.Delete(&models.PortForwardRule{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete port forward rule: %w", err)
+	}
+	m.unapply(rule)
+
+	logger.Info("Port forward removed", zap.String("id", id))
+	return nil
+}
+
+// ListRules returns all port forwards ordered by external port
+func (m *PortForwardingManager) ListRules() ([]PortForwardRule, error) {
+	var rows []models.PortForwardRule
+	if err := m.db.Order("external_port, protocol").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list port forward rules: %w", err)
+	}
+
+	rules := make([]PortForwardRule, len(rows))
+	for i := range rows {
+		rules[i] = *fromPortForwardModel(&rows[i])
+	}
+	return rules, nil
+}
+
+// RestorePortForwardRules applies the enabled port forwards at startup.
+// Rules still present in iptables, e.g. after a service restart, are not
+// added again.
+func (m *PortForwardingManager) RestorePortForwardRules() error {
+	rules, err := m.ListRules()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var failed []string
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Enabled {
+			continue
+		}
+		if m.isApplied(rule) {
+			continue
+		}
+		m.unapply(rule) // Drop a half-applied forward
+		if err := m.apply(rule); err != nil {
+			logger.Warn("Failed to restore port forward", zap.String("id", rule.ID), zap.Error(err))
+			failed = append(failed, rule.ID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore port forward rules %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// isApplied checks whether all iptables rules of a rule exist
+func (m *PortForwardingManager) isApplied(rule *PortForwardRule) bool {
+	for _, args := range iptablesArgs("-C", rule) {
+		if _, err := m.shell.Execute("iptables", args...); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *PortForwardingManager) getRule(id string) (*PortForwardRule, error) {
+	var model models.PortForwardRule
+	if err := m.db.First(&model, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPortForwardNotFound
+		}
+		return nil, fmt.Errorf("failed to query port forward rule: %w", err)
+	}
+	return fromPortForwardModel(&model), nil
+}
+
+func toPortForwardModel(rule *PortForwardRule) *models.PortForwardRule {
+	return &models.PortForwardRule{
+		ExternalPort: rule.ExternalPort,
+		Protocol:     rule.Protocol,
+		InternalIP:   rule.InternalIP,
+		InternalPort: rule.InternalPort,
+		Description:  rule.Description,
+		Enabled:      rule.Enabled,
+	}
+}
+
+func fromPortForwardModel(model *models.PortForwardRule) *PortForwardRule {
+	return &PortForwardRule{
+		ID:           strconv.FormatUint(uint64(model.ID), 10),
+		ExternalPort: model.ExternalPort,
+		InternalIP:   model.InternalIP,
+		InternalPort: model.InternalPort,
+		Protocol:     model.Protocol,
+		Description:  model.Description,
+		Enabled:      model.Enabled,
+	}
+}
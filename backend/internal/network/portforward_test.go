@@ -0,0 +1,118 @@
+package network
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestPortForwardingManager(t *testing.T) (*PortForwardingManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "network.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.PortForwardRule{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	shell := executor.NewMockShellExecutor()
+	shell.Responses["iptables"] = executor.ExecuteResult{}
+	m, err := NewPortForwardingManager(db, shell)
+	if err != nil {
+		t.Fatalf("NewPortForwardingManager: %v", err)
+	}
+	return m, shell
+}
+
+func TestAddRuleTCP(t *testing.T) {
+	m, shell := newTestPortForwardingManager(t)
+
+	rule, err := m.AddRule(PortForwardRule{
+		ExternalPort: 8080,
+		InternalIP:   "192.168.1.50",
+		InternalPort: "80",
+		Protocol:     "tcp",
+		Enabled:      true,
+	})
+	if err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	want := [][]string{
+		{"-t", "nat", "-A", "PREROUTING", "-p", "tcp", "--dport", "8080",
+			"-m", "comment", "--comment", "stumpfworks-pf-" + rule.ID,
+			"-j", "DNAT", "--to-destination", "192.168.1.50:80"},
+		{"-A", "FORWARD", "-p", "tcp", "-d", "192.168.1.50", "--dport", "80",
+			"-m", "comment", "--comment", "stumpfworks-pf-" + rule.ID,
+			"-j", "ACCEPT"},
+	}
+	calls := shell.CallsTo("iptables")
+	if len(calls) != len(want) {
+		t.Fatalf("got %d iptables calls, want %d: %v", len(calls), len(want), calls)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(calls[i].Args, want[i]) {
+			t.Errorf("iptables call %d = %v, want %v", i, calls[i].Args, want[i])
+		}
+	}
+
+	rules, err := m.ListRules()
+	if err != nil || len(rules) != 1 || rules[0] != *rule {
+		t.Errorf("ListRules = %+v, %v; want the added rule", rules, err)
+	}
+
+	// Removing deletes the same rules
+	if err := m.RemoveRule(rule.ID); err != nil {
+		t.Fatalf("RemoveRule: %v", err)
+	}
+	calls = shell.CallsTo("iptables")
+	if len(calls) != 4 || calls[2].Args[2] != "-D" || calls[3].Args[0] != "-D" {
+		t.Errorf("remove calls = %v", calls[2:])
+	}
+	if err := m.RemoveRule(rule.ID); err != ErrPortForwardNotFound {
+		t.Errorf("removing again = %v, want ErrPortForwardNotFound", err)
+	}
+}
+
+func TestAddRuleValidation(t *testing.T) {
+	m, shell := newTestPortForwardingManager(t)
+
+	valid := PortForwardRule{ExternalPort: 2222, InternalIP: "10.0.0.5", InternalPort: "22", Protocol: "tcp"}
+	tests := []struct {
+		name   string
+		modify func(r *PortForwardRule)
+	}{
+		{"public internal IP", func(r *PortForwardRule) { r.InternalIP = "8.8.8.8" }},
+		{"invalid internal IP", func(r *PortForwardRule) { r.InternalIP = "nas.local" }},
+		{"IPv6 internal IP", func(r *PortForwardRule) { r.InternalIP = "fd00::5" }},
+		{"external port out of range", func(r *PortForwardRule) { r.ExternalPort = 70000 }},
+		{"invalid internal port", func(r *PortForwardRule) { r.InternalPort = "ssh" }},
+		{"invalid protocol", func(r *PortForwardRule) { r.Protocol = "icmp" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid
+			tt.modify(&rule)
+			if _, err := m.AddRule(rule); err == nil {
+				t.Error("expected the rule to be rejected")
+			}
+		})
+	}
+
+	if len(shell.Calls) != 0 {
+		t.Errorf("rejected rules ran %v", shell.Calls)
+	}
+}
@@ -0,0 +1,44 @@
+package network
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+)
+
+// hostShell runs the commands of the package-level functions, which have no
+// manager to carry a shell. Replaced in tests.
+var hostShell executor.ShellExecutor = newHostShell()
+
+func newHostShell() executor.ShellExecutor {
+	// NewShellExecutor only fails for invalid arguments
+	shell, _ := system.NewShellExecutor(30*time.Second, false)
+	return shell
+}
+
+// runCommand runs a command through hostShell and returns its stdout and
+// stderr, for parsing and error messages like exec.Cmd.CombinedOutput
+func runCommand(name string, args ...string) (string, error) {
+	return combinedOutput(hostShell.Execute(name, args...))
+}
+
+// runCommandWithTimeout is runCommand for commands that may take longer
+// than the default timeout
+func runCommandWithTimeout(timeout time.Duration, name string, args ...string) (string, error) {
+	return combinedOutput(hostShell.ExecuteWithTimeout(timeout, name, args...))
+}
+
+func combinedOutput(result *executor.CommandResult, err error) (string, error) {
+	if result == nil {
+		return "", err
+	}
+	var output []string
+	for _, s := range []string{result.Stdout, result.Stderr} {
+		if s != "" {
+			output = append(output, s)
+		}
+	}
+	return strings.Join(output, "\n"), err
+}
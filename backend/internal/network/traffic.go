@@ -0,0 +1,286 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// trafficPollInterval is how often the connection tracking table is read
+	trafficPollInterval = 15 * time.Second
+
+	// UnattributedShare labels share traffic of clients that are connected to
+	// no share or several shares over the same protocol
+	UnattributedShare = "unknown"
+)
+
+// sharePorts maps the server ports of share protocols to the protocol
+var sharePorts = map[int]string{
+	445:  models.ShareProtocolSMB,
+	2049: models.ShareProtocolNFS,
+}
+
+// ConnectionStat is a tracked connection to a share port. Byte and packet
+// counts need connection accounting (net.netfilter.nf_conntrack_acct=1).
+type ConnectionStat struct {
+	SrcIP      string `json:"srcIp"`
+	DstIP      string `json:"dstIp"`
+	SrcPort    int    `json:"srcPort"`
+	DstPort    int    `json:"dstPort"`
+	Protocol   string `json:"protocol"`
+	BytesOrig  uint64 `json:"bytesOrig"`  // Client to server
+	BytesReply uint64 `json:"bytesReply"` // Server to client
+	Packets    uint64 `json:"packets"`
+	State      string `json:"state,omitempty"`
+}
+
+// ShareTraffic is the traffic of a share since the monitor started. Rx is
+// received by the NAS, tx sent by it.
+type ShareTraffic struct {
+	Share    string `json:"share"`
+	Protocol string `json:"protocol"`
+	RxBytes  uint64 `json:"rxBytes"`
+	TxBytes  uint64 `json:"txBytes"`
+}
+
+// ParseConntrack parses conntrack -L -o extended output. conntrack has no
+// JSON output; each line holds the original and then the reply direction
+// as key=value pairs.
+func ParseConntrack(data []byte) []ConnectionStat {
+	var stats []ConnectionStat
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		stat := ConnectionStat{Protocol: fields[2]}
+		seen := make(map[string]bool)
+		for _, field := range fields[5:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				if stat.State == "" && !seen["src"] && !strings.HasPrefix(field, "[") {
+					stat.State = field
+				}
+				continue
+			}
+			reply := seen[key]
+			seen[key] = true
+			n, _ := strconv.ParseUint(value, 10, 64)
+			switch {
+			case key == "src" && !reply:
+				stat.SrcIP = value
+			case key == "dst" && !reply:
+				stat.DstIP = value
+			case key == "sport" && !reply:
+				stat.SrcPort = int(n)
+			case key == "dport" && !reply:
+				stat.DstPort = int(n)
+			case key == "bytes" && !reply:
+				stat.BytesOrig = n
+			case key == "bytes":
+				stat.BytesReply = n
+			case key == "packets":
+				stat.Packets += n
+			}
+		}
+		if stat.SrcIP != "" {
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// TrafficMonitor accounts the traffic of share connections. Connections to
+// the SMB and NFS ports are attributed to a share through the client's open
+// sessions in the share access log.
+type TrafficMonitor struct {
+	db        *gorm.DB
+	interval  time.Duration
+	conntrack func() ([]byte, error)
+
+	mu          sync.RWMutex
+	connections []ConnectionStat
+	lastBytes   map[string][2]uint64 // Per connection: orig and reply bytes at the last poll
+	traffic     map[string]*ShareTraffic
+}
+
+var (
+	trafficMonitor   *TrafficMonitor
+	trafficMonitorMu sync.RWMutex
+)
+
+// NewTrafficMonitor creates a traffic monitor reading the live conntrack table
+func NewTrafficMonitor(db *gorm.DB) *TrafficMonitor {
+	return &TrafficMonitor{
+		db:        db,
+		interval:  trafficPollInterval,
+		conntrack: runConntrack,
+		lastBytes: make(map[string][2]uint64),
+		traffic:   make(map[string]*ShareTraffic),
+	}
+}
+
+// GetTrafficMonitor returns the running traffic monitor, or nil
+func GetTrafficMonitor() *TrafficMonitor {
+	trafficMonitorMu.RLock()
+	defer trafficMonitorMu.RUnlock()
+	return trafficMonitor
+}
+
+func runConntrack() ([]byte, error) {
+	path, err := sysutil.RequireCommand("conntrack")
+	if err != nil {
+		return nil, err
+	}
+	result, err := hostShell.Execute(path, "-L", "-o", "extended")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(result.Stdout), nil
+}
+
+// Start polls the connection tracking table until ctx is cancelled and makes
+// the monitor available through GetTrafficMonitor. The first poll runs
+// synchronously so a missing conntrack is reported.
+func (m *TrafficMonitor) Start(ctx context.Context) error {
+	if err := m.Poll(); err != nil {
+		return err
+	}
+
+	trafficMonitorMu.Lock()
+	trafficMonitor = m
+	trafficMonitorMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Poll(); err != nil {
+					logger.Warn("Failed to poll connection tracking", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Poll reads the connection tracking table and adds the traffic since the
+// last poll to the shares
+func (m *TrafficMonitor) Poll() error {
+	data, err := m.conntrack()
+	if err != nil {
+		return fmt.Errorf("failed to list tracked connections: %w", err)
+	}
+
+	var connections []ConnectionStat
+	for _, c := range ParseConntrack(data) {
+		if _, ok := sharePorts[c.DstPort]; ok {
+			connections = append(connections, c)
+		}
+	}
+
+	var sessions []models.ShareAccessLog
+	if err := m.db.Where("disconnected_at IS NULL").Find(&sessions).Error; err != nil {
+		return fmt.Errorf("failed to query share sessions: %w", err)
+	}
+	// Client IP and protocol to the shares the client has open
+	shares := make(map[string]map[string]bool)
+	for _, s := range sessions {
+		key := s.ClientIP + "/" + s.Protocol
+		if shares[key] == nil {
+			shares[key] = make(map[string]bool)
+		}
+		shares[key][s.ShareName] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lastBytes := make(map[string][2]uint64, len(connections))
+	for _, c := range connections {
+		protocol := sharePorts[c.DstPort]
+		key := fmt.Sprintf("%s/%s:%d/%s:%d", c.Protocol, c.SrcIP, c.SrcPort, c.DstIP, c.DstPort)
+		last := m.lastBytes[key]
+		lastBytes[key] = [2]uint64{c.BytesOrig, c.BytesReply}
+
+		share := UnattributedShare
+		if open := shares[c.SrcIP+"/"+protocol]; len(open) == 1 {
+			for name := range open {
+				share = name
+			}
+		}
+		t := m.traffic[share+"/"+protocol]
+		if t == nil {
+			t = &ShareTraffic{Share: share, Protocol: protocol}
+			m.traffic[share+"/"+protocol] = t
+		}
+		// Counters of a reused connection tuple restart from zero
+		if c.BytesOrig >= last[0] && c.BytesReply >= last[1] {
+			t.RxBytes += c.BytesOrig - last[0]
+			t.TxBytes += c.BytesReply - last[1]
+		} else {
+			t.RxBytes += c.BytesOrig
+			t.TxBytes += c.BytesReply
+		}
+	}
+	m.lastBytes = lastBytes
+	m.connections = connections
+	return nil
+}
+
+// GetConnectionStats returns the share connections seen by the last poll
+func (m *TrafficMonitor) GetConnectionStats() ([]ConnectionStat, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]ConnectionStat{}, m.connections...), nil
+}
+
+// GetShareTraffic returns the traffic per share and protocol, sorted by share
+func (m *TrafficMonitor) GetShareTraffic() []ShareTraffic {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	traffic := make([]ShareTraffic, 0, len(m.traffic))
+	for _, t := range m.traffic {
+		traffic = append(traffic, *t)
+	}
+	sort.Slice(traffic, func(i, j int) bool {
+		if traffic[i].Share != traffic[j].Share {
+			return traffic[i].Share < traffic[j].Share
+		}
+		return traffic[i].Protocol < traffic[j].Protocol
+	})
+	return traffic
+}
+
+// PrometheusMetrics returns the share traffic counters in Prometheus text
+// format
+func (m *TrafficMonitor) PrometheusMetrics() string {
+	var b strings.Builder
+	b.WriteString("# HELP nastraffic_bytes_total Bytes transferred over share connections\n")
+	b.WriteString("# TYPE nastraffic_bytes_total counter\n")
+	for _, t := range m.GetShareTraffic() {
+		fmt.Fprintf(&b, "nastraffic_bytes_total{share=%q,protocol=%q,direction=\"rx\"} %d\n", t.Share, t.Protocol, t.RxBytes)
+		fmt.Fprintf(&b, "nastraffic_bytes_total{share=%q,protocol=%q,direction=\"tx\"} %d\n", t.Share, t.Protocol, t.TxBytes)
+	}
+	return b.String()
+}
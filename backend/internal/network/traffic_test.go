@@ -0,0 +1,100 @@
+package network
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// conntrack -L -o extended output: alice on SMB, bob on NFS, carol on two
+// SMB shares, an SSH session and a UDP flow
+const conntrackFirst = `ipv4     2 tcp      6 431999 ESTABLISHED src=192.168.1.20 dst=192.168.1.10 sport=50123 dport=445 packets=120 bytes=15000 src=192.168.1.10 dst=192.168.1.20 sport=445 dport=50123 packets=100 bytes=900000 [ASSURED] mark=0 use=1
+ipv4     2 tcp      6 431999 ESTABLISHED src=192.168.1.21 dst=192.168.1.10 sport=812 dport=2049 packets=40 bytes=500000 src=192.168.1.10 dst=192.168.1.21 sport=2049 dport=812 packets=30 bytes=4000 [ASSURED] mark=0 use=1
+ipv4     2 tcp      6 431999 ESTABLISHED src=192.168.1.22 dst=192.168.1.10 sport=50200 dport=445 packets=10 bytes=1000 src=192.168.1.10 dst=192.168.1.22 sport=445 dport=50200 packets=10 bytes=2000 [ASSURED] mark=0 use=1
+ipv4     2 tcp      6 7440 ESTABLISHED src=192.168.1.20 dst=192.168.1.10 sport=50400 dport=22 packets=50 bytes=7000 src=192.168.1.10 dst=192.168.1.20 sport=22 dport=50400 packets=45 bytes=9000 [ASSURED] mark=0 use=1
+ipv4     2 udp      17 29 src=192.168.1.20 dst=192.168.1.1 sport=5353 dport=53 packets=1 bytes=60 src=192.168.1.1 dst=192.168.1.20 sport=53 dport=5353 packets=1 bytes=120 mark=0 use=1
+`
+
+// alice transferred more and bob disconnected
+const conntrackSecond = `ipv4     2 tcp      6 431999 ESTABLISHED src=192.168.1.20 dst=192.168.1.10 sport=50123 dport=445 packets=150 bytes=20000 src=192.168.1.10 dst=192.168.1.20 sport=445 dport=50123 packets=130 bytes=1000000 [ASSURED] mark=0 use=1
+`
+
+func TestParseConntrack(t *testing.T) {
+	stats := ParseConntrack([]byte(conntrackFirst))
+	if len(stats) != 5 {
+		t.Fatalf("got %d connections, want 5", len(stats))
+	}
+
+	want := ConnectionStat{
+		SrcIP: "192.168.1.20", DstIP: "192.168.1.10", SrcPort: 50123, DstPort: 445,
+		Protocol: "tcp", BytesOrig: 15000, BytesReply: 900000, Packets: 220, State: "ESTABLISHED",
+	}
+	if stats[0] != want {
+		t.Errorf("got %+v, want %+v", stats[0], want)
+	}
+	if udp := stats[4]; udp.Protocol != "udp" || udp.State != "" || udp.DstPort != 53 {
+		t.Errorf("udp connection = %+v", udp)
+	}
+}
+
+func TestTrafficMonitorAggregation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "network.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ShareAccessLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	now := time.Now()
+	db.Create(&[]models.ShareAccessLog{
+		{ShareName: "media", Protocol: models.ShareProtocolSMB, ClientIP: "192.168.1.20", SessionKey: "a", ConnectedAt: now},
+		{ShareName: "backup", Protocol: models.ShareProtocolNFS, ClientIP: "192.168.1.21", SessionKey: "b", ConnectedAt: now},
+		{ShareName: "media", Protocol: models.ShareProtocolSMB, ClientIP: "192.168.1.22", SessionKey: "c", ConnectedAt: now},
+		{ShareName: "docs", Protocol: models.ShareProtocolSMB, ClientIP: "192.168.1.22", SessionKey: "d", ConnectedAt: now},
+	})
+
+	output := conntrackFirst
+	m := NewTrafficMonitor(db)
+	m.conntrack = func() ([]byte, error) { return []byte(output), nil }
+
+	if err := m.Poll(); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	connections, _ := m.GetConnectionStats()
+	if len(connections) != 3 {
+		t.Errorf("got %d share connections, want 3", len(connections))
+	}
+
+	output = conntrackSecond
+	if err := m.Poll(); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+
+	want := []ShareTraffic{
+		{Share: "backup", Protocol: "nfs", RxBytes: 500000, TxBytes: 4000},
+		{Share: "media", Protocol: "smb", RxBytes: 20000, TxBytes: 1000000},
+		{Share: UnattributedShare, Protocol: "smb", RxBytes: 1000, TxBytes: 2000},
+	}
+	got := m.GetShareTraffic()
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("traffic[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	metrics := m.PrometheusMetrics()
+	if line := `nastraffic_bytes_total{share="media",protocol="smb",direction="tx"} 1000000`; !strings.Contains(metrics, line) {
+		t.Errorf("metrics missing %s:\n%s", line, metrics)
+	}
+}
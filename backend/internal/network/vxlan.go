@@ -0,0 +1,257 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultVXLANPort is the IANA-assigned VXLAN UDP port
+	DefaultVXLANPort = 4789
+	// MaxVNI is the largest VXLAN network identifier (24 bits)
+	MaxVNI = 1<<24 - 1
+)
+
+var (
+	// ErrVXLANExists is returned when creating a VXLAN interface whose name is taken
+	ErrVXLANExists = errors.New("VXLAN interface already exists")
+	// ErrVXLANNotFound is returned for unknown VXLAN interfaces
+	ErrVXLANNotFound = errors.New("VXLAN interface not found")
+)
+
+// VXLANConfig is a point-to-point VXLAN tunnel to another node
+type VXLANConfig struct {
+	Name     string `json:"name"`
+	VNI      int    `json:"vni"`
+	RemoteIP string `json:"remoteIp"`
+	LocalIP  string `json:"localIp"`
+	DstPort  int    `json:"dstPort"`          // UDP port, DefaultVXLANPort if 0
+	Bridge   string `json:"bridge,omitempty"` // Bridge to attach the interface to, none if empty
+}
+
+// Validate checks the config and fills in the default port
+func (c *VXLANConfig) Validate() error {
+	if !ifaceNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid interface name %q", c.Name)
+	}
+	if c.VNI < 1 || c.VNI > MaxVNI {
+		return fmt.Errorf("VNI %d out of range 1-%d", c.VNI, MaxVNI)
+	}
+	remote, err := netip.ParseAddr(c.RemoteIP)
+	if err != nil {
+		return fmt.Errorf("invalid remote IP %q", c.RemoteIP)
+	}
+	local, err := netip.ParseAddr(c.LocalIP)
+	if err != nil {
+		return fmt.Errorf("invalid local IP %q", c.LocalIP)
+	}
+	if remote.Is4() != local.Is4() {
+		return fmt.Errorf("remote IP %s and local IP %s must be of the same family", c.RemoteIP, c.LocalIP)
+	}
+	if c.DstPort == 0 {
+		c.DstPort = DefaultVXLANPort
+	}
+	if c.DstPort < 1 || c.DstPort > 65535 {
+		return fmt.Errorf("invalid destination port %d", c.DstPort)
+	}
+	if c.Bridge != "" && !ifaceNamePattern.MatchString(c.Bridge) {
+		return fmt.Errorf("invalid bridge name %q", c.Bridge)
+	}
+	return nil
+}
+
+// VXLANManager manages VXLAN interfaces that give NAS nodes at different
+// sites a shared layer 2 network over UDP
+type VXLANManager struct {
+	db    *gorm.DB
+	shell executor.ShellExecutor
+	mu    sync.Mutex
+}
+
+// NewVXLANManager creates a VXLAN manager
+func NewVXLANManager(db *gorm.DB, shell executor.ShellExecutor) (*VXLANManager, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if !shell.CommandExists("ip") {
+		return nil, fmt.Errorf("ip not installed (install 'iproute2' package)")
+	}
+	return &VXLANManager{db: db, shell: shell}, nil
+}
+
+// CreateVXLAN creates a VXLAN interface to remoteIP, brings it up and
+// attaches it to bridge unless bridge is empty. A dstPort of 0 uses
+// DefaultVXLANPort.
+func (m *VXLANManager) CreateVXLAN(name string, vni int, remoteIP, localIP string, dstPort int, bridge string) error {
+	cfg := VXLANConfig{Name: name, VNI: vni, RemoteIP: remoteIP, LocalIP: localIP, DstPort: dstPort, Bridge: bridge}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	if err := m.db.Model(&models.VXLANInterface{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check VXLAN interfaces: %w", err)
+	}
+	if count > 0 {
+		return ErrVXLANExists
+	}
+
+	if err := m.setup(cfg); err != nil {
+		return err
+	}
+	record := models.VXLANInterface{
+		Name:     cfg.Name,
+		VNI:      cfg.VNI,
+		RemoteIP: cfg.RemoteIP,
+		LocalIP:  cfg.LocalIP,
+		DstPort:  cfg.DstPort,
+		Bridge:   cfg.Bridge,
+	}
+	if err := m.db.Create(&record).Error; err != nil {
+		runIP(m.shell, "link", "delete", name)
+		return fmt.Errorf("failed to save VXLAN interface: %w", err)
+	}
+
+	logger.Info("VXLAN interface created", zap.String("name", name), zap.Int("vni", vni), zap.String("remote", remoteIP))
+	return nil
+}
+
+// DeleteVXLAN removes a VXLAN interface
+func (m *VXLANManager) DeleteVXLAN(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var record models.VXLANInterface
+	err := m.db.First(&record, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrVXLANNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load VXLAN interface: %w", err)
+	}
+
+	// The interface may already be gone, e.g. deleted by hand
+	if m.linkExists(name) {
+		if err := runIP(m.shell, "link", "delete", name); err != nil {
+			return err
+		}
+	}
+	if err := m.db.Delete(&record).Error; err != nil {
+		return fmt.Errorf("failed to delete VXLAN interface: %w", err)
+	}
+
+	logger.Info("VXLAN interface deleted", zap.String("name", name))
+	return nil
+}
+
+// GetVXLAN returns a VXLAN interface
+func (m *VXLANManager) GetVXLAN(name string) (*VXLANConfig, error) {
+	var record models.VXLANInterface
+	err := m.db.First(&record, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrVXLANNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load VXLAN interface: %w", err)
+	}
+	cfg := vxlanConfig(record)
+	return &cfg, nil
+}
+
+// ListVXLANs returns the VXLAN interfaces by name
+func (m *VXLANManager) ListVXLANs() ([]VXLANConfig, error) {
+	var records []models.VXLANInterface
+	if err := m.db.Order("name").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list VXLAN interfaces: %w", err)
+	}
+
+	configs := make([]VXLANConfig, 0, len(records))
+	for _, record := range records {
+		configs = append(configs, vxlanConfig(record))
+	}
+	return configs, nil
+}
+
+// RestoreAllVXLANs recreates the recorded VXLAN interfaces that are
+// missing, as they do not survive a reboot
+func (m *VXLANManager) RestoreAllVXLANs() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var records []models.VXLANInterface
+	if err := m.db.Order("name").Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to load VXLAN interfaces: %w", err)
+	}
+
+	var failed []string
+	for _, record := range records {
+		if m.linkExists(record.Name) {
+			continue
+		}
+		if err := m.setup(vxlanConfig(record)); err != nil {
+			logger.Warn("Failed to restore VXLAN interface", zap.String("name", record.Name), zap.Error(err))
+			failed = append(failed, record.Name)
+			continue
+		}
+		logger.Info("VXLAN interface restored", zap.String("name", record.Name), zap.Int("vni", record.VNI))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore VXLAN interfaces: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// setup creates the interface, attaches it to its bridge and brings it up,
+// removing it again if a step fails
+func (m *VXLANManager) setup(cfg VXLANConfig) error {
+	err := runIP(m.shell, "link", "add", cfg.Name, "type", "vxlan",
+		"id", strconv.Itoa(cfg.VNI),
+		"remote", cfg.RemoteIP,
+		"local", cfg.LocalIP,
+		"dstport", strconv.Itoa(cfg.DstPort))
+	if err != nil {
+		return err
+	}
+
+	if cfg.Bridge != "" {
+		err = runIP(m.shell, "link", "set", cfg.Name, "master", cfg.Bridge)
+	}
+	if err == nil {
+		err = runIP(m.shell, "link", "set", cfg.Name, "up")
+	}
+	if err != nil {
+		runIP(m.shell, "link", "delete", cfg.Name)
+		return err
+	}
+	return nil
+}
+
+// linkExists reports whether a network interface exists
+func (m *VXLANManager) linkExists(name string) bool {
+	_, err := m.shell.Execute("ip", "link", "show", "dev", name)
+	return err == nil
+}
+
+func vxlanConfig(record models.VXLANInterface) VXLANConfig {
+	return VXLANConfig{
+		Name:     record.Name,
+		VNI:      record.VNI,
+		RemoteIP: record.RemoteIP,
+		LocalIP:  record.LocalIP,
+		DstPort:  record.DstPort,
+		Bridge:   record.Bridge,
+	}
+}
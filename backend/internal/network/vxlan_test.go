@@ -0,0 +1,142 @@
+package network
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// newTestVXLANManager returns a manager whose ip commands succeed, except
+// ip link show for interfaces not in links
+func newTestVXLANManager(t *testing.T, links ...string) (*VXLANManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "network.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.VXLANInterface{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	shell := executor.NewMockShellExecutor()
+	shell.Handler = func(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+		if len(call.Args) == 4 && call.Args[1] == "show" {
+			for _, link := range links {
+				if call.Args[3] == link {
+					return &executor.CommandResult{Success: true}, nil
+				}
+			}
+			return &executor.CommandResult{Stderr: "Device does not exist"}, fmt.Errorf("exit status 1")
+		}
+		return &executor.CommandResult{Success: true}, nil
+	}
+	m, err := NewVXLANManager(db, shell)
+	if err != nil {
+		t.Fatalf("NewVXLANManager: %v", err)
+	}
+	return m, shell
+}
+
+func ipCalls(shell *executor.MockShellExecutor) []string {
+	var calls []string
+	for _, call := range shell.Calls {
+		calls = append(calls, call.String())
+	}
+	return calls
+}
+
+func TestCreateVXLAN(t *testing.T) {
+	m, shell := newTestVXLANManager(t)
+
+	if err := m.CreateVXLAN("vxlan100", 100, "203.0.113.20", "198.51.100.10", 0, "br0"); err != nil {
+		t.Fatalf("CreateVXLAN: %v", err)
+	}
+	want := []string{
+		"ip link add vxlan100 type vxlan id 100 remote 203.0.113.20 local 198.51.100.10 dstport 4789",
+		"ip link set vxlan100 master br0",
+		"ip link set vxlan100 up",
+	}
+	if got := ipCalls(shell); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	configs, err := m.ListVXLANs()
+	if err != nil {
+		t.Fatalf("ListVXLANs: %v", err)
+	}
+	wantConfigs := []VXLANConfig{{Name: "vxlan100", VNI: 100, RemoteIP: "203.0.113.20", LocalIP: "198.51.100.10", DstPort: 4789, Bridge: "br0"}}
+	if !reflect.DeepEqual(configs, wantConfigs) {
+		t.Errorf("ListVXLANs = %+v, want %+v", configs, wantConfigs)
+	}
+
+	if err := m.CreateVXLAN("vxlan100", 101, "203.0.113.21", "198.51.100.10", 8472, ""); err != ErrVXLANExists {
+		t.Errorf("CreateVXLAN with a taken name = %v, want ErrVXLANExists", err)
+	}
+}
+
+func TestCreateVXLANValidation(t *testing.T) {
+	m, shell := newTestVXLANManager(t)
+
+	tests := []struct {
+		name            string
+		vni             int
+		remote, local   string
+		dstPort         int
+		wantErrContains string
+	}{
+		{"vxlan0", 0, "203.0.113.20", "198.51.100.10", 0, "VNI 0 out of range"},
+		{"vxlan0", 16777216, "203.0.113.20", "198.51.100.10", 0, "VNI 16777216 out of range"},
+		{"vxlan0", 100, "not-an-ip", "198.51.100.10", 0, "invalid remote IP"},
+		{"vxlan0", 100, "2001:db8::1", "198.51.100.10", 0, "same family"},
+		{"vxlan0", 100, "203.0.113.20", "198.51.100.10", 70000, "invalid destination port"},
+		{"vxlan-name-too-long", 100, "203.0.113.20", "198.51.100.10", 0, "invalid interface name"},
+	}
+	for _, tt := range tests {
+		err := m.CreateVXLAN(tt.name, tt.vni, tt.remote, tt.local, tt.dstPort, "")
+		if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+			t.Errorf("CreateVXLAN(%s, %d, %s, %s, %d) = %v, want error containing %q",
+				tt.name, tt.vni, tt.remote, tt.local, tt.dstPort, err, tt.wantErrContains)
+		}
+	}
+	if len(shell.Calls) != 0 {
+		t.Errorf("invalid configs ran commands: %v", ipCalls(shell))
+	}
+
+	// The largest VNI is valid
+	if err := m.CreateVXLAN("vxlanmax", MaxVNI, "203.0.113.20", "198.51.100.10", 8472, ""); err != nil {
+		t.Errorf("CreateVXLAN with VNI %d: %v", MaxVNI, err)
+	}
+}
+
+func TestRestoreAllVXLANs(t *testing.T) {
+	m, shell := newTestVXLANManager(t, "vxlan1")
+	m.db.Create(&models.VXLANInterface{Name: "vxlan1", VNI: 1, RemoteIP: "203.0.113.20", LocalIP: "198.51.100.10", DstPort: 4789})
+	m.db.Create(&models.VXLANInterface{Name: "vxlan2", VNI: 2, RemoteIP: "203.0.113.21", LocalIP: "198.51.100.10", DstPort: 8472, Bridge: "br0"})
+
+	if err := m.RestoreAllVXLANs(); err != nil {
+		t.Fatalf("RestoreAllVXLANs: %v", err)
+	}
+	want := []string{
+		"ip link show dev vxlan1",
+		"ip link show dev vxlan2",
+		"ip link add vxlan2 type vxlan id 2 remote 203.0.113.21 local 198.51.100.10 dstport 8472",
+		"ip link set vxlan2 master br0",
+		"ip link set vxlan2 up",
+	}
+	if got := ipCalls(shell); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,115 @@
+// Package notifications decouples the services that raise notifications
+// from the channels delivering them. Services publish to a Bus and the
+// delivery channels (email and webhook alerts, browser push) subscribe.
+package notifications
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// AllTypes subscribes a handler to notifications of every type
+const AllTypes = "*"
+
+// Notification severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Notification is something that happened that users should be told about
+type Notification struct {
+	Type       string                 `json:"type"` // e.g. drbd_split_brain, matches the alert types of the alerts service
+	Title      string                 `json:"title"`
+	Body       string                 `json:"body"`
+	Severity   string                 `json:"severity"`
+	Source     string                 `json:"source"` // Service that published the notification
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	OccurredAt time.Time              `json:"occurredAt"`
+}
+
+// Bus fans out published notifications to the handlers subscribed to their
+// type. Each handler runs in its own goroutine, so a slow or failing
+// subscriber never delays the publisher or the other subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(Notification)
+}
+
+var (
+	globalBus *Bus
+	busOnce   sync.Once
+)
+
+// NewBus creates a notification bus
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]func(Notification))}
+}
+
+// GetBus returns the global notification bus
+func GetBus() *Bus {
+	busOnce.Do(func() {
+		globalBus = NewBus()
+	})
+	return globalBus
+}
+
+// Publish is a shortcut for GetBus().Publish
+func Publish(n Notification) error {
+	return GetBus().Publish(n)
+}
+
+// Subscribe calls handler for every notification of eventType, or of any
+// type for AllTypes
+func (b *Bus) Subscribe(eventType string, handler func(Notification)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish hands a notification to its subscribers without waiting for them.
+// OccurredAt defaults to now and Severity to info.
+func (b *Bus) Publish(n Notification) error {
+	if n.Type == "" {
+		return fmt.Errorf("notification type is required")
+	}
+	if n.Title == "" {
+		return fmt.Errorf("notification title is required")
+	}
+	if n.OccurredAt.IsZero() {
+		n.OccurredAt = time.Now()
+	}
+	if n.Severity == "" {
+		n.Severity = SeverityInfo
+	}
+
+	b.mu.RLock()
+	handlers := make([]func(Notification), 0, len(b.handlers[n.Type])+len(b.handlers[AllTypes]))
+	handlers = append(handlers, b.handlers[n.Type]...)
+	if n.Type != AllTypes {
+		handlers = append(handlers, b.handlers[AllTypes]...)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		go deliver(handler, n)
+	}
+	return nil
+}
+
+// deliver calls a subscriber, containing its panics
+func deliver(handler func(Notification), n Notification) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Notification subscriber panicked",
+				zap.String("type", n.Type),
+				zap.Any("panic", r))
+		}
+	}()
+	handler(n)
+}
@@ -0,0 +1,76 @@
+package notifications
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func TestPublishFansOutToSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	received := map[string][]string{}
+	record := func(name string) func(Notification) {
+		return func(n Notification) {
+			defer wg.Done()
+			mu.Lock()
+			received[name] = append(received[name], n.Title)
+			mu.Unlock()
+		}
+	}
+	bus.Subscribe("drbd_split_brain", record("typed"))
+	bus.Subscribe(AllTypes, record("all"))
+	bus.Subscribe("ups_on_battery", record("other"))
+
+	wg.Add(2)
+	if err := bus.Publish(Notification{Type: "drbd_split_brain", Title: "Split-brain on r0"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	wg.Wait()
+
+	if len(received["typed"]) != 1 || len(received["all"]) != 1 {
+		t.Errorf("received = %v, want the notification once by typed and all", received)
+	}
+	if len(received["other"]) != 0 {
+		t.Errorf("subscriber of another type received %v", received["other"])
+	}
+}
+
+func TestFailedSubscriberDoesNotBlockOthers(t *testing.T) {
+	logger.InitLogger("error", false)
+	bus := NewBus()
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	bus.Subscribe(AllTypes, func(Notification) { <-blocked })
+	bus.Subscribe(AllTypes, func(Notification) { panic("delivery failed") })
+
+	delivered := make(chan Notification, 1)
+	bus.Subscribe(AllTypes, func(n Notification) { delivered <- n })
+
+	if err := bus.Publish(Notification{Type: "test", Title: "Hello"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	select {
+	case n := <-delivered:
+		if n.OccurredAt.IsZero() || n.Severity != SeverityInfo {
+			t.Errorf("delivered %+v, want OccurredAt and Severity defaulted", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("healthy subscriber did not receive the notification")
+	}
+}
+
+func TestPublishRequiresTypeAndTitle(t *testing.T) {
+	bus := NewBus()
+	if err := bus.Publish(Notification{Title: "untyped"}); err == nil {
+		t.Error("Publish accepted a notification without a type")
+	}
+	if err := bus.Publish(Notification{Type: "test"}); err == nil {
+		t.Error("Publish accepted a notification without a title")
+	}
+}
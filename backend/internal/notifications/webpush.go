@@ -0,0 +1,377 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// pushTTL is how long push services keep undelivered notifications
+	pushTTL = 24 * time.Hour
+
+	// pushRecordSize is the aes128gcm record size; payloads fit in one record
+	pushRecordSize = 4096
+
+	// maxPushBody is the longest notification body sent, so the encrypted
+	// payload stays below the 4 KiB push services accept
+	maxPushBody = 2048
+)
+
+// ErrPushSubscriptionNotFound is returned when unsubscribing an unknown endpoint
+var ErrPushSubscriptionNotFound = errors.New("push subscription not found")
+
+// PushSubscription is the JSON of a browser PushSubscription
+type PushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Validate checks that the endpoint is an HTTPS URL and the keys are a
+// P-256 public key and a 16 byte authentication secret
+func (s *PushSubscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("endpoint must be an https URL")
+	}
+	if key, err := decodeBase64URL(s.Keys.P256dh); err != nil || len(key) != 65 || key[0] != 0x04 {
+		return fmt.Errorf("keys.p256dh must be an uncompressed P-256 public key")
+	}
+	if secret, err := decodeBase64URL(s.Keys.Auth); err != nil || len(secret) != 16 {
+		return fmt.Errorf("keys.auth must be a 16 byte secret")
+	}
+	return nil
+}
+
+// WebPushSubscriber delivers notifications to the subscribed browsers with
+// the Web Push Protocol (RFC 8030). Payloads are encrypted for each browser
+// (RFC 8291) and the server identifies itself with VAPID (RFC 8292).
+type WebPushSubscriber struct {
+	db        *gorm.DB
+	client    *http.Client
+	subject   string // Contact of the server operator for push services
+	key       *ecdsa.PrivateKey
+	publicKey string // base64url uncompressed point
+}
+
+// NewWebPushSubscriber creates a subscriber, generating the VAPID key pair
+// on first use. subject is a mailto: or https: contact for push services.
+func NewWebPushSubscriber(db *gorm.DB, subject string) (*WebPushSubscriber, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var stored models.VAPIDKey
+	err := db.First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		key, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate VAPID key: %w", err)
+		}
+		stored = models.VAPIDKey{
+			PrivateKey: base64.RawURLEncoding.EncodeToString(key.Bytes()),
+			PublicKey:  base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		}
+		if err := db.Create(&stored).Error; err != nil {
+			return nil, fmt.Errorf("failed to save VAPID key: %w", err)
+		}
+		logger.Info("VAPID key pair generated")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load VAPID key: %w", err)
+	}
+
+	key, err := vapidSigningKey(stored.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &WebPushSubscriber{
+		db:        db,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		subject:   subject,
+		key:       key,
+		publicKey: stored.PublicKey,
+	}, nil
+}
+
+// vapidSigningKey converts a stored P-256 scalar to an ECDSA key for
+// signing VAPID tokens
+func vapidSigningKey(encoded string) (*ecdsa.PrivateKey, error) {
+	scalar, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID key: %w", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID key: %w", err)
+	}
+	return parsed.(*ecdsa.PrivateKey), nil
+}
+
+// PublicKey returns the VAPID public key browsers pass as
+// applicationServerKey when subscribing
+func (w *WebPushSubscriber) PublicKey() string {
+	return w.publicKey
+}
+
+// Subscribe stores a browser subscription of a user, replacing an earlier
+// subscription of the same endpoint
+func (w *WebPushSubscriber) Subscribe(userID uint, sub PushSubscription, userAgent string) (*models.PushSubscription, error) {
+	if err := sub.Validate(); err != nil {
+		return nil, err
+	}
+
+	var record models.PushSubscription
+	if err := w.db.Where("endpoint = ?", sub.Endpoint).Find(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to get push subscription: %w", err)
+	}
+	record.UserID = userID
+	record.Endpoint = sub.Endpoint
+	record.P256dh = sub.Keys.P256dh
+	record.Auth = sub.Keys.Auth
+	record.UserAgent = userAgent
+	if err := w.db.Save(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to save push subscription: %w", err)
+	}
+	return &record, nil
+}
+
+// Unsubscribe removes the subscription of an endpoint owned by a user
+func (w *WebPushSubscriber) Unsubscribe(userID uint, endpoint string) error {
+	result := w.db.Where("endpoint = ? AND user_id = ?", endpoint, userID).Delete(&models.PushSubscription{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPushSubscriptionNotFound
+	}
+	return nil
+}
+
+// Deliver pushes a notification to every subscribed browser. Subscriptions
+// the push service reports as expired are removed.
+func (w *WebPushSubscriber) Deliver(n Notification) {
+	var subs []models.PushSubscription
+	if err := w.db.Find(&subs).Error; err != nil {
+		logger.Warn("Failed to list push subscriptions", zap.Error(err))
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	body := n.Body
+	if len(body) > maxPushBody {
+		body = strings.ToValidUTF8(body[:maxPushBody], "") + "…"
+	}
+	payload, err := json.Marshal(Notification{
+		Type:       n.Type,
+		Title:      n.Title,
+		Body:       body,
+		Severity:   n.Severity,
+		Source:     n.Source,
+		OccurredAt: n.OccurredAt,
+	})
+	if err != nil {
+		logger.Warn("Failed to encode push notification", zap.Error(err))
+		return
+	}
+
+	for i := range subs {
+		status, err := w.send(&subs[i], payload, n.Severity)
+		if status == http.StatusNotFound || status == http.StatusGone {
+			w.db.Delete(&subs[i])
+			logger.Info("Removed expired push subscription", zap.Uint("id", subs[i].ID))
+			continue
+		}
+		if err != nil {
+			logger.Warn("Failed to deliver push notification",
+				zap.Uint("subscription", subs[i].ID),
+				zap.Error(err))
+		}
+	}
+}
+
+// send posts an encrypted payload to the push service of a subscription and
+// returns the HTTP status
+func (w *WebPushSubscriber) send(sub *models.PushSubscription, payload []byte, severity string) (int, error) {
+	body, err := encryptPayload(sub.P256dh, sub.Auth, payload)
+	if err != nil {
+		return 0, err
+	}
+	authorization, err := w.vapidAuthorization(sub.Endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprintf("%d", int(pushTTL.Seconds())))
+	req.Header.Set("Urgency", pushUrgency(severity))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send push request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("push service returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp.StatusCode, nil
+}
+
+// vapidAuthorization returns the Authorization header identifying the server
+// to the push service of endpoint
+func (w *WebPushSubscriber) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint: %w", err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.subject,
+	}).SignedString(w.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, w.publicKey), nil
+}
+
+// pushUrgency maps a severity to the Urgency header of RFC 8030
+func pushUrgency(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "high"
+	case SeverityWarning:
+		return "normal"
+	default:
+		return "low"
+	}
+}
+
+// encryptPayload encrypts a payload for a browser with the aes128gcm content
+// encoding of RFC 8291: an ephemeral ECDH key agreed with the browser's key,
+// mixed with its auth secret, derives the content encryption key
+func encryptPayload(p256dh, auth string, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64URL(auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ECDH key: %w", err)
+	}
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree ECDH key: %w", err)
+	}
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	cek, nonce, err := deriveContentKeys(sharedSecret, authSecret, salt, uaPublicBytes, asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single record, ended by the last record delimiter 0x02
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(asPublicBytes))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(asPublicBytes)))
+	header = append(header, asPublicBytes...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// deriveContentKeys derives the content encryption key and nonce of RFC 8291
+func deriveContentKeys(sharedSecret, authSecret, salt, uaPublic, asPublic []byte) (cek, nonce []byte, err error) {
+	prkKey, err := hkdf.Extract(sha256.New, sharedSecret, authSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cek, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16); err != nil {
+		return nil, nil, err
+	}
+	if nonce, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12); err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+// decodeBase64URL decodes base64url with or without padding, as browsers
+// and libraries differ
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
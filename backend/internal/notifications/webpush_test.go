@@ -0,0 +1,58 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+// TestEncryptPayloadRoundTrip decrypts a payload the way a browser does
+func TestEncryptPayloadRoundTrip(t *testing.T) {
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+	uaPublic := uaKey.PublicKey().Bytes()
+
+	payload := []byte(`{"title":"Split-brain on r0"}`)
+	body, err := encryptPayload(base64.RawURLEncoding.EncodeToString(uaPublic), base64.RawURLEncoding.EncodeToString(authSecret), payload)
+	if err != nil {
+		t.Fatalf("encryptPayload: %v", err)
+	}
+
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != pushRecordSize {
+		t.Errorf("record size = %d, want %d", rs, pushRecordSize)
+	}
+	keyLen := int(body[20])
+	asPublicBytes := body[21 : 21+keyLen]
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		t.Fatalf("invalid server key in header: %v", err)
+	}
+	shared, err := uaKey.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cek, nonce, err := deriveContentKeys(shared, authSecret, salt, uaPublic, asPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+keyLen:], nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if want := append(payload, 0x02); !bytes.Equal(plaintext, want) {
+		t.Errorf("plaintext = %q, want %q", plaintext, want)
+	}
+}
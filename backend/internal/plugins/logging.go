@@ -0,0 +1,140 @@
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// DefaultPluginLogLines is how many log lines are kept per plugin
+const DefaultPluginLogLines = 1000
+
+// maxPluginLogLine is the longest line read from a plugin; longer lines
+// are split
+const maxPluginLogLine = 64 * 1024
+
+// PluginLogCapture collects the stdout/stderr lines of a plugin in a ring
+// buffer, so the latest lines can be read and streamed through the API.
+// Every line is also written to the NAS log.
+type PluginLogCapture struct {
+	pluginID string
+
+	mu          sync.Mutex
+	entries     []models.PluginLog // Ring buffer
+	next        int                // Index the next line is written to
+	full        bool
+	subscribers map[chan models.PluginLog]struct{}
+}
+
+// NewPluginLogCapture creates a log capture keeping the latest size lines
+func NewPluginLogCapture(pluginID string, size int) *PluginLogCapture {
+	if size <= 0 {
+		size = DefaultPluginLogLines
+	}
+	return &PluginLogCapture{
+		pluginID:    pluginID,
+		entries:     make([]models.PluginLog, size),
+		subscribers: make(map[chan models.PluginLog]struct{}),
+	}
+}
+
+// Pipe returns a writer for a process output; each line written to it is
+// captured at the given level. Close the writer once the process has
+// exited to stop the reader.
+func (c *PluginLogCapture) Pipe(level string) io.WriteCloser {
+	pr, pw := io.Pipe()
+	go c.read(pr, level)
+	return pw
+}
+
+// read captures the lines of a process output until it is closed
+func (c *PluginLogCapture) read(r *io.PipeReader, level string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxPluginLogLine)
+	for scanner.Scan() {
+		c.add(level, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Warn("Failed to read plugin output", zap.String("pluginID", c.pluginID), zap.Error(err))
+	}
+	// Unblock the process if the reader stopped early
+	r.CloseWithError(io.ErrClosedPipe)
+}
+
+// add stores a line and passes it to the stream subscribers
+func (c *PluginLogCapture) add(level, message string) {
+	entry := models.PluginLog{
+		PluginID:  c.pluginID,
+		Level:     level,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	}
+
+	if level == models.PluginLogError {
+		logger.Error(fmt.Sprintf("[Plugin:%s] %s", c.pluginID, message), zap.String("pluginID", c.pluginID))
+	} else {
+		logger.Info(fmt.Sprintf("[Plugin:%s] %s", c.pluginID, message), zap.String("pluginID", c.pluginID))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[c.next] = entry
+	c.next = (c.next + 1) % len(c.entries)
+	if c.next == 0 {
+		c.full = true
+	}
+
+	for ch := range c.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			// Slow subscribers miss lines rather than blocking the plugin
+		}
+	}
+}
+
+// Entries returns the captured lines since the given time (all if zero),
+// oldest first, limited to the last tail lines if tail is positive
+func (c *PluginLogCapture) Entries(tail int, since time.Time) []models.PluginLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ordered := c.entries[:c.next]
+	if c.full {
+		ordered = append(append([]models.PluginLog{}, c.entries[c.next:]...), c.entries[:c.next]...)
+	}
+
+	result := make([]models.PluginLog, 0, len(ordered))
+	for _, entry := range ordered {
+		if since.IsZero() || !entry.Timestamp.Before(since) {
+			result = append(result, entry)
+		}
+	}
+	if tail > 0 && len(result) > tail {
+		result = result[len(result)-tail:]
+	}
+	return result
+}
+
+// Subscribe returns a channel receiving new lines and a function to
+// unsubscribe
+func (c *PluginLogCapture) Subscribe() (<-chan models.PluginLog, func()) {
+	ch := make(chan models.PluginLog, 64)
+
+	c.mu.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.mu.Unlock()
+
+	return ch, func() {
+		c.mu.Lock()
+		delete(c.subscribers, ch)
+		c.mu.Unlock()
+	}
+}
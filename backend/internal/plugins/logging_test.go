@@ -0,0 +1,103 @@
+package plugins
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// waitForLines waits until the capture holds n lines
+func waitForLines(t *testing.T, c *PluginLogCapture, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(c.Entries(0, time.Time{})) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("captured %d lines, want %d", len(c.Entries(0, time.Time{})), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func messages(entries []models.PluginLog) []string {
+	result := make([]string, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.Level + " " + entry.Message
+	}
+	return result
+}
+
+func TestPluginLogCapture(t *testing.T) {
+	logger.InitLogger("error", false)
+	capture := NewPluginLogCapture("backup-sync", 4)
+	events, unsubscribe := capture.Subscribe()
+	defer unsubscribe()
+
+	stdout := capture.Pipe(models.PluginLogInfo)
+	// Lines may be split across writes
+	io.WriteString(stdout, "starting\nlistening on :9000\npart")
+	io.WriteString(stdout, "ial line\n")
+	stdout.Close()
+	waitForLines(t, capture, 3)
+
+	stderr := capture.Pipe(models.PluginLogError)
+	io.WriteString(stderr, "disk full\nretrying\n")
+	stderr.Close()
+	waitForLines(t, capture, 4)
+
+	// The oldest line was evicted
+	want := []string{"info listening on :9000", "info partial line", "error disk full", "error retrying"}
+	if got := messages(capture.Entries(0, time.Time{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	if got := messages(capture.Entries(2, time.Time{})); !reflect.DeepEqual(got, want[2:]) {
+		t.Errorf("tail 2 = %v, want %v", got, want[2:])
+	}
+	if got := capture.Entries(0, time.Now().Add(time.Minute)); len(got) != 0 {
+		t.Errorf("entries since the future = %v, want none", messages(got))
+	}
+
+	// Subscribers receive every line as it is captured
+	for i := 0; i < 5; i++ {
+		select {
+		case entry := <-events:
+			if entry.PluginID != "backup-sync" {
+				t.Errorf("streamed line of plugin %q", entry.PluginID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("streamed %d lines, want 5", i)
+		}
+	}
+}
+
+func TestPluginLogCaptureEviction(t *testing.T) {
+	logger.InitLogger("error", false)
+	capture := NewPluginLogCapture("chatty", 10)
+
+	stdout := capture.Pipe(models.PluginLogInfo)
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(stdout, "line %d\n", i)
+	}
+	stdout.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries := capture.Entries(0, time.Time{})
+		if len(entries) == 10 && entries[9].Message == "line 24" {
+			for i, entry := range entries {
+				if want := fmt.Sprintf("line %d", 15+i); entry.Message != want {
+					t.Errorf("entry %d = %q, want %q", i, entry.Message, want)
+				}
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entries = %v, want lines 15 to 24", messages(entries))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -4,12 +4,14 @@ package plugins
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"go.uber.org/zap"
 )
@@ -18,6 +20,7 @@ import (
 type Runtime struct {
 	service   *Service
 	processes map[string]*PluginProcess
+	logs      map[string]*PluginLogCapture // Kept across restarts
 	mu        sync.RWMutex
 }
 
@@ -30,6 +33,7 @@ type PluginProcess struct {
 	LastError error
 	ctx       context.Context
 	cancel    context.CancelFunc
+	outputs   []io.Closer // stdout/stderr pipes, closed once the process exited
 }
 
 // NewRuntime creates a new plugin runtime
@@ -37,6 +41,7 @@ func NewRuntime(service *Service) *Runtime {
 	return &Runtime{
 		service:   service,
 		processes: make(map[string]*PluginProcess),
+		logs:      make(map[string]*PluginLogCapture),
 	}
 }
 
@@ -89,13 +94,21 @@ func (r *Runtime) StartPlugin(ctx context.Context, pluginID string) error {
 		fmt.Sprintf("NAS_API_URL=http://localhost:8080/api/v1"),
 	)
 
-	// Set up logging
-	cmd.Stdout = logger.NewPluginLogger(pluginID, "stdout")
-	cmd.Stderr = logger.NewPluginLogger(pluginID, "stderr")
+	// Capture the output for the log API
+	capture, exists := r.logs[pluginID]
+	if !exists {
+		capture = NewPluginLogCapture(pluginID, DefaultPluginLogLines)
+		r.logs[pluginID] = capture
+	}
+	stdout, stderr := capture.Pipe(models.PluginLogInfo), capture.Pipe(models.PluginLogError)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	// Start process
 	if err := cmd.Start(); err != nil {
 		cancel()
+		stdout.Close()
+		stderr.Close()
 		return fmt.Errorf("failed to start plugin: %w", err)
 	}
 
@@ -107,6 +120,7 @@ func (r *Runtime) StartPlugin(ctx context.Context, pluginID string) error {
 		Status:    "running",
 		ctx:       procCtx,
 		cancel:    cancel,
+		outputs:   []io.Closer{stdout, stderr},
 	}
 
 	r.processes[pluginID] = proc
@@ -224,6 +238,11 @@ func (r *Runtime) StopAll(ctx context.Context) error {
 func (r *Runtime) monitorProcess(proc *PluginProcess) {
 	err := proc.Cmd.Wait()
 
+	// Wait has copied all output to the pipes
+	for _, output := range proc.outputs {
+		output.Close()
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -246,3 +265,41 @@ func (r *Runtime) monitorProcess(proc *PluginProcess) {
 	// Keep process in map for status reporting
 	// It will be removed on explicit stop or restart
 }
+
+// GetPluginLogs returns the captured output lines of a plugin since the
+// given time (all if zero), limited to the last tail lines if tail is
+// positive. Lines of earlier runs are kept until the buffer is full.
+func (r *Runtime) GetPluginLogs(pluginID string, tail int, since time.Time) ([]models.PluginLog, error) {
+	if _, err := r.service.GetPlugin(context.Background(), pluginID); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	capture, exists := r.logs[pluginID]
+	r.mu.RUnlock()
+	if !exists {
+		// Not started yet
+		return []models.PluginLog{}, nil
+	}
+	return capture.Entries(tail, since), nil
+}
+
+// SubscribePluginLogs returns a channel receiving the new output lines of
+// a plugin and a function to unsubscribe
+func (r *Runtime) SubscribePluginLogs(pluginID string) (<-chan models.PluginLog, func(), error) {
+	if _, err := r.service.GetPlugin(context.Background(), pluginID); err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	capture, exists := r.logs[pluginID]
+	if !exists {
+		// Capture the lines of the next start
+		capture = NewPluginLogCapture(pluginID, DefaultPluginLogLines)
+		r.logs[pluginID] = capture
+	}
+	ch, unsubscribe := capture.Subscribe()
+	return ch, unsubscribe, nil
+}
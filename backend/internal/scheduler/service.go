@@ -5,11 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/accesslog"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/audit"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/auth"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -17,13 +24,18 @@ import (
 
 // Service handles scheduled task management and execution
 type Service struct {
-	db      *gorm.DB
-	mu      sync.RWMutex
-	running bool
-	stop    chan bool
-	tasks   map[uint]*taskRunner
+	db             *gorm.DB
+	mu             sync.RWMutex
+	running        bool
+	stop           chan bool
+	tasks          map[uint]*taskRunner
+	reloadInterval time.Duration
+	intervalCh     chan time.Duration
 }
 
+// defaultReloadInterval is used when no scheduler interval is configured
+const defaultReloadInterval = time.Minute
+
 type taskRunner struct {
 	task      *models.ScheduledTask
 	schedule  *CronSchedule
@@ -46,9 +58,14 @@ func Initialize() (*Service, error) {
 		}
 
 		globalService = &Service{
-			db:    db,
-			tasks: make(map[uint]*taskRunner),
-			stop:  make(chan bool),
+			db:             db,
+			tasks:          make(map[uint]*taskRunner),
+			stop:           make(chan bool),
+			reloadInterval: defaultReloadInterval,
+			intervalCh:     make(chan time.Duration, 1),
+		}
+		if config.GlobalConfig != nil && config.GlobalConfig.Scheduler.ReloadInterval > 0 {
+			globalService.reloadInterval = config.GlobalConfig.Scheduler.ReloadInterval
 		}
 
 		logger.Info("Scheduler service initialized")
@@ -74,6 +91,10 @@ func (s *Service) Start() error {
 		return fmt.Errorf("scheduler already running")
 	}
 
+	if err := s.ensureBuiltinTasks(); err != nil {
+		logger.Warn("Failed to create built-in tasks", zap.Error(err))
+	}
+
 	s.running = true
 	go s.run()
 
@@ -96,11 +117,42 @@ func (s *Service) Stop() {
 	logger.Info("Scheduler stopped")
 }
 
+// ApplyConfig applies a new task reload interval from a reloaded configuration
+func (s *Service) ApplyConfig(cfg *config.Config) error {
+	interval := cfg.Scheduler.ReloadInterval
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if interval == s.reloadInterval {
+		return nil
+	}
+	s.reloadInterval = interval
+
+	// Replace any pending, not yet applied interval
+	select {
+	case <-s.intervalCh:
+	default:
+	}
+	s.intervalCh <- interval
+
+	logger.Info("Scheduler reload interval updated", zap.Duration("interval", interval))
+	return nil
+}
+
 // run is the main scheduler loop
 func (s *Service) run() {
 	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
 	defer ticker.Stop()
 
+	s.mu.RLock()
+	reloadTicker := time.NewTicker(s.reloadInterval)
+	s.mu.RUnlock()
+	defer reloadTicker.Stop()
+
 	// Initial load
 	if err := s.loadTasks(); err != nil {
 		logger.Error("Failed to load tasks", zap.Error(err))
@@ -110,10 +162,13 @@ func (s *Service) run() {
 		select {
 		case <-ticker.C:
 			s.checkAndRunTasks()
+		case <-reloadTicker.C:
 			// Reload tasks periodically to pick up changes
 			if err := s.loadTasks(); err != nil {
 				logger.Error("Failed to reload tasks", zap.Error(err))
 			}
+		case interval := <-s.intervalCh:
+			reloadTicker.Reset(interval)
 		case <-s.stop:
 			return
 		}
@@ -253,6 +308,22 @@ func (s *Service) executeTask(task *models.ScheduledTask) {
 	})
 }
 
+// TaskFunc runs a scheduled task and returns its output
+type TaskFunc func(ctx context.Context, task *models.ScheduledTask) (string, error)
+
+var (
+	registeredTasks   = make(map[string]TaskFunc)
+	registeredTasksMu sync.RWMutex
+)
+
+// RegisterTaskType runs the tasks of a type with run. It is for task types
+// of packages the scheduler cannot import.
+func RegisterTaskType(taskType string, run TaskFunc) {
+	registeredTasksMu.Lock()
+	defer registeredTasksMu.Unlock()
+	registeredTasks[taskType] = run
+}
+
 // runTaskType executes the actual task based on its type
 func (s *Service) runTaskType(ctx context.Context, task *models.ScheduledTask) (string, error) {
 	switch task.TaskType {
@@ -262,7 +333,21 @@ func (s *Service) runTaskType(ctx context.Context, task *models.ScheduledTask) (
 		return s.runMaintenanceTask(ctx, task)
 	case models.TaskTypeLogRotation:
 		return s.runLogRotationTask(ctx, task)
+	case models.TaskTypeAccessLogCleanup:
+		return s.runAccessLogCleanupTask(ctx, task)
+	case models.TaskTypeInactiveUserCheck:
+		return s.runInactiveUserCheckTask(ctx, task)
+	case models.TaskTypeLockoutAutoUnlock:
+		return s.runLockoutAutoUnlockTask(ctx, task)
+	case models.TaskTypeAuditRetention:
+		return s.runAuditRetentionTask(ctx, task)
 	default:
+		registeredTasksMu.RLock()
+		run, ok := registeredTasks[task.TaskType]
+		registeredTasksMu.RUnlock()
+		if ok {
+			return run(ctx, task)
+		}
 		return "", fmt.Errorf("unsupported task type: %s", task.TaskType)
 	}
 }
@@ -324,6 +409,157 @@ func (s *Service) runLogRotationTask(ctx context.Context, task *models.Scheduled
 	return "Log rotation completed", nil
 }
 
+// runAccessLogCleanupTask deletes access logs older than logging.retentionDays.
+// A retentionDays value in the task config takes precedence.
+func (s *Service) runAccessLogCleanupTask(ctx context.Context, task *models.ScheduledTask) (string, error) {
+	var taskConfig struct {
+		RetentionDays int `json:"retentionDays"`
+	}
+
+	if task.Config != "" {
+		if err := json.Unmarshal([]byte(task.Config), &taskConfig); err != nil {
+			return "", fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	retentionDays := taskConfig.RetentionDays
+	if retentionDays == 0 && config.GlobalConfig != nil {
+		retentionDays = config.GlobalConfig.Logging.RetentionDays
+	}
+	if retentionDays <= 0 {
+		return "Access log retention disabled, nothing deleted", nil
+	}
+
+	deleted, err := accesslog.NewService(s.db).Cleanup(ctx, retentionDays)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Access log cleanup completed: %d entries older than %d days deleted", deleted, retentionDays), nil
+}
+
+// runInactiveUserCheckTask alerts about active accounts that have not logged
+// in for the configured number of days (default 90)
+func (s *Service) runInactiveUserCheckTask(ctx context.Context, task *models.ScheduledTask) (string, error) {
+	var taskConfig struct {
+		Days int `json:"days"`
+	}
+
+	if task.Config != "" {
+		if err := json.Unmarshal([]byte(task.Config), &taskConfig); err != nil {
+			return "", fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if taskConfig.Days <= 0 {
+		taskConfig.Days = 90
+	}
+
+	inactive, err := users.GetInactiveUsers(time.Now().AddDate(0, 0, -taskConfig.Days))
+	if err != nil {
+		return "", err
+	}
+	if len(inactive) == 0 {
+		return fmt.Sprintf("No accounts inactive for %d days", taskConfig.Days), nil
+	}
+
+	usernames := make([]string, len(inactive))
+	for i, u := range inactive {
+		usernames[i] = u.Username
+	}
+	if err := alerts.GetService().SendInactiveUserAlert(ctx, usernames, taskConfig.Days); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d accounts inactive for %d days: %s", len(inactive), taskConfig.Days, strings.Join(usernames, ", ")), nil
+}
+
+// runLockoutAutoUnlockTask lifts IP and account lockouts whose duration has expired
+func (s *Service) runLockoutAutoUnlockTask(ctx context.Context, task *models.ScheduledTask) (string, error) {
+	service := auth.GetFailedLoginService()
+	if service == nil {
+		return "", fmt.Errorf("failed login service not initialized")
+	}
+
+	unlocked, err := service.AutoUnlockJob(time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d expired lockouts lifted", unlocked), nil
+}
+
+// runAuditRetentionTask deletes and archives audit logs as configured by
+// the audit retention policy
+func (s *Service) runAuditRetentionTask(ctx context.Context, task *models.ScheduledTask) (string, error) {
+	if err := audit.GetService().EnforceRetention(); err != nil {
+		return "", err
+	}
+	return "Audit log retention enforced", nil
+}
+
+// builtinTasks are created on first start. Admins can disable or
+// reschedule them like any other task.
+var builtinTasks = []models.ScheduledTask{
+	{
+		Name:           "Access log cleanup",
+		Description:    "Deletes access logs older than logging.retentionDays",
+		TaskType:       models.TaskTypeAccessLogCleanup,
+		CronExpression: "0 3 * * *", // Daily at 03:00
+		Enabled:        true,
+	},
+	{
+		Name:           "Inactive user check",
+		Description:    "Alerts about active accounts without a login in the last 90 days",
+		TaskType:       models.TaskTypeInactiveUserCheck,
+		CronExpression: "0 6 * * 1", // Mondays at 06:00
+		Config:         `{"days":90}`,
+		Enabled:        true,
+	},
+	{
+		Name:           "Lockout auto-unlock",
+		Description:    "Lifts IP and account lockouts after the lockout policy's duration",
+		TaskType:       models.TaskTypeLockoutAutoUnlock,
+		CronExpression: "* * * * *", // Every minute
+		Enabled:        true,
+	},
+	{
+		Name:           "Audit log retention",
+		Description:    "Deletes and archives audit logs as configured by the audit retention policy",
+		TaskType:       models.TaskTypeAuditRetention,
+		CronExpression: "30 2 * * *", // Daily at 02:30
+		Enabled:        true,
+	},
+	{
+		Name:           "Quota check",
+		Description:    "Alerts about users who used 90% of their disk quota",
+		TaskType:       models.TaskTypeQuotaCheck,
+		CronExpression: "0 7 * * *", // Daily at 07:00
+		Config:         `{"warnPercent":90}`,
+		Enabled:        true,
+	},
+}
+
+// ensureBuiltinTasks creates the built-in tasks whose type has no task yet
+func (s *Service) ensureBuiltinTasks() error {
+	for _, builtin := range builtinTasks {
+		var count int64
+		if err := s.db.Model(&models.ScheduledTask{}).
+			Where("task_type = ?", builtin.TaskType).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		task := builtin
+		if err := s.db.Create(&task).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CreateTask creates a new scheduled task
 func (s *Service) CreateTask(ctx context.Context, task *models.ScheduledTask) error {
 	// Validate cron expression
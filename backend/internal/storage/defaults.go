@@ -2,122 +2,274 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// DefaultShareSpec describes a share created on first run. Path is relative
+// to the storage directory.
+type DefaultShareSpec struct {
+	Name        string
+	Path        string
+	Type        ShareType
+	Description string
+	Browseable  bool
+	GuestOK     bool
+}
+
+// defaultShares are the shares provisioned on startup. Changing a spec
+// provisions the share again on the next startup.
+var defaultShares = []DefaultShareSpec{
+	{
+		Name:        "Files",
+		Path:        "files",
+		Type:        ShareTypeSMB,
+		Description: "Default file storage share",
+		Browseable:  true,
+		GuestOK:     false, // Require authentication
+	},
+	{
+		Name:        "Media",
+		Path:        "media",
+		Type:        ShareTypeSMB,
+		Description: "Media files (videos, music, photos)",
+		Browseable:  true,
+		GuestOK:     false,
+	},
+}
+
+// defaultStorageDirs are tried in order for the default storage directory
+var defaultStorageDirs = []string{
+	"/mnt/stumpfworks-nas/storage",
+	"/mnt/storage",
+	"/data/storage",
+	"/storage",
+	"/home/storage",
+	"/tmp/stumpfworks-nas-storage", // Fallback for testing
+}
+
+// Checksum returns the SHA-256 of the spec, which identifies its
+// configuration in DefaultShareProvision
+func (s DefaultShareSpec) Checksum() string {
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DefaultShareManager provisions the default shares and records each one in
+// the default_share_provisions table, so repeated startups neither duplicate
+// shares nor re-create shares the admin removed
+type DefaultShareManager struct {
+	db          *gorm.DB
+	specs       []DefaultShareSpec
+	storageDirs []string
+	configure   func(*models.Share) error
+}
+
+// NewDefaultShareManager creates a manager for the built-in default shares
+func NewDefaultShareManager(db *gorm.DB) *DefaultShareManager {
+	return &DefaultShareManager{
+		db:          db,
+		specs:       defaultShares,
+		storageDirs: defaultStorageDirs,
+		configure:   configureDefaultShare,
+	}
+}
+
+func configureDefaultShare(share *models.Share) error {
+	if ShareType(share.Type) == ShareTypeNFS {
+		return configureNFSShare(share)
+	}
+	return configureSMBShare(share)
+}
+
 // EnsureDefaultShares ensures that at least one share exists for users to access
 // This prevents "Access Denied" errors when no shares are configured
 func EnsureDefaultShares() error {
+	defer database.Cached().InvalidateShares()
+	return NewDefaultShareManager(database.DB).EnsureDefaultShares()
+}
+
+// EnsureDefaultShares provisions the default shares that were never
+// provisioned or whose spec changed since
+func (m *DefaultShareManager) EnsureDefaultShares() error {
 	logger.Info("Checking for default shares...")
 
-	// Check if any shares exist
-	var count int64
-	if err := database.DB.Model(&models.Share{}).Count(&count).Error; err != nil {
+	if err := m.adoptExistingInstall(); err != nil {
 		return err
 	}
 
-	if count > 0 {
-		logger.Info("Shares already exist, skipping default share creation", zap.Int64("count", count))
+	var pending []DefaultShareSpec
+	for _, spec := range m.specs {
+		var provision models.DefaultShareProvision
+		err := m.db.Where("share_name = ?", spec.Name).First(&provision).Error
+		if err == nil && provision.Checksum == spec.Checksum() {
+			continue
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to query default share provisions: %w", err)
+		}
+		pending = append(pending, spec)
+	}
+
+	if len(pending) == 0 {
+		logger.Info("Default shares already provisioned")
 		return nil
 	}
 
-	logger.Info("No shares found, creating default share...")
+	storageDir := m.storageDir()
+	if storageDir == "" {
+		logger.Warn("Could not create storage directory, file access may be limited")
+		return nil // Don't fail - system can still work without shares
+	}
+
+	for _, spec := range pending {
+		if err := m.provision(spec, storageDir); err != nil {
+			return err
+		}
+	}
 
-	// Define default storage path
-	defaultPath := "/mnt/stumpfworks-nas/storage"
+	writeStorageReadme(storageDir)
+	return nil
+}
 
-	// Try common alternative paths if default doesn't exist
-	alternativePaths := []string{
-		defaultPath,
-		"/mnt/storage",
-		"/data/storage",
-		"/storage",
-		"/home/storage",
-		"/tmp/stumpfworks-nas-storage", // Fallback for testing
+// adoptExistingInstall marks all default shares as provisioned on installs
+// that have shares but predate provision tracking. Such installs got their
+// default shares on first run; the missing ones were removed by the admin.
+func (m *DefaultShareManager) adoptExistingInstall() error {
+	var provisions, shares int64
+	if err := m.db.Model(&models.DefaultShareProvision{}).Count(&provisions).Error; err != nil {
+		return fmt.Errorf("failed to count default share provisions: %w", err)
+	}
+	if provisions > 0 {
+		return nil
+	}
+	if err := m.db.Model(&models.Share{}).Count(&shares).Error; err != nil {
+		return fmt.Errorf("failed to count shares: %w", err)
+	}
+	if shares == 0 {
+		return nil
 	}
 
-	var selectedPath string
-	for _, path := range alternativePaths {
-		if err := os.MkdirAll(path, 0755); err == nil {
-			selectedPath = path
-			logger.Info("Using storage path", zap.String("path", path))
-			break
+	logger.Info("Shares already exist, marking default shares as provisioned", zap.Int64("count", shares))
+	for _, spec := range m.specs {
+		if err := m.recordProvision(m.db, spec); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	if selectedPath == "" {
-		logger.Warn("Could not create storage directory, file access may be limited")
-		return nil // Don't fail - system can still work without shares
-	}
+// storageDir returns the first usable default storage directory with its
+// subdirectories created, or "" if none can be created
+func (m *DefaultShareManager) storageDir() string {
+	for _, path := range m.storageDirs {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			continue
+		}
+		logger.Info("Using storage path", zap.String("path", path))
 
-	// Create subdirectories for organization
-	subdirs := []string{"files", "media", "documents", "backups"}
-	for _, subdir := range subdirs {
-		subdirPath := filepath.Join(selectedPath, subdir)
-		if err := os.MkdirAll(subdirPath, 0755); err != nil {
-			logger.Warn("Failed to create subdirectory", zap.String("path", subdirPath), zap.Error(err))
+		// Create subdirectories for organization
+		for _, subdir := range []string{"files", "media", "documents", "backups"} {
+			subdirPath := filepath.Join(path, subdir)
+			if err := os.MkdirAll(subdirPath, 0755); err != nil {
+				logger.Warn("Failed to create subdirectory", zap.String("path", subdirPath), zap.Error(err))
+			}
 		}
+		return path
 	}
+	return ""
+}
 
-	// Create default SMB share for general file storage
-	defaultShare := &models.Share{
-		Name:        "Files",
-		Path:        filepath.Join(selectedPath, "files"),
-		Type:        "smb",
-		Description: "Default file storage share",
+// provision creates or updates the share of spec and records the provision
+// in one transaction. The share name is unique, so concurrent startups
+// update the same record instead of creating a second one.
+func (m *DefaultShareManager) provision(spec DefaultShareSpec, storageDir string) error {
+	share := models.Share{
+		Name:        spec.Name,
+		Path:        filepath.Join(storageDir, spec.Path),
+		Type:        string(spec.Type),
+		Description: spec.Description,
 		Enabled:     true,
-		ReadOnly:    false,
-		Browseable:  true,
-		GuestOK:     false, // Require authentication
-		ValidUsers:  "",    // Empty = all authenticated users can access
+		Browseable:  spec.Browseable,
+		GuestOK:     spec.GuestOK,
+		ValidUsers:  "", // Empty = all authenticated users can access
 	}
 
-	if err := database.DB.Create(defaultShare).Error; err != nil {
-		logger.Error("Failed to create default share", zap.Error(err))
-		return err
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Share
+		err := tx.Where("name = ?", spec.Name).First(&existing).Error
+		switch {
+		case err == nil:
+			share.ID = existing.ID
+			share.CreatedAt = existing.CreatedAt
+			share.VolumeID = existing.VolumeID
+			share.ReadOnly = existing.ReadOnly
+			share.ValidUsers = existing.ValidUsers
+			share.ValidGroups = existing.ValidGroups
+			if err := tx.Save(&share).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(&share).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		return m.recordProvision(tx, spec)
+	})
+	if err != nil {
+		logger.Error("Failed to create default share", zap.String("name", spec.Name), zap.Error(err))
+		return fmt.Errorf("failed to provision default share %s: %w", spec.Name, err)
 	}
 
-	// Configure Samba for the share
-	if err := configureSMBShare(defaultShare); err != nil {
-		logger.Warn("Failed to configure Samba for default share",
+	if err := m.configure(&share); err != nil {
+		logger.Warn("Failed to configure default share",
+			zap.String("name", share.Name),
 			zap.Error(err),
 			zap.String("note", "Share created but network access may not work"))
 		// Don't fail - share exists in DB for File Manager
 	}
 
-	logger.Info("Default share created successfully",
-		zap.String("name", defaultShare.Name),
-		zap.String("path", defaultShare.Path))
+	logger.Info("Default share provisioned",
+		zap.String("name", share.Name),
+		zap.String("path", share.Path))
+	return nil
+}
 
-	// Create additional media share
-	mediaShare := &models.Share{
-		Name:        "Media",
-		Path:        filepath.Join(selectedPath, "media"),
-		Type:        "smb",
-		Description: "Media files (videos, music, photos)",
-		Enabled:     true,
-		ReadOnly:    false,
-		Browseable:  true,
-		GuestOK:     false,
-		ValidUsers:  "",
+func (m *DefaultShareManager) recordProvision(tx *gorm.DB, spec DefaultShareSpec) error {
+	provision := models.DefaultShareProvision{
+		ShareName:     spec.Name,
+		ProvisionedAt: time.Now(),
+		Checksum:      spec.Checksum(),
 	}
-
-	if err := database.DB.Create(mediaShare).Error; err != nil {
-		logger.Warn("Failed to create media share", zap.Error(err))
-		// Not critical - Files share is enough
-	} else {
-		configureSMBShare(mediaShare)
-		logger.Info("Media share created successfully")
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "share_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"provisioned_at", "checksum"}),
+	}).Create(&provision).Error
+	if err != nil {
+		return fmt.Errorf("failed to record default share provision: %w", err)
 	}
+	return nil
+}
 
-	// Create README in storage directory
-	readmePath := filepath.Join(selectedPath, "README.txt")
+// writeStorageReadme creates a README in the storage directory
+func writeStorageReadme(storageDir string) {
+	readmePath := filepath.Join(storageDir, "README.txt")
 	readmeContent := `Stumpf.Works NAS - Default Storage
 
 This directory contains the default file storage for your NAS system.
@@ -137,6 +289,4 @@ You can access these folders via:
 To add more shares, use the Storage app in the web interface.
 `
 	os.WriteFile(readmePath, []byte(readmeContent), 0644)
-
-	return nil
 }
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestDefaultShareManager(t *testing.T) (*DefaultShareManager, *int) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "defaults.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Share{}, &models.DefaultShareProvision{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	configured := 0
+	m := NewDefaultShareManager(db)
+	m.storageDirs = []string{t.TempDir()}
+	m.configure = func(*models.Share) error {
+		configured++
+		return nil
+	}
+	return m, &configured
+}
+
+func countShares(t *testing.T, db *gorm.DB, name string) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.Share{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		t.Fatalf("count shares: %v", err)
+	}
+	return count
+}
+
+func TestEnsureDefaultSharesIdempotent(t *testing.T) {
+	m, configured := newTestDefaultShareManager(t)
+
+	for i := 0; i < 2; i++ {
+		if err := m.EnsureDefaultShares(); err != nil {
+			t.Fatalf("EnsureDefaultShares (run %d): %v", i+1, err)
+		}
+	}
+
+	for _, spec := range defaultShares {
+		if n := countShares(t, m.db, spec.Name); n != 1 {
+			t.Errorf("got %d %s shares, want 1", n, spec.Name)
+		}
+	}
+	if *configured != len(defaultShares) {
+		t.Errorf("configured %d shares, want %d", *configured, len(defaultShares))
+	}
+
+	// A changed spec updates the share in place
+	m.specs = append([]DefaultShareSpec(nil), defaultShares...)
+	m.specs[0].Description = "Changed"
+	if err := m.EnsureDefaultShares(); err != nil {
+		t.Fatalf("EnsureDefaultShares: %v", err)
+	}
+	var share models.Share
+	m.db.Where("name = ?", m.specs[0].Name).First(&share)
+	if share.Description != "Changed" || countShares(t, m.db, share.Name) != 1 {
+		t.Errorf("share after spec change = %+v", share)
+	}
+	if *configured != len(defaultShares)+1 {
+		t.Errorf("configured %d shares, want %d", *configured, len(defaultShares)+1)
+	}
+}
+
+func TestEnsureDefaultSharesKeepsRemovedShares(t *testing.T) {
+	m, _ := newTestDefaultShareManager(t)
+
+	if err := m.EnsureDefaultShares(); err != nil {
+		t.Fatalf("EnsureDefaultShares: %v", err)
+	}
+	m.db.Where("name = ?", defaultShares[1].Name).Delete(&models.Share{})
+	if err := m.EnsureDefaultShares(); err != nil {
+		t.Fatalf("EnsureDefaultShares: %v", err)
+	}
+	if n := countShares(t, m.db, defaultShares[1].Name); n != 0 {
+		t.Errorf("removed share was re-created")
+	}
+}
+
+func TestEnsureDefaultSharesAdoptsExistingInstall(t *testing.T) {
+	m, configured := newTestDefaultShareManager(t)
+	m.db.Create(&models.Share{Name: "Backups", Path: "/srv/backups", Type: "smb"})
+
+	if err := m.EnsureDefaultShares(); err != nil {
+		t.Fatalf("EnsureDefaultShares: %v", err)
+	}
+	if *configured != 0 || countShares(t, m.db, defaultShares[0].Name) != 0 {
+		t.Error("default shares created on an install that already has shares")
+	}
+	var provisions int64
+	m.db.Model(&models.DefaultShareProvision{}).Count(&provisions)
+	if provisions != int64(len(defaultShares)) {
+		t.Errorf("got %d provisions, want %d", provisions, len(defaultShares))
+	}
+}
@@ -383,8 +383,8 @@ func GetSMARTData(diskName string) (*SMARTData, error) {
 	diskPath := "/dev/" + diskName
 
 	// Check if smartctl is available
-	if _, err := exec.LookPath("smartctl"); err != nil {
-		return nil, fmt.Errorf("smartctl not available")
+	if _, err := sysutil.RequireCommand("smartctl"); err != nil {
+		return nil, err
 	}
 
 	// Run smartctl
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// defaultQuotaWarnPercent is the quota usage users are warned at if the
+// quota check task configures none
+const defaultQuotaWarnPercent = 90
+
+// QuotaWarning is a user who used most of their disk quota
+type QuotaWarning struct {
+	Username    string  `json:"username"`
+	Filesystem  string  `json:"filesystem"`
+	UsedPercent float64 `json:"usedPercent"` // Of the hard limit, or the soft one if there is none
+	FreeBytes   int64   `json:"freeBytes"`   // Until that limit
+}
+
+// quotaReporter reports the quota usage of a filesystem
+type quotaReporter interface {
+	GetReport(fs string) ([]filesystem.QuotaInfo, error)
+	GetFilesystemQuotaStatus(fs string) (*filesystem.FilesystemQuotaStatus, error)
+}
+
+// QuotaMonitor alerts when users approach their disk quota. The quota
+// manager only enforces the limits, so users otherwise learn about them when
+// writes start failing.
+type QuotaMonitor struct {
+	quotas  quotaReporter
+	volumes func() ([]Volume, error)
+	publish func(notifications.Notification) error
+}
+
+// NewQuotaMonitor creates a monitor for the quotas of a quota manager
+func NewQuotaMonitor(quotas *filesystem.QuotaManager) *QuotaMonitor {
+	return &QuotaMonitor{
+		quotas:  quotas,
+		volumes: getMountedVolumes,
+		publish: notifications.Publish,
+	}
+}
+
+// CheckQuotas returns the users on a filesystem who used at least
+// warnPercent of their block limit and publishes a quota warning for
+// each of them
+func (m *QuotaMonitor) CheckQuotas(fs string, warnPercent float64) ([]QuotaWarning, error) {
+	if warnPercent <= 0 || warnPercent > 100 {
+		return nil, fmt.Errorf("warn percent must be between 0 and 100")
+	}
+
+	report, err := m.quotas.GetReport(fs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota report of %s: %w", fs, err)
+	}
+
+	var warnings []QuotaWarning
+	for _, quota := range report {
+		// Users with only a soft limit are warned about that one
+		limit := quota.BlocksHard
+		if limit == 0 {
+			limit = quota.BlocksSoft
+		}
+		if quota.Type != filesystem.UserQuota || limit == 0 {
+			continue
+		}
+		usedPercent := float64(quota.BlocksUsed) / float64(limit) * 100
+		if usedPercent < warnPercent {
+			continue
+		}
+
+		// Blocks are counted in KB
+		freeBytes := int64(0)
+		if quota.BlocksUsed < limit {
+			freeBytes = int64(limit-quota.BlocksUsed) * 1024
+		}
+		warning := QuotaWarning{
+			Username:    quota.Name,
+			Filesystem:  fs,
+			UsedPercent: usedPercent,
+			FreeBytes:   freeBytes,
+		}
+		warnings = append(warnings, warning)
+		m.report(warning)
+	}
+	return warnings, nil
+}
+
+// report publishes a notification about a user approaching their quota
+func (m *QuotaMonitor) report(warning QuotaWarning) {
+	err := m.publish(notifications.Notification{
+		Type:     models.AlertTypeStorageQuota,
+		Title:    fmt.Sprintf("%s used %.0f%% of their disk quota on %s", warning.Username, warning.UsedPercent, warning.Filesystem),
+		Body:     fmt.Sprintf("%s has %s left before writes to %s fail.", warning.Username, formatBytes(warning.FreeBytes), warning.Filesystem),
+		Severity: notifications.SeverityWarning,
+		Source:   "storage",
+		Metadata: map[string]interface{}{
+			"username":    warning.Username,
+			"filesystem":  warning.Filesystem,
+			"usedPercent": fmt.Sprintf("%.1f", warning.UsedPercent),
+			"freeBytes":   warning.FreeBytes,
+		},
+	})
+	if err != nil {
+		logger.Warn("Failed to publish quota warning", zap.String("username", warning.Username), zap.Error(err))
+	}
+}
+
+// RunTask runs the quota check task: it checks the filesystems of its
+// config, or all mounted volumes with user quotas on
+func (m *QuotaMonitor) RunTask(ctx context.Context, task *models.ScheduledTask) (string, error) {
+	var taskConfig struct {
+		WarnPercent float64  `json:"warnPercent"`
+		Filesystems []string `json:"filesystems"`
+	}
+	if task.Config != "" {
+		if err := json.Unmarshal([]byte(task.Config), &taskConfig); err != nil {
+			return "", fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if taskConfig.WarnPercent == 0 {
+		taskConfig.WarnPercent = defaultQuotaWarnPercent
+	}
+
+	filesystems := taskConfig.Filesystems
+	if len(filesystems) == 0 {
+		var err error
+		if filesystems, err = m.quotaFilesystems(); err != nil {
+			return "", err
+		}
+	}
+
+	var warned []string
+	for _, fs := range filesystems {
+		warnings, err := m.CheckQuotas(fs, taskConfig.WarnPercent)
+		if err != nil {
+			return "", err
+		}
+		for _, warning := range warnings {
+			warned = append(warned, fmt.Sprintf("%s on %s (%.0f%%)", warning.Username, fs, warning.UsedPercent))
+		}
+	}
+
+	if len(warned) == 0 {
+		return fmt.Sprintf("No user above %.0f%% of their quota on %d filesystems", taskConfig.WarnPercent, len(filesystems)), nil
+	}
+	return fmt.Sprintf("%d users above %.0f%% of their quota: %s", len(warned), taskConfig.WarnPercent, strings.Join(warned, ", ")), nil
+}
+
+// quotaFilesystems returns the mount points of the mounted volumes with
+// user quotas on
+func (m *QuotaMonitor) quotaFilesystems() ([]string, error) {
+	volumes, err := m.volumes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mounted volumes: %w", err)
+	}
+
+	var filesystems []string
+	seen := make(map[string]bool)
+	for _, vol := range volumes {
+		if vol.MountPoint == "" || seen[vol.MountPoint] {
+			continue
+		}
+		seen[vol.MountPoint] = true
+		if status, err := m.quotas.GetFilesystemQuotaStatus(vol.MountPoint); err == nil && status.UserQuotas {
+			filesystems = append(filesystems, vol.MountPoint)
+		}
+	}
+	return filesystems, nil
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. "1.5 GiB"
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/filesystem"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// fakeQuotaReporter returns a seeded report for each filesystem
+type fakeQuotaReporter struct {
+	reports    map[string][]filesystem.QuotaInfo
+	userQuotas map[string]bool
+}
+
+func (f *fakeQuotaReporter) GetReport(fs string) ([]filesystem.QuotaInfo, error) {
+	return f.reports[fs], nil
+}
+
+func (f *fakeQuotaReporter) GetFilesystemQuotaStatus(fs string) (*filesystem.FilesystemQuotaStatus, error) {
+	return &filesystem.FilesystemQuotaStatus{Filesystem: fs, UserQuotas: f.userQuotas[fs]}, nil
+}
+
+func newTestQuotaMonitor(t *testing.T, reporter *fakeQuotaReporter) (*QuotaMonitor, *[]notifications.Notification) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	var published []notifications.Notification
+	m := &QuotaMonitor{
+		quotas: reporter,
+		volumes: func() ([]Volume, error) {
+			return []Volume{
+				{ID: "sdb1", MountPoint: "/mnt/data"},
+				{ID: "sdc1", MountPoint: "/mnt/backup"},
+				{ID: "sdd1"},
+			}, nil
+		},
+		publish: func(n notifications.Notification) error {
+			published = append(published, n)
+			return nil
+		},
+	}
+	return m, &published
+}
+
+func userQuota(name string, usedKB, softKB, hardKB uint64) filesystem.QuotaInfo {
+	return filesystem.QuotaInfo{Name: name, Type: filesystem.UserQuota, BlocksUsed: usedKB, BlocksSoft: softKB, BlocksHard: hardKB}
+}
+
+func TestQuotaMonitorCheckQuotas(t *testing.T) {
+	reporter := &fakeQuotaReporter{reports: map[string][]filesystem.QuotaInfo{
+		"/mnt/data": {
+			userQuota("alice", 950, 800, 1000), // 95%
+			userQuota("bob", 899, 800, 1000),   // Just below 90%
+			userQuota("carol", 900, 0, 1000),   // Exactly 90%
+			userQuota("dave", 1200, 1000, 0),   // Over the soft limit without a hard one
+			{Name: "staff", Type: filesystem.GroupQuota, BlocksUsed: 1000, BlocksHard: 1000},
+		},
+	}}
+	m, published := newTestQuotaMonitor(t, reporter)
+
+	warnings, err := m.CheckQuotas("/mnt/data", 90)
+	if err != nil {
+		t.Fatalf("CheckQuotas() error = %v", err)
+	}
+	want := []QuotaWarning{
+		{Username: "alice", Filesystem: "/mnt/data", UsedPercent: 95, FreeBytes: 50 * 1024},
+		{Username: "carol", Filesystem: "/mnt/data", UsedPercent: 90, FreeBytes: 100 * 1024},
+		{Username: "dave", Filesystem: "/mnt/data", UsedPercent: 120, FreeBytes: 0},
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings = %+v, want %+v", warnings, want)
+	}
+	for i := range want {
+		if warnings[i] != want[i] {
+			t.Errorf("warning %d = %+v, want %+v", i, warnings[i], want[i])
+		}
+	}
+
+	if len(*published) != len(want) {
+		t.Fatalf("published %d notifications, want %d", len(*published), len(want))
+	}
+	n := (*published)[0]
+	if n.Type != models.AlertTypeStorageQuota || n.Severity != notifications.SeverityWarning {
+		t.Errorf("notification type %q severity %q", n.Type, n.Severity)
+	}
+	if n.Metadata["username"] != "alice" || n.Metadata["filesystem"] != "/mnt/data" ||
+		n.Metadata["usedPercent"] != "95.0" || n.Metadata["freeBytes"] != int64(50*1024) {
+		t.Errorf("notification metadata = %v", n.Metadata)
+	}
+	if !strings.Contains(n.Body, "50.0 KiB") {
+		t.Errorf("notification body %q lacks the remaining space", n.Body)
+	}
+
+	for _, warnPercent := range []float64{0, -5, 101} {
+		if _, err := m.CheckQuotas("/mnt/data", warnPercent); err == nil {
+			t.Errorf("CheckQuotas() accepted warn percent %v", warnPercent)
+		}
+	}
+}
+
+func TestQuotaMonitorRunTask(t *testing.T) {
+	reporter := &fakeQuotaReporter{
+		reports: map[string][]filesystem.QuotaInfo{
+			"/mnt/data":   {userQuota("alice", 800, 0, 1000)},
+			"/mnt/backup": {userQuota("bob", 990, 0, 1000)},
+		},
+		userQuotas: map[string]bool{"/mnt/data": true},
+	}
+	m, published := newTestQuotaMonitor(t, reporter)
+
+	// Only volumes with user quotas on are checked by default
+	output, err := m.RunTask(context.Background(), &models.ScheduledTask{Config: `{"warnPercent":75}`})
+	if err != nil {
+		t.Fatalf("RunTask() error = %v", err)
+	}
+	if len(*published) != 1 || (*published)[0].Metadata["username"] != "alice" {
+		t.Errorf("published %+v, want a warning for alice", *published)
+	}
+	if !strings.Contains(output, "alice on /mnt/data (80%)") {
+		t.Errorf("output = %q", output)
+	}
+
+	// The default threshold is 90%
+	*published = nil
+	output, err = m.RunTask(context.Background(), &models.ScheduledTask{Config: `{"filesystems":["/mnt/data","/mnt/backup"]}`})
+	if err != nil {
+		t.Fatalf("RunTask() error = %v", err)
+	}
+	if len(*published) != 1 || (*published)[0].Metadata["username"] != "bob" {
+		t.Errorf("published %+v, want a warning for bob", *published)
+	}
+	if !strings.HasPrefix(output, "1 users above 90%") {
+		t.Errorf("output = %q", output)
+	}
+
+	if _, err := m.RunTask(context.Background(), &models.ScheduledTask{Config: "{"}); err == nil {
+		t.Error("RunTask() accepted an invalid config")
+	}
+}
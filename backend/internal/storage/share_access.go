@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// shareAccessPollInterval is how often share connections are polled
+	shareAccessPollInterval = 30 * time.Second
+
+	// nfsRmtabPath lists the NFSv3 mounts of clients (host:export:count).
+	// /proc/net/rpc/nfsd and nfsstat only hold aggregate counters, so they
+	// cannot attribute connections to clients.
+	nfsRmtabPath = "/var/lib/nfs/rmtab"
+)
+
+// ShareSession is a client connection to a share seen in one poll
+type ShareSession struct {
+	Key          string
+	ShareName    string
+	Protocol     string
+	Username     string
+	ClientIP     string
+	ConnectedAt  time.Time
+	FilesRead    map[string]bool
+	FilesWritten map[string]bool
+}
+
+// smbstatus -j output. smbstatus -S only lists tree connects; the full
+// output adds the session usernames and open files.
+type smbstatusOutput struct {
+	Sessions map[string]struct {
+		Username      string `json:"username"`
+		RemoteMachine string `json:"remote_machine"`
+	} `json:"sessions"`
+	Tcons map[string]struct {
+		Service     string      `json:"service"`
+		ServerID    smbServerID `json:"server_id"`
+		TconID      string      `json:"tcon_id"`
+		SessionID   string      `json:"session_id"`
+		Machine     string      `json:"machine"`
+		ConnectedAt string      `json:"connected_at"`
+	} `json:"tcons"`
+	OpenFiles map[string]struct {
+		ServicePath string `json:"service_path"`
+		Filename    string `json:"filename"`
+		Opens       map[string]struct {
+			ServerID   smbServerID `json:"server_id"`
+			AccessMask struct {
+				ReadData   bool `json:"READ_DATA"`
+				WriteData  bool `json:"WRITE_DATA"`
+				AppendData bool `json:"APPEND_DATA"`
+			} `json:"access_mask"`
+		} `json:"opens"`
+	} `json:"open_files"`
+}
+
+type smbServerID struct {
+	PID string `json:"pid"`
+}
+
+// ParseSmbstatusJSON returns the share connections in smbstatus -j output.
+// sharePaths maps share names to paths and attributes open files to the
+// share they are in.
+func ParseSmbstatusJSON(data []byte, sharePaths map[string]string, now time.Time) ([]ShareSession, error) {
+	var out smbstatusOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse smbstatus output: %w", err)
+	}
+
+	sessions := make([]ShareSession, 0, len(out.Tcons))
+	byPID := make(map[string][]int)
+	for _, tcon := range out.Tcons {
+		if tcon.Service == "IPC$" {
+			continue
+		}
+		s := ShareSession{
+			Key:          fmt.Sprintf("smb:%s:%s", tcon.ServerID.PID, tcon.TconID),
+			ShareName:    tcon.Service,
+			Protocol:     models.ShareProtocolSMB,
+			ClientIP:     tcon.Machine,
+			ConnectedAt:  now,
+			FilesRead:    map[string]bool{},
+			FilesWritten: map[string]bool{},
+		}
+		if session, ok := out.Sessions[tcon.SessionID]; ok {
+			s.Username = session.Username
+			if s.ClientIP == "" {
+				s.ClientIP = session.RemoteMachine
+			}
+		}
+		if t, err := time.Parse(time.RFC3339Nano, tcon.ConnectedAt); err == nil {
+			s.ConnectedAt = t
+		}
+		byPID[tcon.ServerID.PID] = append(byPID[tcon.ServerID.PID], len(sessions))
+		sessions = append(sessions, s)
+	}
+
+	for _, file := range out.OpenFiles {
+		path := filepath.Join(file.ServicePath, file.Filename)
+		for _, open := range file.Opens {
+			for _, i := range byPID[open.ServerID.PID] {
+				s := &sessions[i]
+				// A client process can have several shares connected; pick the
+				// one the file is in when the share path is known
+				if p, ok := sharePaths[s.ShareName]; ok && filepath.Clean(p) != filepath.Clean(file.ServicePath) {
+					continue
+				}
+				if open.AccessMask.ReadData {
+					s.FilesRead[path] = true
+				}
+				if open.AccessMask.WriteData || open.AccessMask.AppendData {
+					s.FilesWritten[path] = true
+				}
+			}
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Key < sessions[j].Key })
+	return sessions, nil
+}
+
+// parseRmtab returns the NFS mounts in an rmtab file. Exports are mapped to
+// share names by path; mounts of unknown exports are skipped.
+func parseRmtab(data []byte, sharePaths map[string]string, now time.Time) []ShareSession {
+	byPath := make(map[string]string, len(sharePaths))
+	for name, path := range sharePaths {
+		byPath[filepath.Clean(path)] = name
+	}
+
+	var sessions []ShareSession
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		host, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		export := rest
+		if i := strings.LastIndex(rest, ":"); i >= 0 {
+			export = rest[:i]
+		}
+		share, ok := byPath[filepath.Clean(export)]
+		if !ok {
+			continue
+		}
+		sessions = append(sessions, ShareSession{
+			Key:          fmt.Sprintf("nfs:%s:%s", host, export),
+			ShareName:    share,
+			Protocol:     models.ShareProtocolNFS,
+			ClientIP:     host,
+			ConnectedAt:  now,
+			FilesRead:    map[string]bool{},
+			FilesWritten: map[string]bool{},
+		})
+	}
+	return sessions
+}
+
+// ShareAccessTracker polls Samba and NFS for client connections and
+// records each connection in the share access log
+type ShareAccessTracker struct {
+	db        *gorm.DB
+	interval  time.Duration
+	smbstatus func() ([]byte, error)
+	rmtab     func() ([]byte, error)
+
+	mu     sync.Mutex
+	open   map[string]*trackedSession
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+type trackedSession struct {
+	logID   uint
+	session ShareSession
+}
+
+var (
+	shareAccessTracker *ShareAccessTracker
+	shareAccessOnce    sync.Once
+)
+
+// StartShareAccessTracker starts the global share access tracker
+func StartShareAccessTracker() (*ShareAccessTracker, error) {
+	var initErr error
+	shareAccessOnce.Do(func() {
+		db := database.GetDB()
+		if db == nil {
+			initErr = fmt.Errorf("database not initialized")
+			return
+		}
+		shareAccessTracker = NewShareAccessTracker(db)
+		initErr = shareAccessTracker.Start()
+	})
+	return shareAccessTracker, initErr
+}
+
+// NewShareAccessTracker creates a tracker reading the live smbstatus and
+// rmtab
+func NewShareAccessTracker(db *gorm.DB) *ShareAccessTracker {
+	return &ShareAccessTracker{
+		db:        db,
+		interval:  shareAccessPollInterval,
+		smbstatus: runSmbstatus,
+		rmtab:     func() ([]byte, error) { return os.ReadFile(nfsRmtabPath) },
+		open:      make(map[string]*trackedSession),
+	}
+}
+
+func runSmbstatus() ([]byte, error) {
+	path, err := sysutil.RequireCommand("smbstatus")
+	if err != nil {
+		return nil, err
+	}
+	result, err := hostShell.Execute(path, "-j")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(result.Stdout), nil
+}
+
+// Start closes connections left open by a previous run and starts polling
+func (t *ShareAccessTracker) Start() error {
+	// Connections still open in the log were cut off by a restart; their
+	// end is unknown, so they end when they were last known to exist
+	now := time.Now()
+	err := t.db.Model(&models.ShareAccessLog{}).
+		Where("disconnected_at IS NULL").
+		Update("disconnected_at", now).Error
+	if err != nil {
+		return fmt.Errorf("failed to close stale share sessions: %w", err)
+	}
+
+	t.stopCh = make(chan struct{})
+	t.done = make(chan struct{})
+	go t.run()
+	return nil
+}
+
+// Stop stops polling. Open connections stay open in the log until the next
+// Start.
+func (t *ShareAccessTracker) Stop() {
+	if t.stopCh == nil {
+		return
+	}
+	close(t.stopCh)
+	<-t.done
+}
+
+func (t *ShareAccessTracker) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Poll(time.Now()); err != nil {
+			logger.Warn("Failed to poll share sessions", zap.Error(err))
+		}
+		select {
+		case <-t.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll compares the current connections with the previous poll and
+// records new, updated and closed connections
+func (t *ShareAccessTracker) Poll(now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var shares []models.Share
+	if err := t.db.Find(&shares).Error; err != nil {
+		return fmt.Errorf("failed to query shares: %w", err)
+	}
+	sharePaths := make(map[string]string, len(shares))
+	for _, s := range shares {
+		sharePaths[s.Name] = s.Path
+	}
+
+	var current []ShareSession
+	if data, err := t.smbstatus(); err == nil {
+		sessions, err := ParseSmbstatusJSON(data, sharePaths, now)
+		if err != nil {
+			return err
+		}
+		current = append(current, sessions...)
+	} else {
+		logger.Debug("smbstatus unavailable", zap.Error(err))
+	}
+	if data, err := t.rmtab(); err == nil {
+		current = append(current, parseRmtab(data, sharePaths, now)...)
+	}
+
+	seen := make(map[string]bool, len(current))
+	for _, s := range current {
+		seen[s.Key] = true
+		tracked, ok := t.open[s.Key]
+		if !ok {
+			entry := models.ShareAccessLog{
+				ShareName:    s.ShareName,
+				Protocol:     s.Protocol,
+				Username:     s.Username,
+				ClientIP:     s.ClientIP,
+				SessionKey:   s.Key,
+				ConnectedAt:  s.ConnectedAt,
+				FilesRead:    len(s.FilesRead),
+				FilesWritten: len(s.FilesWritten),
+			}
+			if err := t.db.Create(&entry).Error; err != nil {
+				return fmt.Errorf("failed to record share session: %w", err)
+			}
+			t.open[s.Key] = &trackedSession{logID: entry.ID, session: s}
+			continue
+		}
+
+		// Files are only seen while open, so counts accumulate across polls
+		read, written := len(tracked.session.FilesRead), len(tracked.session.FilesWritten)
+		for f := range s.FilesRead {
+			tracked.session.FilesRead[f] = true
+		}
+		for f := range s.FilesWritten {
+			tracked.session.FilesWritten[f] = true
+		}
+		if len(tracked.session.FilesRead) != read || len(tracked.session.FilesWritten) != written {
+			err := t.db.Model(&models.ShareAccessLog{}).Where("id = ?", tracked.logID).Updates(map[string]interface{}{
+				"files_read":    len(tracked.session.FilesRead),
+				"files_written": len(tracked.session.FilesWritten),
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to update share session: %w", err)
+			}
+		}
+	}
+
+	for key, tracked := range t.open {
+		if seen[key] {
+			continue
+		}
+		err := t.db.Model(&models.ShareAccessLog{}).Where("id = ?", tracked.logID).Update("disconnected_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to close share session: %w", err)
+		}
+		delete(t.open, key)
+	}
+
+	return nil
+}
+
+// ShareAccessLogPage is a page of a share's access log, newest first
+type ShareAccessLogPage struct {
+	Logs   []models.ShareAccessLog `json:"logs"`
+	Total  int64                   `json:"total"`
+	Limit  int                     `json:"limit"`
+	Offset int                     `json:"offset"`
+}
+
+// GetShareAccessLog returns a page of the connections to a share
+func GetShareAccessLog(id string, limit, offset int) (*ShareAccessLogPage, error) {
+	shareID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errors.BadRequest("Invalid share ID", err)
+	}
+
+	var share models.Share
+	if err := database.DB.First(&share, shareID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NotFound("Share not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to query share", err)
+	}
+
+	page := &ShareAccessLogPage{Logs: []models.ShareAccessLog{}, Limit: limit, Offset: offset}
+	query := database.DB.Model(&models.ShareAccessLog{}).Where("share_name = ?", share.Name)
+	if err := query.Count(&page.Total).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to query share access log", err)
+	}
+	if err := query.Order("connected_at DESC, id DESC").Limit(limit).Offset(offset).Find(&page.Logs).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to query share access log", err)
+	}
+	return page, nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Trimmed smbstatus -j output of two clients: alice with media and docs
+// connected from one process, bob with media
+const smbstatusTwoClients = `{
+  "timestamp": "2026-10-18T10:00:00.000000+0000",
+  "version": "4.19.5",
+  "sessions": {
+    "3041": {"session_id": "3041", "server_id": {"pid": "1201"}, "username": "alice", "remote_machine": "192.168.1.20"},
+    "3042": {"session_id": "3042", "server_id": {"pid": "1202"}, "username": "bob", "remote_machine": "192.168.1.21"}
+  },
+  "tcons": {
+    "101": {"service": "media", "server_id": {"pid": "1201"}, "tcon_id": "101", "session_id": "3041", "machine": "192.168.1.20", "connected_at": "2026-10-18T09:58:12.000000+00:00"},
+    "102": {"service": "docs", "server_id": {"pid": "1201"}, "tcon_id": "102", "session_id": "3041", "machine": "192.168.1.20", "connected_at": "2026-10-18T09:58:13.000000+00:00"},
+    "103": {"service": "IPC$", "server_id": {"pid": "1202"}, "tcon_id": "103", "session_id": "3042", "machine": "192.168.1.21", "connected_at": "2026-10-18T09:59:00.000000+00:00"},
+    "104": {"service": "media", "server_id": {"pid": "1202"}, "tcon_id": "104", "session_id": "3042", "machine": "192.168.1.21", "connected_at": "2026-10-18T09:59:01.000000+00:00"}
+  },
+  "open_files": {
+    "/srv/media/film.mkv": {
+      "service_path": "/srv/media", "filename": "film.mkv",
+      "opens": {"1201/1": {"server_id": {"pid": "1201"}, "access_mask": {"READ_DATA": true, "WRITE_DATA": false}}}
+    },
+    "/srv/docs/report.odt": {
+      "service_path": "/srv/docs", "filename": "report.odt",
+      "opens": {"1201/2": {"server_id": {"pid": "1201"}, "access_mask": {"READ_DATA": true, "WRITE_DATA": true}}}
+    }
+  }
+}`
+
+// bob has disconnected and alice has opened another file
+const smbstatusOneClient = `{
+  "sessions": {
+    "3041": {"session_id": "3041", "server_id": {"pid": "1201"}, "username": "alice", "remote_machine": "192.168.1.20"}
+  },
+  "tcons": {
+    "101": {"service": "media", "server_id": {"pid": "1201"}, "tcon_id": "101", "session_id": "3041", "machine": "192.168.1.20", "connected_at": "2026-10-18T09:58:12.000000+00:00"},
+    "102": {"service": "docs", "server_id": {"pid": "1201"}, "tcon_id": "102", "session_id": "3041", "machine": "192.168.1.20", "connected_at": "2026-10-18T09:58:13.000000+00:00"}
+  },
+  "open_files": {
+    "/srv/media/song.flac": {
+      "service_path": "/srv/media", "filename": "song.flac",
+      "opens": {"1201/3": {"server_id": {"pid": "1201"}, "access_mask": {"READ_DATA": true}}}
+    }
+  }
+}`
+
+var testSharePaths = map[string]string{"media": "/srv/media", "docs": "/srv/docs"}
+
+func TestParseSmbstatusJSON(t *testing.T) {
+	sessions, err := ParseSmbstatusJSON([]byte(smbstatusTwoClients), testSharePaths, time.Now())
+	if err != nil {
+		t.Fatalf("ParseSmbstatusJSON: %v", err)
+	}
+
+	tests := []struct {
+		key      string
+		share    string
+		username string
+		clientIP string
+		read     int
+		written  int
+	}{
+		{"smb:1201:101", "media", "alice", "192.168.1.20", 1, 0},
+		{"smb:1201:102", "docs", "alice", "192.168.1.20", 1, 1},
+		{"smb:1202:104", "media", "bob", "192.168.1.21", 0, 0},
+	}
+	if len(sessions) != len(tests) {
+		t.Fatalf("got %d sessions, want %d: %+v", len(sessions), len(tests), sessions)
+	}
+	for i, tt := range tests {
+		s := sessions[i]
+		if s.Key != tt.key || s.ShareName != tt.share || s.Username != tt.username || s.ClientIP != tt.clientIP {
+			t.Errorf("session %d = %+v, want %+v", i, s, tt)
+		}
+		if len(s.FilesRead) != tt.read || len(s.FilesWritten) != tt.written {
+			t.Errorf("session %s read %d written %d files, want %d and %d", s.Key, len(s.FilesRead), len(s.FilesWritten), tt.read, tt.written)
+		}
+		if s.Protocol != models.ShareProtocolSMB {
+			t.Errorf("session %s protocol = %q", s.Key, s.Protocol)
+		}
+	}
+	if want := time.Date(2026, 10, 18, 9, 58, 12, 0, time.UTC); !sessions[0].ConnectedAt.Equal(want) {
+		t.Errorf("connected at %v, want %v", sessions[0].ConnectedAt, want)
+	}
+
+	if _, err := ParseSmbstatusJSON([]byte("not json"), nil, time.Now()); err == nil {
+		t.Error("expected an error for invalid output")
+	}
+}
+
+func TestParseRmtab(t *testing.T) {
+	rmtab := "192.168.1.30:/srv/media:0x00000001\n192.168.1.31:/srv/other:0x00000001\n"
+	sessions := parseRmtab([]byte(rmtab), testSharePaths, time.Now())
+	if len(sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1: %+v", len(sessions), sessions)
+	}
+	if s := sessions[0]; s.ShareName != "media" || s.ClientIP != "192.168.1.30" || s.Protocol != models.ShareProtocolNFS {
+		t.Errorf("session = %+v", s)
+	}
+}
+
+func TestShareAccessTrackerPoll(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "storage.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Share{}, &models.ShareAccessLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for name, path := range testSharePaths {
+		db.Create(&models.Share{Name: name, Path: path, Type: "smb"})
+	}
+
+	output := smbstatusTwoClients
+	tracker := NewShareAccessTracker(db)
+	tracker.smbstatus = func() ([]byte, error) { return []byte(output), nil }
+	tracker.rmtab = func() ([]byte, error) { return nil, nil }
+
+	first := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	if err := tracker.Poll(first); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	// An unchanged poll must not create duplicate records
+	if err := tracker.Poll(first.Add(30 * time.Second)); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	var count int64
+	db.Model(&models.ShareAccessLog{}).Count(&count)
+	if count != 3 {
+		t.Fatalf("got %d records after connecting, want 3", count)
+	}
+
+	output = smbstatusOneClient
+	second := first.Add(time.Minute)
+	if err := tracker.Poll(second); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+
+	var logs []models.ShareAccessLog
+	db.Order("session_key").Find(&logs)
+	if len(logs) != 3 {
+		t.Fatalf("got %d records, want 3", len(logs))
+	}
+	tests := []struct {
+		key          string
+		disconnected bool
+		read         int
+	}{
+		{"smb:1201:101", false, 2},
+		{"smb:1201:102", false, 1},
+		{"smb:1202:104", true, 0},
+	}
+	for i, tt := range tests {
+		l := logs[i]
+		if l.SessionKey != tt.key {
+			t.Fatalf("record %d key = %q, want %q", i, l.SessionKey, tt.key)
+		}
+		if (l.DisconnectedAt != nil) != tt.disconnected {
+			t.Errorf("record %s disconnectedAt = %v, want disconnected %v", l.SessionKey, l.DisconnectedAt, tt.disconnected)
+		}
+		if tt.disconnected && !l.DisconnectedAt.Equal(second) {
+			t.Errorf("record %s disconnected at %v, want %v", l.SessionKey, l.DisconnectedAt, second)
+		}
+		if l.FilesRead != tt.read {
+			t.Errorf("record %s filesRead = %d, want %d", l.SessionKey, l.FilesRead, tt.read)
+		}
+	}
+}
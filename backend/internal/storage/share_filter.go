@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+)
+
+var (
+	// ErrFileTypeBlocked is returned for files the file filter of a share does not allow
+	ErrFileTypeBlocked = errors.New("file type not allowed on this share")
+	// ErrFileTooLarge is returned for files larger than the limit of a share
+	ErrFileTooLarge = errors.New("file exceeds the size limit of this share")
+)
+
+// normalizeFileFilter validates a file filter and returns it with its
+// extensions in lower case and without leading dots
+func normalizeFileFilter(filter ShareFileFilter) (ShareFileFilter, error) {
+	deny, err := normalizeExtensions(filter.DenyExtensions)
+	if err != nil {
+		return filter, err
+	}
+	allow, err := normalizeExtensions(filter.AllowExtensions)
+	if err != nil {
+		return filter, err
+	}
+
+	var patterns []string
+	for _, pattern := range filter.DenyPatterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.Contains(pattern, "/") {
+			return filter, fmt.Errorf("file filter pattern %q must not contain '/'", pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return filter, fmt.Errorf("file filter pattern %q is invalid: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	if filter.MaxFileSizeMB < 0 {
+		return filter, fmt.Errorf("file filter maxFileSizeMB must not be negative (got %d)", filter.MaxFileSizeMB)
+	}
+
+	return ShareFileFilter{
+		DenyExtensions:  deny,
+		AllowExtensions: allow,
+		DenyPatterns:    patterns,
+		MaxFileSizeMB:   filter.MaxFileSizeMB,
+	}, nil
+}
+
+// normalizeExtensions turns ".EXE" and "*.exe" into "exe" and drops duplicates
+func normalizeExtensions(extensions []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		ext = strings.TrimPrefix(strings.TrimPrefix(ext, "*"), ".")
+		if ext == "" {
+			continue
+		}
+		if strings.ContainsAny(ext, "/*?[] \t") {
+			return nil, fmt.Errorf("file filter extension %q is invalid", ext)
+		}
+		if !seen[ext] {
+			seen[ext] = true
+			normalized = append(normalized, ext)
+		}
+	}
+	return normalized, nil
+}
+
+// sambaVetoFiles returns the veto files value hiding the denied extensions
+// and patterns of a filter, e.g. /*.exe/*.bat/, or "" if nothing is denied
+func sambaVetoFiles(filter ShareFileFilter) string {
+	var entries []string
+	for _, ext := range filter.DenyExtensions {
+		entries = append(entries, "*."+ext)
+	}
+	entries = append(entries, filter.DenyPatterns...)
+	if len(entries) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(entries, "/") + "/"
+}
+
+// checkFileFilter checks a file name and size against a file filter
+func checkFileFilter(filter ShareFileFilter, filename string, size int64) error {
+	name := strings.ToLower(filepath.Base(filename))
+
+	for _, ext := range filter.DenyExtensions {
+		if strings.HasSuffix(name, "."+ext) {
+			return fmt.Errorf("%w: .%s files are blocked", ErrFileTypeBlocked, ext)
+		}
+	}
+	for _, pattern := range filter.DenyPatterns {
+		if matched, _ := filepath.Match(strings.ToLower(pattern), name); matched {
+			return fmt.Errorf("%w: %s matches blocked pattern %q", ErrFileTypeBlocked, filename, pattern)
+		}
+	}
+	if len(filter.AllowExtensions) > 0 {
+		allowed := false
+		for _, ext := range filter.AllowExtensions {
+			if strings.HasSuffix(name, "."+ext) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: only .%s files are allowed", ErrFileTypeBlocked, strings.Join(filter.AllowExtensions, ", ."))
+		}
+	}
+	if filter.MaxFileSizeMB > 0 && size > filter.MaxFileSizeMB<<20 {
+		return fmt.Errorf("%w: %s is larger than %d MB", ErrFileTooLarge, filename, filter.MaxFileSizeMB)
+	}
+	return nil
+}
+
+// ValidateFileAccess checks whether a file may be stored on a share under
+// its file filter. Samba hides denied files itself; NFS has no server-side
+// filtering, so the filter is only enforced for writes through the API.
+func ValidateFileAccess(shareName, filename string, size int64) error {
+	shares, err := database.Cached().ListShares()
+	if err != nil {
+		return fmt.Errorf("failed to load shares: %w", err)
+	}
+	for _, share := range shares {
+		if share.Name == shareName {
+			return checkFileFilter(share.FileFilter, filename, size)
+		}
+	}
+	return fmt.Errorf("share not found: %s", shareName)
+}
+
+// ShareNameForPath returns the name of the share containing path, the
+// innermost one if shares are nested
+func ShareNameForPath(path string) (string, bool) {
+	shares, err := database.Cached().ListShares()
+	if err != nil {
+		return "", false
+	}
+
+	path = filepath.Clean(path)
+	name, longest := "", -1
+	for _, share := range shares {
+		root := filepath.Clean(share.Path)
+		if path != root && !strings.HasPrefix(path, strings.TrimSuffix(root, "/")+"/") {
+			continue
+		}
+		if len(root) > longest {
+			name, longest = share.Name, len(root)
+		}
+	}
+	return name, longest >= 0
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+)
+
+func TestBuildSambaShareConfigVetoFiles(t *testing.T) {
+	filter, err := normalizeFileFilter(ShareFileFilter{
+		DenyExtensions: []string{".EXE", "*.bat", "exe"},
+		DenyPatterns:   []string{"desktop.ini"},
+	})
+	if err != nil {
+		t.Fatalf("normalizeFileFilter() error = %v", err)
+	}
+
+	config := buildSambaShareConfig(&models.Share{
+		Name:       "media",
+		Path:       "/srv/media",
+		Browseable: true,
+		FileFilter: filter,
+	})
+	if !strings.Contains(config, "\n   veto files = /*.exe/*.bat/desktop.ini/") {
+		t.Errorf("share config has no veto files entry for the blocked extensions:\n%s", config)
+	}
+
+	config = buildSambaShareConfig(&models.Share{Name: "docs", Path: "/srv/docs"})
+	if strings.Contains(config, "veto files") {
+		t.Errorf("share without file filter has a veto files entry:\n%s", config)
+	}
+}
+
+func TestCheckFileFilter(t *testing.T) {
+	filter, err := normalizeFileFilter(ShareFileFilter{
+		DenyExtensions:  []string{"exe"},
+		AllowExtensions: []string{"mkv", "MP4", "exe"},
+		DenyPatterns:    []string{"~$*"},
+		MaxFileSizeMB:   10,
+	})
+	if err != nil {
+		t.Fatalf("normalizeFileFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		filename string
+		size     int64
+		want     error
+	}{
+		{"film.mkv", 1 << 20, nil},
+		{"Clip.MP4", 10 << 20, nil},
+		{"setup.EXE", 1, ErrFileTypeBlocked},
+		{"notes.txt", 1, ErrFileTypeBlocked},
+		{"~$draft.mkv", 1, ErrFileTypeBlocked},
+		{"film.mkv", 10<<20 + 1, ErrFileTooLarge},
+	}
+	for _, tt := range tests {
+		err := checkFileFilter(filter, tt.filename, tt.size)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("checkFileFilter(%q, %d) = %v, want %v", tt.filename, tt.size, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeFileFilterRejectsInvalidEntries(t *testing.T) {
+	for _, filter := range []ShareFileFilter{
+		{DenyExtensions: []string{"a/b"}},
+		{DenyPatterns: []string{"[bad"}},
+		{DenyPatterns: []string{"dir/file"}},
+		{MaxFileSizeMB: -1},
+	} {
+		if _, err := normalizeFileFilter(filter); err == nil {
+			t.Errorf("normalizeFileFilter(%+v) succeeded, want an error", filter)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TransferProgress is the state of a share transfer
+type TransferProgress = models.ShareTransfer
+
+// ErrTransferRunning is returned when a share is already being transferred
+var ErrTransferRunning = errors.New("share is already being transferred")
+
+// rsyncProgress matches an rsync --info=progress2 line, e.g.
+// "  1,234,567  45%   12.34MB/s    0:00:10 (xfr#3, to-chk=10/20)"
+var rsyncProgress = regexp.MustCompile(`^([\d,]+)\s+(\d{1,3})%\s+(\S+/s)`)
+
+// Replaced in tests
+var (
+	runRsync         = sysutil.RunCommandWithStdoutProgress
+	lookupVolume     = GetVolume
+	applyShareConfig = reconfigureShare
+)
+
+// ShareTransferJob moves a share's data to another volume while the share
+// stays accessible. The data is copied while the share is in use, then
+// synced once more with the share briefly read-only before the share is
+// switched to the new location.
+type ShareTransferJob struct {
+	ShareID             string `json:"shareId"`
+	DestinationVolumeID string `json:"destinationVolumeId"`
+	PreservePermissions bool   `json:"preservePermissions"` // Keep owners, permissions, ACLs and xattrs
+	DeleteAfterTransfer bool   `json:"deleteAfterTransfer"` // Remove the data from the old volume afterwards
+}
+
+// Start checks the job and runs the transfer in the background. It returns
+// the ID to query the transfer's progress with.
+func (j ShareTransferJob) Start() (string, error) {
+	db := database.GetDB()
+	if db == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+
+	var share models.Share
+	if err := db.First(&share, "id = ?", j.ShareID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrShareNotFound
+		}
+		return "", err
+	}
+
+	volume, err := lookupVolume(j.DestinationVolumeID)
+	if err != nil {
+		return "", err
+	}
+	if volume.MountPoint == "" {
+		return "", fmt.Errorf("volume %s is not mounted", volume.ID)
+	}
+	if share.VolumeID == volume.ID {
+		return "", fmt.Errorf("share %s is already on volume %s", share.Name, volume.ID)
+	}
+
+	name := sysutil.SanitizeFilename(share.Name)
+	if name == "" {
+		return "", fmt.Errorf("share name %q cannot be used as a directory name", share.Name)
+	}
+	src := filepath.Clean(share.Path)
+	dst := filepath.Join(volume.MountPoint, name)
+	if isWithin(dst, src) || isWithin(src, dst) {
+		return "", fmt.Errorf("destination %s overlaps the share path %s", dst, src)
+	}
+	if entries, err := os.ReadDir(dst); err == nil && len(entries) > 0 {
+		return "", fmt.Errorf("destination %s is not empty", dst)
+	}
+
+	var running int64
+	if err := db.Model(&models.ShareTransfer{}).
+		Where("share_id = ? AND status IN ?", j.ShareID, []string{models.ShareTransferStatusCopying, models.ShareTransferStatusFinalSync}).
+		Count(&running).Error; err != nil {
+		return "", fmt.Errorf("failed to check running transfers: %w", err)
+	}
+	if running > 0 {
+		return "", ErrTransferRunning
+	}
+
+	id, err := generateTransferID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	transfer := &models.ShareTransfer{
+		ID:                  id,
+		ShareID:             j.ShareID,
+		DestinationVolumeID: volume.ID,
+		SourcePath:          src,
+		DestinationPath:     dst,
+		PreservePermissions: j.PreservePermissions,
+		DeleteAfterTransfer: j.DeleteAfterTransfer,
+		Status:              models.ShareTransferStatusCopying,
+	}
+	if err := db.Create(transfer).Error; err != nil {
+		return "", fmt.Errorf("failed to create share transfer: %w", err)
+	}
+
+	go func() {
+		err := j.transfer(db, transfer)
+
+		now := time.Now()
+		transfer.CompletedAt = &now
+		if err != nil {
+			transfer.Status = models.ShareTransferStatusFailed
+			transfer.Error = err.Error()
+			logger.Error("Share transfer failed", zap.String("job", id), zap.String("share", share.Name), zap.Error(err))
+		} else {
+			transfer.Status = models.ShareTransferStatusCompleted
+			transfer.Progress = 100
+			logger.Info("Share transfer completed", zap.String("job", id), zap.String("share", share.Name),
+				zap.String("path", transfer.DestinationPath))
+		}
+		if err := db.Select("status", "progress", "error", "completed_at").Updates(transfer).Error; err != nil {
+			logger.Error("Failed to update share transfer", zap.String("job", id), zap.Error(err))
+		}
+	}()
+
+	return id, nil
+}
+
+// GetTransferProgress returns the state of a share transfer
+func GetTransferProgress(jobID string) (*TransferProgress, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var transfer models.ShareTransfer
+	if err := db.First(&transfer, "id = ?", jobID).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// transfer copies the data, syncs it again with the share read-only and
+// points the share to the new location
+func (j ShareTransferJob) transfer(db *gorm.DB, t *models.ShareTransfer) error {
+	if err := os.MkdirAll(t.DestinationPath, 0755); err != nil {
+		return fmt.Errorf("failed to create destination: %w", err)
+	}
+	if err := syncShareData(db, t, false); err != nil {
+		return err
+	}
+
+	var share models.Share
+	if err := db.First(&share, "id = ?", t.ShareID).Error; err != nil {
+		return fmt.Errorf("failed to load share: %w", err)
+	}
+	previous := share
+
+	// No writes may land on the old volume after the final sync
+	share.ReadOnly = true
+	if err := saveShare(db, &share, previous.Path); err != nil {
+		return fmt.Errorf("failed to make share read-only: %w", err)
+	}
+	t.Status = models.ShareTransferStatusFinalSync
+	db.Model(t).Update("status", t.Status)
+
+	if err := syncShareData(db, t, true); err != nil {
+		if restoreErr := saveShare(db, &previous, previous.Path); restoreErr != nil {
+			logger.Error("Failed to restore share after failed transfer", zap.String("share", share.Name), zap.Error(restoreErr))
+		}
+		return err
+	}
+
+	share.Path = t.DestinationPath
+	share.VolumeID = t.DestinationVolumeID
+	share.ReadOnly = previous.ReadOnly
+	if err := saveShare(db, &share, previous.Path); err != nil {
+		return fmt.Errorf("failed to move share to %s: %w", t.DestinationPath, err)
+	}
+
+	if t.DeleteAfterTransfer {
+		if err := os.RemoveAll(t.SourcePath); err != nil {
+			logger.Warn("Failed to delete share data from old volume", zap.String("path", t.SourcePath), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// syncShareData runs rsync from the share's old path to its new one,
+// recording the progress it reports. The final sync also deletes files
+// removed from the share since the first pass.
+func syncShareData(db *gorm.DB, t *models.ShareTransfer, final bool) error {
+	args := []string{"--archive", "--checksum", "--info=progress2", "--no-inc-recursive"}
+	if t.PreservePermissions {
+		args = append(args, "--acls", "--xattrs")
+	} else {
+		args = append(args, "--no-perms", "--no-owner", "--no-group")
+	}
+	if final {
+		args = append(args, "--delete")
+	}
+	args = append(args, t.SourcePath+"/", t.DestinationPath+"/")
+
+	lastProgress := -1
+	_, err := runRsync(context.Background(), func(line string) {
+		m := rsyncProgress.FindStringSubmatch(line)
+		if m == nil {
+			return
+		}
+		percent, _ := strconv.Atoi(m[2])
+		bytes, _ := strconv.ParseInt(strings.ReplaceAll(m[1], ",", ""), 10, 64)
+		t.BytesTransferred = bytes
+		t.Rate = m[3]
+		if percent != lastProgress {
+			lastProgress = percent
+			t.Progress = percent
+			db.Model(t).Select("progress", "bytes_transferred", "rate").Updates(t)
+		}
+	}, "rsync", args...)
+	if err != nil {
+		return err
+	}
+	db.Model(t).Select("progress", "bytes_transferred", "rate").Updates(t)
+	return nil
+}
+
+// saveShare stores a share and reapplies its Samba or NFS configuration
+func saveShare(db *gorm.DB, share *models.Share, previousPath string) error {
+	defer database.Cached().InvalidateShares()
+
+	if err := db.Save(share).Error; err != nil {
+		return err
+	}
+	return applyShareConfig(share, previousPath)
+}
+
+// reconfigureShare rewrites a share's configuration and reloads Samba or
+// the NFS exports. NFS exports are keyed by path, so the export of the
+// previous path is removed first.
+func reconfigureShare(share *models.Share, previousPath string) error {
+	switch ShareType(share.Type) {
+	case ShareTypeSMB:
+		return configureSMBShare(share)
+	case ShareTypeNFS:
+		old := *share
+		old.Path = previousPath
+		if err := removeNFSShare(&old); err != nil {
+			return err
+		}
+		return configureNFSShare(share)
+	}
+	return nil
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// generateTransferID returns a random job ID
+func generateTransferID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestShareTransferJob(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "transfer.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Share{}, &models.ShareTransfer{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	database.DB = db
+
+	share := models.Share{Name: "media", Path: t.TempDir(), VolumeID: "vol1", Type: string(ShareTypeSMB)}
+	if err := db.Create(&share).Error; err != nil {
+		t.Fatal(err)
+	}
+	mountPoint := t.TempDir()
+
+	var rsyncCalls int
+	var readOnlyDuringFinalSync bool
+	var reconfigured []string
+	origRsync, origLookup, origApply := runRsync, lookupVolume, applyShareConfig
+	t.Cleanup(func() {
+		runRsync, lookupVolume, applyShareConfig = origRsync, origLookup, origApply
+		database.DB = nil
+	})
+	lookupVolume = func(id string) (*Volume, error) {
+		return &Volume{ID: id, MountPoint: mountPoint}, nil
+	}
+	applyShareConfig = func(s *models.Share, previousPath string) error {
+		reconfigured = append(reconfigured, s.Path)
+		return nil
+	}
+	runRsync = func(ctx context.Context, onProgress func(string), name string, args ...string) (string, error) {
+		rsyncCalls++
+		if slices.Contains(args, "--delete") {
+			var current models.Share
+			db.First(&current, share.ID)
+			readOnlyDuringFinalSync = current.ReadOnly
+		}
+		onProgress("sending incremental file list")
+		onProgress("1,048,576  50%   10.00MB/s    0:00:01 (xfr#1, to-chk=1/2)")
+		return "", nil
+	}
+
+	id, err := ShareTransferJob{ShareID: strconv.Itoa(int(share.ID)), DestinationVolumeID: "vol2"}.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	var progress *TransferProgress
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if progress, err = GetTransferProgress(id); err != nil {
+			t.Fatalf("GetTransferProgress() error = %v", err)
+		}
+		if progress.CompletedAt != nil {
+			break
+		}
+	}
+	if progress.Status != models.ShareTransferStatusCompleted {
+		t.Fatalf("status = %q (%s), want completed", progress.Status, progress.Error)
+	}
+	if progress.BytesTransferred != 1048576 || progress.Rate != "10.00MB/s" {
+		t.Errorf("bytes = %d, rate = %q", progress.BytesTransferred, progress.Rate)
+	}
+	if rsyncCalls != 2 || !readOnlyDuringFinalSync {
+		t.Errorf("rsync calls = %d, read-only during final sync = %v", rsyncCalls, readOnlyDuringFinalSync)
+	}
+
+	dst := filepath.Join(mountPoint, "media")
+	var moved models.Share
+	db.First(&moved, share.ID)
+	if moved.Path != dst || moved.VolumeID != "vol2" || moved.ReadOnly {
+		t.Errorf("share after transfer = path %q, volume %q, read-only %v", moved.Path, moved.VolumeID, moved.ReadOnly)
+	}
+	if len(reconfigured) != 2 || reconfigured[1] != dst {
+		t.Errorf("share reconfigured with paths %v", reconfigured)
+	}
+
+	// The share is on vol2 now
+	if _, err := (ShareTransferJob{ShareID: strconv.Itoa(int(share.ID)), DestinationVolumeID: "vol2"}).Start(); err == nil {
+		t.Error("Start() to the share's own volume succeeded")
+	}
+}
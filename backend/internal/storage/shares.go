@@ -2,68 +2,35 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/tracing"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/usergroups"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/users"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // findSmbdPath searches for smbd binary in common locations
 func findSmbdPath() (string, error) {
-	// Try exec.LookPath first (checks PATH)
-	if path, err := exec.LookPath("smbd"); err == nil {
-		return path, nil
-	}
-
-	// Check common installation paths
-	commonPaths := []string{
-		"/usr/sbin/smbd",
-		"/usr/bin/smbd",
-		"/sbin/smbd",
-		"/usr/local/sbin/smbd",
-	}
-
-	for _, path := range commonPaths {
-		if _, err := os.Stat(path); err == nil {
-			return path, nil
-		}
-	}
-
-	return "", fmt.Errorf("smbd not found in PATH or common locations")
+	return sysutil.RequireCommand("smbd")
 }
 
 // findExportfsPath searches for exportfs binary in common locations
 func findExportfsPath() (string, error) {
-	// Try exec.LookPath first (checks PATH)
-	if path, err := exec.LookPath("exportfs"); err == nil {
-		return path, nil
-	}
-
-	// Check common installation paths
-	commonPaths := []string{
-		"/usr/sbin/exportfs",
-		"/usr/bin/exportfs",
-		"/sbin/exportfs",
-	}
-
-	for _, path := range commonPaths {
-		if _, err := os.Stat(path); err == nil {
-			return path, nil
-		}
-	}
-
-	return "", fmt.Errorf("exportfs not found in PATH or common locations")
+	return sysutil.RequireCommand("exportfs")
 }
 
 // toShare converts models.Share to Share
@@ -91,6 +58,7 @@ func toShare(s *models.Share) *Share {
 		GuestOK:     s.GuestOK,
 		ValidUsers:  validUsers,
 		ValidGroups: validGroups,
+		FileFilter:  s.FileFilter,
 		CreatedAt:   s.CreatedAt,
 		UpdatedAt:   s.UpdatedAt,
 	}
@@ -98,8 +66,8 @@ func toShare(s *models.Share) *Share {
 
 // ListShares lists all network shares
 func ListShares() ([]Share, error) {
-	var models []models.Share
-	if err := database.DB.Find(&models).Error; err != nil {
+	models, err := database.Cached().ListShares()
+	if err != nil {
 		return nil, err
 	}
 
@@ -113,19 +81,27 @@ func ListShares() ([]Share, error) {
 
 // GetShare retrieves a specific share by ID
 func GetShare(id string) (*Share, error) {
-	var model models.Share
-	if err := database.DB.First(&model, id).Error; err != nil {
+	model, err := database.Cached().GetShare(id)
+	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("share not found")
 		}
 		return nil, err
 	}
 
-	return toShare(&model), nil
+	return toShare(model), nil
 }
 
 // CreateShare creates a new network share
-func CreateShare(req *CreateShareRequest) (*Share, error) {
+func CreateShare(ctx context.Context, req *CreateShareRequest) (share *Share, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "storage.CreateShare", trace.WithAttributes(
+		attribute.String("share.name", req.Name),
+		attribute.String("share.type", string(req.Type)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	defer database.Cached().InvalidateShares()
+
 	logger.Info("Creating share",
 		zap.String("name", req.Name),
 		zap.String("type", string(req.Type)),
@@ -137,6 +113,11 @@ func CreateShare(req *CreateShareRequest) (*Share, error) {
 		return nil, fmt.Errorf("either volumeId or path must be provided")
 	}
 
+	fileFilter, err := normalizeFileFilter(req.FileFilter)
+	if err != nil {
+		return nil, err
+	}
+
 	// Resolve the actual path
 	sharePath := req.Path
 	volumeID := req.VolumeID
@@ -177,7 +158,7 @@ func CreateShare(req *CreateShareRequest) (*Share, error) {
 		if groupname == "" {
 			continue // Skip empty group names
 		}
-		if _, err := user.LookupGroup(groupname); err != nil {
+		if _, err := database.Cached().LookupGID(groupname); err != nil {
 			return nil, fmt.Errorf("group '%s' does not exist - cannot add to valid groups list", groupname)
 		}
 	}
@@ -195,6 +176,7 @@ func CreateShare(req *CreateShareRequest) (*Share, error) {
 		GuestOK:     req.GuestOK,
 		ValidUsers:  strings.Join(req.ValidUsers, ","),
 		ValidGroups: strings.Join(req.ValidGroups, ","),
+		FileFilter:  fileFilter,
 	}
 
 	// Check if share with this name already exists
@@ -213,19 +195,8 @@ func CreateShare(req *CreateShareRequest) (*Share, error) {
 	}
 
 	// Configure the share based on type
-	switch req.Type {
-	case ShareTypeSMB:
-		if err := configureSMBShare(model); err != nil {
-			database.DB.Delete(model)
-			return nil, fmt.Errorf("failed to configure SMB share: %w", err)
-		}
-	case ShareTypeNFS:
-		if err := configureNFSShare(model); err != nil {
-			database.DB.Delete(model)
-			return nil, fmt.Errorf("failed to configure NFS share: %w", err)
-		}
-	default:
-		return nil, fmt.Errorf("unsupported share type: %s", req.Type)
+	if err := configureShare(ctx, req.Type, model); err != nil {
+		return nil, err
 	}
 
 	logger.Info("Share created successfully", zap.String("name", req.Name))
@@ -235,11 +206,18 @@ func CreateShare(req *CreateShareRequest) (*Share, error) {
 
 // UpdateShare updates an existing share
 func UpdateShare(id string, req *CreateShareRequest) (*Share, error) {
+	defer database.Cached().InvalidateShares()
+
 	var model models.Share
 	if err := database.DB.First(&model, id).Error; err != nil {
 		return nil, err
 	}
 
+	fileFilter, err := normalizeFileFilter(req.FileFilter)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate that all users in ValidUsers exist
 	for _, username := range req.ValidUsers {
 		if username == "" {
@@ -255,7 +233,7 @@ func UpdateShare(id string, req *CreateShareRequest) (*Share, error) {
 		if groupname == "" {
 			continue // Skip empty group names
 		}
-		if _, err := user.LookupGroup(groupname); err != nil {
+		if _, err := database.Cached().LookupGID(groupname); err != nil {
 			return nil, fmt.Errorf("group '%s' does not exist - cannot add to valid groups list", groupname)
 		}
 	}
@@ -269,6 +247,7 @@ func UpdateShare(id string, req *CreateShareRequest) (*Share, error) {
 	model.GuestOK = req.GuestOK
 	model.ValidUsers = strings.Join(req.ValidUsers, ",")
 	model.ValidGroups = strings.Join(req.ValidGroups, ",")
+	model.FileFilter = fileFilter
 
 	if err := database.DB.Save(&model).Error; err != nil {
 		return nil, err
@@ -291,6 +270,8 @@ func UpdateShare(id string, req *CreateShareRequest) (*Share, error) {
 
 // DeleteShare deletes a network share
 func DeleteShare(id string) error {
+	defer database.Cached().InvalidateShares()
+
 	var model models.Share
 	if err := database.DB.First(&model, id).Error; err != nil {
 		return err
@@ -318,6 +299,29 @@ func DeleteShare(id string) error {
 	return nil
 }
 
+// configureShare configures the service exporting a new share. The share's
+// record is removed again if that fails.
+func configureShare(ctx context.Context, shareType ShareType, model *models.Share) (err error) {
+	_, span := tracing.Tracer().Start(ctx, "storage.configureShare")
+	defer func() { tracing.End(span, err) }()
+
+	switch shareType {
+	case ShareTypeSMB:
+		if err := configureSMBShare(model); err != nil {
+			database.DB.Delete(model)
+			return fmt.Errorf("failed to configure SMB share: %w", err)
+		}
+	case ShareTypeNFS:
+		if err := configureNFSShare(model); err != nil {
+			database.DB.Delete(model)
+			return fmt.Errorf("failed to configure NFS share: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported share type: %s", shareType)
+	}
+	return nil
+}
+
 // configureSMBShare configures a Samba share by writing it directly to smb.conf
 func configureSMBShare(share *models.Share) error {
 	// Check if Samba is installed
@@ -390,6 +394,7 @@ func buildSambaShareConfig(share *models.Share) string {
 			group = strings.TrimSpace(group)
 			if group != "" {
 				validEntries = append(validEntries, "@"+group)
+				validEntries = append(validEntries, nestedGroupMembers(group)...)
 			}
 		}
 	}
@@ -399,9 +404,47 @@ func buildSambaShareConfig(share *models.Share) string {
 		config += fmt.Sprintf("\n   valid users = %s", strings.Join(validEntries, " "))
 	}
 
+	// Hide and refuse files blocked by the share's file filter
+	if veto := sambaVetoFiles(share.FileFilter); veto != "" {
+		config += fmt.Sprintf("\n   veto files = %s", veto)
+	}
+
 	return config
 }
 
+// nestedGroupMembers returns the users that belong to a NAS group only
+// through its subgroups. Samba's @group only covers the Unix group, which
+// has the direct members, so inherited members are listed by name.
+func nestedGroupMembers(groupName string) []string {
+	group, err := usergroups.GetGroupByName(groupName)
+	if err != nil {
+		return nil // Not a NAS group
+	}
+
+	ids, err := usergroups.GetGroupHierarchy().GetTransitiveMemberIDs(strconv.FormatUint(uint64(group.ID), 10))
+	if err != nil {
+		logger.Warn("Failed to resolve nested group members", zap.String("group", groupName), zap.Error(err))
+		return nil
+	}
+
+	direct := make(map[uint]bool, len(group.Members))
+	for _, m := range group.Members {
+		direct[m.ID] = true
+	}
+
+	var names []string
+	for _, idStr := range ids {
+		id, _ := strconv.ParseUint(idStr, 10, 32)
+		if direct[uint(id)] {
+			continue
+		}
+		if user, err := users.GetUserByID(uint(id)); err == nil {
+			names = append(names, user.Username)
+		}
+	}
+	return names
+}
+
 // addShareToSmbConf adds or updates a share in smb.conf
 func addShareToSmbConf(shareName, shareConfig string) error {
 	smbConfPath := "/etc/samba/smb.conf"
@@ -434,7 +477,7 @@ func addShareToSmbConf(shareName, shareConfig string) error {
 
 	// Write back to smb.conf
 	newContent := strings.Join(lines, "\n")
-	if err := os.WriteFile(smbConfPath, []byte(newContent), 0644); err != nil {
+	if err := sysutil.AtomicWriteFile(smbConfPath, []byte(newContent), 0644); err != nil {
 		return fmt.Errorf("failed to write smb.conf: %w", err)
 	}
 
@@ -506,7 +549,7 @@ func removeSMBShare(share *models.Share) error {
 
 	// Write back to smb.conf
 	newContent := strings.Join(newLines, "\n")
-	if err := os.WriteFile(smbConfPath, []byte(newContent), 0644); err != nil {
+	if err := sysutil.AtomicWriteFile(smbConfPath, []byte(newContent), 0644); err != nil {
 		return fmt.Errorf("failed to write smb.conf: %w", err)
 	}
 
@@ -521,24 +564,21 @@ func removeSMBShare(share *models.Share) error {
 // reloadSamba reloads the Samba service to apply configuration changes
 func reloadSamba() {
 	// Try systemctl first
-	cmd := exec.Command("systemctl", "reload", "smbd")
-	if output, err := cmd.CombinedOutput(); err != nil {
+	if output, err := runCommand("systemctl", "reload", "smbd"); err != nil {
 		logger.Warn("Failed to reload smbd via systemctl",
-			zap.String("output", string(output)),
+			zap.String("output", output),
 			zap.Error(err))
 		// Try service command as fallback
-		cmd = exec.Command("service", "smbd", "reload")
-		if output, err := cmd.CombinedOutput(); err != nil {
+		if output, err := runCommand("service", "smbd", "reload"); err != nil {
 			logger.Warn("Failed to reload smbd via service",
-				zap.String("output", string(output)),
+				zap.String("output", output),
 				zap.Error(err))
 		}
 	}
 
 	// Also reload nmbd
-	cmd = exec.Command("systemctl", "reload", "nmbd")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		logger.Debug("Failed to reload nmbd", zap.String("output", string(output)))
+	if output, err := runCommand("systemctl", "reload", "nmbd"); err != nil {
+		logger.Debug("Failed to reload nmbd", zap.String("output", output))
 	}
 }
 
@@ -574,9 +614,8 @@ func configureNFSShare(share *models.Share) error {
 	}
 
 	// Reload NFS exports
-	cmd := exec.Command("exportfs", "-ra")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to reload exports: %s: %w", string(output), err)
+	if output, err := runCommand("exportfs", "-ra"); err != nil {
+		return fmt.Errorf("failed to reload exports: %s: %w", output, err)
 	}
 
 	return nil
@@ -588,9 +627,8 @@ func removeNFSShare(share *models.Share) error {
 	// In production, you'd want to parse and rewrite /etc/exports properly
 
 	// Unexport
-	cmd := exec.Command("exportfs", "-u", "*:"+share.Path)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		logger.Warn("Failed to unexport", zap.String("output", string(output)))
+	if output, err := runCommand("exportfs", "-u", "*:"+share.Path); err != nil {
+		logger.Warn("Failed to unexport", zap.String("output", output))
 	}
 
 	return nil
@@ -677,7 +715,7 @@ func RepairSambaConfig() error {
 	// Only write back if we made changes
 	if removedInclude || migratedShares > 0 {
 		newContent := strings.Join(cleanedLines, "\n")
-		if err := os.WriteFile(smbConfPath, []byte(newContent), 0644); err != nil {
+		if err := sysutil.AtomicWriteFile(smbConfPath, []byte(newContent), 0644); err != nil {
 			return fmt.Errorf("failed to write repaired smb.conf: %w", err)
 		}
 
@@ -721,6 +759,8 @@ func DisableShare(id string) error {
 
 // updateShareStatus updates the enabled status of a share
 func updateShareStatus(id string, enabled bool) error {
+	defer database.Cached().InvalidateShares()
+
 	var model models.Share
 	if err := database.DB.First(&model, id).Error; err != nil {
 		return err
@@ -803,8 +843,7 @@ func setupSharePermissions(share *models.Share) error {
 func ensureSMBGroup(groupName string) error {
 	// Check if group exists
 	getentPath := sysutil.FindCommand("getent")
-	cmd := exec.Command(getentPath, "group", groupName)
-	if err := cmd.Run(); err == nil {
+	if _, err := runCommand(getentPath, "group", groupName); err == nil {
 		// Group exists
 		return nil
 	}
@@ -812,69 +851,53 @@ func ensureSMBGroup(groupName string) error {
 	// Group doesn't exist, create it with retry logic
 	groupaddPath := sysutil.FindCommand("groupadd")
 
-	// Retry logic for /etc/group lock contention
-	// Increased retries due to severe lock contention during service startup
-	maxRetries := 10
-	baseDelay := 150 * time.Millisecond
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 150ms, 300ms, 600ms, 1200ms, 2400ms, 4800ms, 9600ms, 19200ms, 38400ms
-			delay := baseDelay * time.Duration(1<<uint(attempt-1))
-			logger.Info("Retrying groupadd after delay",
+	// Retry on /etc/group lock contention, which is severe during service
+	// startup: 10 attempts with delays from 150ms doubling up to 38.4s
+	created, err := sysutil.RetryWithBackoff(context.Background(), sysutil.RetryOptions{
+		MaxAttempts:  10,
+		InitialDelay: 150 * time.Millisecond,
+		RetryIf: func(err error) bool {
+			return sysutil.RetryOnLockContention(err) ||
+				sysutil.RetryOnContains("group")(err) && sysutil.RetryOnContains("lock")(err)
+		},
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			logger.Info("groupadd lock contention detected, will retry",
 				zap.String("group", groupName),
-				zap.Int("attempt", attempt+1),
-				zap.Duration("delay", delay))
-			time.Sleep(delay)
-		}
-
-		cmd = exec.Command(groupaddPath, groupName)
-		output, err := cmd.CombinedOutput()
-		if err == nil {
-			logger.Info("Created SMB group successfully",
-				zap.String("group", groupName),
-				zap.String("groupadd_path", groupaddPath),
-				zap.Int("attempts", attempt+1))
-			return nil
-		}
-
-		// Check if error is due to /etc/group lock contention
-		outputStr := string(output)
-		isLockError := strings.Contains(outputStr, "konnte nicht gesperrt werden") ||
-			strings.Contains(outputStr, "cannot lock") ||
-			strings.Contains(outputStr, "unable to lock") ||
-			strings.Contains(outputStr, "temporarily unavailable") ||
-			strings.Contains(outputStr, "group") && strings.Contains(outputStr, "lock")
-
-		// If group already exists (race condition), that's fine
-		if strings.Contains(outputStr, "already exists") {
+				zap.Int("attempt", attempt),
+				zap.Duration("delay", delay),
+				zap.Error(err))
+		},
+	}, func() (bool, error) {
+		output, err := runCommand(groupaddPath, groupName)
+		if err != nil && strings.Contains(output, "already exists") {
+			// Created concurrently, that's fine
 			logger.Info("SMB group already exists (race condition resolved)",
 				zap.String("group", groupName))
-			return nil
+			return false, nil
 		}
-
-		// If it's not a lock error, or we're on the last attempt, return the error
-		if !isLockError || attempt == maxRetries-1 {
-			return fmt.Errorf("failed to create group %s: %s: %w", groupName, outputStr, err)
+		if err != nil {
+			// The output is matched to detect lock contention
+			return false, fmt.Errorf("%s: %w", output, err)
 		}
-
-		logger.Info("groupadd lock contention detected, will retry",
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create group %s: %w", groupName, err)
+	}
+	if created {
+		logger.Info("Created SMB group successfully",
 			zap.String("group", groupName),
-			zap.Int("attempt", attempt+1),
-			zap.Int("max_retries", maxRetries),
-			zap.String("error", outputStr))
+			zap.String("groupadd_path", groupaddPath))
 	}
-
-	return fmt.Errorf("groupadd failed after %d attempts for group %s", maxRetries, groupName)
+	return nil
 }
 
 // setShareGroupOwnership sets the group ownership of a path
 func setShareGroupOwnership(path, groupName string) error {
 	// Use chgrp to set group ownership
 	chgrpPath := sysutil.FindCommand("chgrp")
-	cmd := exec.Command(chgrpPath, groupName, path)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("chgrp failed: %s: %w", string(output), err)
+	if output, err := runCommand(chgrpPath, groupName, path); err != nil {
+		return fmt.Errorf("chgrp failed: %s: %w", output, err)
 	}
 	return nil
 }
@@ -883,13 +906,12 @@ func setShareGroupOwnership(path, groupName string) error {
 func addUserToGroup(username, groupName string) error {
 	// Check if user already in group
 	idPath := sysutil.FindCommand("id")
-	cmd := exec.Command(idPath, "-nG", username)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(idPath, "-nG", username)
 	if err != nil {
 		return fmt.Errorf("failed to check user groups: %w", err)
 	}
 
-	groups := strings.Fields(string(output))
+	groups := strings.Fields(output)
 	for _, group := range groups {
 		if group == groupName {
 			// User already in group
@@ -899,9 +921,8 @@ func addUserToGroup(username, groupName string) error {
 
 	// Add user to group
 	usermodPath := sysutil.FindCommand("usermod")
-	cmd = exec.Command(usermodPath, "-aG", groupName, username)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("usermod failed: %s: %w", string(output), err)
+	if output, err := runCommand(usermodPath, "-aG", groupName, username); err != nil {
+		return fmt.Errorf("usermod failed: %s: %w", output, err)
 	}
 
 	logger.Info("Added user to SMB group",
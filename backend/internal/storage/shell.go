@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+)
+
+// hostShell runs the Samba, NFS and group commands of the share functions.
+// Replaced in tests.
+var hostShell executor.ShellExecutor = newHostShell()
+
+func newHostShell() executor.ShellExecutor {
+	// NewShellExecutor only fails for invalid arguments
+	shell, _ := system.NewShellExecutor(30*time.Second, false)
+	return shell
+}
+
+// runCommand runs a command through hostShell and returns its stdout and
+// stderr, for parsing and error messages like exec.Cmd.CombinedOutput
+func runCommand(name string, args ...string) (string, error) {
+	return combinedOutput(hostShell.Execute(name, args...))
+}
+
+func combinedOutput(result *executor.CommandResult, err error) (string, error) {
+	if result == nil {
+		return "", err
+	}
+	var output []string
+	for _, s := range []string{result.Stdout, result.Stderr} {
+		if s != "" {
+			output = append(output, s)
+		}
+	}
+	return strings.Join(output, "\n"), err
+}
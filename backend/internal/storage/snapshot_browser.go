@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// zfsSnapshotDir is the hidden directory in the root of a ZFS dataset that
+// exposes its snapshots read-only, one directory per snapshot
+const zfsSnapshotDir = ".zfs/snapshot"
+
+var (
+	// ErrShareNotFound is returned for unknown share names
+	ErrShareNotFound = errors.New("share not found")
+
+	// ErrSnapshotsUnavailable is returned for shares that are not the root of a ZFS dataset
+	ErrSnapshotsUnavailable = errors.New("share has no ZFS snapshots")
+
+	// ErrSnapshotNotFound is returned for unknown snapshots or files in them
+	ErrSnapshotNotFound = errors.New("snapshot or file not found")
+
+	// ErrInvalidSnapshotPath is returned for snapshot names and paths that
+	// cannot be browsed or restored
+	ErrInvalidSnapshotPath = errors.New("invalid snapshot path")
+)
+
+// SnapshotEntry is a ZFS snapshot of the dataset a share lives on
+type SnapshotEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`    // Read-only directory of the snapshot contents
+	ModTime time.Time `json:"modTime"` // Modification time of the dataset root when the snapshot was taken
+}
+
+// RestoreFileRequest restores a file from a snapshot. RestoreTo is relative
+// to the share and defaults to the file's own path.
+type RestoreFileRequest struct {
+	FilePath  string `json:"filePath"`
+	RestoreTo string `json:"restoreTo,omitempty"`
+}
+
+// ShareSnapshotBrowser gives access to the files in the ZFS snapshots of
+// shares, which are visible under <share>/.zfs/snapshot
+type ShareSnapshotBrowser struct {
+	db *gorm.DB
+}
+
+// NewShareSnapshotBrowser creates a snapshot browser for the shares in db
+func NewShareSnapshotBrowser(db *gorm.DB) *ShareSnapshotBrowser {
+	return &ShareSnapshotBrowser{db: db}
+}
+
+// ListSnapshots lists the snapshots of a share, oldest first
+func (b *ShareSnapshotBrowser) ListSnapshots(shareName string) ([]SnapshotEntry, error) {
+	root, err := b.snapshotRoot(shareName)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	snapshots := make([]SnapshotEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot := SnapshotEntry{Name: entry.Name(), Path: filepath.Join(root, entry.Name())}
+		if info, err := os.Stat(snapshot.Path); err == nil {
+			snapshot.ModTime = info.ModTime()
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ModTime.Before(snapshots[j].ModTime)
+	})
+	return snapshots, nil
+}
+
+// SnapshotPath returns the absolute path of path, relative to the share,
+// inside a snapshot of the share
+func (b *ShareSnapshotBrowser) SnapshotPath(shareName, snapshotName, path string) (string, error) {
+	if snapshotName == "" || snapshotName == "." || snapshotName == ".." || strings.ContainsRune(snapshotName, '/') {
+		return "", fmt.Errorf("%w: snapshot name %q", ErrInvalidSnapshotPath, snapshotName)
+	}
+
+	root, err := b.snapshotRoot(shareName)
+	if err != nil {
+		return "", err
+	}
+	snapshotDir := filepath.Join(root, snapshotName)
+	if !sysutil.DirExists(snapshotDir) {
+		return "", ErrSnapshotNotFound
+	}
+
+	path, err = sysutil.SafeJoin(snapshotDir, path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSnapshotPath, err)
+	}
+	return path, nil
+}
+
+// RestoreFileFromSnapshot copies a file from a snapshot into the live share.
+// filePath and restoreTo are relative to the share; an empty restoreTo
+// restores the file in place, replacing the current version.
+func (b *ShareSnapshotBrowser) RestoreFileFromSnapshot(shareName, snapshotName, filePath, restoreTo string) error {
+	src, err := b.SnapshotPath(shareName, snapshotName, filePath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrSnapshotNotFound
+		}
+		return fmt.Errorf("failed to stat snapshot file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s is not a regular file", ErrInvalidSnapshotPath, filePath)
+	}
+
+	share, err := b.getShare(shareName)
+	if err != nil {
+		return err
+	}
+	if restoreTo == "" {
+		restoreTo = filePath
+	}
+	dst, err := sysutil.SafeJoin(share.Path, restoreTo)
+	if err != nil || dst == filepath.Clean(share.Path) || strings.HasPrefix(dst, filepath.Join(share.Path, ".zfs")) {
+		return fmt.Errorf("%w: restore target %q", ErrInvalidSnapshotPath, restoreTo)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create restore directory: %w", err)
+	}
+
+	// Copy next to the target and rename, so the current version stays
+	// intact if the copy fails
+	tmp := dst + ".restore-tmp"
+	if err := sysutil.CopyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to restore file: %w", err)
+	}
+
+	logger.Info("File restored from snapshot",
+		zap.String("share", shareName),
+		zap.String("snapshot", snapshotName),
+		zap.String("file", filePath),
+		zap.String("restoredTo", dst))
+	return nil
+}
+
+// snapshotRoot returns the snapshot directory of a share
+func (b *ShareSnapshotBrowser) snapshotRoot(shareName string) (string, error) {
+	share, err := b.getShare(shareName)
+	if err != nil {
+		return "", err
+	}
+	root := filepath.Join(share.Path, zfsSnapshotDir)
+	if !sysutil.DirExists(root) {
+		return "", ErrSnapshotsUnavailable
+	}
+	return root, nil
+}
+
+func (b *ShareSnapshotBrowser) getShare(name string) (*models.Share, error) {
+	if b.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var share models.Share
+	if err := b.db.Where("name = ?", name).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrShareNotFound
+		}
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+	return &share, nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestRestoreFileFromSnapshot(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "storage.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Share{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	// Lay out the share the way ZFS exposes a dataset with one snapshot
+	// taken before report.txt was modified
+	sharePath := t.TempDir()
+	snapshotDir := filepath.Join(sharePath, ".zfs", "snapshot", "daily-1", "docs")
+	for dir, content := range map[string]string{
+		filepath.Join(sharePath, "docs"): "modified",
+		snapshotDir:                      "original",
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "report.txt"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Create(&models.Share{Name: "docs", Path: sharePath, Type: "smb"})
+
+	browser := NewShareSnapshotBrowser(db)
+	snapshots, err := browser.ListSnapshots("docs")
+	if err != nil {
+		t.Fatalf("ListSnapshots: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Name != "daily-1" {
+		t.Fatalf("snapshots = %+v, want daily-1", snapshots)
+	}
+
+	if err := browser.RestoreFileFromSnapshot("docs", "daily-1", "docs/report.txt", "docs/report-restored.txt"); err != nil {
+		t.Fatalf("restore copy: %v", err)
+	}
+	if err := browser.RestoreFileFromSnapshot("docs", "daily-1", "docs/report.txt", ""); err != nil {
+		t.Fatalf("restore in place: %v", err)
+	}
+	for _, name := range []string{"report.txt", "report-restored.txt"} {
+		data, err := os.ReadFile(filepath.Join(sharePath, "docs", name))
+		if err != nil || string(data) != "original" {
+			t.Errorf("%s = %q, %v; want the snapshot version", name, data, err)
+		}
+	}
+
+	tests := []struct {
+		snapshot, file, restoreTo string
+		want                      error
+	}{
+		{"daily-2", "docs/report.txt", "", ErrSnapshotNotFound},
+		{"daily-1", "docs/missing.txt", "", ErrSnapshotNotFound},
+		{"../..", "docs/report.txt", "", ErrInvalidSnapshotPath},
+		{"daily-1", "docs", "", ErrInvalidSnapshotPath},
+		{"daily-1", "docs/report.txt", "../outside.txt", ErrInvalidSnapshotPath},
+		{"daily-1", "docs/report.txt", ".zfs/snapshot/daily-1/x", ErrInvalidSnapshotPath},
+	}
+	for _, tt := range tests {
+		err := browser.RestoreFileFromSnapshot("docs", tt.snapshot, tt.file, tt.restoreTo)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("restore %s %s to %q = %v, want %v", tt.snapshot, tt.file, tt.restoreTo, err, tt.want)
+		}
+	}
+}
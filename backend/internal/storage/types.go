@@ -1,7 +1,11 @@
 // Revision: 2025-11-16 | Author: Claude | Version: 1.1.1
 package storage
 
-import "time"
+import (
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+)
 
 // DiskType represents the type of disk
 type DiskType string
@@ -131,22 +135,26 @@ const (
 	ShareTypeFTP ShareType = "ftp"
 )
 
+// ShareFileFilter restricts the file types stored on a share
+type ShareFileFilter = models.ShareFileFilter
+
 // Share represents a network share
 type Share struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Path        string    `json:"path"`
-	VolumeID    string    `json:"volumeId,omitempty"` // Optional - linked volume
-	Type        ShareType `json:"type"`
-	Description string    `json:"description"`
-	Enabled     bool      `json:"enabled"`
-	ReadOnly    bool      `json:"readOnly"`
-	Browseable  bool      `json:"browseable"`
-	GuestOK     bool      `json:"guestOk"`
-	ValidUsers  []string  `json:"validUsers,omitempty"`
-	ValidGroups []string  `json:"validGroups,omitempty"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Path        string          `json:"path"`
+	VolumeID    string          `json:"volumeId,omitempty"` // Optional - linked volume
+	Type        ShareType       `json:"type"`
+	Description string          `json:"description"`
+	Enabled     bool            `json:"enabled"`
+	ReadOnly    bool            `json:"readOnly"`
+	Browseable  bool            `json:"browseable"`
+	GuestOK     bool            `json:"guestOk"`
+	ValidUsers  []string        `json:"validUsers,omitempty"`
+	ValidGroups []string        `json:"validGroups,omitempty"`
+	FileFilter  ShareFileFilter `json:"fileFilter"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
 }
 
 // StorageStats represents overall storage statistics
@@ -187,16 +195,17 @@ type CreateVolumeRequest struct {
 
 // CreateShareRequest represents a request to create a new share
 type CreateShareRequest struct {
-	Name        string    `json:"name" validate:"required,min=1,max=255"`
-	VolumeID    string    `json:"volumeId,omitempty"` // Optional - select from managed volumes
-	Path        string    `json:"path,omitempty"`     // Optional - manual path (used if VolumeID not provided)
-	Type        ShareType `json:"type" validate:"required,oneof=smb nfs ftp"`
-	Description string    `json:"description"`
-	ReadOnly    bool      `json:"readOnly"`
-	Browseable  bool      `json:"browseable"`
-	GuestOK     bool      `json:"guestOk"`
-	ValidUsers  []string  `json:"validUsers,omitempty"`
-	ValidGroups []string  `json:"validGroups,omitempty"`
+	Name        string          `json:"name" validate:"required,min=1,max=255"`
+	VolumeID    string          `json:"volumeId,omitempty" openapi:"summary=Managed volume to create the share on"` // Optional - select from managed volumes
+	Path        string          `json:"path,omitempty" openapi:"summary=Share path (used if volumeId is not set)"`  // Optional - manual path (used if VolumeID not provided)
+	Type        ShareType       `json:"type" validate:"required,oneof=smb nfs ftp" openapi:"summary=Share protocol: smb, nfs or ftp"`
+	Description string          `json:"description"`
+	ReadOnly    bool            `json:"readOnly"`
+	Browseable  bool            `json:"browseable"`
+	GuestOK     bool            `json:"guestOk"`
+	ValidUsers  []string        `json:"validUsers,omitempty"`
+	ValidGroups []string        `json:"validGroups,omitempty"`
+	FileFilter  ShareFileFilter `json:"fileFilter"`
 }
 
 // FormatDiskRequest represents a request to format a disk/partition
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// VolumeHealthCheck is the result of a canary file check on a volume
+type VolumeHealthCheck = models.VolumeHealthCheck
+
+const (
+	// volumeHealthCanary is the file written and read back on each volume
+	volumeHealthCanary = ".stumpfworks_healthcheck"
+	// volumeHealthFailureThreshold is how many consecutive failed checks
+	// raise an alert
+	volumeHealthFailureThreshold = 3
+	// volumeHealthRetention is how long check results are kept
+	volumeHealthRetention = 7 * 24 * time.Hour
+)
+
+// readOnlyFilesystems cannot take a canary file and are not checked
+var readOnlyFilesystems = map[string]bool{
+	"squashfs": true,
+	"iso9660":  true,
+	"udf":      true,
+}
+
+// The canary file I/O, replaced in tests to inject errors
+var (
+	writeFile  = os.WriteFile
+	readFile   = os.ReadFile
+	removeFile = os.Remove
+)
+
+// VolumeHealthMonitor checks that mounted volumes still accept I/O by
+// writing and reading back a canary file. A volume can stay mounted while
+// its filesystem fails, which only shows once it is written to.
+type VolumeHealthMonitor struct {
+	db      *gorm.DB
+	volumes func() ([]Volume, error)
+	publish func(notifications.Notification) error
+
+	mu       sync.Mutex
+	failures map[string]int // Consecutive failed checks per volume ID
+}
+
+// NewVolumeHealthMonitor creates a monitor for the mounted volumes
+func NewVolumeHealthMonitor(db *gorm.DB) *VolumeHealthMonitor {
+	return &VolumeHealthMonitor{
+		db:       db,
+		volumes:  getMountedVolumes,
+		publish:  notifications.Publish,
+		failures: make(map[string]int),
+	}
+}
+
+// StartMonitoring checks all mounted volumes every intervalSeconds until
+// ctx is cancelled
+func (m *VolumeHealthMonitor) StartMonitoring(ctx context.Context, intervalSeconds int) error {
+	if m.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if intervalSeconds < 1 {
+		return fmt.Errorf("interval must be at least 1 second")
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			if _, err := m.CheckAll(); err != nil {
+				logger.Warn("Volume health check failed", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// CheckAll checks each mounted volume once, records the results and alerts
+// about volumes that failed three checks in a row
+func (m *VolumeHealthMonitor) CheckAll() ([]VolumeHealthCheck, error) {
+	volumes, err := m.volumes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mounted volumes: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var checks []VolumeHealthCheck
+	seen := make(map[string]bool)
+	for _, vol := range volumes {
+		if vol.MountPoint == "" || readOnlyFilesystems[vol.Filesystem] || seen[vol.ID] {
+			continue
+		}
+		seen[vol.ID] = true
+
+		check := checkVolumeHealth(vol)
+		if err := m.db.Create(&check).Error; err != nil {
+			logger.Warn("Failed to record volume health check", zap.String("volume", vol.ID), zap.Error(err))
+		}
+		checks = append(checks, check)
+
+		if check.Status == models.VolumeHealthHealthy {
+			m.failures[vol.ID] = 0
+			continue
+		}
+		m.failures[vol.ID]++
+		logger.Warn("Volume health check failed",
+			zap.String("volume", vol.ID),
+			zap.String("mountPoint", vol.MountPoint),
+			zap.Int("consecutiveFailures", m.failures[vol.ID]),
+			zap.String("error", check.ErrorMessage))
+		// Alert once per streak of failures
+		if m.failures[vol.ID] == volumeHealthFailureThreshold {
+			m.report(check)
+		}
+	}
+
+	cutoff := time.Now().Add(-volumeHealthRetention)
+	if err := m.db.Where("checked_at < ?", cutoff).Delete(&models.VolumeHealthCheck{}).Error; err != nil {
+		logger.Warn("Failed to delete old volume health checks", zap.Error(err))
+	}
+	return checks, nil
+}
+
+// report publishes a notification about a failing volume
+func (m *VolumeHealthMonitor) report(check VolumeHealthCheck) {
+	err := m.publish(notifications.Notification{
+		Type:     models.AlertTypeVolumeHealth,
+		Title:    fmt.Sprintf("Volume %s is failing I/O", check.VolumeID),
+		Body:     fmt.Sprintf("The last %d health checks of the volume mounted at %s failed: %s", volumeHealthFailureThreshold, check.MountPoint, check.ErrorMessage),
+		Severity: notifications.SeverityCritical,
+		Source:   "storage",
+		Metadata: map[string]interface{}{"volume": check.VolumeID, "mountPoint": check.MountPoint},
+	})
+	if err != nil {
+		logger.Warn("Failed to publish volume health notification", zap.Error(err))
+	}
+}
+
+// checkVolumeHealth writes a canary file to the volume, reads it back and
+// removes it
+func checkVolumeHealth(vol Volume) VolumeHealthCheck {
+	check := VolumeHealthCheck{
+		VolumeID:   vol.ID,
+		MountPoint: vol.MountPoint,
+		Status:     models.VolumeHealthHealthy,
+		CheckedAt:  time.Now(),
+	}
+
+	err := writeAndReadCanary(filepath.Join(vol.MountPoint, volumeHealthCanary))
+	check.LatencyMs = time.Since(check.CheckedAt).Milliseconds()
+	if err != nil {
+		check.Status = models.VolumeHealthFailed
+		check.ErrorMessage = err.Error()
+	}
+	return check
+}
+
+// writeAndReadCanary checks that random content written to path reads back
+// unchanged
+func writeAndReadCanary(path string) error {
+	token := make([]byte, 16)
+	rand.Read(token)
+	content := []byte(hex.EncodeToString(token))
+
+	if err := writeFile(path, content, 0600); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	defer func() {
+		if err := removeFile(path); err != nil && !os.IsNotExist(err) {
+			logger.Warn("Failed to remove volume health canary", zap.String("path", path), zap.Error(err))
+		}
+	}()
+
+	data, err := readFile(path)
+	if err != nil {
+		return fmt.Errorf("read failed: %w", err)
+	}
+	if !bytes.Equal(data, content) {
+		return fmt.Errorf("read back different content than written")
+	}
+	return nil
+}
+
+// GetVolumeHealthHistory returns the latest health checks of a volume,
+// newest first
+func GetVolumeHealthHistory(volumeID string, limit int) ([]VolumeHealthCheck, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, errors.InternalServerError("Database not initialized", nil)
+	}
+
+	checks := []VolumeHealthCheck{}
+	if err := db.Where("volume_id = ?", volumeID).
+		Order("checked_at DESC, id DESC").
+		Limit(limit).
+		Find(&checks).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to query volume health history", err)
+	}
+	return checks, nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestVolumeHealthMonitor(t *testing.T, mountPoint string) (*VolumeHealthMonitor, *[]notifications.Notification) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "health.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.VolumeHealthCheck{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	var published []notifications.Notification
+	m := NewVolumeHealthMonitor(db)
+	m.volumes = func() ([]Volume, error) {
+		return []Volume{{ID: "sdb1", MountPoint: mountPoint, Filesystem: "ext4"}}, nil
+	}
+	m.publish = func(n notifications.Notification) error {
+		published = append(published, n)
+		return nil
+	}
+	return m, &published
+}
+
+func TestVolumeHealthMonitorAlertsAfterThreeFailures(t *testing.T) {
+	mountPoint := t.TempDir()
+	m, published := newTestVolumeHealthMonitor(t, mountPoint)
+
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		return &os.PathError{Op: "write", Path: name, Err: syscall.EIO}
+	}
+	t.Cleanup(func() { writeFile = os.WriteFile })
+
+	for i := 1; i <= 4; i++ {
+		checks, err := m.CheckAll()
+		if err != nil {
+			t.Fatalf("CheckAll() error = %v", err)
+		}
+		if len(checks) != 1 || checks[0].Status != models.VolumeHealthFailed {
+			t.Fatalf("check %d = %+v, want one failed check", i, checks)
+		}
+		if want := i / volumeHealthFailureThreshold; len(*published) != want {
+			t.Errorf("after %d failed checks %d alerts were published, want %d", i, len(*published), want)
+		}
+	}
+	if n := (*published)[0]; n.Type != models.AlertTypeVolumeHealth || n.Severity != notifications.SeverityCritical {
+		t.Errorf("published %+v, want a critical volume health notification", n)
+	}
+}
+
+func TestVolumeHealthMonitorRecordsHealthyChecks(t *testing.T) {
+	mountPoint := t.TempDir()
+	m, published := newTestVolumeHealthMonitor(t, mountPoint)
+
+	// Two failures followed by a success reset the streak
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		return errors.New("read-only file system")
+	}
+	t.Cleanup(func() { writeFile = os.WriteFile })
+	m.CheckAll()
+	m.CheckAll()
+	writeFile = os.WriteFile
+	m.CheckAll()
+	if len(*published) != 0 || m.failures["sdb1"] != 0 {
+		t.Errorf("healthy check did not reset failures: %d alerts, %d failures", len(*published), m.failures["sdb1"])
+	}
+
+	if _, err := os.Stat(filepath.Join(mountPoint, volumeHealthCanary)); !os.IsNotExist(err) {
+		t.Errorf("canary file was not removed: %v", err)
+	}
+
+	database.DB = m.db
+	t.Cleanup(func() { database.DB = nil })
+	history, err := GetVolumeHealthHistory("sdb1", 10)
+	if err != nil {
+		t.Fatalf("GetVolumeHealthHistory() error = %v", err)
+	}
+	if len(history) != 3 || history[0].Status != models.VolumeHealthHealthy || history[1].Status != models.VolumeHealthFailed {
+		t.Errorf("history = %+v, want the healthy check first, then two failed checks", history)
+	}
+}
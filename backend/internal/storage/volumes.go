@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"go.uber.org/zap"
 )
 
@@ -119,7 +120,7 @@ func getRAIDVolumes() ([]Volume, error) {
 	var volumes []Volume
 
 	// Check if mdadm is available
-	if _, err := exec.LookPath("mdadm"); err != nil {
+	if !sysutil.CommandExists("mdadm") {
 		return volumes, nil // No RAID support
 	}
 
@@ -205,7 +206,7 @@ func getLVMVolumes() ([]Volume, error) {
 	var volumes []Volume
 
 	// Check if lvs is available
-	if _, err := exec.LookPath("lvs"); err != nil {
+	if !sysutil.CommandExists("lvs") {
 		return volumes, nil // No LVM support
 	}
 
@@ -372,8 +373,8 @@ func CreateVolume(req *CreateVolumeRequest) (*Volume, error) {
 
 // createRAIDVolume creates a RAID array
 func createRAIDVolume(req *CreateVolumeRequest) (*Volume, error) {
-	if _, err := exec.LookPath("mdadm"); err != nil {
-		return nil, fmt.Errorf("mdadm not available")
+	if _, err := sysutil.RequireCommand("mdadm"); err != nil {
+		return nil, err
 	}
 
 	// Prepare disk paths
@@ -443,8 +444,8 @@ func createRAIDVolume(req *CreateVolumeRequest) (*Volume, error) {
 
 // createLVMVolume creates an LVM logical volume
 func createLVMVolume(req *CreateVolumeRequest) (*Volume, error) {
-	if _, err := exec.LookPath("lvcreate"); err != nil {
-		return nil, fmt.Errorf("LVM tools not available")
+	if _, err := sysutil.RequireCommand("lvcreate"); err != nil {
+		return nil, err
 	}
 
 	if len(req.Disks) == 0 {
@@ -873,7 +874,7 @@ func removeFromFstab(mountPoint string) error {
 
 	// Write new fstab
 	newData := strings.Join(newLines, "\n")
-	if err := os.WriteFile("/etc/fstab", []byte(newData), 0644); err != nil {
+	if err := sysutil.AtomicWriteFile("/etc/fstab", []byte(newData), 0644); err != nil {
 		logger.Error("Failed to write /etc/fstab", zap.Error(err))
 		return fmt.Errorf("failed to update /etc/fstab: %w", err)
 	}
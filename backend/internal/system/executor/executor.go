@@ -3,7 +3,10 @@
 // used across all system management packages.
 package executor
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // CommandResult represents the result of a shell command execution
 type CommandResult struct {
@@ -26,6 +29,12 @@ type ShellExecutor interface {
 	// ExecuteWithTimeout runs a command with a specific timeout
 	ExecuteWithTimeout(timeout time.Duration, command string, args ...string) (*CommandResult, error)
 
+	// RunPipeline runs commands connected by pipes, without a shell
+	RunPipeline(ctx context.Context, pipeline Pipeline) (*CommandResult, error)
+
+	// PipeCommands runs the stages connected by pipes, without input
+	PipeCommands(ctx context.Context, stages []PipelineStage) (*CommandResult, error)
+
 	// CommandExists checks if a command exists in PATH
 	CommandExists(command string) bool
 
@@ -0,0 +1,273 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ExecuteResult is the canned outcome of a mocked command
+type ExecuteResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error // Returned instead of running, e.g. a timeout
+}
+
+// ExecutedCommand is a command run through a MockShellExecutor
+type ExecutedCommand struct {
+	Command string
+	Args    []string
+	Timeout time.Duration // Zero for Execute
+	Stdin   string        // Input of the first stage of a pipeline
+}
+
+// String returns the command line of the call
+func (c ExecutedCommand) String() string {
+	return strings.TrimSpace(c.Command + " " + strings.Join(c.Args, " "))
+}
+
+// MockShellExecutor is a ShellExecutor for tests that never runs anything.
+// A call is answered by the first matching expectation registered with
+// ExpectCommand, then by Responses keyed by command name, then by Handler.
+// Calls nothing answers fail with an error. Every call is recorded in Calls.
+type MockShellExecutor struct {
+	Responses map[string]ExecuteResult
+	Calls     []ExecutedCommand
+
+	// Handler answers calls without a canned response, for fakes that need
+	// state across calls
+	Handler func(call ExecutedCommand) (*CommandResult, error)
+
+	// MissingCommands are reported as absent by CommandExists
+	MissingCommands []string
+
+	mu           sync.Mutex
+	expectations []*ExpectationBuilder
+	dryRun       bool
+}
+
+// Ensure MockShellExecutor implements the ShellExecutor interface
+var _ ShellExecutor = (*MockShellExecutor)(nil)
+
+// ExpectationBuilder configures the response to an expected command
+type ExpectationBuilder struct {
+	command string
+	args    []string
+	result  ExecuteResult
+	times   int // Zero means any number of times, at least once
+	calls   int
+}
+
+// NewMockShellExecutor creates a mock executor without canned responses
+func NewMockShellExecutor() *MockShellExecutor {
+	return &MockShellExecutor{Responses: make(map[string]ExecuteResult)}
+}
+
+// ExpectCommand expects the command to be run with exactly args. Without
+// args any arguments match. The command succeeds with no output unless
+// configured with Returns or Fails.
+func (m *MockShellExecutor) ExpectCommand(cmd string, args ...string) *ExpectationBuilder {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &ExpectationBuilder{command: cmd, args: args}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// Returns sets the output and exit code of the command. A non-zero exit
+// code makes Execute return an error like the real executor.
+func (e *ExpectationBuilder) Returns(stdout, stderr string, exitCode int) *ExpectationBuilder {
+	e.result = ExecuteResult{Stdout: stdout, Stderr: stderr, ExitCode: exitCode}
+	return e
+}
+
+// Fails makes the command fail with err without producing output
+func (e *ExpectationBuilder) Fails(err error) *ExpectationBuilder {
+	e.result = ExecuteResult{Err: err, ExitCode: -1}
+	return e
+}
+
+// Times limits the expectation to n calls; AssertExpectations checks it was
+// called exactly n times
+func (e *ExpectationBuilder) Times(n int) *ExpectationBuilder {
+	e.times = n
+	return e
+}
+
+func (e *ExpectationBuilder) matches(command string, args []string) bool {
+	if e.command != command || (e.times > 0 && e.calls >= e.times) {
+		return false
+	}
+	if len(e.args) == 0 {
+		return true
+	}
+	if len(e.args) != len(args) {
+		return false
+	}
+	for i := range args {
+		if e.args[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Execute records the call and returns its configured response
+func (m *MockShellExecutor) Execute(command string, args ...string) (*CommandResult, error) {
+	return m.run(ExecutedCommand{Command: command, Args: args})
+}
+
+// ExecuteWithTimeout records the call with its timeout and returns its
+// configured response
+func (m *MockShellExecutor) ExecuteWithTimeout(timeout time.Duration, command string, args ...string) (*CommandResult, error) {
+	return m.run(ExecutedCommand{Command: command, Args: args, Timeout: timeout})
+}
+
+// ExecutePipe records the pipeline as a bash -c call, as the real executor
+// runs it
+func (m *MockShellExecutor) ExecutePipe(commands ...string) (*CommandResult, error) {
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("no commands provided")
+	}
+	return m.run(ExecutedCommand{Command: "bash", Args: []string{"-c", strings.Join(commands, " | ")}})
+}
+
+// RunPipeline records each stage as a call and returns the response of the
+// last one, stopping at the first stage that fails
+func (m *MockShellExecutor) RunPipeline(ctx context.Context, pipeline Pipeline) (*CommandResult, error) {
+	if len(pipeline.Stages) == 0 {
+		return nil, fmt.Errorf("no pipeline stages provided")
+	}
+
+	var result *CommandResult
+	for i, stage := range pipeline.Stages {
+		call := ExecutedCommand{Command: stage.Command, Args: stage.Args, Timeout: pipeline.Timeout}
+		if i == 0 {
+			call.Stdin = pipeline.Stdin
+		}
+		var err error
+		if result, err = m.run(call); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// PipeCommands records each stage as a call, like RunPipeline
+func (m *MockShellExecutor) PipeCommands(ctx context.Context, stages []PipelineStage) (*CommandResult, error) {
+	return m.RunPipeline(ctx, Pipeline{Stages: stages})
+}
+
+// CommandExists reports every command not in MissingCommands as present
+func (m *MockShellExecutor) CommandExists(command string) bool {
+	for _, missing := range m.MissingCommands {
+		if missing == command {
+			return false
+		}
+	}
+	return true
+}
+
+// SetDryRun enables or disables dry-run mode
+func (m *MockShellExecutor) SetDryRun(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dryRun = enabled
+}
+
+// IsDryRun returns whether dry-run mode is enabled
+func (m *MockShellExecutor) IsDryRun() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dryRun
+}
+
+// CallsTo returns the recorded calls of a command
+func (m *MockShellExecutor) CallsTo(command string) []ExecutedCommand {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var calls []ExecutedCommand
+	for _, c := range m.Calls {
+		if c.Command == command {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// AssertExpectations fails the test for every expectation that was not
+// called, or not called as many times as configured with Times
+func (m *MockShellExecutor) AssertExpectations(t testing.TB) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.expectations {
+		call := ExecutedCommand{Command: e.command, Args: e.args}.String()
+		switch {
+		case e.times > 0 && e.calls != e.times:
+			t.Errorf("expected %q to be run %d times, was run %d times", call, e.times, e.calls)
+		case e.calls == 0:
+			t.Errorf("expected %q to be run, was not run", call)
+		}
+	}
+}
+
+func (m *MockShellExecutor) run(call ExecutedCommand) (*CommandResult, error) {
+	m.mu.Lock()
+	m.Calls = append(m.Calls, call)
+
+	if m.dryRun {
+		m.mu.Unlock()
+		return &CommandResult{Command: call.Command, Args: call.Args, Stdout: "[DRY RUN] Command not executed", Success: true, DryRun: true}, nil
+	}
+
+	var result *ExecuteResult
+	for _, e := range m.expectations {
+		if e.matches(call.Command, call.Args) {
+			e.calls++
+			r := e.result
+			result = &r
+			break
+		}
+	}
+	if result == nil {
+		if r, ok := m.Responses[call.Command]; ok {
+			result = &r
+		}
+	}
+	handler := m.Handler
+	m.mu.Unlock()
+
+	if result == nil {
+		if handler != nil {
+			return handler(call)
+		}
+		return nil, fmt.Errorf("unexpected command: %s", call)
+	}
+
+	res := &CommandResult{
+		Command:  call.Command,
+		Args:     call.Args,
+		Stdout:   strings.TrimSpace(result.Stdout),
+		Stderr:   strings.TrimSpace(result.Stderr),
+		ExitCode: result.ExitCode,
+	}
+	switch {
+	case result.Err != nil:
+		res.Error = result.Err
+	case result.ExitCode != 0:
+		res.Error = fmt.Errorf("exit status %d", result.ExitCode)
+	}
+	if res.Error != nil {
+		return res, fmt.Errorf("command failed: %w", res.Error)
+	}
+	res.Success = true
+	return res, nil
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMockShellExecutor(t *testing.T) {
+	m := NewMockShellExecutor()
+	m.Responses["uname"] = ExecuteResult{Stdout: "Linux\n"}
+	m.ExpectCommand("zpool", "list", "-H").Returns("tank\tONLINE\n", "", 0).Times(1)
+	m.ExpectCommand("zpool").Returns("", "no such pool", 1)
+	m.ExpectCommand("smartctl").Fails(errors.New("timed out"))
+
+	tests := []struct {
+		name       string
+		call       func() (*CommandResult, error)
+		wantStdout string
+		wantExit   int
+		wantErr    bool
+	}{
+		{"exact args", func() (*CommandResult, error) { return m.Execute("zpool", "list", "-H") }, "tank\tONLINE", 0, false},
+		{"exhausted falls through to any args", func() (*CommandResult, error) { return m.Execute("zpool", "list", "-H") }, "", 1, true},
+		{"response by name", func() (*CommandResult, error) { return m.ExecuteWithTimeout(time.Second, "uname", "-s") }, "Linux", 0, false},
+		{"failure", func() (*CommandResult, error) { return m.Execute("smartctl", "-a", "/dev/sda") }, "", -1, true},
+		{"pipeline returns the last stage", func() (*CommandResult, error) {
+			return m.PipeCommands(context.Background(), []PipelineStage{{Command: "uname"}, {Command: "uname", Args: []string{"-r"}}})
+		}, "Linux", 0, false},
+		{"pipeline stops at the failing stage", func() (*CommandResult, error) {
+			return m.PipeCommands(context.Background(), []PipelineStage{{Command: "smartctl"}, {Command: "uname"}})
+		}, "", -1, true},
+		{"unexpected", func() (*CommandResult, error) { return m.Execute("rm", "-rf", "/") }, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.call()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result == nil {
+				return
+			}
+			if result.Stdout != tt.wantStdout || result.ExitCode != tt.wantExit || result.Success == tt.wantErr {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+
+	// The first pipeline records both stages, the second only the failing one
+	if len(m.Calls) != len(tests)+1 || m.Calls[2].Timeout != time.Second {
+		t.Errorf("calls = %+v", m.Calls)
+	}
+	m.AssertExpectations(t)
+}
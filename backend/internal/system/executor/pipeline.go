@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// PipelineStage is one command of a pipeline
+type PipelineStage struct {
+	Command string
+	Args    []string
+}
+
+// String returns the command line of the stage
+func (s PipelineStage) String() string {
+	return strings.TrimSpace(s.Command + " " + strings.Join(s.Args, " "))
+}
+
+// Pipeline runs commands with the stdout of each stage connected to the
+// stdin of the next, like a shell pipe but without a shell. Stdin is fed to
+// the first stage, which keeps secrets such as passwords out of the
+// process arguments.
+type Pipeline struct {
+	Stages  []PipelineStage
+	Stdin   string
+	Timeout time.Duration // Zero means no timeout beyond the context's
+}
+
+// NewPipeline creates a pipeline of the given stages
+func NewPipeline(stages ...PipelineStage) *Pipeline {
+	return &Pipeline{Stages: stages}
+}
+
+// WithTimeout sets the timeout of the whole pipeline
+func (p *Pipeline) WithTimeout(d time.Duration) *Pipeline {
+	p.Timeout = d
+	return p
+}
+
+// WithStdin sets the input of the first stage
+func (p *Pipeline) WithStdin(input string) *Pipeline {
+	p.Stdin = input
+	return p
+}
+
+// String returns the pipeline as a shell would show it, without its input
+func (p Pipeline) String() string {
+	stages := make([]string, len(p.Stages))
+	for i, stage := range p.Stages {
+		stages[i] = stage.String()
+	}
+	return strings.Join(stages, " | ")
+}
+
+// PipelineExecutor runs pipelines
+type PipelineExecutor interface {
+	// RunPipeline runs the stages of a pipeline connected by pipes
+	RunPipeline(ctx context.Context, pipeline Pipeline) (*CommandResult, error)
+}
+
+// RunPipeline runs the stages of a pipeline concurrently, each reading the
+// output of the previous one through an os.Pipe. The result holds the
+// stdout of the last stage and the stderr of all stages. Like a shell with
+// pipefail, the pipeline fails if any stage fails; ExitCode is that of the
+// last failing stage.
+func RunPipeline(ctx context.Context, pipeline Pipeline) (*CommandResult, error) {
+	if len(pipeline.Stages) == 0 {
+		return nil, fmt.Errorf("no pipeline stages provided")
+	}
+	if pipeline.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pipeline.Timeout)
+		defer cancel()
+	}
+
+	startTime := time.Now()
+	result := &CommandResult{Command: pipeline.String()}
+
+	cmds := make([]*exec.Cmd, len(pipeline.Stages))
+	stderrs := make([]bytes.Buffer, len(pipeline.Stages))
+	var stdout bytes.Buffer
+	for i, stage := range pipeline.Stages {
+		cmds[i] = exec.CommandContext(ctx, stage.Command, stage.Args...)
+		cmds[i].Stderr = &stderrs[i]
+	}
+	cmds[0].Stdin = strings.NewReader(pipeline.Stdin)
+	cmds[len(cmds)-1].Stdout = &stdout
+
+	// The parent's copies of the pipe ends are closed once the stages have
+	// started, so each reader sees EOF when its writer exits
+	var pipeFiles []*os.File
+	closePipes := func() {
+		for _, f := range pipeFiles {
+			f.Close()
+		}
+	}
+	for i := 0; i < len(cmds)-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closePipes()
+			return nil, fmt.Errorf("failed to create pipe: %w", err)
+		}
+		pipeFiles = append(pipeFiles, r, w)
+		cmds[i].Stdout = w
+		cmds[i+1].Stdin = r
+	}
+
+	started := 0
+	var startErr, runErr error
+	for _, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			startErr = fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+			break
+		}
+		started++
+	}
+	closePipes()
+
+	for i := 0; i < started; i++ {
+		if err := cmds[i].Wait(); err != nil {
+			if cmds[i].ProcessState != nil {
+				result.ExitCode = cmds[i].ProcessState.ExitCode()
+			}
+			runErr = fmt.Errorf("%s: %w", pipeline.Stages[i].Command, err)
+		}
+	}
+	if startErr != nil {
+		runErr = startErr
+	}
+
+	var stderr []string
+	for i := range stderrs {
+		if s := strings.TrimSpace(stderrs[i].String()); s != "" {
+			stderr = append(stderr, s)
+		}
+	}
+	result.Duration = time.Since(startTime)
+	result.Stdout = strings.TrimSpace(stdout.String())
+	result.Stderr = strings.Join(stderr, "\n")
+
+	if runErr != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			runErr = fmt.Errorf("pipeline timed out after %v", pipeline.Timeout)
+		}
+		result.Error = runErr
+		return result, fmt.Errorf("pipeline failed: %w", runErr)
+	}
+
+	result.Success = true
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPipeline(t *testing.T) {
+	ctx := context.Background()
+
+	pipeline := NewPipeline(
+		PipelineStage{Command: "cat"},
+		PipelineStage{Command: "tr", Args: []string{"a-z", "A-Z"}},
+		PipelineStage{Command: "rev"},
+	).WithStdin("hello pipe\n")
+	result, err := RunPipeline(ctx, *pipeline)
+	if err != nil {
+		t.Fatalf("RunPipeline: %v", err)
+	}
+	if result.Stdout != "EPIP OLLEH" || !result.Success {
+		t.Errorf("result = %+v, want EPIP OLLEH", result)
+	}
+	if result.Command != "cat | tr a-z A-Z | rev" {
+		t.Errorf("Command = %q", result.Command)
+	}
+
+	// A failing stage fails the pipeline even if the last stage succeeds
+	result, err = RunPipeline(ctx, *NewPipeline(
+		PipelineStage{Command: "ls", Args: []string{"/does/not/exist"}},
+		PipelineStage{Command: "cat"},
+	))
+	if err == nil || result.ExitCode == 0 || result.Stderr == "" {
+		t.Errorf("failing stage: result = %+v, err = %v", result, err)
+	}
+
+	_, err = RunPipeline(ctx, *NewPipeline(PipelineStage{Command: "sleep", Args: []string{"5"}}).WithTimeout(50 * time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("timeout error = %v", err)
+	}
+}
+
+func TestRunPipelineKeepsStdinOutOfArguments(t *testing.T) {
+	if _, err := os.Stat("/proc/self/cmdline"); err != nil {
+		t.Skip("/proc not available")
+	}
+
+	// cat prints its own argument list, then the secret it reads on stdin
+	const secret = "hunter2'; rm -rf /"
+	result, err := RunPipeline(context.Background(), *NewPipeline(
+		PipelineStage{Command: "cat", Args: []string{"/proc/self/cmdline", "-"}},
+	).WithStdin(secret))
+	if err != nil {
+		t.Fatalf("RunPipeline: %v", err)
+	}
+
+	cmdline, input, found := strings.Cut(result.Stdout, "-\x00")
+	if !found || input != secret {
+		t.Fatalf("stdout = %q, want the argument list followed by the secret", result.Stdout)
+	}
+	if strings.Contains(cmdline, "hunter2") {
+		t.Errorf("secret visible in the argument list %q", cmdline)
+	}
+
+	m := NewMockShellExecutor()
+	m.Responses["chpasswd"] = ExecuteResult{}
+	if _, err := m.RunPipeline(context.Background(), *NewPipeline(PipelineStage{Command: "chpasswd"}).WithStdin("root:" + secret)); err != nil {
+		t.Fatalf("mock RunPipeline: %v", err)
+	}
+	if calls := m.CallsTo("chpasswd"); len(calls) != 1 || calls[0].Stdin != "root:"+secret || len(calls[0].Args) != 0 {
+		t.Errorf("mock calls = %+v", calls)
+	}
+}
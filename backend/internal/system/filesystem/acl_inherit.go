@@ -0,0 +1,173 @@
+package filesystem
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// aclJobProgressInterval is how many paths are processed between job progress updates
+const aclJobProgressInterval = 50
+
+// SetInheritedACL sets entries on root, both as access and default ACL, and
+// sets them as default ACL on every subdirectory up to depth levels below
+// root (-1 = unlimited). Symlinks are never followed.
+func (a *ACLManager) SetInheritedACL(root string, entries []ACLEntry, depth int) error {
+	return a.setInheritedACL(root, entries, depth, nil)
+}
+
+// SetInheritedACLAsync runs SetInheritedACL in the background and returns the
+// ID of a models.ACLJob that tracks its progress
+func (a *ACLManager) SetInheritedACLAsync(root string, entries []ACLEntry, depth int) (string, error) {
+	if err := validateInheritArgs(root, entries, depth); err != nil {
+		return "", err
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+
+	id, err := generateJobID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	job := &models.ACLJob{
+		ID:     id,
+		Root:   root,
+		Depth:  depth,
+		Status: models.ACLJobStatusRunning,
+	}
+	if err := db.Create(job).Error; err != nil {
+		return "", fmt.Errorf("failed to create ACL job: %w", err)
+	}
+
+	go func() {
+		processed := 0
+		err := a.setInheritedACL(root, entries, depth, func(path string) {
+			processed++
+			if processed%aclJobProgressInterval == 0 {
+				db.Model(job).Updates(map[string]interface{}{
+					"paths_processed": processed,
+					"current_path":    path,
+				})
+			}
+		})
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"paths_processed": processed,
+			"current_path":    "",
+			"status":          models.ACLJobStatusCompleted,
+			"completed_at":    &now,
+		}
+		if err != nil {
+			updates["status"] = models.ACLJobStatusFailed
+			updates["error"] = err.Error()
+			logger.Error("ACL inheritance job failed", zap.String("job", id), zap.String("root", root), zap.Error(err))
+		} else {
+			logger.Info("ACL inheritance job completed", zap.String("job", id), zap.String("root", root), zap.Int("paths", processed))
+		}
+		if err := db.Model(job).Updates(updates).Error; err != nil {
+			logger.Error("Failed to update ACL job", zap.String("job", id), zap.Error(err))
+		}
+	}()
+
+	return id, nil
+}
+
+// GetACLJob returns an ACL inheritance job by ID
+func GetACLJob(id string) (*models.ACLJob, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var job models.ACLJob
+	if err := db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// setInheritedACL walks root and calls onPath after each updated path
+func (a *ACLManager) setInheritedACL(root string, entries []ACLEntry, depth int, onPath func(path string)) error {
+	if !a.enabled {
+		return fmt.Errorf("ACL support not available")
+	}
+	if err := validateInheritArgs(root, entries, depth); err != nil {
+		return err
+	}
+
+	root = filepath.Clean(root)
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Walk uses Lstat, so symlinks show up as symlinks and are never
+		// descended into; skip them so setfacl doesn't follow them either
+		if info.Mode()&os.ModeSymlink != 0 || !info.IsDir() {
+			return nil
+		}
+
+		if path == root {
+			if err := a.SetACL(path, entries); err != nil {
+				return err
+			}
+		} else if depth >= 0 {
+			rel, _ := filepath.Rel(root, path)
+			if strings.Count(rel, string(filepath.Separator))+1 > depth {
+				return filepath.SkipDir
+			}
+		}
+
+		if err := a.SetDefaultACL(path, entries); err != nil {
+			return err
+		}
+
+		logger.Info("Inherited ACL applied", zap.String("path", path))
+		if onPath != nil {
+			onPath(path)
+		}
+		return nil
+	})
+}
+
+// validateInheritArgs checks SetInheritedACL arguments before any work is done
+func validateInheritArgs(root string, entries []ACLEntry, depth int) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("no ACL entries provided")
+	}
+	if depth < -1 {
+		return fmt.Errorf("invalid depth %d (use -1 for unlimited)", depth)
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", root)
+	}
+	return nil
+}
+
+// generateJobID returns a random hex job ID
+func generateJobID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
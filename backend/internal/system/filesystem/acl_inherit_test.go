@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func TestSetInheritedACL(t *testing.T) {
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{"a/b/c", "d"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(outside, "secret"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "file.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "a", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []ACLEntry{{Type: "group", Name: "media", Permissions: "rwx"}}
+
+	tests := []struct {
+		name  string
+		depth int
+		want  []string
+	}{
+		{"root only", 0, []string{"."}},
+		{"one level", 1, []string{".", "a", "d"}},
+		{"unlimited", -1, []string{".", "a", "a/b", "a/b/c", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := executor.NewMockShellExecutor()
+			shell.ExpectCommand("setfacl")
+			manager, err := NewACLManager(shell)
+			if err != nil {
+				t.Fatalf("NewACLManager() error = %v", err)
+			}
+
+			if err := manager.SetInheritedACL(root, entries, tt.depth); err != nil {
+				t.Fatalf("SetInheritedACL() error = %v", err)
+			}
+
+			shell.AssertExpectations(t)
+			var access, defaults []string
+			for _, call := range shell.CallsTo("setfacl") {
+				path := call.Args[len(call.Args)-1]
+				if strings.HasPrefix(call.Args[1], "default:") {
+					defaults = append(defaults, path)
+				} else {
+					access = append(access, path)
+				}
+			}
+
+			if len(access) != 1 || access[0] != root {
+				t.Errorf("access ACL set on %v, want only root", access)
+			}
+
+			var got []string
+			for _, path := range defaults {
+				rel, err := filepath.Rel(root, path)
+				if err != nil || strings.HasPrefix(rel, "..") {
+					t.Fatalf("default ACL set outside root: %s", path)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("default ACL set on %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("default ACL set on %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
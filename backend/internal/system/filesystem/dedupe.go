@@ -0,0 +1,330 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+)
+
+// DedupeReport lists the duplicate files found by a scan
+type DedupeReport = models.DedupeReport
+
+// DuplicateGroup is a set of files with identical content
+type DuplicateGroup = models.DuplicateGroup
+
+// ScanOptions configures a duplicate file scan
+type ScanOptions struct {
+	MinSize        int64  `json:"minSize"`        // Ignore files smaller than this many bytes
+	Algorithm      string `json:"algorithm"`      // Hash reported for each group: "sha256" (default) or "md5"
+	LinkDuplicates bool   `json:"linkDuplicates"` // Replace duplicates with hardlinks to the first file of their group
+}
+
+// dupesSizeLine matches the group header printed by jdupes -S and fdupes -S
+var dupesSizeLine = regexp.MustCompile(`^(\d+) bytes? each:$`)
+
+// dupesProgress matches the percentage in jdupes and fdupes progress lines
+var dupesProgress = regexp.MustCompile(`(\d{1,3})%`)
+
+// DeduplicationScanner finds duplicate files with jdupes or fdupes
+type DeduplicationScanner struct {
+	tool string
+}
+
+// NewDeduplicationScanner creates a scanner using jdupes, or fdupes if
+// jdupes is not installed
+func NewDeduplicationScanner() (*DeduplicationScanner, error) {
+	for _, tool := range []string{"jdupes", "fdupes"} {
+		if sysutil.CommandExists(tool) {
+			return &DeduplicationScanner{tool: tool}, nil
+		}
+	}
+	return nil, fmt.Errorf("no duplicate file finder installed (install the 'jdupes' or 'fdupes' package)")
+}
+
+// Scan finds the duplicate files below root. Files that are already
+// hardlinked to each other are not reported as duplicates.
+func (d *DeduplicationScanner) Scan(root string, opts ScanOptions) (*DedupeReport, error) {
+	return d.scan(context.Background(), root, opts, nil)
+}
+
+// ScanAsync runs Scan in the background and returns the ID of a
+// models.DedupeJob that tracks its progress and keeps its report
+func (d *DeduplicationScanner) ScanAsync(root string, opts ScanOptions) (string, error) {
+	opts, err := validateScanArgs(root, opts)
+	if err != nil {
+		return "", err
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+
+	id, err := generateJobID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	job := &models.DedupeJob{
+		ID:             id,
+		Root:           filepath.Clean(root),
+		Algorithm:      opts.Algorithm,
+		MinSize:        opts.MinSize,
+		LinkDuplicates: opts.LinkDuplicates,
+		Tool:           d.tool,
+		Status:         models.DedupeJobStatusRunning,
+	}
+	if err := db.Create(job).Error; err != nil {
+		return "", fmt.Errorf("failed to create dedupe job: %w", err)
+	}
+
+	go func() {
+		lastProgress := 0
+		report, err := d.scan(context.Background(), root, opts, func(percent int) {
+			if percent != lastProgress {
+				lastProgress = percent
+				db.Model(job).Update("progress", percent)
+			}
+		})
+
+		now := time.Now()
+		job.CompletedAt = &now
+		if err != nil {
+			job.Status = models.DedupeJobStatusFailed
+			job.Error = err.Error()
+			logger.Error("Dedupe scan failed", zap.String("job", id), zap.String("root", root), zap.Error(err))
+		} else {
+			job.Status = models.DedupeJobStatusCompleted
+			job.Progress = 100
+			job.Report = report
+			logger.Info("Dedupe scan completed", zap.String("job", id), zap.String("root", root),
+				zap.Int("duplicates", report.TotalDuplicates), zap.Int64("wasted_bytes", report.WastedBytes))
+		}
+		if err := db.Select("status", "progress", "report", "error", "completed_at").Updates(job).Error; err != nil {
+			logger.Error("Failed to update dedupe job", zap.String("job", id), zap.Error(err))
+		}
+	}()
+
+	return id, nil
+}
+
+// GetDedupeJob returns a dedupe job by ID
+func GetDedupeJob(id string) (*models.DedupeJob, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var job models.DedupeJob
+	if err := db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// scan runs the duplicate finder and calls onProgress with the percentage it reports
+func (d *DeduplicationScanner) scan(ctx context.Context, root string, opts ScanOptions, onProgress func(percent int)) (*DedupeReport, error) {
+	opts, err := validateScanArgs(root, opts)
+	if err != nil {
+		return nil, err
+	}
+	root = filepath.Clean(root)
+
+	args := []string{"-r", "-S"}
+	if d.tool == "fdupes" {
+		args = append(args, "-n") // jdupes skips empty files by default
+	}
+	args = append(args, root)
+
+	output, err := sysutil.RunCommandWithProgress(ctx, func(line string) {
+		if onProgress == nil {
+			return
+		}
+		if m := dupesProgress.FindStringSubmatch(line); m != nil {
+			if percent, err := strconv.Atoi(m[1]); err == nil && percent <= 100 {
+				onProgress(percent)
+			}
+		}
+	}, d.tool, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := parseDupesOutput(output)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DedupeReport{Groups: []DuplicateGroup{}}
+	for _, group := range groups {
+		if group.Size < opts.MinSize {
+			continue
+		}
+
+		group.Hash, err = hashFile(group.Files[0], opts.Algorithm)
+		if err != nil {
+			logger.Warn("Failed to hash duplicate file", zap.String("path", group.Files[0]), zap.Error(err))
+			continue
+		}
+
+		if opts.LinkDuplicates {
+			report.LinkedFiles += linkDuplicates(group, opts.Algorithm)
+		}
+
+		report.Groups = append(report.Groups, group)
+		report.TotalDuplicates += len(group.Files) - 1
+		report.WastedBytes += int64(len(group.Files)-1) * group.Size
+	}
+	return report, nil
+}
+
+// validateScanArgs checks the scan arguments and fills in defaults
+func validateScanArgs(root string, opts ScanOptions) (ScanOptions, error) {
+	switch opts.Algorithm {
+	case "":
+		opts.Algorithm = "sha256"
+	case "sha256", "md5":
+	default:
+		return opts, fmt.Errorf("invalid algorithm %q (use sha256 or md5)", opts.Algorithm)
+	}
+	if opts.MinSize < 0 {
+		return opts, fmt.Errorf("minSize must not be negative")
+	}
+
+	if !filepath.IsAbs(root) {
+		return opts, fmt.Errorf("scan root must be an absolute path")
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return opts, fmt.Errorf("failed to stat %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return opts, fmt.Errorf("%s is not a directory", root)
+	}
+	return opts, nil
+}
+
+// parseDupesOutput parses the output of jdupes -S or fdupes -S: groups of
+// file names separated by blank lines, each preceded by "N bytes each:"
+func parseDupesOutput(output string) ([]DuplicateGroup, error) {
+	var groups []DuplicateGroup
+	var current *DuplicateGroup
+
+	flush := func() {
+		if current != nil && len(current.Files) > 1 {
+			groups = append(groups, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			flush()
+			continue
+		}
+		if m := dupesSizeLine.FindStringSubmatch(line); m != nil {
+			flush()
+			size, err := strconv.ParseInt(m[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid size line %q", line)
+			}
+			current = &DuplicateGroup{Size: size}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("unexpected line %q outside of a duplicate group", line)
+		}
+		current.Files = append(current.Files, line)
+	}
+	flush()
+
+	return groups, nil
+}
+
+// hashFile returns the hex hash of a file's content
+func hashFile(path, algorithm string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var h hash.Hash
+	if algorithm == "md5" {
+		h = md5.New()
+	} else {
+		h = sha256.New()
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// linkDuplicates replaces the files of a group with hardlinks to its first
+// file and returns how many were replaced. Files that changed since the
+// scan or live on another filesystem are left alone.
+func linkDuplicates(group DuplicateGroup, algorithm string) int {
+	original := group.Files[0]
+	originalInfo, err := os.Stat(original)
+	if err != nil {
+		return 0
+	}
+
+	linked := 0
+	for _, dup := range group.Files[1:] {
+		info, err := os.Lstat(dup)
+		if err != nil || !info.Mode().IsRegular() || os.SameFile(originalInfo, info) {
+			continue
+		}
+		if deviceOf(info) != deviceOf(originalInfo) {
+			logger.Debug("Not linking duplicate on another filesystem", zap.String("path", dup))
+			continue
+		}
+		if sum, err := hashFile(dup, algorithm); err != nil || sum != group.Hash {
+			logger.Warn("Duplicate changed since the scan, not linking it", zap.String("path", dup))
+			continue
+		}
+
+		// Link under a temporary name and rename it over the duplicate, so
+		// the duplicate is never missing
+		tmp := filepath.Join(filepath.Dir(dup), fmt.Sprintf(".%s.dedupe-%d", filepath.Base(dup), time.Now().UnixNano()))
+		if err := os.Link(original, tmp); err != nil {
+			logger.Warn("Failed to link duplicate", zap.String("path", dup), zap.Error(err))
+			continue
+		}
+		if err := os.Rename(tmp, dup); err != nil {
+			os.Remove(tmp)
+			logger.Warn("Failed to replace duplicate", zap.String("path", dup), zap.Error(err))
+			continue
+		}
+		linked++
+	}
+	return linked
+}
+
+// deviceOf returns the device a file is stored on
+func deviceOf(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev)
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// jdupes -r -S output with a group of three and a group of two files
+const jdupesOutput = `1048576 bytes each:
+/srv/media/photos/IMG_0001.jpg
+/srv/media/backup/IMG_0001.jpg
+/srv/media/backup/old/IMG_0001 (copy).jpg
+
+42 bytes each:
+/srv/media/notes.txt
+/srv/media/notes-2.txt
+
+`
+
+func TestParseDupesOutput(t *testing.T) {
+	groups, err := parseDupesOutput(jdupesOutput)
+	if err != nil {
+		t.Fatalf("parseDupesOutput() error = %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(groups), groups)
+	}
+	if groups[0].Size != 1048576 || len(groups[0].Files) != 3 || groups[0].Files[2] != "/srv/media/backup/old/IMG_0001 (copy).jpg" {
+		t.Errorf("group 0 = %+v", groups[0])
+	}
+	if groups[1].Size != 42 || len(groups[1].Files) != 2 {
+		t.Errorf("group 1 = %+v", groups[1])
+	}
+
+	if groups, err := parseDupesOutput(""); err != nil || len(groups) != 0 {
+		t.Errorf("parseDupesOutput(\"\") = %v, %v, want no groups", groups, err)
+	}
+	if _, err := parseDupesOutput("/srv/media/stray\n"); err == nil {
+		t.Error("parseDupesOutput() accepted a file name without a size line")
+	}
+}
+
+func TestLinkDuplicates(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	dir := t.TempDir()
+	original := filepath.Join(dir, "a.bin")
+	dup := filepath.Join(dir, "b.bin")
+	changed := filepath.Join(dir, "c.bin")
+	for _, path := range []string{original, dup} {
+		if err := os.WriteFile(path, []byte("same content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(changed, []byte("other content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := hashFile(original, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := DuplicateGroup{Files: []string{original, dup, changed}, Size: 12, Hash: hash}
+
+	if linked := linkDuplicates(group, "sha256"); linked != 1 {
+		t.Errorf("linkDuplicates() = %d, want 1", linked)
+	}
+
+	originalInfo, _ := os.Stat(original)
+	dupInfo, _ := os.Stat(dup)
+	changedInfo, _ := os.Stat(changed)
+	if !os.SameFile(originalInfo, dupInfo) {
+		t.Error("duplicate was not replaced by a hardlink")
+	}
+	if os.SameFile(originalInfo, changedInfo) {
+		t.Error("file with different content was linked")
+	}
+}
@@ -0,0 +1,10 @@
+package filesystem
+
+import "github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+
+func init() {
+	sysutil.RegisterComponents("filesystem",
+		sysutil.ComponentDefinition{Name: "chown", Command: "chown", Required: true},
+		sysutil.ComponentDefinition{Name: "chmod", Command: "chmod", Required: true},
+	)
+}
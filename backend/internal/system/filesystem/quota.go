@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
 )
@@ -14,6 +15,11 @@ import (
 type QuotaManager struct {
 	shell   executor.ShellExecutor
 	enabled bool
+
+	// XFS project quotas
+	projidFile   string
+	projectsFile string
+	projectMu    sync.Mutex
 }
 
 // QuotaType represents the type of quota (user or group)
@@ -63,8 +69,10 @@ func NewQuotaManager(shell executor.ShellExecutor) (*QuotaManager, error) {
 	}
 
 	return &QuotaManager{
-		shell:   shell,
-		enabled: true,
+		shell:        shell,
+		enabled:      true,
+		projidFile:   "/etc/projid",
+		projectsFile: "/etc/projects",
 	}, nil
 }
 
@@ -193,6 +201,23 @@ func (q *QuotaManager) ListUserQuotas(filesystem string) ([]QuotaInfo, error) {
 	return q.parseRepquotaOutput(result.Stdout, UserQuota, filesystem)
 }
 
+// GetReport returns the usage of the users with a block limit on a
+// filesystem
+func (q *QuotaManager) GetReport(filesystem string) ([]QuotaInfo, error) {
+	quotas, err := q.ListUserQuotas(filesystem)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]QuotaInfo, 0, len(quotas))
+	for _, quota := range quotas {
+		if quota.BlocksSoft > 0 || quota.BlocksHard > 0 {
+			report = append(report, quota)
+		}
+	}
+	return report, nil
+}
+
 // ListGroupQuotas lists all group quotas on a filesystem
 func (q *QuotaManager) ListGroupQuotas(filesystem string) ([]QuotaInfo, error) {
 	if !q.enabled {
@@ -0,0 +1,401 @@
+package filesystem
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+)
+
+// ErrProjectNotFound is returned for projects missing from /etc/projid or /etc/projects
+var ErrProjectNotFound = errors.New("project not found")
+
+// projectNameRegex matches valid project names; /etc/projid separates fields with colons
+var projectNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// XFSProject is a directory tree with an XFS project quota
+type XFSProject struct {
+	ID         uint32 `json:"id"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Filesystem string `json:"filesystem"` // Mount point of the XFS filesystem
+	SoftLimit  int64  `json:"soft_limit"` // Bytes, 0 = no limit
+	HardLimit  int64  `json:"hard_limit"` // Bytes, 0 = no limit
+}
+
+// ProjectUsage is the block usage of a project as reported by xfs_quota
+type ProjectUsage struct {
+	Name      string `json:"name"`
+	Used      int64  `json:"used"`       // Bytes
+	SoftLimit int64  `json:"soft_limit"` // Bytes, 0 = no limit
+	HardLimit int64  `json:"hard_limit"` // Bytes, 0 = no limit
+}
+
+// CreateProject assigns the next free project ID to the directory tree at
+// path, registers it in /etc/projid and /etc/projects and sets its limits
+func (q *QuotaManager) CreateProject(name, path string, softLimit, hardLimit int64) (*XFSProject, error) {
+	if !q.shell.CommandExists("xfs_quota") {
+		return nil, fmt.Errorf("xfs_quota not installed (install 'xfsprogs' package)")
+	}
+	if !projectNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid project name %q", name)
+	}
+	if err := validateLimits(softLimit, hardLimit); err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(path) || strings.ContainsAny(path, ":\n") {
+		return nil, fmt.Errorf("invalid project path %q", path)
+	}
+	path = filepath.Clean(path)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("project path %s is not a directory", path)
+	}
+
+	mountPoint, err := q.xfsMountPoint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	q.projectMu.Lock()
+	defer q.projectMu.Unlock()
+
+	names, err := readProjectFile(q.projidFile)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := readProjectFile(q.projectsFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := names[name]; exists {
+		return nil, fmt.Errorf("project %s already exists", name)
+	}
+
+	// Take the ID after the highest one in use in either file, so IDs
+	// removed from one file but not the other are never reused
+	var id uint32
+	for _, n := range names {
+		if v, err := strconv.ParseUint(n, 10, 32); err == nil && uint32(v) > id {
+			id = uint32(v)
+		}
+	}
+	for existing := range paths {
+		if v, err := strconv.ParseUint(existing, 10, 32); err == nil && uint32(v) > id {
+			id = uint32(v)
+		}
+	}
+	id++
+
+	project := &XFSProject{
+		ID:         id,
+		Name:       name,
+		Path:       path,
+		Filesystem: mountPoint,
+		SoftLimit:  softLimit,
+		HardLimit:  hardLimit,
+	}
+
+	if err := appendLine(q.projidFile, fmt.Sprintf("%s:%d", name, id)); err != nil {
+		return nil, err
+	}
+	if err := appendLine(q.projectsFile, fmt.Sprintf("%d:%s", id, path)); err != nil {
+		q.removeProjectEntries(name, id)
+		return nil, err
+	}
+
+	if err := q.runXFSQuota(mountPoint, "project -s "+name); err != nil {
+		q.removeProjectEntries(name, id)
+		return nil, fmt.Errorf("failed to set up project: %w", err)
+	}
+	if err := q.setProjectLimits(project); err != nil {
+		q.runXFSQuota(mountPoint, "project -C "+name)
+		q.removeProjectEntries(name, id)
+		return nil, err
+	}
+
+	logger.Info("XFS project quota created",
+		zap.String("project", name),
+		zap.Uint32("id", id),
+		zap.String("path", path))
+	return project, nil
+}
+
+// ListProjects returns the projects registered in /etc/projid and /etc/projects
+func (q *QuotaManager) ListProjects() ([]XFSProject, error) {
+	q.projectMu.Lock()
+	defer q.projectMu.Unlock()
+
+	names, err := readProjectFile(q.projidFile)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := readProjectFile(q.projectsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []XFSProject{}
+	for name, id := range names {
+		path, ok := paths[id]
+		if !ok {
+			continue
+		}
+		v, _ := strconv.ParseUint(id, 10, 32)
+		projects = append(projects, XFSProject{ID: uint32(v), Name: name, Path: path})
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	return projects, nil
+}
+
+// GetProject returns a project with its current limits
+func (q *QuotaManager) GetProject(name string) (*XFSProject, error) {
+	project, err := q.findProject(name)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := q.projectUsage(project)
+	if err != nil {
+		return nil, err
+	}
+	project.SoftLimit = usage.SoftLimit
+	project.HardLimit = usage.HardLimit
+	return project, nil
+}
+
+// GetProjectUsage returns the block usage and limits of a project
+func (q *QuotaManager) GetProjectUsage(name string) (*ProjectUsage, error) {
+	project, err := q.findProject(name)
+	if err != nil {
+		return nil, err
+	}
+	return q.projectUsage(project)
+}
+
+// SetProjectLimits replaces the limits of a project
+func (q *QuotaManager) SetProjectLimits(name string, softLimit, hardLimit int64) (*XFSProject, error) {
+	if err := validateLimits(softLimit, hardLimit); err != nil {
+		return nil, err
+	}
+	project, err := q.findProject(name)
+	if err != nil {
+		return nil, err
+	}
+
+	project.SoftLimit = softLimit
+	project.HardLimit = hardLimit
+	if err := q.setProjectLimits(project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// DeleteProject removes the limits of a project, clears the project ID from
+// its directory tree and unregisters it
+func (q *QuotaManager) DeleteProject(name string) error {
+	project, err := q.findProject(name)
+	if err != nil {
+		return err
+	}
+
+	project.SoftLimit, project.HardLimit = 0, 0
+	if err := q.setProjectLimits(project); err != nil {
+		return err
+	}
+	if err := q.runXFSQuota(project.Filesystem, "project -C "+name); err != nil {
+		return fmt.Errorf("failed to clear project: %w", err)
+	}
+
+	q.projectMu.Lock()
+	defer q.projectMu.Unlock()
+	if err := q.removeProjectEntries(name, project.ID); err != nil {
+		return err
+	}
+
+	logger.Info("XFS project quota deleted", zap.String("project", name))
+	return nil
+}
+
+// findProject looks up a registered project and the mount point of its path
+func (q *QuotaManager) findProject(name string) (*XFSProject, error) {
+	projects, err := q.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+	for i := range projects {
+		if projects[i].Name != name {
+			continue
+		}
+		project := &projects[i]
+		if project.Filesystem, err = q.xfsMountPoint(project.Path); err != nil {
+			return nil, err
+		}
+		return project, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+}
+
+func (q *QuotaManager) projectUsage(project *XFSProject) (*ProjectUsage, error) {
+	result, err := q.shell.Execute("xfs_quota", "-x", "-c", "report -p", project.Filesystem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project quota report: %w", err)
+	}
+
+	usages := parseProjectReport(result.Stdout)
+	for _, usage := range usages {
+		if usage.Name == project.Name || usage.Name == fmt.Sprintf("#%d", project.ID) {
+			usage.Name = project.Name
+			return &usage, nil
+		}
+	}
+	// Projects without blocks or limits are left out of the report
+	return &ProjectUsage{Name: project.Name}, nil
+}
+
+func (q *QuotaManager) setProjectLimits(project *XFSProject) error {
+	cmd := fmt.Sprintf("limit -p bsoft=%d bhard=%d %s", project.SoftLimit, project.HardLimit, project.Name)
+	if err := q.runXFSQuota(project.Filesystem, cmd); err != nil {
+		return fmt.Errorf("failed to set project limits: %w", err)
+	}
+	return nil
+}
+
+func (q *QuotaManager) runXFSQuota(mountPoint, cmd string) error {
+	result, err := q.shell.Execute("xfs_quota", "-x", "-c", cmd, mountPoint)
+	if err != nil {
+		if result != nil && result.Stderr != "" {
+			return fmt.Errorf("%s: %w", strings.TrimSpace(result.Stderr), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// xfsMountPoint returns the mount point of the XFS filesystem containing path
+func (q *QuotaManager) xfsMountPoint(path string) (string, error) {
+	result, err := q.shell.Execute("findmnt", "-n", "-o", "TARGET,FSTYPE", "--target", path)
+	if err != nil {
+		return "", fmt.Errorf("failed to find filesystem of %s: %w", path, err)
+	}
+	fields := strings.Fields(result.Stdout)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("failed to find filesystem of %s", path)
+	}
+	if fields[1] != "xfs" {
+		return "", fmt.Errorf("%s is on a %s filesystem; project quotas require XFS", path, fields[1])
+	}
+	return fields[0], nil
+}
+
+// removeProjectEntries removes a project from /etc/projid and /etc/projects
+func (q *QuotaManager) removeProjectEntries(name string, id uint32) error {
+	if err := removeLines(q.projidFile, name+":"); err != nil {
+		return err
+	}
+	return removeLines(q.projectsFile, fmt.Sprintf("%d:", id))
+}
+
+// parseProjectReport parses the output of xfs_quota -x -c "report -p", which
+// reports blocks in KiB
+func parseProjectReport(output string) []ProjectUsage {
+	var usages []ProjectUsage
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		used, err1 := strconv.ParseInt(fields[1], 10, 64)
+		soft, err2 := strconv.ParseInt(fields[2], 10, 64)
+		hard, err3 := strconv.ParseInt(fields[3], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		usages = append(usages, ProjectUsage{
+			Name:      fields[0],
+			Used:      used * 1024,
+			SoftLimit: soft * 1024,
+			HardLimit: hard * 1024,
+		})
+	}
+	return usages
+}
+
+func validateLimits(softLimit, hardLimit int64) error {
+	if softLimit < 0 || hardLimit < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if hardLimit > 0 && softLimit > hardLimit {
+		return fmt.Errorf("soft limit must not exceed hard limit")
+	}
+	return nil
+}
+
+// readProjectFile reads a colon separated /etc/projid or /etc/projects file
+// into a map from the first field to the second
+func readProjectFile(path string) (map[string]string, error) {
+	entries := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			entries[key] = value
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func appendLine(path, line string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// removeLines removes the lines starting with prefix from a file
+func removeLines(path, prefix string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var kept []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line != "" && !strings.HasPrefix(line, prefix) {
+			kept = append(kept, line)
+		}
+	}
+	if err := sysutil.AtomicWriteFile(path, []byte(strings.Join(kept, "")), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
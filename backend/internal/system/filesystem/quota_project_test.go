@@ -0,0 +1,138 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+const xfsProjectReport = `Project quota on /data (/dev/sdb1)
+                               Blocks
+Project ID       Used       Soft       Hard    Warn/Grace
+---------- --------------------------------------------------
+#0                  0          0          0     00 [--------]
+media          524288    1048576    2097152     00 [--------]
+#7                 12          0          0     00 [--------]
+
+`
+
+func newTestQuotaManager(t *testing.T) (*QuotaManager, *executor.MockShellExecutor) {
+	t.Helper()
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatalf("InitLogger() error = %v", err)
+	}
+
+	shell := executor.NewMockShellExecutor()
+	q, err := NewQuotaManager(shell)
+	if err != nil {
+		t.Fatalf("NewQuotaManager() error = %v", err)
+	}
+	dir := t.TempDir()
+	q.projidFile = filepath.Join(dir, "projid")
+	q.projectsFile = filepath.Join(dir, "projects")
+
+	shell.Handler = func(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+		switch {
+		case call.Command == "findmnt":
+			return &executor.CommandResult{Stdout: "/data xfs\n", Success: true}, nil
+		case call.Command == "xfs_quota" && call.Args[2] == "report -p":
+			return &executor.CommandResult{Stdout: xfsProjectReport, Success: true}, nil
+		}
+		return &executor.CommandResult{Success: true}, nil
+	}
+	return q, shell
+}
+
+func TestCreateProjectAssignsFreeIDs(t *testing.T) {
+	q, shell := newTestQuotaManager(t)
+
+	// ID 7 is only left in /etc/projects and must not be reused
+	os.WriteFile(q.projidFile, []byte("# projects\nbackup:3\n"), 0644)
+	os.WriteFile(q.projectsFile, []byte("3:/data/backup\n7:/data/old\n"), 0644)
+
+	dir := t.TempDir()
+	var ids []uint32
+	for _, name := range []string{"media", "docs"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		project, err := q.CreateProject(name, filepath.Join(dir, name), 1<<30, 2<<30)
+		if err != nil {
+			t.Fatalf("CreateProject(%s) error = %v", name, err)
+		}
+		ids = append(ids, project.ID)
+	}
+	if ids[0] != 8 || ids[1] != 9 {
+		t.Errorf("ids = %v, want [8 9]", ids)
+	}
+
+	projid, _ := os.ReadFile(q.projidFile)
+	if !strings.HasSuffix(string(projid), "backup:3\nmedia:8\ndocs:9\n") {
+		t.Errorf("projid = %q", projid)
+	}
+	projects, _ := os.ReadFile(q.projectsFile)
+	if !strings.Contains(string(projects), "\n8:"+filepath.Join(dir, "media")+"\n") {
+		t.Errorf("projects = %q", projects)
+	}
+
+	var setup, limits int
+	for _, call := range shell.CallsTo("xfs_quota") {
+		switch call.Args[2] {
+		case "project -s media", "project -s docs":
+			setup++
+		case "limit -p bsoft=1073741824 bhard=2147483648 media", "limit -p bsoft=1073741824 bhard=2147483648 docs":
+			limits++
+		}
+	}
+	if setup != 2 || limits != 2 {
+		t.Errorf("xfs_quota calls = %v", shell.CallsTo("xfs_quota"))
+	}
+
+	if _, err := q.CreateProject("media", filepath.Join(dir, "media"), 0, 0); err == nil {
+		t.Error("CreateProject() accepted a duplicate name")
+	}
+	if _, err := q.CreateProject("bad:name", dir, 0, 0); err == nil {
+		t.Error("CreateProject() accepted an invalid name")
+	}
+}
+
+func TestCreateProjectRollsBackOnFailure(t *testing.T) {
+	q, shell := newTestQuotaManager(t)
+	shell.ExpectCommand("xfs_quota", "-x", "-c", "project -s media", "/data").Returns("", "xfs_quota: cannot setup path", 1)
+
+	if _, err := q.CreateProject("media", t.TempDir(), 0, 0); err == nil {
+		t.Fatal("CreateProject() error = nil")
+	}
+	if projects, _ := q.ListProjects(); len(projects) != 0 {
+		t.Errorf("projects after failure = %+v", projects)
+	}
+}
+
+func TestGetProjectUsage(t *testing.T) {
+	q, _ := newTestQuotaManager(t)
+	os.WriteFile(q.projidFile, []byte("media:1\nlegacy:7\n"), 0644)
+	os.WriteFile(q.projectsFile, []byte("1:/data/media\n7:/data/legacy\n"), 0644)
+
+	usage, err := q.GetProjectUsage("media")
+	if err != nil {
+		t.Fatalf("GetProjectUsage() error = %v", err)
+	}
+	if usage.Used != 512<<20 || usage.SoftLimit != 1<<30 || usage.HardLimit != 2<<30 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	// Projects whose name xfs_quota could not resolve are reported by ID
+	usage, err = q.GetProjectUsage("legacy")
+	if err != nil || usage.Name != "legacy" || usage.Used != 12<<10 {
+		t.Errorf("legacy usage = %+v, %v", usage, err)
+	}
+
+	if _, err := q.GetProjectUsage("missing"); !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("GetProjectUsage(missing) error = %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+// Package cluster combines DRBD, Pacemaker and Keepalived into a single view
+// of the High Availability cluster
+package cluster
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Overall health of the cluster
+const (
+	HealthHealthy     = "healthy"     // All nodes online, resources running and data replicated
+	HealthDegraded    = "degraded"    // The cluster works but has lost redundancy
+	HealthCritical    = "critical"    // Services or data are at risk
+	HealthUnavailable = "unavailable" // No HA subsystem is available
+)
+
+// NodeStatus is the status of a cluster node
+type NodeStatus struct {
+	Name      string   `json:"name"`
+	Online    bool     `json:"online"`
+	Local     bool     `json:"local"`     // The node this server runs on
+	Resources []string `json:"resources"` // IDs of the resources running on the node
+}
+
+// ResourceStatus is the status of a Pacemaker resource
+type ResourceStatus struct {
+	ID     string `json:"id"`
+	Agent  string `json:"agent"`
+	Node   string `json:"node"`
+	Active bool   `json:"active"`
+	Failed bool   `json:"failed"`
+}
+
+// VIPStatus is the status of a Keepalived virtual IP on this node
+type VIPStatus = ha.VIPStatus
+
+// DRBDStatus is the replication status of all DRBD resources
+type DRBDStatus struct {
+	Healthy   bool            `json:"healthy"` // All resources connected and up to date on both nodes
+	Resources []ha.DRBDStatus `json:"resources"`
+}
+
+// ClusterStatus is the combined status of the HA subsystems
+type ClusterStatus struct {
+	OverallHealth     string           `json:"overall_health"` // healthy, degraded, critical, unavailable
+	Issues            []string         `json:"issues"`         // Reasons the cluster is not healthy
+	Name              string           `json:"name"`
+	Quorum            bool             `json:"quorum"`
+	StonithEnabled    bool             `json:"stonith_enabled"`
+	Nodes             []NodeStatus     `json:"nodes"`
+	Resources         []ResourceStatus `json:"resources"`
+	VirtualIPs        []VIPStatus      `json:"virtual_ips"`
+	ReplicationStatus DRBDStatus       `json:"replication_status"`
+}
+
+// ClusterManager coordinates the HA subsystems. Any of them may be nil or
+// disabled; the status then only covers the available ones.
+type ClusterManager struct {
+	DRBD       *ha.DRBDManager
+	Pacemaker  *ha.PacemakerManager
+	Keepalived *ha.KeepalivedManager
+
+	hostname func() (string, error)
+}
+
+// NewClusterManager creates a cluster manager from the HA subsystem managers
+func NewClusterManager(drbd *ha.DRBDManager, pacemaker *ha.PacemakerManager, keepalived *ha.KeepalivedManager) *ClusterManager {
+	return &ClusterManager{
+		DRBD:       drbd,
+		Pacemaker:  pacemaker,
+		Keepalived: keepalived,
+		hostname:   os.Hostname,
+	}
+}
+
+// IsEnabled returns whether any HA subsystem is available
+func (cm *ClusterManager) IsEnabled() bool {
+	return cm.drbdEnabled() || cm.pacemakerEnabled() || cm.keepalivedEnabled()
+}
+
+func (cm *ClusterManager) drbdEnabled() bool {
+	return cm.DRBD != nil && cm.DRBD.IsEnabled()
+}
+
+func (cm *ClusterManager) pacemakerEnabled() bool {
+	return cm.Pacemaker != nil && cm.Pacemaker.IsEnabled()
+}
+
+func (cm *ClusterManager) keepalivedEnabled() bool {
+	return cm.Keepalived != nil && cm.Keepalived.IsEnabled()
+}
+
+// GetClusterStatus collects the status of all available subsystems and rates
+// the overall health of the cluster. A subsystem that fails to report is
+// listed as an issue instead of failing the whole status.
+func (cm *ClusterManager) GetClusterStatus() (*ClusterStatus, error) {
+	if !cm.IsEnabled() {
+		return nil, fmt.Errorf("no HA subsystem is enabled")
+	}
+
+	status := &ClusterStatus{
+		Issues:     []string{},
+		Nodes:      []NodeStatus{},
+		Resources:  []ResourceStatus{},
+		VirtualIPs: []VIPStatus{},
+		ReplicationStatus: DRBDStatus{
+			Healthy:   true,
+			Resources: []ha.DRBDStatus{},
+		},
+	}
+	var pcs *ha.ClusterStatus
+	var errs []string
+
+	if cm.pacemakerEnabled() {
+		var err error
+		if pcs, err = cm.Pacemaker.GetClusterStatus(); err != nil {
+			errs = append(errs, fmt.Sprintf("Pacemaker status unavailable: %v", err))
+		} else {
+			localNode, _ := cm.hostname()
+			status.Name = pcs.Name
+			status.Quorum = pcs.Quorum
+			status.StonithEnabled = pcs.StonithEnabled
+			for _, node := range pcs.Nodes {
+				ns := NodeStatus{Name: node.Name, Online: node.Online, Local: node.Name == localNode, Resources: []string{}}
+				for _, res := range pcs.Resources {
+					if res.Active && res.Node == node.Name {
+						ns.Resources = append(ns.Resources, res.ID)
+					}
+				}
+				status.Nodes = append(status.Nodes, ns)
+			}
+			for _, res := range pcs.Resources {
+				status.Resources = append(status.Resources, ResourceStatus{
+					ID:     res.ID,
+					Agent:  res.Type + ":" + res.Agent,
+					Node:   res.Node,
+					Active: res.Active,
+					Failed: res.Failed,
+				})
+			}
+		}
+	}
+
+	if cm.drbdEnabled() {
+		names, err := cm.DRBD.ListResources()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("DRBD status unavailable: %v", err))
+		}
+		for _, name := range names {
+			res, err := cm.DRBD.GetResourceStatus(name)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("DRBD resource %s status unavailable: %v", name, err))
+				continue
+			}
+			status.ReplicationStatus.Resources = append(status.ReplicationStatus.Resources, *res)
+		}
+	}
+
+	if cm.keepalivedEnabled() {
+		vips, err := cm.Keepalived.ListVIPs()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("Keepalived status unavailable: %v", err))
+		} else {
+			status.VirtualIPs = append(status.VirtualIPs, vips...)
+		}
+	}
+
+	status.OverallHealth, status.Issues = evaluateHealth(status, pcs != nil, errs)
+	status.ReplicationStatus.Healthy = replicationHealthy(status.ReplicationStatus.Resources)
+	return status, nil
+}
+
+// evaluateHealth rates the cluster. Lost quorum, failed resources and DRBD
+// resources without usable local data are critical; offline nodes, stopped
+// resources, interrupted replication, faulted VIPs and unreachable
+// subsystems are degraded.
+func evaluateHealth(status *ClusterStatus, havePacemaker bool, errs []string) (string, []string) {
+	var critical, degraded []string
+	degraded = append(degraded, errs...)
+
+	if havePacemaker {
+		if !status.Quorum {
+			critical = append(critical, "Cluster has no quorum")
+		}
+		for _, node := range status.Nodes {
+			if !node.Online {
+				degraded = append(degraded, fmt.Sprintf("Node %s is offline", node.Name))
+			}
+		}
+		for _, res := range status.Resources {
+			switch {
+			case res.Failed:
+				critical = append(critical, fmt.Sprintf("Resource %s has failed", res.ID))
+			case !res.Active:
+				degraded = append(degraded, fmt.Sprintf("Resource %s is not running", res.ID))
+			}
+		}
+	}
+
+	for _, res := range status.ReplicationStatus.Resources {
+		switch {
+		case res.DiskState != "UpToDate" && !res.Resyncing:
+			critical = append(critical, fmt.Sprintf("DRBD resource %s disk is %s", res.Name, res.DiskState))
+		case res.ConnectionState != "Connected":
+			degraded = append(degraded, fmt.Sprintf("DRBD resource %s is %s", res.Name, res.ConnectionState))
+		case res.Resyncing || res.PeerDiskState != "UpToDate":
+			degraded = append(degraded, fmt.Sprintf("DRBD resource %s peer disk is %s", res.Name, res.PeerDiskState))
+		}
+	}
+
+	for _, vip := range status.VirtualIPs {
+		if vip.State == "FAULT" {
+			degraded = append(degraded, fmt.Sprintf("Virtual IP %s is in FAULT state", vip.VirtualIP))
+		}
+	}
+
+	issues := append(critical, degraded...)
+	switch {
+	case len(critical) > 0:
+		return HealthCritical, issues
+	case len(degraded) > 0:
+		return HealthDegraded, issues
+	default:
+		return HealthHealthy, []string{}
+	}
+}
+
+func replicationHealthy(resources []ha.DRBDStatus) bool {
+	for _, res := range resources {
+		if res.ConnectionState != "Connected" || res.DiskState != "UpToDate" || res.PeerDiskState != "UpToDate" {
+			return false
+		}
+	}
+	return true
+}
+
+// PerformFailover gracefully moves all services to targetNode. It migrates
+// the Pacemaker resources, which also promotes the DRBD resources they
+// manage, moves the Keepalived VIPs by adjusting this node's priority, and
+// fences the old primary so it cannot take the services back. The local
+// node is put in standby instead of being fenced, since fencing would cut
+// off the failover in progress, as is any node while STONITH is disabled.
+func (cm *ClusterManager) PerformFailover(targetNode string) error {
+	if !cm.pacemakerEnabled() {
+		return fmt.Errorf("Pacemaker is not enabled")
+	}
+
+	pcs, err := cm.Pacemaker.GetClusterStatus()
+	if err != nil {
+		return err
+	}
+	if !pcs.Quorum {
+		return fmt.Errorf("cluster has no quorum")
+	}
+
+	var target *ha.ClusterNode
+	for i := range pcs.Nodes {
+		if pcs.Nodes[i].Name == targetNode {
+			target = &pcs.Nodes[i]
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("node %s is not a cluster member", targetNode)
+	}
+	if !target.Online {
+		return fmt.Errorf("node %s is offline", targetNode)
+	}
+
+	// The old primaries are the nodes currently running resources
+	var oldPrimaries []string
+	seen := map[string]bool{}
+	for _, res := range pcs.Resources {
+		if !res.Active || res.Node == "" || res.Node == targetNode {
+			continue
+		}
+		if err := cm.Pacemaker.MoveResource(res.ID, targetNode); err != nil {
+			return fmt.Errorf("failed to migrate resource %s: %w", res.ID, err)
+		}
+		if !seen[res.Node] {
+			seen[res.Node] = true
+			oldPrimaries = append(oldPrimaries, res.Node)
+		}
+	}
+
+	localNode, err := cm.hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine local node: %w", err)
+	}
+
+	if err := cm.moveVIPs(localNode == targetNode); err != nil {
+		return err
+	}
+
+	for _, node := range oldPrimaries {
+		if pcs.StonithEnabled && node != localNode {
+			err = cm.Pacemaker.FenceNode(node)
+		} else {
+			err = cm.Pacemaker.StandbyNode(node)
+		}
+		if err != nil {
+			return fmt.Errorf("resources migrated, but failed to isolate old primary %s: %w", node, err)
+		}
+	}
+
+	logger.Info("HA failover completed",
+		zap.String("target", targetNode),
+		zap.Strings("previousPrimaries", oldPrimaries))
+	return nil
+}
+
+// moveVIPs promotes this node's VIPs when it becomes the primary and demotes
+// them when it holds them now. Keepalived priorities apply to the whole
+// configuration of the node, so one VIP is changed for all of them.
+func (cm *ClusterManager) moveVIPs(promote bool) error {
+	if !cm.keepalivedEnabled() {
+		return nil
+	}
+
+	vips, err := cm.Keepalived.ListVIPs()
+	if err != nil {
+		return fmt.Errorf("failed to list virtual IPs: %w", err)
+	}
+	for _, vip := range vips {
+		switch {
+		case promote && !vip.IsMaster:
+			if err := cm.Keepalived.PromoteToMaster(vip.ID); err != nil {
+				return fmt.Errorf("failed to promote virtual IPs: %w", err)
+			}
+			return nil
+		case !promote && vip.IsMaster:
+			if err := cm.Keepalived.DemoteToBackup(vip.ID); err != nil {
+				return fmt.Errorf("failed to demote virtual IPs: %w", err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/ha"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func newTestClusterManager(t *testing.T, stonith bool) (*ClusterManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	shell.Responses["which"] = executor.ExecuteResult{Stdout: "/usr/sbin/pcs"}
+	shell.ExpectCommand("sudo", "pcs", "cluster", "status").Returns("Cluster name: nas", "", 0)
+	shell.ExpectCommand("sudo", "pcs", "quorum", "status").Returns("Quorate: Yes", "", 0)
+	shell.ExpectCommand("sudo", "pcs", "status", "nodes").Returns("Online:\nnas1\nnas2", "", 0)
+	shell.ExpectCommand("sudo", "pcs", "status", "resources").Returns(
+		"vip (ocf:heartbeat:IPaddr2): Started nas1\nfs (ocf:heartbeat:Filesystem): Started nas1", "", 0)
+	shell.ExpectCommand("sudo", "pcs", "property", "show", "maintenance-mode").Returns("maintenance-mode: false", "", 0)
+	stonithOutput := "stonith-enabled: false"
+	if stonith {
+		stonithOutput = "stonith-enabled: true"
+	}
+	shell.ExpectCommand("sudo", "pcs", "property", "show", "stonith-enabled").Returns(stonithOutput, "", 0)
+	shell.ExpectCommand("sudo").Returns("", "", 0)
+
+	pacemaker, err := ha.NewPacemakerManager(shell)
+	if err != nil {
+		t.Fatalf("NewPacemakerManager: %v", err)
+	}
+	cm := NewClusterManager(nil, pacemaker, nil)
+	cm.hostname = func() (string, error) { return "nas2", nil }
+	return cm, shell
+}
+
+func pcsCalls(shell *executor.MockShellExecutor, subcommand string) []string {
+	var calls []string
+	for _, call := range shell.CallsTo("sudo") {
+		if len(call.Args) > 2 && call.Args[1] == subcommand {
+			calls = append(calls, strings.Join(call.Args[1:], " "))
+		}
+	}
+	return calls
+}
+
+func TestPerformFailover(t *testing.T) {
+	cm, shell := newTestClusterManager(t, true)
+
+	if err := cm.PerformFailover("nas2"); err != nil {
+		t.Fatalf("PerformFailover: %v", err)
+	}
+
+	moves := pcsCalls(shell, "resource")
+	if len(moves) != 2 || moves[0] != "resource move vip nas2" || moves[1] != "resource move fs nas2" {
+		t.Errorf("resource calls = %v", moves)
+	}
+	if fences := pcsCalls(shell, "stonith"); len(fences) != 1 || fences[0] != "stonith fence nas1" {
+		t.Errorf("stonith calls = %v", fences)
+	}
+}
+
+func TestPerformFailoverStandbyWithoutStonith(t *testing.T) {
+	cm, shell := newTestClusterManager(t, false)
+
+	if err := cm.PerformFailover("nas2"); err != nil {
+		t.Fatalf("PerformFailover: %v", err)
+	}
+	if len(pcsCalls(shell, "stonith")) != 0 {
+		t.Error("fenced a node with STONITH disabled")
+	}
+	if standby := pcsCalls(shell, "node"); len(standby) != 1 || standby[0] != "node standby nas1" {
+		t.Errorf("node calls = %v", standby)
+	}
+}
+
+func TestPerformFailoverUnknownNode(t *testing.T) {
+	cm, shell := newTestClusterManager(t, true)
+
+	if err := cm.PerformFailover("nas3"); err == nil {
+		t.Fatal("expected failover to an unknown node to fail")
+	}
+	if len(pcsCalls(shell, "resource")) != 0 {
+		t.Error("migrated resources to an unknown node")
+	}
+}
+
+func TestEvaluateHealth(t *testing.T) {
+	healthy := func() *ClusterStatus {
+		return &ClusterStatus{
+			Quorum:    true,
+			Nodes:     []NodeStatus{{Name: "nas1", Online: true}, {Name: "nas2", Online: true}},
+			Resources: []ResourceStatus{{ID: "vip", Node: "nas1", Active: true}},
+			ReplicationStatus: DRBDStatus{Resources: []ha.DRBDStatus{
+				{Name: "r0", ConnectionState: "Connected", DiskState: "UpToDate", PeerDiskState: "UpToDate"},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *ClusterStatus)
+		want   string
+	}{
+		{"healthy", func(s *ClusterStatus) {}, HealthHealthy},
+		{"node offline", func(s *ClusterStatus) { s.Nodes[1].Online = false }, HealthDegraded},
+		{"replication disconnected", func(s *ClusterStatus) {
+			s.ReplicationStatus.Resources[0].ConnectionState = "StandAlone"
+			s.ReplicationStatus.Resources[0].PeerDiskState = "DUnknown"
+		}, HealthDegraded},
+		{"no quorum", func(s *ClusterStatus) { s.Quorum = false }, HealthCritical},
+		{"resource failed", func(s *ClusterStatus) { s.Resources[0].Failed = true }, HealthCritical},
+		{"local disk inconsistent", func(s *ClusterStatus) { s.ReplicationStatus.Resources[0].DiskState = "Inconsistent" }, HealthCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := healthy()
+			tt.modify(status)
+			got, issues := evaluateHealth(status, true, nil)
+			if got != tt.want {
+				t.Errorf("health = %s (%v), want %s", got, issues, tt.want)
+			}
+			if (got == HealthHealthy) != (len(issues) == 0) {
+				t.Errorf("issues = %v for health %s", issues, got)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package ha
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -108,14 +109,12 @@ func (dm *DRBDManager) CreateResource(resource DRBDResource) error {
 
 	// Write config to /etc/drbd.d/
 	configPath := fmt.Sprintf("/etc/drbd.d/%s.res", resource.Name)
-	writeCmd := fmt.Sprintf("echo '%s' | sudo tee %s", config, configPath)
-	result, err := dm.shell.Execute("sh", "-c", writeCmd)
-	if err != nil {
-		return fmt.Errorf("failed to write DRBD config: %s: %w", result.Stderr, err)
+	if err := writeConfigFile(dm.shell, configPath, config); err != nil {
+		return fmt.Errorf("failed to write DRBD config: %w", err)
 	}
 
 	// Create metadata
-	result, err = dm.shell.Execute("sudo", "drbdadm", "create-md", resource.Name)
+	result, err := dm.shell.Execute("sudo", "drbdadm", "create-md", resource.Name)
 	if err != nil {
 		logger.Error("Failed to create DRBD metadata", zap.Error(err), zap.String("stderr", result.Stderr))
 		return fmt.Errorf("failed to create DRBD metadata: %s: %w", result.Stderr, err)
@@ -363,3 +362,23 @@ func (dm *DRBDManager) VerifyData(name string) error {
 	logger.Info("DRBD data verification started", zap.String("name", name))
 	return nil
 }
+
+// writeConfigFile writes content to a root-owned file through sudo tee. The
+// content is passed on stdin, not through a shell, so it is written verbatim.
+func writeConfigFile(shell executor.ShellExecutor, path, content string) error {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	write := executor.NewPipeline(executor.PipelineStage{Command: "sudo", Args: []string{"tee", path}}).
+		WithStdin(content)
+	result, err := shell.RunPipeline(context.Background(), *write)
+	if err != nil {
+		// tee echoes the content to stdout, so only stderr is reported
+		stderr := ""
+		if result != nil {
+			stderr = strings.TrimSpace(result.Stderr)
+		}
+		return fmt.Errorf("%s: %w", stderr, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package ha
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+)
+
+func TestWriteConfigFileWritesContentVerbatim(t *testing.T) {
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("sudo", "tee", "/etc/keepalived/keepalived.conf").Returns("", "", 0).Times(1)
+
+	// echo would turn \n into a newline and a shell would expand $PASS
+	content := `auth_pass "a\nb$PASS"`
+	if err := writeConfigFile(shell, "/etc/keepalived/keepalived.conf", content); err != nil {
+		t.Fatalf("writeConfigFile: %v", err)
+	}
+	shell.AssertExpectations(t)
+
+	if got := shell.CallsTo("sudo")[0].Stdin; got != content+"\n" {
+		t.Errorf("written content = %q, want %q", got, content+"\n")
+	}
+	if calls := shell.CallsTo("sh"); len(calls) != 0 {
+		t.Errorf("content went through a shell: %v", calls)
+	}
+}
+
+func TestWriteConfigFileReportsStderrOnly(t *testing.T) {
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("sudo", "tee", "/etc/drbd.d/r0.res").Returns("resource r0 {}", "tee: permission denied", 1)
+
+	err := writeConfigFile(shell, "/etc/drbd.d/r0.res", "resource r0 {}")
+	if err == nil {
+		t.Fatal("writeConfigFile succeeded although tee failed")
+	}
+	if !strings.Contains(err.Error(), "permission denied") || strings.Contains(err.Error(), "resource r0") {
+		t.Errorf("error = %q, want tee's stderr without the content", err)
+	}
+}
@@ -0,0 +1,233 @@
+package ha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Fencing methods
+const (
+	FenceMethodIPMI   = "ipmi"    // Power off through the node's BMC with ipmitool
+	FenceMethodAPCPDU = "apc-pdu" // Switch off the node's outlet with fence_apc
+	FenceMethodAWSEC2 = "aws-ec2" // Stop the node's instance with fence_aws
+)
+
+// fenceTimeout bounds a fencing command; BMCs and PDUs can be slow to answer
+const fenceTimeout = 60 * time.Second
+
+// ErrNoFencingConfigured is returned for nodes without a fencing configuration
+var ErrNoFencingConfigured = errors.New("no fencing configured for node")
+
+// fenceRequiredOptions are the config keys each method needs
+var fenceRequiredOptions = map[string][]string{
+	FenceMethodIPMI:   {"address", "username", "password"},
+	FenceMethodAPCPDU: {"address", "username", "password", "plug"},
+	FenceMethodAWSEC2: {"region", "instance_id"},
+}
+
+// fenceSecretOptions are config keys that are never returned by the API
+var fenceSecretOptions = map[string]bool{"password": true, "secret_key": true}
+
+// FencingAgent fences (powers off) a single cluster node. It is used for
+// STONITH to make sure a node that stopped responding cannot keep writing to
+// shared or replicated storage.
+type FencingAgent struct {
+	Method string            `json:"method"` // ipmi, apc-pdu, aws-ec2
+	Config map[string]string `json:"config"` // Method options, see fenceRequiredOptions
+
+	shell executor.ShellExecutor
+}
+
+// NewFencingAgent creates a fencing agent after validating its configuration
+func NewFencingAgent(shell executor.ShellExecutor, method string, config map[string]string) (*FencingAgent, error) {
+	required, ok := fenceRequiredOptions[method]
+	if !ok {
+		return nil, fmt.Errorf("unsupported fencing method %q", method)
+	}
+	for _, key := range required {
+		if config[key] == "" {
+			return nil, fmt.Errorf("fencing method %s requires %s", method, key)
+		}
+	}
+
+	return &FencingAgent{Method: method, Config: config, shell: shell}, nil
+}
+
+// Fence powers off the node and, where the method can report it, checks
+// that the node is off
+func (fa *FencingAgent) Fence(nodeID string) error {
+	command, args := fa.command("off")
+	result, err := fa.shell.ExecuteWithTimeout(fenceTimeout, command, args...)
+	if err != nil {
+		return fmt.Errorf("failed to fence node %s: %s: %w", nodeID, fenceOutput(result), err)
+	}
+
+	if fa.Method == FenceMethodIPMI {
+		command, args = fa.command("status")
+		result, err = fa.shell.ExecuteWithTimeout(fenceTimeout, command, args...)
+		if err != nil {
+			return fmt.Errorf("failed to verify node %s is fenced: %s: %w", nodeID, fenceOutput(result), err)
+		}
+		if !strings.Contains(result.Stdout, "Chassis Power is off") {
+			return fmt.Errorf("node %s is still powered on after fencing: %s", nodeID, result.Stdout)
+		}
+	}
+
+	logger.Warn("Cluster node fenced", zap.String("node", nodeID), zap.String("method", fa.Method))
+	return nil
+}
+
+// TestFencing checks that the fencing device can be reached and controls the
+// node by querying its power status, without cutting power
+func (fa *FencingAgent) TestFencing(nodeID string) error {
+	command, args := fa.command("status")
+	result, err := fa.shell.ExecuteWithTimeout(fenceTimeout, command, args...)
+	if err != nil {
+		return fmt.Errorf("fencing device for node %s unreachable: %s: %w", nodeID, fenceOutput(result), err)
+	}
+
+	if fa.Method == FenceMethodIPMI && !strings.Contains(result.Stdout, "Chassis Power is") {
+		return fmt.Errorf("unexpected power status for node %s: %s", nodeID, result.Stdout)
+	}
+
+	logger.Info("Fencing test succeeded", zap.String("node", nodeID), zap.String("method", fa.Method))
+	return nil
+}
+
+// command returns the command line for a power action (off or status)
+func (fa *FencingAgent) command(action string) (string, []string) {
+	c := fa.Config
+	switch fa.Method {
+	case FenceMethodIPMI:
+		iface := c["interface"]
+		if iface == "" {
+			iface = "lanplus"
+		}
+		return "ipmitool", []string{"-I", iface, "-H", c["address"], "-U", c["username"], "-P", c["password"], "chassis", "power", action}
+	case FenceMethodAPCPDU:
+		return "fence_apc", []string{"--ip=" + c["address"], "--username=" + c["username"], "--password=" + c["password"], "--plug=" + c["plug"], "--action=" + action}
+	default:
+		args := []string{"--region=" + c["region"], "--plug=" + c["instance_id"], "--action=" + action}
+		// Without keys fence_aws uses the instance role
+		if c["access_key"] != "" {
+			args = append(args, "--access-key="+c["access_key"], "--secret-key="+c["secret_key"])
+		}
+		return "fence_aws", args
+	}
+}
+
+func fenceOutput(result *executor.CommandResult) string {
+	if result == nil {
+		return ""
+	}
+	if result.Stderr != "" {
+		return result.Stderr
+	}
+	return result.Stdout
+}
+
+// NodeFencing is the fencing configuration of a node as returned by the API,
+// without credentials
+type NodeFencing struct {
+	Node   string            `json:"node"`
+	Method string            `json:"method"`
+	Config map[string]string `json:"config"`
+}
+
+// FencingManager fences cluster nodes using their stored fencing
+// configuration
+type FencingManager struct {
+	db    *gorm.DB
+	shell executor.ShellExecutor
+}
+
+// NewFencingManager creates a fencing manager
+func NewFencingManager(db *gorm.DB, shell executor.ShellExecutor) *FencingManager {
+	return &FencingManager{db: db, shell: shell}
+}
+
+// SetNodeFencing validates and stores the fencing configuration of a node
+func (fm *FencingManager) SetNodeFencing(node, method string, config map[string]string) (*NodeFencing, error) {
+	if node == "" {
+		return nil, fmt.Errorf("node name is required")
+	}
+	if _, err := NewFencingAgent(fm.shell, method, config); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var model models.ClusterNode
+	if err := fm.db.Where("name = ?", node).First(&model).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load cluster node: %w", err)
+	}
+	model.Name = node
+	model.FenceMethod = method
+	model.FenceConfig = string(data)
+	if err := fm.db.Save(&model).Error; err != nil {
+		return nil, fmt.Errorf("failed to save fencing configuration: %w", err)
+	}
+
+	logger.Info("Fencing configured", zap.String("node", node), zap.String("method", method))
+	return redactFencing(node, method, config), nil
+}
+
+// AgentForNode returns the fencing agent of a node
+func (fm *FencingManager) AgentForNode(node string) (*FencingAgent, error) {
+	var model models.ClusterNode
+	if err := fm.db.Where("name = ?", node).First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoFencingConfigured
+		}
+		return nil, fmt.Errorf("failed to load cluster node: %w", err)
+	}
+	if model.FenceMethod == "" {
+		return nil, ErrNoFencingConfigured
+	}
+
+	config := map[string]string{}
+	if err := json.Unmarshal([]byte(model.FenceConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid fencing configuration for node %s: %w", node, err)
+	}
+	return NewFencingAgent(fm.shell, model.FenceMethod, config)
+}
+
+// FenceNode powers off a node
+func (fm *FencingManager) FenceNode(node string) error {
+	agent, err := fm.AgentForNode(node)
+	if err != nil {
+		return err
+	}
+	return agent.Fence(node)
+}
+
+// TestNode checks that a node can be fenced without powering it off
+func (fm *FencingManager) TestNode(node string) error {
+	agent, err := fm.AgentForNode(node)
+	if err != nil {
+		return err
+	}
+	return agent.TestFencing(node)
+}
+
+func redactFencing(node, method string, config map[string]string) *NodeFencing {
+	redacted := make(map[string]string, len(config))
+	for key, value := range config {
+		if !fenceSecretOptions[key] {
+			redacted[key] = value
+		}
+	}
+	return &NodeFencing{Node: node, Method: method, Config: redacted}
+}
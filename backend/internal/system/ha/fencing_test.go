@@ -0,0 +1,152 @@
+package ha
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var testIPMIConfig = map[string]string{"address": "10.0.0.12", "username": "admin", "password": "secret"}
+
+func ipmiArgs(action string) []string {
+	return []string{"-I", "lanplus", "-H", "10.0.0.12", "-U", "admin", "-P", "secret", "chassis", "power", action}
+}
+
+func TestFencingAgentIPMI(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("ipmitool", ipmiArgs("off")...).Returns("Chassis Power Control: Down/Off", "", 0).Times(1)
+	shell.ExpectCommand("ipmitool", ipmiArgs("status")...).Returns("Chassis Power is off", "", 0).Times(1)
+
+	agent, err := NewFencingAgent(shell, FenceMethodIPMI, testIPMIConfig)
+	if err != nil {
+		t.Fatalf("NewFencingAgent: %v", err)
+	}
+	if err := agent.Fence("nas2"); err != nil {
+		t.Fatalf("Fence: %v", err)
+	}
+	shell.AssertExpectations(t)
+}
+
+func TestFencingAgentIPMIStillOn(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("ipmitool", ipmiArgs("off")...).Returns("Chassis Power Control: Down/Off", "", 0)
+	shell.ExpectCommand("ipmitool", ipmiArgs("status")...).Returns("Chassis Power is on", "", 0)
+
+	agent, _ := NewFencingAgent(shell, FenceMethodIPMI, testIPMIConfig)
+	if err := agent.Fence("nas2"); err == nil {
+		t.Fatal("expected fencing to fail while the node is powered on")
+	}
+}
+
+func TestFencingAgentTestDoesNotPowerOff(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("ipmitool", ipmiArgs("status")...).Returns("Chassis Power is on", "", 0)
+
+	agent, _ := NewFencingAgent(shell, FenceMethodIPMI, testIPMIConfig)
+	if err := agent.TestFencing("nas2"); err != nil {
+		t.Fatalf("TestFencing: %v", err)
+	}
+	for _, call := range shell.CallsTo("ipmitool") {
+		if call.Args[len(call.Args)-1] != "status" {
+			t.Errorf("test ran %s", call)
+		}
+	}
+
+	shell = executor.NewMockShellExecutor()
+	shell.ExpectCommand("ipmitool").Returns("", "Error: Unable to establish IPMI v2 / RMCP+ session", 1)
+	agent, _ = NewFencingAgent(shell, FenceMethodIPMI, testIPMIConfig)
+	if err := agent.TestFencing("nas2"); err == nil {
+		t.Error("expected an unreachable BMC to fail the test")
+	}
+}
+
+func TestNewFencingAgentValidation(t *testing.T) {
+	if _, err := NewFencingAgent(nil, "ssh", testIPMIConfig); err == nil {
+		t.Error("accepted an unsupported method")
+	}
+	if _, err := NewFencingAgent(nil, FenceMethodAPCPDU, testIPMIConfig); err == nil {
+		t.Error("accepted an APC PDU without a plug")
+	}
+}
+
+func TestFencingManager(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "fencing.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ClusterNode{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("ipmitool", ipmiArgs("status")...).Returns("Chassis Power is on", "", 0)
+	fm := NewFencingManager(db, shell)
+
+	if err := fm.TestNode("nas2"); !errors.Is(err, ErrNoFencingConfigured) {
+		t.Fatalf("TestNode without config = %v", err)
+	}
+
+	fencing, err := fm.SetNodeFencing("nas2", FenceMethodIPMI, testIPMIConfig)
+	if err != nil {
+		t.Fatalf("SetNodeFencing: %v", err)
+	}
+	if _, ok := fencing.Config["password"]; ok {
+		t.Error("password returned in fencing configuration")
+	}
+	if err := fm.TestNode("nas2"); err != nil {
+		t.Fatalf("TestNode: %v", err)
+	}
+}
+
+func TestDRBDSplitBrainDetector(t *testing.T) {
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	shell.Responses["which"] = executor.ExecuteResult{Stdout: "/usr/sbin/drbdadm"}
+	shell.ExpectCommand("sudo", "dmesg").Returns(
+		"[  12.1] drbd r0 nas2: Split-Brain detected but unresolved, dropping connection!\n"+
+			"[  13.4] drbd r1 nas2: Split-Brain detected but unresolved, dropping connection!", "", 0)
+	shell.ExpectCommand("sudo", "drbdadm", "status", "r0").Returns("r0 role:Primary\n  disk:UpToDate\n  nas2 connection:StandAlone", "", 0)
+	shell.ExpectCommand("sudo", "drbdadm", "status", "r1").Returns("r1 role:Primary\n  disk:UpToDate\n  nas2 role:Secondary\n    peer-disk:UpToDate", "", 0)
+
+	drbd, err := NewDRBDManager(shell)
+	if err != nil {
+		t.Fatalf("NewDRBDManager: %v", err)
+	}
+	var fenced []string
+	d := NewDRBDSplitBrainDetector(drbd, shell, func(node string) error {
+		fenced = append(fenced, node)
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		splitBrains, err := d.Check()
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		// r1 reconnected, so only r0 is unresolved
+		if len(splitBrains) != 1 || splitBrains[0] != (SplitBrain{Resource: "r0", Peer: "nas2"}) {
+			t.Fatalf("split-brains = %v", splitBrains)
+		}
+	}
+	if len(fenced) != 1 || fenced[0] != "nas2" {
+		t.Errorf("fenced = %v, want nas2 once", fenced)
+	}
+}
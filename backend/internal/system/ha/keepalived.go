@@ -102,14 +102,12 @@ func (km *KeepalivedManager) CreateVIP(config VIPConfig) error {
 	km.shell.Execute("sudo", "cp", configPath, configPath+".bak")
 
 	// Write new config
-	writeCmd := fmt.Sprintf("echo '%s' | sudo tee %s", configContent, configPath)
-	result, err := km.shell.Execute("sh", "-c", writeCmd)
-	if err != nil {
-		return fmt.Errorf("failed to write keepalived config: %s: %w", result.Stderr, err)
+	if err := writeConfigFile(km.shell, configPath, configContent); err != nil {
+		return fmt.Errorf("failed to write keepalived config: %w", err)
 	}
 
 	// Restart keepalived service
-	result, err = km.shell.Execute("sudo", "systemctl", "restart", "keepalived")
+	result, err := km.shell.Execute("sudo", "systemctl", "restart", "keepalived")
 	if err != nil {
 		logger.Error("Failed to restart keepalived", zap.Error(err), zap.String("stderr", result.Stderr))
 		return fmt.Errorf("failed to restart keepalived: %s: %w", result.Stderr, err)
@@ -324,14 +322,12 @@ func (km *KeepalivedManager) PromoteToMaster(vipID string) error {
 	})
 
 	// Write updated config
-	writeCmd := fmt.Sprintf("echo '%s' | sudo tee %s", configContent, configPath)
-	result, err := km.shell.Execute("sh", "-c", writeCmd)
-	if err != nil {
-		return fmt.Errorf("failed to write keepalived config: %s: %w", result.Stderr, err)
+	if err := writeConfigFile(km.shell, configPath, configContent); err != nil {
+		return fmt.Errorf("failed to write keepalived config: %w", err)
 	}
 
 	// Restart keepalived
-	result, err = km.shell.Execute("sudo", "systemctl", "restart", "keepalived")
+	result, err := km.shell.Execute("sudo", "systemctl", "restart", "keepalived")
 	if err != nil {
 		return fmt.Errorf("failed to restart keepalived: %s: %w", result.Stderr, err)
 	}
@@ -368,14 +364,12 @@ func (km *KeepalivedManager) DemoteToBackup(vipID string) error {
 	})
 
 	// Write updated config
-	writeCmd := fmt.Sprintf("echo '%s' | sudo tee %s", configContent, configPath)
-	result, err := km.shell.Execute("sh", "-c", writeCmd)
-	if err != nil {
-		return fmt.Errorf("failed to write keepalived config: %s: %w", result.Stderr, err)
+	if err := writeConfigFile(km.shell, configPath, configContent); err != nil {
+		return fmt.Errorf("failed to write keepalived config: %w", err)
 	}
 
 	// Restart keepalived
-	result, err = km.shell.Execute("sudo", "systemctl", "restart", "keepalived")
+	result, err := km.shell.Execute("sudo", "systemctl", "restart", "keepalived")
 	if err != nil {
 		return fmt.Errorf("failed to restart keepalived: %s: %w", result.Stderr, err)
 	}
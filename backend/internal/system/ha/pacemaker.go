@@ -368,3 +368,18 @@ func (pm *PacemakerManager) UnstandbyNode(nodeName string) error {
 	logger.Info("Node removed from standby", zap.String("node", nodeName))
 	return nil
 }
+
+// FenceNode fences a node through the configured STONITH devices
+func (pm *PacemakerManager) FenceNode(nodeName string) error {
+	if !pm.enabled {
+		return fmt.Errorf("Pacemaker is not enabled")
+	}
+
+	result, err := pm.shell.Execute("sudo", "pcs", "stonith", "fence", nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to fence node: %s: %w", result.Stderr, err)
+	}
+
+	logger.Info("Node fenced", zap.String("node", nodeName))
+	return nil
+}
@@ -0,0 +1,281 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+)
+
+// Resource template types
+const (
+	TemplateFilesystem = "filesystem"
+	TemplateIPAddr     = "ip-addr"
+	TemplateService    = "service"
+	TemplateDRBD       = "drbd"
+)
+
+var (
+	// resourceIDRegex matches valid CIB IDs
+	resourceIDRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]{0,63}$`)
+	// nicRegex matches network interface names
+	nicRegex = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,15}$`)
+	// fstypeRegex matches filesystem types such as ext4 or nfs4
+	fstypeRegex = regexp.MustCompile(`^[a-z0-9]+$`)
+	// unitRegex matches systemd unit names
+	unitRegex = regexp.MustCompile(`^[A-Za-z0-9@_.:-]+$`)
+)
+
+// PacemakerResourceTemplate describes how to create a common resource type
+// without writing CRM XML: the resource agent, the parameters it needs and
+// the operations it is monitored with
+type PacemakerResourceTemplate struct {
+	Type       string     `json:"type"`                 // filesystem, ip-addr, service or drbd
+	Class      string     `json:"class"`                // ocf or systemd
+	Provider   string     `json:"provider,omitempty"`   // OCF provider, e.g. heartbeat
+	Agent      string     `json:"agent,omitempty"`      // Empty for services, where the unit is the agent
+	Required   []string   `json:"required,omitempty"`   // Parameters that must be given
+	Ops        []OpConfig `json:"ops"`                  // Operations
+	Promotable bool       `json:"promotable,omitempty"` // Run as a promotable clone, one primary at a time
+}
+
+// PacemakerResourceTemplates are the templates by type
+var PacemakerResourceTemplates = map[string]PacemakerResourceTemplate{
+	TemplateIPAddr: {
+		Type:     TemplateIPAddr,
+		Class:    "ocf",
+		Provider: "heartbeat",
+		Agent:    "IPaddr2",
+		Required: []string{"ip", "cidr_netmask"},
+		Ops:      []OpConfig{{Name: "monitor", Interval: "10s", Timeout: "20s"}},
+	},
+	TemplateFilesystem: {
+		Type:     TemplateFilesystem,
+		Class:    "ocf",
+		Provider: "heartbeat",
+		Agent:    "Filesystem",
+		Required: []string{"device", "directory", "fstype"},
+		Ops: []OpConfig{
+			{Name: "monitor", Interval: "20s", Timeout: "40s"},
+			{Name: "start", Interval: "0s", Timeout: "60s"},
+			{Name: "stop", Interval: "0s", Timeout: "60s"},
+		},
+	},
+	TemplateService: {
+		Type:  TemplateService,
+		Class: "systemd",
+		Ops:   []OpConfig{{Name: "monitor", Interval: "30s", Timeout: "100s"}},
+	},
+	TemplateDRBD: {
+		Type:     TemplateDRBD,
+		Class:    "ocf",
+		Provider: "linbit",
+		Agent:    "drbd",
+		Required: []string{"drbd_resource"},
+		Ops: []OpConfig{
+			{Name: "monitor", Interval: "29s", Timeout: "20s"},
+			{Name: "monitor", Interval: "31s", Timeout: "20s"},
+		},
+		Promotable: true,
+	},
+}
+
+// resourceXML is the CIB fragment crm configure load xml update merges
+// into the cluster configuration
+var resourceXML = template.Must(template.New("resource").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<cib>
+  <configuration>
+    <resources>
+{{- if .Promotable}}
+      <clone id="{{xml .ID}}-clone">
+        <meta_attributes id="{{xml .ID}}-clone-meta_attributes">
+          <nvpair id="{{xml .ID}}-clone-meta_attributes-promotable" name="promotable" value="true"/>
+          <nvpair id="{{xml .ID}}-clone-meta_attributes-promoted-max" name="promoted-max" value="1"/>
+          <nvpair id="{{xml .ID}}-clone-meta_attributes-clone-max" name="clone-max" value="2"/>
+          <nvpair id="{{xml .ID}}-clone-meta_attributes-notify" name="notify" value="true"/>
+        </meta_attributes>
+{{- end}}
+      <primitive id="{{xml .ID}}" class="{{xml .Class}}"{{if .Provider}} provider="{{xml .Provider}}"{{end}} type="{{xml .Agent}}">
+{{- if .Params}}
+        <instance_attributes id="{{xml .ID}}-instance_attributes">
+{{- range .Params}}
+          <nvpair id="{{xml $.ID}}-instance_attributes-{{xml .Name}}" name="{{xml .Name}}" value="{{xml .Value}}"/>
+{{- end}}
+        </instance_attributes>
+{{- end}}
+        <operations>
+{{- range $i, $op := .Ops}}
+          <op id="{{xml $.ID}}-{{xml $op.Name}}-interval-{{xml $op.Interval}}" name="{{xml $op.Name}}" interval="{{xml $op.Interval}}" timeout="{{xml $op.Timeout}}"{{if $.Promotable}}{{if eq $i 0}} role="Promoted"{{else if eq $i 1}} role="Unpromoted"{{end}}{{end}}/>
+{{- end}}
+        </operations>
+      </primitive>
+{{- if .Promotable}}
+      </clone>
+{{- end}}
+    </resources>
+  </configuration>
+</cib>
+`))
+
+// resourceParam is an instance attribute of a resource
+type resourceParam struct {
+	Name  string
+	Value string
+}
+
+// resourceData is what resourceXML renders
+type resourceData struct {
+	PacemakerResourceTemplate
+	ID     string
+	Params []resourceParam
+}
+
+// Render returns the CIB XML of a resource named id with the given
+// parameters. For services, agent is the systemd unit; other templates
+// have their own agent and ignore it.
+func (t PacemakerResourceTemplate) Render(id, agent string, params map[string]string) (string, error) {
+	if !resourceIDRegex.MatchString(id) {
+		return "", fmt.Errorf("invalid resource name %q", id)
+	}
+	for _, name := range t.Required {
+		if params[name] == "" {
+			return "", fmt.Errorf("parameter %s is required for %s resources", name, t.Type)
+		}
+	}
+	data := resourceData{PacemakerResourceTemplate: t, ID: id}
+	if data.Agent == "" {
+		if agent == "" {
+			return "", fmt.Errorf("agent is required for %s resources", t.Type)
+		}
+		data.Agent = agent
+	}
+	for name, value := range params {
+		data.Params = append(data.Params, resourceParam{Name: name, Value: value})
+	}
+	sort.Slice(data.Params, func(i, j int) bool { return data.Params[i].Name < data.Params[j].Name })
+
+	var buf bytes.Buffer
+	if err := resourceXML.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s resource: %w", t.Type, err)
+	}
+	return buf.String(), nil
+}
+
+// CreateIPAddressResource creates a floating IP address that follows the
+// active node. nic may be empty to let the agent pick the interface whose
+// subnet contains the address.
+func (pm *PacemakerManager) CreateIPAddressResource(name, ipAddress, cidr, nic string) error {
+	if !sysutil.ValidateIP(ipAddress) {
+		return fmt.Errorf("invalid IP address %q", ipAddress)
+	}
+	maxPrefix := 128
+	if sysutil.ValidateIPv4(ipAddress) {
+		maxPrefix = 32
+	}
+	if prefix, err := strconv.Atoi(cidr); err != nil || prefix < 1 || prefix > maxPrefix {
+		return fmt.Errorf("invalid CIDR prefix length %q", cidr)
+	}
+
+	params := map[string]string{"ip": ipAddress, "cidr_netmask": cidr}
+	if nic != "" {
+		if !nicRegex.MatchString(nic) {
+			return fmt.Errorf("invalid network interface %q", nic)
+		}
+		params["nic"] = nic
+	}
+	return pm.createFromTemplate(TemplateIPAddr, name, "", params)
+}
+
+// CreateFilesystemResource creates a filesystem that is mounted on the
+// active node. device is a device path or a UUID= or LABEL= specification.
+func (pm *PacemakerManager) CreateFilesystemResource(name, device, mountpoint, fstype string) error {
+	if !filepath.IsAbs(device) && !strings.HasPrefix(device, "UUID=") && !strings.HasPrefix(device, "LABEL=") {
+		return fmt.Errorf("invalid device %q", device)
+	}
+	if !filepath.IsAbs(mountpoint) {
+		return fmt.Errorf("mountpoint must be an absolute path")
+	}
+	if !fstypeRegex.MatchString(fstype) {
+		return fmt.Errorf("invalid filesystem type %q", fstype)
+	}
+
+	return pm.createFromTemplate(TemplateFilesystem, name, "", map[string]string{
+		"device":    device,
+		"directory": mountpoint,
+		"fstype":    fstype,
+	})
+}
+
+// CreateServiceResource creates a resource that runs a systemd service on
+// the active node
+func (pm *PacemakerManager) CreateServiceResource(name, serviceName string) error {
+	if !unitRegex.MatchString(serviceName) {
+		return fmt.Errorf("invalid service name %q", serviceName)
+	}
+	return pm.createFromTemplate(TemplateService, name, serviceName, nil)
+}
+
+// createFromTemplate loads the resource into the cluster configuration
+// with crm, then verifies the configuration and removes the resource again
+// if it does not verify
+func (pm *PacemakerManager) createFromTemplate(templateType, name, agent string, params map[string]string) error {
+	if !pm.enabled {
+		return fmt.Errorf("Pacemaker is not enabled")
+	}
+	tmpl, ok := PacemakerResourceTemplates[templateType]
+	if !ok {
+		return fmt.Errorf("unknown resource template %q", templateType)
+	}
+	config, err := tmpl.Render(name, agent, params)
+	if err != nil {
+		return err
+	}
+
+	load := executor.NewPipeline(executor.PipelineStage{
+		Command: "sudo",
+		Args:    []string{"crm", "configure", "load", "xml", "update", "-"},
+	}).WithStdin(config)
+	if result, err := pm.shell.RunPipeline(context.Background(), *load); err != nil {
+		return fmt.Errorf("failed to load %s resource: %s: %w", tmpl.Type, commandOutput(result), err)
+	}
+
+	result, err := pm.shell.Execute("sudo", "crm", "configure", "verify")
+	if err != nil || strings.Contains(commandOutput(result), "ERROR:") {
+		id := name
+		if tmpl.Promotable {
+			id += "-clone"
+		}
+		if _, delErr := pm.shell.Execute("sudo", "crm", "configure", "delete", "--force", id); delErr != nil {
+			logger.Warn("Failed to remove resource that failed verification", zap.String("id", id), zap.Error(delErr))
+		}
+		return fmt.Errorf("resource %s failed verification: %s", name, commandOutput(result))
+	}
+
+	logger.Info("Pacemaker resource created", zap.String("id", name), zap.String("template", tmpl.Type))
+	return nil
+}
+
+// commandOutput returns the stderr and stdout of a command, for errors
+func commandOutput(result *executor.CommandResult) string {
+	if result == nil {
+		return ""
+	}
+	return strings.TrimSpace(result.Stderr + "\n" + result.Stdout)
+}
+
+// xmlEscape escapes a string for use in an XML attribute
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
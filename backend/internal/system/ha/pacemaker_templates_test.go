@@ -0,0 +1,137 @@
+package ha
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// cibResources is the part of a CIB fragment the tests look at
+type cibResources struct {
+	Primitives []struct {
+		ID       string `xml:"id,attr"`
+		Class    string `xml:"class,attr"`
+		Provider string `xml:"provider,attr"`
+		Type     string `xml:"type,attr"`
+		NVPairs  []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"instance_attributes>nvpair"`
+		Ops []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"operations>op"`
+	} `xml:"configuration>resources>primitive"`
+}
+
+func newTestPacemakerManager(t *testing.T) (*PacemakerManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("which", "pcs").Returns("/usr/sbin/pcs", "", 0)
+	pm, err := NewPacemakerManager(shell)
+	if err != nil {
+		t.Fatalf("NewPacemakerManager: %v", err)
+	}
+	return pm, shell
+}
+
+func TestCreateIPAddressResource(t *testing.T) {
+	pm, shell := newTestPacemakerManager(t)
+	shell.ExpectCommand("sudo", "crm", "configure", "load", "xml", "update", "-").Returns("", "", 0).Times(1)
+	shell.ExpectCommand("sudo", "crm", "configure", "verify").Returns("", "", 0).Times(1)
+
+	if err := pm.CreateIPAddressResource("nas-vip", "192.168.1.50", "24", "eth0"); err != nil {
+		t.Fatalf("CreateIPAddressResource: %v", err)
+	}
+	shell.AssertExpectations(t)
+
+	var cib cibResources
+	if err := xml.Unmarshal([]byte(shell.CallsTo("sudo")[0].Stdin), &cib); err != nil {
+		t.Fatalf("loaded XML does not parse: %v", err)
+	}
+	if len(cib.Primitives) != 1 {
+		t.Fatalf("loaded %d primitives, want 1", len(cib.Primitives))
+	}
+	p := cib.Primitives[0]
+	if p.ID != "nas-vip" || p.Class != "ocf" || p.Provider != "heartbeat" || p.Type != "IPaddr2" {
+		t.Errorf("primitive = %s %s:%s:%s, want nas-vip ocf:heartbeat:IPaddr2", p.ID, p.Class, p.Provider, p.Type)
+	}
+	params := map[string]string{}
+	for _, nv := range p.NVPairs {
+		params[nv.Name] = nv.Value
+	}
+	if params["ip"] != "192.168.1.50" || params["cidr_netmask"] != "24" || params["nic"] != "eth0" {
+		t.Errorf("instance attributes = %v", params)
+	}
+	if len(p.Ops) != 1 || p.Ops[0].Name != "monitor" {
+		t.Errorf("operations = %+v, want a monitor", p.Ops)
+	}
+}
+
+func TestCreateIPAddressResourceValidation(t *testing.T) {
+	pm, shell := newTestPacemakerManager(t)
+
+	tests := []struct {
+		name, ip, cidr, nic string
+	}{
+		{"vip", "192.168.1.300", "24", ""},
+		{"vip", "not-an-ip", "24", ""},
+		{"vip", "192.168.1.50", "33", ""},
+		{"vip", "fd00::50", "0", ""},
+		{"vip", "192.168.1.50", "24", "eth0; reboot"},
+		{"1vip", "192.168.1.50", "24", ""},
+	}
+	for _, tt := range tests {
+		if err := pm.CreateIPAddressResource(tt.name, tt.ip, tt.cidr, tt.nic); err == nil {
+			t.Errorf("CreateIPAddressResource(%q, %q, %q, %q) succeeded", tt.name, tt.ip, tt.cidr, tt.nic)
+		}
+	}
+	if calls := shell.CallsTo("sudo"); len(calls) != 0 {
+		t.Errorf("invalid resources reached crm: %v", calls)
+	}
+}
+
+func TestCreateFilesystemResourceFailsVerification(t *testing.T) {
+	pm, shell := newTestPacemakerManager(t)
+	verifyOutput := `ERROR: primitive nas-data: parameter "fstype" has invalid value "zfs"`
+	shell.ExpectCommand("sudo", "crm", "configure", "load", "xml", "update", "-").Returns("", "", 0).Times(1)
+	shell.ExpectCommand("sudo", "crm", "configure", "verify").Returns("", verifyOutput, 1).Times(1)
+	shell.ExpectCommand("sudo", "crm", "configure", "delete", "--force", "nas-data").Returns("", "", 0).Times(1)
+
+	err := pm.CreateFilesystemResource("nas-data", "/dev/drbd0", "/mnt/data", "zfs")
+	if err == nil || !strings.Contains(err.Error(), verifyOutput) {
+		t.Fatalf("CreateFilesystemResource error = %v, want the verify output", err)
+	}
+	shell.AssertExpectations(t)
+}
+
+func TestPacemakerResourceTemplateRender(t *testing.T) {
+	config, err := PacemakerResourceTemplates[TemplateService].Render("nas-smb", "smbd", nil)
+	if err != nil {
+		t.Fatalf("Render service: %v", err)
+	}
+	if !strings.Contains(config, `<primitive id="nas-smb" class="systemd" type="smbd">`) {
+		t.Errorf("service resource =\n%s", config)
+	}
+
+	config, err = PacemakerResourceTemplates[TemplateDRBD].Render("nas-drbd", "", map[string]string{"drbd_resource": "r0"})
+	if err != nil {
+		t.Fatalf("Render drbd: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(config), new(struct{})); err != nil {
+		t.Fatalf("drbd resource does not parse: %v", err)
+	}
+	for _, want := range []string{`<clone id="nas-drbd-clone">`, `name="promotable" value="true"`, `role="Promoted"`} {
+		if !strings.Contains(config, want) {
+			t.Errorf("drbd resource lacks %s:\n%s", want, config)
+		}
+	}
+
+	if _, err := PacemakerResourceTemplates[TemplateDRBD].Render("nas-drbd", "", nil); err == nil {
+		t.Error("Render drbd without drbd_resource succeeded")
+	}
+}
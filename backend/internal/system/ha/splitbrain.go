@@ -0,0 +1,160 @@
+package ha
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// splitBrainInterval is how often DRBD resources are checked for split-brain
+const splitBrainInterval = 30 * time.Second
+
+// splitBrainRegex matches the kernel message DRBD logs when it drops the
+// connection to a peer after a split-brain it could not resolve, e.g.
+// "drbd r0 nas2: Split-Brain detected but unresolved, dropping connection!"
+var splitBrainRegex = regexp.MustCompile(`drbd (\S+) (\S+): Split-Brain detected but unresolved`)
+
+// SplitBrain is an unresolved DRBD split-brain of a resource
+type SplitBrain struct {
+	Resource string `json:"resource"`
+	Peer     string `json:"peer"`
+}
+
+// DRBDSplitBrainDetector watches DRBD resources for unresolved split-brains
+// and fences the losing node. The node that is still Primary for the
+// resource keeps serving its data, so it fences the peer; a Secondary node
+// leaves fencing to its peer.
+type DRBDSplitBrainDetector struct {
+	drbd  *DRBDManager
+	shell executor.ShellExecutor
+	fence func(node string) error
+
+	fenced   map[SplitBrain]bool
+	reported map[SplitBrain]bool
+}
+
+// NewDRBDSplitBrainDetector creates a detector that calls fence with the
+// name of the losing node
+func NewDRBDSplitBrainDetector(drbd *DRBDManager, shell executor.ShellExecutor, fence func(node string) error) *DRBDSplitBrainDetector {
+	return &DRBDSplitBrainDetector{
+		drbd:     drbd,
+		shell:    shell,
+		fence:    fence,
+		fenced:   make(map[SplitBrain]bool),
+		reported: make(map[SplitBrain]bool),
+	}
+}
+
+// Start checks for split-brains every 30 seconds until ctx is cancelled
+func (d *DRBDSplitBrainDetector) Start(ctx context.Context) error {
+	if !d.drbd.IsEnabled() {
+		return fmt.Errorf("DRBD is not enabled")
+	}
+
+	go func() {
+		ticker := time.NewTicker(splitBrainInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := d.Check(); err != nil {
+				logger.Warn("DRBD split-brain check failed", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Check returns the unresolved split-brains and fences the peer of each
+// resource this node is Primary for. A split-brain counts as unresolved
+// while the resource stays StandAlone; each peer is fenced once per
+// split-brain.
+func (d *DRBDSplitBrainDetector) Check() ([]SplitBrain, error) {
+	result, err := d.shell.Execute("sudo", "dmesg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kernel log: %w", err)
+	}
+
+	detected := map[SplitBrain]bool{}
+	for _, match := range splitBrainRegex.FindAllStringSubmatch(result.Stdout, -1) {
+		detected[SplitBrain{Resource: match[1], Peer: match[2]}] = true
+	}
+
+	splitBrains := []SplitBrain{}
+	unresolved := map[SplitBrain]bool{}
+	for sb := range detected {
+		status, err := d.drbd.GetResourceStatus(sb.Resource)
+		if err != nil {
+			logger.Warn("Failed to get DRBD status", zap.String("resource", sb.Resource), zap.Error(err))
+			continue
+		}
+		if status.ConnectionState != "StandAlone" {
+			continue
+		}
+
+		splitBrains = append(splitBrains, sb)
+		unresolved[sb] = true
+		logger.Error("DRBD split-brain detected",
+			zap.String("resource", sb.Resource),
+			zap.String("peer", sb.Peer),
+			zap.String("role", status.Role))
+		if !d.reported[sb] {
+			d.report(sb, status.Role)
+			d.reported[sb] = true
+		}
+
+		if status.Role != "Primary" || d.fenced[sb] {
+			continue
+		}
+		if err := d.fence(sb.Peer); err != nil {
+			logger.Error("Failed to fence split-brain peer",
+				zap.String("resource", sb.Resource),
+				zap.String("peer", sb.Peer),
+				zap.Error(err))
+			continue
+		}
+		d.fenced[sb] = true
+	}
+
+	// Fence and report again if a resolved split-brain happens again
+	for sb := range d.fenced {
+		if !unresolved[sb] {
+			delete(d.fenced, sb)
+		}
+	}
+	for sb := range d.reported {
+		if !unresolved[sb] {
+			delete(d.reported, sb)
+		}
+	}
+	return splitBrains, nil
+}
+
+// report publishes a notification about a new split-brain
+func (d *DRBDSplitBrainDetector) report(sb SplitBrain, role string) {
+	action := "The peer will be fenced by this node."
+	if role != "Primary" {
+		action = "This node is " + role + " and leaves fencing to its peer."
+	}
+	err := notifications.Publish(notifications.Notification{
+		Type:     models.AlertTypeDRBDSplitBrain,
+		Title:    fmt.Sprintf("DRBD split-brain on resource %s", sb.Resource),
+		Body:     fmt.Sprintf("DRBD detected an unresolved split-brain with %s and dropped the connection. %s", sb.Peer, action),
+		Severity: notifications.SeverityCritical,
+		Source:   "drbd",
+		Metadata: map[string]interface{}{"resource": sb.Resource, "peer": sb.Peer, "role": role},
+	})
+	if err != nil {
+		logger.Warn("Failed to publish split-brain notification", zap.Error(err))
+	}
+}
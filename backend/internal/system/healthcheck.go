@@ -0,0 +1,9 @@
+package system
+
+import "github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+
+func init() {
+	sysutil.RegisterComponents("system",
+		sysutil.ComponentDefinition{Name: "systemctl", Command: "systemctl"},
+	)
+}
@@ -2,20 +2,28 @@
 package lxc
 
 import (
+	"context"
 	"fmt"
+	"net/netip"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/network"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"go.uber.org/zap"
 )
 
 // LXCManager manages LXC containers
 type LXCManager struct {
-	shell   executor.ShellExecutor
-	enabled bool
+	shell          executor.ShellExecutor
+	enabled        bool
+	checkpointRoot string                    // Parent of the CRIU checkpoints of migrations
+	configRoot     string                    // Holds a directory with the config file of each container
+	netns          *network.NetworkNamespace // Isolates container networking if set
 }
 
 // Container represents an LXC container
@@ -45,6 +53,8 @@ type ContainerCreateRequest struct {
 	Bridge      string `json:"bridge"`       // Bridge name when network_mode is "bridged" (e.g., br0, vmbr0, vmbr1)
 	Password    string `json:"password"`     // Root password for SSH access
 	SSHKey      string `json:"ssh_key"`      // SSH public key for passwordless authentication
+	IPv4Address string `json:"ipv4_address,omitempty"` // Static address in CIDR notation, DHCP if empty
+	IPv4Gateway string `json:"ipv4_gateway,omitempty"` // Default gateway for a static address
 }
 
 // Template represents an LXC template
@@ -58,8 +68,10 @@ type Template struct {
 // NewLXCManager creates a new LXC manager
 func NewLXCManager(shell executor.ShellExecutor) (*LXCManager, error) {
 	manager := &LXCManager{
-		shell:   shell,
-		enabled: false,
+		shell:          shell,
+		enabled:        false,
+		checkpointRoot: "/var/lib/lxc-checkpoints",
+		configRoot:     "/var/lib/lxc",
 	}
 
 	// Check if lxc-ls is available
@@ -74,6 +86,13 @@ func NewLXCManager(shell executor.ShellExecutor) (*LXCManager, error) {
 	return manager, nil
 }
 
+// UseNetworkNamespaces makes new containers join a network namespace of
+// their own, connected to the bridge through a veth pair, instead of
+// having LXC attach them to the bridge directly
+func (lm *LXCManager) UseNetworkNamespaces(netns *network.NetworkNamespace) {
+	lm.netns = netns
+}
+
 // IsEnabled returns whether LXC is available
 func (lm *LXCManager) IsEnabled() bool {
 	return lm.enabled
@@ -219,6 +238,16 @@ func (lm *LXCManager) CreateContainer(req ContainerCreateRequest) error {
 	if req.Architecture == "" {
 		req.Architecture = "amd64"
 	}
+	if req.IPv4Address != "" {
+		if prefix, err := netip.ParsePrefix(req.IPv4Address); err != nil || !prefix.Addr().Is4() {
+			return fmt.Errorf("invalid IPv4 address %q: must be in CIDR notation", req.IPv4Address)
+		}
+	}
+	if req.IPv4Gateway != "" {
+		if addr, err := netip.ParseAddr(req.IPv4Gateway); err != nil || !addr.Is4() {
+			return fmt.Errorf("invalid IPv4 gateway %q", req.IPv4Gateway)
+		}
+	}
 
 	// Build lxc-create command
 	// Modern LXC uses the "download" template
@@ -265,16 +294,22 @@ func (lm *LXCManager) CreateContainer(req ContainerCreateRequest) error {
 	// Remove default network configuration and add custom one
 	lm.shell.Execute("sh", "-c", fmt.Sprintf("sed -i '/lxc.net.0/d' %s", configPath))
 
+	bridge := "lxcbr0"
 	if req.NetworkMode == "bridged" {
 		// Use custom bridge or default to br0
-		bridge := req.Bridge
+		bridge = req.Bridge
 		if bridge == "" {
 			bridge = "br0"
 		}
+	}
 
+	isolated := lm.netns != nil && lm.configureNetworkNamespace(req, bridge, configPath)
+	if isolated {
+		logger.Info("Container configured with its own network namespace", zap.String("name", req.Name), zap.String("bridge", bridge))
+	} else if req.NetworkMode == "bridged" {
 		// Configure bridged network for DHCP from router
 		lm.shell.Execute("sh", "-c", fmt.Sprintf("echo 'lxc.net.0.type = veth' >> %s", configPath))
-		lm.shell.Execute("sh", "-c", fmt.Sprintf("echo 'lxc.net.0.link = %s' >> %s", bridge, configPath))
+		lm.shell.Execute("sh", "-c", fmt.Sprintf("echo %s >> %s", sysutil.SanitizeShellArg("lxc.net.0.link = "+bridge), configPath))
 		lm.shell.Execute("sh", "-c", fmt.Sprintf("echo 'lxc.net.0.flags = up' >> %s", configPath))
 		lm.shell.Execute("sh", "-c", fmt.Sprintf("echo 'lxc.net.0.hwaddr = 00:16:3e:xx:xx:xx' >> %s", configPath))
 		logger.Info("Container configured with bridged network", zap.String("name", req.Name), zap.String("bridge", bridge))
@@ -287,6 +322,15 @@ func (lm *LXCManager) CreateContainer(req ContainerCreateRequest) error {
 		logger.Info("Container configured with internal network", zap.String("name", req.Name))
 	}
 
+	// An isolated container's address was set up in its namespace
+	if req.IPv4Address != "" && !isolated {
+		lm.shell.Execute("sh", "-c", fmt.Sprintf("echo 'lxc.net.0.ipv4.address = %s' >> %s", req.IPv4Address, configPath))
+		if req.IPv4Gateway != "" {
+			lm.shell.Execute("sh", "-c", fmt.Sprintf("echo 'lxc.net.0.ipv4.gateway = %s' >> %s", req.IPv4Gateway, configPath))
+		}
+		logger.Info("Container configured with static address", zap.String("name", req.Name), zap.String("address", req.IPv4Address))
+	}
+
 	logger.Info("Container created", zap.String("name", req.Name))
 
 	// Start container temporarily to configure password and SSH key
@@ -305,9 +349,10 @@ func (lm *LXCManager) CreateContainer(req ContainerCreateRequest) error {
 		// Configure root password
 		if req.Password != "" {
 			logger.Info("Setting root password", zap.String("name", req.Name))
-			// Use echo to pass password to chpasswd
-			passwdCmd := fmt.Sprintf("echo 'root:%s' | chpasswd", req.Password)
-			_, err := lm.shell.Execute("lxc-attach", "-n", req.Name, "--", "sh", "-c", passwdCmd)
+			// Pass the password to chpasswd on stdin
+			pipeline := executor.NewPipeline(executor.PipelineStage{Command: "lxc-attach", Args: []string{"-n", req.Name, "--", "chpasswd"}}).
+				WithStdin("root:" + req.Password + "\n")
+			_, err := lm.shell.RunPipeline(context.Background(), *pipeline)
 			if err != nil {
 				logger.Warn("Failed to set root password", zap.Error(err), zap.String("name", req.Name))
 			} else {
@@ -325,8 +370,12 @@ func (lm *LXCManager) CreateContainer(req ContainerCreateRequest) error {
 				logger.Warn("Failed to create .ssh directory", zap.Error(err), zap.String("name", req.Name))
 			} else {
 				// Add SSH key to authorized_keys
-				sshCmd := fmt.Sprintf("echo '%s' >> /root/.ssh/authorized_keys && chmod 600 /root/.ssh/authorized_keys", req.SSHKey)
-				_, err = lm.shell.Execute("lxc-attach", "-n", req.Name, "--", "sh", "-c", sshCmd)
+				var quotedKey string
+				quotedKey, err = sysutil.SanitizeShellArgReject(req.SSHKey)
+				if err == nil {
+					sshCmd := fmt.Sprintf("echo %s >> /root/.ssh/authorized_keys && chmod 600 /root/.ssh/authorized_keys", quotedKey)
+					_, err = lm.shell.Execute("lxc-attach", "-n", req.Name, "--", "sh", "-c", sshCmd)
+				}
 				if err != nil {
 					logger.Warn("Failed to configure SSH key", zap.Error(err), zap.String("name", req.Name))
 				} else {
@@ -363,10 +412,44 @@ func (lm *LXCManager) DeleteContainer(name string) error {
 		return fmt.Errorf("failed to delete container: %s: %w", result.Stderr, err)
 	}
 
+	if lm.netns != nil {
+		if err := lm.netns.Release(models.NamespaceContainerLXC, name); err != nil {
+			logger.Warn("Failed to delete container network namespace", zap.String("name", name), zap.Error(err))
+		}
+	}
+
 	logger.Info("Container deleted", zap.String("name", name))
 	return nil
 }
 
+// configureNetworkNamespace creates a network namespace for a new container
+// and makes the container join it. It reports false if the namespace could
+// not be created, leaving the container to be bridged directly.
+func (lm *LXCManager) configureNetworkNamespace(req ContainerCreateRequest, bridge, configPath string) bool {
+	ns, err := lm.netns.Provision(models.NetworkNamespace{
+		ContainerID:   req.Name,
+		ContainerType: models.NamespaceContainerLXC,
+		Bridge:        bridge,
+		IPv4Address:   req.IPv4Address,
+		IPv4Gateway:   req.IPv4Gateway,
+	})
+	if err != nil {
+		logger.Warn("Failed to create container network namespace, bridging it on the host",
+			zap.String("name", req.Name), zap.Error(err))
+		return false
+	}
+
+	// The namespace already has the container's interface, so LXC creates none
+	line := "lxc.namespace.share.net = " + lm.netns.Path(ns.Name)
+	if _, err := lm.shell.Execute("sh", "-c", fmt.Sprintf("echo %s >> %s", sysutil.SanitizeShellArg(line), configPath)); err != nil {
+		logger.Warn("Failed to configure container network namespace, bridging it on the host",
+			zap.String("name", req.Name), zap.Error(err))
+		lm.netns.Release(models.NamespaceContainerLXC, req.Name)
+		return false
+	}
+	return true
+}
+
 // StartContainer starts an LXC container
 func (lm *LXCManager) StartContainer(name string) error {
 	if !lm.enabled {
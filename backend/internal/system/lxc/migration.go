@@ -0,0 +1,290 @@
+package lxc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// checkpointTimeout bounds dumping or restoring the memory of a container
+	checkpointTimeout = 10 * time.Minute
+	// transferTimeout bounds copying a container and its checkpoint to the target
+	transferTimeout = 2 * time.Hour
+)
+
+var (
+	containerNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// Hosts must not start with a dash, which ssh and rsync would take as an option
+	hostRegex     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.:-]*$`)
+	sshUserRegex  = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+	sshOptions    = []string{"-o", "BatchMode=yes"}
+	rsyncSSHShell = "ssh -o BatchMode=yes"
+)
+
+// CheckpointDir returns the directory migrations dump the container's state to
+func (lm *LXCManager) CheckpointDir(containerID string) string {
+	return filepath.Join(lm.checkpointRoot, containerID)
+}
+
+// CheckpointContainer dumps the state of a running container to
+// checkpointDir with CRIU and stops it
+func (lm *LXCManager) CheckpointContainer(containerID, checkpointDir string) error {
+	if !lm.enabled {
+		return fmt.Errorf("LXC is not enabled")
+	}
+	if !containerNameRegex.MatchString(containerID) {
+		return fmt.Errorf("invalid container name %q", containerID)
+	}
+	if err := os.MkdirAll(checkpointDir, 0700); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	result, err := lm.shell.ExecuteWithTimeout(checkpointTimeout, "lxc-checkpoint", "-n", containerID, "-D", checkpointDir, "-s")
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint container: %s: %w", stderrOf(result), err)
+	}
+
+	logger.Info("Container checkpointed", zap.String("name", containerID), zap.String("dir", checkpointDir))
+	return nil
+}
+
+// RestoreContainer restores a container from a checkpoint. CRIU restores the
+// container running; unless startOnRestore is set, it is frozen right away.
+func (lm *LXCManager) RestoreContainer(containerID, checkpointDir string, startOnRestore bool) error {
+	if !lm.enabled {
+		return fmt.Errorf("LXC is not enabled")
+	}
+	if !containerNameRegex.MatchString(containerID) {
+		return fmt.Errorf("invalid container name %q", containerID)
+	}
+
+	result, err := lm.shell.ExecuteWithTimeout(checkpointTimeout, "lxc-checkpoint", "-n", containerID, "-D", checkpointDir, "-r")
+	if err != nil {
+		return fmt.Errorf("failed to restore container: %s: %w", stderrOf(result), err)
+	}
+	if !startOnRestore {
+		if err := lm.FreezeContainer(containerID); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Container restored", zap.String("name", containerID), zap.Bool("running", startOnRestore))
+	return nil
+}
+
+// MigrateContainer live-migrates a container to the NAS at targetHost. The
+// container is checkpointed, its directory and checkpoint are copied with
+// rsync over SSH as targetUser, and the target's API is called over SSH to
+// restore it, authenticated with apiToken. Both hosts must share the JWT
+// secret. If the migration fails after the checkpoint, the container is
+// restored here.
+func (lm *LXCManager) MigrateContainer(containerID, targetHost, targetUser, apiToken string) error {
+	migration, err := lm.newMigration(containerID, targetHost, targetUser)
+	if err != nil {
+		return err
+	}
+	return lm.runMigration(migration, targetUser, apiToken)
+}
+
+// StartMigration runs MigrateContainer in the background and returns the
+// models.ContainerMigration that tracks its progress
+func (lm *LXCManager) StartMigration(containerID, targetHost, targetUser, apiToken string) (*models.ContainerMigration, error) {
+	migration, err := lm.newMigration(containerID, targetHost, targetUser)
+	if err != nil {
+		return nil, err
+	}
+
+	started := *migration
+	go lm.runMigration(migration, targetUser, apiToken)
+	return &started, nil
+}
+
+func (lm *LXCManager) newMigration(containerID, targetHost, targetUser string) (*models.ContainerMigration, error) {
+	if !lm.enabled {
+		return nil, fmt.Errorf("LXC is not enabled")
+	}
+	if !containerNameRegex.MatchString(containerID) {
+		return nil, fmt.Errorf("invalid container name %q", containerID)
+	}
+	if !hostRegex.MatchString(targetHost) {
+		return nil, fmt.Errorf("invalid target host %q", targetHost)
+	}
+	if !sshUserRegex.MatchString(targetUser) {
+		return nil, fmt.Errorf("invalid target user %q", targetUser)
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	sourceHost, _ := os.Hostname()
+	migration := &models.ContainerMigration{
+		ContainerID: containerID,
+		SourceHost:  sourceHost,
+		TargetHost:  targetHost,
+		Status:      models.MigrationStatusCheckpointing,
+		StartedAt:   time.Now(),
+	}
+	if err := db.Create(migration).Error; err != nil {
+		return nil, fmt.Errorf("failed to create migration: %w", err)
+	}
+	return migration, nil
+}
+
+func (lm *LXCManager) runMigration(migration *models.ContainerMigration, targetUser, apiToken string) error {
+	name := migration.ContainerID
+	dir := lm.CheckpointDir(name)
+	remote := targetUser + "@" + migration.TargetHost
+
+	if err := lm.CheckpointContainer(name, dir); err != nil {
+		return lm.failMigration(migration, err)
+	}
+	defer os.RemoveAll(dir)
+
+	err := func() error {
+		lm.setMigrationStatus(migration, models.MigrationStatusTransferring)
+		containerDir := filepath.Join("/var/lib/lxc", name)
+		if err := lm.rsync(containerDir, remote); err != nil {
+			return err
+		}
+		if err := lm.rsync(dir, remote); err != nil {
+			return err
+		}
+
+		lm.setMigrationStatus(migration, models.MigrationStatusRestoring)
+		return lm.triggerRemoteRestore(name, remote, apiToken)
+	}()
+	if err != nil {
+		// Bring the container back up here rather than leaving it stopped
+		if restoreErr := lm.RestoreContainer(name, dir, true); restoreErr != nil {
+			logger.Error("Failed to restore container after failed migration",
+				zap.String("name", name),
+				zap.Error(restoreErr))
+		}
+		return lm.failMigration(migration, err)
+	}
+
+	now := time.Now()
+	migration.Status = models.MigrationStatusCompleted
+	migration.CompletedAt = &now
+	lm.saveMigration(migration)
+
+	logger.Info("Container migrated",
+		zap.String("name", name),
+		zap.String("target", migration.TargetHost))
+	return nil
+}
+
+// rsync copies a directory to the same path on the remote host
+func (lm *LXCManager) rsync(dir, remote string) error {
+	src := strings.TrimSuffix(dir, "/") + "/"
+	result, err := lm.shell.ExecuteWithTimeout(transferTimeout, "rsync",
+		"-aHAX", "--numeric-ids", "--delete", "--mkpath", "-e", rsyncSSHShell, src, remote+":"+src)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %s: %w", dir, remote, stderrOf(result), err)
+	}
+	return nil
+}
+
+// triggerRemoteRestore calls the restore endpoint of the target's API from
+// the target itself, so the API does not have to be reachable from here
+func (lm *LXCManager) triggerRemoteRestore(name, remote, apiToken string) error {
+	port := 8080
+	if config.GlobalConfig != nil && config.GlobalConfig.Server.Port != 0 {
+		port = config.GlobalConfig.Server.Port
+	}
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/v1/lxc/containers/%s/restore", port, name)
+
+	// ssh runs the command through the remote shell, so every argument is quoted
+	curl := []string{"curl", "-fsS", "-X", "POST",
+		"-H", "Authorization: Bearer " + apiToken,
+		"-H", "Content-Type: application/json",
+		"-d", `{"start":true}`, url}
+	for i, arg := range curl {
+		curl[i] = shellQuote(arg)
+	}
+
+	args := append(append([]string{}, sshOptions...), remote, strings.Join(curl, " "))
+	result, err := lm.shell.ExecuteWithTimeout(checkpointTimeout, "ssh", args...)
+	if err != nil {
+		return fmt.Errorf("failed to restore container on %s: %s: %w", remote, stderrOf(result), err)
+	}
+	return nil
+}
+
+func (lm *LXCManager) setMigrationStatus(migration *models.ContainerMigration, status string) {
+	migration.Status = status
+	lm.saveMigration(migration)
+}
+
+func (lm *LXCManager) failMigration(migration *models.ContainerMigration, err error) error {
+	now := time.Now()
+	migration.Status = models.MigrationStatusFailed
+	migration.Error = err.Error()
+	migration.CompletedAt = &now
+	lm.saveMigration(migration)
+
+	logger.Error("Container migration failed",
+		zap.String("name", migration.ContainerID),
+		zap.String("target", migration.TargetHost),
+		zap.Error(err))
+	return err
+}
+
+func (lm *LXCManager) saveMigration(migration *models.ContainerMigration) {
+	if db := database.GetDB(); db != nil {
+		if err := db.Save(migration).Error; err != nil {
+			logger.Warn("Failed to save container migration", zap.Uint("id", migration.ID), zap.Error(err))
+		}
+	}
+}
+
+// ListMigrations returns the container migrations, most recent first
+func (lm *LXCManager) ListMigrations() ([]models.ContainerMigration, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var migrations []models.ContainerMigration
+	if err := db.Order("started_at DESC").Find(&migrations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	return migrations, nil
+}
+
+// GetMigration returns a container migration
+func (lm *LXCManager) GetMigration(id uint) (*models.ContainerMigration, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var migration models.ContainerMigration
+	if err := db.First(&migration, id).Error; err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// stderrOf returns the trimmed stderr of a command that may not have run
+func stderrOf(result *executor.CommandResult) string {
+	if result == nil {
+		return ""
+	}
+	return strings.TrimSpace(result.Stderr)
+}
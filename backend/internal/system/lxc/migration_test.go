@@ -0,0 +1,103 @@
+package lxc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestLXCManager(t *testing.T) (*LXCManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lxc.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ContainerMigration{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { database.DB = nil })
+
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("which", "lxc-ls").Returns("/usr/bin/lxc-ls\n", "", 0)
+	lm, err := NewLXCManager(shell)
+	if err != nil {
+		t.Fatalf("NewLXCManager: %v", err)
+	}
+	lm.checkpointRoot = t.TempDir()
+	return lm, shell
+}
+
+func TestMigrateContainer(t *testing.T) {
+	lm, shell := newTestLXCManager(t)
+	dir := lm.CheckpointDir("web")
+	shell.ExpectCommand("lxc-checkpoint", "-n", "web", "-D", dir, "-s").Returns("", "", 0)
+	shell.ExpectCommand("rsync").Returns("", "", 0).Times(2)
+	shell.ExpectCommand("ssh").Returns("", "", 0)
+
+	if err := lm.MigrateContainer("web", "nas2.lan", "root", "tok'en"); err != nil {
+		t.Fatalf("MigrateContainer: %v", err)
+	}
+	shell.AssertExpectations(t)
+
+	rsyncs := shell.CallsTo("rsync")
+	if got := rsyncs[0].String(); got != "rsync -aHAX --numeric-ids --delete --mkpath -e ssh -o BatchMode=yes /var/lib/lxc/web/ root@nas2.lan:/var/lib/lxc/web/" {
+		t.Errorf("container rsync = %s", got)
+	}
+	if got := rsyncs[1].Args[len(rsyncs[1].Args)-1]; got != "root@nas2.lan:"+dir+"/" {
+		t.Errorf("checkpoint rsync target = %s", got)
+	}
+
+	ssh := shell.CallsTo("ssh")[0]
+	if ssh.Args[2] != "root@nas2.lan" {
+		t.Errorf("ssh target = %s", ssh.Args[2])
+	}
+	remote := ssh.Args[3]
+	if !strings.Contains(remote, `'Authorization: Bearer tok'\''en'`) ||
+		!strings.HasSuffix(remote, "'http://127.0.0.1:8080/api/v1/lxc/containers/web/restore'") {
+		t.Errorf("remote command = %s", remote)
+	}
+
+	migrations, err := lm.ListMigrations()
+	if err != nil || len(migrations) != 1 {
+		t.Fatalf("ListMigrations = %v, %v", migrations, err)
+	}
+	if m := migrations[0]; m.Status != models.MigrationStatusCompleted || m.TargetHost != "nas2.lan" || m.CompletedAt == nil {
+		t.Errorf("migration = %+v", m)
+	}
+}
+
+func TestMigrateContainerRestoresLocallyOnFailure(t *testing.T) {
+	lm, shell := newTestLXCManager(t)
+	dir := lm.CheckpointDir("web")
+	shell.ExpectCommand("lxc-checkpoint", "-n", "web", "-D", dir, "-s").Returns("", "", 0)
+	shell.ExpectCommand("rsync").Returns("", "ssh: connect to host nas2.lan port 22: Connection refused", 255)
+	shell.ExpectCommand("lxc-checkpoint", "-n", "web", "-D", dir, "-r").Returns("", "", 0)
+
+	if err := lm.MigrateContainer("web", "nas2.lan", "root", "token"); err == nil {
+		t.Fatal("MigrateContainer succeeded")
+	}
+	shell.AssertExpectations(t)
+
+	migrations, _ := lm.ListMigrations()
+	if len(migrations) != 1 || migrations[0].Status != models.MigrationStatusFailed ||
+		!strings.Contains(migrations[0].Error, "Connection refused") {
+		t.Errorf("migrations = %+v", migrations)
+	}
+
+	if err := lm.MigrateContainer("web", "-oProxyCommand=x", "root", "token"); err == nil {
+		t.Error("accepted a target host starting with a dash")
+	}
+}
@@ -0,0 +1,268 @@
+package lxc
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+)
+
+// The lxc.net.0 keys SetContainerNetwork manages. The VLAN of a veth NIC is
+// set with veth.vlan.id (LXC 4.0+, on a bridge with VLAN filtering); vlan.id
+// only applies to NICs of type vlan.
+const (
+	netKeyType     = "lxc.net.0.type"
+	netKeyFlags    = "lxc.net.0.flags"
+	netKeyLink     = "lxc.net.0.link"
+	netKeyName     = "lxc.net.0.name"
+	netKeyVLAN     = "lxc.net.0.vlan.id"
+	netKeyVethVLAN = "lxc.net.0.veth.vlan.id"
+	netKeyIPv4     = "lxc.net.0.ipv4.address"
+	netKeyGateway  = "lxc.net.0.ipv4.gateway"
+	netKeyHWAddr   = "lxc.net.0.hwaddr"
+	netKeyShareNet = "lxc.namespace.share.net"
+)
+
+// Interface names are limited to 15 characters by the kernel
+var ifaceNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,14}$`)
+
+// LXCNetworkConfig is the first NIC of a container: the bridge it is
+// attached to, its VLAN and its static address
+type LXCNetworkConfig struct {
+	ContainerID string `json:"container_id"`
+	NICName     string `json:"nic_name"`    // Name inside the container, e.g. eth0
+	BridgeName  string `json:"bridge_name"` // Host bridge, e.g. br0
+	VLANID      int    `json:"vlan_id"`     // 1-4094, 0 for untagged
+	IPAddress   string `json:"ip_address"`  // CIDR notation, DHCP if empty
+	Gateway     string `json:"gateway"`
+	MACAddress  string `json:"mac_address"` // Kept if empty; xx is filled in randomly by LXC
+}
+
+// Validate checks the config
+func (c *LXCNetworkConfig) Validate() error {
+	if !containerNameRegex.MatchString(c.ContainerID) {
+		return fmt.Errorf("invalid container name %q", c.ContainerID)
+	}
+	if c.NICName != "" && !ifaceNameRegex.MatchString(c.NICName) {
+		return fmt.Errorf("invalid NIC name %q", c.NICName)
+	}
+	if !ifaceNameRegex.MatchString(c.BridgeName) {
+		return fmt.Errorf("invalid bridge name %q", c.BridgeName)
+	}
+	if c.VLANID < 0 || c.VLANID > 4094 {
+		return fmt.Errorf("VLAN ID %d out of range 1-4094", c.VLANID)
+	}
+	if c.IPAddress != "" {
+		if prefix, err := netip.ParsePrefix(c.IPAddress); err != nil || !prefix.Addr().Is4() {
+			return fmt.Errorf("invalid IPv4 address %q: must be in CIDR notation", c.IPAddress)
+		}
+	}
+	if c.Gateway != "" {
+		if c.IPAddress == "" {
+			return fmt.Errorf("a gateway needs a static address")
+		}
+		if addr, err := netip.ParseAddr(c.Gateway); err != nil || !addr.Is4() {
+			return fmt.Errorf("invalid IPv4 gateway %q", c.Gateway)
+		}
+	}
+	if c.MACAddress != "" && !isLXCHWAddr(c.MACAddress) {
+		return fmt.Errorf("invalid MAC address %q", c.MACAddress)
+	}
+	return nil
+}
+
+// isLXCHWAddr reports whether mac is a MAC address, where LXC allows xx
+// for random bytes
+func isLXCHWAddr(mac string) bool {
+	hw, err := net.ParseMAC(strings.ReplaceAll(strings.ToLower(mac), "xx", "00"))
+	return err == nil && len(hw) == 6
+}
+
+// configPath returns the path of a container's config file
+func (lm *LXCManager) configPath(containerID string) string {
+	return filepath.Join(lm.configRoot, containerID, "config")
+}
+
+// GetContainerNetwork returns the first NIC of a container from its config
+// file
+func (lm *LXCManager) GetContainerNetwork(containerID string) (*LXCNetworkConfig, error) {
+	if !lm.enabled {
+		return nil, fmt.Errorf("LXC is not enabled")
+	}
+	if !containerNameRegex.MatchString(containerID) {
+		return nil, fmt.Errorf("invalid container name %q", containerID)
+	}
+
+	data, err := os.ReadFile(lm.configPath(containerID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read container config: %w", err)
+	}
+
+	cfg := &LXCNetworkConfig{ContainerID: containerID}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := parseConfigLine(line)
+		if !ok {
+			continue
+		}
+		switch key {
+		case netKeyLink:
+			cfg.BridgeName = value
+		case netKeyName:
+			cfg.NICName = value
+		case netKeyVLAN, netKeyVethVLAN:
+			cfg.VLANID, _ = strconv.Atoi(value)
+		case netKeyIPv4:
+			cfg.IPAddress = value
+		case netKeyGateway:
+			cfg.Gateway = value
+		case netKeyHWAddr:
+			cfg.MACAddress = value
+		}
+	}
+	return cfg, nil
+}
+
+// SetContainerNetwork attaches the first NIC of a container to a bridge and
+// VLAN and sets its address by editing the container's config file. The
+// other lxc.net.0 settings are kept. The change takes effect when the
+// container is next started.
+func (lm *LXCManager) SetContainerNetwork(cfg LXCNetworkConfig) error {
+	if !lm.enabled {
+		return fmt.Errorf("LXC is not enabled")
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	path := lm.configPath(cfg.ContainerID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read container config: %w", err)
+	}
+
+	updated, err := setNetworkConfig(string(data), cfg)
+	if err != nil {
+		return err
+	}
+
+	// Replace the file in one step, so a failed write leaves the old config
+	if err := sysutil.AtomicWriteFile(path, []byte(updated), 0640); err != nil {
+		return fmt.Errorf("failed to write container config: %w", err)
+	}
+
+	logger.Info("Container network configured",
+		zap.String("name", cfg.ContainerID),
+		zap.String("bridge", cfg.BridgeName),
+		zap.Int("vlan", cfg.VLANID),
+		zap.String("address", cfg.IPAddress))
+	return nil
+}
+
+// setNetworkConfig returns config with the lxc.net.0 directives of cfg.
+// They replace the managed keys where the NIC is defined; the type and
+// flags are added if missing.
+func setNetworkConfig(config string, cfg LXCNetworkConfig) (string, error) {
+	lines := strings.Split(strings.TrimRight(config, "\n"), "\n")
+	if config == "" {
+		lines = nil
+	}
+
+	nicType := "veth"
+	hasFlags := false
+	insertAt := -1
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		key, value, ok := parseConfigLine(line)
+		if !ok {
+			kept = append(kept, line)
+			continue
+		}
+		switch key {
+		case netKeyShareNet:
+			return "", fmt.Errorf("container %s joins a network namespace of its own; its bridge is set there", cfg.ContainerID)
+		case netKeyType:
+			nicType = value
+		case netKeyFlags:
+			hasFlags = true
+		case netKeyLink, netKeyName, netKeyVLAN, netKeyVethVLAN, netKeyIPv4, netKeyGateway:
+			insertAt = len(kept)
+			continue
+		case netKeyHWAddr:
+			if cfg.MACAddress == "" {
+				cfg.MACAddress = value
+			}
+			insertAt = len(kept)
+			continue
+		}
+		kept = append(kept, line)
+		if strings.HasPrefix(key, "lxc.net.0.") {
+			insertAt = len(kept)
+		}
+	}
+
+	switch nicType {
+	case "veth", "vlan", "macvlan":
+	default:
+		return "", fmt.Errorf("NIC of type %s cannot be attached to a bridge", nicType)
+	}
+	if nicType == "vlan" && cfg.VLANID == 0 {
+		return "", fmt.Errorf("NIC of type vlan needs a VLAN ID")
+	}
+
+	var directives []string
+	if insertAt < 0 {
+		directives = append(directives, netKeyType+" = "+nicType)
+	}
+	if !hasFlags {
+		directives = append(directives, netKeyFlags+" = up")
+	}
+	directives = append(directives, netKeyLink+" = "+cfg.BridgeName)
+	if cfg.NICName != "" {
+		directives = append(directives, netKeyName+" = "+cfg.NICName)
+	}
+	if cfg.VLANID > 0 {
+		key := netKeyVethVLAN
+		if nicType == "vlan" {
+			key = netKeyVLAN
+		}
+		directives = append(directives, key+" = "+strconv.Itoa(cfg.VLANID))
+	}
+	if cfg.MACAddress != "" {
+		directives = append(directives, netKeyHWAddr+" = "+cfg.MACAddress)
+	}
+	if cfg.IPAddress != "" {
+		directives = append(directives, netKeyIPv4+" = "+cfg.IPAddress)
+		if cfg.Gateway != "" {
+			directives = append(directives, netKeyGateway+" = "+cfg.Gateway)
+		}
+	}
+
+	if insertAt < 0 {
+		insertAt = len(kept)
+	}
+	result := append(kept[:insertAt:insertAt], directives...)
+	result = append(result, kept[insertAt:]...)
+	return strings.Join(result, "\n") + "\n", nil
+}
+
+// parseConfigLine splits a "key = value" line of an LXC config file.
+// Comments and blank lines are not directives.
+func parseConfigLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
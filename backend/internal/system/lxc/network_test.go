@@ -0,0 +1,191 @@
+package lxc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// downloadConfig is the config of a container created from the download
+// template, with the network set up by CreateContainer
+const downloadConfig = `# Template used to create this container: /usr/share/lxc/templates/lxc-download
+# Parameters passed to the template: --dist debian --release bookworm --arch amd64
+# For additional config options, please look at lxc.container.conf(5)
+
+# Distribution configuration
+lxc.include = /usr/share/lxc/config/common.conf
+lxc.arch = linux64
+
+# Container specific configuration
+lxc.rootfs.path = dir:/var/lib/lxc/web/rootfs
+lxc.uts.name = web
+lxc.cgroup2.memory.max = 512M
+lxc.start.auto = 1
+lxc.net.0.type = veth
+lxc.net.0.link = lxcbr0
+lxc.net.0.flags = up
+lxc.net.0.hwaddr = 00:16:3e:4a:91:0c
+lxc.net.0.ipv4.address = 10.0.3.50/24
+lxc.net.0.ipv4.gateway = 10.0.3.1
+`
+
+func writeContainerConfig(t *testing.T, lm *LXCManager, name, config string) string {
+	t.Helper()
+	lm.configRoot = t.TempDir()
+	path := lm.configPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(config), 0640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSetContainerNetwork(t *testing.T) {
+	lm, _ := newTestLXCManager(t)
+	path := writeContainerConfig(t, lm, "web", downloadConfig)
+
+	err := lm.SetContainerNetwork(LXCNetworkConfig{
+		ContainerID: "web",
+		NICName:     "eth0",
+		BridgeName:  "br0",
+		VLANID:      30,
+		IPAddress:   "192.168.30.20/24",
+		Gateway:     "192.168.30.1",
+	})
+	if err != nil {
+		t.Fatalf("SetContainerNetwork: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The generated MAC is kept
+	want := strings.Replace(downloadConfig, `lxc.net.0.link = lxcbr0
+lxc.net.0.flags = up
+lxc.net.0.hwaddr = 00:16:3e:4a:91:0c
+lxc.net.0.ipv4.address = 10.0.3.50/24
+lxc.net.0.ipv4.gateway = 10.0.3.1
+`, `lxc.net.0.flags = up
+lxc.net.0.link = br0
+lxc.net.0.name = eth0
+lxc.net.0.veth.vlan.id = 30
+lxc.net.0.hwaddr = 00:16:3e:4a:91:0c
+lxc.net.0.ipv4.address = 192.168.30.20/24
+lxc.net.0.ipv4.gateway = 192.168.30.1
+`, 1)
+	if string(data) != want {
+		t.Errorf("config =\n%s\nwant\n%s", data, want)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+
+	cfg, err := lm.GetContainerNetwork("web")
+	if err != nil {
+		t.Fatalf("GetContainerNetwork: %v", err)
+	}
+	wantCfg := &LXCNetworkConfig{
+		ContainerID: "web",
+		NICName:     "eth0",
+		BridgeName:  "br0",
+		VLANID:      30,
+		IPAddress:   "192.168.30.20/24",
+		Gateway:     "192.168.30.1",
+		MACAddress:  "00:16:3e:4a:91:0c",
+	}
+	if !reflect.DeepEqual(cfg, wantCfg) {
+		t.Errorf("network = %+v, want %+v", cfg, wantCfg)
+	}
+
+	// Back to untagged DHCP on the internal bridge
+	if err := lm.SetContainerNetwork(LXCNetworkConfig{ContainerID: "web", BridgeName: "lxcbr0"}); err != nil {
+		t.Fatalf("SetContainerNetwork: %v", err)
+	}
+	cfg, _ = lm.GetContainerNetwork("web")
+	wantCfg = &LXCNetworkConfig{ContainerID: "web", BridgeName: "lxcbr0", MACAddress: "00:16:3e:4a:91:0c"}
+	if !reflect.DeepEqual(cfg, wantCfg) {
+		t.Errorf("network = %+v, want %+v", cfg, wantCfg)
+	}
+}
+
+func TestSetNetworkConfig(t *testing.T) {
+	tests := []struct {
+		name, config string
+		cfg          LXCNetworkConfig
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:   "no NIC",
+			config: "lxc.uts.name = db\n",
+			cfg:    LXCNetworkConfig{ContainerID: "db", BridgeName: "br1", MACAddress: "00:16:3e:xx:xx:xx"},
+			want:   "lxc.uts.name = db\nlxc.net.0.type = veth\nlxc.net.0.flags = up\nlxc.net.0.link = br1\nlxc.net.0.hwaddr = 00:16:3e:xx:xx:xx\n",
+		},
+		{
+			name:   "vlan NIC",
+			config: "lxc.net.0.type=vlan\nlxc.net.0.link=eno1\nlxc.net.0.vlan.id=10\nlxc.net.0.flags=up\n",
+			cfg:    LXCNetworkConfig{ContainerID: "db", BridgeName: "eno2", VLANID: 20},
+			want:   "lxc.net.0.type=vlan\nlxc.net.0.flags=up\nlxc.net.0.link = eno2\nlxc.net.0.vlan.id = 20\n",
+		},
+		{
+			name:    "vlan NIC without VLAN",
+			config:  "lxc.net.0.type = vlan\n",
+			cfg:     LXCNetworkConfig{ContainerID: "db", BridgeName: "eno1"},
+			wantErr: true,
+		},
+		{
+			name:    "own network namespace",
+			config:  "lxc.namespace.share.net = /run/netns/lxc-db\n",
+			cfg:     LXCNetworkConfig{ContainerID: "db", BridgeName: "br0"},
+			wantErr: true,
+		},
+		{
+			name:    "physical NIC",
+			config:  "lxc.net.0.type = phys\nlxc.net.0.link = eno3\n",
+			cfg:     LXCNetworkConfig{ContainerID: "db", BridgeName: "br0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setNetworkConfig(tt.config, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("config =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLXCNetworkConfigValidate(t *testing.T) {
+	valid := LXCNetworkConfig{ContainerID: "web", BridgeName: "br0", VLANID: 4094, IPAddress: "10.0.0.5/24", Gateway: "10.0.0.1", MACAddress: "00:16:3E:xx:xx:01"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate(%+v): %v", valid, err)
+	}
+
+	invalid := []func(c *LXCNetworkConfig){
+		func(c *LXCNetworkConfig) { c.ContainerID = "../etc" },
+		func(c *LXCNetworkConfig) { c.BridgeName = "" },
+		func(c *LXCNetworkConfig) { c.BridgeName = "br0\nlxc.hook.start = /tmp/x" },
+		func(c *LXCNetworkConfig) { c.NICName = "a-very-long-nic-name" },
+		func(c *LXCNetworkConfig) { c.VLANID = 4095 },
+		func(c *LXCNetworkConfig) { c.IPAddress = "10.0.0.5" },
+		func(c *LXCNetworkConfig) { c.IPAddress = ""; c.Gateway = "10.0.0.1" },
+		func(c *LXCNetworkConfig) { c.Gateway = "fe80::1" },
+		func(c *LXCNetworkConfig) { c.MACAddress = "00:16:3e" },
+	}
+	for i, mutate := range invalid {
+		cfg := valid
+		mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("case %d: Validate(%+v) succeeded", i, cfg)
+		}
+	}
+}
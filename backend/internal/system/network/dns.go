@@ -3,6 +3,7 @@ package network
 
 import (
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"fmt"
 	"os"
 	"strings"
@@ -99,7 +100,7 @@ func (d *DNSManager) SetConfig(config DNSConfig) error {
 	content := strings.Join(lines, "\n") + "\n"
 
 	// Write to resolv.conf
-	err := os.WriteFile("/etc/resolv.conf", []byte(content), 0644)
+	err := sysutil.AtomicWriteFile("/etc/resolv.conf", []byte(content), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write resolv.conf: %w", err)
 	}
@@ -163,7 +164,7 @@ func (d *DNSManager) SetHostname(hostname string) error {
 	}
 
 	// Write to /etc/hostname for persistence
-	err = os.WriteFile("/etc/hostname", []byte(hostname+"\n"), 0644)
+	err = sysutil.AtomicWriteFile("/etc/hostname", []byte(hostname+"\n"), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write /etc/hostname: %w", err)
 	}
@@ -216,7 +217,7 @@ func (d *DNSManager) UpdateHosts(hostname string, ip string) error {
 	}
 
 	content := strings.Join(newLines, "\n")
-	err = os.WriteFile("/etc/hosts", []byte(content), 0644)
+	err = sysutil.AtomicWriteFile("/etc/hosts", []byte(content), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write /etc/hosts: %w", err)
 	}
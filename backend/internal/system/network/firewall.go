@@ -3,6 +3,7 @@ package network
 
 import (
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"context"
 	"fmt"
 	"strings"
 )
@@ -355,9 +356,7 @@ func (f *FirewallManager) SaveRules() error {
 			}
 
 			// Write to file
-			_, err = f.shell.Execute("bash", "-c",
-				fmt.Sprintf("echo '%s' > /etc/iptables/rules.v4", result.Stdout))
-			if err != nil {
+			if err := f.writeRulesFile("/etc/iptables/rules.v4", result.Stdout); err != nil {
 				return fmt.Errorf("failed to write rules file: %w", err)
 			}
 		}
@@ -371,9 +370,7 @@ func (f *FirewallManager) SaveRules() error {
 			return fmt.Errorf("failed to save nftables rules: %w", err)
 		}
 
-		_, err = f.shell.Execute("bash", "-c",
-			fmt.Sprintf("echo '%s' > /etc/nftables.conf", result.Stdout))
-		if err != nil {
+		if err := f.writeRulesFile("/etc/nftables.conf", result.Stdout); err != nil {
 			return fmt.Errorf("failed to write rules file: %w", err)
 		}
 	}
@@ -381,6 +378,15 @@ func (f *FirewallManager) SaveRules() error {
 	return nil
 }
 
+// writeRulesFile writes saved rules to path. The rules are passed to tee on
+// stdin rather than through a shell, so they are written verbatim.
+func (f *FirewallManager) writeRulesFile(path, rules string) error {
+	write := executor.NewPipeline(executor.PipelineStage{Command: "tee", Args: []string{path}}).
+		WithStdin(rules)
+	_, err := f.shell.RunPipeline(context.Background(), *write)
+	return err
+}
+
 // RestoreRules restores rules from file
 func (f *FirewallManager) RestoreRules() error {
 	if !f.enabled {
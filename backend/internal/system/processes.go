@@ -0,0 +1,218 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cpuSampleInterval is the time between the two CPU time samples
+// CPUPercent is computed from
+const cpuSampleInterval = 100 * time.Millisecond
+
+// clockTicks is the kernel's USER_HZ, the unit of the CPU and start times
+// in /proc/<pid>/stat
+const clockTicks = 100
+
+// procPath is where the kernel exposes the running processes. Replaced in tests.
+var procPath = "/proc"
+
+// nasProcessPrefixes are the name prefixes of the daemons GetNASProcesses
+// lists. The name of the WireGuard kernel threads is wg-crypt-<interface>;
+// strongSwan runs as charon.
+var nasProcessPrefixes = []string{
+	"smbd", "nmbd", "nfsd", "rpcbind", "openvpn", "wg-", "dockerd", "containerd",
+	"pacemaker", "corosync", "keepalived", "xl2tpd", "strongswan", "charon", "stumpfworks",
+}
+
+// ProcessInfo describes a running NAS process
+type ProcessInfo struct {
+	PID        int     `json:"pid"`
+	Name       string  `json:"name"`    // Truncated to 15 characters by the kernel
+	Command    string  `json:"command"` // Arguments separated by spaces, empty for kernel threads
+	State      string  `json:"state"`   // e.g. "S (sleeping)"
+	RSS        int64   `json:"rss"`     // Resident memory in kB
+	VmSwap     int64   `json:"vmSwap"`  // Swapped out memory in kB
+	CPUPercent float64 `json:"cpuPercent"`
+	OpenFiles  int     `json:"openFiles"` // -1 if the file descriptors cannot be read
+	Uptime     int64   `json:"uptime"`    // Seconds since the process started
+}
+
+// GetNASProcesses lists the running NAS daemons (Samba, NFS, VPN, Docker,
+// cluster and the server itself) with their memory and CPU use. CPU use is
+// measured over 100ms, so the call takes at least that long.
+func GetNASProcesses() ([]ProcessInfo, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	uptime, err := systemUptime()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the system uptime: %w", err)
+	}
+
+	var processes []ProcessInfo
+	var startTicks []uint64
+	self := os.Getpid()
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// Processes may exit while they are read
+		info, err := readProcessStatus(pid)
+		if err != nil {
+			continue
+		}
+		command := readProcessCmdline(pid)
+		if pid != self && !isNASProcess(info.Name, command) {
+			continue
+		}
+		stat, err := readProcessStat(pid)
+		if err != nil {
+			continue
+		}
+		info.Command = command
+		info.OpenFiles = countOpenFiles(pid)
+		info.Uptime = int64(uptime - float64(stat.startTime)/clockTicks)
+		processes = append(processes, *info)
+		startTicks = append(startTicks, stat.cpuTicks)
+	}
+
+	time.Sleep(cpuSampleInterval)
+	for i := range processes {
+		stat, err := readProcessStat(processes[i].PID)
+		if err != nil || stat.cpuTicks < startTicks[i] {
+			continue
+		}
+		seconds := float64(stat.cpuTicks-startTicks[i]) / clockTicks
+		processes[i].CPUPercent = seconds / cpuSampleInterval.Seconds() * 100
+	}
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+	return processes, nil
+}
+
+// isNASProcess reports whether a process is one of the NAS daemons, by its
+// name or the executable of its command line
+func isNASProcess(name, command string) bool {
+	executable := filepath.Base(strings.SplitN(command, " ", 2)[0])
+	for _, prefix := range nasProcessPrefixes {
+		if strings.HasPrefix(name, prefix) || (command != "" && strings.HasPrefix(executable, prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readProcessStatus reads the name, state and memory of a process from
+// /proc/<pid>/status
+func readProcessStatus(pid int) (*ProcessInfo, error) {
+	f, err := os.Open(filepath.Join(procPath, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &ProcessInfo{PID: pid}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Name":
+			info.Name = value
+		case "State":
+			info.State = value
+		case "VmRSS":
+			info.RSS = parseStatusKB(value)
+		case "VmSwap":
+			info.VmSwap = parseStatusKB(value)
+		}
+	}
+	return info, scanner.Err()
+}
+
+// parseStatusKB parses a /proc/<pid>/status size like "10240 kB"
+func parseStatusKB(value string) int64 {
+	kb, _ := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(value, "kB")), 10, 64)
+	return kb
+}
+
+// readProcessCmdline returns the command line of a process with its
+// arguments separated by spaces
+func readProcessCmdline(pid int) string {
+	cmdline, err := os.ReadFile(filepath.Join(procPath, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return ""
+	}
+	return strings.Join(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"), " ")
+}
+
+// processStat are the CPU and start times of a process in clock ticks
+type processStat struct {
+	cpuTicks  uint64 // User and system time
+	startTime uint64 // Since boot
+}
+
+// readProcessStat reads /proc/<pid>/stat
+func readProcessStat(pid int) (*processStat, error) {
+	data, err := os.ReadFile(filepath.Join(procPath, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+	return parseProcessStat(string(data))
+}
+
+// parseProcessStat parses a /proc/<pid>/stat line. The name in parentheses
+// may contain spaces, so fields are counted after it: utime and stime are
+// fields 14 and 15, starttime is field 22.
+func parseProcessStat(stat string) (*processStat, error) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed stat %q", stat)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("malformed stat %q", stat)
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	start, err3 := strconv.ParseUint(fields[19], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, fmt.Errorf("malformed stat %q", stat)
+	}
+	return &processStat{cpuTicks: utime + stime, startTime: start}, nil
+}
+
+// countOpenFiles counts the file descriptors of a process, or returns -1
+// if they cannot be read, which needs root for the processes of other users
+func countOpenFiles(pid int) int {
+	fds, err := os.ReadDir(filepath.Join(procPath, strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
+
+// systemUptime returns the seconds since boot from /proc/uptime
+func systemUptime() (float64, error) {
+	data, err := os.ReadFile(filepath.Join(procPath, "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("malformed %s/uptime", procPath)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
@@ -0,0 +1,121 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// smbdStatus is /proc/<pid>/status of a Samba daemon
+const smbdStatus = `Name:	smbd
+Umask:	0022
+State:	S (sleeping)
+Tgid:	1234
+Pid:	1234
+PPid:	1
+VmPeak:	  112436 kB
+VmSize:	  110388 kB
+VmHWM:	   24212 kB
+VmRSS:	   21504 kB
+RssAnon:	    5120 kB
+RssFile:	   16384 kB
+VmSwap:	     768 kB
+Threads:	1
+`
+
+// writeFakeProcess adds a process to a fake /proc
+func writeFakeProcess(t *testing.T, proc string, pid int, status, cmdline, stat string, fds int) {
+	t.Helper()
+	dir := filepath.Join(proc, strconv.Itoa(pid))
+	if err := os.MkdirAll(filepath.Join(dir, "fd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"status": status, "cmdline": cmdline, "stat": stat} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < fds; i++ {
+		if err := os.WriteFile(filepath.Join(dir, "fd", strconv.Itoa(i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetNASProcesses(t *testing.T) {
+	proc := t.TempDir()
+	real := procPath
+	procPath = proc
+	t.Cleanup(func() { procPath = real })
+
+	// Booted 1000 seconds ago; smbd started 100 seconds after boot
+	if err := os.WriteFile(filepath.Join(proc, "uptime"), []byte("1000.52 3950.10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFakeProcess(t, proc, 1234, smbdStatus, "/usr/sbin/smbd\x00--foreground\x00--no-process-group\x00",
+		"1234 (smbd) S 1 1234 1234 0 -1 4194560 5432 0 12 0 150 50 0 0 20 0 1 0 10000 113037312 5376 18446744073709551615", 7)
+	writeFakeProcess(t, proc, 88, "Name:\twg-crypt-wg0\nState:\tI (idle)\n", "",
+		"88 (wg-crypt-wg0) I 2 0 0 0 -1 69238880 0 0 0 0 0 0 0 0 0 -20 1 0 500 0 0 18446744073709551615", 0)
+	writeFakeProcess(t, proc, 4321, "Name:\tbash\nState:\tS (sleeping)\nVmRSS:\t    4096 kB\n", "-bash\x00",
+		"4321 (bash) S 1 4321 4321 0 -1 4194560 0 0 0 0 1 1 0 0 20 0 1 0 90000 0 0 18446744073709551615", 3)
+
+	processes, err := GetNASProcesses()
+	if err != nil {
+		t.Fatalf("GetNASProcesses: %v", err)
+	}
+	if len(processes) != 2 {
+		t.Fatalf("processes = %+v, want wg-crypt-wg0 and smbd", processes)
+	}
+
+	wg, smbd := processes[0], processes[1]
+	if wg.Name != "wg-crypt-wg0" || wg.Command != "" || wg.Uptime != 995 {
+		t.Errorf("wg-crypt-wg0 = %+v", wg)
+	}
+	want := ProcessInfo{
+		PID:       1234,
+		Name:      "smbd",
+		Command:   "/usr/sbin/smbd --foreground --no-process-group",
+		State:     "S (sleeping)",
+		RSS:       21504,
+		VmSwap:    768,
+		OpenFiles: 7,
+		Uptime:    900,
+	}
+	if smbd != want {
+		t.Errorf("smbd =\n%+v\nwant\n%+v", smbd, want)
+	}
+}
+
+func TestParseProcessStat(t *testing.T) {
+	// Names may contain spaces and parentheses
+	stat, err := parseProcessStat("42 (tmux: server) (x)) S 1 42 42 0 -1 4194368 100 0 0 0 320 80 0 0 20 0 1 0 777 0 0")
+	if err != nil {
+		t.Fatalf("parseProcessStat: %v", err)
+	}
+	if stat.cpuTicks != 400 || stat.startTime != 777 {
+		t.Errorf("stat = %+v, want 400 CPU ticks and start time 777", stat)
+	}
+	if _, err := parseProcessStat("42 (short) S 1 2"); err == nil {
+		t.Error("parsed a truncated stat")
+	}
+}
+
+func TestIsNASProcess(t *testing.T) {
+	tests := []struct {
+		name, command string
+		want          bool
+	}{
+		{"smbd-notifyd", "/usr/libexec/samba/samba-dcerpcd", true},
+		{"stumpfworks-ser", "/usr/local/bin/stumpfworks-server --config /etc/stumpfworks/config.yaml", true},
+		{"containerd-shim", "/usr/bin/containerd-shim-runc-v2 -namespace moby", true},
+		{"python3", "/usr/bin/python3 /usr/bin/keepalived-notify", false},
+		{"wget", "wget https://example.com", false},
+		{"charon", "/usr/lib/ipsec/charon", true},
+	}
+	for _, tt := range tests {
+		if got := isNASProcess(tt.name, tt.command); got != tt.want {
+			t.Errorf("isNASProcess(%q, %q) = %v, want %v", tt.name, tt.command, got, tt.want)
+		}
+	}
+}
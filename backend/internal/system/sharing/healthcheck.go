@@ -0,0 +1,18 @@
+package sharing
+
+import "github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+
+func init() {
+	sysutil.RegisterComponents("sharing",
+		// Samba (SMB shares)
+		sysutil.ComponentDefinition{Name: "Samba (smbd)", Command: "smbd", VersionFlag: "--version", ServiceName: "smbd"},
+		sysutil.ComponentDefinition{Name: "Samba (nmbd)", Command: "nmbd", ServiceName: "nmbd"},
+		sysutil.ComponentDefinition{Name: "smbpasswd", Command: "smbpasswd"},
+		sysutil.ComponentDefinition{Name: "pdbedit", Command: "pdbedit"},
+		sysutil.ComponentDefinition{Name: "testparm", Command: "testparm"},
+
+		// NFS
+		sysutil.ComponentDefinition{Name: "NFS exportfs", Command: "exportfs"},
+		sysutil.ComponentDefinition{Name: "NFS rpcbind", Command: "rpcbind", ServiceName: "rpcbind"},
+	)
+}
@@ -3,6 +3,7 @@ package sharing
 
 import (
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"fmt"
 	"os"
 	"strings"
@@ -258,7 +259,7 @@ func (n *NFSManager) CreateExport(export NFSExport) error {
 	// Append to exports file
 	config += exportLine
 
-	err = os.WriteFile(n.exportsPath, []byte(config), 0644)
+	err = sysutil.AtomicWriteFile(n.exportsPath, []byte(config), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write exports: %w", err)
 	}
@@ -304,7 +305,7 @@ func (n *NFSManager) DeleteExport(path string) error {
 
 	// Write back
 	config := strings.Join(newLines, "\n")
-	err = os.WriteFile(n.exportsPath, []byte(config), 0644)
+	err = sysutil.AtomicWriteFile(n.exportsPath, []byte(config), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write exports: %w", err)
 	}
@@ -2,7 +2,9 @@
 package sharing
 
 import (
+	"context"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"fmt"
 	"os"
 	"strings"
@@ -274,7 +276,7 @@ func (s *SambaManager) CreateShare(share SambaShare) error {
 	config += shareConfig
 
 	// Write back
-	err = os.WriteFile(s.configPath, []byte(config), 0644)
+	err = sysutil.AtomicWriteFile(s.configPath, []byte(config), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
@@ -337,7 +339,7 @@ func (s *SambaManager) DeleteShare(name string) error {
 
 	// Write back
 	config := strings.Join(newLines, "\n")
-	err = os.WriteFile(s.configPath, []byte(config), 0644)
+	err = sysutil.AtomicWriteFile(s.configPath, []byte(config), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
@@ -355,8 +357,9 @@ func (s *SambaManager) AddUser(username string, password string) error {
 	}
 
 	// Add to Samba
-	_, err = s.shell.Execute("bash", "-c",
-		fmt.Sprintf("(echo %s; echo %s) | smbpasswd -a -s %s", password, password, username))
+	pipeline := executor.NewPipeline(executor.PipelineStage{Command: "smbpasswd", Args: []string{"-a", "-s", username}}).
+		WithStdin(password + "\n" + password + "\n")
+	_, err = s.shell.RunPipeline(context.Background(), *pipeline)
 	if err != nil {
 		return fmt.Errorf("failed to add samba user: %w", err)
 	}
@@ -397,8 +400,9 @@ func (s *SambaManager) DeleteUser(username string) error {
 
 // SetUserPassword sets a Samba user's password
 func (s *SambaManager) SetUserPassword(username string, password string) error {
-	_, err := s.shell.Execute("bash", "-c",
-		fmt.Sprintf("(echo %s; echo %s) | smbpasswd -s %s", password, password, username))
+	pipeline := executor.NewPipeline(executor.PipelineStage{Command: "smbpasswd", Args: []string{"-s", username}}).
+		WithStdin(password + "\n" + password + "\n")
+	_, err := s.shell.RunPipeline(context.Background(), *pipeline)
 	if err != nil {
 		return fmt.Errorf("failed to set password: %w", err)
 	}
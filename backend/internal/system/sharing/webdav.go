@@ -2,6 +2,7 @@
 package sharing
 
 import (
+	"context"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
 	"fmt"
 )
@@ -247,15 +248,16 @@ func (w *WebDAVManager) CreateHTPasswdFile(path string, users []WebDAVUser) erro
 		var args []string
 		if i == 0 {
 			// First user creates the file
-			args = []string{"-c", "-B", path, user.Username}
+			args = []string{"-i", "-c", "-B", path, user.Username}
 		} else {
 			// Subsequent users append
-			args = []string{"-B", path, user.Username}
+			args = []string{"-i", "-B", path, user.Username}
 		}
 
-		// Use echo to pipe password to htpasswd
-		cmd := fmt.Sprintf("echo '%s' | htpasswd -i %s", user.Password, args[len(args)-1])
-		_, err := w.shell.Execute("sh", "-c", cmd)
+		// htpasswd -i reads the password from stdin
+		pipeline := executor.NewPipeline(executor.PipelineStage{Command: "htpasswd", Args: args}).
+			WithStdin(user.Password + "\n")
+		_, err := w.shell.RunPipeline(context.Background(), *pipeline)
 		if err != nil {
 			return fmt.Errorf("failed to add user %s: %w", user.Username, err)
 		}
@@ -270,8 +272,9 @@ func (w *WebDAVManager) AddUserToHTPasswd(htpasswdPath string, username string,
 		return fmt.Errorf("WebDAV not available")
 	}
 
-	cmd := fmt.Sprintf("echo '%s' | htpasswd -iB %s %s", password, htpasswdPath, username)
-	_, err := w.shell.Execute("sh", "-c", cmd)
+	pipeline := executor.NewPipeline(executor.PipelineStage{Command: "htpasswd", Args: []string{"-iB", htpasswdPath, username}}).
+		WithStdin(password + "\n")
+	_, err := w.shell.RunPipeline(context.Background(), *pipeline)
 	if err != nil {
 		return fmt.Errorf("failed to add user: %w", err)
 	}
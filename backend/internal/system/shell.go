@@ -12,6 +12,7 @@ import (
 
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"go.uber.org/zap"
 )
 
@@ -207,16 +208,62 @@ func (s *ShellExecutor) ExecutePipe(commands ...string) (*executor.CommandResult
 	return s.ExecuteScript(pipeCommand, nil)
 }
 
-// CommandExists checks if a command exists in PATH
+// RunPipeline runs commands connected by pipes, without a shell. The input
+// of the pipeline is never logged.
+func (s *ShellExecutor) RunPipeline(ctx context.Context, pipeline executor.Pipeline) (*executor.CommandResult, error) {
+	s.mu.RLock()
+	dryRun := s.dryRun
+	timeout := s.defaultTimeout
+	s.mu.RUnlock()
+
+	if pipeline.Timeout <= 0 {
+		pipeline.Timeout = timeout
+	}
+
+	logger.Debug("Executing pipeline",
+		zap.String("pipeline", pipeline.String()),
+		zap.Duration("timeout", pipeline.Timeout),
+		zap.Bool("dry_run", dryRun))
+
+	if dryRun {
+		logger.Info("[DRY RUN] Pipeline would be executed", zap.String("pipeline", pipeline.String()))
+		return &executor.CommandResult{
+			Command: pipeline.String(),
+			Stdout:  "[DRY RUN] Command not executed",
+			Success: true,
+			DryRun:  true,
+		}, nil
+	}
+
+	result, err := executor.RunPipeline(ctx, pipeline)
+	if err != nil {
+		stderr := ""
+		if result != nil {
+			stderr = result.Stderr
+		}
+		logger.Error("Pipeline failed",
+			zap.String("pipeline", pipeline.String()),
+			zap.String("stderr", stderr),
+			zap.Error(err))
+	}
+	return result, err
+}
+
+// PipeCommands runs the stages connected by pipes, without a shell, with
+// the default timeout
+func (s *ShellExecutor) PipeCommands(ctx context.Context, stages []executor.PipelineStage) (*executor.CommandResult, error) {
+	return s.RunPipeline(ctx, executor.Pipeline{Stages: stages})
+}
+
+// CommandExists checks if a command exists in PATH or the common system paths
 func (s *ShellExecutor) CommandExists(command string) bool {
-	_, err := exec.LookPath(command)
-	return err == nil
+	return sysutil.CommandExists(command)
 }
 
 // FindCommand searches for a command in PATH and common locations
 func (s *ShellExecutor) FindCommand(command string, commonPaths ...string) (string, error) {
-	// Try PATH first
-	if path, err := exec.LookPath(command); err == nil {
+	// Try PATH and the common system paths first
+	if path, err := sysutil.FindCommandInPaths(command, sysutil.DefaultSearchPaths); err == nil {
 		return path, nil
 	}
 
@@ -3,6 +3,7 @@ package storage
 
 import (
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -156,35 +157,116 @@ func (b *BTRFSManager) Scrub(path string) error {
 	return nil
 }
 
-// GetUsage gets filesystem usage
+// GetUsage gets the device size and used bytes of a filesystem
 func (b *BTRFSManager) GetUsage(path string) (uint64, uint64, error) {
-	result, err := b.shell.Execute("btrfs", "filesystem", "usage", "-b", path)
+	usage, err := b.GetFilesystemUsage(path)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get usage: %w", err)
+		return 0, 0, err
 	}
+	return usage.DeviceSize, usage.Used, nil
+}
 
-	var totalSize, used uint64
-	lines := strings.Split(result.Stdout, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "Device size:") {
-			parts := strings.Fields(line)
-			if len(parts) >= 3 {
-				if size, err := strconv.ParseUint(parts[2], 10, 64); err == nil {
-					totalSize = size
+// BTRFSUsage is the space usage of a BTRFS filesystem, as reported by
+// btrfs filesystem usage. Sizes are in bytes.
+type BTRFSUsage struct {
+	DeviceSize        uint64            `json:"device_size"`
+	DeviceAllocated   uint64            `json:"device_allocated"`
+	DeviceUnallocated uint64            `json:"device_unallocated"`
+	DeviceMissing     uint64            `json:"device_missing"`
+	Used              uint64            `json:"used"`
+	FreeEstimated     uint64            `json:"free_estimated"`
+	FreeMin           uint64            `json:"free_min"` // Free space if all unallocated space gets the most wasteful profile
+	DataRatio         float64           `json:"data_ratio"`
+	MetadataRatio     float64           `json:"metadata_ratio"`
+	GlobalReserve     uint64            `json:"global_reserve"`
+	BlockGroups       []BTRFSBlockGroup `json:"block_groups"`
+}
+
+// BTRFSBlockGroup is the allocation of one block group type, e.g. Data
+// with the RAID1 profile
+type BTRFSBlockGroup struct {
+	Type    string `json:"type"`    // Data, Metadata or System
+	Profile string `json:"profile"` // single, DUP, RAID1, ...
+	Size    uint64 `json:"size"`
+	Used    uint64 `json:"used"`
+}
+
+// GetFilesystemUsage returns the space usage of the filesystem mounted at
+// mountPoint
+func (b *BTRFSManager) GetFilesystemUsage(mountPoint string) (*BTRFSUsage, error) {
+	result, err := b.shell.Execute("btrfs", "filesystem", "usage", "-b", mountPoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return ParseBTRFSUsage(result.Stdout)
+}
+
+// ParseBTRFSUsage parses the output of btrfs filesystem usage -b
+func ParseBTRFSUsage(output string) (*BTRFSUsage, error) {
+	usage := &BTRFSUsage{BlockGroups: []BTRFSBlockGroup{}}
+	found := false
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		// Block groups: "Data,RAID1: Size:10737418240, Used:8518627328 (79.34%)"
+		if kind, profile, ok := strings.Cut(key, ","); ok && strings.HasPrefix(value, "Size:") {
+			group := BTRFSBlockGroup{Type: kind, Profile: profile}
+			for _, field := range strings.Split(value, ",") {
+				name, size, _ := strings.Cut(strings.TrimSpace(field), ":")
+				size, _, _ = strings.Cut(size, " ")
+				switch name {
+				case "Size":
+					group.Size, _ = strconv.ParseUint(size, 10, 64)
+				case "Used":
+					group.Used, _ = strconv.ParseUint(size, 10, 64)
 				}
 			}
+			usage.BlockGroups = append(usage.BlockGroups, group)
+			continue
 		}
-		if strings.Contains(line, "Used:") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				if size, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
-					used = size
-				}
+
+		// Overall values, some followed by a note: "96683741184	(min: 49451687936)"
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		number, _ := strconv.ParseUint(fields[0], 10, 64)
+		switch key {
+		case "Device size":
+			usage.DeviceSize = number
+			found = true
+		case "Device allocated":
+			usage.DeviceAllocated = number
+		case "Device unallocated":
+			usage.DeviceUnallocated = number
+		case "Device missing":
+			usage.DeviceMissing = number
+		case "Used":
+			usage.Used = number
+		case "Free (estimated)":
+			usage.FreeEstimated = number
+			if len(fields) == 3 && fields[1] == "(min:" {
+				usage.FreeMin, _ = strconv.ParseUint(strings.TrimSuffix(fields[2], ")"), 10, 64)
 			}
+		case "Data ratio":
+			usage.DataRatio, _ = strconv.ParseFloat(fields[0], 64)
+		case "Metadata ratio":
+			usage.MetadataRatio, _ = strconv.ParseFloat(fields[0], 64)
+		case "Global reserve":
+			usage.GlobalReserve = number
 		}
 	}
 
-	return totalSize, used, nil
+	if !found {
+		return nil, fmt.Errorf("unexpected btrfs filesystem usage output")
+	}
+	return usage, nil
 }
 
 // ===== Advanced BTRFS Features =====
@@ -196,7 +278,8 @@ type BTRFSSubvolume struct {
 	TopLevel   uint64 `json:"top_level"`
 	Path       string `json:"path"`
 	UUID       string `json:"uuid"`
-	ParentUUID string `json:"parent_uuid,omitempty"`
+	ParentUUID string `json:"parent_uuid,omitempty"` // Set for snapshots
+	ReadOnly   bool   `json:"read_only"`
 }
 
 // CreateSubvolume creates a new BTRFS subvolume
@@ -227,53 +310,103 @@ func (b *BTRFSManager) DeleteSubvolume(path string) error {
 	return nil
 }
 
-// ListSubvolumes lists all subvolumes in a filesystem
-func (b *BTRFSManager) ListSubvolumes(path string) ([]BTRFSSubvolume, error) {
+// ListSubvolumes lists all subvolumes of the filesystem mounted at mountPoint
+func (b *BTRFSManager) ListSubvolumes(mountPoint string) ([]BTRFSSubvolume, error) {
 	if !b.enabled {
 		return nil, fmt.Errorf("BTRFS not available")
 	}
 
-	result, err := b.shell.Execute("btrfs", "subvolume", "list", "-p", "-u", path)
+	result, err := b.shell.Execute("btrfs", "subvolume", "list", "-p", "-u", "-q", mountPoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list subvolumes: %w", err)
 	}
+	subvolumes := ParseSubvolumeList(result.Stdout)
 
-	var subvolumes []BTRFSSubvolume
-	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
+	// The read-only flag is not part of the listing; -r lists only
+	// read-only subvolumes
+	result, err = b.shell.Execute("btrfs", "subvolume", "list", "-r", mountPoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list read-only subvolumes: %w", err)
+	}
+	readOnly := make(map[uint64]bool)
+	for _, subvol := range ParseSubvolumeList(result.Stdout) {
+		readOnly[subvol.ID] = true
+	}
+	for i := range subvolumes {
+		subvolumes[i].ReadOnly = readOnly[subvolumes[i].ID]
+	}
+
+	return subvolumes, nil
+}
 
-		fields := strings.Fields(line)
-		if len(fields) < 9 {
+// ParseSubvolumeList parses the output of btrfs subvolume list, e.g.
+// "ID 257 gen 12 parent 5 top level 5 parent_uuid - uuid 1b2c... path home".
+// Paths may contain spaces; they are always the last column.
+func ParseSubvolumeList(output string) []BTRFSSubvolume {
+	subvolumes := []BTRFSSubvolume{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "ID ") {
 			continue
 		}
 
-		subvol := BTRFSSubvolume{}
-
-		// Parse ID
-		if id, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
-			subvol.ID = id
+		head, path, ok := strings.Cut(line, " path ")
+		if !ok {
+			continue
 		}
-
-		// Parse parent ID
-		if pid, err := strconv.ParseUint(fields[3], 10, 64); err == nil {
-			subvol.ParentID = pid
+		subvol := BTRFSSubvolume{Path: path}
+
+		fields := strings.Fields(head)
+		for i := 0; i+1 < len(fields); i++ {
+			value := fields[i+1]
+			switch fields[i] {
+			case "ID":
+				subvol.ID, _ = strconv.ParseUint(value, 10, 64)
+			case "parent":
+				subvol.ParentID, _ = strconv.ParseUint(value, 10, 64)
+			case "level":
+				if fields[i-1] == "top" {
+					subvol.TopLevel, _ = strconv.ParseUint(value, 10, 64)
+				}
+			case "uuid":
+				subvol.UUID = value
+			case "parent_uuid":
+				if value != "-" {
+					subvol.ParentUUID = value
+				}
+			default:
+				continue
+			}
+			i++
 		}
+		subvolumes = append(subvolumes, subvol)
+	}
+	return subvolumes
+}
 
-		// Parse UUID
-		if len(fields) >= 7 {
-			subvol.UUID = fields[6]
-		}
+// SendReceive replicates a read-only snapshot into the directory dstPath on
+// another BTRFS filesystem with btrfs send | btrfs receive. With a parent
+// snapshot present on both sides only the changes since it are sent.
+func (b *BTRFSManager) SendReceive(srcSnap, dstPath, parent string) error {
+	if !b.enabled {
+		return fmt.Errorf("BTRFS not available")
+	}
 
-		// Parse path (last field)
-		subvol.Path = fields[len(fields)-1]
+	send := []string{"send"}
+	if parent != "" {
+		send = append(send, "-p", parent)
+	}
+	send = append(send, srcSnap)
 
-		subvolumes = append(subvolumes, subvol)
+	pipeline := executor.NewPipeline(
+		executor.PipelineStage{Command: "btrfs", Args: send},
+		executor.PipelineStage{Command: "btrfs", Args: []string{"receive", dstPath}},
+	)
+	if _, err := b.shell.RunPipeline(context.Background(), *pipeline); err != nil {
+		return fmt.Errorf("failed to send %s to %s: %w", srcSnap, dstPath, err)
 	}
 
-	return subvolumes, nil
+	return nil
 }
 
 // Send sends a BTRFS subvolume/snapshot to a stream
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+)
+
+// btrfs subvolume list -p -u -q of a filesystem with a home subvolume, a
+// read-only snapshot of it and a subvolume whose path contains a space
+const btrfsSubvolumeList = `ID 256 gen 1520 parent 5 top level 5 parent_uuid - uuid 9ad6a1c0-2f4b-6f41-9e7b-2d1a0e3c4b5f path home
+ID 257 gen 1498 parent 5 top level 5 parent_uuid 9ad6a1c0-2f4b-6f41-9e7b-2d1a0e3c4b5f uuid 1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901 path .snapshots/home@2025-10-12
+ID 260 gen 1519 parent 256 top level 256 parent_uuid - uuid 0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0 path home/Media Library
+`
+
+// btrfs subvolume list -r of the same filesystem
+const btrfsSubvolumeListReadOnly = `ID 257 gen 1498 top level 5 path .snapshots/home@2025-10-12
+`
+
+const btrfsFilesystemUsage = `Overall:
+    Device size:		        107374182400
+    Device allocated:		         12910067712
+    Device unallocated:		         94464114688
+    Device missing:		                   0
+    Device slack:		                   0
+    Used:			          9427746816
+    Free (estimated):		         96683741184	(min: 49451687936)
+    Free (statfs, df):		         96682692608
+    Data ratio:			                1.00
+    Metadata ratio:		                2.00
+    Global reserve:		            16777216	(used: 0)
+    Multiple profiles:		                  no
+
+Data,single: Size:10737418240, Used:8518627328 (79.34%)
+   /dev/sdb	10737418240
+
+Metadata,DUP: Size:1073741824, Used:454541312 (42.33%)
+   /dev/sdb	2147483648
+
+System,DUP: Size:8388608, Used:16384 (0.20%)
+   /dev/sdb	  16777216
+
+Unallocated:
+   /dev/sdb	94464114688
+`
+
+func TestParseSubvolumeList(t *testing.T) {
+	got := ParseSubvolumeList(btrfsSubvolumeList)
+	want := []BTRFSSubvolume{
+		{ID: 256, ParentID: 5, TopLevel: 5, Path: "home", UUID: "9ad6a1c0-2f4b-6f41-9e7b-2d1a0e3c4b5f"},
+		{ID: 257, ParentID: 5, TopLevel: 5, Path: ".snapshots/home@2025-10-12", UUID: "1b2c3d4e-5f60-7182-93a4-b5c6d7e8f901",
+			ParentUUID: "9ad6a1c0-2f4b-6f41-9e7b-2d1a0e3c4b5f"},
+		{ID: 260, ParentID: 256, TopLevel: 256, Path: "home/Media Library", UUID: "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSubvolumeList() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := ParseSubvolumeList(""); len(got) != 0 {
+		t.Errorf("ParseSubvolumeList(\"\") = %+v, want none", got)
+	}
+}
+
+func TestListSubvolumesMarksReadOnly(t *testing.T) {
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("btrfs", "subvolume", "list", "-p", "-u", "-q", "/mnt/pool").Returns(btrfsSubvolumeList, "", 0)
+	shell.ExpectCommand("btrfs", "subvolume", "list", "-r", "/mnt/pool").Returns(btrfsSubvolumeListReadOnly, "", 0)
+
+	b, err := NewBTRFSManager(shell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subvolumes, err := b.ListSubvolumes("/mnt/pool")
+	if err != nil {
+		t.Fatalf("ListSubvolumes() error = %v", err)
+	}
+	for _, subvol := range subvolumes {
+		if subvol.ReadOnly != (subvol.ID == 257) {
+			t.Errorf("subvolume %d read-only = %v", subvol.ID, subvol.ReadOnly)
+		}
+	}
+}
+
+func TestParseBTRFSUsage(t *testing.T) {
+	usage, err := ParseBTRFSUsage(btrfsFilesystemUsage)
+	if err != nil {
+		t.Fatalf("ParseBTRFSUsage() error = %v", err)
+	}
+	want := &BTRFSUsage{
+		DeviceSize:        107374182400,
+		DeviceAllocated:   12910067712,
+		DeviceUnallocated: 94464114688,
+		Used:              9427746816,
+		FreeEstimated:     96683741184,
+		FreeMin:           49451687936,
+		DataRatio:         1,
+		MetadataRatio:     2,
+		GlobalReserve:     16777216,
+		BlockGroups: []BTRFSBlockGroup{
+			{Type: "Data", Profile: "single", Size: 10737418240, Used: 8518627328},
+			{Type: "Metadata", Profile: "DUP", Size: 1073741824, Used: 454541312},
+			{Type: "System", Profile: "DUP", Size: 8388608, Used: 16384},
+		},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("ParseBTRFSUsage() =\n%+v\nwant\n%+v", usage, want)
+	}
+
+	if _, err := ParseBTRFSUsage("ERROR: not a btrfs filesystem"); err == nil {
+		t.Error("ParseBTRFSUsage() of an error message succeeded")
+	}
+}
+
+func TestSendReceive(t *testing.T) {
+	shell := executor.NewMockShellExecutor()
+	b, err := NewBTRFSManager(shell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shell.ExpectCommand("btrfs")
+
+	if err := b.SendReceive("/mnt/pool/.snapshots/home@2", "/mnt/backup/home", "/mnt/pool/.snapshots/home@1"); err != nil {
+		t.Fatalf("SendReceive() error = %v", err)
+	}
+	var calls []string
+	for _, call := range shell.Calls {
+		calls = append(calls, call.String())
+	}
+	want := []string{
+		"btrfs send -p /mnt/pool/.snapshots/home@1 /mnt/pool/.snapshots/home@2",
+		"btrfs receive /mnt/backup/home",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("commands = %q, want %q", calls, want)
+	}
+}
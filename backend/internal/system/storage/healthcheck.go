@@ -0,0 +1,20 @@
+package storage
+
+import "github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+
+func init() {
+	sysutil.RegisterComponents("storage",
+		// Disk management
+		sysutil.ComponentDefinition{Name: "lsblk", Command: "lsblk", Required: true},
+		sysutil.ComponentDefinition{Name: "fdisk", Command: "fdisk"},
+		sysutil.ComponentDefinition{Name: "parted", Command: "parted"},
+		sysutil.ComponentDefinition{Name: "mkfs.ext4", Command: "mkfs.ext4"},
+		sysutil.ComponentDefinition{Name: "mkfs.xfs", Command: "mkfs.xfs"},
+		sysutil.ComponentDefinition{Name: "mkfs.btrfs", Command: "mkfs.btrfs"},
+	)
+
+	sysutil.RegisterComponents("monitoring",
+		sysutil.ComponentDefinition{Name: "smartctl", Command: "smartctl"},
+		sysutil.ComponentDefinition{Name: "iostat", Command: "iostat"},
+	)
+}
@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/scheduler"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// smartTestCheckInterval is how often scheduled devices are checked for
+// failed self-tests
+const smartTestCheckInterval = time.Hour
+
+// smartctl exit status bits that mean the command itself failed; higher
+// bits report disk problems such as errors in the self-test log
+const smartctlCommandErrorBits = 0x07
+
+// deviceNameRegex matches the kernel names of block devices, e.g. sda or
+// nvme0n1, which are also used in cron file names
+var deviceNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// selfTestRegex matches an entry of the ATA self-test log, e.g.
+// "# 1  Extended offline    Completed: read failure       90%     23456         123456789"
+var selfTestRegex = regexp.MustCompile(`^#\s*(\d+)\s+(\S.*?)\s{2,}(\S.*?)\s+(\d+)%\s+(\d+)\s+(\S+)\s*$`)
+
+// SMARTTestResult is an entry of a drive's self-test log. smartctl logs
+// when a test ran as the drive's power-on hours, not as a time or runtime;
+// OccurredAt is estimated from the current power-on hours.
+type SMARTTestResult struct {
+	Num             int        `json:"num"` // 1 is the most recent test
+	Type            string     `json:"type"`
+	Status          string     `json:"status"`
+	Failed          bool       `json:"failed"`
+	Remaining       int        `json:"remaining"`      // Percent of the test left when it ended
+	LifetimeHours   uint64     `json:"lifetime_hours"` // Power-on hours when the test ran
+	LBAOfFirstError *uint64    `json:"lba_of_first_error,omitempty"`
+	OccurredAt      *time.Time `json:"occurred_at,omitempty"`
+}
+
+// SMARTTestSchedule is the self-test schedule of a device
+type SMARTTestSchedule struct {
+	Device string `json:"device"`
+	Short  string `json:"short"` // Cron expression, empty if not scheduled
+	Long   string `json:"long"`  // Cron expression, empty if not scheduled
+}
+
+// ParseSelfTestLog parses the output of smartctl -l selftest for ATA
+// drives. With the drive's current power-on hours, the time of each test is
+// estimated relative to now.
+func ParseSelfTestLog(output string, powerOnHours uint64, now time.Time) []SMARTTestResult {
+	results := []SMARTTestResult{}
+	for _, line := range strings.Split(output, "\n") {
+		m := selfTestRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		result := SMARTTestResult{Type: m[2], Status: m[3]}
+		result.Num, _ = strconv.Atoi(m[1])
+		result.Remaining, _ = strconv.Atoi(m[4])
+		result.LifetimeHours, _ = strconv.ParseUint(m[5], 10, 64)
+		if lba, err := strconv.ParseUint(m[6], 10, 64); err == nil {
+			result.LBAOfFirstError = &lba
+		}
+		status := strings.ToLower(result.Status)
+		result.Failed = strings.Contains(status, "failure") || strings.Contains(status, "damage") || strings.HasPrefix(status, "fatal")
+
+		if powerOnHours > 0 {
+			// The log stores 16 bit hours, which wrap after 65535 hours
+			elapsed := int64(powerOnHours) - int64(result.LifetimeHours)
+			if powerOnHours > 0xFFFF {
+				elapsed = int64(powerOnHours%0x10000) - int64(result.LifetimeHours)
+				if elapsed < 0 {
+					elapsed += 0x10000
+				}
+			}
+			if elapsed >= 0 {
+				at := now.Add(-time.Duration(elapsed) * time.Hour)
+				result.OccurredAt = &at
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// SMARTTestScheduler runs drive self-tests on cron schedules and alerts when
+// the most recent self-test of a scheduled drive failed
+type SMARTTestScheduler struct {
+	smart   *SMARTManager
+	cronDir string
+	now     func() time.Time
+	alert   func(device string, result SMARTTestResult)
+
+	mu      sync.Mutex
+	alerted map[string]uint64 // Device to lifetime hours of the last alerted failure
+}
+
+// NewSMARTTestScheduler creates a scheduler that writes its cron jobs to
+// /etc/cron.d
+func NewSMARTTestScheduler(smart *SMARTManager) *SMARTTestScheduler {
+	return &SMARTTestScheduler{
+		smart:   smart,
+		cronDir: "/etc/cron.d",
+		now:     time.Now,
+		alert:   alertSMARTTestFailure,
+		alerted: make(map[string]uint64),
+	}
+}
+
+func alertSMARTTestFailure(device string, result SMARTTestResult) {
+	service := alerts.GetService()
+	if service == nil {
+		return
+	}
+	if err := service.SendSMARTTestFailureAlert(context.Background(), device, result.Type, result.Status, result.LBAOfFirstError); err != nil {
+		logger.Warn("Failed to send SMART self-test alert", zap.Error(err))
+	}
+}
+
+func (s *SMARTTestScheduler) cronFile(device string) string {
+	return filepath.Join(s.cronDir, "smart-test-"+device)
+}
+
+// normalizeDevice strips /dev/ from the device and validates its name
+func normalizeDevice(device string) (string, error) {
+	device = strings.TrimPrefix(device, "/dev/")
+	if !deviceNameRegex.MatchString(device) {
+		return "", fmt.Errorf("invalid device name %q", device)
+	}
+	return device, nil
+}
+
+// ScheduleShortTest runs a short self-test of the device on the cron
+// schedule. An empty schedule removes the short test.
+func (s *SMARTTestScheduler) ScheduleShortTest(device string, schedule string) error {
+	return s.scheduleTest(device, "short", schedule)
+}
+
+// ScheduleLongTest runs an extended self-test of the device on the cron
+// schedule. An empty schedule removes the long test.
+func (s *SMARTTestScheduler) ScheduleLongTest(device string, schedule string) error {
+	return s.scheduleTest(device, "long", schedule)
+}
+
+func (s *SMARTTestScheduler) scheduleTest(device, testType, schedule string) error {
+	device, err := normalizeDevice(device)
+	if err != nil {
+		return err
+	}
+	schedule = strings.Join(strings.Fields(schedule), " ")
+	if schedule != "" {
+		if err := scheduler.ValidateCronExpression(schedule); err != nil {
+			return fmt.Errorf("invalid %s test schedule: %w", testType, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.readSchedule(device)
+	if err != nil {
+		return err
+	}
+	if testType == "short" {
+		current.Short = schedule
+	} else {
+		current.Long = schedule
+	}
+
+	if current.Short == "" && current.Long == "" {
+		if err := os.Remove(s.cronFile(device)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove SMART test schedule: %w", err)
+		}
+		logger.Info("SMART test schedule removed", zap.String("device", device))
+		return nil
+	}
+
+	content := fmt.Sprintf("# Managed by Stumpf.Works NAS - SMART self-tests of /dev/%s\n"+
+		"PATH=/usr/sbin:/usr/bin:/sbin:/bin\n", device)
+	if current.Short != "" {
+		content += fmt.Sprintf("%s root smartctl -t short /dev/%s\n", current.Short, device)
+	}
+	if current.Long != "" {
+		content += fmt.Sprintf("%s root smartctl -t long /dev/%s\n", current.Long, device)
+	}
+	if err := os.WriteFile(s.cronFile(device), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write SMART test schedule: %w", err)
+	}
+
+	logger.Info("SMART test schedule set",
+		zap.String("device", device),
+		zap.String("type", testType),
+		zap.String("schedule", schedule))
+	return nil
+}
+
+// GetSchedule returns the self-test schedule of the device
+func (s *SMARTTestScheduler) GetSchedule(device string) (*SMARTTestSchedule, error) {
+	device, err := normalizeDevice(device)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readSchedule(device)
+}
+
+func (s *SMARTTestScheduler) readSchedule(device string) (*SMARTTestSchedule, error) {
+	schedule := &SMARTTestSchedule{Device: device}
+
+	f, err := os.Open(s.cronFile(device))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return schedule, nil
+		}
+		return nil, fmt.Errorf("failed to read SMART test schedule: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// minute hour day month weekday user smartctl -t type device
+		if len(fields) < 10 || fields[6] != "smartctl" || fields[7] != "-t" {
+			continue
+		}
+		switch fields[8] {
+		case "short":
+			schedule.Short = strings.Join(fields[:5], " ")
+		case "long":
+			schedule.Long = strings.Join(fields[:5], " ")
+		}
+	}
+	return schedule, nil
+}
+
+// scheduledDevices returns the devices with a self-test schedule
+func (s *SMARTTestScheduler) scheduledDevices() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.cronDir, "smart-test-*"))
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]string, 0, len(matches))
+	for _, match := range matches {
+		devices = append(devices, strings.TrimPrefix(filepath.Base(match), "smart-test-"))
+	}
+	return devices, nil
+}
+
+// GetTestHistory returns the self-test log of the device, most recent first
+func (s *SMARTTestScheduler) GetTestHistory(device string) ([]SMARTTestResult, error) {
+	device, err := normalizeDevice(device)
+	if err != nil {
+		return nil, err
+	}
+	if !s.smart.IsEnabled() {
+		return nil, fmt.Errorf("SMART not available")
+	}
+
+	// smartctl sets status bits when the log contains failed tests
+	result, err := s.smart.shell.Execute("smartctl", "-l", "selftest", "/dev/"+device)
+	if err != nil && (result == nil || result.ExitCode&smartctlCommandErrorBits != 0) {
+		return nil, fmt.Errorf("failed to get self-test log: %w", err)
+	}
+
+	var powerOnHours uint64
+	if info, err := s.smart.GetInfo(device); err == nil {
+		powerOnHours = info.PowerOnHours
+	}
+	return ParseSelfTestLog(result.Stdout, powerOnHours, s.now()), nil
+}
+
+// Start checks the scheduled devices every hour until ctx is cancelled
+func (s *SMARTTestScheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(smartTestCheckInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.Check(); err != nil {
+				logger.Warn("SMART self-test check failed", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Check alerts for every scheduled device whose most recent self-test
+// failed and was not alerted yet
+func (s *SMARTTestScheduler) Check() error {
+	devices, err := s.scheduledDevices()
+	if err != nil {
+		return err
+	}
+
+	for _, device := range devices {
+		history, err := s.GetTestHistory(device)
+		if err != nil {
+			logger.Warn("Failed to get SMART self-test log", zap.String("device", device), zap.Error(err))
+			continue
+		}
+		if len(history) == 0 || !history[0].Failed {
+			continue
+		}
+
+		latest := history[0]
+		s.mu.Lock()
+		alerted, ok := s.alerted[device]
+		s.alerted[device] = latest.LifetimeHours
+		s.mu.Unlock()
+		if ok && alerted == latest.LifetimeHours {
+			continue
+		}
+
+		logger.Error("SMART self-test failed",
+			zap.String("device", device),
+			zap.String("type", latest.Type),
+			zap.String("status", latest.Status))
+		s.alert(device, latest)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+const smartctlAttributes = `ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  9 Power_On_Hours          0x0032   070   070   000    Old_age   Always       -       23460
+`
+
+func newTestSMARTTestScheduler(t *testing.T) (*SMARTTestScheduler, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	smart, err := NewSMARTManager(shell)
+	if err != nil {
+		t.Fatalf("NewSMARTManager: %v", err)
+	}
+	s := NewSMARTTestScheduler(smart)
+	s.cronDir = t.TempDir()
+	return s, shell
+}
+
+func TestParseSelfTestLog(t *testing.T) {
+	data, err := os.ReadFile("testdata/smartctl_selftest.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 10, 12, 12, 0, 0, 0, time.UTC)
+
+	results := ParseSelfTestLog(string(data), 23460, now)
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+
+	failed := results[0]
+	if failed.Num != 1 || failed.Type != "Extended offline" || failed.Status != "Completed: read failure" ||
+		!failed.Failed || failed.Remaining != 90 || failed.LifetimeHours != 23456 {
+		t.Errorf("failed result = %+v", failed)
+	}
+	if failed.LBAOfFirstError == nil || *failed.LBAOfFirstError != 123456789 {
+		t.Errorf("LBA of first error = %v", failed.LBAOfFirstError)
+	}
+	if failed.OccurredAt == nil || !failed.OccurredAt.Equal(now.Add(-4*time.Hour)) {
+		t.Errorf("occurred at = %v", failed.OccurredAt)
+	}
+
+	for _, result := range results[1:] {
+		if result.Failed || result.LBAOfFirstError != nil {
+			t.Errorf("result %d = %+v", result.Num, result)
+		}
+	}
+	if results[3].Status != "Interrupted (host reset)" {
+		t.Errorf("status = %q", results[3].Status)
+	}
+}
+
+func TestSMARTTestSchedule(t *testing.T) {
+	s, _ := newTestSMARTTestScheduler(t)
+
+	if err := s.ScheduleShortTest("sda", "daily"); err == nil {
+		t.Error("accepted an invalid cron expression")
+	}
+	if err := s.ScheduleShortTest("../sda", "0 3 * * *"); err == nil {
+		t.Error("accepted an invalid device name")
+	}
+
+	if err := s.ScheduleShortTest("/dev/sda", "0 3 * * *"); err != nil {
+		t.Fatalf("ScheduleShortTest: %v", err)
+	}
+	if err := s.ScheduleLongTest("sda", "0 4 * * 0"); err != nil {
+		t.Fatalf("ScheduleLongTest: %v", err)
+	}
+	data, _ := os.ReadFile(s.cronFile("sda"))
+	if !strings.Contains(string(data), "\n0 3 * * * root smartctl -t short /dev/sda\n") ||
+		!strings.Contains(string(data), "\n0 4 * * 0 root smartctl -t long /dev/sda\n") {
+		t.Errorf("cron file = %q", data)
+	}
+	schedule, err := s.GetSchedule("sda")
+	if err != nil || schedule.Short != "0 3 * * *" || schedule.Long != "0 4 * * 0" {
+		t.Errorf("GetSchedule = %+v, %v", schedule, err)
+	}
+
+	s.ScheduleShortTest("sda", "")
+	s.ScheduleLongTest("sda", "")
+	if _, err := os.Stat(s.cronFile("sda")); !os.IsNotExist(err) {
+		t.Errorf("cron file not removed: %v", err)
+	}
+}
+
+func TestSMARTTestSchedulerAlertsOnce(t *testing.T) {
+	s, shell := newTestSMARTTestScheduler(t)
+	data, err := os.ReadFile("testdata/smartctl_selftest.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bit 6 reports errors in the self-test log
+	shell.ExpectCommand("smartctl", "-l", "selftest", "/dev/sdb").Returns(string(data), "", 64)
+	shell.ExpectCommand("smartctl", "-a", "/dev/sdb").Returns(smartctlAttributes, "", 0)
+
+	var alerts []SMARTTestResult
+	s.alert = func(device string, result SMARTTestResult) { alerts = append(alerts, result) }
+	if err := s.ScheduleLongTest("sdb", "0 4 * * 0"); err != nil {
+		t.Fatalf("ScheduleLongTest: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Check(); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	}
+	if len(alerts) != 1 || alerts[0].Status != "Completed: read failure" {
+		t.Errorf("alerts = %+v", alerts)
+	}
+}
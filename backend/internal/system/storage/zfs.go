@@ -16,6 +16,7 @@ import (
 type ZFSManager struct {
 	shell      executor.ShellExecutor
 	enabled bool
+	cronDir string // Directory of the scrub schedule cron jobs
 }
 
 // ZFSPool represents a ZFS storage pool
@@ -66,6 +67,7 @@ func NewZFSManager(shell executor.ShellExecutor) (*ZFSManager, error) {
 	return &ZFSManager{
 		shell:   shell,
 		enabled: true,
+		cronDir: "/etc/cron.d",
 	}, nil
 }
 
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// ZFS encryption algorithms and key formats
+const (
+	EncryptionAES256GCM = "aes-256-gcm"
+	EncryptionAES256CCM = "aes-256-ccm"
+
+	KeyFormatPassphrase = "passphrase"
+	KeyFormatRaw        = "raw"
+	KeyFormatHex        = "hex"
+)
+
+// EncryptionOptions configures ZFS native encryption of a dataset
+type EncryptionOptions struct {
+	Algorithm   string `json:"algorithm"`    // aes-256-gcm (default), aes-256-ccm
+	KeyFormat   string `json:"key_format"`   // passphrase (default), raw, hex
+	KeyLocation string `json:"key_location"` // Empty or prompt to enter the key at mount, or a file:// URI to load it at boot
+}
+
+func (o *EncryptionOptions) validate() error {
+	if o.Algorithm == "" {
+		o.Algorithm = EncryptionAES256GCM
+	}
+	if o.KeyFormat == "" {
+		o.KeyFormat = KeyFormatPassphrase
+	}
+	if o.KeyLocation == "" {
+		o.KeyLocation = "prompt"
+	}
+
+	if o.Algorithm != EncryptionAES256GCM && o.Algorithm != EncryptionAES256CCM {
+		return fmt.Errorf("unsupported encryption algorithm %q", o.Algorithm)
+	}
+	if o.KeyFormat != KeyFormatPassphrase && o.KeyFormat != KeyFormatRaw && o.KeyFormat != KeyFormatHex {
+		return fmt.Errorf("unsupported key format %q", o.KeyFormat)
+	}
+	if o.KeyLocation != "prompt" && !strings.HasPrefix(o.KeyLocation, "file:///") {
+		return fmt.Errorf("key location must be prompt or an absolute file:// URI")
+	}
+	return nil
+}
+
+// validateKey checks that a key matches the requirements ZFS has for the
+// key format
+func validateKey(format, key string) error {
+	switch format {
+	case KeyFormatPassphrase:
+		if len(key) < 8 || len(key) > 512 {
+			return fmt.Errorf("passphrase must be between 8 and 512 characters")
+		}
+	case KeyFormatHex:
+		if _, err := hex.DecodeString(key); err != nil || len(key) != 64 {
+			return fmt.Errorf("hex key must be 64 hexadecimal characters")
+		}
+	case KeyFormatRaw:
+		if len(key) != 32 {
+			return fmt.Errorf("raw key must be 32 bytes")
+		}
+	default:
+		return fmt.Errorf("unsupported key format %q", format)
+	}
+	return nil
+}
+
+// withKeyFile writes key to a temporary file readable only by the server and
+// calls fn with its file:// URI. ZFS reads keys from a terminal or a file,
+// and the shell executor has no stdin, so keys are passed through a file
+// that is removed as soon as the command finished.
+func withKeyFile(key string, fn func(location string) error) error {
+	f, err := os.CreateTemp("", "zfs-key-*")
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return fn("file://" + f.Name())
+}
+
+// CreateEncryptedDataset creates pool/name with native encryption. With the
+// default prompt key location the passphrase must be entered to mount the
+// dataset after every boot; with a file:// key location the key is read
+// from that file and no passphrase is given.
+func (z *ZFSManager) CreateEncryptedDataset(pool, name, passphrase string, opts EncryptionOptions) error {
+	if !z.enabled {
+		return fmt.Errorf("ZFS not available")
+	}
+	if pool == "" || name == "" {
+		return fmt.Errorf("pool and dataset name are required")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	dataset := pool + "/" + name
+	create := func(location string) error {
+		_, err := z.shell.Execute("zfs", "create",
+			"-o", "encryption="+opts.Algorithm,
+			"-o", "keyformat="+opts.KeyFormat,
+			"-o", "keylocation="+location,
+			dataset)
+		if err != nil {
+			return fmt.Errorf("failed to create encrypted dataset: %w", err)
+		}
+		return nil
+	}
+
+	requiresPassphrase := opts.KeyLocation == "prompt"
+	if requiresPassphrase {
+		if err := validateKey(opts.KeyFormat, passphrase); err != nil {
+			return err
+		}
+		if err := withKeyFile(passphrase, create); err != nil {
+			return err
+		}
+		// The temporary key file is gone, so ask for the key from now on
+		if err := z.SetProperty(dataset, "keylocation", "prompt"); err != nil {
+			return err
+		}
+	} else {
+		if passphrase != "" {
+			return fmt.Errorf("a passphrase cannot be combined with a key file")
+		}
+		if err := create(opts.KeyLocation); err != nil {
+			return err
+		}
+	}
+
+	if db := database.GetDB(); db != nil {
+		record := models.EncryptedDataset{
+			Dataset:            dataset,
+			Algorithm:          opts.Algorithm,
+			KeyFormat:          opts.KeyFormat,
+			KeyLocation:        opts.KeyLocation,
+			RequiresPassphrase: requiresPassphrase,
+		}
+		err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "dataset"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at", "algorithm", "key_format", "key_location", "requires_passphrase"}),
+		}).Create(&record).Error
+		if err != nil {
+			logger.Error("Failed to record encrypted dataset", zap.String("dataset", dataset), zap.Error(err))
+		}
+	}
+
+	logger.Info("Encrypted ZFS dataset created",
+		zap.String("dataset", dataset),
+		zap.String("algorithm", opts.Algorithm),
+		zap.Bool("requiresPassphrase", requiresPassphrase))
+	return nil
+}
+
+// MountEncryptedDataset loads the key of an encrypted dataset and mounts it.
+// The passphrase may be empty for datasets whose key is in a file.
+func (z *ZFSManager) MountEncryptedDataset(dataset, passphrase string) error {
+	if !z.enabled {
+		return fmt.Errorf("ZFS not available")
+	}
+
+	keyStatus, err := z.GetProperty(dataset, "keystatus")
+	if err != nil {
+		return err
+	}
+	if keyStatus == "unavailable" {
+		if passphrase == "" {
+			_, err = z.shell.Execute("zfs", "load-key", dataset)
+		} else {
+			err = withKeyFile(passphrase, func(location string) error {
+				_, err := z.shell.Execute("zfs", "load-key", "-L", location, dataset)
+				return err
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load encryption key: %w", err)
+		}
+	} else if keyStatus != "available" {
+		return fmt.Errorf("dataset %s is not encrypted", dataset)
+	}
+
+	mounted, err := z.GetProperty(dataset, "mounted")
+	if err != nil {
+		return err
+	}
+	if mounted != "yes" {
+		if _, err := z.shell.Execute("zfs", "mount", dataset); err != nil {
+			return fmt.Errorf("failed to mount dataset: %w", err)
+		}
+	}
+
+	logger.Info("Encrypted ZFS dataset mounted", zap.String("dataset", dataset))
+	return nil
+}
+
+// UnmountEncryptedDataset unmounts an encrypted dataset and unloads its key,
+// so its data cannot be read until the key is loaded again
+func (z *ZFSManager) UnmountEncryptedDataset(dataset string) error {
+	if !z.enabled {
+		return fmt.Errorf("ZFS not available")
+	}
+
+	mounted, err := z.GetProperty(dataset, "mounted")
+	if err != nil {
+		return err
+	}
+	if mounted == "yes" {
+		if _, err := z.shell.Execute("zfs", "unmount", dataset); err != nil {
+			return fmt.Errorf("failed to unmount dataset: %w", err)
+		}
+	}
+	if _, err := z.shell.Execute("zfs", "unload-key", dataset); err != nil {
+		return fmt.Errorf("failed to unload encryption key: %w", err)
+	}
+
+	logger.Info("Encrypted ZFS dataset unmounted", zap.String("dataset", dataset))
+	return nil
+}
+
+// ChangeEncryptionKey replaces the passphrase of an encrypted dataset after
+// checking the old one. Only the wrapping key changes, so no data is
+// re-encrypted.
+func (z *ZFSManager) ChangeEncryptionKey(dataset, oldPassphrase, newPassphrase string) error {
+	if !z.enabled {
+		return fmt.Errorf("ZFS not available")
+	}
+
+	location, err := z.GetProperty(dataset, "keylocation")
+	if err != nil {
+		return err
+	}
+	if location != "prompt" {
+		return fmt.Errorf("dataset %s does not use a passphrase", dataset)
+	}
+	format, err := z.GetProperty(dataset, "keyformat")
+	if err != nil {
+		return err
+	}
+	if err := validateKey(format, newPassphrase); err != nil {
+		return err
+	}
+
+	// A dry run checks the old key even while the key is loaded
+	err = withKeyFile(oldPassphrase, func(location string) error {
+		if _, err := z.shell.Execute("zfs", "load-key", "-n", "-L", location, dataset); err != nil {
+			return fmt.Errorf("current passphrase is incorrect: %w", err)
+		}
+		keyStatus, err := z.GetProperty(dataset, "keystatus")
+		if err != nil {
+			return err
+		}
+		if keyStatus == "unavailable" {
+			if _, err := z.shell.Execute("zfs", "load-key", "-L", location, dataset); err != nil {
+				return fmt.Errorf("failed to load encryption key: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = withKeyFile(newPassphrase, func(location string) error {
+		if _, err := z.shell.Execute("zfs", "change-key", "-o", "keylocation="+location, dataset); err != nil {
+			return fmt.Errorf("failed to change encryption key: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := z.SetProperty(dataset, "keylocation", "prompt"); err != nil {
+		return err
+	}
+
+	logger.Info("Encryption key of ZFS dataset changed", zap.String("dataset", dataset))
+	return nil
+}
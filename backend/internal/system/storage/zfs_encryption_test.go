@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestZFSManager(t *testing.T) (*ZFSManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	z, err := NewZFSManager(shell)
+	if err != nil {
+		t.Fatalf("NewZFSManager: %v", err)
+	}
+	return z, shell
+}
+
+func TestCreateEncryptedDataset(t *testing.T) {
+	z, shell := newTestZFSManager(t)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "zfs.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.EncryptedDataset{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	defer func() { database.DB = nil }()
+
+	shell.ExpectCommand("zfs").Returns("", "", 0)
+
+	if err := z.CreateEncryptedDataset("tank", "medical", "short", EncryptionOptions{}); err == nil {
+		t.Fatal("accepted a passphrase shorter than 8 characters")
+	}
+	if err := z.CreateEncryptedDataset("tank", "medical", "correct horse battery", EncryptionOptions{}); err != nil {
+		t.Fatalf("CreateEncryptedDataset: %v", err)
+	}
+
+	calls := shell.CallsTo("zfs")
+	if len(calls) != 2 {
+		t.Fatalf("zfs calls = %v", calls)
+	}
+	create := calls[0].String()
+	if !strings.HasPrefix(create, "zfs create -o encryption=aes-256-gcm -o keyformat=passphrase -o keylocation=file:///") || !strings.HasSuffix(create, " tank/medical") {
+		t.Errorf("create = %s", create)
+	}
+	if strings.Contains(create, "correct horse battery") {
+		t.Error("passphrase passed on the command line")
+	}
+	if got := calls[1].String(); got != "zfs set keylocation=prompt tank/medical" {
+		t.Errorf("second call = %s", got)
+	}
+
+	var record models.EncryptedDataset
+	if err := db.First(&record, "dataset = ?", "tank/medical").Error; err != nil {
+		t.Fatalf("load record: %v", err)
+	}
+	if !record.RequiresPassphrase || record.KeyFormat != KeyFormatPassphrase {
+		t.Errorf("record = %+v", record)
+	}
+}
+
+func TestMountEncryptedDataset(t *testing.T) {
+	z, shell := newTestZFSManager(t)
+	shell.ExpectCommand("zfs", "get", "-H", "-o", "value", "keystatus", "tank/medical").Returns("unavailable\n", "", 0)
+	shell.ExpectCommand("zfs", "get", "-H", "-o", "value", "mounted", "tank/medical").Returns("no\n", "", 0)
+	shell.ExpectCommand("zfs", "mount", "tank/medical").Returns("", "", 0).Times(1)
+	shell.ExpectCommand("zfs").Returns("", "", 0)
+
+	if err := z.MountEncryptedDataset("tank/medical", "correct horse battery"); err != nil {
+		t.Fatalf("MountEncryptedDataset: %v", err)
+	}
+
+	var loadKey string
+	for _, call := range shell.CallsTo("zfs") {
+		if call.Args[0] == "load-key" {
+			loadKey = call.String()
+		}
+	}
+	if !strings.HasPrefix(loadKey, "zfs load-key -L file:///") {
+		t.Errorf("load-key = %q", loadKey)
+	}
+	shell.AssertExpectations(t)
+}
+
+func TestChangeEncryptionKeyWrongPassphrase(t *testing.T) {
+	z, shell := newTestZFSManager(t)
+	shell.ExpectCommand("zfs", "get", "-H", "-o", "value", "keylocation", "tank/medical").Returns("prompt", "", 0)
+	shell.ExpectCommand("zfs", "get", "-H", "-o", "value", "keyformat", "tank/medical").Returns("passphrase", "", 0)
+	shell.ExpectCommand("zfs").Returns("", "Key load error: Incorrect key provided for 'tank/medical'.", 255)
+
+	if err := z.ChangeEncryptionKey("tank/medical", "wrong passphrase", "new passphrase"); err == nil {
+		t.Fatal("expected an incorrect passphrase to fail")
+	}
+	for _, call := range shell.CallsTo("zfs") {
+		if call.Args[0] == "change-key" {
+			t.Error("changed the key after a failed passphrase check")
+		}
+	}
+}
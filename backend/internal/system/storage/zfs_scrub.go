@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/scheduler"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Scrub states
+const (
+	ScrubStateNone       = "none"
+	ScrubStateInProgress = "in_progress"
+	ScrubStateCompleted  = "completed"
+	ScrubStateCanceled   = "canceled"
+)
+
+// scrubMonitorInterval is how often pools are checked for scrubs with errors
+const scrubMonitorInterval = time.Hour
+
+// ErrScrubScheduleNotFound is returned for pools without a scrub schedule
+var ErrScrubScheduleNotFound = errors.New("no scrub schedule for pool")
+
+// poolNameRegex matches valid ZFS pool names, which are also used in file
+// names and cron commands
+var poolNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.:-]*$`)
+
+// ScrubResult is the outcome of the last scrub of a pool as reported by
+// zpool status
+type ScrubResult struct {
+	Pool          string     `json:"pool"`
+	State         string     `json:"state"` // none, in_progress, completed, canceled
+	StartTime     *time.Time `json:"start_time,omitempty"`
+	EndTime       *time.Time `json:"end_time,omitempty"`
+	DataExamined  uint64     `json:"data_examined"`  // Bytes scanned so far while in progress
+	RepairedBytes uint64     `json:"repaired_bytes"` // zpool reports repairs as the amount of data rewritten
+	Errors        int        `json:"errors"`         // Unrecoverable errors found
+	Progress      float64    `json:"progress"`       // Percent done while in progress
+}
+
+// scrubCronFile returns the cron job file of the scrub schedule of a pool
+func (z *ZFSManager) scrubCronFile(pool string) string {
+	return filepath.Join(z.cronDir, "zfs-scrub-"+pool)
+}
+
+// SetScrubSchedule scrubs the pool on the given standard cron schedule,
+// e.g. "0 2 1 * *" for 02:00 on the first of every month. The schedule is a
+// cron job, so it keeps running when the server is stopped.
+func (z *ZFSManager) SetScrubSchedule(pool string, schedule string) error {
+	if !z.enabled {
+		return fmt.Errorf("ZFS not available")
+	}
+	if !poolNameRegex.MatchString(pool) {
+		return fmt.Errorf("invalid pool name %q", pool)
+	}
+	schedule = strings.Join(strings.Fields(schedule), " ")
+	if err := scheduler.ValidateCronExpression(schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if _, err := z.shell.Execute("zpool", "list", "-H", "-o", "name", pool); err != nil {
+		return fmt.Errorf("pool %s not found: %w", pool, err)
+	}
+
+	content := fmt.Sprintf("# Managed by Stumpf.Works NAS - scrub schedule of pool %s\n"+
+		"PATH=/usr/sbin:/usr/bin:/sbin:/bin\n"+
+		"%s root zpool scrub %s\n", pool, schedule, pool)
+	if err := os.WriteFile(z.scrubCronFile(pool), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write scrub schedule: %w", err)
+	}
+
+	logger.Info("ZFS scrub schedule set", zap.String("pool", pool), zap.String("schedule", schedule))
+	return nil
+}
+
+// GetScrubSchedule returns the cron schedule of the pool's scrubs
+func (z *ZFSManager) GetScrubSchedule(pool string) (string, error) {
+	if !poolNameRegex.MatchString(pool) {
+		return "", fmt.Errorf("invalid pool name %q", pool)
+	}
+
+	f, err := os.Open(z.scrubCronFile(pool))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrScrubScheduleNotFound
+		}
+		return "", fmt.Errorf("failed to read scrub schedule: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// minute hour day month weekday user command...
+		if len(fields) > 7 && fields[6] == "zpool" && fields[7] == "scrub" {
+			return strings.Join(fields[:5], " "), nil
+		}
+	}
+	return "", ErrScrubScheduleNotFound
+}
+
+// RemoveScrubSchedule stops scheduled scrubs of the pool
+func (z *ZFSManager) RemoveScrubSchedule(pool string) error {
+	if !poolNameRegex.MatchString(pool) {
+		return fmt.Errorf("invalid pool name %q", pool)
+	}
+
+	if err := os.Remove(z.scrubCronFile(pool)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrScrubScheduleNotFound
+		}
+		return fmt.Errorf("failed to remove scrub schedule: %w", err)
+	}
+
+	logger.Info("ZFS scrub schedule removed", zap.String("pool", pool))
+	return nil
+}
+
+// GetLastScrubResult returns the state of the last or running scrub of the
+// pool
+func (z *ZFSManager) GetLastScrubResult(pool string) (*ScrubResult, error) {
+	if !z.enabled {
+		return nil, fmt.Errorf("ZFS not available")
+	}
+
+	status, err := z.GetPoolStatus(pool)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScrubStatus(pool, status)
+}
+
+var (
+	scrubCompletedRegex  = regexp.MustCompile(`scrub repaired (\S+) in (.+?) with (\d+) errors on (.+)$`)
+	scrubInProgressRegex = regexp.MustCompile(`scrub in progress since (.+)$`)
+	scrubCanceledRegex   = regexp.MustCompile(`scrub canceled on (.+)$`)
+	scrubScannedRegex    = regexp.MustCompile(`(\S+) scanned`)
+	scrubRepairedRegex   = regexp.MustCompile(`(\S+) repaired`)
+	scrubProgressRegex   = regexp.MustCompile(`([\d.]+)% done`)
+)
+
+// zpoolTimeLayout is the time format of zpool status, e.g.
+// "Sun Oct 12 00:24:02 2025"
+const zpoolTimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// ParseScrubStatus parses the scan section of zpool status output. A pool
+// whose last scan was a resilver reports no scrub.
+func ParseScrubStatus(pool, output string) (*ScrubResult, error) {
+	result := &ScrubResult{Pool: pool, State: ScrubStateNone}
+
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "scan:") {
+			continue
+		}
+		scan := strings.TrimSpace(strings.TrimPrefix(line, "scan:"))
+
+		if m := scrubCompletedRegex.FindStringSubmatch(scan); m != nil {
+			end, err := time.ParseInLocation(zpoolTimeLayout, strings.TrimSpace(m[4]), time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scrub end time %q: %w", m[4], err)
+			}
+			result.State = ScrubStateCompleted
+			result.EndTime = &end
+			result.RepairedBytes = parseZFSSize(m[1])
+			result.Errors, _ = strconv.Atoi(m[3])
+			if duration, ok := parseScrubDuration(m[2]); ok {
+				start := end.Add(-duration)
+				result.StartTime = &start
+			}
+			return result, nil
+		}
+
+		if m := scrubInProgressRegex.FindStringSubmatch(scan); m != nil {
+			start, err := time.ParseInLocation(zpoolTimeLayout, strings.TrimSpace(m[1]), time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scrub start time %q: %w", m[1], err)
+			}
+			result.State = ScrubStateInProgress
+			result.StartTime = &start
+			// Progress is reported on the following indented lines
+			for _, detail := range lines[i+1:] {
+				if !strings.HasPrefix(detail, "\t") && !strings.HasPrefix(detail, "    ") {
+					break
+				}
+				if m := scrubScannedRegex.FindStringSubmatch(detail); m != nil {
+					result.DataExamined = parseZFSSize(m[1])
+				}
+				if m := scrubRepairedRegex.FindStringSubmatch(detail); m != nil {
+					result.RepairedBytes = parseZFSSize(m[1])
+				}
+				if m := scrubProgressRegex.FindStringSubmatch(detail); m != nil {
+					result.Progress, _ = strconv.ParseFloat(m[1], 64)
+				}
+			}
+			return result, nil
+		}
+
+		if m := scrubCanceledRegex.FindStringSubmatch(scan); m != nil {
+			end, err := time.ParseInLocation(zpoolTimeLayout, strings.TrimSpace(m[1]), time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scrub cancel time %q: %w", m[1], err)
+			}
+			result.State = ScrubStateCanceled
+			result.EndTime = &end
+			return result, nil
+		}
+
+		return result, nil
+	}
+	return result, nil
+}
+
+// parseScrubDuration parses the scrub duration of zpool status, which is
+// "01:02:03" or "2 days 01:02:03" in current and "1h2m" in old releases
+func parseScrubDuration(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	var days int
+	if parts := strings.SplitN(s, " days ", 2); len(parts) == 2 {
+		days, _ = strconv.Atoi(parts[0])
+		s = parts[1]
+	}
+
+	var h, m, sec int
+	if n, _ := fmt.Sscanf(s, "%d:%d:%d", &h, &m, &sec); n == 3 {
+		return time.Duration(days)*24*time.Hour + time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second, true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, true
+	}
+	return 0, false
+}
+
+// parseZFSSize parses a human readable ZFS size like "1.50M" or "0B"
+func parseZFSSize(s string) uint64 {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "B")
+	if s == "" {
+		return 0
+	}
+
+	multiplier := float64(1)
+	units := "KMGTPE"
+	if i := strings.IndexByte(units, s[len(s)-1]); i >= 0 {
+		for j := 0; j <= i; j++ {
+			multiplier *= 1024
+		}
+		s = s[:len(s)-1]
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return uint64(value * multiplier)
+}
+
+// ScrubMonitor alerts when a scrub of a pool finds errors. Each scrub is
+// alerted once while the server runs.
+type ScrubMonitor struct {
+	zfs   *ZFSManager
+	alert func(result *ScrubResult)
+
+	mu      sync.Mutex
+	alerted map[string]time.Time // Pool to end time of the last alerted scrub
+}
+
+// NewScrubMonitor creates a monitor that sends alerts through the alert
+// service
+func NewScrubMonitor(zfs *ZFSManager) *ScrubMonitor {
+	return &ScrubMonitor{
+		zfs:     zfs,
+		alert:   alertScrubErrors,
+		alerted: make(map[string]time.Time),
+	}
+}
+
+func alertScrubErrors(result *ScrubResult) {
+	service := alerts.GetService()
+	if service == nil {
+		return
+	}
+	if err := service.SendScrubErrorAlert(context.Background(), result.Pool, result.Errors, result.RepairedBytes, *result.EndTime); err != nil {
+		logger.Warn("Failed to send scrub alert", zap.Error(err))
+	}
+}
+
+// Start checks the pools every hour until ctx is cancelled
+func (m *ScrubMonitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(scrubMonitorInterval)
+		defer ticker.Stop()
+		for {
+			if err := m.Check(); err != nil {
+				logger.Warn("ZFS scrub check failed", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Check alerts for every pool whose last completed scrub found errors and
+// was not alerted yet
+func (m *ScrubMonitor) Check() error {
+	pools, err := m.zfs.ListPools()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, pool := range pools {
+		result, err := m.zfs.GetLastScrubResult(pool.Name)
+		if err != nil {
+			logger.Warn("Failed to get scrub result", zap.String("pool", pool.Name), zap.Error(err))
+			continue
+		}
+		if result.State != ScrubStateCompleted || result.Errors == 0 || m.alerted[pool.Name].Equal(*result.EndTime) {
+			continue
+		}
+
+		logger.Error("ZFS scrub found errors",
+			zap.String("pool", pool.Name),
+			zap.Int("errors", result.Errors),
+			zap.Uint64("repairedBytes", result.RepairedBytes))
+		m.alert(result)
+		m.alerted[pool.Name] = *result.EndTime
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+)
+
+const zpoolStatusClean = `  pool: tank
+ state: ONLINE
+  scan: scrub repaired 0B in 01:02:03 with 0 errors on Sun Oct 12 02:00:00 2025
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        ONLINE       0     0     0
+	  mirror-0  ONLINE       0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     ONLINE       0     0     0
+
+errors: No known data errors
+`
+
+const zpoolStatusErrors = `  pool: tank
+ state: ONLINE
+status: One or more devices has experienced an error resulting in data
+	corruption.  Applications may be affected.
+action: Restore the file in question if possible.  Otherwise restore the
+	entire pool from backup.
+   see: https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-8A
+  scan: scrub repaired 1.50M in 2 days 00:00:10 with 3 errors on Sun Oct 12 02:00:00 2025
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        ONLINE       0     0     0
+	  sda       ONLINE       0     0    14
+
+errors: 3 data errors, use '-v' for a list
+`
+
+const zpoolStatusInProgress = `  pool: tank
+ state: ONLINE
+  scan: scrub in progress since Sun Oct 12 00:24:01 2025
+	1.25G scanned at 100M/s, 512M issued at 50M/s, 4.56G total
+	0B repaired, 27.40% done, 00:01:00 to go
+config:
+`
+
+func TestParseScrubStatus(t *testing.T) {
+	end := time.Date(2025, 10, 12, 2, 0, 0, 0, time.Local)
+
+	result, err := ParseScrubStatus("tank", zpoolStatusClean)
+	if err != nil {
+		t.Fatalf("ParseScrubStatus: %v", err)
+	}
+	if result.State != ScrubStateCompleted || result.Errors != 0 || result.RepairedBytes != 0 {
+		t.Errorf("clean result = %+v", result)
+	}
+	if !result.EndTime.Equal(end) || !result.StartTime.Equal(end.Add(-(time.Hour + 2*time.Minute + 3*time.Second))) {
+		t.Errorf("clean times = %v - %v", result.StartTime, result.EndTime)
+	}
+
+	result, err = ParseScrubStatus("tank", zpoolStatusErrors)
+	if err != nil {
+		t.Fatalf("ParseScrubStatus: %v", err)
+	}
+	if result.State != ScrubStateCompleted || result.Errors != 3 || result.RepairedBytes != 1572864 {
+		t.Errorf("errors result = %+v", result)
+	}
+	if !result.StartTime.Equal(end.Add(-(48*time.Hour + 10*time.Second))) {
+		t.Errorf("start time = %v", result.StartTime)
+	}
+
+	result, err = ParseScrubStatus("tank", zpoolStatusInProgress)
+	if err != nil {
+		t.Fatalf("ParseScrubStatus: %v", err)
+	}
+	if result.State != ScrubStateInProgress || result.DataExamined != 1342177280 || result.Progress != 27.4 || result.EndTime != nil {
+		t.Errorf("in progress result = %+v", result)
+	}
+
+	result, _ = ParseScrubStatus("tank", "  pool: tank\n state: ONLINE\n  scan: none requested\n")
+	if result.State != ScrubStateNone {
+		t.Errorf("unscrubbed pool state = %s", result.State)
+	}
+}
+
+func TestScrubSchedule(t *testing.T) {
+	z, shell := newTestZFSManager(t)
+	z.cronDir = t.TempDir()
+	shell.ExpectCommand("zpool", "list", "-H", "-o", "name", "tank").Returns("tank", "", 0)
+
+	if _, err := z.GetScrubSchedule("tank"); !errors.Is(err, ErrScrubScheduleNotFound) {
+		t.Fatalf("GetScrubSchedule without schedule = %v", err)
+	}
+	if err := z.SetScrubSchedule("tank", "every month"); err == nil {
+		t.Error("accepted an invalid cron expression")
+	}
+	if err := z.SetScrubSchedule("tank; reboot", "0 2 1 * *"); err == nil {
+		t.Error("accepted an invalid pool name")
+	}
+
+	if err := z.SetScrubSchedule("tank", "0  2 1 * *"); err != nil {
+		t.Fatalf("SetScrubSchedule: %v", err)
+	}
+	data, _ := os.ReadFile(z.scrubCronFile("tank"))
+	if !strings.Contains(string(data), "\n0 2 1 * * root zpool scrub tank\n") {
+		t.Errorf("cron file = %q", data)
+	}
+	if schedule, err := z.GetScrubSchedule("tank"); err != nil || schedule != "0 2 1 * *" {
+		t.Errorf("GetScrubSchedule = %q, %v", schedule, err)
+	}
+
+	if err := z.RemoveScrubSchedule("tank"); err != nil {
+		t.Fatalf("RemoveScrubSchedule: %v", err)
+	}
+	if err := z.RemoveScrubSchedule("tank"); !errors.Is(err, ErrScrubScheduleNotFound) {
+		t.Errorf("second RemoveScrubSchedule = %v", err)
+	}
+}
+
+func TestScrubMonitorAlertsOnce(t *testing.T) {
+	z, shell := newTestZFSManager(t)
+	shell.ExpectCommand("zpool", "list", "-H", "-p").Returns("tank\t10G\t5G\t5G\t-\t-\t10%\t50%\t1.00x\tONLINE\t-", "", 0)
+	shell.ExpectCommand("zpool", "status", "tank").Returns(zpoolStatusErrors, "", 0)
+	shell.Handler = func(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+		return &executor.CommandResult{Success: true}, nil
+	}
+
+	var alerts []*ScrubResult
+	m := NewScrubMonitor(z)
+	m.alert = func(result *ScrubResult) { alerts = append(alerts, result) }
+
+	for i := 0; i < 2; i++ {
+		if err := m.Check(); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	}
+	if len(alerts) != 1 || alerts[0].Pool != "tank" || alerts[0].Errors != 3 {
+		t.Errorf("alerts = %+v", alerts)
+	}
+}
@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/scheduler"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Dataset temperatures, by the days since any of its files was read
+const (
+	TemperatureHot  = "hot"  // Read within the last week
+	TemperatureWarm = "warm" // Read within the last 90 days
+	TemperatureCold = "cold"
+)
+
+// Outcomes of a dataset migration
+const (
+	TieringMigrated = "migrated"
+	TieringSkipped  = "skipped"
+	TieringFailed   = "failed"
+)
+
+const (
+	// tieringScanTimeout bounds the find over the files of one dataset
+	tieringScanTimeout = time.Hour
+
+	// tieringSendTimeout bounds one zfs send | zfs receive
+	tieringSendTimeout = 12 * time.Hour
+)
+
+// AutoTieringPolicy moves datasets that have not been read for a while from
+// a pool on fast vdevs to a pool on slow ones
+type AutoTieringPolicy struct {
+	HotPool           string `json:"hot_pool"`
+	ColdPool          string `json:"cold_pool"`
+	ColdThresholdDays int    `json:"cold_threshold_days"`  // Days without reads after which a dataset is cold
+	MaxColdTransferMB int    `json:"max_cold_transfer_mb"` // Data moved per run; 0 for no limit
+	Schedule          string `json:"schedule,omitempty"`   // Cron expression of scheduled runs
+}
+
+// DatasetTemperature is how recently the files of a dataset were read
+type DatasetTemperature struct {
+	Dataset      string     `json:"dataset"`
+	Mountpoint   string     `json:"mountpoint"`
+	Used         uint64     `json:"used"`
+	AtimeEnabled bool       `json:"atime_enabled"` // Without atime the access times are those of the last write
+	Files        int        `json:"files"`
+	Bytes        uint64     `json:"bytes"`
+	LastAccess   *time.Time `json:"last_access,omitempty"` // Newest access time of any file
+	IdleDays     int        `json:"idle_days"`
+	Temperature  string     `json:"temperature"` // hot, warm or cold
+	HasChildren  bool       `json:"has_children"`
+}
+
+// DatasetMigration is the outcome of moving one dataset to the cold pool
+type DatasetMigration struct {
+	Dataset  string `json:"dataset"`
+	Target   string `json:"target,omitempty"`
+	Bytes    uint64 `json:"bytes"`
+	IdleDays int    `json:"idle_days"`
+	Status   string `json:"status"` // migrated, skipped or failed
+	Reason   string `json:"reason,omitempty"`
+}
+
+// MigrationReport is the result of an auto-tiering run
+type MigrationReport struct {
+	HotPool          string             `json:"hot_pool"`
+	ColdPool         string             `json:"cold_pool"`
+	StartedAt        time.Time          `json:"started_at"`
+	FinishedAt       time.Time          `json:"finished_at"`
+	TransferredBytes uint64             `json:"transferred_bytes"`
+	Datasets         []DatasetMigration `json:"datasets"` // Cold datasets only
+}
+
+// AutoTieringManager approximates hot/cold tiering, which ZFS lacks, with
+// two pools. ZFS moves data between pools with zfs send, so a dataset is
+// the unit of tiering: it moves once none of its files was read within the
+// threshold, and keeps its mountpoint.
+type AutoTieringManager struct {
+	zfs *ZFSManager
+	now func() time.Time
+}
+
+// NewAutoTieringManager creates an auto-tiering manager for the ZFS pools
+func NewAutoTieringManager(zfs *ZFSManager) *AutoTieringManager {
+	return &AutoTieringManager{zfs: zfs, now: time.Now}
+}
+
+func (p *AutoTieringPolicy) validate() error {
+	if !poolNameRegex.MatchString(p.HotPool) {
+		return fmt.Errorf("invalid hot pool name %q", p.HotPool)
+	}
+	if !poolNameRegex.MatchString(p.ColdPool) {
+		return fmt.Errorf("invalid cold pool name %q", p.ColdPool)
+	}
+	if p.HotPool == p.ColdPool {
+		return fmt.Errorf("hot and cold pool must differ")
+	}
+	if p.ColdThresholdDays < 1 {
+		return fmt.Errorf("cold threshold must be at least one day")
+	}
+	if p.MaxColdTransferMB < 0 {
+		return fmt.Errorf("max cold transfer must not be negative")
+	}
+	if p.Schedule != "" {
+		if err := scheduler.ValidateCronExpression(p.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	return nil
+}
+
+// AnalyzeAccessPatterns reports the temperature of every mounted file
+// system of a pool, from the access times of its files
+func (m *AutoTieringManager) AnalyzeAccessPatterns(pool string) ([]DatasetTemperature, error) {
+	if !m.zfs.enabled {
+		return nil, fmt.Errorf("ZFS not available")
+	}
+	if !poolNameRegex.MatchString(pool) {
+		return nil, fmt.Errorf("invalid pool name %q", pool)
+	}
+
+	result, err := m.zfs.shell.Execute("zfs", "list", "-H", "-p", "-r", "-t", "filesystem", "-o", "name,used,mountpoint,atime", pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list datasets of %s: %w", pool, err)
+	}
+
+	var datasets []DatasetTemperature
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 {
+			continue
+		}
+		used, _ := strconv.ParseUint(fields[1], 10, 64)
+		datasets = append(datasets, DatasetTemperature{
+			Dataset:      fields[0],
+			Used:         used,
+			Mountpoint:   fields[2],
+			AtimeEnabled: fields[3] == "on",
+		})
+	}
+
+	now := m.now()
+	temperatures := make([]DatasetTemperature, 0, len(datasets))
+	for i := range datasets {
+		ds := &datasets[i]
+		for _, other := range datasets {
+			if strings.HasPrefix(other.Dataset, ds.Dataset+"/") {
+				ds.HasChildren = true
+				break
+			}
+		}
+		if !strings.HasPrefix(ds.Mountpoint, "/") {
+			continue // Not mounted: none, legacy or -
+		}
+		if err := m.scanFiles(ds, now); err != nil {
+			return nil, err
+		}
+		temperatures = append(temperatures, *ds)
+	}
+	return temperatures, nil
+}
+
+// scanFiles collects the access times of the files of a dataset. -xdev
+// leaves out child datasets, which are scanned on their own.
+func (m *AutoTieringManager) scanFiles(ds *DatasetTemperature, now time.Time) error {
+	result, err := m.zfs.shell.ExecuteWithTimeout(tieringScanTimeout,
+		"find", ds.Mountpoint, "-xdev", "-type", "f", "-printf", `%A@\t%s\n`)
+	if err != nil {
+		return fmt.Errorf("failed to scan files of %s: %w", ds.Dataset, err)
+	}
+
+	var newest float64
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		atime, size, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(atime, 64)
+		if err != nil {
+			continue
+		}
+		bytes, _ := strconv.ParseUint(size, 10, 64)
+		ds.Files++
+		ds.Bytes += bytes
+		newest = math.Max(newest, seconds)
+	}
+
+	if ds.Files > 0 {
+		sec, frac := math.Modf(newest)
+		lastAccess := time.Unix(int64(sec), int64(frac*1e9)).UTC()
+		ds.LastAccess = &lastAccess
+		ds.IdleDays = int(now.Sub(lastAccess).Hours() / 24)
+	}
+	switch {
+	case ds.Files == 0:
+		ds.Temperature = TemperatureCold
+	case ds.IdleDays < 7:
+		ds.Temperature = TemperatureHot
+	case ds.IdleDays < 90:
+		ds.Temperature = TemperatureWarm
+	default:
+		ds.Temperature = TemperatureCold
+	}
+	return nil
+}
+
+// MigrateColdData moves the datasets of the hot pool whose files were not
+// read within the policy threshold to the cold pool, least recently used
+// first, until the transfer limit is reached. A dataset is copied with an
+// incremental send while the original is read-only, so no writes are lost,
+// and then takes over the mountpoint of the original.
+func (m *AutoTieringManager) MigrateColdData(policy AutoTieringPolicy) (*MigrationReport, error) {
+	if !m.zfs.enabled {
+		return nil, fmt.Errorf("ZFS not available")
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := m.zfs.shell.Execute("zpool", "list", "-H", "-o", "name", policy.ColdPool); err != nil {
+		return nil, fmt.Errorf("cold pool %s not found: %w", policy.ColdPool, err)
+	}
+
+	report := &MigrationReport{HotPool: policy.HotPool, ColdPool: policy.ColdPool, StartedAt: m.now()}
+	temperatures, err := m.AnalyzeAccessPatterns(policy.HotPool)
+	if err != nil {
+		return nil, err
+	}
+
+	var cold []DatasetTemperature
+	for _, ds := range temperatures {
+		if ds.Files > 0 && ds.IdleDays >= policy.ColdThresholdDays {
+			cold = append(cold, ds)
+		}
+	}
+	sort.SliceStable(cold, func(i, j int) bool { return cold[i].IdleDays > cold[j].IdleDays })
+
+	limit := uint64(policy.MaxColdTransferMB) << 20
+	for _, ds := range cold {
+		migration := DatasetMigration{
+			Dataset:  ds.Dataset,
+			Target:   policy.ColdPool + strings.TrimPrefix(ds.Dataset, policy.HotPool),
+			Bytes:    ds.Used,
+			IdleDays: ds.IdleDays,
+			Status:   TieringSkipped,
+		}
+		switch {
+		case ds.Dataset == policy.HotPool:
+			migration.Target = ""
+			migration.Reason = "The root dataset of a pool cannot be moved"
+		case ds.HasChildren:
+			migration.Reason = "Datasets with child datasets are not moved"
+		case !ds.AtimeEnabled:
+			migration.Reason = "atime is off, so reads are not recorded"
+		case limit > 0 && report.TransferredBytes+ds.Used > limit:
+			migration.Reason = "Transfer limit reached"
+		default:
+			if err := m.migrateDataset(ds, migration.Target); err != nil {
+				migration.Status = TieringFailed
+				migration.Reason = err.Error()
+				logger.Error("Failed to move dataset to cold pool",
+					zap.String("dataset", ds.Dataset), zap.String("target", migration.Target), zap.Error(err))
+				break
+			}
+			migration.Status = TieringMigrated
+			migration.Reason = ""
+			report.TransferredBytes += ds.Used
+			logger.Info("Moved cold dataset",
+				zap.String("dataset", ds.Dataset), zap.String("target", migration.Target), zap.Int("idle_days", ds.IdleDays))
+		}
+		report.Datasets = append(report.Datasets, migration)
+	}
+
+	report.FinishedAt = m.now()
+	return report, nil
+}
+
+// migrateDataset moves a dataset to target, which must not exist yet
+func (m *AutoTieringManager) migrateDataset(ds DatasetTemperature, target string) error {
+	shell := m.zfs.shell
+	stamp := m.now().UTC().Format("20060102-150405")
+	base, final := ds.Dataset+"@autotier-"+stamp, ds.Dataset+"@autotier-"+stamp+"-final"
+
+	if _, err := shell.Execute("zfs", "list", "-H", "-o", "name", target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	}
+	if parent := target[:strings.LastIndex(target, "/")]; strings.Contains(parent, "/") {
+		if _, err := shell.Execute("zfs", "create", "-p", parent); err != nil {
+			return fmt.Errorf("failed to create %s: %w", parent, err)
+		}
+	}
+
+	send := func(args ...string) error {
+		pipeline := executor.NewPipeline(
+			executor.PipelineStage{Command: "zfs", Args: append([]string{"send"}, args...)},
+			executor.PipelineStage{Command: "zfs", Args: []string{"receive", "-u", target}},
+		).WithTimeout(tieringSendTimeout)
+		_, err := shell.RunPipeline(context.Background(), *pipeline)
+		return err
+	}
+
+	// Copy while the dataset is in use, then copy the last changes with the
+	// dataset read-only
+	if _, err := shell.Execute("zfs", "snapshot", base); err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", ds.Dataset, err)
+	}
+	rollback := func(err error) error {
+		// Leave the original as it was and remove the partial copy
+		shell.Execute("zfs", "inherit", "readonly", ds.Dataset)
+		shell.Execute("zfs", "destroy", ds.Dataset+"@autotier-"+stamp+"%autotier-"+stamp+"-final")
+		shell.Execute("zfs", "destroy", "-r", target)
+		return err
+	}
+	if err := send(base); err != nil {
+		return rollback(fmt.Errorf("failed to send %s: %w", ds.Dataset, err))
+	}
+	if _, err := shell.Execute("zfs", "set", "readonly=on", ds.Dataset); err != nil {
+		return rollback(fmt.Errorf("failed to make %s read-only: %w", ds.Dataset, err))
+	}
+	if _, err := shell.Execute("zfs", "snapshot", final); err != nil {
+		return rollback(fmt.Errorf("failed to snapshot %s: %w", ds.Dataset, err))
+	}
+	if err := send("-i", base, final); err != nil {
+		return rollback(fmt.Errorf("failed to send changes of %s: %w", ds.Dataset, err))
+	}
+
+	// The copy is complete; swap it in
+	if _, err := shell.Execute("zfs", "destroy", "-r", ds.Dataset); err != nil {
+		return rollback(fmt.Errorf("failed to destroy %s: %w", ds.Dataset, err))
+	}
+	if _, err := shell.Execute("zfs", "set", "mountpoint="+ds.Mountpoint, target); err != nil {
+		return fmt.Errorf("moved to %s but failed to set its mountpoint: %w", target, err)
+	}
+	if _, err := shell.Execute("zfs", "mount", target); err != nil {
+		return fmt.Errorf("moved to %s but failed to mount it: %w", target, err)
+	}
+	shell.Execute("zfs", "destroy", target+"@autotier-"+stamp+"%autotier-"+stamp+"-final")
+	return nil
+}
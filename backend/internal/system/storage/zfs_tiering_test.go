@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+)
+
+func newTestAutoTieringManager(t *testing.T) (*AutoTieringManager, *executor.MockShellExecutor, time.Time) {
+	t.Helper()
+	z, shell := newTestZFSManager(t)
+	now := time.Date(2025, 10, 12, 3, 0, 0, 0, time.UTC)
+	m := NewAutoTieringManager(z)
+	m.now = func() time.Time { return now }
+
+	shell.ExpectCommand("zfs", "list", "-H", "-p", "-r", "-t", "filesystem", "-o", "name,used,mountpoint,atime", "ssd").Returns(
+		"ssd\t4000000000\t/ssd\ton\n"+
+			"ssd/projects\t1000000000\t/srv/projects\ton\n"+
+			"ssd/archive\t2000000000\t/srv/archive\ton\n"+
+			"ssd/old\t3000000000\t/srv/old\ton\n"+
+			"ssd/scratch\t500000000\t/srv/scratch\toff\n"+
+			"ssd/vm\t100000\tnone\ton", "", 0)
+	daysAgo := func(days int) string {
+		return strconv.FormatInt(now.AddDate(0, 0, -days).Unix(), 10)
+	}
+	find := func(path string, lines ...string) {
+		shell.ExpectCommand("find", path, "-xdev", "-type", "f", "-printf", `%A@\t%s\n`).Returns(strings.Join(lines, "\n"), "", 0)
+	}
+	find("/ssd")
+	find("/srv/projects", daysAgo(400)+".5\t100", daysAgo(2)+"\t200")
+	find("/srv/archive", daysAgo(300)+".25\t1000", daysAgo(200)+"\t2000")
+	find("/srv/old", daysAgo(120)+"\t5000")
+	find("/srv/scratch", daysAgo(365)+"\t10")
+	return m, shell, now
+}
+
+func TestAnalyzeAccessPatterns(t *testing.T) {
+	m, shell, now := newTestAutoTieringManager(t)
+
+	temperatures, err := m.AnalyzeAccessPatterns("ssd")
+	if err != nil {
+		t.Fatalf("AnalyzeAccessPatterns: %v", err)
+	}
+	got := map[string]DatasetTemperature{}
+	for _, ds := range temperatures {
+		got[ds.Dataset] = ds
+	}
+	if len(temperatures) != 5 {
+		t.Errorf("analyzed %d datasets, want the 5 mounted ones", len(temperatures))
+	}
+	if ds := got["ssd/projects"]; ds.Files != 2 || ds.Bytes != 300 || ds.IdleDays != 2 || ds.Temperature != TemperatureHot {
+		t.Errorf("projects = %+v", ds)
+	}
+	archive := got["ssd/archive"]
+	if archive.IdleDays != 200 || archive.Temperature != TemperatureCold || !archive.LastAccess.Equal(now.AddDate(0, 0, -200)) {
+		t.Errorf("archive = %+v", archive)
+	}
+	if !got["ssd"].HasChildren || got["ssd/archive"].HasChildren || got["ssd/scratch"].AtimeEnabled {
+		t.Errorf("root = %+v, scratch = %+v", got["ssd"], got["ssd/scratch"])
+	}
+	shell.AssertExpectations(t)
+
+	if _, err := m.AnalyzeAccessPatterns("ssd; rm -rf /"); err == nil {
+		t.Error("accepted an invalid pool name")
+	}
+}
+
+func TestMigrateColdData(t *testing.T) {
+	m, shell, _ := newTestAutoTieringManager(t)
+	shell.ExpectCommand("zpool", "list", "-H", "-o", "name", "hdd").Returns("hdd", "", 0)
+	shell.ExpectCommand("zfs", "list", "-H", "-o", "name", "hdd/archive").Returns("", "dataset does not exist", 1)
+	shell.Handler = func(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+		return &executor.CommandResult{Success: true}, nil
+	}
+
+	if _, err := m.MigrateColdData(AutoTieringPolicy{HotPool: "ssd", ColdPool: "ssd", ColdThresholdDays: 90}); err == nil {
+		t.Error("accepted the same hot and cold pool")
+	}
+	if _, err := m.MigrateColdData(AutoTieringPolicy{HotPool: "ssd", ColdPool: "hdd", ColdThresholdDays: 90, Schedule: "nightly"}); err == nil {
+		t.Error("accepted an invalid schedule")
+	}
+
+	report, err := m.MigrateColdData(AutoTieringPolicy{HotPool: "ssd", ColdPool: "hdd", ColdThresholdDays: 90, MaxColdTransferMB: 4000, Schedule: "0 3 * * 0"})
+	if err != nil {
+		t.Fatalf("MigrateColdData: %v", err)
+	}
+
+	var got []string
+	for _, ds := range report.Datasets {
+		got = append(got, ds.Dataset+":"+ds.Status)
+	}
+	// Least recently used first; old no longer fits the transfer limit and
+	// scratch does not record reads
+	if want := "ssd/scratch:skipped,ssd/archive:migrated,ssd/old:skipped"; strings.Join(got, ",") != want {
+		t.Errorf("datasets = %s, want %s", strings.Join(got, ","), want)
+	}
+	if report.TransferredBytes != 2000000000 {
+		t.Errorf("transferred %d bytes, want 2000000000", report.TransferredBytes)
+	}
+
+	var zfsCalls []string
+	for _, call := range shell.Calls {
+		if call.Command == "zfs" && !strings.HasPrefix(call.String(), "zfs list") {
+			zfsCalls = append(zfsCalls, call.String())
+		}
+	}
+	want := []string{
+		"zfs snapshot ssd/archive@autotier-20251012-030000",
+		"zfs send ssd/archive@autotier-20251012-030000",
+		"zfs receive -u hdd/archive",
+		"zfs set readonly=on ssd/archive",
+		"zfs snapshot ssd/archive@autotier-20251012-030000-final",
+		"zfs send -i ssd/archive@autotier-20251012-030000 ssd/archive@autotier-20251012-030000-final",
+		"zfs receive -u hdd/archive",
+		"zfs destroy -r ssd/archive",
+		"zfs set mountpoint=/srv/archive hdd/archive",
+		"zfs mount hdd/archive",
+		"zfs destroy hdd/archive@autotier-20251012-030000%autotier-20251012-030000-final",
+	}
+	if strings.Join(zfsCalls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("zfs calls:\n%s\nwant:\n%s", strings.Join(zfsCalls, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -2,6 +2,7 @@
 package users
 
 import (
+	"context"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
 	"fmt"
 	"strings"
@@ -76,9 +77,10 @@ func (a *ADManager) JoinDomain(config ADConfig) error {
 		args = append(args, "-O", config.OU)
 	}
 
-	// Execute net ads join
-	_, err := a.shell.Execute("bash", "-c",
-		fmt.Sprintf("echo '%s' | net %s", config.Password, strings.Join(args, " ")))
+	// Execute net ads join, which reads the password from stdin
+	pipeline := executor.NewPipeline(executor.PipelineStage{Command: "net", Args: args}).
+		WithStdin(config.Password + "\n")
+	_, err := a.shell.RunPipeline(context.Background(), *pipeline)
 	if err != nil {
 		return fmt.Errorf("failed to join domain: %w", err)
 	}
@@ -97,8 +99,9 @@ func (a *ADManager) LeaveDomain(config ADConfig) error {
 		"-U", config.Administrator,
 	}
 
-	_, err := a.shell.Execute("bash", "-c",
-		fmt.Sprintf("echo '%s' | net %s", config.Password, strings.Join(args, " ")))
+	pipeline := executor.NewPipeline(executor.PipelineStage{Command: "net", Args: args}).
+		WithStdin(config.Password + "\n")
+	_, err := a.shell.RunPipeline(context.Background(), *pipeline)
 	if err != nil {
 		return fmt.Errorf("failed to leave domain: %w", err)
 	}
@@ -179,8 +182,9 @@ func (a *ADManager) GetUserInfo(username string) (*ADUser, error) {
 
 // TestAuthentication tests authentication against AD
 func (a *ADManager) TestAuthentication(username string, password string) error {
-	_, err := a.shell.Execute("bash", "-c",
-		fmt.Sprintf("echo '%s' | wbinfo -a '%s'", password, username))
+	pipeline := executor.NewPipeline(executor.PipelineStage{Command: "wbinfo", Args: []string{"-a", username}}).
+		WithStdin(password + "\n")
+	_, err := a.shell.RunPipeline(context.Background(), *pipeline)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
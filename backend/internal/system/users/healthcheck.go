@@ -0,0 +1,12 @@
+package users
+
+import "github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+
+func init() {
+	sysutil.RegisterComponents("users",
+		sysutil.ComponentDefinition{Name: "useradd", Command: "useradd", Required: true},
+		sysutil.ComponentDefinition{Name: "userdel", Command: "userdel", Required: true},
+		sysutil.ComponentDefinition{Name: "usermod", Command: "usermod", Required: true},
+		sysutil.ComponentDefinition{Name: "groupadd", Command: "groupadd", Required: true},
+	)
+}
@@ -2,6 +2,7 @@
 package users
 
 import (
+	"context"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
 	"fmt"
 	"os/user"
@@ -188,8 +189,9 @@ func (l *LocalManager) DeleteUser(username string, removeHome bool) error {
 
 // SetUserPassword sets a user's password
 func (l *LocalManager) SetUserPassword(username string, password string) error {
-	_, err := l.shell.Execute("bash", "-c",
-		fmt.Sprintf("echo '%s:%s' | chpasswd", username, password))
+	pipeline := executor.NewPipeline(executor.PipelineStage{Command: "chpasswd"}).
+		WithStdin(username + ":" + password + "\n")
+	_, err := l.shell.RunPipeline(context.Background(), *pipeline)
 	if err != nil {
 		return fmt.Errorf("failed to set password: %w", err)
 	}
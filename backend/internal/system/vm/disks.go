@@ -0,0 +1,351 @@
+package vm
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrDiskNotFound is returned for a disk target the VM does not have
+var ErrDiskNotFound = errors.New("disk not found")
+
+// diskTargetPrefixes are the device name prefixes the guest sees per bus
+var diskTargetPrefixes = map[string]string{
+	"virtio": "vd",
+	"sata":   "sd",
+	"scsi":   "sd",
+	"usb":    "sd",
+	"ide":    "hd",
+}
+
+var (
+	diskCacheModes = []string{"default", "none", "writethrough", "writeback", "directsync", "unsafe"}
+	diskIOModes    = []string{"native", "threads", "io_uring"}
+)
+
+// DiskOptions are the driver settings of an attached disk
+type DiskOptions struct {
+	Cache     string `json:"cache,omitempty"` // none, writethrough, writeback, directsync, unsafe; libvirt's default if empty
+	IO        string `json:"io,omitempty"`    // native, threads, io_uring; libvirt's default if empty
+	ReadOnly  bool   `json:"read_only"`
+	Shareable bool   `json:"shareable"` // May be attached to several VMs, e.g. for cluster file systems
+}
+
+// domainDisk is a <disk> element of a domain XML
+type domainDisk struct {
+	XMLName xml.Name `xml:"disk"`
+	Type    string   `xml:"type,attr"`
+	Device  string   `xml:"device,attr"`
+	Driver  struct {
+		Name  string `xml:"name,attr,omitempty"`
+		Type  string `xml:"type,attr,omitempty"`
+		Cache string `xml:"cache,attr,omitempty"`
+		IO    string `xml:"io,attr,omitempty"`
+	} `xml:"driver"`
+	Source struct {
+		File string `xml:"file,attr,omitempty"`
+		Dev  string `xml:"dev,attr,omitempty"`
+	} `xml:"source"`
+	Target struct {
+		Dev string `xml:"dev,attr"`
+		Bus string `xml:"bus,attr"`
+	} `xml:"target"`
+	ReadOnly  *struct{} `xml:"readonly"`
+	Shareable *struct{} `xml:"shareable"`
+}
+
+// ListDisks lists the disks and CD-ROM drives attached to a VM
+func (lm *LibvirtManager) ListDisks(vmName string) ([]VMDisk, error) {
+	if !lm.enabled {
+		return nil, fmt.Errorf("libvirt is not enabled")
+	}
+
+	result, err := lm.shell.Execute("virsh", "dumpxml", vmName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM configuration: %s: %w", result.Stderr, err)
+	}
+	return parseDomainDisks(result.Stdout)
+}
+
+// parseDomainDisks returns the disks of a domain XML
+func parseDomainDisks(domainXML string) ([]VMDisk, error) {
+	var domain struct {
+		Disks []domainDisk `xml:"devices>disk"`
+	}
+	if err := xml.Unmarshal([]byte(domainXML), &domain); err != nil {
+		return nil, fmt.Errorf("failed to parse VM configuration: %w", err)
+	}
+
+	disks := make([]VMDisk, 0, len(domain.Disks))
+	for _, d := range domain.Disks {
+		path := d.Source.File
+		if d.Type == "block" {
+			path = d.Source.Dev
+		}
+		disks = append(disks, VMDisk{
+			Path:      path,
+			Format:    d.Driver.Type,
+			Bus:       d.Target.Bus,
+			Target:    d.Target.Dev,
+			Device:    d.Device,
+			Cache:     d.Driver.Cache,
+			IO:        d.Driver.IO,
+			ReadOnly:  d.ReadOnly != nil,
+			Shareable: d.Shareable != nil,
+		})
+	}
+	return disks, nil
+}
+
+// nextDiskTarget returns the first device name for a bus that no attached
+// disk uses: vda, vdb, ..., vdz, vdaa, ...
+func nextDiskTarget(bus string, disks []VMDisk) (string, error) {
+	prefix, ok := diskTargetPrefixes[bus]
+	if !ok {
+		return "", fmt.Errorf("unsupported disk bus %q (use virtio, sata, scsi, usb or ide)", bus)
+	}
+
+	used := make(map[string]bool, len(disks))
+	for _, disk := range disks {
+		used[disk.Target] = true
+	}
+	for i := 0; i < 26*27; i++ {
+		target := prefix + diskTargetSuffix(i)
+		if !used[target] {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("no free %s disk target", bus)
+}
+
+// diskTargetSuffix returns the letters of the nth device name: a-z, aa-zz
+func diskTargetSuffix(n int) string {
+	if n < 26 {
+		return string(rune('a' + n))
+	}
+	return diskTargetSuffix(n/26-1) + string(rune('a'+n%26))
+}
+
+// AddDisk attaches an existing disk image or block device to a VM on the
+// next free target of the bus. The disk is added to the VM's configuration
+// and, if the VM is running, hot-plugged.
+func (lm *LibvirtManager) AddDisk(vmName string, imageFile string, bus string, opts DiskOptions) error {
+	if !lm.enabled {
+		return fmt.Errorf("libvirt is not enabled")
+	}
+	if opts.Cache != "" && !slices.Contains(diskCacheModes, opts.Cache) {
+		return fmt.Errorf("invalid cache mode %q", opts.Cache)
+	}
+	if opts.IO != "" && !slices.Contains(diskIOModes, opts.IO) {
+		return fmt.Errorf("invalid IO mode %q", opts.IO)
+	}
+	info, err := os.Stat(imageFile)
+	if err != nil {
+		return fmt.Errorf("disk image not found: %w", err)
+	}
+
+	disks, err := lm.ListDisks(vmName)
+	if err != nil {
+		return err
+	}
+	for _, disk := range disks {
+		if disk.Path == imageFile && !opts.Shareable {
+			return fmt.Errorf("%s is already attached as %s", imageFile, disk.Target)
+		}
+	}
+	target, err := nextDiskTarget(bus, disks)
+	if err != nil {
+		return err
+	}
+
+	disk := domainDisk{Type: "file", Device: "disk"}
+	if info.Mode()&os.ModeDevice != 0 {
+		disk.Type = "block"
+		disk.Source.Dev = imageFile
+		disk.Driver.Type = "raw"
+	} else {
+		disk.Source.File = imageFile
+		if disk.Driver.Type, err = lm.imageFormat(imageFile); err != nil {
+			return err
+		}
+	}
+	disk.Driver.Name = "qemu"
+	disk.Driver.Cache = opts.Cache
+	disk.Driver.IO = opts.IO
+	disk.Target.Dev = target
+	disk.Target.Bus = bus
+	if opts.ReadOnly {
+		disk.ReadOnly = &struct{}{}
+	}
+	if opts.Shareable {
+		disk.Shareable = &struct{}{}
+	}
+
+	diskXML, err := xml.MarshalIndent(disk, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to generate disk XML: %w", err)
+	}
+	f, err := os.CreateTemp("", "disk-*.xml")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(diskXML); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write disk XML: %w", err)
+	}
+	f.Close()
+
+	result, err := lm.shell.Execute("virsh", lm.liveArgs(vmName, "attach-device", vmName, f.Name(), "--config")...)
+	if err != nil {
+		return fmt.Errorf("failed to attach disk: %s: %w", result.Stderr, err)
+	}
+
+	logger.Info("VM disk attached",
+		zap.String("name", vmName),
+		zap.String("image", imageFile),
+		zap.String("target", target))
+	return nil
+}
+
+// imageFormat returns the format of a disk image file, e.g. qcow2 or raw
+func (lm *LibvirtManager) imageFormat(imageFile string) (string, error) {
+	result, err := lm.shell.Execute("qemu-img", "info", "--output=json", imageFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect disk image: %s: %w", result.Stderr, err)
+	}
+	var info struct {
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &info); err != nil || info.Format == "" {
+		return "", fmt.Errorf("failed to read the format of %s", imageFile)
+	}
+	return info.Format, nil
+}
+
+// ResizeDisk grows a disk of a VM to newSizeGB. Running VMs see the new size
+// right away; the partitions and file systems in the guest still need to be
+// grown.
+func (lm *LibvirtManager) ResizeDisk(vmName, diskPath string, newSizeGB int) error {
+	if !lm.enabled {
+		return fmt.Errorf("libvirt is not enabled")
+	}
+	if newSizeGB <= 0 {
+		return fmt.Errorf("invalid disk size %d GB", newSizeGB)
+	}
+
+	disks, err := lm.ListDisks(vmName)
+	if err != nil {
+		return err
+	}
+	disk := findDisk(disks, diskPath)
+	if disk == nil {
+		return fmt.Errorf("%w: %s", ErrDiskNotFound, diskPath)
+	}
+	if disk.Device != "disk" || disk.ReadOnly {
+		return fmt.Errorf("%s is not a writable disk", diskPath)
+	}
+	size := fmt.Sprintf("%dG", newSizeGB)
+
+	var result *executor.CommandResult
+	if lm.isRunning(vmName) {
+		if capacity, err := lm.diskCapacity(vmName, disk.Target); err == nil && int64(newSizeGB)<<30 < capacity {
+			return fmt.Errorf("disks can only be grown: %s is larger than %d GB", diskPath, newSizeGB)
+		}
+		result, err = lm.shell.Execute("virsh", "blockresize", vmName, disk.Target, size)
+	} else {
+		// qemu-img refuses to shrink images without --shrink
+		result, err = lm.shell.Execute("qemu-img", "resize", disk.Path, size)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resize disk: %s: %w", result.Stderr, err)
+	}
+
+	logger.Info("VM disk resized",
+		zap.String("name", vmName),
+		zap.String("target", disk.Target),
+		zap.Int("sizeGB", newSizeGB))
+	return nil
+}
+
+// diskCapacity returns the size of a disk of a running VM in bytes
+func (lm *LibvirtManager) diskCapacity(vmName, target string) (int64, error) {
+	result, err := lm.shell.Execute("virsh", "domblkinfo", vmName, target)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if value, ok := strings.CutPrefix(line, "Capacity:"); ok {
+			return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no capacity in domblkinfo output")
+}
+
+// RemoveDisk detaches a disk from a VM, from the running VM as well, and
+// optionally deletes its image file
+func (lm *LibvirtManager) RemoveDisk(vmName, target string, deleteImage bool) error {
+	if !lm.enabled {
+		return fmt.Errorf("libvirt is not enabled")
+	}
+
+	disks, err := lm.ListDisks(vmName)
+	if err != nil {
+		return err
+	}
+	disk := findDisk(disks, target)
+	if disk == nil {
+		return fmt.Errorf("%w: %s", ErrDiskNotFound, target)
+	}
+
+	result, err := lm.shell.Execute("virsh", lm.liveArgs(vmName, "detach-disk", vmName, disk.Target, "--config")...)
+	if err != nil {
+		return fmt.Errorf("failed to detach disk: %s: %w", result.Stderr, err)
+	}
+
+	if deleteImage && disk.Path != "" && !strings.HasPrefix(disk.Path, "/dev/") {
+		if err := os.Remove(disk.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("disk detached, but failed to delete its image: %w", err)
+		}
+	}
+
+	logger.Info("VM disk removed",
+		zap.String("name", vmName),
+		zap.String("target", disk.Target),
+		zap.Bool("imageDeleted", deleteImage))
+	return nil
+}
+
+// findDisk returns the disk with a target or path
+func findDisk(disks []VMDisk, targetOrPath string) *VMDisk {
+	for i := range disks {
+		if disks[i].Target == targetOrPath || disks[i].Path == targetOrPath {
+			return &disks[i]
+		}
+	}
+	return nil
+}
+
+// isRunning reports whether a VM is running
+func (lm *LibvirtManager) isRunning(vmName string) bool {
+	result, err := lm.shell.Execute("virsh", "domstate", vmName)
+	return err == nil && strings.TrimSpace(result.Stdout) == "running"
+}
+
+// liveArgs appends --live to virsh arguments if the VM is running, so a
+// device change applies to the running VM as well as its configuration
+func (lm *LibvirtManager) liveArgs(vmName string, args ...string) []string {
+	if lm.isRunning(vmName) {
+		args = append(args, "--live")
+	}
+	return args
+}
@@ -0,0 +1,175 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+const dumpXMLWithDisks = `<domain type='kvm' id='3'>
+  <name>db</name>
+  <memory unit='KiB'>4194304</memory>
+  <devices>
+    <emulator>/usr/bin/qemu-system-x86_64</emulator>
+    <disk type='file' device='disk'>
+      <driver name='qemu' type='qcow2' cache='none' io='native'/>
+      <source file='/var/lib/libvirt/images/db.qcow2' index='3'/>
+      <target dev='vda' bus='virtio'/>
+      <address type='pci' domain='0x0000' bus='0x04' slot='0x00' function='0x0'/>
+    </disk>
+    <disk type='block' device='disk'>
+      <driver name='qemu' type='raw'/>
+      <source dev='/dev/zvol/tank/db-data' index='2'/>
+      <target dev='vdc' bus='virtio'/>
+      <shareable/>
+    </disk>
+    <disk type='file' device='cdrom'>
+      <driver name='qemu' type='raw'/>
+      <source file='/var/lib/libvirt/images/db-cloud-init.iso' index='1'/>
+      <target dev='sda' bus='sata'/>
+      <readonly/>
+    </disk>
+    <interface type='network'>
+      <source network='default'/>
+    </interface>
+  </devices>
+</domain>
+`
+
+func newTestLibvirtManager(t *testing.T) (*LibvirtManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("which", "virsh").Returns("/usr/bin/virsh\n", "", 0)
+	shell.ExpectCommand("systemctl", "is-active", "libvirtd").Returns("active\n", "", 0)
+	lm, err := NewLibvirtManager(shell)
+	if err != nil {
+		t.Fatalf("NewLibvirtManager: %v", err)
+	}
+	return lm, shell
+}
+
+func TestParseDomainDisks(t *testing.T) {
+	disks, err := parseDomainDisks(dumpXMLWithDisks)
+	if err != nil {
+		t.Fatalf("parseDomainDisks: %v", err)
+	}
+
+	want := []VMDisk{
+		{Path: "/var/lib/libvirt/images/db.qcow2", Format: "qcow2", Bus: "virtio", Target: "vda", Device: "disk", Cache: "none", IO: "native"},
+		{Path: "/dev/zvol/tank/db-data", Format: "raw", Bus: "virtio", Target: "vdc", Device: "disk", Shareable: true},
+		{Path: "/var/lib/libvirt/images/db-cloud-init.iso", Format: "raw", Bus: "sata", Target: "sda", Device: "cdrom", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(disks, want) {
+		t.Errorf("disks =\n%+v\nwant\n%+v", disks, want)
+	}
+}
+
+func TestNextDiskTarget(t *testing.T) {
+	disks, _ := parseDomainDisks(dumpXMLWithDisks)
+
+	tests := []struct {
+		bus  string
+		want string
+	}{
+		{"virtio", "vdb"}, // The gap between vda and vdc
+		{"sata", "sdb"},   // sda is the cloud-init CD-ROM
+		{"scsi", "sdb"},   // SCSI and SATA disks share the sd names
+		{"ide", "hda"},
+	}
+	for _, tt := range tests {
+		if got, err := nextDiskTarget(tt.bus, disks); err != nil || got != tt.want {
+			t.Errorf("nextDiskTarget(%s) = %q, %v, want %q", tt.bus, got, err, tt.want)
+		}
+	}
+
+	var many []VMDisk
+	for i := 0; i < 27; i++ {
+		many = append(many, VMDisk{Target: "vd" + diskTargetSuffix(i)})
+	}
+	if got, _ := nextDiskTarget("virtio", many); got != "vdab" {
+		t.Errorf("target after vdaa = %q, want vdab", got)
+	}
+	if _, err := nextDiskTarget("floppy", disks); err == nil {
+		t.Error("accepted an unsupported bus")
+	}
+}
+
+func TestAddDisk(t *testing.T) {
+	lm, shell := newTestLibvirtManager(t)
+	image := filepath.Join(t.TempDir(), "db-logs.qcow2")
+	if err := os.WriteFile(image, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var diskXML string
+	shell.ExpectCommand("virsh", "dumpxml", "db").Returns(dumpXMLWithDisks, "", 0)
+	shell.ExpectCommand("qemu-img", "info", "--output=json", image).Returns(`{"format": "qcow2", "virtual-size": 10737418240}`, "", 0)
+	shell.ExpectCommand("virsh", "domstate", "db").Returns("running\n", "", 0)
+	shell.Handler = func(call executor.ExecutedCommand) (*executor.CommandResult, error) {
+		// Read the temp file before AddDisk removes it
+		if call.Command == "virsh" && len(call.Args) == 5 && call.Args[0] == "attach-device" &&
+			call.Args[3] == "--config" && call.Args[4] == "--live" {
+			data, _ := os.ReadFile(call.Args[2])
+			diskXML = string(data)
+			return &executor.CommandResult{Success: true}, nil
+		}
+		t.Errorf("unexpected command: %s", call)
+		return &executor.CommandResult{}, os.ErrInvalid
+	}
+
+	if err := lm.AddDisk("db", image, "virtio", DiskOptions{Cache: "writeback", IO: "threads"}); err != nil {
+		t.Fatalf("AddDisk: %v", err)
+	}
+	for _, part := range []string{
+		`<disk type="file" device="disk">`,
+		`<driver name="qemu" type="qcow2" cache="writeback" io="threads"></driver>`,
+		`<source file="` + image + `"></source>`,
+		`<target dev="vdb" bus="virtio"></target>`,
+	} {
+		if !strings.Contains(diskXML, part) {
+			t.Errorf("disk XML lacks %s:\n%s", part, diskXML)
+		}
+	}
+	if strings.Contains(diskXML, "readonly") || strings.Contains(diskXML, "shareable") {
+		t.Errorf("disk XML is read-only or shareable:\n%s", diskXML)
+	}
+	shell.AssertExpectations(t)
+
+	if err := lm.AddDisk("db", image, "virtio", DiskOptions{Cache: "fast"}); err == nil {
+		t.Error("accepted an invalid cache mode")
+	}
+	if err := lm.AddDisk("db", "/nonexistent.qcow2", "virtio", DiskOptions{}); err == nil {
+		t.Error("accepted a missing image")
+	}
+}
+
+func TestResizeAndRemoveDisk(t *testing.T) {
+	lm, shell := newTestLibvirtManager(t)
+
+	shell.ExpectCommand("virsh", "dumpxml", "db").Returns(dumpXMLWithDisks, "", 0)
+	shell.ExpectCommand("virsh", "domstate", "db").Returns("shut off\n", "", 0)
+	shell.ExpectCommand("qemu-img", "resize", "/var/lib/libvirt/images/db.qcow2", "40G").Returns("Image resized.", "", 0).Times(1)
+	shell.ExpectCommand("virsh", "detach-disk", "db", "vdc", "--config").Returns("Disk detached successfully", "", 0).Times(1)
+
+	if err := lm.ResizeDisk("db", "vda", 40); err != nil {
+		t.Fatalf("ResizeDisk: %v", err)
+	}
+	if err := lm.ResizeDisk("db", "sda", 40); err == nil {
+		t.Error("resized the read-only CD-ROM")
+	}
+	if err := lm.ResizeDisk("db", "vdz", 40); err == nil {
+		t.Error("resized a missing disk")
+	}
+
+	// Block devices are never deleted
+	if err := lm.RemoveDisk("db", "/dev/zvol/tank/db-data", true); err != nil {
+		t.Fatalf("RemoveDisk: %v", err)
+	}
+	shell.AssertExpectations(t)
+}
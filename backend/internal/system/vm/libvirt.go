@@ -35,10 +35,16 @@ type VM struct {
 
 // VMDisk represents a VM disk
 type VMDisk struct {
-	Path   string `json:"path"`
-	Size   int64  `json:"size"` // GB
-	Format string `json:"format"` // qcow2, raw
-	Bus    string `json:"bus"`    // virtio, sata, scsi
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`            // GB
+	Format    string `json:"format"`          // qcow2, raw
+	Bus       string `json:"bus"`             // virtio, sata, scsi
+	Target    string `json:"target"`          // Device name in the guest, e.g. vdb
+	Device    string `json:"device"`          // disk, cdrom
+	Cache     string `json:"cache,omitempty"` // Driver cache mode
+	IO        string `json:"io,omitempty"`    // Driver IO mode
+	ReadOnly  bool   `json:"read_only"`
+	Shareable bool   `json:"shareable"`
 }
 
 // VMNetwork represents a VM network interface
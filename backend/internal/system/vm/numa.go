@@ -0,0 +1,204 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// cpuSetRegex matches libvirt CPU and node sets such as "0-3,8,10-11"
+	cpuSetRegex = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+
+	memoryBackingRegex = regexp.MustCompile(`(?s)\s*<memoryBacking>(.*?)</memoryBacking>`)
+	hugepagesRegex     = regexp.MustCompile(`(?s)<hugepages\s*/>|<hugepages>.*?</hugepages>`)
+	numatuneRegex      = regexp.MustCompile(`(?s)\s*<numatune>.*?</numatune>`)
+
+	numaCPUsRegex = regexp.MustCompile(`^node (\d+) cpus:(.*)$`)
+	numaSizeRegex = regexp.MustCompile(`^node (\d+) (size|free): (\d+) MB$`)
+)
+
+// VCPUPin pins a virtual CPU of a VM to a set of host CPUs
+type VCPUPin struct {
+	VCPU   int    `json:"vcpu"`
+	CPUSet string `json:"cpuset"` // e.g. "2-3" or "4,12"
+}
+
+// NUMANode is a NUMA node of the host
+type NUMANode struct {
+	ID       int   `json:"id"`
+	CPUs     []int `json:"cpus"`
+	MemoryMB int64 `json:"memory_mb"`
+	FreeMB   int64 `json:"free_mb"`
+}
+
+// NUMATopology is the NUMA layout of the host
+type NUMATopology struct {
+	Nodes []NUMANode `json:"nodes"`
+}
+
+// SetCPUPinning pins virtual CPUs of a VM to host CPUs. The pinning is stored
+// in the VM's configuration as <vcpupin> elements and applied right away if
+// the VM is running.
+func (lm *LibvirtManager) SetCPUPinning(vmName string, vcpuMap []VCPUPin) error {
+	if !lm.enabled {
+		return fmt.Errorf("libvirt is not enabled")
+	}
+	for _, pin := range vcpuMap {
+		if pin.VCPU < 0 {
+			return fmt.Errorf("invalid vCPU %d", pin.VCPU)
+		}
+		if !cpuSetRegex.MatchString(pin.CPUSet) {
+			return fmt.Errorf("invalid CPU set %q for vCPU %d", pin.CPUSet, pin.VCPU)
+		}
+	}
+
+	running := false
+	if result, err := lm.shell.Execute("virsh", "domstate", vmName); err == nil {
+		running = strings.TrimSpace(result.Stdout) == "running"
+	}
+
+	for _, pin := range vcpuMap {
+		args := []string{"vcpupin", vmName, strconv.Itoa(pin.VCPU), pin.CPUSet, "--config"}
+		if running {
+			args = append(args, "--live")
+		}
+		result, err := lm.shell.Execute("virsh", args...)
+		if err != nil {
+			return fmt.Errorf("failed to pin vCPU %d: %s: %w", pin.VCPU, result.Stderr, err)
+		}
+	}
+
+	logger.Info("VM CPU pinning updated", zap.String("name", vmName), zap.Int("vcpus", len(vcpuMap)))
+	return nil
+}
+
+// SetMemoryBacking backs the memory of a VM with hugepages and binds it to the
+// NUMA nodes in nodeset. An empty nodeset removes the binding. The change takes
+// effect the next time the VM starts.
+func (lm *LibvirtManager) SetMemoryBacking(vmName string, hugepages bool, nodeset string) error {
+	if !lm.enabled {
+		return fmt.Errorf("libvirt is not enabled")
+	}
+	if nodeset != "" && !cpuSetRegex.MatchString(nodeset) {
+		return fmt.Errorf("invalid NUMA node set %q", nodeset)
+	}
+
+	result, err := lm.shell.Execute("virsh", "dumpxml", "--inactive", vmName)
+	if err != nil {
+		return fmt.Errorf("failed to get VM configuration: %s: %w", result.Stderr, err)
+	}
+
+	domainXML, err := setMemoryBackingXML(result.Stdout, hugepages, nodeset)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "domain-*.xml")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(domainXML); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write VM configuration: %w", err)
+	}
+	f.Close()
+
+	result, err = lm.shell.Execute("virsh", "define", f.Name())
+	if err != nil {
+		return fmt.Errorf("failed to update VM configuration: %s: %w", result.Stderr, err)
+	}
+
+	logger.Info("VM memory backing updated",
+		zap.String("name", vmName),
+		zap.Bool("hugepages", hugepages),
+		zap.String("nodeset", nodeset))
+	return nil
+}
+
+// setMemoryBackingXML sets or removes <hugepages/> in the <memoryBacking> of
+// a domain XML, keeping its other settings, and replaces its <numatune>. The
+// domain schema does not order its children, so new elements go at the end.
+func setMemoryBackingXML(domainXML string, hugepages bool, nodeset string) (string, error) {
+	if !strings.Contains(domainXML, "</domain>") {
+		return "", fmt.Errorf("invalid domain XML")
+	}
+
+	var add strings.Builder
+	backing := ""
+	if m := memoryBackingRegex.FindStringSubmatch(domainXML); m != nil {
+		backing = strings.TrimSpace(hugepagesRegex.ReplaceAllString(m[1], ""))
+	}
+	if hugepages {
+		backing = strings.TrimSpace("<hugepages/>\n    " + backing)
+	}
+	if backing != "" {
+		fmt.Fprintf(&add, "  <memoryBacking>\n    %s\n  </memoryBacking>\n", backing)
+	}
+	if nodeset != "" {
+		fmt.Fprintf(&add, "  <numatune>\n    <memory mode='strict' nodeset='%s'/>\n  </numatune>\n", nodeset)
+	}
+
+	domainXML = memoryBackingRegex.ReplaceAllString(domainXML, "")
+	domainXML = numatuneRegex.ReplaceAllString(domainXML, "")
+	end := strings.LastIndex(domainXML, "</domain>")
+	head := strings.TrimRight(domainXML[:end], " \t\n") + "\n"
+	return head + add.String() + domainXML[end:], nil
+}
+
+// GetNUMATopology returns the NUMA nodes of the host with their CPUs and memory
+func (lm *LibvirtManager) GetNUMATopology() (*NUMATopology, error) {
+	result, err := lm.shell.Execute("numactl", "--hardware")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NUMA topology: %w", err)
+	}
+	return ParseNUMATopology(result.Stdout)
+}
+
+// ParseNUMATopology parses the output of numactl --hardware
+func ParseNUMATopology(output string) (*NUMATopology, error) {
+	topology := &NUMATopology{Nodes: []NUMANode{}}
+	node := func(id int) *NUMANode {
+		for i := range topology.Nodes {
+			if topology.Nodes[i].ID == id {
+				return &topology.Nodes[i]
+			}
+		}
+		topology.Nodes = append(topology.Nodes, NUMANode{ID: id, CPUs: []int{}})
+		return &topology.Nodes[len(topology.Nodes)-1]
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if m := numaCPUsRegex.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			n := node(id)
+			for _, field := range strings.Fields(m[2]) {
+				cpu, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("invalid CPU %q of NUMA node %d", field, id)
+				}
+				n.CPUs = append(n.CPUs, cpu)
+			}
+		} else if m := numaSizeRegex.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			mb, _ := strconv.ParseInt(m[3], 10, 64)
+			if m[2] == "size" {
+				node(id).MemoryMB = mb
+			} else {
+				node(id).FreeMB = mb
+			}
+		}
+	}
+
+	if len(topology.Nodes) == 0 {
+		return nil, fmt.Errorf("no NUMA nodes found")
+	}
+	return topology, nil
+}
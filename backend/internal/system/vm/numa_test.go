@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+const numactlHardware = `available: 2 nodes (0-1)
+node 0 cpus: 0 1 2 3 8 9 10 11
+node 0 size: 32168 MB
+node 0 free: 20480 MB
+node 1 cpus: 4 5 6 7 12 13 14 15
+node 1 size: 32252 MB
+node 1 free: 28001 MB
+node distances:
+node   0   1
+  0:  10  21
+  1:  21  10
+`
+
+func TestParseNUMATopology(t *testing.T) {
+	topology, err := ParseNUMATopology(numactlHardware)
+	if err != nil {
+		t.Fatalf("ParseNUMATopology: %v", err)
+	}
+
+	want := []NUMANode{
+		{ID: 0, CPUs: []int{0, 1, 2, 3, 8, 9, 10, 11}, MemoryMB: 32168, FreeMB: 20480},
+		{ID: 1, CPUs: []int{4, 5, 6, 7, 12, 13, 14, 15}, MemoryMB: 32252, FreeMB: 28001},
+	}
+	if !reflect.DeepEqual(topology.Nodes, want) {
+		t.Errorf("nodes = %+v", topology.Nodes)
+	}
+
+	// Memory-only nodes, e.g. CXL memory, have no CPUs
+	topology, err = ParseNUMATopology("available: 1 nodes (0)\nnode 0 cpus:\nnode 0 size: 1024 MB\n")
+	if err != nil || len(topology.Nodes) != 1 || len(topology.Nodes[0].CPUs) != 0 {
+		t.Errorf("memory-only node = %+v, %v", topology, err)
+	}
+
+	if _, err := ParseNUMATopology("No NUMA available on this system\n"); err == nil {
+		t.Error("parsed a system without NUMA")
+	}
+}
+
+func TestSetCPUPinning(t *testing.T) {
+	logger.InitLogger("error", false)
+	shell := executor.NewMockShellExecutor()
+	shell.ExpectCommand("which", "virsh").Returns("/usr/bin/virsh\n", "", 0)
+	shell.ExpectCommand("systemctl", "is-active", "libvirtd").Returns("active\n", "", 0)
+	lm, err := NewLibvirtManager(shell)
+	if err != nil {
+		t.Fatalf("NewLibvirtManager: %v", err)
+	}
+
+	shell.ExpectCommand("virsh", "domstate", "db").Returns("running\n", "", 0)
+	shell.ExpectCommand("virsh", "vcpupin", "db", "0", "2", "--config", "--live").Returns("", "", 0)
+	shell.ExpectCommand("virsh", "vcpupin", "db", "1", "10-11", "--config", "--live").Returns("", "", 0)
+
+	if err := lm.SetCPUPinning("db", []VCPUPin{{VCPU: 0, CPUSet: "2"}, {VCPU: 1, CPUSet: "10-11"}}); err != nil {
+		t.Fatalf("SetCPUPinning: %v", err)
+	}
+	shell.AssertExpectations(t)
+
+	if err := lm.SetCPUPinning("db", []VCPUPin{{VCPU: 0, CPUSet: "2; reboot"}}); err == nil {
+		t.Error("accepted an invalid CPU set")
+	}
+}
+
+func TestSetMemoryBackingXML(t *testing.T) {
+	domain := `<domain type='kvm'>
+  <name>db</name>
+  <memory unit='KiB'>4194304</memory>
+  <memoryBacking>
+    <nosharepages/>
+  </memoryBacking>
+  <vcpu placement='static'>2</vcpu>
+</domain>
+`
+	got, err := setMemoryBackingXML(domain, true, "1")
+	if err != nil {
+		t.Fatalf("setMemoryBackingXML: %v", err)
+	}
+	if strings.Count(got, "<memoryBacking>") != 1 ||
+		!strings.Contains(got, "<memoryBacking>\n    <hugepages/>\n    <nosharepages/>\n  </memoryBacking>") ||
+		!strings.Contains(got, "<memory mode='strict' nodeset='1'/>") ||
+		!strings.HasSuffix(got, "</numatune>\n</domain>\n") {
+		t.Errorf("domain XML =\n%s", got)
+	}
+
+	got, _ = setMemoryBackingXML(got, false, "")
+	got, _ = setMemoryBackingXML(got, false, "")
+	if strings.Contains(got, "hugepages") || !strings.Contains(got, "<nosharepages/>") || strings.Contains(got, "numatune") {
+		t.Errorf("domain XML after reset =\n%s", got)
+	}
+}
@@ -0,0 +1,291 @@
+package vpn
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrPeerNotFound is returned for peers that are not recorded in the database
+var ErrPeerNotFound = errors.New("vpn peer not found")
+
+// fullTunnel routes all IPv4 and IPv6 traffic through the tunnel
+var fullTunnel = []string{"0.0.0.0/0", "::/0"}
+
+// SplitTunnelPolicy decides which traffic a peer routes through the tunnel.
+// With RouteAllTraffic everything except ExcludedCIDRs is routed, otherwise
+// AllowedCIDRs except ExcludedCIDRs.
+type SplitTunnelPolicy struct {
+	PeerID          string   `json:"peerId"`
+	AllowedCIDRs    []string `json:"allowedCIDRs"`
+	ExcludedCIDRs   []string `json:"excludedCIDRs"`
+	RouteAllTraffic bool     `json:"routeAllTraffic"`
+}
+
+// SetPeerSplitTunnel changes the traffic the client of a peer routes through
+// the tunnel and regenerates its client configuration. The AllowedIPs of the
+// peer in the interface configuration remain its tunnel addresses, as they
+// decide which source addresses the server accepts from the peer.
+func (m *VPNManager) SetPeerSplitTunnel(peerID string, policy SplitTunnelPolicy) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	id, err := strconv.ParseUint(peerID, 10, 64)
+	if err != nil {
+		return ErrPeerNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var peer models.VPNPeer
+	if err := db.First(&peer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPeerNotFound
+		}
+		return fmt.Errorf("failed to get vpn peer: %w", err)
+	}
+
+	iface, _, err := readWireGuardConfig(m.configPath(peer.Interface))
+	if os.IsNotExist(err) {
+		return ErrWireGuardInterfaceNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Split tunnels always route the tunnel network so the server stays reachable
+	allowed := policy.AllowedCIDRs
+	if !policy.RouteAllTraffic {
+		if tunnel, err := netip.ParsePrefix(iface.Address); err == nil {
+			allowed = append([]string{tunnel.Masked().String()}, allowed...)
+		}
+	}
+	clientAllowedIPs, err := ComputeAllowedIPs(policy.RouteAllTraffic, allowed, policy.ExcludedCIDRs)
+	if err != nil {
+		return err
+	}
+	if len(clientAllowedIPs) == 0 {
+		return fmt.Errorf("the split tunnel policy routes no traffic through the tunnel")
+	}
+
+	peer.RouteAllTraffic = policy.RouteAllTraffic
+	peer.AllowedCIDRs = nonNil(policy.AllowedCIDRs)
+	peer.ExcludedCIDRs = nonNil(policy.ExcludedCIDRs)
+	peer.ClientAllowedIPs = clientAllowedIPs
+	peer.ClientConfig = m.clientConfig(iface, &peer)
+	if err := db.Save(&peer).Error; err != nil {
+		return fmt.Errorf("failed to save vpn peer: %w", err)
+	}
+
+	logger.Info("VPN peer split tunnel updated",
+		zap.Uint("peer", peer.ID),
+		zap.Bool("routeAllTraffic", policy.RouteAllTraffic),
+		zap.Int("allowedIPs", len(clientAllowedIPs)))
+	return nil
+}
+
+// ListPeers lists the recorded peers of an interface with their split tunnel policies
+func (m *VPNManager) ListPeers(ifaceName string) ([]models.VPNPeer, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	peers := []models.VPNPeer{}
+	if err := db.Where("interface = ?", ifaceName).Order("id").Find(&peers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list vpn peers: %w", err)
+	}
+	return peers, nil
+}
+
+// GetPeerClientConfig returns the wg-quick configuration of a peer's client
+func (m *VPNManager) GetPeerClientConfig(peerID string) (string, error) {
+	db := database.GetDB()
+	if db == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+
+	var peer models.VPNPeer
+	if err := db.First(&peer, "id = ?", peerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrPeerNotFound
+		}
+		return "", fmt.Errorf("failed to get vpn peer: %w", err)
+	}
+	return peer.ClientConfig, nil
+}
+
+// recordPeer stores a peer created through CreateWireGuardPeer with a full
+// tunnel client configuration. Without a database the peer is only kept in
+// the interface configuration.
+func (m *VPNManager) recordPeer(iface *WireGuardInterface, peer WireGuardPeer) error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+
+	record := &models.VPNPeer{
+		Interface:        iface.Name,
+		Name:             peer.Name,
+		PublicKey:        peer.PublicKey,
+		AllowedIPs:       peer.AllowedIPs,
+		RouteAllTraffic:  true,
+		AllowedCIDRs:     []string{},
+		ExcludedCIDRs:    []string{},
+		ClientAllowedIPs: fullTunnel,
+	}
+	record.ClientConfig = m.clientConfig(iface, record)
+	if err := db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to record vpn peer: %w", err)
+	}
+	return nil
+}
+
+// forgetPeers removes the database records of the peers of a deleted interface
+func forgetPeers(ifaceName string) error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+	if err := db.Where("interface = ?", ifaceName).Delete(&models.VPNPeer{}).Error; err != nil {
+		return fmt.Errorf("failed to remove vpn peers: %w", err)
+	}
+	return nil
+}
+
+// clientConfig generates the wg-quick configuration of a peer's client. The
+// private key of the client never leaves it, so the client fills it in.
+func (m *VPNManager) clientConfig(iface *WireGuardInterface, peer *models.VPNPeer) string {
+	host := m.endpointHost
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	var b strings.Builder
+	b.WriteString("[Interface]\n")
+	b.WriteString("PrivateKey = <client private key>\n")
+	fmt.Fprintf(&b, "Address = %s\n", strings.Join(peer.AllowedIPs, ", "))
+	b.WriteString("\n[Peer]\n")
+	fmt.Fprintf(&b, "PublicKey = %s\n", iface.PublicKey)
+	fmt.Fprintf(&b, "Endpoint = %s\n", net.JoinHostPort(host, strconv.Itoa(iface.ListenPort)))
+	fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(peer.ClientAllowedIPs, ", "))
+	b.WriteString("PersistentKeepalive = 25\n")
+	return b.String()
+}
+
+// ComputeAllowedIPs returns the CIDRs a client routes through the tunnel:
+// everything (IPv4 and IPv6) when routeAll is set, otherwise the allowed
+// CIDRs, minus the excluded CIDRs. Excluding a range splits the ranges
+// containing it into the smallest set of CIDRs that cover the remainder.
+func ComputeAllowedIPs(routeAll bool, allowed, excluded []string) ([]string, error) {
+	if routeAll {
+		allowed = fullTunnel
+	}
+
+	include, err := parsePrefixes(allowed)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := parsePrefixes(excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []netip.Prefix
+	for _, prefix := range include {
+		result = append(result, subtractPrefixes(prefix, exclude)...)
+	}
+	result = removeContained(result)
+
+	cidrs := make([]string, 0, len(result))
+	for _, prefix := range result {
+		cidrs = append(cidrs, prefix.String())
+	}
+	return cidrs, nil
+}
+
+// subtractPrefixes returns the parts of prefix not covered by exclude
+func subtractPrefixes(prefix netip.Prefix, exclude []netip.Prefix) []netip.Prefix {
+	overlapping := false
+	for _, ex := range exclude {
+		if ex.Bits() <= prefix.Bits() && ex.Contains(prefix.Addr()) {
+			return nil // Fully excluded
+		}
+		if ex.Overlaps(prefix) {
+			overlapping = true
+		}
+	}
+	if !overlapping {
+		return []netip.Prefix{prefix}
+	}
+
+	// An excluded range lies inside prefix; split it in halves
+	lower, upper := splitPrefix(prefix)
+	return append(subtractPrefixes(lower, exclude), subtractPrefixes(upper, exclude)...)
+}
+
+// splitPrefix splits a prefix into its two halves
+func splitPrefix(prefix netip.Prefix) (netip.Prefix, netip.Prefix) {
+	bits := prefix.Bits()
+	addr := prefix.Addr().AsSlice()
+	lower := netip.PrefixFrom(prefix.Addr(), bits+1)
+	addr[bits/8] |= 0x80 >> (bits % 8)
+	upperAddr, _ := netip.AddrFromSlice(addr)
+	return lower, netip.PrefixFrom(upperAddr, bits+1)
+}
+
+// removeContained sorts prefixes and drops those contained in another prefix
+func removeContained(prefixes []netip.Prefix) []netip.Prefix {
+	sort.Slice(prefixes, func(i, j int) bool {
+		if c := prefixes[i].Addr().Compare(prefixes[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return prefixes[i].Bits() < prefixes[j].Bits()
+	})
+
+	result := []netip.Prefix{}
+	for _, prefix := range prefixes {
+		if len(result) > 0 {
+			last := result[len(result)-1]
+			if last.Addr().BitLen() == prefix.Addr().BitLen() && last.Bits() <= prefix.Bits() && last.Contains(prefix.Addr()) {
+				continue
+			}
+		}
+		result = append(result, prefix)
+	}
+	return result
+}
+
+// parsePrefixes parses CIDRs, masking host bits
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
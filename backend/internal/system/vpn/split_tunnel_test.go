@@ -0,0 +1,97 @@
+package vpn
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestComputeAllowedIPsExcludesFromFullTunnel(t *testing.T) {
+	got, err := ComputeAllowedIPs(true, nil, []string{"192.168.1.0/24"})
+	if err != nil {
+		t.Fatalf("ComputeAllowedIPs: %v", err)
+	}
+	want := []string{
+		"0.0.0.0/1", "128.0.0.0/2", "192.0.0.0/9", "192.128.0.0/11", "192.160.0.0/13",
+		"192.168.0.0/24", "192.168.2.0/23", "192.168.4.0/22", "192.168.8.0/21", "192.168.16.0/20",
+		"192.168.32.0/19", "192.168.64.0/18", "192.168.128.0/17", "192.169.0.0/16", "192.170.0.0/15",
+		"192.172.0.0/14", "192.176.0.0/12", "192.192.0.0/10", "193.0.0.0/8", "194.0.0.0/7",
+		"196.0.0.0/6", "200.0.0.0/5", "208.0.0.0/4", "224.0.0.0/3", "::/0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputeAllowedIPs =\n%v\nwant\n%v", got, want)
+	}
+
+	// Overlapping allowed ranges are merged, host bits are masked
+	got, err = ComputeAllowedIPs(false, []string{"10.0.0.0/8", "10.1.2.3/16", "fd00::1/64"}, []string{"10.0.0.0/9"})
+	if err != nil || !reflect.DeepEqual(got, []string{"10.128.0.0/9", "fd00::/64"}) {
+		t.Errorf("ComputeAllowedIPs(split) = %v, %v", got, err)
+	}
+
+	if _, err := ComputeAllowedIPs(true, nil, []string{"192.168.1.0/33"}); err == nil {
+		t.Error("accepted an invalid CIDR")
+	}
+}
+
+func TestSetPeerSplitTunnel(t *testing.T) {
+	m, shell := newTestVPNManager(t)
+	m.endpointHost = "nas.example.org"
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "vpn.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.VPNPeer{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { database.DB = nil })
+
+	shell.ExpectCommand("systemctl", "enable", "--now", "wg-quick@wg0.service").Times(1)
+	shell.ExpectCommand("systemctl", "is-active", "wg-quick@wg0.service").Returns("inactive", "", 3).Times(1)
+	if err := m.CreateWireGuardInterface(WireGuardInterface{Name: "wg0", ListenPort: 51820, Address: "10.8.0.1/24"}); err != nil {
+		t.Fatalf("CreateWireGuardInterface: %v", err)
+	}
+	peerKey, _ := generatePrivateKey()
+	if err := m.CreateWireGuardPeer("wg0", WireGuardPeer{PublicKey: peerKey, AllowedIPs: []string{"10.8.0.2/32"}}); err != nil {
+		t.Fatalf("CreateWireGuardPeer: %v", err)
+	}
+
+	config, err := m.GetPeerClientConfig("1")
+	if err != nil || !strings.Contains(config, "AllowedIPs = 0.0.0.0/0, ::/0") || !strings.Contains(config, "Endpoint = nas.example.org:51820") {
+		t.Fatalf("initial client config = %q, %v", config, err)
+	}
+
+	if err := m.SetPeerSplitTunnel("1", SplitTunnelPolicy{AllowedCIDRs: []string{"192.168.1.0/24"}, ExcludedCIDRs: []string{"192.168.1.128/25"}}); err != nil {
+		t.Fatalf("SetPeerSplitTunnel: %v", err)
+	}
+	var peer models.VPNPeer
+	db.First(&peer, 1)
+	if !reflect.DeepEqual(peer.ClientAllowedIPs, []string{"10.8.0.0/24", "192.168.1.0/25"}) || peer.RouteAllTraffic {
+		t.Errorf("peer = %+v", peer)
+	}
+	if config, _ := m.GetPeerClientConfig("1"); !strings.Contains(config, "AllowedIPs = 10.8.0.0/24, 192.168.1.0/25") || !strings.Contains(config, "Address = 10.8.0.2/32") {
+		t.Errorf("client config = %q", config)
+	}
+
+	// The server side AllowedIPs of the peer are unchanged
+	_, peers, _ := m.GetWireGuardInterface("wg0")
+	if len(peers) != 1 || !reflect.DeepEqual(peers[0].AllowedIPs, []string{"10.8.0.2/32"}) {
+		t.Errorf("interface peers = %+v", peers)
+	}
+
+	if err := m.SetPeerSplitTunnel("7", SplitTunnelPolicy{RouteAllTraffic: true}); !errors.Is(err, ErrPeerNotFound) {
+		t.Errorf("SetPeerSplitTunnel of a missing peer = %v", err)
+	}
+	shell.AssertExpectations(t)
+}
@@ -0,0 +1,71 @@
+// Package vpn provides VPN server management
+package vpn
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// Supported VPN protocols
+const (
+	ProtocolWireGuard = "wireguard"
+)
+
+// VPNManager manages the VPN servers of the NAS
+type VPNManager struct {
+	shell              executor.ShellExecutor
+	wireguardEnabled   bool
+	wireguardConfigDir string // Directory of the wg-quick configurations
+	endpointHost       string // Host in client configurations; the hostname when empty
+
+	mu sync.Mutex // Serializes configuration changes
+}
+
+// ProtocolStatus is the status of a VPN protocol, aggregated across its interfaces
+type ProtocolStatus struct {
+	Protocol    string            `json:"protocol"`
+	Installed   bool              `json:"installed"`
+	Running     bool              `json:"running"` // At least one interface is up
+	Interfaces  []InterfaceStatus `json:"interfaces"`
+	Peers       int               `json:"peers"`
+	ActivePeers int               `json:"activePeers"` // Peers with a recent handshake
+}
+
+// InterfaceStatus is the status of a single VPN interface
+type InterfaceStatus struct {
+	Name        string `json:"name"`
+	ListenPort  int    `json:"listenPort"`
+	Running     bool   `json:"running"`
+	Peers       int    `json:"peers"`
+	ActivePeers int    `json:"activePeers"`
+}
+
+// NewVPNManager creates a new VPN manager
+func NewVPNManager(shell executor.ShellExecutor) (*VPNManager, error) {
+	manager := &VPNManager{
+		shell:              shell,
+		wireguardConfigDir: "/etc/wireguard",
+	}
+
+	if !shell.CommandExists("wg") || !shell.CommandExists("wg-quick") {
+		logger.Warn("wg not found, WireGuard features will be disabled")
+		return manager, fmt.Errorf("wireguard not available: install wireguard-tools package")
+	}
+
+	manager.wireguardEnabled = true
+	logger.Info("VPN manager initialized successfully")
+	return manager, nil
+}
+
+// GetProtocolStatus returns the status of a VPN protocol
+func (m *VPNManager) GetProtocolStatus(protocol string) (*ProtocolStatus, error) {
+	switch protocol {
+	case ProtocolWireGuard:
+		return m.wireGuardStatus()
+	default:
+		return nil, fmt.Errorf("unsupported VPN protocol: %s", protocol)
+	}
+}
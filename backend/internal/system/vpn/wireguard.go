@@ -0,0 +1,529 @@
+package vpn
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/curve25519"
+)
+
+// activeHandshakeWindow is how recent a peer's last handshake must be to count as active
+const activeHandshakeWindow = 3 * time.Minute
+
+// Errors returned for invalid interface changes
+var (
+	ErrWireGuardInterfaceNotFound = errors.New("wireguard interface not found")
+	ErrWireGuardInterfaceExists   = errors.New("wireguard interface already exists")
+	ErrListenPortInUse            = errors.New("listen port is used by another wireguard interface")
+)
+
+// interfaceNamePattern matches the interface names wg-quick accepts
+var interfaceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_=+.-]{1,15}$`)
+
+// WireGuardInterface is a WireGuard interface managed by wg-quick
+type WireGuardInterface struct {
+	Name       string `json:"name"`
+	ConfigPath string `json:"configPath"`
+	ListenPort int    `json:"listenPort"`
+	Address    string `json:"address"`              // Address of the interface in CIDR notation, e.g. 10.8.0.1/24
+	PrivateKey string `json:"privateKey,omitempty"` // Generated when empty on creation; never listed
+	PublicKey  string `json:"publicKey,omitempty"`
+}
+
+// WireGuardPeer is a peer of a WireGuard interface
+type WireGuardPeer struct {
+	Name                string   `json:"name,omitempty"` // Stored as a comment in the configuration
+	PublicKey           string   `json:"publicKey"`
+	AllowedIPs          []string `json:"allowedIPs"`
+	Endpoint            string   `json:"endpoint,omitempty"` // host:port, for site-to-site tunnels
+	PersistentKeepalive int      `json:"persistentKeepalive,omitempty"`
+}
+
+// serviceName returns the systemd unit of the interface
+func serviceName(name string) string {
+	return "wg-quick@" + name + ".service"
+}
+
+// configPath returns the wg-quick configuration file of the interface
+func (m *VPNManager) configPath(name string) string {
+	return filepath.Join(m.wireguardConfigDir, name+".conf")
+}
+
+// ListWireGuardInterfaces lists the interfaces configured in the WireGuard
+// configuration directory. Private keys are not returned.
+func (m *VPNManager) ListWireGuardInterfaces() ([]WireGuardInterface, error) {
+	paths, err := filepath.Glob(filepath.Join(m.wireguardConfigDir, "*.conf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wireguard configurations: %w", err)
+	}
+	sort.Strings(paths)
+
+	interfaces := []WireGuardInterface{}
+	for _, path := range paths {
+		iface, _, err := readWireGuardConfig(path)
+		if err != nil {
+			logger.Warn("Skipping unreadable wireguard configuration", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		iface.PrivateKey = ""
+		interfaces = append(interfaces, *iface)
+	}
+
+	return interfaces, nil
+}
+
+// GetWireGuardInterface returns an interface and its peers. The private key is not returned.
+func (m *VPNManager) GetWireGuardInterface(name string) (*WireGuardInterface, []WireGuardPeer, error) {
+	if !interfaceNamePattern.MatchString(name) {
+		return nil, nil, fmt.Errorf("invalid interface name: %s", name)
+	}
+
+	iface, peers, err := readWireGuardConfig(m.configPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil, ErrWireGuardInterfaceNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	iface.PrivateKey = ""
+	return iface, peers, nil
+}
+
+// CreateWireGuardInterface writes the configuration of a new interface and
+// starts it with wg-quick. Each interface needs its own listen port.
+func (m *VPNManager) CreateWireGuardInterface(iface WireGuardInterface) error {
+	if !m.wireguardEnabled {
+		return fmt.Errorf("WireGuard is not enabled")
+	}
+	if err := validateInterface(&iface); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	iface.ConfigPath = m.configPath(iface.Name)
+	if _, err := os.Stat(iface.ConfigPath); err == nil {
+		return ErrWireGuardInterfaceExists
+	}
+	if err := m.checkListenPort(iface.Name, iface.ListenPort); err != nil {
+		return err
+	}
+
+	if iface.PrivateKey == "" {
+		key, err := generatePrivateKey()
+		if err != nil {
+			return err
+		}
+		iface.PrivateKey = key
+	}
+
+	if err := os.MkdirAll(m.wireguardConfigDir, 0700); err != nil {
+		return fmt.Errorf("failed to create wireguard configuration directory: %w", err)
+	}
+	if err := writeWireGuardConfig(iface.ConfigPath, &iface, nil); err != nil {
+		return err
+	}
+
+	if _, err := m.shell.Execute("systemctl", "enable", "--now", serviceName(iface.Name)); err != nil {
+		os.Remove(iface.ConfigPath)
+		return fmt.Errorf("failed to start wireguard interface %s: %w", iface.Name, err)
+	}
+
+	logger.Info("WireGuard interface created",
+		zap.String("interface", iface.Name),
+		zap.Int("listenPort", iface.ListenPort))
+	return nil
+}
+
+// UpdateWireGuardInterface changes the listen port and address of an
+// interface, keeping its key and peers, and restarts it
+func (m *VPNManager) UpdateWireGuardInterface(name string, update WireGuardInterface) error {
+	if !m.wireguardEnabled {
+		return fmt.Errorf("WireGuard is not enabled")
+	}
+	update.Name = name
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	iface, peers, err := readWireGuardConfig(m.configPath(name))
+	if os.IsNotExist(err) {
+		return ErrWireGuardInterfaceNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	update.PrivateKey = iface.PrivateKey
+	if err := validateInterface(&update); err != nil {
+		return err
+	}
+	if err := m.checkListenPort(name, update.ListenPort); err != nil {
+		return err
+	}
+
+	if err := writeWireGuardConfig(iface.ConfigPath, &update, peers); err != nil {
+		return err
+	}
+	if _, err := m.shell.Execute("systemctl", "restart", serviceName(name)); err != nil {
+		return fmt.Errorf("failed to restart wireguard interface %s: %w", name, err)
+	}
+
+	logger.Info("WireGuard interface updated", zap.String("interface", name))
+	return nil
+}
+
+// DeleteWireGuardInterface stops an interface and removes its configuration
+func (m *VPNManager) DeleteWireGuardInterface(name string) error {
+	if !interfaceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid interface name: %s", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path := m.configPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrWireGuardInterfaceNotFound
+	}
+
+	if _, err := m.shell.Execute("systemctl", "disable", "--now", serviceName(name)); err != nil {
+		return fmt.Errorf("failed to stop wireguard interface %s: %w", name, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove wireguard configuration: %w", err)
+	}
+	if err := forgetPeers(name); err != nil {
+		return err
+	}
+
+	logger.Info("WireGuard interface deleted", zap.String("interface", name))
+	return nil
+}
+
+// CreateWireGuardPeer adds a peer to the interface ifaceName. The peer is
+// added to the configuration, the running interface and the database.
+func (m *VPNManager) CreateWireGuardPeer(ifaceName string, peer WireGuardPeer) error {
+	if !m.wireguardEnabled {
+		return fmt.Errorf("WireGuard is not enabled")
+	}
+	if !isWireGuardKey(peer.PublicKey) {
+		return fmt.Errorf("invalid peer public key")
+	}
+	if len(peer.AllowedIPs) == 0 {
+		return fmt.Errorf("at least one allowed IP is required")
+	}
+	for _, allowed := range peer.AllowedIPs {
+		if _, _, err := net.ParseCIDR(allowed); err != nil {
+			return fmt.Errorf("invalid allowed IP %q: must be a CIDR range", allowed)
+		}
+	}
+	if peer.Endpoint != "" {
+		if _, _, err := net.SplitHostPort(peer.Endpoint); err != nil {
+			return fmt.Errorf("invalid endpoint %q: must be host:port", peer.Endpoint)
+		}
+	}
+	if peer.PersistentKeepalive < 0 || peer.PersistentKeepalive > 65535 {
+		return fmt.Errorf("persistentKeepalive must be between 0 and 65535")
+	}
+	if strings.ContainsAny(peer.Name, "\r\n") {
+		return fmt.Errorf("peer name must be a single line")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	iface, peers, err := readWireGuardConfig(m.configPath(ifaceName))
+	if os.IsNotExist(err) {
+		return ErrWireGuardInterfaceNotFound
+	}
+	if err != nil {
+		return err
+	}
+	for _, existing := range peers {
+		if existing.PublicKey == peer.PublicKey {
+			return fmt.Errorf("peer already exists on interface %s", ifaceName)
+		}
+	}
+
+	if err := writeWireGuardConfig(iface.ConfigPath, iface, append(peers, peer)); err != nil {
+		return err
+	}
+	if err := m.recordPeer(iface, peer); err != nil {
+		return err
+	}
+
+	if m.isRunning(ifaceName) {
+		args := []string{"set", ifaceName, "peer", peer.PublicKey, "allowed-ips", strings.Join(peer.AllowedIPs, ",")}
+		if peer.Endpoint != "" {
+			args = append(args, "endpoint", peer.Endpoint)
+		}
+		if peer.PersistentKeepalive > 0 {
+			args = append(args, "persistent-keepalive", strconv.Itoa(peer.PersistentKeepalive))
+		}
+		if _, err := m.shell.Execute("wg", args...); err != nil {
+			return fmt.Errorf("failed to add peer to running interface %s: %w", ifaceName, err)
+		}
+	}
+
+	logger.Info("WireGuard peer created",
+		zap.String("interface", ifaceName),
+		zap.String("peer", peer.PublicKey))
+	return nil
+}
+
+// wireGuardStatus aggregates the status of all WireGuard interfaces
+func (m *VPNManager) wireGuardStatus() (*ProtocolStatus, error) {
+	status := &ProtocolStatus{
+		Protocol:   ProtocolWireGuard,
+		Installed:  m.wireguardEnabled,
+		Interfaces: []InterfaceStatus{},
+	}
+	if !m.wireguardEnabled {
+		return status, nil
+	}
+
+	interfaces, err := m.ListWireGuardInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, iface := range interfaces {
+		ifaceStatus := InterfaceStatus{Name: iface.Name, ListenPort: iface.ListenPort}
+		if m.isRunning(iface.Name) {
+			ifaceStatus.Running = true
+			ifaceStatus.Peers, ifaceStatus.ActivePeers = m.countPeers(iface.Name, now)
+		} else if _, peers, err := readWireGuardConfig(iface.ConfigPath); err == nil {
+			ifaceStatus.Peers = len(peers)
+		}
+
+		status.Running = status.Running || ifaceStatus.Running
+		status.Peers += ifaceStatus.Peers
+		status.ActivePeers += ifaceStatus.ActivePeers
+		status.Interfaces = append(status.Interfaces, ifaceStatus)
+	}
+
+	return status, nil
+}
+
+// isRunning reports whether the wg-quick service of the interface is active
+func (m *VPNManager) isRunning(name string) bool {
+	result, err := m.shell.Execute("systemctl", "is-active", serviceName(name))
+	return err == nil && strings.TrimSpace(result.Stdout) == "active"
+}
+
+// countPeers counts the peers of a running interface and those with a recent handshake
+func (m *VPNManager) countPeers(name string, now time.Time) (int, int) {
+	result, err := m.shell.Execute("wg", "show", name, "dump")
+	if err != nil {
+		logger.Warn("Failed to read wireguard interface status", zap.String("interface", name), zap.Error(err))
+		return 0, 0
+	}
+
+	peers, active := 0, 0
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	// The first line describes the interface itself
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			continue
+		}
+		peers++
+		if handshake, err := strconv.ParseInt(fields[4], 10, 64); err == nil && handshake > 0 &&
+			now.Sub(time.Unix(handshake, 0)) < activeHandshakeWindow {
+			active++
+		}
+	}
+	return peers, active
+}
+
+// checkListenPort fails if another interface than name uses port
+func (m *VPNManager) checkListenPort(name string, port int) error {
+	interfaces, err := m.ListWireGuardInterfaces()
+	if err != nil {
+		return err
+	}
+	for _, iface := range interfaces {
+		if iface.Name != name && iface.ListenPort == port {
+			return fmt.Errorf("%w: port %d is used by %s", ErrListenPortInUse, port, iface.Name)
+		}
+	}
+	return nil
+}
+
+// validateInterface checks an interface before it is written and derives its public key
+func validateInterface(iface *WireGuardInterface) error {
+	if !interfaceNamePattern.MatchString(iface.Name) {
+		return fmt.Errorf("invalid interface name %q: use up to 15 letters, digits or _=+.-", iface.Name)
+	}
+	if iface.ListenPort < 1 || iface.ListenPort > 65535 {
+		return fmt.Errorf("listen port must be between 1 and 65535")
+	}
+	if _, _, err := net.ParseCIDR(iface.Address); err != nil {
+		return fmt.Errorf("invalid address %q: must be in CIDR notation", iface.Address)
+	}
+	if iface.PrivateKey != "" {
+		publicKey, err := publicKeyOf(iface.PrivateKey)
+		if err != nil {
+			return err
+		}
+		iface.PublicKey = publicKey
+	}
+	return nil
+}
+
+// generatePrivateKey generates a WireGuard private key like wg genkey
+func generatePrivateKey() (string, error) {
+	key := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate private key: %w", err)
+	}
+	key[0] &= 248
+	key[31] = (key[31] & 127) | 64
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// publicKeyOf derives the public key of a private key like wg pubkey
+func publicKeyOf(privateKey string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil || len(key) != curve25519.ScalarSize {
+		return "", fmt.Errorf("invalid private key")
+	}
+	publicKey, err := curve25519.X25519(key, curve25519.Basepoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(publicKey), nil
+}
+
+// isWireGuardKey reports whether key is a base64 encoded 32 byte key
+func isWireGuardKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 32
+}
+
+// readWireGuardConfig parses the [Interface] and [Peer] sections of a wg-quick configuration
+func readWireGuardConfig(path string) (*WireGuardInterface, []WireGuardPeer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	iface := &WireGuardInterface{
+		Name:       strings.TrimSuffix(filepath.Base(path), ".conf"),
+		ConfigPath: path,
+	}
+	peers := []WireGuardPeer{}
+	var peer *WireGuardPeer
+	pendingName := ""
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "# Name = "):
+			pendingName = strings.TrimPrefix(line, "# Name = ")
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		case strings.EqualFold(line, "[Interface]"):
+			peer = nil
+			continue
+		case strings.EqualFold(line, "[Peer]"):
+			peers = append(peers, WireGuardPeer{Name: pendingName, AllowedIPs: []string{}})
+			peer = &peers[len(peers)-1]
+			pendingName = ""
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if peer == nil {
+			switch strings.ToLower(key) {
+			case "listenport":
+				iface.ListenPort, _ = strconv.Atoi(value)
+			case "address":
+				iface.Address = value
+			case "privatekey":
+				iface.PrivateKey = value
+				iface.PublicKey, _ = publicKeyOf(value)
+			}
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "publickey":
+			peer.PublicKey = value
+		case "allowedips":
+			for _, allowed := range strings.Split(value, ",") {
+				if allowed = strings.TrimSpace(allowed); allowed != "" {
+					peer.AllowedIPs = append(peer.AllowedIPs, allowed)
+				}
+			}
+		case "endpoint":
+			peer.Endpoint = value
+		case "persistentkeepalive":
+			peer.PersistentKeepalive, _ = strconv.Atoi(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return iface, peers, nil
+}
+
+// writeWireGuardConfig writes a wg-quick configuration readable only by root
+func writeWireGuardConfig(path string, iface *WireGuardInterface, peers []WireGuardPeer) error {
+	var b strings.Builder
+	b.WriteString("# Managed by StumpfWorks NAS\n")
+	b.WriteString("[Interface]\n")
+	fmt.Fprintf(&b, "Address = %s\n", iface.Address)
+	fmt.Fprintf(&b, "ListenPort = %d\n", iface.ListenPort)
+	fmt.Fprintf(&b, "PrivateKey = %s\n", iface.PrivateKey)
+
+	for _, peer := range peers {
+		b.WriteString("\n")
+		if peer.Name != "" {
+			fmt.Fprintf(&b, "# Name = %s\n", peer.Name)
+		}
+		b.WriteString("[Peer]\n")
+		fmt.Fprintf(&b, "PublicKey = %s\n", peer.PublicKey)
+		fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(peer.AllowedIPs, ", "))
+		if peer.Endpoint != "" {
+			fmt.Fprintf(&b, "Endpoint = %s\n", peer.Endpoint)
+		}
+		if peer.PersistentKeepalive > 0 {
+			fmt.Fprintf(&b, "PersistentKeepalive = %d\n", peer.PersistentKeepalive)
+		}
+	}
+
+	if err := sysutil.AtomicWriteFile(path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write wireguard configuration: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package vpn
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// WireGuardIfaceStats are the counters of a running WireGuard interface,
+// aggregated over its peers
+type WireGuardIfaceStats struct {
+	Interface     string `json:"interface"`
+	ListenPort    int    `json:"listenPort"`
+	FwMark        uint32 `json:"fwMark"` // 0 if off
+	NumberOfPeers int    `json:"numberOfPeers"`
+	TotalRxBytes  uint64 `json:"totalRxBytes"`
+	TotalTxBytes  uint64 `json:"totalTxBytes"`
+}
+
+// GetWireGuardInterfaceStats returns the counters of a running interface
+// from wg show dump
+func (m *VPNManager) GetWireGuardInterfaceStats(ifaceName string) (*WireGuardIfaceStats, error) {
+	if !interfaceNamePattern.MatchString(ifaceName) {
+		return nil, fmt.Errorf("invalid interface name: %s", ifaceName)
+	}
+	if _, err := os.Stat(m.configPath(ifaceName)); os.IsNotExist(err) {
+		return nil, ErrWireGuardInterfaceNotFound
+	}
+
+	result, err := m.shell.Execute("wg", "show", ifaceName, "dump")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wireguard interface %s, is it running: %w", ifaceName, err)
+	}
+	return parseWireGuardDump(ifaceName, result.Stdout)
+}
+
+// parseWireGuardDump parses the output of wg show <interface> dump: a line
+// with the private key, public key, listen port and fwmark of the interface,
+// then a line per peer with its public key, preshared key, endpoint, allowed
+// IPs, latest handshake, received and sent bytes and keepalive
+func parseWireGuardDump(ifaceName, output string) (*WireGuardIfaceStats, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Split(lines[0], "\t")
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("unexpected wg show dump output for %s", ifaceName)
+	}
+
+	stats := &WireGuardIfaceStats{Interface: ifaceName}
+	stats.ListenPort, _ = strconv.Atoi(fields[2])
+	if fields[3] != "off" {
+		fwMark, err := strconv.ParseUint(fields[3], 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fwmark %q of %s", fields[3], ifaceName)
+		}
+		stats.FwMark = uint32(fwMark)
+	}
+
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			continue
+		}
+		stats.NumberOfPeers++
+		rx, _ := strconv.ParseUint(fields[5], 10, 64)
+		tx, _ := strconv.ParseUint(fields[6], 10, 64)
+		stats.TotalRxBytes += rx
+		stats.TotalTxBytes += tx
+	}
+	return stats, nil
+}
+
+// PrometheusMetrics returns the counters of the running WireGuard interfaces
+// in Prometheus text format
+func (m *VPNManager) PrometheusMetrics() string {
+	if !m.wireguardEnabled {
+		return ""
+	}
+	interfaces, err := m.ListWireGuardInterfaces()
+	if err != nil {
+		logger.Warn("Failed to list wireguard interfaces for metrics", zap.Error(err))
+		return ""
+	}
+
+	var stats []*WireGuardIfaceStats
+	for _, iface := range interfaces {
+		// Stopped interfaces have no counters
+		if s, err := m.GetWireGuardInterfaceStats(iface.Name); err == nil {
+			stats = append(stats, s)
+		}
+	}
+	if len(stats) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# HELP naswireguard_interface_rx_bytes_total Bytes received by a WireGuard interface from all peers\n")
+	b.WriteString("# TYPE naswireguard_interface_rx_bytes_total counter\n")
+	for _, s := range stats {
+		fmt.Fprintf(&b, "naswireguard_interface_rx_bytes_total{interface=%q} %d\n", s.Interface, s.TotalRxBytes)
+	}
+	b.WriteString("# HELP naswireguard_interface_tx_bytes_total Bytes sent by a WireGuard interface to all peers\n")
+	b.WriteString("# TYPE naswireguard_interface_tx_bytes_total counter\n")
+	for _, s := range stats {
+		fmt.Fprintf(&b, "naswireguard_interface_tx_bytes_total{interface=%q} %d\n", s.Interface, s.TotalTxBytes)
+	}
+	b.WriteString("# HELP naswireguard_peers_total Number of peers of a WireGuard interface\n")
+	b.WriteString("# TYPE naswireguard_peers_total gauge\n")
+	for _, s := range stats {
+		fmt.Fprintf(&b, "naswireguard_peers_total{interface=%q} %d\n", s.Interface, s.NumberOfPeers)
+	}
+	return b.String()
+}
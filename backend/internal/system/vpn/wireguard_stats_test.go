@@ -0,0 +1,78 @@
+package vpn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// multiPeerDump is wg show dump output of an interface with three peers, one
+// of which never connected
+const multiPeerDump = "cHJpdmF0ZQ==\tcHVibGlj\t51820\t0xca6c\n" +
+	"cGVlcjE=\t(none)\t198.51.100.7:51820\t10.8.0.2/32\t1700000000\t1048576\t524288\t25\n" +
+	"cGVlcjI=\t(none)\t203.0.113.9:43122\t10.8.0.3/32,192.168.50.0/24\t1700000100\t3000\t7000\toff\n" +
+	"cGVlcjM=\t(none)\t(none)\t10.8.0.4/32\t0\t0\t0\toff\n"
+
+func TestParseWireGuardDump(t *testing.T) {
+	stats, err := parseWireGuardDump("wg0", multiPeerDump)
+	if err != nil {
+		t.Fatalf("parseWireGuardDump: %v", err)
+	}
+	want := WireGuardIfaceStats{
+		Interface:     "wg0",
+		ListenPort:    51820,
+		FwMark:        0xca6c,
+		NumberOfPeers: 3,
+		TotalRxBytes:  1048576 + 3000,
+		TotalTxBytes:  524288 + 7000,
+	}
+	if *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+
+	stats, err = parseWireGuardDump("wg1", "cHJpdmF0ZQ==\tcHVibGlj\t51821\toff\n")
+	if err != nil || stats.FwMark != 0 || stats.NumberOfPeers != 0 || stats.ListenPort != 51821 {
+		t.Errorf("interface without peers = %+v, %v", stats, err)
+	}
+	if _, err := parseWireGuardDump("wg0", "unexpected"); err == nil {
+		t.Error("invalid dump was parsed")
+	}
+}
+
+func TestWireGuardInterfaceStatsMetrics(t *testing.T) {
+	m, shell := newTestVPNManager(t)
+	m.wireguardEnabled = true
+	for _, iface := range []WireGuardInterface{
+		{Name: "wg0", ListenPort: 51820, Address: "10.8.0.1/24"},
+		{Name: "site1", ListenPort: 51821, Address: "10.9.0.1/30"},
+	} {
+		shell.ExpectCommand("systemctl", "enable", "--now", serviceName(iface.Name)).Times(1)
+		if err := m.CreateWireGuardInterface(iface); err != nil {
+			t.Fatalf("CreateWireGuardInterface(%s): %v", iface.Name, err)
+		}
+	}
+	shell.ExpectCommand("wg", "show", "wg0", "dump").Returns(multiPeerDump, "", 0)
+	shell.ExpectCommand("wg", "show", "site1", "dump").Returns("", "Unable to access interface: No such device", 1)
+
+	if _, err := m.GetWireGuardInterfaceStats("missing"); !errors.Is(err, ErrWireGuardInterfaceNotFound) {
+		t.Errorf("stats of an unknown interface: %v", err)
+	}
+	if _, err := m.GetWireGuardInterfaceStats("site1"); err == nil {
+		t.Error("stats of a stopped interface did not fail")
+	}
+
+	// The stopped interface has no series
+	metrics := m.PrometheusMetrics()
+	for _, line := range []string{
+		`naswireguard_interface_rx_bytes_total{interface="wg0"} 1051576`,
+		`naswireguard_interface_tx_bytes_total{interface="wg0"} 531288`,
+		`naswireguard_peers_total{interface="wg0"} 3`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, metrics)
+		}
+	}
+	if strings.Contains(metrics, "site1") {
+		t.Errorf("metrics contain the stopped interface:\n%s", metrics)
+	}
+}
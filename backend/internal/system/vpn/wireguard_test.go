@@ -0,0 +1,107 @@
+package vpn
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/system/executor"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+func newTestVPNManager(t *testing.T) (*VPNManager, *executor.MockShellExecutor) {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	shell := executor.NewMockShellExecutor()
+	manager, err := NewVPNManager(shell)
+	if err != nil {
+		t.Fatalf("NewVPNManager: %v", err)
+	}
+	manager.wireguardConfigDir = t.TempDir()
+	return manager, shell
+}
+
+func TestCreateWireGuardInterfacesRequireDistinctPorts(t *testing.T) {
+	m, shell := newTestVPNManager(t)
+	shell.ExpectCommand("systemctl", "enable", "--now", "wg-quick@wg0.service").Times(1)
+	shell.ExpectCommand("systemctl", "enable", "--now", "wg-quick@site1.service").Times(1)
+
+	if err := m.CreateWireGuardInterface(WireGuardInterface{Name: "wg0", ListenPort: 51820, Address: "10.8.0.1/24"}); err != nil {
+		t.Fatalf("CreateWireGuardInterface(wg0): %v", err)
+	}
+	err := m.CreateWireGuardInterface(WireGuardInterface{Name: "site1", ListenPort: 51820, Address: "10.9.0.1/30"})
+	if !errors.Is(err, ErrListenPortInUse) {
+		t.Errorf("CreateWireGuardInterface with a used port = %v", err)
+	}
+	if err := m.CreateWireGuardInterface(WireGuardInterface{Name: "wg0", ListenPort: 51821, Address: "10.8.0.1/24"}); !errors.Is(err, ErrWireGuardInterfaceExists) {
+		t.Errorf("CreateWireGuardInterface of an existing name = %v", err)
+	}
+	if err := m.CreateWireGuardInterface(WireGuardInterface{Name: "site1", ListenPort: 51821, Address: "10.9.0.1/30"}); err != nil {
+		t.Fatalf("CreateWireGuardInterface(site1): %v", err)
+	}
+
+	interfaces, err := m.ListWireGuardInterfaces()
+	if err != nil || len(interfaces) != 2 || interfaces[0].Name != "site1" || interfaces[1].ListenPort != 51820 {
+		t.Fatalf("ListWireGuardInterfaces = %+v, %v", interfaces, err)
+	}
+	if interfaces[1].PrivateKey != "" || !isWireGuardKey(interfaces[1].PublicKey) {
+		t.Errorf("listed interface keys = %+v", interfaces[1])
+	}
+	if info, err := os.Stat(interfaces[0].ConfigPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("configuration file = %v, %v", info, err)
+	}
+
+	// Moving wg0 onto the port of site1 is refused as well
+	if err := m.UpdateWireGuardInterface("wg0", WireGuardInterface{ListenPort: 51821, Address: "10.8.0.1/24"}); !errors.Is(err, ErrListenPortInUse) {
+		t.Errorf("UpdateWireGuardInterface with a used port = %v", err)
+	}
+	shell.AssertExpectations(t)
+}
+
+func TestWireGuardPeersAndStatus(t *testing.T) {
+	m, shell := newTestVPNManager(t)
+	peerKey, _ := generatePrivateKey()
+
+	for _, iface := range []WireGuardInterface{
+		{Name: "wg0", ListenPort: 51820, Address: "10.8.0.1/24"},
+		{Name: "site1", ListenPort: 51821, Address: "10.9.0.1/30"},
+	} {
+		shell.ExpectCommand("systemctl", "enable", "--now", serviceName(iface.Name)).Times(1)
+		if err := m.CreateWireGuardInterface(iface); err != nil {
+			t.Fatalf("CreateWireGuardInterface(%s): %v", iface.Name, err)
+		}
+	}
+
+	shell.ExpectCommand("systemctl", "is-active", "wg-quick@site1.service").Returns("active\n", "", 0).Times(1)
+	shell.ExpectCommand("wg", "set", "site1", "peer", peerKey, "allowed-ips", "192.168.50.0/24", "endpoint", "vpn.example.org:51820", "persistent-keepalive", "25").Times(1)
+	if err := m.CreateWireGuardPeer("site1", WireGuardPeer{Name: "Branch office", PublicKey: peerKey,
+		AllowedIPs: []string{"192.168.50.0/24"}, Endpoint: "vpn.example.org:51820", PersistentKeepalive: 25}); err != nil {
+		t.Fatalf("CreateWireGuardPeer: %v", err)
+	}
+	if err := m.CreateWireGuardPeer("wg1", WireGuardPeer{PublicKey: peerKey, AllowedIPs: []string{"10.8.0.2/32"}}); !errors.Is(err, ErrWireGuardInterfaceNotFound) {
+		t.Errorf("CreateWireGuardPeer on a missing interface = %v", err)
+	}
+
+	_, peers, err := m.GetWireGuardInterface("site1")
+	if err != nil || len(peers) != 1 || peers[0].Name != "Branch office" || peers[0].PersistentKeepalive != 25 {
+		t.Fatalf("GetWireGuardInterface = %+v, %v", peers, err)
+	}
+
+	// wg0 is down; site1 runs with one peer that shook hands recently
+	shell.ExpectCommand("systemctl", "is-active", "wg-quick@site1.service").Returns("active\n", "", 0).Times(1)
+	shell.ExpectCommand("systemctl", "is-active", "wg-quick@wg0.service").Returns("inactive\n", "", 3).Times(1)
+	shell.ExpectCommand("wg", "show", "site1", "dump").Returns(fmt.Sprintf("priv\tpub\t51821\toff\n%s\t(none)\t198.51.100.7:51820\t192.168.50.0/24\t%d\t100\t200\t25\n",
+		peerKey, time.Now().Add(-time.Minute).Unix()), "", 0).Times(1)
+
+	status, err := m.GetProtocolStatus(ProtocolWireGuard)
+	if err != nil {
+		t.Fatalf("GetProtocolStatus: %v", err)
+	}
+	if !status.Running || len(status.Interfaces) != 2 || status.Peers != 1 || status.ActivePeers != 1 || status.Interfaces[1].Running {
+		t.Errorf("status = %+v", status)
+	}
+	shell.AssertExpectations(t)
+}
@@ -0,0 +1,218 @@
+// Package telemetry sends opt-in anonymous usage statistics. Reports only
+// contain counts, service names and a hardware summary; no usernames,
+// hostnames, addresses or paths are collected.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/updates"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/mem"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultCollectorURL receives the reports unless another collector is configured
+	DefaultCollectorURL = "https://telemetry.stumpf.works/v1/report"
+	// ReportInterval is how often an enabled node reports
+	ReportInterval = 24 * time.Hour
+)
+
+// ErrDisabled is returned when reporting while telemetry is disabled
+var ErrDisabled = errors.New("telemetry is disabled")
+
+// featureServices are the services reported as features when active
+var featureServices = []string{
+	"smbd", "nfs-server", "vsftpd", "docker", "libvirtd", "lxc",
+	"samba-ad-dc", "keepalived", "pacemaker", "wg-quick@wg0",
+}
+
+// Replaced in tests
+var serviceActive = func(name string) bool {
+	return exec.Command("systemctl", "is-active", "--quiet", name).Run() == nil
+}
+
+// Telemetry are the telemetry settings of this node
+type Telemetry struct {
+	Enabled      bool   `json:"enabled"`
+	CollectorURL string `json:"collectorUrl"`
+	NodeID       string `json:"nodeId"`
+
+	db     *gorm.DB
+	client *http.Client
+}
+
+// Payload is the report sent to the collector. Every field must stay free
+// of personally identifiable information.
+type Payload struct {
+	NodeID        string   `json:"nodeId"`
+	Version       string   `json:"version"`
+	OS            string   `json:"os"`       // e.g. "linux"
+	Platform      string   `json:"platform"` // Distribution and release, e.g. "debian 12.5"
+	Arch          string   `json:"arch"`
+	UptimeSeconds uint64   `json:"uptimeSeconds"`
+	Features      []string `json:"features"` // Names of the active services
+	ShareCount    int64    `json:"shareCount"`
+	UserCount     int64    `json:"userCount"`
+	CPUCount      int      `json:"cpuCount"`
+	MemoryGB      int      `json:"memoryGb"` // Total RAM, rounded to whole GB
+}
+
+// Load returns the telemetry settings, creating the node ID on first use
+func Load(db *gorm.DB) (*Telemetry, error) {
+	state, err := loadState(db)
+	if err != nil {
+		return nil, err
+	}
+	return &Telemetry{
+		Enabled:      state.Enabled,
+		CollectorURL: state.CollectorURL,
+		NodeID:       state.NodeID,
+		db:           db,
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// LastReport returns when the node last reported, or nil if it never has
+func (t *Telemetry) LastReport() (*time.Time, error) {
+	state, err := loadState(t.db)
+	if err != nil {
+		return nil, err
+	}
+	return state.LastReportAt, nil
+}
+
+// Save stores the settings. The node ID is never changed.
+func (t *Telemetry) Save() error {
+	return t.db.Model(&models.TelemetryState{}).Where("node_id = ?", t.NodeID).
+		Updates(map[string]interface{}{"enabled": t.Enabled, "collector_url": t.CollectorURL}).Error
+}
+
+// Collect gathers the report without sending it
+func (t *Telemetry) Collect(ctx context.Context) (*Payload, error) {
+	p := &Payload{
+		NodeID:   t.NodeID,
+		Version:  updates.CurrentVersion,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		CPUCount: runtime.NumCPU(),
+		Features: []string{},
+	}
+
+	if platform, _, version, err := host.PlatformInformationWithContext(ctx); err == nil {
+		p.Platform = platform + " " + version
+	}
+	if uptime, err := host.UptimeWithContext(ctx); err == nil {
+		p.UptimeSeconds = uptime
+	}
+	if vmem, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		p.MemoryGB = int((vmem.Total + 1<<29) >> 30)
+	}
+	for _, name := range featureServices {
+		if serviceActive(name) {
+			p.Features = append(p.Features, name)
+		}
+	}
+
+	if err := t.db.WithContext(ctx).Model(&models.Share{}).Count(&p.ShareCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count shares: %w", err)
+	}
+	if err := t.db.WithContext(ctx).Model(&models.User{}).Count(&p.UserCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	return p, nil
+}
+
+// Report sends the report to the collector
+func (t *Telemetry) Report(ctx context.Context) error {
+	if !t.Enabled {
+		return ErrDisabled
+	}
+
+	payload, err := t.Collect(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.CollectorURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Stumpfworks-NAS-Telemetry")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	return t.db.Model(&models.TelemetryState{}).Where("node_id = ?", t.NodeID).
+		Update("last_report_at", time.Now()).Error
+}
+
+// Start reports once a day while telemetry is enabled. The settings are
+// reloaded before each report so changes apply without a restart.
+func Start(ctx context.Context, db *gorm.DB) {
+	go func() {
+		ticker := time.NewTicker(ReportInterval)
+		defer ticker.Stop()
+
+		for {
+			t, err := Load(db)
+			if err != nil {
+				logger.Warn("Failed to load telemetry settings", zap.Error(err))
+			} else if t.Enabled {
+				if err := t.Report(ctx); err != nil {
+					logger.Warn("Failed to send telemetry report", zap.Error(err))
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// loadState returns the telemetry record, creating it with a new node ID
+func loadState(db *gorm.DB) (*models.TelemetryState, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var state models.TelemetryState
+	err := db.First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		state = models.TelemetryState{NodeID: uuid.NewString(), CollectorURL: DefaultCollectorURL}
+		err = db.Create(&state).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load telemetry state: %w", err)
+	}
+	return &state, nil
+}
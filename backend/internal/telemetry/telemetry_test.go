@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	logger.InitLogger("error", false)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "telemetry.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.TelemetryState{}, &models.Share{}, &models.User{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+func TestLoadKeepsNodeID(t *testing.T) {
+	db := newTestDB(t)
+
+	first, err := Load(db)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if first.Enabled {
+		t.Error("telemetry enabled by default")
+	}
+	first.Enabled = true
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	second, err := Load(db)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if second.NodeID == "" || second.NodeID != first.NodeID {
+		t.Errorf("node ID changed from %q to %q", first.NodeID, second.NodeID)
+	}
+	if !second.Enabled {
+		t.Error("Enabled not saved")
+	}
+}
+
+func TestReportContainsNoPersonalData(t *testing.T) {
+	db := newTestDB(t)
+	origServiceActive := serviceActive
+	serviceActive = func(name string) bool { return name == "smbd" || name == "docker" }
+	t.Cleanup(func() { serviceActive = origServiceActive })
+
+	db.Create(&models.User{Username: "alice", Email: "alice@example.com", FullName: "Alice Liddell", Role: "admin"})
+	db.Create(&models.User{Username: "bob", Email: "bob@example.com", FullName: "Bob Builder", Role: "user"})
+	db.Create(&models.Share{Name: "family-photos", Path: "/mnt/tank/family-photos", Type: "smb", ValidUsers: "alice,bob"})
+
+	var received []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer collector.Close()
+
+	tel, err := Load(db)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	tel.CollectorURL = collector.URL
+	if err := tel.Report(context.Background()); err != ErrDisabled {
+		t.Fatalf("Report() while disabled error = %v, want ErrDisabled", err)
+	}
+
+	tel.Enabled = true
+	if err := tel.Report(context.Background()); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(received, &payload); err != nil {
+		t.Fatalf("invalid payload %s: %v", received, err)
+	}
+	if payload.NodeID != tel.NodeID || payload.UserCount != 2 || payload.ShareCount != 1 {
+		t.Errorf("payload = %+v", payload)
+	}
+	if strings.Join(payload.Features, ",") != "smbd,docker" {
+		t.Errorf("features = %v, want [smbd docker]", payload.Features)
+	}
+
+	body := string(received)
+	for _, personal := range []string{"alice", "bob", "Alice", "example.com", "family-photos", "/mnt", "tank"} {
+		if strings.Contains(body, personal) {
+			t.Errorf("payload contains %q: %s", personal, body)
+		}
+	}
+	if ip := regexp.MustCompile(`\d+\.\d+\.\d+\.\d+`).FindString(body); ip != "" {
+		t.Errorf("payload contains IP address %s: %s", ip, body)
+	}
+
+	last, err := tel.LastReport()
+	if err != nil || last == nil {
+		t.Errorf("LastReport() = %v, %v; want the report time", last, err)
+	}
+}
@@ -0,0 +1,59 @@
+// Package tracing configures OpenTelemetry distributed tracing. Spans are
+// started with Tracer(); until Initialize installs an exporter they are
+// dropped, so instrumented code does not need to check whether tracing is on.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans of the NAS
+const instrumentationName = "github.com/Stumpf-works/stumpfworks-nas"
+
+// Tracer returns the tracer of the globally installed tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Initialize installs a tracer provider that exports spans to the OTLP/HTTP
+// collector at cfg.OTLPEndpoint. The returned function flushes pending spans
+// and must be called on shutdown.
+func Initialize(ctx context.Context, cfg config.TracingConfig, serviceName, version string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// End records err on span, if any, and ends it. Use it with a named error
+// result: defer func() { tracing.End(span, err) }()
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,160 @@
+package twofa
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"gorm.io/gorm"
+	"lukechampine.com/blake3"
+)
+
+// BackupCodeStatus describes a backup code without revealing it
+type BackupCodeStatus struct {
+	ID        uint       `json:"id"`
+	Used      bool       `json:"used"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// BackupCodeManager generates and verifies one-time backup codes. Codes are
+// stored as BLAKE3 hashes bound to the user, so equal codes of different
+// users never share a hash.
+type BackupCodeManager struct {
+	db *gorm.DB
+}
+
+// NewBackupCodeManager creates a backup code manager backed by db
+func NewBackupCodeManager(db *gorm.DB) *BackupCodeManager {
+	return &BackupCodeManager{db: db}
+}
+
+// GenerateCodes replaces the user's backup codes with count new codes
+// (BackupCodeCount if count is not positive) and returns them. The codes
+// cannot be retrieved again.
+func (m *BackupCodeManager) GenerateCodes(userID string, count int) ([]string, error) {
+	uid, err := parseUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		count = BackupCodeCount
+	}
+
+	codes := make([]string, count)
+	rows := make([]models.BackupCode, count)
+	for i := range codes {
+		code, err := generateRandomCode(BackupCodeLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate backup code: %w", err)
+		}
+		codes[i] = code
+		rows[i] = models.BackupCode{UserID: uid, CodeHash: hashBackupCode(uid, code)}
+	}
+
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteBackupCodes(tx, uid); err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store backup codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// VerifyBackupCode checks a backup code and marks it used. A code is only
+// accepted once; dashes and case are ignored.
+func (m *BackupCodeManager) VerifyBackupCode(userID string, code string) (bool, error) {
+	uid, err := parseUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	code = strings.ToUpper(UnformatBackupCode(strings.TrimSpace(code)))
+	if len(code) != BackupCodeLength {
+		return false, nil
+	}
+
+	// The conditional update consumes the code atomically, so concurrent
+	// logins cannot both use it
+	result := m.db.Model(&models.BackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", uid, hashBackupCode(uid, code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to verify backup code: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ListCodes returns the status of the user's backup codes, oldest first
+func (m *BackupCodeManager) ListCodes(userID string) ([]BackupCodeStatus, error) {
+	uid, err := parseUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.BackupCode
+	if err := m.db.Where("user_id = ?", uid).Order("id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list backup codes: %w", err)
+	}
+
+	statuses := make([]BackupCodeStatus, len(rows))
+	for i, r := range rows {
+		statuses[i] = BackupCodeStatus{ID: r.ID, Used: r.UsedAt != nil, UsedAt: r.UsedAt, CreatedAt: r.CreatedAt}
+	}
+	return statuses, nil
+}
+
+// RemainingCodes returns the number of unused backup codes of the user
+func (m *BackupCodeManager) RemainingCodes(userID string) (int, error) {
+	uid, err := parseUserID(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := m.db.Model(&models.BackupCode{}).Where("user_id = ? AND used_at IS NULL", uid).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count backup codes: %w", err)
+	}
+	return int(count), nil
+}
+
+// DeleteCodes removes all backup codes of the user
+func (m *BackupCodeManager) DeleteCodes(userID string) error {
+	uid, err := parseUserID(userID)
+	if err != nil {
+		return err
+	}
+	return deleteBackupCodes(m.db, uid)
+}
+
+// deleteBackupCodes removes the user's codes, including bcrypt hashed codes
+// created before BackupCodeManager existed
+func deleteBackupCodes(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.BackupCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.TwoFactorBackupCode{}).Error
+}
+
+func hashBackupCode(userID uint, code string) string {
+	sum := blake3.Sum256([]byte(strconv.FormatUint(uint64(userID), 10) + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseUserID(userID string) (uint, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user ID %q", userID)
+	}
+	return uint(id), nil
+}
+
+func formatUserID(userID uint) string {
+	return strconv.FormatUint(uint64(userID), 10)
+}
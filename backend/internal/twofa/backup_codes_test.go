@@ -0,0 +1,126 @@
+package twofa
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestManager(t *testing.T) *BackupCodeManager {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "twofa.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.BackupCode{}, &models.TwoFactorBackupCode{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewBackupCodeManager(db)
+}
+
+func TestGenerateCodes(t *testing.T) {
+	m := newTestManager(t)
+
+	codes, err := m.GenerateCodes("1", 0)
+	if err != nil {
+		t.Fatalf("GenerateCodes: %v", err)
+	}
+	if len(codes) != BackupCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), BackupCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[A-Z0-9]{8}$`)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not 8 alphanumeric characters", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	var stored []models.BackupCode
+	m.db.Find(&stored)
+	for _, row := range stored {
+		if seen[row.CodeHash] {
+			t.Errorf("code stored in plaintext")
+		}
+	}
+
+	// Regenerating invalidates the previous codes
+	if _, err := m.GenerateCodes("1", 3); err != nil {
+		t.Fatalf("GenerateCodes: %v", err)
+	}
+	if ok, _ := m.VerifyBackupCode("1", codes[0]); ok {
+		t.Error("code from a previous generation was accepted")
+	}
+	statuses, err := m.ListCodes("1")
+	if err != nil {
+		t.Fatalf("ListCodes: %v", err)
+	}
+	if len(statuses) != 3 {
+		t.Errorf("got %d codes after regenerating, want 3", len(statuses))
+	}
+}
+
+func TestVerifyBackupCode(t *testing.T) {
+	m := newTestManager(t)
+
+	codes, err := m.GenerateCodes("1", 2)
+	if err != nil {
+		t.Fatalf("GenerateCodes: %v", err)
+	}
+	if _, err := m.GenerateCodes("2", 2); err != nil {
+		t.Fatalf("GenerateCodes: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		code   string
+		want   bool
+	}{
+		{"other user", "2", codes[0], false},
+		{"first use", "1", codes[0], true},
+		{"second use", "1", codes[0], false},
+		{"formatted lower case", "1", " " + strings.ToLower(FormatBackupCode(codes[1])) + " ", true},
+		{"wrong code", "1", "AAAAAAAA", false},
+		{"too short", "1", "ABC", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.VerifyBackupCode(tt.userID, tt.code)
+			if err != nil {
+				t.Fatalf("VerifyBackupCode: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyBackupCode(%q, %q) = %v, want %v", tt.userID, tt.code, got, tt.want)
+			}
+		})
+	}
+
+	statuses, err := m.ListCodes("1")
+	if err != nil {
+		t.Fatalf("ListCodes: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Used || s.UsedAt == nil {
+			t.Errorf("code %d not marked used", s.ID)
+		}
+	}
+	if remaining, _ := m.RemainingCodes("2"); remaining != 2 {
+		t.Errorf("user 2 has %d remaining codes, want 2", remaining)
+	}
+}
@@ -33,8 +33,9 @@ const (
 
 // Service manages two-factor authentication
 type Service struct {
-	db *gorm.DB
-	mu sync.RWMutex
+	db    *gorm.DB
+	mu    sync.RWMutex
+	codes *BackupCodeManager
 }
 
 var (
@@ -73,7 +74,8 @@ func Initialize() (*Service, error) {
 		}
 
 		globalService = &Service{
-			db: db,
+			db:    db,
+			codes: NewBackupCodeManager(db),
 		}
 
 		logger.Info("Two-Factor Authentication service initialized")
@@ -90,6 +92,11 @@ func GetService() *Service {
 	return globalService
 }
 
+// BackupCodes returns the manager of the users' backup codes
+func (s *Service) BackupCodes() *BackupCodeManager {
+	return s.codes
+}
+
 // IsEnabled checks if 2FA is enabled for a user
 func (s *Service) IsEnabled(ctx context.Context, userID uint) (bool, error) {
 	var twoFA models.TwoFactorAuth
@@ -126,12 +133,6 @@ func (s *Service) SetupTwoFactor(ctx context.Context, req SetupRequest) (*SetupR
 
 	secret := key.Secret()
 
-	// Generate backup codes
-	backupCodes, hashedCodes, err := s.generateBackupCodes()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate backup codes: %w", err)
-	}
-
 	// Store 2FA configuration (not enabled yet)
 	twoFA := models.TwoFactorAuth{
 		UserID:  req.UserID,
@@ -141,22 +142,15 @@ func (s *Service) SetupTwoFactor(ctx context.Context, req SetupRequest) (*SetupR
 
 	// Delete existing 2FA config if present
 	s.db.WithContext(ctx).Where("user_id = ?", req.UserID).Delete(&models.TwoFactorAuth{})
-	s.db.WithContext(ctx).Where("user_id = ?", req.UserID).Delete(&models.TwoFactorBackupCode{})
 
 	if err := s.db.WithContext(ctx).Create(&twoFA).Error; err != nil {
 		return nil, fmt.Errorf("failed to store 2FA config: %w", err)
 	}
 
-	// Store backup codes
-	for _, hashedCode := range hashedCodes {
-		backupCode := models.TwoFactorBackupCode{
-			UserID: req.UserID,
-			Code:   hashedCode,
-			Used:   false,
-		}
-		if err := s.db.WithContext(ctx).Create(&backupCode).Error; err != nil {
-			logger.Error("Failed to store backup code", zap.Error(err))
-		}
+	// Generate backup codes, replacing any previous ones
+	backupCodes, err := s.codes.GenerateCodes(formatUserID(req.UserID), BackupCodeCount)
+	if err != nil {
+		return nil, err
 	}
 
 	logger.Info("2FA setup initiated", zap.Uint("userId", req.UserID))
@@ -216,7 +210,7 @@ func (s *Service) DisableTwoFactor(ctx context.Context, userID uint, code string
 		return fmt.Errorf("failed to disable 2FA: %w", err)
 	}
 
-	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.TwoFactorBackupCode{}).Error; err != nil {
+	if err := s.codes.DeleteCodes(formatUserID(userID)); err != nil {
 		logger.Error("Failed to delete backup codes", zap.Error(err))
 	}
 
@@ -270,6 +264,21 @@ func (s *Service) verifyCode(secret, code string) bool {
 
 // verifyBackupCode verifies and consumes a backup code
 func (s *Service) verifyBackupCode(ctx context.Context, userID uint, code string) bool {
+	valid, err := s.codes.VerifyBackupCode(formatUserID(userID), code)
+	if err != nil {
+		logger.Error("Failed to verify backup code", zap.Error(err))
+		return false
+	}
+	if valid {
+		logger.Info("Backup code used", zap.Uint("userId", userID))
+		return true
+	}
+	return s.verifyLegacyBackupCode(ctx, userID, code)
+}
+
+// verifyLegacyBackupCode verifies and consumes a bcrypt hashed backup code
+// generated before codes were managed by BackupCodeManager
+func (s *Service) verifyLegacyBackupCode(ctx context.Context, userID uint, code string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -320,30 +329,6 @@ func (s *Service) checkRateLimit(ctx context.Context, userID uint) error {
 	return nil
 }
 
-// generateBackupCodes generates a set of backup codes
-func (s *Service) generateBackupCodes() ([]string, []string, error) {
-	codes := make([]string, BackupCodeCount)
-	hashedCodes := make([]string, BackupCodeCount)
-
-	for i := 0; i < BackupCodeCount; i++ {
-		code, err := generateRandomCode(BackupCodeLength)
-		if err != nil {
-			return nil, nil, err
-		}
-
-		// Hash the code for storage
-		hashedCode, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
-		if err != nil {
-			return nil, nil, err
-		}
-
-		codes[i] = code
-		hashedCodes[i] = string(hashedCode)
-	}
-
-	return codes, hashedCodes, nil
-}
-
 // generateRandomCode generates a random alphanumeric code
 func generateRandomCode(length int) (string, error) {
 	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
@@ -379,14 +364,19 @@ func (s *Service) GenerateQRCode(secret, issuer, accountName string) string {
 
 // GetBackupCodes retrieves remaining backup codes for a user (count only, not actual codes)
 func (s *Service) GetBackupCodes(ctx context.Context, userID uint) (int, error) {
-	var count int64
+	remaining, err := s.codes.RemainingCodes(formatUserID(userID))
+	if err != nil {
+		return 0, err
+	}
+
+	var legacy int64
 	if err := s.db.WithContext(ctx).
 		Model(&models.TwoFactorBackupCode{}).
 		Where("user_id = ? AND used = ?", userID, false).
-		Count(&count).Error; err != nil {
+		Count(&legacy).Error; err != nil {
 		return 0, err
 	}
-	return int(count), nil
+	return remaining + int(legacy), nil
 }
 
 // RegenerateBackupCodes generates new backup codes for a user
@@ -405,27 +395,10 @@ func (s *Service) RegenerateBackupCodes(ctx context.Context, userID uint, verifi
 		return nil, fmt.Errorf("invalid verification code")
 	}
 
-	// Delete old backup codes
-	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.TwoFactorBackupCode{}).Error; err != nil {
-		return nil, fmt.Errorf("failed to delete old backup codes: %w", err)
-	}
-
-	// Generate new backup codes
-	backupCodes, hashedCodes, err := s.generateBackupCodes()
+	// Replace old backup codes with new ones
+	backupCodes, err := s.codes.GenerateCodes(formatUserID(userID), BackupCodeCount)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate backup codes: %w", err)
-	}
-
-	// Store new backup codes
-	for _, hashedCode := range hashedCodes {
-		backupCode := models.TwoFactorBackupCode{
-			UserID: userID,
-			Code:   hashedCode,
-			Used:   false,
-		}
-		if err := s.db.WithContext(ctx).Create(&backupCode).Error; err != nil {
-			logger.Error("Failed to store backup code", zap.Error(err))
-		}
+		return nil, err
 	}
 
 	logger.Info("Backup codes regenerated", zap.Uint("userId", userID))
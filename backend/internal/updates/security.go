@@ -0,0 +1,364 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/backup"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/scheduler"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrUpdateInProgress is returned when updates are applied while another
+// run is still installing packages
+var ErrUpdateInProgress = errors.New("package updates are already being applied")
+
+// AutoUpdateConfig configures automatic system package updates
+type AutoUpdateConfig struct {
+	Enabled         bool     `json:"enabled"`
+	SecurityOnly    bool     `json:"securityOnly"`    // Only install updates from AllowedOrigins
+	AllowedOrigins  []string `json:"allowedOrigins"`  // Matched against the apt release labels of an update
+	Schedule        string   `json:"schedule"`        // Cron expression of the automatic runs
+	RequireApproval bool     `json:"requireApproval"` // Only announce pending updates; an admin applies them
+}
+
+// Package is a pending system package update
+type Package struct {
+	Name           string   `json:"name"`
+	CurrentVersion string   `json:"currentVersion"`
+	NewVersion     string   `json:"newVersion"`
+	Architecture   string   `json:"architecture"`
+	Origins        []string `json:"origins"` // apt release labels, e.g. Ubuntu:22.04/jammy-security
+	Security       bool     `json:"security"`
+}
+
+// FailedPackage is a package update that could not be installed
+type FailedPackage struct {
+	Package
+	Error string `json:"error"`
+}
+
+// UpdateReport is the result of applying package updates
+type UpdateReport struct {
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt time.Time       `json:"finishedAt"`
+	Automatic  bool            `json:"automatic"`
+	Snapshot   string          `json:"snapshot,omitempty"` // Root filesystem snapshot taken before the updates
+	Applied    []Package       `json:"applied"`
+	Failed     []FailedPackage `json:"failed"`
+}
+
+// aptSimulatedInstall matches the Inst lines of apt-get upgrade -s:
+// Inst <package> [<current version>] (<new version> <release>[, <release>...] [<arch>])
+var aptSimulatedInstall = regexp.MustCompile(`^Inst (\S+) (?:\[([^\]]*)\] )?\((\S+) (.+) \[([^\]]+)\]\)`)
+
+// runAptGet runs apt-get non-interactively and returns its output.
+// Replaced in tests.
+var runAptGet = func(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "apt-get", args...)
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive", "LC_ALL=C")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("apt-get %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// rootZFSDataset returns the ZFS dataset mounted at /, or "" if the root
+// filesystem is not ZFS. Replaced in tests.
+var rootZFSDataset = func() (string, error) {
+	output, err := exec.Command("findmnt", "-n", "-o", "FSTYPE,SOURCE", "/").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the root filesystem: %w", err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 || fields[0] != "zfs" {
+		return "", nil
+	}
+	return fields[1], nil
+}
+
+// AutoUpdater installs system package updates, on its schedule or when an
+// admin applies them. Security updates are installed on their own so that
+// they do not wait for a full upgrade.
+type AutoUpdater struct {
+	config   AutoUpdateConfig
+	schedule *scheduler.CronSchedule
+	applying sync.Mutex
+
+	createSnapshot func(ctx context.Context, filesystem, name string) (*backup.Snapshot, error)
+	publish        func(notifications.Notification) error
+}
+
+// NewAutoUpdater creates an updater for a config. Snapshots before updates
+// are taken with the backup service if it is running.
+func NewAutoUpdater(config AutoUpdateConfig) (*AutoUpdater, error) {
+	schedule, err := scheduler.ParseCronExpression(config.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid update schedule: %w", err)
+	}
+	if config.SecurityOnly && len(config.AllowedOrigins) == 0 {
+		return nil, fmt.Errorf("security-only updates need allowed origins")
+	}
+
+	u := &AutoUpdater{
+		config:   config,
+		schedule: schedule,
+		publish:  notifications.Publish,
+	}
+	if service := backup.GetService(); service != nil {
+		u.createSnapshot = service.CreateSnapshot
+	}
+	return u, nil
+}
+
+// Config returns the config of the updater
+func (u *AutoUpdater) Config() AutoUpdateConfig {
+	return u.config
+}
+
+// GetSecurityUpdates returns the pending updates from the allowed origins,
+// e.g. security.ubuntu.com (jammy-security) or debian-security
+// (Debian-Security:12/stable-security), as simulated by apt-get upgrade -s
+func (u *AutoUpdater) GetSecurityUpdates() ([]Package, error) {
+	packages, err := u.pendingUpdates(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return securityPackages(packages), nil
+}
+
+// ApplySecurityUpdates snapshots the root filesystem and then installs the
+// pending security updates one package at a time, so that one failing
+// package does not hold back the others
+func (u *AutoUpdater) ApplySecurityUpdates(ctx context.Context) (*UpdateReport, error) {
+	return u.apply(ctx, true, false)
+}
+
+// RunScheduled applies the pending updates of the config once: security
+// updates only, or all of them. If updates require approval the admins are
+// only told about them.
+func (u *AutoUpdater) RunScheduled(ctx context.Context) (*UpdateReport, error) {
+	if u.config.RequireApproval {
+		if _, err := runAptGet(ctx, "update"); err != nil {
+			return nil, err
+		}
+		packages, err := u.GetSecurityUpdates()
+		if err != nil {
+			return nil, err
+		}
+		if len(packages) > 0 {
+			u.notify(fmt.Sprintf("%d security updates await approval", len(packages)),
+				"Apply them in the system settings: "+packageNames(packages), notifications.SeverityWarning, nil)
+		}
+		return &UpdateReport{Automatic: true}, nil
+	}
+	return u.apply(ctx, u.config.SecurityOnly, true)
+}
+
+// Start runs the scheduled updates until ctx is canceled
+func (u *AutoUpdater) Start(ctx context.Context) {
+	if !u.config.Enabled {
+		return
+	}
+	logger.Info("Automatic package updates enabled",
+		zap.String("schedule", u.config.Schedule),
+		zap.Bool("securityOnly", u.config.SecurityOnly),
+		zap.Bool("requireApproval", u.config.RequireApproval))
+
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(u.schedule.Next(time.Now())))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if _, err := u.RunScheduled(ctx); err != nil {
+				logger.Error("Automatic package updates failed", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// apply installs the pending updates, or only the security ones
+func (u *AutoUpdater) apply(ctx context.Context, securityOnly, automatic bool) (*UpdateReport, error) {
+	if !u.applying.TryLock() {
+		return nil, ErrUpdateInProgress
+	}
+	defer u.applying.Unlock()
+
+	report := &UpdateReport{StartedAt: time.Now(), Automatic: automatic}
+	packages, err := u.prepare(ctx, securityOnly, report)
+	if err != nil {
+		u.notify("Package updates failed", err.Error(), notifications.SeverityCritical, report)
+		return nil, err
+	}
+
+	for _, pkg := range packages {
+		_, err := runAptGet(ctx, "install", "-y", "--only-upgrade",
+			"-o", "Dpkg::Options::=--force-confold", pkg.Name)
+		if err != nil {
+			logger.Warn("Failed to update package", zap.String("package", pkg.Name), zap.Error(err))
+			report.Failed = append(report.Failed, FailedPackage{Package: pkg, Error: err.Error()})
+			continue
+		}
+		report.Applied = append(report.Applied, pkg)
+	}
+	report.FinishedAt = time.Now()
+
+	logger.Info("Package updates applied",
+		zap.Bool("automatic", automatic),
+		zap.Int("applied", len(report.Applied)),
+		zap.Int("failed", len(report.Failed)))
+	switch {
+	case len(report.Failed) > 0:
+		failed := make([]Package, len(report.Failed))
+		for i, f := range report.Failed {
+			failed[i] = f.Package
+		}
+		u.notify(fmt.Sprintf("%d of %d package updates failed", len(report.Failed), len(packages)),
+			"Failed: "+packageNames(failed), notifications.SeverityCritical, report)
+	case len(report.Applied) > 0:
+		u.notify(fmt.Sprintf("Installed %d package updates", len(report.Applied)),
+			packageNames(report.Applied), notifications.SeverityInfo, report)
+	}
+	return report, nil
+}
+
+// prepare refreshes the package lists, returns the updates to install and
+// snapshots the root filesystem if there are any
+func (u *AutoUpdater) prepare(ctx context.Context, securityOnly bool, report *UpdateReport) ([]Package, error) {
+	if _, err := runAptGet(ctx, "update"); err != nil {
+		return nil, err
+	}
+	packages, err := u.pendingUpdates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if securityOnly {
+		packages = securityPackages(packages)
+	}
+	if len(packages) == 0 {
+		return nil, nil
+	}
+
+	snapshot, err := u.snapshotRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Snapshot = snapshot
+	return packages, nil
+}
+
+// snapshotRoot snapshots the root filesystem for a rollback of the updates.
+// Without a ZFS root or the backup service updates are not snapshotted.
+func (u *AutoUpdater) snapshotRoot(ctx context.Context) (string, error) {
+	dataset, err := rootZFSDataset()
+	if err != nil {
+		return "", err
+	}
+	if dataset == "" || u.createSnapshot == nil {
+		logger.Warn("Applying package updates without a rollback snapshot",
+			zap.Bool("zfsRoot", dataset != ""))
+		return "", nil
+	}
+
+	snapshot, err := u.createSnapshot(ctx, dataset, "pre-update-"+time.Now().Format("20060102-150405"))
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot %s before the updates: %w", dataset, err)
+	}
+	return snapshot.ID, nil
+}
+
+// pendingUpdates returns all updates apt-get upgrade would install
+func (u *AutoUpdater) pendingUpdates(ctx context.Context) ([]Package, error) {
+	output, err := runAptGet(ctx, "upgrade", "-s")
+	if err != nil {
+		return nil, err
+	}
+	return parseAptSimulation(output, u.config.AllowedOrigins), nil
+}
+
+// parseAptSimulation parses the output of apt-get upgrade -s. Updates from a
+// release label containing one of allowedOrigins are security updates.
+func parseAptSimulation(output string, allowedOrigins []string) []Package {
+	var packages []Package
+	for _, line := range strings.Split(output, "\n") {
+		m := aptSimulatedInstall.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		pkg := Package{
+			Name:           m[1],
+			CurrentVersion: m[2],
+			NewVersion:     m[3],
+			Origins:        strings.Split(m[4], ", "),
+			Architecture:   m[5],
+		}
+		for _, origin := range pkg.Origins {
+			for _, allowed := range allowedOrigins {
+				if strings.Contains(strings.ToLower(origin), strings.ToLower(allowed)) {
+					pkg.Security = true
+				}
+			}
+		}
+		packages = append(packages, pkg)
+	}
+	return packages
+}
+
+// securityPackages returns the security updates of packages
+func securityPackages(packages []Package) []Package {
+	security := []Package{}
+	for _, pkg := range packages {
+		if pkg.Security {
+			security = append(security, pkg)
+		}
+	}
+	return security
+}
+
+// packageNames lists packages with their new versions
+func packageNames(packages []Package) string {
+	names := make([]string, len(packages))
+	for i, pkg := range packages {
+		names[i] = pkg.Name + " " + pkg.NewVersion
+	}
+	return strings.Join(names, ", ")
+}
+
+// notify publishes an alert about package updates
+func (u *AutoUpdater) notify(title, body, severity string, report *UpdateReport) {
+	metadata := map[string]interface{}{}
+	if report != nil {
+		metadata["automatic"] = report.Automatic
+		metadata["applied"] = len(report.Applied)
+		metadata["failed"] = len(report.Failed)
+		if report.Snapshot != "" {
+			metadata["snapshot"] = report.Snapshot
+		}
+	}
+	err := u.publish(notifications.Notification{
+		Type:     models.AlertTypeSystemUpdates,
+		Title:    title,
+		Body:     body,
+		Severity: severity,
+		Source:   "updates",
+		Metadata: metadata,
+	})
+	if err != nil {
+		logger.Warn("Failed to publish update alert", zap.String("title", title), zap.Error(err))
+	}
+}
@@ -0,0 +1,193 @@
+package updates
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/backup"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/notifications"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+)
+
+// ubuntuUpgradeSimulation is apt-get upgrade -s output on Ubuntu 22.04:
+// libssl3 is in jammy-security and jammy-updates, tzdata only in updates
+const ubuntuUpgradeSimulation = `Reading package lists...
+Building dependency tree...
+Reading state information...
+Calculating upgrade...
+The following packages will be upgraded:
+  libssl3 openssl tzdata
+3 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.
+Inst libssl3 [3.0.2-0ubuntu1.10] (3.0.2-0ubuntu1.12 Ubuntu:22.04/jammy-updates, Ubuntu:22.04/jammy-security [amd64])
+Inst openssl [3.0.2-0ubuntu1.10] (3.0.2-0ubuntu1.12 Ubuntu:22.04/jammy-updates, Ubuntu:22.04/jammy-security [amd64]) []
+Inst tzdata [2023c-0ubuntu0.22.04.2] (2024a-0ubuntu0.22.04 Ubuntu:22.04/jammy-updates [all])
+Conf libssl3 (3.0.2-0ubuntu1.12 Ubuntu:22.04/jammy-updates, Ubuntu:22.04/jammy-security [amd64])
+Conf openssl (3.0.2-0ubuntu1.12 Ubuntu:22.04/jammy-updates, Ubuntu:22.04/jammy-security [amd64])
+Conf tzdata (2024a-0ubuntu0.22.04 Ubuntu:22.04/jammy-updates [all])
+`
+
+// debianUpgradeSimulation is apt-get upgrade -s output on Debian 12
+const debianUpgradeSimulation = `NOTE: This is only a simulation!
+      apt-get needs root privileges for real execution.
+Inst libc-bin [2.36-9+deb12u3] (2.36-9+deb12u4 Debian-Security:12/stable-security [amd64])
+Inst samba-libs [2:4.17.12+dfsg-0+deb12u1] (2:4.17.12+dfsg-0+deb12u2 Debian:12.5/stable [amd64])
+Conf libc-bin (2.36-9+deb12u4 Debian-Security:12/stable-security [amd64])
+`
+
+func TestParseAptSimulation(t *testing.T) {
+	packages := parseAptSimulation(ubuntuUpgradeSimulation, []string{"-security"})
+	want := []Package{
+		{
+			Name:           "libssl3",
+			CurrentVersion: "3.0.2-0ubuntu1.10",
+			NewVersion:     "3.0.2-0ubuntu1.12",
+			Architecture:   "amd64",
+			Origins:        []string{"Ubuntu:22.04/jammy-updates", "Ubuntu:22.04/jammy-security"},
+			Security:       true,
+		},
+		{
+			Name:           "openssl",
+			CurrentVersion: "3.0.2-0ubuntu1.10",
+			NewVersion:     "3.0.2-0ubuntu1.12",
+			Architecture:   "amd64",
+			Origins:        []string{"Ubuntu:22.04/jammy-updates", "Ubuntu:22.04/jammy-security"},
+			Security:       true,
+		},
+		{
+			Name:           "tzdata",
+			CurrentVersion: "2023c-0ubuntu0.22.04.2",
+			NewVersion:     "2024a-0ubuntu0.22.04",
+			Architecture:   "all",
+			Origins:        []string{"Ubuntu:22.04/jammy-updates"},
+		},
+	}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("packages =\n%+v\nwant\n%+v", packages, want)
+	}
+
+	packages = parseAptSimulation(debianUpgradeSimulation, []string{"-security"})
+	if len(packages) != 2 || !packages[0].Security || packages[1].Security {
+		t.Errorf("debian packages = %+v, want libc-bin as the only security update", packages)
+	}
+	if packages[1].CurrentVersion != "2:4.17.12+dfsg-0+deb12u1" {
+		t.Errorf("samba-libs version = %q", packages[1].CurrentVersion)
+	}
+
+	// Origins match case-insensitively
+	packages = parseAptSimulation(debianUpgradeSimulation, []string{"debian-security"})
+	if names := packageNames(securityPackages(packages)); names != "libc-bin 2.36-9+deb12u4" {
+		t.Errorf("security updates = %q", names)
+	}
+}
+
+func TestApplySecurityUpdates(t *testing.T) {
+	logger.InitLogger("error", false)
+	var calls []string
+	realApt, realRoot := runAptGet, rootZFSDataset
+	runAptGet = func(ctx context.Context, args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		switch {
+		case args[0] == "upgrade":
+			return ubuntuUpgradeSimulation, nil
+		case args[len(args)-1] == "openssl":
+			return "", fmt.Errorf("dpkg was interrupted")
+		}
+		return "", nil
+	}
+	rootZFSDataset = func() (string, error) { return "rpool/ROOT/ubuntu", nil }
+	t.Cleanup(func() { runAptGet, rootZFSDataset = realApt, realRoot })
+
+	var snapshotted string
+	var published []notifications.Notification
+	u, err := NewAutoUpdater(AutoUpdateConfig{SecurityOnly: true, AllowedOrigins: []string{"-security"}, Schedule: "0 4 * * *"})
+	if err != nil {
+		t.Fatalf("NewAutoUpdater: %v", err)
+	}
+	u.createSnapshot = func(ctx context.Context, filesystem, name string) (*backup.Snapshot, error) {
+		snapshotted = filesystem
+		return &backup.Snapshot{ID: filesystem + "@" + name}, nil
+	}
+	u.publish = func(n notifications.Notification) error {
+		published = append(published, n)
+		return nil
+	}
+
+	report, err := u.ApplySecurityUpdates(context.Background())
+	if err != nil {
+		t.Fatalf("ApplySecurityUpdates: %v", err)
+	}
+
+	wantCalls := []string{
+		"update",
+		"upgrade -s",
+		"install -y --only-upgrade -o Dpkg::Options::=--force-confold libssl3",
+		"install -y --only-upgrade -o Dpkg::Options::=--force-confold openssl",
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Errorf("apt-get calls = %q, want %q", calls, wantCalls)
+	}
+	if snapshotted != "rpool/ROOT/ubuntu" || !strings.HasPrefix(report.Snapshot, "rpool/ROOT/ubuntu@pre-update-") {
+		t.Errorf("snapshot = %q of %q", report.Snapshot, snapshotted)
+	}
+	if len(report.Applied) != 1 || report.Applied[0].Name != "libssl3" ||
+		len(report.Failed) != 1 || report.Failed[0].Name != "openssl" {
+		t.Errorf("report = %+v", report)
+	}
+	if len(published) != 1 || published[0].Severity != notifications.SeverityCritical {
+		t.Errorf("published %+v, want one failure alert", published)
+	}
+
+	// No rollback, no updates
+	calls = nil
+	u.createSnapshot = func(ctx context.Context, filesystem, name string) (*backup.Snapshot, error) {
+		return nil, fmt.Errorf("dataset is busy")
+	}
+	if _, err := u.ApplySecurityUpdates(context.Background()); err == nil {
+		t.Error("applied updates without a snapshot")
+	}
+	if len(calls) != 2 {
+		t.Errorf("apt-get calls = %q, want no installs", calls)
+	}
+}
+
+func TestRunScheduledRequiresApproval(t *testing.T) {
+	logger.InitLogger("error", false)
+	realApt := runAptGet
+	runAptGet = func(ctx context.Context, args ...string) (string, error) {
+		if args[0] == "install" {
+			t.Errorf("installed packages without approval: %v", args)
+		}
+		return debianUpgradeSimulation, nil
+	}
+	t.Cleanup(func() { runAptGet = realApt })
+
+	var published []notifications.Notification
+	u, err := NewAutoUpdater(AutoUpdateConfig{
+		Enabled:         true,
+		SecurityOnly:    true,
+		AllowedOrigins:  []string{"-security"},
+		Schedule:        "0 4 * * *",
+		RequireApproval: true,
+	})
+	if err != nil {
+		t.Fatalf("NewAutoUpdater: %v", err)
+	}
+	u.publish = func(n notifications.Notification) error {
+		published = append(published, n)
+		return nil
+	}
+
+	if _, err := u.RunScheduled(context.Background()); err != nil {
+		t.Fatalf("RunScheduled: %v", err)
+	}
+	if len(published) != 1 || !strings.Contains(published[0].Body, "libc-bin") {
+		t.Errorf("published %+v, want an approval request for libc-bin", published)
+	}
+
+	if _, err := NewAutoUpdater(AutoUpdateConfig{Schedule: "every night"}); err == nil {
+		t.Error("accepted an invalid schedule")
+	}
+}
@@ -0,0 +1,272 @@
+package usergroups
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// GroupHierarchy resolves nested group membership. A group's transitive
+// members are its own members plus those of all its subgroups.
+type GroupHierarchy struct {
+	db *gorm.DB
+}
+
+// AddSubgroupRequest represents a request to nest a group in another
+type AddSubgroupRequest struct {
+	GroupID uint `json:"groupId" validate:"required"`
+}
+
+// GroupTreeNode is a group with its direct members and nested subgroups
+type GroupTreeNode struct {
+	ID        uint             `json:"id"`
+	Name      string           `json:"name"`
+	Members   []MemberInfo     `json:"members"`
+	Subgroups []*GroupTreeNode `json:"subgroups"`
+}
+
+// NewGroupHierarchy creates a group hierarchy backed by db
+func NewGroupHierarchy(db *gorm.DB) *GroupHierarchy {
+	return &GroupHierarchy{db: db}
+}
+
+// GetGroupHierarchy returns the group hierarchy of the main database
+func GetGroupHierarchy() *GroupHierarchy {
+	return NewGroupHierarchy(database.DB)
+}
+
+// AddSubgroup makes childGID a member of parentGID. Refuses memberships
+// that would make a group (transitively) contain itself.
+func (h *GroupHierarchy) AddSubgroup(parentGID, childGID string) error {
+	parent, err := h.group(parentGID)
+	if err != nil {
+		return err
+	}
+	child, err := h.group(childGID)
+	if err != nil {
+		return err
+	}
+
+	if parent.ID == child.ID {
+		return errors.BadRequest("A group cannot contain itself", nil)
+	}
+
+	edges, err := h.edges()
+	if err != nil {
+		return err
+	}
+	for _, c := range edges[parent.ID] {
+		if c == child.ID {
+			return errors.Conflict(fmt.Sprintf("%s is already a subgroup of %s", child.Name, parent.Name), nil)
+		}
+	}
+	if descendants(edges, child.ID)[parent.ID] {
+		return errors.BadRequest(fmt.Sprintf("Circular membership: %s already contains %s", child.Name, parent.Name), nil)
+	}
+
+	if err := h.db.Create(&models.GroupMembership{ParentID: parent.ID, ChildID: child.ID}).Error; err != nil {
+		return errors.InternalServerError("Failed to add subgroup", err)
+	}
+	return nil
+}
+
+// RemoveSubgroup removes childGID from parentGID
+func (h *GroupHierarchy) RemoveSubgroup(parentGID, childGID string) error {
+	parentID, err := parseGroupID(parentGID)
+	if err != nil {
+		return err
+	}
+	childID, err := parseGroupID(childGID)
+	if err != nil {
+		return err
+	}
+
+	result := h.db.Where("parent_id = ? AND child_id = ?", parentID, childID).Delete(&models.GroupMembership{})
+	if result.Error != nil {
+		return errors.InternalServerError("Failed to remove subgroup", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFound("Subgroup not found", nil)
+	}
+	return nil
+}
+
+// GetTransitiveMemberIDs returns the IDs of the users that are members of
+// the group directly or through any of its subgroups, in ascending order
+func (h *GroupHierarchy) GetTransitiveMemberIDs(groupID string) ([]string, error) {
+	group, err := h.group(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	edges, err := h.edges()
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := []uint{group.ID}
+	for id := range descendants(edges, group.ID) {
+		groupIDs = append(groupIDs, id)
+	}
+
+	var userIDs []uint
+	err = h.db.Table("user_group_members").
+		Joins("JOIN users ON users.id = user_group_members.user_id AND users.deleted_at IS NULL").
+		Distinct("user_group_members.user_id").
+		Where("user_group_members.user_group_id IN ?", groupIDs).
+		Order("user_group_members.user_id").
+		Pluck("user_group_members.user_id", &userIDs).Error
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to query group members", err)
+	}
+	return formatIDs(userIDs), nil
+}
+
+// GetTransitiveGroupsForUser returns the IDs of the groups the user belongs
+// to directly or through subgroup membership, in ascending order
+func (h *GroupHierarchy) GetTransitiveGroupsForUser(userID string) ([]string, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return nil, errors.BadRequest("Invalid user ID", err)
+	}
+
+	var direct []uint
+	err = h.db.Table("user_group_members").
+		Where("user_id = ?", id).
+		Pluck("user_group_id", &direct).Error
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to query group members", err)
+	}
+
+	edges, err := h.edges()
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[uint][]uint)
+	for parent, children := range edges {
+		for _, child := range children {
+			parents[child] = append(parents[child], parent)
+		}
+	}
+
+	groups := make(map[uint]bool)
+	for _, g := range direct {
+		groups[g] = true
+		for ancestor := range descendants(parents, g) {
+			groups[ancestor] = true
+		}
+	}
+
+	ids := make([]uint, 0, len(groups))
+	for g := range groups {
+		ids = append(ids, g)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return formatIDs(ids), nil
+}
+
+// GetTree returns the group with its nested subgroups
+func (h *GroupHierarchy) GetTree(groupID string) (*GroupTreeNode, error) {
+	root, err := h.group(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	edges, err := h.edges()
+	if err != nil {
+		return nil, err
+	}
+
+	var build func(id uint) (*GroupTreeNode, error)
+	build = func(id uint) (*GroupTreeNode, error) {
+		var group models.UserGroup
+		if err := h.db.Preload("Members").First(&group, id).Error; err != nil {
+			return nil, errors.InternalServerError("Failed to query group", err)
+		}
+		node := &GroupTreeNode{
+			ID:        group.ID,
+			Name:      group.Name,
+			Members:   ToResponse(&group).Members,
+			Subgroups: []*GroupTreeNode{},
+		}
+		// Cycles are refused on insertion, so the recursion terminates
+		for _, child := range edges[id] {
+			sub, err := build(child)
+			if err != nil {
+				return nil, err
+			}
+			node.Subgroups = append(node.Subgroups, sub)
+		}
+		return node, nil
+	}
+	return build(root.ID)
+}
+
+// group loads a group by its ID given as a string
+func (h *GroupHierarchy) group(groupID string) (*models.UserGroup, error) {
+	id, err := parseGroupID(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var group models.UserGroup
+	if err := h.db.First(&group, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NotFound("Group not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to query group", err)
+	}
+	return &group, nil
+}
+
+// edges returns the child group IDs of every group with subgroups
+func (h *GroupHierarchy) edges() (map[uint][]uint, error) {
+	var memberships []models.GroupMembership
+	if err := h.db.Order("child_id").Find(&memberships).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to query subgroups", err)
+	}
+
+	edges := make(map[uint][]uint)
+	for _, m := range memberships {
+		edges[m.ParentID] = append(edges[m.ParentID], m.ChildID)
+	}
+	return edges, nil
+}
+
+// descendants returns all groups reachable from id, not including id itself
+// unless it is part of a cycle
+func descendants(edges map[uint][]uint, id uint) map[uint]bool {
+	seen := make(map[uint]bool)
+	queue := []uint{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range edges[current] {
+			if !seen[child] {
+				seen[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return seen
+}
+
+func parseGroupID(groupID string) (uint, error) {
+	id, err := strconv.ParseUint(groupID, 10, 32)
+	if err != nil {
+		return 0, errors.BadRequest("Invalid group ID", err)
+	}
+	return uint(id), nil
+}
+
+func formatIDs(ids []uint) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package usergroups
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGroupHierarchy(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "groups.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserGroup{}, &models.GroupMembership{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// AllEmployees > Managers > CSuite, each with one direct member, and
+	// "dave" in both Managers and CSuite
+	userIDs := map[string]string{}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		u := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x", Role: "user"}
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+		userIDs[name] = strconv.Itoa(int(u.ID))
+	}
+	groupIDs := map[string]string{}
+	for _, group := range []struct {
+		name    string
+		members []string
+	}{
+		{"AllEmployees", []string{"alice"}},
+		{"Managers", []string{"bob", "dave"}},
+		{"CSuite", []string{"carol", "dave"}},
+		{"Contractors", nil},
+	} {
+		name, members := group.name, group.members
+		g := &models.UserGroup{Name: name}
+		if err := db.Create(g).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range members {
+			var u models.User
+			db.First(&u, "username = ?", m)
+			if err := db.Model(g).Association("Members").Append(&u); err != nil {
+				t.Fatal(err)
+			}
+		}
+		groupIDs[name] = strconv.Itoa(int(g.ID))
+	}
+
+	h := NewGroupHierarchy(db)
+	if err := h.AddSubgroup(groupIDs["AllEmployees"], groupIDs["Managers"]); err != nil {
+		t.Fatalf("AddSubgroup() error = %v", err)
+	}
+	if err := h.AddSubgroup(groupIDs["Managers"], groupIDs["CSuite"]); err != nil {
+		t.Fatalf("AddSubgroup() error = %v", err)
+	}
+
+	t.Run("circular membership is refused", func(t *testing.T) {
+		for _, tt := range []struct{ parent, child string }{
+			{"CSuite", "AllEmployees"}, // Three-level cycle
+			{"CSuite", "Managers"},     // Two-level cycle
+			{"Managers", "Managers"},   // Self
+		} {
+			err := h.AddSubgroup(groupIDs[tt.parent], groupIDs[tt.child])
+			if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != 400 {
+				t.Errorf("AddSubgroup(%s, %s) error = %v, want bad request", tt.parent, tt.child, err)
+			}
+		}
+		if err := h.AddSubgroup(groupIDs["AllEmployees"], groupIDs["Managers"]); err == nil {
+			t.Error("AddSubgroup() accepted a duplicate subgroup")
+		}
+	})
+
+	t.Run("members are flattened", func(t *testing.T) {
+		tests := map[string][]string{
+			"AllEmployees": {userIDs["alice"], userIDs["bob"], userIDs["carol"], userIDs["dave"]},
+			"Managers":     {userIDs["bob"], userIDs["carol"], userIDs["dave"]},
+			"CSuite":       {userIDs["carol"], userIDs["dave"]},
+			"Contractors":  {},
+		}
+		for group, want := range tests {
+			got, err := h.GetTransitiveMemberIDs(groupIDs[group])
+			if err != nil {
+				t.Fatalf("GetTransitiveMemberIDs(%s) error = %v", group, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GetTransitiveMemberIDs(%s) = %v, want %v", group, got, want)
+			}
+		}
+	})
+
+	t.Run("groups for user", func(t *testing.T) {
+		got, err := h.GetTransitiveGroupsForUser(userIDs["carol"])
+		if err != nil {
+			t.Fatalf("GetTransitiveGroupsForUser() error = %v", err)
+		}
+		want := []string{groupIDs["AllEmployees"], groupIDs["Managers"], groupIDs["CSuite"]}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetTransitiveGroupsForUser(carol) = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		tree, err := h.GetTree(groupIDs["AllEmployees"])
+		if err != nil {
+			t.Fatalf("GetTree() error = %v", err)
+		}
+		if len(tree.Subgroups) != 1 || tree.Subgroups[0].Name != "Managers" ||
+			len(tree.Subgroups[0].Subgroups) != 1 || tree.Subgroups[0].Subgroups[0].Name != "CSuite" {
+			t.Errorf("GetTree() = %+v", tree)
+		}
+	})
+
+	if err := h.RemoveSubgroup(groupIDs["Managers"], groupIDs["CSuite"]); err != nil {
+		t.Fatalf("RemoveSubgroup() error = %v", err)
+	}
+	if got, _ := h.GetTransitiveMemberIDs(groupIDs["AllEmployees"]); len(got) != 3 {
+		t.Errorf("members after RemoveSubgroup = %v, want alice, bob and dave", got)
+	}
+}
@@ -152,6 +152,11 @@ func DeleteGroup(id uint) error {
 		return errors.InternalServerError("Failed to clear group members", err)
 	}
 
+	// Remove the group from the hierarchy
+	if err := database.DB.Where("parent_id = ? OR child_id = ?", id, id).Delete(&models.GroupMembership{}).Error; err != nil {
+		return errors.InternalServerError("Failed to remove subgroup memberships", err)
+	}
+
 	// Delete group
 	if err := database.DB.Delete(group).Error; err != nil {
 		return errors.InternalServerError("Failed to delete group", err)
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
@@ -104,6 +105,7 @@ func (m *UnixGroupManager) DeleteUnixGroup(group *models.UserGroup) error {
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to delete Unix group %s: %s: %w", groupName, string(output), err)
 	}
+	database.Cached().InvalidateGroup(groupName)
 
 	logger.Info("Deleted Unix group", zap.String("group", groupName))
 	return nil
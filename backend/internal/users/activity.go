@@ -0,0 +1,205 @@
+package users
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+)
+
+// topSharesLimit is the number of shares in UserActivityReport.TopAccessedShares
+const topSharesLimit = 5
+
+// Audit resources that count as a created file. Chunked uploads log every
+// chunk as file.upload, so only the final request of an upload counts.
+var fileCreateResources = []string{"/files/upload", "/files/upload/finalize"}
+
+// ShareStat is the number of file API requests a user made in a share
+type ShareStat struct {
+	Share    string `json:"share"`
+	Accesses int    `json:"accesses"`
+}
+
+// UserActivityReport summarizes what a user did since a point in time
+type UserActivityReport struct {
+	UserID            uint        `json:"userId"`
+	Username          string      `json:"username"`
+	Since             time.Time   `json:"since"`
+	LastLogin         *time.Time  `json:"lastLogin,omitempty"`
+	LoginCount        int         `json:"loginCount"`
+	SharesAccessed    []string    `json:"sharesAccessed"`
+	FilesCreated      int         `json:"filesCreated"`
+	FilesModified     int         `json:"filesModified"`
+	FilesDeleted      int         `json:"filesDeleted"`
+	VPNHours          float64     `json:"vpnHours"` // Always 0 until VPN sessions are recorded
+	TopAccessedShares []ShareStat `json:"topAccessedShares"`
+}
+
+// UserActivity describes an account without logins since a point in time
+type UserActivity struct {
+	UserID       uint       `json:"userId"`
+	Username     string     `json:"username"`
+	Email        string     `json:"email"`
+	Role         string     `json:"role"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastLogin    *time.Time `json:"lastLogin,omitempty"`
+	DaysInactive int        `json:"daysInactive"`
+}
+
+// GetActivityReport builds a user's activity report from the audit and
+// access logs. Counts only cover what is still in the logs, so they are
+// bounded by the log retention.
+func GetActivityReport(userID string, since time.Time) (*UserActivityReport, error) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return nil, errors.BadRequest("Invalid user ID", err)
+	}
+	user, err := GetUserByID(uint(id))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UserActivityReport{
+		UserID:            user.ID,
+		Username:          user.Username,
+		Since:             since,
+		LastLogin:         user.LastLoginAt,
+		SharesAccessed:    []string{},
+		TopAccessedShares: []ShareStat{},
+	}
+
+	counts := []struct {
+		target *int
+		where  string
+		args   []interface{}
+	}{
+		{&report.LoginCount, "action = ?", []interface{}{models.ActionAuthLogin}},
+		{&report.FilesCreated, "((action = ? AND resource IN ?) OR action = ?)",
+			[]interface{}{models.ActionFileUpload, fileCreateResources, models.ActionFileCopy}},
+		{&report.FilesModified, "action IN ?", []interface{}{[]string{models.ActionFileRename, models.ActionFileMove}}},
+		{&report.FilesDeleted, "action = ?", []interface{}{models.ActionFileDelete}},
+	}
+	for _, c := range counts {
+		var n int64
+		err := database.DB.Model(&models.AuditLog{}).
+			Where("user_id = ? AND created_at >= ? AND status = ?", user.ID, since, models.StatusSuccess).
+			Where(c.where, c.args...).
+			Count(&n).Error
+		if err != nil {
+			return nil, errors.InternalServerError("Failed to query audit logs", err)
+		}
+		*c.target = int(n)
+	}
+
+	stats, err := shareAccessStats(user.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stats {
+		report.SharesAccessed = append(report.SharesAccessed, s.Share)
+	}
+	sort.Strings(report.SharesAccessed)
+	if len(stats) > topSharesLimit {
+		stats = stats[:topSharesLimit]
+	}
+	report.TopAccessedShares = stats
+
+	return report, nil
+}
+
+// shareAccessStats counts the user's file API requests per share, most
+// accessed first. Requests are mapped to shares by their path parameter.
+func shareAccessStats(userID uint, since time.Time) ([]ShareStat, error) {
+	var shares []models.Share
+	if err := database.DB.Find(&shares).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to query shares", err)
+	}
+	if len(shares) == 0 {
+		return []ShareStat{}, nil
+	}
+
+	var logs []models.AccessLog
+	err := database.DB.Select("query").
+		Where("user_id = ? AND created_at >= ? AND path LIKE ? AND status_code < 400", userID, since, "/api/v1/files/%").
+		Find(&logs).Error
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to query access logs", err)
+	}
+
+	accesses := make(map[string]int)
+	for _, l := range logs {
+		values, err := url.ParseQuery(l.Query)
+		if err != nil {
+			continue
+		}
+		if share := shareForPath(shares, values.Get("path")); share != "" {
+			accesses[share]++
+		}
+	}
+
+	stats := make([]ShareStat, 0, len(accesses))
+	for share, n := range accesses {
+		stats = append(stats, ShareStat{Share: share, Accesses: n})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Accesses != stats[j].Accesses {
+			return stats[i].Accesses > stats[j].Accesses
+		}
+		return stats[i].Share < stats[j].Share
+	})
+	return stats, nil
+}
+
+// shareForPath returns the name of the share with the longest path that
+// contains path
+func shareForPath(shares []models.Share, path string) string {
+	if path == "" {
+		return ""
+	}
+	best, bestLen := "", -1
+	for _, s := range shares {
+		root := strings.TrimSuffix(s.Path, "/")
+		if (path == root || strings.HasPrefix(path, root+"/")) && len(root) > bestLen {
+			best, bestLen = s.Name, len(root)
+		}
+	}
+	return best
+}
+
+// GetInactiveUsers returns the active accounts that have not logged in since
+// the given time, longest inactive first. Accounts created after since are
+// not reported.
+func GetInactiveUsers(since time.Time) ([]UserActivity, error) {
+	var users []User
+	err := database.DB.
+		Where("is_active = ? AND created_at < ? AND (last_login_at IS NULL OR last_login_at < ?)", true, since, since).
+		Find(&users).Error
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to query users", err)
+	}
+
+	now := time.Now()
+	result := make([]UserActivity, 0, len(users))
+	for _, u := range users {
+		lastSeen := u.CreatedAt
+		if u.LastLoginAt != nil {
+			lastSeen = *u.LastLoginAt
+		}
+		result = append(result, UserActivity{
+			UserID:       u.ID,
+			Username:     u.Username,
+			Email:        u.Email,
+			Role:         u.Role,
+			CreatedAt:    u.CreatedAt,
+			LastLogin:    u.LastLoginAt,
+			DaysInactive: int(now.Sub(lastSeen).Hours() / 24),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DaysInactive > result[j].DaysInactive })
+	return result, nil
+}
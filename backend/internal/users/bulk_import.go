@@ -0,0 +1,480 @@
+package users
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/alerts"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/usergroups"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// What BulkImport does with rows whose username or email already exists
+const (
+	OnConflictSkip   = "skip"
+	OnConflictUpdate = "update"
+	OnConflictError  = "error"
+)
+
+// maxImportRows limits the size of a single import
+const maxImportRows = 10000
+
+// BulkImportOptions controls a bulk user import
+type BulkImportOptions struct {
+	DryRun           bool   `json:"dryRun"`
+	OnConflict       string `json:"onConflict"`      // skip (default), update or error
+	DefaultPassword  string `json:"defaultPassword"` // For rows without a password
+	SendWelcomeEmail bool   `json:"sendWelcomeEmail"`
+}
+
+// RowError describes why a row of an import failed
+type RowError struct {
+	Row      int    `json:"row"` // CSV line or LDIF entry number, starting at 1
+	Username string `json:"username,omitempty"`
+	Message  string `json:"message"`
+}
+
+// BulkImportResult summarizes a bulk user import. With DryRun the counts
+// are what the import would do.
+type BulkImportResult struct {
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Skipped int        `json:"skipped"`
+	Failed  int        `json:"failed"`
+	Errors  []RowError `json:"errors"`
+}
+
+// importRecord is one user parsed from an import file
+type importRecord struct {
+	row      int
+	username string
+	email    string
+	fullName string
+	role     string
+	groups   []string
+	password string
+}
+
+// csvColumns are the CSV columns in their default order
+var csvColumns = []string{"username", "email", "fullname", "role", "groupnames", "password"}
+
+// BulkImportCSV creates users from CSV with the columns Username, Email,
+// FullName, Role, GroupNames and Password. A header row may reorder or omit
+// columns; without one the columns are read in that order. GroupNames are
+// separated by semicolons.
+func BulkImportCSV(r io.Reader, opts BulkImportOptions) (*BulkImportResult, error) {
+	records, err := parseImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	return bulkImport(records, opts)
+}
+
+// BulkImportLDIF creates users from the person entries of an LDAP export
+// (entries with uid or sAMAccountName). Groups are taken from memberOf.
+// Hashed userPassword values cannot be imported, so LDIF users get the
+// default password.
+func BulkImportLDIF(r io.Reader, opts BulkImportOptions) (*BulkImportResult, error) {
+	records, err := parseImportLDIF(r)
+	if err != nil {
+		return nil, err
+	}
+	return bulkImport(records, opts)
+}
+
+func parseImportCSV(r io.Reader) ([]importRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := csvColumns
+	var records []importRecord
+	for line := 1; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.BadRequest(fmt.Sprintf("Invalid CSV: %v", err), err)
+		}
+
+		if line == 1 && strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(fields[0], "\ufeff")), "username") {
+			columns = make([]string, len(fields))
+			for i, f := range fields {
+				columns[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(f, "\ufeff")))
+			}
+			continue
+		}
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+		if len(records) >= maxImportRows {
+			return nil, errors.BadRequest(fmt.Sprintf("Import is limited to %d users", maxImportRows), nil)
+		}
+
+		rec := importRecord{row: line}
+		for i, f := range fields {
+			if i >= len(columns) {
+				break
+			}
+			f = strings.TrimSpace(f)
+			switch columns[i] {
+			case "username":
+				rec.username = f
+			case "email":
+				rec.email = f
+			case "fullname":
+				rec.fullName = f
+			case "role":
+				rec.role = strings.ToLower(f)
+			case "groupnames", "groups":
+				rec.groups = splitGroupNames(f)
+			case "password":
+				rec.password = f
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func parseImportLDIF(r io.Reader) ([]importRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var records []importRecord
+	var lines []string
+	entry := 0
+
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		entry++
+		attrs, err := parseLDIFEntry(lines)
+		lines = nil
+		if err != nil {
+			return errors.BadRequest(fmt.Sprintf("Invalid LDIF in entry %d: %v", entry, err), err)
+		}
+
+		username := first(attrs["uid"])
+		if username == "" {
+			username = first(attrs["samaccountname"])
+		}
+		if username == "" {
+			return nil // Not a person (ou, group, ...)
+		}
+		if len(records) >= maxImportRows {
+			return errors.BadRequest(fmt.Sprintf("Import is limited to %d users", maxImportRows), nil)
+		}
+
+		fullName := first(attrs["displayname"])
+		if fullName == "" {
+			fullName = first(attrs["cn"])
+		}
+		rec := importRecord{
+			row:      entry,
+			username: username,
+			email:    first(attrs["mail"]),
+			fullName: fullName,
+		}
+		for _, dn := range attrs["memberof"] {
+			if name := firstRDNValue(dn); name != "" {
+				rec.groups = append(rec.groups, name)
+			}
+		}
+		records = append(records, rec)
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, " "):
+			// Folded continuation of the previous line
+			if len(lines) > 0 {
+				lines[len(lines)-1] += line[1:]
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.BadRequest("Failed to read LDIF", err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// parseLDIFEntry returns the attributes of an entry keyed by lowercase name
+func parseLDIFEntry(lines []string) (map[string][]string, error) {
+	attrs := make(map[string][]string)
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %q is not an attribute", line)
+		}
+		if strings.HasPrefix(value, ":") {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("attribute %s: invalid base64", name)
+			}
+			value = string(decoded)
+		} else if strings.HasPrefix(value, "<") {
+			continue // URL references are not supported
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if i := strings.Index(name, ";"); i >= 0 {
+			name = name[:i] // Attribute options such as ;lang-de
+		}
+		attrs[name] = append(attrs[name], strings.TrimSpace(value))
+	}
+	return attrs, nil
+}
+
+// firstRDNValue returns "staff" for "cn=staff,ou=groups,dc=example,dc=com"
+func firstRDNValue(dn string) string {
+	rdn, _, _ := strings.Cut(dn, ",")
+	_, value, ok := strings.Cut(rdn, "=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+func splitGroupNames(s string) []string {
+	var groups []string
+	for _, g := range strings.Split(s, ";") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// bulkImport validates and applies parsed records. Rows are independent:
+// a failing row is reported and the import continues with the next one.
+func bulkImport(records []importRecord, opts BulkImportOptions) (*BulkImportResult, error) {
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = OnConflictSkip
+	case OnConflictSkip, OnConflictUpdate, OnConflictError:
+	default:
+		return nil, errors.BadRequest("onConflict must be one of: skip, update, error", nil)
+	}
+	if opts.DefaultPassword != "" && len(opts.DefaultPassword) < 8 {
+		return nil, errors.BadRequest("Default password must be at least 8 characters", nil)
+	}
+
+	result := &BulkImportResult{Errors: []RowError{}}
+	fail := func(rec importRecord, msg string) {
+		result.Failed++
+		result.Errors = append(result.Errors, RowError{Row: rec.row, Username: rec.username, Message: msg})
+	}
+
+	groupIDs := make(map[string]uint)
+	seenUsernames := make(map[string]bool)
+	seenEmails := make(map[string]bool)
+
+	for _, rec := range records {
+		if rec.role == "" {
+			rec.role = "user"
+		}
+		if msg := validateImportRecord(rec); msg != "" {
+			fail(rec, msg)
+			continue
+		}
+
+		// Duplicates within the file are always errors
+		if seenUsernames[strings.ToLower(rec.username)] {
+			fail(rec, "Username appears more than once in the file")
+			continue
+		}
+		if seenEmails[strings.ToLower(rec.email)] {
+			fail(rec, "Email appears more than once in the file")
+			continue
+		}
+		seenUsernames[strings.ToLower(rec.username)] = true
+		seenEmails[strings.ToLower(rec.email)] = true
+
+		if msg := resolveImportGroups(rec.groups, groupIDs); msg != "" {
+			fail(rec, msg)
+			continue
+		}
+
+		var existing User
+		err := database.DB.Where("username = ? OR email = ?", rec.username, rec.email).First(&existing).Error
+		if err == nil {
+			switch {
+			case opts.OnConflict == OnConflictSkip:
+				result.Skipped++
+			case opts.OnConflict == OnConflictError:
+				fail(rec, fmt.Sprintf("User %s already exists", existing.Username))
+			case existing.Username != rec.username:
+				fail(rec, fmt.Sprintf("Email is already used by %s", existing.Username))
+			default:
+				if opts.DryRun {
+					result.Updated++
+				} else if msg := updateImportedUser(&existing, rec, groupIDs); msg != "" {
+					fail(rec, msg)
+				} else {
+					result.Updated++
+				}
+			}
+			continue
+		}
+
+		password := rec.password
+		if password == "" {
+			password = opts.DefaultPassword
+		}
+		if password == "" {
+			fail(rec, "No password in the row and no default password set")
+			continue
+		}
+		if len(password) < 8 {
+			fail(rec, "Password must be at least 8 characters")
+			continue
+		}
+
+		if opts.DryRun {
+			result.Created++
+			continue
+		}
+
+		user, err := CreateUser(&CreateUserRequest{
+			Username: rec.username,
+			Email:    rec.email,
+			Password: password,
+			FullName: rec.fullName,
+			Role:     rec.role,
+		})
+		if err != nil {
+			fail(rec, errorMessage(err))
+			continue
+		}
+		result.Created++
+
+		if msg := addImportedUserToGroups(user, rec.groups, groupIDs); msg != "" {
+			result.Errors = append(result.Errors, RowError{Row: rec.row, Username: rec.username, Message: msg})
+		}
+
+		if opts.SendWelcomeEmail {
+			if err := alerts.GetService().SendWelcomeEmail(context.Background(), user.Email, user.Username); err != nil {
+				logger.Warn("Failed to send welcome email", zap.String("username", user.Username), zap.Error(err))
+				result.Errors = append(result.Errors, RowError{Row: rec.row, Username: rec.username, Message: "Welcome email not sent: " + err.Error()})
+			}
+		}
+	}
+
+	logger.Info("Bulk user import finished",
+		zap.Bool("dryRun", opts.DryRun),
+		zap.Int("created", result.Created),
+		zap.Int("updated", result.Updated),
+		zap.Int("skipped", result.Skipped),
+		zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+// validateImportRecord applies the checks of the create user API
+func validateImportRecord(rec importRecord) string {
+	switch {
+	case rec.username == "":
+		return "Username is required"
+	case len(rec.username) < 3 || len(rec.username) > 100:
+		return "Username must be between 3 and 100 characters"
+	case rec.email == "":
+		return "Email is required"
+	case !strings.Contains(rec.email, "@"):
+		return "Invalid email address"
+	case rec.role != "admin" && rec.role != "user" && rec.role != "guest":
+		return "Role must be one of: admin, user, guest"
+	}
+	return ""
+}
+
+// resolveImportGroups looks up group names, caching their IDs in ids
+func resolveImportGroups(names []string, ids map[string]uint) string {
+	for _, name := range names {
+		if _, ok := ids[name]; ok {
+			continue
+		}
+		group, err := usergroups.GetGroupByName(name)
+		if err != nil {
+			return fmt.Sprintf("Group %s not found", name)
+		}
+		ids[name] = group.ID
+	}
+	return ""
+}
+
+// updateImportedUser applies a row to an existing user. The password is only
+// changed if the row has one.
+func updateImportedUser(user *User, rec importRecord, groupIDs map[string]uint) string {
+	req := &UpdateUserRequest{
+		Email: &rec.email,
+		Role:  &rec.role,
+	}
+	if rec.fullName != "" {
+		req.FullName = &rec.fullName
+	}
+	if rec.password != "" {
+		if len(rec.password) < 8 {
+			return "Password must be at least 8 characters"
+		}
+		req.Password = &rec.password
+	}
+
+	updated, err := UpdateUser(user.ID, req)
+	if err != nil {
+		return errorMessage(err)
+	}
+	return addImportedUserToGroups(updated, rec.groups, groupIDs)
+}
+
+func addImportedUserToGroups(user *User, groups []string, groupIDs map[string]uint) string {
+	var failed []string
+	for _, name := range groups {
+		err := usergroups.AddMember(groupIDs[name], user.ID)
+		if appErr, ok := err.(*errors.AppError); ok && appErr.Code == http.StatusConflict {
+			continue // Already a member
+		}
+		if err != nil {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return "Failed to add user to groups: " + strings.Join(failed, ", ")
+	}
+	return ""
+}
+
+func errorMessage(err error) string {
+	if appErr, ok := err.(*errors.AppError); ok {
+		return appErr.Message
+	}
+	return err.Error()
+}
@@ -0,0 +1,143 @@
+package users
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	if err := logger.InitLogger("error", false); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "users.db")), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserGroup{}, &models.AuditLog{}, &models.AccessLog{}, &models.Share{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.User{Username: "admin", Email: "admin@example.com", PasswordHash: "x", Role: "admin", IsActive: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.UserGroup{Name: "staff"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	database.DB = db
+	sambaManager = &SambaUserManager{}
+	t.Cleanup(func() {
+		database.DB = nil
+		sambaManager = nil
+	})
+}
+
+func TestBulkImportCSV(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           BulkImportOptions
+		want           BulkImportResult
+		wantJaneDB     bool
+		wantAdminEmail string
+	}{
+		{name: "skip", opts: BulkImportOptions{}, want: BulkImportResult{Created: 1, Skipped: 1}, wantJaneDB: true, wantAdminEmail: "admin@example.com"},
+		{name: "error", opts: BulkImportOptions{OnConflict: OnConflictError}, want: BulkImportResult{Created: 1, Failed: 1}, wantJaneDB: true, wantAdminEmail: "admin@example.com"},
+		{name: "update", opts: BulkImportOptions{OnConflict: OnConflictUpdate}, want: BulkImportResult{Created: 1, Updated: 1}, wantJaneDB: true, wantAdminEmail: "admin2@example.com"},
+		{name: "dry run", opts: BulkImportOptions{DryRun: true}, want: BulkImportResult{Created: 1, Skipped: 1}, wantAdminEmail: "admin@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			f, err := os.Open("testdata/import.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			result, err := BulkImportCSV(f, tt.opts)
+			if err != nil {
+				t.Fatalf("BulkImportCSV() error = %v", err)
+			}
+			if result.Created != tt.want.Created || result.Updated != tt.want.Updated ||
+				result.Skipped != tt.want.Skipped || result.Failed != tt.want.Failed {
+				t.Errorf("BulkImportCSV() = %+v, want %+v", result, tt.want)
+			}
+			if result.Failed > 0 && (len(result.Errors) != 1 || result.Errors[0].Row != 3 || result.Errors[0].Username != "admin") {
+				t.Errorf("Errors = %+v, want one for row 3 (admin)", result.Errors)
+			}
+
+			jane, err := GetUserByUsername("jdoe")
+			if (err == nil) != tt.wantJaneDB {
+				t.Fatalf("GetUserByUsername(jdoe) error = %v, want user created: %v", err, tt.wantJaneDB)
+			}
+			if tt.wantJaneDB {
+				if jane.FullName != "Jane Doe" || !jane.CheckPassword("correct-horse") {
+					t.Errorf("imported user = %+v", jane)
+				}
+				var group models.UserGroup
+				database.DB.Preload("Members").First(&group, "name = ?", "staff")
+				if len(group.Members) != 1 || group.Members[0].Username != "jdoe" {
+					t.Errorf("staff members = %+v, want jdoe", group.Members)
+				}
+			}
+
+			if admin, _ := GetUserByUsername("admin"); admin.Email != tt.wantAdminEmail {
+				t.Errorf("admin email = %q, want %q", admin.Email, tt.wantAdminEmail)
+			}
+		})
+	}
+}
+
+func TestBulkImportLDIF(t *testing.T) {
+	setupTestDB(t)
+
+	ldif := `version: 1
+
+dn: ou=people,dc=example,dc=com
+objectClass: organizationalUnit
+ou: people
+
+# Jane
+dn: uid=jdoe,ou=people,dc=example,dc=com
+objectClass: inetOrgPerson
+uid: jdoe
+cn: Jane Doe
+mail: jdoe@exam
+ ple.com
+memberOf: cn=staff,ou=groups,dc=example,dc=com
+userPassword: {SSHA}aGFzaA==
+
+dn: uid=nomail,ou=people,dc=example,dc=com
+uid: nomail
+cn:: Tm8gTWFpbA==
+`
+	result, err := BulkImportLDIF(strings.NewReader(ldif), BulkImportOptions{DefaultPassword: "changeme-now"})
+	if err != nil {
+		t.Fatalf("BulkImportLDIF() error = %v", err)
+	}
+	if result.Created != 1 || result.Failed != 1 || result.Errors[0].Username != "nomail" {
+		t.Errorf("BulkImportLDIF() = %+v", result)
+	}
+
+	jane, err := GetUserByUsername("jdoe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jane.Email != "jdoe@example.com" || !jane.CheckPassword("changeme-now") {
+		t.Errorf("imported user = %+v", jane)
+	}
+}
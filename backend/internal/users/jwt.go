@@ -9,11 +9,18 @@ import (
 	"github.com/Stumpf-works/stumpfworks-nas/internal/config"
 )
 
+// ScopeTwoFASetup restricts a token to setting up two-factor authentication
+const ScopeTwoFASetup = "2fa_setup"
+
+// setupTokenLifetime is how long a 2FA setup token is valid
+const setupTokenLifetime = 15 * time.Minute
+
 // Claims represents JWT claims
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Scope    string `json:"scope,omitempty"` // Empty for unrestricted tokens
 	jwt.RegisteredClaims
 }
 
@@ -79,6 +86,37 @@ func GenerateRefreshToken(user *User) (string, error) {
 	return tokenString, nil
 }
 
+// GenerateSetupToken generates a short-lived token that only allows the
+// user to set up two-factor authentication
+func GenerateSetupToken(user *User) (string, error) {
+	cfg := config.GlobalConfig
+	if cfg == nil {
+		return "", fmt.Errorf("configuration not initialized")
+	}
+
+	claims := &Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		Scope:    ScopeTwoFASetup,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(setupTokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "stumpfworks-nas",
+			Subject:   fmt.Sprintf("%d", user.ID),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign setup token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
 // ValidateToken validates a JWT token and returns claims
 func ValidateToken(tokenString string) (*Claims, error) {
 	cfg := config.GlobalConfig
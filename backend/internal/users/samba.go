@@ -2,11 +2,13 @@
 package users
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
 	"go.uber.org/zap"
@@ -167,57 +169,28 @@ func (m *SambaUserManager) createLinuxUser(username string) error {
 	// This is a "system user" only for Samba authentication
 	useraddPath := sysutil.FindCommand("useradd")
 
-	// Retry logic for /etc/passwd lock contention
-	// Increased retries due to severe lock contention in production
-	maxRetries := 10
-	baseDelay := 150 * time.Millisecond
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 150ms, 300ms, 600ms, 1200ms, 2400ms, 4800ms, 9600ms, 19200ms, 38400ms
-			delay := baseDelay * time.Duration(1<<uint(attempt-1))
-			logger.Info("Retrying useradd after delay",
-				zap.String("username", username),
-				zap.Int("attempt", attempt+1),
-				zap.Duration("delay", delay))
-			time.Sleep(delay)
-		}
-
+	// Retry on /etc/passwd lock contention, which is severe in production
+	_, err := sysutil.RetryWithBackoff(context.Background(), passwdLockRetry("useradd", username), func() (struct{}, error) {
 		cmd = exec.Command(useraddPath,
-			"-M",                  // No home directory
-			"-s", "/bin/false",    // No shell access (security)
+			"-M",               // No home directory
+			"-s", "/bin/false", // No shell access (security)
 			"-c", "Stumpf.Works NAS User", // Comment
 			username)
 
 		output, err := cmd.CombinedOutput()
-		if err == nil {
-			logger.Info("Linux user created successfully",
-				zap.String("username", username),
-				zap.String("useradd_path", useraddPath),
-				zap.Int("attempts", attempt+1))
-			return nil
+		if err != nil {
+			return struct{}{}, fmt.Errorf("useradd failed: %s: %w", string(output), err)
 		}
-
-		// Check if error is due to /etc/passwd lock contention
-		outputStr := string(output)
-		isLockError := strings.Contains(outputStr, "konnte nicht gesperrt werden") ||
-			strings.Contains(outputStr, "cannot lock") ||
-			strings.Contains(outputStr, "unable to lock") ||
-			strings.Contains(outputStr, "temporarily unavailable")
-
-		// If it's not a lock error, or we're on the last attempt, return the error
-		if !isLockError || attempt == maxRetries-1 {
-			return fmt.Errorf("useradd failed: %s: %w", outputStr, err)
-		}
-
-		logger.Info("useradd lock contention detected, will retry",
-			zap.String("username", username),
-			zap.Int("attempt", attempt+1),
-			zap.Int("max_retries", maxRetries),
-			zap.String("error", outputStr))
+		return struct{}{}, nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return fmt.Errorf("useradd failed after %d attempts", maxRetries)
+	logger.Info("Linux user created successfully",
+		zap.String("username", username),
+		zap.String("useradd_path", useraddPath))
+	return nil
 }
 
 // deleteLinuxUser removes a Linux system user
@@ -235,6 +208,7 @@ func (m *SambaUserManager) deleteLinuxUser(username string) error {
 	if err != nil {
 		return fmt.Errorf("userdel failed: %s: %w", string(output), err)
 	}
+	database.Cached().InvalidateUser(username)
 
 	logger.Debug("Linux user deleted", zap.String("username", username))
 	return nil
@@ -244,23 +218,14 @@ func (m *SambaUserManager) deleteLinuxUser(username string) error {
 func (m *SambaUserManager) addSambaPassword(username, password string) error {
 	smbpasswdPath := sysutil.FindCommand("smbpasswd")
 
-	// Retry logic for /etc/passwd lock contention
-	// smbpasswd needs to read /etc/passwd to get user UID
-	// Increased retries due to severe lock contention in production
-	maxRetries := 10
-	baseDelay := 150 * time.Millisecond
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 150ms, 300ms, 600ms, 1200ms, 2400ms, 4800ms, 9600ms, 19200ms, 38400ms
-			delay := baseDelay * time.Duration(1<<uint(attempt-1))
-			logger.Info("Retrying smbpasswd after delay",
-				zap.String("username", username),
-				zap.Int("attempt", attempt+1),
-				zap.Duration("delay", delay))
-			time.Sleep(delay)
-		}
-
+	// Retry on /etc/passwd lock contention; smbpasswd needs to read
+	// /etc/passwd to get the user UID
+	retry := passwdLockRetry("smbpasswd", username)
+	retry.RetryIf = func(err error) bool {
+		return sysutil.RetryOnLockContention(err) ||
+			sysutil.RetryOnContains("passwd")(err) && sysutil.RetryOnContains("lock")(err)
+	}
+	_, err := sysutil.RetryWithBackoff(context.Background(), retry, func() (struct{}, error) {
 		// Use smbpasswd to set password
 		// -a = add user (or update if exists)
 		// -s = silent mode (read password from stdin)
@@ -270,35 +235,36 @@ func (m *SambaUserManager) addSambaPassword(username, password string) error {
 		cmd.Stdin = strings.NewReader(password + "\n" + password + "\n")
 
 		output, err := cmd.CombinedOutput()
-		if err == nil {
-			logger.Info("Samba password set successfully",
-				zap.String("username", username),
-				zap.String("smbpasswd_path", smbpasswdPath),
-				zap.Int("attempts", attempt+1))
-			return nil
+		if err != nil {
+			return struct{}{}, fmt.Errorf("%s: %w", string(output), err)
 		}
+		return struct{}{}, nil
+	})
+	if err != nil {
+		return fmt.Errorf("smbpasswd failed: %w", err)
+	}
 
-		// Check if error is due to /etc/passwd lock contention
-		outputStr := string(output)
-		isLockError := strings.Contains(outputStr, "konnte nicht gesperrt werden") ||
-			strings.Contains(outputStr, "cannot lock") ||
-			strings.Contains(outputStr, "unable to lock") ||
-			strings.Contains(outputStr, "temporarily unavailable") ||
-			strings.Contains(outputStr, "passwd") && strings.Contains(outputStr, "lock")
-
-		// If it's not a lock error, or we're on the last attempt, return the error
-		if !isLockError || attempt == maxRetries-1 {
-			return fmt.Errorf("smbpasswd failed: %s: %w", outputStr, err)
-		}
+	logger.Info("Samba password set successfully",
+		zap.String("username", username),
+		zap.String("smbpasswd_path", smbpasswdPath))
+	return nil
+}
 
-		logger.Info("smbpasswd lock contention detected, will retry",
-			zap.String("username", username),
-			zap.Int("attempt", attempt+1),
-			zap.Int("max_retries", maxRetries),
-			zap.String("error", outputStr))
+// passwdLockRetry retries a shadow utility command on lock contention: 10
+// attempts with delays from 150ms doubling up to 38.4s
+func passwdLockRetry(command, username string) sysutil.RetryOptions {
+	return sysutil.RetryOptions{
+		MaxAttempts:  10,
+		InitialDelay: 150 * time.Millisecond,
+		RetryIf:      sysutil.RetryOnLockContention,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			logger.Info(command+" lock contention detected, will retry",
+				zap.String("username", username),
+				zap.Int("attempt", attempt),
+				zap.Duration("delay", delay),
+				zap.Error(err))
+		},
 	}
-
-	return fmt.Errorf("smbpasswd failed after %d attempts", maxRetries)
 }
 
 // enableSambaUser enables a Samba user account
@@ -83,7 +83,7 @@ type CreateUserRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8"`
 	FullName string `json:"fullName"`
-	Role     string `json:"role" validate:"required,oneof=admin user guest"`
+	Role     string `json:"role" validate:"required,oneof=admin user guest" openapi:"summary=One of admin, user or guest"`
 }
 
 // CreateUser creates a new user
@@ -222,6 +222,12 @@ func DeleteUser(id uint) error {
 		return errors.InternalServerError("Failed to delete user", err)
 	}
 
+	if err := database.DB.Where("user_id = ?", user.ID).Delete(&SSHKey{}).Error; err != nil {
+		logger.Warn("Failed to delete SSH keys of user",
+			zap.String("username", user.Username),
+			zap.Error(err))
+	}
+
 	// Remove from Samba
 	sambaManager := GetSambaManager()
 	if err := sambaManager.DeleteSambaUser(user.Username); err != nil {
@@ -0,0 +1,371 @@
+package users
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database"
+	"github.com/Stumpf-works/stumpfworks-nas/internal/database/models"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/logger"
+	"github.com/Stumpf-works/stumpfworks-nas/pkg/sysutil"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// SSHKey is an alias for models.SSHKey for easier imports
+type SSHKey = models.SSHKey
+
+// sambaPrivateDB is the Samba AD database, used to sync keys to domain accounts
+const sambaPrivateDB = "/var/lib/samba/private/sam.ldb"
+
+// SSHKeyManager manages the authorized SSH keys of users' system accounts
+type SSHKeyManager struct {
+	db *gorm.DB
+	mu sync.Mutex // Serializes authorized_keys rewrites
+
+	// lookupUser resolves the system account of a user (os/user.Lookup)
+	lookupUser func(username string) (*user.User, error)
+}
+
+var sshKeyManager *SSHKeyManager
+
+// NewSSHKeyManager creates an SSH key manager that stores keys in db
+func NewSSHKeyManager(db *gorm.DB) *SSHKeyManager {
+	return &SSHKeyManager{db: db, lookupUser: user.Lookup}
+}
+
+// GetSSHKeyManager returns the global SSH key manager instance
+func GetSSHKeyManager() *SSHKeyManager {
+	if sshKeyManager == nil {
+		sshKeyManager = NewSSHKeyManager(database.GetDB())
+	}
+	return sshKeyManager
+}
+
+// AddKey validates an authorized_keys style public key, appends it to the
+// user's ~/.ssh/authorized_keys and records it. label defaults to the key's
+// comment.
+func (m *SSHKeyManager) AddKey(username string, keyData string, label string) (*SSHKey, error) {
+	pub, comment, err := ParseSSHPublicKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	var owner User
+	if err := m.db.Where("username = ?", username).First(&owner).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NotFound("User not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to query user", err)
+	}
+
+	if label == "" {
+		label = comment
+	}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" {
+		line += " " + comment
+	}
+
+	id, err := generateSSHKeyID()
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to generate key ID", err)
+	}
+	key := &SSHKey{
+		ID:          id,
+		UserID:      owner.ID,
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		Label:       label,
+		KeyType:     pub.Type(),
+		PublicKey:   line,
+	}
+
+	var count int64
+	m.db.Model(&SSHKey{}).Where("user_id = ? AND fingerprint = ?", owner.ID, key.Fingerprint).Count(&count)
+	if count > 0 {
+		return nil, errors.Conflict("SSH key already added for this user", nil)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.appendAuthorizedKey(username, line); err != nil {
+		return nil, errors.InternalServerError("Failed to update authorized_keys", err)
+	}
+	if err := m.db.Create(key).Error; err != nil {
+		if rmErr := m.removeAuthorizedKey(username, key.Fingerprint); rmErr != nil {
+			logger.Warn("Failed to roll back authorized_keys", zap.String("username", username), zap.Error(rmErr))
+		}
+		return nil, errors.InternalServerError("Failed to save SSH key", err)
+	}
+
+	m.syncSamba(username, "add", line)
+
+	logger.Info("SSH key added",
+		zap.String("username", username),
+		zap.String("fingerprint", key.Fingerprint),
+		zap.String("type", key.KeyType))
+	return key, nil
+}
+
+// ListKeys returns the SSH keys of a user
+func (m *SSHKeyManager) ListKeys(username string) ([]SSHKey, error) {
+	keys := []SSHKey{}
+	err := m.db.Joins("JOIN users ON users.id = ssh_keys.user_id").
+		Where("users.username = ?", username).
+		Order("ssh_keys.created_at").
+		Find(&keys).Error
+	if err != nil {
+		return nil, errors.InternalServerError("Failed to list SSH keys", err)
+	}
+	return keys, nil
+}
+
+// GetKey returns an SSH key by ID
+func (m *SSHKeyManager) GetKey(keyID string) (*SSHKey, error) {
+	var key SSHKey
+	if err := m.db.First(&key, "id = ?", keyID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.NotFound("SSH key not found", err)
+		}
+		return nil, errors.InternalServerError("Failed to query SSH key", err)
+	}
+	return &key, nil
+}
+
+// UpdateLabel changes the label of an SSH key
+func (m *SSHKeyManager) UpdateLabel(keyID, label string) (*SSHKey, error) {
+	key, err := m.GetKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.db.Model(key).Update("label", label).Error; err != nil {
+		return nil, errors.InternalServerError("Failed to update SSH key", err)
+	}
+	return key, nil
+}
+
+// DeleteKey removes an SSH key from the user's authorized_keys and the database
+func (m *SSHKeyManager) DeleteKey(keyID string) error {
+	key, err := m.GetKey(keyID)
+	if err != nil {
+		return err
+	}
+
+	var owner User
+	if err := m.db.Unscoped().First(&owner, key.UserID).Error; err != nil {
+		return errors.InternalServerError("Failed to query user", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.removeAuthorizedKey(owner.Username, key.Fingerprint); err != nil {
+		return errors.InternalServerError("Failed to update authorized_keys", err)
+	}
+	if err := m.db.Delete(key).Error; err != nil {
+		return errors.InternalServerError("Failed to delete SSH key", err)
+	}
+
+	m.syncSamba(owner.Username, "delete", key.PublicKey)
+
+	logger.Info("SSH key deleted", zap.String("username", owner.Username), zap.String("fingerprint", key.Fingerprint))
+	return nil
+}
+
+// ParseSSHPublicKey parses a single public key in authorized_keys format and
+// returns it with its comment. Key options (command=..., from=...) are not
+// accepted.
+func ParseSSHPublicKey(keyData string) (ssh.PublicKey, string, error) {
+	keyData = strings.TrimSpace(keyData)
+	if keyData == "" {
+		return nil, "", errors.BadRequest("SSH public key is required", nil)
+	}
+	if strings.Contains(keyData, "PRIVATE KEY") {
+		return nil, "", errors.BadRequest("This is a private key; paste the public key (the .pub file) instead", nil)
+	}
+
+	pub, comment, options, rest, err := ssh.ParseAuthorizedKey([]byte(keyData))
+	if err != nil {
+		return nil, "", errors.BadRequest(
+			"Invalid SSH public key: expected a single line like \"ssh-ed25519 AAAA... comment\"", err)
+	}
+	if len(options) > 0 {
+		return nil, "", errors.BadRequest("SSH key options are not supported: "+strings.Join(options, ","), nil)
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, "", errors.BadRequest("Only one SSH public key can be added at a time", nil)
+	}
+	return pub, comment, nil
+}
+
+// authorizedKeysPath returns ~/.ssh/authorized_keys of a system account
+// with the account's uid and gid
+func (m *SSHKeyManager) authorizedKeysPath(username string) (string, int, int, error) {
+	u, err := m.lookupUser(username)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("system account %s not found: %w", username, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid uid %q", u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid gid %q", u.Gid)
+	}
+	if u.HomeDir == "" || !filepath.IsAbs(u.HomeDir) {
+		return "", 0, 0, fmt.Errorf("system account %s has no home directory", username)
+	}
+	return filepath.Join(u.HomeDir, ".ssh", "authorized_keys"), uid, gid, nil
+}
+
+// appendAuthorizedKey adds a line to the user's authorized_keys, creating the
+// home and .ssh directories if needed (NAS accounts are created without a home)
+func (m *SSHKeyManager) appendAuthorizedKey(username, line string) error {
+	path, uid, gid, err := m.authorizedKeysPath(username)
+	if err != nil {
+		return err
+	}
+
+	// sshd's StrictModes requires the home and .ssh to not be writable by others
+	for _, dir := range []string{filepath.Dir(filepath.Dir(path)), filepath.Dir(path)} {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.Mkdir(dir, 0700); err != nil {
+				return err
+			}
+			if err := os.Chown(dir, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// removeAuthorizedKey removes every line with the given key from the user's
+// authorized_keys, keeping all other lines as they are
+func (m *SSHKeyManager) removeAuthorizedKey(username, fingerprint string) error {
+	path, uid, gid, err := m.authorizedKeysPath(username)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var kept bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line)); err == nil && ssh.FingerprintSHA256(pub) == fingerprint {
+			continue
+		}
+		kept.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".authorized_keys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(kept.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// syncSamba mirrors a key change to the sshPublicKey attribute of the
+// user's Samba AD account. This only works on a provisioned domain
+// controller whose schema includes sshPublicKey, so failures are logged
+// and otherwise ignored.
+func (m *SSHKeyManager) syncSamba(username, op, line string) {
+	if !sysutil.CommandExists("samba-tool") || !sysutil.CommandExists("ldbmodify") {
+		return
+	}
+	if _, err := os.Stat(sambaPrivateDB); err != nil {
+		return
+	}
+
+	out, err := sysutil.RunCommand("samba-tool", "user", "show", username, "--attributes=dn")
+	if err != nil {
+		logger.Debug("No Samba AD account for SSH key sync", zap.String("username", username))
+		return
+	}
+	dn := ""
+	for _, l := range strings.Split(out, "\n") {
+		if v, ok := strings.CutPrefix(l, "dn: "); ok {
+			dn = strings.TrimSpace(v)
+			break
+		}
+	}
+	if dn == "" {
+		return
+	}
+
+	ldif, err := os.CreateTemp("", "sshkey-*.ldif")
+	if err != nil {
+		logger.Warn("Failed to sync SSH key to Samba", zap.String("username", username), zap.Error(err))
+		return
+	}
+	defer os.Remove(ldif.Name())
+	fmt.Fprintf(ldif, "dn: %s\nchangetype: modify\n%s: sshPublicKey\nsshPublicKey: %s\n", dn, op, line)
+	ldif.Close()
+
+	if _, err := sysutil.RunCommand("ldbmodify", "-H", sambaPrivateDB, ldif.Name()); err != nil {
+		logger.Warn("Failed to sync SSH key to Samba (schema may lack sshPublicKey)",
+			zap.String("username", username), zap.Error(err))
+		return
+	}
+	logger.Info("SSH key synced to Samba AD", zap.String("username", username), zap.String("op", op))
+}
+
+// generateSSHKeyID returns a random hex key ID
+func generateSSHKeyID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -61,6 +62,11 @@ func (c *Client) Delete(endpoint string, result interface{}) error {
 
 // request performs an HTTP request
 func (c *Client) request(method, endpoint string, body interface{}, result interface{}) error {
+	return c.requestContext(context.Background(), c.HTTPClient, method, endpoint, body, result)
+}
+
+// requestContext performs an HTTP request with httpClient, cancelled with ctx
+func (c *Client) requestContext(ctx context.Context, httpClient *http.Client, method, endpoint string, body interface{}, result interface{}) error {
 	url := c.BaseURL + endpoint
 
 	var reqBody io.Reader
@@ -72,7 +78,7 @@ func (c *Client) request(method, endpoint string, body interface{}, result inter
 		reqBody = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequest(method, url, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -82,7 +88,7 @@ func (c *Client) request(method, endpoint string, body interface{}, result inter
 		req.Header.Set("Authorization", "Bearer "+c.Token)
 	}
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// BackupRun is the history entry of a backup job run
+type BackupRun struct {
+	ID          string     `json:"id"`
+	JobID       string     `json:"jobId"`
+	JobName     string     `json:"jobName"`
+	StartTime   time.Time  `json:"startTime"`
+	EndTime     *time.Time `json:"endTime,omitempty"`
+	Status      string     `json:"status"` // running, success, failed
+	BytesBackup int64      `json:"bytesBackup"`
+	FilesBackup int        `json:"filesBackup"`
+	Duration    int64      `json:"duration"` // seconds
+	Error       string     `json:"error,omitempty"`
+	BackupPath  string     `json:"backupPath"`
+}
+
+// RunBackupJob runs a backup job and waits for it to finish. Runs can take
+// far longer than the regular client timeout, so only ctx bounds the wait;
+// the run continues on the server if ctx is cancelled. Its progress is
+// published as "backup" events.
+func (c *Client) RunBackupJob(ctx context.Context, id string) (*BackupRun, error) {
+	var run BackupRun
+	httpClient := &http.Client{Transport: c.HTTPClient.Transport}
+	endpoint := fmt.Sprintf("/api/v1/backups/jobs/%s/run", url.PathEscape(id))
+	if err := c.requestContext(ctx, httpClient, "POST", endpoint, nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
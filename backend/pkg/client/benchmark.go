@@ -0,0 +1,27 @@
+package client
+
+import "time"
+
+// BenchmarkResult is one measurement of a system benchmark
+type BenchmarkResult struct {
+	ID        uint      `json:"id,omitempty"`
+	RunID     string    `json:"runId,omitempty"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Test      string    `json:"test"` // disk, network or cpu
+	Target    string    `json:"target"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Unit      string    `json:"unit"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+}
+
+// SaveBenchmarkResults stores the results of a benchmark run on the server
+// and returns them with their run ID
+func (c *Client) SaveBenchmarkResults(hostname string, results []BenchmarkResult) ([]BenchmarkResult, error) {
+	body := map[string]interface{}{"hostname": hostname, "results": results}
+	var saved []BenchmarkResult
+	if err := c.Post("/api/v1/system/benchmark/results", body, &saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a single event received from the /events/stream endpoint
+type Event struct {
+	ID        uint64                 `json:"id"`
+	Type      string                 `json:"type"`
+	Action    string                 `json:"action,omitempty"`
+	Severity  string                 `json:"severity"`
+	Source    string                 `json:"source,omitempty"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// EventStreamOptions controls which events are streamed
+type EventStreamOptions struct {
+	Types       []string // Event types to receive (empty = all)
+	Since       string   // Duration (e.g. "5m") or RFC3339 timestamp to replay from
+	LastEventID uint64   // Resume after this event ID (0 = no resume)
+}
+
+// Backoff computes exponential reconnect delays
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+
+	current time.Duration
+}
+
+// NewBackoff creates a backoff starting at 1s and capped at 30s
+func NewBackoff() *Backoff {
+	return &Backoff{
+		Initial:    time.Second,
+		Max:        30 * time.Second,
+		Multiplier: 2,
+	}
+}
+
+// Next returns the delay before the next attempt and advances the backoff
+func (b *Backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.Initial
+	} else {
+		b.current = time.Duration(float64(b.current) * b.Multiplier)
+	}
+	if b.Max > 0 && b.current > b.Max {
+		b.current = b.Max
+	}
+	return b.current
+}
+
+// Reset restarts the backoff from the initial delay
+func (b *Backoff) Reset() {
+	b.current = 0
+}
+
+// StreamEvents opens a single SSE connection and calls handle for each event.
+// It returns the number of events received once the stream ends.
+func (c *Client) StreamEvents(ctx context.Context, opts EventStreamOptions, handle func(Event)) (int, error) {
+	query := url.Values{}
+	if len(opts.Types) > 0 {
+		query.Set("type", strings.Join(opts.Types, ","))
+	}
+	if opts.Since != "" {
+		query.Set("since", opts.Since)
+	}
+
+	endpoint := c.BaseURL + "/api/v1/events/stream"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if opts.LastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(opts.LastEventID, 10))
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	// Streams are long-lived, so the regular client timeout must not apply
+	httpClient := &http.Client{Transport: c.HTTPClient.Transport}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	received := 0
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// Blank line terminates an event
+			if data.Len() > 0 {
+				var event Event
+				if err := json.Unmarshal([]byte(data.String()), &event); err == nil {
+					received++
+					handle(event)
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return received, fmt.Errorf("stream read failed: %w", err)
+	}
+	return received, nil
+}
+
+// WatchEvents streams events and reconnects with exponential backoff whenever
+// the connection drops. onReconnect (optional) is called before each wait.
+// It only returns once ctx is cancelled.
+func (c *Client) WatchEvents(ctx context.Context, opts EventStreamOptions, backoff *Backoff, handle func(Event), onReconnect func(delay time.Duration, err error)) error {
+	if backoff == nil {
+		backoff = NewBackoff()
+	}
+
+	for {
+		received, err := c.StreamEvents(ctx, opts, func(e Event) {
+			opts.LastEventID = e.ID
+			handle(e)
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A connection that delivered events was healthy; start over
+		if received > 0 {
+			backoff.Reset()
+		}
+		// Resume after the last seen event instead of replaying the window again
+		if opts.LastEventID > 0 {
+			opts.Since = ""
+		}
+
+		delay := backoff.Next()
+		if onReconnect != nil {
+			onReconnect(delay, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := &Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, want := range expected {
+		if got := b.Next(); got != want {
+			t.Errorf("Next() #%d = %v, want %v", i+1, got, want)
+		}
+	}
+
+	b.Reset()
+	if got := b.Next(); got != 100*time.Millisecond {
+		t.Errorf("Next() after Reset = %v, want %v", got, 100*time.Millisecond)
+	}
+}
+
+func TestWatchEventsReconnectBackoff(t *testing.T) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		// The first two connections drop immediately without delivering anything
+		if n <= 2 {
+			return
+		}
+
+		fmt.Fprint(w, "id: 1\nevent: alert\ndata: {\"id\":1,\"type\":\"alert\",\"severity\":\"critical\",\"message\":\"disk failed\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := NewClient(server.URL)
+	backoff := &Backoff{Initial: 10 * time.Millisecond, Max: time.Second, Multiplier: 2}
+
+	var delays []time.Duration
+	var received []Event
+
+	err := c.WatchEvents(ctx, EventStreamOptions{Types: []string{"alert"}}, backoff,
+		func(e Event) {
+			received = append(received, e)
+			cancel()
+		},
+		func(delay time.Duration, err error) {
+			delays = append(delays, delay)
+		},
+	)
+
+	if err != context.Canceled {
+		t.Fatalf("WatchEvents() error = %v, want %v", err, context.Canceled)
+	}
+
+	wantDelays := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}
+	if len(delays) != len(wantDelays) {
+		t.Fatalf("got %d reconnects (%v), want %d", len(delays), delays, len(wantDelays))
+	}
+	for i, want := range wantDelays {
+		if delays[i] != want {
+			t.Errorf("reconnect delay #%d = %v, want %v", i+1, delays[i], want)
+		}
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("server saw %d connections, want 3", got)
+	}
+	if len(received) != 1 || received[0].Severity != "critical" {
+		t.Errorf("received = %+v, want one critical alert", received)
+	}
+}
@@ -0,0 +1,75 @@
+package client
+
+import "time"
+
+// SystemMetric is a metrics sample from the /metrics/latest endpoint.
+// Disk and network values are aggregated across all disks and interfaces.
+type SystemMetric struct {
+	Timestamp time.Time `json:"timestamp"`
+
+	CPUUsage       float64 `json:"cpuUsage"`
+	CPULoadAvg1    float64 `json:"cpuLoadAvg1"`
+	CPULoadAvg5    float64 `json:"cpuLoadAvg5"`
+	CPULoadAvg15   float64 `json:"cpuLoadAvg15"`
+	CPUTemperature float64 `json:"cpuTemperature"`
+
+	MemoryUsedBytes  uint64  `json:"memoryUsedBytes"`
+	MemoryTotalBytes uint64  `json:"memoryTotalBytes"`
+	MemoryUsage      float64 `json:"memoryUsage"`
+	SwapUsedBytes    uint64  `json:"swapUsedBytes"`
+	SwapTotalBytes   uint64  `json:"swapTotalBytes"`
+	SwapUsage        float64 `json:"swapUsage"`
+
+	DiskUsedBytes        uint64  `json:"diskUsedBytes"`
+	DiskTotalBytes       uint64  `json:"diskTotalBytes"`
+	DiskUsage            float64 `json:"diskUsage"`
+	DiskReadBytesPerSec  uint64  `json:"diskReadBytesPerSec"`
+	DiskWriteBytesPerSec uint64  `json:"diskWriteBytesPerSec"`
+	DiskIOPS             uint64  `json:"diskIOPS"`
+
+	NetworkRxBytesPerSec   uint64 `json:"networkRxBytesPerSec"`
+	NetworkTxBytesPerSec   uint64 `json:"networkTxBytesPerSec"`
+	NetworkRxPacketsPerSec uint64 `json:"networkRxPacketsPerSec"`
+	NetworkTxPacketsPerSec uint64 `json:"networkTxPacketsPerSec"`
+
+	ProcessCount int `json:"processCount"`
+	ThreadCount  int `json:"threadCount"`
+}
+
+// RealtimeMetrics is a point-in-time reading from the /system/metrics
+// endpoint, with per-core CPU usage and per-mount disk usage
+type RealtimeMetrics struct {
+	CPU struct {
+		UsagePercent float64   `json:"usagePercent"`
+		PerCore      []float64 `json:"perCore,omitempty"`
+	} `json:"cpu"`
+	Disk []MountUsage `json:"disk"`
+}
+
+// MountUsage is the usage of a mounted filesystem
+type MountUsage struct {
+	Device      string  `json:"device"`
+	Mountpoint  string  `json:"mountpoint"`
+	Fstype      string  `json:"fstype"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+// GetLatestMetric retrieves the most recent collected metrics sample
+func (c *Client) GetLatestMetric() (*SystemMetric, error) {
+	var metric SystemMetric
+	if err := c.Get("/api/v1/metrics/latest", &metric); err != nil {
+		return nil, err
+	}
+	return &metric, nil
+}
+
+// GetRealtimeMetrics retrieves per-core CPU and per-mount disk usage
+func (c *Client) GetRealtimeMetrics() (*RealtimeMetrics, error) {
+	var metrics RealtimeMetrics
+	if err := c.Get("/api/v1/system/metrics", &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
@@ -76,3 +76,13 @@ func ValidationError(message string, err error) *AppError {
 func InsufficientStorage(message string, err error) *AppError {
 	return NewAppError(http.StatusInsufficientStorage, message, err)
 }
+
+// PayloadTooLarge creates a 413 error
+func PayloadTooLarge(message string, err error) *AppError {
+	return NewAppError(http.StatusRequestEntityTooLarge, message, err)
+}
+
+// TooManyRequests creates a 429 error
+func TooManyRequests(message string, err error) *AppError {
+	return NewAppError(http.StatusTooManyRequests, message, err)
+}
@@ -2,6 +2,7 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	"go.uber.org/zap"
@@ -10,6 +11,9 @@ import (
 
 var Log *zap.Logger
 
+// atomicLevel is the active log level; it can be changed at runtime via SetLevel
+var atomicLevel = zap.NewAtomicLevel()
+
 // InitLogger initializes the global logger with the specified level
 func InitLogger(level string, isDevelopment bool) error {
 	var config zap.Config
@@ -28,7 +32,8 @@ func InitLogger(level string, isDevelopment bool) error {
 	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
 		zapLevel = zapcore.InfoLevel
 	}
-	config.Level = zap.NewAtomicLevelAt(zapLevel)
+	atomicLevel.SetLevel(zapLevel)
+	config.Level = atomicLevel
 
 	// Build logger
 	logger, err := config.Build(
@@ -45,6 +50,21 @@ func InitLogger(level string, isDevelopment bool) error {
 	return nil
 }
 
+// SetLevel changes the log level of the running logger
+func SetLevel(levelName string) error {
+	var zapLevel zapcore.Level
+	if err := zapLevel.UnmarshalText([]byte(levelName)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", levelName, err)
+	}
+	atomicLevel.SetLevel(zapLevel)
+	return nil
+}
+
+// GetLevel returns the current log level
+func GetLevel() string {
+	return atomicLevel.Level().String()
+}
+
 // Sync flushes any buffered log entries
 func Sync() {
 	if Log != nil {
@@ -2,59 +2,107 @@
 package sysutil
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 )
 
+// DefaultSearchPaths are the directories searched for commands that are not
+// in $PATH. Admin tools often live in sbin directories, which are missing
+// from $PATH for non-root users.
+var DefaultSearchPaths = []string{
+	"/usr/sbin",       // System administration binaries (primary)
+	"/sbin",           // Essential system binaries
+	"/usr/bin",        // User binaries
+	"/bin",            // Essential command binaries
+	"/usr/local/sbin", // Locally installed system binaries
+	"/usr/local/bin",  // Locally installed user binaries
+}
+
+// commandPackages names the package providing a command where it differs
+// from the command name, for installation hints
+var commandPackages = map[string]string{
+	"samba-tool": "samba",
+	"smbd":       "samba",
+	"smbpasswd":  "samba",
+	"pdbedit":    "samba",
+	"exportfs":   "nfs-kernel-server",
+	"setfacl":    "acl",
+	"getfacl":    "acl",
+	"setquota":   "quota",
+	"repquota":   "quota",
+	"smartctl":   "smartmontools",
+	"lvs":        "lvm2",
+	"lvcreate":   "lvm2",
+	"zfs":        "zfsutils-linux",
+	"zpool":      "zfsutils-linux",
+	"rg":         "ripgrep",
+	"targetcli":  "targetcli-fb",
+	"drbdadm":    "drbd-utils",
+	"virsh":      "libvirt-clients",
+	"useradd":    "passwd",
+	"ldbmodify":  "ldb-tools",
+}
+
 // FindCommand searches for a command in common system paths
 // Returns the full path to the executable if found, otherwise returns the original name
 //
 // Search order:
 //  1. exec.LookPath() - checks $PATH environment variable
-//  2. Common system paths: /usr/sbin, /sbin, /usr/bin, /bin, /usr/local/sbin, /usr/local/bin
+//  2. DefaultSearchPaths: /usr/sbin, /sbin, /usr/bin, /bin, /usr/local/sbin, /usr/local/bin
 //
 // This is useful for finding system administration tools that may not be in $PATH
 // for non-root users (e.g., useradd, userdel, smbpasswd, pdbedit)
 func FindCommand(name string) string {
-	// First try exec.LookPath (searches in PATH)
-	if path, err := exec.LookPath(name); err == nil {
+	if path, err := FindCommandInPaths(name, DefaultSearchPaths); err == nil {
 		return path
 	}
 
-	// Common system paths where admin tools are located
-	systemPaths := []string{
-		"/usr/sbin",      // System administration binaries (primary)
-		"/sbin",          // Essential system binaries
-		"/usr/bin",       // User binaries
-		"/bin",           // Essential command binaries
-		"/usr/local/sbin", // Locally installed system binaries
-		"/usr/local/bin",  // Locally installed user binaries
+	// Return original name as fallback - will fail with proper error message
+	return name
+}
+
+// FindCommandInPaths returns the full path of a command found in $PATH or
+// in one of paths
+func FindCommandInPaths(name string, paths []string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
 	}
 
-	for _, dir := range systemPaths {
+	for _, dir := range paths {
 		fullPath := filepath.Join(dir, name)
-		if info, err := os.Stat(fullPath); err == nil {
-			// Check if executable (has any execute bit set)
-			if info.Mode()&0111 != 0 {
-				return fullPath
-			}
+		if info, err := os.Stat(fullPath); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return fullPath, nil
 		}
 	}
 
-	// Return original name as fallback - will fail with proper error message
-	return name
+	return "", fmt.Errorf("%s: %w", name, ErrCommandNotFound)
 }
 
-// CommandExists checks if a command exists and is executable
+// CommandExists checks if a command exists and is executable, in $PATH or
+// in DefaultSearchPaths
 func CommandExists(name string) bool {
-	path := FindCommand(name)
-	if path == name {
-		// FindCommand returned original name, try exec.LookPath
-		_, err := exec.LookPath(name)
-		return err == nil
+	return CommandExistsInPaths(name, DefaultSearchPaths)
+}
+
+// CommandExistsInPaths checks if a command exists and is executable, in
+// $PATH or in one of paths
+func CommandExistsInPaths(name string, paths []string) bool {
+	_, err := FindCommandInPaths(name, paths)
+	return err == nil
+}
+
+// RequireCommand returns the full path of a command, or an error naming the
+// package to install
+func RequireCommand(name string) (string, error) {
+	path, err := FindCommandInPaths(name, DefaultSearchPaths)
+	if err != nil {
+		pkg, ok := commandPackages[name]
+		if !ok {
+			pkg = name
+		}
+		return "", fmt.Errorf("%s not found; install %s package: %w", name, pkg, ErrCommandNotFound)
 	}
-	// FindCommand found full path
-	info, err := os.Stat(path)
-	return err == nil && info.Mode()&0111 != 0
+	return path, nil
 }
@@ -0,0 +1,65 @@
+package sysutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandExistsInPaths(t *testing.T) {
+	// Stand-in for /usr/local/sbin, which is usually not in $PATH
+	localSbin := t.TempDir()
+	name := "stumpfworks-test-tool"
+	if err := os.WriteFile(filepath.Join(localSbin, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(localSbin, "not-executable"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", t.TempDir())
+
+	tests := []struct {
+		name    string
+		command string
+		paths   []string
+		want    bool
+	}{
+		{"nonexistent", "stumpfworks-no-such-command", DefaultSearchPaths, false},
+		{"only in search path", name, []string{"/nonexistent", localSbin}, true},
+		{"search path not given", name, DefaultSearchPaths, false},
+		{"not executable", "not-executable", []string{localSbin}, false},
+		{"directory", filepath.Base(localSbin), []string{filepath.Dir(localSbin)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CommandExistsInPaths(tt.command, tt.paths); got != tt.want {
+				t.Errorf("CommandExistsInPaths(%q, %v) = %v, want %v", tt.command, tt.paths, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("found through PATH", func(t *testing.T) {
+		t.Setenv("PATH", localSbin)
+		if !CommandExists(name) {
+			t.Errorf("CommandExists(%q) = false with the command in PATH", name)
+		}
+	})
+}
+
+func TestRequireCommand(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := RequireCommand("stumpfworks-no-such-command")
+	if err == nil || !strings.Contains(err.Error(), "install stumpfworks-no-such-command package") || !errors.Is(err, ErrCommandNotFound) {
+		t.Errorf("RequireCommand() error = %v, want package hint", err)
+	}
+
+	commandPackages["stumpfworks-no-such-command"] = "stumpfworks-tools"
+	defer delete(commandPackages, "stumpfworks-no-such-command")
+	if _, err := RequireCommand("stumpfworks-no-such-command"); err == nil || !strings.Contains(err.Error(), "install stumpfworks-tools package") {
+		t.Errorf("RequireCommand() error = %v, want package hint", err)
+	}
+}
@@ -0,0 +1,276 @@
+package sysutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons for a FileDiff
+const (
+	DiffReasonSize        = "size"
+	DiffReasonChecksum    = "checksum"
+	DiffReasonPermissions = "permissions"
+	DiffReasonModTime     = "modtime"
+	DiffReasonType        = "type"   // File in one tree, directory or symlink in the other
+	DiffReasonTarget      = "target" // Symlinks pointing to different targets
+)
+
+// CompareOptions controls what CompareDirectories compares
+type CompareOptions struct {
+	Checksum          bool     // Compare the content of files of equal size
+	IgnorePermissions bool     // Don't compare permission bits
+	IgnoreTimestamps  bool     // Don't compare file modification times
+	ExcludePatterns   []string // Glob patterns of names or relative paths to skip
+}
+
+// DirectoryDiff is the difference between two directory trees. Paths are
+// relative to the tree roots; a directory only in one tree is listed
+// without its contents.
+type DirectoryDiff struct {
+	OnlyInSrc     []string   `json:"onlyInSrc"`
+	OnlyInDst     []string   `json:"onlyInDst"`
+	Modified      []FileDiff `json:"modified"`
+	TotalCompared int        `json:"totalCompared"` // Paths present in both trees
+}
+
+// FileDiff is a path that differs between two directory trees
+type FileDiff struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Identical reports whether the trees had no differences
+func (d *DirectoryDiff) Identical() bool {
+	return len(d.OnlyInSrc) == 0 && len(d.OnlyInDst) == 0 && len(d.Modified) == 0
+}
+
+// CompareDirectories compares the directory trees at src and dst. Files
+// are checksummed concurrently, at most one per CPU at a time.
+func CompareDirectories(src, dst string, opts CompareOptions) (*DirectoryDiff, error) {
+	srcEntries, err := scanTree(src, opts.ExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	dstEntries, err := scanTree(dst, opts.ExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &DirectoryDiff{
+		OnlyInSrc: onlyIn(srcEntries, dstEntries),
+		OnlyInDst: onlyIn(dstEntries, srcEntries),
+		Modified:  []FileDiff{},
+	}
+
+	// Metadata differences, reported if the content turns out equal
+	pending := make(map[string]string)
+	var checksums []string
+	for rel, s := range srcEntries {
+		d, ok := dstEntries[rel]
+		if !ok {
+			continue
+		}
+		diff.TotalCompared++
+
+		if s.Mode().Type() != d.Mode().Type() {
+			diff.Modified = append(diff.Modified, FileDiff{Path: rel, Reason: DiffReasonType})
+			continue
+		}
+
+		switch {
+		case s.Mode()&fs.ModeSymlink != 0:
+			sTarget, err := os.Readlink(filepath.Join(src, rel))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read symlink: %w", err)
+			}
+			dTarget, err := os.Readlink(filepath.Join(dst, rel))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read symlink: %w", err)
+			}
+			if sTarget != dTarget {
+				diff.Modified = append(diff.Modified, FileDiff{Path: rel, Reason: DiffReasonTarget})
+			}
+			continue
+		case s.Mode().IsRegular() && s.Size() != d.Size():
+			diff.Modified = append(diff.Modified, FileDiff{Path: rel, Reason: DiffReasonSize})
+			continue
+		}
+
+		// Directory timestamps change with their contents and are not compared
+		if !opts.IgnoreTimestamps && s.Mode().IsRegular() &&
+			!s.ModTime().Truncate(time.Second).Equal(d.ModTime().Truncate(time.Second)) {
+			pending[rel] = DiffReasonModTime
+		}
+		const permBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+		if !opts.IgnorePermissions && s.Mode()&permBits != d.Mode()&permBits {
+			pending[rel] = DiffReasonPermissions
+		}
+		if opts.Checksum && s.Mode().IsRegular() {
+			checksums = append(checksums, rel)
+		}
+	}
+
+	mismatched, err := compareChecksums(src, dst, checksums)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range mismatched {
+		pending[rel] = DiffReasonChecksum
+	}
+	for rel, reason := range pending {
+		diff.Modified = append(diff.Modified, FileDiff{Path: rel, Reason: reason})
+	}
+
+	sort.Slice(diff.Modified, func(i, j int) bool { return diff.Modified[i].Path < diff.Modified[j].Path })
+	return diff, nil
+}
+
+// scanTree returns the entries below root by relative path, skipping
+// excluded names and paths
+func scanTree(root string, exclude []string) (map[string]fs.FileInfo, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", root)
+	}
+
+	entries := make(map[string]fs.FileInfo)
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if isExcluded(rel, exclude) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		entries[rel] = info
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return entries, nil
+}
+
+// isExcluded reports whether a relative path or its name matches one of
+// the patterns
+func isExcluded(rel string, patterns []string) bool {
+	name := filepath.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyIn returns the sorted paths of a that are missing from b, leaving
+// out the contents of missing directories
+func onlyIn(a, b map[string]fs.FileInfo) []string {
+	var missing []string
+	for rel := range a {
+		if _, ok := b[rel]; !ok {
+			missing = append(missing, rel)
+		}
+	}
+	// Parents sort before their contents
+	sort.Strings(missing)
+
+	listed := make(map[string]bool)
+	paths := []string{}
+	for _, rel := range missing {
+		listed[rel] = true
+		if listed[filepath.Dir(rel)] {
+			continue
+		}
+		paths = append(paths, rel)
+	}
+	return paths
+}
+
+// compareChecksums checksums the files at the relative paths in both trees
+// concurrently and returns the paths whose content differs
+func compareChecksums(src, dst string, paths []string) ([]string, error) {
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		mismatched []string
+		firstErr   error
+	)
+	sem := make(chan struct{}, runtime.NumCPU())
+
+	for _, rel := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(rel string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			srcSum, err := fileChecksum(filepath.Join(src, rel))
+			var dstSum []byte
+			if err == nil {
+				dstSum, err = fileChecksum(filepath.Join(dst, rel))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			if !bytes.Equal(srcSum, dstSum) {
+				mismatched = append(mismatched, rel)
+			}
+		}(rel)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return mismatched, nil
+}
+
+// fileChecksum returns the SHA-256 digest of a file
+func fileChecksum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for checksum: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,104 @@
+package sysutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string, modes map[string]os.FileMode) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		mode := os.FileMode(0644)
+		if m, ok := modes[rel]; ok {
+			mode = m
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompareDirectories(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTree(t, src, map[string]string{
+		"same.txt":             "unchanged",
+		"docs/size.txt":        "abc",
+		"docs/content.txt":     "aaaa",
+		"bin/run.sh":           "#!/bin/sh",
+		"only-src.txt":         "x",
+		"archive/2024/jan.tar": "x",
+		"archive/2024/feb.tar": "x",
+		"cache/skipped.tmp":    "x",
+		"docs/notes.swp":       "x",
+	}, nil)
+	writeTree(t, dst, map[string]string{
+		"same.txt":         "unchanged",
+		"docs/size.txt":    "abcd",
+		"docs/content.txt": "bbbb",
+		"bin/run.sh":       "#!/bin/sh",
+		"only-dst.txt":     "y",
+	}, map[string]os.FileMode{"bin/run.sh": 0755})
+
+	diff, err := CompareDirectories(src, dst, CompareOptions{
+		Checksum:         true,
+		IgnoreTimestamps: true,
+		ExcludePatterns:  []string{"*.swp", "cache"},
+	})
+	if err != nil {
+		t.Fatalf("CompareDirectories() error = %v", err)
+	}
+
+	if want := []string{"archive", "only-src.txt"}; !reflect.DeepEqual(diff.OnlyInSrc, want) {
+		t.Errorf("OnlyInSrc = %v, want %v", diff.OnlyInSrc, want)
+	}
+	if want := []string{"only-dst.txt"}; !reflect.DeepEqual(diff.OnlyInDst, want) {
+		t.Errorf("OnlyInDst = %v, want %v", diff.OnlyInDst, want)
+	}
+	wantModified := []FileDiff{
+		{Path: "bin/run.sh", Reason: DiffReasonPermissions},
+		{Path: "docs/content.txt", Reason: DiffReasonChecksum},
+		{Path: "docs/size.txt", Reason: DiffReasonSize},
+	}
+	if !reflect.DeepEqual(diff.Modified, wantModified) {
+		t.Errorf("Modified = %v, want %v", diff.Modified, wantModified)
+	}
+	// same.txt, bin, bin/run.sh, docs and its two files
+	if diff.TotalCompared != 6 {
+		t.Errorf("TotalCompared = %d, want 6", diff.TotalCompared)
+	}
+
+	// Without checksums and permissions only the size difference is found
+	diff, err = CompareDirectories(src, dst, CompareOptions{IgnorePermissions: true, IgnoreTimestamps: true})
+	if err != nil {
+		t.Fatalf("CompareDirectories() error = %v", err)
+	}
+	if want := []FileDiff{{Path: "docs/size.txt", Reason: DiffReasonSize}}; !reflect.DeepEqual(diff.Modified, want) {
+		t.Errorf("Modified without checksums = %v, want %v", diff.Modified, want)
+	}
+}
+
+func TestCompareDirectoriesIdenticalCopy(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"a.txt": "a", "sub/b.txt": "b"}, nil)
+	dst := filepath.Join(t.TempDir(), "copy")
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatalf("CopyDir() error = %v", err)
+	}
+
+	diff, err := CompareDirectories(src, dst, CompareOptions{Checksum: true, IgnoreTimestamps: true})
+	if err != nil {
+		t.Fatalf("CompareDirectories() error = %v", err)
+	}
+	if !diff.Identical() {
+		t.Errorf("copy differs from source: %+v", diff)
+	}
+}
@@ -8,18 +8,34 @@
 // Key Features:
 //
 // Command Execution:
-//   - Command discovery in system paths (FindCommand)
-//   - Simplified command execution (RunCommand, RunCommandQuiet, RunCommandWithInput)
+//   - Command discovery in system paths (FindCommand, FindCommandInPaths)
+//   - Command availability checks (CommandExists, CommandExistsInPaths, RequireCommand)
+//   - Simplified command execution (RunCommand, RunCommandQuiet, RunCommandWithInput,
+//     RunCommandWithProgress)
+//   - Cancellable command execution (RunCommandWithContext, RunCommandStream,
+//     CommandError)
+//
+// Process Management:
+//   - PID checks and signalling (ProcessExists, KillProcess, WaitForProcess)
+//   - Process lookup by name (FindProcessByName)
+//   - Process details from /proc (GetProcessInfo)
+//
+// Retrying:
+//   - Retries with exponential backoff (RetryWithBackoff)
+//   - Retry predicates (RetryOnAny, RetryOnExitCode, RetryOnContains, RetryOnLockContention)
 //
 // Privilege and Security:
 //   - Root privilege checking (IsRoot, RequireRoot)
 //   - Path sanitization and validation (SanitizePath, SanitizeFilename, SafeJoin)
 //   - Path traversal detection (IsPathTraversal)
+//   - Shell argument quoting (SanitizeShellArg, SanitizeShellCommand)
 //
 // File Operations:
 //   - File/directory existence checks (FileExists, DirExists, IsExecutable)
 //   - File copying and moving (CopyFile, CopyDir, MoveFile, MoveDir)
+//   - Directory tree comparison (CompareDirectories)
 //   - Sysfs file reading helpers (ReadSysFile)
+//   - File change notification by polling (WatchFile)
 //
 // User and Group Management:
 //   - UID/GID lookups by name (LookupUID, LookupGID)
@@ -28,7 +44,7 @@
 //
 // Network Utilities:
 //   - IP address validation (ValidateIP, ValidateIPv4, ValidateIPv6)
-//   - CIDR notation validation (ValidateCIDR)
+//   - CIDR notation validation (ValidateCIDR, ValidateIPv6CIDR)
 //   - Private/Loopback IP detection (IsPrivateIP, IsLoopbackIP)
 //   - Hostname validation (IsValidHostname)
 //
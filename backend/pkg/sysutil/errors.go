@@ -12,4 +12,10 @@ var (
 
 	// ErrPathTraversal is returned when a path traversal attempt is detected
 	ErrPathTraversal = errors.New("path traversal attempt detected")
+
+	// ErrUnsafeShellArg is returned for shell arguments containing null bytes or line breaks
+	ErrUnsafeShellArg = errors.New("shell argument contains a null byte or line break")
+
+	// ErrProcessNotFound is returned when no process has the given PID
+	ErrProcessNotFound = errors.New("no such process")
 )
@@ -2,10 +2,49 @@
 package sysutil
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"strings"
+	"time"
 )
 
+// commandWaitDelay is how long RunCommandWithContext waits for the output
+// of a killed command, which children it started may still hold open
+const commandWaitDelay = 5 * time.Second
+
+// CommandError is returned by RunCommandWithContext when a command fails.
+// Err is the context's error if the command was cancelled, otherwise the
+// error of the command, so errors.Is(err, context.Canceled) tells why it
+// failed.
+type CommandError struct {
+	Command  string // Name of the command
+	Output   string // Combined output up to the failure, empty if streamed
+	ExitCode int    // -1 if the command did not exit on its own
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("%s failed: %v", e.Command, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %s: %v", e.Command, e.Output, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Cancelled reports whether the command was killed because its context was
+// cancelled or timed out
+func (e *CommandError) Cancelled() bool {
+	return errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded)
+}
+
 // RunCommand executes a command and returns its combined output
 // Automatically finds the command using FindCommand()
 func RunCommand(name string, args ...string) (string, error) {
@@ -18,6 +57,46 @@ func RunCommand(name string, args ...string) (string, error) {
 	return string(output), nil
 }
 
+// RunCommandWithContext is RunCommand for a command that is killed when ctx
+// is cancelled. Failures are returned as *CommandError.
+func RunCommandWithContext(ctx context.Context, name string, args ...string) (string, error) {
+	cmdPath := FindCommand(name)
+	cmd := exec.CommandContext(ctx, cmdPath, args...)
+	cmd.WaitDelay = commandWaitDelay
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", newCommandError(ctx, name, string(output), err)
+	}
+	return string(output), nil
+}
+
+// RunCommandStream runs a command, copying its stdout and stderr to the
+// writers as it writes them instead of buffering the output; a nil writer
+// discards the stream. The command is killed when ctx is cancelled.
+// Failures are returned as *CommandError.
+func RunCommandStream(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	cmdPath := FindCommand(name)
+	cmd := exec.CommandContext(ctx, cmdPath, args...)
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return newCommandError(ctx, name, "", err)
+	}
+	return nil
+}
+
+// newCommandError wraps the error of a command run with ctx
+func newCommandError(ctx context.Context, name, output string, err error) *CommandError {
+	cmdErr := &CommandError{Command: name, Output: output, ExitCode: -1, Err: err}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		cmdErr.Err = ctxErr
+	} else if exitErr, ok := err.(*exec.ExitError); ok {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	return cmdErr
+}
+
 // RunCommandQuiet executes a command and only returns error
 // Use this when you don't need the output
 func RunCommandQuiet(name string, args ...string) error {
@@ -48,3 +127,80 @@ func RunCommandWithInput(input, name string, args ...string) (string, error) {
 	}
 	return string(output), nil
 }
+
+// RunCommandWithProgress executes a command and returns its stdout. Each line
+// the command writes to stderr is passed to onProgress as it arrives; lines
+// may end with a carriage return, as progress meters redrawing one line do.
+// The command is killed when ctx is cancelled.
+func RunCommandWithProgress(ctx context.Context, onProgress func(line string), name string, args ...string) (string, error) {
+	return runWithProgress(ctx, onProgress, false, name, args...)
+}
+
+// RunCommandWithStdoutProgress is RunCommandWithProgress for commands that
+// report progress on stdout, such as rsync. It returns what the command
+// wrote to stderr.
+func RunCommandWithStdoutProgress(ctx context.Context, onProgress func(line string), name string, args ...string) (string, error) {
+	return runWithProgress(ctx, onProgress, true, name, args...)
+}
+
+// runWithProgress runs a command, passing the lines of stdout or stderr to
+// onProgress and returning the other stream
+func runWithProgress(ctx context.Context, onProgress func(line string), fromStdout bool, name string, args ...string) (string, error) {
+	cmdPath := FindCommand(name)
+	cmd := exec.CommandContext(ctx, cmdPath, args...)
+
+	var output bytes.Buffer
+	var progress io.ReadCloser
+	var err error
+	if fromStdout {
+		cmd.Stderr = &output
+		progress, err = cmd.StdoutPipe()
+	} else {
+		cmd.Stdout = &output
+		progress, err = cmd.StderrPipe()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create output pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("%s failed to start: %w", name, err)
+	}
+
+	// Keep the last lines for the error message
+	var lastLines []string
+	scanner := bufio.NewScanner(progress)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if onProgress != nil {
+			onProgress(line)
+		}
+		if lastLines = append(lastLines, line); len(lastLines) > 5 {
+			lastLines = lastLines[1:]
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if fromStdout {
+			// The error is on stderr, not among the progress lines
+			return output.String(), fmt.Errorf("%s failed: %s: %w", name, strings.TrimSpace(output.String()), err)
+		}
+		return output.String(), fmt.Errorf("%s failed: %s: %w", name, strings.Join(lastLines, "; "), err)
+	}
+	return output.String(), nil
+}
+
+// scanProgressLines is a bufio.SplitFunc splitting on \n and \r
+func scanProgressLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,88 @@
+package sysutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommandWithContext(t *testing.T) {
+	output, err := RunCommandWithContext(context.Background(), "sh", "-c", "echo hello")
+	if err != nil || output != "hello\n" {
+		t.Errorf("RunCommandWithContext = %q, %v, want hello", output, err)
+	}
+
+	_, err = RunCommandWithContext(context.Background(), "sh", "-c", "echo no such pool >&2; exit 3")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("error = %v, want a *CommandError", err)
+	}
+	if cmdErr.ExitCode != 3 || cmdErr.Cancelled() || cmdErr.Output != "no such pool\n" {
+		t.Errorf("error = %+v, want exit code 3 with the output", cmdErr)
+	}
+	if !strings.Contains(err.Error(), "sh failed: no such pool") {
+		t.Errorf("message = %q", err.Error())
+	}
+}
+
+func TestRunCommandWithContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := RunCommandWithContext(ctx, "sleep", "10")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command not killed on cancellation, took %s", elapsed)
+	}
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || !cmdErr.Cancelled() || cmdErr.ExitCode != -1 {
+		t.Fatalf("error = %#v, want a cancelled *CommandError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is(%v, context.DeadlineExceeded) = false", err)
+	}
+}
+
+// lineWriter records the writes it receives
+type lineWriter struct {
+	writes []string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestRunCommandStream(t *testing.T) {
+	var stdout, stderr lineWriter
+	err := RunCommandStream(context.Background(), "sh", []string{"-c", "echo sent 1; sleep 0.1; echo sent 2; echo warning >&2"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("RunCommandStream: %v", err)
+	}
+	// The lines arrive as the command writes them, not at exit
+	if len(stdout.writes) != 2 || stdout.writes[0] != "sent 1\n" || stdout.writes[1] != "sent 2\n" {
+		t.Errorf("stdout writes = %q", stdout.writes)
+	}
+	if strings.Join(stderr.writes, "") != "warning\n" {
+		t.Errorf("stderr writes = %q", stderr.writes)
+	}
+
+	err = RunCommandStream(context.Background(), "sh", []string{"-c", "exit 23"}, nil, nil)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != 23 || cmdErr.Cancelled() {
+		t.Fatalf("error = %#v, want a *CommandError with exit code 23", err)
+	}
+	if err.Error() != "sh failed: exit status 23" {
+		t.Errorf("message = %q", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = RunCommandStream(ctx, "sleep", []string{"10"}, nil, nil)
+	if !errors.As(err, &cmdErr) || !cmdErr.Cancelled() || !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %#v, want a cancelled *CommandError", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"syscall"
 )
 
 // CopyFile copies a file from src to dst
@@ -44,6 +45,70 @@ func CopyFile(src, dst string) error {
 	return nil
 }
 
+// AtomicWriteFile writes data to a temporary file next to path, syncs it
+// and renames it over path, so a crash mid-write leaves either the old or
+// the new file, never a truncated one. If path exists, its permissions and
+// ownership are kept and perm is ignored. A symlink is followed and its
+// target replaced, as os.WriteFile would write through it.
+func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	uid, gid := -1, -1
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", tmpPath, err)
+	}
+	if uid >= 0 {
+		if err := tmp.Chown(uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of %s: %w", tmpPath, err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	committed = true
+
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
 // MoveFile moves a file from src to dst
 // Tries rename first, falls back to copy+delete if across filesystems
 func MoveFile(src, dst string) error {
@@ -111,8 +176,10 @@ func CopyDir(src, dst string) error {
 }
 
 // MoveDir moves a directory from src to dst
-// Tries rename first, falls back to copy+delete if across filesystems
-func MoveDir(src, dst string) error {
+// Tries rename first, falls back to copy+delete if across filesystems.
+// With verify, a copy is compared by checksum against the source, which is
+// only removed if they match.
+func MoveDir(src, dst string, verify bool) error {
 	// Try rename first (fast if same filesystem)
 	if err := os.Rename(src, dst); err == nil {
 		return nil
@@ -123,6 +190,18 @@ func MoveDir(src, dst string) error {
 		return fmt.Errorf("failed to copy directory during move: %w", err)
 	}
 
+	if verify {
+		// CopyDir does not preserve timestamps, and the umask may narrow permissions
+		diff, err := CompareDirectories(src, dst, CompareOptions{Checksum: true, IgnorePermissions: true, IgnoreTimestamps: true})
+		if err != nil {
+			return fmt.Errorf("failed to verify directory copy: %w", err)
+		}
+		if !diff.Identical() {
+			return fmt.Errorf("directory copy does not match source (%d missing, %d extra, %d modified), source kept",
+				len(diff.OnlyInSrc), len(diff.OnlyInDst), len(diff.Modified))
+		}
+	}
+
 	// Remove source after successful copy
 	if err := os.RemoveAll(src); err != nil {
 		return fmt.Errorf("failed to remove source directory after copy: %w", err)
@@ -0,0 +1,74 @@
+package sysutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicWriteFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// A new file gets perm
+	exports := filepath.Join(dir, "exports")
+	if err := AtomicWriteFile(exports, []byte("/mnt/data *(rw)\n"), 0644); err != nil {
+		t.Fatalf("AtomicWriteFile: %v", err)
+	}
+	assertFile(t, exports, "/mnt/data *(rw)\n", 0644)
+
+	// An existing file keeps its permissions
+	wg := filepath.Join(dir, "wg0.conf")
+	if err := os.WriteFile(wg, []byte("[Interface]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := AtomicWriteFile(wg, []byte("[Interface]\nListenPort = 51820\n"), 0644); err != nil {
+		t.Fatalf("AtomicWriteFile: %v", err)
+	}
+	assertFile(t, wg, "[Interface]\nListenPort = 51820\n", 0600)
+
+	// A symlink is written through, like resolv.conf managed by resolved
+	stub := filepath.Join(dir, "stub-resolv.conf")
+	if err := os.WriteFile(stub, []byte("nameserver 127.0.0.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resolv := filepath.Join(dir, "resolv.conf")
+	if err := os.Symlink(stub, resolv); err != nil {
+		t.Fatal(err)
+	}
+	if err := AtomicWriteFile(resolv, []byte("nameserver 192.168.1.1\n"), 0644); err != nil {
+		t.Fatalf("AtomicWriteFile: %v", err)
+	}
+	if info, err := os.Lstat(resolv); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink replaced: %v, %v", info, err)
+	}
+	assertFile(t, stub, "nameserver 192.168.1.1\n", 0644)
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 4 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %v, want exports, wg0.conf, stub-resolv.conf and resolv.conf", names)
+	}
+
+	if err := AtomicWriteFile(filepath.Join(dir, "missing", "smb.conf"), []byte("[global]\n"), 0644); err == nil {
+		t.Error("AtomicWriteFile into a missing directory succeeded")
+	}
+}
+
+func assertFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("%s = %q, want %q", filepath.Base(path), data, content)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != perm {
+		t.Errorf("%s mode = %v, want %v", filepath.Base(path), info.Mode().Perm(), perm)
+	}
+}
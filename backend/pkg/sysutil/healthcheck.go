@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 // SystemCheck represents the result of a single system component check
 type SystemCheck struct {
 	Name        string    `json:"name"`
+	Category    string    `json:"category,omitempty"`
 	Required    bool      `json:"required"`
 	Installed   bool      `json:"installed"`
 	Version     string    `json:"version,omitempty"`
@@ -50,41 +53,103 @@ type ComponentDefinition struct {
 	ServiceName string // for systemd service checks
 }
 
-// Standard components to check
-var standardComponents = []ComponentDefinition{
-	// Samba (SMB shares)
-	{Name: "Samba (smbd)", Command: "smbd", Required: false, VersionFlag: "--version", ServiceName: "smbd"},
-	{Name: "Samba (nmbd)", Command: "nmbd", Required: false, ServiceName: "nmbd"},
-	{Name: "smbpasswd", Command: "smbpasswd", Required: false},
-	{Name: "pdbedit", Command: "pdbedit", Required: false},
-	{Name: "testparm", Command: "testparm", Required: false},
-
-	// NFS
-	{Name: "NFS exportfs", Command: "exportfs", Required: false},
-	{Name: "NFS rpcbind", Command: "rpcbind", Required: false, ServiceName: "rpcbind"},
-
-	// System utilities
-	{Name: "useradd", Command: "useradd", Required: true},
-	{Name: "userdel", Command: "userdel", Required: true},
-	{Name: "usermod", Command: "usermod", Required: true},
-	{Name: "groupadd", Command: "groupadd", Required: true},
-	{Name: "chown", Command: "chown", Required: true},
-	{Name: "chmod", Command: "chmod", Required: true},
-
-	// Disk management
-	{Name: "lsblk", Command: "lsblk", Required: true},
-	{Name: "fdisk", Command: "fdisk", Required: false},
-	{Name: "parted", Command: "parted", Required: false},
-	{Name: "mkfs.ext4", Command: "mkfs.ext4", Required: false},
-	{Name: "mkfs.xfs", Command: "mkfs.xfs", Required: false},
-	{Name: "mkfs.btrfs", Command: "mkfs.btrfs", Required: false},
-
-	// Monitoring
-	{Name: "smartctl", Command: "smartctl", Required: false},
-	{Name: "iostat", Command: "iostat", Required: false},
-
-	// Systemd
-	{Name: "systemctl", Command: "systemctl", Required: false},
+// HealthCheckResult is the outcome of a registered health check
+type HealthCheckResult struct {
+	Installed bool
+	Version   string
+	Path      string
+	Status    string // ok, warning, error, missing
+	Message   string
+}
+
+// registeredCheck is a health check in a HealthCheckRegistry
+type registeredCheck struct {
+	name     string
+	required bool
+	category string
+	fn       func() HealthCheckResult
+}
+
+// HealthCheckRegistry holds the checks run by a system health check.
+// Subsystem packages register their checks at init time.
+type HealthCheckRegistry struct {
+	mu     sync.RWMutex
+	checks []registeredCheck
+}
+
+// NewHealthCheckRegistry creates an empty registry
+func NewHealthCheckRegistry() *HealthCheckRegistry {
+	return &HealthCheckRegistry{}
+}
+
+// HealthChecks is the registry used by PerformSystemHealthCheck
+var HealthChecks = NewHealthCheckRegistry()
+
+// Register adds a check, replacing a check already registered under the same name
+func (r *HealthCheckRegistry) Register(name string, required bool, category string, fn func() HealthCheckResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	check := registeredCheck{name: name, required: required, category: category, fn: fn}
+	for i := range r.checks {
+		if r.checks[i].name == name {
+			r.checks[i] = check
+			return
+		}
+	}
+	r.checks = append(r.checks, check)
+}
+
+// Deregister removes a check
+func (r *HealthCheckRegistry) Deregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.checks {
+		if r.checks[i].name == name {
+			r.checks = append(r.checks[:i], r.checks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Run runs all registered checks, grouped by category in registration order
+func (r *HealthCheckRegistry) Run(now time.Time) []SystemCheck {
+	r.mu.RLock()
+	registered := make([]registeredCheck, len(r.checks))
+	copy(registered, r.checks)
+	r.mu.RUnlock()
+
+	sort.SliceStable(registered, func(i, j int) bool {
+		return registered[i].category < registered[j].category
+	})
+
+	checks := make([]SystemCheck, 0, len(registered))
+	for _, rc := range registered {
+		result := rc.fn()
+		checks = append(checks, SystemCheck{
+			Name:      rc.name,
+			Category:  rc.category,
+			Required:  rc.required,
+			Installed: result.Installed,
+			Version:   result.Version,
+			Path:      result.Path,
+			Status:    result.Status,
+			Message:   result.Message,
+			CheckedAt: now,
+		})
+	}
+	return checks
+}
+
+// RegisterComponents registers a command-line component check for each definition
+func RegisterComponents(category string, components ...ComponentDefinition) {
+	for _, def := range components {
+		def := def
+		HealthChecks.Register(def.Name, def.Required, category, func() HealthCheckResult {
+			return checkComponent(def)
+		})
+	}
 }
 
 // PerformSystemHealthCheck runs all system checks
@@ -92,7 +157,6 @@ func PerformSystemHealthCheck() *SystemHealthReport {
 	now := time.Now()
 	report := &SystemHealthReport{
 		CheckedAt: now,
-		Checks:    make([]SystemCheck, 0, len(standardComponents)),
 	}
 
 	// Get hostname
@@ -105,11 +169,8 @@ func PerformSystemHealthCheck() *SystemHealthReport {
 		report.OS = strings.TrimSpace(osInfo)
 	}
 
-	// Perform checks for each component
-	for _, component := range standardComponents {
-		check := checkComponent(component, now)
-		report.Checks = append(report.Checks, check)
-	}
+	// Perform the checks registered by the subsystem packages
+	report.Checks = HealthChecks.Run(now)
 
 	// Calculate summary
 	report.Summary = calculateSummary(report.Checks)
@@ -127,12 +188,8 @@ func PerformSystemHealthCheck() *SystemHealthReport {
 }
 
 // checkComponent performs a check for a single component
-func checkComponent(def ComponentDefinition, now time.Time) SystemCheck {
-	check := SystemCheck{
-		Name:      def.Name,
-		Required:  def.Required,
-		CheckedAt: now,
-	}
+func checkComponent(def ComponentDefinition) HealthCheckResult {
+	var check HealthCheckResult
 
 	// Check if command exists
 	path := FindCommand(def.Command)
@@ -304,7 +361,14 @@ func (r *SystemHealthReport) PrintReport() {
 	fmt.Println("Component Details:")
 	fmt.Println("-------------------------------------")
 
-	for _, check := range r.Checks {
+	category := ""
+	for i, check := range r.Checks {
+		if i == 0 || check.Category != category {
+			category = check.Category
+			if category != "" {
+				fmt.Printf("[%s]\n", category)
+			}
+		}
 		statusSymbol := getStatusSymbol(check.Status)
 		required := ""
 		if check.Required {
@@ -0,0 +1,50 @@
+package sysutil
+
+import "testing"
+
+func TestHealthCheckRegistry(t *testing.T) {
+	HealthChecks.Register("VPN tunnel", false, "vpn", func() HealthCheckResult {
+		return HealthCheckResult{Installed: true, Status: "warning", Message: "wg0 has no handshake"}
+	})
+	HealthChecks.Register("Cluster quorum", true, "ha", func() HealthCheckResult {
+		return HealthCheckResult{Installed: true, Status: "ok"}
+	})
+	defer HealthChecks.Deregister("Cluster quorum")
+
+	report := PerformSystemHealthCheck()
+	checks := map[string]SystemCheck{}
+	for _, check := range report.Checks {
+		checks[check.Name] = check
+	}
+
+	vpn, ok := checks["VPN tunnel"]
+	if !ok {
+		t.Fatalf("registered check missing from report: %+v", report.Checks)
+	}
+	if vpn.Category != "vpn" || vpn.Required || vpn.Status != "warning" || vpn.Message != "wg0 has no handshake" || vpn.CheckedAt.IsZero() {
+		t.Errorf("VPN check = %+v", vpn)
+	}
+	if report.OverallStatus != "degraded" {
+		t.Errorf("overall status = %s, want degraded", report.OverallStatus)
+	}
+
+	// Checks are grouped by category
+	if len(report.Checks) != 2 || report.Checks[0].Name != "Cluster quorum" {
+		t.Errorf("checks = %+v", report.Checks)
+	}
+
+	// Registering a name again replaces the check
+	HealthChecks.Register("VPN tunnel", true, "vpn", func() HealthCheckResult {
+		return HealthCheckResult{Status: "missing"}
+	})
+	report = PerformSystemHealthCheck()
+	if len(report.Checks) != 2 || report.OverallStatus != "unhealthy" {
+		t.Errorf("report after re-registration = %+v", report)
+	}
+
+	HealthChecks.Deregister("VPN tunnel")
+	report = PerformSystemHealthCheck()
+	if len(report.Checks) != 1 || report.Checks[0].Name != "Cluster quorum" {
+		t.Errorf("checks after Deregister = %+v", report.Checks)
+	}
+}
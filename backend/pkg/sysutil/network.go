@@ -35,6 +35,15 @@ func ValidateCIDR(cidr string) bool {
 	return err == nil
 }
 
+// ValidateIPv6CIDR checks if a string is a valid IPv6 CIDR notation
+func ValidateIPv6CIDR(cidr string) bool {
+	ip, _, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	return ip.To4() == nil
+}
+
 // IsPrivateIP checks if an IP address is in a private range
 func IsPrivateIP(ip string) bool {
 	parsedIP := net.ParseIP(ip)
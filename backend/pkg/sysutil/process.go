@@ -0,0 +1,219 @@
+package sysutil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// procDir is where the kernel exposes the running processes
+	procDir = "/proc"
+
+	// commLength is the length the kernel truncates process names to in
+	// /proc/<pid>/comm
+	commLength = 15
+
+	// clockTicks is the kernel's USER_HZ, the unit of process start times
+	// in /proc/<pid>/stat. It is 100 on all Linux architectures we run on.
+	clockTicks = 100
+)
+
+// ProcessInfo describes a running process
+type ProcessInfo struct {
+	PID       int       `json:"pid"`
+	Name      string    `json:"name"`    // Executable name, truncated to 15 characters by the kernel
+	CmdLine   string    `json:"cmdLine"` // Arguments separated by spaces, empty for kernel threads
+	StartTime time.Time `json:"startTime"`
+	RSS       int64     `json:"rss"` // Resident memory in bytes
+	VSZ       int64     `json:"vsz"` // Virtual memory in bytes
+}
+
+// ProcessExists reports whether a process with the given PID is running.
+// Processes of other users count as running even though they cannot be
+// signalled.
+func ProcessExists(pid int) bool {
+	// Signal 0 and negative PIDs address process groups
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// KillProcess sends signal to the process with the given PID. It returns
+// ErrProcessNotFound if there is no such process.
+func KillProcess(pid int, signal os.Signal) error {
+	if pid <= 0 {
+		return fmt.Errorf("invalid PID %d", pid)
+	}
+	sig, ok := signal.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("unsupported signal %v", signal)
+	}
+
+	err := syscall.Kill(pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("%w: %d", ErrProcessNotFound, pid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send %v to process %d: %w", signal, pid, err)
+	}
+	return nil
+}
+
+// FindProcessByName returns the PIDs of the processes whose executable is
+// named name, in ascending order. Names longer than the kernel keeps are
+// matched on their first 15 characters.
+func FindProcessByName(name string) ([]int, error) {
+	if len(name) > commLength {
+		name = name[:commLength]
+	}
+
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// The process may exit while we look at it
+		comm, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSuffix(string(comm), "\n") == name {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// WaitForProcess waits for the process with the given PID to exit, polling
+// ProcessExists with backoff. It returns an error if the process is still
+// running after timeout.
+func WaitForProcess(pid int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := 10 * time.Millisecond
+	for ProcessExists(pid) {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("process %d still running after %s", pid, timeout)
+		}
+		time.Sleep(min(delay, remaining))
+		delay = min(delay*2, 500*time.Millisecond)
+	}
+	return nil
+}
+
+// GetProcessInfo returns the name, command line, start time and memory
+// use of the process with the given PID. It returns ErrProcessNotFound if
+// there is no such process.
+func GetProcessInfo(pid int) (*ProcessInfo, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid PID %d", pid)
+	}
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+	status, err := os.Open(filepath.Join(dir, "status"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %d", ErrProcessNotFound, pid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process %d: %w", pid, err)
+	}
+	defer status.Close()
+
+	info := &ProcessInfo{PID: pid}
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Name":
+			info.Name = value
+		case "VmRSS":
+			info.RSS = parseKB(value)
+		case "VmSize":
+			info.VSZ = parseKB(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read process %d: %w", pid, err)
+	}
+
+	cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process %d: %w", pid, err)
+	}
+	info.CmdLine = strings.Join(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"), " ")
+
+	// Start time is optional; it needs the boot time as well
+	info.StartTime, _ = processStartTime(dir)
+
+	return info, nil
+}
+
+// processStartTime reads the start time of the process in dir from its
+// stat file, which counts clock ticks since boot
+func processStartTime(dir string) (time.Time, error) {
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	// The name in parentheses may contain spaces; fields start after it.
+	// starttime is field 22, the 20th after the name.
+	end := strings.LastIndexByte(string(stat), ')')
+	fields := strings.Fields(string(stat)[end+1:])
+	if end < 0 || len(fields) < 20 {
+		return time.Time{}, fmt.Errorf("malformed %s/stat", dir)
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	boot, err := bootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return boot.Add(time.Duration(ticks) * time.Second / clockTicks), nil
+}
+
+// bootTime reads the system boot time from /proc/stat
+func bootTime() (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("boot time not found in %s/stat", procDir)
+}
+
+// parseKB parses a /proc/<pid>/status size like "10240 kB" into bytes
+func parseKB(value string) int64 {
+	kb, _ := strconv.ParseInt(strings.TrimSuffix(value, " kB"), 10, 64)
+	return kb * 1024
+}
@@ -0,0 +1,85 @@
+package sysutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// missingPID is above the largest PID the kernel hands out
+const missingPID = 1<<22 + 1
+
+func TestFindProcessByName(t *testing.T) {
+	pid := os.Getpid()
+	name := filepath.Base(os.Args[0])
+
+	pids, err := FindProcessByName(name)
+	if err != nil {
+		t.Fatalf("FindProcessByName(%q) error = %v", name, err)
+	}
+	if !slices.Contains(pids, pid) {
+		t.Errorf("FindProcessByName(%q) = %v, want it to contain the test process %d", name, pids, pid)
+	}
+
+	pids, err = FindProcessByName("no-such-process-name")
+	if err != nil || len(pids) != 0 {
+		t.Errorf("FindProcessByName of an unknown name = %v, %v, want none", pids, err)
+	}
+}
+
+func TestKillProcess(t *testing.T) {
+	if err := KillProcess(missingPID, syscall.SIGTERM); !errors.Is(err, ErrProcessNotFound) {
+		t.Errorf("KillProcess(%d) error = %v, want ErrProcessNotFound", missingPID, err)
+	}
+	if err := KillProcess(0, syscall.SIGTERM); err == nil {
+		t.Error("KillProcess(0) succeeded, want an error instead of signalling the process group")
+	}
+
+	// Signal 0 only checks that the process can be signalled
+	if err := KillProcess(os.Getpid(), syscall.Signal(0)); err != nil {
+		t.Errorf("KillProcess(self, 0) error = %v", err)
+	}
+}
+
+func TestProcessExists(t *testing.T) {
+	if !ProcessExists(os.Getpid()) {
+		t.Error("ProcessExists(self) = false")
+	}
+	if ProcessExists(missingPID) || ProcessExists(0) || ProcessExists(-1) {
+		t.Error("ProcessExists of a missing or invalid PID = true")
+	}
+	if err := WaitForProcess(missingPID, time.Second); err != nil {
+		t.Errorf("WaitForProcess(%d) error = %v", missingPID, err)
+	}
+	if err := WaitForProcess(os.Getpid(), 50*time.Millisecond); err == nil {
+		t.Error("WaitForProcess(self) succeeded, want a timeout")
+	}
+}
+
+func TestGetProcessInfo(t *testing.T) {
+	info, err := GetProcessInfo(os.Getpid())
+	if err != nil {
+		t.Fatalf("GetProcessInfo(self) error = %v", err)
+	}
+	if want := filepath.Base(os.Args[0]); info.Name != want[:min(len(want), commLength)] {
+		t.Errorf("Name = %q, want %q", info.Name, want)
+	}
+	if info.CmdLine != strings.Join(os.Args, " ") {
+		t.Errorf("CmdLine = %q, want %q", info.CmdLine, strings.Join(os.Args, " "))
+	}
+	if info.RSS <= 0 || info.VSZ < info.RSS {
+		t.Errorf("RSS = %d, VSZ = %d", info.RSS, info.VSZ)
+	}
+	if age := time.Since(info.StartTime); age < 0 || age > time.Hour {
+		t.Errorf("StartTime = %v, %v ago", info.StartTime, age)
+	}
+
+	if _, err := GetProcessInfo(missingPID); !errors.Is(err, ErrProcessNotFound) {
+		t.Errorf("GetProcessInfo(%d) error = %v, want ErrProcessNotFound", missingPID, err)
+	}
+}
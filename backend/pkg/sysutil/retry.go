@@ -0,0 +1,108 @@
+package sysutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RetryOptions configures RetryWithBackoff
+type RetryOptions struct {
+	MaxAttempts  int           // Including the first attempt; at least 1
+	InitialDelay time.Duration // Delay before the second attempt
+	MaxDelay     time.Duration // Upper bound of the delay; 0 for none
+	Multiplier   float64       // Growth of the delay per attempt; 2 if unset, 1 for a constant delay
+	// RetryIf reports whether an error is worth another attempt; all
+	// errors are retried if nil
+	RetryIf func(error) bool
+	// OnRetry is called before waiting for the next attempt
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// retrySleep waits between attempts. Replaced in tests.
+var retrySleep = func(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryWithBackoff calls fn until it succeeds, returns an error RetryIf
+// rejects or MaxAttempts is reached, waiting an exponentially growing delay
+// between attempts. It returns the last error of fn, or the context error
+// if ctx is done while waiting.
+func RetryWithBackoff[T any](ctx context.Context, opts RetryOptions, fn func() (T, error)) (T, error) {
+	retryIf := opts.RetryIf
+	if retryIf == nil {
+		retryIf = RetryOnAny
+	}
+	multiplier := opts.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	delay := opts.InitialDelay
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= opts.MaxAttempts || !retryIf(err) {
+			return result, err
+		}
+
+		if opts.MaxDelay > 0 && delay > opts.MaxDelay {
+			delay = opts.MaxDelay
+		}
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, delay, err)
+		}
+		if err := retrySleep(ctx, delay); err != nil {
+			var zero T
+			return zero, err
+		}
+		delay = time.Duration(float64(delay) * multiplier)
+	}
+}
+
+// RetryOnAny retries every error
+func RetryOnAny(err error) bool {
+	return true
+}
+
+// RetryOnExitCode retries errors of commands that exited with the given code
+func RetryOnExitCode(code int) func(error) bool {
+	return func(err error) bool {
+		var exitErr interface{ ExitCode() int }
+		return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+	}
+}
+
+// RetryOnContains retries errors whose message contains substring
+func RetryOnContains(substring string) func(error) bool {
+	return func(err error) bool {
+		return strings.Contains(err.Error(), substring)
+	}
+}
+
+// RetryOnLockContention retries errors of the shadow utilities (useradd,
+// groupadd, smbpasswd, ...) that could not lock /etc/passwd or /etc/group
+// because another process held it
+func RetryOnLockContention(err error) bool {
+	for _, message := range []string{
+		"konnte nicht gesperrt werden", // German locale
+		"cannot lock",
+		"unable to lock",
+		"temporarily unavailable",
+	} {
+		if RetryOnContains(message)(err) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package sysutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// recordSleeps replaces retrySleep with one recording the delays
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var delays []time.Duration
+	real := retrySleep
+	retrySleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { retrySleep = real })
+	return &delays
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	errLocked := errors.New("groupadd: cannot lock /etc/group; try again later")
+
+	tests := []struct {
+		name       string
+		opts       RetryOptions
+		failures   int // Attempts failing before fn succeeds
+		err        error
+		wantCalls  int
+		wantDelays []time.Duration
+		wantErr    bool
+	}{
+		{
+			name:       "exponential backoff",
+			opts:       RetryOptions{MaxAttempts: 5, InitialDelay: 100 * time.Millisecond},
+			failures:   4,
+			err:        errLocked,
+			wantCalls:  5,
+			wantDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond},
+		},
+		{
+			name:       "max delay caps the backoff",
+			opts:       RetryOptions{MaxAttempts: 6, InitialDelay: time.Second, MaxDelay: 3 * time.Second, Multiplier: 3},
+			failures:   10,
+			err:        errLocked,
+			wantCalls:  6,
+			wantDelays: []time.Duration{time.Second, 3 * time.Second, 3 * time.Second, 3 * time.Second, 3 * time.Second},
+			wantErr:    true,
+		},
+		{
+			name:       "constant delay",
+			opts:       RetryOptions{MaxAttempts: 3, InitialDelay: time.Second, Multiplier: 1},
+			failures:   10,
+			err:        errLocked,
+			wantCalls:  3,
+			wantDelays: []time.Duration{time.Second, time.Second},
+			wantErr:    true,
+		},
+		{
+			name:      "non-retryable errors fail immediately",
+			opts:      RetryOptions{MaxAttempts: 5, InitialDelay: time.Second, RetryIf: RetryOnContains("cannot lock")},
+			failures:  10,
+			err:       errors.New("groupadd: group 'staff' already exists"),
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:       "retryable errors are retried",
+			opts:       RetryOptions{MaxAttempts: 5, InitialDelay: time.Second, RetryIf: RetryOnLockContention},
+			failures:   1,
+			err:        errLocked,
+			wantCalls:  2,
+			wantDelays: []time.Duration{time.Second},
+		},
+		{
+			name:      "a single attempt without MaxAttempts",
+			opts:      RetryOptions{InitialDelay: time.Second},
+			failures:  10,
+			err:       errLocked,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays := recordSleeps(t)
+			calls := 0
+			got, err := RetryWithBackoff(context.Background(), tt.opts, func() (int, error) {
+				calls++
+				if calls <= tt.failures {
+					return 0, tt.err
+				}
+				return calls, nil
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && err != tt.err {
+				t.Errorf("err = %v, want the last error of fn %v", err, tt.err)
+			}
+			if err == nil && got != tt.wantCalls {
+				t.Errorf("result = %d, want %d", got, tt.wantCalls)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("fn called %d times, want %d", calls, tt.wantCalls)
+			}
+			if fmt.Sprint(*delays) != fmt.Sprint(tt.wantDelays) {
+				t.Errorf("delays = %v, want %v", *delays, tt.wantDelays)
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoffContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	_, err := RetryWithBackoff(ctx, RetryOptions{MaxAttempts: 5, InitialDelay: time.Hour}, func() (struct{}, error) {
+		calls++
+		cancel()
+		return struct{}{}, errors.New("unavailable")
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want the context error after 1 call", err, calls)
+	}
+}
+
+func TestRetryOnExitCode(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 10").Run()
+	if exitErr == nil {
+		t.Skip("sh not available")
+	}
+	wrapped := fmt.Errorf("command failed: %w", exitErr)
+
+	if !RetryOnExitCode(10)(wrapped) {
+		t.Error("exit code 10 was not retried")
+	}
+	if RetryOnExitCode(1)(wrapped) {
+		t.Error("exit code 10 was retried as exit code 1")
+	}
+	if RetryOnExitCode(10)(errors.New("exit status 10")) {
+		t.Error("an error without exit code was retried")
+	}
+}
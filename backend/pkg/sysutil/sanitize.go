@@ -111,7 +111,63 @@ func SafeJoin(basePath string, elem ...string) (string, error) {
 	return cleaned, nil
 }
 
+// SafeJoinResolved is like SafeJoin but also resolves symlinks, so a link
+// inside basePath that points outside of it is rejected. The joined path
+// must exist. Returns the resolved path.
+func SafeJoinResolved(basePath string, elem ...string) (string, error) {
+	joined, err := SafeJoin(basePath, elem...)
+	if err != nil {
+		return "", err
+	}
+
+	resolvedBase, err := filepath.EvalSymlinks(basePath)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(joined)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(resolvedBase, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrPathTraversal
+	}
+
+	return resolved, nil
+}
+
 // ContainsNullByte checks if a string contains null bytes (potential security issue)
 func ContainsNullByte(s string) bool {
 	return strings.Contains(s, "\x00")
 }
+
+// SanitizeShellArg quotes arg for use as a single word in a sh command
+// string. The result is wrapped in single quotes, and each single quote in
+// arg closes the quoting, is escaped with a backslash and reopens it, so no
+// character in arg is interpreted by the shell. Prefer passing arguments to
+// exec.Command or an executor.Pipeline.
+func SanitizeShellArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// SanitizeShellArgReject is like SanitizeShellArg but returns
+// ErrUnsafeShellArg for arguments with null bytes or line breaks, which are
+// never valid in single-line values such as keys and names
+func SanitizeShellArgReject(arg string) (string, error) {
+	if strings.ContainsAny(arg, "\x00\n\r") {
+		return "", ErrUnsafeShellArg
+	}
+	return SanitizeShellArg(arg), nil
+}
+
+// SanitizeShellCommand builds a sh command string running cmd with args,
+// quoting each of them with SanitizeShellArg
+func SanitizeShellCommand(cmd string, args []string) string {
+	words := make([]string, 0, len(args)+1)
+	words = append(words, SanitizeShellArg(cmd))
+	for _, arg := range args {
+		words = append(words, SanitizeShellArg(arg))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,51 @@
+package sysutil
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestSanitizeShellArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"plain", `'plain'`},
+		{"", `''`},
+		{"it's", `'it'\''s'`},
+		{"$(id) `id` $HOME", "'$(id) `id` $HOME'"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeShellArg(tt.arg); got != tt.want {
+			t.Errorf("SanitizeShellArg(%q) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeShellArgIsLiteralInShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	key := "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk='; touch /tmp/pwned; echo '`id` $(id) $HOME \\ \"x\""
+	out, err := exec.Command(sh, "-c", SanitizeShellCommand("printf", []string{"%s", key})).Output()
+	if err != nil {
+		t.Fatalf("sh error = %v", err)
+	}
+	if string(out) != key {
+		t.Errorf("shell printed %q, want %q", out, key)
+	}
+}
+
+func TestSanitizeShellArgReject(t *testing.T) {
+	if got, err := SanitizeShellArgReject("ssh-ed25519 AAAA it's"); err != nil || got != `'ssh-ed25519 AAAA it'\''s'` {
+		t.Errorf("SanitizeShellArgReject() = %s, %v", got, err)
+	}
+	for _, arg := range []string{"key\nrm -rf /", "key\r", "key\x00"} {
+		if _, err := SanitizeShellArgReject(arg); !errors.Is(err, ErrUnsafeShellArg) {
+			t.Errorf("SanitizeShellArgReject(%q) error = %v, want ErrUnsafeShellArg", arg, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package sysutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultWatchInterval is the polling interval used by WatchFile when none is given
+const DefaultWatchInterval = time.Second
+
+// WatchFile polls a file and calls onChange whenever its modification time or
+// size changes. Polling (rather than inotify) also catches editors that replace
+// the file via rename. The watch runs in the background until ctx is cancelled.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) error {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	lastMod, lastSize := info.ModTime(), info.Size()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil {
+					// File may be mid-replace; try again on the next tick
+					continue
+				}
+				if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+					continue
+				}
+				lastMod, lastSize = info.ModTime(), info.Size()
+				onChange()
+			}
+		}
+	}()
+
+	return nil
+}
@@ -3,6 +3,8 @@ package utils
 
 import (
 	"encoding/json"
+	stderrors "errors"
+	"fmt"
 	"net/http"
 
 	"github.com/Stumpf-works/stumpfworks-nas/pkg/errors"
@@ -45,6 +47,14 @@ func RespondError(w http.ResponseWriter, err error) {
 		appErr = errors.InternalServerError("Internal server error", err)
 	}
 
+	// Handlers report unreadable bodies as bad requests; a body cut off by
+	// http.MaxBytesReader is really a 413
+	var maxBytesErr *http.MaxBytesError
+	if stderrors.As(appErr.Err, &maxBytesErr) {
+		appErr = errors.PayloadTooLarge(
+			fmt.Sprintf("Request body too large (limit %d bytes)", maxBytesErr.Limit), appErr.Err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(appErr.Code)
 
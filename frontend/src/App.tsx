@@ -29,6 +29,30 @@ export default function App() {
         console.error('Setup check failed:', error);
       }
 
+      // A SAML login returns to the web UI with the session in the fragment
+      const sso = new URLSearchParams(window.location.hash.slice(1));
+      if (sso.has('accessToken') || sso.has('ssoError')) {
+        window.history.replaceState(null, '', window.location.pathname + window.location.search);
+        const accessToken = sso.get('accessToken');
+        const refreshToken = sso.get('refreshToken');
+        if (accessToken && refreshToken) {
+          localStorage.setItem('accessToken', accessToken);
+          localStorage.setItem('refreshToken', refreshToken);
+          try {
+            const response = await authApi.getCurrentUser();
+            if (response.success && response.data) {
+              setAuth(response.data, accessToken, refreshToken);
+              setIsChecking(false);
+              return;
+            }
+          } catch (error) {
+            clearAuth();
+          }
+        } else {
+          console.error('Single sign-on failed:', sso.get('ssoError'));
+        }
+      }
+
       // Check if user is still authenticated
       const token = localStorage.getItem('accessToken');
       if (token) {
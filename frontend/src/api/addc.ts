@@ -117,6 +117,21 @@ export interface FSMORoles {
   [roleName: string]: string;
 }
 
+// LDAP directory object
+export interface LDAPEntry {
+  dn: string;
+  attributes: Record<string, string[]>;
+}
+
+// Kerberos ticket of a service principal
+export interface KerberosTicket {
+  principal: string;
+  servicePrincipal: string; // krbtgt/REALM@REALM for TGTs
+  expiresAt: string;
+  renewUntil: string; // Zero time for tickets that are not renewable
+  forwardableRenewable: boolean;
+}
+
 // ===== API Client =====
 
 export const addcApi = {
@@ -475,4 +490,37 @@ export const addcApi = {
     );
     return response.data;
   },
+
+  // ===== Kerberos Tickets =====
+
+  // List the tickets of the service principals
+  listKerberosTickets: async () => {
+    const response = await client.get<ApiResponse<KerberosTicket[]>>('/ad-dc/kerberos/tickets');
+    return response.data;
+  },
+
+  // Obtain a TGT for a service principal with either a password or a keytab path
+  obtainKerberosTicket: async (principal: string, credentials: { password?: string; keytab?: string }) => {
+    const response = await client.post<ApiResponse<{ message: string; principal: string; cache: string }>>(
+      '/ad-dc/kerberos/obtain',
+      { principal, ...credentials }
+    );
+    return response.data;
+  },
+
+  // ===== LDAP Browser =====
+
+  // Search the directory (defaults: the whole domain, all objects, all attributes)
+  ldapSearch: async (params: { base?: string; filter?: string; attrs?: string[] }) => {
+    const response = await client.get<ApiResponse<LDAPEntry[]>>('/ad-dc/ldap/search', {
+      params: { base: params.base, filter: params.filter, attrs: params.attrs?.join(',') },
+    });
+    return response.data;
+  },
+
+  // Get a directory object with all its attributes
+  ldapGetObject: async (dn: string) => {
+    const response = await client.get<ApiResponse<LDAPEntry>>('/ad-dc/ldap/object', { params: { dn } });
+    return response.data;
+  },
 };
@@ -18,6 +18,24 @@ export interface AddonManifest {
   minimum_memory: number;
   minimum_disk: number;
   architecture: string[];
+  dependencies?: AddonDependency[];
+  conflicts?: string[];
+  download_url?: string;
+  sha256?: string;
+  signature_url?: string;
+}
+
+export interface AddonDependency {
+  id: string;
+  min_version?: string;
+  optional?: boolean;
+}
+
+export interface ResolutionPlan {
+  addon_id: string;
+  install: string[];
+  already_installed: string[];
+  warnings?: string[];
 }
 
 export interface InstallationStatus {
@@ -55,8 +73,8 @@ export const addonsApi = {
   },
 
   // Install an addon
-  installAddon: async (addonId: string): Promise<ApiResponse<{ message: string; addon_id: string }>> => {
-    const response = await client.post<ApiResponse<{ message: string; addon_id: string }>>(`/addons/${encodeURIComponent(addonId)}/install`);
+  installAddon: async (addonId: string): Promise<ApiResponse<{ message: string; addon_id: string; plan: ResolutionPlan }>> => {
+    const response = await client.post<ApiResponse<{ message: string; addon_id: string; plan: ResolutionPlan }>>(`/addons/${encodeURIComponent(addonId)}/install`);
     return response.data;
   },
 
@@ -65,4 +83,16 @@ export const addonsApi = {
     const response = await client.post<ApiResponse<{ message: string; addon_id: string }>>(`/addons/${encodeURIComponent(addonId)}/uninstall`);
     return response.data;
   },
+
+  // List addons of the marketplace registry, optionally filtered by category and search query
+  listMarketplaceAddons: async (category?: string, q?: string): Promise<ApiResponse<AddonManifest[]>> => {
+    const response = await client.get<ApiResponse<AddonManifest[]>>('/store/plugins', { params: { category, q } });
+    return response.data;
+  },
+
+  // Get a marketplace addon
+  getMarketplaceAddon: async (addonId: string): Promise<ApiResponse<AddonManifest>> => {
+    const response = await client.get<ApiResponse<AddonManifest>>(`/store/plugins/${encodeURIComponent(addonId)}`);
+    return response.data;
+  },
 };
@@ -45,6 +45,31 @@ export interface AlertLog {
   error?: string;
 }
 
+export interface AlertCondition {
+  metricName: string; // SystemMetric field, e.g. cpuUsage
+  comparator: '>' | '>=' | '<' | '<=' | '==' | '!=';
+  threshold: number;
+  window: number; // Nanoseconds the comparison must hold
+}
+
+export interface AlertRuleExpression {
+  operator: 'and' | 'or';
+  conditions: AlertCondition[];
+}
+
+export interface AlertRuleDefinition {
+  name: string;
+  enabled: boolean;
+  expression: AlertRuleExpression;
+}
+
+export interface AlertRule extends AlertRuleDefinition {
+  id: number;
+  createdAt: string;
+  updatedAt: string;
+  lastFiredAt?: string;
+}
+
 export const alertsApi = {
   // Get alert configuration
   getConfig: async () => {
@@ -75,4 +100,24 @@ export const alertsApi = {
     const response = await client.get<ApiResponse<AlertLog[]>>(`/alerts/logs?limit=${limit}`);
     return response.data;
   },
+
+  // Alert rules
+  listRules: async () => {
+    const response = await client.get<ApiResponse<AlertRule[]>>('/alerts/rules');
+    return response.data;
+  },
+
+  createRule: async (rule: AlertRuleDefinition) => {
+    const response = await client.post<ApiResponse<AlertRule>>('/alerts/rules', rule);
+    return response.data;
+  },
+
+  updateRule: async (id: number, rule: AlertRuleDefinition) => {
+    const response = await client.put<ApiResponse<AlertRule>>(`/alerts/rules/${id}`, rule);
+    return response.data;
+  },
+
+  deleteRule: async (id: number) => {
+    await client.delete(`/alerts/rules/${id}`);
+  },
 };
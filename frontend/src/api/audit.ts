@@ -47,6 +47,14 @@ export interface AuditStats {
   }>;
 }
 
+export interface AuditRetentionPolicy {
+  updatedAt?: string;
+  maxAgeDays: number; // 0 keeps logs regardless of age
+  maxRecords: number; // 0 keeps any number
+  archiveBeforeDelete: boolean;
+  archivePath: string;
+}
+
 export const auditApi = {
   // List audit logs with filters and pagination
   listLogs: async (params?: AuditLogQueryParams) => {
@@ -92,4 +100,21 @@ export const auditApi = {
     );
     return response.data;
   },
+
+  // Get the audit log retention policy
+  getRetentionPolicy: async () => {
+    const response = await client.get<ApiResponse<AuditRetentionPolicy>>(
+      '/audit/retention-policy'
+    );
+    return response.data;
+  },
+
+  // Update the audit log retention policy (admin only)
+  updateRetentionPolicy: async (policy: AuditRetentionPolicy) => {
+    const response = await client.put<ApiResponse<AuditRetentionPolicy>>(
+      '/audit/retention-policy',
+      policy
+    );
+    return response.data;
+  },
 };
@@ -21,9 +21,38 @@ export interface LoginResponse {
   refreshToken?: string;
   user?: User;
   requires2FA?: boolean;
+  requires2FASetup?: boolean;
+  setupToken?: string;
+  mfaGraceUntil?: string;
   userId?: number;
 }
 
+export interface MFAPolicy {
+  requireForRoles: string[];
+  requireForExternalIPs: boolean;
+  exemptLocalSubnets: string[];
+  gracePeriodDays: number;
+  changedAt?: string;
+  updatedAt?: string;
+}
+
+export interface SAMLConfig {
+  enabled: boolean;
+  idpMetadataUrl: string;
+  spEntityId: string;
+  assertionConsumerUrl: string;
+  certFile: string;
+  keyFile: string;
+  emailAttribute: string;
+  groupsAttribute: string;
+  adminGroups: string[];
+  defaultRole: 'user' | 'guest';
+  allowIdpInitiated: boolean;
+  metadataRefreshHours: number;
+  metadataFetchedAt?: string;
+  updatedAt?: string;
+}
+
 export const authApi = {
   login: async (credentials: LoginRequest) => {
     const response = await client.post<ApiResponse<LoginResponse>>('/auth/login', credentials);
@@ -46,4 +75,28 @@ export const authApi = {
     });
     return response.data;
   },
+
+  // Get the MFA policy (admin only)
+  getMFAPolicy: async () => {
+    const response = await client.get<ApiResponse<MFAPolicy>>('/auth/mfa-policy');
+    return response.data;
+  },
+
+  // Update the MFA policy (admin only)
+  updateMFAPolicy: async (policy: MFAPolicy) => {
+    const response = await client.put<ApiResponse<MFAPolicy>>('/auth/mfa-policy', policy);
+    return response.data;
+  },
+
+  // Get the SAML single sign-on configuration (admin only)
+  getSAMLConfig: async () => {
+    const response = await client.get<ApiResponse<SAMLConfig>>('/auth/saml/config');
+    return response.data;
+  },
+
+  // Update the SAML configuration; enabling it fetches the IdP metadata (admin only)
+  updateSAMLConfig: async (config: SAMLConfig) => {
+    const response = await client.put<ApiResponse<SAMLConfig>>('/auth/saml/config', config);
+    return response.data;
+  },
 };
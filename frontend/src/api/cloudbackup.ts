@@ -0,0 +1,50 @@
+import client, { ApiResponse } from './client';
+
+// Sensitive parameters (passwords, secrets, tokens) come back as this value;
+// sending it back in an update keeps the stored value
+export const MASKED_VALUE = '********';
+
+export interface RcloneRemote {
+  name: string;
+  type: string; // rclone backend, e.g. s3, b2, sftp, drive
+  params: Record<string, string>;
+}
+
+export interface RemoteTestResult {
+  name: string;
+  success: boolean;
+  duration: number; // ms
+  error?: string;
+}
+
+export const cloudBackupApi = {
+  // rclone remotes
+  async listRemotes(): Promise<ApiResponse<RcloneRemote[]>> {
+    const response = await client.get('/cloudbackup/remotes');
+    return response.data;
+  },
+
+  async getRemote(name: string): Promise<ApiResponse<RcloneRemote>> {
+    const response = await client.get(`/cloudbackup/remotes/${encodeURIComponent(name)}`);
+    return response.data;
+  },
+
+  async createRemote(remote: RcloneRemote): Promise<ApiResponse<RcloneRemote>> {
+    const response = await client.post('/cloudbackup/remotes', remote);
+    return response.data;
+  },
+
+  async updateRemote(name: string, params: Record<string, string>): Promise<ApiResponse<RcloneRemote>> {
+    const response = await client.put(`/cloudbackup/remotes/${encodeURIComponent(name)}`, { params });
+    return response.data;
+  },
+
+  async deleteRemote(name: string): Promise<void> {
+    await client.delete(`/cloudbackup/remotes/${encodeURIComponent(name)}`);
+  },
+
+  async testRemote(name: string): Promise<ApiResponse<RemoteTestResult>> {
+    const response = await client.post(`/cloudbackup/remotes/${encodeURIComponent(name)}/test`);
+    return response.data;
+  },
+};
@@ -83,6 +83,23 @@ export interface CreateStackRequest {
   compose: string;
 }
 
+export interface ServiceDiff {
+  name: string;
+  field: string; // Environment variables as environment.<NAME>
+  old: string;
+  new: string;
+}
+
+export interface StackDiff {
+  addedServices: string[];
+  removedServices: string[];
+  changedServices: ServiceDiff[];
+  addedNetworks: string[];
+  removedNetworks: string[];
+  addedVolumes: string[];
+  removedVolumes: string[];
+}
+
 export interface UpdateStackRequest {
   compose: string;
 }
@@ -107,6 +124,40 @@ export interface CreateContainerRequest {
   labels?: Record<string, string>;
 }
 
+export interface ResourceGovernorConfig {
+  enabled: boolean;
+  memoryThresholdPercent: number;
+  memoryReductionPercent: number;
+  cpuStealThresholdPercent: number;
+  updatedAt?: string;
+}
+
+export interface ThrottledContainer {
+  id: string;
+  name: string;
+  memory?: number;
+  cpuQuota?: number;
+  originalMemory: number;
+  originalCpuQuota: number;
+  throttledAt: string;
+}
+
+export interface ResourceGovernorStatus {
+  config: ResourceGovernorConfig;
+  running: boolean;
+  lastCheck?: string;
+  metrics: {
+    memoryTotalBytes: number;
+    memoryAvailablePercent: number;
+    cpuStealPercent: number;
+  };
+  memoryPressure: boolean;
+  cpuPressure: boolean;
+  throttled: ThrottledContainer[];
+  priorities: Record<string, 'high' | 'normal'>;
+  lastError?: string;
+}
+
 // API
 export const dockerApi = {
   // Containers
@@ -166,6 +217,24 @@ export const dockerApi = {
     return response.data;
   },
 
+  async setContainerPriority(id: string, priority: 'high' | 'normal'): Promise<ApiResponse<any>> {
+    const response = await client.put(`/docker/containers/${id}/priority`, { priority });
+    return response.data;
+  },
+
+  // Resource governor
+  async getResourceGovernorStatus(): Promise<ApiResponse<ResourceGovernorStatus>> {
+    const response = await client.get('/docker/resource-governor/status');
+    return response.data;
+  },
+
+  async updateResourceGovernorConfig(
+    config: ResourceGovernorConfig
+  ): Promise<ApiResponse<ResourceGovernorStatus>> {
+    const response = await client.put('/docker/resource-governor/config', config);
+    return response.data;
+  },
+
   // Images
   async listImages(): Promise<ApiResponse<DockerImage[]>> {
     const response = await client.get('/docker/images');
@@ -257,6 +326,11 @@ export const dockerApi = {
     return response.data;
   },
 
+  async diffStack(name: string, compose: string): Promise<ApiResponse<StackDiff>> {
+    const response = await client.post(`/docker/stacks/${name}/diff`, { compose });
+    return response.data;
+  },
+
   async createContainer(request: CreateContainerRequest): Promise<ApiResponse<any>> {
     const response = await client.post('/docker/containers', request);
     return response.data;
@@ -51,6 +51,29 @@ export interface UploadSession {
   chunks: boolean[];
   startTime: string;
   lastUpdate: string;
+  expiresAt: string;
+}
+
+export interface UploadProgress {
+  sessionId: string;
+  userId: number;
+  fileName: string;
+  status: 'active' | 'completed' | 'cancelled';
+  totalChunks: number;
+  receivedChunks: number;
+  totalSize: number;
+  bytesReceived: number;
+  percent: number;
+  startedAt: string;
+  expiresAt: string;
+  finishedAt?: string;
+}
+
+export interface FileVersion {
+  versionId: string;
+  createdAt: string;
+  size: number;
+  checksum: string;
 }
 
 // ===== Browse & Info =====
@@ -99,6 +122,30 @@ export const renameFile = async (oldPath: string, newName: string): Promise<void
   });
 };
 
+export interface BulkRenameOptions {
+  dir: string;
+  pattern: string;
+  replacement: string;
+  dryRun?: boolean;
+  preview?: boolean;
+}
+
+export interface BulkRenameResult {
+  renamed: number;
+  skipped: number;
+  conflicts: string[];
+  errors: string[];
+  preview?: { before: string; after: string }[];
+}
+
+export const bulkRenameFiles = async (options: BulkRenameOptions): Promise<BulkRenameResult> => {
+  // Name conflicts are reported with status 409 and the result as data
+  const response = await client.post('/files/rename/bulk', options, {
+    validateStatus: (status) => status === 200 || status === 409
+  });
+  return response.data.data;
+};
+
 export const copyFiles = async (source: string, destination: string, overwrite: boolean = false): Promise<void> => {
   await client.post('/files/copy', {
     source,
@@ -184,6 +231,22 @@ export const getUploadSession = async (sessionId: string): Promise<UploadSession
   return response.data.data;
 };
 
+export const getUploadProgress = async (sessionId: string): Promise<UploadProgress> => {
+  const response = await client.get(`/files/upload/${sessionId}/progress`);
+  return response.data.data;
+};
+
+// ===== Version History =====
+
+export const listFileVersions = async (path: string): Promise<FileVersion[]> => {
+  const response = await client.get('/files/versions', { params: { path } });
+  return response.data.data;
+};
+
+export const restoreFileVersion = async (path: string, versionId: string): Promise<void> => {
+  await client.post(`/files/versions/${versionId}/restore`, { path });
+};
+
 // ===== Download =====
 
 export const downloadFile = (path: string): void => {
@@ -240,25 +303,96 @@ export const changeFilePermissions = async (
 
 // ===== Archives =====
 
+export type ArchiveFormat = 'zip' | 'tar' | 'tar.gz' | 'tar.xz';
+
+export interface CreateArchiveOptions {
+  compressionLevel?: number; // 1-9, gzip and xz only
+  password?: string; // zip only
+}
+
+export interface ArchiveResult {
+  jobId?: string; // Set for large archives created in the background
+  destination: string;
+  format: ArchiveFormat;
+  totalBytes: number;
+}
+
+// Large archives are created in the background; their progress is streamed
+// as "archive" events on /events/stream
 export const createArchive = async (
   paths: string[],
-  outputPath: string,
-  format: 'zip' | 'tar' | 'tar.gz' = 'zip'
-): Promise<void> => {
-  await client.post('/files/archive/create', {
+  destination: string,
+  format: ArchiveFormat = 'zip',
+  options: CreateArchiveOptions = {}
+): Promise<ArchiveResult> => {
+  const response = await client.post('/files/archive/create', {
     paths,
-    outputPath,
-    format
+    destination,
+    format,
+    ...options
   });
+  return response.data.data;
 };
 
-export const extractArchive = async (archivePath: string, destination: string): Promise<void> => {
+export const extractArchive = async (
+  archivePath: string,
+  destination: string,
+  password?: string
+): Promise<void> => {
   await client.post('/files/archive/extract', {
     archivePath,
-    destination
+    destination,
+    password
   });
 };
 
+// ===== Duplicate Files =====
+
+export interface DedupeScanOptions {
+  minSize?: number; // Bytes
+  algorithm?: 'sha256' | 'md5';
+  linkDuplicates?: boolean; // Replace duplicates with hardlinks
+}
+
+export interface DuplicateGroup {
+  files: string[];
+  size: number;
+  hash: string;
+}
+
+export interface DedupeJob {
+  id: string;
+  root: string;
+  algorithm: string;
+  minSize: number;
+  linkDuplicates: boolean;
+  tool: string;
+  status: 'running' | 'completed' | 'failed';
+  progress: number;
+  report?: {
+    totalDuplicates: number;
+    wastedBytes: number;
+    linkedFiles: number;
+    groups: DuplicateGroup[];
+  };
+  error?: string;
+  createdAt: string;
+  completedAt?: string;
+}
+
+export const startDedupeScan = async (
+  path: string,
+  options: DedupeScanOptions = {}
+): Promise<{ jobId: string; path: string }> => {
+  const response = await client.post('/files/dedup/scan', { path, ...options });
+  return response.data;
+};
+
+export const getDedupeResults = async (jobId: string): Promise<DedupeJob> => {
+  const response = await client.get(`/files/dedup/results/${jobId}`);
+  return response.data.data;
+};
+
 // ===== Helpers =====
 
 export const formatFileSize = (bytes: number): string => {
@@ -33,6 +33,13 @@ export interface AddMemberRequest {
   userId: number;
 }
 
+export interface GroupTreeNode {
+  id: number;
+  name: string;
+  members: MemberInfo[];
+  subgroups: GroupTreeNode[];
+}
+
 export const groupsApi = {
   list: async () => {
     const response = await client.get<ApiResponse<UserGroup[]>>('/groups');
@@ -80,4 +87,26 @@ export const groupsApi = {
     );
     return response.data;
   },
+
+  getTree: async (groupId: number) => {
+    const response = await client.get<ApiResponse<GroupTreeNode>>(
+      `/groups/${groupId}/tree`
+    );
+    return response.data;
+  },
+
+  addSubgroup: async (groupId: number, childGroupId: number) => {
+    const response = await client.post<ApiResponse>(
+      `/groups/${groupId}/subgroups`,
+      { groupId: childGroupId }
+    );
+    return response.data;
+  },
+
+  removeSubgroup: async (groupId: number, childGroupId: number) => {
+    const response = await client.delete<ApiResponse>(
+      `/groups/${groupId}/subgroups/${childGroupId}`
+    );
+    return response.data;
+  },
 };
@@ -98,6 +98,46 @@ export interface VIPStatus {
   is_active: boolean;
 }
 
+// Unified HA cluster Types
+export interface HANodeStatus {
+  name: string;
+  online: boolean;
+  local: boolean;
+  resources: string[];
+}
+
+export interface HAResourceStatus {
+  id: string;
+  agent: string;
+  node: string;
+  active: boolean;
+  failed: boolean;
+}
+
+export interface HAClusterStatus {
+  overall_health: 'healthy' | 'degraded' | 'critical' | 'unavailable';
+  issues: string[];
+  name: string;
+  quorum: boolean;
+  stonith_enabled: boolean;
+  nodes: HANodeStatus[];
+  resources: HAResourceStatus[];
+  virtual_ips: VIPStatus[];
+  replication_status: {
+    healthy: boolean;
+    resources: DRBDStatus[];
+  };
+}
+
+// Fencing Types
+export type FenceMethod = 'ipmi' | 'apc-pdu' | 'aws-ec2';
+
+export interface NodeFencing {
+  node: string;
+  method: FenceMethod;
+  config: Record<string, string>; // Without password and secret_key
+}
+
 export const haApi = {
   // DRBD Resource Management
   listDRBDResources: async (): Promise<ApiResponse<string[]>> => {
@@ -291,4 +331,43 @@ export const haApi = {
     });
     return response.json();
   },
+
+  // Unified HA cluster
+  getHAClusterStatus: async (): Promise<ApiResponse<HAClusterStatus>> => {
+    const response = await fetch('/api/v1/syslib/ha/cluster/status');
+    return response.json();
+  },
+
+  performFailover: async (targetNode: string): Promise<ApiResponse<void>> => {
+    const response = await fetch('/api/v1/syslib/ha/cluster/failover', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ target_node: targetNode }),
+    });
+    return response.json();
+  },
+
+  // Fencing (STONITH)
+  setNodeFencing: async (node: string, method: FenceMethod, config: Record<string, string>): Promise<ApiResponse<NodeFencing>> => {
+    const response = await fetch(`/api/v1/syslib/ha/fence/${encodeURIComponent(node)}/config`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ method, config }),
+    });
+    return response.json();
+  },
+
+  fenceNode: async (node: string): Promise<ApiResponse<void>> => {
+    const response = await fetch(`/api/v1/syslib/ha/fence/${encodeURIComponent(node)}`, {
+      method: 'POST',
+    });
+    return response.json();
+  },
+
+  testNodeFencing: async (node: string): Promise<ApiResponse<void>> => {
+    const response = await fetch(`/api/v1/syslib/ha/fence/${encodeURIComponent(node)}/test`, {
+      method: 'POST',
+    });
+    return response.json();
+  },
 };
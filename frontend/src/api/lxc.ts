@@ -27,11 +27,37 @@ export interface ContainerCreateRequest {
   ssh_key?: string; // SSH public key for passwordless authentication
 }
 
+export interface LXCNetworkConfig {
+  container_id: string;
+  nic_name: string; // e.g. eth0
+  bridge_name: string; // e.g. br0
+  vlan_id: number; // 1-4094, 0 for untagged
+  ip_address: string; // CIDR notation, DHCP if empty
+  gateway: string;
+  mac_address: string;
+}
+
+export type ContainerNetworkRequest = Partial<Omit<LXCNetworkConfig, 'container_id'>> & {
+  bridge_name: string;
+  ipam_pool?: string; // Static address from this IPAM pool
+};
+
 export interface LXCTemplate {
   name: string;
   description: string;
 }
 
+export interface ContainerMigration {
+  id: number;
+  containerId: string;
+  sourceHost: string;
+  targetHost: string;
+  startedAt: string;
+  status: 'checkpointing' | 'transferring' | 'restoring' | 'completed' | 'failed';
+  error?: string;
+  completedAt?: string;
+}
+
 export const lxcApi = {
   // List all containers
   listContainers: async (): Promise<ApiResponse<Container[]>> => {
@@ -86,4 +112,37 @@ export const lxcApi = {
     const response = await client.get<ApiResponse<{ console_command: string; container_name: string }>>(`/lxc/containers/${encodeURIComponent(name)}/console`);
     return response.data;
   },
+
+  // Get the bridge, VLAN and address of a container
+  getContainerNetwork: async (name: string): Promise<ApiResponse<LXCNetworkConfig>> => {
+    const response = await client.get<ApiResponse<LXCNetworkConfig>>(`/lxc/containers/${encodeURIComponent(name)}/network`);
+    return response.data;
+  },
+
+  // Change the network of a container; applies on its next start
+  setContainerNetwork: async (name: string, data: ContainerNetworkRequest): Promise<ApiResponse<LXCNetworkConfig>> => {
+    const response = await client.put<ApiResponse<LXCNetworkConfig>>(`/lxc/containers/${encodeURIComponent(name)}/network`, data);
+    return response.data;
+  },
+
+  // Live-migrate a container to another NAS
+  migrateContainer: async (name: string, targetHost: string, targetUser = 'root'): Promise<ApiResponse<ContainerMigration>> => {
+    const response = await client.post<ApiResponse<ContainerMigration>>(`/lxc/containers/${encodeURIComponent(name)}/migrate`, {
+      target_host: targetHost,
+      target_user: targetUser,
+    });
+    return response.data;
+  },
+
+  // List container migrations
+  listMigrations: async (): Promise<ApiResponse<ContainerMigration[]>> => {
+    const response = await client.get<ApiResponse<ContainerMigration[]>>('/lxc/migrations');
+    return response.data;
+  },
+
+  // Get migration progress
+  getMigration: async (id: number): Promise<ApiResponse<ContainerMigration>> => {
+    const response = await client.get<ApiResponse<ContainerMigration>>(`/lxc/migrations/${id}`);
+    return response.data;
+  },
 };
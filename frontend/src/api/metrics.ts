@@ -47,11 +47,24 @@ export interface HealthScore {
   cpuScore: number;
   memoryScore: number;
   diskScore: number;
+  smartScore: number;
   networkScore: number;
   issues?: string;
   createdAt: string;
 }
 
+export interface ScoreDeduction {
+  component: string;
+  reason: string;
+  points: number;
+}
+
+export interface HealthScoreBreakdown {
+  overall: number;
+  components: Record<string, number>;
+  deductions: ScoreDeduction[];
+}
+
 export interface MetricsTrend {
   metricName: string;
   currentValue: number;
@@ -142,4 +155,12 @@ export const metricsApi = {
     const response = await client.get('/health/score');
     return response.data.data;
   },
+
+  /**
+   * Get the components and deductions of the current health score
+   */
+  getHealthScoreBreakdown: async (): Promise<HealthScoreBreakdown> => {
+    const response = await client.get('/health/score/breakdown');
+    return response.data.data;
+  },
 };
@@ -30,6 +30,7 @@ export interface HealthScore {
   cpuScore: number;
   memoryScore: number;
   diskScore: number;
+  smartScore: number;
   networkScore: number;
   issues?: string;
 }
@@ -42,6 +43,67 @@ export interface MetricsTrend {
   previous_value: number;
 }
 
+export interface CustomMetricDefinition {
+  name: string;
+  expression: string; // Go template over SystemMetric fields, e.g. {{div .MemoryUsedBytes .MemoryTotalBytes}}
+  help: string;
+  type: 'gauge' | 'counter';
+}
+
+export interface CustomMetric extends CustomMetricDefinition {
+  id: number;
+  createdAt: string;
+  updatedAt: string;
+  value?: number;
+  lastError?: string;
+}
+
+export interface MetricsExportConfig {
+  enabled: boolean;
+  protocol: 'influxdb' | 'prometheus-push';
+  remoteUrl: string;
+  token?: string; // Empty keeps the current token
+  org: string;
+  bucket: string;
+  pushInterval: string; // Go duration, e.g. 60s
+}
+
+export interface MetricsExportSettings extends Omit<MetricsExportConfig, 'token'> {
+  tokenSet: boolean;
+}
+
+export interface MetricsExportTestResult {
+  success: boolean;
+  points: number;
+  duration: number; // Milliseconds
+  error?: string;
+}
+
+export interface MaintenanceWindowDefinition {
+  name: string;
+  reason: string;
+  start: string;
+  end: string;
+  matchingAlertTypes: string[];
+  allAlerts: boolean;
+}
+
+export interface MaintenanceWindow extends MaintenanceWindowDefinition {
+  id: number;
+  createdAt: string;
+  updatedAt: string;
+  summarySentAt?: string;
+}
+
+export interface SuppressedAlert {
+  id: number;
+  createdAt: string;
+  windowId: number;
+  alertType: string;
+  subject: string;
+  body: string;
+}
+
 export const monitoringApi = {
   // Configuration
   getConfig: async () => {
@@ -54,6 +116,72 @@ export const monitoringApi = {
     return response.data;
   },
 
+  // Custom metrics
+  listCustomMetrics: async () => {
+    const response = await client.get<ApiResponse<CustomMetric[]>>('/monitoring/custom-metrics');
+    return response.data;
+  },
+
+  createCustomMetric: async (metric: CustomMetricDefinition) => {
+    const response = await client.post<ApiResponse<CustomMetric>>('/monitoring/custom-metrics', metric);
+    return response.data;
+  },
+
+  updateCustomMetric: async (id: number, metric: CustomMetricDefinition) => {
+    const response = await client.put<ApiResponse<CustomMetric>>(`/monitoring/custom-metrics/${id}`, metric);
+    return response.data;
+  },
+
+  deleteCustomMetric: async (id: number) => {
+    await client.delete(`/monitoring/custom-metrics/${id}`);
+  },
+
+  // Metrics export
+  getExportConfig: async () => {
+    const response = await client.get<ApiResponse<MetricsExportSettings>>('/monitoring/export/config');
+    return response.data;
+  },
+
+  updateExportConfig: async (config: MetricsExportConfig) => {
+    const response = await client.put<ApiResponse<MetricsExportSettings>>('/monitoring/export/config', config);
+    return response.data;
+  },
+
+  testExport: async (config?: MetricsExportConfig) => {
+    const response = await client.post<ApiResponse<MetricsExportTestResult>>('/monitoring/export/test', config);
+    return response.data;
+  },
+
+  // Maintenance windows
+  listMaintenanceWindows: async () => {
+    const response = await client.get<ApiResponse<MaintenanceWindow[]>>('/monitoring/maintenance-windows');
+    return response.data;
+  },
+
+  createMaintenanceWindow: async (window: MaintenanceWindowDefinition) => {
+    const response = await client.post<ApiResponse<{ id: string }>>('/monitoring/maintenance-windows', window);
+    return response.data;
+  },
+
+  updateMaintenanceWindow: async (id: number, window: MaintenanceWindowDefinition) => {
+    const response = await client.put<ApiResponse<MaintenanceWindow>>(`/monitoring/maintenance-windows/${id}`, window);
+    return response.data;
+  },
+
+  deleteMaintenanceWindow: async (id: number) => {
+    await client.delete(`/monitoring/maintenance-windows/${id}`);
+  },
+
+  getSuppressedAlerts: async (id: number) => {
+    const response = await client.get<ApiResponse<SuppressedAlert[]>>(`/monitoring/maintenance-windows/${id}/suppressed`);
+    return response.data;
+  },
+
+  sendMaintenanceSummary: async (id: number) => {
+    const response = await client.post<ApiResponse<{ message: string }>>(`/monitoring/maintenance-windows/${id}/summary`);
+    return response.data;
+  },
+
   // Metrics
   getLatestMetrics: async () => {
     const response = await client.get<ApiResponse<SystemMetrics>>('/metrics/latest');
@@ -8,6 +8,8 @@ export interface NetworkInterface {
   flags: string[];
   mtu: number;
   addresses: string[];
+  ipv4Addresses: string[];
+  ipv6Addresses: string[];
   isUp: boolean;
   speed: string;
   type: string;
@@ -23,6 +25,7 @@ export interface InterfaceStats {
   txErrors: number;
   rxDropped: number;
   txDropped: number;
+  type: string; // e.g. 'vpn' for WireGuard tunnels
 }
 
 export interface Route {
@@ -64,6 +67,142 @@ export interface DiagnosticResult {
   error?: string;
 }
 
+export interface ConnectionStat {
+  srcIp: string;
+  dstIp: string;
+  srcPort: number;
+  dstPort: number;
+  protocol: string;
+  bytesOrig: number;
+  bytesReply: number;
+  packets: number;
+  state?: string;
+}
+
+export interface ShareTraffic {
+  share: string;
+  protocol: 'smb' | 'nfs';
+  rxBytes: number;
+  txBytes: number;
+}
+
+export interface TrafficStats {
+  shares: ShareTraffic[];
+  connections: ConnectionStat[];
+}
+
+export interface PortForwardRule {
+  id: string;
+  externalPort: number;
+  internalIp: string;
+  internalPort: string;
+  protocol: 'tcp' | 'udp';
+  description: string;
+  enabled: boolean;
+}
+
+export type PortForwardRequest = Omit<PortForwardRule, 'id' | 'enabled'> & { enabled?: boolean };
+
+export interface VXLANConfig {
+  name: string;
+  vni: number;            // 1-16777215
+  remoteIp: string;
+  localIp: string;
+  dstPort?: number;       // Default 4789
+  bridge?: string;
+}
+
+export interface ARPDevice {
+  ip: string;
+  mac: string;
+  firstSeen: string;
+  lastSeen: string;
+  changeCount: number; // How often the IP moved to another MAC
+}
+
+export interface IPAMPoolRequest {
+  name: string;
+  subnet: string; // CIDR, e.g. 10.8.0.0/24
+  gatewayIp?: string;
+  dnsServers?: string[];
+  excludedRanges?: string[]; // CIDRs, single IPs or first-last ranges
+}
+
+export interface IPAMPool extends Required<IPAMPoolRequest> {
+  id: number;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface IPAllocation {
+  id: number;
+  poolName: string;
+  allocatedIp: string;
+  allocatedFor: string;
+  allocatedAt: string;
+}
+
+export interface DynamicDNSConfig {
+  enabled: boolean;
+  provider: 'cloudflare' | 'namecheap' | 'dyndns';
+  domain: string;
+  subdomain: string;
+  credential?: string;
+  updateIntervalMinutes: number;
+  hasCredential?: boolean;
+}
+
+export interface DDNSState {
+  hostname: string;
+  currentIp: string;
+  detectedIp: string;
+  lastUpdate?: string;
+  lastCheck?: string;
+  lastError?: string;
+  failedAttempts: number;
+}
+
+export interface DDNSStatus {
+  config: DynamicDNSConfig;
+  state: DDNSState;
+}
+
+export interface MDNSService {
+  type: string;
+  port: number;
+}
+
+export interface BridgePortStats {
+  name: string;
+  portNo: number;
+  state: 'disabled' | 'listening' | 'learning' | 'forwarding' | 'blocking' | 'unknown';
+  rxBytes: number;
+  txBytes: number;
+  rxPackets: number;
+  txPackets: number;
+}
+
+export interface BridgeStats {
+  name: string;
+  rxBytes: number;
+  txBytes: number;
+  rxPackets: number;
+  txPackets: number;
+  rxErrors: number;
+  txErrors: number;
+  rxDropped: number;
+  txDropped: number;
+  ports: BridgePortStats[];
+}
+
+export interface FDBEntry {
+  mac: string;
+  port: string;
+  isLocal: boolean;
+  isStatic: boolean;
+  ageSeconds: number;
+}
+
 // API
 export const networkApi = {
   // Interfaces
@@ -77,6 +216,28 @@ export const networkApi = {
     return response.data;
   },
 
+  async getTraffic(): Promise<ApiResponse<TrafficStats>> {
+    const response = await client.get('/network/traffic');
+    return response.data;
+  },
+
+  // Dynamic DNS
+  async getDDNSStatus(): Promise<ApiResponse<DDNSStatus>> {
+    const response = await client.get('/network/ddns/status');
+    return response.data;
+  },
+
+  async updateDDNSConfig(config: DynamicDNSConfig): Promise<ApiResponse<DDNSStatus>> {
+    const response = await client.put('/network/ddns/config', config);
+    return response.data;
+  },
+
+  // mDNS/Bonjour
+  async restartMDNS(): Promise<ApiResponse<MDNSService[]>> {
+    const response = await client.post('/network/mdns/restart');
+    return response.data;
+  },
+
   async setInterfaceState(name: string, state: 'up' | 'down'): Promise<ApiResponse<any>> {
     const response = await client.post(`/network/interfaces/${name}/state`, { state });
     return response.data;
@@ -87,13 +248,17 @@ export const networkApi = {
     mode: 'static' | 'dhcp',
     address?: string,
     netmask?: string,
-    gateway?: string
+    gateway?: string,
+    ipv6Address?: string,
+    ipv6Gateway?: string
   ): Promise<ApiResponse<any>> {
     const response = await client.post(`/network/interfaces/${name}/configure`, {
       mode,
       address,
       netmask,
       gateway,
+      ipv6Address,
+      ipv6Gateway,
     });
     return response.data;
   },
@@ -163,6 +328,91 @@ export const networkApi = {
     return response.data;
   },
 
+  // Port forwarding
+  async listPortForwards(): Promise<ApiResponse<PortForwardRule[]>> {
+    const response = await client.get('/network/port-forwards');
+    return response.data;
+  },
+
+  async createPortForward(rule: PortForwardRequest): Promise<ApiResponse<PortForwardRule>> {
+    const response = await client.post('/network/port-forwards', rule);
+    return response.data;
+  },
+
+  async updatePortForward(id: string, rule: PortForwardRequest): Promise<ApiResponse<PortForwardRule>> {
+    const response = await client.put(`/network/port-forwards/${id}`, rule);
+    return response.data;
+  },
+
+  async deletePortForward(id: string): Promise<void> {
+    await client.delete(`/network/port-forwards/${id}`);
+  },
+
+  // VXLAN overlay networks
+  async listVXLANs(): Promise<ApiResponse<VXLANConfig[]>> {
+    const response = await client.get('/network/vxlan');
+    return response.data;
+  },
+
+  async getVXLAN(name: string): Promise<ApiResponse<VXLANConfig>> {
+    const response = await client.get(`/network/vxlan/${name}`);
+    return response.data;
+  },
+
+  async createVXLAN(config: VXLANConfig): Promise<ApiResponse<VXLANConfig>> {
+    const response = await client.post('/network/vxlan', config);
+    return response.data;
+  },
+
+  async deleteVXLAN(name: string): Promise<void> {
+    await client.delete(`/network/vxlan/${name}`);
+  },
+
+  // ARP spoofing detection
+  async listARPDevices(): Promise<ApiResponse<ARPDevice[]>> {
+    const response = await client.get('/network/arp/devices');
+    return response.data;
+  },
+
+  async whitelistARPMAC(ip: string, mac: string): Promise<ApiResponse<{ message: string }>> {
+    const response = await client.post('/network/arp/whitelist', { ip, mac });
+    return response.data;
+  },
+
+  // IP address management
+  async listIPAMPools(): Promise<ApiResponse<IPAMPool[]>> {
+    const response = await client.get('/network/ipam/pools');
+    return response.data;
+  },
+
+  async createIPAMPool(pool: IPAMPoolRequest): Promise<ApiResponse<IPAMPool>> {
+    const response = await client.post('/network/ipam/pools', pool);
+    return response.data;
+  },
+
+  async updateIPAMPool(name: string, pool: IPAMPoolRequest): Promise<ApiResponse<IPAMPool>> {
+    const response = await client.put(`/network/ipam/pools/${encodeURIComponent(name)}`, pool);
+    return response.data;
+  },
+
+  async deleteIPAMPool(name: string): Promise<void> {
+    await client.delete(`/network/ipam/pools/${encodeURIComponent(name)}`);
+  },
+
+  async listIPAllocations(pool: string): Promise<ApiResponse<IPAllocation[]>> {
+    const response = await client.get(`/network/ipam/pools/${encodeURIComponent(pool)}/allocations`);
+    return response.data;
+  },
+
+  async allocateIP(pool: string, allocatedFor: string): Promise<ApiResponse<{ ip: string }>> {
+    const response = await client.post(`/network/ipam/pools/${encodeURIComponent(pool)}/allocations`, { allocatedFor });
+    return response.data;
+  },
+
+  async releaseIP(ip: string): Promise<void> {
+    await client.delete(`/network/ipam/allocations/${encodeURIComponent(ip)}`);
+  },
+
   async setDefaultPolicy(direction: string, policy: string): Promise<ApiResponse<any>> {
     const response = await client.post('/network/firewall/default', { direction, policy });
     return response.data;
@@ -220,4 +470,14 @@ export const networkApi = {
     const response = await client.post(`/network/bridges/detach`, { port });
     return response.data;
   },
+
+  async getBridgeStats(name: string): Promise<ApiResponse<BridgeStats>> {
+    const response = await client.get(`/network/bridges/${name}/stats`);
+    return response.data;
+  },
+
+  async getBridgeFDB(name: string): Promise<ApiResponse<FDBEntry[]>> {
+    const response = await client.get(`/network/bridges/${name}/fdb`);
+    return response.data;
+  },
 };
@@ -0,0 +1,29 @@
+import client, { ApiResponse } from './client';
+
+export interface PushSubscriptionRecord {
+  id: number;
+  userId: number;
+  endpoint: string;
+  userAgent: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export const notificationsApi = {
+  // Get the key browsers pass as applicationServerKey to pushManager.subscribe
+  getVapidPublicKey: async () => {
+    const response = await client.get<ApiResponse<{ publicKey: string }>>('/notifications/vapid-public-key');
+    return response.data;
+  },
+
+  // Register a browser PushSubscription (subscription.toJSON())
+  subscribe: async (subscription: PushSubscriptionJSON) => {
+    const response = await client.post<ApiResponse<PushSubscriptionRecord>>('/notifications/subscribe', subscription);
+    return response.data;
+  },
+
+  // Stop pushing notifications to a browser
+  unsubscribe: async (endpoint: string) => {
+    await client.delete('/notifications/unsubscribe', { data: { endpoint } });
+  },
+};
@@ -16,6 +16,13 @@ export interface Plugin {
   updatedAt: string;
 }
 
+export interface PluginLog {
+  pluginId: string;
+  level: 'info' | 'error';
+  message: string;
+  timestamp: string;
+}
+
 export interface InstallPluginRequest {
   sourcePath: string;
 }
@@ -70,4 +77,13 @@ export const pluginApi = {
     const response = await client.put(`/plugins/${id}/config`, request);
     return response.data;
   },
+
+  // Get the latest stdout/stderr lines of a plugin
+  async getPluginLogs(
+    id: string,
+    params?: { tail?: number; since?: string }
+  ): Promise<ApiResponse<PluginLog[]>> {
+    const response = await client.get(`/plugins/${id}/logs`, { params });
+    return response.data;
+  },
 };
@@ -35,6 +35,22 @@ export interface FilesystemQuotaStatus {
   groupQuotaEnabled: boolean;
 }
 
+export interface XFSProject {
+  id: number;
+  name: string;
+  path: string;
+  filesystem: string;
+  soft_limit: number;
+  hard_limit: number;
+}
+
+export interface CreateProjectRequest {
+  name: string;
+  path: string;
+  soft_limit: number;
+  hard_limit: number;
+}
+
 export const quotaApi = {
   // User quota operations
   getUserQuota: async (username: string, filesystem: string): Promise<ApiResponse<QuotaInfo>> => {
@@ -99,4 +115,37 @@ export const quotaApi = {
     const response = await fetch(`/api/v1/quotas/status?filesystem=${encodeURIComponent(filesystem)}`);
     return response.json();
   },
+
+  // XFS project quotas
+  listProjects: async (): Promise<ApiResponse<XFSProject[]>> => {
+    const response = await fetch('/api/v1/syslib/quota/projects');
+    return response.json();
+  },
+
+  createProject: async (request: CreateProjectRequest): Promise<ApiResponse<XFSProject>> => {
+    const response = await fetch('/api/v1/syslib/quota/projects', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(request),
+    });
+    return response.json();
+  },
+
+  getProject: async (name: string): Promise<ApiResponse<XFSProject & { used: number }>> => {
+    const response = await fetch(`/api/v1/syslib/quota/projects/${encodeURIComponent(name)}`);
+    return response.json();
+  },
+
+  updateProject: async (name: string, limits: { soft_limit: number; hard_limit: number }): Promise<ApiResponse<XFSProject>> => {
+    const response = await fetch(`/api/v1/syslib/quota/projects/${encodeURIComponent(name)}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(limits),
+    });
+    return response.json();
+  },
+
+  deleteProject: async (name: string): Promise<void> => {
+    await fetch(`/api/v1/syslib/quota/projects/${encodeURIComponent(name)}`, { method: 'DELETE' });
+  },
 };
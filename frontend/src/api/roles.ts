@@ -0,0 +1,93 @@
+import client, { ApiResponse } from './client';
+
+export interface Permission {
+  resource: string;
+  action: string;
+}
+
+export interface Role {
+  id: number;
+  name: string;
+  description?: string;
+  builtIn: boolean;
+  permissions: Permission[];
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface UserRole {
+  id: number;
+  userId: number;
+  roleId: number;
+  expiresAt?: string;
+  createdAt: string;
+  role: Role;
+}
+
+export interface CreateRoleRequest {
+  name: string;
+  description?: string;
+  permissions: Permission[];
+}
+
+export interface UpdateRoleRequest {
+  name?: string;
+  description?: string;
+}
+
+export interface AssignRoleRequest {
+  roleId: number;
+  expiresAt?: string;
+}
+
+export const rolesApi = {
+  list: async () => {
+    const response = await client.get<ApiResponse<Role[]>>('/admin/roles');
+    return response.data;
+  },
+
+  get: async (id: number) => {
+    const response = await client.get<ApiResponse<Role>>(`/admin/roles/${id}`);
+    return response.data;
+  },
+
+  create: async (data: CreateRoleRequest) => {
+    const response = await client.post<ApiResponse<Role>>('/admin/roles', data);
+    return response.data;
+  },
+
+  update: async (id: number, data: UpdateRoleRequest) => {
+    const response = await client.put<ApiResponse<Role>>(`/admin/roles/${id}`, data);
+    return response.data;
+  },
+
+  delete: async (id: number) => {
+    const response = await client.delete<ApiResponse>(`/admin/roles/${id}`);
+    return response.data;
+  },
+
+  getPermissions: async (id: number) => {
+    const response = await client.get<ApiResponse<Permission[]>>(`/admin/roles/${id}/permissions`);
+    return response.data;
+  },
+
+  setPermissions: async (id: number, permissions: Permission[]) => {
+    const response = await client.put<ApiResponse<Permission[]>>(`/admin/roles/${id}/permissions`, { permissions });
+    return response.data;
+  },
+
+  listUserRoles: async (userId: number) => {
+    const response = await client.get<ApiResponse<UserRole[]>>(`/admin/users/${userId}/roles`);
+    return response.data;
+  },
+
+  assign: async (userId: number, data: AssignRoleRequest) => {
+    const response = await client.post<ApiResponse<UserRole>>(`/admin/users/${userId}/roles`, data);
+    return response.data;
+  },
+
+  revoke: async (userId: number, roleId: number) => {
+    const response = await client.delete<ApiResponse>(`/admin/users/${userId}/roles/${roleId}`);
+    return response.data;
+  },
+};
@@ -22,6 +22,26 @@ export interface IPBlock {
   isPermanent: boolean;
 }
 
+export interface AccountLockout {
+  id: number;
+  createdAt: string;
+  updatedAt: string;
+  expiresAt: string;
+  username: string;
+  reason: string;
+  attempts: number;
+  isActive: boolean;
+}
+
+export interface LockoutPolicy {
+  maxAttempts: number;
+  windowMinutes: number;
+  lockoutMinutes: number;
+  lockoutByIP: boolean;
+  lockoutByUsername: boolean;
+  whitelistIPs: string[];
+}
+
 export interface FailedLoginStats {
   total_attempts: number;
   last_24h_attempts: number;
@@ -76,4 +96,38 @@ export const securityApi = {
     );
     return response.data;
   },
+
+  // Get all locked usernames
+  getLockedAccounts: async () => {
+    const response = await client.get<ApiResponse<AccountLockout[]>>(
+      '/security/locked-accounts'
+    );
+    return response.data;
+  },
+
+  // Unlock a username
+  unlockAccount: async (username: string) => {
+    const response = await client.post<ApiResponse<{ message: string }>>(
+      '/security/unlock-account',
+      { username }
+    );
+    return response.data;
+  },
+
+  // Get the lockout policy (admin only)
+  getLockoutPolicy: async () => {
+    const response = await client.get<ApiResponse<LockoutPolicy>>(
+      '/security/lockout-policy'
+    );
+    return response.data;
+  },
+
+  // Update the lockout policy (admin only)
+  updateLockoutPolicy: async (policy: LockoutPolicy) => {
+    const response = await client.put<ApiResponse<LockoutPolicy>>(
+      '/security/lockout-policy',
+      policy
+    );
+    return response.data;
+  },
 };
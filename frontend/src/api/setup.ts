@@ -3,6 +3,15 @@ import apiClient from './client';
 export interface SetupStatus {
   setupRequired: boolean;
   adminExists: boolean;
+  completedSteps: string[];
+  nextStep?: string;
+}
+
+export interface WizardStep {
+  name: string;
+  description: string;
+  completed: boolean;
+  required: boolean;
 }
 
 export interface InitialSetupRequest {
@@ -10,6 +19,7 @@ export interface InitialSetupRequest {
   email: string;
   password: string;
   fullName: string;
+  enableTelemetry?: boolean;
 }
 
 export interface InitialSetupResponse {
@@ -28,4 +38,18 @@ export const setupApi = {
     const response = await apiClient.post<InitialSetupResponse>('/setup/initialize', data);
     return response.data!;
   },
+
+  async getSteps(): Promise<WizardStep[]> {
+    const response = await apiClient.get<{ steps: WizardStep[] }>('/setup/steps');
+    return response.data!.steps;
+  },
+
+  // Steps after admin-account need the admin to be logged in
+  async completeStep(name: string, data: unknown): Promise<void> {
+    await apiClient.put(`/setup/steps/${name}`, data);
+  },
+
+  async complete(): Promise<void> {
+    await apiClient.post('/setup/complete');
+  },
 };
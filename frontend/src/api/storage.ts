@@ -1,4 +1,5 @@
 import client, { ApiResponse } from './client';
+import { BrowseResponse } from './files';
 
 // ===== Types =====
 
@@ -61,6 +62,40 @@ export interface Volume {
   snapshots?: Snapshot[];
 }
 
+export interface VolumeHealthCheck {
+  id: number;
+  volumeId: string;
+  mountPoint: string;
+  status: 'healthy' | 'failed';
+  latencyMs: number;
+  errorMessage?: string;
+  checkedAt: string;
+}
+
+export interface ShareTransferRequest {
+  destinationVolumeId: string;
+  preservePermissions: boolean;
+  deleteAfterTransfer: boolean;
+}
+
+export interface ShareTransferProgress {
+  id: string;
+  shareId: string;
+  destinationVolumeId: string;
+  sourcePath: string;
+  destinationPath: string;
+  preservePermissions: boolean;
+  deleteAfterTransfer: boolean;
+  status: 'copying' | 'final_sync' | 'completed' | 'failed';
+  progress: number;
+  bytesTransferred: number;
+  rate?: string;
+  error?: string;
+  createdAt: string;
+  updatedAt: string;
+  completedAt?: string;
+}
+
 export interface Snapshot {
   id: string;
   volumeId: string;
@@ -69,6 +104,15 @@ export interface Snapshot {
   createdAt: string;
 }
 
+// Extensions are given without the leading dot; Samba hides denied files,
+// allowExtensions and maxFileSizeMB only apply to uploads through the web UI
+export interface ShareFileFilter {
+  denyExtensions?: string[];
+  allowExtensions?: string[];
+  denyPatterns?: string[];
+  maxFileSizeMB?: number;
+}
+
 export interface Share {
   id: string;
   name: string;
@@ -82,6 +126,7 @@ export interface Share {
   guestOk: boolean;
   validUsers?: string[];
   validGroups?: string[];
+  fileFilter?: ShareFileFilter;
   createdAt: string;
   updatedAt: string;
 }
@@ -138,6 +183,7 @@ export interface CreateShareRequest {
   guestOk: boolean;
   validUsers?: string[];
   validGroups?: string[];
+  fileFilter?: ShareFileFilter;
 }
 
 export interface FormatDiskRequest {
@@ -147,6 +193,36 @@ export interface FormatDiskRequest {
   force: boolean;
 }
 
+export interface ShareAccessLog {
+  id: number;
+  shareName: string;
+  protocol: 'smb' | 'nfs';
+  username?: string;
+  clientIp: string;
+  connectedAt: string;
+  disconnectedAt?: string;
+  filesRead: number;
+  filesWritten: number;
+}
+
+export interface ShareAccessLogResponse {
+  logs: ShareAccessLog[];
+  total: number;
+  limit: number;
+  offset: number;
+}
+
+export interface ShareSnapshot {
+  name: string;
+  path: string;
+  modTime: string;
+}
+
+export interface RestoreSnapshotFileRequest {
+  filePath: string; // Relative to the share
+  restoreTo?: string; // Relative to the share, defaults to filePath
+}
+
 // ===== API Client =====
 
 export const storageApi = {
@@ -218,6 +294,13 @@ export const storageApi = {
     return response.data;
   },
 
+  getVolumeHealthHistory: async (id: string, limit = 100) => {
+    const response = await client.get<ApiResponse<VolumeHealthCheck[]>>(
+      `/storage/volumes/${id}/health-history?limit=${limit}`
+    );
+    return response.data;
+  },
+
   createVolume: async (data: CreateVolumeRequest) => {
     const response = await client.post<ApiResponse<Volume>>('/storage/volumes', data);
     return response.data;
@@ -263,4 +346,47 @@ export const storageApi = {
     const response = await client.post<ApiResponse<{ message: string }>>(`/storage/shares/${id}/disable`);
     return response.data;
   },
+
+  transferShare: async (id: string, data: ShareTransferRequest) => {
+    const response = await client.post<ApiResponse<{ jobId: string; shareId: string }>>(
+      `/storage/shares/${id}/transfer`,
+      data
+    );
+    return response.data;
+  },
+
+  getShareTransferProgress: async (id: string, jobId: string) => {
+    const response = await client.get<ApiResponse<ShareTransferProgress>>(
+      `/storage/shares/${id}/transfer/${jobId}/progress`
+    );
+    return response.data;
+  },
+
+  getShareAccessLog: async (id: string, limit = 100, offset = 0) => {
+    const response = await client.get<ApiResponse<ShareAccessLogResponse>>(
+      `/storage/shares/${id}/access-log?limit=${limit}&offset=${offset}`
+    );
+    return response.data;
+  },
+
+  listShareSnapshots: async (id: string) => {
+    const response = await client.get<ApiResponse<ShareSnapshot[]>>(`/storage/shares/${id}/snapshots`);
+    return response.data;
+  },
+
+  browseShareSnapshot: async (id: string, snapshot: string, path = '/', showHidden = false) => {
+    const response = await client.get<ApiResponse<BrowseResponse>>(
+      `/storage/shares/${id}/snapshots/${encodeURIComponent(snapshot)}/browse`,
+      { params: { path, showHidden } }
+    );
+    return response.data;
+  },
+
+  restoreShareSnapshotFile: async (id: string, snapshot: string, req: RestoreSnapshotFileRequest) => {
+    const response = await client.post<ApiResponse<{ message: string }>>(
+      `/storage/shares/${id}/snapshots/${encodeURIComponent(snapshot)}/restore`,
+      req
+    );
+    return response.data;
+  },
 };
@@ -38,6 +38,94 @@ export interface CreateZFSPoolRequest {
   options?: Record<string, string>;
 }
 
+export interface ZFSScrubResult {
+  pool: string;
+  state: 'none' | 'in_progress' | 'completed' | 'canceled';
+  start_time?: string;
+  end_time?: string;
+  data_examined: number;  // Bytes scanned so far while in progress
+  repaired_bytes: number;
+  errors: number;
+  progress: number;       // Percent done while in progress
+}
+
+export interface ZFSScrubSchedule {
+  pool: string;
+  schedule: string;       // Cron expression, empty without a schedule
+  last_scrub?: ZFSScrubResult;
+}
+
+export interface AutoTieringPolicy {
+  hot_pool: string;
+  cold_pool: string;
+  cold_threshold_days: number;
+  max_cold_transfer_mb: number; // 0 for no limit
+  schedule?: string;
+}
+
+export interface DatasetTemperature {
+  dataset: string;
+  mountpoint: string;
+  used: number;
+  atime_enabled: boolean;
+  files: number;
+  bytes: number;
+  last_access?: string;
+  idle_days: number;
+  temperature: 'hot' | 'warm' | 'cold';
+  has_children: boolean;
+}
+
+export interface AutoTieringReport {
+  hot_pool: string;
+  cold_pool: string;
+  started_at: string;
+  finished_at: string;
+  transferred_bytes: number;
+  datasets: {
+    dataset: string;
+    target?: string;
+    bytes: number;
+    idle_days: number;
+    status: 'migrated' | 'skipped' | 'failed';
+    reason?: string;
+  }[];
+}
+
+export interface CreateEncryptedDatasetRequest {
+  pool: string;
+  name: string;
+  passphrase?: string;                        // Omitted with a file:// key location
+  algorithm?: 'aes-256-gcm' | 'aes-256-ccm';  // Default aes-256-gcm
+  key_format?: 'passphrase' | 'raw' | 'hex';  // Default passphrase
+  key_location?: string;                      // prompt (default) or file:///path
+}
+
+// BTRFS Types
+export interface BTRFSSubvolume {
+  id: number;
+  parent_id: number;
+  top_level: number;
+  path: string;
+  uuid: string;
+  parent_uuid?: string;
+  read_only: boolean;
+}
+
+export interface BTRFSUsage {
+  device_size: number;
+  device_allocated: number;
+  device_unallocated: number;
+  device_missing: number;
+  used: number;
+  free_estimated: number;
+  free_min: number;
+  data_ratio: number;
+  metadata_ratio: number;
+  global_reserve: number;
+  block_groups: Array<{ type: string; profile: string; size: number; used: number }>;
+}
+
 // RAID Types
 export interface RAIDArray {
   name: string;
@@ -72,6 +160,23 @@ export interface SMARTInfo {
   healthScore: number;
 }
 
+export interface SMARTTestResult {
+  num: number;
+  type: string;
+  status: string;
+  failed: boolean;
+  remaining: number;
+  lifetime_hours: number;
+  lba_of_first_error?: number;
+  occurred_at?: string;
+}
+
+export interface SMARTTestSchedule {
+  device: string;
+  short: string;
+  long: string;
+}
+
 // Samba Types
 export interface SambaShare {
   name: string;
@@ -172,6 +277,89 @@ export const syslibApi = {
       const response = await client.get<ApiResponse<ZFSSnapshot[]>>(`/syslib/zfs/datasets/${dataset}/snapshots`);
       return response.data;
     },
+
+    getScrubSchedule: async (pool: string) => {
+      const response = await client.get<ApiResponse<ZFSScrubSchedule>>(`/syslib/zfs/pools/${pool}/scrub-schedule`);
+      return response.data;
+    },
+
+    setScrubSchedule: async (pool: string, schedule: string) => {
+      const response = await client.put<ApiResponse<ZFSScrubSchedule>>(`/syslib/zfs/pools/${pool}/scrub-schedule`, { schedule });
+      return response.data;
+    },
+
+    deleteScrubSchedule: async (pool: string) => {
+      await client.delete(`/syslib/zfs/pools/${pool}/scrub-schedule`);
+    },
+
+    analyzeAutoTiering: async (pool: string) => {
+      const response = await client.post<ApiResponse<DatasetTemperature[]>>('/syslib/zfs/auto-tiering/analyze', { pool });
+      return response.data;
+    },
+
+    runAutoTiering: async (policy: AutoTieringPolicy) => {
+      const response = await client.post<ApiResponse<AutoTieringReport>>('/syslib/zfs/auto-tiering/run', policy);
+      return response.data;
+    },
+
+    createEncryptedDataset: async (data: CreateEncryptedDatasetRequest) => {
+      const response = await client.post<ApiResponse<{ message: string; dataset: string }>>('/syslib/zfs/datasets/encrypted', data);
+      return response.data;
+    },
+
+    mountEncryptedDataset: async (dataset: string, passphrase?: string) => {
+      const response = await client.post<ApiResponse<{ message: string }>>(`/syslib/zfs/datasets/${encodeURIComponent(dataset)}/mount-encrypted`, { passphrase });
+      return response.data;
+    },
+
+    unmountEncryptedDataset: async (dataset: string) => {
+      const response = await client.post<ApiResponse<{ message: string }>>(`/syslib/zfs/datasets/${encodeURIComponent(dataset)}/unmount-encrypted`, {});
+      return response.data;
+    },
+
+    changeEncryptionKey: async (dataset: string, oldPassphrase: string, passphrase: string) => {
+      const response = await client.post<ApiResponse<{ message: string }>>(`/syslib/zfs/datasets/${encodeURIComponent(dataset)}/change-key`, {
+        old_passphrase: oldPassphrase,
+        passphrase,
+      });
+      return response.data;
+    },
+  },
+
+  // BTRFS Operations
+  btrfs: {
+    listSubvolumes: async (mountpoint: string) => {
+      const response = await client.get<ApiResponse<BTRFSSubvolume[]>>(`/syslib/btrfs/subvolumes?mountpoint=${encodeURIComponent(mountpoint)}`);
+      return response.data;
+    },
+
+    createSubvolume: async (path: string) => {
+      const response = await client.post<ApiResponse<{ message: string; path: string }>>('/syslib/btrfs/subvolumes', { path });
+      return response.data;
+    },
+
+    deleteSubvolume: async (path: string) => {
+      await client.delete(`/syslib/btrfs/subvolumes?path=${encodeURIComponent(path)}`);
+    },
+
+    createSnapshot: async (source: string, destination: string, readOnly: boolean = true) => {
+      const response = await client.post<ApiResponse<{ message: string; snapshot: string }>>('/syslib/btrfs/snapshots', {
+        source,
+        destination,
+        read_only: readOnly,
+      });
+      return response.data;
+    },
+
+    sendReceive: async (snapshot: string, destination: string, parent?: string) => {
+      const response = await client.post<ApiResponse<{ message: string }>>('/syslib/btrfs/send-receive', { snapshot, destination, parent });
+      return response.data;
+    },
+
+    getUsage: async (mountpoint: string) => {
+      const response = await client.get<ApiResponse<BTRFSUsage>>(`/syslib/btrfs/usage?mountpoint=${encodeURIComponent(mountpoint)}`);
+      return response.data;
+    },
   },
 
   // RAID Operations
@@ -203,6 +391,21 @@ export const syslibApi = {
       const response = await client.post<ApiResponse<{ message: string; type: string }>>(`/syslib/smart/${device}/test?type=${type}`, {});
       return response.data;
     },
+
+    getTestHistory: async (device: string) => {
+      const response = await client.get<ApiResponse<SMARTTestResult[]>>(`/syslib/smart/${device}/history`);
+      return response.data;
+    },
+
+    getSchedule: async (device: string) => {
+      const response = await client.get<ApiResponse<SMARTTestSchedule>>(`/syslib/smart/${device}/schedule`);
+      return response.data;
+    },
+
+    setSchedule: async (device: string, schedule: { short: string; long: string }) => {
+      const response = await client.put<ApiResponse<SMARTTestSchedule>>(`/syslib/smart/${device}/schedule`, schedule);
+      return response.data;
+    },
   },
 
   // Samba Operations
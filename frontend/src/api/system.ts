@@ -78,6 +78,111 @@ export interface VersionInfo {
   commit?: string;
 }
 
+export interface TelemetryPayload {
+  nodeId: string;
+  version: string;
+  os: string;
+  platform: string;
+  arch: string;
+  uptimeSeconds: number;
+  features: string[];
+  shareCount: number;
+  userCount: number;
+  cpuCount: number;
+  memoryGb: number;
+}
+
+export interface TelemetrySettings {
+  enabled: boolean;
+  collectorUrl: string;
+  nodeId: string;
+  lastReportAt?: string;
+  payload?: TelemetryPayload;
+}
+
+export type StartupStageState = 'pending' | 'running' | 'complete' | 'failed' | 'skipped';
+
+export interface StartupStage {
+  name: string;
+  dependsOn?: string[];
+  critical: boolean;
+  state: StartupStageState;
+  error?: string;
+  startedAt?: string;
+  finishedAt?: string;
+  durationMs?: number;
+}
+
+export interface StartupStatus {
+  ready: boolean;
+  completed: number;
+  total: number;
+  stages: StartupStage[];
+}
+
+export interface PackageStatus {
+  name: string;
+  installedVersion?: string;
+  requiredVersion?: string;
+  status: 'installed' | 'missing' | 'outdated';
+  required: boolean;
+  usedBy: string[];
+}
+
+export interface DependencyReport {
+  packages: PackageStatus[];
+  summary: {
+    total: number;
+    installed: number;
+    missing: number;
+    outdated: number;
+    requiredMissing: number;
+  };
+  generatedAt: string;
+}
+
+export interface SystemPackageUpdate {
+  name: string;
+  currentVersion: string;
+  newVersion: string;
+  architecture: string;
+  origins: string[];
+  security: boolean;
+}
+
+export interface SystemUpdateReport {
+  startedAt: string;
+  finishedAt: string;
+  automatic: boolean;
+  snapshot?: string;
+  applied: SystemPackageUpdate[];
+  failed: (SystemPackageUpdate & { error: string })[];
+}
+
+export interface BenchmarkResult {
+  id: number;
+  runId: string;
+  hostname: string;
+  test: 'disk' | 'network' | 'cpu';
+  target: string;
+  metric: string;
+  value: number;
+  unit: string;
+  createdAt: string;
+}
+
+export interface NASProcess {
+  pid: number;
+  name: string;
+  command: string; // Empty for kernel threads
+  state: string;
+  rss: number; // kB
+  vmSwap: number; // kB
+  cpuPercent: number;
+  openFiles: number; // -1 if not readable
+  uptime: number; // Seconds
+}
+
 export const systemApi = {
   getInfo: async () => {
     const response = await client.get<ApiResponse<SystemInfo>>('/system/info');
@@ -110,4 +215,50 @@ export const systemApi = {
     const response = await client.get<ApiResponse<UpdateCheckResult>>(url);
     return response.data;
   },
+
+  // System packages with their version and install status (dpkg-based systems only)
+  getDependencies: async () => {
+    const response = await client.get<ApiResponse<DependencyReport>>('/system/dependencies');
+    return response.data;
+  },
+
+  // Pending security updates of the system packages (apt-based systems only)
+  getSecurityUpdates: async () => {
+    const response = await client.get<ApiResponse<SystemPackageUpdate[]>>('/system/updates/security');
+    return response.data;
+  },
+
+  applySecurityUpdates: async () => {
+    const response = await client.post<ApiResponse<SystemUpdateReport>>('/system/updates/security/apply');
+    return response.data;
+  },
+
+  // Running Samba, NFS, VPN, Docker and cluster daemons
+  getProcesses: async () => {
+    const response = await client.get<ApiResponse<NASProcess[]>>('/system/processes');
+    return response.data;
+  },
+
+  // Results uploaded by stumpfctl system benchmark, newest first
+  getBenchmarkResults: async (params?: { test?: string; limit?: number }) => {
+    const response = await client.get<ApiResponse<BenchmarkResult[]>>('/system/benchmark/results', { params });
+    return response.data;
+  },
+
+  // Progress of the service startup; available while the server starts
+  getStartupStatus: async () => {
+    const response = await client.get<ApiResponse<StartupStatus>>('/system/startup-status');
+    return response.data;
+  },
+
+  // Anonymous usage statistics
+  getTelemetry: async () => {
+    const response = await client.get<ApiResponse<TelemetrySettings>>('/admin/telemetry');
+    return response.data;
+  },
+
+  updateTelemetry: async (data: { enabled?: boolean; collectorUrl?: string }) => {
+    const response = await client.put<ApiResponse<TelemetrySettings>>('/admin/telemetry', data);
+    return response.data;
+  },
 };
@@ -5,6 +5,20 @@ export interface TwoFAStatus {
   backupCodesRemaining: number;
 }
 
+export interface BackupCodeStatus {
+  id: number;
+  used: boolean;
+  usedAt?: string;
+  createdAt: string;
+}
+
+export interface BackupCodesStatus {
+  total: number;
+  used: number;
+  remaining: number;
+  codes: BackupCodeStatus[];
+}
+
 export interface TwoFASetupResponse {
   secret: string;
   qrCodeUrl: string;
@@ -62,6 +76,14 @@ export const twofaApi = {
     return response.data.data.backupCodes;
   },
 
+  /**
+   * Get which backup codes were used (the codes themselves are never returned)
+   */
+  getBackupCodesStatus: async (): Promise<BackupCodesStatus> => {
+    const response = await client.get('/2fa/backup-codes/status');
+    return response.data.data;
+  },
+
   /**
    * Complete login with 2FA code
    */
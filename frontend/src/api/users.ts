@@ -17,6 +17,61 @@ export interface UpdateUserRequest {
   password?: string;
 }
 
+export interface SSHKey {
+  id: string;
+  userId: number;
+  fingerprint: string;
+  label: string;
+  keyType: string;
+  publicKey: string;
+  createdAt: string;
+  lastUsedAt?: string;
+}
+
+export interface BulkImportOptions {
+  dryRun?: boolean;
+  onConflict?: 'skip' | 'update' | 'error';
+  defaultPassword?: string;
+  sendWelcomeEmail?: boolean;
+}
+
+export interface BulkImportResult {
+  created: number;
+  updated: number;
+  skipped: number;
+  failed: number;
+  errors: { row: number; username?: string; message: string }[];
+}
+
+export interface ShareStat {
+  share: string;
+  accesses: number;
+}
+
+export interface UserActivityReport {
+  userId: number;
+  username: string;
+  since: string;
+  lastLogin?: string;
+  loginCount: number;
+  sharesAccessed: string[];
+  filesCreated: number;
+  filesModified: number;
+  filesDeleted: number;
+  vpnHours: number;
+  topAccessedShares: ShareStat[];
+}
+
+export interface UserActivity {
+  userId: number;
+  username: string;
+  email: string;
+  role: string;
+  createdAt: string;
+  lastLogin?: string;
+  daysInactive: number;
+}
+
 export const usersApi = {
   list: async () => {
     const response = await client.get<ApiResponse<User[]>>('/users');
@@ -42,4 +97,49 @@ export const usersApi = {
     const response = await client.delete<ApiResponse>(`/users/${id}`);
     return response.data;
   },
+
+  import: async (file: File, format: 'csv' | 'ldif', options: BulkImportOptions = {}) => {
+    const formData = new FormData();
+    formData.append('file', file);
+    formData.append('dryRun', String(!!options.dryRun));
+    formData.append('onConflict', options.onConflict ?? 'skip');
+    formData.append('sendWelcomeEmail', String(!!options.sendWelcomeEmail));
+    if (options.defaultPassword) {
+      formData.append('defaultPassword', options.defaultPassword);
+    }
+    const response = await client.post<ApiResponse<BulkImportResult>>(`/users/import?format=${format}`, formData, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    });
+    return response.data;
+  },
+
+  getActivity: async (id: number, since = '30d') => {
+    const response = await client.get<ApiResponse<UserActivityReport>>(`/users/${id}/activity`, { params: { since } });
+    return response.data;
+  },
+
+  listInactive: async (days = 90) => {
+    const response = await client.get<ApiResponse<UserActivity[]>>('/users/inactive', { params: { days } });
+    return response.data;
+  },
+
+  listSSHKeys: async (id: number) => {
+    const response = await client.get<ApiResponse<SSHKey[]>>(`/users/${id}/ssh-keys`);
+    return response.data;
+  },
+
+  addSSHKey: async (id: number, key: string, label?: string) => {
+    const response = await client.post<ApiResponse<SSHKey>>(`/users/${id}/ssh-keys`, { key, label });
+    return response.data;
+  },
+
+  updateSSHKey: async (id: number, keyId: string, label: string) => {
+    const response = await client.put<ApiResponse<SSHKey>>(`/users/${id}/ssh-keys/${keyId}`, { label });
+    return response.data;
+  },
+
+  deleteSSHKey: async (id: number, keyId: string) => {
+    const response = await client.delete<ApiResponse>(`/users/${id}/ssh-keys/${keyId}`);
+    return response.data;
+  },
 };
@@ -26,6 +26,38 @@ export interface VMCreateRequest {
   ssh_key?: string; // SSH public key for passwordless authentication
 }
 
+export interface VCPUPin {
+  vcpu: number;
+  cpuset: string; // e.g. "2-3" or "4,12"
+}
+
+export interface VMDisk {
+  path: string;
+  size: number;
+  format: string; // qcow2, raw
+  bus: string; // virtio, sata, scsi, usb, ide
+  target: string; // Device name in the guest, e.g. vdb
+  device: string; // disk, cdrom
+  cache?: string;
+  io?: string;
+  read_only: boolean;
+  shareable: boolean;
+}
+
+export interface VMDiskOptions {
+  cache?: 'none' | 'writethrough' | 'writeback' | 'directsync' | 'unsafe';
+  io?: 'native' | 'threads' | 'io_uring';
+  read_only?: boolean;
+  shareable?: boolean;
+}
+
+export interface NUMANode {
+  id: number;
+  cpus: number[];
+  memory_mb: number;
+  free_mb: number;
+}
+
 export const vmsApi = {
   // List all VMs
   listVMs: async (): Promise<ApiResponse<VM[]>> => {
@@ -68,4 +100,51 @@ export const vmsApi = {
     const response = await client.get<ApiResponse<{ vm_id: string; port: number }>>(`/vms/${encodeURIComponent(vmId)}/vnc`);
     return response.data;
   },
+
+  // Pin virtual CPUs to host CPUs
+  setCPUPinning: async (name: string, pins: VCPUPin[]): Promise<ApiResponse<{ message: string }>> => {
+    const response = await client.put<ApiResponse<{ message: string }>>(`/syslib/vms/${encodeURIComponent(name)}/cpu-pinning`, { pins });
+    return response.data;
+  },
+
+  // Back VM memory with hugepages on specific NUMA nodes
+  setMemoryBacking: async (name: string, hugepages: boolean, nodeset = ''): Promise<ApiResponse<{ message: string }>> => {
+    const response = await client.put<ApiResponse<{ message: string }>>(`/syslib/vms/${encodeURIComponent(name)}/memory-backing`, { hugepages, nodeset });
+    return response.data;
+  },
+
+  // Disks attached to a VM; changes apply to running VMs right away
+  listDisks: async (name: string): Promise<ApiResponse<VMDisk[]>> => {
+    const response = await client.get<ApiResponse<VMDisk[]>>(`/syslib/vms/${encodeURIComponent(name)}/disks`);
+    return response.data;
+  },
+
+  addDisk: async (name: string, imageFile: string, bus = 'virtio', options: VMDiskOptions = {}): Promise<ApiResponse<VMDisk[]>> => {
+    const response = await client.post<ApiResponse<VMDisk[]>>(`/syslib/vms/${encodeURIComponent(name)}/disks`, {
+      image_file: imageFile,
+      bus,
+      ...options,
+    });
+    return response.data;
+  },
+
+  resizeDisk: async (name: string, target: string, sizeGB: number): Promise<ApiResponse<{ message: string }>> => {
+    const response = await client.put<ApiResponse<{ message: string }>>(
+      `/syslib/vms/${encodeURIComponent(name)}/disks/${encodeURIComponent(target)}`,
+      { size_gb: sizeGB }
+    );
+    return response.data;
+  },
+
+  removeDisk: async (name: string, target: string, deleteImage = false): Promise<void> => {
+    await client.delete(`/syslib/vms/${encodeURIComponent(name)}/disks/${encodeURIComponent(target)}`, {
+      params: { delete_image: deleteImage },
+    });
+  },
+
+  // Get the host NUMA topology
+  getNUMATopology: async (): Promise<ApiResponse<{ nodes: NUMANode[] }>> => {
+    const response = await client.get<ApiResponse<{ nodes: NUMANode[] }>>('/syslib/numa/topology');
+    return response.data;
+  },
 };
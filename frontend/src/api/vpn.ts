@@ -0,0 +1,131 @@
+import client, { ApiResponse } from './client';
+
+// WireGuard Types
+export interface WireGuardInterface {
+  name: string;
+  configPath?: string;
+  listenPort: number;
+  address: string; // CIDR notation, e.g. 10.8.0.1/24
+  privateKey?: string; // Generated when empty on creation; never returned
+  publicKey?: string;
+}
+
+export interface WireGuardPeer {
+  name?: string;
+  publicKey: string;
+  allowedIPs: string[];
+  endpoint?: string; // host:port, for site-to-site tunnels
+  persistentKeepalive?: number;
+}
+
+export interface VPNPeer {
+  id: number;
+  interface: string;
+  name?: string;
+  publicKey: string;
+  allowedIPs: string[]; // Tunnel addresses of the peer
+  routeAllTraffic: boolean;
+  allowedCIDRs: string[];
+  excludedCIDRs: string[];
+  clientAllowedIPs: string[]; // Routed through the tunnel by the client
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface SplitTunnelPolicy {
+  allowedCIDRs: string[];
+  excludedCIDRs: string[];
+  routeAllTraffic: boolean;
+}
+
+export interface WireGuardInterfaceDetails {
+  interface: WireGuardInterface;
+  peers: WireGuardPeer[];
+}
+
+export interface WireGuardIfaceStats {
+  interface: string;
+  listenPort: number;
+  fwMark: number;
+  numberOfPeers: number;
+  totalRxBytes: number;
+  totalTxBytes: number;
+}
+
+export interface VPNInterfaceStatus {
+  name: string;
+  listenPort: number;
+  running: boolean;
+  peers: number;
+  activePeers: number;
+}
+
+export interface VPNProtocolStatus {
+  protocol: string;
+  installed: boolean;
+  running: boolean;
+  interfaces: VPNInterfaceStatus[];
+  peers: number;
+  activePeers: number;
+}
+
+// VPN API
+export const vpnApi = {
+  // Get the status of a VPN protocol across all its interfaces
+  getStatus: async (protocol: string = 'wireguard'): Promise<ApiResponse<VPNProtocolStatus>> => {
+    const response = await client.get<ApiResponse<VPNProtocolStatus>>('/vpn/status', { params: { protocol } });
+    return response.data;
+  },
+
+  listWireGuardInterfaces: async (): Promise<ApiResponse<WireGuardInterface[]>> => {
+    const response = await client.get<ApiResponse<WireGuardInterface[]>>('/vpn/wireguard/interfaces');
+    return response.data;
+  },
+
+  getWireGuardInterface: async (name: string): Promise<ApiResponse<WireGuardInterfaceDetails>> => {
+    const response = await client.get<ApiResponse<WireGuardInterfaceDetails>>(`/vpn/wireguard/interfaces/${encodeURIComponent(name)}`);
+    return response.data;
+  },
+
+  // Traffic counters of a running interface
+  getWireGuardInterfaceStats: async (name: string): Promise<ApiResponse<WireGuardIfaceStats>> => {
+    const response = await client.get<ApiResponse<WireGuardIfaceStats>>(`/vpn/wireguard/interfaces/${encodeURIComponent(name)}/stats`);
+    return response.data;
+  },
+
+  createWireGuardInterface: async (data: WireGuardInterface): Promise<ApiResponse<WireGuardInterface>> => {
+    const response = await client.post<ApiResponse<WireGuardInterface>>('/vpn/wireguard/interfaces', data);
+    return response.data;
+  },
+
+  updateWireGuardInterface: async (name: string, data: Pick<WireGuardInterface, 'listenPort' | 'address'>): Promise<ApiResponse<WireGuardInterface>> => {
+    const response = await client.put<ApiResponse<WireGuardInterface>>(`/vpn/wireguard/interfaces/${encodeURIComponent(name)}`, data);
+    return response.data;
+  },
+
+  deleteWireGuardInterface: async (name: string): Promise<void> => {
+    await client.delete(`/vpn/wireguard/interfaces/${encodeURIComponent(name)}`);
+  },
+
+  createWireGuardPeer: async (name: string, peer: WireGuardPeer & { ipamPool?: string }): Promise<ApiResponse<WireGuardPeer>> => {
+    const response = await client.post<ApiResponse<WireGuardPeer>>(`/vpn/wireguard/interfaces/${encodeURIComponent(name)}/peers`, peer);
+    return response.data;
+  },
+
+  listWireGuardPeers: async (name: string): Promise<ApiResponse<VPNPeer[]>> => {
+    const response = await client.get<ApiResponse<VPNPeer[]>>(`/vpn/wireguard/interfaces/${encodeURIComponent(name)}/peers`);
+    return response.data;
+  },
+
+  // Get the wg-quick configuration of a peer's client
+  getPeerClientConfig: async (id: number): Promise<ApiResponse<{ config: string }>> => {
+    const response = await client.get<ApiResponse<{ config: string }>>(`/vpn/wireguard/peers/${id}/config`);
+    return response.data;
+  },
+
+  // Set the traffic a peer's client routes through the tunnel
+  setPeerSplitTunnel: async (id: number, policy: SplitTunnelPolicy): Promise<ApiResponse<{ config: string }>> => {
+    const response = await client.put<ApiResponse<{ config: string }>>(`/vpn/wireguard/peers/${id}/split-tunnel`, policy);
+    return response.data;
+  },
+};
@@ -6,11 +6,6 @@ interface FileThumbnailProps {
   size?: 'small' | 'medium' | 'large';
 }
 
-const isImageFile = (file: FileInfo): boolean => {
-  if (!file.mimeType) return false;
-  return file.mimeType.startsWith('image/');
-};
-
 const FileThumbnail: React.FC<FileThumbnailProps> = ({ file, size = 'medium' }) => {
   const [imageError, setImageError] = useState(false);
   const [imageLoaded, setImageLoaded] = useState(false);
@@ -28,14 +23,21 @@ const FileThumbnail: React.FC<FileThumbnailProps> = ({ file, size = 'medium' })
     large: 'text-7xl',
   };
 
-  // If not an image or image failed to load, show icon
-  if (!isImageFile(file) || imageError) {
+  // Thumbnail pixel sizes (requested at 2x for high-DPI screens)
+  const thumbnailSizes = {
+    small: 96,
+    medium: 160,
+    large: 256,
+  };
+
+  // If no thumbnail is available or it failed to load, show icon
+  if (!file.hasThumbnail || imageError) {
     return <div className={iconSizes[size]}>{getFileIcon(file)}</div>;
   }
 
   // Construct image URL
   const token = localStorage.getItem('token');
-  const imageUrl = `/api/files/download?path=${encodeURIComponent(file.path)}&token=${token}`;
+  const imageUrl = `/api/v1/files/thumbnail?path=${encodeURIComponent(file.path)}&size=${thumbnailSizes[size]}&token=${token}`;
 
   return (
     <div className={`${sizeClasses[size]} relative flex items-center justify-center`}>
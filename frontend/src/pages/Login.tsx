@@ -42,6 +42,13 @@ export default function Login({ onSuccess }: LoginProps) {
           return;
         }
 
+        // The MFA policy requires 2FA, but the user has not set it up yet
+        if (response.data.requires2FASetup) {
+          setError('Two-factor authentication is required for your account. Please set it up to continue.');
+          setIsLoading(false);
+          return;
+        }
+
         // Normal login (no 2FA)
         const { accessToken, refreshToken, user } = response.data;
         if (accessToken && refreshToken && user) {
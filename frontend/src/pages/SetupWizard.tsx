@@ -15,6 +15,7 @@ export default function SetupWizard({ onComplete }: SetupWizardProps) {
   const [password, setPassword] = useState('');
   const [confirmPassword, setConfirmPassword] = useState('');
   const [fullName, setFullName] = useState('');
+  const [enableTelemetry, setEnableTelemetry] = useState(false);
   const [error, setError] = useState('');
   const [isLoading, setIsLoading] = useState(false);
 
@@ -46,6 +47,7 @@ export default function SetupWizard({ onComplete }: SetupWizardProps) {
         email,
         password,
         fullName,
+        enableTelemetry,
       });
 
       // Setup complete - redirect to login
@@ -190,6 +192,29 @@ export default function SetupWizard({ onComplete }: SetupWizardProps) {
                 />
               </motion.div>
 
+              <motion.div
+                initial={{ x: -20, opacity: 0 }}
+                animate={{ x: 0, opacity: 1 }}
+                transition={{ delay: 0.42 }}
+              >
+                <label className="flex items-start gap-2 cursor-pointer">
+                  <input
+                    type="checkbox"
+                    checked={enableTelemetry}
+                    onChange={(e) => setEnableTelemetry(e.target.checked)}
+                    disabled={isLoading}
+                    className="mt-0.5 w-4 h-4 text-macos-blue bg-white dark:bg-macos-dark-100 border-gray-300 dark:border-macos-dark-300 rounded focus:ring-macos-blue"
+                  />
+                  <span className="text-sm text-gray-700 dark:text-gray-300">
+                    Send anonymous usage statistics
+                    <span className="block text-xs text-gray-500 dark:text-gray-400">
+                      Version, active services, share and user counts and a hardware summary once a day.
+                      No usernames, addresses or file paths. You can change this later.
+                    </span>
+                  </span>
+                </label>
+              </motion.div>
+
               {error && (
                 <motion.div
                   initial={{ opacity: 0, y: -10 }}